@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"Bt1QFM/model"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	artistInfoKeyPrefix = "artist:netease:"
+	// ArtistInfoTTL 网易云歌手详情的缓存时间
+	ArtistInfoTTL = 6 * time.Hour
+	// ArtistMissTTL 网易云上找不到的歌手的缓存时间，避免重复搜索
+	ArtistMissTTL = 30 * time.Minute
+)
+
+// artistMissMarker 表示网易云上没有该歌手
+const artistMissMarker = "null"
+
+func artistInfoKey(name string) string {
+	return artistInfoKeyPrefix + strings.ToLower(strings.TrimSpace(name))
+}
+
+// GetArtistInfo 获取缓存的网易云歌手详情
+// found 为 false 表示没有缓存；found 为 true 且 info 为 nil 表示已确认网易云上没有该歌手
+func GetArtistInfo(ctx context.Context, name string) (info *model.NeteaseArtistInfo, found bool, err error) {
+	if RedisClient == nil {
+		return nil, false, fmt.Errorf("redis client not initialized")
+	}
+
+	data, err := RedisClient.Get(ctx, artistInfoKey(name)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get artist info: %w", err)
+	}
+	if string(data) == artistMissMarker {
+		return nil, true, nil
+	}
+	info = &model.NeteaseArtistInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal artist info: %w", err)
+	}
+	return info, true, nil
+}
+
+// SetArtistInfo 缓存网易云歌手详情，info 为 nil 时缓存"未找到"
+func SetArtistInfo(ctx context.Context, name string, info *model.NeteaseArtistInfo) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	data, ttl := []byte(artistMissMarker), ArtistMissTTL
+	if info != nil {
+		var err error
+		if data, err = json.Marshal(info); err != nil {
+			return fmt.Errorf("failed to marshal artist info: %w", err)
+		}
+		ttl = ArtistInfoTTL
+	}
+	if err := RedisClient.Set(ctx, artistInfoKey(name), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set artist info: %w", err)
+	}
+	return nil
+}
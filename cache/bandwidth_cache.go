@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/model"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// bandwidthKeyPrefix 每天一个 hash，字段为用户ID，值为当天向该用户发送的字节数
+	bandwidthKeyPrefix = "bandwidth:daily:"
+	// bandwidthTTL 汇总服务停机几天后仍能补汇总
+	bandwidthTTL = 8 * 24 * time.Hour
+)
+
+func bandwidthKey(day string) string {
+	return bandwidthKeyPrefix + day
+}
+
+// IncrBandwidth 累加用户在 at 当天的流量，返回当天累计的字节数
+func IncrBandwidth(ctx context.Context, userID, bytes int64, at time.Time) (int64, error) {
+	if RedisClient == nil {
+		return 0, fmt.Errorf("redis client not initialized")
+	}
+
+	key := bandwidthKey(at.Format(PlayCountDayLayout))
+	pipe := RedisClient.TxPipeline()
+	incr := pipe.HIncrBy(ctx, key, strconv.FormatInt(userID, 10), bytes)
+	pipe.Expire(ctx, key, bandwidthTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to increment bandwidth: %w", err)
+	}
+	return incr.Val(), nil
+}
+
+// GetUserBandwidth 获取用户某天（格式为 PlayCountDayLayout）还在 Redis 中的流量，没有记录时返回 0
+func GetUserBandwidth(ctx context.Context, userID int64, day string) (int64, error) {
+	if RedisClient == nil {
+		return 0, fmt.Errorf("redis client not initialized")
+	}
+
+	bytes, err := RedisClient.HGet(ctx, bandwidthKey(day), strconv.FormatInt(userID, 10)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get bandwidth: %w", err)
+	}
+	return bytes, nil
+}
+
+// GetBandwidthUsage 获取某天每个用户的流量，没有记录时返回空列表
+func GetBandwidthUsage(ctx context.Context, day string) ([]model.BandwidthUsage, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	fields, err := RedisClient.HGetAll(ctx, bandwidthKey(day)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bandwidth usage: %w", err)
+	}
+	usage := make([]model.BandwidthUsage, 0, len(fields))
+	for field, value := range fields {
+		userID, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		bytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || bytes <= 0 {
+			continue
+		}
+		usage = append(usage, model.BandwidthUsage{UserID: userID, Day: day, Bytes: bytes})
+	}
+	return usage, nil
+}
+
+// DeleteBandwidthUsage 删除已汇总到数据库的某天流量
+func DeleteBandwidthUsage(ctx context.Context, day string) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	if err := RedisClient.Del(ctx, bandwidthKey(day)).Err(); err != nil {
+		return fmt.Errorf("failed to delete bandwidth usage: %w", err)
+	}
+	return nil
+}
+
+// BandwidthDays 按日期从早到晚列出 Redis 中还有流量记录的日期
+func BandwidthDays(ctx context.Context) ([]string, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	var days []string
+	var cursor uint64
+	for {
+		keys, next, err := RedisClient.Scan(ctx, cursor, bandwidthKeyPrefix+"*", 100).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bandwidth keys: %w", err)
+		}
+		for _, key := range keys {
+			days = append(days, strings.TrimPrefix(key, bandwidthKeyPrefix))
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const captchaChallengeKeyPrefix = "captcha:pow:"
+
+// SaveCaptchaChallenge 保存签发的工作量证明挑战及其难度，过期或使用后失效
+func SaveCaptchaChallenge(ctx context.Context, challenge string, difficulty int, ttl time.Duration) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	if err := RedisClient.Set(ctx, captchaChallengeKeyPrefix+challenge, difficulty, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save captcha challenge: %w", err)
+	}
+	return nil
+}
+
+// ConsumeCaptchaChallenge 取出并删除挑战，返回签发时的难度；挑战不存在、已过期或已被使用时返回 0
+func ConsumeCaptchaChallenge(ctx context.Context, challenge string) (int, error) {
+	if RedisClient == nil {
+		return 0, fmt.Errorf("redis client not initialized")
+	}
+
+	value, err := consumeTokenScript.Run(ctx, RedisClient, []string{captchaChallengeKeyPrefix + challenge}).Text()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to consume captcha challenge: %w", err)
+	}
+	difficulty, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid captcha challenge value %q: %w", value, err)
+	}
+	return difficulty, nil
+}
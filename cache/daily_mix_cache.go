@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"Bt1QFM/model"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const dailyMixKeyPrefix = "daily_mix:user:"
+
+func dailyMixKey(userID int64) string {
+	return dailyMixKeyPrefix + strconv.FormatInt(userID, 10)
+}
+
+// SetDailyMixes 保存用户的 Daily Mix，替换之前生成的歌单
+func SetDailyMixes(ctx context.Context, userID int64, mixes []model.DailyMix, ttl time.Duration) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	data, err := json.Marshal(mixes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal daily mixes: %w", err)
+	}
+	if err := RedisClient.Set(ctx, dailyMixKey(userID), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set daily mixes: %w", err)
+	}
+	return nil
+}
+
+// GetDailyMixes 获取用户的 Daily Mix，还没有生成时返回 nil
+func GetDailyMixes(ctx context.Context, userID int64) ([]model.DailyMix, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	data, err := RedisClient.Get(ctx, dailyMixKey(userID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily mixes: %w", err)
+	}
+	var mixes []model.DailyMix
+	if err := json.Unmarshal(data, &mixes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal daily mixes: %w", err)
+	}
+	return mixes, nil
+}
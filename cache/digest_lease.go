@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// digestLeaseKey 某一天的每日摘要发送租约，day 格式为 2006-01-02
+func digestLeaseKey(day string) string {
+	return "digest:lease:" + day
+}
+
+// AcquireDigestLease 获取某一天的摘要发送租约，其他实例已获取时返回 false
+// 租约发送完成后不释放，在 ttl 后自动过期，避免其他实例当天再次发送
+func AcquireDigestLease(ctx context.Context, day, owner string, ttl time.Duration) (bool, error) {
+	if RedisClient == nil {
+		return false, fmt.Errorf("redis client not initialized")
+	}
+	ok, err := RedisClient.SetNX(ctx, digestLeaseKey(day), owner, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire digest lease: %w", err)
+	}
+	return ok, nil
+}
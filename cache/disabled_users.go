@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// disabledUsersKey 被管理员禁用的用户 ID 集合，认证中间件据此拒绝已签发的 Token
+const disabledUsersKey = "users:disabled"
+
+// SetUserDisabled 把用户加入或移出禁用集合
+func SetUserDisabled(ctx context.Context, userID int64, disabled bool) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	if disabled {
+		return RedisClient.SAdd(ctx, disabledUsersKey, userID).Err()
+	}
+	return RedisClient.SRem(ctx, disabledUsersKey, userID).Err()
+}
+
+// IsUserDisabled 检查用户是否被禁用，Redis 不可用时视为未禁用
+func IsUserDisabled(ctx context.Context, userID int64) bool {
+	if RedisClient == nil {
+		return false
+	}
+	disabled, err := RedisClient.SIsMember(ctx, disabledUsersKey, userID).Result()
+	return err == nil && disabled
+}
+
+// ReplaceDisabledUsers 用数据库中的禁用用户重建禁用集合，启动时调用以防 Redis 数据丢失
+func ReplaceDisabledUsers(ctx context.Context, userIDs []int64) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	_, err := RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, disabledUsersKey)
+		if len(userIDs) > 0 {
+			members := make([]interface{}, len(userIDs))
+			for i, id := range userIDs {
+				members[i] = id
+			}
+			pipe.SAdd(ctx, disabledUsersKey, members...)
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// IdempotencyRecord 一个 Idempotency-Key 对应的请求记录
+// Completed 为 false 表示首次请求仍在处理中，此时不含响应
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Completed   bool   `json:"completed"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+func idempotencyKey(userID int64, key string) string {
+	return fmt.Sprintf("idempotency:%d:%s", userID, key)
+}
+
+// ReserveIdempotencyKey 为首次出现的 key 写入处理中的记录并返回 (nil, nil)；
+// key 已存在时返回已有记录，由调用方比较指纹并决定重放响应还是拒绝
+func ReserveIdempotencyKey(ctx context.Context, userID int64, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	data, err := json.Marshal(&IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	redisKey := idempotencyKey(userID, key)
+	reserved, err := RedisClient.SetNX(ctx, redisKey, data, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if reserved {
+		return nil, nil
+	}
+
+	existing, err := RedisClient.Get(ctx, redisKey).Bytes()
+	if err == redis.Nil {
+		// 记录恰好过期或被释放，按首次请求处理
+		return ReserveIdempotencyKey(ctx, userID, key, fingerprint, ttl)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+	var record IdempotencyRecord
+	if err := json.Unmarshal(existing, &record); err != nil {
+		return nil, fmt.Errorf("invalid idempotency record: %w", err)
+	}
+	return &record, nil
+}
+
+// SaveIdempotentResponse 保存首次请求的响应，ttl 内使用同一 key 的请求直接返回该响应
+func SaveIdempotentResponse(ctx context.Context, userID int64, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	record.Completed = true
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := RedisClient.Set(ctx, idempotencyKey(userID, key), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey 删除 key 的记录，之后使用该 key 的请求会重新执行
+func ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return RedisClient.Del(ctx, idempotencyKey(userID, key)).Err()
+}
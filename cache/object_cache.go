@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ObjectCache 按对象路径缓存热点数据的内存 LRU，总大小超过上限时淘汰最久未访问的条目
+// 同一对象的并发未命中只会触发一次加载，避免缓存击穿时大量请求同时回源
+type ObjectCache struct {
+	maxBytes int64
+
+	mu      sync.Mutex
+	ll      *list.List
+	entries map[string]*list.Element
+	bytes   int64
+
+	loadMu  sync.Mutex
+	loading map[string]*objectLoad
+
+	hits      uint64
+	misses    uint64
+	coalesced uint64
+	evictions uint64
+}
+
+// objectEntry LRU 中的一个条目
+type objectEntry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+// objectLoad 进行中的一次加载，后到的请求等待其结果
+type objectLoad struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+// ObjectCacheStats 缓存统计
+type ObjectCacheStats struct {
+	Entries   int     `json:"entries"`
+	Bytes     int64   `json:"bytes"`
+	MaxBytes  int64   `json:"maxBytes"`
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	Coalesced uint64  `json:"coalesced"` // 等待其他请求加载结果的未命中次数
+	Evictions uint64  `json:"evictions"`
+	HitRate   float64 `json:"hitRate"`
+}
+
+// ObjectLoader 缓存未命中时加载对象，ttl 为 0 表示结果不写入缓存
+type ObjectLoader func() (data []byte, ttl time.Duration, err error)
+
+// NewObjectCache 创建内存对象缓存，maxBytes <= 0 时不缓存，仅做并发加载合并
+func NewObjectCache(maxBytes int64) *ObjectCache {
+	return &ObjectCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		entries:  make(map[string]*list.Element),
+		loading:  make(map[string]*objectLoad),
+	}
+}
+
+// Get 读取缓存，未命中时调用 load 加载并写入缓存
+func (c *ObjectCache) Get(key string, load ObjectLoader) ([]byte, error) {
+	if data, ok := c.lookup(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return data, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	c.loadMu.Lock()
+	if l, ok := c.loading[key]; ok {
+		c.loadMu.Unlock()
+		atomic.AddUint64(&c.coalesced, 1)
+		l.wg.Wait()
+		return l.data, l.err
+	}
+	l := &objectLoad{}
+	l.wg.Add(1)
+	c.loading[key] = l
+	c.loadMu.Unlock()
+
+	var ttl time.Duration
+	l.data, ttl, l.err = load()
+	if l.err == nil && ttl > 0 {
+		c.add(key, l.data, ttl)
+	}
+	l.wg.Done()
+
+	c.loadMu.Lock()
+	delete(c.loading, key)
+	c.loadMu.Unlock()
+
+	return l.data, l.err
+}
+
+// lookup 查找未过期的条目并移到队首
+func (c *ObjectCache) lookup(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*objectEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.removeElement(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.data, true
+}
+
+// add 写入条目，超过容量时从队尾淘汰；单个对象超过总容量时不缓存
+func (c *ObjectCache) add(key string, data []byte, ttl time.Duration) {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+	c.entries[key] = c.ll.PushFront(&objectEntry{key: key, data: data, expiresAt: time.Now().Add(ttl)})
+	c.bytes += size
+
+	for c.bytes > c.maxBytes {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.removeElement(oldest)
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// removeElement 移除条目，调用方需持有 mu
+func (c *ObjectCache) removeElement(el *list.Element) {
+	entry := c.ll.Remove(el).(*objectEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.data))
+}
+
+// Invalidate 删除单个对象的缓存
+func (c *ObjectCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// InvalidatePrefix 删除指定前缀下所有对象的缓存
+func (c *ObjectCache) InvalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+		}
+	}
+}
+
+// Stats 返回缓存统计
+func (c *ObjectCache) Stats() ObjectCacheStats {
+	c.mu.Lock()
+	stats := ObjectCacheStats{
+		Entries:  c.ll.Len(),
+		Bytes:    c.bytes,
+		MaxBytes: c.maxBytes,
+	}
+	c.mu.Unlock()
+
+	stats.Hits = atomic.LoadUint64(&c.hits)
+	stats.Misses = atomic.LoadUint64(&c.misses)
+	stats.Coalesced = atomic.LoadUint64(&c.coalesced)
+	stats.Evictions = atomic.LoadUint64(&c.evictions)
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 一次性令牌的用途，不同用途的令牌互不影响
+const (
+	TokenPasswordReset     = "password_reset"
+	TokenEmailVerification = "email_verify"
+)
+
+// consumeTokenScript 原子地读取并删除令牌，保证令牌只能使用一次
+// KEYS[1] 令牌键；返回令牌对应的用户 ID，不存在时返回 false
+var consumeTokenScript = redis.NewScript(`
+local userID = redis.call('GET', KEYS[1])
+if userID then
+	redis.call('DEL', KEYS[1])
+end
+return userID
+`)
+
+// oneTimeTokenKey 令牌的键，只保存令牌哈希
+func oneTimeTokenKey(purpose, tokenHash string) string {
+	return purpose + ":token:" + tokenHash
+}
+
+// oneTimeTokenUserKey 记录用户当前有效的令牌哈希，用于签发新令牌时作废旧令牌
+func oneTimeTokenUserKey(purpose string, userID int64) string {
+	return fmt.Sprintf("%s:user:%d", purpose, userID)
+}
+
+// SaveOneTimeToken 保存用户某一用途的令牌哈希，同一用户之前签发的同用途令牌随之失效
+func SaveOneTimeToken(ctx context.Context, purpose string, userID int64, tokenHash string, ttl time.Duration) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	userKey := oneTimeTokenUserKey(purpose, userID)
+	previous, err := RedisClient.Get(ctx, userKey).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to get previous %s token: %w", purpose, err)
+	}
+
+	_, err = RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if previous != "" {
+			pipe.Del(ctx, oneTimeTokenKey(purpose, previous))
+		}
+		pipe.Set(ctx, oneTimeTokenKey(purpose, tokenHash), userID, ttl)
+		pipe.Set(ctx, userKey, tokenHash, ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save %s token: %w", purpose, err)
+	}
+	return nil
+}
+
+// ConsumeOneTimeToken 取出并删除令牌，返回对应的用户 ID
+// 令牌不存在、已过期或已被使用时返回 0
+func ConsumeOneTimeToken(ctx context.Context, purpose, tokenHash string) (int64, error) {
+	if RedisClient == nil {
+		return 0, fmt.Errorf("redis client not initialized")
+	}
+
+	value, err := consumeTokenScript.Run(ctx, RedisClient, []string{oneTimeTokenKey(purpose, tokenHash)}).Text()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to consume %s token: %w", purpose, err)
+	}
+
+	userID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s token value %q: %w", purpose, value, err)
+	}
+	RedisClient.Del(ctx, oneTimeTokenUserKey(purpose, userID))
+	return userID, nil
+}
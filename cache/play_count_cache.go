@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/model"
+)
+
+const (
+	// playCountKeyPrefix 每天一个 hash，字段为 "来源:歌曲ID"，值为当天开始播放的次数
+	playCountKeyPrefix = "plays:daily:"
+	// playCountTTL 汇总服务停机几天后仍能补汇总
+	playCountTTL = 8 * 24 * time.Hour
+	// PlayCountDayLayout 每日播放计数的日期格式
+	PlayCountDayLayout = "2006-01-02"
+)
+
+func playCountKey(day string) string {
+	return playCountKeyPrefix + day
+}
+
+// IncrPlayCount 把一首歌在 at 当天的播放次数加一
+func IncrPlayCount(ctx context.Context, source, sourceID string, at time.Time) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	key := playCountKey(at.Format(PlayCountDayLayout))
+	pipe := RedisClient.TxPipeline()
+	pipe.HIncrBy(ctx, key, source+":"+sourceID, 1)
+	pipe.Expire(ctx, key, playCountTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to increment play count: %w", err)
+	}
+	return nil
+}
+
+// GetPlayCounts 获取某天（格式为 PlayCountDayLayout）每首歌的播放次数，没有记录时返回空列表
+func GetPlayCounts(ctx context.Context, day string) ([]model.PlayCount, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	fields, err := RedisClient.HGetAll(ctx, playCountKey(day)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get play counts: %w", err)
+	}
+	counts := make([]model.PlayCount, 0, len(fields))
+	for field, value := range fields {
+		source, sourceID, ok := strings.Cut(field, ":")
+		plays, err := strconv.ParseInt(value, 10, 64)
+		if !ok || err != nil || plays <= 0 {
+			continue
+		}
+		counts = append(counts, model.PlayCount{Source: source, SourceID: sourceID, Plays: plays})
+	}
+	return counts, nil
+}
+
+// DeletePlayCounts 删除已汇总到数据库的某天播放次数
+func DeletePlayCounts(ctx context.Context, day string) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	if err := RedisClient.Del(ctx, playCountKey(day)).Err(); err != nil {
+		return fmt.Errorf("failed to delete play counts: %w", err)
+	}
+	return nil
+}
+
+// PlayCountDays 按日期从早到晚列出 Redis 中还有播放次数的日期
+func PlayCountDays(ctx context.Context) ([]string, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	var days []string
+	var cursor uint64
+	for {
+		keys, next, err := RedisClient.Scan(ctx, cursor, playCountKeyPrefix+"*", 100).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan play count keys: %w", err)
+		}
+		for _, key := range keys {
+			days = append(days, strings.TrimPrefix(key, playCountKeyPrefix))
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 循环模式
+const (
+	RepeatOff = "off"
+	RepeatOne = "one"
+	RepeatAll = "all"
+)
+
+// ValidRepeatMode 判断是否为支持的循环模式
+func ValidRepeatMode(mode string) bool {
+	switch mode {
+	case RepeatOff, RepeatOne, RepeatAll:
+		return true
+	}
+	return false
+}
+
+// RepeatMode 返回循环模式，未设置时为 off
+func (s *UserPlaybackState) RepeatMode() string {
+	if s.Repeat == "" {
+		return RepeatOff
+	}
+	return s.Repeat
+}
+
+// NextIndex 按循环和随机模式返回 current 之后播放的歌曲在 playlist 中的索引，没有下一首时返回 -1
+// skip 为 true 表示用户手动切歌，单曲循环时也切到下一首，此时按列表循环处理
+// 随机播放的顺序由种子和歌曲本身决定，同一份状态在所有设备上算出的下一首相同，增删歌曲也不会打乱其余歌曲的顺序
+func (s *UserPlaybackState) NextIndex(playlist []PlaylistItem, current int, skip bool) int {
+	if len(playlist) == 0 {
+		return -1
+	}
+	if current < 0 || current >= len(playlist) {
+		return 0
+	}
+
+	repeat := s.RepeatMode()
+	if repeat == RepeatOne {
+		if !skip {
+			return current
+		}
+		repeat = RepeatAll
+	}
+
+	if !s.Shuffle {
+		if current+1 < len(playlist) {
+			return current + 1
+		}
+		if repeat == RepeatAll {
+			return 0
+		}
+		return -1
+	}
+
+	order := shuffleOrder(s.ShuffleSeed, playlist)
+	for i, index := range order {
+		if index != current {
+			continue
+		}
+		if i+1 < len(order) {
+			return order[i+1]
+		}
+		break
+	}
+	if repeat == RepeatAll {
+		return order[0]
+	}
+	return -1
+}
+
+// shuffleOrder 返回随机播放时的播放顺序（playlist 的索引），按种子和歌曲来源计算的哈希排序
+func shuffleOrder(seed int64, playlist []PlaylistItem) []int {
+	keys := make([]uint64, len(playlist))
+	var seedBytes [8]byte
+	binary.LittleEndian.PutUint64(seedBytes[:], uint64(seed))
+	for i := range playlist {
+		h := sha256.New()
+		h.Write(seedBytes[:])
+		h.Write([]byte(playlist[i].Source + ":" + playlist[i].SourceID))
+		keys[i] = binary.BigEndian.Uint64(h.Sum(nil))
+	}
+
+	order := make([]int, len(playlist))
+	for i := range order {
+		order[i] = i
+	}
+	// 同一首歌重复出现时哈希相同，按列表中的位置排序
+	sort.SliceStable(order, func(a, b int) bool {
+		return keys[order[a]] < keys[order[b]]
+	})
+	return order
+}
+
+// SetUserPlaybackMode 修改用户的循环和随机模式，为 nil 的参数保持不变，返回修改后的状态
+// 从关闭切换到开启随机播放时生成新的种子，每次开启得到不同的播放顺序
+func SetUserPlaybackMode(ctx context.Context, userID int64, repeat *string, shuffle *bool) (*UserPlaybackState, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("Redis client not initialized")
+	}
+
+	key := GetUserPlaybackKey(userID)
+	var state UserPlaybackState
+	update := func(tx *redis.Tx) error {
+		state = UserPlaybackState{}
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &state); err != nil {
+				return fmt.Errorf("failed to unmarshal user playback state: %w", err)
+			}
+		}
+
+		if repeat != nil {
+			state.Repeat = *repeat
+		}
+		if shuffle != nil {
+			if *shuffle && !state.Shuffle {
+				state.ShuffleSeed = rand.Int63()
+			}
+			state.Shuffle = *shuffle
+		}
+		state.UpdatedAt = time.Now().UnixMilli()
+
+		data, err = json.Marshal(&state)
+		if err != nil {
+			return fmt.Errorf("failed to marshal user playback state: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, userPlaybackTTL)
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < playlistTxRetries; attempt++ {
+		err := RedisClient.Watch(ctx, update, key)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to set user playback mode: %w", err)
+		}
+		return &state, nil
+	}
+	return nil, fmt.Errorf("failed to set user playback mode: too many concurrent updates")
+}
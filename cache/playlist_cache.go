@@ -4,6 +4,8 @@ import (
     "context"
     "encoding/json"
     "fmt"
+    "strconv"
+    "strings"
     "time"
 
     "github.com/go-redis/redis/v8"
@@ -21,11 +23,79 @@ type PlaylistItem struct {
     Cover     string `json:"cover,omitempty"`     // 封面URL
     Duration  int    `json:"duration,omitempty"`  // 时长（秒）
     Source    string `json:"source,omitempty"`    // 来源：local, netease
+    SourceID  string `json:"sourceId,omitempty"`  // 来源内的歌曲ID
+    HLSURL    string `json:"hlsUrl,omitempty"`    // HLS 播放地址
     Position  int    `json:"position"`            // 在播放列表中的位置
     AddedBy   int64  `json:"addedBy,omitempty"`   // 添加者ID
     AddedAt   int64  `json:"addedAt,omitempty"`   // 添加时间戳
 }
 
+// 播放列表项的来源
+const (
+    SourceLocal   = "local"
+    SourceNetease = "netease"
+)
+
+// Normalize 根据旧字段补全 Source、SourceID 和 HLSURL，并回填旧字段以兼容仍按 TrackID/NeteaseID 读取的代码
+// 返回 false 表示无法识别来源
+func (item *PlaylistItem) Normalize() bool {
+    if item.Source == "" {
+        switch {
+        case item.NeteaseID != 0:
+            item.Source = SourceNetease
+        case item.TrackID != 0:
+            item.Source = SourceLocal
+        case item.SongID != "":
+            item.Source = SourceNetease // 房间歌单的 SongID 目前只来自网易云
+        default:
+            return false
+        }
+    }
+
+    if item.SourceID == "" {
+        switch {
+        case item.Source == SourceNetease && item.NeteaseID != 0:
+            item.SourceID = strconv.FormatInt(item.NeteaseID, 10)
+        case item.Source == SourceNetease && item.SongID != "":
+            item.SourceID = strings.TrimPrefix(item.SongID, "netease_")
+        case item.Source == SourceLocal && item.TrackID != 0:
+            item.SourceID = strconv.FormatInt(item.TrackID, 10)
+        default:
+            return false
+        }
+    }
+
+    id, err := strconv.ParseInt(item.SourceID, 10, 64)
+    if err != nil || id <= 0 {
+        return false
+    }
+    switch item.Source {
+    case SourceNetease:
+        item.NeteaseID = id
+        item.TrackID = 0
+        if item.HLSURL == "" {
+            item.HLSURL = fmt.Sprintf("/streams/netease/%d/playlist.m3u8", id)
+        }
+    case SourceLocal:
+        item.TrackID = id
+        item.NeteaseID = 0
+        if item.HLSURL == "" {
+            item.HLSURL = fmt.Sprintf("/streams/%d/playlist.m3u8", id)
+        }
+    default:
+        return false
+    }
+    if item.Title == "" {
+        item.Title = item.Name
+    }
+    return true
+}
+
+// Matches 判断播放列表项是否为指定来源的歌曲
+func (item *PlaylistItem) Matches(source, sourceID string) bool {
+    return item.Source == source && item.SourceID == sourceID
+}
+
 // GetPlaylistKey 根据用户ID生成播放列表的Redis键
 func GetPlaylistKey(userID int64) string {
     return fmt.Sprintf("playlist:%d", userID)
@@ -37,6 +107,10 @@ func AddTrackToPlaylist(ctx context.Context, userID int64, item PlaylistItem) er
         return fmt.Errorf("Redis client not initialized")
     }
 
+    if !item.Normalize() {
+        return fmt.Errorf("invalid playlist item: unknown source %q or id %q", item.Source, item.SourceID)
+    }
+
     playlistKey := GetPlaylistKey(userID)
 
     // 获取当前播放列表以确定新项目的位置
@@ -128,6 +202,30 @@ func RemoveTrackFromPlaylist(ctx context.Context, userID int64, trackID int64) e
     return fmt.Errorf("track not found in playlist")
 }
 
+// RemoveSourceFromPlaylist 按来源和来源内ID从用户的播放列表中删除歌曲
+func RemoveSourceFromPlaylist(ctx context.Context, userID int64, source, sourceID string) error {
+    if RedisClient == nil {
+        return fmt.Errorf("Redis client not initialized")
+    }
+
+    items, err := GetPlaylist(ctx, userID)
+    if err != nil {
+        return fmt.Errorf("failed to get playlist: %w", err)
+    }
+
+    for i := range items {
+        if items[i].Matches(source, sourceID) {
+            remaining := append(items[:i:i], items[i+1:]...)
+            if err := rewritePlaylist(ctx, GetPlaylistKey(userID), remaining); err != nil {
+                return fmt.Errorf("failed to remove track from playlist: %w", err)
+            }
+            return nil
+        }
+    }
+
+    return fmt.Errorf("track not found in playlist")
+}
+
 // GetPlaylist 获取用户的整个播放列表
 func GetPlaylist(ctx context.Context, userID int64) ([]PlaylistItem, error) {
     if RedisClient == nil {
@@ -149,16 +247,145 @@ func GetPlaylist(ctx context.Context, userID int64) ([]PlaylistItem, error) {
         return nil, fmt.Errorf("failed to get playlist: %w", err)
     }
 
+    playlist, migrated, err := decodePlaylist(result)
+    if err != nil {
+        return nil, err
+    }
+
+    // 旧格式的项目需要写回，否则按 JSON 删除时匹配不到原始成员
+    if migrated {
+        if err := rewritePlaylist(ctx, playlistKey, playlist); err != nil {
+            return nil, fmt.Errorf("failed to migrate playlist: %w", err)
+        }
+    }
+
+    return playlist, nil
+}
+
+// decodePlaylist 解析有序集合中的播放列表项，返回是否有需要写回的旧格式项目
+func decodePlaylist(members []string) ([]PlaylistItem, bool, error) {
     var playlist []PlaylistItem
-    for _, itemJSON := range result {
+    migrated := false
+    for _, itemJSON := range members {
         var item PlaylistItem
         if err := json.Unmarshal([]byte(itemJSON), &item); err != nil {
-            return nil, fmt.Errorf("failed to unmarshal playlist item: %w", err)
+            return nil, false, fmt.Errorf("failed to unmarshal playlist item: %w", err)
+        }
+        if item.Source == "" || item.SourceID == "" || item.HLSURL == "" {
+            migrated = true
+            if !item.Normalize() {
+                continue // 无法识别来源的旧数据直接丢弃
+            }
         }
         playlist = append(playlist, item)
     }
+    return playlist, migrated, nil
+}
 
-    return playlist, nil
+// playlistTxRetries 并发修改导致 WATCH 事务失败时的重试次数
+const playlistTxRetries = 5
+
+// InsertTracksIntoPlaylist 把多首歌曲按给定顺序插入到用户的播放列表，返回插入后的播放列表
+// playNext 为 true 时插入到当前播放的歌曲之后（没有播放状态时插入到开头），否则追加到末尾；
+// 读取和重写在同一个 WATCH 事务中完成，并发修改时重试，插入的歌曲保持连续且顺序不变
+func InsertTracksIntoPlaylist(ctx context.Context, userID int64, items []PlaylistItem, playNext bool) ([]PlaylistItem, error) {
+    if RedisClient == nil {
+        return nil, fmt.Errorf("Redis client not initialized")
+    }
+    for i := range items {
+        if !items[i].Normalize() {
+            return nil, fmt.Errorf("invalid playlist item: unknown source %q or id %q", items[i].Source, items[i].SourceID)
+        }
+    }
+
+    playlistKey := GetPlaylistKey(userID)
+    var merged []PlaylistItem
+    insert := func(tx *redis.Tx) error {
+        members, err := tx.ZRangeByScore(ctx, playlistKey, &redis.ZRangeBy{Min: "-inf", Max: "+inf"}).Result()
+        if err != nil && err != redis.Nil {
+            return err
+        }
+        current, _, err := decodePlaylist(members)
+        if err != nil {
+            return err
+        }
+
+        index := len(current)
+        if playNext {
+            index = nextPlaylistIndex(ctx, userID, current)
+        }
+        merged = make([]PlaylistItem, 0, len(current)+len(items))
+        merged = append(merged, current[:index]...)
+        merged = append(merged, items...)
+        merged = append(merged, current[index:]...)
+
+        _, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+            return queuePlaylistRewrite(ctx, pipe, playlistKey, merged)
+        })
+        return err
+    }
+
+    for attempt := 0; attempt < playlistTxRetries; attempt++ {
+        err := RedisClient.Watch(ctx, insert, playlistKey)
+        if err == redis.TxFailedErr {
+            continue
+        }
+        if err != nil {
+            return nil, fmt.Errorf("failed to insert tracks into playlist: %w", err)
+        }
+        return merged, nil
+    }
+    return nil, fmt.Errorf("failed to insert tracks into playlist: too many concurrent updates")
+}
+
+// nextPlaylistIndex 返回"下一首播放"的插入位置：当前播放的歌曲之后，没有播放状态时为列表开头
+// 优先按播放状态中的歌曲定位，播放列表变化后索引可能已经过期
+func nextPlaylistIndex(ctx context.Context, userID int64, items []PlaylistItem) int {
+    state, err := GetUserPlaybackState(ctx, userID)
+    if err != nil || state == nil {
+        return 0
+    }
+    if state.SourceID != "" {
+        for i := range items {
+            if items[i].Matches(state.Source, state.SourceID) {
+                return i + 1
+            }
+        }
+    }
+    if state.CurrentIndex >= 0 && state.CurrentIndex < len(items) {
+        return state.CurrentIndex + 1
+    }
+    return 0
+}
+
+// rewritePlaylist 以给定顺序原子地重写整个播放列表
+func rewritePlaylist(ctx context.Context, playlistKey string, items []PlaylistItem) error {
+    pipe := RedisClient.TxPipeline()
+    if err := queuePlaylistRewrite(ctx, pipe, playlistKey, items); err != nil {
+        return err
+    }
+    _, err := pipe.Exec(ctx)
+    return err
+}
+
+// queuePlaylistRewrite 在管道中加入重写整个播放列表的命令，并按顺序重新设置位置
+func queuePlaylistRewrite(ctx context.Context, pipe redis.Pipeliner, playlistKey string, items []PlaylistItem) error {
+    pipe.Del(ctx, playlistKey)
+    for i := range items {
+        items[i].Position = i
+        itemJSON, err := json.Marshal(items[i])
+        if err != nil {
+            return fmt.Errorf("failed to marshal playlist item: %w", err)
+        }
+        pipe.ZAdd(ctx, playlistKey, &redis.Z{
+            Score:  float64(i),
+            Member: itemJSON,
+        })
+    }
+    if len(items) > 0 {
+        pipe.Expire(ctx, playlistKey, 24*time.Hour)
+    }
+    return nil
 }
 
 // ClearPlaylist 清空用户的播放列表
@@ -314,10 +541,17 @@ const (
 
 // UserPlaybackState 用户个人播放状态
 type UserPlaybackState struct {
-    CurrentIndex int     `json:"currentIndex"` // 当前播放索引
-    Position     float64 `json:"position"`     // 当前播放位置（秒）
-    IsPlaying    bool    `json:"isPlaying"`    // 是否正在播放
-    UpdatedAt    int64   `json:"updatedAt"`    // 更新时间戳
+    CurrentIndex int     `json:"currentIndex"`       // 当前播放索引
+    Position     float64 `json:"position"`           // 当前播放位置（秒）
+    IsPlaying    bool    `json:"isPlaying"`          // 是否正在播放
+    Source       string  `json:"source,omitempty"`   // 当前歌曲来源，播放列表变化后用于重新定位索引
+    SourceID     string  `json:"sourceId,omitempty"` // 当前歌曲在来源内的ID
+    DeviceID     string  `json:"deviceId,omitempty"` // 最后上报状态的设备
+    UpdatedAt    int64   `json:"updatedAt"`          // 更新时间戳
+
+    Repeat      string `json:"repeat,omitempty"`      // 循环模式：off、one、all，空值等同于 off
+    Shuffle     bool   `json:"shuffle,omitempty"`     // 是否随机播放
+    ShuffleSeed int64  `json:"shuffleSeed,omitempty"` // 随机播放顺序的种子，开启随机播放时生成
 }
 
 // GetUserPlaybackKey 获取用户播放状态 Redis key
@@ -345,6 +579,52 @@ func SetUserPlaybackState(ctx context.Context, userID int64, state *UserPlayback
     return nil
 }
 
+// SaveUserPlaybackHeartbeat 保存客户端上报的播放进度，并延长播放列表的过期时间，
+// 只要用户还在播放，播放列表和进度就不会过期
+// 心跳不携带播放模式，循环和随机设置沿用已保存的状态
+func SaveUserPlaybackHeartbeat(ctx context.Context, userID int64, state *UserPlaybackState) error {
+    if RedisClient == nil {
+        return fmt.Errorf("Redis client not initialized")
+    }
+
+    key := GetUserPlaybackKey(userID)
+    save := func(tx *redis.Tx) error {
+        previous, err := tx.Get(ctx, key).Bytes()
+        if err != nil && err != redis.Nil {
+            return err
+        }
+        if len(previous) > 0 {
+            var saved UserPlaybackState
+            if err := json.Unmarshal(previous, &saved); err == nil {
+                state.Repeat, state.Shuffle, state.ShuffleSeed = saved.Repeat, saved.Shuffle, saved.ShuffleSeed
+            }
+        }
+
+        data, err := json.Marshal(state)
+        if err != nil {
+            return fmt.Errorf("failed to marshal user playback state: %w", err)
+        }
+        _, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+            pipe.Set(ctx, key, data, userPlaybackTTL)
+            pipe.Expire(ctx, GetPlaylistKey(userID), 24*time.Hour)
+            return nil
+        })
+        return err
+    }
+
+    for attempt := 0; attempt < playlistTxRetries; attempt++ {
+        err := RedisClient.Watch(ctx, save, key)
+        if err == redis.TxFailedErr {
+            continue
+        }
+        if err != nil {
+            return fmt.Errorf("failed to save user playback heartbeat: %w", err)
+        }
+        return nil
+    }
+    return fmt.Errorf("failed to save user playback heartbeat: too many concurrent updates")
+}
+
 // GetUserPlaybackState 获取用户播放状态
 func GetUserPlaybackState(ctx context.Context, userID int64) (*UserPlaybackState, error) {
     if RedisClient == nil {
@@ -400,6 +680,18 @@ func UpdateUserPlaybackIndex(ctx context.Context, userID int64, index int, isPla
     return SetUserPlaybackState(ctx, userID, state)
 }
 
+// PauseUserPlayback 将用户播放状态标记为暂停，保留播放位置
+func PauseUserPlayback(ctx context.Context, userID int64) error {
+    state, err := GetUserPlaybackState(ctx, userID)
+    if err != nil || state == nil {
+        return err
+    }
+
+    state.IsPlaying = false
+    state.UpdatedAt = time.Now().UnixMilli()
+    return SetUserPlaybackState(ctx, userID, state)
+}
+
 // GetUserNextSong 获取用户播放列表中的下一首歌
 func GetUserNextSong(ctx context.Context, userID int64) (*PlaylistItem, bool, error) {
     state, err := GetUserPlaybackState(ctx, userID)
@@ -415,8 +707,9 @@ func GetUserNextSong(ctx context.Context, userID int64) (*PlaylistItem, bool, er
         return nil, false, err
     }
 
-    nextIndex := state.CurrentIndex + 1
-    if nextIndex >= len(playlist) {
+    // 按循环和随机模式决定下一首
+    nextIndex := state.NextIndex(playlist, state.CurrentIndex, false)
+    if nextIndex < 0 {
         return nil, false, nil // 没有下一首
     }
 
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// processingLockKeyPrefix 歌曲转码的分布式锁，值为持有锁的实例标识
+const processingLockKeyPrefix = "processing:lock:"
+
+// renewLockScript 锁仍由 owner 持有时延长有效期
+// KEYS[1] 锁键；ARGV[1] owner；ARGV[2] 有效期（毫秒）；返回 1 表示续期成功
+var renewLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLockScript 锁仍由 owner 持有时删除，避免释放已被其他实例重新获取的锁
+// KEYS[1] 锁键；ARGV[1] owner
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func processingLockKey(songID string) string {
+	return processingLockKeyPrefix + songID
+}
+
+// AcquireProcessingLock 获取歌曲的转码锁，其他实例持有时返回 false；锁在 ttl 后自动过期，持有期间需按时续期
+func AcquireProcessingLock(ctx context.Context, songID, owner string, ttl time.Duration) (bool, error) {
+	if RedisClient == nil {
+		return false, fmt.Errorf("redis client not initialized")
+	}
+	ok, err := RedisClient.SetNX(ctx, processingLockKey(songID), owner, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire processing lock: %w", err)
+	}
+	return ok, nil
+}
+
+// RenewProcessingLock 延长转码锁的有效期，锁已过期或被其他实例获取时返回 false
+func RenewProcessingLock(ctx context.Context, songID, owner string, ttl time.Duration) (bool, error) {
+	if RedisClient == nil {
+		return false, fmt.Errorf("redis client not initialized")
+	}
+	renewed, err := renewLockScript.Run(ctx, RedisClient, []string{processingLockKey(songID)}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to renew processing lock: %w", err)
+	}
+	return renewed == 1, nil
+}
+
+// ReleaseProcessingLock 释放 owner 持有的转码锁
+func ReleaseProcessingLock(ctx context.Context, songID, owner string) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return releaseLockScript.Run(ctx, RedisClient, []string{processingLockKey(songID)}, owner).Err()
+}
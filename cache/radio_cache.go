@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	radioEnabledKeyPrefix = "radio:enabled:"
+	radioRefillKeyPrefix  = "radio:refill:"
+	// radioEnabledTTL 与播放列表的过期时间一致，每次续播时延长
+	radioEnabledTTL = 24 * time.Hour
+)
+
+func radioEnabledKey(userID int64) string {
+	return radioEnabledKeyPrefix + strconv.FormatInt(userID, 10)
+}
+
+func radioRefillKey(userID int64) string {
+	return radioRefillKeyPrefix + strconv.FormatInt(userID, 10)
+}
+
+// SetRadioEnabled 开启或关闭用户的 AI 电台
+func SetRadioEnabled(ctx context.Context, userID int64, enabled bool) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	var err error
+	if enabled {
+		err = RedisClient.Set(ctx, radioEnabledKey(userID), "1", radioEnabledTTL).Err()
+	} else {
+		err = RedisClient.Del(ctx, radioEnabledKey(userID), radioRefillKey(userID)).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set radio state: %w", err)
+	}
+	return nil
+}
+
+// IsRadioEnabled 用户是否开启了 AI 电台
+func IsRadioEnabled(ctx context.Context, userID int64) (bool, error) {
+	if RedisClient == nil {
+		return false, fmt.Errorf("redis client not initialized")
+	}
+
+	err := RedisClient.Get(ctx, radioEnabledKey(userID)).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get radio state: %w", err)
+	}
+	return true, nil
+}
+
+// TouchRadioEnabled 延长 AI 电台的开启状态，电台已关闭时不做任何事
+func TouchRadioEnabled(ctx context.Context, userID int64) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return RedisClient.Expire(ctx, radioEnabledKey(userID), radioEnabledTTL).Err()
+}
+
+// TryLockRadioRefill 获取续播锁，同一用户同时只有一次续播在进行；锁在 ttl 后自动释放
+func TryLockRadioRefill(ctx context.Context, userID int64, ttl time.Duration) (bool, error) {
+	if RedisClient == nil {
+		return false, fmt.Errorf("redis client not initialized")
+	}
+
+	ok, err := RedisClient.SetNX(ctx, radioRefillKey(userID), "1", ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to lock radio refill: %w", err)
+	}
+	return ok, nil
+}
+
+// UnlockRadioRefill 释放续播锁
+func UnlockRadioRefill(ctx context.Context, userID int64) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return RedisClient.Del(ctx, radioRefillKey(userID)).Err()
+}
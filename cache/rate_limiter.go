@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript 原子地补充并消耗令牌
+// KEYS[1] 桶的键；ARGV: 每毫秒补充的令牌数、桶容量、当前毫秒时间戳
+// 返回 {是否放行, 需要等待的毫秒数}
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+end
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', math.max(now, ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate) + 1000)
+return {allowed, wait}
+`)
+
+// AllowRate 基于 Redis 令牌桶判断 key 的一次请求是否放行
+// 桶容量为 limit，每 period 补满；被拒绝时返回需要等待的时间
+func AllowRate(key string, limit int, period time.Duration) (bool, time.Duration, error) {
+	if RedisClient == nil {
+		return true, 0, fmt.Errorf("redis client not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	rate := float64(limit) / float64(period.Milliseconds())
+	result, err := tokenBucketScript.Run(ctx, RedisClient, []string{key}, rate, limit, time.Now().UnixMilli()).Slice()
+	if err != nil {
+		return true, 0, err
+	}
+	if len(result) != 2 {
+		return true, 0, fmt.Errorf("unexpected rate limit result: %v", result)
+	}
+
+	allowed, _ := result[0].(int64)
+	wait, _ := result[1].(int64)
+	return allowed == 1, time.Duration(wait) * time.Millisecond, nil
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"Bt1QFM/model"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	recommendUserKeyPrefix = "recommend:user:"
+	// recommendPopularKey 听众最多的歌曲，用于还没有个性化推荐的用户
+	recommendPopularKey = "recommend:popular"
+)
+
+func recommendUserKey(userID int64) string {
+	return recommendUserKeyPrefix + strconv.FormatInt(userID, 10)
+}
+
+// SetRecommendations 保存用户的推荐列表
+func SetRecommendations(ctx context.Context, userID int64, list *model.RecommendationList, ttl time.Duration) error {
+	return setRecommendationList(ctx, recommendUserKey(userID), list, ttl)
+}
+
+// GetRecommendations 获取用户的推荐列表，不存在时返回 nil
+func GetRecommendations(ctx context.Context, userID int64) (*model.RecommendationList, error) {
+	return getRecommendationList(ctx, recommendUserKey(userID))
+}
+
+// SetPopularRecommendations 保存热门歌曲列表
+func SetPopularRecommendations(ctx context.Context, list *model.RecommendationList, ttl time.Duration) error {
+	return setRecommendationList(ctx, recommendPopularKey, list, ttl)
+}
+
+// GetPopularRecommendations 获取热门歌曲列表，不存在时返回 nil
+func GetPopularRecommendations(ctx context.Context) (*model.RecommendationList, error) {
+	return getRecommendationList(ctx, recommendPopularKey)
+}
+
+func setRecommendationList(ctx context.Context, key string, list *model.RecommendationList, ttl time.Duration) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recommendations: %w", err)
+	}
+	if err := RedisClient.Set(ctx, key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set recommendations: %w", err)
+	}
+	return nil
+}
+
+func getRecommendationList(ctx context.Context, key string) (*model.RecommendationList, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	data, err := RedisClient.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recommendations: %w", err)
+	}
+	var list model.RecommendationList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recommendations: %w", err)
+	}
+	return &list, nil
+}
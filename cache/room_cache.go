@@ -673,6 +673,10 @@ func (c *RoomCache) ClearRoom(ctx context.Context, roomID string) error {
 		fmt.Sprintf(roomMembersKey, roomID),
 		fmt.Sprintf(roomPlaylistKey, roomID),
 		fmt.Sprintf(roomPlaybackKey, roomID),
+		fmt.Sprintf(roomSeqKey, roomID),
+		fmt.Sprintf(roomReplayKey, roomID),
+		fmt.Sprintf(roomStatsKey, roomID),
+		fmt.Sprintf(roomHistoryKey, roomID),
 	}
 	return c.client.Del(ctx, keys...).Err()
 }
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	roomSeqKey    = "room:%s:seq"    // String: 房间广播消息的递增序号
+	roomReplayKey = "room:%s:replay" // Sorted Set: seq -> ReplayEntry JSON，最近的广播消息
+	// RoomReplaySize 每个房间保留的最近广播消息数
+	RoomReplaySize = 200
+	// roomReplayTTL 重放缓冲的过期时间，只用于短暂断线后的补发
+	roomReplayTTL = 5 * time.Minute
+)
+
+// ReplayEntry 重放缓冲中的一条广播消息，保留广播时的过滤条件
+type ReplayEntry struct {
+	Seq       int64           `json:"seq"`
+	Message   json.RawMessage `json:"message"`
+	ExcludeID int64           `json:"excludeId,omitempty"`
+	OnlyMode  string          `json:"onlyMode,omitempty"`
+	Topic     string          `json:"topic,omitempty"`
+}
+
+// NextMessageSeq 分配房间下一条广播消息的序号
+func (c *RoomCache) NextMessageSeq(ctx context.Context, roomID string) (int64, error) {
+	if c.client == nil {
+		return 0, fmt.Errorf("Redis client not initialized")
+	}
+
+	key := fmt.Sprintf(roomSeqKey, roomID)
+	pipe := c.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, roomTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// GetMessageSeq 获取房间最近一条广播消息的序号，没有消息时为 0
+func (c *RoomCache) GetMessageSeq(ctx context.Context, roomID string) (int64, error) {
+	if c.client == nil {
+		return 0, fmt.Errorf("Redis client not initialized")
+	}
+
+	seq, err := c.client.Get(ctx, fmt.Sprintf(roomSeqKey, roomID)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// AppendReplay 把广播消息写入重放缓冲，只保留最近 RoomReplaySize 条
+func (c *RoomCache) AppendReplay(ctx context.Context, roomID string, entry *ReplayEntry) error {
+	if c.client == nil {
+		return fmt.Errorf("Redis client not initialized")
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replay entry: %w", err)
+	}
+
+	key := fmt.Sprintf(roomReplayKey, roomID)
+	pipe := c.client.Pipeline()
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(entry.Seq), Member: data})
+	pipe.ZRemRangeByRank(ctx, key, 0, -RoomReplaySize-1)
+	pipe.Expire(ctx, key, roomReplayTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetReplaySince 获取序号大于 afterSeq 的广播消息（按序号升序）
+func (c *RoomCache) GetReplaySince(ctx context.Context, roomID string, afterSeq int64) ([]ReplayEntry, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("Redis client not initialized")
+	}
+
+	key := fmt.Sprintf(roomReplayKey, roomID)
+	members, err := c.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(afterSeq, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ReplayEntry, 0, len(members))
+	for _, member := range members {
+		var entry ReplayEntry
+		if err := json.Unmarshal([]byte(member), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
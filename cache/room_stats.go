@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"Bt1QFM/model"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	roomStatsKey   = "room:%s:stats"   // Hash: playing_since / played_ms / peak，房间关闭时生成总结
+	roomHistoryKey = "room:%s:history" // List: 按播放顺序记录的 RoomPlayedSong JSON
+	// RoomHistorySize 每个房间最多记录的播放歌曲数
+	RoomHistorySize = 500
+)
+
+// roomPlayTimeScript 累计房间的播放时长：上次开始播放以来的时间计入 played_ms，
+// 然后按当前是否在播放重新设置 playing_since（0 表示暂停）
+var roomPlayTimeScript = redis.NewScript(`
+local since = tonumber(redis.call('HGET', KEYS[1], 'playing_since') or '0')
+local now = tonumber(ARGV[1])
+if since > 0 and now > since then
+	redis.call('HINCRBY', KEYS[1], 'played_ms', now - since)
+end
+if ARGV[2] == '1' then
+	redis.call('HSET', KEYS[1], 'playing_since', now)
+else
+	redis.call('HSET', KEYS[1], 'playing_since', 0)
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// roomPeakScript 记录房间同时在线连接数的峰值
+var roomPeakScript = redis.NewScript(`
+local peak = tonumber(redis.call('HGET', KEYS[1], 'peak') or '0')
+if tonumber(ARGV[1]) > peak then
+	redis.call('HSET', KEYS[1], 'peak', ARGV[1])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// RoomStats 房间关闭时用于生成总结的累计数据
+type RoomStats struct {
+	PlayedMs int64 // 累计播放时长（毫秒）
+	Peak     int   // 同时在线连接数峰值
+}
+
+// UpdateRoomPlayTime 在播放状态变化时累计播放时长，playing 为变化后的播放状态
+func (c *RoomCache) UpdateRoomPlayTime(ctx context.Context, roomID string, playing bool, at time.Time) error {
+	if c.client == nil {
+		return fmt.Errorf("Redis client not initialized")
+	}
+
+	flag := "0"
+	if playing {
+		flag = "1"
+	}
+	key := fmt.Sprintf(roomStatsKey, roomID)
+	return roomPlayTimeScript.Run(ctx, c.client, []string{key}, at.UnixMilli(), flag, roomTTL.Milliseconds()).Err()
+}
+
+// UpdateRoomPeak 用当前同时在线连接数更新峰值
+func (c *RoomCache) UpdateRoomPeak(ctx context.Context, roomID string, count int) error {
+	if c.client == nil {
+		return fmt.Errorf("Redis client not initialized")
+	}
+
+	key := fmt.Sprintf(roomStatsKey, roomID)
+	return roomPeakScript.Run(ctx, c.client, []string{key}, count, roomTTL.Milliseconds()).Err()
+}
+
+// GetRoomStats 获取房间的累计播放时长和在线峰值，没有记录时返回零值
+func (c *RoomCache) GetRoomStats(ctx context.Context, roomID string) (*RoomStats, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("Redis client not initialized")
+	}
+
+	fields, err := c.client.HGetAll(ctx, fmt.Sprintf(roomStatsKey, roomID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	stats := &RoomStats{}
+	stats.PlayedMs, _ = strconv.ParseInt(fields["played_ms"], 10, 64)
+	stats.Peak, _ = strconv.Atoi(fields["peak"])
+	return stats, nil
+}
+
+// RecordRoomSong 记录房间开始播放的歌曲，与上一首相同（暂停、拖动进度）时不重复记录
+func (c *RoomCache) RecordRoomSong(ctx context.Context, roomID string, song *model.RoomPlayedSong) error {
+	if c.client == nil {
+		return fmt.Errorf("Redis client not initialized")
+	}
+
+	key := fmt.Sprintf(roomHistoryKey, roomID)
+	last, err := c.client.LIndex(ctx, key, -1).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if last != "" {
+		var prev model.RoomPlayedSong
+		if json.Unmarshal([]byte(last), &prev) == nil && prev.SongID == song.SongID {
+			return nil
+		}
+	}
+
+	data, err := json.Marshal(song)
+	if err != nil {
+		return fmt.Errorf("failed to marshal played song: %w", err)
+	}
+	pipe := c.client.Pipeline()
+	pipe.RPush(ctx, key, data)
+	pipe.LTrim(ctx, key, -RoomHistorySize, -1)
+	pipe.Expire(ctx, key, roomTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetRoomHistory 按播放顺序获取房间播放过的歌曲
+func (c *RoomCache) GetRoomHistory(ctx context.Context, roomID string) ([]model.RoomPlayedSong, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("Redis client not initialized")
+	}
+
+	items, err := c.client.LRange(ctx, fmt.Sprintf(roomHistoryKey, roomID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	songs := make([]model.RoomPlayedSong, 0, len(items))
+	for _, item := range items {
+		var song model.RoomPlayedSong
+		if err := json.Unmarshal([]byte(item), &song); err != nil {
+			continue
+		}
+		songs = append(songs, song)
+	}
+	return songs, nil
+}
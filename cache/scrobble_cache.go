@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	listenStateKeyPrefix = "playback:listen:"
+	// listenStateTTL 超过该时间没有心跳视为本次收听结束
+	listenStateTTL = 6 * time.Hour
+
+	// scrobbleRetryKey 提交失败的听歌记录，score 为下次重试的时间戳（秒）
+	scrobbleRetryKey = "scrobble:retry"
+)
+
+// ListenState 用户当前这次收听的累计状态，由播放心跳更新
+type ListenState struct {
+	HistoryID int64   `json:"historyId"` // 对应的播放历史记录
+	Source    string  `json:"source"`
+	SourceID  string  `json:"sourceId"`
+	Title     string  `json:"title"`
+	Artist    string  `json:"artist"`
+	Album     string  `json:"album,omitempty"`
+	Duration  float64 `json:"duration"`  // 歌曲时长（秒），未知时为 0
+	Listened  float64 `json:"listened"`  // 累计收听时长（秒）
+	Position  float64 `json:"position"`  // 最近一次心跳上报的位置（秒）
+	Playing   bool    `json:"playing"`   // 最近一次心跳时是否在播放
+	Scrobbled bool    `json:"scrobbled"` // 本次收听是否已提交
+	StartedAt int64   `json:"startedAt"` // 开始收听的时间戳（秒）
+	LastBeat  int64   `json:"lastBeat"`  // 最近一次心跳的时间戳（毫秒）
+}
+
+func listenStateKey(userID int64) string {
+	return listenStateKeyPrefix + strconv.FormatInt(userID, 10)
+}
+
+// GetListenState 获取用户当前的收听状态，不存在时返回 nil
+func GetListenState(ctx context.Context, userID int64) (*ListenState, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	data, err := RedisClient.Get(ctx, listenStateKey(userID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get listen state: %w", err)
+	}
+	var state ListenState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal listen state: %w", err)
+	}
+	return &state, nil
+}
+
+// SaveListenState 保存用户当前的收听状态
+func SaveListenState(ctx context.Context, userID int64, state *ListenState) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal listen state: %w", err)
+	}
+	return RedisClient.Set(ctx, listenStateKey(userID), data, listenStateTTL).Err()
+}
+
+// ClearListenState 删除用户当前的收听状态
+func ClearListenState(ctx context.Context, userID int64) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return RedisClient.Del(ctx, listenStateKey(userID)).Err()
+}
+
+// popDueScript 原子地取出并删除到期的重试项，避免多个实例重复提交
+// KEYS[1] 重试队列；ARGV: 当前时间戳、最多取出的数量
+var popDueScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+if #items > 0 then
+	redis.call('ZREM', KEYS[1], unpack(items))
+end
+return items
+`)
+
+// QueueScrobbleRetry 将提交失败的听歌记录加入重试队列，payload 需唯一
+func QueueScrobbleRetry(ctx context.Context, payload []byte, retryAt time.Time) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return RedisClient.ZAdd(ctx, scrobbleRetryKey, &redis.Z{Score: float64(retryAt.Unix()), Member: payload}).Err()
+}
+
+// PopDueScrobbleRetries 取出最多 limit 条已到重试时间的听歌记录
+func PopDueScrobbleRetries(ctx context.Context, now time.Time, limit int) ([][]byte, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	result, err := popDueScript.Run(ctx, RedisClient, []string{scrobbleRetryKey}, now.Unix(), limit).StringSlice()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to pop scrobble retries: %w", err)
+	}
+	payloads := make([][]byte, len(result))
+	for i, item := range result {
+		payloads[i] = []byte(item)
+	}
+	return payloads, nil
+}
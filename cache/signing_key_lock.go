@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// signingKeyLockKey 生成 JWT 签名密钥的分布式锁，避免多个实例同时轮换
+const signingKeyLockKey = "jwt_keys:rotate_lock"
+
+// AcquireSigningKeyLock 获取签名密钥轮换锁，其他实例持有时返回 false；锁在 ttl 后自动过期
+func AcquireSigningKeyLock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	if RedisClient == nil {
+		return false, fmt.Errorf("redis client not initialized")
+	}
+	ok, err := RedisClient.SetNX(ctx, signingKeyLockKey, owner, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire signing key lock: %w", err)
+	}
+	return ok, nil
+}
+
+// ReleaseSigningKeyLock 释放 owner 持有的签名密钥轮换锁
+func ReleaseSigningKeyLock(ctx context.Context, owner string) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return releaseLockScript.Run(ctx, RedisClient, []string{signingKeyLockKey}, owner).Err()
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	sleepTimerKeyPrefix = "sleep_timer:"
+	// sleepTimerDueKey 按到期时间（毫秒）排序的用户ID，定时任务从中取出到期的定时
+	sleepTimerDueKey = "sleep_timer:due"
+	// sleepTimerGrace 定时到期后记录继续保留的时间，定时任务停机时仍可补触发
+	sleepTimerGrace = time.Hour
+)
+
+// SleepTimer 用户的睡眠定时
+type SleepTimer struct {
+	Minutes   int    `json:"minutes"`            // 设置的时长（分钟）
+	EndsAt    int64  `json:"endsAt"`             // 到期时间戳（毫秒）
+	DeviceID  string `json:"deviceId,omitempty"` // 设置定时的设备
+	CreatedAt int64  `json:"createdAt"`          // 设置时间戳（毫秒）
+}
+
+func sleepTimerKey(userID int64) string {
+	return fmt.Sprintf("%s%d", sleepTimerKeyPrefix, userID)
+}
+
+// SetSleepTimer 设置用户的睡眠定时，已有的定时被替换
+func SetSleepTimer(ctx context.Context, userID int64, timer *SleepTimer) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	data, err := json.Marshal(timer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sleep timer: %w", err)
+	}
+	ttl := time.Until(time.UnixMilli(timer.EndsAt)) + sleepTimerGrace
+
+	pipe := RedisClient.TxPipeline()
+	pipe.Set(ctx, sleepTimerKey(userID), data, ttl)
+	pipe.ZAdd(ctx, sleepTimerDueKey, &redis.Z{Score: float64(timer.EndsAt), Member: userID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to set sleep timer: %w", err)
+	}
+	return nil
+}
+
+// GetSleepTimer 获取用户的睡眠定时，没有定时时返回 nil
+func GetSleepTimer(ctx context.Context, userID int64) (*SleepTimer, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	data, err := RedisClient.Get(ctx, sleepTimerKey(userID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sleep timer: %w", err)
+	}
+	var timer SleepTimer
+	if err := json.Unmarshal(data, &timer); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sleep timer: %w", err)
+	}
+	return &timer, nil
+}
+
+// DeleteSleepTimer 取消用户的睡眠定时，返回是否存在定时
+func DeleteSleepTimer(ctx context.Context, userID int64) (bool, error) {
+	if RedisClient == nil {
+		return false, fmt.Errorf("redis client not initialized")
+	}
+
+	pipe := RedisClient.TxPipeline()
+	deleted := pipe.Del(ctx, sleepTimerKey(userID))
+	pipe.ZRem(ctx, sleepTimerDueKey, userID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to delete sleep timer: %w", err)
+	}
+	return deleted.Val() > 0, nil
+}
+
+// PopDueSleepTimers 取出最多 limit 个 now 之前到期的睡眠定时的用户ID，多个实例不会重复取出同一个定时
+func PopDueSleepTimers(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	result, err := popDueScript.Run(ctx, RedisClient, []string{sleepTimerDueKey}, now.UnixMilli(), limit).StringSlice()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to pop sleep timers: %w", err)
+	}
+	userIDs := make([]int64, 0, len(result))
+	for _, member := range result {
+		if id, err := strconv.ParseInt(member, 10, 64); err == nil {
+			userIDs = append(userIDs, id)
+		}
+	}
+	return userIDs, nil
+}
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// transcodeJobsKey 待处理的转码任务列表，API 服务从左侧写入，转码 worker 从右侧取出
+	transcodeJobsKey = "transcode:jobs"
+	// transcodeProcessingKeyPrefix 每个 worker 正在处理的任务列表，任务完成后才从中删除
+	transcodeProcessingKeyPrefix = "transcode:processing:"
+	// transcodeWorkersKey 转码 worker 的最近心跳时间，field 为 worker 名称，用于找出已退出的 worker
+	transcodeWorkersKey = "transcode:workers"
+	// transcodeStatusKey 交给转码 worker 处理的曲目的任务状态，field 为曲目 ID
+	transcodeStatusKey = "transcode:status"
+)
+
+// TranscodeStatus 转码任务的状态，由 API 服务入队时和 worker 开始、结束时写入
+type TranscodeStatus struct {
+	State     string `json:"state"` // queued、processing、completed、failed
+	Worker    string `json:"worker,omitempty"`
+	Error     string `json:"error,omitempty"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// PushTranscodeJob 将序列化后的转码任务加入队列
+func PushTranscodeJob(ctx context.Context, job []byte) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return RedisClient.LPush(ctx, transcodeJobsKey, job).Err()
+}
+
+// transcodeProcessingKey worker 正在处理的任务列表
+func transcodeProcessingKey(worker string) string {
+	return transcodeProcessingKeyPrefix + worker
+}
+
+// ClaimTranscodeJob 阻塞等待一个转码任务并原子地移入 worker 的处理中列表，timeout 内没有任务时返回 nil
+// 任务处理完成后须调用 AckTranscodeJob，worker 中途退出时任务留在处理中列表，由 RequeueStaleTranscodeJobs 放回队列
+func ClaimTranscodeJob(ctx context.Context, worker string, timeout time.Duration) ([]byte, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+	data, err := RedisClient.BLMove(ctx, transcodeJobsKey, transcodeProcessingKey(worker), "RIGHT", "LEFT", timeout).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// AckTranscodeJob 任务处理完成（无论成功或失败），从 worker 的处理中列表删除
+func AckTranscodeJob(ctx context.Context, worker string, job []byte) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return RedisClient.LRem(ctx, transcodeProcessingKey(worker), 1, job).Err()
+}
+
+// TouchTranscodeWorker 记录 worker 的心跳
+func TouchTranscodeWorker(ctx context.Context, worker string) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return RedisClient.HSet(ctx, transcodeWorkersKey, worker, time.Now().Unix()).Err()
+}
+
+// RemoveTranscodeWorker worker 正常停止时注销，处理中列表中剩余的任务放回队列
+func RemoveTranscodeWorker(ctx context.Context, worker string) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	if _, err := requeueTranscodeJobs(ctx, worker); err != nil {
+		return err
+	}
+	return RedisClient.HDel(ctx, transcodeWorkersKey, worker).Err()
+}
+
+// RequeueStaleTranscodeJobs 把超过 staleAfter 没有心跳的 worker 处理中的任务放回队列头部并注销这些 worker，返回放回的任务数
+func RequeueStaleTranscodeJobs(ctx context.Context, staleAfter time.Duration) (int, error) {
+	if RedisClient == nil {
+		return 0, fmt.Errorf("redis client not initialized")
+	}
+	workers, err := RedisClient.HGetAll(ctx, transcodeWorkersKey).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	deadline := time.Now().Add(-staleAfter).Unix()
+	total := 0
+	for worker, value := range workers {
+		if heartbeat, err := strconv.ParseInt(value, 10, 64); err == nil && heartbeat >= deadline {
+			continue
+		}
+		n, err := requeueTranscodeJobs(ctx, worker)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if err := RedisClient.HDel(ctx, transcodeWorkersKey, worker).Err(); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// requeueTranscodeJobs 把 worker 处理中列表里的任务逐个移回队列中最先被取出的一端
+func requeueTranscodeJobs(ctx context.Context, worker string) (int, error) {
+	n := 0
+	for {
+		err := RedisClient.LMove(ctx, transcodeProcessingKey(worker), transcodeJobsKey, "RIGHT", "RIGHT").Err()
+		if err == redis.Nil {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// TranscodeQueueLength 队列中等待处理的转码任务数
+func TranscodeQueueLength(ctx context.Context) (int64, error) {
+	if RedisClient == nil {
+		return 0, fmt.Errorf("redis client not initialized")
+	}
+	return RedisClient.LLen(ctx, transcodeJobsKey).Result()
+}
+
+// SetTranscodeStatus 写入曲目转码任务的状态
+func SetTranscodeStatus(ctx context.Context, trackID int64, status *TranscodeStatus) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	status.UpdatedAt = time.Now().Unix()
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return RedisClient.HSet(ctx, transcodeStatusKey, strconv.FormatInt(trackID, 10), data).Err()
+}
+
+// GetTranscodeStatus 读取曲目转码任务的状态，没有交给 worker 处理过的曲目返回 nil
+func GetTranscodeStatus(ctx context.Context, trackID int64) (*TranscodeStatus, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+	data, err := RedisClient.HGet(ctx, transcodeStatusKey, strconv.FormatInt(trackID, 10)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var status TranscodeStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transcode status: %w", err)
+	}
+	return &status, nil
+}
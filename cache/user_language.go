@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// userLanguagesKey 用户设置的语言偏好，field 为用户 ID；认证中间件据此选择响应语言，避免每个请求读取用户表
+const userLanguagesKey = "users:language"
+
+// SetUserLanguage 保存用户的语言偏好，lang 为空时删除，之后按 Accept-Language 选择语言
+func SetUserLanguage(ctx context.Context, userID int64, lang string) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	field := strconv.FormatInt(userID, 10)
+	if lang == "" {
+		return RedisClient.HDel(ctx, userLanguagesKey, field).Err()
+	}
+	return RedisClient.HSet(ctx, userLanguagesKey, field, lang).Err()
+}
+
+// GetUserLanguage 读取用户的语言偏好，未设置或 Redis 不可用时返回空串
+func GetUserLanguage(ctx context.Context, userID int64) string {
+	if RedisClient == nil {
+		return ""
+	}
+	lang, err := RedisClient.HGet(ctx, userLanguagesKey, strconv.FormatInt(userID, 10)).Result()
+	if err != nil {
+		return ""
+	}
+	return lang
+}
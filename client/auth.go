@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"Bt1QFM/model"
+)
+
+// LoginResult 登录结果
+type LoginResult struct {
+	Token string     `json:"token"`
+	User  model.User `json:"user"`
+}
+
+// Login 使用用户名或邮箱登录，成功后客户端后续请求自动携带返回的 Token
+func (c *Client) Login(ctx context.Context, username, password string) (*LoginResult, error) {
+	req, err := jsonRequest(http.MethodPost, "/api/auth/login", map[string]string{
+		"username": username,
+		"password": password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result LoginResult
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	c.SetToken(result.Token)
+	return &result, nil
+}
+
+// RegisterRequest 注册请求
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	Phone    string `json:"phone,omitempty"`
+}
+
+// RegisteredUser 注册成功的用户
+type RegisteredUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Status   string `json:"status"`
+	Phone    string `json:"phone,omitempty"`
+}
+
+// RegisterResult 注册结果，服务端要求先验证邮箱时 Token 为空，验证后再登录
+type RegisterResult struct {
+	User                 RegisteredUser `json:"user"`
+	VerificationRequired bool           `json:"verificationRequired"`
+	Token                string         `json:"token,omitempty"`
+}
+
+// Register 注册账号，返回 Token 时客户端后续请求自动携带
+func (c *Client) Register(ctx context.Context, in RegisterRequest) (*RegisterResult, error) {
+	req, err := jsonRequest(http.MethodPost, "/api/auth/register", in)
+	if err != nil {
+		return nil, err
+	}
+	var result RegisterResult
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	if result.Token != "" {
+		c.SetToken(result.Token)
+	}
+	return &result, nil
+}
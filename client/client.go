@@ -0,0 +1,255 @@
+// Package client 1QFM HTTP API 的 Go 客户端，供机器人和命令行工具调用，免去手写 HTTP 请求
+//
+//	c := client.New("http://localhost:8080")
+//	if _, err := c.Login(ctx, "alice", "secret"); err != nil {
+//		return err
+//	}
+//	tracks, err := c.ListTracks(ctx, client.TrackQuery{})
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	defaultRetryWait  = 500 * time.Millisecond
+	maxRetryWait      = 30 * time.Second
+)
+
+// Client 1QFM API 客户端，可在多个 goroutine 中共用
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	// MaxRetries 请求失败后的最大重试次数，为 0 时不重试
+	MaxRetries int
+	// RetryWait 第一次重试前的等待时间，之后每次翻倍；服务端返回 Retry-After 时以其为准
+	RetryWait time.Duration
+	// Language 设置后作为 Accept-Language 发送，错误信息按该语言返回
+	Language string
+
+	mu    sync.RWMutex
+	token string
+}
+
+// New 创建客户端，baseURL 为服务地址，如 http://localhost:8080
+func New(baseURL string) *Client {
+	return NewWithHTTPClient(baseURL, &http.Client{Timeout: defaultTimeout})
+}
+
+// NewWithHTTPClient 使用自定义的 http.Client 创建客户端，用于设置代理、超时等
+func NewWithHTTPClient(baseURL string, httpClient *http.Client) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+		MaxRetries: defaultMaxRetries,
+		RetryWait:  defaultRetryWait,
+	}
+}
+
+// SetToken 设置请求携带的登录 Token，登录成功后会自动设置
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// Token 返回当前使用的登录 Token
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// Error 服务端返回的错误响应，Code 对应 /api/errors 中的错误码
+type Error struct {
+	StatusCode int                    `json:"-"`
+	Code       string                 `json:"code"`
+	Message    string                 `json:"message"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	RequestID  string                 `json:"requestId,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("1qfm: status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("1qfm: %s (status %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// IsCode 判断 err 是否为指定错误码的服务端错误，如 IsCode(err, "TRACK_NOT_FOUND")
+func IsCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// request 一次 API 调用，body 保存为字节以便重试时重新发送
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+	// idempotencyKey 非空时作为 Idempotency-Key 请求头发送，服务端保证重试不会重复执行，
+	// 因此 POST 请求也可以在网络错误后重试
+	idempotencyKey string
+}
+
+// jsonRequest 构造请求体为 JSON 的请求
+func jsonRequest(method, path string, in interface{}) (*request, error) {
+	req := &request{method: method, path: path}
+	if in != nil {
+		body, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		req.body = body
+		req.contentType = "application/json"
+	}
+	return req, nil
+}
+
+// do 发送请求并将响应解析到 out，按 MaxRetries 重试：
+// 429 对所有请求重试（服务端没有处理该请求），网络错误和 502/503/504 只对幂等请求或带 Idempotency-Key 的请求重试，避免重复写入
+func (c *Client) do(ctx context.Context, req *request, out interface{}) error {
+	wait := c.RetryWait
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.send(ctx, req, out)
+		if err == nil {
+			return nil
+		}
+		if attempt >= c.MaxRetries || !c.shouldRetry(req, err) {
+			return err
+		}
+
+		delay := wait
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		if delay > maxRetryWait {
+			delay = maxRetryWait
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+// send 发送一次请求，返回服务端要求的等待时间
+func (c *Client) send(ctx context.Context, req *request, out interface{}) (time.Duration, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return 0, err
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if c.Language != "" {
+		httpReq.Header.Set("Accept-Language", c.Language)
+	}
+	if token := c.Token(); token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	if req.idempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", req.idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("1qfm: %s %s: %w", req.method, req.path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("1qfm: read response of %s %s: %w", req.method, req.path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(raw, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(raw))
+		}
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return retryAfter, apiErr
+	}
+	if out != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return 0, fmt.Errorf("1qfm: invalid response of %s %s: %w", req.method, req.path, err)
+		}
+	}
+	return 0, nil
+}
+
+// shouldRetry 判断失败的请求能否重试
+func (c *Client) shouldRetry(req *request, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		// 网络错误时无法确认服务端是否已处理
+		return req.idempotent()
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return req.idempotent()
+	case http.StatusConflict:
+		// 上一次尝试仍在服务端处理中，稍后重试可取得它的结果
+		return apiErr.Code == "IDEMPOTENCY_IN_PROGRESS"
+	}
+	return false
+}
+
+func (req *request) idempotent() bool {
+	if req.idempotencyKey != "" {
+		return true
+	}
+	switch req.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// newIdempotencyKey 生成随机的 Idempotency-Key，同一次调用的各次重试共用一个键
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
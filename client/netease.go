@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// NeteaseSong 网易云搜索结果中的一首歌
+type NeteaseSong struct {
+	ID       int64    `json:"id"`
+	Name     string   `json:"name"`
+	Artists  []string `json:"artists"`
+	Album    string   `json:"album"`
+	Duration int      `json:"duration"`
+	PicURL   string   `json:"picUrl,omitempty"`
+	VideoURL string   `json:"videoUrl,omitempty"` // 动态封面视频
+}
+
+// SearchNetease 搜索网易云歌曲，limit 不大于 0 时由服务端决定数量（默认 3，最多 50）
+func (c *Client) SearchNetease(ctx context.Context, keyword string, limit int) ([]NeteaseSong, error) {
+	query := url.Values{"q": {keyword}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	// 搜索接口失败时仍返回 200，错误信息在 success/error 字段中
+	var resp struct {
+		Success bool          `json:"success"`
+		Data    []NeteaseSong `json:"data"`
+		Error   string        `json:"error"`
+	}
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/api/netease/search", query: query}, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("1qfm: netease search failed: %s", resp.Error)
+	}
+	return resp.Data, nil
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// 播放列表项的来源
+const (
+	SourceLocal   = "local"
+	SourceNetease = "netease"
+)
+
+// PlaylistItem 播放列表中的一首歌，个人播放列表和房间歌单共用
+type PlaylistItem struct {
+	Source    string `json:"source"`
+	SourceID  string `json:"sourceId"`
+	Title     string `json:"title"`
+	Name      string `json:"name,omitempty"` // 房间歌单中的歌曲名称
+	SongID    string `json:"songId,omitempty"`
+	Artist    string `json:"artist"`
+	Album     string `json:"album,omitempty"`
+	Cover     string `json:"cover,omitempty"`
+	Duration  int    `json:"duration,omitempty"` // 秒
+	HLSURL    string `json:"hlsUrl,omitempty"`
+	Position  int    `json:"position"`
+	TrackID   int64  `json:"trackId,omitempty"`
+	NeteaseID int64  `json:"neteaseId,omitempty"`
+	AddedBy   int64  `json:"addedBy,omitempty"`
+	AddedAt   int64  `json:"addedAt,omitempty"`
+}
+
+// AddToPlaylistRequest 添加到播放列表的歌曲，Source 为 local 时 SourceID 为曲目 ID
+type AddToPlaylistRequest struct {
+	Source   string `json:"source"`
+	SourceID string `json:"sourceId"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Album    string `json:"album,omitempty"`
+	Cover    string `json:"cover,omitempty"`
+	Duration int    `json:"duration,omitempty"`
+	HLSURL   string `json:"hlsUrl,omitempty"`
+}
+
+// GetPlaylist 获取当前用户的播放列表
+func (c *Client) GetPlaylist(ctx context.Context) ([]PlaylistItem, error) {
+	var resp struct {
+		Playlist []PlaylistItem `json:"playlist"`
+	}
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/api/playlist"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Playlist, nil
+}
+
+// AddToPlaylist 将歌曲添加到播放列表末尾，重试时使用同一 Idempotency-Key，不会重复添加
+func (c *Client) AddToPlaylist(ctx context.Context, in AddToPlaylistRequest) error {
+	req, err := jsonRequest(http.MethodPost, "/api/playlist", in)
+	if err != nil {
+		return err
+	}
+	req.idempotencyKey = newIdempotencyKey()
+	return c.do(ctx, req, nil)
+}
+
+// RemoveFromPlaylist 从播放列表中删除歌曲
+func (c *Client) RemoveFromPlaylist(ctx context.Context, source, sourceID string) error {
+	query := url.Values{"source": {source}, "sourceId": {sourceID}}
+	return c.do(ctx, &request{method: http.MethodDelete, path: "/api/playlist", query: query}, nil)
+}
+
+// ClearPlaylist 清空播放列表
+func (c *Client) ClearPlaylist(ctx context.Context) error {
+	query := url.Values{"clear": {"true"}}
+	return c.do(ctx, &request{method: http.MethodDelete, path: "/api/playlist", query: query}, nil)
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"Bt1QFM/model"
+)
+
+// AddRoomSongRequest 添加到房间歌单的歌曲
+type AddRoomSongRequest struct {
+	SongID   string `json:"songId"`
+	Name     string `json:"name"`
+	Artist   string `json:"artist"`
+	Cover    string `json:"cover,omitempty"`
+	Duration int    `json:"duration,omitempty"`
+	Source   string `json:"source,omitempty"`
+}
+
+// JoinRoomResult 加入房间的结果
+type JoinRoomResult struct {
+	Room   *model.Room       `json:"room"`
+	Member *model.RoomMember `json:"member"`
+}
+
+func roomPath(roomID, suffix string) string {
+	return "/api/rooms/" + url.PathEscape(roomID) + suffix
+}
+
+// CreateRoom 创建房间，name 为空时使用“用户名的房间”
+func (c *Client) CreateRoom(ctx context.Context, name string) (*model.Room, error) {
+	req, err := jsonRequest(http.MethodPost, "/api/rooms", map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Room *model.Room `json:"room"`
+	}
+	if err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Room, nil
+}
+
+// MyRooms 获取当前用户参与的房间
+func (c *Client) MyRooms(ctx context.Context) ([]*model.UserRoomInfo, error) {
+	var rooms []*model.UserRoomInfo
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/api/rooms/my"}, &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// GetRoom 获取房间信息和在线成员
+func (c *Client) GetRoom(ctx context.Context, roomID string) (*model.RoomInfo, error) {
+	var info model.RoomInfo
+	if err := c.do(ctx, &request{method: http.MethodGet, path: roomPath(roomID, "")}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// JoinRoom 加入房间
+func (c *Client) JoinRoom(ctx context.Context, roomID string) (*JoinRoomResult, error) {
+	req, err := jsonRequest(http.MethodPost, "/api/rooms/join", map[string]string{"roomId": roomID})
+	if err != nil {
+		return nil, err
+	}
+	var result JoinRoomResult
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// LeaveRoom 离开房间，房主离开时房主身份转给其他成员
+func (c *Client) LeaveRoom(ctx context.Context, roomID string) error {
+	req, err := jsonRequest(http.MethodPost, "/api/rooms/leave", map[string]string{"roomId": roomID})
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, req, nil)
+}
+
+// RoomPlaylist 获取房间歌单
+func (c *Client) RoomPlaylist(ctx context.Context, roomID string) ([]PlaylistItem, error) {
+	var playlist []PlaylistItem
+	if err := c.do(ctx, &request{method: http.MethodGet, path: roomPath(roomID, "/playlist")}, &playlist); err != nil {
+		return nil, err
+	}
+	return playlist, nil
+}
+
+// AddRoomSong 添加歌曲到房间歌单，只有房间成员可以添加
+func (c *Client) AddRoomSong(ctx context.Context, roomID string, in AddRoomSongRequest) error {
+	req, err := jsonRequest(http.MethodPost, roomPath(roomID, "/playlist"), in)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, req, nil)
+}
+
+// RoomPlayback 获取房间当前的播放状态
+func (c *Client) RoomPlayback(ctx context.Context, roomID string) (*model.RoomPlaybackState, error) {
+	var state model.RoomPlaybackState
+	if err := c.do(ctx, &request{method: http.MethodGet, path: roomPath(roomID, "/playback")}, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"Bt1QFM/model"
+)
+
+// TrackQuery 曲库列表的筛选条件，零值返回直接上传的全部曲目
+type TrackQuery struct {
+	IncludeAlbum bool     // 同时返回通过专辑上传的曲目
+	Tags         []string // 需同时带有的全部标签
+	Keyword      string   // 匹配标题、歌手和专辑
+}
+
+// ListTracks 获取当前用户的曲库
+func (c *Client) ListTracks(ctx context.Context, q TrackQuery) ([]*model.Track, error) {
+	query := url.Values{}
+	if q.IncludeAlbum {
+		query.Set("includeAlbum", "true")
+	}
+	for _, tag := range q.Tags {
+		query.Add("tag", tag)
+	}
+	if q.Keyword != "" {
+		query.Set("q", q.Keyword)
+	}
+
+	var tracks []*model.Track
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/api/tracks", query: query}, &tracks); err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// UploadTrackRequest 上传曲目请求，Audio 会完整读入内存以便限流时重试
+type UploadTrackRequest struct {
+	FileName string
+	Audio    io.Reader
+	Title    string
+	Artist   string
+	Album    string
+	License  string
+}
+
+// UploadTrackResult 上传结果，曲目在后台转码，Track.Status 为 completed 后可以播放
+type UploadTrackResult struct {
+	Message     string       `json:"message"`
+	TrackID     int64        `json:"trackId"`
+	Track       *model.Track `json:"track"`
+	DuplicateOf []int64      `json:"duplicateOf,omitempty"`
+	Warning     string       `json:"warning,omitempty"`
+}
+
+// UploadTrack 上传一首曲目到曲库，网络错误时使用同一 Idempotency-Key 重试，不会重复创建曲目
+func (c *Client) UploadTrack(ctx context.Context, in UploadTrackRequest) (*UploadTrackResult, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"title":   in.Title,
+		"artist":  in.Artist,
+		"album":   in.Album,
+		"license": in.License,
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := form.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	part, err := form.CreateFormFile("trackFile", in.FileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, in.Audio); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req := &request{
+		method:         http.MethodPost,
+		path:           "/api/upload",
+		body:           body.Bytes(),
+		contentType:    form.FormDataContentType(),
+		idempotencyKey: newIdempotencyKey(),
+	}
+	var result UploadTrackResult
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteTrack 将曲目移入回收站
+func (c *Client) DeleteTrack(ctx context.Context, trackID int64) error {
+	path := "/api/tracks/" + strconv.FormatInt(trackID, 10)
+	return c.do(ctx, &request{method: http.MethodDelete, path: path}, nil)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/core/backup"
+	"Bt1QFM/db"
+	"Bt1QFM/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupOutputDir string
+
+	restoreYes          bool
+	restoreSkipDatabase bool
+	restoreConfigDir    string
+)
+
+// connectBackupTargets 连接对象存储和数据库，失败时直接退出
+func connectBackupTargets(cfg *config.Config) {
+	if err := storage.InitStorage(cfg); err != nil {
+		log.Fatalf("初始化对象存储失败: %v", err)
+	}
+	if err := db.ConnectDB(cfg); err != nil {
+		log.Fatalf("连接数据库失败: %v", err)
+	}
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "备份数据库、对象存储清单和配置文件",
+	Long: `导出所有表的结构和数据、对象存储中所有对象的清单（键、大小、ETag），连同 BACKUP_CONFIG_FILES 中的配置文件打包为 tar.gz。
+对象内容不在备份中，请使用存储自身的复制功能或 migrate-storage 同步到备用存储桶。
+完成后按 BACKUP_KEEP 删除旧备份。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := config.Load()
+		if backupOutputDir != "" {
+			cfg.BackupDir = backupOutputDir
+		}
+		connectBackupTargets(cfg)
+		defer db.DB.Close()
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+
+		result, err := backup.NewService(db.DB, cfg).Run(ctx)
+		if err != nil {
+			log.Fatalf("备份失败: %v", err)
+		}
+		fmt.Printf("备份完成: %s (%s)\n", result.File, storage.FormatSize(result.Size))
+		fmt.Printf("  数据库: %d 张表，%d 行\n", result.Tables, result.Rows)
+		fmt.Printf("  对象存储清单: %d 个对象，%s\n", result.Objects, storage.FormatSize(result.ObjectBytes))
+		fmt.Printf("  配置文件: %v\n", result.ConfigFiles)
+		fmt.Printf("  耗时: %v\n", (time.Duration(result.DurationMs) * time.Millisecond).Round(time.Millisecond))
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <备份文件>",
+	Short: "用备份恢复实例",
+	Long: `导入备份中的数据库转储（删除并重建同名表），并执行当前版本的数据库迁移；配置文件写入 --config-dir，已存在时写为 <文件名>.restored。
+最后按清单核对对象存储，列出缺失的对象，需从备用存储桶用 migrate-storage 复制回来。
+恢复会覆盖现有数据，必须指定 --yes。`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !restoreYes && !restoreSkipDatabase {
+			log.Fatal("恢复会覆盖数据库中的现有数据，确认后请加 --yes")
+		}
+
+		cfg := config.Load()
+		connectBackupTargets(cfg)
+		defer db.DB.Close()
+
+		start := time.Now()
+		result, err := backup.Restore(context.Background(), db.DB, args[0], backup.RestoreOptions{
+			SkipDatabase: restoreSkipDatabase,
+			ConfigDir:    restoreConfigDir,
+		})
+		if err != nil {
+			log.Fatalf("恢复失败: %v", err)
+		}
+		if !restoreSkipDatabase {
+			if err := db.InitDB(); err != nil {
+				log.Fatalf("数据库迁移失败: %v", err)
+			}
+			fmt.Printf("数据库: 执行 %d 条语句\n", result.Statements)
+		}
+		for _, path := range result.ConfigFiles {
+			fmt.Printf("配置文件: %s\n", path)
+		}
+		fmt.Printf("对象存储: 清单中 %d 个对象，缺失 %d 个（%s）\n", result.Objects, result.MissingObjects, storage.FormatSize(result.MissingBytes))
+		for _, key := range result.MissingSample {
+			fmt.Printf("  缺失 %s\n", key)
+		}
+		if result.MissingObjects > len(result.MissingSample) {
+			fmt.Printf("  ……另有 %d 个\n", result.MissingObjects-len(result.MissingSample))
+		}
+		fmt.Printf("恢复完成，耗时 %v\n", time.Since(start).Round(time.Millisecond))
+	},
+}
+
+func init() {
+	backupCmd.Flags().StringVarP(&backupOutputDir, "output", "o", "", "备份目录，默认为 BACKUP_DIR")
+	backupCmd.Example = `  1qfm_server backup
+  1qfm_server backup -o /mnt/nas/1qfm-backups`
+
+	restoreCmd.Flags().BoolVar(&restoreYes, "yes", false, "确认覆盖数据库")
+	restoreCmd.Flags().BoolVar(&restoreSkipDatabase, "skip-db", false, "不导入数据库，只恢复配置文件并核对对象存储")
+	restoreCmd.Flags().StringVar(&restoreConfigDir, "config-dir", ".", "配置文件写入的目录")
+	restoreCmd.Example = `  1qfm_server restore backups/1qfm-backup-20240601-030000.tar.gz --yes
+
+  # 只检查新存储桶中缺少哪些对象
+  1qfm_server restore backups/1qfm-backup-20240601-030000.tar.gz --skip-db`
+
+	rootCmd.AddCommand(backupCmd, restoreCmd)
+}
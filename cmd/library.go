@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/repository"
+	"Bt1QFM/server"
+	"Bt1QFM/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	importUser      string
+	importAlbum     string
+	importRecursive bool
+
+	reprocessTrackIDs []int64
+
+	userCreateName     string
+	userCreateEmail    string
+	userCreatePassword string
+
+	statsDays    int
+	statsStorage bool
+	statsJSON    bool
+)
+
+// openLibrary 连接存储和数据库，失败时直接退出
+func openLibrary(transcode bool) *server.Library {
+	library, err := server.OpenLibrary(config.Load(), transcode)
+	if err != nil {
+		log.Fatalf("初始化失败: %v", err)
+	}
+	return library
+}
+
+var importDirCmd = &cobra.Command{
+	Use:   "import-dir <目录>",
+	Short: "批量导入目录中的音频文件",
+	Long: `读取目录中音频文件的标签（标题、艺术家、专辑、流派）作为曲目信息，按内容哈希存储源文件并同步转码到指定用户的曲库。
+没有标题标签时使用文件名；用户曲库中已有相同内容的曲目时跳过，中断后可重复执行。
+离线导入不受用户存储配额限制。`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if importUser == "" {
+			log.Fatal("请通过 --user 指定导入到哪个用户")
+		}
+
+		var files []string
+		root := args[0]
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && !importRecursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if server.IsImportableAudio(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("读取目录失败: %v", err)
+		}
+		if len(files) == 0 {
+			fmt.Println("目录中没有支持的音频文件")
+			return
+		}
+		sort.Strings(files)
+
+		library := openLibrary(true)
+		defer library.Close()
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+
+		user, err := library.LookupUser(ctx, importUser)
+		if err != nil {
+			log.Fatalf("查找用户失败: %v", err)
+		}
+		fmt.Printf("导入 %d 个文件到用户 %s (ID %d)\n", len(files), user.Username, user.ID)
+
+		start := time.Now()
+		imported, duplicates, failed := 0, 0, 0
+		for i, path := range files {
+			if ctx.Err() != nil {
+				fmt.Println("已中断")
+				break
+			}
+			fileStart := time.Now()
+			track, skipped, err := library.ImportFile(ctx, user.ID, path, importAlbum)
+			switch {
+			case err != nil:
+				failed++
+				fmt.Printf("[%d/%d] 失败 %s: %v\n", i+1, len(files), path, err)
+			case skipped:
+				duplicates++
+				fmt.Printf("[%d/%d] 跳过 %s（已存在，曲目 ID %d）\n", i+1, len(files), path, track.ID)
+			default:
+				imported++
+				fmt.Printf("[%d/%d] 导入 %s -> %d %s - %s (%v)\n", i+1, len(files), path,
+					track.ID, track.Artist, track.Title, time.Since(fileStart).Round(time.Millisecond))
+			}
+		}
+
+		fmt.Printf("\n导入结束，耗时 %v：导入 %d，跳过 %d，失败 %d\n", time.Since(start).Round(time.Millisecond), imported, duplicates, failed)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var reprocessCmd = &cobra.Command{
+	Use:   "reprocess",
+	Short: "重新转码曲目",
+	Long:  `按曲目所有者当前的转码偏好从源文件重新生成 HLS 流，用于修复转码失败或损坏的曲目。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(reprocessTrackIDs) == 0 {
+			log.Fatal("请通过 --track-id 指定曲目")
+		}
+
+		library := openLibrary(true)
+		defer library.Close()
+
+		failed := 0
+		for _, trackID := range reprocessTrackIDs {
+			start := time.Now()
+			track, err := library.ReprocessTrack(context.Background(), trackID)
+			if err != nil {
+				failed++
+				fmt.Printf("曲目 %d 重新转码失败: %v\n", trackID, err)
+				continue
+			}
+			fmt.Printf("曲目 %d %s 重新转码完成 (%v): %s\n", track.ID, track.Title, time.Since(start).Round(time.Millisecond), track.HLSPlaylistPath)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "用户管理",
+}
+
+var userCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "创建用户",
+	Long:  `创建已激活的账号，无需验证邮箱。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		library := openLibrary(false)
+		defer library.Close()
+
+		userID, err := library.CreateUser(context.Background(), userCreateName, userCreateEmail, userCreatePassword)
+		if errors.Is(err, repository.ErrDuplicateUser) {
+			log.Fatalf("用户名或邮箱已存在")
+		}
+		if err != nil {
+			log.Fatalf("创建用户失败: %v", err)
+		}
+		fmt.Printf("已创建用户 %s (ID %d)\n", userCreateName, userID)
+	},
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "曲库统计",
+	Long:  `统计用户数和注册趋势、各处理状态的曲目数，可选按对象实际大小统计每个用户的存储占用。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		library := openLibrary(false)
+		defer library.Close()
+
+		since := time.Now().AddDate(0, 0, -statsDays)
+		stats, err := library.Stats(context.Background(), since, statsStorage)
+		if err != nil {
+			log.Fatalf("统计失败: %v", err)
+		}
+
+		if statsJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(stats)
+			return
+		}
+
+		fmt.Printf("用户: %d\n", stats.Users.Total)
+		for _, status := range sortedKeys(stats.Users.ByStatus) {
+			fmt.Printf("  %-10s %d\n", status, stats.Users.ByStatus[status])
+		}
+		var signups int64
+		for _, day := range stats.Users.Signups {
+			signups += day.Count
+		}
+		fmt.Printf("  最近 %d 天注册 %d\n", statsDays, signups)
+
+		fmt.Printf("曲目: %d（回收站 %d）\n", stats.Tracks.Total, stats.Tracks.Trashed)
+		for _, status := range sortedKeys(stats.Tracks.ByStatus) {
+			fmt.Printf("  %-10s %d\n", status, stats.Tracks.ByStatus[status])
+		}
+
+		if usage := stats.Storage; usage != nil {
+			fmt.Printf("存储: 源音频 %s，HLS 流 %s，网易云缓存 %s，待回收 %s\n",
+				storage.FormatSize(usage.AudioBytes), storage.FormatSize(usage.StreamBytes),
+				storage.FormatSize(usage.NeteaseBytes), storage.FormatSize(usage.UnreferencedBytes))
+			for _, u := range usage.Users {
+				fmt.Printf("  用户 %-8d %4d 首 %s\n", u.UserID, u.Tracks, storage.FormatSize(u.TotalBytes))
+			}
+		}
+	},
+}
+
+// sortedKeys 按字母顺序返回计数表的键
+func sortedKeys(counts map[string]int64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	importDirCmd.Flags().StringVarP(&importUser, "user", "u", "", "导入到的用户（用户名或 ID）")
+	importDirCmd.Flags().StringVar(&importAlbum, "album", "", "覆盖标签中的专辑名")
+	importDirCmd.Flags().BoolVarP(&importRecursive, "recursive", "r", true, "包含子目录")
+	importDirCmd.Example = `  # 导入目录及子目录中的所有音频
+  1qfm_server import-dir /data/music --user alice
+
+  # 只导入顶层文件并统一专辑名
+  1qfm_server import-dir ./live-2024 -u alice --album "Live 2024" -r=false`
+
+	reprocessCmd.Flags().Int64SliceVar(&reprocessTrackIDs, "track-id", nil, "曲目 ID，可重复指定或用逗号分隔")
+	reprocessCmd.Example = `  1qfm_server reprocess --track-id 42
+  1qfm_server reprocess --track-id 42,43,44`
+
+	userCreateCmd.Flags().StringVar(&userCreateName, "username", "", "用户名")
+	userCreateCmd.Flags().StringVar(&userCreateEmail, "email", "", "邮箱")
+	userCreateCmd.Flags().StringVar(&userCreatePassword, "password", "", "密码")
+	userCreateCmd.MarkFlagRequired("username")
+	userCreateCmd.MarkFlagRequired("email")
+	userCreateCmd.MarkFlagRequired("password")
+	userCmd.AddCommand(userCreateCmd)
+
+	statsCmd.Flags().IntVar(&statsDays, "days", 30, "统计最近多少天的注册数")
+	statsCmd.Flags().BoolVar(&statsStorage, "storage", false, "列出对象存储统计每个用户的占用（对象多时较慢）")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "以 JSON 输出")
+
+	rootCmd.AddCommand(importDirCmd, reprocessCmd, userCmd, statsCmd)
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/loadtest"
+
+	"github.com/spf13/cobra"
+)
+
+var loadtestCmd = &cobra.Command{
+	Use:   "loadtest",
+	Short: "性能基准与压测工具",
+	Long: `对 RoomHub 广播和 MP3Processor 转码进行基准测试，或对运行中的实例进行压测。
+hub、transcode 在进程内运行，无需启动服务；rooms、uploads 针对运行中的实例。
+可重复对比的基准测试见 go test -bench . ./core/room ./core/audio。`,
+}
+
+var (
+	ltClients       int
+	ltMessages      int
+	ltInterval      time.Duration
+	ltSlowClients   int
+	ltConsumerDelay time.Duration
+	ltDrainTimeout  time.Duration
+
+	ltInput        string
+	ltJobs         int
+	ltConcurrency  int
+	ltTrimSilence  bool
+	ltCrossfade    float64
+	ltBaseURL      string
+	ltToken        string
+	ltRoomID       string
+	ltUserIDBase   int64
+	ltPollInterval time.Duration
+	ltTimeout      time.Duration
+	ltCleanup      bool
+)
+
+var loadtestHubCmd = &cobra.Command{
+	Use:   "hub",
+	Short: "进程内 RoomHub 广播基准测试",
+	Long:  `在进程内启动 RoomHub，注册模拟客户端并广播聊天消息，报告广播延迟分位数和丢弃消息数。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Printf("开始 Hub 广播测试: %d 个客户端（慢客户端 %d 个），%d 条消息\n", ltClients, ltSlowClients, ltMessages)
+		report, err := loadtest.RunHub(loadtest.HubOptions{
+			Clients:       ltClients,
+			Messages:      ltMessages,
+			Interval:      ltInterval,
+			SlowClients:   ltSlowClients,
+			ConsumerDelay: ltConsumerDelay,
+			DrainTimeout:  ltDrainTimeout,
+		})
+		if err != nil {
+			log.Fatalf("Hub 广播测试失败: %v", err)
+		}
+
+		fmt.Printf("耗时: %v\n", report.Elapsed.Round(time.Millisecond))
+		fmt.Printf("投递: %d/%d，丢弃: %d，断开客户端: %d\n", report.Delivered, report.Expected, report.Dropped, report.Disconnected)
+		report.Latency.Fprint(os.Stdout, "广播延迟")
+	},
+}
+
+var loadtestTranscodeCmd = &cobra.Command{
+	Use:   "transcode",
+	Short: "进程内 MP3Processor 转码吞吐基准测试",
+	Long:  `以固定并发度重复将样本音频转码为 HLS，报告排队等待时间、单次转码耗时和整体实时倍率。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if ltInput == "" {
+			log.Fatal("请通过 --input 指定样本音频文件")
+		}
+		cfg := config.Load()
+		fmt.Printf("开始转码测试: %d 个任务，并发 %d，码率 %s\n", ltJobs, ltConcurrency, cfg.AudioBitrate)
+
+		var opts *audio.TranscodeOptions
+		if ltTrimSilence || ltCrossfade > 0 {
+			opts = &audio.TranscodeOptions{TrimSilence: ltTrimSilence, CrossfadeSeconds: ltCrossfade}
+		}
+		report, err := loadtest.RunTranscode(loadtest.TranscodeOptions{
+			FFmpegPath:     cfg.FFmpegPath,
+			Input:          ltInput,
+			Jobs:           ltJobs,
+			Concurrency:    ltConcurrency,
+			AudioBitrate:   cfg.AudioBitrate,
+			HLSSegmentTime: cfg.HLSSegmentTime,
+			Transcode:      opts,
+		})
+		if err != nil {
+			log.Fatalf("转码测试失败: %v", err)
+		}
+
+		fmt.Printf("耗时: %v，失败: %d/%d，实时倍率: %.1fx\n",
+			report.Elapsed.Round(time.Millisecond), report.Failed, report.Jobs, report.RealtimeFactor())
+		report.QueueWait.Fprint(os.Stdout, "排队等待")
+		report.Transcode.Fprint(os.Stdout, "转码耗时")
+	},
+}
+
+var loadtestRoomsCmd = &cobra.Command{
+	Use:   "rooms",
+	Short: "对运行中实例的房间 WebSocket 压测",
+	Long:  `向指定房间建立多个 WebSocket 连接，由第一个连接发送聊天消息，报告端到端广播延迟和丢失消息数。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if ltRoomID == "" || ltToken == "" {
+			log.Fatal("请通过 --room 和 --token 指定房间和 token")
+		}
+		fmt.Printf("开始房间压测: %s 房间 %s，%d 个客户端，%d 条消息\n", ltBaseURL, ltRoomID, ltClients, ltMessages)
+		report, err := loadtest.RunRooms(loadtest.RoomsOptions{
+			BaseURL:      ltBaseURL,
+			RoomID:       ltRoomID,
+			Token:        ltToken,
+			Clients:      ltClients,
+			Messages:     ltMessages,
+			Interval:     ltInterval,
+			UserIDBase:   ltUserIDBase,
+			DrainTimeout: ltDrainTimeout,
+		})
+		if err != nil {
+			log.Fatalf("房间压测失败: %v", err)
+		}
+
+		fmt.Printf("连接: 成功 %d，失败 %d\n", report.Connected, report.ConnectFail)
+		fmt.Printf("耗时: %v\n", report.Elapsed.Round(time.Millisecond))
+		fmt.Printf("投递: %d/%d，丢失: %d，被断开连接: %d\n", report.Delivered, report.Expected, report.Missing(), report.Disconnected)
+		report.Connect.Fprint(os.Stdout, "连接耗时")
+		report.Latency.Fprint(os.Stdout, "广播延迟")
+	},
+}
+
+var loadtestUploadsCmd = &cobra.Command{
+	Use:   "uploads",
+	Short: "对运行中实例的并发上传压测",
+	Long:  `并发上传样本音频并轮询 HLS 播放列表，报告上传耗时和转码排队等待时间。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if ltInput == "" || ltToken == "" {
+			log.Fatal("请通过 --input 和 --token 指定样本音频和 token")
+		}
+		fmt.Printf("开始上传压测: %s，%d 次上传，并发 %d\n", ltBaseURL, ltJobs, ltConcurrency)
+		report, err := loadtest.RunUploads(loadtest.UploadsOptions{
+			BaseURL:      ltBaseURL,
+			Token:        ltToken,
+			File:         ltInput,
+			Uploads:      ltJobs,
+			Concurrency:  ltConcurrency,
+			PollInterval: ltPollInterval,
+			Timeout:      ltTimeout,
+			Cleanup:      ltCleanup,
+		})
+		if err != nil {
+			log.Fatalf("上传压测失败: %v", err)
+		}
+
+		fmt.Printf("耗时: %v，成功: %d/%d，失败: %d\n",
+			report.Elapsed.Round(time.Millisecond), report.Succeeded, report.Uploads, report.Failed)
+		statuses := make([]int, 0, len(report.Rejected))
+		for status := range report.Rejected {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+		for _, status := range statuses {
+			fmt.Printf("被拒绝 HTTP %d: %d\n", status, report.Rejected[status])
+		}
+		report.Upload.Fprint(os.Stdout, "上传耗时")
+		report.TranscodeWait.Fprint(os.Stdout, "转码等待")
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{loadtestHubCmd, loadtestRoomsCmd} {
+		c.Flags().IntVar(&ltClients, "clients", 100, "模拟客户端数量")
+		c.Flags().IntVar(&ltMessages, "messages", 1000, "广播消息数量")
+		c.Flags().DurationVar(&ltInterval, "interval", 0, "两条消息之间的间隔")
+		c.Flags().DurationVar(&ltDrainTimeout, "drain-timeout", 10*time.Second, "发送结束后等待消息到达的最长时间")
+	}
+	loadtestHubCmd.Flags().IntVar(&ltSlowClients, "slow-clients", 0, "慢消费客户端数量")
+	loadtestHubCmd.Flags().DurationVar(&ltConsumerDelay, "consumer-delay", 5*time.Millisecond, "慢消费客户端处理每条消息的耗时")
+
+	loadtestRoomsCmd.Flags().StringVar(&ltRoomID, "room", "", "已存在的房间ID")
+	loadtestRoomsCmd.Flags().Int64Var(&ltUserIDBase, "user-id-base", 900000, "模拟用户ID起始值")
+
+	for _, c := range []*cobra.Command{loadtestTranscodeCmd, loadtestUploadsCmd} {
+		c.Flags().StringVar(&ltInput, "input", "", "样本音频文件")
+		c.Flags().IntVar(&ltJobs, "jobs", 10, "任务总数")
+		c.Flags().IntVar(&ltConcurrency, "concurrency", 2, "并发数")
+	}
+	loadtestTranscodeCmd.Flags().BoolVar(&ltTrimSilence, "trim-silence", false, "转码时裁剪首尾静音")
+	loadtestTranscodeCmd.Flags().Float64Var(&ltCrossfade, "crossfade", 0, "淡入淡出时长（秒）")
+
+	loadtestUploadsCmd.Flags().DurationVar(&ltPollInterval, "poll-interval", 500*time.Millisecond, "轮询播放列表的间隔")
+	loadtestUploadsCmd.Flags().DurationVar(&ltTimeout, "timeout", 5*time.Minute, "单首歌曲等待转码完成的最长时间")
+	loadtestUploadsCmd.Flags().BoolVar(&ltCleanup, "cleanup", false, "结束后删除压测上传的歌曲")
+
+	for _, c := range []*cobra.Command{loadtestRoomsCmd, loadtestUploadsCmd} {
+		c.Flags().StringVar(&ltBaseURL, "url", "http://localhost:8080", "服务地址")
+		c.Flags().StringVar(&ltToken, "token", "", "认证 token")
+	}
+
+	loadtestCmd.AddCommand(loadtestHubCmd, loadtestTranscodeCmd, loadtestRoomsCmd, loadtestUploadsCmd)
+	rootCmd.AddCommand(loadtestCmd)
+}
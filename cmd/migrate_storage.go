@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateFromBucket   string
+	migrateFromEndpoint string
+	migrateFromRegion   string
+	migrateClasses      []string
+	migrateWorkers      int
+	migrateDryRun       bool
+	migrateDeleteSource bool
+)
+
+var migrateStorageCmd = &cobra.Command{
+	Use:   "migrate-storage",
+	Short: "在存储桶之间迁移对象",
+	Long: `将源存储桶中的源音频、封面和 HLS 流按类别复制到配置中对应的存储桶
+（MINIO_AUDIO_BUCKET、MINIO_COVER_BUCKET、MINIO_STREAM_BUCKET 及对应的 ENDPOINT/REGION）。
+目标中已存在相同对象时跳过，中断后可重复执行。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := config.Load()
+		source := config.BucketConfig{Name: migrateFromBucket, Endpoint: migrateFromEndpoint, Region: migrateFromRegion}
+		if source.Name == "" {
+			source.Name = cfg.MinioBucket
+		}
+
+		fmt.Printf("源存储桶: %s\n", source.Name)
+		for _, class := range migrateClasses {
+			bucket := storage.BucketFor(cfg, class)
+			fmt.Printf("  %-8s -> %s (%s)\n", class, bucket.Name, bucket.Endpoint)
+		}
+		if migrateDryRun {
+			fmt.Println("试运行模式：只统计需要复制的对象")
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+
+		// 进度输出限流，避免对象很多时刷屏
+		var mu sync.Mutex
+		var lastPrint time.Time
+		onProgress := func(p storage.MigrateProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			if p.Done() != p.Total && time.Since(lastPrint) < 500*time.Millisecond {
+				return
+			}
+			lastPrint = time.Now()
+			percent := 100.0
+			if p.Total > 0 {
+				percent = float64(p.Done()) / float64(p.Total) * 100
+			}
+			fmt.Printf("\r[%s] %d/%d (%.1f%%) %s/%s 复制 %d 跳过 %d 失败 %d   ",
+				p.Class, p.Done(), p.Total, percent,
+				storage.FormatSize(p.Bytes), storage.FormatSize(p.TotalBytes),
+				p.Copied, p.Skipped, p.Failed)
+			if p.Done() == p.Total {
+				fmt.Println()
+			}
+		}
+
+		start := time.Now()
+		results, err := storage.MigrateBuckets(ctx, cfg, storage.MigrateOptions{
+			Source:       source,
+			Classes:      migrateClasses,
+			Workers:      migrateWorkers,
+			DryRun:       migrateDryRun,
+			DeleteSource: migrateDeleteSource,
+		}, onProgress)
+
+		fmt.Printf("\n迁移结束，耗时 %v\n", time.Since(start).Round(time.Millisecond))
+		failed := 0
+		for _, r := range results {
+			fmt.Printf("  %-8s 共 %d 个对象 (%s)，复制 %d，跳过 %d，失败 %d\n",
+				r.Class, r.Total, storage.FormatSize(r.TotalBytes), r.Copied, r.Skipped, r.Failed)
+			if r.LastFailure != nil {
+				fmt.Printf("           最近一次失败: %v\n", r.LastFailure)
+			}
+			failed += r.Failed
+		}
+		if err != nil {
+			log.Fatalf("迁移失败: %v", err)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	migrateStorageCmd.Flags().StringVar(&migrateFromBucket, "from-bucket", "", "源存储桶，默认 MINIO_BUCKET")
+	migrateStorageCmd.Flags().StringVar(&migrateFromEndpoint, "from-endpoint", "", "源 Endpoint，默认 MINIO_ENDPOINT")
+	migrateStorageCmd.Flags().StringVar(&migrateFromRegion, "from-region", "", "源 Region，默认 MINIO_REGION")
+	migrateStorageCmd.Flags().StringSliceVar(&migrateClasses, "class", storage.Classes, "迁移的对象类别（audio、covers、streams）")
+	migrateStorageCmd.Flags().IntVar(&migrateWorkers, "workers", 4, "并发复制数")
+	migrateStorageCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "只统计不复制")
+	migrateStorageCmd.Flags().BoolVar(&migrateDeleteSource, "delete-source", false, "复制成功后删除源对象")
+
+	migrateStorageCmd.Example = `  # 按当前配置拆分默认存储桶
+  1qfm_server migrate-storage
+
+  # 只迁移 HLS 流，先试运行
+  1qfm_server migrate-storage --class streams --dry-run`
+
+	rootCmd.AddCommand(migrateStorageCmd)
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"Bt1QFM/server"
+
+	"github.com/spf13/cobra"
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "启动转码 worker",
+	Long: `只运行转码：从 Redis 队列取出上传曲目的转码任务，转码后将 HLS 分片上传到对象存储，并回写曲目状态。
+API 服务需配置 TRANSCODE_DISPATCH=worker 才会把转码任务写入队列；worker 与 API 服务使用相同的数据库、Redis 和对象存储配置，可以启动多个。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		server.StartTranscodeWorker()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(workerCmd)
+}
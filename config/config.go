@@ -1,10 +1,14 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -13,6 +17,8 @@ import (
 // For V1, these are mostly hardcoded or have simple defaults.
 type Config struct {
 	FFmpegPath     string
+	FFprobePath    string // 为空时使用 ffmpeg 同目录下或 PATH 中的 ffprobe
+	FpcalcPath     string // Chromaprint fpcalc，用于音频指纹
 	AudioBitrate   string // e.g., "192k"
 	HLSSegmentTime string
 	SourceAudioDir string // Base directory for storing original uploaded audio files
@@ -23,9 +29,19 @@ type Config struct {
 	DBUser         string
 	DBPassword     string
 	DBName         string
-	UploadDir      string // Base directory for all uploads
-	AudioUploadDir string // Subdirectory for audio files: UploadDir/audio
-	CoverUploadDir string // Subdirectory for cover art: UploadDir/covers
+	// 数据库查询超时（秒），列表查询单独配置，<=0 表示不限制
+	DBQueryTimeoutSeconds     int
+	DBListQueryTimeoutSeconds int
+	UploadDir                 string // Base directory for all uploads
+	AudioUploadDir            string // Subdirectory for audio files: UploadDir/audio
+	CoverUploadDir            string // Subdirectory for cover art: UploadDir/covers
+	// 重复上传检测：为 true 时拒绝上传与已有曲目指纹相同的文件，否则仅提示
+	BlockDuplicateUploads bool
+	// 每个用户上传源文件的存储配额（MB），<=0 表示不限制
+	UserStorageQuotaMB int
+	// 上传校验：按文件头识别真实格式，并用 ffprobe 检查结构和截断
+	UploadDeepValidation      bool
+	UploadProbeTimeoutSeconds int // 单个文件 ffprobe 检查的超时时间（秒），超时时跳过检查
 	// Redis配置
 	RedisHost     string
 	RedisPort     string
@@ -40,14 +56,208 @@ type Config struct {
 	MinioUseSSL    bool
 	MinioAPI       string // S3 API 版本
 	MinioPath      string // 路径样式
+	// 按对象类型拆分的存储桶，未单独配置时使用 MinioBucket
+	MinioAudioBucket  BucketConfig // 源音频与待确认的直传文件（audio/、uploads/）
+	MinioCoverBucket  BucketConfig // 封面（covers/）
+	MinioStreamBucket BucketConfig // HLS 播放列表、分片与波形（streams/）
+	// 存储后端：minio（默认）或 local（本地磁盘，无需部署 MinIO）
+	StorageBackend    string
+	StorageLocalDir   string // local 后端的存储根目录
+	StorageSigningKey string // local 后端预签名地址的签名密钥，为空时每次启动随机生成
+	// HLS 播放列表与分片的内存缓存上限（MB），0 表示不使用内存缓存
+	StreamCacheMaxMB int
+	// 是否使用 Redis 作为内存缓存之后的第二级分片缓存
+	StreamCacheRedis bool
+	// 服务端签名密钥：派生 HLS 分片密钥、签名播放地址和退订链接，HS256 模式下也用于签发 JWT；未配置时拒绝启动
+	AppSecret string
+	// 转码时使用 AES-128 加密 HLS 分片，密钥通过 /api/streams/{id}/key 只下发给可以播放该流的用户
+	HLSEncryption bool
+	// 是否要求 /streams/ 请求携带签名参数，开启后未签名或已过期的地址返回 403
+	StreamSignedURLs bool
+	// 签名播放地址的有效期（分钟）
+	StreamURLTTLMinutes int
+	// 转码并发和资源限制，所有 FFmpeg 转码任务共享
+	TranscodeConcurrency int    // 同时运行的转码任务数，0 表示 CPU 核数
+	TranscodeThreads     int    // 每个转码任务的 FFmpeg 线程数，0 表示自动
+	TranscodeNice        int    // 转码进程的 nice 值（1-19），0 表示不调整
+	TranscodeIONiceClass string // 转码进程的 ionice 类别：best-effort 或 idle，为空表示不调整
+	TranscodeCPUSet      string // 转码进程绑定的 CPU 列表（taskset 格式，如 "2-5"），为空表示不绑定
+	// 上传曲目的转码方式：local 在 API 服务进程内转码，worker 写入 Redis 队列由 worker 命令启动的转码进程处理
+	TranscodeDispatch string
+	// 转码 worker 同时处理的任务数，0 表示与 TranscodeConcurrency 一致
+	TranscodeWorkerJobs int
+	// 启动时要求的 FFmpeg 最低版本（主版本.次版本），为空表示不检查版本
+	FFmpegMinVersion string
+	// HTTP 服务：连接超时（秒）、请求头大小上限（KB），ReadTimeout 包含上传的请求体，WriteTimeout 需覆盖大文件下载
+	HTTPReadTimeoutSeconds       int
+	HTTPReadHeaderTimeoutSeconds int
+	HTTPWriteTimeoutSeconds      int
+	HTTPIdleTimeoutSeconds       int
+	HTTPMaxHeaderKB              int
+	// 是否接受明文 HTTP/2（h2c，需客户端预先知道），用于反向代理以 HTTP/2 转发到本服务
+	HTTPUnencryptedHTTP2 bool
+	// 响应压缩：对 JSON、播放列表等文本响应按 Accept-Encoding 使用 gzip 压缩，小于 MinBytes 的响应不压缩；音频和 HLS 分片始终不压缩
+	HTTPCompressionEnabled  bool
+	HTTPCompressionMinBytes int
+	// CORS：允许的来源（逗号分隔的 scheme://host[:port]，* 表示任意来源）、是否允许携带凭据、预检缓存时间（秒）
+	CORSAllowedOrigins   []string
+	CORSAllowCredentials bool
+	CORSMaxAgeSeconds    int
+	// WebSocket 允许的来源（逗号分隔的 scheme://host[:port]，* 表示任意来源），同源和 PublicBaseURL 始终允许
+	WSAllowedOrigins []string
+	// 是否兼容旧客户端通过 ?token= 传递 WebSocket Token，Token 会出现在 URL 和日志中
+	WSAllowQueryToken bool
+	// 投屏：是否启用局域网渲染器发现（SSDP）与 DLNA 控制
+	CastEnabled bool
+	// 投屏地址的根地址，需为渲染器可访问的局域网地址，为空时使用 PublicBaseURL
+	CastBaseURL string
+	// 每次搜索渲染器的等待时间（秒）
+	CastDiscoveryTimeoutSeconds int
+	// 是否启用 /rest/ 下的 Subsonic 兼容接口，供 DSub、Symfonium 等客户端使用
+	SubsonicEnabled bool
+	// 听歌记录同步：Last.fm 应用的 API key/secret，用户未提供自己的 key 时使用
+	LastFMAPIKey    string
+	LastFMAPISecret string
+	// ListenBrainz API 地址，可指向自建实例
+	ListenBrainzAPIURL string
+	// MusicBrainz API 地址，用于补全专辑元数据，可指向镜像
+	MusicBrainzAPIURL string
 	// 网易云音乐API配置
 	NeteaseAPIURL string `env:"NETEASE_API_URL" default:"http://localhost:3000"`
+	// 网易云API备用地址，主地址失败或熔断时按顺序切换
+	NeteaseFallbackURLs []string
+	// 网易云API请求的重试和熔断策略
+	NeteaseMaxRetries             int // 每个地址的最大重试次数
+	NeteaseRetryBackoffMs         int // 首次重试前的等待毫秒数，之后每次翻倍
+	NeteaseBreakerThreshold       int // 连续失败多少次后熔断该地址，0 表示不熔断
+	NeteaseBreakerCooldownSeconds int // 熔断持续秒数
+	// 邮件配置（SMTPHost 为空时不发送邮件）
+	MailSender   string // 邮件发送器：smtp（默认）或 log（只写日志，用于开发）
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// 对外访问地址，用于邮件中的链接
+	PublicBaseURL string
+	// 每日摘要邮件发送时间（服务器本地时间的小时，0-23）
+	DigestHour int
+	// 找回密码邮件中重置令牌的有效期（分钟），令牌只能使用一次
+	PasswordResetTokenTTLMinutes int
+	// 注册后是否需要验证邮箱（未配置邮件发送器时不生效），验证链接有效期（小时）
+	EmailVerificationRequired      bool
+	EmailVerificationTokenTTLHours int
+	UnverifiedRestriction          string // 未验证账号的限制：upload（默认，禁止上传）或 login（禁止登录）
+	// 管理员用户ID列表（逗号分隔），可访问 /api/admin 接口；用户名可以修改和抢注，不用于判断管理员
+	AdminUserIDs []int64
+	// 存储垃圾回收间隔（小时），0 表示不自动执行
+	StorageGCIntervalHours int
+	// 存储对账间隔（小时）：检查曲目的源音频和流是否仍在存储中，0 表示不自动执行
+	StorageReconcileIntervalHours int
+	// 曲目完整性校验间隔（小时）：检查播放列表能否解析、分片是否齐全、时长是否一致，0 表示不定期执行
+	TrackVerifyIntervalHours int
+	// 曲库歌曲 HLS 流的分片时长（秒），与 AudioBitrate 一起决定流ID；修改后可通过 /api/admin/reprocess 重新生成旧曲目的流
+	TrackSegmentTime string
+	// 替换音频后旧版本的保留天数，期间可以恢复，到期后由回收站清理任务释放存储
+	TrackVersionRetentionDays int
+	// 回收站：删除的曲目和专辑保留天数，到期后由定期任务彻底删除并释放存储
+	TrashRetentionDays      int
+	TrashPurgeIntervalHours int // 0 表示不自动清理
+	// 监视目录：放入目录的音频自动导入到指定用户的曲库，WatchFolderDir 为空表示不启用
+	WatchFolderDir           string
+	WatchFolderUser          string // 导入到的用户名
+	WatchFolderAlbumID       int64  // 导入后加入的专辑，0 表示不加入专辑
+	WatchFolderArchiveDir    string // 导入后源文件移动到的目录，为空表示删除源文件
+	WatchFolderSettleSeconds int    // 文件多久没有变化视为写入完成
+	// 备份：数据库转储、对象存储清单和配置文件打包为 tar.gz，保存在 BackupDir
+	BackupDir           string
+	BackupIntervalHours int      // 0 表示不自动备份
+	BackupKeep          int      // 保留最近多少个备份，0 表示全部保留
+	BackupConfigFiles   []string // 一并打包的配置文件
+	// 推荐：根据所有用户的网易云播放历史计算相似歌曲，定期为每个用户预计算推荐列表
+	RecommendIntervalHours int // 刷新间隔（小时），0 表示不计算推荐
+	RecommendWindowDays    int // 只统计最近多少天的播放
+	RecommendMinUsers      int // 两首歌至少被多少位用户都听过才视为相关，也是进入热门列表的最少听众数
+	// 每日推荐歌单：每晚根据用户的收听历史和网易云相似歌手为每位用户生成 2～3 个 Daily Mix
+	DailyMixHour       int // 每天生成的时间（0-23 点），-1 表示不生成
+	DailyMixWindowDays int // 只为最近多少天内有收听记录的用户生成
+	// 每日收听目标：每小时把播放历史汇总为每天的收听时长，连续达标天数达到里程碑时发送通知
+	StreakDailyGoalMinutes int // 默认的每日收听目标（分钟），用户可在偏好设置中修改
+	// 播放次数：当天的播放计数保存在 Redis，每天定时汇总到数据库
+	PlayCountRollupHour int // 每天汇总前一天播放次数的时间（0-23 点）
+	TrendingDays        int // 热门歌曲默认统计最近多少天的播放
+	// 流量统计：记录每位用户每天从 /static/ 和 /streams/ 下载的字节数，当天的流量保存在 Redis，定时汇总到数据库
+	BandwidthDailySoftCapMB int // 每位用户每天的流量软上限（MB），超出后只在响应头中提示，不拒绝请求，0 表示不限制
+	// 通知：是否通过设备通道（/ws/devices）向在线用户实时推送新通知
+	NotificationPushEnabled bool
+	// 公告：检查定时发布和过期的间隔（秒）
+	AnnouncementScheduleIntervalSeconds int
+	// 限流配置（Redis 令牌桶），规则格式为 "次数/时间单位"，如 10/min，0 表示不限流
+	RateLimitEnabled       bool
+	RateLimitAuth          RateLimitRule // 登录、注册，按 IP
+	RateLimitSearch        RateLimitRule // 网易云搜索，按用户或 IP
+	RateLimitUpload        RateLimitRule // 上传，按用户
+	RateLimitChat          RateLimitRule // AI 聊天消息，按用户
+	RateLimitPasswordReset RateLimitRule // 找回与重置密码，按 IP；找回密码另按邮箱地址限制
+	RateLimitVerification  RateLimitRule // 重发验证邮件，按 IP，另按邮箱地址限制
+	RateLimitTrustProxy    bool          // 是否从 X-Forwarded-For / X-Real-IP 读取客户端 IP
+	// 受信任的反向代理地址，只有直连地址属于其中时才读取代理请求头，X-Forwarded-For 从右往左跳过其中的地址
+	TrustedProxies []*net.IPNet
+	// 人机验证：注册时必须通过；同一 IP 登录失败达到次数后，之后的登录也需要通过
+	CaptchaProvider      string // none（默认）、hcaptcha、turnstile 或 pow（工作量证明，不依赖第三方服务）
+	CaptchaSiteKey       string // hCaptcha / Turnstile 的站点密钥，返回给前端渲染验证组件
+	CaptchaSecret        string // hCaptcha / Turnstile 的服务端密钥
+	CaptchaPowDifficulty int    // 工作量证明的难度（前导零比特数），每增加 1 客户端计算量翻倍
+	CaptchaLoginFailures int    // 同一 IP 登录失败多少次后需要验证，0 表示每次登录都需要，-1 表示登录不需要
+	// 登录失败限制：按账号和 IP 统计失败次数，超过免费次数后指数退避，账号失败过多时临时锁定并通知用户
+	LoginThrottleEnabled      bool
+	LoginFreeAttempts         int // 每个账号退避前允许连续失败的次数
+	LoginIPFreeAttempts       int // 每个 IP 退避前允许连续失败的次数（可能多人共用出口 IP，应大于账号的次数）
+	LoginBackoffMaxSeconds    int // 退避等待的上限（秒），从 1 秒开始每次失败翻倍
+	LoginLockoutThreshold     int // 账号在窗口内失败多少次后锁定，0 表示不锁定
+	LoginLockoutMinutes       int // 账号锁定时长（分钟）
+	LoginFailureWindowMinutes int // 失败次数的统计窗口（分钟），从第一次失败开始计算
+	// JWT 签名：RS256/EdDSA 密钥保存在数据库中按计划轮换，公钥通过 /.well-known/jwks.json 发布
+	JWTSigningAlgorithm   string // EdDSA（默认）、RS256，或 HS256（使用 APP_SECRET，不轮换）
+	JWTKeyRotationHours   int    // 每把密钥用于签名的时长（小时）
+	JWTKeyOverlapMinutes  int    // 新密钥启用前先在 JWKS 中发布的时长（分钟），供其他服务提前缓存
+	JWTAcceptLegacyTokens bool   // 首次启用非对称密钥后的 7 天内是否仍接受之前签发的 HS256 Token，过渡期后总是拒绝
+	// 安全响应头：所有响应带 X-Content-Type-Options: nosniff，HTML 响应另带 Content-Security-Policy
+	SecurityHeadersEnabled bool
+	ContentSecurityPolicy  string // HTML 响应的 Content-Security-Policy，为空时不设置
+	// 幂等请求：带 Idempotency-Key 的上传和播放列表请求的响应保存时长（小时），0 表示不启用
+	IdempotencyTTLHours int
+	// 内部 gRPC 服务（proto/internalrpc/v1），供拆分出的服务查询曲目、流状态和房间状态；地址为空表示不启用
+	InternalGRPCAddr  string // 监听地址，如 127.0.0.1:9090，只应监听内网地址
+	InternalGRPCToken string // 调用方须在 authorization 元数据中携带 Bearer <token>，为空表示不校验
 	// AI Agent 配置
+	AgentProvider    string // openai（含 OpenAI 兼容接口）、anthropic、gemini、ollama
 	AgentAPIBaseURL  string
 	AgentAPIKey      string
 	AgentModel       string
 	AgentMaxTokens   int
 	AgentTemperature float64
+	// 发送给模型的历史消息 token 预算，超出部分在后台合并为会话摘要
+	AgentContextTokens int
+	// 备用模型，主模型请求失败时自动切换；AgentFallbackProvider 为空表示不启用
+	AgentFallbackProvider   string
+	AgentFallbackAPIBaseURL string
+	AgentFallbackAPIKey     string
+	AgentFallbackModel      string
+	// AI 聊天语音消息的语音识别；STTProvider 为空表示不启用
+	STTProvider      string // openai（Whisper API 及兼容接口）、whispercpp（whisper.cpp server）
+	STTAPIBaseURL    string
+	STTAPIKey        string
+	STTModel         string
+	STTLanguage      string // ISO-639-1 语言代码，为空时自动检测
+	STTMaxAudioBytes int    // 单条语音的最大字节数
+	// AI 回复的语音合成；TTSProvider 为空表示不启用
+	TTSProvider   string // openai（OpenAI TTS 及兼容接口）、azure（Azure 语音服务）
+	TTSAPIBaseURL string // azure 为 https://<region>.tts.speech.microsoft.com
+	TTSAPIKey     string
+	TTSModel      string
+	TTSVoice      string
+	TTSMaxChars   int // 单条回复合成的最大字数，超出部分截断到句末
 }
 
 // getEnv gets an environment variable or returns a default value.
@@ -78,6 +288,65 @@ func getEnvFloat(key string, fallback float64) float64 {
 	return fallback
 }
 
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvInt64List 读取逗号分隔的整数列表，无法解析的项忽略
+func getEnvInt64List(key string) []int64 {
+	var values []int64
+	for _, item := range splitList(os.Getenv(key)) {
+		value, err := strconv.ParseInt(item, 10, 64)
+		if err != nil || value <= 0 {
+			log.Printf("Invalid %s item %q, ignored", key, item)
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// defaultTrustedProxies 默认信任的反向代理地址：本机和内网
+const defaultTrustedProxies = "127.0.0.0/8,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,::1/128,fc00::/7"
+
+// getEnvCIDRList 读取逗号分隔的 CIDR 列表，单个 IP 按只含该地址的网段处理，无法解析的项忽略
+func getEnvCIDRList(key, fallback string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, item := range splitList(getEnv(key, fallback)) {
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			log.Printf("Invalid %s item %q, ignored", key, item)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// BucketConfig 单个存储桶的配置，Endpoint、Region 为空时沿用全局 MinIO 配置
+type BucketConfig struct {
+	Name     string
+	Endpoint string
+	Region   string
+}
+
 // Load loads configuration from environment variables (via .env file) or defaults.
 func Load() *Config {
 	// Attempt to load .env file. godotenv.Load() will not override existing env vars.
@@ -86,12 +355,23 @@ func Load() *Config {
 		log.Println("No .env file found or error loading .env, relying on existing environment variables and defaults.")
 	}
 
+	if os.Getenv("ADMIN_USERNAMES") != "" {
+		log.Println("ADMIN_USERNAMES is no longer supported, use ADMIN_USER_IDS instead")
+	}
+
 	ffmpegPath := getEnv("FFMPEG_PATH", "ffmpeg")
+	minioBucket := getEnv("MINIO_BUCKET", "")
 	uploadBase := "uploads"
 	staticBase := "static"
+	agentProvider := getEnv("AGENT_PROVIDER", "openai")
+	agentFallbackProvider := getEnv("AGENT_FALLBACK_PROVIDER", "")
+	sttProvider := getEnv("STT_PROVIDER", "")
+	ttsProvider := getEnv("TTS_PROVIDER", "")
 
 	return &Config{
 		FFmpegPath:     ffmpegPath,
+		FFprobePath:    getEnv("FFPROBE_PATH", ""),
+		FpcalcPath:     getEnv("FPCALC_PATH", "fpcalc"),
 		AudioBitrate:   getEnv("AUDIO_BITRATE", "192k"),
 		HLSSegmentTime: getEnv("HLS_SEGMENT_TIME", "10"),
 		SourceAudioDir: filepath.Join(uploadBase, "audio"), // Will be created if not exists
@@ -102,9 +382,19 @@ func Load() *Config {
 		DBUser:         getEnv("DB_USER", "root"),
 		DBPassword:     os.Getenv("DB_PASSWORD"), // For password, better not to have a hardcoded default
 		DBName:         getEnv("DB_NAME", "fm"),
-		UploadDir:      uploadBase,
-		AudioUploadDir: filepath.Join(uploadBase, "audio"),
-		CoverUploadDir: filepath.Join(uploadBase, "covers"),
+		// 数据库查询超时
+		DBQueryTimeoutSeconds:     getEnvInt("DB_QUERY_TIMEOUT_SECONDS", 5),
+		DBListQueryTimeoutSeconds: getEnvInt("DB_LIST_QUERY_TIMEOUT_SECONDS", 30),
+		UploadDir:                 uploadBase,
+		AudioUploadDir:            filepath.Join(uploadBase, "audio"),
+		CoverUploadDir:            filepath.Join(uploadBase, "covers"),
+		// 重复上传检测
+		BlockDuplicateUploads: getEnv("BLOCK_DUPLICATE_UPLOADS", "false") == "true",
+		// 用户存储配额
+		UserStorageQuotaMB: getEnvInt("USER_STORAGE_QUOTA_MB", 0),
+		// 上传校验
+		UploadDeepValidation:      getEnv("UPLOAD_DEEP_VALIDATION", "true") == "true",
+		UploadProbeTimeoutSeconds: getEnvInt("UPLOAD_PROBE_TIMEOUT_SECONDS", 15),
 		// Redis配置，使用默认值
 		RedisHost:     getEnv("REDIS_HOST", "127.0.0.1"),
 		RedisPort:     getEnv("REDIS_PORT", "6379"),
@@ -114,18 +404,301 @@ func Load() *Config {
 		MinioEndpoint:  getEnv("MINIO_ENDPOINT", ""),
 		MinioAccessKey: getEnv("MINIO_ACCESS_KEY", ""),
 		MinioSecretKey: getEnv("MINIO_SECRET_KEY", ""),
-		MinioBucket:    getEnv("MINIO_BUCKET", ""),
+		MinioBucket:    minioBucket,
 		MinioRegion:    getEnv("MINIO_REGION", ""),
 		MinioUseSSL:    getEnv("MINIO_USE_SSL", "true") == "true",
 		MinioAPI:       getEnv("MINIO_API", "s3v4"),
 		MinioPath:      getEnv("MINIO_PATH", "auto"),
+		// 按对象类型拆分的存储桶
+		MinioAudioBucket:  loadBucketConfig("AUDIO", minioBucket),
+		MinioCoverBucket:  loadBucketConfig("COVER", minioBucket),
+		MinioStreamBucket: loadBucketConfig("STREAM", minioBucket),
+		// 存储后端配置
+		StorageBackend:    getEnv("STORAGE_BACKEND", "minio"),
+		StorageLocalDir:   getEnv("STORAGE_LOCAL_DIR", "data/storage"),
+		StorageSigningKey: getEnv("STORAGE_SIGNING_KEY", ""),
+		// 流缓存配置
+		StreamCacheMaxMB: getEnvInt("STREAM_CACHE_MAX_MB", 256),
+		StreamCacheRedis: getEnv("STREAM_CACHE_REDIS", "true") == "true",
+		// HLS 加密与防盗链
+		AppSecret:           getEnv("APP_SECRET", ""),
+		HLSEncryption:       getEnv("HLS_ENCRYPTION", "false") == "true",
+		StreamSignedURLs:    getEnv("STREAM_SIGNED_URLS", "false") == "true",
+		StreamURLTTLMinutes: getEnvInt("STREAM_URL_TTL_MINUTES", 360),
+		// 转码并发和资源限制
+		TranscodeConcurrency: getEnvInt("TRANSCODE_CONCURRENCY", 0),
+		TranscodeThreads:     getEnvInt("TRANSCODE_THREADS", 0),
+		TranscodeNice:        getEnvInt("TRANSCODE_NICE", 0),
+		TranscodeIONiceClass: getEnv("TRANSCODE_IONICE_CLASS", ""),
+		TranscodeCPUSet:      getEnv("TRANSCODE_CPUSET", ""),
+		// 转码任务分发
+		TranscodeDispatch:   getEnv("TRANSCODE_DISPATCH", "local"),
+		TranscodeWorkerJobs: getEnvInt("TRANSCODE_WORKER_JOBS", 0),
+		// FFmpeg 启动检查
+		FFmpegMinVersion: getEnv("FFMPEG_MIN_VERSION", "4.0"),
+		// HTTP 服务
+		HTTPReadTimeoutSeconds:       getEnvInt("HTTP_READ_TIMEOUT_SECONDS", 300),
+		HTTPReadHeaderTimeoutSeconds: getEnvInt("HTTP_READ_HEADER_TIMEOUT_SECONDS", 10),
+		HTTPWriteTimeoutSeconds:      getEnvInt("HTTP_WRITE_TIMEOUT_SECONDS", 300),
+		HTTPIdleTimeoutSeconds:       getEnvInt("HTTP_IDLE_TIMEOUT_SECONDS", 1200),
+		HTTPMaxHeaderKB:              getEnvInt("HTTP_MAX_HEADER_KB", 1024),
+		HTTPUnencryptedHTTP2:         getEnv("HTTP_UNENCRYPTED_HTTP2", "false") == "true",
+		HTTPCompressionEnabled:       getEnv("HTTP_COMPRESSION_ENABLED", "true") == "true",
+		HTTPCompressionMinBytes:      getEnvInt("HTTP_COMPRESSION_MIN_BYTES", 1024),
+		// CORS
+		CORSAllowedOrigins:   splitList(getEnv("CORS_ALLOWED_ORIGINS", "*")),
+		CORSAllowCredentials: getEnv("CORS_ALLOW_CREDENTIALS", "false") == "true",
+		CORSMaxAgeSeconds:    getEnvInt("CORS_MAX_AGE_SECONDS", 86400),
+		// WebSocket 安全
+		WSAllowedOrigins:  splitList(getEnv("WS_ALLOWED_ORIGINS", "")),
+		WSAllowQueryToken: getEnv("WS_ALLOW_QUERY_TOKEN", "false") == "true",
+		// 投屏
+		CastEnabled:                 getEnv("CAST_ENABLED", "false") == "true",
+		CastBaseURL:                 getEnv("CAST_BASE_URL", ""),
+		CastDiscoveryTimeoutSeconds: getEnvInt("CAST_DISCOVERY_TIMEOUT_SECONDS", 3),
+		// Subsonic 兼容接口
+		SubsonicEnabled: getEnv("SUBSONIC_ENABLED", "false") == "true",
+		// 听歌记录同步
+		LastFMAPIKey:       getEnv("LASTFM_API_KEY", ""),
+		LastFMAPISecret:    getEnv("LASTFM_API_SECRET", ""),
+		ListenBrainzAPIURL: getEnv("LISTENBRAINZ_API_URL", "https://api.listenbrainz.org"),
+		// 专辑元数据补全
+		MusicBrainzAPIURL: getEnv("MUSICBRAINZ_API_URL", "https://musicbrainz.org"),
 		// 网易云音乐API配置
-		NeteaseAPIURL: getEnv("NETEASE_API_URL", "http://localhost:3000"), // 默认使用本地代理
+		NeteaseAPIURL:                 getEnv("NETEASE_API_URL", "http://localhost:3000"), // 默认使用本地代理
+		NeteaseFallbackURLs:           splitList(getEnv("NETEASE_FALLBACK_URLS", "")),
+		NeteaseMaxRetries:             getEnvInt("NETEASE_MAX_RETRIES", 2),
+		NeteaseRetryBackoffMs:         getEnvInt("NETEASE_RETRY_BACKOFF_MS", 200),
+		NeteaseBreakerThreshold:       getEnvInt("NETEASE_BREAKER_THRESHOLD", 5),
+		NeteaseBreakerCooldownSeconds: getEnvInt("NETEASE_BREAKER_COOLDOWN_SECONDS", 30),
+		// 邮件配置
+		MailSender:    getEnv("MAIL_SENDER", "smtp"),
+		SMTPHost:      getEnv("SMTP_HOST", ""),
+		SMTPPort:      getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:  getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:  getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:      getEnv("SMTP_FROM", ""),
+		PublicBaseURL: getEnv("PUBLIC_BASE_URL", "http://localhost:8080"),
+		DigestHour:    getEnvInt("DIGEST_HOUR", 8),
+		// 找回密码
+		PasswordResetTokenTTLMinutes: getEnvInt("PASSWORD_RESET_TOKEN_TTL_MINUTES", 30),
+		// 邮箱验证
+		EmailVerificationRequired:      getEnv("EMAIL_VERIFICATION_REQUIRED", "true") == "true",
+		EmailVerificationTokenTTLHours: getEnvInt("EMAIL_VERIFICATION_TOKEN_TTL_HOURS", 24),
+		UnverifiedRestriction:          getEnv("UNVERIFIED_RESTRICTION", "upload"),
+		// 管理与维护
+		AdminUserIDs:            getEnvInt64List("ADMIN_USER_IDS"),
+		StorageGCIntervalHours:  getEnvInt("STORAGE_GC_INTERVAL_HOURS", 24),
+		TrashRetentionDays:      getEnvInt("TRASH_RETENTION_DAYS", 30),
+		TrashPurgeIntervalHours: getEnvInt("TRASH_PURGE_INTERVAL_HOURS", 6),
+		RecommendIntervalHours:  getEnvInt("RECOMMEND_INTERVAL_HOURS", 6),
+		RecommendWindowDays:     getEnvInt("RECOMMEND_WINDOW_DAYS", 90),
+		RecommendMinUsers:       getEnvInt("RECOMMEND_MIN_USERS", 3),
+		// 每日推荐歌单
+		DailyMixHour:       getEnvInt("DAILY_MIX_HOUR", 4),
+		DailyMixWindowDays: getEnvInt("DAILY_MIX_WINDOW_DAYS", 30),
+		// 每日收听目标
+		StreakDailyGoalMinutes: getEnvInt("STREAK_DAILY_GOAL_MINUTES", 15),
+		// 存储对账
+		StorageReconcileIntervalHours: getEnvInt("STORAGE_RECONCILE_INTERVAL_HOURS", 24),
+		// 曲目完整性校验
+		TrackVerifyIntervalHours: getEnvInt("TRACK_VERIFY_INTERVAL_HOURS", 168),
+		// 曲库流编码
+		TrackSegmentTime: getEnv("TRACK_SEGMENT_TIME", "4"),
+		// 曲目版本
+		TrackVersionRetentionDays: getEnvInt("TRACK_VERSION_RETENTION_DAYS", 30),
+		// 监视目录
+		WatchFolderDir:           getEnv("WATCH_FOLDER_DIR", ""),
+		WatchFolderUser:          getEnv("WATCH_FOLDER_USER", ""),
+		WatchFolderAlbumID:       int64(getEnvInt("WATCH_FOLDER_ALBUM_ID", 0)),
+		WatchFolderArchiveDir:    getEnv("WATCH_FOLDER_ARCHIVE_DIR", ""),
+		WatchFolderSettleSeconds: getEnvInt("WATCH_FOLDER_SETTLE_SECONDS", 5),
+		// 备份
+		BackupDir:           getEnv("BACKUP_DIR", "backups"),
+		BackupIntervalHours: getEnvInt("BACKUP_INTERVAL_HOURS", 0),
+		BackupKeep:          getEnvInt("BACKUP_KEEP", 7),
+		BackupConfigFiles:   splitList(getEnv("BACKUP_CONFIG_FILES", ".env")),
+		// 播放次数
+		PlayCountRollupHour: getEnvInt("PLAY_COUNT_ROLLUP_HOUR", 3),
+		TrendingDays:        getEnvInt("TRENDING_DAYS", 7),
+		// 流量统计
+		BandwidthDailySoftCapMB: getEnvInt("BANDWIDTH_DAILY_SOFT_CAP_MB", 0),
+		// 通知
+		NotificationPushEnabled: getEnv("NOTIFICATION_PUSH_ENABLED", "true") == "true",
+		// 公告
+		AnnouncementScheduleIntervalSeconds: getEnvInt("ANNOUNCEMENT_SCHEDULE_INTERVAL_SECONDS", 60),
+		// 限流
+		RateLimitEnabled:       getEnv("RATE_LIMIT_ENABLED", "true") == "true",
+		RateLimitAuth:          getEnvRateLimit("RATE_LIMIT_AUTH", "10/min"),
+		RateLimitSearch:        getEnvRateLimit("RATE_LIMIT_SEARCH", "30/min"),
+		RateLimitUpload:        getEnvRateLimit("RATE_LIMIT_UPLOAD", "30/hour"),
+		RateLimitChat:          getEnvRateLimit("RATE_LIMIT_CHAT", "20/min"),
+		RateLimitPasswordReset: getEnvRateLimit("RATE_LIMIT_PASSWORD_RESET", "5/hour"),
+		RateLimitVerification:  getEnvRateLimit("RATE_LIMIT_VERIFICATION", "5/hour"),
+		RateLimitTrustProxy:    getEnv("RATE_LIMIT_TRUST_PROXY", "false") == "true",
+		TrustedProxies:         getEnvCIDRList("RATE_LIMIT_TRUSTED_PROXIES", defaultTrustedProxies),
+		// 人机验证
+		CaptchaProvider:      getEnv("CAPTCHA_PROVIDER", "none"),
+		CaptchaSiteKey:       getEnv("CAPTCHA_SITE_KEY", ""),
+		CaptchaSecret:        getEnv("CAPTCHA_SECRET", ""),
+		CaptchaPowDifficulty: getEnvInt("CAPTCHA_POW_DIFFICULTY", 18),
+		CaptchaLoginFailures: getEnvInt("CAPTCHA_LOGIN_FAILURES", 3),
+		// 登录失败限制
+		LoginThrottleEnabled:      getEnv("LOGIN_THROTTLE_ENABLED", "true") == "true",
+		LoginFreeAttempts:         getEnvInt("LOGIN_FREE_ATTEMPTS", 3),
+		LoginIPFreeAttempts:       getEnvInt("LOGIN_IP_FREE_ATTEMPTS", 10),
+		LoginBackoffMaxSeconds:    getEnvInt("LOGIN_BACKOFF_MAX_SECONDS", 300),
+		LoginLockoutThreshold:     getEnvInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LoginLockoutMinutes:       getEnvInt("LOGIN_LOCKOUT_MINUTES", 15),
+		LoginFailureWindowMinutes: getEnvInt("LOGIN_FAILURE_WINDOW_MINUTES", 60),
+		// JWT 签名
+		JWTSigningAlgorithm:   getEnv("JWT_SIGNING_ALG", "EdDSA"),
+		JWTKeyRotationHours:   getEnvInt("JWT_KEY_ROTATION_HOURS", 720),
+		JWTKeyOverlapMinutes:  getEnvInt("JWT_KEY_OVERLAP_MINUTES", 60),
+		JWTAcceptLegacyTokens: getEnv("JWT_ACCEPT_LEGACY_TOKENS", "false") == "true",
+		// 安全响应头
+		SecurityHeadersEnabled: getEnv("SECURITY_HEADERS_ENABLED", "true") == "true",
+		ContentSecurityPolicy:  getEnv("CONTENT_SECURITY_POLICY", defaultContentSecurityPolicy),
+		// 幂等请求
+		IdempotencyTTLHours: getEnvInt("IDEMPOTENCY_TTL_HOURS", 24),
+		// 内部 gRPC 服务
+		InternalGRPCAddr:  getEnv("INTERNAL_GRPC_ADDR", ""),
+		InternalGRPCToken: getEnv("INTERNAL_GRPC_TOKEN", ""),
 		// AI Agent 配置
-		AgentAPIBaseURL:  getEnv("AGENT_API_BASE_URL", "https://one-api.ygxz.in/v1"),
-		AgentAPIKey:      getEnv("AGENT_API_KEY", ""),
-		AgentModel:       getEnv("AGENT_MODEL", "gpt5"),
-		AgentMaxTokens:   getEnvInt("AGENT_MAX_TOKENS", 2000),
-		AgentTemperature: getEnvFloat("AGENT_TEMPERATURE", 0.7),
+		AgentProvider:           agentProvider,
+		AgentAPIBaseURL:         getEnv("AGENT_API_BASE_URL", defaultAgentBaseURL(agentProvider)),
+		AgentAPIKey:             getEnv("AGENT_API_KEY", ""),
+		AgentModel:              getEnv("AGENT_MODEL", "gpt5"),
+		AgentMaxTokens:          getEnvInt("AGENT_MAX_TOKENS", 2000),
+		AgentTemperature:        getEnvFloat("AGENT_TEMPERATURE", 0.7),
+		AgentContextTokens:      getEnvInt("AGENT_CONTEXT_TOKENS", 3000),
+		AgentFallbackProvider:   agentFallbackProvider,
+		AgentFallbackAPIBaseURL: getEnv("AGENT_FALLBACK_API_BASE_URL", defaultAgentBaseURL(agentFallbackProvider)),
+		AgentFallbackAPIKey:     getEnv("AGENT_FALLBACK_API_KEY", ""),
+		AgentFallbackModel:      getEnv("AGENT_FALLBACK_MODEL", ""),
+		STTProvider:             sttProvider,
+		STTAPIBaseURL:           getEnv("STT_API_BASE_URL", defaultSTTBaseURL(sttProvider)),
+		STTAPIKey:               getEnv("STT_API_KEY", ""),
+		STTModel:                getEnv("STT_MODEL", "whisper-1"),
+		STTLanguage:             getEnv("STT_LANGUAGE", ""),
+		STTMaxAudioBytes:        getEnvInt("STT_MAX_AUDIO_BYTES", 1<<20),
+		TTSProvider:             ttsProvider,
+		TTSAPIBaseURL:           getEnv("TTS_API_BASE_URL", defaultTTSBaseURL(ttsProvider)),
+		TTSAPIKey:               getEnv("TTS_API_KEY", ""),
+		TTSModel:                getEnv("TTS_MODEL", "tts-1"),
+		TTSVoice:                getEnv("TTS_VOICE", defaultTTSVoice(ttsProvider)),
+		TTSMaxChars:             getEnvInt("TTS_MAX_CHARS", 300),
+	}
+}
+
+// defaultTTSBaseURL 各语音合成服务的默认 API 地址
+func defaultTTSBaseURL(provider string) string {
+	switch provider {
+	case "azure":
+		return "https://eastasia.tts.speech.microsoft.com"
+	default:
+		return "https://api.openai.com/v1"
+	}
+}
+
+// defaultTTSVoice 各语音合成服务的默认音色
+func defaultTTSVoice(provider string) string {
+	switch provider {
+	case "azure":
+		return "zh-CN-XiaoxiaoNeural"
+	default:
+		return "alloy"
+	}
+}
+
+// defaultSTTBaseURL 各语音识别服务的默认 API 地址
+func defaultSTTBaseURL(provider string) string {
+	switch provider {
+	case "whispercpp":
+		return "http://localhost:8080"
+	default:
+		return "https://api.openai.com/v1"
+	}
+}
+
+// defaultAgentBaseURL 各模型服务的默认 API 地址
+func defaultAgentBaseURL(provider string) string {
+	switch provider {
+	case "anthropic":
+		return "https://api.anthropic.com/v1"
+	case "gemini":
+		return "https://generativelanguage.googleapis.com/v1beta"
+	case "ollama":
+		return "http://localhost:11434"
+	default:
+		return "https://one-api.ygxz.in/v1"
+	}
+}
+
+// loadBucketConfig 读取 MINIO_<kind>_BUCKET/ENDPOINT/REGION，未设置桶名时使用默认桶
+func loadBucketConfig(kind, defaultBucket string) BucketConfig {
+	return BucketConfig{
+		Name:     getEnv("MINIO_"+kind+"_BUCKET", defaultBucket),
+		Endpoint: getEnv("MINIO_"+kind+"_ENDPOINT", ""),
+		Region:   getEnv("MINIO_"+kind+"_REGION", ""),
+	}
+}
+
+// defaultContentSecurityPolicy 前端页面默认的 Content-Security-Policy：脚本只能来自本站，
+// 封面和音频可能来自网易云等外部地址，WebSocket 用于设备同步和房间
+const defaultContentSecurityPolicy = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data: blob: https: http:; media-src 'self' blob: https: http:; connect-src 'self' ws: wss: https:; " +
+	"font-src 'self' data:; object-src 'none'; base-uri 'self'; frame-ancestors 'self'"
+
+// RateLimitRule 令牌桶限流规则：Period 内最多 Limit 次，允许一次性用完 Limit 次
+type RateLimitRule struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Enabled 规则是否生效
+func (r RateLimitRule) Enabled() bool {
+	return r.Limit > 0 && r.Period > 0
+}
+
+// String 返回 "次数/时间单位" 形式的规则
+func (r RateLimitRule) String() string {
+	return fmt.Sprintf("%d/%s", r.Limit, r.Period)
+}
+
+// rateLimitUnits 限流规则支持的时间单位
+var rateLimitUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "second": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute,
+	"h": time.Hour, "hour": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour,
+}
+
+// ParseRateLimit 解析 "10/min" 形式的限流规则
+func ParseRateLimit(value string) (RateLimitRule, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), "/", 2)
+	if len(parts) != 2 {
+		return RateLimitRule{}, fmt.Errorf("invalid rate limit %q, expected <count>/<unit>", value)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || limit < 0 {
+		return RateLimitRule{}, fmt.Errorf("invalid rate limit count %q", parts[0])
+	}
+	period, ok := rateLimitUnits[strings.ToLower(strings.TrimSpace(parts[1]))]
+	if !ok {
+		return RateLimitRule{}, fmt.Errorf("invalid rate limit unit %q", parts[1])
+	}
+	return RateLimitRule{Limit: limit, Period: period}, nil
+}
+
+// getEnvRateLimit 读取限流规则，无法解析时使用默认值
+func getEnvRateLimit(key, fallback string) RateLimitRule {
+	if value, exists := os.LookupEnv(key); exists {
+		if rule, err := ParseRateLimit(value); err == nil {
+			return rule
+		}
+		log.Printf("Invalid %s=%q, using default %s", key, value, fallback)
 	}
+	rule, _ := ParseRateLimit(fallback)
+	return rule
 }
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"Bt1QFM/model"
+)
+
+const (
+	// DefaultContextTokens 未配置时历史消息的 token 预算
+	DefaultContextTokens = 3000
+	// messageTokenOverhead 每条消息的角色、分隔符等额外 token
+	messageTokenOverhead = 4
+	// maxSummaryRunes 会话摘要的最大长度（字符），防止摘要本身不断膨胀
+	maxSummaryRunes = 800
+)
+
+// summarySystemPrompt 合并早期对话的系统提示词
+const summarySystemPrompt = `你负责为音乐电台AI助手压缩对话记录。把已有摘要和新的对话合并为一段新的摘要，供助手在后续对话中参考。
+要求：
+1. 保留用户的音乐偏好、提到或推荐过的歌曲和歌手、用户明确的要求和反馈
+2. 省略寒暄和重复内容，不要编造
+3. 用第三人称陈述，不超过300字，只输出摘要本身`
+
+// ChatContext 发送给模型的对话上下文：较早对话的摘要和预算内的最近消息
+type ChatContext struct {
+	Summary string
+	History []*model.ChatMessage
+	// Language 用户的语言，决定使用的系统提示词，为空时使用中文
+	Language string
+}
+
+// EstimateTokens 粗略估算文本的 token 数：中日韩字符约 1 token/字，其他字符约 4 字符/token
+// 不同模型的分词器不同，这里只用于控制上下文长度，宁可偏大
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r), unicode.Is(unicode.Hiragana, r),
+			unicode.Is(unicode.Katakana, r), unicode.Is(unicode.Hangul, r):
+			cjk++
+		default:
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// messageTokens 一条历史消息占用的 token
+func messageTokens(msg *model.ChatMessage) int {
+	return EstimateTokens(msg.Content) + messageTokenOverhead
+}
+
+// contextTokens 历史消息的 token 预算
+func (a *MusicAgent) contextTokens() int {
+	if a.config.ContextTokens > 0 {
+		return a.config.ContextTokens
+	}
+	return DefaultContextTokens
+}
+
+// SplitHistory 按 token 预算切分尚未摘要的历史消息（按时间正序）
+// window 是本次发送给模型的最近消息；超出预算时，最近一半预算以外的消息作为 stale 返回，
+// 由调用方合并进会话摘要，这样不必每轮对话都重新生成摘要
+func (a *MusicAgent) SplitHistory(history []*model.ChatMessage) (window, stale []*model.ChatMessage) {
+	budget := a.contextTokens()
+	keep := budget / 2
+
+	total := 0
+	windowStart, keepStart := len(history), len(history)
+	for i := len(history) - 1; i >= 0; i-- {
+		total += messageTokens(history[i])
+		if total <= budget {
+			windowStart = i
+		}
+		if total <= keep {
+			keepStart = i
+		}
+	}
+	if total <= budget {
+		return history, nil
+	}
+
+	// 窗口不能以助手消息开头，部分模型服务（如 Anthropic）要求首条消息来自用户
+	for windowStart < len(history) && history[windowStart].Role != "user" {
+		windowStart++
+	}
+	for keepStart < len(history) && history[keepStart].Role != "user" {
+		keepStart++
+	}
+	return history[windowStart:], history[:keepStart]
+}
+
+// Summarize 把较早的对话合并进已有摘要，返回新的摘要
+func (a *MusicAgent) Summarize(ctx context.Context, previous string, messages []*model.ChatMessage) (string, error) {
+	var b strings.Builder
+	if previous != "" {
+		b.WriteString("已有摘要：\n")
+		b.WriteString(previous)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("新的对话：\n")
+	for _, msg := range messages {
+		role := "用户"
+		if msg.Role == "assistant" {
+			role = "助手"
+		}
+		fmt.Fprintf(&b, "%s：%s\n", role, msg.Content)
+	}
+
+	reply, err := a.complete(ctx, []model.OpenAIChatMessage{
+		{Role: "system", Content: summarySystemPrompt},
+		{Role: "user", Content: b.String()},
+	})
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(reply)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary in response")
+	}
+	if runes := []rune(summary); len(runes) > maxSummaryRunes {
+		summary = string(runes[:maxSummaryRunes])
+	}
+	return summary, nil
+}
+
+// summaryMessage 把会话摘要作为系统消息放在历史消息之前
+func summaryMessage(summary string) model.OpenAIChatMessage {
+	return model.OpenAIChatMessage{
+		Role:    "system",
+		Content: "以下是与该用户较早对话的摘要，供参考：\n" + summary,
+	}
+}
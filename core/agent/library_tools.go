@@ -0,0 +1,324 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/plugin"
+	"Bt1QFM/i18n"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+// Library tool names.
+const (
+	ToolListLibrary  = "list_library"
+	ToolGetFavorites = "get_favorites"
+	ToolGetQueue     = "get_queue"
+)
+
+const (
+	// defaultLibraryLimit、maxLibraryLimit list_library / get_favorites 返回给模型的结果数
+	defaultLibraryLimit = 20
+	maxLibraryLimit     = 50
+	// maxQueueItems get_queue 最多返回当前歌曲之后的几首
+	maxQueueItems = 30
+	// libraryStatsLimit 读取曲库播放统计的上限，用于标注每首歌的最近播放时间
+	libraryStatsLimit = 2000
+)
+
+// libraryToolPrompt 可以读取用户曲库时追加到工具调用提示词中的规则
+const libraryToolPrompt = `
+5. 用户提到"我的曲库""我上传的歌"时，用 list_library 查看用户自己的曲目，加入播放列表时 queue_song 的 source 传 "local"
+6. 用户提到"我喜欢的""常听的"时，用 get_favorites 查看收听最多的歌曲
+7. 用户问到正在播放或接下来播放什么时，用 get_queue 查看当前播放列表`
+
+// libraryTools 读取用户曲库、常听歌曲和播放列表的工具定义
+var libraryTools = []model.OpenAITool{
+	newTool(ToolListLibrary, "列出用户自己上传的曲目，附带播放次数和最近播放时间", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query":           map[string]interface{}{"type": "string", "description": "按歌名、歌手、专辑或流派筛选，可选"},
+			"not_played_days": map[string]interface{}{"type": "integer", "description": "只返回最近这么多天内没有播放过的曲目，可选"},
+			"limit":           map[string]interface{}{"type": "integer", "description": "返回结果数，默认 20，最多 50"},
+		},
+	}),
+	newTool(ToolGetFavorites, "列出用户收听次数最多的歌曲（包括曲库和网易云）", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"limit": map[string]interface{}{"type": "integer", "description": "返回结果数，默认 20，最多 50"},
+		},
+	}),
+	newTool(ToolGetQueue, "查看用户当前的播放列表、正在播放的歌曲和接下来要播放的歌曲", map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}),
+}
+
+// SetLibrary 注入读取用户曲库和播放历史的仓库，注入后模型可以调用曲库相关工具
+func (a *MusicAgent) SetLibrary(trackRepo repository.TrackRepository, historyRepo repository.PlayHistoryRepository) {
+	a.trackRepo = trackRepo
+	a.historyRepo = historyRepo
+}
+
+// hasLibrary 是否可以读取用户曲库
+func (a *MusicAgent) hasLibrary() bool {
+	return a.trackRepo != nil && a.historyRepo != nil
+}
+
+// availableTools 提供给模型的全部工具
+func (a *MusicAgent) availableTools() []model.OpenAITool {
+	if !a.hasLibrary() {
+		return musicTools
+	}
+	tools := make([]model.OpenAITool, 0, len(musicTools)+len(libraryTools))
+	return append(append(tools, musicTools...), libraryTools...)
+}
+
+// toolPrompt 工具调用模式下的系统提示词，按语言选择中文或英文版本
+func (a *MusicAgent) toolPrompt(lang string) string {
+	prompt, library := MusicAgentToolPrompt, libraryToolPrompt
+	if lang == i18n.En {
+		prompt, library = MusicAgentToolPromptEn, libraryToolPromptEn
+	}
+	if !a.hasLibrary() {
+		return prompt
+	}
+	return prompt + library
+}
+
+// executeLibraryTool 执行曲库相关的工具调用，ok 为 false 表示不是曲库工具
+func (a *MusicAgent) executeLibraryTool(ctx context.Context, tc ToolContext, call model.OpenAIToolCall) (result ToolResult, output string, ok bool) {
+	result = ToolResult{Name: call.Function.Name}
+	if !a.hasLibrary() {
+		return result, "", false
+	}
+
+	switch call.Function.Name {
+	case ToolListLibrary:
+		var args struct {
+			Query         string `json:"query"`
+			NotPlayedDays int    `json:"not_played_days"`
+			Limit         int    `json:"limit"`
+		}
+		if err := parseToolArgs(call, &args); err != nil {
+			result.Err = err
+			return result, toolError(err), true
+		}
+		tracks, err := a.listLibrary(ctx, tc.UserID, args.Query, args.NotPlayedDays, libraryLimit(args.Limit))
+		if err != nil {
+			result.Err = err
+			return result, toolError(fmt.Errorf("failed to read library")), true
+		}
+		return result, toolOutput(map[string]interface{}{"tracks": tracks}), true
+
+	case ToolGetFavorites:
+		var args struct {
+			Limit int `json:"limit"`
+		}
+		if err := parseToolArgs(call, &args); err != nil {
+			result.Err = err
+			return result, toolError(err), true
+		}
+		stats, err := a.historyRepo.GetPlayStats(ctx, tc.UserID, "", libraryLimit(args.Limit))
+		if err != nil {
+			result.Err = err
+			return result, toolError(fmt.Errorf("failed to read play history")), true
+		}
+		favorites := make([]map[string]interface{}, 0, len(stats))
+		for _, s := range stats {
+			favorites = append(favorites, map[string]interface{}{
+				"id":             s.SourceID,
+				"source":         s.Source,
+				"name":           s.Title,
+				"artist":         s.Artist,
+				"plays":          s.Plays,
+				"completed":      s.Completed,
+				"last_played_at": s.LastPlayedAt.Format(time.DateOnly),
+			})
+		}
+		return result, toolOutput(map[string]interface{}{"favorites": favorites}), true
+
+	case ToolGetQueue:
+		queue, err := queueSummary(ctx, tc.UserID)
+		if err != nil {
+			result.Err = err
+			return result, toolError(fmt.Errorf("failed to read queue")), true
+		}
+		return result, toolOutput(queue), true
+	}
+	return result, "", false
+}
+
+// listLibrary 列出用户已处理完成的曲目，附带收听统计，从未播放和最久未播放的排在前面
+func (a *MusicAgent) listLibrary(ctx context.Context, userID int64, query string, notPlayedDays, limit int) ([]map[string]interface{}, error) {
+	tracks, err := a.trackRepo.GetAllTracksByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := a.historyRepo.GetPlayStats(ctx, userID, cache.SourceLocal, libraryStatsLimit)
+	if err != nil {
+		return nil, err
+	}
+	statByID := make(map[string]*model.PlayStat, len(stats))
+	for _, s := range stats {
+		statByID[s.SourceID] = s
+	}
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	cutoff := time.Now().AddDate(0, 0, -notPlayedDays)
+	type entry struct {
+		track *model.Track
+		stat  *model.PlayStat
+	}
+	var matched []entry
+	for _, t := range tracks {
+		if t.Status != "completed" || t.DeletedAt != nil {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(t.Title+" "+t.Artist+" "+t.Album+" "+t.Genre), query) {
+			continue
+		}
+		stat := statByID[strconv.FormatInt(t.ID, 10)]
+		if notPlayedDays > 0 && stat != nil && stat.LastPlayedAt.After(cutoff) {
+			continue
+		}
+		matched = append(matched, entry{track: t, stat: stat})
+	}
+
+	// 从未播放的在前，其余按最近播放时间从早到晚
+	sort.SliceStable(matched, func(i, j int) bool {
+		x, y := matched[i], matched[j]
+		if x.stat == nil || y.stat == nil {
+			return x.stat == nil && y.stat != nil
+		}
+		return x.stat.LastPlayedAt.Before(y.stat.LastPlayedAt)
+	})
+
+	out := make([]map[string]interface{}, 0, min(len(matched), limit))
+	for _, e := range matched[:min(len(matched), limit)] {
+		item := map[string]interface{}{
+			"id":     strconv.FormatInt(e.track.ID, 10),
+			"name":   e.track.Title,
+			"artist": e.track.Artist,
+			"album":  e.track.Album,
+			"genre":  e.track.Genre,
+			"plays":  0,
+		}
+		if e.stat != nil {
+			item["plays"] = e.stat.Plays
+			item["last_played_at"] = e.stat.LastPlayedAt.Format(time.DateOnly)
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// queueSummary 当前播放的歌曲和接下来的歌曲
+func queueSummary(ctx context.Context, userID int64) (map[string]interface{}, error) {
+	playlist, err := cache.GetPlaylist(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	state, err := cache.GetUserPlaybackState(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := -1
+	playing := false
+	if state != nil && state.CurrentIndex >= 0 && state.CurrentIndex < len(playlist) {
+		current, playing = state.CurrentIndex, state.IsPlaying
+	}
+
+	start := max(current, 0)
+	end := min(start+maxQueueItems, len(playlist))
+	items := make([]map[string]interface{}, 0, end-start)
+	for i := start; i < end; i++ {
+		item := playlist[i]
+		items = append(items, map[string]interface{}{
+			"position": i,
+			"id":       item.SourceID,
+			"source":   item.Source,
+			"name":     item.Title,
+			"artist":   item.Artist,
+			"current":  i == current,
+		})
+	}
+	return map[string]interface{}{
+		"total":         len(playlist),
+		"current_index": current,
+		"is_playing":    playing,
+		"songs":         items,
+	}, nil
+}
+
+// queueLocalTrack 把用户自己的曲目加入播放列表
+func (a *MusicAgent) queueLocalTrack(ctx context.Context, userID int64, rawID string) (*plugin.PluginSong, error) {
+	if a.trackRepo == nil {
+		return nil, fmt.Errorf("library is not available")
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("track %s not found", rawID)
+	}
+	track, err := a.trackRepo.GetTrackByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if track == nil || track.UserID != userID || track.Status != "completed" || track.DeletedAt != nil {
+		return nil, fmt.Errorf("track %s not found", rawID)
+	}
+
+	item := cache.PlaylistItem{
+		Title:    track.Title,
+		Artist:   track.Artist,
+		Album:    track.Album,
+		Cover:    track.CoverArtPath,
+		Duration: int(track.Duration),
+		Source:   cache.SourceLocal,
+		SourceID: rawID,
+		AddedAt:  time.Now().Unix(),
+	}
+	if !item.Normalize() {
+		return nil, fmt.Errorf("track %s not found", rawID)
+	}
+	if err := cache.AddTrackToPlaylist(ctx, userID, item); err != nil {
+		return nil, err
+	}
+	return &plugin.PluginSong{
+		ID:       rawID,
+		Name:     track.Title,
+		Artists:  []string{track.Artist},
+		Album:    track.Album,
+		Duration: int(track.Duration * 1000),
+		CoverURL: track.CoverArtPath,
+		HLSURL:   item.HLSURL,
+		Source:   cache.SourceLocal,
+	}, nil
+}
+
+// parseToolArgs 解析工具参数，参数可以为空
+func parseToolArgs(call model.OpenAIToolCall, v interface{}) error {
+	if strings.TrimSpace(call.Function.Arguments) == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), v); err != nil {
+		return fmt.Errorf("invalid arguments: %s", call.Function.Arguments)
+	}
+	return nil
+}
+
+// libraryLimit 规范化 limit 参数
+func libraryLimit(limit int) int {
+	if limit <= 0 {
+		return defaultLibraryLimit
+	}
+	return min(limit, maxLibraryLimit)
+}
@@ -1,12 +1,8 @@
 package agent
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"regexp"
 	"strings"
@@ -15,22 +11,33 @@ import (
 	"Bt1QFM/core/plugin"
 	"Bt1QFM/logger"
 	"Bt1QFM/model"
+	"Bt1QFM/repository"
 )
 
 // MusicAgentConfig contains configuration for the music agent.
 type MusicAgentConfig struct {
+	Provider    string // openai, anthropic, gemini or ollama; defaults to openai
 	APIBaseURL  string
 	APIKey      string
 	Model       string
 	MaxTokens   int
 	Temperature float64
+	// ContextTokens is the token budget for history sent to the model; older
+	// turns are summarized. Zero uses DefaultContextTokens.
+	ContextTokens int
+	// Fallbacks are tried in order when the primary provider fails.
+	Fallbacks []*MusicAgentConfig
 }
 
 // MusicAgent handles chat interactions with the AI model.
 type MusicAgent struct {
 	config      *MusicAgentConfig
 	httpClient  *http.Client
+	provider    Provider
 	musicPlugin plugin.MusicPlugin
+	// trackRepo、historyRepo 由 SetLibrary 注入，为空时不提供曲库相关工具
+	trackRepo   repository.TrackRepository
+	historyRepo repository.PlayHistoryRepository
 }
 
 // ToolCall 工具调用结构
@@ -286,15 +293,39 @@ const MusicAgentSystemPrompt = `你是1QFM音乐电台的AI助手"小Q"。
 
 // NewMusicAgent creates a new music agent.
 func NewMusicAgent(config *MusicAgentConfig) *MusicAgent {
+	httpClient := &http.Client{
+		Timeout: 120 * time.Second, // Longer timeout for streaming
+	}
 	return &MusicAgent{
-		config: config,
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second, // Longer timeout for streaming
-		},
+		config:      config,
+		httpClient:  httpClient,
+		provider:    newProviderChain(config, httpClient),
 		musicPlugin: plugin.NewNeteasePlugin(),
 	}
 }
 
+// newProviderChain creates the primary provider followed by its fallbacks.
+// Unsupported providers are skipped; if none is usable the OpenAI format is assumed.
+func newProviderChain(config *MusicAgentConfig, httpClient *http.Client) Provider {
+	var providers []Provider
+	for _, c := range append([]*MusicAgentConfig{config}, config.Fallbacks...) {
+		p, err := NewProvider(c, httpClient)
+		if err != nil {
+			logger.Error("Invalid agent provider, skipped", logger.ErrorField(err))
+			continue
+		}
+		providers = append(providers, p)
+	}
+	switch len(providers) {
+	case 0:
+		return &openAIProvider{config: config, httpClient: httpClient}
+	case 1:
+		return providers[0]
+	default:
+		return &failoverProvider{providers: providers}
+	}
+}
+
 // searchMusicPattern 用于匹配 <search_music>...</search_music> 标签
 var searchMusicPattern = regexp.MustCompile(`<search_music>(.*?)</search_music>`)
 
@@ -345,17 +376,28 @@ func (a *MusicAgent) GetMusicPlugin() plugin.MusicPlugin {
 }
 
 // buildMessages constructs the message array for the API call.
-func (a *MusicAgent) buildMessages(history []*model.ChatMessage, userMessage string) []model.OpenAIChatMessage {
-	messages := make([]model.OpenAIChatMessage, 0, len(history)+2)
+func (a *MusicAgent) buildMessages(chatCtx ChatContext, userMessage string) []model.OpenAIChatMessage {
+	return a.buildMessagesWithPrompt(systemPromptFor(chatCtx.Language), chatCtx, userMessage)
+}
+
+// buildMessagesWithPrompt constructs the message array with the given system prompt.
+// The session summary, if any, is placed before the history messages.
+func (a *MusicAgent) buildMessagesWithPrompt(systemPrompt string, chatCtx ChatContext, userMessage string) []model.OpenAIChatMessage {
+	messages := make([]model.OpenAIChatMessage, 0, len(chatCtx.History)+3)
 
 	// Add system prompt
 	messages = append(messages, model.OpenAIChatMessage{
 		Role:    "system",
-		Content: MusicAgentSystemPrompt,
+		Content: systemPrompt,
 	})
 
+	// Add summary of earlier turns
+	if chatCtx.Summary != "" {
+		messages = append(messages, summaryMessage(chatCtx.Summary))
+	}
+
 	// Add history messages
-	for _, msg := range history {
+	for _, msg := range chatCtx.History {
 		messages = append(messages, model.OpenAIChatMessage{
 			Role:    msg.Role,
 			Content: msg.Content,
@@ -372,51 +414,13 @@ func (a *MusicAgent) buildMessages(history []*model.ChatMessage, userMessage str
 }
 
 // Chat sends a message and returns the complete response.
-func (a *MusicAgent) Chat(ctx context.Context, history []*model.ChatMessage, userMessage string) (string, error) {
-	messages := a.buildMessages(history, userMessage)
-
-	reqBody := model.OpenAIChatRequest{
-		Model:       a.config.Model,
-		Messages:    messages,
-		MaxTokens:   a.config.MaxTokens,
-		Temperature: a.config.Temperature,
-		Stream:      false,
-	}
-
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", a.config.APIBaseURL+"/chat/completions", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+a.config.APIKey)
-
-	resp, err := a.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var chatResp model.OpenAIChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices returned")
-	}
+func (a *MusicAgent) Chat(ctx context.Context, chatCtx ChatContext, userMessage string) (string, error) {
+	return a.complete(ctx, a.buildMessages(chatCtx, userMessage))
+}
 
-	return chatResp.Choices[0].Message.Content, nil
+// complete sends the messages in non-streaming mode and returns the reply.
+func (a *MusicAgent) complete(ctx context.Context, messages []model.OpenAIChatMessage) (string, error) {
+	return a.provider.Complete(ctx, messages)
 }
 
 // StreamCallback is called for each chunk of the streaming response.
@@ -424,20 +428,20 @@ type StreamCallback func(chunk string) error
 
 // ChatStream sends a message and streams the response.
 // If streaming fails to produce content, it falls back to non-streaming mode.
-func (a *MusicAgent) ChatStream(ctx context.Context, history []*model.ChatMessage, userMessage string, callback StreamCallback) (string, error) {
+func (a *MusicAgent) ChatStream(ctx context.Context, chatCtx ChatContext, userMessage string, callback StreamCallback) (string, error) {
 	// Try streaming first
-	result, err := a.chatStreamInternal(ctx, history, userMessage, callback)
+	result, err := a.chatStreamInternal(ctx, chatCtx, userMessage, callback)
 	if err != nil {
 		logger.Warn("Streaming chat failed, falling back to non-streaming",
 			logger.ErrorField(err))
 		// Fall back to non-streaming
-		return a.Chat(ctx, history, userMessage)
+		return a.Chat(ctx, chatCtx, userMessage)
 	}
 
 	// If streaming returned empty, fall back to non-streaming
 	if result == "" {
 		logger.Warn("Streaming returned empty response, falling back to non-streaming")
-		nonStreamResult, err := a.Chat(ctx, history, userMessage)
+		nonStreamResult, err := a.Chat(ctx, chatCtx, userMessage)
 		if err != nil {
 			return "", err
 		}
@@ -452,133 +456,22 @@ func (a *MusicAgent) ChatStream(ctx context.Context, history []*model.ChatMessag
 }
 
 // chatStreamInternal is the internal streaming implementation.
-func (a *MusicAgent) chatStreamInternal(ctx context.Context, history []*model.ChatMessage, userMessage string, callback StreamCallback) (string, error) {
-	messages := a.buildMessages(history, userMessage)
-
-	reqBody := model.OpenAIChatRequest{
-		Model:       a.config.Model,
-		Messages:    messages,
-		MaxTokens:   a.config.MaxTokens,
-		Temperature: a.config.Temperature,
-		Stream:      true,
-	}
-
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
+func (a *MusicAgent) chatStreamInternal(ctx context.Context, chatCtx ChatContext, userMessage string, callback StreamCallback) (string, error) {
+	messages := a.buildMessages(chatCtx, userMessage)
 
 	logger.Info("Sending streaming chat request",
-		logger.String("model", a.config.Model),
-		logger.Int("historyCount", len(history)),
-		logger.Int("maxTokens", a.config.MaxTokens),
-		logger.String("apiUrl", a.config.APIBaseURL))
-
-	req, err := http.NewRequestWithContext(ctx, "POST", a.config.APIBaseURL+"/chat/completions", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
+		logger.String("provider", a.provider.Name()),
+		logger.Int("historyCount", len(chatCtx.History)),
+		logger.Bool("hasSummary", chatCtx.Summary != ""),
+		logger.Int("maxTokens", a.config.MaxTokens))
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+a.config.APIKey)
-	req.Header.Set("Accept", "text/event-stream")
-
-	resp, err := a.httpClient.Do(req)
+	result, err := a.provider.Stream(ctx, messages, callback)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	logger.Info("Stream response started",
-		logger.Int("statusCode", resp.StatusCode),
-		logger.String("contentType", resp.Header.Get("Content-Type")))
-
-	var fullContent strings.Builder
-	reader := bufio.NewReader(resp.Body)
-	lineCount := 0
-
-	for {
-		select {
-		case <-ctx.Done():
-			return fullContent.String(), ctx.Err()
-		default:
-		}
-
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				logger.Info("Stream ended with EOF",
-					logger.Int("linesRead", lineCount),
-					logger.Int("contentLength", fullContent.Len()))
-				break
-			}
-			return fullContent.String(), fmt.Errorf("failed to read stream: %w", err)
-		}
-
-		lineCount++
-		rawLine := line
-		line = strings.TrimSpace(line)
-
-		if line == "" {
-			continue
-		}
-
-		logger.Debug("Stream line received",
-			logger.Int("lineNum", lineCount),
-			logger.String("rawLine", rawLine),
-			logger.String("trimmedLine", line))
-
-		// Skip non-data lines
-		if !strings.HasPrefix(line, "data: ") {
-			logger.Debug("Skipping non-data line",
-				logger.String("line", line))
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
-
-		// Check for stream end
-		if data == "[DONE]" {
-			logger.Info("Stream completed with [DONE]",
-				logger.Int("totalLines", lineCount),
-				logger.Int("contentLength", fullContent.Len()))
-			break
-		}
-
-		var chunk model.OpenAIStreamChunk
-		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-			logger.Warn("Failed to parse stream chunk",
-				logger.String("data", data),
-				logger.ErrorField(err))
-			continue
-		}
-
-		if len(chunk.Choices) > 0 {
-			delta := chunk.Choices[0].Delta
-			if delta.Content != "" {
-				content := delta.Content
-				fullContent.WriteString(content)
-
-				if callback != nil {
-					if err := callback(content); err != nil {
-						// 记录错误但继续处理流，不要因为单次写入失败就中断
-						logger.Warn("Callback error during streaming, continuing",
-							logger.ErrorField(err),
-							logger.Int("contentLenSoFar", fullContent.Len()))
-					}
-				}
-			}
-		}
+		return result, err
 	}
 
 	logger.Info("ChatStream completed",
-		logger.Int("totalLinesRead", lineCount),
-		logger.Int("finalContentLength", fullContent.Len()))
+		logger.Int("finalContentLength", len(result)))
 
-	return fullContent.String(), nil
+	return result, nil
 }
@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// Supported LLM providers.
+const (
+	ProviderOpenAI    = "openai" // OpenAI 及兼容接口（Grok、Azure、one-api 等）
+	ProviderAnthropic = "anthropic"
+	ProviderGemini    = "gemini"
+	ProviderOllama    = "ollama"
+)
+
+// Provider 大模型服务适配器，负责把 OpenAI 格式的消息转换为各家接口的请求和流式响应
+type Provider interface {
+	// Name 返回 "类型/模型"，用于日志
+	Name() string
+	// Complete 非流式请求，返回完整回复
+	Complete(ctx context.Context, messages []model.OpenAIChatMessage) (string, error)
+	// Stream 流式请求，每收到一段内容调用一次 callback，返回完整回复
+	Stream(ctx context.Context, messages []model.OpenAIChatMessage, callback StreamCallback) (string, error)
+}
+
+// ToolStreamer 支持原生工具调用的适配器
+type ToolStreamer interface {
+	// StreamWithTools 流式请求并允许模型调用 tools，返回文本回复和模型请求的工具调用
+	StreamWithTools(ctx context.Context, messages []model.OpenAIChatMessage, tools []model.OpenAITool, callback StreamCallback) (string, []model.OpenAIToolCall, error)
+}
+
+// NewProvider 根据配置创建对应的适配器
+func NewProvider(config *MusicAgentConfig, httpClient *http.Client) (Provider, error) {
+	switch strings.ToLower(config.Provider) {
+	case "", ProviderOpenAI:
+		return &openAIProvider{config: config, httpClient: httpClient}, nil
+	case ProviderAnthropic:
+		return &anthropicProvider{config: config, httpClient: httpClient}, nil
+	case ProviderGemini:
+		return &geminiProvider{config: config, httpClient: httpClient}, nil
+	case ProviderOllama:
+		return &ollamaProvider{config: config, httpClient: httpClient}, nil
+	default:
+		return nil, fmt.Errorf("unsupported agent provider %q", config.Provider)
+	}
+}
+
+// failoverProvider 依次尝试多个适配器，前一个出错时自动切换到下一个
+type failoverProvider struct {
+	providers []Provider
+}
+
+// Name 返回主适配器的名称
+func (f *failoverProvider) Name() string {
+	return f.providers[0].Name()
+}
+
+// Complete 依次尝试，返回第一个成功的回复
+func (f *failoverProvider) Complete(ctx context.Context, messages []model.OpenAIChatMessage) (string, error) {
+	var errs []error
+	for i, p := range f.providers {
+		reply, err := p.Complete(ctx, messages)
+		if err == nil {
+			return reply, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		if !f.shouldFailover(ctx, i, p, err) {
+			break
+		}
+	}
+	return "", errors.Join(errs...)
+}
+
+// Stream 依次尝试；已经向客户端输出内容后不再切换，避免两个模型的回复拼在一起
+func (f *failoverProvider) Stream(ctx context.Context, messages []model.OpenAIChatMessage, callback StreamCallback) (string, error) {
+	var errs []error
+	for i, p := range f.providers {
+		reply, err := p.Stream(ctx, messages, callback)
+		if err == nil || reply != "" {
+			return reply, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		if !f.shouldFailover(ctx, i, p, err) {
+			break
+		}
+	}
+	return "", errors.Join(errs...)
+}
+
+// StreamWithTools 依次尝试；不支持工具调用的备用适配器只返回文本回复
+func (f *failoverProvider) StreamWithTools(ctx context.Context, messages []model.OpenAIChatMessage, tools []model.OpenAITool, callback StreamCallback) (string, []model.OpenAIToolCall, error) {
+	var errs []error
+	for i, p := range f.providers {
+		var reply string
+		var calls []model.OpenAIToolCall
+		var err error
+		if ts, ok := p.(ToolStreamer); ok {
+			reply, calls, err = ts.StreamWithTools(ctx, messages, tools, callback)
+		} else {
+			reply, err = p.Stream(ctx, withoutToolMessages(messages), callback)
+		}
+		if err == nil || reply != "" {
+			return reply, calls, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		if !f.shouldFailover(ctx, i, p, err) {
+			break
+		}
+	}
+	return "", nil, errors.Join(errs...)
+}
+
+// supportsTools 主适配器支持工具调用时才启用工具
+func (f *failoverProvider) supportsTools() bool {
+	_, ok := f.providers[0].(ToolStreamer)
+	return ok
+}
+
+// shouldFailover 请求被取消或已没有备用适配器时不再切换
+func (f *failoverProvider) shouldFailover(ctx context.Context, index int, p Provider, err error) bool {
+	if ctx.Err() != nil || index == len(f.providers)-1 {
+		return false
+	}
+	logger.Warn("模型请求失败，切换到备用模型",
+		logger.String("provider", p.Name()),
+		logger.String("fallback", f.providers[index+1].Name()),
+		logger.ErrorField(err))
+	return true
+}
+
+// withoutToolMessages 去掉工具调用相关的消息，供不支持工具调用的适配器使用
+func withoutToolMessages(messages []model.OpenAIChatMessage) []model.OpenAIChatMessage {
+	out := make([]model.OpenAIChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == "tool" || len(msg.ToolCalls) > 0 && msg.Content == "" {
+			continue
+		}
+		msg.ToolCalls = nil
+		out = append(out, msg)
+	}
+	return out
+}
+
+// splitSystemPrompt 拆出系统提示词，Anthropic 和 Gemini 把它放在单独的字段中
+func splitSystemPrompt(messages []model.OpenAIChatMessage) (string, []model.OpenAIChatMessage) {
+	var system []string
+	rest := make([]model.OpenAIChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+			continue
+		}
+		rest = append(rest, msg)
+	}
+	return strings.Join(system, "\n\n"), rest
+}
+
+// checkResponse 非 200 响应转换为错误
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+}
+
+// readLines 逐行读取流式响应，handle 返回 true 表示流已结束
+func readLines(ctx context.Context, body io.Reader, handle func(line string) (bool, error)) error {
+	reader := bufio.NewReader(body)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			done, herr := handle(line)
+			if herr != nil {
+				return herr
+			}
+			if done {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read stream: %w", err)
+		}
+	}
+}
+
+// readSSE 读取 Server-Sent Events 流，只处理 data 行
+func readSSE(ctx context.Context, body io.Reader, handle func(data string) (bool, error)) error {
+	return readLines(ctx, body, func(line string) (bool, error) {
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			return false, nil
+		}
+		return handle(strings.TrimSpace(data))
+	})
+}
+
+// emit 累积一段内容并回调；回调失败只记录日志，不中断流
+func emit(full *strings.Builder, content string, callback StreamCallback) {
+	if content == "" {
+		return
+	}
+	full.WriteString(content)
+	if callback == nil {
+		return
+	}
+	if err := callback(content); err != nil {
+		logger.Warn("Callback error during streaming, continuing",
+			logger.ErrorField(err),
+			logger.Int("contentLenSoFar", full.Len()))
+	}
+}
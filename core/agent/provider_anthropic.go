@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// anthropicAPIVersion Messages API 版本
+const anthropicAPIVersion = "2023-06-01"
+
+// anthropicProvider Anthropic Messages 接口
+type anthropicProvider struct {
+	config     *MusicAgentConfig
+	httpClient *http.Client
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+// Name 返回 "anthropic/模型"
+func (p *anthropicProvider) Name() string {
+	return ProviderAnthropic + "/" + p.config.Model
+}
+
+// Complete 非流式请求，拼接所有 text 类型的内容块
+func (p *anthropicProvider) Complete(ctx context.Context, messages []model.OpenAIChatMessage) (string, error) {
+	resp, err := p.send(ctx, messages, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	var text strings.Builder
+	for _, block := range body.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("no text content returned")
+	}
+	return text.String(), nil
+}
+
+// Stream 流式请求，处理 content_block_delta 事件，message_stop 时结束
+func (p *anthropicProvider) Stream(ctx context.Context, messages []model.OpenAIChatMessage, callback StreamCallback) (string, error) {
+	resp, err := p.send(ctx, messages, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var fullContent strings.Builder
+	err = readSSE(ctx, resp.Body, func(data string) (bool, error) {
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			logger.Warn("Failed to parse stream chunk",
+				logger.String("data", data),
+				logger.ErrorField(err))
+			return false, nil
+		}
+		switch event.Type {
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" {
+				emit(&fullContent, event.Delta.Text, callback)
+			}
+		case "message_stop":
+			return true, nil
+		case "error":
+			return true, fmt.Errorf("stream error %s: %s", event.Error.Type, event.Error.Message)
+		}
+		return false, nil
+	})
+	return fullContent.String(), err
+}
+
+// send 发送请求并检查状态码；Anthropic 要求 user/assistant 交替且以 user 开头，连续的同角色消息合并为一条
+func (p *anthropicProvider) send(ctx context.Context, messages []model.OpenAIChatMessage, stream bool) (*http.Response, error) {
+	system, rest := splitSystemPrompt(messages)
+	converted := make([]anthropicMessage, 0, len(rest))
+	for _, msg := range rest {
+		if n := len(converted); n > 0 && converted[n-1].Role == msg.Role {
+			converted[n-1].Content += "\n\n" + msg.Content
+			continue
+		}
+		if len(converted) == 0 && msg.Role != "user" {
+			continue
+		}
+		converted = append(converted, anthropicMessage{Role: msg.Role, Content: msg.Content})
+	}
+
+	maxTokens := p.config.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = 2000 // max_tokens 是必填字段
+	}
+	jsonBody, err := json.Marshal(anthropicRequest{
+		Model:       p.config.Model,
+		System:      system,
+		Messages:    converted,
+		MaxTokens:   maxTokens,
+		Temperature: p.config.Temperature,
+		Stream:      stream,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(p.config.APIBaseURL, "/")+"/messages", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.config.APIKey)
+	req.Header.Set("anthropic-version", anthropicAPIVersion)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
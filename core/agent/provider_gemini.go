@@ -0,0 +1,148 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// geminiProvider Google Gemini generateContent 接口
+type geminiProvider struct {
+	config     *MusicAgentConfig
+	httpClient *http.Client
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent  `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent `json:"contents"`
+	GenerationConfig  struct {
+		MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+		Temperature     float64 `json:"temperature,omitempty"`
+	} `json:"generationConfig"`
+}
+
+// geminiResponse 非流式响应和流式响应中的每个事件结构相同
+type geminiResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+}
+
+// text 拼接第一个候选回复的所有文本
+func (r *geminiResponse) text() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		b.WriteString(part.Text)
+	}
+	return b.String()
+}
+
+// Name 返回 "gemini/模型"
+func (p *geminiProvider) Name() string {
+	return ProviderGemini + "/" + p.config.Model
+}
+
+// Complete 非流式请求
+func (p *geminiProvider) Complete(ctx context.Context, messages []model.OpenAIChatMessage) (string, error) {
+	resp, err := p.send(ctx, messages, "generateContent", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	text := body.text()
+	if text == "" {
+		return "", fmt.Errorf("no response candidates returned")
+	}
+	return text, nil
+}
+
+// Stream 流式请求，alt=sse 时每个事件都是一个完整的 geminiResponse
+func (p *geminiProvider) Stream(ctx context.Context, messages []model.OpenAIChatMessage, callback StreamCallback) (string, error) {
+	resp, err := p.send(ctx, messages, "streamGenerateContent", url.Values{"alt": {"sse"}})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var fullContent strings.Builder
+	err = readSSE(ctx, resp.Body, func(data string) (bool, error) {
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logger.Warn("Failed to parse stream chunk",
+				logger.String("data", data),
+				logger.ErrorField(err))
+			return false, nil
+		}
+		emit(&fullContent, chunk.text(), callback)
+		return false, nil
+	})
+	return fullContent.String(), err
+}
+
+// send 发送请求并检查状态码；Gemini 的助手角色为 model
+func (p *geminiProvider) send(ctx context.Context, messages []model.OpenAIChatMessage, method string, query url.Values) (*http.Response, error) {
+	system, rest := splitSystemPrompt(messages)
+	var reqBody geminiRequest
+	if system != "" {
+		reqBody.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+	for _, msg := range rest {
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+		reqBody.Contents = append(reqBody.Contents, geminiContent{Role: role, Parts: []geminiPart{{Text: msg.Content}}})
+	}
+	reqBody.GenerationConfig.MaxOutputTokens = p.config.MaxTokens
+	reqBody.GenerationConfig.Temperature = p.config.Temperature
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/models/%s:%s", strings.TrimRight(p.config.APIBaseURL, "/"), url.PathEscape(p.config.Model), method)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", p.config.APIKey)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
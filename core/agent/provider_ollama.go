@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// ollamaProvider 本地 Ollama 的 /api/chat 接口，不需要 API key
+type ollamaProvider struct {
+	config     *MusicAgentConfig
+	httpClient *http.Client
+}
+
+type ollamaRequest struct {
+	Model    string                    `json:"model"`
+	Messages []model.OpenAIChatMessage `json:"messages"`
+	Stream   bool                      `json:"stream"`
+	Options  struct {
+		NumPredict  int     `json:"num_predict,omitempty"`
+		Temperature float64 `json:"temperature,omitempty"`
+	} `json:"options"`
+}
+
+// ollamaResponse 非流式响应，以及流式响应中的每一行
+type ollamaResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done  bool   `json:"done"`
+	Error string `json:"error"`
+}
+
+// Name 返回 "ollama/模型"
+func (p *ollamaProvider) Name() string {
+	return ProviderOllama + "/" + p.config.Model
+}
+
+// Complete 非流式请求
+func (p *ollamaProvider) Complete(ctx context.Context, messages []model.OpenAIChatMessage) (string, error) {
+	resp, err := p.send(ctx, messages, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if body.Error != "" {
+		return "", fmt.Errorf("ollama error: %s", body.Error)
+	}
+	return body.Message.Content, nil
+}
+
+// Stream 流式请求，响应为每行一个 JSON 对象，done 为 true 时结束
+func (p *ollamaProvider) Stream(ctx context.Context, messages []model.OpenAIChatMessage, callback StreamCallback) (string, error) {
+	resp, err := p.send(ctx, messages, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var fullContent strings.Builder
+	err = readLines(ctx, resp.Body, func(line string) (bool, error) {
+		var chunk ollamaResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			logger.Warn("Failed to parse stream chunk",
+				logger.String("data", line),
+				logger.ErrorField(err))
+			return false, nil
+		}
+		if chunk.Error != "" {
+			return true, fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+		emit(&fullContent, chunk.Message.Content, callback)
+		return chunk.Done, nil
+	})
+	return fullContent.String(), err
+}
+
+// send 发送请求并检查状态码
+func (p *ollamaProvider) send(ctx context.Context, messages []model.OpenAIChatMessage, stream bool) (*http.Response, error) {
+	reqBody := ollamaRequest{
+		Model:    p.config.Model,
+		Messages: messages,
+		Stream:   stream,
+	}
+	reqBody.Options.NumPredict = p.config.MaxTokens
+	reqBody.Options.Temperature = p.config.Temperature
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(p.config.APIBaseURL, "/")+"/api/chat", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.APIKey) // 反向代理鉴权
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
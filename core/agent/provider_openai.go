@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// openAIProvider OpenAI chat-completions 接口，也适用于 Grok、one-api 等兼容服务
+type openAIProvider struct {
+	config     *MusicAgentConfig
+	httpClient *http.Client
+}
+
+// Name 返回 "openai/模型"
+func (p *openAIProvider) Name() string {
+	return ProviderOpenAI + "/" + p.config.Model
+}
+
+// Complete 非流式请求
+func (p *openAIProvider) Complete(ctx context.Context, messages []model.OpenAIChatMessage) (string, error) {
+	resp, err := p.send(ctx, messages, nil, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var chatResp model.OpenAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("no response choices returned")
+	}
+	return chatResp.Choices[0].Message.Content, nil
+}
+
+// Stream 流式请求
+func (p *openAIProvider) Stream(ctx context.Context, messages []model.OpenAIChatMessage, callback StreamCallback) (string, error) {
+	content, _, err := p.StreamWithTools(ctx, messages, nil, callback)
+	return content, err
+}
+
+// StreamWithTools 流式请求，解析 data: {...} 行，以 data: [DONE] 结束；
+// 工具调用按 index 分片下发，参数需要拼接后才是完整的 JSON
+func (p *openAIProvider) StreamWithTools(ctx context.Context, messages []model.OpenAIChatMessage, tools []model.OpenAITool, callback StreamCallback) (string, []model.OpenAIToolCall, error) {
+	resp, err := p.send(ctx, messages, tools, true)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	logger.Info("Stream response started",
+		logger.String("provider", p.Name()),
+		logger.String("contentType", resp.Header.Get("Content-Type")))
+
+	var fullContent strings.Builder
+	var calls []model.OpenAIToolCall
+	err = readSSE(ctx, resp.Body, func(data string) (bool, error) {
+		if data == "[DONE]" {
+			return true, nil
+		}
+		var chunk model.OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logger.Warn("Failed to parse stream chunk",
+				logger.String("data", data),
+				logger.ErrorField(err))
+			return false, nil
+		}
+		if len(chunk.Choices) > 0 {
+			delta := chunk.Choices[0].Delta
+			emit(&fullContent, delta.Content, callback)
+			for _, d := range delta.ToolCalls {
+				if d.Index < 0 || d.Index >= maxToolCallsPerTurn {
+					continue
+				}
+				for len(calls) <= d.Index {
+					calls = append(calls, model.OpenAIToolCall{Type: "function"})
+				}
+				call := &calls[d.Index]
+				if d.ID != "" {
+					call.ID = d.ID
+				}
+				call.Function.Name += d.Function.Name
+				call.Function.Arguments += d.Function.Arguments
+			}
+		}
+		return false, nil
+	})
+	return fullContent.String(), calls, err
+}
+
+// send 发送请求并检查状态码
+func (p *openAIProvider) send(ctx context.Context, messages []model.OpenAIChatMessage, tools []model.OpenAITool, stream bool) (*http.Response, error) {
+	reqBody := model.OpenAIChatRequest{
+		Model:       p.config.Model,
+		Messages:    messages,
+		Tools:       tools,
+		MaxTokens:   p.config.MaxTokens,
+		Temperature: p.config.Temperature,
+		Stream:      stream,
+	}
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(p.config.APIBaseURL, "/")+"/chat/completions", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
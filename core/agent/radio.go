@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// RadioQueryCount 每次续播生成的搜索关键词数量
+const RadioQueryCount = 5
+
+// RadioSeed 生成续播关键词时参考的一首歌
+type RadioSeed struct {
+	Title  string
+	Artist string
+}
+
+// radioSystemPrompt 电台续播的系统提示词，要求每行输出一个搜索关键词
+const radioSystemPrompt = `你是1QFM音乐电台的DJ"小Q"。用户的播放队列即将播完，你需要根据用户最近听过和最常听的歌曲，挑选接下来播放的歌曲。
+
+要求：
+1. 每行输出一个搜索关键词，格式为"歌名 歌手"，不要编号，不要任何解释
+2. 风格与用户的口味相近，但不要推荐列表中已经出现的歌曲
+3. 可以适当加入同风格的其他歌手，避免全部来自同一位歌手
+4. 只推荐真实存在、能在网易云音乐搜索到的歌曲`
+
+// GenerateRadioQueries 根据最近播放和最常听的歌曲生成 count 个续播搜索关键词
+func (a *MusicAgent) GenerateRadioQueries(ctx context.Context, recent, favorites []RadioSeed, count int) ([]string, error) {
+	if count <= 0 {
+		count = RadioQueryCount
+	}
+
+	var b strings.Builder
+	writeSeeds(&b, "最近播放", recent)
+	writeSeeds(&b, "最常听", favorites)
+	if len(recent) == 0 && len(favorites) == 0 {
+		b.WriteString("用户还没有播放记录，请推荐几首风格各异的热门歌曲。\n")
+	}
+	fmt.Fprintf(&b, "请推荐接下来播放的 %d 首歌。", count)
+
+	reply, err := a.complete(ctx, []model.OpenAIChatMessage{
+		{Role: "system", Content: radioSystemPrompt},
+		{Role: "user", Content: b.String()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	queries := parseRadioQueries(reply, count)
+	logger.Debug("[MusicAgent] 生成电台续播关键词",
+		logger.String("reply", reply),
+		logger.Int("count", len(queries)))
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no radio queries in response")
+	}
+	return queries, nil
+}
+
+// writeSeeds 将参考歌曲写入提示词
+func writeSeeds(b *strings.Builder, label string, seeds []RadioSeed) {
+	if len(seeds) == 0 {
+		return
+	}
+	b.WriteString(label)
+	b.WriteString("：\n")
+	for _, seed := range seeds {
+		fmt.Fprintf(b, "- %s - %s\n", seed.Title, seed.Artist)
+	}
+	b.WriteString("\n")
+}
+
+// radioListMarker 匹配行首的编号或列表符号，如 "1." "2、" "3)" "-" "*"
+var radioListMarker = regexp.MustCompile(`^(\d+[.、)）]|[-*•])\s*`)
+
+// radioQuoteReplacer 去掉歌名两侧常见的引号和书名号
+var radioQuoteReplacer = strings.NewReplacer("\"", "", "“", "", "”", "", "《", "", "》", "", "`", "")
+
+// parseRadioQueries 逐行解析关键词，去掉模型常加的编号、列表符号和引号，最多返回 count 个
+func parseRadioQueries(reply string, count int) []string {
+	// 模型偶尔仍会输出 <search_music> 标签
+	reply = searchMusicPattern.ReplaceAllString(reply, "\n$1\n")
+
+	seen := make(map[string]bool)
+	queries := make([]string, 0, count)
+	for _, line := range strings.Split(reply, "\n") {
+		line = radioListMarker.ReplaceAllString(strings.TrimSpace(line), "")
+		line = strings.TrimSpace(radioQuoteReplacer.Replace(line))
+		// 跳过空行、"好的，推荐如下：" 之类的引导语和过长的解释
+		if line == "" || strings.HasSuffix(line, ":") || strings.HasSuffix(line, "：") || len([]rune(line)) > 60 || seen[line] {
+			continue
+		}
+		seen[line] = true
+		queries = append(queries, line)
+		if len(queries) == count {
+			break
+		}
+	}
+	return queries
+}
@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"Bt1QFM/model"
+)
+
+// maxTitleRunes 会话标题的最大长度（字符）
+const maxTitleRunes = 20
+
+// titleSystemPrompt 生成会话标题的系统提示词
+const titleSystemPrompt = `根据用户发给音乐电台AI助手的第一条消息，为这次对话起一个简短的标题。
+要求：不超过12个字，不要引号和标点结尾，只输出标题本身。`
+
+// GenerateTitle 根据会话的第一条消息生成标题，lang 决定使用的提示词
+func (a *MusicAgent) GenerateTitle(ctx context.Context, firstMessage, lang string) (string, error) {
+	reply, err := a.complete(ctx, []model.OpenAIChatMessage{
+		{Role: "system", Content: titlePromptFor(lang)},
+		{Role: "user", Content: firstMessage},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 只取第一行，去掉模型常加的 "标题：" 前缀和引号
+	title, _, _ := strings.Cut(strings.TrimSpace(reply), "\n")
+	title = strings.TrimPrefix(strings.TrimPrefix(title, "标题："), "标题:")
+	title = strings.Trim(strings.TrimSpace(title), "\"'“”《》「」。.")
+	if title == "" {
+		return "", fmt.Errorf("empty title in response")
+	}
+	return TruncateTitle(title), nil
+}
+
+// TruncateTitle 截断到标题的最大长度，模型不可用时也直接用来从消息生成标题
+func TruncateTitle(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= maxTitleRunes {
+		return text
+	}
+	return string(runes[:maxTitleRunes]) + "…"
+}
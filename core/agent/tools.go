@@ -0,0 +1,247 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/plugin"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// Tool names.
+const (
+	ToolSearchMusic = "search_music"
+	ToolQueueSong   = "queue_song"
+)
+
+const (
+	// maxToolRounds 一条消息内最多执行几轮工具调用，超过后要求模型直接回复
+	maxToolRounds = 3
+	// maxToolCallsPerTurn 单轮最多执行的工具调用数
+	maxToolCallsPerTurn = 8
+	// defaultToolSearchLimit、maxToolSearchLimit search_music 返回给模型的结果数
+	defaultToolSearchLimit = 5
+	maxToolSearchLimit     = 10
+)
+
+// MusicAgentToolPrompt 工具调用模式下的系统提示词
+const MusicAgentToolPrompt = `你是1QFM音乐电台的AI助手"小Q"，热情、简洁地和用户聊音乐。
+
+## 工具使用规则
+1. 提到或推荐具体歌曲时，调用 search_music 搜索，用"歌名 歌手"作为关键词，一首歌调用一次
+2. 用户要求播放、加入播放列表时，先 search_music，再用结果中的 id 调用 queue_song
+3. 不要编造歌曲 id 或链接，不要在回复中输出搜索关键词
+4. 搜索结果会以歌曲卡片展示给用户，回复中只需简短介绍歌曲`
+
+// ToolContext 执行工具时的用户上下文
+type ToolContext struct {
+	UserID int64
+}
+
+// ToolResult 一次工具调用的结果，用于向客户端推送歌曲卡片
+type ToolResult struct {
+	Name  string              // 工具名
+	Query string              // search_music 的关键词
+	Songs []plugin.PluginSong // search_music 的结果，或 queue_song 加入队列的歌曲
+	Err   error
+}
+
+// ToolCallback 每执行完一次工具调用回调一次
+type ToolCallback func(result ToolResult)
+
+// musicTools 提供给模型的工具定义
+var musicTools = []model.OpenAITool{
+	newTool(ToolSearchMusic, "在曲库中搜索歌曲，返回歌曲 id、歌名、歌手和专辑", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{"type": "string", "description": "搜索关键词，格式为\"歌名 歌手\""},
+			"limit": map[string]interface{}{"type": "integer", "description": "返回结果数，默认 5，最多 10"},
+		},
+		"required": []string{"query"},
+	}),
+	newTool(ToolQueueSong, "把一首歌加入用户的播放列表末尾", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"song_id": map[string]interface{}{"type": "string", "description": "search_music 或 list_library 返回的歌曲 id"},
+			"source":  map[string]interface{}{"type": "string", "enum": []string{"netease", "local"}, "description": "歌曲来源，用户曲库中的曲目为 local，默认 netease"},
+		},
+		"required": []string{"song_id"},
+	}),
+}
+
+func newTool(name, description string, parameters map[string]interface{}) model.OpenAITool {
+	var tool model.OpenAITool
+	tool.Type = "function"
+	tool.Function.Name = name
+	tool.Function.Description = description
+	tool.Function.Parameters = parameters
+	return tool
+}
+
+// SupportsTools 当前模型服务是否支持原生工具调用；不支持时使用 <search_music> 标签模式
+func (a *MusicAgent) SupportsTools() bool {
+	if f, ok := a.provider.(*failoverProvider); ok {
+		return f.supportsTools()
+	}
+	_, ok := a.provider.(ToolStreamer)
+	return ok
+}
+
+// ChatStreamWithTools 以工具调用模式流式回复：模型请求的工具执行后把结果交回模型，直到模型给出最终回复
+// 返回各轮输出的完整文本
+func (a *MusicAgent) ChatStreamWithTools(ctx context.Context, chatCtx ChatContext, userMessage string, tc ToolContext, callback StreamCallback, onTool ToolCallback) (string, error) {
+	streamer, ok := a.provider.(ToolStreamer)
+	if !ok {
+		return "", fmt.Errorf("provider %s does not support tool calling", a.provider.Name())
+	}
+
+	messages := a.buildMessagesWithPrompt(a.toolPrompt(chatCtx.Language), chatCtx, userMessage)
+	var fullContent strings.Builder
+	for round := 0; ; round++ {
+		// 达到轮数上限后不再提供工具，强制模型直接回复
+		tools := a.availableTools()
+		if round == maxToolRounds {
+			tools = nil
+		}
+
+		content, calls, err := streamer.StreamWithTools(ctx, messages, tools, callback)
+		fullContent.WriteString(content)
+		if err != nil {
+			return fullContent.String(), err
+		}
+		if len(calls) == 0 {
+			return fullContent.String(), nil
+		}
+		if len(calls) > maxToolCallsPerTurn {
+			calls = calls[:maxToolCallsPerTurn]
+		}
+
+		logger.Info("[MusicAgent] 执行工具调用",
+			logger.Int64("userID", tc.UserID),
+			logger.Int("round", round),
+			logger.Int("calls", len(calls)))
+
+		messages = append(messages, model.OpenAIChatMessage{
+			Role:      "assistant",
+			Content:   content,
+			ToolCalls: calls,
+		})
+		for _, call := range calls {
+			result, output := a.executeTool(ctx, tc, call)
+			if onTool != nil {
+				onTool(result)
+			}
+			messages = append(messages, model.OpenAIChatMessage{
+				Role:       "tool",
+				Content:    output,
+				ToolCallID: call.ID,
+			})
+		}
+	}
+}
+
+// executeTool 执行一次工具调用，返回结果和交给模型的 JSON 输出
+func (a *MusicAgent) executeTool(ctx context.Context, tc ToolContext, call model.OpenAIToolCall) (ToolResult, string) {
+	if result, output, ok := a.executeLibraryTool(ctx, tc, call); ok {
+		return result, output
+	}
+	result := ToolResult{Name: call.Function.Name}
+
+	switch call.Function.Name {
+	case ToolSearchMusic:
+		var args struct {
+			Query string `json:"query"`
+			Limit int    `json:"limit"`
+		}
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil || strings.TrimSpace(args.Query) == "" {
+			result.Err = fmt.Errorf("invalid arguments: %s", call.Function.Arguments)
+			return result, toolError(result.Err)
+		}
+		if args.Limit <= 0 {
+			args.Limit = defaultToolSearchLimit
+		}
+		result.Query = strings.TrimSpace(args.Query)
+		result.Songs, result.Err = a.SearchMusic(result.Query, min(args.Limit, maxToolSearchLimit))
+		if result.Err != nil {
+			return result, toolError(result.Err)
+		}
+		return result, toolOutput(map[string]interface{}{"songs": songSummaries(result.Songs)})
+
+	case ToolQueueSong:
+		var args struct {
+			SongID string `json:"song_id"`
+			Source string `json:"source"`
+		}
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil || args.SongID == "" {
+			result.Err = fmt.Errorf("invalid arguments: %s", call.Function.Arguments)
+			return result, toolError(result.Err)
+		}
+		if args.Source == cache.SourceLocal {
+			song, err := a.queueLocalTrack(ctx, tc.UserID, args.SongID)
+			if err != nil {
+				result.Err = err
+				return result, toolError(fmt.Errorf("failed to queue track %s", args.SongID))
+			}
+			result.Songs = []plugin.PluginSong{*song}
+			return result, toolOutput(map[string]interface{}{"queued": songSummaries(result.Songs)[0]})
+		}
+		song, err := a.musicPlugin.GetDetail(args.SongID)
+		if err != nil || song == nil {
+			result.Err = fmt.Errorf("song %s not found", args.SongID)
+			return result, toolError(result.Err)
+		}
+		err = cache.AddTrackToPlaylist(ctx, tc.UserID, cache.PlaylistItem{
+			Title:    song.Name,
+			Artist:   strings.Join(song.Artists, "/"),
+			Album:    song.Album,
+			Cover:    song.CoverURL,
+			Duration: song.Duration / 1000,
+			Source:   song.Source,
+			SourceID: song.ID,
+			HLSURL:   song.HLSURL,
+			AddedAt:  time.Now().Unix(),
+		})
+		if err != nil {
+			result.Err = err
+			return result, toolError(fmt.Errorf("failed to queue song"))
+		}
+		result.Songs = []plugin.PluginSong{*song}
+		return result, toolOutput(map[string]interface{}{"queued": songSummaries(result.Songs)[0]})
+
+	default:
+		result.Err = fmt.Errorf("unknown tool %q", call.Function.Name)
+		return result, toolError(result.Err)
+	}
+}
+
+// songSummaries 交给模型的歌曲信息，省略封面和播放地址以节省 token
+func songSummaries(songs []plugin.PluginSong) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(songs))
+	for i, song := range songs {
+		out[i] = map[string]interface{}{
+			"id":      song.ID,
+			"name":    song.Name,
+			"artists": song.Artists,
+			"album":   song.Album,
+		}
+	}
+	return out
+}
+
+func toolOutput(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return toolError(err)
+	}
+	return string(data)
+}
+
+func toolError(err error) string {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(data)
+}
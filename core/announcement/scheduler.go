@@ -0,0 +1,131 @@
+package announcement
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/core/notification"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+// defaultInterval ANNOUNCEMENT_SCHEDULE_INTERVAL_SECONDS 无效时的检查间隔
+const defaultInterval = time.Minute
+
+// Scheduler 公告定时任务：发布时间到达的公告切换为已发布并通知受众，过期的公告切换为已过期
+// 用户查询公告时同样按发布和过期时间过滤，定时任务只负责切换状态和发送通知
+type Scheduler struct {
+	repo          *repository.AnnouncementRepository
+	notifications *notification.Service
+	cfg           *config.Config
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewScheduler 创建公告定时任务，notifications 为空时发布公告不发送通知
+func NewScheduler(repo *repository.AnnouncementRepository, notifications *notification.Service, cfg *config.Config) *Scheduler {
+	return &Scheduler{
+		repo:          repo,
+		notifications: notifications,
+		cfg:           cfg,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start 启动时先处理停机期间到期的公告，之后按配置的间隔检查
+func (s *Scheduler) Start() {
+	interval := s.interval()
+	logger.Info("公告定时任务启动", logger.Duration("interval", interval))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.tick()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				s.tick()
+			}
+		}
+	}()
+}
+
+// Stop 停止定时任务
+func (s *Scheduler) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) interval() time.Duration {
+	if s.cfg.AnnouncementScheduleIntervalSeconds <= 0 {
+		return defaultInterval
+	}
+	return time.Duration(s.cfg.AnnouncementScheduleIntervalSeconds) * time.Second
+}
+
+func (s *Scheduler) tick() {
+	published, expired, err := s.Run(context.Background(), time.Now())
+	if err != nil {
+		logger.Warn("处理定时公告失败", logger.ErrorField(err))
+		return
+	}
+	if published > 0 || expired > 0 {
+		logger.Info("定时公告已处理",
+			logger.Int("published", published),
+			logger.Int64("expired", expired))
+	}
+}
+
+// Run 发布 now 之前到期的公告并将过期的公告标记为已过期，返回发布和过期的数量
+func (s *Scheduler) Run(ctx context.Context, now time.Time) (published int, expired int64, err error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	due, err := s.repo.GetDueAnnouncements(now)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i := range due {
+		a := &due[i]
+		// 停机期间发布时间和过期时间都已经过去的公告直接过期，不再通知
+		status := a.StatusAt(now)
+		switched, err := s.repo.UpdateStatus(a.ID, model.AnnouncementScheduled, status)
+		if err != nil {
+			return published, 0, err
+		}
+		if !switched || status != model.AnnouncementPublished {
+			continue
+		}
+		a.Status = status
+		s.Announce(ctx, a)
+		published++
+	}
+
+	expired, err = s.repo.ExpireAnnouncements(now)
+	if err != nil {
+		return published, 0, err
+	}
+	return published, expired, nil
+}
+
+// Announce 通知公告的受众，公告发布时调用
+func (s *Scheduler) Announce(ctx context.Context, a *model.Announcement) {
+	if s.notifications == nil {
+		return
+	}
+	s.notifications.Broadcast(ctx, &model.Notification{
+		Type:     model.NotificationAnnouncement,
+		ObjectID: a.ID,
+		Title:    a.Title,
+		Content:  a.Content,
+	}, a.NotificationAudience())
+}
@@ -2,6 +2,7 @@ package audio
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -29,7 +30,7 @@ func (p *FFmpegProcessor) FFmpegPath() string {
 
 // getAudioFormat 获取音频文件的格式
 func (p *FFmpegProcessor) getAudioFormat(inputFile string) (string, error) {
-	ffprobePath := strings.Replace(p.ffmpegPath, "ffmpeg", "ffprobe", 1)
+	ffprobePath := ffprobePathFor(p.ffmpegPath)
 
 	args := []string{
 		"-v", "error",
@@ -69,6 +70,12 @@ func (p *FFmpegProcessor) getAudioFormat(inputFile string) (string, error) {
 // ProcessToHLS transcodes an audio file to HLS format (M3U8 playlist and TS segments).
 // It returns the duration of the audio file in seconds.
 func (p *FFmpegProcessor) ProcessToHLS(inputFile, outputM3U8, segmentPattern, hlsBaseURL, audioBitrate, hlsSegmentTime string) (float32, error) {
+	return p.ProcessToHLSWithOptions(inputFile, outputM3U8, segmentPattern, hlsBaseURL, audioBitrate, hlsSegmentTime, nil)
+}
+
+// ProcessToHLSWithOptions is like ProcessToHLS but applies the optional audio
+// processing (silence trimming, fades) described by opts.
+func (p *FFmpegProcessor) ProcessToHLSWithOptions(inputFile, outputM3U8, segmentPattern, hlsBaseURL, audioBitrate, hlsSegmentTime string, opts *TranscodeOptions) (float32, error) {
 	log.Printf("Processing %s to HLS. Output M3U8: %s, Segments: %s, Base URL: %s", inputFile, outputM3U8, segmentPattern, hlsBaseURL)
 
 	// Ensure output directory for M3U8 exists
@@ -86,33 +93,55 @@ func (p *FFmpegProcessor) ProcessToHLS(inputFile, outputM3U8, segmentPattern, hl
 	// 构建FFmpeg参数
 	// 使用多线程加速转码：-threads 0 表示自动检测 CPU 核心数
 	args := []string{
-		"-threads", "0", // 自动使用所有可用 CPU 核心
+		"-threads", sharedTranscodePool.threads(), // 默认 0，自动使用所有可用 CPU 核心
 		"-i", inputFile,
-		"-c:a", "aac",
+		"-vn", // 忽略封面等视频流
 	}
 
-	// 统一使用192k比特率，在音质和性能之间取得平衡
-	// 移除FLAC特殊处理，减少编码开销
-	args = append(args, "-b:a", "192k")
+	// 按输出格式选择编码器：默认 AAC 192k，在音质和性能之间取得平衡
+	args = append(args, opts.codecArgs()...)
+
+	// 按用户偏好添加音频滤镜
+	if filter := opts.AudioFilter(float64(duration)); filter != "" {
+		args = append(args, "-af", filter)
+	}
+
+	// 分片加密
+	keyArgs, cleanupKey, err := opts.encryption().ffmpegArgs()
+	if err != nil {
+		return 0, err
+	}
+	defer cleanupKey()
+	args = append(args, keyArgs...)
 
 	// 添加HLS相关参数
+	// 使用 EVENT 类型播放列表，每写完一个分片就更新一次，转码完成时追加 #EXT-X-ENDLIST；
+	// temp_file 让分片和播放列表先写入 .tmp 再重命名，读取方不会看到写了一半的文件
 	args = append(args,
 		"-hls_time", hlsSegmentTime,
-		"-hls_playlist_type", "vod",
+		"-hls_playlist_type", "event",
+		"-hls_flags", "temp_file",
 		"-hls_list_size", "0",
 		"-hls_segment_filename", segmentPattern,
 		"-hls_base_url", hlsBaseURL,
-		"-f", "hls",
-		outputM3U8,
 	)
+	args = append(args, opts.segmentArgs()...)
+	args = append(args, "-f", "hls", outputM3U8)
 
-	cmd := exec.Command(p.ffmpegPath, args...)
+	// 在转码池中排队，限制同时运行的 FFmpeg 进程数
+	release, err := sharedTranscodePool.acquire(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	cmd := sharedTranscodePool.command(context.Background(), p.ffmpegPath, args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
 	log.Printf("Executing FFmpeg command: %s %s", p.ffmpegPath, strings.Join(args, " "))
 
-	if err := cmd.Run(); err != nil {
+	if err := sharedTranscodePool.observe(context.Background(), cmd.Run()); err != nil {
 		return 0, fmt.Errorf("ffmpeg execution failed for %s: %w\nFFmpeg Error: %s", inputFile, err, stderr.String())
 	}
 
@@ -129,7 +158,7 @@ type ffprobeOutput struct {
 
 // GetAudioDuration uses ffprobe to get the duration of an audio file in seconds.
 func (p *FFmpegProcessor) GetAudioDuration(inputFile string) (float32, error) {
-	ffprobePath := strings.Replace(p.ffmpegPath, "ffmpeg", "ffprobe", 1)
+	ffprobePath := ffprobePathFor(p.ffmpegPath)
 
 	args := []string{
 		"-v", "error",
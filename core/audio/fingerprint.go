@@ -0,0 +1,132 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"os/exec"
+)
+
+const (
+	// DuplicateSimilarityThreshold 判定为重复曲目的最低相似度
+	DuplicateSimilarityThreshold = 0.85
+	// DuplicateDurationTolerance 重复曲目允许的时长差（秒），时长相差更大的曲目不必比较指纹
+	DuplicateDurationTolerance = 7.0
+	// fingerprintMaxOffset 比较指纹时允许的最大错位（每项约 0.124 秒）
+	fingerprintMaxOffset = 80
+	// fingerprintMinOverlap 比较时要求的最少重叠项数
+	fingerprintMinOverlap = 40
+)
+
+// Fingerprint Chromaprint 原始音频指纹
+type Fingerprint struct {
+	Duration float64
+	Values   []uint32
+}
+
+// ComputeFingerprint 调用 fpcalc 计算音频文件的原始指纹
+func ComputeFingerprint(ctx context.Context, fpcalcPath, inputPath string) (*Fingerprint, error) {
+	cmd := exec.CommandContext(ctx, fpcalcPath, "-raw", "-json", inputPath)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("fpcalc execution failed for %s: %w\nfpcalc Error: %s", inputPath, err, stderr.String())
+	}
+
+	var result struct {
+		Duration    float64 `json:"duration"`
+		Fingerprint []int64 `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		return nil, fmt.Errorf("解析fpcalc输出失败: %w", err)
+	}
+	if len(result.Fingerprint) == 0 {
+		return nil, fmt.Errorf("fpcalc未返回指纹: %s", inputPath)
+	}
+
+	values := make([]uint32, len(result.Fingerprint))
+	for i, v := range result.Fingerprint {
+		values[i] = uint32(v)
+	}
+	return &Fingerprint{Duration: result.Duration, Values: values}, nil
+}
+
+// Encode 将指纹编码为 base64 字符串以便存储
+func (f *Fingerprint) Encode() string {
+	buf := make([]byte, len(f.Values)*4)
+	for i, v := range f.Values {
+		binary.LittleEndian.PutUint32(buf[i*4:], v)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// DecodeFingerprint 从存储的 base64 字符串还原指纹
+func DecodeFingerprint(encoded string, duration float64) (*Fingerprint, error) {
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("解码指纹失败: %w", err)
+	}
+	if len(buf)%4 != 0 {
+		return nil, fmt.Errorf("指纹数据长度无效: %d", len(buf))
+	}
+	values := make([]uint32, len(buf)/4)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint32(buf[i*4:])
+	}
+	return &Fingerprint{Duration: duration, Values: values}, nil
+}
+
+// Similarity 计算两个指纹的相似度（0~1），在一定错位范围内取最佳对齐
+func (f *Fingerprint) Similarity(other *Fingerprint) float64 {
+	if f == nil || other == nil {
+		return 0
+	}
+	best := 0.0
+	for offset := -fingerprintMaxOffset; offset <= fingerprintMaxOffset; offset++ {
+		if s := alignedSimilarity(f.Values, other.Values, offset); s > best {
+			best = s
+		}
+	}
+	return best
+}
+
+// IsDuplicateOf 判断两个指纹是否为同一录音
+func (f *Fingerprint) IsDuplicateOf(other *Fingerprint) (bool, float64) {
+	if f == nil || other == nil {
+		return false, 0
+	}
+	if math.Abs(f.Duration-other.Duration) > DuplicateDurationTolerance {
+		return false, 0
+	}
+	similarity := f.Similarity(other)
+	return similarity >= DuplicateSimilarityThreshold, similarity
+}
+
+// alignedSimilarity 计算 b 相对 a 错位 offset 项时重叠部分的比特一致率
+func alignedSimilarity(a, b []uint32, offset int) float64 {
+	startA, startB := 0, 0
+	if offset > 0 {
+		startA = offset
+	} else {
+		startB = -offset
+	}
+	n := len(a) - startA
+	if m := len(b) - startB; m < n {
+		n = m
+	}
+	if n < fingerprintMinOverlap {
+		return 0
+	}
+
+	diffBits := 0
+	for i := 0; i < n; i++ {
+		diffBits += bits.OnesCount32(a[startA+i] ^ b[startB+i])
+	}
+	return 1 - float64(diffBits)/float64(n*32)
+}
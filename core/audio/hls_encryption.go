@@ -0,0 +1,71 @@
+package audio
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"Bt1QFM/core/auth"
+)
+
+// HLSEncryption HLS 分片的 AES-128 加密参数
+type HLSEncryption struct {
+	KeyURI string // 播放列表中 #EXT-X-KEY 的密钥地址
+	Key    []byte
+	IV     []byte
+}
+
+// HLSKeyURI 返回流密钥的下发地址，该接口需要登录并检查流的访问权限
+func HLSKeyURI(streamID string) string {
+	return "/api/streams/" + streamID + "/key"
+}
+
+// NewHLSEncryption 按流 ID 派生加密参数，密钥不落盘，由密钥接口按需重新派生
+func NewHLSEncryption(streamID string) *HLSEncryption {
+	key, iv := auth.DeriveStreamKey(streamID)
+	return &HLSEncryption{
+		KeyURI: HLSKeyURI(streamID),
+		Key:    key,
+		IV:     iv,
+	}
+}
+
+// KeyTag 返回播放列表中的 #EXT-X-KEY 标签，未加密时返回空字符串
+func (e *HLSEncryption) KeyTag() string {
+	if e == nil {
+		return ""
+	}
+	return fmt.Sprintf("#EXT-X-KEY:METHOD=AES-128,URI=\"%s\",IV=0x%s", e.KeyURI, hex.EncodeToString(e.IV))
+}
+
+// ffmpegArgs 生成 FFmpeg 的 -hls_key_info_file 参数，返回的 cleanup 用于删除临时密钥文件
+// 密钥文件写在系统临时目录而不是流输出目录，避免随分片一起被上传或通过 /streams/ 访问
+// 使用固定 IV 而不是按分片序号推导，渐进式播放列表缺少中间分片时也能正确解密
+func (e *HLSEncryption) ffmpegArgs() (args []string, cleanup func(), err error) {
+	cleanup = func() {}
+	if e == nil {
+		return nil, cleanup, nil
+	}
+
+	dir, err := os.MkdirTemp("", "hls-key-*")
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("创建密钥临时目录失败: %w", err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+
+	keyPath := filepath.Join(dir, "enc.key")
+	if err := os.WriteFile(keyPath, e.Key, 0600); err != nil {
+		cleanup()
+		return nil, func() {}, fmt.Errorf("写入密钥文件失败: %w", err)
+	}
+
+	infoPath := filepath.Join(dir, "enc.keyinfo")
+	info := fmt.Sprintf("%s\n%s\n%s\n", e.KeyURI, keyPath, hex.EncodeToString(e.IV))
+	if err := os.WriteFile(infoPath, []byte(info), 0600); err != nil {
+		cleanup()
+		return nil, func() {}, fmt.Errorf("写入密钥信息文件失败: %w", err)
+	}
+
+	return []string{"-hls_key_info_file", infoPath}, cleanup, nil
+}
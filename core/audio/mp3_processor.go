@@ -2,6 +2,7 @@ package audio
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -12,33 +13,19 @@ import (
 	"sync"
 	"time"
 
-	"Bt1QFM/core/utils"
 	"Bt1QFM/logger"
 )
 
-// PreprocessTask 表示预处理任务
-type PreprocessTask struct {
-	SongID     string
-	URL        string
-	OutputDir  string
-	ResultChan chan<- *PreprocessResult
-}
-
-// PreprocessResult 表示预处理结果
-type PreprocessResult struct {
-	Success bool
-	Error   error
-}
-
 // MP3Processor 处理网易云音乐的音频文件
 type MP3Processor struct {
 	ffmpegPath       string
 	processingStatus map[string]*ProcessingStatus
-	statusMutex      sync.RWMutex // 添加状态锁
-	preprocessChan   chan *PreprocessTask
+	statusMutex      sync.RWMutex  // 添加状态锁
+	prepareQueue     *prepareQueue // 按优先级排队的预处理任务
+	prepareFunc      PrepareFunc
+	prepareMu        sync.RWMutex
 	workerCount      int
 	wg               sync.WaitGroup
-	stopChan         chan struct{}
 }
 
 // ProcessingStatus 表示音频处理状态
@@ -50,7 +37,8 @@ type ProcessingStatus struct {
 	StartTime    time.Time
 	SongID       string
 	IsNetease    bool
-	done         chan struct{} // 添加完成信号
+	done         chan struct{}    // 添加完成信号
+	lease        *processingLease // 跨实例的分布式锁，Redis 不可用时为 nil
 }
 
 // NewMP3Processor 创建一个新的 MP3 处理器
@@ -58,9 +46,8 @@ func NewMP3Processor(ffmpegPath string) *MP3Processor {
 	processor := &MP3Processor{
 		ffmpegPath:       ffmpegPath,
 		processingStatus: make(map[string]*ProcessingStatus),
-		preprocessChan:   make(chan *PreprocessTask, 10), // 缓冲通道，最多存储10个预处理任务
-		workerCount:      2,                              // 默认2个工作协程
-		stopChan:         make(chan struct{}),
+		prepareQueue:     newPrepareQueue(),
+		workerCount:      2, // 默认2个预处理协程
 	}
 
 	// 启动工作池
@@ -74,135 +61,20 @@ func (p *MP3Processor) GetFFmpegPath() string {
 	return p.ffmpegPath
 }
 
-// startWorkers 启动工作协程池
+// startWorkers 启动预处理协程池
 func (p *MP3Processor) startWorkers() {
 	for i := 0; i < p.workerCount; i++ {
 		p.wg.Add(1)
-		go p.worker()
-	}
-}
-
-// worker 工作协程
-func (p *MP3Processor) worker() {
-	defer p.wg.Done()
-
-	for {
-		select {
-		case task := <-p.preprocessChan:
-			// 检查是否已经在处理中
-			if status := p.GetProcessingStatus(task.SongID); status != nil && status.IsProcessing {
-				task.ResultChan <- &PreprocessResult{
-					Success: false,
-					Error:   fmt.Errorf("song is already being processed"),
-				}
-				continue
-			}
-
-			// 设置处理状态
-			p.SetProcessingStatus(task.SongID, true, nil)
-
-			// 创建临时目录
-			tempDir := filepath.Join(task.OutputDir, "temp", task.SongID)
-			if err := os.MkdirAll(tempDir, 0755); err != nil {
-				p.UpdateProcessingStatus(task.SongID, err)
-				task.ResultChan <- &PreprocessResult{
-					Success: false,
-					Error:   fmt.Errorf("failed to create temp directory: %w", err),
-				}
-				continue
-			}
-
-			// 下载音频文件
-			tempFile := filepath.Join(tempDir, fmt.Sprintf("%s.mp3", task.SongID))
-			if err := utils.DownloadFile(task.URL, tempFile); err != nil {
-				logger.Error("下载音频文件失败", logger.String("songId", task.SongID), logger.ErrorField(err))
-				p.UpdateProcessingStatus(task.SongID, err)
-				task.ResultChan <- &PreprocessResult{
-					Success: false,
-					Error:   fmt.Errorf("failed to download file: %w", err),
-				}
-				continue
-			}
-
-			// 优化MP3文件
-			optimizedFile := filepath.Join(tempDir, fmt.Sprintf("%s_optimized.mp3", task.SongID))
-			if err := p.OptimizeMP3(tempFile, optimizedFile); err != nil {
-				p.UpdateProcessingStatus(task.SongID, err)
-				task.ResultChan <- &PreprocessResult{
-					Success: false,
-					Error:   fmt.Errorf("failed to optimize MP3: %w", err),
-				}
-				continue
-			}
-
-			// 转换为HLS格式
-			hlsDir := filepath.Join(task.OutputDir, "streams", "netease", task.SongID)
-			if err := os.MkdirAll(hlsDir, 0755); err != nil {
-				p.UpdateProcessingStatus(task.SongID, err)
-				task.ResultChan <- &PreprocessResult{
-					Success: false,
-					Error:   fmt.Errorf("failed to create HLS directory: %w", err),
-				}
-				continue
-			}
-
-			outputM3U8 := filepath.Join(hlsDir, "playlist.m3u8")
-			segmentPattern := filepath.Join(hlsDir, "segment_%03d.ts")
-			hlsBaseURL := fmt.Sprintf("/streams/netease/%s/", task.SongID)
-
-			_, err := p.ProcessToHLS(optimizedFile, outputM3U8, segmentPattern, hlsBaseURL, "192k", "4")
-			if err != nil {
-				p.UpdateProcessingStatus(task.SongID, err)
-				task.ResultChan <- &PreprocessResult{
-					Success: false,
-					Error:   fmt.Errorf("failed to process to HLS: %w", err),
-				}
-				continue
-			}
-
-			// 清理临时文件
-			os.RemoveAll(tempDir)
-
-			// 更新处理状态
-			p.UpdateProcessingStatus(task.SongID, nil)
-
-			task.ResultChan <- &PreprocessResult{
-				Success: true,
-			}
-
-		case <-p.stopChan:
-			return
-		}
+		go p.prepareWorker()
 	}
 }
 
-// Stop 停止所有工作协程
+// Stop 停止所有预处理协程，正在处理的任务完成后返回
 func (p *MP3Processor) Stop() {
-	close(p.stopChan)
+	p.prepareQueue.close()
 	p.wg.Wait()
 }
 
-// PreprocessSong 异步预处理歌曲
-func (p *MP3Processor) PreprocessSong(songID, url, outputDir string) error {
-	resultChan := make(chan *PreprocessResult, 1)
-
-	task := &PreprocessTask{
-		SongID:     songID,
-		URL:        url,
-		OutputDir:  outputDir,
-		ResultChan: resultChan,
-	}
-
-	p.preprocessChan <- task
-	result := <-resultChan
-
-	if !result.Success {
-		return result.Error
-	}
-
-	return nil
-}
-
 // GetProcessingStatus 获取处理状态 - 线程安全
 func (p *MP3Processor) GetProcessingStatus(songID string) *ProcessingStatus {
 	p.statusMutex.RLock()
@@ -259,6 +131,9 @@ func (p *MP3Processor) UpdateProcessingStatus(songID string, err error) {
 
 // ClearProcessingStatus 清除处理状态 - 线程安全
 func (p *MP3Processor) ClearProcessingStatus(songID string) {
+	var lease *processingLease
+	defer func() { lease.release() }() // 在释放状态锁之后执行，避免持锁访问 Redis
+
 	p.statusMutex.Lock()
 	defer p.statusMutex.Unlock()
 
@@ -267,14 +142,15 @@ func (p *MP3Processor) ClearProcessingStatus(songID string) {
 			status.IsProcessing = false
 			close(status.done)
 		}
+		lease = status.lease
 		delete(p.processingStatus, songID)
 	}
 }
 
 // TryLockProcessing 尝试获取处理锁
+// 先在进程内占位，再获取 Redis 分布式锁，其他实例正在处理同一首歌时同样返回 false
 func (p *MP3Processor) TryLockProcessing(songID string, isNetease bool) (*ProcessingStatus, bool) {
 	p.statusMutex.Lock()
-	defer p.statusMutex.Unlock()
 
 	logger.Debug("尝试获取歌曲处理锁",
 		logger.String("songId", songID),
@@ -282,6 +158,7 @@ func (p *MP3Processor) TryLockProcessing(songID string, isNetease bool) (*Proces
 
 	// 检查是否已经在处理中
 	if status, exists := p.processingStatus[songID]; exists && status.IsProcessing {
+		p.statusMutex.Unlock()
 		logger.Info("歌曲正在处理中，无法获取锁",
 			logger.String("songId", songID),
 			logger.Bool("isNetease", isNetease),
@@ -301,6 +178,37 @@ func (p *MP3Processor) TryLockProcessing(songID string, isNetease bool) (*Proces
 	}
 
 	p.processingStatus[songID] = status
+	p.statusMutex.Unlock()
+
+	lease, acquired := acquireProcessingLease(songID)
+
+	p.statusMutex.Lock()
+	if acquired && p.processingStatus[songID] != status {
+		// 获取分布式锁期间本地占位已被释放或替换，放弃刚获取的锁，在释放状态锁之后访问 Redis
+		p.statusMutex.Unlock()
+		lease.release()
+		logger.Info("获取分布式锁期间处理状态已被释放，放弃处理锁",
+			logger.String("songId", songID),
+			logger.Bool("isNetease", isNetease))
+		return nil, false
+	}
+	defer p.statusMutex.Unlock()
+
+	if !acquired {
+		// 其他实例持有锁，撤销本地占位并唤醒等待者
+		if p.processingStatus[songID] == status {
+			delete(p.processingStatus, songID)
+		}
+		if status.IsProcessing {
+			status.IsProcessing = false
+			close(status.done)
+		}
+		logger.Info("歌曲正在其他实例处理中，无法获取锁",
+			logger.String("songId", songID),
+			logger.Bool("isNetease", isNetease))
+		return nil, false
+	}
+	status.lease = lease
 
 	logger.Info("成功获取歌曲处理锁",
 		logger.String("songId", songID),
@@ -312,6 +220,9 @@ func (p *MP3Processor) TryLockProcessing(songID string, isNetease bool) (*Proces
 
 // ReleaseProcessing 释放处理锁
 func (p *MP3Processor) ReleaseProcessing(songID string) {
+	var lease *processingLease
+	defer func() { lease.release() }() // 在释放状态锁之后执行，避免持锁访问 Redis
+
 	p.statusMutex.Lock()
 	defer p.statusMutex.Unlock()
 
@@ -323,6 +234,7 @@ func (p *MP3Processor) ReleaseProcessing(songID string) {
 			close(status.done)
 		}
 
+		lease = status.lease
 		delete(p.processingStatus, songID)
 
 		logger.Info("成功释放歌曲处理锁",
@@ -397,6 +309,13 @@ func (p *MP3Processor) IsProcessing(songID string) bool {
 
 // CleanupExpiredProcessing 清理过期的处理状态
 func (p *MP3Processor) CleanupExpiredProcessing(maxAge time.Duration) {
+	var leases []*processingLease
+	defer func() {
+		for _, lease := range leases {
+			lease.release()
+		}
+	}()
+
 	p.statusMutex.Lock()
 	defer p.statusMutex.Unlock()
 
@@ -411,6 +330,9 @@ func (p *MP3Processor) CleanupExpiredProcessing(maxAge time.Duration) {
 				close(status.done)
 			}
 
+			if status.lease != nil {
+				leases = append(leases, status.lease)
+			}
 			delete(p.processingStatus, songID)
 			cleanedCount++
 
@@ -432,6 +354,11 @@ func (p *MP3Processor) CleanupExpiredProcessing(maxAge time.Duration) {
 
 // ProcessToHLS 将 MP3 文件转换为 HLS 格式
 func (p *MP3Processor) ProcessToHLS(inputFile, outputM3U8, segmentPattern, hlsBaseURL, audioBitrate, hlsSegmentTime string) (float32, error) {
+	return p.ProcessToHLSWithOptions(inputFile, outputM3U8, segmentPattern, hlsBaseURL, audioBitrate, hlsSegmentTime, nil)
+}
+
+// ProcessToHLSWithOptions 将 MP3 文件转换为 HLS 格式，并应用转码选项（静音裁剪、淡入淡出）
+func (p *MP3Processor) ProcessToHLSWithOptions(inputFile, outputM3U8, segmentPattern, hlsBaseURL, audioBitrate, hlsSegmentTime string, opts *TranscodeOptions) (float32, error) {
 	logger.Info("开始MP3到HLS转换",
 		logger.String("inputFile", inputFile),
 		logger.String("outputM3U8", outputM3U8),
@@ -465,24 +392,53 @@ func (p *MP3Processor) ProcessToHLS(inputFile, outputM3U8, segmentPattern, hlsBa
 	// 构建 FFmpeg 参数
 	// 使用多线程加速转码：-threads 0 表示自动检测 CPU 核心数
 	args := []string{
-		"-threads", "0", // 自动使用所有可用 CPU 核心
+		"-threads", sharedTranscodePool.threads(), // 默认 0，自动使用所有可用 CPU 核心
 		"-i", inputFile,
-		"-c:a", "aac", // 使用 AAC 编码
-		"-b:a", "192k", // 设置比特率为 192k
-		"-ar", "44100", // 设置采样率为 44.1kHz
+	}
+	// 按输出格式选择编码器，默认 AAC 192k、44.1kHz
+	args = append(args, opts.codecArgs()...)
+	if !opts.IsFMP4() {
+		args = append(args, "-ar", "44100")
+	}
+	args = append(args,
 		"-ac", "2", // 设置为双声道
 		"-vn",                 // 不处理视频
 		"-map_metadata", "-1", // 移除元数据
-		"-hls_time", "4", // 每个分片 4 秒
-		"-hls_playlist_type", "vod",
+	)
+
+	// 按用户偏好添加音频滤镜
+	if filter := opts.AudioFilter(float64(duration)); filter != "" {
+		args = append(args, "-af", filter)
+	}
+
+	// 分片加密
+	keyArgs, cleanupKey, err := opts.encryption().ffmpegArgs()
+	if err != nil {
+		return 0, err
+	}
+	defer cleanupKey()
+	args = append(args, keyArgs...)
+
+	// EVENT 类型播放列表随分片写入不断更新，转码未完成时即可开始播放
+	args = append(args,
+		"-hls_time", hlsSegmentTime,
+		"-hls_playlist_type", "event",
+		"-hls_flags", "temp_file", // 分片和播放列表写完后再重命名，避免读到不完整的文件
 		"-hls_list_size", "0", // 保留所有分片
 		"-hls_segment_filename", segmentPattern,
 		"-hls_base_url", hlsBaseURL,
-		"-f", "hls",
-		outputM3U8,
+	)
+	args = append(args, opts.segmentArgs()...)
+	args = append(args, "-f", "hls", outputM3U8)
+
+	// 在转码池中排队，限制同时运行的 FFmpeg 进程数
+	release, err := sharedTranscodePool.acquire(context.Background())
+	if err != nil {
+		return 0, err
 	}
+	defer release()
 
-	cmd := exec.Command(p.ffmpegPath, args...)
+	cmd := sharedTranscodePool.command(context.Background(), p.ffmpegPath, args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
@@ -495,7 +451,7 @@ func (p *MP3Processor) ProcessToHLS(inputFile, outputM3U8, segmentPattern, hlsBa
 		return 0, fmt.Errorf("FFmpeg执行前文件丢失 %s: %w", inputFile, err)
 	}
 
-	if err := cmd.Run(); err != nil {
+	if err := sharedTranscodePool.observe(context.Background(), cmd.Run()); err != nil {
 		// 检查文件是否在执行过程中被删除
 		if _, statErr := os.Stat(inputFile); statErr != nil {
 			return 0, fmt.Errorf("FFmpeg执行期间文件被删除 %s: %w (original error: %v)", inputFile, statErr, err)
@@ -527,7 +483,7 @@ func (p *MP3Processor) ProcessToHLS(inputFile, outputM3U8, segmentPattern, hlsBa
 
 // GetAudioDuration 获取音频文件时长
 func (p *MP3Processor) GetAudioDuration(inputFile string) (float32, error) {
-	ffprobePath := strings.Replace(p.ffmpegPath, "ffmpeg", "ffprobe", 1)
+	ffprobePath := ffprobePathFor(p.ffmpegPath)
 
 	args := []string{
 		"-v", "error",
@@ -580,11 +536,17 @@ func (p *MP3Processor) OptimizeMP3(inputFile, outputFile string) error {
 		outputFile,
 	}
 
-	cmd := exec.Command(p.ffmpegPath, args...)
+	release, err := sharedTranscodePool.acquire(context.Background())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	cmd := sharedTranscodePool.command(context.Background(), p.ffmpegPath, args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	if err := sharedTranscodePool.observe(context.Background(), cmd.Run()); err != nil {
 		return fmt.Errorf("ffmpeg execution failed for optimizing %s: %w\nFFmpeg Error: %s", inputFile, err, stderr.String())
 	}
 
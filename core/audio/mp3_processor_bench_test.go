@@ -0,0 +1,119 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// benchFFmpeg 返回压测使用的 FFmpeg 路径，优先使用 FFMPEG_PATH，找不到时跳过
+func benchFFmpeg(b *testing.B) string {
+	b.Helper()
+	path := os.Getenv("FFMPEG_PATH")
+	if path == "" {
+		path = "ffmpeg"
+	}
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		b.Skipf("未找到 FFmpeg（%s），跳过转码压测", path)
+	}
+	return resolved
+}
+
+// benchInput 用 FFmpeg 生成一段 30 秒的 MP3 样本
+func benchInput(b *testing.B, ffmpegPath string) string {
+	b.Helper()
+	input := filepath.Join(b.TempDir(), "input.mp3")
+	cmd := exec.Command(ffmpegPath, "-v", "error", "-y",
+		"-f", "lavfi", "-i", "sine=frequency=440:sample_rate=44100:duration=30",
+		"-ac", "2", "-b:a", "192k", input)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		b.Fatalf("生成样本音频失败: %v: %s", err, out)
+	}
+	return input
+}
+
+// BenchmarkProcessToHLS 测量单个 30 秒 MP3 转码为 HLS 的耗时，audio-s/s 为转码速度相对实时播放的倍数
+func BenchmarkProcessToHLS(b *testing.B) {
+	ffmpegPath := benchFFmpeg(b)
+	input := benchInput(b, ffmpegPath)
+	processor := NewMP3Processor(ffmpegPath)
+	b.Cleanup(processor.Stop)
+
+	var audioSeconds float64
+	job := 0
+	for b.Loop() {
+		outDir := filepath.Join(b.TempDir(), strconv.Itoa(job))
+		job++
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			b.Fatal(err)
+		}
+		duration, err := processor.ProcessToHLS(input,
+			filepath.Join(outDir, "playlist.m3u8"),
+			filepath.Join(outDir, "segment_%03d.ts"),
+			"/streams/bench/", "192k", "10")
+		if err != nil {
+			b.Fatal(err)
+		}
+		audioSeconds += float64(duration)
+	}
+	b.ReportMetric(audioSeconds/b.Elapsed().Seconds(), "audio-s/s")
+}
+
+// BenchmarkProcessToHLSParallel 在不同转码并发度下测量整体吞吐
+func BenchmarkProcessToHLSParallel(b *testing.B) {
+	ffmpegPath := benchFFmpeg(b)
+	input := benchInput(b, ffmpegPath)
+
+	for _, concurrency := range []int{1, 2, 4} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			SetTranscodeLimits(TranscodeLimits{Concurrency: concurrency})
+			b.Cleanup(func() { SetTranscodeLimits(TranscodeLimits{}) })
+			processor := NewMP3Processor(ffmpegPath)
+			b.Cleanup(processor.Stop)
+			workDir := b.TempDir()
+
+			b.SetParallelism(concurrency)
+			var job atomic.Int64
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					outDir := filepath.Join(workDir, strconv.FormatInt(job.Add(1), 10))
+					if err := os.MkdirAll(outDir, 0755); err != nil {
+						b.Error(err)
+						return
+					}
+					if _, err := processor.ProcessToHLS(input,
+						filepath.Join(outDir, "playlist.m3u8"),
+						filepath.Join(outDir, "segment_%03d.ts"),
+						"/streams/bench/", "192k", "10"); err != nil {
+						b.Error(err)
+						return
+					}
+					os.RemoveAll(outDir)
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkTryLockProcessing 测量进程内处理锁的获取和释放，Redis 未连接时只使用进程内锁
+func BenchmarkTryLockProcessing(b *testing.B) {
+	processor := NewMP3Processor("ffmpeg")
+	b.Cleanup(processor.Stop)
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			songID := strconv.Itoa(i % 64)
+			i++
+			if _, ok := processor.TryLockProcessing(songID, true); ok {
+				processor.ReleaseProcessing(songID)
+			}
+		}
+	})
+}
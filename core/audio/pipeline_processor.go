@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -19,7 +18,6 @@ import (
 	"Bt1QFM/storage"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/minio/minio-go/v7"
 )
 
 // PipelineProcessor 流水线处理器
@@ -65,7 +63,8 @@ func NewPipelineProcessor(ffmpeg *FFmpegProcessor, cfg *config.Config, workers i
 // ProcessWithPipeline 流水线处理：边转码边上传
 // 相比传统方式，首个分片可用时间从 ~30s 降低到 ~2-4s
 // 支持渐进式播放：边转码边播放
-func (p *PipelineProcessor) ProcessWithPipeline(ctx context.Context, streamID, inputPath, tempDir string, isNetease bool) (*PipelineResult, error) {
+// opts 为可选的转码处理（静音裁剪、淡入淡出），nil 表示不处理
+func (p *PipelineProcessor) ProcessWithPipeline(ctx context.Context, streamID, inputPath, tempDir string, isNetease bool, opts *TranscodeOptions) (*PipelineResult, error) {
 	startTime := time.Now()
 
 	logger.Info("开始流水线处理（渐进式HLS模式）",
@@ -85,6 +84,13 @@ func (p *PipelineProcessor) ProcessWithPipeline(ctx context.Context, streamID, i
 
 	// 创建渐进式 HLS 状态（默认分片时长 4 秒）
 	hlsState := GetProgressiveHLSManager().CreateState(streamID, tempDir, isNetease, 4.0)
+	hlsState.KeyTag = opts.encryption().KeyTag()
+	hlsState.SegmentExt = opts.SegmentExt()
+	hlsState.FMP4 = opts.IsFMP4()
+	// 探测源文件时长用于估算转码进度，失败时进度未知，不影响转码
+	if d, err := p.ffmpeg.GetAudioDuration(inputPath); err == nil {
+		hlsState.SetExpectedDuration(float64(d))
+	}
 
 	// 创建任务通道和结果收集
 	taskChan := make(chan *SegmentTask, 100)
@@ -131,7 +137,7 @@ func (p *PipelineProcessor) ProcessWithPipeline(ctx context.Context, streamID, i
 	var duration float32
 	go func() {
 		outputM3U8 := filepath.Join(tempDir, "playlist.m3u8")
-		segmentPattern := filepath.Join(tempDir, "segment_%03d.ts")
+		segmentPattern := filepath.Join(tempDir, "segment_%03d"+opts.SegmentExt())
 
 		var hlsBaseURL string
 		if isNetease {
@@ -140,7 +146,7 @@ func (p *PipelineProcessor) ProcessWithPipeline(ctx context.Context, streamID, i
 			hlsBaseURL = fmt.Sprintf("/streams/%s/", streamID)
 		}
 
-		d, err := p.ffmpeg.ProcessToHLS(inputPath, outputM3U8, segmentPattern, hlsBaseURL, "192k", "4")
+		d, err := p.ffmpeg.ProcessToHLSWithOptions(inputPath, outputM3U8, segmentPattern, hlsBaseURL, opts.AACBitrate(), opts.HLSSegmentTime(), opts)
 		duration = d
 		ffmpegDone <- err
 	}()
@@ -211,10 +217,10 @@ func (p *PipelineProcessor) watchSegments(
 				return
 			}
 
-			// 只处理 .ts 和 .m3u8 文件的写入事件
+			// 只处理分片文件的写入事件；播放列表在转码过程中不断更新，
+			// 由 FFmpeg 完成后的最终扫描上传完整版本
 			if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
-				ext := filepath.Ext(event.Name)
-				if ext == ".ts" || ext == ".m3u8" {
+				if isSegmentFile(event.Name) {
 					pendingFiles[event.Name] = time.Now()
 				}
 			}
@@ -413,11 +419,11 @@ func (p *PipelineProcessor) workerWithHLS(
 }
 
 // parseSegmentIndex 从分片文件名解析索引
-// segment_000.ts -> 0, segment_001.ts -> 1
+// segment_000.ts -> 0, segment_001.m4s -> 1，初始化分片返回 -1
 func parseSegmentIndex(segmentName string) int {
 	// 移除前缀和后缀
 	name := strings.TrimPrefix(segmentName, "segment_")
-	name = strings.TrimSuffix(name, ".ts")
+	name = strings.TrimSuffix(name, filepath.Ext(name))
 
 	idx, err := strconv.Atoi(name)
 	if err != nil {
@@ -451,8 +457,8 @@ func (p *PipelineProcessor) processRemainingSegments(
 	processedSegments *sync.Map,
 	segmentCount *int32,
 ) {
-	// 扫描目录中所有 .ts 和 .m3u8 文件
-	files, err := filepath.Glob(filepath.Join(tempDir, "*.ts"))
+	// 扫描目录中所有分片和 .m3u8 文件
+	files, err := listSegmentFiles(tempDir)
 	if err != nil {
 		return
 	}
@@ -486,8 +492,8 @@ func (p *PipelineProcessor) processRemainingSegments(
 
 // uploadSegmentToMinIO 上传单个分片到 MinIO
 func (p *PipelineProcessor) uploadSegmentToMinIO(task *SegmentTask, data []byte, isNetease bool) {
-	client := storage.GetMinioClient()
-	if client == nil {
+	store := storage.GetStorage()
+	if store == nil {
 		return
 	}
 
@@ -501,21 +507,17 @@ func (p *PipelineProcessor) uploadSegmentToMinIO(task *SegmentTask, data []byte,
 	var contentType string
 	if task.IsM3U8 {
 		contentType = "application/vnd.apple.mpegurl"
-	} else {
+	} else if strings.HasSuffix(task.SegmentName, ".ts") {
 		contentType = "video/MP2T"
+	} else {
+		contentType = "audio/mp4" // fMP4 分片和初始化分片
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	reader := strings.NewReader(string(data))
-	opts := minio.PutObjectOptions{
-		ContentType:      contentType,
-		DisableMultipart: true,
-	}
-
-	_, err := client.PutObject(ctx, p.cfg.MinioBucket, minioPath, reader, int64(len(data)), opts)
-	if err != nil {
+	if err := store.Put(ctx, minioPath, reader, int64(len(data)), contentType); err != nil {
 		logger.Warn("分片上传MinIO失败",
 			logger.String("segment", task.SegmentName),
 			logger.ErrorField(err))
@@ -537,13 +539,14 @@ func (p *PipelineProcessor) ProcessToHLSWithProgress(
 	// 构建 FFmpeg 命令（带进度输出）
 	// 使用多线程加速转码：-threads 0 表示自动检测 CPU 核心数
 	args := []string{
-		"-threads", "0", // 自动使用所有可用 CPU 核心
+		"-threads", sharedTranscodePool.threads(), // 默认 0，自动使用所有可用 CPU 核心
 		"-progress", "pipe:1", // 进度输出到 stdout
 		"-i", inputPath,
 		"-c:a", "aac",
 		"-b:a", "192k",
 		"-hls_time", "4",
-		"-hls_playlist_type", "vod",
+		"-hls_playlist_type", "event",
+		"-hls_flags", "temp_file",
 		"-hls_list_size", "0",
 		"-hls_segment_filename", segmentPattern,
 		"-hls_base_url", hlsBaseURL,
@@ -551,7 +554,13 @@ func (p *PipelineProcessor) ProcessToHLSWithProgress(
 		outputM3U8,
 	}
 
-	cmd := exec.CommandContext(ctx, p.ffmpeg.FFmpegPath(), args...)
+	release, err := sharedTranscodePool.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	cmd := sharedTranscodePool.command(ctx, p.ffmpeg.FFmpegPath(), args...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -596,7 +605,7 @@ func (p *PipelineProcessor) ProcessToHLSWithProgress(
 		}
 	}()
 
-	if err := cmd.Wait(); err != nil {
+	if err := sharedTranscodePool.observe(ctx, cmd.Wait()); err != nil {
 		return 0, err
 	}
 
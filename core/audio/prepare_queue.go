@@ -0,0 +1,257 @@
+package audio
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"Bt1QFM/logger"
+)
+
+// PreparePriority 预处理任务的优先级，数值越大越先处理
+type PreparePriority int
+
+const (
+	// PrioritySpeculative 推测性预处理，如搜索结果中靠前的歌曲
+	PrioritySpeculative PreparePriority = iota
+	// PriorityRequested 用户明确要播放的歌曲，排在所有推测性任务之前
+	PriorityRequested
+)
+
+// 预处理状态
+const (
+	PrepareStateIdle       = "idle"       // 不在队列中，也没有在处理
+	PrepareStateQueued     = "queued"     // 排队中
+	PrepareStateProcessing = "processing" // 正在下载或转码
+	PrepareStateFailed     = "failed"     // 最近一次预处理失败
+)
+
+const (
+	// maxPrepareQueue 队列长度上限，超过后丢弃新的推测性任务；明确请求的任务总会入队
+	maxPrepareQueue = 50
+	// prepareTimeout 单首歌曲预处理的最长时间
+	prepareTimeout = 10 * time.Minute
+)
+
+// PrepareFunc 下载并转码一首歌，由调用方提供（网易云歌曲需要先获取播放地址）
+type PrepareFunc func(ctx context.Context, songID string) error
+
+// PrepareStatus 一首歌的预处理状态
+type PrepareStatus struct {
+	State    string `json:"state"`
+	Position int    `json:"position,omitempty"` // 排队时前面还有多少个任务（从 1 开始）
+	Error    string `json:"error,omitempty"`
+}
+
+// prepareItem 队列中的一个任务
+type prepareItem struct {
+	songID   string
+	priority PreparePriority
+	seq      uint64 // 入队顺序，同优先级先进先出
+	index    int
+}
+
+// prepareHeap 按优先级和入队顺序排列的堆
+type prepareHeap []*prepareItem
+
+func (h prepareHeap) Len() int { return len(h) }
+
+func (h prepareHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h prepareHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *prepareHeap) Push(x any) {
+	item := x.(*prepareItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *prepareHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// prepareQueue 预处理优先队列，同一首歌只排队一次
+type prepareQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  prepareHeap
+	byID   map[string]*prepareItem
+	failed map[string]string // 最近一次失败的原因，重新入队时清除
+	seq    uint64
+	closed bool
+}
+
+func newPrepareQueue() *prepareQueue {
+	q := &prepareQueue{
+		byID:   make(map[string]*prepareItem),
+		failed: make(map[string]string),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push 入队或提升已在队列中的任务的优先级，返回是否在队列中
+func (q *prepareQueue) push(songID string, priority PreparePriority) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	if item, ok := q.byID[songID]; ok {
+		if priority > item.priority {
+			item.priority = priority
+			heap.Fix(&q.items, item.index)
+		}
+		return true
+	}
+	if priority == PrioritySpeculative && len(q.items) >= maxPrepareQueue {
+		return false
+	}
+
+	q.seq++
+	item := &prepareItem{songID: songID, priority: priority, seq: q.seq}
+	heap.Push(&q.items, item)
+	q.byID[songID] = item
+	delete(q.failed, songID)
+	q.cond.Signal()
+	return true
+}
+
+// pop 取出优先级最高的任务，队列为空时阻塞；队列关闭后返回 false
+func (q *prepareQueue) pop() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return "", false
+	}
+	item := heap.Pop(&q.items).(*prepareItem)
+	delete(q.byID, item.songID)
+	return item.songID, true
+}
+
+// position 任务前面的任务数加一，不在队列中时返回 0
+func (q *prepareQueue) position(songID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	item, ok := q.byID[songID]
+	if !ok {
+		return 0
+	}
+	ahead := 0
+	for _, other := range q.items {
+		if q.items.Less(other.index, item.index) {
+			ahead++
+		}
+	}
+	return ahead + 1
+}
+
+func (q *prepareQueue) setFailed(songID string, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err == nil {
+		delete(q.failed, songID)
+		return
+	}
+	q.failed[songID] = err.Error()
+}
+
+func (q *prepareQueue) failure(songID string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.failed[songID]
+}
+
+func (q *prepareQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// SetPrepareFunc 设置预处理函数，未设置时队列中的任务会被丢弃
+func (p *MP3Processor) SetPrepareFunc(fn PrepareFunc) {
+	p.prepareMu.Lock()
+	defer p.prepareMu.Unlock()
+	p.prepareFunc = fn
+}
+
+// EnqueuePrepare 把歌曲加入预处理队列；已在队列中时按更高的优先级重新排队
+func (p *MP3Processor) EnqueuePrepare(songID string, priority PreparePriority) PrepareStatus {
+	if !p.IsProcessing(songID) && !p.prepareQueue.push(songID, priority) {
+		logger.Debug("预处理队列已满，跳过推测性预处理", logger.String("songId", songID))
+	}
+	return p.PrepareStatus(songID)
+}
+
+// PrepareStatus 返回歌曲的预处理状态
+func (p *MP3Processor) PrepareStatus(songID string) PrepareStatus {
+	if p.IsProcessing(songID) {
+		return PrepareStatus{State: PrepareStateProcessing}
+	}
+	if pos := p.prepareQueue.position(songID); pos > 0 {
+		return PrepareStatus{State: PrepareStateQueued, Position: pos}
+	}
+	if reason := p.prepareQueue.failure(songID); reason != "" {
+		return PrepareStatus{State: PrepareStateFailed, Error: reason}
+	}
+	return PrepareStatus{State: PrepareStateIdle}
+}
+
+// prepareWorker 按优先级依次处理队列中的歌曲
+func (p *MP3Processor) prepareWorker() {
+	defer p.wg.Done()
+	for {
+		songID, ok := p.prepareQueue.pop()
+		if !ok {
+			return
+		}
+		p.runPrepare(songID)
+	}
+}
+
+// runPrepare 处理一首歌，已被播放请求或预热服务处理中的歌曲直接跳过
+func (p *MP3Processor) runPrepare(songID string) {
+	p.prepareMu.RLock()
+	fn := p.prepareFunc
+	p.prepareMu.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	if _, acquired := p.TryLockProcessing(songID, true); !acquired {
+		return
+	}
+	defer p.ReleaseProcessing(songID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), prepareTimeout)
+	defer cancel()
+	start := time.Now()
+	err := fn(ctx, songID)
+	p.prepareQueue.setFailed(songID, err)
+	if err != nil {
+		logger.Warn("歌曲预处理失败", logger.String("songId", songID), logger.ErrorField(err))
+		return
+	}
+	logger.Info("歌曲预处理完成",
+		logger.String("songId", songID),
+		logger.Duration("elapsed", time.Since(start)))
+}
@@ -0,0 +1,107 @@
+package audio
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/logger"
+)
+
+const (
+	// processingLockTTL 分布式锁的租约时长，实例崩溃后最多经过该时长锁自动释放
+	processingLockTTL = 30 * time.Second
+	// processingLockRenewInterval 持有锁期间的续期间隔
+	processingLockRenewInterval = processingLockTTL / 3
+	// processingLockOpTimeout 单次 Redis 锁操作的超时时间
+	processingLockOpTimeout = 3 * time.Second
+)
+
+// processingLockOwner 本实例的锁标识，释放和续期时校验，避免操作其他实例的锁
+var processingLockOwner = newProcessingLockOwner()
+
+func newProcessingLockOwner() string {
+	hostname, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(b))
+}
+
+// processingLease 本实例持有的一把分布式锁，后台按间隔续期直到释放
+type processingLease struct {
+	songID string
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// acquireProcessingLease 获取跨实例的歌曲处理锁，其他实例正在处理时返回 false
+// Redis 未连接或出错时返回 (nil, true)，退化为只有进程内锁，不阻塞转码
+func acquireProcessingLease(songID string) (*processingLease, bool) {
+	if cache.RedisClient == nil {
+		return nil, true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), processingLockOpTimeout)
+	defer cancel()
+	ok, err := cache.AcquireProcessingLock(ctx, songID, processingLockOwner, processingLockTTL)
+	if err != nil {
+		logger.Warn("获取分布式处理锁失败，仅使用进程内锁",
+			logger.String("songId", songID),
+			logger.ErrorField(err))
+		return nil, true
+	}
+	if !ok {
+		return nil, false
+	}
+
+	lease := &processingLease{songID: songID, stop: make(chan struct{})}
+	go lease.renew()
+	return lease, true
+}
+
+// renew 按间隔续期，锁已丢失（如 Redis 重启）时停止续期
+func (l *processingLease) renew() {
+	ticker := time.NewTicker(processingLockRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), processingLockOpTimeout)
+			renewed, err := cache.RenewProcessingLock(ctx, l.songID, processingLockOwner, processingLockTTL)
+			cancel()
+			if err != nil {
+				logger.Warn("分布式处理锁续期失败", logger.String("songId", l.songID), logger.ErrorField(err))
+				continue
+			}
+			if !renewed {
+				logger.Warn("分布式处理锁已丢失，其他实例可能同时处理该歌曲", logger.String("songId", l.songID))
+				return
+			}
+		}
+	}
+}
+
+// release 停止续期并释放锁，可重复调用，l 为 nil 时不做任何事
+func (l *processingLease) release() {
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		close(l.stop)
+		ctx, cancel := context.WithTimeout(context.Background(), processingLockOpTimeout)
+		defer cancel()
+		if err := cache.ReleaseProcessingLock(ctx, l.songID, processingLockOwner); err != nil {
+			logger.Warn("释放分布式处理锁失败，锁将在租约到期后自动释放",
+				logger.String("songId", l.songID),
+				logger.ErrorField(err))
+		}
+	})
+}
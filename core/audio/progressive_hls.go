@@ -24,6 +24,10 @@ type ProgressiveHLSState struct {
 	SegmentInfos    map[int]float64   // 分片索引 -> 实际时长
 	IsComplete      bool              // 转码是否完成
 	TotalDuration   float64           // 总时长（转码完成后才有）
+	ExpectedDuration float64          // 转码前探测到的源文件时长，用于估算进度，未知时为 0
+	KeyTag          string            // 分片加密时的 #EXT-X-KEY 标签
+	SegmentExt      string            // 分片扩展名，为空时为 .ts
+	FMP4            bool              // 是否为 fMP4 分片，需要 #EXT-X-MAP 指向初始化分片
 	StartTime       time.Time
 	mu              sync.RWMutex
 }
@@ -117,6 +121,31 @@ func (s *ProgressiveHLSState) MarkComplete(totalDuration float64) {
 		logger.Float64("totalDuration", totalDuration))
 }
 
+// SetExpectedDuration 设置源文件时长
+func (s *ProgressiveHLSState) SetExpectedDuration(duration float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ExpectedDuration = duration
+}
+
+// Progress 转码进度（0-1），按已完成分片的时长和源文件时长估算；完成前最多为 0.99，时长未知时为 0
+func (s *ProgressiveHLSState) Progress() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.IsComplete {
+		return 1
+	}
+	if s.ExpectedDuration <= 0 {
+		return 0
+	}
+	var done float64
+	for _, d := range s.SegmentInfos {
+		done += d
+	}
+	return min(done/s.ExpectedDuration, 0.99)
+}
+
 // GetCompletedSegmentCount 获取已完成分片数
 func (s *ProgressiveHLSState) GetCompletedSegmentCount() int {
 	s.mu.RLock()
@@ -133,7 +162,12 @@ func (s *ProgressiveHLSState) GenerateM3U8() string {
 
 	// HLS 头部
 	builder.WriteString("#EXTM3U\n")
-	builder.WriteString("#EXT-X-VERSION:3\n")
+	// fMP4 分片需要 #EXT-X-MAP，要求版本 7
+	if s.FMP4 {
+		builder.WriteString("#EXT-X-VERSION:7\n")
+	} else {
+		builder.WriteString("#EXT-X-VERSION:3\n")
+	}
 	builder.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(s.SegmentDuration)+1))
 	builder.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
 
@@ -144,6 +178,14 @@ func (s *ProgressiveHLSState) GenerateM3U8() string {
 		builder.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
 	}
 
+	if s.FMP4 {
+		builder.WriteString(fmt.Sprintf("#EXT-X-MAP:URI=\"%s%s\"\n", s.BaseURL, InitSegmentName))
+	}
+
+	if s.KeyTag != "" {
+		builder.WriteString(s.KeyTag + "\n")
+	}
+
 	builder.WriteString("\n")
 
 	// 获取已完成分片的索引并排序
@@ -160,7 +202,7 @@ func (s *ProgressiveHLSState) GenerateM3U8() string {
 			duration = s.SegmentDuration // 使用默认时长
 		}
 
-		segmentName := fmt.Sprintf("segment_%03d.ts", idx)
+		segmentName := fmt.Sprintf("segment_%03d%s", idx, s.segmentExt())
 		builder.WriteString(fmt.Sprintf("#EXTINF:%.6f,\n", duration))
 		builder.WriteString(fmt.Sprintf("%s%s\n", s.BaseURL, segmentName))
 	}
@@ -173,13 +215,21 @@ func (s *ProgressiveHLSState) GenerateM3U8() string {
 	return builder.String()
 }
 
+// segmentExt 返回分片扩展名
+func (s *ProgressiveHLSState) segmentExt() string {
+	if s.SegmentExt == "" {
+		return ".ts"
+	}
+	return s.SegmentExt
+}
+
 // ScanAndUpdateSegments 扫描 temp 目录并更新分片状态
 func (s *ProgressiveHLSState) ScanAndUpdateSegments() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// 扫描 .ts 文件
-	pattern := filepath.Join(s.TempDir, "segment_*.ts")
+	pattern := filepath.Join(s.TempDir, "segment_*"+s.segmentExt())
 	files, err := filepath.Glob(pattern)
 	if err != nil {
 		return err
@@ -190,7 +240,7 @@ func (s *ProgressiveHLSState) ScanAndUpdateSegments() error {
 		baseName := filepath.Base(file)
 		// segment_000.ts -> 000
 		indexStr := strings.TrimPrefix(baseName, "segment_")
-		indexStr = strings.TrimSuffix(indexStr, ".ts")
+		indexStr = strings.TrimSuffix(indexStr, s.segmentExt())
 
 		idx, err := strconv.Atoi(indexStr)
 		if err != nil {
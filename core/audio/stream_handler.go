@@ -3,6 +3,7 @@ package audio
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -11,6 +12,7 @@ import (
 	// "strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"Bt1QFM/cache"
@@ -18,7 +20,6 @@ import (
 	"Bt1QFM/logger"
 	"Bt1QFM/storage"
 
-	"github.com/minio/minio-go/v7"
 )
 
 // StreamProcessor 流处理器
@@ -29,6 +30,26 @@ type StreamProcessor struct {
 	processingMu      sync.RWMutex
 	processing        map[string]*ProcessingState
 	usePipeline       bool // 是否启用流水线处理模式
+
+	// objectCache 按对象路径缓存已完成的播放列表和分片
+	objectCache *cache.ObjectCache
+	tempHits    uint64
+	redisHits   uint64
+	storageHits uint64
+	notFound    uint64
+}
+
+// streamCacheTTL 播放列表和分片在内存、Redis 中的缓存时间
+const streamCacheTTL = 30 * time.Minute
+
+// StreamCacheStats 流分片各级缓存的命中统计
+type StreamCacheStats struct {
+	Memory       cache.ObjectCacheStats `json:"memory"`
+	RedisEnabled bool                   `json:"redisEnabled"`
+	TempHits     uint64                 `json:"tempHits"`
+	RedisHits    uint64                 `json:"redisHits"`
+	StorageHits  uint64                 `json:"storageHits"`
+	NotFound     uint64                 `json:"notFound"`
 }
 
 // ProcessingState 处理状态
@@ -54,6 +75,7 @@ func NewStreamProcessor(mp3Processor *MP3Processor, cfg *config.Config) *StreamP
 		cfg:               cfg,
 		processing:        make(map[string]*ProcessingState),
 		usePipeline:       true, // 默认启用流水线模式
+		objectCache:       cache.NewObjectCache(int64(cfg.StreamCacheMaxMB) << 20),
 	}
 }
 
@@ -64,10 +86,16 @@ func (sp *StreamProcessor) SetPipelineMode(enabled bool) {
 
 // StreamProcess 处理音频文件，分四个阶段：FFmpeg分片 -> temp存储 -> Redis缓存 -> MinIO持久化
 func (sp *StreamProcessor) StreamProcess(ctx context.Context, streamID, inputPath string, isNetease bool) error {
+	return sp.StreamProcessWithOptions(ctx, streamID, inputPath, isNetease, nil)
+}
+
+// StreamProcessWithOptions 异步处理音频文件，并应用用户的转码选项（静音裁剪、淡入淡出）
+func (sp *StreamProcessor) StreamProcessWithOptions(ctx context.Context, streamID, inputPath string, isNetease bool, opts *TranscodeOptions) error {
 	logger.Info("开始流处理",
 		logger.String("streamId", streamID),
 		logger.String("inputPath", inputPath),
-		logger.Bool("isNetease", isNetease))
+		logger.Bool("isNetease", isNetease),
+		logger.String("transcodeKey", opts.Key()))
 
 	// 检查是否已在处理中
 	sp.processingMu.Lock()
@@ -97,7 +125,7 @@ func (sp *StreamProcessor) StreamProcess(ctx context.Context, streamID, inputPat
 			sp.processingMu.Unlock()
 		}()
 
-		if err := sp.processStream(ctx, streamID, inputPath, tempDir, isNetease); err != nil {
+		if err := sp.processStream(ctx, streamID, inputPath, tempDir, isNetease, opts); err != nil {
 			logger.Error("流处理失败",
 				logger.String("streamId", streamID),
 				logger.ErrorField(err))
@@ -115,10 +143,16 @@ func (sp *StreamProcessor) StreamProcess(ctx context.Context, streamID, inputPat
 
 // StreamProcessSync 同步处理音频文件，等待处理完成后返回
 func (sp *StreamProcessor) StreamProcessSync(ctx context.Context, streamID, inputPath string, isNetease bool) error {
+	return sp.StreamProcessSyncWithOptions(ctx, streamID, inputPath, isNetease, nil)
+}
+
+// StreamProcessSyncWithOptions 同步处理音频文件并应用转码选项，等待处理完成后返回
+func (sp *StreamProcessor) StreamProcessSyncWithOptions(ctx context.Context, streamID, inputPath string, isNetease bool, opts *TranscodeOptions) error {
 	logger.Info("开始同步流处理",
 		logger.String("streamId", streamID),
 		logger.String("inputPath", inputPath),
-		logger.Bool("isNetease", isNetease))
+		logger.Bool("isNetease", isNetease),
+		logger.String("transcodeKey", opts.Key()))
 
 	// 检查是否已在处理中
 	sp.processingMu.Lock()
@@ -147,7 +181,7 @@ func (sp *StreamProcessor) StreamProcessSync(ctx context.Context, streamID, inpu
 		sp.processingMu.Unlock()
 	}()
 
-	if err := sp.processStream(ctx, streamID, inputPath, tempDir, isNetease); err != nil {
+	if err := sp.processStream(ctx, streamID, inputPath, tempDir, isNetease, opts); err != nil {
 		logger.Error("同步流处理失败",
 			logger.String("streamId", streamID),
 			logger.ErrorField(err))
@@ -165,7 +199,7 @@ func (sp *StreamProcessor) StreamProcessSync(ctx context.Context, streamID, inpu
 }
 
 // processStream 执行实际的流处理
-func (sp *StreamProcessor) processStream(ctx context.Context, streamID, inputPath, tempDir string, isNetease bool) error {
+func (sp *StreamProcessor) processStream(ctx context.Context, streamID, inputPath, tempDir string, isNetease bool, opts *TranscodeOptions) error {
 	// 验证输入文件是否存在
 	if fileInfo, err := os.Stat(inputPath); err != nil {
 		if os.IsNotExist(err) {
@@ -181,6 +215,14 @@ func (sp *StreamProcessor) processStream(ctx context.Context, streamID, inputPat
 			logger.Int64("fileSize", fileInfo.Size()))
 	}
 
+	// 开启 HLS 加密时按流 ID 派生密钥，密钥接口可随时重新派生
+	if sp.cfg.HLSEncryption {
+		opts = opts.WithEncryption(NewHLSEncryption(streamID))
+	}
+
+	// 重新处理同一个流时丢弃旧的内存缓存
+	sp.objectCache.InvalidatePrefix(streamObjectPrefix(streamID, isNetease))
+
 	// 设置定时清理（900秒后）
 	go func() {
 		time.Sleep(900 * time.Second)
@@ -189,18 +231,76 @@ func (sp *StreamProcessor) processStream(ctx context.Context, streamID, inputPat
 	}()
 
 	// 根据配置选择处理模式
+	var err error
 	if sp.usePipeline {
-		return sp.processStreamPipeline(ctx, streamID, inputPath, tempDir, isNetease)
+		err = sp.processStreamPipeline(ctx, streamID, inputPath, tempDir, isNetease, opts)
+	} else {
+		err = sp.processStreamLegacy(ctx, streamID, inputPath, tempDir, isNetease, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 生成波形数据（网易云歌曲为即时播放，跳过以减少延迟）
+	if !isNetease {
+		if err := sp.generateWaveform(ctx, streamID, inputPath, tempDir, opts); err != nil {
+			logger.Warn("生成波形数据失败",
+				logger.String("streamId", streamID),
+				logger.ErrorField(err))
+		}
 	}
-	return sp.processStreamLegacy(ctx, streamID, inputPath, tempDir, isNetease)
+
+	return nil
+}
+
+// generateWaveform 生成波形峰值 JSON，写入temp目录并上传到MinIO，与HLS输出存放在一起
+func (sp *StreamProcessor) generateWaveform(ctx context.Context, streamID, inputPath, tempDir string, opts *TranscodeOptions) error {
+	// 淡出滤镜需要源文件时长，无需处理时不必探测
+	var sourceDuration float64
+	if !opts.IsZero() {
+		if d, err := sp.mp3Processor.GetAudioDuration(inputPath); err == nil {
+			sourceDuration = float64(d)
+		}
+	}
+	waveform, err := GenerateWaveform(ctx, sp.mp3Processor.GetFFmpegPath(), inputPath, DefaultWaveformBuckets, opts, sourceDuration)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(waveform)
+	if err != nil {
+		return fmt.Errorf("序列化波形数据失败: %w", err)
+	}
+
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, WaveformFileName), data, 0644); err != nil {
+		return fmt.Errorf("写入波形文件失败: %w", err)
+	}
+
+	store := storage.GetStorage()
+	if store == nil {
+		return fmt.Errorf("对象存储未初始化")
+	}
+	minioPath := fmt.Sprintf("streams/%s/%s", streamID, WaveformFileName)
+	if err := store.Put(context.Background(), minioPath, bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+		return fmt.Errorf("上传波形文件到对象存储失败: %w", err)
+	}
+
+	logger.Info("波形数据已生成",
+		logger.String("streamId", streamID),
+		logger.Int("buckets", waveform.Buckets),
+		logger.Float64("duration", waveform.Duration))
+	return nil
 }
 
 // processStreamPipeline 使用流水线模式处理（边转码边上传）
-func (sp *StreamProcessor) processStreamPipeline(ctx context.Context, streamID, inputPath, tempDir string, isNetease bool) error {
+func (sp *StreamProcessor) processStreamPipeline(ctx context.Context, streamID, inputPath, tempDir string, isNetease bool, opts *TranscodeOptions) error {
 	logger.Info("使用流水线模式处理",
 		logger.String("streamId", streamID))
 
-	result, err := sp.pipelineProcessor.ProcessWithPipeline(ctx, streamID, inputPath, tempDir, isNetease)
+	result, err := sp.pipelineProcessor.ProcessWithPipeline(ctx, streamID, inputPath, tempDir, isNetease, opts)
 	if err != nil {
 		return fmt.Errorf("流水线处理失败: %w", err)
 	}
@@ -222,7 +322,7 @@ func (sp *StreamProcessor) processStreamPipeline(ctx context.Context, streamID,
 }
 
 // processStreamLegacy 使用传统模式处理（先转码完成再上传）
-func (sp *StreamProcessor) processStreamLegacy(ctx context.Context, streamID, inputPath, tempDir string, isNetease bool) error {
+func (sp *StreamProcessor) processStreamLegacy(ctx context.Context, streamID, inputPath, tempDir string, isNetease bool, opts *TranscodeOptions) error {
 	logger.Info("使用传统模式处理",
 		logger.String("streamId", streamID))
 
@@ -233,7 +333,7 @@ func (sp *StreamProcessor) processStreamLegacy(ctx context.Context, streamID, in
 
 	// 设置HLS输出路径
 	outputM3U8 := filepath.Join(tempDir, "playlist.m3u8")
-	segmentPattern := filepath.Join(tempDir, "segment_%03d.ts")
+	segmentPattern := filepath.Join(tempDir, "segment_%03d"+opts.SegmentExt())
 
 	var hlsBaseURL string
 	if isNetease {
@@ -253,7 +353,7 @@ func (sp *StreamProcessor) processStreamLegacy(ctx context.Context, streamID, in
 		return fmt.Errorf("FFmpeg处理前文件丢失 %s: %w", inputPath, err)
 	}
 
-	duration, err := sp.mp3Processor.ProcessToHLS(inputPath, outputM3U8, segmentPattern, hlsBaseURL, "192k", "4")
+	duration, err := sp.mp3Processor.ProcessToHLSWithOptions(inputPath, outputM3U8, segmentPattern, hlsBaseURL, opts.AACBitrate(), opts.HLSSegmentTime(), opts)
 	if err != nil {
 		return fmt.Errorf("FFmpeg处理失败: %w", err)
 	}
@@ -297,7 +397,7 @@ func (sp *StreamProcessor) processStreamLegacy(ctx context.Context, streamID, in
 		logger.Info("开始异步上传到MinIO",
 			logger.String("streamId", streamID))
 
-		if err := sp.uploadToMinIO(streamID, tempDir, isNetease); err != nil {
+		if err := sp.uploadToStorage(streamID, tempDir, isNetease); err != nil {
 			logger.Warn("异步上传到MinIO失败",
 				logger.String("streamId", streamID),
 				logger.ErrorField(err))
@@ -333,8 +433,8 @@ func (sp *StreamProcessor) storeSegmentsToRedis(streamID, tempDir string, isNete
 	}
 	segments[fmt.Sprintf("segment:%s:playlist.m3u8", streamID)] = m3u8Data
 
-	// 收集所有 .ts 分片文件
-	segmentFiles, err := filepath.Glob(filepath.Join(tempDir, "*.ts"))
+	// 收集所有分片文件
+	segmentFiles, err := listSegmentFiles(tempDir)
 	if err != nil {
 		return fmt.Errorf("查找分片文件失败: %w", err)
 	}
@@ -365,11 +465,11 @@ func (sp *StreamProcessor) storeSegmentsToRedis(streamID, tempDir string, isNete
 	return nil
 }
 
-// uploadToMinIO 上传到MinIO
-func (sp *StreamProcessor) uploadToMinIO(streamID, tempDir string, isNetease bool) error {
-	client := storage.GetMinioClient()
-	if client == nil {
-		return fmt.Errorf("MinIO客户端未初始化")
+// uploadToStorage 上传到对象存储
+func (sp *StreamProcessor) uploadToStorage(streamID, tempDir string, isNetease bool) error {
+	store := storage.GetStorage()
+	if store == nil {
+		return fmt.Errorf("对象存储未初始化")
 	}
 
 	var minioBasePath string
@@ -397,97 +497,116 @@ func (sp *StreamProcessor) uploadToMinIO(streamID, tempDir string, isNetease boo
 		}
 		defer file.Close()
 
-		var contentType string
-		if strings.HasSuffix(path, ".m3u8") {
-			contentType = "application/vnd.apple.mpegurl"
-		} else if strings.HasSuffix(path, ".ts") {
-			contentType = "video/MP2T"
-		} else {
-			contentType = "application/octet-stream"
-		}
-
-		opts := minio.PutObjectOptions{
-			ContentType:      contentType,
-			DisableMultipart: true,
-		}
-
-		_, err = client.PutObject(context.Background(), sp.cfg.MinioBucket, minioPath, file, info.Size(), opts)
-		return err
+		return store.Put(context.Background(), minioPath, file, info.Size(), sp.getContentType(path))
 	})
 }
 
-// StreamGet 获取音频分片，优化缓存策略：temp -> Redis -> MinIO
+// StreamGet 获取音频分片，优化缓存策略：temp -> 内存 -> Redis -> MinIO
 func (sp *StreamProcessor) StreamGet(streamID, fileName string, isNetease bool) ([]byte, string, error) {
 	logger.Debug("获取流分片",
 		logger.String("streamId", streamID),
 		logger.String("fileName", fileName),
 		logger.Bool("isNetease", isNetease))
 
-	// 第一级：从temp目录获取（最快，优先级最高）
+	// 第一级：从temp目录获取（最快，优先级最高，处理中的流只存在于这里）
 	tempPath := filepath.Join(sp.cfg.StaticDir, "temp", "streams", streamID, fileName)
 	if data, contentType, err := sp.getFromTemp(tempPath, fileName); err == nil {
+		atomic.AddUint64(&sp.tempHits, 1)
 		logger.Debug("从temp获取成功，立即返回",
 			logger.String("streamId", streamID),
 			logger.String("fileName", fileName))
 		return data, contentType, nil
 	}
 
-	logger.Debug("temp目录未找到文件，尝试从缓存获取",
-		logger.String("streamId", streamID),
-		logger.String("fileName", fileName),
-		logger.String("tempPath", tempPath))
+	// 第二级起由内存缓存按对象路径合并并发回源：内存 -> Redis -> MinIO
+	objectPath := streamObjectPrefix(streamID, isNetease) + fileName
+	data, err := sp.objectCache.Get(objectPath, func() ([]byte, time.Duration, error) {
+		return sp.loadStreamObject(streamID, fileName, objectPath)
+	})
+	if err != nil {
+		atomic.AddUint64(&sp.notFound, 1)
+		logger.Warn("所有存储层都未找到分片文件",
+			logger.String("streamId", streamID),
+			logger.String("fileName", fileName),
+			logger.Bool("isNetease", isNetease),
+			logger.ErrorField(err))
+		return nil, "", fmt.Errorf("未找到分片文件: %s", fileName)
+	}
+	return data, sp.getContentType(fileName), nil
+}
 
-	// 第二级：从Redis缓存获取
+// loadStreamObject 内存缓存未命中时依次从 Redis 和对象存储加载，返回写入内存缓存的时长
+func (sp *StreamProcessor) loadStreamObject(streamID, fileName, objectPath string) ([]byte, time.Duration, error) {
 	cacheKey := fmt.Sprintf("segment:%s:%s", streamID, fileName)
-	if data, err := cache.GetSegmentCache(cacheKey); err == nil && len(data) > 0 {
-		logger.Debug("从Redis缓存获取成功",
-			logger.String("streamId", streamID),
-			logger.String("fileName", fileName))
-		contentType := sp.getContentType(fileName)
-		return data, contentType, nil
+	if sp.cfg.StreamCacheRedis {
+		if data, err := cache.GetSegmentCache(cacheKey); err == nil && len(data) > 0 {
+			atomic.AddUint64(&sp.redisHits, 1)
+			logger.Debug("从Redis缓存获取成功",
+				logger.String("streamId", streamID),
+				logger.String("fileName", fileName))
+			return data, cacheableTTL(fileName, data), nil
+		}
 	}
 
-	// Redis未命中或获取失败，继续尝试从MinIO获取
-	logger.Debug("Redis缓存未命中，尝试从MinIO获取",
+	data, _, err := sp.getFromStorage(objectPath, fileName)
+	if err != nil {
+		return nil, 0, err
+	}
+	atomic.AddUint64(&sp.storageHits, 1)
+	logger.Debug("从对象存储获取成功",
 		logger.String("streamId", streamID),
 		logger.String("fileName", fileName))
 
-	// 第三级：从MinIO获取
-	var minioPath string
-	if isNetease {
-		minioPath = fmt.Sprintf("streams/netease/%s/%s", streamID, fileName)
-	} else {
-		minioPath = fmt.Sprintf("streams/%s/%s", streamID, fileName)
-	}
-
-	if data, contentType, err := sp.getFromMinIO(minioPath, fileName); err == nil {
-		logger.Debug("从MinIO获取成功",
-			logger.String("streamId", streamID),
-			logger.String("fileName", fileName))
-
-		// 异步回填到Redis缓存（仅在Redis可用时）
+	// 异步回填到Redis缓存（仅在Redis可用时）
+	if sp.cfg.StreamCacheRedis {
 		go func() {
-			if setErr := cache.SetSegmentCache(cacheKey, data, 1800*time.Second); setErr != nil {
+			if setErr := cache.SetSegmentCache(cacheKey, data, streamCacheTTL); setErr != nil {
 				logger.Warn("异步回填Redis缓存失败",
 					logger.String("streamId", streamID),
 					logger.String("fileName", fileName),
 					logger.ErrorField(setErr))
-			} else {
-				logger.Debug("异步回填Redis缓存成功",
-					logger.String("streamId", streamID),
-					logger.String("fileName", fileName))
 			}
 		}()
+	}
+	return data, cacheableTTL(fileName, data), nil
+}
 
-		return data, contentType, nil
+// cacheableTTL 未结束的播放列表仍会变化，不写入内存缓存
+func cacheableTTL(fileName string, data []byte) time.Duration {
+	if strings.HasSuffix(fileName, ".m3u8") && !bytes.Contains(data, []byte("#EXT-X-ENDLIST")) {
+		return 0
 	}
+	return streamCacheTTL
+}
 
-	logger.Warn("所有存储层都未找到分片文件",
-		logger.String("streamId", streamID),
-		logger.String("fileName", fileName),
-		logger.Bool("isNetease", isNetease))
+// streamObjectPrefix 流在对象存储中的路径前缀
+func streamObjectPrefix(streamID string, isNetease bool) string {
+	if isNetease {
+		return "streams/netease/" + streamID + "/"
+	}
+	return "streams/" + streamID + "/"
+}
 
-	return nil, "", fmt.Errorf("未找到分片文件: %s", fileName)
+// InvalidateStreamCache 清除流在内存和 Redis 中的缓存，流被删除或重新生成时调用
+func (sp *StreamProcessor) InvalidateStreamCache(streamID string) {
+	sp.objectCache.InvalidatePrefix(streamObjectPrefix(streamID, false))
+	if err := cache.DeleteSegmentPattern("segment:" + streamID + ":*"); err != nil {
+		logger.Warn("清理流分片缓存失败",
+			logger.String("streamId", streamID),
+			logger.ErrorField(err))
+	}
+}
+
+// CacheStats 返回流分片各级缓存的命中统计
+func (sp *StreamProcessor) CacheStats() StreamCacheStats {
+	return StreamCacheStats{
+		Memory:       sp.objectCache.Stats(),
+		RedisEnabled: sp.cfg.StreamCacheRedis,
+		TempHits:     atomic.LoadUint64(&sp.tempHits),
+		RedisHits:    atomic.LoadUint64(&sp.redisHits),
+		StorageHits:  atomic.LoadUint64(&sp.storageHits),
+		NotFound:     atomic.LoadUint64(&sp.notFound),
+	}
 }
 
 // getFromTemp 从temp目录获取文件
@@ -505,17 +624,17 @@ func (sp *StreamProcessor) getFromTemp(filePath, fileName string) ([]byte, strin
 	return data, contentType, nil
 }
 
-// getFromMinIO 从MinIO获取文件
-func (sp *StreamProcessor) getFromMinIO(objectPath, fileName string) ([]byte, string, error) {
-	client := storage.GetMinioClient()
-	if client == nil {
-		return nil, "", fmt.Errorf("MinIO客户端未初始化")
+// getFromStorage 从对象存储获取文件
+func (sp *StreamProcessor) getFromStorage(objectPath, fileName string) ([]byte, string, error) {
+	store := storage.GetStorage()
+	if store == nil {
+		return nil, "", fmt.Errorf("对象存储未初始化")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	object, err := client.GetObject(ctx, sp.cfg.MinioBucket, objectPath, minio.GetObjectOptions{})
+	object, err := store.Get(ctx, objectPath)
 	if err != nil {
 		return nil, "", err
 	}
@@ -536,10 +655,23 @@ func (sp *StreamProcessor) getContentType(fileName string) string {
 		return "application/vnd.apple.mpegurl"
 	} else if strings.HasSuffix(fileName, ".ts") {
 		return "video/MP2T"
+	} else if strings.HasSuffix(fileName, ".m4s") || strings.HasSuffix(fileName, ".mp4") {
+		return "audio/mp4"
+	} else if strings.HasSuffix(fileName, ".json") {
+		return "application/json"
 	}
 	return "application/octet-stream"
 }
 
+// IsStreamProcessing 流是否正在转码（上传后的本地歌曲等不经过 MP3Processor 处理锁的流）
+func (sp *StreamProcessor) IsStreamProcessing(streamID string) bool {
+	sp.processingMu.RLock()
+	defer sp.processingMu.RUnlock()
+
+	state, exists := sp.processing[streamID]
+	return exists && state.IsProcessing
+}
+
 // GetProcessingState 获取处理状态
 func (sp *StreamProcessor) GetProcessingState(streamID string) *ProcessingState {
 	sp.processingMu.RLock()
@@ -0,0 +1,85 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// AudioTags 从音频文件元数据中读取的标签，文件没有对应标签时为空
+type AudioTags struct {
+	Title    string
+	Artist   string
+	Album    string
+	Genre    string
+	Duration float32 // 秒，无法读取时为 0
+}
+
+// GetAudioTags 使用 ffprobe 读取音频文件的标签和时长
+// 容器级标签优先，Ogg/Opus 等格式的标签写在音频流上时使用流标签；标签名不区分大小写
+func (p *FFmpegProcessor) GetAudioTags(inputFile string) (*AudioTags, error) {
+	ffprobePath := ffprobePathFor(p.ffmpegPath)
+
+	args := []string{
+		"-v", "error",
+		"-show_entries", "format=duration:format_tags:stream_tags",
+		"-select_streams", "a:0",
+		"-of", "json",
+		inputFile,
+	}
+
+	cmd := exec.Command(ffprobePath, args...)
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffprobe execution failed for %s: %w\nFFprobe Error: %s", inputFile, err, stderr.String())
+	}
+
+	var probeData struct {
+		Format struct {
+			Duration string            `json:"duration"`
+			Tags     map[string]string `json:"tags"`
+		} `json:"format"`
+		Streams []struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &probeData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ffprobe output for %s: %w", inputFile, err)
+	}
+
+	tags := make(map[string]string)
+	for _, stream := range probeData.Streams {
+		mergeTags(tags, stream.Tags)
+	}
+	mergeTags(tags, probeData.Format.Tags)
+
+	result := &AudioTags{
+		Title:  tags["title"],
+		Artist: tags["artist"],
+		Album:  tags["album"],
+		Genre:  tags["genre"],
+	}
+	if result.Artist == "" {
+		result.Artist = tags["album_artist"]
+	}
+	if duration, err := strconv.ParseFloat(probeData.Format.Duration, 32); err == nil {
+		result.Duration = float32(duration)
+	}
+	return result, nil
+}
+
+// mergeTags 把 src 中的非空标签按小写标签名写入 dst，后写入的覆盖先写入的
+func mergeTags(dst, src map[string]string) {
+	for key, value := range src {
+		if value = strings.TrimSpace(value); value != "" {
+			dst[strings.ToLower(key)] = value
+		}
+	}
+}
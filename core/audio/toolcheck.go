@@ -0,0 +1,210 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+)
+
+// 转码依赖的 FFmpeg 组件
+var (
+	requiredMuxers   = []string{"hls"}
+	requiredEncoders = []string{"aac", "libmp3lame"}
+	// 可选输出格式使用的编码器，缺少时只影响对应格式
+	profileEncoders = map[string]string{ProfileFLAC: "flac", ProfileOpus: "libopus"}
+)
+
+// toolCheckTimeout 单条 FFmpeg 检查命令的最长时间
+const toolCheckTimeout = 10 * time.Second
+
+// ffmpegVersionPattern 匹配 "ffmpeg version 6.1.1-3ubuntu5" 和 "ffmpeg version n7.0" 等版本行
+var ffmpegVersionPattern = regexp.MustCompile(`^ffmpeg version n?(\d+)\.(\d+)`)
+
+// FFmpegInfo 启动检查得到的 FFmpeg 信息
+type FFmpegInfo struct {
+	FFmpegPath  string // 解析后的 ffmpeg 绝对路径
+	FFprobePath string // 解析后的 ffprobe 绝对路径
+	Version     string // 版本号，如 "6.1.1-3ubuntu5"；开发版为 "N-xxxxx-g..." 形式
+}
+
+var (
+	ffprobePathMu       sync.RWMutex
+	resolvedFFprobePath string
+)
+
+// ffprobePathFor 返回与 ffmpeg 配套的 ffprobe 路径，启动检查通过后使用检查得到的路径
+func ffprobePathFor(ffmpegPath string) string {
+	ffprobePathMu.RLock()
+	defer ffprobePathMu.RUnlock()
+	if resolvedFFprobePath != "" {
+		return resolvedFFprobePath
+	}
+	return strings.Replace(ffmpegPath, "ffmpeg", "ffprobe", 1)
+}
+
+// CheckFFmpeg 定位 ffmpeg/ffprobe 并检查版本和转码所需的 muxer、编码器
+// 返回的错误说明了缺少什么以及如何修复，调用方应在启动时直接退出
+func CheckFFmpeg(ctx context.Context, cfg *config.Config) (*FFmpegInfo, error) {
+	ffmpegPath, err := exec.LookPath(cfg.FFmpegPath)
+	if err != nil {
+		return nil, fmt.Errorf("未找到 ffmpeg（%s）：请安装 FFmpeg 并加入 PATH，或通过 FFMPEG_PATH 指定可执行文件路径: %w", cfg.FFmpegPath, err)
+	}
+
+	ffprobePath, err := locateFFprobe(cfg.FFprobePath, ffmpegPath)
+	if err != nil {
+		return nil, err
+	}
+
+	versionOut, err := runTool(ctx, ffmpegPath, "-hide_banner", "-version")
+	if err != nil {
+		return nil, fmt.Errorf("无法执行 ffmpeg（%s）：%w", ffmpegPath, err)
+	}
+	version, err := checkFFmpegVersion(versionOut, cfg.FFmpegMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := runTool(ctx, ffprobePath, "-hide_banner", "-version"); err != nil {
+		return nil, fmt.Errorf("无法执行 ffprobe（%s）：%w", ffprobePath, err)
+	}
+
+	muxersOut, err := runTool(ctx, ffmpegPath, "-hide_banner", "-muxers")
+	if err != nil {
+		return nil, fmt.Errorf("无法列出 ffmpeg 支持的 muxer：%w", err)
+	}
+	encodersOut, err := runTool(ctx, ffmpegPath, "-hide_banner", "-encoders")
+	if err != nil {
+		return nil, fmt.Errorf("无法列出 ffmpeg 支持的编码器：%w", err)
+	}
+	var missing []string
+	muxers, encoders := listedNames(muxersOut), listedNames(encodersOut)
+	for _, name := range requiredMuxers {
+		if !muxers[name] {
+			missing = append(missing, "muxer "+name)
+		}
+	}
+	for _, name := range requiredEncoders {
+		if !encoders[name] {
+			missing = append(missing, "encoder "+name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("ffmpeg（%s）缺少转码所需的组件：%s；请安装完整版 FFmpeg（需启用 --enable-libmp3lame）",
+			ffmpegPath, strings.Join(missing, ", "))
+	}
+	for profile, encoder := range profileEncoders {
+		if !encoders[encoder] {
+			logger.Warn("ffmpeg 缺少可选编码器，该输出格式的转码会失败",
+				logger.String("profile", profile),
+				logger.String("encoder", encoder))
+		}
+	}
+
+	ffprobePathMu.Lock()
+	resolvedFFprobePath = ffprobePath
+	ffprobePathMu.Unlock()
+
+	return &FFmpegInfo{FFmpegPath: ffmpegPath, FFprobePath: ffprobePath, Version: version}, nil
+}
+
+// locateFFprobe 优先使用 FFPROBE_PATH，其次是 ffmpeg 同目录下的 ffprobe，最后在 PATH 中查找
+func locateFFprobe(override, ffmpegPath string) (string, error) {
+	if override != "" {
+		path, err := exec.LookPath(override)
+		if err != nil {
+			return "", fmt.Errorf("FFPROBE_PATH 指定的 ffprobe（%s）不可用: %w", override, err)
+		}
+		return path, nil
+	}
+	sibling := filepath.Join(filepath.Dir(ffmpegPath), strings.Replace(filepath.Base(ffmpegPath), "ffmpeg", "ffprobe", 1))
+	if path, err := exec.LookPath(sibling); err == nil {
+		return path, nil
+	}
+	path, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return "", fmt.Errorf("未找到 ffprobe：请安装与 ffmpeg 配套的 ffprobe，或通过 FFPROBE_PATH 指定可执行文件路径: %w", err)
+	}
+	return path, nil
+}
+
+// checkFFmpegVersion 解析 -version 输出并与最低版本比较
+// 开发版（如 "N-113000-g..."）无法比较版本号，视为满足要求
+func checkFFmpegVersion(output, minVersion string) (string, error) {
+	firstLine, _, _ := strings.Cut(output, "\n")
+	version := strings.TrimPrefix(firstLine, "ffmpeg version ")
+	if i := strings.IndexByte(version, ' '); i >= 0 {
+		version = version[:i]
+	}
+	if minVersion == "" {
+		return version, nil
+	}
+
+	m := ffmpegVersionPattern.FindStringSubmatch(firstLine)
+	if m == nil {
+		return version, nil
+	}
+	minMajor, minMinor, err := parseMajorMinor(minVersion)
+	if err != nil {
+		return "", fmt.Errorf("FFMPEG_MIN_VERSION 格式无效（%s），应为 主版本.次版本，如 4.0", minVersion)
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	if major < minMajor || (major == minMajor && minor < minMinor) {
+		return "", fmt.Errorf("ffmpeg 版本 %s 低于要求的最低版本 %s，请升级 FFmpeg", version, minVersion)
+	}
+	return version, nil
+}
+
+// parseMajorMinor 解析 "4.0" 形式的版本号
+func parseMajorMinor(version string) (int, int, error) {
+	majorStr, minorStr, _ := strings.Cut(version, ".")
+	major, err := strconv.Atoi(majorStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if minorStr == "" {
+		return major, 0, nil
+	}
+	minorStr, _, _ = strings.Cut(minorStr, ".")
+	minor, err := strconv.Atoi(minorStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	return major, minor, nil
+}
+
+// listedNames 解析 -muxers/-encoders 的输出，每行第二列为名称
+func listedNames(output string) map[string]bool {
+	names := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			names[fields[1]] = true
+		}
+	}
+	return names
+}
+
+// runTool 执行检查命令并返回标准输出
+func runTool(ctx context.Context, path string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, toolCheckTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
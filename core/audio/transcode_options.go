@@ -0,0 +1,221 @@
+package audio
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// silenceThreshold 静音判定阈值
+	silenceThreshold = "-50dB"
+	// silenceStopDuration 持续超过该时长（秒）的静音才会被去除，避免裁掉歌曲中的短暂停顿
+	silenceStopDuration = "2"
+)
+
+// HLS 输出格式
+const (
+	ProfileAAC  = "aac"  // 默认：AAC 192k，MPEG-TS 分片
+	ProfileFLAC = "flac" // 无损模式：FLAC，fMP4 分片
+	ProfileOpus = "opus" // 省流量模式：Opus 64k，fMP4 分片
+)
+
+const (
+	// opusBitrate 省流量模式的 Opus 码率
+	opusBitrate = "64k"
+	// InitSegmentName fMP4 输出的初始化分片文件名
+	InitSegmentName = "init.mp4"
+	// defaultAACBitrate 默认格式的 AAC 码率
+	defaultAACBitrate = "192k"
+	// defaultSegmentTime 默认的分片时长（秒）
+	defaultSegmentTime = "4"
+)
+
+// IsValidProfile 是否为支持的输出格式，空字符串表示默认格式
+func IsValidProfile(profile string) bool {
+	switch profile {
+	case "", ProfileAAC, ProfileFLAC, ProfileOpus:
+		return true
+	}
+	return false
+}
+
+// TranscodeOptions 转码时的可选音频处理（来自用户偏好）
+type TranscodeOptions struct {
+	TrimSilence      bool    // 去除首尾静音
+	CrossfadeSeconds float64 // 首尾淡入淡出时长（秒），0 表示不启用
+	Profile          string  // 输出格式，为空时使用 ProfileAAC
+	// Bitrate 和 SegmentTime 来自运维配置，为空时使用 192k 和 4 秒；与默认值不同时参与 Key 的计算
+	Bitrate     string // 默认格式的 AAC 码率
+	SegmentTime string // 分片时长（秒）
+	// Encryption 分片加密参数，只影响输出格式，不参与 IsZero 和 Key 的判断
+	Encryption *HLSEncryption
+}
+
+// WithEncoding 返回附加了码率和分片时长的副本，o 为 nil 时同样可用
+func (o *TranscodeOptions) WithEncoding(bitrate, segmentTime string) *TranscodeOptions {
+	var c TranscodeOptions
+	if o != nil {
+		c = *o
+	}
+	c.Bitrate = bitrate
+	c.SegmentTime = segmentTime
+	return &c
+}
+
+// AACBitrate 返回默认格式的 AAC 码率，未设置时为 192k
+func (o *TranscodeOptions) AACBitrate() string {
+	if o == nil || o.Bitrate == "" {
+		return defaultAACBitrate
+	}
+	return o.Bitrate
+}
+
+// HLSSegmentTime 返回分片时长（秒），未设置时为 4
+func (o *TranscodeOptions) HLSSegmentTime() string {
+	if o == nil || o.SegmentTime == "" {
+		return defaultSegmentTime
+	}
+	return o.SegmentTime
+}
+
+// WithEncryption 返回附加了分片加密参数的副本，o 为 nil 时同样可用
+func (o *TranscodeOptions) WithEncryption(e *HLSEncryption) *TranscodeOptions {
+	var c TranscodeOptions
+	if o != nil {
+		c = *o
+	}
+	c.Encryption = e
+	return &c
+}
+
+// WithProfile 返回使用指定输出格式的副本，o 为 nil 时同样可用
+func (o *TranscodeOptions) WithProfile(profile string) *TranscodeOptions {
+	var c TranscodeOptions
+	if o != nil {
+		c = *o
+	}
+	c.Profile = profile
+	return &c
+}
+
+// profile 返回输出格式，未设置时为 ProfileAAC
+func (o *TranscodeOptions) profile() string {
+	if o == nil || o.Profile == "" {
+		return ProfileAAC
+	}
+	return o.Profile
+}
+
+// IsFMP4 输出是否使用 fMP4 分片（FLAC 和 Opus 无法封装进 MPEG-TS）
+func (o *TranscodeOptions) IsFMP4() bool {
+	return o.profile() != ProfileAAC
+}
+
+// SegmentExt 返回媒体分片的扩展名
+func (o *TranscodeOptions) SegmentExt() string {
+	if o.IsFMP4() {
+		return ".m4s"
+	}
+	return ".ts"
+}
+
+// codecArgs 返回输出格式对应的 FFmpeg 编码参数
+func (o *TranscodeOptions) codecArgs() []string {
+	switch o.profile() {
+	case ProfileFLAC:
+		// 保留源采样率；旧版 FFmpeg 中 MP4 封装 FLAC 仍标记为实验性
+		return []string{"-c:a", "flac", "-strict", "experimental"}
+	case ProfileOpus:
+		// Opus 只支持 48kHz 等固定采样率
+		return []string{"-c:a", "libopus", "-b:a", opusBitrate, "-ar", "48000"}
+	default:
+		return []string{"-c:a", "aac", "-b:a", o.AACBitrate()}
+	}
+}
+
+// segmentArgs 返回分片封装参数，fMP4 输出时额外生成初始化分片
+func (o *TranscodeOptions) segmentArgs() []string {
+	if !o.IsFMP4() {
+		return nil
+	}
+	return []string{"-hls_segment_type", "fmp4", "-hls_fmp4_init_filename", InitSegmentName}
+}
+
+// encryption 返回分片加密参数，未加密时返回 nil
+func (o *TranscodeOptions) encryption() *HLSEncryption {
+	if o == nil {
+		return nil
+	}
+	return o.Encryption
+}
+
+// IsZero 是否没有任何需要处理的音频选项
+func (o *TranscodeOptions) IsZero() bool {
+	return o == nil || (!o.TrimSilence && o.CrossfadeSeconds <= 0)
+}
+
+// AudioFilter 构建 FFmpeg -af 滤镜链，无需处理时返回空字符串
+// 滤镜均为流式处理，不会把整首歌缓存在内存中：尾部静音由 silenceremove 的 stop_periods 去除，
+// 淡出按源文件时长 sourceDuration（秒）定位，在裁剪静音之前应用；时长未知（<=0）时不淡出
+func (o *TranscodeOptions) AudioFilter(sourceDuration float64) string {
+	if o.IsZero() {
+		return ""
+	}
+
+	var filters []string
+	if o.CrossfadeSeconds > 0 && sourceDuration > o.CrossfadeSeconds {
+		filters = append(filters, fmt.Sprintf("afade=t=out:st=%.2f:d=%.2f", sourceDuration-o.CrossfadeSeconds, o.CrossfadeSeconds))
+	}
+	if o.TrimSilence {
+		// stop_periods=-1 去除所有超过 silenceStopDuration 的静音段，包括结尾的静音
+		filters = append(filters, fmt.Sprintf(
+			"silenceremove=start_periods=1:start_duration=0.1:start_threshold=%s:stop_periods=-1:stop_duration=%s:stop_threshold=%s",
+			silenceThreshold, silenceStopDuration, silenceThreshold))
+	}
+	if o.CrossfadeSeconds > 0 {
+		filters = append(filters, fmt.Sprintf("afade=t=in:d=%.2f", o.CrossfadeSeconds))
+	}
+	return strings.Join(filters, ",")
+}
+
+// Key 返回选项的简短标识，用于区分同一源文件按不同选项生成的流，默认格式且无需处理时返回空字符串
+func (o *TranscodeOptions) Key() string {
+	var parts []string
+	if !o.IsZero() {
+		if o.TrimSilence {
+			parts = append(parts, "trim")
+		}
+		if o.CrossfadeSeconds > 0 {
+			parts = append(parts, fmt.Sprintf("xf%.2f", o.CrossfadeSeconds))
+		}
+	}
+	if profile := o.profile(); profile != ProfileAAC {
+		parts = append(parts, profile)
+	} else if bitrate := o.AACBitrate(); bitrate != defaultAACBitrate {
+		parts = append(parts, bitrate)
+	}
+	if segmentTime := o.HLSSegmentTime(); segmentTime != defaultSegmentTime {
+		parts = append(parts, "seg"+segmentTime)
+	}
+	return strings.Join(parts, "_")
+}
+
+// isSegmentFile 是否为需要上传的分片文件（MPEG-TS/fMP4 媒体分片或 fMP4 初始化分片）
+func isSegmentFile(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".ts" || ext == ".m4s" || filepath.Base(name) == InitSegmentName
+}
+
+// listSegmentFiles 列出目录中的所有分片文件
+func listSegmentFiles(dir string) ([]string, error) {
+	var files []string
+	for _, pattern := range []string{"*.ts", "*.m4s", InitSegmentName} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
@@ -0,0 +1,254 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+)
+
+// ErrTranscodeBusy 转码池没有空闲槽位，TryTranscodeCommand 不排队时返回
+var ErrTranscodeBusy = errors.New("transcode pool is saturated")
+
+// ionice 调度类别
+var ioniceClasses = map[string]string{
+	"best-effort": "2",
+	"idle":        "3",
+}
+
+// TranscodeLimits 转码任务的并发和资源限制
+type TranscodeLimits struct {
+	Concurrency int    // 同时运行的转码任务数，<=0 表示 CPU 核数
+	Threads     int    // 每个 FFmpeg 任务的线程数，0 表示由 FFmpeg 自动决定
+	Nice        int    // 转码进程的 nice 值（1-19），0 表示不调整
+	IONiceClass string // ionice 调度类别：best-effort 或 idle，为空表示不调整
+	CPUSet      string // 绑定的 CPU 列表（taskset 格式，如 "2-5"），为空表示不绑定
+}
+
+// TranscodeStats 转码池的运行状态
+type TranscodeStats struct {
+	Concurrency int     `json:"concurrency"`
+	Running     int64   `json:"running"`
+	Waiting     int64   `json:"waiting"`
+	Completed   int64   `json:"completed"`
+	Failed      int64   `json:"failed"`      // 转码命令以错误结束的次数（不含取消）
+	FailureRate float64 `json:"failureRate"` // Failed / Completed
+}
+
+// transcodePool 限制同时运行的 FFmpeg 转码进程数，并按配置降低其 CPU/IO 优先级
+// 所有处理器共享，超出并发数的任务排队等待，而不是各自启动 FFmpeg
+type transcodePool struct {
+	mu      sync.RWMutex
+	limits  TranscodeLimits
+	slots   chan struct{}
+	wrapper []string // 放在 FFmpeg 命令前的 taskset/ionice/nice 前缀
+
+	running   atomic.Int64
+	waiting   atomic.Int64
+	completed atomic.Int64
+	failed    atomic.Int64
+}
+
+// sharedTranscodePool 进程内共享的转码池，默认并发数为 CPU 核数
+var sharedTranscodePool = newTranscodePool(TranscodeLimits{})
+
+func newTranscodePool(limits TranscodeLimits) *transcodePool {
+	p := &transcodePool{}
+	p.configure(limits)
+	return p
+}
+
+// ConfigureTranscoding 根据配置设置转码并发数和资源限制，应在开始转码前调用
+func ConfigureTranscoding(cfg *config.Config) {
+	SetTranscodeLimits(TranscodeLimits{
+		Concurrency: cfg.TranscodeConcurrency,
+		Threads:     cfg.TranscodeThreads,
+		Nice:        cfg.TranscodeNice,
+		IONiceClass: cfg.TranscodeIONiceClass,
+		CPUSet:      cfg.TranscodeCPUSet,
+	})
+}
+
+// SetTranscodeLimits 设置共享转码池的限制
+func SetTranscodeLimits(limits TranscodeLimits) {
+	sharedTranscodePool.configure(limits)
+
+	sharedTranscodePool.mu.RLock()
+	defer sharedTranscodePool.mu.RUnlock()
+	logger.Info("转码池已配置",
+		logger.Int("concurrency", cap(sharedTranscodePool.slots)),
+		logger.Int("threads", limits.Threads),
+		logger.String("wrapper", strings.Join(sharedTranscodePool.wrapper, " ")))
+}
+
+// GetTranscodeStats 返回转码池的运行状态
+func GetTranscodeStats() TranscodeStats {
+	p := sharedTranscodePool
+	p.mu.RLock()
+	concurrency := cap(p.slots)
+	p.mu.RUnlock()
+	stats := TranscodeStats{
+		Concurrency: concurrency,
+		Running:     p.running.Load(),
+		Waiting:     p.waiting.Load(),
+		Completed:   p.completed.Load(),
+		Failed:      p.failed.Load(),
+	}
+	if stats.Completed > 0 {
+		stats.FailureRate = float64(stats.Failed) / float64(stats.Completed)
+	}
+	return stats
+}
+
+// configure 应用新的限制；已在运行的任务归还到旧的槽位，不受影响
+func (p *transcodePool) configure(limits TranscodeLimits) {
+	concurrency := limits.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	var wrapper []string
+	if limits.CPUSet != "" {
+		wrapper = appendWrapper(wrapper, "taskset", "-c", limits.CPUSet)
+	}
+	if class, ok := ioniceClasses[limits.IONiceClass]; ok {
+		wrapper = appendWrapper(wrapper, "ionice", "-c", class)
+	} else if limits.IONiceClass != "" {
+		logger.Warn("不支持的 ionice 调度类别，已忽略", logger.String("class", limits.IONiceClass))
+	}
+	if limits.Nice > 0 {
+		wrapper = appendWrapper(wrapper, "nice", "-n", strconv.Itoa(min(limits.Nice, 19)))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limits = limits
+	p.slots = make(chan struct{}, concurrency)
+	p.wrapper = wrapper
+}
+
+// appendWrapper 工具存在时追加命令前缀，不存在时（如非 Linux 系统）记录警告并跳过
+func appendWrapper(wrapper []string, tool string, args ...string) []string {
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		logger.Warn("未找到转码资源限制工具，已跳过", logger.String("tool", tool))
+		return wrapper
+	}
+	return append(append(wrapper, path), args...)
+}
+
+// acquire 等待一个转码槽位，返回释放函数
+func (p *transcodePool) acquire(ctx context.Context) (func(), error) {
+	p.mu.RLock()
+	slots := p.slots
+	p.mu.RUnlock()
+
+	select {
+	case slots <- struct{}{}:
+	default:
+		p.waiting.Add(1)
+		start := time.Now()
+		select {
+		case slots <- struct{}{}:
+			p.waiting.Add(-1)
+			logger.Debug("转码任务排队完成", logger.Duration("waited", time.Since(start)))
+		case <-ctx.Done():
+			p.waiting.Add(-1)
+			return nil, ctx.Err()
+		}
+	}
+
+	return p.hold(slots), nil
+}
+
+// tryAcquire 有空闲槽位时立即占用并返回释放函数，没有时返回 false，不排队
+func (p *transcodePool) tryAcquire() (func(), bool) {
+	p.mu.RLock()
+	slots := p.slots
+	p.mu.RUnlock()
+
+	select {
+	case slots <- struct{}{}:
+		return p.hold(slots), true
+	default:
+		return nil, false
+	}
+}
+
+// hold 记录已占用的槽位，返回只生效一次的释放函数
+func (p *transcodePool) hold(slots chan struct{}) func() {
+	p.running.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.running.Add(-1)
+			p.completed.Add(1)
+			<-slots
+		})
+	}
+}
+
+// observe 记录转码命令的结果并原样返回错误，用法为 p.observe(ctx, cmd.Run())
+// ctx 已取消导致的错误不计入失败
+func (p *transcodePool) observe(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() == nil {
+		p.failed.Add(1)
+	}
+	return err
+}
+
+// threads 返回 -threads 参数值
+func (p *transcodePool) threads() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return strconv.Itoa(max(p.limits.Threads, 0))
+}
+
+// command 创建 FFmpeg 命令，按配置加上 taskset/ionice/nice 前缀
+// 这些工具都会 exec 成目标进程，取消 ctx 时结束的仍是 FFmpeg 本身
+func (p *transcodePool) command(ctx context.Context, ffmpegPath string, args ...string) *exec.Cmd {
+	p.mu.RLock()
+	wrapper := p.wrapper
+	p.mu.RUnlock()
+
+	if len(wrapper) == 0 {
+		return exec.CommandContext(ctx, ffmpegPath, args...)
+	}
+	full := append(append(append([]string{}, wrapper[1:]...), ffmpegPath), args...)
+	return exec.CommandContext(ctx, wrapper[0], full...)
+}
+
+// TranscodeCommand 在共享转码池中创建实时转码命令，如直接输出到 HTTP 响应的转码
+// 返回的释放函数须在命令结束后调用；排队期间 ctx 取消时返回错误
+func TranscodeCommand(ctx context.Context, ffmpegPath string, args ...string) (*exec.Cmd, func(), error) {
+	release, err := sharedTranscodePool.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	args = append([]string{"-threads", sharedTranscodePool.threads()}, args...)
+	return sharedTranscodePool.command(ctx, ffmpegPath, args...), release, nil
+}
+
+// TryTranscodeCommand 与 TranscodeCommand 相同，但转码池已满时不排队，直接返回 ErrTranscodeBusy
+// 用于无法长时间等待首字节的客户端，由调用方返回 503 让其稍后重试
+func TryTranscodeCommand(ctx context.Context, ffmpegPath string, args ...string) (*exec.Cmd, func(), error) {
+	release, ok := sharedTranscodePool.tryAcquire()
+	if !ok {
+		return nil, nil, ErrTranscodeBusy
+	}
+	args = append([]string{"-threads", sharedTranscodePool.threads()}, args...)
+	return sharedTranscodePool.command(ctx, ffmpegPath, args...), release, nil
+}
+
+// ObserveTranscode 记录 TranscodeCommand 创建的命令的结果并原样返回错误
+func ObserveTranscode(ctx context.Context, err error) error {
+	return sharedTranscodePool.observe(ctx, err)
+}
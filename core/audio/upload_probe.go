@@ -0,0 +1,185 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// 上传音频未通过结构检查的原因
+const (
+	InvalidReasonCorrupt       = "corrupt"         // ffprobe 无法解析文件
+	InvalidReasonNoAudioStream = "no_audio_stream" // 容器中没有音频流
+	InvalidReasonZeroDuration  = "zero_duration"   // 没有可播放的时长
+	InvalidReasonTruncated     = "truncated"       // 数据在声明的时长之前结束
+)
+
+const (
+	// probeTailSeconds 检查截断时从声明时长末尾往前读取的秒数
+	probeTailSeconds = 10.0
+	// probeTruncationTolerance 最后一个数据包的结束时间与声明时长允许的差（秒），另加 1% 的估算误差
+	probeTruncationTolerance = 1.0
+)
+
+// UploadProbe 上传音频的结构信息
+type UploadProbe struct {
+	FormatName string  // ffprobe 识别的容器格式，如 mp3、flac、mov,mp4,m4a,3gp,3g2,mj2
+	Codec      string  // 第一条音频流的编码
+	Duration   float64 // 秒
+	SampleRate int
+	Channels   int
+}
+
+// InvalidAudioError 文件不是完整可用的音频
+type InvalidAudioError struct {
+	Reason string // InvalidReason* 之一
+	Detail string // ffprobe 的错误输出或检查结果说明
+}
+
+func (e *InvalidAudioError) Error() string {
+	if e.Detail == "" {
+		return "invalid audio: " + e.Reason
+	}
+	return fmt.Sprintf("invalid audio: %s: %s", e.Reason, e.Detail)
+}
+
+// ProbeUpload 用 ffprobe 快速检查上传的音频：能否解析、是否有音频流和有效时长，
+// 并读取末尾的数据包确认文件没有在声明的时长之前被截断。不解码音频，耗时与文件大小基本无关。
+// 文件本身有问题时返回 *InvalidAudioError，ffprobe 无法执行或超时时返回普通错误
+func ProbeUpload(ctx context.Context, ffmpegPath, inputPath string) (*UploadProbe, error) {
+	var probeData struct {
+		Streams []struct {
+			CodecType  string `json:"codec_type"`
+			CodecName  string `json:"codec_name"`
+			SampleRate string `json:"sample_rate"`
+			Channels   int    `json:"channels"`
+		} `json:"streams"`
+		Format struct {
+			FormatName string `json:"format_name"`
+			StartTime  string `json:"start_time"`
+			Duration   string `json:"duration"`
+		} `json:"format"`
+	}
+	if _, err := runUploadProbe(ctx, ffmpegPath, &probeData,
+		"-v", "error",
+		"-show_entries", "format=format_name,start_time,duration:stream=codec_type,codec_name,sample_rate,channels",
+		"-of", "json",
+		inputPath,
+	); err != nil {
+		return nil, err
+	}
+
+	probe := &UploadProbe{FormatName: probeData.Format.FormatName}
+	for _, stream := range probeData.Streams {
+		if stream.CodecType == "audio" {
+			probe.Codec = stream.CodecName
+			probe.SampleRate, _ = strconv.Atoi(stream.SampleRate)
+			probe.Channels = stream.Channels
+			break
+		}
+	}
+	if probe.Codec == "" {
+		return nil, &InvalidAudioError{Reason: InvalidReasonNoAudioStream}
+	}
+	probe.Duration, _ = strconv.ParseFloat(probeData.Format.Duration, 64)
+	if probe.Duration <= 0 {
+		return nil, &InvalidAudioError{Reason: InvalidReasonZeroDuration}
+	}
+	startTime, _ := strconv.ParseFloat(probeData.Format.StartTime, 64)
+
+	if err := checkTruncation(ctx, ffmpegPath, inputPath, startTime, probe.Duration); err != nil {
+		return nil, err
+	}
+	return probe, nil
+}
+
+// checkTruncation 读取声明时长最后 probeTailSeconds 秒的数据包，读取失败或数据在声明的时长之前结束时视为截断。
+// 时长由文件大小估算的格式（如没有 Xing 头的 CBR MP3）截断后时长也随之变短，这里无法发现，但这类文件仍可完整播放
+func checkTruncation(ctx context.Context, ffmpegPath, inputPath string, startTime, duration float64) error {
+	var packetData struct {
+		Packets []struct {
+			PTSTime      string `json:"pts_time"`
+			DurationTime string `json:"duration_time"`
+		} `json:"packets"`
+	}
+	tailStart := startTime + max(duration-probeTailSeconds, 0)
+	stderr, err := runUploadProbe(ctx, ffmpegPath, &packetData,
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-read_intervals", strconv.FormatFloat(tailStart, 'f', 3, 64)+"%",
+		"-show_entries", "packet=pts_time,duration_time",
+		"-of", "json",
+		inputPath,
+	)
+	var invalid *InvalidAudioError
+	if errors.As(err, &invalid) {
+		invalid.Reason = InvalidReasonTruncated
+		return invalid
+	}
+	if err != nil {
+		return err
+	}
+
+	end := 0.0
+	for _, packet := range packetData.Packets {
+		pts, err := strconv.ParseFloat(packet.PTSTime, 64)
+		if err != nil {
+			continue
+		}
+		packetDuration, _ := strconv.ParseFloat(packet.DurationTime, 64)
+		end = max(end, pts+packetDuration)
+	}
+	expected := startTime + duration
+	if end < expected-probeTruncationTolerance-duration*0.01 {
+		detail := fmt.Sprintf("audio data ends at %.1fs, expected %.1fs", max(end-startTime, 0), duration)
+		if stderr != "" {
+			detail += ": " + stderr
+		}
+		return &InvalidAudioError{Reason: InvalidReasonTruncated, Detail: detail}
+	}
+	return nil
+}
+
+// runUploadProbe 执行 ffprobe 并解析 JSON 输出，返回错误输出的第一行；ffprobe 无法解析文件而报错退出时返回 *InvalidAudioError。
+// 错误输出不单独作为拒绝的依据，部分可正常播放的文件（如 ID3 标签不规范的 MP3）也会输出错误级别的日志
+func runUploadProbe(ctx context.Context, ffmpegPath string, out interface{}, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, ffprobePathFor(ffmpegPath), args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("ffprobe timed out: %w", ctx.Err())
+	}
+	detail := firstLine(stderr.String())
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if detail == "" {
+			detail = err.Error()
+		}
+		return detail, &InvalidAudioError{Reason: InvalidReasonCorrupt, Detail: detail}
+	}
+	if err != nil {
+		return "", fmt.Errorf("ffprobe execution failed: %w", err)
+	}
+	if err := json.Unmarshal(stdout.Bytes(), out); err != nil {
+		return detail, fmt.Errorf("failed to unmarshal ffprobe output: %w", err)
+	}
+	return detail, nil
+}
+
+// firstLine 返回 ffprobe 错误输出的第一行非空内容
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
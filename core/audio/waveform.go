@@ -0,0 +1,149 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// WaveformFileName 波形数据文件名，与 HLS 输出存放在同一目录
+	WaveformFileName = "waveform.json"
+	// DefaultWaveformBuckets 默认波形采样桶数量
+	DefaultWaveformBuckets = 1000
+
+	// waveformSampleRate 解码波形时使用的采样率，足够计算峰值且开销小
+	waveformSampleRate = 8000
+	// waveformWindowSamples 每个预聚合窗口的采样数（10ms）
+	waveformWindowSamples = waveformSampleRate / 100
+)
+
+// Waveform 波形峰值数据，Peaks 为归一化到 [0,1] 的振幅
+type Waveform struct {
+	Buckets  int       `json:"buckets"`
+	Duration float64   `json:"duration"` // 秒
+	Peaks    []float64 `json:"peaks"`
+}
+
+// GenerateWaveform 使用 FFmpeg 解码为单声道 PCM 并计算峰值
+// opts 不为空时应用与转码相同的滤镜，保证波形与实际播放内容对齐；sourceDuration 为源文件时长（秒），用于定位淡出
+func GenerateWaveform(ctx context.Context, ffmpegPath, inputPath string, buckets int, opts *TranscodeOptions, sourceDuration float64) (*Waveform, error) {
+	if buckets <= 0 {
+		buckets = DefaultWaveformBuckets
+	}
+
+	args := []string{"-v", "error", "-i", inputPath, "-vn"}
+	if filter := opts.AudioFilter(sourceDuration); filter != "" {
+		args = append(args, "-af", filter)
+	}
+	args = append(args, "-ac", "1", "-ar", fmt.Sprint(waveformSampleRate), "-f", "s16le", "-")
+
+	release, err := sharedTranscodePool.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	cmd := sharedTranscodePool.command(ctx, ffmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建FFmpeg输出管道失败: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动FFmpeg失败: %w", err)
+	}
+
+	// 先按 10ms 窗口聚合峰值，避免将整首歌的 PCM 读入内存
+	windows, totalSamples, readErr := readWindowPeaks(bufio.NewReader(stdout))
+	if err := sharedTranscodePool.observe(ctx, cmd.Wait()); err != nil {
+		return nil, fmt.Errorf("FFmpeg解码波形失败: %w\nFFmpeg Error: %s", err, stderr.String())
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("读取PCM数据失败: %w", readErr)
+	}
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("音频无有效采样: %s", inputPath)
+	}
+
+	return &Waveform{
+		Buckets:  buckets,
+		Duration: math.Round(float64(totalSamples)/waveformSampleRate*1000) / 1000,
+		Peaks:    bucketPeaks(windows, buckets),
+	}, nil
+}
+
+// readWindowPeaks 读取 s16le PCM 流，返回每个窗口的最大绝对振幅和总采样数
+func readWindowPeaks(r io.Reader) ([]uint16, int64, error) {
+	var (
+		windows []uint16
+		total   int64
+		peak    uint16
+		count   int
+		buf     [2]byte
+	)
+	for {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, total, err
+		}
+		sample := int16(binary.LittleEndian.Uint16(buf[:]))
+		amp := uint16(sample)
+		if sample < 0 {
+			amp = uint16(-int32(sample))
+		}
+		if amp > peak {
+			peak = amp
+		}
+		total++
+		count++
+		if count == waveformWindowSamples {
+			windows = append(windows, peak)
+			peak, count = 0, 0
+		}
+	}
+	if count > 0 {
+		windows = append(windows, peak)
+	}
+	return windows, total, nil
+}
+
+// bucketPeaks 将窗口峰值合并为固定数量的桶，并归一化到 [0,1]
+func bucketPeaks(windows []uint16, buckets int) []float64 {
+	peaks := make([]float64, buckets)
+	var max uint16
+	for i := 0; i < buckets; i++ {
+		start := i * len(windows) / buckets
+		end := (i + 1) * len(windows) / buckets
+		if end <= start {
+			end = start + 1
+		}
+		if end > len(windows) {
+			end = len(windows)
+		}
+		var p uint16
+		for _, w := range windows[start:end] {
+			if w > p {
+				p = w
+			}
+		}
+		peaks[i] = float64(p)
+		if p > max {
+			max = p
+		}
+	}
+	if max == 0 {
+		return peaks
+	}
+	for i := range peaks {
+		peaks[i] = math.Round(peaks[i]/float64(max)*1000) / 1000
+	}
+	return peaks
+}
@@ -1,7 +1,12 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sync"
 	"time"
 
 	"Bt1QFM/logger"
@@ -10,7 +15,37 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtSecret = []byte("your-secret-key") // 在生产环境中应该从配置文件读取
+// minSecretLength 服务端签名密钥的最短长度
+const minSecretLength = 32
+
+var (
+	secretMu  sync.RWMutex
+	appSecret []byte
+)
+
+// TokenTTL 登录 Token 的有效期，停用的签名密钥至少保留这么久用于校验
+const TokenTTL = 7 * 24 * time.Hour
+
+// SetSecret 设置服务端签名密钥（APP_SECRET），所有实例和 worker 必须相同；各入口在处理请求前调用，返回错误时应直接退出
+func SetSecret(secret string) error {
+	if len(secret) < minSecretLength {
+		return fmt.Errorf("APP_SECRET 未配置或少于 %d 个字符：请设置随机生成的密钥（如 openssl rand -hex 32），所有实例和 worker 使用同一个值", minSecretLength)
+	}
+	secretMu.Lock()
+	appSecret = []byte(secret)
+	secretMu.Unlock()
+	return nil
+}
+
+// secretKey 返回服务端签名密钥；未设置时 panic，避免用空密钥派生或签名
+func secretKey() []byte {
+	secretMu.RLock()
+	defer secretMu.RUnlock()
+	if appSecret == nil {
+		panic("auth: APP_SECRET is not configured, call auth.SetSecret at startup")
+	}
+	return appSecret
+}
 
 // HashPassword generates a bcrypt hash of the password.
 func HashPassword(password string) (string, error) {
@@ -49,18 +84,25 @@ type Claims struct {
 }
 
 // GenerateToken generates a JWT token for the given user
+// 设置了非对称签名密钥时使用 RS256/EdDSA 签名并在头部写入 kid，否则使用 APP_SECRET 以 HS256 签名
 func GenerateToken(userID int64, username string) (string, error) {
 	claims := Claims{
 		UserID:   userID,
 		Username: username,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour * 7)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	key := currentSigningKey()
+	if key == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(secretKey())
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
 }
 
 // ParseToken parses and validates a JWT token
@@ -70,12 +112,25 @@ func ParseToken(tokenString string) (*Claims, error) {
 	}
 
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// 确保签名方法是我们期望的
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			// 没有 kid 的是用 APP_SECRET 签发的 HS256 Token，启用非对称密钥后只在过渡期内接受
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || !legacyTokensAccepted() {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			return secretKey(), nil
+		}
+
+		// 确保签名方法与该密钥的算法一致
+		key := verificationKey(kid)
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.Algorithm {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return jwtSecret, nil
-	})
+		return key.Private.Public(), nil
+	}, jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmEdDSA, AlgorithmHS256}))
 
 	if err != nil {
 		logger.Warn("[Auth] Token解析失败", logger.ErrorField(err))
@@ -99,3 +154,56 @@ func IsTokenExpired(err error) bool {
 	return err != nil && (err.Error() == "token expired" ||
 		jwt.ErrTokenExpired.Error() == err.Error())
 }
+
+// GenerateUnsubscribeToken 生成退订邮件用的签名，无需登录即可校验
+func GenerateUnsubscribeToken(userID int64) string {
+	mac := hmac.New(sha256.New, secretKey())
+	fmt.Fprintf(mac, "unsubscribe:%d", userID)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyUnsubscribeToken 校验退订签名
+func VerifyUnsubscribeToken(userID int64, token string) bool {
+	expected := GenerateUnsubscribeToken(userID)
+	return hmac.Equal([]byte(expected), []byte(token))
+}
+
+// GenerateOneTimeToken 生成找回密码、验证邮箱等邮件链接用的随机令牌，返回发给用户的令牌及其用于存储的哈希
+func GenerateOneTimeToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate one-time token: %w", err)
+	}
+	token = hex.EncodeToString(b)
+	return token, HashOneTimeToken(token), nil
+}
+
+// HashOneTimeToken 计算一次性令牌的哈希，服务端只保存哈希
+func HashOneTimeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeriveStreamKey 按流 ID 派生 HLS 分片的 AES-128 密钥和 IV，同一个流重新转码时保持不变
+func DeriveStreamKey(streamID string) (key, iv []byte) {
+	mac := hmac.New(sha256.New, secretKey())
+	fmt.Fprintf(mac, "hls-key:%s", streamID)
+	sum := mac.Sum(nil)
+	return sum[:16], sum[16:32]
+}
+
+// SignStreamPath 为流目录生成带过期时间的播放签名，目录下的播放列表和分片共用同一签名
+func SignStreamPath(streamDir string, expires int64) string {
+	mac := hmac.New(sha256.New, secretKey())
+	fmt.Fprintf(mac, "stream:%s:%d", streamDir, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyStreamSignature 校验播放签名及其是否过期
+func VerifyStreamSignature(streamDir string, expires int64, signature string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+	expected := SignStreamPath(streamDir, expires)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
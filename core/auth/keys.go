@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// 支持的 JWT 签名算法
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmHS256 = "HS256" // 使用 APP_SECRET 签名，不轮换
+)
+
+// rsaKeyBits RS256 密钥长度
+const rsaKeyBits = 2048
+
+// SigningKey 一把 JWT 签名密钥，ID 写入 Token 头部的 kid
+type SigningKey struct {
+	ID        string
+	Algorithm string // RS256 或 EdDSA
+	Private   crypto.Signer
+}
+
+// JWK 公钥的 JSON Web Key 表示
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA 模数
+	E   string `json:"e,omitempty"`   // RSA 指数
+	Crv string `json:"crv,omitempty"` // OKP 曲线
+	X   string `json:"x,omitempty"`   // OKP 公钥
+}
+
+// JWKS 公钥集合，其他服务据此校验本服务签发的 Token
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// keySet 当前用于签名和校验的密钥
+type keySet struct {
+	signing *SigningKey
+	byID    map[string]*SigningKey
+	ordered []*SigningKey
+}
+
+var (
+	keysMu sync.RWMutex
+	keys   *keySet
+	// legacyTokensUntil 使用非对称密钥后仍接受 HS256 Token 的截止时间，零值表示不接受
+	legacyTokensUntil time.Time
+)
+
+// GenerateSigningKey 生成一把新的签名密钥，ID 随机生成
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var private crypto.Signer
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate rsa key: %w", err)
+		}
+		private = key
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ed25519 key: %w", err)
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate key id: %w", err)
+	}
+	return &SigningKey{ID: hex.EncodeToString(id), Algorithm: algorithm, Private: private}, nil
+}
+
+// MarshalPrivateKey 把私钥编码为 PKCS#8 PEM，用于持久化
+func MarshalPrivateKey(key *SigningKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal private key %s: %w", key.ID, err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParseSigningKey 从 PKCS#8 PEM 还原签名密钥，并检查密钥类型与算法一致
+func ParseSigningKey(id, algorithm, privatePEM string) (*SigningKey, error) {
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, fmt.Errorf("invalid pem for key %s", id)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", id, err)
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if algorithm == AlgorithmRS256 {
+			return &SigningKey{ID: id, Algorithm: algorithm, Private: key}, nil
+		}
+	case ed25519.PrivateKey:
+		if algorithm == AlgorithmEdDSA {
+			return &SigningKey{ID: id, Algorithm: algorithm, Private: key}, nil
+		}
+	}
+	return nil, fmt.Errorf("key %s does not match algorithm %s", id, algorithm)
+}
+
+// JWK 返回密钥的公钥部分
+func (k *SigningKey) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+	switch public := k.Private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// SetSigningKeys 设置用于签发 Token 的密钥和可用于校验的全部密钥（包括即将启用和已停用但仍在有效期内的）
+// signing 为空时退回使用 APP_SECRET 的 HS256 签名
+func SetSigningKeys(signing *SigningKey, verification []*SigningKey) {
+	set := &keySet{signing: signing, byID: make(map[string]*SigningKey, len(verification))}
+	for _, key := range verification {
+		if _, ok := set.byID[key.ID]; ok {
+			continue
+		}
+		set.byID[key.ID] = key
+		set.ordered = append(set.ordered, key)
+	}
+	if signing != nil {
+		if _, ok := set.byID[signing.ID]; !ok {
+			set.byID[signing.ID] = signing
+			set.ordered = append(set.ordered, signing)
+		}
+	}
+
+	keysMu.Lock()
+	keys = set
+	keysMu.Unlock()
+}
+
+// SetLegacyTokenDeadline 设置启用非对称密钥后仍接受 HS256 Token 的截止时间，零值表示不再接受
+func SetLegacyTokenDeadline(until time.Time) {
+	keysMu.Lock()
+	legacyTokensUntil = until
+	keysMu.Unlock()
+}
+
+// PublicKeys 返回当前可用于校验 Token 的公钥集合
+func PublicKeys() JWKS {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	jwks := JWKS{Keys: make([]JWK, 0)}
+	if keys == nil {
+		return jwks
+	}
+	for _, key := range keys.ordered {
+		jwks.Keys = append(jwks.Keys, key.JWK())
+	}
+	return jwks
+}
+
+// currentSigningKey 当前用于签发 Token 的密钥，未设置时为空
+func currentSigningKey() *SigningKey {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	if keys == nil {
+		return nil
+	}
+	return keys.signing
+}
+
+// verificationKey 按 kid 查找校验密钥
+func verificationKey(id string) *SigningKey {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	if keys == nil {
+		return nil
+	}
+	return keys.byID[id]
+}
+
+// legacyTokensAccepted 是否接受没有 kid 的 HS256 Token；尚未设置非对称密钥时总是接受，之后只在截止时间前接受
+func legacyTokensAccepted() bool {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return keys == nil || keys.signing == nil || time.Now().Before(legacyTokensUntil)
+}
@@ -0,0 +1,412 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+	"Bt1QFM/storage"
+)
+
+// 备份包内的文件
+const (
+	databaseEntry = "database.sql"
+	manifestEntry = "manifest.json"
+	configPrefix  = "config/"
+)
+
+// 备份文件名格式：1qfm-backup-<时间>.tar.gz
+const (
+	fileNamePrefix = "1qfm-backup-"
+	fileNameSuffix = ".tar.gz"
+	fileTimeLayout = "20060102-150405"
+)
+
+// ErrAlreadyRunning 已有备份任务在执行
+var ErrAlreadyRunning = fmt.Errorf("backup is already running")
+
+// ManifestObject 对象存储中的一个对象
+type ManifestObject struct {
+	Class        string    `json:"class"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// Manifest 对象存储清单，只记录对象的键和大小，不包含对象内容
+// 音频和 HLS 流体积较大，由存储自身的复制或 migrate-storage 迁移，恢复时用清单核对哪些对象缺失
+type Manifest struct {
+	GeneratedAt time.Time        `json:"generatedAt"`
+	Objects     []ManifestObject `json:"objects"`
+	TotalBytes  int64            `json:"totalBytes"`
+}
+
+// Result 一次备份的结果
+type Result struct {
+	File        string    `json:"file"`
+	Size        int64     `json:"size"`
+	Tables      int       `json:"tables"`
+	Rows        int64     `json:"rows"`
+	Objects     int       `json:"objects"`
+	ObjectBytes int64     `json:"objectBytes"`
+	ConfigFiles []string  `json:"configFiles"`
+	StartedAt   time.Time `json:"startedAt"`
+	DurationMs  int64     `json:"durationMs"`
+}
+
+// FileInfo 备份目录中的一个备份文件
+type FileInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Status 备份服务状态
+type Status struct {
+	Running     bool       `json:"running"`
+	Last        *Result    `json:"last,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+	Dir         string     `json:"dir"`
+	Backups     []FileInfo `json:"backups"`
+}
+
+// Service 备份服务：把数据库转储、对象存储清单和配置文件打包到备份目录，并只保留最近的若干个
+type Service struct {
+	db  *sql.DB
+	cfg *config.Config
+
+	running sync.Mutex
+
+	mu          sync.Mutex
+	busy        bool
+	last        *Result
+	lastError   string
+	lastErrorAt time.Time
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewService 创建备份服务
+func NewService(db *sql.DB, cfg *config.Config) *Service {
+	return &Service{
+		db:       db,
+		cfg:      cfg,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start 按配置的间隔定期备份，间隔为 0 时不启动
+func (s *Service) Start() {
+	if s.cfg.BackupIntervalHours <= 0 {
+		logger.Info("自动备份未启用")
+		return
+	}
+	interval := time.Duration(s.cfg.BackupIntervalHours) * time.Hour
+	logger.Info("备份服务启动",
+		logger.Duration("interval", interval),
+		logger.String("dir", s.cfg.BackupDir),
+		logger.Int("keep", s.cfg.BackupKeep))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				if _, err := s.Run(context.Background()); err != nil {
+					logger.Warn("定期备份失败", logger.ErrorField(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止定期备份，等待正在执行的备份完成
+func (s *Service) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// Trigger 在后台开始一次备份，已有备份在执行时返回 ErrAlreadyRunning
+func (s *Service) Trigger() error {
+	if !s.running.TryLock() {
+		return ErrAlreadyRunning
+	}
+	// 立即标记为运行中，触发后马上查询状态也能看到
+	s.mu.Lock()
+	s.busy = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.running.Unlock()
+		if _, err := s.run(context.Background()); err != nil {
+			logger.Warn("备份失败", logger.ErrorField(err))
+		}
+	}()
+	return nil
+}
+
+// Run 执行一次备份，已有备份在执行时返回 ErrAlreadyRunning
+func (s *Service) Run(ctx context.Context) (*Result, error) {
+	if !s.running.TryLock() {
+		return nil, ErrAlreadyRunning
+	}
+	defer s.running.Unlock()
+	return s.run(ctx)
+}
+
+// run 执行备份并记录结果，调用方持有 running 锁
+func (s *Service) run(ctx context.Context) (*Result, error) {
+	s.mu.Lock()
+	s.busy = true
+	s.mu.Unlock()
+
+	result, err := Create(ctx, s.db, s.cfg.BackupDir, s.cfg.BackupConfigFiles)
+
+	s.mu.Lock()
+	s.busy = false
+	if err != nil {
+		s.lastError = err.Error()
+		s.lastErrorAt = time.Now()
+	} else {
+		s.last = result
+		s.lastError = ""
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.prune(); err != nil {
+		logger.Warn("清理旧备份失败", logger.ErrorField(err))
+	}
+	return result, nil
+}
+
+// prune 删除超出保留个数的旧备份
+func (s *Service) prune() error {
+	if s.cfg.BackupKeep <= 0 {
+		return nil
+	}
+	backups, err := List(s.cfg.BackupDir)
+	if err != nil {
+		return err
+	}
+	for i := s.cfg.BackupKeep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(s.cfg.BackupDir, backups[i].Name)); err != nil {
+			return err
+		}
+		logger.Info("已删除旧备份", logger.String("file", backups[i].Name))
+	}
+	return nil
+}
+
+// Status 返回当前是否在备份、最近一次结果和备份目录中的备份
+func (s *Service) Status() (*Status, error) {
+	s.mu.Lock()
+	status := &Status{
+		Running:   s.busy,
+		Last:      s.last,
+		LastError: s.lastError,
+		Dir:       s.cfg.BackupDir,
+	}
+	if s.lastError != "" {
+		at := s.lastErrorAt
+		status.LastErrorAt = &at
+	}
+	s.mu.Unlock()
+
+	backups, err := List(s.cfg.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	status.Backups = backups
+	return status, nil
+}
+
+// List 按时间从新到旧列出目录中的备份文件，目录不存在时返回空列表
+func List(dir string) ([]FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []FileInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []FileInfo{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, fileNamePrefix) || !strings.HasSuffix(name, fileNameSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, FileInfo{Name: name, Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+	// 文件名中的时间戳可按字典序排序
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+// Create 在 dir 中创建一个备份文件
+// 先写入同目录下的临时文件，打包完成后再重命名，中途失败不会留下不完整的备份
+func Create(ctx context.Context, database *sql.DB, dir string, configFiles []string) (*Result, error) {
+	start := time.Now()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %w", err)
+	}
+
+	dump, err := os.CreateTemp(dir, ".database-*.sql")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(dump.Name())
+	defer dump.Close()
+
+	result := &Result{StartedAt: start}
+	if result.Tables, result.Rows, err = dumpDatabase(ctx, database, dump); err != nil {
+		return nil, fmt.Errorf("导出数据库失败: %w", err)
+	}
+
+	manifest, err := buildManifest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("导出对象存储清单失败: %w", err)
+	}
+	result.Objects = len(manifest.Objects)
+	result.ObjectBytes = manifest.TotalBytes
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := os.CreateTemp(dir, ".backup-*"+fileNameSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	gz := gzip.NewWriter(archive)
+	tw := tar.NewWriter(gz)
+	if _, err := dump.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := writeFileEntry(tw, databaseEntry, dump); err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, manifestEntry, manifestData, start); err != nil {
+		return nil, err
+	}
+	for _, path := range configFiles {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件 %s 失败: %w", path, err)
+		}
+		if err := writeEntry(tw, configPrefix+filepath.Base(path), data, start); err != nil {
+			return nil, err
+		}
+		result.ConfigFiles = append(result.ConfigFiles, filepath.Base(path))
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	target := filepath.Join(dir, fileNamePrefix+start.Format(fileTimeLayout)+fileNameSuffix)
+	if err := os.Rename(archive.Name(), target); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(target); err == nil {
+		result.Size = info.Size()
+	}
+	result.File = target
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	logger.Info("备份完成",
+		logger.String("file", target),
+		logger.Int64("size", result.Size),
+		logger.Int("tables", result.Tables),
+		logger.Int64("rows", result.Rows),
+		logger.Int("objects", result.Objects),
+		logger.Duration("elapsed", time.Since(start)))
+	return result, nil
+}
+
+// buildManifest 列出各类别前缀下的所有对象
+func buildManifest(ctx context.Context) (*Manifest, error) {
+	store := storage.GetStorage()
+	manifest := &Manifest{GeneratedAt: time.Now(), Objects: []ManifestObject{}}
+	for _, class := range storage.Classes {
+		for _, prefix := range storage.ClassPrefixes(class) {
+			objects, err := store.List(ctx, prefix)
+			if err != nil {
+				return nil, fmt.Errorf("列出 %s 失败: %w", prefix, err)
+			}
+			for _, object := range objects {
+				manifest.Objects = append(manifest.Objects, ManifestObject{
+					Class:        class,
+					Key:          object.Key,
+					Size:         object.Size,
+					ETag:         object.ETag,
+					LastModified: object.LastModified,
+				})
+				manifest.TotalBytes += object.Size
+			}
+		}
+	}
+	return manifest, nil
+}
+
+// writeEntry 向备份包写入一个内存中的文件
+func writeEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// writeFileEntry 向备份包写入一个磁盘上的文件
+func writeFileEntry(tw *tar.Writer, name string, f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: info.Size(), ModTime: info.ModTime()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,237 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// insertBatchSize 每条 INSERT 语句包含的行数
+const insertBatchSize = 100
+
+// binaryColumnTypes 以十六进制字面量导出的二进制列类型，避免按连接字符集解析时损坏
+var binaryColumnTypes = map[string]bool{
+	"BINARY": true, "VARBINARY": true, "BIT": true, "GEOMETRY": true,
+	"TINYBLOB": true, "BLOB": true, "MEDIUMBLOB": true, "LONGBLOB": true,
+}
+
+// dumpDatabase 把所有表的结构和数据导出为 SQL 语句
+// 在一致性快照事务中读取，备份期间的写入不会导致表之间不一致；INSERT 语句各占一行
+func dumpDatabase(ctx context.Context, database *sql.DB, w io.Writer) (tables int, rows int64, err error) {
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+		return 0, 0, err
+	}
+	if _, err := conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+		return 0, 0, err
+	}
+	defer conn.ExecContext(context.Background(), "COMMIT")
+
+	names, err := listTables(ctx, conn)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "-- 1QFM database backup %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintln(out, "SET NAMES utf8mb4;")
+	fmt.Fprintln(out, "SET FOREIGN_KEY_CHECKS=0;")
+	for _, name := range names {
+		n, err := dumpTable(ctx, conn, name, out)
+		if err != nil {
+			return tables, rows, fmt.Errorf("导出表 %s 失败: %w", name, err)
+		}
+		tables++
+		rows += n
+	}
+	fmt.Fprintln(out, "SET FOREIGN_KEY_CHECKS=1;")
+	return tables, rows, out.Flush()
+}
+
+// listTables 列出当前数据库的所有基础表（不含视图）
+func listTables(ctx context.Context, conn *sql.Conn) ([]string, error) {
+	result, err := conn.QueryContext(ctx, "SHOW FULL TABLES WHERE Table_type = 'BASE TABLE'")
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	var names []string
+	for result.Next() {
+		var name, tableType string
+		if err := result.Scan(&name, &tableType); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, result.Err()
+}
+
+// dumpTable 导出单个表的建表语句和全部数据
+func dumpTable(ctx context.Context, conn *sql.Conn, name string, out *bufio.Writer) (int64, error) {
+	var table, createSQL string
+	if err := conn.QueryRowContext(ctx, "SHOW CREATE TABLE "+quoteIdent(name)).Scan(&table, &createSQL); err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(out, "\n-- Table %s\n", name)
+	fmt.Fprintf(out, "DROP TABLE IF EXISTS %s;\n", quoteIdent(name))
+	fmt.Fprintf(out, "%s;\n", createSQL)
+
+	result, err := conn.QueryContext(ctx, "SELECT * FROM "+quoteIdent(name))
+	if err != nil {
+		return 0, err
+	}
+	defer result.Close()
+
+	columnTypes, err := result.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+	binary := make([]bool, len(columnTypes))
+	for i, ct := range columnTypes {
+		binary[i] = binaryColumnTypes[strings.ToUpper(ct.DatabaseTypeName())]
+	}
+
+	values := make([]interface{}, len(columnTypes))
+	pointers := make([]interface{}, len(columnTypes))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	var count int64
+	inBatch := 0
+	for result.Next() {
+		if err := result.Scan(pointers...); err != nil {
+			return count, err
+		}
+		if inBatch == 0 {
+			fmt.Fprintf(out, "INSERT INTO %s VALUES ", quoteIdent(name))
+		} else {
+			out.WriteString(",")
+		}
+		out.WriteString("(")
+		for i, value := range values {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			out.WriteString(sqlLiteral(value, binary[i]))
+		}
+		out.WriteString(")")
+		count++
+		inBatch++
+		if inBatch == insertBatchSize {
+			out.WriteString(";\n")
+			inBatch = 0
+		}
+	}
+	if inBatch > 0 {
+		out.WriteString(";\n")
+	}
+	return count, result.Err()
+}
+
+// quoteIdent 用反引号引用表名
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// sqlLiteral 把扫描得到的值转换为 SQL 字面量
+func sqlLiteral(value interface{}, binary bool) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		if v.IsZero() {
+			return "'0000-00-00 00:00:00'"
+		}
+		return "'" + v.Format("2006-01-02 15:04:05.999999") + "'"
+	case []byte:
+		if binary {
+			if len(v) == 0 {
+				return "''"
+			}
+			return "X'" + hex.EncodeToString(v) + "'"
+		}
+		return quoteString(string(v))
+	default:
+		return quoteString(fmt.Sprint(v))
+	}
+}
+
+// sqlEscaper 转义字符串字面量中的特殊字符，转义后的语句不含换行
+var sqlEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"'", "\\'",
+	"\x00", "\\0",
+	"\n", "\\n",
+	"\r", "\\r",
+	"\x1a", "\\Z",
+)
+
+// quoteString 转换为单引号字符串字面量
+func quoteString(s string) string {
+	return "'" + sqlEscaper.Replace(s) + "'"
+}
+
+// executeDump 在同一个连接上逐条执行 dumpDatabase 导出的语句，返回执行的语句数
+// 语句以行尾的分号结束，建表语句可以跨行
+func executeDump(ctx context.Context, database *sql.DB, r io.Reader) (int, error) {
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(r)
+	var statement strings.Builder
+	executed := 0
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return executed, readErr
+		}
+		trimmed := strings.TrimSpace(line)
+		if statement.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			if readErr == io.EOF {
+				break
+			}
+			continue
+		}
+		statement.WriteString(line)
+		if strings.HasSuffix(trimmed, ";") {
+			query := strings.TrimSuffix(strings.TrimSpace(statement.String()), ";")
+			if _, err := conn.ExecContext(ctx, query); err != nil {
+				return executed, fmt.Errorf("执行第 %d 条语句失败: %w", executed+1, err)
+			}
+			executed++
+			statement.Reset()
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	if statement.Len() > 0 {
+		return executed, fmt.Errorf("备份中的最后一条语句不完整")
+	}
+	return executed, nil
+}
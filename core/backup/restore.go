@@ -0,0 +1,154 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/storage"
+)
+
+// missingSampleSize 恢复报告中列出的缺失对象个数上限
+const missingSampleSize = 20
+
+// RestoreOptions 恢复选项
+type RestoreOptions struct {
+	SkipDatabase bool   // 不导入数据库，只恢复配置文件并核对对象存储
+	ConfigDir    string // 配置文件写入的目录
+}
+
+// RestoreResult 恢复结果
+type RestoreResult struct {
+	Statements     int      `json:"statements"`
+	ConfigFiles    []string `json:"configFiles"`
+	Objects        int      `json:"objects"`
+	MissingObjects int      `json:"missingObjects"`
+	MissingBytes   int64    `json:"missingBytes"`
+	MissingSample  []string `json:"missingSample,omitempty"`
+}
+
+// Restore 用备份文件恢复实例：导入数据库转储（会覆盖同名表），写出配置文件，并按清单核对对象存储
+// 目标位置已有同名配置文件时写为 <文件名>.restored，由运维比较后手动替换
+func Restore(ctx context.Context, database *sql.DB, archivePath string, opts RestoreOptions) (*RestoreResult, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的备份文件: %w", err)
+	}
+	defer gz.Close()
+
+	result := &RestoreResult{}
+	var manifest *Manifest
+	foundDatabase := false
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("读取备份文件失败: %w", err)
+		}
+
+		switch {
+		case header.Name == databaseEntry:
+			foundDatabase = true
+			if opts.SkipDatabase {
+				continue
+			}
+			logger.Info("开始导入数据库", logger.String("file", archivePath))
+			if result.Statements, err = executeDump(ctx, database, tr); err != nil {
+				return result, fmt.Errorf("导入数据库失败: %w", err)
+			}
+		case header.Name == manifestEntry:
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return result, fmt.Errorf("解析对象存储清单失败: %w", err)
+			}
+		case strings.HasPrefix(header.Name, configPrefix):
+			name, err := restoreConfigFile(tr, opts.ConfigDir, strings.TrimPrefix(header.Name, configPrefix))
+			if err != nil {
+				return result, err
+			}
+			result.ConfigFiles = append(result.ConfigFiles, name)
+		}
+	}
+	if !foundDatabase {
+		return result, fmt.Errorf("备份文件中没有数据库转储")
+	}
+
+	if manifest != nil {
+		if err := checkManifest(ctx, manifest, result); err != nil {
+			return result, fmt.Errorf("核对对象存储失败: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// restoreConfigFile 写出备份中的配置文件，返回写入的路径
+func restoreConfigFile(r io.Reader, dir, name string) (string, error) {
+	// 只取文件名，防止备份中的路径写到目录之外
+	name = filepath.Base(name)
+	if name == "." || name == "/" {
+		return "", fmt.Errorf("备份中的配置文件名无效")
+	}
+	if dir == "" {
+		dir = "."
+	}
+	target := filepath.Join(dir, name)
+	if _, err := os.Stat(target); err == nil {
+		target += ".restored"
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(target, data, 0600); err != nil {
+		return "", fmt.Errorf("写入配置文件 %s 失败: %w", target, err)
+	}
+	return target, nil
+}
+
+// checkManifest 统计清单中在当前对象存储里不存在的对象
+func checkManifest(ctx context.Context, manifest *Manifest, result *RestoreResult) error {
+	store := storage.GetStorage()
+	existing := make(map[string]bool)
+	for _, class := range storage.Classes {
+		for _, prefix := range storage.ClassPrefixes(class) {
+			objects, err := store.List(ctx, prefix)
+			if err != nil {
+				return fmt.Errorf("列出 %s 失败: %w", prefix, err)
+			}
+			for _, object := range objects {
+				existing[object.Key] = true
+			}
+		}
+	}
+
+	result.Objects = len(manifest.Objects)
+	for _, object := range manifest.Objects {
+		if existing[object.Key] {
+			continue
+		}
+		result.MissingObjects++
+		result.MissingBytes += object.Size
+		if len(result.MissingSample) < missingSampleSize {
+			result.MissingSample = append(result.MissingSample, object.Key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package bandwidth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+const (
+	// MaxDays 流量统计最多查询的天数
+	MaxDays = 90
+	// MaxLimit 管理员统计最多返回的用户数
+	MaxLimit = 100
+	// rollupInterval 汇总之前各天流量的间隔，当天的流量仍在累加，不汇总
+	rollupInterval = time.Hour
+	// WarnRatio 当天流量达到软上限的该比例时开始提示
+	WarnRatio = 0.8
+)
+
+// Service 流量统计服务：响应写完后在 Redis 当天的流量上累加，定时把之前各天的流量汇总到数据库
+type Service struct {
+	repo     repository.BandwidthRepository
+	userRepo repository.UserRepository
+	cfg      *config.Config
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Report 一次汇总的结果
+type Report struct {
+	Days  int `json:"days"`  // 汇总的天数
+	Users int `json:"users"` // 汇总的用户-天数
+}
+
+// NewService 创建流量统计服务
+func NewService(repo repository.BandwidthRepository, userRepo repository.UserRepository, cfg *config.Config) *Service {
+	return &Service{
+		repo:     repo,
+		userRepo: userRepo,
+		cfg:      cfg,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start 启动时先补汇总停机期间遗留的流量，之后每小时汇总一次
+func (s *Service) Start() {
+	logger.Info("流量统计汇总服务启动", logger.Int64("softCapMB", int64(s.cfg.BandwidthDailySoftCapMB)))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.rollup()
+		ticker := time.NewTicker(rollupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				s.rollup()
+			}
+		}
+	}()
+}
+
+// Stop 停止定时汇总
+func (s *Service) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// SoftCapBytes 每位用户每天的流量软上限，不限制时为 0
+func (s *Service) SoftCapBytes() int64 {
+	if s.cfg.BandwidthDailySoftCapMB <= 0 {
+		return 0
+	}
+	return int64(s.cfg.BandwidthDailySoftCapMB) << 20
+}
+
+// Record 把一次响应的字节数累加到用户当天的流量
+func (s *Service) Record(ctx context.Context, userID, bytes int64) error {
+	if bytes <= 0 {
+		return nil
+	}
+	_, err := cache.IncrBandwidth(ctx, userID, bytes, time.Now())
+	return err
+}
+
+// Today 用户 now 当天已使用的流量
+func (s *Service) Today(ctx context.Context, userID int64, now time.Time) (int64, error) {
+	return cache.GetUserBandwidth(ctx, userID, now.Format(cache.PlayCountDayLayout))
+}
+
+// Days 规范化统计天数，未指定时统计最近 30 天
+func (s *Service) Days(days int) int {
+	if days <= 0 {
+		days = 30
+	}
+	return min(days, MaxDays)
+}
+
+func (s *Service) rollup() {
+	report, err := s.Rollup(context.Background(), time.Now())
+	if err != nil {
+		logger.Warn("汇总用户流量失败", logger.ErrorField(err))
+		return
+	}
+	if report.Days > 0 {
+		logger.Info("用户流量已汇总",
+			logger.Int("days", report.Days),
+			logger.Int("users", report.Users))
+	}
+}
+
+// Rollup 把 now 当天之前的每日流量写入数据库并从 Redis 删除
+func (s *Service) Rollup(ctx context.Context, now time.Time) (*Report, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	days, err := cache.BandwidthDays(ctx)
+	if err != nil {
+		return nil, err
+	}
+	today := now.Format(cache.PlayCountDayLayout)
+	report := &Report{}
+	for _, day := range days {
+		if day >= today {
+			continue
+		}
+		usage, err := cache.GetBandwidthUsage(ctx, day)
+		if err != nil {
+			return report, err
+		}
+		if err := s.repo.SaveDailyBandwidth(ctx, day, usage); err != nil {
+			return report, err
+		}
+		if err := cache.DeleteBandwidthUsage(ctx, day); err != nil {
+			return report, err
+		}
+		report.Days++
+		report.Users += len(usage)
+	}
+	return report, nil
+}
+
+// UserUsage 返回用户包含 now 当天在内最近 days 天每天的流量，从早到晚排列，没有流量的日期为 0
+// 已汇总的天数从数据库读取，还在 Redis 中的流量（当天以及尚未汇总的日期）实时合并
+func (s *Service) UserUsage(ctx context.Context, userID int64, now time.Time, days int) ([]model.BandwidthUsage, error) {
+	first := now.AddDate(0, 0, 1-days)
+	stored, err := s.repo.GetUserDailyBandwidth(ctx, userID, first.Format(cache.PlayCountDayLayout))
+	if err != nil {
+		return nil, err
+	}
+	byDay := make(map[string]int64, len(stored))
+	for _, u := range stored {
+		byDay[u.Day] += u.Bytes
+	}
+
+	usage := make([]model.BandwidthUsage, 0, days)
+	for day := first; !day.After(now); day = day.AddDate(0, 0, 1) {
+		key := day.Format(cache.PlayCountDayLayout)
+		bytes, err := cache.GetUserBandwidth(ctx, userID, key)
+		if err != nil {
+			logger.Warn("读取当日流量失败", logger.ErrorField(err))
+		}
+		usage = append(usage, model.BandwidthUsage{Day: key, Bytes: byDay[key] + bytes})
+	}
+	return usage, nil
+}
+
+// Summary 全部用户的流量统计
+type Summary struct {
+	Days       int                    `json:"days"`
+	TotalBytes int64                  `json:"totalBytes"`
+	Daily      []model.BandwidthUsage `json:"daily"` // 每天全部用户的流量，从早到晚排列
+	TopUsers   []model.BandwidthUsage `json:"topUsers"`
+}
+
+// Summarize 统计包含 now 当天在内最近 days 天全部用户每天的流量和流量最多的 limit 位用户
+func (s *Service) Summarize(ctx context.Context, now time.Time, days, limit int) (*Summary, error) {
+	first := now.AddDate(0, 0, 1-days)
+	since := first.Format(cache.PlayCountDayLayout)
+
+	totals, err := s.repo.GetDailyBandwidthTotals(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	// 多取一些用户，与 Redis 中的流量合并后排名仍然准确
+	stored, err := s.repo.GetTopBandwidthUsers(ctx, since, MaxLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	byDay := make(map[string]int64, len(totals))
+	for _, u := range totals {
+		byDay[u.Day] += u.Bytes
+	}
+	byUser := make(map[int64]*model.BandwidthUsage, len(stored))
+	for i := range stored {
+		byUser[stored[i].UserID] = &stored[i]
+	}
+	for day := first; !day.After(now); day = day.AddDate(0, 0, 1) {
+		key := day.Format(cache.PlayCountDayLayout)
+		usage, err := cache.GetBandwidthUsage(ctx, key)
+		if err != nil {
+			logger.Warn("读取当日流量失败", logger.ErrorField(err))
+			break
+		}
+		for _, u := range usage {
+			byDay[key] += u.Bytes
+			if existing := byUser[u.UserID]; existing != nil {
+				existing.Bytes += u.Bytes
+			} else {
+				byUser[u.UserID] = &model.BandwidthUsage{UserID: u.UserID, Bytes: u.Bytes}
+			}
+		}
+	}
+
+	summary := &Summary{Days: days, Daily: make([]model.BandwidthUsage, 0, days)}
+	for day := first; !day.After(now); day = day.AddDate(0, 0, 1) {
+		key := day.Format(cache.PlayCountDayLayout)
+		summary.Daily = append(summary.Daily, model.BandwidthUsage{Day: key, Bytes: byDay[key]})
+		summary.TotalBytes += byDay[key]
+	}
+
+	ranked := make([]model.BandwidthUsage, 0, len(byUser))
+	for _, u := range byUser {
+		ranked = append(ranked, *u)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Bytes != ranked[j].Bytes {
+			return ranked[i].Bytes > ranked[j].Bytes
+		}
+		return ranked[i].UserID < ranked[j].UserID
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	// 只在 Redis 中有流量的用户还没有用户名
+	for i := range ranked {
+		if ranked[i].Username != "" {
+			continue
+		}
+		if user, err := s.userRepo.GetUserByID(ctx, ranked[i].UserID); err == nil && user != nil {
+			ranked[i].Username = user.Username
+		}
+	}
+	summary.TopUsers = ranked
+	return summary, nil
+}
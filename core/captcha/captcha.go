@@ -0,0 +1,75 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"Bt1QFM/config"
+)
+
+// 支持的人机验证方式
+const (
+	ProviderNone      = "none"
+	ProviderHCaptcha  = "hcaptcha"
+	ProviderTurnstile = "turnstile"
+	ProviderPoW       = "pow" // 工作量证明，不依赖第三方服务
+)
+
+var (
+	// ErrInvalid 验证未通过：缺少令牌、令牌错误、已过期或已被使用
+	ErrInvalid = errors.New("captcha verification failed")
+	// ErrUnavailable 验证服务暂时不可用
+	ErrUnavailable = errors.New("captcha service unavailable")
+)
+
+// httpClient 访问 hCaptcha / Turnstile 校验接口的 HTTP 客户端
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Challenge 客户端完成验证所需的参数
+type Challenge struct {
+	Provider   string `json:"provider"`
+	SiteKey    string `json:"siteKey,omitempty"`    // hCaptcha / Turnstile 组件的站点密钥
+	Challenge  string `json:"challenge,omitempty"`  // 工作量证明的挑战
+	Difficulty int    `json:"difficulty,omitempty"` // 工作量证明要求 SHA-256(challenge + nonce) 的前导零比特数
+	ExpiresAt  int64  `json:"expiresAt,omitempty"`  // 工作量证明挑战的过期时间戳（毫秒）
+}
+
+// Verifier 人机验证方式
+type Verifier interface {
+	// Challenge 返回客户端完成验证所需的参数
+	Challenge(ctx context.Context) (*Challenge, error)
+	// Verify 校验客户端提交的令牌，未通过时返回 ErrInvalid
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// New 按配置创建人机验证方式，未启用或配置不完整时返回 nil
+func New(cfg *config.Config) Verifier {
+	switch strings.ToLower(cfg.CaptchaProvider) {
+	case ProviderHCaptcha:
+		if cfg.CaptchaSecret == "" {
+			return nil
+		}
+		return &siteVerifier{
+			provider:  ProviderHCaptcha,
+			verifyURL: "https://api.hcaptcha.com/siteverify",
+			siteKey:   cfg.CaptchaSiteKey,
+			secret:    cfg.CaptchaSecret,
+		}
+	case ProviderTurnstile:
+		if cfg.CaptchaSecret == "" {
+			return nil
+		}
+		return &siteVerifier{
+			provider:  ProviderTurnstile,
+			verifyURL: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+			siteKey:   cfg.CaptchaSiteKey,
+			secret:    cfg.CaptchaSecret,
+		}
+	case ProviderPoW:
+		return &powVerifier{difficulty: min(max(cfg.CaptchaPowDifficulty, 1), MaxPoWDifficulty)}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package captcha
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+)
+
+const (
+	// MaxPoWDifficulty 工作量证明难度的上限，每增加 1 计算量翻倍
+	MaxPoWDifficulty = 32
+	// powTTL 挑战的有效期
+	powTTL = 5 * time.Minute
+)
+
+// powVerifier 工作量证明：服务端签发随机挑战，客户端找到 nonce 使 SHA-256(challenge + nonce) 的前导零比特数
+// 不少于难度，提交 "challenge:nonce"；挑战保存在 Redis，只能使用一次
+type powVerifier struct {
+	difficulty int
+}
+
+func (v *powVerifier) Challenge(ctx context.Context) (*Challenge, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	challenge := hex.EncodeToString(b)
+	if err := cache.SaveCaptchaChallenge(ctx, challenge, v.difficulty, powTTL); err != nil {
+		return nil, err
+	}
+	return &Challenge{
+		Provider:   ProviderPoW,
+		Challenge:  challenge,
+		Difficulty: v.difficulty,
+		ExpiresAt:  time.Now().Add(powTTL).UnixMilli(),
+	}, nil
+}
+
+func (v *powVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	challenge, nonce, ok := strings.Cut(token, ":")
+	if !ok || challenge == "" || nonce == "" || len(nonce) > 64 {
+		return ErrInvalid
+	}
+	// 先检查哈希，错误的答案不消耗挑战
+	zeros := leadingZeroBits(sha256.Sum256([]byte(challenge + nonce)))
+	if zeros < v.difficulty {
+		return ErrInvalid
+	}
+	difficulty, err := cache.ConsumeCaptchaChallenge(ctx, challenge)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if difficulty == 0 || zeros < difficulty {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// leadingZeroBits 哈希的前导零比特数
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			return zeros + bits.LeadingZeros8(b)
+		}
+		zeros += 8
+	}
+	return zeros
+}
@@ -0,0 +1,61 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// siteVerifier hCaptcha 和 Cloudflare Turnstile 的服务端校验，两者的接口格式相同
+type siteVerifier struct {
+	provider  string
+	verifyURL string
+	siteKey   string
+	secret    string
+}
+
+func (v *siteVerifier) Challenge(ctx context.Context) (*Challenge, error) {
+	return &Challenge{Provider: v.provider, SiteKey: v.siteKey}, nil
+}
+
+func (v *siteVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return ErrInvalid
+	}
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	if v.siteKey != "" && v.provider == ProviderHCaptcha {
+		form.Set("sitekey", v.siteKey)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s returned status %d", ErrUnavailable, v.provider, resp.StatusCode)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%w: invalid %s response: %v", ErrUnavailable, v.provider, err)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(result.ErrorCodes, ","))
+	}
+	return nil
+}
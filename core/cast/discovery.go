@@ -0,0 +1,237 @@
+package cast
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/logger"
+)
+
+// SSDP 多播地址和搜索目标
+const (
+	ssdpAddr = "239.255.255.250:1900"
+
+	searchMediaRenderer = "urn:schemas-upnp-org:device:MediaRenderer:1"
+	searchDial          = "urn:dial-multiscreen-org:service:dial:1" // Chromecast 等 DIAL 设备
+)
+
+// UPnP 服务类型
+const (
+	serviceAVTransport      = "urn:schemas-upnp-org:service:AVTransport:1"
+	serviceRenderingControl = "urn:schemas-upnp-org:service:RenderingControl:1"
+)
+
+// 渲染器类型
+const (
+	TypeDLNA       = "dlna"
+	TypeChromecast = "chromecast"
+)
+
+// descriptionClient 获取设备描述文件的 HTTP 客户端
+var descriptionClient = &http.Client{Timeout: 3 * time.Second}
+
+// Renderer 局域网中的投屏设备
+type Renderer struct {
+	ID           string    `json:"id"` // 设备 UDN
+	Name         string    `json:"name"`
+	Manufacturer string    `json:"manufacturer,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	Type         string    `json:"type"`         // dlna 或 chromecast
+	Address      string    `json:"address"`      // 设备的 host:port
+	Controllable bool      `json:"controllable"` // 是否可由服务端控制，Chromecast 需由客户端通过 Cast SDK 控制
+	LastSeen     time.Time `json:"lastSeen"`
+
+	avTransportURL      string
+	renderingControlURL string
+}
+
+// deviceDescription UPnP 设备描述文件
+type deviceDescription struct {
+	URLBase string        `xml:"URLBase"`
+	Device  deviceElement `xml:"device"`
+}
+
+type deviceElement struct {
+	DeviceType   string           `xml:"deviceType"`
+	FriendlyName string           `xml:"friendlyName"`
+	Manufacturer string           `xml:"manufacturer"`
+	ModelName    string           `xml:"modelName"`
+	UDN          string           `xml:"UDN"`
+	Services     []serviceElement `xml:"serviceList>service"`
+	Devices      []deviceElement  `xml:"deviceList>device"`
+}
+
+type serviceElement struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// Discover 通过 SSDP 搜索局域网中的 DLNA 渲染器和 Chromecast，等待 timeout 后返回
+func Discover(ctx context.Context, timeout time.Duration) ([]*Renderer, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ssdp socket: %w", err)
+	}
+	defer conn.Close()
+
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	mx := int(timeout / time.Second)
+	if mx < 1 {
+		mx = 1
+	}
+	for _, st := range []string{searchMediaRenderer, searchDial} {
+		msg := fmt.Sprintf("M-SEARCH * HTTP/1.1\r\nHOST: %s\r\nMAN: \"ssdp:discover\"\r\nMX: %d\r\nST: %s\r\n\r\n", ssdpAddr, mx, st)
+		if _, err := conn.WriteTo([]byte(msg), dst); err != nil {
+			return nil, fmt.Errorf("failed to send ssdp search: %w", err)
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	// LOCATION -> 搜索目标，同一设备会对多个搜索目标分别响应
+	locations := make(map[string]string)
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break // 读取超时即搜索结束
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		location := resp.Header.Get("Location")
+		if location == "" {
+			continue
+		}
+		if _, seen := locations[location]; !seen || resp.Header.Get("St") == searchMediaRenderer {
+			locations[location] = resp.Header.Get("St")
+		}
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		renderers = make(map[string]*Renderer)
+	)
+	for location, st := range locations {
+		wg.Add(1)
+		go func(location, st string) {
+			defer wg.Done()
+			r, err := describe(ctx, location, st)
+			if err != nil {
+				logger.Debug("获取投屏设备描述失败",
+					logger.String("location", location),
+					logger.ErrorField(err))
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			// 同时响应两种搜索的设备以可控制的 DLNA 描述为准
+			if existing, ok := renderers[r.ID]; !ok || (!existing.Controllable && r.Controllable) {
+				renderers[r.ID] = r
+			}
+		}(location, st)
+	}
+	wg.Wait()
+
+	result := make([]*Renderer, 0, len(renderers))
+	for _, r := range renderers {
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// describe 读取设备描述文件并解析控制地址
+func describe(ctx context.Context, location, st string) (*Renderer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := descriptionClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var desc deviceDescription
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&desc); err != nil {
+		return nil, fmt.Errorf("invalid device description: %w", err)
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if desc.URLBase != "" {
+		if u, err := url.Parse(desc.URLBase); err == nil {
+			base = u
+		}
+	}
+
+	dev := desc.Device
+	r := &Renderer{
+		ID:           strings.TrimPrefix(dev.UDN, "uuid:"),
+		Name:         dev.FriendlyName,
+		Manufacturer: dev.Manufacturer,
+		Model:        dev.ModelName,
+		Type:         TypeDLNA,
+		Address:      base.Host,
+		LastSeen:     time.Now(),
+	}
+	if r.ID == "" {
+		r.ID = location
+	}
+	if r.Name == "" {
+		r.Name = r.Address
+	}
+
+	for _, svc := range collectServices(dev) {
+		controlURL, err := base.Parse(svc.ControlURL)
+		if err != nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(svc.ServiceType, strings.TrimSuffix(serviceAVTransport, "1")):
+			r.avTransportURL = controlURL.String()
+		case strings.HasPrefix(svc.ServiceType, strings.TrimSuffix(serviceRenderingControl, "1")):
+			r.renderingControlURL = controlURL.String()
+		}
+	}
+	r.Controllable = r.avTransportURL != ""
+
+	if !r.Controllable && (st == searchDial || strings.Contains(strings.ToLower(dev.ModelName), "chromecast")) {
+		r.Type = TypeChromecast
+	}
+	return r, nil
+}
+
+// collectServices 收集设备及其嵌入设备的服务
+func collectServices(dev deviceElement) []serviceElement {
+	services := append([]serviceElement(nil), dev.Services...)
+	for _, child := range dev.Devices {
+		services = append(services, collectServices(child)...)
+	}
+	return services
+}
@@ -0,0 +1,162 @@
+package cast
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnsupported 渲染器不支持由服务端控制（如 Chromecast），需由客户端通过 Cast SDK 投屏
+var ErrUnsupported = errors.New("renderer does not support server-side control")
+
+// controlClient 发送 SOAP 控制请求的 HTTP 客户端
+var controlClient = &http.Client{Timeout: 5 * time.Second}
+
+// Media 投屏的媒体信息
+type Media struct {
+	URL      string
+	MimeType string
+	Title    string
+	Artist   string
+	Album    string
+	Cover    string
+	Duration int // 秒
+}
+
+// Load 设置渲染器要播放的媒体，随后调用 Play 开始播放
+func (r *Renderer) Load(ctx context.Context, media Media) error {
+	return r.avTransport(ctx, "SetAVTransportURI",
+		"CurrentURI", media.URL,
+		"CurrentURIMetaData", didlLite(media))
+}
+
+// Play 开始或继续播放
+func (r *Renderer) Play(ctx context.Context) error {
+	return r.avTransport(ctx, "Play", "Speed", "1")
+}
+
+// Pause 暂停
+func (r *Renderer) Pause(ctx context.Context) error {
+	return r.avTransport(ctx, "Pause")
+}
+
+// Stop 停止播放
+func (r *Renderer) Stop(ctx context.Context) error {
+	return r.avTransport(ctx, "Stop")
+}
+
+// Seek 跳转到指定位置（秒）
+func (r *Renderer) Seek(ctx context.Context, position float64) error {
+	return r.avTransport(ctx, "Seek", "Unit", "REL_TIME", "Target", formatDuration(position))
+}
+
+// SetVolume 设置音量（0-100）
+func (r *Renderer) SetVolume(ctx context.Context, volume int) error {
+	if !r.Controllable {
+		return ErrUnsupported
+	}
+	if r.renderingControlURL == "" {
+		return fmt.Errorf("renderer has no rendering control service")
+	}
+	return soapCall(ctx, r.renderingControlURL, serviceRenderingControl, "SetVolume",
+		"InstanceID", "0",
+		"Channel", "Master",
+		"DesiredVolume", strconv.Itoa(volume))
+}
+
+// avTransport 调用 AVTransport 服务的动作，参数为键值交替排列
+func (r *Renderer) avTransport(ctx context.Context, action string, args ...string) error {
+	if !r.Controllable {
+		return ErrUnsupported
+	}
+	return soapCall(ctx, r.avTransportURL, serviceAVTransport, action, append([]string{"InstanceID", "0"}, args...)...)
+}
+
+// soapFault UPnP 错误响应
+type soapFault struct {
+	Code        int    `xml:"Body>Fault>detail>UPnPError>errorCode"`
+	Description string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+}
+
+// soapCall 发送 SOAP 请求
+func soapCall(ctx context.Context, controlURL, service, action string, args ...string) error {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	body.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, service)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&body, "<%s>", args[i])
+		xml.EscapeText(&body, []byte(args[i+1]))
+		fmt.Fprintf(&body, "</%s>", args[i])
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, controlURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, service, action))
+
+	resp, err := controlClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode != http.StatusOK {
+		var fault soapFault
+		if xml.Unmarshal(respBody, &fault) == nil && fault.Code != 0 {
+			return fmt.Errorf("%s failed: upnp error %d %s", action, fault.Code, fault.Description)
+		}
+		return fmt.Errorf("%s failed: unexpected status %d", action, resp.StatusCode)
+	}
+	return nil
+}
+
+// didlLite 生成 SetAVTransportURI 使用的 DIDL-Lite 元数据
+func didlLite(media Media) string {
+	var b strings.Builder
+	b.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">`)
+	b.WriteString(`<item id="0" parentID="-1" restricted="1">`)
+	writeElement(&b, "dc:title", media.Title)
+	writeElement(&b, "dc:creator", media.Artist)
+	writeElement(&b, "upnp:artist", media.Artist)
+	writeElement(&b, "upnp:album", media.Album)
+	writeElement(&b, "upnp:albumArtURI", media.Cover)
+	b.WriteString(`<upnp:class>object.item.audioItem.musicTrack</upnp:class>`)
+	b.WriteString(`<res protocolInfo="http-get:*:`)
+	xml.EscapeText(&b, []byte(media.MimeType))
+	b.WriteString(`:*"`)
+	if media.Duration > 0 {
+		fmt.Fprintf(&b, ` duration="%s"`, formatDuration(float64(media.Duration)))
+	}
+	b.WriteString(`>`)
+	xml.EscapeText(&b, []byte(media.URL))
+	b.WriteString(`</res></item></DIDL-Lite>`)
+	return b.String()
+}
+
+// writeElement 写入非空的 XML 元素
+func writeElement(b *strings.Builder, name, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(b, "<%s>", name)
+	xml.EscapeText(b, []byte(value))
+	fmt.Fprintf(b, "</%s>", name)
+}
+
+// formatDuration 将秒数格式化为 H:MM:SS
+func formatDuration(seconds float64) string {
+	total := int(seconds)
+	return fmt.Sprintf("%d:%02d:%02d", total/3600, total%3600/60, total%60)
+}
@@ -0,0 +1,92 @@
+package cast
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"Bt1QFM/logger"
+)
+
+// rendererTTL 超过该时间未被重新发现的渲染器视为离线
+const rendererTTL = 10 * time.Minute
+
+// Registry 缓存最近发现的渲染器，发现过程较慢，列表接口默认直接返回缓存
+type Registry struct {
+	timeout time.Duration
+
+	mu        sync.RWMutex
+	renderers map[string]*Renderer
+	lastScan  time.Time
+
+	// 同一时间只进行一次搜索
+	scanMu sync.Mutex
+}
+
+// NewRegistry 创建渲染器注册表，timeout 为每次 SSDP 搜索的等待时间
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	return &Registry{
+		timeout:   timeout,
+		renderers: make(map[string]*Renderer),
+	}
+}
+
+// Refresh 重新搜索局域网中的渲染器，返回当前在线的渲染器
+func (reg *Registry) Refresh(ctx context.Context) ([]*Renderer, error) {
+	reg.scanMu.Lock()
+	defer reg.scanMu.Unlock()
+
+	found, err := Discover(ctx, reg.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reg.mu.Lock()
+	for _, r := range found {
+		reg.renderers[r.ID] = r
+	}
+	for id, r := range reg.renderers {
+		if now.Sub(r.LastSeen) > rendererTTL {
+			delete(reg.renderers, id)
+		}
+	}
+	reg.lastScan = now
+	reg.mu.Unlock()
+
+	logger.Info("投屏设备搜索完成", logger.Int("found", len(found)))
+	return reg.List(), nil
+}
+
+// List 返回缓存的渲染器，按名称排序
+func (reg *Registry) List() []*Renderer {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	result := make([]*Renderer, 0, len(reg.renderers))
+	for _, r := range reg.renderers {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Get 按 ID 获取渲染器，未找到时返回 nil
+func (reg *Registry) Get(id string) *Renderer {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.renderers[id]
+}
+
+// LastScan 最近一次搜索的时间
+func (reg *Registry) LastScan() time.Time {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.lastScan
+}
@@ -0,0 +1,294 @@
+package cover
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+	"Bt1QFM/repository"
+	"Bt1QFM/storage"
+)
+
+const (
+	// KindTrack 曲目封面
+	KindTrack = "track"
+	// KindAlbum 专辑封面
+	KindAlbum = "album"
+
+	// maxCoverSize 下载封面的最大字节数
+	maxCoverSize = 10 << 20
+	// sweepInterval 扫描缺失封面的间隔
+	sweepInterval = time.Hour
+	// sweepBatchSize 每次扫描处理的最大条目数
+	sweepBatchSize = 50
+	// retryAfter 同一条目未找到封面后的重试间隔
+	retryAfter = 24 * time.Hour
+	// queueSize 任务队列长度
+	queueSize = 256
+)
+
+// Job 封面获取任务
+type Job struct {
+	Kind  string
+	ID    int64
+	Query Query
+}
+
+// key 用于去重的任务标识
+func (j Job) key() string {
+	return fmt.Sprintf("%s:%d", j.Kind, j.ID)
+}
+
+// Fetcher 后台封面获取服务
+// 为没有封面的曲目和专辑依次查询外部来源，下载后存入 MinIO 并更新封面路径
+type Fetcher struct {
+	trackRepo  repository.TrackRepository
+	albumRepo  repository.AlbumRepository
+	providers  []Provider
+	httpClient *http.Client
+	cfg        *config.Config
+
+	queue chan Job
+
+	attemptedMu sync.Mutex
+	attempted   map[string]time.Time // 最近一次尝试时间，避免反复请求外部接口
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewFetcher 创建封面获取服务，按 providers 顺序查询
+func NewFetcher(trackRepo repository.TrackRepository, albumRepo repository.AlbumRepository, providers []Provider, cfg *config.Config) *Fetcher {
+	return &Fetcher{
+		trackRepo:  trackRepo,
+		albumRepo:  albumRepo,
+		providers:  providers,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+		cfg:        cfg,
+		queue:      make(chan Job, queueSize),
+		attempted:  make(map[string]time.Time),
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// Start 启动后台 worker 和定期扫描
+func (f *Fetcher) Start() {
+	logger.Info("封面获取服务启动", logger.Int("providers", len(f.providers)))
+
+	f.wg.Add(2)
+	go f.worker()
+	go f.sweeper()
+}
+
+// Stop 停止服务
+func (f *Fetcher) Stop() {
+	close(f.stopChan)
+	f.wg.Wait()
+}
+
+// Enqueue 提交封面获取任务，队列已满或近期已尝试时忽略
+func (f *Fetcher) Enqueue(job Job) {
+	if f == nil || (job.Query.Artist == "" && job.Query.Album == "" && job.Query.Title == "") {
+		return
+	}
+	if !f.markAttempt(job.key()) {
+		return
+	}
+	select {
+	case f.queue <- job:
+	default:
+		logger.Warn("封面获取队列已满，丢弃任务", logger.String("job", job.key()))
+	}
+}
+
+// markAttempt 记录尝试时间，返回是否允许本次尝试
+func (f *Fetcher) markAttempt(key string) bool {
+	f.attemptedMu.Lock()
+	defer f.attemptedMu.Unlock()
+
+	if last, ok := f.attempted[key]; ok && time.Since(last) < retryAfter {
+		return false
+	}
+	f.attempted[key] = time.Now()
+	return true
+}
+
+// worker 串行处理任务，避免对外部接口造成压力
+func (f *Fetcher) worker() {
+	defer f.wg.Done()
+
+	for {
+		select {
+		case <-f.stopChan:
+			return
+		case job := <-f.queue:
+			if err := f.process(job); err != nil {
+				logger.Warn("获取封面失败",
+					logger.String("job", job.key()),
+					logger.ErrorField(err))
+			}
+		}
+	}
+}
+
+// sweeper 定期扫描缺失封面的曲目和专辑
+func (f *Fetcher) sweeper() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	f.sweep()
+	for {
+		select {
+		case <-f.stopChan:
+			return
+		case <-ticker.C:
+			f.sweep()
+		}
+	}
+}
+
+// sweep 将缺失封面且近期未尝试过的条目加入队列，始终找不到封面的条目不会挡住后面的条目
+func (f *Fetcher) sweep() {
+	attemptedBefore := time.Now().Add(-retryAfter)
+	tracks, err := f.trackRepo.GetTracksWithoutCover(context.Background(), attemptedBefore, sweepBatchSize)
+	if err != nil {
+		logger.Warn("查询缺失封面的曲目失败", logger.ErrorField(err))
+	}
+	for _, t := range tracks {
+		f.Enqueue(Job{Kind: KindTrack, ID: t.ID, Query: Query{Artist: t.Artist, Album: t.Album, Title: t.Title}})
+	}
+
+	albums, err := f.albumRepo.GetAlbumsWithoutCover(context.Background(), attemptedBefore, sweepBatchSize)
+	if err != nil {
+		logger.Warn("查询缺失封面的专辑失败", logger.ErrorField(err))
+	}
+	for _, a := range albums {
+		f.Enqueue(Job{Kind: KindAlbum, ID: a.ID, Query: Query{Artist: a.Artist, Album: a.Name}})
+	}
+}
+
+// process 依次查询来源，下载第一个找到的封面并保存
+func (f *Fetcher) process(job Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := f.markAttempted(ctx, job); err != nil {
+		logger.Warn("记录封面获取时间失败", logger.String("job", job.key()), logger.ErrorField(err))
+	}
+
+	for _, p := range f.providers {
+		coverURL, err := p.FindCover(ctx, job.Query)
+		if err != nil {
+			logger.Debug("封面来源查询失败",
+				logger.String("provider", p.Name()),
+				logger.String("job", job.key()),
+				logger.ErrorField(err))
+			continue
+		}
+		if coverURL == "" {
+			continue
+		}
+
+		servePath, err := f.store(ctx, job, coverURL)
+		if err != nil {
+			logger.Debug("下载封面失败",
+				logger.String("provider", p.Name()),
+				logger.String("url", coverURL),
+				logger.ErrorField(err))
+			continue
+		}
+
+		if err := f.updateCoverPath(ctx, job, servePath); err != nil {
+			return err
+		}
+		logger.Info("自动获取封面成功",
+			logger.String("job", job.key()),
+			logger.String("provider", p.Name()),
+			logger.String("path", servePath))
+		return nil
+	}
+
+	logger.Debug("所有来源均未找到封面", logger.String("job", job.key()))
+	return nil
+}
+
+// store 下载封面并上传到 MinIO，返回服务路径
+func (f *Fetcher) store(ctx context.Context, job Job, coverURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, coverURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "image/") {
+		return "", fmt.Errorf("不是图片: %s", contentType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCoverSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxCoverSize {
+		return "", fmt.Errorf("封面过大")
+	}
+
+	ext := ".jpg"
+	switch contentType {
+	case "image/png":
+		ext = ".png"
+	case "image/webp":
+		ext = ".webp"
+	}
+	objectPath := fmt.Sprintf("covers/auto/%s_%d%s", job.Kind, job.ID, ext)
+
+	store := storage.GetStorage()
+	if store == nil {
+		return "", fmt.Errorf("storage not initialized")
+	}
+	if err := store.Put(ctx, objectPath, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return "", fmt.Errorf("failed to upload to storage: %v", err)
+	}
+	return "/static/" + objectPath, nil
+}
+
+// markAttempted 在数据库中记录本次尝试时间，扫描时据此跳过近期已尝试的条目
+func (f *Fetcher) markAttempted(ctx context.Context, job Job) error {
+	switch job.Kind {
+	case KindTrack:
+		return f.trackRepo.MarkTrackCoverAttempted(ctx, job.ID)
+	case KindAlbum:
+		return f.albumRepo.MarkAlbumCoverAttempted(ctx, job.ID)
+	}
+	return fmt.Errorf("未知的封面任务类型: %s", job.Kind)
+}
+
+// updateCoverPath 更新数据库中的封面路径
+func (f *Fetcher) updateCoverPath(ctx context.Context, job Job, servePath string) error {
+	switch job.Kind {
+	case KindTrack:
+		return f.trackRepo.UpdateTrackCoverArtPath(ctx, job.ID, servePath)
+	case KindAlbum:
+		return f.albumRepo.UpdateAlbumCoverPath(ctx, job.ID, servePath)
+	}
+	return fmt.Errorf("未知的封面任务类型: %s", job.Kind)
+}
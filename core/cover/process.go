@@ -0,0 +1,240 @@
+package cover
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const (
+	// MinCoverDimension 封面最短边的最小像素数
+	MinCoverDimension = 64
+	// MaxCoverDimension 封面最长边的最大像素数
+	MaxCoverDimension = 8000
+
+	// webpQuality WebP 编码质量
+	webpQuality = "80"
+	// jpegQuality JPEG 编码质量（ffmpeg -q:v，越小越好）
+	jpegQuality = "3"
+)
+
+// CoverSizes 生成的封面尺寸（最长边像素数）
+var CoverSizes = []int{64, 256, 1024}
+
+// Variant 处理后的单个封面文件
+type Variant struct {
+	Size        int    // 目标尺寸
+	Width       int    // 实际宽度
+	Height      int    // 实际高度
+	Format      string // webp / jpeg
+	ContentType string
+	Data        []byte
+}
+
+// FileName 变体在 covers/{hash}/ 目录下的文件名
+func (v *Variant) FileName() string {
+	if v.Format == "webp" {
+		return fmt.Sprintf("%d.webp", v.Size)
+	}
+	return fmt.Sprintf("%d.jpg", v.Size)
+}
+
+// ProcessedCover 封面处理结果
+type ProcessedCover struct {
+	Hash     string // 原图内容的 SHA-256
+	Width    int
+	Height   int
+	Variants []*Variant
+}
+
+// ObjectPath 返回变体在 MinIO 中的对象路径
+func (p *ProcessedCover) ObjectPath(v *Variant) string {
+	return p.ObjectPathIn("covers/", v)
+}
+
+// ObjectPathIn 返回变体在 dir 下的对象路径，dir 以 / 结尾
+func (p *ProcessedCover) ObjectPathIn(dir string, v *Variant) string {
+	return fmt.Sprintf("%s%s/%s", dir, p.Hash, v.FileName())
+}
+
+// ImageDimensions 读取图片尺寸和格式，支持 JPEG、PNG、GIF 和 WebP
+func ImageDimensions(data []byte) (int, int, string, error) {
+	if cfg, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		return cfg.Width, cfg.Height, format, nil
+	}
+	if w, h, ok := webpDimensions(data); ok {
+		return w, h, "webp", nil
+	}
+	return 0, 0, "", fmt.Errorf("不支持的图片格式")
+}
+
+// ValidateDimensions 校验封面尺寸是否在允许范围内
+func ValidateDimensions(width, height int) error {
+	if width < MinCoverDimension || height < MinCoverDimension {
+		return fmt.Errorf("图片尺寸过小，最短边至少 %d 像素", MinCoverDimension)
+	}
+	if width > MaxCoverDimension || height > MaxCoverDimension {
+		return fmt.Errorf("图片尺寸过大，最长边不能超过 %d 像素", MaxCoverDimension)
+	}
+	return nil
+}
+
+// ProcessCover 校验原图并生成各尺寸的 WebP 和 JPEG 变体
+// 通过 FFmpeg 重新编码，输出中不保留 EXIF 等元数据；不放大小于目标尺寸的图片
+func ProcessCover(ctx context.Context, ffmpegPath string, data []byte) (*ProcessedCover, error) {
+	width, height, _, err := ImageDimensions(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateDimensions(width, height); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	result := &ProcessedCover{
+		Hash:   hex.EncodeToString(sum[:]),
+		Width:  width,
+		Height: height,
+	}
+
+	workDir, err := os.MkdirTemp("", "cover-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "source")
+	if err := os.WriteFile(inputPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("写入临时文件失败: %w", err)
+	}
+
+	longest := width
+	if height > longest {
+		longest = height
+	}
+	for _, size := range CoverSizes {
+		if size > longest {
+			break
+		}
+		w, h := scaledDimensions(width, height, size)
+		for _, format := range []string{"webp", "jpeg"} {
+			variant := &Variant{Size: size, Width: w, Height: h, Format: format}
+			outputPath := filepath.Join(workDir, variant.FileName())
+			if err := encodeVariant(ctx, ffmpegPath, inputPath, outputPath, w, h, format); err != nil {
+				return nil, err
+			}
+			if variant.Data, err = os.ReadFile(outputPath); err != nil {
+				return nil, fmt.Errorf("读取处理结果失败: %w", err)
+			}
+			variant.ContentType = "image/" + format
+			result.Variants = append(result.Variants, variant)
+		}
+	}
+	return result, nil
+}
+
+// ExtractEmbeddedCover 用 FFmpeg 取出音频文件内嵌的封面（第一个视频流），按原编码输出，不重新压缩
+// 文件没有内嵌封面时返回错误
+func ExtractEmbeddedCover(ctx context.Context, ffmpegPath, audioPath string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-v", "error",
+		"-i", audioPath,
+		"-map", "0:v:0",
+		"-an",
+		"-frames:v", "1",
+		"-c:v", "copy",
+		"-f", "image2pipe",
+		"-",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("FFmpeg提取内嵌封面失败: %w\nFFmpeg Error: %s", err, stderr.String())
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("音频文件没有内嵌封面")
+	}
+	return stdout.Bytes(), nil
+}
+
+// scaledDimensions 按最长边等比缩放到 size
+func scaledDimensions(width, height, size int) (int, int) {
+	if width >= height {
+		h := height * size / width
+		if h < 1 {
+			h = 1
+		}
+		return size, h
+	}
+	w := width * size / height
+	if w < 1 {
+		w = 1
+	}
+	return w, size
+}
+
+// encodeVariant 调用 FFmpeg 缩放并编码为指定格式，丢弃全部元数据
+func encodeVariant(ctx context.Context, ffmpegPath, inputPath, outputPath string, width, height int, format string) error {
+	args := []string{
+		"-v", "error", "-y",
+		"-i", inputPath,
+		"-frames:v", "1",
+		"-map_metadata", "-1",
+		"-vf", fmt.Sprintf("scale=%d:%d:flags=lanczos", width, height),
+	}
+	switch format {
+	case "webp":
+		args = append(args, "-c:v", "libwebp", "-quality", webpQuality, "-f", "webp")
+	default:
+		args = append(args, "-c:v", "mjpeg", "-q:v", jpegQuality, "-pix_fmt", "yuvj420p", "-f", "image2")
+	}
+	args = append(args, outputPath)
+
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("FFmpeg处理封面失败: %w\nFFmpeg Error: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// webpDimensions 解析 WebP 文件头中的画布尺寸（VP8 / VP8L / VP8X）
+func webpDimensions(data []byte) (int, int, bool) {
+	if len(data) < 30 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, false
+	}
+	chunk := data[12:]
+	switch string(chunk[0:4]) {
+	case "VP8 ":
+		// 关键帧头之后为 14 位宽高
+		if chunk[11] != 0x9d || chunk[12] != 0x01 || chunk[13] != 0x2a {
+			return 0, 0, false
+		}
+		w := int(binary.LittleEndian.Uint16(chunk[14:16]) & 0x3fff)
+		h := int(binary.LittleEndian.Uint16(chunk[16:18]) & 0x3fff)
+		return w, h, true
+	case "VP8L":
+		if chunk[8] != 0x2f {
+			return 0, 0, false
+		}
+		bits := binary.LittleEndian.Uint32(chunk[9:13])
+		return int(bits&0x3fff) + 1, int((bits>>14)&0x3fff) + 1, true
+	case "VP8X":
+		w := int(chunk[12]) | int(chunk[13])<<8 | int(chunk[14])<<16
+		h := int(chunk[15]) | int(chunk[16])<<8 | int(chunk[17])<<16
+		return w + 1, h + 1, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,163 @@
+package cover
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"Bt1QFM/core/netease"
+)
+
+// Query 封面查询条件
+type Query struct {
+	Artist string
+	Album  string
+	Title  string
+}
+
+// keyword 组合搜索关键词，优先使用专辑名
+func (q Query) keyword() string {
+	name := q.Album
+	if name == "" {
+		name = q.Title
+	}
+	return strings.TrimSpace(strings.TrimSpace(q.Artist) + " " + strings.TrimSpace(name))
+}
+
+// Provider 外部封面来源，返回图片 URL，未找到时返回空字符串
+type Provider interface {
+	Name() string
+	FindCover(ctx context.Context, q Query) (string, error)
+}
+
+// userAgent 部分外部接口（MusicBrainz）要求提供可识别的 User-Agent
+const userAgent = "Bt1QFM/1.0 (cover fetcher)"
+
+// getJSON 发送 GET 请求并解析 JSON 响应
+func getJSON(ctx context.Context, client *http.Client, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, rawURL)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// NeteaseProvider 通过网易云搜索获取专辑封面
+type NeteaseProvider struct {
+	client *netease.Client
+}
+
+// NewNeteaseProvider 创建网易云封面来源
+func NewNeteaseProvider(client *netease.Client) *NeteaseProvider {
+	return &NeteaseProvider{client: client}
+}
+
+// Name 来源名称
+func (p *NeteaseProvider) Name() string { return "netease" }
+
+// FindCover 搜索最匹配的歌曲并返回其专辑封面
+func (p *NeteaseProvider) FindCover(ctx context.Context, q Query) (string, error) {
+	keyword := q.keyword()
+	if q.Title != "" && q.Album == "" {
+		keyword = strings.TrimSpace(q.Artist + " " + q.Title)
+	}
+	result, err := p.client.SearchSongs(keyword, 1, 0, nil, "")
+	if err != nil {
+		return "", err
+	}
+	if len(result.Songs) == 0 {
+		return "", nil
+	}
+	return result.Songs[0].Album.PicURL, nil
+}
+
+// ITunesProvider 通过 iTunes Search API 获取封面
+type ITunesProvider struct {
+	client *http.Client
+}
+
+// NewITunesProvider 创建 iTunes 封面来源
+func NewITunesProvider(client *http.Client) *ITunesProvider {
+	return &ITunesProvider{client: client}
+}
+
+// Name 来源名称
+func (p *ITunesProvider) Name() string { return "itunes" }
+
+// FindCover 搜索专辑并返回 600x600 封面
+func (p *ITunesProvider) FindCover(ctx context.Context, q Query) (string, error) {
+	params := url.Values{}
+	params.Set("term", q.keyword())
+	params.Set("entity", "album")
+	params.Set("limit", "1")
+
+	var result struct {
+		Results []struct {
+			ArtworkURL100 string `json:"artworkUrl100"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, p.client, "https://itunes.apple.com/search?"+params.Encode(), &result); err != nil {
+		return "", err
+	}
+	if len(result.Results) == 0 || result.Results[0].ArtworkURL100 == "" {
+		return "", nil
+	}
+	return strings.Replace(result.Results[0].ArtworkURL100, "100x100", "600x600", 1), nil
+}
+
+// MusicBrainzProvider 通过 MusicBrainz 查询发行版，再从 Cover Art Archive 获取封面
+type MusicBrainzProvider struct {
+	client *http.Client
+}
+
+// NewMusicBrainzProvider 创建 MusicBrainz 封面来源
+func NewMusicBrainzProvider(client *http.Client) *MusicBrainzProvider {
+	return &MusicBrainzProvider{client: client}
+}
+
+// Name 来源名称
+func (p *MusicBrainzProvider) Name() string { return "musicbrainz" }
+
+// FindCover 查询最匹配的发行版并返回 Cover Art Archive 封面地址
+func (p *MusicBrainzProvider) FindCover(ctx context.Context, q Query) (string, error) {
+	if q.Album == "" {
+		return "", nil
+	}
+	query := fmt.Sprintf(`release:"%s"`, q.Album)
+	if q.Artist != "" {
+		query += fmt.Sprintf(` AND artist:"%s"`, q.Artist)
+	}
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("limit", "1")
+	params.Set("fmt", "json")
+
+	var result struct {
+		Releases []struct {
+			ID    string `json:"id"`
+			Score int    `json:"score"`
+		} `json:"releases"`
+	}
+	if err := getJSON(ctx, p.client, "https://musicbrainz.org/ws/2/release/?"+params.Encode(), &result); err != nil {
+		return "", err
+	}
+	if len(result.Releases) == 0 || result.Releases[0].Score < 90 {
+		return "", nil
+	}
+	return fmt.Sprintf("https://coverartarchive.org/release/%s/front-500", result.Releases[0].ID), nil
+}
@@ -0,0 +1,366 @@
+package dailymix
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/netease"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+const (
+	// maxMixes 每位用户最多生成的歌单数
+	maxMixes = 3
+	// mixSize 每个歌单最多的歌曲数
+	mixSize = 30
+	// familiarPerMix 每个歌单中用户听过的歌曲数，其余为新发现的歌曲
+	familiarPerMix = 12
+	// similarArtistsPerMix 每个歌单参考的相似歌手数
+	similarArtistsPerMix = 4
+	// songsPerArtist 每位歌手取的热门歌曲数
+	songsPerArtist = 4
+	// statsLimit 读取的常听歌曲数
+	statsLimit = 300
+	// minSeedWeight 作为歌单种子的歌手至少需要的收听权重（听完一次计 3，开始播放计 1）
+	minSeedWeight = 3
+	// RefreshCooldown 手动刷新的最短间隔，避免频繁请求网易云
+	RefreshCooldown = 10 * time.Minute
+	// mixTTL 歌单的过期时间，覆盖两次生成，某晚生成失败时仍可使用上次的结果
+	mixTTL = 48 * time.Hour
+)
+
+// Service Daily Mix 服务：每晚为最近有收听记录的用户生成 2～3 个推荐歌单并保存到 Redis
+// 每个歌单以一位常听的歌手为种子，由用户听过的歌曲和该歌手及网易云相似歌手的热门歌曲交替组成
+type Service struct {
+	historyRepo repository.PlayHistoryRepository
+	client      *netease.Client
+	cfg         *config.Config
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewService 创建 Daily Mix 服务
+func NewService(historyRepo repository.PlayHistoryRepository, client *netease.Client, cfg *config.Config) *Service {
+	return &Service{
+		historyRepo: historyRepo,
+		client:      client,
+		cfg:         cfg,
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Enabled 是否每晚生成歌单
+func (s *Service) Enabled() bool {
+	return s.cfg.DailyMixHour >= 0 && s.cfg.DailyMixHour <= 23
+}
+
+// Start 启动每日任务，DAILY_MIX_HOUR 为 -1 时不启动，用户仍可手动刷新
+func (s *Service) Start() {
+	if !s.Enabled() {
+		logger.Info("Daily Mix 定时生成未启用")
+		return
+	}
+	logger.Info("Daily Mix 服务启动", logger.Int("hour", s.cfg.DailyMixHour))
+
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Stop 停止每日任务
+func (s *Service) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// loop 等待到每天的生成时间后执行
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	for {
+		timer := time.NewTimer(time.Until(nextRun(time.Now(), s.cfg.DailyMixHour)))
+		select {
+		case <-s.stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+			generated, err := s.RunOnce(context.Background(), time.Now())
+			if err != nil {
+				logger.Warn("生成 Daily Mix 失败", logger.ErrorField(err))
+				continue
+			}
+			logger.Info("Daily Mix 生成完成", logger.Int("users", generated))
+		}
+	}
+}
+
+// nextRun 计算下一次执行时间
+func nextRun(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.Add(24 * time.Hour)
+	}
+	return next
+}
+
+// RunOnce 为统计窗口内有收听记录的所有用户重新生成歌单，返回生成了歌单的用户数
+func (s *Service) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	window := time.Duration(max(s.cfg.DailyMixWindowDays, 1)) * 24 * time.Hour
+	userIDs, err := s.historyRepo.GetActiveListeners(ctx, now.Add(-window))
+	if err != nil {
+		return 0, err
+	}
+
+	generated := 0
+	for _, userID := range userIDs {
+		select {
+		case <-s.stopChan:
+			return generated, nil
+		default:
+		}
+		mixes, err := s.Generate(ctx, userID, now)
+		if err != nil {
+			logger.Warn("生成用户的 Daily Mix 失败", logger.Int64("userId", userID), logger.ErrorField(err))
+			continue
+		}
+		if len(mixes) > 0 {
+			generated++
+		}
+	}
+	return generated, nil
+}
+
+// ForUser 返回用户最近一次生成的歌单，还没有生成时返回空列表
+func (s *Service) ForUser(ctx context.Context, userID int64) ([]model.DailyMix, error) {
+	mixes, err := cache.GetDailyMixes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mixes == nil {
+		mixes = []model.DailyMix{}
+	}
+	return mixes, nil
+}
+
+// Refresh 立即重新生成用户的歌单；距上次生成不足 RefreshCooldown 时直接返回上次的结果
+func (s *Service) Refresh(ctx context.Context, userID int64, now time.Time) ([]model.DailyMix, error) {
+	mixes, err := cache.GetDailyMixes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(mixes) > 0 && now.Sub(mixes[0].GeneratedAt) < RefreshCooldown {
+		return mixes, nil
+	}
+	return s.Generate(ctx, userID, now)
+}
+
+// artistStat 用户对一位歌手的收听情况
+type artistStat struct {
+	name   string
+	weight int
+	songs  []*model.PlayStat
+}
+
+// Generate 根据用户的收听历史生成歌单并保存，收听记录太少时不生成
+func (s *Service) Generate(ctx context.Context, userID int64, now time.Time) ([]model.DailyMix, error) {
+	stats, err := s.historyRepo.GetPlayStats(ctx, userID, "", statsLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	// 听过的歌曲不作为新发现的歌曲
+	exclude := make(map[string]bool, len(stats)*2)
+	byArtist := make(map[string]*artistStat)
+	for _, stat := range stats {
+		exclude[stat.Source+":"+stat.SourceID] = true
+		exclude[songKey(stat.Title, stat.Artist)] = true
+
+		name := primaryArtist(stat.Artist)
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		a := byArtist[key]
+		if a == nil {
+			a = &artistStat{name: name}
+			byArtist[key] = a
+		}
+		a.weight += stat.Completed*3 + (stat.Plays - stat.Completed)
+		a.songs = append(a.songs, stat)
+	}
+
+	ranked := make([]*artistStat, 0, len(byArtist))
+	for _, a := range byArtist {
+		ranked = append(ranked, a)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].weight != ranked[j].weight {
+			return ranked[i].weight > ranked[j].weight
+		}
+		return ranked[i].name < ranked[j].name
+	})
+	var seeds []*artistStat
+	for _, a := range ranked {
+		if len(seeds) == maxMixes || a.weight < minSeedWeight {
+			break
+		}
+		seeds = append(seeds, a)
+	}
+	if len(seeds) == 0 {
+		return nil, nil
+	}
+
+	// 种子歌手以外的常听歌曲轮流补充到各个歌单
+	var others []*model.PlayStat
+	for _, a := range ranked[len(seeds):] {
+		others = append(others, a.songs...)
+	}
+	sort.SliceStable(others, func(i, j int) bool {
+		return others[i].Completed > others[j].Completed
+	})
+
+	mixes := make([]model.DailyMix, 0, len(seeds))
+	for i, seed := range seeds {
+		var familiar []model.DailyMixItem
+		for _, stat := range seed.songs {
+			if len(familiar) == familiarPerMix {
+				break
+			}
+			familiar = append(familiar, familiarItem(stat))
+		}
+		for j := i; j < len(others) && len(familiar) < familiarPerMix; j += len(seeds) {
+			familiar = append(familiar, familiarItem(others[j]))
+		}
+
+		discovered, artists := s.discover(seed.name, exclude)
+		items := interleave(familiar, discovered, mixSize)
+		if len(items) == 0 {
+			continue
+		}
+		if len(artists) == 0 {
+			artists = []string{seed.name}
+		}
+		mixes = append(mixes, model.DailyMix{
+			ID:          len(mixes) + 1,
+			Name:        fmt.Sprintf("Daily Mix %d", len(mixes)+1),
+			SeedArtist:  seed.name,
+			Artists:     artists,
+			Items:       items,
+			GeneratedAt: now,
+		})
+	}
+
+	if err := cache.SetDailyMixes(ctx, userID, mixes, mixTTL); err != nil {
+		return nil, err
+	}
+	return mixes, nil
+}
+
+// discover 取种子歌手及其相似歌手的热门歌曲中用户没有听过的歌曲，返回歌曲和涉及的歌手
+// 网易云请求失败时返回已取到的部分，相似歌手接口需要登录，失败时只使用种子歌手的热门歌曲
+func (s *Service) discover(seed string, exclude map[string]bool) ([]model.DailyMixItem, []string) {
+	info, err := s.client.SearchArtist(seed)
+	if err != nil {
+		logger.Debug("Daily Mix 搜索歌手失败", logger.String("artist", seed), logger.ErrorField(err))
+		return nil, nil
+	}
+	artists := []model.NeteaseArtist{{ID: info.ID, Name: info.Name}}
+	similar, err := s.client.GetSimilarArtists(info.ID)
+	if err != nil {
+		logger.Debug("Daily Mix 获取相似歌手失败", logger.String("artist", seed), logger.ErrorField(err))
+	}
+	artists = append(artists, similar[:min(len(similar), similarArtistsPerMix)]...)
+
+	var items []model.DailyMixItem
+	var names []string
+	for _, artist := range artists {
+		songs, err := s.client.GetArtistTopSongs(strconv.FormatInt(artist.ID, 10))
+		if err != nil {
+			continue
+		}
+		taken := 0
+		for _, song := range songs {
+			if taken == songsPerArtist {
+				break
+			}
+			item := neteaseItem(song)
+			if exclude[item.Source+":"+item.SourceID] || exclude[songKey(item.Title, item.Artist)] {
+				continue
+			}
+			exclude[item.Source+":"+item.SourceID] = true
+			exclude[songKey(item.Title, item.Artist)] = true
+			items = append(items, item)
+			taken++
+		}
+		if taken > 0 {
+			names = append(names, artist.Name)
+		}
+	}
+	return items, names
+}
+
+// interleave 交替合并听过的歌曲和新发现的歌曲，最多 limit 首
+func interleave(familiar, discovered []model.DailyMixItem, limit int) []model.DailyMixItem {
+	items := make([]model.DailyMixItem, 0, min(len(familiar)+len(discovered), limit))
+	for i := 0; len(items) < limit && (i < len(familiar) || i < len(discovered)); i++ {
+		if i < len(familiar) {
+			items = append(items, familiar[i])
+		}
+		if i < len(discovered) && len(items) < limit {
+			items = append(items, discovered[i])
+		}
+	}
+	return items
+}
+
+func familiarItem(stat *model.PlayStat) model.DailyMixItem {
+	return model.DailyMixItem{
+		Source:   stat.Source,
+		SourceID: stat.SourceID,
+		Title:    stat.Title,
+		Artist:   stat.Artist,
+		Familiar: true,
+	}
+}
+
+func neteaseItem(song model.NeteaseSong) model.DailyMixItem {
+	names := make([]string, 0, len(song.Artists))
+	for _, a := range song.Artists {
+		names = append(names, a.Name)
+	}
+	return model.DailyMixItem{
+		Source:   cache.SourceNetease,
+		SourceID: strconv.FormatInt(song.ID, 10),
+		Title:    song.Name,
+		Artist:   strings.Join(names, "/"),
+		Album:    song.Album.Name,
+		Cover:    song.CoverURL,
+		Duration: song.Duration / 1000,
+	}
+}
+
+// primaryArtist 多位歌手时取第一位
+func primaryArtist(artist string) string {
+	name, _, _ := strings.Cut(artist, "/")
+	name, _, _ = strings.Cut(name, "、")
+	name, _, _ = strings.Cut(name, ",")
+	return strings.TrimSpace(name)
+}
+
+// songKey 按标题和歌手识别同一首歌，不同来源的同一首歌视为重复
+func songKey(title, artist string) string {
+	return strings.ToLower(strings.TrimSpace(title)) + "\x00" + strings.ToLower(primaryArtist(artist))
+}
@@ -0,0 +1,243 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/websocket"
+)
+
+// Device 一个在线设备的 WebSocket 连接
+type Device struct {
+	Hub         *Hub
+	Conn        *websocket.Conn
+	Send        chan []byte
+	UserID      int64
+	ID          string // 客户端生成并持久化的设备ID
+	Name        string
+	Type        string
+	ConnectedAt int64
+
+	heartbeat int64
+	state     *PlaybackState
+	mu        sync.RWMutex
+}
+
+// NewDevice 创建设备连接
+func NewDevice(hub *Hub, conn *websocket.Conn, userID int64, id, name, deviceType string) *Device {
+	return &Device{
+		Hub:         hub,
+		Conn:        conn,
+		Send:        make(chan []byte, 64),
+		UserID:      userID,
+		ID:          id,
+		Name:        name,
+		Type:        deviceType,
+		ConnectedAt: time.Now().UnixMilli(),
+	}
+}
+
+// ReadPump 读取消息循环，处理心跳、状态上报和控制命令
+func (d *Device) ReadPump(ctx context.Context) {
+	defer func() {
+		d.Hub.Unregister(d)
+		d.Conn.Close()
+	}()
+
+	d.Conn.SetReadLimit(4096) // 4KB
+	d.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	// 协议层的 pong 同样视为心跳，客户端不发送 ping 消息也不会被判定超时
+	d.Conn.SetPongHandler(func(string) error {
+		d.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		d.touch()
+		return nil
+	})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		_, message, err := d.Conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				logger.Warn("device websocket read error",
+					logger.ErrorField(err),
+					logger.Int64("user", d.UserID),
+					logger.String("device", d.ID))
+			}
+			return
+		}
+		d.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+
+		var msg Message
+		if err := json.Unmarshal(message, &msg); err != nil {
+			d.sendError("invalid message format")
+			continue
+		}
+
+		switch msg.Type {
+		case MsgTypePing:
+			d.touch()
+			d.sendMessage(MsgTypePong, nil)
+
+		case MsgTypeState:
+			var state PlaybackState
+			if err := json.Unmarshal(msg.Data, &state); err != nil {
+				d.sendError("invalid state")
+				continue
+			}
+			d.touch()
+			d.Hub.UpdateState(d, &state)
+
+		case MsgTypeCommand:
+			var cmd Command
+			if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+				d.sendError("invalid command")
+				continue
+			}
+			cmd.FromDeviceID = d.ID
+			if err := d.Hub.SendCommand(d.UserID, &cmd); err != nil {
+				d.sendError(err.Error())
+			}
+
+		default:
+			d.sendError("unknown message type")
+		}
+	}
+}
+
+// WritePump 写入消息循环
+func (d *Device) WritePump() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer func() {
+		ticker.Stop()
+		d.Conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-d.Send:
+			d.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if !ok {
+				// Hub 关闭了通道
+				d.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := d.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			d.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := d.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sendCommand 向设备投递控制命令，调用方需持有 Hub 的读锁
+func (d *Device) sendCommand(cmd *Command) error {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	if !d.trySend(MsgTypeCommand, cmd.FromDeviceID, data) {
+		atomic.AddUint64(&d.Hub.droppedMessages, 1)
+		return ErrSendBufferFull
+	}
+	return nil
+}
+
+// sendError 向设备发送错误消息
+func (d *Device) sendError(reason string) {
+	d.sendMessage(MsgTypeError, errorData(reason))
+}
+
+// sendMessage 向设备发送消息，缓冲区满时丢弃
+// 持有 Hub 的读锁并确认设备仍在线，避免向已关闭的通道写入
+func (d *Device) sendMessage(msgType MessageType, data json.RawMessage) {
+	d.Hub.mu.RLock()
+	defer d.Hub.mu.RUnlock()
+	if d.Hub.users[d.UserID][d.ID] != d {
+		return
+	}
+	d.trySend(msgType, "", data)
+}
+
+// errorData 错误消息的数据
+func errorData(reason string) json.RawMessage {
+	data, _ := json.Marshal(map[string]string{"message": reason})
+	return data
+}
+
+// trySend 非阻塞写入发送缓冲区
+func (d *Device) trySend(msgType MessageType, fromDeviceID string, data json.RawMessage) bool {
+	msg, err := json.Marshal(&Message{
+		Type:      msgType,
+		DeviceID:  fromDeviceID,
+		Data:      data,
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return false
+	}
+
+	select {
+	case d.Send <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// touch 更新心跳时间
+func (d *Device) touch() {
+	d.mu.Lock()
+	d.heartbeat = time.Now().UnixMilli()
+	d.mu.Unlock()
+}
+
+// lastHeartbeat 最后心跳时间（毫秒时间戳）
+func (d *Device) lastHeartbeat() int64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.heartbeat
+}
+
+// setState 更新播放状态
+func (d *Device) setState(state *PlaybackState) {
+	d.mu.Lock()
+	d.state = state
+	d.mu.Unlock()
+}
+
+// getState 返回播放状态的副本
+func (d *Device) getState() *PlaybackState {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.state == nil {
+		return nil
+	}
+	state := *d.state
+	return &state
+}
+
+// info 返回设备信息
+func (d *Device) info() Info {
+	return Info{
+		ID:          d.ID,
+		Name:        d.Name,
+		Type:        d.Type,
+		State:       d.getState(),
+		ConnectedAt: d.ConnectedAt,
+	}
+}
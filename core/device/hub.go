@@ -0,0 +1,446 @@
+package device
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Bt1QFM/logger"
+)
+
+// MessageType 设备通道的消息类型
+type MessageType string
+
+const (
+	MsgTypePing       MessageType = "ping"        // 心跳
+	MsgTypePong       MessageType = "pong"        // 心跳响应
+	MsgTypeError      MessageType = "error"       // 错误消息
+	MsgTypeDeviceList MessageType = "device_list" // 在线设备列表（设备上下线、状态变化、切换活跃设备时推送）
+	MsgTypeState      MessageType = "state"       // 设备上报自己的播放状态
+	MsgTypeCommand    MessageType = "command"     // 控制命令（设备 -> 服务端 -> 目标设备）
+
+	MsgTypeNotification MessageType = "notification" // 新通知（服务端 -> 用户的所有设备）
+	MsgTypeSleepTimer   MessageType = "sleep_timer"  // 睡眠定时变化（服务端 -> 用户的所有设备），定时取消或到期时数据为 null
+)
+
+// Action 控制命令的动作
+type Action string
+
+const (
+	ActionPlay     Action = "play"     // 继续播放
+	ActionPause    Action = "pause"    // 暂停
+	ActionSeek     Action = "seek"     // 跳转到 position
+	ActionNext     Action = "next"     // 下一首
+	ActionPrev     Action = "prev"     // 上一首
+	ActionTransfer Action = "transfer" // 在目标设备上接着播放，原活跃设备暂停
+)
+
+// ValidAction 检查是否为支持的动作
+func ValidAction(action Action) bool {
+	switch action {
+	case ActionPlay, ActionPause, ActionSeek, ActionNext, ActionPrev, ActionTransfer:
+		return true
+	}
+	return false
+}
+
+var (
+	ErrDeviceNotFound = errors.New("device not found")
+	ErrInvalidCommand = errors.New("invalid command")
+	ErrSendBufferFull = errors.New("device send buffer full")
+)
+
+// Message 设备通道消息结构
+type Message struct {
+	Type      MessageType     `json:"type"`
+	DeviceID  string          `json:"deviceId,omitempty"` // 消息来源设备
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+// PlaybackState 设备当前的播放状态
+type PlaybackState struct {
+	IsPlaying bool    `json:"isPlaying"`
+	Position  float64 `json:"position"`           // 当前播放位置（秒）
+	Index     int     `json:"index"`              // 在用户播放列表中的索引
+	Source    string  `json:"source,omitempty"`   // 当前歌曲来源
+	SourceID  string  `json:"sourceId,omitempty"` // 当前歌曲在来源内的ID
+	Title     string  `json:"title,omitempty"`
+	Artist    string  `json:"artist,omitempty"`
+	UpdatedAt int64   `json:"updatedAt"`
+}
+
+// Command 控制命令
+type Command struct {
+	TargetDeviceID string         `json:"targetDeviceId"`
+	Action         Action         `json:"action"`
+	Position       *float64       `json:"position,omitempty"`     // seek 的目标位置
+	FromDeviceID   string         `json:"fromDeviceId,omitempty"` // 发出命令的设备，HTTP 调用时可以为空
+	State          *PlaybackState `json:"state,omitempty"`        // transfer 时附带原活跃设备的播放状态
+	Reason         string         `json:"reason,omitempty"`       // 服务端发出命令的原因，例如 sleep_timer
+}
+
+// Info 设备信息
+type Info struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Type        string         `json:"type"` // web, mobile, desktop 等，由客户端自行声明
+	Active      bool           `json:"active"`
+	State       *PlaybackState `json:"state,omitempty"`
+	ConnectedAt int64          `json:"connectedAt"`
+}
+
+// Hub 按用户管理在线设备，结构与 room.RoomHub 一致：
+// 注册和注销在 Run 循环中串行处理，查询和投递消息持有读锁
+type Hub struct {
+	// 用户 -> 设备ID -> 设备
+	users map[int64]map[string]*Device
+
+	// 用户 -> 活跃设备ID（正在播放的设备）
+	active map[int64]string
+
+	register   chan *Device
+	unregister chan *Device
+
+	mu   sync.RWMutex
+	done chan struct{}
+
+	healthCheckTicker *time.Ticker
+
+	// 因发送缓冲区满而丢弃的消息数
+	droppedMessages uint64
+}
+
+// NewHub 创建设备 Hub
+func NewHub() *Hub {
+	return &Hub{
+		users:      make(map[int64]map[string]*Device),
+		active:     make(map[int64]string),
+		register:   make(chan *Device),
+		unregister: make(chan *Device),
+		done:       make(chan struct{}),
+	}
+}
+
+// Run 启动 Hub 主循环
+func (h *Hub) Run() {
+	h.healthCheckTicker = time.NewTicker(30 * time.Second)
+
+	for {
+		select {
+		case d := <-h.register:
+			h.registerDevice(d)
+
+		case d := <-h.unregister:
+			h.unregisterDevice(d)
+
+		case <-h.healthCheckTicker.C:
+			h.performHealthCheck()
+
+		case <-h.done:
+			h.cleanup()
+			return
+		}
+	}
+}
+
+// Stop 停止 Hub
+func (h *Hub) Stop() {
+	if h.healthCheckTicker != nil {
+		h.healthCheckTicker.Stop()
+	}
+	close(h.done)
+}
+
+// Register 注册设备
+func (h *Hub) Register(d *Device) {
+	h.register <- d
+}
+
+// Unregister 注销设备
+func (h *Hub) Unregister(d *Device) {
+	h.unregister <- d
+}
+
+// registerDevice 注册设备，同一用户的同一设备ID重复连接时踢掉旧连接
+func (h *Hub) registerDevice(d *Device) {
+	h.mu.Lock()
+	devices := h.users[d.UserID]
+	if devices == nil {
+		devices = make(map[string]*Device)
+		h.users[d.UserID] = devices
+	}
+	if old, exists := devices[d.ID]; exists {
+		old.trySend(MsgTypeError, "", errorData("replaced_by_new_connection"))
+		h.removeDevice(old)
+		if h.users[d.UserID] == nil {
+			h.users[d.UserID] = devices
+		}
+	}
+	d.touch()
+	devices[d.ID] = d
+	h.mu.Unlock()
+
+	logger.Info("device registered",
+		logger.Int64("user", d.UserID),
+		logger.String("device", d.ID),
+		logger.String("name", d.Name))
+
+	h.broadcastDeviceList(d.UserID)
+}
+
+// unregisterDevice 注销设备
+func (h *Hub) unregisterDevice(d *Device) {
+	h.mu.Lock()
+	removed := h.removeDevice(d)
+	h.mu.Unlock()
+
+	if removed {
+		h.broadcastDeviceList(d.UserID)
+	}
+}
+
+// removeDevice 移除设备（需要持有锁），返回设备是否仍在 Hub 中
+func (h *Hub) removeDevice(d *Device) bool {
+	devices := h.users[d.UserID]
+	if devices == nil || devices[d.ID] != d {
+		return false
+	}
+
+	delete(devices, d.ID)
+	close(d.Send)
+	if h.active[d.UserID] == d.ID {
+		delete(h.active, d.UserID)
+	}
+	if len(devices) == 0 {
+		delete(h.users, d.UserID)
+	}
+
+	logger.Info("device unregistered",
+		logger.Int64("user", d.UserID),
+		logger.String("device", d.ID))
+	return true
+}
+
+// performHealthCheck 清理心跳超时的设备
+func (h *Hub) performHealthCheck() {
+	h.mu.RLock()
+	var devicesToCheck []*Device
+	for _, devices := range h.users {
+		for _, d := range devices {
+			devicesToCheck = append(devicesToCheck, d)
+		}
+	}
+	h.mu.RUnlock()
+
+	now := time.Now().UnixMilli()
+	// 心跳超时阈值：90 秒，与房间一致
+	heartbeatTimeout := int64(90 * 1000)
+
+	for _, d := range devicesToCheck {
+		if now-d.lastHeartbeat() > heartbeatTimeout {
+			logger.Warn("device heartbeat timeout, closing connection",
+				logger.Int64("user", d.UserID),
+				logger.String("device", d.ID))
+			h.unregisterDevice(d)
+		}
+	}
+}
+
+// cleanup 清理所有连接
+func (h *Hub) cleanup() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, devices := range h.users {
+		for _, d := range devices {
+			close(d.Send)
+		}
+	}
+	h.users = make(map[int64]map[string]*Device)
+	h.active = make(map[int64]string)
+}
+
+// Devices 返回用户的在线设备，按连接时间排序
+func (h *Hub) Devices(userID int64) []Info {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	activeID := h.active[userID]
+	devices := h.users[userID]
+	result := make([]Info, 0, len(devices))
+	for _, d := range devices {
+		info := d.info()
+		info.Active = d.ID == activeID
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ConnectedAt < result[j].ConnectedAt
+	})
+	return result
+}
+
+// UpdateState 记录设备上报的播放状态，正在播放的设备成为活跃设备
+func (h *Hub) UpdateState(d *Device, state *PlaybackState) {
+	state.UpdatedAt = time.Now().UnixMilli()
+	d.setState(state)
+
+	h.mu.Lock()
+	changed := false
+	if state.IsPlaying && h.active[d.UserID] != d.ID && h.users[d.UserID][d.ID] == d {
+		h.active[d.UserID] = d.ID
+		changed = true
+	}
+	h.mu.Unlock()
+
+	if changed {
+		logger.Debug("active device changed",
+			logger.Int64("user", d.UserID),
+			logger.String("device", d.ID))
+	}
+	h.broadcastDeviceList(d.UserID)
+}
+
+// SendCommand 向用户的目标设备发送控制命令
+// transfer 会把原活跃设备（或发出命令的设备）的播放状态交给目标设备，并让原活跃设备暂停
+func (h *Hub) SendCommand(userID int64, cmd *Command) error {
+	if cmd == nil || cmd.TargetDeviceID == "" || !ValidAction(cmd.Action) {
+		return ErrInvalidCommand
+	}
+	if cmd.Action == ActionSeek && (cmd.Position == nil || *cmd.Position < 0) {
+		return ErrInvalidCommand
+	}
+
+	// 投递期间持有读锁，避免设备注销时关闭发送通道
+	h.mu.RLock()
+	target := h.users[userID][cmd.TargetDeviceID]
+	if target == nil {
+		h.mu.RUnlock()
+		return ErrDeviceNotFound
+	}
+
+	if cmd.Action != ActionTransfer {
+		err := target.sendCommand(cmd)
+		h.mu.RUnlock()
+		return err
+	}
+
+	previousID := h.active[userID]
+	if previousID == "" {
+		previousID = cmd.FromDeviceID
+	}
+	var previous *Device
+	if previousID != target.ID {
+		previous = h.users[userID][previousID]
+	}
+	if cmd.State == nil && previous != nil {
+		cmd.State = previous.getState()
+	}
+	if err := target.sendCommand(cmd); err != nil {
+		h.mu.RUnlock()
+		return err
+	}
+	if previous != nil {
+		pause := &Command{
+			TargetDeviceID: previous.ID,
+			Action:         ActionPause,
+			FromDeviceID:   cmd.FromDeviceID,
+		}
+		if err := previous.sendCommand(pause); err != nil {
+			logger.Warn("failed to pause previous device on transfer",
+				logger.Int64("user", userID),
+				logger.String("device", previous.ID),
+				logger.ErrorField(err))
+		}
+	}
+	h.mu.RUnlock()
+
+	h.mu.Lock()
+	if h.users[userID][target.ID] == target {
+		h.active[userID] = target.ID
+	}
+	h.mu.Unlock()
+	h.broadcastDeviceList(userID)
+
+	logger.Info("playback transferred",
+		logger.Int64("user", userID),
+		logger.String("from", previousID),
+		logger.String("to", target.ID))
+	return nil
+}
+
+// broadcastDeviceList 向用户的所有设备推送在线设备列表
+func (h *Hub) broadcastDeviceList(userID int64) {
+	h.sendToUser(userID, MsgTypeDeviceList, h.Devices(userID))
+}
+
+// PushNotification 向用户的所有在线设备推送通知，用户不在线时直接忽略
+func (h *Hub) PushNotification(userID int64, payload interface{}) {
+	h.sendToUser(userID, MsgTypeNotification, payload)
+}
+
+// PauseAll 服务端让用户的所有在线设备暂停，返回收到命令的设备数
+func (h *Hub) PauseAll(userID int64, reason string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	sent := 0
+	for _, d := range h.users[userID] {
+		cmd := &Command{TargetDeviceID: d.ID, Action: ActionPause, Reason: reason}
+		if err := d.sendCommand(cmd); err == nil {
+			sent++
+		}
+	}
+	return sent
+}
+
+// PushSleepTimer 向用户的所有在线设备推送睡眠定时，各设备据此显示倒计时
+func (h *Hub) PushSleepTimer(userID int64, payload interface{}) {
+	h.sendToUser(userID, MsgTypeSleepTimer, payload)
+}
+
+// OnlineUsers 返回当前至少有一台设备在线的用户
+func (h *Hub) OnlineUsers() []int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	users := make([]int64, 0, len(h.users))
+	for userID := range h.users {
+		users = append(users, userID)
+	}
+	return users
+}
+
+// sendToUser 向用户的所有设备投递消息，发送缓冲区满的设备丢弃该消息
+func (h *Hub) sendToUser(userID int64, msgType MessageType, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	msg, err := json.Marshal(&Message{
+		Type:      msgType,
+		Data:      data,
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	for _, d := range h.users[userID] {
+		select {
+		case d.Send <- msg:
+		default:
+			atomic.AddUint64(&h.droppedMessages, 1)
+		}
+	}
+	h.mu.RUnlock()
+}
+
+// DroppedMessages 返回因设备发送缓冲区满而丢弃的消息总数
+func (h *Hub) DroppedMessages() uint64 {
+	return atomic.LoadUint64(&h.droppedMessages)
+}
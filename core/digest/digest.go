@@ -0,0 +1,383 @@
+package digest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/core/mail"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+// Service 每日摘要邮件服务
+// 每晚汇总订阅用户过去 24 小时的房间与曲库动态并发送邮件
+type Service struct {
+	userRepo         repository.UserRepository
+	trackRepo        repository.TrackRepository
+	roomRepo         repository.RoomRepository
+	socialRepo       repository.SocialRepository
+	notificationRepo repository.NotificationRepository
+	mailer           mail.Sender
+	cfg              *config.Config
+	owner            string // 获取发送租约时的实例标识
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// maxSectionItems 摘要每一部分最多列出的条数，同时限制读取关注者、动态和通知时扫描的范围
+const maxSectionItems = 50
+
+// leaseTTL 每日发送租约的有效期，短于发送间隔，保证第二天可以重新获取
+const leaseTTL = 20 * time.Hour
+
+// Digest 单个用户的摘要内容
+type Digest struct {
+	User            *model.User
+	Since           time.Time
+	NewFollowers    []*model.FollowUser
+	PlaylistUpdates []*PlaylistUpdate
+	RoomInvites     []*model.Notification
+	JoinedRooms     []*model.UserRoomInfo
+	FailedTracks    []*model.Track
+}
+
+// PlaylistUpdate 一位关注的用户在摘要时间段内向播放列表添加的歌曲
+type PlaylistUpdate struct {
+	Username string
+	Count    int      // 添加的歌曲总数
+	Titles   []string // 每次添加的第一首歌曲，最新的在前
+}
+
+// IsEmpty 摘要是否没有任何内容
+func (d *Digest) IsEmpty() bool {
+	return len(d.NewFollowers) == 0 && len(d.PlaylistUpdates) == 0 && len(d.RoomInvites) == 0 &&
+		len(d.JoinedRooms) == 0 && len(d.FailedTracks) == 0
+}
+
+// NewService 创建摘要服务
+func NewService(
+	userRepo repository.UserRepository,
+	trackRepo repository.TrackRepository,
+	roomRepo repository.RoomRepository,
+	socialRepo repository.SocialRepository,
+	notificationRepo repository.NotificationRepository,
+	mailer mail.Sender,
+	cfg *config.Config,
+) *Service {
+	hostname, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return &Service{
+		userRepo:         userRepo,
+		trackRepo:        trackRepo,
+		roomRepo:         roomRepo,
+		socialRepo:       socialRepo,
+		notificationRepo: notificationRepo,
+		mailer:           mailer,
+		cfg:              cfg,
+		owner:            fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(b)),
+		stopChan:         make(chan struct{}),
+	}
+}
+
+// Start 启动每日任务，SMTP 未配置时不启动
+func (s *Service) Start() {
+	if !s.mailer.Enabled() {
+		logger.Info("SMTP未配置，每日摘要服务未启动")
+		return
+	}
+	logger.Info("每日摘要服务启动", logger.Int("hour", s.cfg.DigestHour))
+
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Stop 停止每日任务
+func (s *Service) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// loop 等待到每天的发送时间后执行
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	for {
+		timer := time.NewTimer(time.Until(nextRun(time.Now(), s.cfg.DigestHour)))
+		select {
+		case <-s.stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.RunOnce(context.Background(), time.Now())
+		}
+	}
+}
+
+// nextRun 计算下一次执行时间
+func nextRun(now time.Time, hour int) time.Time {
+	if hour < 0 || hour > 23 {
+		hour = 8
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.Add(24 * time.Hour)
+	}
+	return next
+}
+
+// RunOnce 为所有订阅用户生成并发送摘要
+// 多个实例同时运行时先获取当天的 Redis 租约，只有获取到的实例发送；Redis 不可用时跳过本次，避免重复发送
+func (s *Service) RunOnce(ctx context.Context, now time.Time) {
+	day := now.Format("2006-01-02")
+	leased, err := cache.AcquireDigestLease(ctx, day, s.owner, leaseTTL)
+	if err != nil {
+		logger.Error("获取每日摘要发送租约失败，跳过本次摘要", logger.ErrorField(err))
+		return
+	}
+	if !leased {
+		logger.Info("其他实例已发送当天的每日摘要，跳过", logger.String("day", day))
+		return
+	}
+
+	users, err := s.userRepo.GetAllUsers(ctx)
+	if err != nil {
+		logger.Error("获取用户列表失败，跳过本次摘要", logger.ErrorField(err))
+		return
+	}
+
+	since := now.Add(-24 * time.Hour)
+	sent := 0
+	for _, user := range users {
+		prefs := user.GetPreferences().Digest
+		if !prefs.Enabled || user.Email == "" {
+			continue
+		}
+
+		digest, err := s.Build(ctx, user, since)
+		if err != nil {
+			logger.Warn("生成摘要失败",
+				logger.Int64("userId", user.ID),
+				logger.ErrorField(err))
+			continue
+		}
+		if digest.IsEmpty() {
+			continue
+		}
+
+		if err := s.send(digest, prefs.Locale); err != nil {
+			logger.Warn("发送摘要邮件失败",
+				logger.Int64("userId", user.ID),
+				logger.ErrorField(err))
+			continue
+		}
+		sent++
+	}
+
+	logger.Info("每日摘要发送完成", logger.Int("sent", sent))
+}
+
+// Build 汇总用户自 since 以来的动态
+func (s *Service) Build(ctx context.Context, user *model.User, since time.Time) (*Digest, error) {
+	digest := &Digest{User: user, Since: since}
+	var err error
+
+	if digest.NewFollowers, err = s.newFollowers(ctx, user.ID, since); err != nil {
+		return nil, fmt.Errorf("获取新的关注者失败: %w", err)
+	}
+	if digest.PlaylistUpdates, err = s.playlistUpdates(ctx, user.ID, since); err != nil {
+		return nil, fmt.Errorf("获取关注用户的播放列表动态失败: %w", err)
+	}
+	if digest.RoomInvites, err = s.roomInvites(ctx, user.ID, since); err != nil {
+		return nil, fmt.Errorf("获取房间邀请失败: %w", err)
+	}
+
+	rooms, err := s.roomRepo.GetUserRooms(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("获取用户房间失败: %w", err)
+	}
+	for _, room := range rooms {
+		if !room.IsOwner && room.JoinedAt.After(since) {
+			digest.JoinedRooms = append(digest.JoinedRooms, room)
+		}
+	}
+
+	failed, err := s.trackRepo.GetFailedTracksSince(ctx, user.ID, since)
+	if err != nil {
+		return nil, fmt.Errorf("获取处理失败的曲目失败: %w", err)
+	}
+	digest.FailedTracks = failed
+
+	return digest, nil
+}
+
+// newFollowers 返回 since 之后关注用户的人，最新的在前
+func (s *Service) newFollowers(ctx context.Context, userID int64, since time.Time) ([]*model.FollowUser, error) {
+	followers, err := s.socialRepo.GetFollowers(ctx, userID, maxSectionItems, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range followers {
+		if !f.FollowedAt.After(since) {
+			return followers[:i], nil
+		}
+	}
+	return followers, nil
+}
+
+// playlistUpdates 汇总关注的用户在 since 之后向播放列表添加的歌曲，按发布者当前的隐私设置过滤
+func (s *Service) playlistUpdates(ctx context.Context, userID int64, since time.Time) ([]*PlaylistUpdate, error) {
+	var updates []*PlaylistUpdate
+	byUser := make(map[int64]*PlaylistUpdate)
+	shares := make(map[int64]bool)
+	var beforeID int64
+	// 动态流包含所有类型的动态，最多扫描几页
+	for page := 0; page < 4; page++ {
+		activities, err := s.socialRepo.GetFeed(ctx, userID, beforeID, maxSectionItems)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range activities {
+			if !a.CreatedAt.After(since) {
+				return updates, nil
+			}
+			beforeID = a.ID
+			if a.Type != model.ActivityPlaylistAdded || !s.sharesPlaylists(ctx, shares, a.UserID) {
+				continue
+			}
+			update, ok := byUser[a.UserID]
+			if !ok {
+				if len(updates) == maxSectionItems {
+					continue
+				}
+				update = &PlaylistUpdate{Username: a.Username}
+				byUser[a.UserID] = update
+				updates = append(updates, update)
+			}
+			count, _ := strconv.Atoi(a.ObjectID)
+			update.Count += max(count, 1)
+			if a.Title != "" && len(update.Titles) < 3 {
+				update.Titles = append(update.Titles, a.Title)
+			}
+		}
+		if len(activities) < maxSectionItems {
+			break
+		}
+	}
+	return updates, nil
+}
+
+// sharesPlaylists 发布者当前是否向关注者展示播放列表动态，每个用户只读取一次
+func (s *Service) sharesPlaylists(ctx context.Context, known map[int64]bool, userID int64) bool {
+	shares, ok := known[userID]
+	if !ok {
+		user, err := s.userRepo.GetUserByID(ctx, userID)
+		shares = err == nil && user != nil && !user.IsDisabled() &&
+			user.GetPreferences().Social.Shares(model.ActivityPlaylistAdded)
+		known[userID] = shares
+	}
+	return shares
+}
+
+// roomInvites 返回 since 之后收到的房间邀请，最新的在前
+func (s *Service) roomInvites(ctx context.Context, userID int64, since time.Time) ([]*model.Notification, error) {
+	var invites []*model.Notification
+	var beforeID int64
+	for page := 0; page < 4; page++ {
+		notifications, err := s.notificationRepo.GetNotifications(ctx, userID, false, beforeID, maxSectionItems)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range notifications {
+			if !n.CreatedAt.After(since) {
+				return invites, nil
+			}
+			beforeID = n.ID
+			if n.Type == model.NotificationRoomInvite && len(invites) < maxSectionItems {
+				invites = append(invites, n)
+			}
+		}
+		if len(notifications) < maxSectionItems {
+			break
+		}
+	}
+	return invites, nil
+}
+
+// UnsubscribeURL 生成退订链接
+func (s *Service) UnsubscribeURL(userID int64) string {
+	q := url.Values{}
+	q.Set("uid", fmt.Sprint(userID))
+	q.Set("token", auth.GenerateUnsubscribeToken(userID))
+	return strings.TrimRight(s.cfg.PublicBaseURL, "/") + "/api/digest/unsubscribe?" + q.Encode()
+}
+
+// send 渲染并发送摘要邮件
+func (s *Service) send(d *Digest, locale string) error {
+	t := textsFor(locale)
+	unsubscribe := s.UnsubscribeURL(d.User.ID)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, t.greeting+"\n\n", d.User.Username)
+
+	if len(d.NewFollowers) > 0 {
+		b.WriteString(t.followersTitle + "\n")
+		for _, f := range d.NewFollowers {
+			fmt.Fprintf(&b, "  - %s\n", f.Username)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(d.PlaylistUpdates) > 0 {
+		b.WriteString(t.playlistsTitle + "\n")
+		for _, u := range d.PlaylistUpdates {
+			fmt.Fprintf(&b, "  - "+t.playlistLine+"\n", u.Username, u.Count, strings.Join(u.Titles, t.listSeparator))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(d.RoomInvites) > 0 {
+		b.WriteString(t.invitesTitle + "\n")
+		for _, n := range d.RoomInvites {
+			fmt.Fprintf(&b, "  - %s (%s) — %s\n", n.Title, n.ObjectID, n.ActorName)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(d.JoinedRooms) > 0 {
+		b.WriteString(t.roomsTitle + "\n")
+		for _, room := range d.JoinedRooms {
+			fmt.Fprintf(&b, "  - %s (%s) — %s\n", room.Name, room.ID, room.OwnerName)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(d.FailedTracks) > 0 {
+		b.WriteString(t.failedTitle + "\n")
+		for _, track := range d.FailedTracks {
+			fmt.Fprintf(&b, "  - %s - %s\n", track.Title, track.Artist)
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, t.footer+"\n", unsubscribe)
+
+	// RFC 8058 一键退订：邮件客户端向退订链接发送 POST 即可退订，无需打开确认页
+	return s.mailer.Send(d.User.Email, t.subject, b.String(), map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribe + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	})
+}
@@ -0,0 +1,55 @@
+package digest
+
+import "strings"
+
+// texts 摘要邮件的本地化文案
+type texts struct {
+	subject        string
+	greeting       string
+	followersTitle string
+	playlistsTitle string
+	playlistLine   string // 用户名、歌曲数、部分歌曲名
+	listSeparator  string
+	invitesTitle   string
+	roomsTitle     string
+	failedTitle    string
+	footer         string
+}
+
+var localeTexts = map[string]texts{
+	"zh-CN": {
+		subject:        "Bt1QFM 每日摘要",
+		greeting:       "%s，你好！以下是过去 24 小时的动态：",
+		followersTitle: "新的关注者：",
+		playlistsTitle: "你关注的用户更新了播放列表：",
+		playlistLine:   "%s 添加了 %d 首歌曲，包括 %s",
+		listSeparator:  "、",
+		invitesTitle:   "邀请你加入的房间：",
+		roomsTitle:     "你加入的房间：",
+		failedTitle:    "处理失败的曲目（可重新上传）：",
+		footer:         "不想再收到此邮件？点击退订：%s",
+	},
+	"en": {
+		subject:        "Your Bt1QFM daily digest",
+		greeting:       "Hi %s, here is what happened in the last 24 hours:",
+		followersTitle: "New followers:",
+		playlistsTitle: "Playlist updates from people you follow:",
+		playlistLine:   "%s added %d songs, including %s",
+		listSeparator:  ", ",
+		invitesTitle:   "Rooms you were invited to:",
+		roomsTitle:     "Rooms you joined:",
+		failedTitle:    "Tracks that failed to process (you may re-upload them):",
+		footer:         "Don't want these emails? Unsubscribe: %s",
+	},
+}
+
+// textsFor 根据用户语言选择文案，未知语言回退到中文
+func textsFor(locale string) texts {
+	if t, ok := localeTexts[locale]; ok {
+		return t
+	}
+	if strings.HasPrefix(strings.ToLower(locale), "en") {
+		return localeTexts["en"]
+	}
+	return localeTexts["zh-CN"]
+}
@@ -0,0 +1,294 @@
+package keyrotation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+const (
+	// checkInterval 各实例重新加载密钥、检查是否需要轮换的间隔
+	checkInterval = 5 * time.Minute
+	// syncTimeout 单次加载和轮换的超时时间
+	syncTimeout = 30 * time.Second
+	// lockTTL 生成密钥时持有分布式锁的时长
+	lockTTL = 30 * time.Second
+	// startupWait 启动时其他实例正在生成第一把密钥，最多等待的时长
+	startupWait = 30 * time.Second
+)
+
+// errNoSigningKey 还没有可用于签名的密钥，通常是其他实例正在生成
+var errNoSigningKey = errors.New("no active signing key")
+
+// Service JWT 签名密钥轮换服务：密钥保存在数据库中由所有实例共用，
+// 每把密钥使用 JWT_KEY_ROTATION_HOURS 后生成下一把，新密钥先在 JWKS 中发布 JWT_KEY_OVERLAP_MINUTES 再用于签名，
+// 停用的密钥继续用于校验，直到用它签发的 Token 全部过期后删除
+type Service struct {
+	repo  repository.SigningKeyRepository
+	cfg   *config.Config
+	owner string
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewService 创建签名密钥轮换服务
+func NewService(repo repository.SigningKeyRepository, cfg *config.Config) *Service {
+	hostname, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return &Service{
+		repo:     repo,
+		cfg:      cfg,
+		owner:    fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(b)),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Enabled 是否使用非对称签名密钥，JWT_SIGNING_ALG 为 HS256 时使用 APP_SECRET 签名
+func (s *Service) Enabled() bool {
+	return s.cfg.JWTSigningAlgorithm == auth.AlgorithmRS256 || s.cfg.JWTSigningAlgorithm == auth.AlgorithmEdDSA
+}
+
+// Start 先同步加载（必要时生成）签名密钥，保证开始处理请求时已使用新的签名方式，之后定时检查轮换
+// 加载失败时暂时使用 APP_SECRET 以 HS256 签名，下次检查成功后切换
+func (s *Service) Start() {
+	if !s.Enabled() {
+		logger.Info("JWT 使用 HS256 签名，不轮换", logger.String("algorithm", s.cfg.JWTSigningAlgorithm))
+		return
+	}
+	logger.Info("JWT 签名密钥轮换服务启动",
+		logger.String("algorithm", s.cfg.JWTSigningAlgorithm),
+		logger.Duration("rotation", s.rotationInterval()),
+		logger.Duration("overlap", s.overlap()))
+
+	deadline := time.Now().Add(startupWait)
+	for {
+		err := s.sync()
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errNoSigningKey) || time.Now().After(deadline) {
+			logger.Error("加载 JWT 签名密钥失败，暂时使用 HS256 签名", logger.ErrorField(err))
+			break
+		}
+		time.Sleep(time.Second)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				if err := s.sync(); err != nil {
+					logger.Warn("同步 JWT 签名密钥失败", logger.ErrorField(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止定时检查
+func (s *Service) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+func (s *Service) sync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+	return s.Sync(ctx, time.Now())
+}
+
+// Sync 删除过期的密钥，需要时生成下一把密钥，然后加载签名和校验密钥
+func (s *Service) Sync(ctx context.Context, now time.Time) error {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	records, err := s.repo.ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+	records = s.prune(ctx, records, now)
+	if s.needsKey(records, now) {
+		if records, err = s.generate(ctx, records, now); err != nil {
+			return err
+		}
+	}
+	return s.apply(ctx, records, now)
+}
+
+// prune 删除停用时间超过 Token 有效期的密钥，返回剩余的密钥
+func (s *Service) prune(ctx context.Context, records []*model.JWTSigningKey, now time.Time) []*model.JWTSigningKey {
+	kept := records[:0]
+	for _, record := range records {
+		if record.RetiredAt != nil && now.After(record.RetiredAt.Add(auth.TokenTTL)) {
+			if err := s.repo.DeleteSigningKey(ctx, record.ID); err != nil {
+				logger.Warn("删除过期的 JWT 签名密钥失败", logger.String("kid", record.ID), logger.ErrorField(err))
+			} else {
+				logger.Info("删除过期的 JWT 签名密钥", logger.String("kid", record.ID))
+				continue
+			}
+		}
+		kept = append(kept, record)
+	}
+	return kept
+}
+
+// needsKey 没有可用于签名的密钥，或当前密钥即将到期（或算法已修改）且还没有待启用的密钥时需要生成
+func (s *Service) needsKey(records []*model.JWTSigningKey, now time.Time) bool {
+	active := activeIndex(records, now)
+	if active < 0 {
+		return true
+	}
+	if active < len(records)-1 {
+		return false // 已有待启用的密钥
+	}
+	current := records[active]
+	return current.Algorithm != s.cfg.JWTSigningAlgorithm ||
+		!now.Before(current.ActivatesAt.Add(s.rotationInterval()-s.overlap()))
+}
+
+// generate 在分布式锁内生成下一把密钥：没有可用密钥时立即启用，否则先发布 overlap 时长再启用；返回最新的密钥列表
+// 其他实例正持有锁时不生成，由它完成轮换；Redis 不可用时直接生成
+func (s *Service) generate(ctx context.Context, records []*model.JWTSigningKey, now time.Time) ([]*model.JWTSigningKey, error) {
+	locked, err := cache.AcquireSigningKeyLock(ctx, s.owner, lockTTL)
+	if err != nil {
+		logger.Warn("获取 JWT 签名密钥锁失败，直接生成", logger.ErrorField(err))
+	} else if !locked {
+		return records, nil
+	} else {
+		defer func() {
+			if err := cache.ReleaseSigningKeyLock(context.Background(), s.owner); err != nil {
+				logger.Warn("释放 JWT 签名密钥锁失败", logger.ErrorField(err))
+			}
+		}()
+		// 拿到锁之前其他实例可能刚完成轮换
+		if records, err = s.repo.ListSigningKeys(ctx); err != nil {
+			return nil, err
+		}
+		if !s.needsKey(records, now) {
+			return records, nil
+		}
+	}
+
+	key, err := auth.GenerateSigningKey(s.cfg.JWTSigningAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	privatePEM, err := auth.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	record := &model.JWTSigningKey{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  privatePEM,
+		ActivatesAt: now,
+		CreatedAt:   now,
+	}
+	if activeIndex(records, now) >= 0 {
+		record.ActivatesAt = now.Add(s.overlap())
+	}
+	if err := s.repo.CreateSigningKey(ctx, record); err != nil {
+		return nil, err
+	}
+	logger.Info("生成 JWT 签名密钥",
+		logger.String("kid", record.ID),
+		logger.String("algorithm", record.Algorithm),
+		logger.String("activatesAt", record.ActivatesAt.Format(time.RFC3339)))
+	records = append(records, record)
+	sort.SliceStable(records, func(i, j int) bool { return records[i].ActivatesAt.Before(records[j].ActivatesAt) })
+	return records, nil
+}
+
+// apply 停用已被新密钥取代的密钥，并把签名密钥、全部校验密钥和 HS256 Token 的过渡截止时间交给 auth
+func (s *Service) apply(ctx context.Context, records []*model.JWTSigningKey, now time.Time) error {
+	active := activeIndex(records, now)
+	if active < 0 {
+		return errNoSigningKey
+	}
+	auth.SetLegacyTokenDeadline(s.legacyTokenDeadline(records))
+
+	var signing *auth.SigningKey
+	verification := make([]*auth.SigningKey, 0, len(records))
+	for i, record := range records {
+		if i < active && record.RetiredAt == nil {
+			retiredAt := records[i+1].ActivatesAt
+			if err := s.repo.RetireSigningKey(ctx, record.ID, retiredAt); err != nil {
+				logger.Warn("停用 JWT 签名密钥失败", logger.String("kid", record.ID), logger.ErrorField(err))
+			}
+			record.RetiredAt = &retiredAt
+		}
+
+		key, err := auth.ParseSigningKey(record.ID, record.Algorithm, record.PrivateKey)
+		if err != nil {
+			if i == active {
+				return err
+			}
+			logger.Warn("解析 JWT 签名密钥失败，跳过", logger.String("kid", record.ID), logger.ErrorField(err))
+			continue
+		}
+		if i == active {
+			signing = key
+		}
+		verification = append(verification, key)
+	}
+	auth.SetSigningKeys(signing, verification)
+	return nil
+}
+
+// legacyTokenDeadline 启用非对称密钥前签发的 HS256 Token 最多再有效一个 TokenTTL，过渡期从最早的密钥生成时开始计算。
+// 最早的密钥被删除时距离下一把密钥生成已超过 TokenTTL，因此剩余密钥中最早的生成时间不会重新打开过渡期
+func (s *Service) legacyTokenDeadline(records []*model.JWTSigningKey) time.Time {
+	if !s.cfg.JWTAcceptLegacyTokens || len(records) == 0 {
+		return time.Time{}
+	}
+	first := records[0].CreatedAt
+	for _, record := range records[1:] {
+		if record.CreatedAt.Before(first) {
+			first = record.CreatedAt
+		}
+	}
+	return first.Add(auth.TokenTTL)
+}
+
+// rotationInterval 每把密钥用于签名的时长
+func (s *Service) rotationInterval() time.Duration {
+	return time.Duration(max(s.cfg.JWTKeyRotationHours, 1)) * time.Hour
+}
+
+// overlap 新密钥启用前在 JWKS 中发布的时长，至少为一个检查间隔，保证所有实例在启用前都已加载
+func (s *Service) overlap() time.Duration {
+	return max(time.Duration(s.cfg.JWTKeyOverlapMinutes)*time.Minute, checkInterval)
+}
+
+// activeIndex 返回当前用于签名的密钥（已启用的密钥中最新的一把）的下标，没有时为 -1；records 按启用时间排序
+func activeIndex(records []*model.JWTSigningKey, now time.Time) int {
+	active := -1
+	for i, record := range records {
+		if !record.ActivatesAt.After(now) {
+			active = i
+		}
+	}
+	return active
+}
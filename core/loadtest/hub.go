@@ -0,0 +1,146 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Bt1QFM/core/room"
+	"Bt1QFM/model"
+)
+
+// hubRoomID 进程内压测使用的房间ID
+const hubRoomID = "loadtest-hub"
+
+// HubOptions 进程内 RoomHub 广播压测参数
+type HubOptions struct {
+	Clients       int           // 模拟客户端数量
+	Messages      int           // 广播消息数量
+	Interval      time.Duration // 两条消息之间的间隔，0 表示尽快发送
+	SlowClients   int           // 其中慢消费客户端数量
+	ConsumerDelay time.Duration // 慢消费客户端处理每条消息的耗时
+	SendBuffer    int           // 客户端发送缓冲区大小，与线上保持一致
+	DrainTimeout  time.Duration // 发送结束后等待消费完成的最长时间
+}
+
+// HubReport 进程内广播压测结果
+type HubReport struct {
+	Clients      int
+	Sent         int
+	Expected     int64 // 应投递消息数（消息数 × 客户端数）
+	Delivered    int64
+	Dropped      uint64 // Hub 因缓冲区满丢弃的消息数
+	Disconnected int64  // 被 Hub 踢出的客户端数
+	Elapsed      time.Duration
+	Latency      Summary
+}
+
+// RunHub 在进程内启动 RoomHub，注册模拟客户端并测量广播延迟
+func RunHub(opts HubOptions) (*HubReport, error) {
+	if opts.Clients <= 0 || opts.Messages <= 0 {
+		return nil, fmt.Errorf("客户端数和消息数必须大于0")
+	}
+	if opts.SendBuffer <= 0 {
+		opts.SendBuffer = 256
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = 10 * time.Second
+	}
+
+	hub := room.NewRoomHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	latency := NewRecorder()
+	var delivered, disconnected int64
+	var wg sync.WaitGroup
+
+	for i := 0; i < opts.Clients; i++ {
+		client := &room.Client{
+			Hub:      hub,
+			Send:     make(chan []byte, opts.SendBuffer),
+			RoomID:   hubRoomID,
+			UserID:   int64(i + 1),
+			Username: fmt.Sprintf("loadtest-%d", i+1),
+			Mode:     model.RoomModeChat,
+			Role:     model.RoomRoleMember,
+		}
+		var delay time.Duration
+		if i < opts.SlowClients {
+			delay = opts.ConsumerDelay
+		}
+
+		wg.Add(1)
+		go func(c *room.Client, delay time.Duration) {
+			defer wg.Done()
+			received := 0
+			for data := range c.Send {
+				if _, sentAt, ok := decodeWSMessage(data); ok {
+					latency.Add(time.Since(sentAt))
+					atomic.AddInt64(&delivered, 1)
+					received++
+					if received == opts.Messages {
+						return
+					}
+				}
+				if delay > 0 {
+					time.Sleep(delay)
+				}
+			}
+			// 通道被关闭说明客户端被 Hub 移除
+			atomic.AddInt64(&disconnected, 1)
+		}(client, delay)
+
+		hub.Register(client)
+	}
+
+	start := time.Now()
+	for seq := 0; seq < opts.Messages; seq++ {
+		chatData, _ := json.Marshal(&room.ChatData{Content: encodeProbe(seq, time.Now())})
+		hub.BroadcastWSMessage(hubRoomID, &room.WSMessage{
+			Type:   room.MsgTypeChat,
+			RoomID: hubRoomID,
+			Data:   chatData,
+		}, 0, "")
+		if opts.Interval > 0 {
+			time.Sleep(opts.Interval)
+		}
+	}
+
+	// 等待所有客户端消费完毕或超时
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(opts.DrainTimeout):
+	}
+
+	return &HubReport{
+		Clients:      opts.Clients,
+		Sent:         opts.Messages,
+		Expected:     int64(opts.Messages) * int64(opts.Clients),
+		Delivered:    atomic.LoadInt64(&delivered),
+		Dropped:      hub.DroppedMessages(),
+		Disconnected: atomic.LoadInt64(&disconnected),
+		Elapsed:      time.Since(start),
+		Latency:      latency.Summary(),
+	}, nil
+}
+
+// decodeWSMessage 从单条 WebSocket 消息中解析聊天探针
+func decodeWSMessage(data []byte) (int, time.Time, bool) {
+	var msg room.WSMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != room.MsgTypeChat {
+		return 0, time.Time{}, false
+	}
+	var chat room.ChatData
+	if err := json.Unmarshal(msg.Data, &chat); err != nil {
+		return 0, time.Time{}, false
+	}
+	return decodeProbe(chat.Content)
+}
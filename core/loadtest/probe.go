@@ -0,0 +1,36 @@
+package loadtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// probePrefix 压测探针消息前缀，用于从房间消息中区分压测流量
+const probePrefix = "loadtest:"
+
+// encodeProbe 生成携带序号和发送时间的聊天内容
+func encodeProbe(seq int, sentAt time.Time) string {
+	return fmt.Sprintf("%s%d:%d", probePrefix, seq, sentAt.UnixNano())
+}
+
+// decodeProbe 解析探针内容，返回序号和发送时间
+func decodeProbe(content string) (int, time.Time, bool) {
+	if !strings.HasPrefix(content, probePrefix) {
+		return 0, time.Time{}, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(content, probePrefix), ":", 2)
+	if len(parts) != 2 {
+		return 0, time.Time{}, false
+	}
+	seq, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return seq, time.Unix(0, nanos), true
+}
@@ -0,0 +1,175 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Bt1QFM/core/room"
+
+	"github.com/gorilla/websocket"
+)
+
+// RoomsOptions 针对运行中实例的房间 WebSocket 压测参数
+type RoomsOptions struct {
+	BaseURL      string        // 服务地址，例如 http://localhost:8080
+	RoomID       string        // 已存在的房间ID
+	Token        string        // 连接时携带的 token
+	Clients      int           // 模拟客户端数量
+	Messages     int           // 由第一个客户端发送的聊天消息数量
+	Interval     time.Duration // 两条消息之间的间隔
+	UserIDBase   int64         // 模拟用户ID起始值，各客户端ID需互不相同
+	DrainTimeout time.Duration // 发送结束后等待消息到达的最长时间
+}
+
+// RoomsReport 房间 WebSocket 压测结果
+type RoomsReport struct {
+	Connected    int
+	ConnectFail  int
+	Sent         int
+	Expected     int64
+	Delivered    int64
+	Disconnected int64 // 压测过程中被服务端断开的连接数
+	Elapsed      time.Duration
+	Connect      Summary
+	Latency      Summary
+}
+
+// Missing 返回未送达的消息数
+func (r *RoomsReport) Missing() int64 {
+	return r.Expected - r.Delivered
+}
+
+// RunRooms 建立多个房间 WebSocket 连接，发送聊天探针并测量端到端广播延迟
+func RunRooms(opts RoomsOptions) (*RoomsReport, error) {
+	if opts.Clients <= 0 || opts.Messages <= 0 {
+		return nil, fmt.Errorf("客户端数和消息数必须大于0")
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = 10 * time.Second
+	}
+	wsBase, err := websocketBaseURL(opts.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RoomsReport{}
+	connectTimes := NewRecorder()
+	latency := NewRecorder()
+	runStart := time.Now()
+
+	var conns []*websocket.Conn
+	var wg sync.WaitGroup
+	var delivered, disconnected int64
+	var closing int32
+
+	for i := 0; i < opts.Clients; i++ {
+		query := url.Values{}
+		query.Set("userId", fmt.Sprint(opts.UserIDBase+int64(i)))
+		query.Set("username", fmt.Sprintf("loadtest-%d", i))
+		query.Set("token", opts.Token)
+		query.Set("topics", string(room.TopicChat))
+		target := fmt.Sprintf("%s/ws/room/%s?%s", wsBase, url.PathEscape(opts.RoomID), query.Encode())
+
+		begin := time.Now()
+		conn, _, err := websocket.DefaultDialer.Dial(target, nil)
+		if err != nil {
+			report.ConnectFail++
+			if i == 0 {
+				return nil, fmt.Errorf("连接房间失败: %w", err)
+			}
+			continue
+		}
+		connectTimes.Add(time.Since(begin))
+		conns = append(conns, conn)
+
+		wg.Add(1)
+		go func(conn *websocket.Conn) {
+			defer wg.Done()
+			received := 0
+			for {
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					if atomic.LoadInt32(&closing) == 0 {
+						atomic.AddInt64(&disconnected, 1)
+					}
+					return
+				}
+				// 服务端可能将多条消息以换行合并为一帧
+				for _, line := range strings.Split(string(data), "\n") {
+					seq, sentAt, ok := decodeWSMessage([]byte(line))
+					if !ok || seq >= opts.Messages || sentAt.Before(runStart) {
+						continue
+					}
+					latency.Add(time.Since(sentAt))
+					atomic.AddInt64(&delivered, 1)
+					received++
+				}
+				if received >= opts.Messages {
+					return
+				}
+			}
+		}(conn)
+	}
+	report.Connected = len(conns)
+
+	// 第一个连接作为发送方，其余连接均为接收方（服务端广播不排除发送者）
+	sender := conns[0]
+	start := time.Now()
+	for seq := 0; seq < opts.Messages; seq++ {
+		chatData, _ := json.Marshal(&room.ChatData{Content: encodeProbe(seq, time.Now())})
+		msg := &room.WSMessage{Type: room.MsgTypeChat, RoomID: opts.RoomID, Data: chatData}
+		if err := sender.WriteJSON(msg); err != nil {
+			break
+		}
+		report.Sent++
+		if opts.Interval > 0 {
+			time.Sleep(opts.Interval)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(opts.DrainTimeout):
+	}
+	report.Elapsed = time.Since(start)
+
+	atomic.StoreInt32(&closing, 1)
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	report.Expected = int64(report.Sent) * int64(report.Connected)
+	report.Delivered = atomic.LoadInt64(&delivered)
+	report.Disconnected = atomic.LoadInt64(&disconnected)
+	report.Connect = connectTimes.Summary()
+	report.Latency = latency.Summary()
+	return report, nil
+}
+
+// websocketBaseURL 将 http(s) 地址转换为 ws(s) 地址
+func websocketBaseURL(base string) (string, error) {
+	u, err := url.Parse(strings.TrimRight(base, "/"))
+	if err != nil {
+		return "", fmt.Errorf("无效的服务地址: %w", err)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return "", fmt.Errorf("不支持的地址协议: %s", u.Scheme)
+	}
+	return u.String(), nil
+}
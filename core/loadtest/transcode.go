@@ -0,0 +1,117 @@
+package loadtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Bt1QFM/core/audio"
+)
+
+// TranscodeOptions 进程内 MP3Processor 转码吞吐压测参数
+type TranscodeOptions struct {
+	FFmpegPath     string
+	Input          string // 用于转码的样本音频
+	Jobs           int    // 转码任务总数
+	Concurrency    int    // 同时运行的 FFmpeg 进程数
+	AudioBitrate   string
+	HLSSegmentTime string
+	Transcode      *audio.TranscodeOptions // 可选的静音裁剪/淡入淡出参数
+}
+
+// TranscodeReport 转码压测结果
+type TranscodeReport struct {
+	Jobs         int
+	Failed       int64
+	Elapsed      time.Duration
+	AudioSeconds float64 // 成功转码的音频总时长
+	QueueWait    Summary // 任务提交到开始转码的等待时间
+	Transcode    Summary // 单个任务转码耗时
+}
+
+// RealtimeFactor 返回整体转码速度相对实时播放的倍数
+func (r *TranscodeReport) RealtimeFactor() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return r.AudioSeconds / r.Elapsed.Seconds()
+}
+
+// RunTranscode 以固定并发度对同一输入重复执行 HLS 转码并统计排队和转码耗时
+func RunTranscode(opts TranscodeOptions) (*TranscodeReport, error) {
+	if opts.Jobs <= 0 || opts.Concurrency <= 0 {
+		return nil, fmt.Errorf("任务数和并发数必须大于0")
+	}
+	if _, err := os.Stat(opts.Input); err != nil {
+		return nil, fmt.Errorf("输入文件不可用: %w", err)
+	}
+
+	workDir, err := os.MkdirTemp("", "loadtest-transcode-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	// 转码池的并发数与压测并发度一致，避免被默认的 CPU 核数限制
+	audio.SetTranscodeLimits(audio.TranscodeLimits{Concurrency: opts.Concurrency})
+	processor := audio.NewMP3Processor(opts.FFmpegPath)
+	defer processor.Stop()
+
+	queueWait := NewRecorder()
+	transcode := NewRecorder()
+	var failed int64
+	var audioMillis int64
+
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	// 所有任务同时提交，模拟上传高峰时的排队
+	for i := 0; i < opts.Jobs; i++ {
+		wg.Add(1)
+		go func(job int) {
+			defer wg.Done()
+			submitted := time.Now()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			queueWait.Add(time.Since(submitted))
+
+			outDir := filepath.Join(workDir, fmt.Sprintf("job-%d", job))
+			if err := os.MkdirAll(outDir, 0755); err != nil {
+				atomic.AddInt64(&failed, 1)
+				return
+			}
+			defer os.RemoveAll(outDir)
+
+			begin := time.Now()
+			duration, err := processor.ProcessToHLSWithOptions(
+				opts.Input,
+				filepath.Join(outDir, "playlist.m3u8"),
+				filepath.Join(outDir, "segment_%03d.ts"),
+				fmt.Sprintf("/streams/loadtest-%d/", job),
+				opts.AudioBitrate,
+				opts.HLSSegmentTime,
+				opts.Transcode,
+			)
+			if err != nil {
+				atomic.AddInt64(&failed, 1)
+				return
+			}
+			transcode.Add(time.Since(begin))
+			atomic.AddInt64(&audioMillis, int64(duration*1000))
+		}(i)
+	}
+	wg.Wait()
+
+	return &TranscodeReport{
+		Jobs:         opts.Jobs,
+		Failed:       failed,
+		Elapsed:      time.Since(start),
+		AudioSeconds: float64(audioMillis) / 1000,
+		QueueWait:    queueWait.Summary(),
+		Transcode:    transcode.Summary(),
+	}, nil
+}
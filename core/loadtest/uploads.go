@@ -0,0 +1,183 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UploadsOptions 针对运行中实例的并发上传压测参数
+type UploadsOptions struct {
+	BaseURL      string
+	Token        string        // Bearer token
+	File         string        // 上传的样本音频
+	Uploads      int           // 上传总数
+	Concurrency  int           // 同时进行的上传数
+	PollInterval time.Duration // 轮询播放列表的间隔
+	Timeout      time.Duration // 单首歌曲等待转码完成的最长时间
+	Cleanup      bool          // 结束后删除压测上传的歌曲
+}
+
+// UploadsReport 并发上传压测结果
+type UploadsReport struct {
+	Uploads       int
+	Succeeded     int
+	Rejected      map[int]int // 按 HTTP 状态码统计被拒绝的上传
+	Failed        int         // 网络错误或转码超时
+	Elapsed       time.Duration
+	Upload        Summary // 上传请求耗时
+	TranscodeWait Summary // 上传返回到播放列表完整可用的等待时间
+}
+
+// RunUploads 并发上传样本音频，并轮询 HLS 播放列表直到转码完成
+func RunUploads(opts UploadsOptions) (*UploadsReport, error) {
+	if opts.Uploads <= 0 || opts.Concurrency <= 0 {
+		return nil, fmt.Errorf("上传数和并发数必须大于0")
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 500 * time.Millisecond
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	content, err := os.ReadFile(opts.File)
+	if err != nil {
+		return nil, fmt.Errorf("读取样本文件失败: %w", err)
+	}
+
+	base := strings.TrimRight(opts.BaseURL, "/")
+	client := &http.Client{Timeout: 2 * time.Minute}
+	report := &UploadsReport{Uploads: opts.Uploads, Rejected: make(map[int]int)}
+	uploadTimes := NewRecorder()
+	waitTimes := NewRecorder()
+
+	var mu sync.Mutex
+	var trackIDs []int64
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	runID := time.Now().Unix()
+	start := time.Now()
+
+	for i := 0; i < opts.Uploads; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			title := fmt.Sprintf("loadtest-%d-%d", runID, n)
+			begin := time.Now()
+			trackID, status, err := uploadTrack(client, base, opts.Token, opts.File, content, title)
+			if err != nil {
+				mu.Lock()
+				if status != 0 {
+					report.Rejected[status]++
+				} else {
+					report.Failed++
+				}
+				mu.Unlock()
+				return
+			}
+			uploadTimes.Add(time.Since(begin))
+			mu.Lock()
+			trackIDs = append(trackIDs, trackID)
+			mu.Unlock()
+
+			uploaded := time.Now()
+			if err := waitForPlaylist(client, base, opts.Token, trackID, opts.PollInterval, opts.Timeout); err != nil {
+				mu.Lock()
+				report.Failed++
+				mu.Unlock()
+				return
+			}
+			waitTimes.Add(time.Since(uploaded))
+			mu.Lock()
+			report.Succeeded++
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	report.Elapsed = time.Since(start)
+	report.Upload = uploadTimes.Summary()
+	report.TranscodeWait = waitTimes.Summary()
+
+	if opts.Cleanup {
+		for _, id := range trackIDs {
+			req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/tracks/%d", base, id), nil)
+			req.Header.Set("Authorization", "Bearer "+opts.Token)
+			if resp, err := client.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	return report, nil
+}
+
+// uploadTrack 以 multipart 表单上传一首歌曲，返回歌曲ID；被拒绝时返回 HTTP 状态码
+func uploadTrack(client *http.Client, base, token, path string, content []byte, title string) (int64, int, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("trackFile", filepath.Base(path))
+	if err != nil {
+		return 0, 0, err
+	}
+	part.Write(content)
+	form.WriteField("title", title)
+	form.WriteField("artist", "loadtest")
+	form.Close()
+
+	req, err := http.NewRequest(http.MethodPost, base+"/api/upload", &body)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, resp.StatusCode, fmt.Errorf("上传被拒绝: %d %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		TrackID int64 `json:"trackId"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.TrackID == 0 {
+		return 0, 0, fmt.Errorf("解析上传响应失败: %v", err)
+	}
+	return result.TrackID, 0, nil
+}
+
+// waitForPlaylist 轮询歌曲播放列表，直到出现 EXT-X-ENDLIST 表示转码完成
+func waitForPlaylist(client *http.Client, base, token string, trackID int64, interval, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	playlistURL := fmt.Sprintf("%s/streams/%d/playlist.m3u8", base, trackID)
+	for time.Now().Before(deadline) {
+		req, _ := http.NewRequest(http.MethodGet, playlistURL, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		if err == nil {
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK && bytes.Contains(data, []byte("#EXT-X-ENDLIST")) {
+				return nil
+			}
+		}
+		time.Sleep(interval)
+	}
+	return fmt.Errorf("等待歌曲 %d 转码超时", trackID)
+}
@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+)
+
+// Mailer 基于 SMTP 的邮件发送器
+type Mailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+// NewMailer 根据配置创建邮件发送器
+func NewMailer(cfg *config.Config) *Mailer {
+	from := cfg.SMTPFrom
+	if from == "" {
+		from = cfg.SMTPUsername
+	}
+	return &Mailer{
+		host:     cfg.SMTPHost,
+		port:     cfg.SMTPPort,
+		username: cfg.SMTPUsername,
+		password: cfg.SMTPPassword,
+		from:     from,
+	}
+}
+
+// Enabled 是否已配置 SMTP
+func (m *Mailer) Enabled() bool {
+	return m != nil && m.host != "" && m.from != ""
+}
+
+// Send 发送纯文本邮件，headers 为附加的邮件头（如 List-Unsubscribe）
+func (m *Mailer) Send(to, subject, body string, headers map[string]string) error {
+	if !m.Enabled() {
+		return fmt.Errorf("SMTP未配置")
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	for k, v := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", k, v)
+	}
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	addr := fmt.Sprintf("%s:%d", m.host, m.port)
+	if err := smtp.SendMail(addr, auth, m.from, []string{to}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+
+	logger.Info("邮件已发送",
+		logger.String("to", to),
+		logger.String("subject", subject))
+	return nil
+}
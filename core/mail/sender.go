@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"strings"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+)
+
+// Sender 邮件发送器，SMTP 之外的实现（如开发环境只写日志）可按配置替换
+type Sender interface {
+	// Enabled 是否可以发送邮件
+	Enabled() bool
+	// Send 发送纯文本邮件，headers 为附加的邮件头
+	Send(to, subject, body string, headers map[string]string) error
+}
+
+// NewSender 根据 MAIL_SENDER 创建邮件发送器：smtp（默认）或 log
+func NewSender(cfg *config.Config) Sender {
+	switch strings.ToLower(cfg.MailSender) {
+	case "log":
+		logger.Info("邮件发送器: log，邮件只写入日志不实际发送")
+		return LogSender{}
+	case "", "smtp":
+		return NewMailer(cfg)
+	default:
+		logger.Warn("未知的邮件发送器，使用 smtp", logger.String("sender", cfg.MailSender))
+		return NewMailer(cfg)
+	}
+}
+
+// LogSender 只把邮件内容写入日志，用于本地开发和调试
+type LogSender struct{}
+
+// Enabled 始终可用
+func (LogSender) Enabled() bool {
+	return true
+}
+
+// Send 将邮件写入日志
+func (LogSender) Send(to, subject, body string, headers map[string]string) error {
+	logger.Info("邮件（仅记录日志）",
+		logger.String("to", to),
+		logger.String("subject", subject),
+		logger.String("body", body))
+	return nil
+}
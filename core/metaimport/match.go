@@ -0,0 +1,209 @@
+package metaimport
+
+import (
+	"strings"
+	"unicode"
+
+	"Bt1QFM/model"
+)
+
+// 匹配结果
+const (
+	StatusMatched   = "matched"   // 找到唯一的曲目
+	StatusUnmatched = "unmatched" // 没有足够相似的曲目
+	StatusAmbiguous = "ambiguous" // 有多首同样相似的曲目，不自动选择
+	StatusDuplicate = "duplicate" // 匹配到的曲目已被前面的行使用
+	StatusInvalid   = "invalid"   // 该行本身无效
+	StatusUnchanged = "unchanged" // 匹配到的曲目与该行一致，无需修改（由调用方比较后设置）
+)
+
+const (
+	// MinScore 视为同一首歌的最低相似度
+	MinScore = 0.85
+	// titleWeight 两边都有歌手时标题在相似度中的权重，其余为歌手
+	titleWeight = 0.7
+	// strippedPenalty 去掉括号内容（如 "(Remastered)"）后才相似时的折扣
+	strippedPenalty = 0.9
+	// ambiguousMargin 最高分和次高分相差不超过该值时视为无法区分
+	ambiguousMargin = 0.01
+)
+
+// Match 一行元数据的匹配结果
+type Match struct {
+	Row   Row
+	Track *model.Track // 匹配到的曲目，Status 为 matched 时非空
+	Score float64
+	// Status 取值见 StatusMatched 等常量
+	Status string
+}
+
+// candidate 预先规范化的曲目
+type candidate struct {
+	track    *model.Track
+	title    string
+	stripped string
+	artist   string
+}
+
+// MatchRows 按标题和歌手的模糊相似度把每一行对应到曲库中的曲目，返回顺序与 rows 一致
+// 标题规范化后完全相同的曲目优先；每首曲目只对应第一行匹配到它的元数据
+func MatchRows(rows []Row, tracks []*model.Track) []Match {
+	candidates := make([]*candidate, 0, len(tracks))
+	byTitle := make(map[string][]*candidate, len(tracks))
+	for _, t := range tracks {
+		c := &candidate{
+			track:    t,
+			title:    normalize(t.Title),
+			stripped: normalize(stripBrackets(t.Title)),
+			artist:   normalize(t.Artist),
+		}
+		candidates = append(candidates, c)
+		byTitle[c.title] = append(byTitle[c.title], c)
+	}
+
+	claimed := make(map[int64]bool)
+	matches := make([]Match, len(rows))
+	for i, row := range rows {
+		m := &matches[i]
+		m.Row = row
+		if row.Error != "" {
+			m.Status = StatusInvalid
+			continue
+		}
+
+		title, artist := normalize(row.Title), normalize(row.Artist)
+		stripped := normalize(stripBrackets(row.Title))
+		best, bestScore, secondScore := bestCandidate(byTitle[title], title, stripped, artist)
+		if bestScore < MinScore {
+			best, bestScore, secondScore = bestCandidate(candidates, title, stripped, artist)
+		}
+
+		m.Score = bestScore
+		switch {
+		case best == nil || bestScore < MinScore:
+			m.Status = StatusUnmatched
+		case bestScore-secondScore <= ambiguousMargin:
+			m.Status = StatusAmbiguous
+		case claimed[best.track.ID]:
+			m.Status = StatusDuplicate
+		default:
+			m.Status = StatusMatched
+			m.Track = best.track
+			claimed[best.track.ID] = true
+		}
+	}
+	return matches
+}
+
+// bestCandidate 返回 pool 中最相似的曲目及最高分和次高分
+func bestCandidate(pool []*candidate, title, stripped, artist string) (*candidate, float64, float64) {
+	var best *candidate
+	bestScore, secondScore := 0.0, 0.0
+	for _, c := range pool {
+		s := score(title, stripped, artist, c)
+		switch {
+		case s > bestScore:
+			best, secondScore, bestScore = c, bestScore, s
+		case s > secondScore:
+			secondScore = s
+		}
+	}
+	return best, bestScore, secondScore
+}
+
+// score 计算一行和一首曲目的相似度（0～1），行中没有歌手时只比较标题
+func score(title, stripped, artist string, c *candidate) float64 {
+	// 标题在总分中占 0.7，标题相似度低于 minTitle 时即使歌手完全相同也达不到 MinScore
+	minTitle := (MinScore - (1 - titleWeight)) / titleWeight
+	t := similarity(title, c.title, minTitle)
+	if s := strippedPenalty * similarity(stripped, c.stripped, minTitle); s > t {
+		t = s
+	}
+	if t < minTitle {
+		return 0
+	}
+	if artist == "" || c.artist == "" {
+		return t
+	}
+	a := similarity(artist, c.artist, 0)
+	// "Beatles" 与 "The Beatles" 这类一方包含另一方的歌手名视为基本相同
+	if a < strippedPenalty && (strings.Contains(artist, c.artist) || strings.Contains(c.artist, artist)) {
+		a = strippedPenalty
+	}
+	return titleWeight*t + (1-titleWeight)*a
+}
+
+// similarity 基于编辑距离的相似度；长度相差太大、不可能达到 minRatio 时直接返回 0
+func similarity(a, b string, minRatio float64) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	if float64(abs(len(ra)-len(rb))) > (1-minRatio)*float64(longest) {
+		return 0
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// normalize 只保留字母和数字并转为小写，忽略标点、空格和大小写差异
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// stripBrackets 去掉圆括号、方括号和全角括号中的内容，如 "Song (Remastered 2011)"
+func stripBrackets(s string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '(', '[', '（', '【':
+			depth++
+		case ')', ']', '）', '】':
+			if depth > 0 {
+				depth--
+			}
+		default:
+			if depth == 0 {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,193 @@
+package metaimport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"Bt1QFM/model"
+)
+
+// 支持的文件格式
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// MaxRows 单次导入的最多行数
+const MaxRows = 2000
+
+var (
+	// ErrUnsupportedFormat 不支持的文件格式
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	// ErrMissingTitleColumn CSV 表头中没有标题列
+	ErrMissingTitleColumn = errors.New("missing title column")
+	// ErrTooManyRows 行数超过 MaxRows
+	ErrTooManyRows = errors.New("too many rows")
+)
+
+// Row 导入文件中的一行元数据，除标题外的空字段表示不修改
+type Row struct {
+	Line   int    `json:"line"` // CSV 中的行号（表头为第 1 行）或 JSON 数组中的序号（从 1 开始）
+	Title  string `json:"title"`
+	Artist string `json:"artist,omitempty"`
+	Album  string `json:"album,omitempty"`
+	Year   int    `json:"year,omitempty"`
+	Genre  string `json:"genre,omitempty"`
+	Error  string `json:"error,omitempty"` // 该行本身无效的原因，例如缺少标题或年份无法识别
+}
+
+// columnAliases 表头名（小写）对应的字段，兼容常见音乐软件导出的列名
+var columnAliases = map[string]string{
+	"title":        "title",
+	"name":         "title",
+	"song":         "title",
+	"track":        "title",
+	"track name":   "title",
+	"artist":       "artist",
+	"artist name":  "artist",
+	"album":        "album",
+	"album name":   "album",
+	"year":         "year",
+	"date":         "year",
+	"release date": "year",
+	"genre":        "genre",
+}
+
+// Parse 解析 CSV（带表头）或 JSON（对象数组）格式的元数据导出文件
+func Parse(r io.Reader, format string) ([]Row, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatJSON:
+		return parseJSON(r)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+func parseCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []Row{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := columnAliases[name]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, ErrMissingTitleColumn
+	}
+
+	cell := func(record []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+	rows := make([]Row, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+		if isBlank(record) {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, newRow(line, cell(record, "title"), cell(record, "artist"), cell(record, "album"), cell(record, "year"), cell(record, "genre")))
+	}
+	return rows, nil
+}
+
+// jsonRow JSON 导出中的一首歌曲，year 可以是数字或字符串
+type jsonRow struct {
+	Title  string      `json:"title"`
+	Artist string      `json:"artist"`
+	Album  string      `json:"album"`
+	Year   interface{} `json:"year"`
+	Genre  string      `json:"genre"`
+}
+
+func parseJSON(r io.Reader) ([]Row, error) {
+	var records []jsonRow
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	if len(records) > MaxRows {
+		return nil, ErrTooManyRows
+	}
+
+	rows := make([]Row, 0, len(records))
+	for i, record := range records {
+		var year string
+		switch v := record.Year.(type) {
+		case float64:
+			year = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			year = v
+		}
+		rows = append(rows, newRow(i+1, record.Title, record.Artist, record.Album, year, record.Genre))
+	}
+	return rows, nil
+}
+
+// newRow 去除首尾空白并解析年份，缺少标题或年份无法识别时记录错误
+func newRow(line int, title, artist, album, year, genre string) Row {
+	row := Row{
+		Line:   line,
+		Title:  strings.TrimSpace(title),
+		Artist: strings.TrimSpace(artist),
+		Album:  strings.TrimSpace(album),
+		Genre:  strings.TrimSpace(genre),
+	}
+	if row.Title == "" {
+		row.Error = "missing title"
+		return row
+	}
+	if year = strings.TrimSpace(year); year != "" {
+		// 兼容 2001-05-01 这类完整日期，只取年份
+		if len(year) > 4 && (year[4] == '-' || year[4] == '/' || year[4] == '.') {
+			year = year[:4]
+		}
+		y, err := strconv.Atoi(year)
+		if err != nil || !model.ValidYear(y) {
+			row.Error = "invalid year"
+			return row
+		}
+		row.Year = y
+	}
+	return row
+}
+
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
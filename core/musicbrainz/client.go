@@ -0,0 +1,261 @@
+package musicbrainz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// userAgent MusicBrainz 要求提供可识别的 User-Agent，否则会拒绝请求
+	userAgent = "Bt1QFM/1.0 (album enrichment)"
+	// minInterval MusicBrainz 限制每个客户端每秒最多一次请求
+	minInterval = time.Second
+	// maxResponseSize 响应体的最大字节数
+	maxResponseSize = 4 << 20
+)
+
+// ErrNotFound 发行版不存在
+var ErrNotFound = errors.New("musicbrainz: release not found")
+
+// ReleaseSummary 搜索结果中的一个发行版
+type ReleaseSummary struct {
+	ID         string `json:"id"`
+	Title      string `json:"title"`
+	Artist     string `json:"artist"`
+	Date       string `json:"date"`
+	Country    string `json:"country"`
+	TrackCount int    `json:"trackCount"`
+	Score      int    `json:"score"` // 与查询条件的匹配度，0-100
+}
+
+// Release 发行版的详细信息
+type Release struct {
+	ID     string   `json:"id"`
+	Title  string   `json:"title"`
+	Artist string   `json:"artist"`
+	Date   string   `json:"date"` // YYYY、YYYY-MM 或 YYYY-MM-DD
+	Label  string   `json:"label"`
+	Media  []Medium `json:"media"`
+}
+
+// Medium 发行版中的一张碟
+type Medium struct {
+	Position int     `json:"position"` // 碟号，从 1 开始
+	Format   string  `json:"format"`
+	Tracks   []Track `json:"tracks"`
+}
+
+// Track 碟中的一首曲目
+type Track struct {
+	Position int    `json:"position"` // 碟内序号，从 1 开始
+	Title    string `json:"title"`
+	LengthMs int    `json:"lengthMs"`
+}
+
+// TrackCount 发行版的曲目总数
+func (r *Release) TrackCount() int {
+	count := 0
+	for _, m := range r.Media {
+		count += len(m.Tracks)
+	}
+	return count
+}
+
+// ParseDate 解析 MusicBrainz 的日期，只有年份或年月时取该年或该月的第一天
+func ParseDate(date string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid release date %q", date)
+}
+
+// Client MusicBrainz Web Service v2 客户端，请求按 minInterval 串行发送
+type Client struct {
+	baseURL string
+	client  *http.Client
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NewClient 创建 MusicBrainz 客户端，baseURL 可指向镜像
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// artistCredit 发行版和曲目的署名
+type artistCredit []struct {
+	Name       string `json:"name"`
+	JoinPhrase string `json:"joinphrase"`
+}
+
+// String 拼接完整的署名
+func (c artistCredit) String() string {
+	var b strings.Builder
+	for _, credit := range c {
+		b.WriteString(credit.Name)
+		b.WriteString(credit.JoinPhrase)
+	}
+	return b.String()
+}
+
+// SearchReleases 按艺术家和专辑名搜索发行版，按匹配度从高到低返回
+func (c *Client) SearchReleases(ctx context.Context, artist, album string, limit int) ([]ReleaseSummary, error) {
+	query := fmt.Sprintf(`release:"%s"`, escapeQuery(album))
+	if artist != "" {
+		query += fmt.Sprintf(` AND artist:"%s"`, escapeQuery(artist))
+	}
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("limit", fmt.Sprint(limit))
+	params.Set("fmt", "json")
+
+	var result struct {
+		Releases []struct {
+			ID           string       `json:"id"`
+			Title        string       `json:"title"`
+			Date         string       `json:"date"`
+			Country      string       `json:"country"`
+			Score        int          `json:"score"`
+			TrackCount   int          `json:"track-count"`
+			ArtistCredit artistCredit `json:"artist-credit"`
+		} `json:"releases"`
+	}
+	if err := c.get(ctx, "/ws/2/release/?"+params.Encode(), &result); err != nil {
+		return nil, err
+	}
+
+	releases := make([]ReleaseSummary, 0, len(result.Releases))
+	for _, r := range result.Releases {
+		releases = append(releases, ReleaseSummary{
+			ID:         r.ID,
+			Title:      r.Title,
+			Artist:     r.ArtistCredit.String(),
+			Date:       r.Date,
+			Country:    r.Country,
+			TrackCount: r.TrackCount,
+			Score:      r.Score,
+		})
+	}
+	return releases, nil
+}
+
+// GetRelease 获取发行版的日期、厂牌和各碟的曲目列表
+func (c *Client) GetRelease(ctx context.Context, id string) (*Release, error) {
+	params := url.Values{}
+	params.Set("inc", "artist-credits+labels+recordings")
+	params.Set("fmt", "json")
+
+	var result struct {
+		ID           string       `json:"id"`
+		Title        string       `json:"title"`
+		Date         string       `json:"date"`
+		ArtistCredit artistCredit `json:"artist-credit"`
+		LabelInfo    []struct {
+			Label *struct {
+				Name string `json:"name"`
+			} `json:"label"`
+		} `json:"label-info"`
+		Media []struct {
+			Position int    `json:"position"`
+			Format   string `json:"format"`
+			Tracks   []struct {
+				Position int    `json:"position"`
+				Title    string `json:"title"`
+				Length   int    `json:"length"`
+			} `json:"tracks"`
+		} `json:"media"`
+	}
+	if err := c.get(ctx, "/ws/2/release/"+url.PathEscape(id)+"?"+params.Encode(), &result); err != nil {
+		return nil, err
+	}
+
+	release := &Release{
+		ID:     result.ID,
+		Title:  result.Title,
+		Artist: result.ArtistCredit.String(),
+		Date:   result.Date,
+		Media:  make([]Medium, 0, len(result.Media)),
+	}
+	for _, info := range result.LabelInfo {
+		if info.Label != nil && info.Label.Name != "" {
+			release.Label = info.Label.Name
+			break
+		}
+	}
+	for _, m := range result.Media {
+		medium := Medium{Position: m.Position, Format: m.Format, Tracks: make([]Track, 0, len(m.Tracks))}
+		for _, t := range m.Tracks {
+			medium.Tracks = append(medium.Tracks, Track{Position: t.Position, Title: t.Title, LengthMs: t.Length})
+		}
+		release.Media = append(release.Media, medium)
+	}
+	return release, nil
+}
+
+// get 发送 GET 请求并解析 JSON 响应，与上一次请求至少间隔 minInterval
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("musicbrainz request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("musicbrainz returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("invalid musicbrainz response: %w", err)
+	}
+	return nil
+}
+
+// wait 等待到允许发送下一次请求
+func (c *Client) wait(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if delay := minInterval - time.Since(c.last); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	c.last = time.Now()
+	return nil
+}
+
+// escapeQuery 转义 Lucene 查询中引号内的特殊字符
+func escapeQuery(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,100 @@
+package musicbrainz
+
+import (
+	"strings"
+	"unicode"
+)
+
+// LocalTrack 曲库中待匹配的曲目
+type LocalTrack struct {
+	ID    int64
+	Title string
+}
+
+// TrackMatch 曲库曲目在发行版中对应的位置，未匹配时 Matched 为 false
+type TrackMatch struct {
+	TrackID    int64  `json:"trackId"`
+	Matched    bool   `json:"matched"`
+	DiscNumber int    `json:"discNumber,omitempty"`
+	Position   int    `json:"position,omitempty"`
+	Title      string `json:"releaseTitle,omitempty"` // 发行版中的曲目名
+}
+
+// releaseTrack 展开后的发行版曲目
+type releaseTrack struct {
+	disc  int
+	track Track
+	key   string
+	used  bool
+}
+
+// MatchTracks 按曲目名把曲库曲目对应到发行版的碟号和序号，返回顺序与 local 一致
+// 先按规范化后的曲目名完全匹配，再按包含关系匹配（如 "Song (Remastered)"）；
+// 曲目数与发行版一致时，剩余的曲目按原有顺序依次对应剩余的发行版曲目
+func MatchTracks(local []LocalTrack, release *Release) []TrackMatch {
+	var candidates []*releaseTrack
+	for _, m := range release.Media {
+		for _, t := range m.Tracks {
+			candidates = append(candidates, &releaseTrack{disc: m.Position, track: t, key: normalizeTitle(t.Title)})
+		}
+	}
+
+	matches := make([]TrackMatch, len(local))
+	keys := make([]string, len(local))
+	for i, t := range local {
+		matches[i] = TrackMatch{TrackID: t.ID}
+		keys[i] = normalizeTitle(t.Title)
+	}
+
+	assign := func(i int, c *releaseTrack) {
+		c.used = true
+		matches[i].Matched = true
+		matches[i].DiscNumber = c.disc
+		matches[i].Position = c.track.Position
+		matches[i].Title = c.track.Title
+	}
+	passes := []func(key, candidate string) bool{
+		func(key, candidate string) bool { return key == candidate },
+		func(key, candidate string) bool {
+			return strings.Contains(key, candidate) || strings.Contains(candidate, key)
+		},
+	}
+	for _, same := range passes {
+		for i := range local {
+			if matches[i].Matched || keys[i] == "" {
+				continue
+			}
+			for _, c := range candidates {
+				if !c.used && c.key != "" && same(keys[i], c.key) {
+					assign(i, c)
+					break
+				}
+			}
+		}
+	}
+
+	if len(local) == len(candidates) {
+		next := 0
+		for i := range local {
+			if matches[i].Matched {
+				continue
+			}
+			for candidates[next].used {
+				next++
+			}
+			assign(i, candidates[next])
+		}
+	}
+	return matches
+}
+
+// normalizeTitle 只保留字母和数字并转为小写，忽略标点、空格和大小写差异
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,205 @@
+package netease
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// ErrArtistNotFound 网易云上没有找到该歌手
+var ErrArtistNotFound = errors.New("未找到歌手")
+
+// artistAlbumLimit 歌手详情中返回的专辑数量
+const artistAlbumLimit = 30
+
+// SearchArtist 按名称搜索歌手，优先返回名称或别名完全一致的结果
+func (c *Client) SearchArtist(name string) (*model.NeteaseArtistInfo, error) {
+	params := url.Values{}
+	params.Set("keywords", name)
+	params.Set("type", "100")
+	params.Set("limit", "10")
+	apiURL := fmt.Sprintf("%s/cloudsearch?%s", c.BaseURL, params.Encode())
+	logger.Info("[SearchArtist] 搜索歌手", logger.String("name", name))
+	resp, err := c.HTTPClient.Get(apiURL)
+	if err != nil {
+		logger.Error("[SearchArtist] 请求失败", logger.String("name", name), logger.ErrorField(err))
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Result struct {
+			Artists []struct {
+				ID        int64    `json:"id"`
+				Name      string   `json:"name"`
+				Alias     []string `json:"alias"`
+				PicURL    string   `json:"picUrl"`
+				AlbumSize int      `json:"albumSize"`
+				MusicSize int      `json:"musicSize"`
+			} `json:"artists"`
+		} `json:"result"`
+		Code int `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		logger.Error("[SearchArtist] 解析响应失败", logger.String("name", name), logger.ErrorField(err))
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if result.Code != 200 {
+		return nil, fmt.Errorf("API返回错误码: %d", result.Code)
+	}
+
+	artists := result.Result.Artists
+	if len(artists) == 0 {
+		return nil, ErrArtistNotFound
+	}
+	best := artists[0]
+	for _, a := range artists {
+		if strings.EqualFold(a.Name, name) || containsFold(a.Alias, name) {
+			best = a
+			break
+		}
+	}
+	return &model.NeteaseArtistInfo{
+		ID:        best.ID,
+		Name:      best.Name,
+		Alias:     best.Alias,
+		PicURL:    best.PicURL,
+		AlbumSize: best.AlbumSize,
+		MusicSize: best.MusicSize,
+	}, nil
+}
+
+// GetArtistDesc 获取歌手简介
+func (c *Client) GetArtistDesc(artistID int64) (string, error) {
+	apiURL := fmt.Sprintf("%s/artist/desc?id=%d", c.BaseURL, artistID)
+	resp, err := c.HTTPClient.Get(apiURL)
+	if err != nil {
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		BriefDesc string `json:"briefDesc"`
+		Code      int    `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("解析响应失败: %w", err)
+	}
+	if result.Code != 200 {
+		return "", fmt.Errorf("API返回错误码: %d", result.Code)
+	}
+	return strings.TrimSpace(result.BriefDesc), nil
+}
+
+// GetSimilarArtists 获取相似歌手，该接口需要登录，使用 NETEASE_COOKIE，未配置时通常返回错误码
+func (c *Client) GetSimilarArtists(artistID int64) ([]model.NeteaseArtist, error) {
+	req, err := c.createRequest(http.MethodGet, fmt.Sprintf("%s/simi/artist?id=%d", c.BaseURL, artistID))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Artists []model.NeteaseArtist `json:"artists"`
+		Code    int                   `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if result.Code != 200 {
+		return nil, fmt.Errorf("API返回错误码: %d", result.Code)
+	}
+	return result.Artists, nil
+}
+
+// GetArtistAlbums 获取歌手的专辑，按发行时间倒序
+func (c *Client) GetArtistAlbums(artistID int64, limit int) ([]model.NeteaseArtistAlbum, error) {
+	apiURL := fmt.Sprintf("%s/artist/album?id=%d&limit=%d", c.BaseURL, artistID, limit)
+	resp, err := c.HTTPClient.Get(apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		HotAlbums []model.NeteaseArtistAlbum `json:"hotAlbums"`
+		Code      int                        `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if result.Code != 200 {
+		return nil, fmt.Errorf("API返回错误码: %d", result.Code)
+	}
+	return result.HotAlbums, nil
+}
+
+// GetArtistInfo 按名称获取歌手详情，简介、热门歌曲和专辑并行获取
+// 只有搜索歌手失败时返回错误，其余部分失败时对应字段为空
+func (c *Client) GetArtistInfo(name string) (*model.NeteaseArtistInfo, error) {
+	info, err := c.SearchArtist(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		bio, err := c.GetArtistDesc(info.ID)
+		if err != nil {
+			logger.Warn("[GetArtistInfo] 获取歌手简介失败", logger.Int64("artist_id", info.ID), logger.ErrorField(err))
+			return
+		}
+		info.Bio = bio
+	}()
+	go func() {
+		defer wg.Done()
+		songs, err := c.GetArtistTopSongs(strconv.FormatInt(info.ID, 10))
+		if err != nil {
+			logger.Warn("[GetArtistInfo] 获取热门歌曲失败", logger.Int64("artist_id", info.ID), logger.ErrorField(err))
+			return
+		}
+		info.TopSongs = songs
+	}()
+	go func() {
+		defer wg.Done()
+		albums, err := c.GetArtistAlbums(info.ID, artistAlbumLimit)
+		if err != nil {
+			logger.Warn("[GetArtistInfo] 获取歌手专辑失败", logger.Int64("artist_id", info.ID), logger.ErrorField(err))
+			return
+		}
+		info.Albums = albums
+	}()
+	wg.Wait()
+
+	if info.TopSongs == nil {
+		info.TopSongs = []model.NeteaseSong{}
+	}
+	if info.Albums == nil {
+		info.Albums = []model.NeteaseArtistAlbum{}
+	}
+	return info, nil
+}
+
+// containsFold 列表中是否有与 s 忽略大小写相等的项
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
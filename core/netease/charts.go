@@ -0,0 +1,127 @@
+package netease
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// 新歌速递的地区，对应 /top/song 的 type 参数
+const (
+	NewSongsAll     = 0  // 全部
+	NewSongsChinese = 7  // 华语
+	NewSongsWestern = 96 // 欧美
+	NewSongsJapan   = 8  // 日本
+	NewSongsKorea   = 16 // 韩国
+)
+
+// NewSongAreas 支持的新歌地区，键为接口参数
+var NewSongAreas = map[string]int{
+	"all":     NewSongsAll,
+	"chinese": NewSongsChinese,
+	"western": NewSongsWestern,
+	"japan":   NewSongsJapan,
+	"korea":   NewSongsKorea,
+}
+
+// GetToplists 获取所有排行榜
+func (c *Client) GetToplists() ([]model.NeteaseToplist, error) {
+	url := fmt.Sprintf("%s/toplist", c.BaseURL)
+	logger.Info("[GetToplists] 获取排行榜列表")
+	resp, err := c.HTTPClient.Get(url)
+	if err != nil {
+		logger.Error("[GetToplists] 请求失败", logger.ErrorField(err))
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		List []model.NeteaseToplist `json:"list"`
+		Code int                    `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		logger.Error("[GetToplists] 解析响应失败", logger.ErrorField(err))
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if result.Code != 200 {
+		return nil, fmt.Errorf("API返回错误码: %d", result.Code)
+	}
+	logger.Info("[GetToplists] 成功获取排行榜", logger.Int("count", len(result.List)))
+	return result.List, nil
+}
+
+// GetNewSongs 获取新歌速递，area 为 NewSongs* 常量
+func (c *Client) GetNewSongs(area int) ([]model.NeteaseSong, error) {
+	url := fmt.Sprintf("%s/top/song?type=%d", c.BaseURL, area)
+	logger.Info("[GetNewSongs] 获取新歌速递", logger.Int("area", area))
+	resp, err := c.HTTPClient.Get(url)
+	if err != nil {
+		logger.Error("[GetNewSongs] 请求失败", logger.Int("area", area), logger.ErrorField(err))
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 该接口使用旧版字段名（artists/album/duration）
+	var result struct {
+		Data []struct {
+			ID       int64                 `json:"id"`
+			Name     string                `json:"name"`
+			Artists  []model.NeteaseArtist `json:"artists"`
+			Album    model.NeteaseAlbum    `json:"album"`
+			Duration int                   `json:"duration"`
+		} `json:"data"`
+		Code int `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		logger.Error("[GetNewSongs] 解析响应失败", logger.Int("area", area), logger.ErrorField(err))
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if result.Code != 200 {
+		return nil, fmt.Errorf("API返回错误码: %d", result.Code)
+	}
+
+	songs := make([]model.NeteaseSong, 0, len(result.Data))
+	for _, s := range result.Data {
+		songs = append(songs, model.NeteaseSong{
+			ID:       s.ID,
+			Name:     s.Name,
+			Artists:  s.Artists,
+			Album:    s.Album,
+			Duration: s.Duration,
+			CoverURL: s.Album.PicURL,
+		})
+	}
+	logger.Info("[GetNewSongs] 成功获取新歌", logger.Int("area", area), logger.Int("songs_count", len(songs)))
+	return songs, nil
+}
+
+// GetArtistTopSongs 获取歌手的热门歌曲（最多50首）
+func (c *Client) GetArtistTopSongs(artistID string) ([]model.NeteaseSong, error) {
+	url := fmt.Sprintf("%s/artist/top/song?id=%s", c.BaseURL, artistID)
+	logger.Info("[GetArtistTopSongs] 获取歌手热门歌曲", logger.String("artist_id", artistID))
+	resp, err := c.HTTPClient.Get(url)
+	if err != nil {
+		logger.Error("[GetArtistTopSongs] 请求失败", logger.String("artist_id", artistID), logger.ErrorField(err))
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Songs []model.NeteaseSong `json:"songs"`
+		Code  int                 `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		logger.Error("[GetArtistTopSongs] 解析响应失败", logger.String("artist_id", artistID), logger.ErrorField(err))
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if result.Code != 200 {
+		return nil, fmt.Errorf("API返回错误码: %d", result.Code)
+	}
+	for i := range result.Songs {
+		result.Songs[i].CoverURL = result.Songs[i].Album.PicURL
+	}
+	logger.Info("[GetArtistTopSongs] 成功获取热门歌曲", logger.String("artist_id", artistID), logger.Int("songs_count", len(result.Songs)))
+	return result.Songs, nil
+}
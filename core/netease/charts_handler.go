@@ -0,0 +1,126 @@
+package netease
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/mux"
+)
+
+// 浏览接口返回的歌曲数量
+const (
+	defaultBrowseLimit = 30
+	maxBrowseLimit     = 100
+)
+
+// ChartsResponse 排行榜列表响应
+type ChartsResponse struct {
+	Success bool                   `json:"success"`
+	Data    []model.NeteaseToplist `json:"data"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// HandleCharts 返回所有排行榜
+func (h *NeteaseHandler) HandleCharts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	charts, err := h.client.GetToplists()
+	if err != nil {
+		logger.Error("[HandleCharts] 获取排行榜失败", logger.ErrorField(err))
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(ChartsResponse{Success: false, Error: "获取排行榜失败"})
+		return
+	}
+	json.NewEncoder(w).Encode(ChartsResponse{Success: true, Data: charts})
+}
+
+// HandleChartTracks 返回排行榜中的歌曲，支持 limit 参数
+func (h *NeteaseHandler) HandleChartTracks(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	songs, err := h.client.GetPlaylistTracks(id)
+	if err != nil {
+		logger.Error("[HandleChartTracks] 获取排行榜歌曲失败", logger.String("id", id), logger.ErrorField(err))
+		writeSongsError(w, http.StatusBadGateway, "获取排行榜歌曲失败")
+		return
+	}
+	writeSongs(w, songs, browseLimit(r))
+}
+
+// HandleNewSongs 返回新歌速递，area 参数为 all（默认）、chinese、western、japan、korea
+func (h *NeteaseHandler) HandleNewSongs(w http.ResponseWriter, r *http.Request) {
+	areaName := r.URL.Query().Get("area")
+	if areaName == "" {
+		areaName = "all"
+	}
+	area, ok := NewSongAreas[areaName]
+	if !ok {
+		writeSongsError(w, http.StatusBadRequest, "不支持的地区: "+areaName)
+		return
+	}
+
+	songs, err := h.client.GetNewSongs(area)
+	if err != nil {
+		logger.Error("[HandleNewSongs] 获取新歌失败", logger.String("area", areaName), logger.ErrorField(err))
+		writeSongsError(w, http.StatusBadGateway, "获取新歌失败")
+		return
+	}
+	writeSongs(w, songs, browseLimit(r))
+}
+
+// HandleArtistTopSongs 返回歌手的热门歌曲
+func (h *NeteaseHandler) HandleArtistTopSongs(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	songs, err := h.client.GetArtistTopSongs(id)
+	if err != nil {
+		logger.Error("[HandleArtistTopSongs] 获取歌手热门歌曲失败", logger.String("artist_id", id), logger.ErrorField(err))
+		writeSongsError(w, http.StatusBadGateway, "获取歌手热门歌曲失败")
+		return
+	}
+	writeSongs(w, songs, browseLimit(r))
+}
+
+// browseLimit 解析 limit 参数，默认 30，最大 100
+func browseLimit(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return defaultBrowseLimit
+	}
+	return min(limit, maxBrowseLimit)
+}
+
+// writeSongs 以搜索结果相同的格式返回歌曲列表
+func writeSongs(w http.ResponseWriter, songs []model.NeteaseSong, limit int) {
+	if len(songs) > limit {
+		songs = songs[:limit]
+	}
+	response := SearchResponse{
+		Success: true,
+		Data:    make([]SearchSongItem, 0, len(songs)),
+	}
+	for _, song := range songs {
+		artistNames := make([]string, len(song.Artists))
+		for i, artist := range song.Artists {
+			artistNames[i] = artist.Name
+		}
+		response.Data = append(response.Data, SearchSongItem{
+			ID:       song.ID,
+			Name:     song.Name,
+			Artists:  artistNames,
+			Album:    song.Album.Name,
+			Duration: song.Duration,
+			PicURL:   song.Album.PicURL,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeSongsError 以搜索结果相同的格式返回错误
+func writeSongsError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(SearchResponse{Success: false, Error: msg})
+}
@@ -31,7 +31,8 @@ func NewClient() *Client {
 	return &Client{
 		BaseURL: baseURL,
 		HTTPClient: &http.Client{
-			Timeout: 10 * time.Second, // 设置默认超时
+			Timeout:   10 * time.Second, // 设置默认超时，包含重试和切换备用地址的时间
+			Transport: sharedTransport,  // 重试、熔断和备用地址
 		},
 	}
 }
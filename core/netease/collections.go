@@ -25,7 +25,7 @@ func (h *NeteaseHandler) HandleUserPlaylists(w http.ResponseWriter, r *http.Requ
 	apiURL := fmt.Sprintf("%s/user/playlist?uid=%s", cfg.NeteaseAPIURL, url.QueryEscape(uid))
 
 	// 发送请求到网易云API
-	resp, err := http.Get(apiURL)
+	resp, err := h.client.HTTPClient.Get(apiURL)
 	if err != nil {
 		logger.Error("获取用户歌单失败", logger.ErrorField(err))
 		http.Error(w, "Failed to fetch user playlists", http.StatusInternalServerError)
@@ -43,7 +43,6 @@ func (h *NeteaseHandler) HandleUserPlaylists(w http.ResponseWriter, r *http.Requ
 
 	// 设置响应头
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// 返回结果
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
@@ -67,7 +66,7 @@ func (h *NeteaseHandler) HandleGetUserIDs(w http.ResponseWriter, r *http.Request
 	apiURL := fmt.Sprintf("%s/get/userids?nicknames=%s", cfg.NeteaseAPIURL, url.QueryEscape(nicknames))
 
 	// 发送请求到网易云API
-	resp, err := http.Get(apiURL)
+	resp, err := h.client.HTTPClient.Get(apiURL)
 	if err != nil {
 		logger.Error("获取用户ID失败", logger.ErrorField(err))
 		http.Error(w, "Failed to fetch user IDs", http.StatusInternalServerError)
@@ -85,7 +84,6 @@ func (h *NeteaseHandler) HandleGetUserIDs(w http.ResponseWriter, r *http.Request
 
 	// 设置响应头
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// 返回结果
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
@@ -111,7 +109,7 @@ func (h *NeteaseHandler) HandlePlaylistDetail(w http.ResponseWriter, r *http.Req
 	playlistInfoURL := fmt.Sprintf("%s/playlist/detail?id=%s", cfg.NeteaseAPIURL, url.QueryEscape(id))
 
 	// 发送请求获取歌单基本信息
-	playlistResp, err := http.Get(playlistInfoURL)
+	playlistResp, err := h.client.HTTPClient.Get(playlistInfoURL)
 	if err != nil {
 		logger.Error("获取歌单基本信息失败", logger.ErrorField(err))
 		http.Error(w, "Failed to fetch playlist info", http.StatusInternalServerError)
@@ -131,7 +129,7 @@ func (h *NeteaseHandler) HandlePlaylistDetail(w http.ResponseWriter, r *http.Req
 	trackListURL := fmt.Sprintf("%s/playlist/track/all?id=%s", cfg.NeteaseAPIURL, url.QueryEscape(id))
 
 	// 发送请求获取完整歌曲列表
-	trackResp, err := http.Get(trackListURL)
+	trackResp, err := h.client.HTTPClient.Get(trackListURL)
 	if err != nil {
 		logger.Error("获取歌单歌曲列表失败", logger.ErrorField(err))
 		http.Error(w, "Failed to fetch track list", http.StatusInternalServerError)
@@ -198,7 +196,6 @@ func (h *NeteaseHandler) HandlePlaylistDetail(w http.ResponseWriter, r *http.Req
 
 	// 设置响应头
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// 返回合并后的结果
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
@@ -242,7 +239,7 @@ func (h *NeteaseHandler) HandleUpdateNeteaseInfo(userRepo repository.UserReposit
 		}
 
 		// 更新用户网易云信息 - 用户名可以为空
-		if err := userRepo.UpdateNeteaseInfo(userID, req.NeteaseUsername, req.NeteaseUID); err != nil {
+		if err := userRepo.UpdateNeteaseInfo(r.Context(), userID, req.NeteaseUsername, req.NeteaseUID); err != nil {
 			logger.Error("更新用户网易云信息失败", logger.ErrorField(err))
 			http.Error(w, "Failed to update netease info", http.StatusInternalServerError)
 			return
@@ -250,7 +247,6 @@ func (h *NeteaseHandler) HandleUpdateNeteaseInfo(userRepo repository.UserReposit
 
 		// 设置响应头
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
 
 		// 返回成功响应
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
@@ -46,7 +46,6 @@ type LyricResponse struct {
 func (h *NeteaseHandler) HandleLyricNew(w http.ResponseWriter, r *http.Request) {
 	// 设置响应头
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// 获取歌曲ID参数
 	songIDStr := r.URL.Query().Get("id")
@@ -125,7 +124,7 @@ func (c *Client) GetLyric(songID string) (*LyricResponse, error) {
 		logger.String("songId", songID))
 
 	// 发送HTTP请求
-	resp, err := http.Get(url)
+	resp, err := c.HTTPClient.Get(url)
 	if err != nil {
 		return nil, fmt.Errorf("请求歌词API失败: %w", err)
 	}
@@ -26,13 +26,13 @@ type NeteaseHandler struct {
 	config       *config.Config
 }
 
-// NewNeteaseHandler 创建新的网易云音乐处理器
-func NewNeteaseHandler(baseURL string, cfg *config.Config) *NeteaseHandler {
+// NewNeteaseHandler 创建新的网易云音乐处理器，mp3Processor 与流媒体处理共用，以便共享处理锁和预处理队列
+func NewNeteaseHandler(baseURL string, mp3Processor *audio.MP3Processor, cfg *config.Config) *NeteaseHandler {
 	client := NewClient()
 	client.SetBaseURL(baseURL)
 	return &NeteaseHandler{
 		client:       client,
-		mp3Processor: audio.NewMP3Processor(cfg.FFmpegPath),
+		mp3Processor: mp3Processor,
 		config:       cfg,
 	}
 }
@@ -0,0 +1,337 @@
+package netease
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+)
+
+// ErrCircuitOpen 所有网易云 API 地址都处于熔断状态，请求未发出
+var ErrCircuitOpen = errors.New("网易云API暂不可用（熔断中）")
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"    // 正常
+	BreakerOpen     = "open"      // 熔断中，请求直接跳过该地址
+	BreakerHalfOpen = "half-open" // 冷却结束，放行一个探测请求
+)
+
+// ResiliencePolicy 重试和熔断策略
+type ResiliencePolicy struct {
+	MaxRetries       int           // 每个地址的最大重试次数（不含首次请求）
+	RetryBackoff     time.Duration // 首次重试前的等待时间，之后每次翻倍
+	BreakerThreshold int           // 连续失败多少次后熔断，<=0 表示不熔断
+	BreakerCooldown  time.Duration // 熔断持续时间，之后放行探测请求
+}
+
+// maxRetryBackoff 单次重试等待的上限
+const maxRetryBackoff = 5 * time.Second
+
+// EndpointHealth 单个 API 地址的健康状况
+type EndpointHealth struct {
+	URL                 string     `json:"url"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	TotalRequests       int64      `json:"totalRequests"`
+	TotalFailures       int64      `json:"totalFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt,omitempty"`
+	LastFailureAt       *time.Time `json:"lastFailureAt,omitempty"`
+	OpenUntil           *time.Time `json:"openUntil,omitempty"`
+}
+
+// endpoint 一个 API 地址及其熔断器
+type endpoint struct {
+	mu      sync.Mutex
+	base    string
+	health  EndpointHealth
+	probing bool // 半开状态下已有探测请求在进行
+}
+
+// allow 判断是否可以向该地址发送请求
+func (e *endpoint) allow(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch e.health.State {
+	case BreakerOpen:
+		if now.Before(*e.health.OpenUntil) {
+			return false
+		}
+		e.health.State = BreakerHalfOpen
+		e.probing = true
+		return true
+	case BreakerHalfOpen:
+		if e.probing {
+			return false
+		}
+		e.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record 记录一次请求结果并更新熔断状态，返回该地址是否刚被熔断
+func (e *endpoint) record(err error, policy ResiliencePolicy, now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.health.TotalRequests++
+	e.probing = false
+	if err == nil {
+		e.health.State = BreakerClosed
+		e.health.ConsecutiveFailures = 0
+		e.health.OpenUntil = nil
+		e.health.LastSuccessAt = &now
+		return false
+	}
+
+	e.health.TotalFailures++
+	e.health.ConsecutiveFailures++
+	e.health.LastError = err.Error()
+	e.health.LastFailureAt = &now
+	halfOpen := e.health.State == BreakerHalfOpen
+	if policy.BreakerThreshold > 0 && (halfOpen || e.health.ConsecutiveFailures >= policy.BreakerThreshold) {
+		wasOpen := e.health.State == BreakerOpen
+		until := now.Add(policy.BreakerCooldown)
+		e.health.State = BreakerOpen
+		e.health.OpenUntil = &until
+		return !wasOpen
+	}
+	return false
+}
+
+// release 放弃本次请求的结果，半开状态下允许下一个请求继续探测
+func (e *endpoint) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.probing = false
+}
+
+// snapshot 返回当前健康状况的副本
+func (e *endpoint) snapshot() EndpointHealth {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.health
+}
+
+// resilientTransport 为网易云 API 请求提供重试、熔断和多地址切换
+// 请求地址以某个已配置的地址开头时，按配置顺序依次尝试可用的地址；否则只做重试
+type resilientTransport struct {
+	next http.RoundTripper
+
+	mu        sync.RWMutex
+	endpoints []*endpoint
+	policy    ResiliencePolicy
+}
+
+// sharedTransport 所有 Client 共享，熔断状态在进程内共享
+var sharedTransport = &resilientTransport{
+	next: http.DefaultTransport,
+	policy: ResiliencePolicy{
+		MaxRetries:       2,
+		RetryBackoff:     200 * time.Millisecond,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	},
+}
+
+// Configure 根据配置设置 API 地址列表和重试、熔断策略，应在创建 Client 前调用
+// 主地址为 NETEASE_API_URL，NETEASE_FALLBACK_URLS 中的地址按顺序作为备用
+func Configure(cfg *config.Config) {
+	urls := append([]string{cfg.NeteaseAPIURL}, cfg.NeteaseFallbackURLs...)
+	SetEndpoints(urls, ResiliencePolicy{
+		MaxRetries:       cfg.NeteaseMaxRetries,
+		RetryBackoff:     time.Duration(cfg.NeteaseRetryBackoffMs) * time.Millisecond,
+		BreakerThreshold: cfg.NeteaseBreakerThreshold,
+		BreakerCooldown:  time.Duration(cfg.NeteaseBreakerCooldownSeconds) * time.Second,
+	})
+}
+
+// SetEndpoints 设置 API 地址列表和策略，已有地址的健康状况会保留
+func SetEndpoints(urls []string, policy ResiliencePolicy) {
+	t := sharedTransport
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	existing := make(map[string]*endpoint, len(t.endpoints))
+	for _, e := range t.endpoints {
+		existing[e.base] = e
+	}
+	endpoints := make([]*endpoint, 0, len(urls))
+	seen := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		base := strings.TrimRight(strings.TrimSpace(u), "/")
+		if base == "" || seen[base] {
+			continue
+		}
+		seen[base] = true
+		if e, ok := existing[base]; ok {
+			endpoints = append(endpoints, e)
+			continue
+		}
+		endpoints = append(endpoints, &endpoint{base: base, health: EndpointHealth{URL: base, State: BreakerClosed}})
+	}
+	t.endpoints = endpoints
+	t.policy = policy
+}
+
+// Health 返回各 API 地址的健康状况，按尝试顺序排列
+func Health() []EndpointHealth {
+	t := sharedTransport
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make([]EndpointHealth, 0, len(t.endpoints))
+	for _, e := range t.endpoints {
+		out = append(out, e.snapshot())
+	}
+	return out
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	endpoints, policy := t.endpoints, t.policy
+	t.mu.RUnlock()
+
+	// 有请求体的请求无法安全重放，只发送一次
+	if req.Body != nil && req.GetBody == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	original := req.URL.String()
+	var path string
+	matched := false
+	for _, e := range endpoints {
+		if rest, ok := strings.CutPrefix(original, e.base); ok && (rest == "" || strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, "?")) {
+			path, matched = rest, true
+			break
+		}
+	}
+	if !matched {
+		return t.tryWithRetries(req, original, policy)
+	}
+
+	var errs []error
+	now := time.Now()
+	for i, e := range endpoints {
+		if !e.allow(now) {
+			continue
+		}
+		resp, err := t.tryWithRetries(req, e.base+path, policy)
+		// 调用方取消的请求不计入该地址的失败
+		if req.Context().Err() != nil {
+			e.release()
+			return resp, err
+		}
+		if opened := e.record(failure(resp, err), policy, time.Now()); opened {
+			logger.Warn("网易云API地址连续失败，已熔断",
+				logger.String("endpoint", e.base),
+				logger.Duration("cooldown", policy.BreakerCooldown))
+		}
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		// 还有备用地址时丢弃本次响应；最后一个地址的响应原样返回，由调用方处理状态码
+		if i < len(endpoints)-1 && hasAllowed(endpoints[i+1:], now) {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", e.base, failure(resp, err)))
+			logger.Warn("网易云API请求失败，切换到备用地址",
+				logger.String("endpoint", e.base),
+				logger.ErrorField(failure(resp, err)))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+	if len(errs) == 0 {
+		return nil, ErrCircuitOpen
+	}
+	return nil, errors.Join(errs...)
+}
+
+// tryWithRetries 向一个地址发送请求，网络错误和 5xx/429 响应按退避时间重试
+func (t *resilientTransport) tryWithRetries(req *http.Request, target string, policy ResiliencePolicy) (*http.Response, error) {
+	backoff := policy.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		attemptReq, err := cloneRequest(req, target)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.next.RoundTrip(attemptReq)
+		if (err == nil && !retryableStatus(resp.StatusCode)) || attempt >= policy.MaxRetries || req.Context().Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		// 退避时间加入最多 50% 的随机抖动，避免大量请求同时重试
+		wait := backoff
+		if wait > 0 {
+			wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// cloneRequest 复制请求并替换目标地址
+func cloneRequest(req *http.Request, target string) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	u, err := req.URL.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid netease API URL %q: %w", target, err)
+	}
+	clone.URL = u
+	clone.Host = ""
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// retryableStatus 上游过载或故障的状态码
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// failure 把请求结果转换为熔断器使用的错误
+func failure(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if retryableStatus(resp.StatusCode) {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// hasAllowed 是否还有未熔断的地址，不改变熔断器状态
+func hasAllowed(endpoints []*endpoint, now time.Time) bool {
+	for _, e := range endpoints {
+		h := e.snapshot()
+		if h.State != BreakerOpen || !now.Before(*h.OpenUntil) {
+			return true
+		}
+	}
+	return false
+}
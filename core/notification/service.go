@@ -0,0 +1,209 @@
+package notification
+
+import (
+	"context"
+	"errors"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+// ErrUserNotFound 接收通知的用户不存在或已被禁用
+var ErrUserNotFound = errors.New("user not found")
+
+// Pusher 向在线用户实时推送通知，由设备 Hub 实现
+type Pusher interface {
+	PushNotification(userID int64, payload interface{})
+	OnlineUsers() []int64
+}
+
+// AnnouncementReader 同步公告的已读状态，由公告仓库实现
+type AnnouncementReader interface {
+	MarkAsRead(userID uint, announcementID string) error
+}
+
+// PushMessage 推送给客户端的通知消息，附带推送后的未读数以便直接更新角标
+type PushMessage struct {
+	Notification *model.Notification `json:"notification"`
+	UnreadCount  int64               `json:"unreadCount"`
+}
+
+// Service 用户通知：关注、评论回复、房间邀请和公告都会写入通知表，
+// 设置了 Pusher 时同时推送给在线用户
+type Service struct {
+	repo          repository.NotificationRepository
+	userRepo      repository.UserRepository
+	pusher        Pusher
+	announcements AnnouncementReader
+}
+
+// NewService 创建通知服务
+func NewService(repo repository.NotificationRepository, userRepo repository.UserRepository) *Service {
+	return &Service{repo: repo, userRepo: userRepo}
+}
+
+// SetPusher 设置实时推送，未设置时客户端只能轮询未读数
+func (s *Service) SetPusher(pusher Pusher) {
+	s.pusher = pusher
+}
+
+// SetAnnouncementReader 设置公告已读同步，公告通知被标记已读时公告也随之已读
+func (s *Service) SetAnnouncementReader(reader AnnouncementReader) {
+	s.announcements = reader
+}
+
+// Notify 给一个用户发送通知；失败只记录日志，不影响触发通知的操作
+func (s *Service) Notify(ctx context.Context, n *model.Notification) {
+	if err := s.Send(ctx, n); err != nil {
+		logger.Warn("创建通知失败",
+			logger.Int64("userId", n.UserID),
+			logger.String("type", n.Type),
+			logger.ErrorField(err))
+	}
+}
+
+// Send 给一个用户发送通知并返回错误，用于通知本身就是操作结果的场景，例如房间邀请
+// 用户给自己的操作不产生通知；接收者不存在或已被禁用时返回 ErrUserNotFound
+func (s *Service) Send(ctx context.Context, n *model.Notification) error {
+	if n.ActorID > 0 && n.ActorID == n.UserID {
+		return nil
+	}
+	user, err := s.userRepo.GetUserByID(ctx, n.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.IsDisabled() {
+		return ErrUserNotFound
+	}
+
+	id, err := s.repo.CreateNotification(ctx, n)
+	if err != nil {
+		return err
+	}
+	n.ID = id
+	if n.ActorID > 0 && n.ActorName == "" {
+		// 列表查询时通过 JOIN 得到用户名，推送的通知需要自己补上
+		if actor, err := s.userRepo.GetUserByID(ctx, n.ActorID); err == nil && actor != nil {
+			n.ActorName = actor.Username
+		}
+	}
+	s.push(ctx, n.UserID, n)
+	return nil
+}
+
+// Broadcast 给受众内所有未禁用的用户发送同一条通知，例如管理员发布公告
+func (s *Service) Broadcast(ctx context.Context, n *model.Notification, audience model.NotificationAudience) {
+	created, err := s.repo.CreateForAudience(ctx, n, audience)
+	if err != nil {
+		logger.Warn("广播通知失败",
+			logger.String("type", n.Type),
+			logger.String("objectId", n.ObjectID),
+			logger.ErrorField(err))
+		return
+	}
+	logger.Info("已广播通知",
+		logger.String("type", n.Type),
+		logger.String("objectId", n.ObjectID),
+		logger.Int64("users", created))
+
+	if s.pusher == nil || created == 0 {
+		return
+	}
+	// 只推送给在线且在受众内的用户，每人推送自己的那条通知
+	received, err := s.repo.GetObjectNotifications(ctx, s.pusher.OnlineUsers(), n.Type, n.ObjectID)
+	if err != nil {
+		logger.Warn("读取广播通知失败",
+			logger.String("type", n.Type),
+			logger.String("objectId", n.ObjectID),
+			logger.ErrorField(err))
+		return
+	}
+	for _, userNotification := range received {
+		s.push(ctx, userNotification.UserID, userNotification)
+	}
+}
+
+// push 推送通知和最新的未读数给在线用户
+func (s *Service) push(ctx context.Context, userID int64, n *model.Notification) {
+	if s.pusher == nil {
+		return
+	}
+	unread, err := s.repo.CountUnread(ctx, userID)
+	if err != nil {
+		logger.Warn("统计未读通知失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		return
+	}
+	s.pusher.PushNotification(userID, &PushMessage{Notification: n, UnreadCount: unread})
+}
+
+// List 获取用户 ID 小于 beforeID 的通知（beforeID 为 0 时从最新开始），返回的 next 为 0 表示没有更多
+func (s *Service) List(ctx context.Context, userID int64, unreadOnly bool, beforeID int64, limit int) (items []*model.Notification, next int64, err error) {
+	limit = min(limit, model.MaxNotificationLimit)
+	items, err = s.repo.GetNotifications(ctx, userID, unreadOnly, beforeID, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(items) == limit {
+		next = items[len(items)-1].ID
+	}
+	return items, next, nil
+}
+
+// UnreadCount 获取用户的未读通知数
+func (s *Service) UnreadCount(ctx context.Context, userID int64) (int64, error) {
+	return s.repo.CountUnread(ctx, userID)
+}
+
+// MarkRead 将用户的一条通知标记为已读，通知不存在时返回 nil；公告通知同时标记公告已读
+func (s *Service) MarkRead(ctx context.Context, userID, id int64) (*model.Notification, error) {
+	n, err := s.repo.GetNotificationByID(ctx, userID, id)
+	if err != nil || n == nil {
+		return nil, err
+	}
+	if _, err := s.repo.MarkRead(ctx, userID, []int64{id}); err != nil {
+		return nil, err
+	}
+	if n.Type == model.NotificationAnnouncement && s.announcements != nil {
+		if err := s.announcements.MarkAsRead(uint(userID), n.ObjectID); err != nil {
+			logger.Warn("同步公告已读状态失败",
+				logger.Int64("userId", userID),
+				logger.String("announcementId", n.ObjectID),
+				logger.ErrorField(err))
+		}
+	}
+	return n, nil
+}
+
+// MarkAllRead 将用户的所有通知标记为已读，返回之前未读的数量
+// 公告通知只更新通知本身，公告列表中的已读状态仍由用户逐条确认
+func (s *Service) MarkAllRead(ctx context.Context, userID int64) (int64, error) {
+	return s.repo.MarkAllRead(ctx, userID)
+}
+
+// MarkObjectRead 将用户关于某个对象的通知标记为已读，例如在公告弹窗中确认了公告
+func (s *Service) MarkObjectRead(ctx context.Context, userID int64, notificationType, objectID string) {
+	if err := s.repo.MarkObjectRead(ctx, userID, notificationType, objectID); err != nil {
+		logger.Warn("标记通知已读失败",
+			logger.Int64("userId", userID),
+			logger.String("type", notificationType),
+			logger.String("objectId", objectID),
+			logger.ErrorField(err))
+	}
+}
+
+// MarkObjectUnread 将所有用户关于某个对象的通知重新标记为未读，例如公告被实质修改后
+func (s *Service) MarkObjectUnread(ctx context.Context, notificationType, objectID string) {
+	reset, err := s.repo.MarkObjectUnread(ctx, notificationType, objectID)
+	if err != nil {
+		logger.Warn("重置通知已读状态失败",
+			logger.String("type", notificationType),
+			logger.String("objectId", objectID),
+			logger.ErrorField(err))
+		return
+	}
+	logger.Info("已重置通知已读状态",
+		logger.String("type", notificationType),
+		logger.String("objectId", objectID),
+		logger.Int64("notifications", reset))
+}
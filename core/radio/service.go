@@ -0,0 +1,249 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/agent"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+// ErrRefillInProgress 已有一次续播正在进行
+var ErrRefillInProgress = errors.New("radio refill already in progress")
+
+const (
+	// refillTimeout 一次续播（生成关键词 + 搜索）的最长时间，同时也是续播锁的过期时间
+	refillTimeout = 2 * time.Minute
+	// historyWindow 分析口味时读取的播放历史条数
+	historyWindow = 200
+	// maxRecentSeeds、maxFavoriteSeeds 提示词中最近播放和最常听歌曲的数量
+	maxRecentSeeds   = 10
+	maxFavoriteSeeds = 10
+	// searchLimit 每个关键词取前几条搜索结果，第一条已在队列中时顺延
+	searchLimit = 3
+)
+
+// Service AI 电台：用户的播放队列快播完时，根据播放历史生成续播关键词，搜索后追加到队列末尾
+type Service struct {
+	agent       *agent.MusicAgent
+	historyRepo repository.PlayHistoryRepository
+}
+
+// NewService 创建 AI 电台服务
+func NewService(musicAgent *agent.MusicAgent, historyRepo repository.PlayHistoryRepository) *Service {
+	return &Service{
+		agent:       musicAgent,
+		historyRepo: historyRepo,
+	}
+}
+
+// Enable 开启电台
+func (s *Service) Enable(ctx context.Context, userID int64) error {
+	return cache.SetRadioEnabled(ctx, userID, true)
+}
+
+// Disable 关闭电台，已追加的歌曲保留在队列中
+func (s *Service) Disable(ctx context.Context, userID int64) error {
+	return cache.SetRadioEnabled(ctx, userID, false)
+}
+
+// NeedsRefill 队列为空或正在播放最后一首时需要续播，提前续播留出生成关键词和搜索的时间
+func NeedsRefill(playlistLen, currentIndex int) bool {
+	return currentIndex >= playlistLen-1
+}
+
+// OnHeartbeat 由播放心跳触发，电台开启且队列快播完时在后台续播
+func (s *Service) OnHeartbeat(ctx context.Context, userID int64, state *cache.UserPlaybackState) {
+	if !state.IsPlaying {
+		return
+	}
+	enabled, err := cache.IsRadioEnabled(ctx, userID)
+	if err != nil || !enabled {
+		return
+	}
+	playlist, err := cache.GetPlaylist(ctx, userID)
+	if err != nil || !NeedsRefill(len(playlist), state.CurrentIndex) {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), refillTimeout)
+		defer cancel()
+
+		added, err := s.Refill(ctx, userID)
+		if err != nil {
+			if !errors.Is(err, ErrRefillInProgress) {
+				logger.Warn("AI 电台续播失败", logger.Int64("userId", userID), logger.ErrorField(err))
+			}
+			return
+		}
+		logger.Info("AI 电台已续播", logger.Int64("userId", userID), logger.Int("added", len(added)))
+	}()
+}
+
+// Refill 生成续播关键词并把搜索到的歌曲追加到队列末尾，返回追加的歌曲
+func (s *Service) Refill(ctx context.Context, userID int64) ([]cache.PlaylistItem, error) {
+	locked, err := cache.TryLockRadioRefill(ctx, userID, refillTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrRefillInProgress
+	}
+	defer cache.UnlockRadioRefill(context.Background(), userID)
+
+	playlist, err := cache.GetPlaylist(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	plays, err := s.historyRepo.GetRecentPlays(ctx, userID, historyWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	recent, favorites := seedsFromHistory(plays)
+	if len(recent) == 0 {
+		recent = seedsFromPlaylist(playlist)
+	}
+
+	// 已在队列中或最近播放过的歌曲不再追加
+	exclude := make(map[string]bool, len(playlist)+len(plays))
+	for _, item := range playlist {
+		exclude[item.Source+":"+item.SourceID] = true
+		exclude[songKey(item.Title, item.Artist)] = true
+	}
+	for _, play := range plays {
+		exclude[play.Source+":"+play.SourceID] = true
+		exclude[songKey(play.Title, play.Artist)] = true
+	}
+
+	songs, err := s.suggest(ctx, recent, favorites, exclude)
+	if err != nil {
+		return nil, err
+	}
+	added := make([]cache.PlaylistItem, 0, len(songs))
+	for _, item := range songs {
+		if err := cache.AddTrackToPlaylist(ctx, userID, item); err != nil {
+			return added, err
+		}
+		added = append(added, item)
+	}
+
+	if err := cache.TouchRadioEnabled(ctx, userID); err != nil {
+		logger.Warn("延长 AI 电台状态失败", logger.Int64("userId", userID), logger.ErrorField(err))
+	}
+	return added, nil
+}
+
+// SuggestFromPlaylist 以歌单末尾的歌曲为种子生成续播歌曲，不修改任何队列
+// 用于没有单一听众播放历史的场景，如房间电台；歌单中已有的歌曲不会重复推荐
+func (s *Service) SuggestFromPlaylist(ctx context.Context, playlist []cache.PlaylistItem) ([]cache.PlaylistItem, error) {
+	exclude := make(map[string]bool, len(playlist)*2)
+	for _, item := range playlist {
+		title := item.Title
+		if title == "" {
+			title = item.Name
+		}
+		exclude[item.Source+":"+item.SourceID] = true
+		exclude[songKey(title, item.Artist)] = true
+	}
+	return s.suggest(ctx, seedsFromPlaylist(playlist), nil, exclude)
+}
+
+// suggest 让 AI 生成续播关键词，每个关键词取第一首不在 exclude 中的搜索结果
+func (s *Service) suggest(ctx context.Context, recent, favorites []agent.RadioSeed, exclude map[string]bool) ([]cache.PlaylistItem, error) {
+	queries, err := s.agent.GenerateRadioQueries(ctx, recent, favorites, agent.RadioQueryCount)
+	if err != nil {
+		return nil, err
+	}
+
+	songs := make([]cache.PlaylistItem, 0, len(queries))
+	for _, query := range queries {
+		results, err := s.agent.SearchMusic(query, searchLimit)
+		if err != nil {
+			logger.Warn("AI 电台搜索歌曲失败", logger.String("query", query), logger.ErrorField(err))
+			continue
+		}
+		for _, song := range results {
+			artist := strings.Join(song.Artists, "/")
+			if exclude[song.Source+":"+song.ID] || exclude[songKey(song.Name, artist)] {
+				continue
+			}
+			songs = append(songs, cache.PlaylistItem{
+				Title:    song.Name,
+				Artist:   artist,
+				Album:    song.Album,
+				Cover:    song.CoverURL,
+				Duration: song.Duration / 1000,
+				Source:   song.Source,
+				SourceID: song.ID,
+				HLSURL:   song.HLSURL,
+				AddedAt:  time.Now().Unix(),
+			})
+			exclude[song.Source+":"+song.ID] = true
+			exclude[songKey(song.Name, artist)] = true
+			break
+		}
+	}
+	return songs, nil
+}
+
+// seedsFromHistory 从播放历史中取最近播放的歌曲，以及听完过半次数最多的歌曲作为常听歌曲
+func seedsFromHistory(plays []*model.PlayHistory) (recent, favorites []agent.RadioSeed) {
+	seen := make(map[string]bool)
+	counts := make(map[string]int)
+	var completed []agent.RadioSeed
+	for _, play := range plays {
+		key := songKey(play.Title, play.Artist)
+		seed := agent.RadioSeed{Title: play.Title, Artist: play.Artist}
+		if !seen[key] {
+			seen[key] = true
+			if len(recent) < maxRecentSeeds {
+				recent = append(recent, seed)
+			}
+		}
+		if play.Scrobbled {
+			if counts[key] == 0 {
+				completed = append(completed, seed)
+			}
+			counts[key]++
+		}
+	}
+
+	sort.SliceStable(completed, func(i, j int) bool {
+		return counts[songKey(completed[i].Title, completed[i].Artist)] > counts[songKey(completed[j].Title, completed[j].Artist)]
+	})
+	for _, seed := range completed {
+		// 只听完过一次的不算常听
+		if len(favorites) == maxFavoriteSeeds || counts[songKey(seed.Title, seed.Artist)] < 2 {
+			break
+		}
+		favorites = append(favorites, seed)
+	}
+	return recent, favorites
+}
+
+// seedsFromPlaylist 没有播放历史时使用队列末尾的歌曲
+func seedsFromPlaylist(playlist []cache.PlaylistItem) []agent.RadioSeed {
+	start := max(len(playlist)-maxRecentSeeds, 0)
+	seeds := make([]agent.RadioSeed, 0, len(playlist)-start)
+	for i := len(playlist) - 1; i >= start; i-- {
+		title := playlist[i].Title
+		if title == "" {
+			title = playlist[i].Name // 房间歌单只填写 Name
+		}
+		seeds = append(seeds, agent.RadioSeed{Title: title, Artist: playlist[i].Artist})
+	}
+	return seeds
+}
+
+// songKey 按歌名和歌手识别同一首歌，忽略大小写和首尾空白
+func songKey(title, artist string) string {
+	return strings.ToLower(strings.TrimSpace(title)) + "|" + strings.ToLower(strings.TrimSpace(artist))
+}
@@ -0,0 +1,179 @@
+package recommend
+
+import (
+	"math"
+	"sort"
+
+	"Bt1QFM/model"
+)
+
+const (
+	// maxItemsPerUser 每位用户参与计算的歌曲数（按收听次数取前 N 首），限制两两组合的数量
+	maxItemsPerUser = 100
+	// maxNeighbors 每首歌保留的最相似歌曲数
+	maxNeighbors = 50
+	// ListLimit 每个推荐列表保存的歌曲数
+	ListLimit = 50
+)
+
+// song 一首歌及其听众数
+type song struct {
+	title     string
+	artist    string
+	listeners int
+}
+
+// userItem 用户听过的一首歌
+type userItem struct {
+	id     string
+	weight float64 // log(1+收听次数)，多次收听的歌影响更大，但不会压过其他歌
+}
+
+// neighbor 相似歌曲
+type neighbor struct {
+	id  string
+	sim float64
+}
+
+// pair 两首歌，a < b
+type pair struct {
+	a, b string
+}
+
+// result 一次计算的结果
+type result struct {
+	perUser map[int64][]model.Recommendation
+	popular []model.Recommendation
+}
+
+// compute 基于歌曲共现计算推荐
+// 两首歌的相似度为同时听过两首歌的用户数除以两首歌听众数的几何平均（余弦相似度）；
+// 同时听过的用户少于 minUsers 的组合、听众少于 minUsers 的热门歌曲都被丢弃，
+// 推荐结果因此只反映至少 minUsers 位用户的共同偏好，无法据此推断个别用户的听歌记录
+func compute(aggregates []*model.ListenAggregate, minUsers int) *result {
+	songs := make(map[string]*song)
+	byUser := make(map[int64][]userItem)
+	heard := make(map[int64]map[string]bool)
+	for _, a := range aggregates {
+		s, ok := songs[a.SourceID]
+		if !ok {
+			s = &song{title: a.Title, artist: a.Artist}
+			songs[a.SourceID] = s
+		}
+		s.listeners++
+		byUser[a.UserID] = append(byUser[a.UserID], userItem{id: a.SourceID, weight: math.Log1p(float64(a.Plays))})
+		if heard[a.UserID] == nil {
+			heard[a.UserID] = make(map[string]bool)
+		}
+		heard[a.UserID][a.SourceID] = true
+	}
+
+	// 统计同时听过两首歌的用户数
+	co := make(map[pair]int)
+	for userID, items := range byUser {
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].weight != items[j].weight {
+				return items[i].weight > items[j].weight
+			}
+			return items[i].id < items[j].id
+		})
+		if len(items) > maxItemsPerUser {
+			items = items[:maxItemsPerUser]
+		}
+		byUser[userID] = items
+		for i := 0; i < len(items); i++ {
+			for j := i + 1; j < len(items); j++ {
+				co[newPair(items[i].id, items[j].id)]++
+			}
+		}
+	}
+
+	neighbors := make(map[string][]neighbor)
+	for p, count := range co {
+		if count < minUsers {
+			continue
+		}
+		sim := float64(count) / math.Sqrt(float64(songs[p.a].listeners*songs[p.b].listeners))
+		neighbors[p.a] = append(neighbors[p.a], neighbor{id: p.b, sim: sim})
+		neighbors[p.b] = append(neighbors[p.b], neighbor{id: p.a, sim: sim})
+	}
+	for id, list := range neighbors {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].sim != list[j].sim {
+				return list[i].sim > list[j].sim
+			}
+			return list[i].id < list[j].id
+		})
+		if len(list) > maxNeighbors {
+			neighbors[id] = list[:maxNeighbors]
+		}
+	}
+
+	res := &result{perUser: make(map[int64][]model.Recommendation, len(byUser))}
+	for userID, items := range byUser {
+		scores := make(map[string]float64)
+		because := make(map[string]string)
+		best := make(map[string]float64)
+		for _, item := range items {
+			for _, n := range neighbors[item.id] {
+				if heard[userID][n.id] {
+					continue
+				}
+				contribution := item.weight * n.sim
+				scores[n.id] += contribution
+				if contribution > best[n.id] {
+					best[n.id] = contribution
+					because[n.id] = songs[item.id].title
+				}
+			}
+		}
+		recs := make([]model.Recommendation, 0, len(scores))
+		for id, score := range scores {
+			s := songs[id]
+			recs = append(recs, model.Recommendation{
+				SourceID: id,
+				Title:    s.title,
+				Artist:   s.artist,
+				Score:    math.Round(score*1000) / 1000,
+				Because:  because[id],
+			})
+		}
+		res.perUser[userID] = topN(recs)
+	}
+
+	popular := make([]model.Recommendation, 0)
+	for id, s := range songs {
+		if s.listeners < minUsers {
+			continue
+		}
+		popular = append(popular, model.Recommendation{
+			SourceID: id,
+			Title:    s.title,
+			Artist:   s.artist,
+			Score:    float64(s.listeners),
+		})
+	}
+	res.popular = topN(popular)
+	return res
+}
+
+// topN 按分数从高到低排序并保留前 ListLimit 首
+func topN(recs []model.Recommendation) []model.Recommendation {
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Score != recs[j].Score {
+			return recs[i].Score > recs[j].Score
+		}
+		return recs[i].SourceID < recs[j].SourceID
+	})
+	if len(recs) > ListLimit {
+		recs = recs[:ListLimit]
+	}
+	return recs
+}
+
+func newPair(a, b string) pair {
+	if a > b {
+		a, b = b, a
+	}
+	return pair{a: a, b: b}
+}
@@ -0,0 +1,156 @@
+package recommend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+// minMinUsers RECOMMEND_MIN_USERS 的下限；为 1 时推荐会直接暴露另一位用户听过的歌
+const minMinUsers = 2
+
+// Report 一次计算的结果
+type Report struct {
+	Listeners int           `json:"listeners"` // 参与计算的用户数
+	Songs     int           `json:"songs"`     // 参与计算的歌曲数
+	Elapsed   time.Duration `json:"elapsed"`
+}
+
+// Service 推荐服务，定期根据所有用户的网易云播放历史预计算推荐列表并保存到 Redis
+// 本地上传的曲目只属于上传者，不参与跨用户的推荐
+type Service struct {
+	historyRepo repository.PlayHistoryRepository
+	interval    time.Duration
+	window      time.Duration
+	minUsers    int
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewService 创建推荐服务
+func NewService(historyRepo repository.PlayHistoryRepository, cfg *config.Config) *Service {
+	return &Service{
+		historyRepo: historyRepo,
+		interval:    time.Duration(cfg.RecommendIntervalHours) * time.Hour,
+		window:      time.Duration(cfg.RecommendWindowDays) * 24 * time.Hour,
+		minUsers:    max(cfg.RecommendMinUsers, minMinUsers),
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Enabled 是否启用了推荐
+func (s *Service) Enabled() bool {
+	return s.interval > 0
+}
+
+// ttl 推荐列表的过期时间，覆盖两次刷新，刷新失败一次时仍可使用上次的结果
+func (s *Service) ttl() time.Duration {
+	return 2 * s.interval
+}
+
+// Start 启动时计算一次，之后按配置的间隔刷新；间隔为 0 时不启动
+func (s *Service) Start() {
+	if !s.Enabled() {
+		logger.Info("推荐服务未启用")
+		return
+	}
+	logger.Info("推荐服务启动",
+		logger.Duration("interval", s.interval),
+		logger.Duration("window", s.window),
+		logger.Int("minUsers", s.minUsers))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.refresh()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				s.refresh()
+			}
+		}
+	}()
+}
+
+// Stop 停止定期刷新
+func (s *Service) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+func (s *Service) refresh() {
+	report, err := s.Run(context.Background(), time.Now())
+	if err != nil {
+		logger.Warn("刷新推荐失败", logger.ErrorField(err))
+		return
+	}
+	logger.Info("推荐已刷新",
+		logger.Int("listeners", report.Listeners),
+		logger.Int("songs", report.Songs),
+		logger.Duration("elapsed", report.Elapsed))
+}
+
+// Run 根据 now 之前一个统计窗口内的播放历史重新计算所有推荐列表
+func (s *Service) Run(ctx context.Context, now time.Time) (*Report, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	start := time.Now()
+	aggregates, err := s.historyRepo.GetListenAggregates(ctx, "netease", now.Add(-s.window))
+	if err != nil {
+		return nil, err
+	}
+	res := compute(aggregates, s.minUsers)
+
+	ttl := s.ttl()
+	if err := cache.SetPopularRecommendations(ctx, &model.RecommendationList{Items: res.popular, GeneratedAt: now}, ttl); err != nil {
+		return nil, err
+	}
+	for userID, items := range res.perUser {
+		list := &model.RecommendationList{Items: items, GeneratedAt: now}
+		if err := cache.SetRecommendations(ctx, userID, list, ttl); err != nil {
+			logger.Warn("保存推荐列表失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		}
+	}
+
+	songs := make(map[string]bool)
+	for _, a := range aggregates {
+		songs[a.SourceID] = true
+	}
+	return &Report{
+		Listeners: len(res.perUser),
+		Songs:     len(songs),
+		Elapsed:   time.Since(start),
+	}, nil
+}
+
+// ForUser 返回用户的推荐列表；还没有个性化推荐时返回热门歌曲，personalized 为 false
+func (s *Service) ForUser(ctx context.Context, userID int64) (list *model.RecommendationList, personalized bool, err error) {
+	list, err = cache.GetRecommendations(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if list != nil && len(list.Items) > 0 {
+		return list, true, nil
+	}
+	list, err = cache.GetPopularRecommendations(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if list == nil {
+		list = &model.RecommendationList{Items: []model.Recommendation{}}
+	}
+	return list, false, nil
+}
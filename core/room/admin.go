@@ -0,0 +1,119 @@
+package room
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// ActiveRoom 当前有 WebSocket 连接的房间（管理后台用）
+type ActiveRoom struct {
+	RoomID      string `json:"roomId"`
+	Name        string `json:"name"`
+	OwnerID     int64  `json:"ownerId"`
+	Connections int    `json:"connections"`
+	Listeners   int    `json:"listeners"` // 听歌模式的连接数
+	Station     bool   `json:"station"`
+	Karaoke     bool   `json:"karaoke"`
+}
+
+// ActiveRooms 列出当前有连接的房间，按连接数从多到少排列
+func (m *RoomManager) ActiveRooms(ctx context.Context) []*ActiveRoom {
+	rooms := make([]*ActiveRoom, 0)
+	for _, roomID := range m.hub.RoomIDs() {
+		active := &ActiveRoom{
+			RoomID:  roomID,
+			Station: m.IsStationActive(roomID),
+			Karaoke: m.getKaraoke(roomID) != nil,
+		}
+		for _, client := range m.hub.GetRoomClients(roomID) {
+			active.Connections++
+			if client.GetMode() == model.RoomModeListen {
+				active.Listeners++
+			}
+		}
+		if room, err := m.GetRoom(ctx, roomID); err == nil && room != nil {
+			active.Name = room.Name
+			active.OwnerID = room.OwnerID
+		}
+		rooms = append(rooms, active)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Connections > rooms[j].Connections })
+	return rooms
+}
+
+// ForceCloseRoom 管理员强制关闭房间：通知成员房间已解散，关闭房间并断开所有连接
+func (m *RoomManager) ForceCloseRoom(ctx context.Context, roomID string) error {
+	room, err := m.GetRoom(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("获取房间失败: %w", err)
+	}
+	if room == nil || room.Status == model.RoomStatusClosed {
+		return fmt.Errorf("房间不存在")
+	}
+
+	m.broadcastRoomDisband(roomID)
+	if err := m.CloseRoom(ctx, roomID); err != nil {
+		return err
+	}
+	closed := m.hub.DisconnectRoom(roomID, "room_closed")
+
+	logger.Info("房间已被管理员关闭",
+		logger.String("roomId", roomID),
+		logger.Int("connections", closed))
+	return nil
+}
+
+// DisconnectUser 断开用户在所有房间的连接（如账号被禁用时），返回断开的连接数
+func (m *RoomManager) DisconnectUser(userID int64, reason string) int {
+	return m.hub.DisconnectUser(userID, reason)
+}
+
+// RoomIDs 返回当前有连接的房间
+func (h *RoomHub) RoomIDs() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ids := make([]string, 0, len(h.rooms))
+	for roomID := range h.rooms {
+		ids = append(ids, roomID)
+	}
+	return ids
+}
+
+// DisconnectRoom 通知并断开房间内的所有连接，返回断开的连接数
+func (h *RoomHub) DisconnectRoom(roomID, reason string) int {
+	clients := h.GetRoomClients(roomID)
+	for _, client := range clients {
+		h.disconnect(client, reason)
+	}
+	return len(clients)
+}
+
+// DisconnectUser 通知并断开用户在所有房间的连接，返回断开的连接数
+func (h *RoomHub) DisconnectUser(userID int64, reason string) int {
+	h.mu.RLock()
+	var clients []*Client
+	for _, roomClients := range h.rooms {
+		for client := range roomClients {
+			if client.UserID == userID {
+				clients = append(clients, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		h.disconnect(client, reason)
+	}
+	return len(clients)
+}
+
+// disconnect 发送断线通知后注销客户端，发送协程随后关闭连接
+func (h *RoomHub) disconnect(client *Client, reason string) {
+	h.sendConnectionState(client, "disconnected", reason)
+	h.unregister <- client
+}
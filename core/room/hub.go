@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"Bt1QFM/cache"
@@ -26,6 +27,7 @@ const (
 	MsgTypeSync            MessageType = "sync"             // 状态同步
 	MsgTypeMemberList      MessageType = "member_list"      // 成员列表
 	MsgTypeConnectionState MessageType = "connection_state" // 连接状态通知
+	MsgTypeResume          MessageType = "resume"           // 序号同步和断线补发结果
 
 	// 聊天消息
 	MsgTypeChat       MessageType = "chat"        // 聊天消息
@@ -50,6 +52,9 @@ const (
 	// 订阅相关消息
 	MsgTypeMasterModeChange MessageType = "master_mode" // 房主模式变更通知
 	MsgTypeSongPlay         MessageType = "song_play"   // 播放歌曲（添加到歌单并播放）
+	MsgTypeStationMode      MessageType = "station_mode" // 房间电台模式开关通知
+	MsgTypeKaraokeMode      MessageType = "karaoke_mode" // 房间卡拉 OK 模式开关和偏移通知
+	MsgTypeLyric            MessageType = "lyric"        // 卡拉 OK 当前歌词行（服务端 -> 听歌模式用户）
 
 	// 权限消息
 	MsgTypeTransferOwner MessageType = "transfer_owner" // 转让房主
@@ -71,6 +76,7 @@ type WSMessage struct {
 	Username  string          `json:"username,omitempty"`
 	Data      json.RawMessage `json:"data,omitempty"`
 	Timestamp int64           `json:"timestamp"`
+	Seq       int64           `json:"seq,omitempty"` // 房间广播消息的递增序号，断线重连时用于补发
 }
 
 // ChatData 聊天消息数据
@@ -168,10 +174,17 @@ type Client struct {
 	RoomID        string
 	UserID        int64
 	Username      string
-	Mode          string // chat, listen
-	Role          string // owner, admin, member
-	LastHeartbeat int64  // 最后心跳时间（毫秒时间戳）
+	Mode          string         // chat, listen
+	Role          string         // owner, admin, member
+	LastHeartbeat int64          // 最后心跳时间（毫秒时间戳）
+	Topics        map[Topic]bool // 订阅的消息主题，nil 表示全部
+	ResumeSeq     int64          // 重连时客户端最后收到的消息序号，0 表示新连接
 	mu            sync.RWMutex
+
+	// 注册后同步序号期间为 true，实时消息暂存在 held 中，暂存已满时丢弃并设置 heldDropped
+	resuming    bool
+	held        []*BroadcastMessage
+	heldDropped bool
 }
 
 // RoomHub 房间 WebSocket 管理中心
@@ -197,6 +210,13 @@ type RoomHub struct {
 
 	// 健康检查定时器
 	healthCheckTicker *time.Ticker
+
+	// 因发送缓冲区满而丢弃的消息数
+	droppedMessages uint64
+
+	// 各房间的序号分配器，保证同一房间的广播消息按序号顺序进入广播通道
+	sequencers map[string]*roomSequencer
+	seqMu      sync.Mutex // 保护 sequencers
 }
 
 // BroadcastMessage 广播消息
@@ -205,6 +225,8 @@ type BroadcastMessage struct {
 	Message   []byte
 	ExcludeID int64 // 排除的用户ID（用于不向发送者回发）
 	OnlyMode  string // 只发送给特定模式的用户（listen/chat）
+	Topic     Topic  // 消息所属主题，为空表示系统消息
+	Seq       int64  // 房间内的消息序号，未分配时为 0
 }
 
 // NewRoomHub 创建房间 Hub
@@ -217,6 +239,7 @@ func NewRoomHub() *RoomHub {
 		broadcast:         make(chan *BroadcastMessage, 256),
 		done:              make(chan struct{}),
 		healthCheckTicker: nil, // 在 Run 中启动
+		sequencers:        make(map[string]*roomSequencer),
 	}
 }
 
@@ -351,9 +374,10 @@ func (h *RoomHub) registerClient(client *Client) {
 		h.rooms[roomID] = make(map[*Client]bool)
 	}
 
-	// 设置初始心跳时间
+	// 设置初始心跳时间，序号同步完成前暂存实时消息
 	client.mu.Lock()
 	client.LastHeartbeat = time.Now().UnixMilli()
+	client.resuming = true
 	client.mu.Unlock()
 
 	// 添加客户端
@@ -369,10 +393,18 @@ func (h *RoomHub) registerClient(client *Client) {
 			logger.String("room", roomID),
 			logger.Int64("user", client.UserID))
 	}
+	if err := roomCache.UpdateRoomPeak(ctx, roomID, len(h.rooms[roomID])); err != nil {
+		logger.Debug("failed to update room peak listeners",
+			logger.ErrorField(err),
+			logger.String("room", roomID))
+	}
 
 	// 发送连接成功通知
 	h.sendConnectionState(client, "connected", "")
 
+	// 同步消息序号，重连时补发断线期间的消息；需要读取 Redis，不在主循环中执行
+	go h.resumeClient(client)
+
 	logger.Info("client registered",
 		logger.String("room", roomID),
 		logger.Int64("user", client.UserID),
@@ -440,6 +472,7 @@ func (h *RoomHub) broadcastToRoom(msg *BroadcastMessage) {
 	}
 	h.mu.RUnlock()
 
+	var slowClients []*Client
 	for _, client := range clientList {
 		// 排除指定用户
 		if msg.ExcludeID > 0 && client.UserID == msg.ExcludeID {
@@ -451,13 +484,38 @@ func (h *RoomHub) broadcastToRoom(msg *BroadcastMessage) {
 			continue
 		}
 
+		// 只发送给订阅了该主题的用户
+		if !client.IsSubscribed(msg.Topic) {
+			continue
+		}
+
+		// 正在同步序号的客户端先暂存，补发完成后再发送
+		if client.holdWhileResuming(msg) {
+			continue
+		}
+
 		select {
 		case client.Send <- msg.Message:
 		default:
-			// 发送缓冲区满，移除客户端
-			h.unregister <- client
+			// 发送缓冲区满，记录丢弃并移除客户端
+			atomic.AddUint64(&h.droppedMessages, 1)
+			slowClients = append(slowClients, client)
 		}
 	}
+
+	// 当前处于 Run 循环中，不能再写 unregister 通道，直接加锁移除
+	if len(slowClients) > 0 {
+		h.mu.Lock()
+		for _, client := range slowClients {
+			h.removeClient(client)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// DroppedMessages 返回因客户端发送缓冲区满而丢弃的消息总数
+func (h *RoomHub) DroppedMessages() uint64 {
+	return atomic.LoadUint64(&h.droppedMessages)
 }
 
 // cleanup 清理所有连接
@@ -502,11 +560,21 @@ func (h *RoomHub) Broadcast(roomID string, message []byte, excludeUserID int64,
 // BroadcastMessage 广播 WSMessage
 func (h *RoomHub) BroadcastWSMessage(roomID string, msg *WSMessage, excludeUserID int64, onlyMode string) error {
 	msg.Timestamp = time.Now().UnixMilli()
+
+	if !unsequencedMessages[msg.Type] {
+		return h.broadcastSequenced(roomID, msg, excludeUserID, onlyMode)
+	}
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
-	h.Broadcast(roomID, data, excludeUserID, onlyMode)
+	h.broadcast <- &BroadcastMessage{
+		RoomID:    roomID,
+		Message:   data,
+		ExcludeID: excludeUserID,
+		OnlyMode:  onlyMode,
+		Topic:     TopicOf(msg.Type),
+	}
 	return nil
 }
 
@@ -556,6 +624,11 @@ func (h *RoomHub) SendToUser(roomID string, userID int64, msg *WSMessage) error
 		return fmt.Errorf("user not found: %d", userID)
 	}
 
+	// 未订阅该主题，静默跳过
+	if !client.IsSubscribed(TopicOf(msg.Type)) {
+		return nil
+	}
+
 	msg.Timestamp = time.Now().UnixMilli()
 	data, err := json.Marshal(msg)
 	if err != nil {
@@ -744,6 +817,18 @@ func (c *Client) GetMode() string {
 	return c.Mode
 }
 
+// IsSubscribed 检查客户端是否订阅了指定主题（线程安全）
+// 系统消息（空主题）和未声明主题的客户端始终返回 true
+func (c *Client) IsSubscribed(topic Topic) bool {
+	if topic == "" {
+		return true
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Topics == nil || c.Topics[topic]
+}
+
 // GetRole 获取客户端角色（线程安全）
 func (c *Client) GetRole() string {
 	c.mu.RLock()
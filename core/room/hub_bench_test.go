@@ -0,0 +1,110 @@
+package room
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"Bt1QFM/model"
+)
+
+// benchRoomID 压测使用的房间ID
+const benchRoomID = "bench"
+
+// benchClients 在房间中加入 n 个客户端，每个客户端由一个协程持续读取发送队列，模拟写协程
+// 直接写入 Hub 的客户端表，跳过 registerClient 中依赖 Redis 的序号同步；Hub 的 Run 循环需在此之后启动，先于客户端清理退出
+func benchClients(b *testing.B, h *RoomHub, n int) {
+	b.Helper()
+	var wg sync.WaitGroup
+	h.mu.Lock()
+	h.rooms[benchRoomID] = make(map[*Client]bool, n)
+	for i := 0; i < n; i++ {
+		client := &Client{
+			Hub:    h,
+			Send:   make(chan []byte, 256),
+			RoomID: benchRoomID,
+			UserID: int64(i + 1),
+			Mode:   model.RoomModeChat,
+			Role:   model.RoomRoleMember,
+		}
+		h.rooms[benchRoomID][client] = true
+		h.userClients[h.userKey(benchRoomID, client.UserID)] = client
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range client.Send {
+			}
+		}()
+	}
+	h.mu.Unlock()
+	b.Cleanup(func() {
+		h.cleanup()
+		wg.Wait()
+	})
+}
+
+// benchMessage 压测使用的聊天消息
+func benchMessage(b *testing.B) *WSMessage {
+	b.Helper()
+	data, err := json.Marshal(&ChatData{Content: "benchmark message"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return &WSMessage{Type: MsgTypeChat, RoomID: benchRoomID, UserID: 1, Username: "bench", Data: data}
+}
+
+// BenchmarkBroadcastToRoom 测量单条消息扇出到房间内所有客户端的耗时，不经过广播通道
+func BenchmarkBroadcastToRoom(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("clients=%d", n), func(b *testing.B) {
+			h := NewRoomHub()
+			benchClients(b, h, n)
+			data, err := json.Marshal(benchMessage(b))
+			if err != nil {
+				b.Fatal(err)
+			}
+			msg := &BroadcastMessage{RoomID: benchRoomID, Message: data, Topic: TopicOf(MsgTypeChat)}
+
+			b.ReportAllocs()
+			for b.Loop() {
+				h.broadcastToRoom(msg)
+			}
+			b.ReportMetric(float64(h.DroppedMessages())/float64(b.N), "dropped/op")
+		})
+	}
+}
+
+// BenchmarkBroadcastWSMessage 测量从 BroadcastWSMessage 经广播通道和 Run 循环扇出的吞吐
+// 使用不分配序号的歌词消息，不依赖 Redis
+func BenchmarkBroadcastWSMessage(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("clients=%d", n), func(b *testing.B) {
+			h := NewRoomHub()
+			benchClients(b, h, n)
+			exited := make(chan struct{})
+			go func() {
+				h.Run()
+				close(exited)
+			}()
+			// 等 Run 循环退出后再关闭客户端的发送通道
+			b.Cleanup(func() {
+				h.Stop()
+				<-exited
+			})
+			data, err := json.Marshal(&LyricLineData{SongID: "bench", Text: "benchmark lyric"})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			for b.Loop() {
+				msg := &WSMessage{Type: MsgTypeLyric, RoomID: benchRoomID, Data: data}
+				if err := h.BroadcastWSMessage(benchRoomID, msg, 0, ""); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(h.DroppedMessages())/float64(b.N), "dropped/op")
+		})
+	}
+}
@@ -0,0 +1,440 @@
+package room
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+const (
+	// karaokeTickInterval 检查当前歌词行的间隔
+	karaokeTickInterval = 200 * time.Millisecond
+	// karaokeMaxOffset 歌词偏移校准的最大绝对值
+	karaokeMaxOffset = 10 * time.Second
+	// karaokeOffsetCommand 校准歌词偏移的聊天命令，如 "/offset 500" 表示歌词提前 500 毫秒
+	karaokeOffsetCommand = "/offset"
+)
+
+var (
+	// lrcTimeTagPattern 匹配 [mm:ss]、[mm:ss.xx]、[mm:ss.xxx] 时间标签
+	lrcTimeTagPattern = regexp.MustCompile(`\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	// neteaseStreamPattern 从网易云 HLS 地址中提取歌曲 ID
+	neteaseStreamPattern = regexp.MustCompile(`/streams/netease/(\d+)/`)
+)
+
+// lyricLine 一行带时间的歌词
+type lyricLine struct {
+	at          time.Duration
+	text        string
+	translation string
+}
+
+// LyricLineData 当前歌词行消息
+type LyricLineData struct {
+	SongID      string `json:"songId"`
+	Index       int    `json:"index"`
+	Time        int64  `json:"time"`               // 本行开始时间（毫秒）
+	NextTime    int64  `json:"nextTime,omitempty"` // 下一行开始时间（毫秒），最后一行为 0
+	Text        string `json:"text"`
+	Translation string `json:"translation,omitempty"`
+	OffsetMs    int64  `json:"offsetMs"`
+}
+
+// KaraokeStatus 房间卡拉 OK 模式状态（API 响应和 karaoke_mode 消息用）
+type KaraokeStatus struct {
+	Enabled   bool   `json:"enabled"`
+	OffsetMs  int64  `json:"offsetMs"`
+	SongID    string `json:"songId,omitempty"`
+	LineCount int    `json:"lineCount"`
+}
+
+// karaoke 房间卡拉 OK 会话：按服务端推算的播放位置向听歌模式用户推送当前歌词行
+type karaoke struct {
+	roomID string
+
+	mu        sync.Mutex
+	offset    time.Duration // 歌词提前显示的时间，负数表示延后
+	songID    string
+	lines     []lyricLine
+	lastIndex int // 最近一次推送的行，-1 表示尚未推送
+	basePos   time.Duration
+	baseAt    time.Time
+	playing   bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// currentIndex 按播放位置和偏移计算当前歌词行，还没到第一行时返回 -1
+func (k *karaoke) currentIndex() int {
+	pos := k.basePos + k.offset
+	if k.playing {
+		pos += time.Since(k.baseAt)
+	}
+	return sort.Search(len(k.lines), func(i int) bool { return k.lines[i].at > pos }) - 1
+}
+
+// lineData 构造指定歌词行的消息数据
+func (k *karaoke) lineData(index int) *LyricLineData {
+	line := k.lines[index]
+	data := &LyricLineData{
+		SongID:      k.songID,
+		Index:       index,
+		Time:        line.at.Milliseconds(),
+		Text:        line.text,
+		Translation: line.translation,
+		OffsetMs:    k.offset.Milliseconds(),
+	}
+	if index+1 < len(k.lines) {
+		data.NextTime = k.lines[index+1].at.Milliseconds()
+	}
+	return data
+}
+
+// status 当前会话状态
+func (k *karaoke) status() *KaraokeStatus {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return &KaraokeStatus{
+		Enabled:   true,
+		OffsetMs:  k.offset.Milliseconds(),
+		SongID:    k.songID,
+		LineCount: len(k.lines),
+	}
+}
+
+// SetKaraoke 开启或关闭房间卡拉 OK 模式（仅房主）
+func (m *RoomManager) SetKaraoke(ctx context.Context, roomID string, userID int64, enabled bool) error {
+	room, err := m.GetRoom(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("获取房间失败: %w", err)
+	}
+	if room == nil {
+		return fmt.Errorf("房间不存在")
+	}
+	if room.OwnerID != userID {
+		return fmt.Errorf("只有房主可以切换卡拉 OK 模式")
+	}
+
+	if !enabled {
+		m.stopKaraoke(roomID, "房主关闭")
+		return nil
+	}
+
+	m.karaokeMu.Lock()
+	if m.karaokes[roomID] != nil {
+		m.karaokeMu.Unlock()
+		return nil
+	}
+	k := &karaoke{
+		roomID:    roomID,
+		lastIndex: -1,
+		stop:      make(chan struct{}),
+	}
+	m.karaokes[roomID] = k
+	m.karaokeMu.Unlock()
+
+	if state, err := m.cache.GetPlaybackState(ctx, roomID); err == nil {
+		m.syncKaraoke(roomID, state)
+	}
+	go m.runKaraoke(k)
+	m.broadcastKaraokeMode(roomID, k.status())
+
+	logger.Info("房间卡拉 OK 模式已开启",
+		logger.String("roomId", roomID),
+		logger.Int64("ownerId", userID))
+	return nil
+}
+
+// SetKaraokeOffset 校准歌词偏移（房主或有播放控制权限的用户），正数表示歌词提前显示
+func (m *RoomManager) SetKaraokeOffset(ctx context.Context, roomID string, userID int64, offsetMs int64) error {
+	k := m.getKaraoke(roomID)
+	if k == nil {
+		return fmt.Errorf("卡拉 OK 模式未开启")
+	}
+	member, err := m.cache.GetMemberOnline(ctx, roomID, userID)
+	if err != nil || member == nil {
+		return fmt.Errorf("用户不在房间中")
+	}
+	if !member.CanControl && member.Role != model.RoomRoleOwner {
+		return fmt.Errorf("没有播放控制权限")
+	}
+
+	offset := time.Duration(offsetMs) * time.Millisecond
+	offset = min(max(offset, -karaokeMaxOffset), karaokeMaxOffset)
+
+	k.mu.Lock()
+	k.offset = offset
+	// 立即按新偏移重新推送当前行
+	k.lastIndex = -1
+	k.mu.Unlock()
+
+	m.broadcastKaraokeMode(roomID, k.status())
+	logger.Info("歌词偏移已校准",
+		logger.String("roomId", roomID),
+		logger.Int64("userId", userID),
+		logger.Int64("offsetMs", offset.Milliseconds()))
+	return nil
+}
+
+// GetKaraokeStatus 获取房间卡拉 OK 模式状态
+func (m *RoomManager) GetKaraokeStatus(roomID string) *KaraokeStatus {
+	k := m.getKaraoke(roomID)
+	if k == nil {
+		return &KaraokeStatus{}
+	}
+	return k.status()
+}
+
+// getKaraoke 获取房间的卡拉 OK 会话，未开启时返回 nil
+func (m *RoomManager) getKaraoke(roomID string) *karaoke {
+	m.karaokeMu.Lock()
+	defer m.karaokeMu.Unlock()
+	return m.karaokes[roomID]
+}
+
+// stopKaraoke 关闭房间卡拉 OK 模式并通知房间成员
+func (m *RoomManager) stopKaraoke(roomID, reason string) {
+	m.karaokeMu.Lock()
+	k := m.karaokes[roomID]
+	delete(m.karaokes, roomID)
+	m.karaokeMu.Unlock()
+	if k == nil {
+		return
+	}
+
+	k.stopOnce.Do(func() { close(k.stop) })
+	m.broadcastKaraokeMode(roomID, &KaraokeStatus{})
+	logger.Info("房间卡拉 OK 模式已关闭",
+		logger.String("roomId", roomID),
+		logger.String("reason", reason))
+}
+
+// syncKaraoke 播放状态变化时更新歌词时钟，切歌时重新加载歌词
+func (m *RoomManager) syncKaraoke(roomID string, state *model.RoomPlaybackState) {
+	k := m.getKaraoke(roomID)
+	if k == nil || state == nil {
+		return
+	}
+	progress, ok := currentSongProgress(state)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.basePos = progress.position
+	k.baseAt = time.Now()
+	k.playing = state.IsPlaying
+	if !ok || progress.songID == k.songID {
+		return
+	}
+
+	k.songID = progress.songID
+	k.lines = nil
+	k.lastIndex = -1
+	go m.loadKaraokeLyrics(k, progress)
+}
+
+// loadKaraokeLyrics 从歌词服务加载当前歌曲的歌词，加载期间已切歌时丢弃结果
+func (m *RoomManager) loadKaraokeLyrics(k *karaoke, progress songProgress) {
+	lyricID := neteaseLyricID(progress)
+	if lyricID == "" {
+		logger.Debug("歌曲没有可用的歌词来源",
+			logger.String("roomId", k.roomID),
+			logger.String("songId", progress.songID))
+		return
+	}
+	resp, err := m.neteaseClient.GetLyric(lyricID)
+	if err != nil {
+		logger.Warn("获取卡拉 OK 歌词失败",
+			logger.String("roomId", k.roomID),
+			logger.String("songId", progress.songID),
+			logger.ErrorField(err))
+		return
+	}
+	translation := ""
+	if resp.TLyric != nil {
+		translation = resp.TLyric.Lyric
+	}
+	lines := parseLRC(resp.LRC.Lyric, translation)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.songID != progress.songID {
+		return
+	}
+	k.lines = lines
+	k.lastIndex = -1
+	logger.Debug("卡拉 OK 歌词已加载",
+		logger.String("roomId", k.roomID),
+		logger.String("songId", progress.songID),
+		logger.Int("lines", len(lines)))
+}
+
+// runKaraoke 卡拉 OK 主循环：当前歌词行变化时推送给听歌模式用户
+func (m *RoomManager) runKaraoke(k *karaoke) {
+	ticker := time.NewTicker(karaokeTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+			k.mu.Lock()
+			index := k.currentIndex()
+			if index < 0 || index == k.lastIndex {
+				k.mu.Unlock()
+				continue
+			}
+			k.lastIndex = index
+			data := k.lineData(index)
+			k.mu.Unlock()
+
+			m.broadcastLyricLine(k.roomID, data)
+		}
+	}
+}
+
+// sendKaraokeLine 向刚进入听歌模式的用户发送当前歌词行
+func (m *RoomManager) sendKaraokeLine(roomID string, userID int64) {
+	k := m.getKaraoke(roomID)
+	if k == nil {
+		return
+	}
+	k.mu.Lock()
+	index := k.currentIndex()
+	if index < 0 {
+		k.mu.Unlock()
+		return
+	}
+	data := k.lineData(index)
+	k.mu.Unlock()
+
+	payload, _ := json.Marshal(data)
+	msg := &WSMessage{
+		Type:   MsgTypeLyric,
+		RoomID: roomID,
+		Data:   payload,
+	}
+	if err := m.hub.SendToUser(roomID, userID, msg); err != nil {
+		logger.Debug("发送当前歌词行失败",
+			logger.String("roomId", roomID),
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+	}
+}
+
+// handleOffsetCommand 处理 /offset 命令，结果以系统聊天消息回复
+func (m *RoomManager) handleOffsetCommand(ctx context.Context, client *Client, arg string) {
+	offsetMs, err := strconv.ParseInt(strings.TrimSpace(arg), 10, 64)
+	if err != nil {
+		m.SendMessage(ctx, client.RoomID, client.UserID, client.Username, "用法: /offset <毫秒>，正数表示歌词提前显示")
+		return
+	}
+	if err := m.SetKaraokeOffset(ctx, client.RoomID, client.UserID, offsetMs); err != nil {
+		m.SendMessage(ctx, client.RoomID, client.UserID, client.Username, "歌词偏移校准失败: "+err.Error())
+	}
+}
+
+func (m *RoomManager) broadcastLyricLine(roomID string, data *LyricLineData) {
+	payload, _ := json.Marshal(data)
+	msg := &WSMessage{
+		Type:   MsgTypeLyric,
+		RoomID: roomID,
+		Data:   payload,
+	}
+	m.hub.BroadcastWSMessage(roomID, msg, 0, model.RoomModeListen)
+}
+
+func (m *RoomManager) broadcastKaraokeMode(roomID string, status *KaraokeStatus) {
+	data, _ := json.Marshal(status)
+	msg := &WSMessage{
+		Type:   MsgTypeKaraokeMode,
+		RoomID: roomID,
+		Data:   data,
+	}
+	m.hub.BroadcastWSMessage(roomID, msg, 0, "")
+}
+
+// neteaseLyricID 歌曲在网易云的 ID；本地上传的歌曲没有歌词来源，返回空
+func neteaseLyricID(progress songProgress) string {
+	if match := neteaseStreamPattern.FindStringSubmatch(progress.hlsURL); match != nil {
+		return match[1]
+	}
+	if progress.hlsURL != "" && !strings.HasPrefix(progress.songID, cache.SourceNetease+"_") {
+		return ""
+	}
+	id := strings.TrimPrefix(progress.songID, cache.SourceNetease+"_")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return ""
+	}
+	return id
+}
+
+// parseLRC 解析 LRC 歌词并按时间合并翻译；一行可带多个时间标签，元数据和非时间行被忽略
+func parseLRC(lyric, translation string) []lyricLine {
+	lines := parseLRCText(lyric)
+	if len(lines) == 0 || translation == "" {
+		return lines
+	}
+	translations := make(map[time.Duration]string)
+	for _, line := range parseLRCText(translation) {
+		translations[line.at] = line.text
+	}
+	for i := range lines {
+		lines[i].translation = translations[lines[i].at]
+	}
+	return lines
+}
+
+// parseLRCText 解析 LRC 文本为按时间排序的歌词行
+func parseLRCText(text string) []lyricLine {
+	var lines []lyricLine
+	for _, raw := range strings.Split(text, "\n") {
+		raw = strings.TrimSpace(raw)
+		tags := lrcTimeTagPattern.FindAllStringSubmatchIndex(raw, -1)
+		// 时间标签须位于行首，且连续出现
+		if len(tags) == 0 || tags[0][0] != 0 {
+			continue
+		}
+		end := 0
+		var times []time.Duration
+		for _, tag := range tags {
+			if tag[0] != end {
+				break
+			}
+			end = tag[1]
+			minutes, _ := strconv.Atoi(raw[tag[2]:tag[3]])
+			seconds, _ := strconv.Atoi(raw[tag[4]:tag[5]])
+			at := time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+			if tag[6] >= 0 {
+				fraction := raw[tag[6]:tag[7]]
+				value, _ := strconv.Atoi(fraction)
+				switch len(fraction) {
+				case 1:
+					at += time.Duration(value) * 100 * time.Millisecond
+				case 2:
+					at += time.Duration(value) * 10 * time.Millisecond
+				default:
+					at += time.Duration(value) * time.Millisecond
+				}
+			}
+			times = append(times, at)
+		}
+		content := strings.TrimSpace(raw[end:])
+		for _, at := range times {
+			lines = append(lines, lyricLine{at: at, text: content})
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].at < lines[j].at })
+	return lines
+}
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"Bt1QFM/cache"
@@ -23,6 +24,21 @@ type RoomManager struct {
 	hub           *RoomHub
 	neteaseClient *netease.Client
 	maxMembers    int
+
+	// 服务端计时，歌曲播完后自动切歌
+	schedule playbackSchedule
+
+	// 电台模式：服务端驱动播放的房间
+	suggester  StationSuggester
+	stationsMu sync.Mutex
+	stations   map[string]*station
+
+	// 服务端切歌时为曲库曲目签发播放地址
+	streamResolver LocalStreamResolver
+
+	// 卡拉 OK 模式：向听歌模式用户推送同步歌词的房间
+	karaokeMu sync.Mutex
+	karaokes  map[string]*karaoke
 }
 
 // NewRoomManager 创建房间管理器
@@ -33,6 +49,9 @@ func NewRoomManager(repo repository.RoomRepository, roomCache *cache.RoomCache,
 		hub:           hub,
 		neteaseClient: netease.NewClient(),
 		maxMembers:    10,
+		schedule:      playbackSchedule{timers: make(map[string]*time.Timer)},
+		stations:      make(map[string]*station),
+		karaokes:      make(map[string]*karaoke),
 	}
 }
 
@@ -263,8 +282,18 @@ func (m *RoomManager) DisbandRoom(ctx context.Context, roomID string, userID int
 
 // CloseRoom 关闭房间
 func (m *RoomManager) CloseRoom(ctx context.Context, roomID string) error {
-	// 清理订阅
+	// 清理订阅并停止电台
 	GetSubscriptionManager().CleanupRoom(roomID)
+	m.stopStation(roomID, "房间关闭")
+	m.stopKaraoke(roomID, "房间关闭")
+	m.cancelAutoAdvance(roomID)
+
+	// 生成房间总结，需要在清理缓存前读取播放记录
+	if room, err := m.repo.GetByID(ctx, roomID); err != nil {
+		logger.Warn("获取房间信息失败，跳过房间总结", logger.String("roomId", roomID), logger.ErrorField(err))
+	} else if room != nil {
+		m.saveSummary(ctx, room)
+	}
 
 	// 关闭数据库记录
 	if err := m.repo.Close(ctx, roomID); err != nil {
@@ -414,6 +443,8 @@ func (m *RoomManager) SwitchMode(ctx context.Context, roomID string, userID int6
 		if client != nil {
 			subMgr.Subscribe(roomID, client)
 		}
+		// 卡拉 OK 模式下立即发送当前歌词行
+		m.sendKaraokeLine(roomID, userID)
 
 		if isOwner {
 			// 房主进入听歌模式，注册为发布者
@@ -423,8 +454,10 @@ func (m *RoomManager) SwitchMode(ctx context.Context, roomID string, userID int6
 			// 广播房主模式变更
 			m.broadcastMasterModeChange(roomID, mode)
 		} else {
-			// 非房主，检查房主是否在听歌模式，如果是则请求同步
-			if subMgr.IsMasterInListenMode(roomID) {
+			// 非房主，电台模式下直接同步电台状态，否则检查房主是否在听歌模式，如果是则请求同步
+			if m.sendStationSync(roomID, userID) {
+				// 已同步
+			} else if subMgr.IsMasterInListenMode(roomID) {
 				m.requestMasterStateForUser(ctx, roomID, userID)
 			}
 		}
@@ -503,6 +536,7 @@ func (m *RoomManager) UpdatePlayback(ctx context.Context, roomID string, userID
 	if err := m.cache.SetPlaybackState(ctx, roomID, state); err != nil {
 		return fmt.Errorf("更新播放状态失败: %w", err)
 	}
+	m.playbackChanged(roomID, state)
 
 	// 广播给 listen 模式的用户
 	m.broadcastPlayback(roomID, state, userID)
@@ -899,6 +933,9 @@ func (m *RoomManager) HandleMessage(ctx context.Context, client *Client, msg *WS
 				if keyword != "" {
 					m.handleNeteaseSearch(ctx, client.RoomID, client.UserID, client.Username, keyword)
 				}
+			} else if arg, ok := strings.CutPrefix(content, karaokeOffsetCommand+" "); ok {
+				// 校准卡拉 OK 歌词偏移
+				m.handleOffsetCommand(ctx, client, arg)
 			} else {
 				// 普通聊天消息
 				m.SendMessage(ctx, client.RoomID, client.UserID, client.Username, content)
@@ -928,6 +965,13 @@ func (m *RoomManager) HandleMessage(ctx context.Context, client *Client, msg *WS
 		}
 
 	case MsgTypeNext, MsgTypePrev:
+		// 电台模式下由电台切歌，只支持下一首
+		if m.IsStationActive(client.RoomID) {
+			if msg.Type == MsgTypeNext {
+				m.skipStation(ctx, client)
+			}
+			return
+		}
 		// 切换歌曲
 		if current, err := m.GetPlayback(ctx, client.RoomID); err == nil && current != nil {
 			newIndex := current.CurrentIndex
@@ -1057,6 +1101,11 @@ func (m *RoomManager) handleMasterReport(ctx context.Context, client *Client, da
 		return
 	}
 
+	// 电台模式下播放由服务端驱动，忽略房主客户端上报
+	if m.IsStationActive(client.RoomID) {
+		return
+	}
+
 	// 解析上报数据
 	var syncData MasterSyncData
 	if err := json.Unmarshal(data, &syncData); err != nil {
@@ -1127,6 +1176,7 @@ func (m *RoomManager) handleMasterReport(ctx context.Context, client *Client, da
 			logger.ErrorField(err),
 			logger.String("roomId", client.RoomID))
 	}
+	m.playbackChanged(client.RoomID, playbackState)
 
 	// 广播给所有听歌模式的用户（无论房主是否在听歌模式）
 	// 这样即使房主在聊天模式，听歌模式的用户也能同步
@@ -1171,6 +1221,11 @@ func (m *RoomManager) handleMasterRequest(ctx context.Context, client *Client) {
 		return
 	}
 
+	// 电台模式下直接返回电台状态
+	if m.sendStationSync(client.RoomID, client.UserID) {
+		return
+	}
+
 	// 构建请求消息，发送给房主
 	msg := &WSMessage{
 		Type:      MsgTypeMasterRequest,
@@ -1262,9 +1317,11 @@ func (m *RoomManager) handleSongChange(ctx context.Context, client *Client, data
 			logger.ErrorField(err),
 			logger.String("roomId", client.RoomID))
 	}
+	m.playbackChanged(client.RoomID, playbackState)
 
 	// 广播切歌消息给所有 listen 模式用户
 	m.broadcastSongChange(client.RoomID, &songData)
+	m.followSongChange(ctx, client.RoomID, &songData)
 
 	logger.Info("用户切歌成功",
 		logger.String("roomId", client.RoomID),
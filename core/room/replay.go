@@ -0,0 +1,282 @@
+package room
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/logger"
+)
+
+// unsequencedMessages 不分配序号、不进入重放缓冲的广播消息：高频且只反映瞬时状态，重连后补发没有意义
+var unsequencedMessages = map[MessageType]bool{
+	MsgTypeLyric: true,
+}
+
+// ResumeData 连接建立后的序号同步结果
+type ResumeData struct {
+	LastSeq    int64 `json:"lastSeq"`    // 客户端重连时上报的最后收到的序号，新连接为 0
+	CurrentSeq int64 `json:"currentSeq"` // 房间当前序号，客户端下次重连时从这里开始
+	Replayed   int   `json:"replayed"`   // 补发的消息数
+	Complete   bool  `json:"complete"`   // false 表示断线期间的消息已无法完整补发，客户端需重新拉取房间状态
+}
+
+// roomSequencer 保证同一房间的广播按序号顺序进入广播通道
+// mu 只在分配序号和写入重放缓冲时持有，发送到广播通道时不持锁：先拿到 draining 的调用方负责按序发送 pending 中的消息
+type roomSequencer struct {
+	mu       sync.Mutex
+	pending  []*BroadcastMessage
+	draining bool
+	refs     int // 正在使用的调用方数量，由 RoomHub.seqMu 保护，归零时从 Hub 中删除
+}
+
+// acquireSequencer 获取房间的序号分配器，用完后调用 releaseSequencer
+func (h *RoomHub) acquireSequencer(roomID string) *roomSequencer {
+	h.seqMu.Lock()
+	defer h.seqMu.Unlock()
+
+	seq, ok := h.sequencers[roomID]
+	if !ok {
+		seq = &roomSequencer{}
+		h.sequencers[roomID] = seq
+	}
+	seq.refs++
+	return seq
+}
+
+// releaseSequencer 释放房间的序号分配器，没有调用方使用时删除
+func (h *RoomHub) releaseSequencer(roomID string, seq *roomSequencer) {
+	h.seqMu.Lock()
+	defer h.seqMu.Unlock()
+
+	seq.refs--
+	if seq.refs == 0 {
+		delete(h.sequencers, roomID)
+	}
+}
+
+// broadcastSequenced 分配序号、写入重放缓冲后按序号顺序放入广播通道
+func (h *RoomHub) broadcastSequenced(roomID string, msg *WSMessage, excludeUserID int64, onlyMode string) error {
+	seq := h.acquireSequencer(roomID)
+	defer h.releaseSequencer(roomID, seq)
+
+	seq.mu.Lock()
+	data, err := h.encodeBroadcast(roomID, msg, excludeUserID, onlyMode)
+	if err != nil {
+		seq.mu.Unlock()
+		return err
+	}
+	seq.pending = append(seq.pending, &BroadcastMessage{
+		RoomID:    roomID,
+		Message:   data,
+		ExcludeID: excludeUserID,
+		OnlyMode:  onlyMode,
+		Topic:     TopicOf(msg.Type),
+		Seq:       msg.Seq,
+	})
+	if seq.draining {
+		// 正在发送的调用方会一并发送这条消息
+		seq.mu.Unlock()
+		return nil
+	}
+
+	seq.draining = true
+	for len(seq.pending) > 0 {
+		batch := seq.pending
+		seq.pending = nil
+		seq.mu.Unlock()
+		for _, broadcast := range batch {
+			h.broadcast <- broadcast
+		}
+		seq.mu.Lock()
+	}
+	seq.draining = false
+	seq.mu.Unlock()
+	return nil
+}
+
+// encodeBroadcast 为广播消息分配房间内递增的序号并写入重放缓冲，调用方需持有房间的序号分配器
+// Redis 不可用时不带序号照常广播，客户端重连时会收到 complete=false
+func (h *RoomHub) encodeBroadcast(roomID string, msg *WSMessage, excludeUserID int64, onlyMode string) ([]byte, error) {
+	ctx := context.Background()
+	roomCache := cache.NewRoomCache()
+	seq, err := roomCache.NextMessageSeq(ctx, roomID)
+	if err != nil {
+		logger.Debug("分配房间消息序号失败", logger.String("room", roomID), logger.ErrorField(err))
+		return json.Marshal(msg)
+	}
+
+	msg.Seq = seq
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cache.ReplayEntry{
+		Seq:       seq,
+		Message:   data,
+		ExcludeID: excludeUserID,
+		OnlyMode:  onlyMode,
+		Topic:     string(TopicOf(msg.Type)),
+	}
+	if err := roomCache.AppendReplay(ctx, roomID, entry); err != nil {
+		logger.Warn("写入房间消息重放缓冲失败",
+			logger.ErrorField(err),
+			logger.String("room", roomID),
+			logger.Int64("seq", seq))
+	}
+	return data, nil
+}
+
+// resumeClient 向新注册的客户端同步房间序号；客户端携带最后收到的序号重连时，按序补发断线期间的广播消息
+// 注册后在独立协程中执行，读取 Redis 期间到达的实时消息暂存在客户端上（见 holdWhileResuming），
+// 补发完成后再发送其中序号大于补发范围的消息，保证补发的消息先于实时消息进入发送队列
+func (h *RoomHub) resumeClient(client *Client) {
+	ctx := context.Background()
+	roomCache := cache.NewRoomCache()
+	result := &ResumeData{LastSeq: client.ResumeSeq}
+
+	var missed [][]byte
+	var covered int64 // 补发覆盖到的序号，暂存的实时消息中不大于该序号的不再发送
+	currentSeq, err := roomCache.GetMessageSeq(ctx, client.RoomID)
+	if err != nil {
+		logger.Warn("获取房间消息序号失败",
+			logger.ErrorField(err),
+			logger.String("room", client.RoomID))
+	} else {
+		result.CurrentSeq = currentSeq
+		switch {
+		case client.ResumeSeq == 0 || client.ResumeSeq == currentSeq:
+			// 新连接或断线期间没有新消息
+			result.Complete = true
+			covered = client.ResumeSeq
+		case client.ResumeSeq > currentSeq:
+			// 序号已重置（如房间缓存被清理），无法补发
+		default:
+			missed, covered, result.Complete = h.loadMissed(ctx, roomCache, client)
+		}
+	}
+
+	// 持有 Hub 的读锁期间客户端不会被移除，发送通道不会被关闭
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !h.rooms[client.RoomID][client] {
+		return
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+replay:
+	for _, message := range missed {
+		select {
+		case client.Send <- message:
+			result.Replayed++
+		default:
+			result.Complete = false
+			break replay
+		}
+	}
+	if client.heldDropped {
+		result.Complete = false
+	}
+	h.sendResumeResult(client, result)
+
+	for _, msg := range client.held {
+		if msg.Seq > 0 && msg.Seq <= covered {
+			continue
+		}
+		select {
+		case client.Send <- msg.Message:
+		default:
+			atomic.AddUint64(&h.droppedMessages, 1)
+		}
+	}
+	client.resuming = false
+	client.held = nil
+	client.heldDropped = false
+
+	logger.Info("client resumed",
+		logger.String("room", client.RoomID),
+		logger.Int64("user", client.UserID),
+		logger.Int64("lastSeq", client.ResumeSeq),
+		logger.Int64("currentSeq", currentSeq),
+		logger.Int("replayed", result.Replayed),
+		logger.Bool("complete", result.Complete))
+}
+
+// loadMissed 读取序号大于 client.ResumeSeq 的广播消息，按广播时的条件过滤
+// 返回需要补发的消息、读取到的最大序号和是否完整（缓冲中缺少紧接着的消息时不完整）
+func (h *RoomHub) loadMissed(ctx context.Context, roomCache *cache.RoomCache, client *Client) ([][]byte, int64, bool) {
+	entries, err := roomCache.GetReplaySince(ctx, client.RoomID, client.ResumeSeq)
+	if err != nil {
+		logger.Warn("读取房间消息重放缓冲失败",
+			logger.ErrorField(err),
+			logger.String("room", client.RoomID))
+		return nil, 0, false
+	}
+	if len(entries) == 0 || entries[0].Seq != client.ResumeSeq+1 {
+		return nil, 0, false
+	}
+
+	// 新连接的模式还未恢复，按成员缓存中的模式过滤只发给听歌模式的消息
+	mode := client.GetMode()
+	if member, err := roomCache.GetMemberOnline(ctx, client.RoomID, client.UserID); err == nil && member != nil && member.Mode != "" {
+		mode = member.Mode
+	}
+
+	missed := make([][]byte, 0, len(entries))
+	for _, entry := range entries {
+		if entry.ExcludeID > 0 && entry.ExcludeID == client.UserID {
+			continue
+		}
+		if entry.OnlyMode != "" && entry.OnlyMode != mode {
+			continue
+		}
+		if !client.IsSubscribed(Topic(entry.Topic)) {
+			continue
+		}
+		missed = append(missed, entry.Message)
+	}
+	return missed, entries[len(entries)-1].Seq, true
+}
+
+// holdWhileResuming 客户端正在补发断线期间的消息时暂存实时消息，返回 true 表示已暂存（或因暂存已满丢弃）
+func (c *Client) holdWhileResuming(msg *BroadcastMessage) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.resuming {
+		return false
+	}
+	if len(c.held) >= cap(c.Send) {
+		c.heldDropped = true
+		return true
+	}
+	c.held = append(c.held, msg)
+	return true
+}
+
+// sendResumeResult 发送序号同步结果
+func (h *RoomHub) sendResumeResult(client *Client, result *ResumeData) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	msg := &WSMessage{
+		Type:      MsgTypeResume,
+		RoomID:    client.RoomID,
+		Data:      data,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	msgData, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	select {
+	case client.Send <- msgData:
+	default:
+	}
+}
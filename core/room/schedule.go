@@ -0,0 +1,250 @@
+package room
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+const (
+	// autoAdvanceName 自动切歌时切歌消息中的操作者名称
+	autoAdvanceName = "自动播放"
+	// autoAdvanceGrace 歌曲播完后等待房主客户端自行切歌的时间，超时后由服务端切到下一首
+	autoAdvanceGrace = 3 * time.Second
+	// defaultSongDuration 歌曲缺少时长时使用的时长
+	defaultSongDuration = 4 * time.Minute
+)
+
+// LocalStreamResolver 为曲库曲目签发房间内播放用的 HLS 地址，由服务层实现
+// 曲目的流按内容哈希存储，地址取自曲目记录中的播放列表路径，不能由曲目 ID 拼出
+type LocalStreamResolver interface {
+	LocalStreamURL(ctx context.Context, trackID int64) (string, error)
+}
+
+// SetLocalStreamResolver 设置曲库曲目的播放地址签发，未设置时服务端切歌不为曲库曲目提供播放地址
+func (m *RoomManager) SetLocalStreamResolver(resolver LocalStreamResolver) {
+	m.streamResolver = resolver
+}
+
+// playbackSchedule 房间当前歌曲的服务端计时，歌曲播完后自动切到歌单中的下一首
+type playbackSchedule struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer // roomID -> 切歌定时器
+}
+
+// songProgress 播放状态中当前歌曲的 ID、时长和按服务器时间推算的播放位置
+type songProgress struct {
+	songID   string
+	hlsURL   string
+	duration time.Duration
+	position time.Duration
+}
+
+// currentSongProgress 解析播放状态；CurrentSong 可能是缓存读出的 map，也可能是歌单项
+func currentSongProgress(state *model.RoomPlaybackState) (songProgress, bool) {
+	if state == nil || state.CurrentSong == nil {
+		return songProgress{}, false
+	}
+	data, err := json.Marshal(state.CurrentSong)
+	if err != nil {
+		return songProgress{}, false
+	}
+	var song struct {
+		SongID   string `json:"songId"`
+		HlsURL   string `json:"hlsUrl"`
+		Duration int    `json:"duration"`
+	}
+	if err := json.Unmarshal(data, &song); err != nil || song.SongID == "" {
+		return songProgress{}, false
+	}
+
+	position := time.Duration(state.Position * float64(time.Second))
+	if state.IsPlaying && state.UpdatedAt > 0 {
+		position += time.Since(time.UnixMilli(state.UpdatedAt))
+	}
+	// 播放状态中的时长以毫秒记录
+	return songProgress{songID: song.SongID, hlsURL: song.HlsURL, duration: songDuration(song.Duration, time.Millisecond), position: position}, true
+}
+
+// playbackChanged 播放状态写入缓存后调用，更新自动切歌计时、卡拉 OK 歌词时钟和房间总结的播放记录
+func (m *RoomManager) playbackChanged(roomID string, state *model.RoomPlaybackState) {
+	m.scheduleAutoAdvance(roomID, state)
+	m.syncKaraoke(roomID, state)
+	m.recordPlayback(roomID, state)
+}
+
+// scheduleAutoAdvance 根据最新播放状态重新安排自动切歌，暂停、电台模式或无法识别歌曲时取消
+func (m *RoomManager) scheduleAutoAdvance(roomID string, state *model.RoomPlaybackState) {
+	m.schedule.mu.Lock()
+	defer m.schedule.mu.Unlock()
+
+	if timer := m.schedule.timers[roomID]; timer != nil {
+		timer.Stop()
+		delete(m.schedule.timers, roomID)
+	}
+	if state == nil || !state.IsPlaying || m.IsStationActive(roomID) {
+		return
+	}
+	progress, ok := currentSongProgress(state)
+	if !ok {
+		return
+	}
+
+	wait := max(progress.duration-progress.position, 0) + autoAdvanceGrace
+	m.schedule.timers[roomID] = time.AfterFunc(wait, func() {
+		m.autoAdvance(roomID, progress.songID)
+	})
+}
+
+// cancelAutoAdvance 取消房间的自动切歌
+func (m *RoomManager) cancelAutoAdvance(roomID string) {
+	m.scheduleAutoAdvance(roomID, nil)
+}
+
+// autoAdvance 当前歌曲播完且房主没有切歌时，服务端切到歌单中的下一首并广播给听众
+func (m *RoomManager) autoAdvance(roomID, songID string) {
+	ctx := context.Background()
+	state, err := m.cache.GetPlaybackState(ctx, roomID)
+	if err != nil || state == nil || !state.IsPlaying || m.IsStationActive(roomID) {
+		return
+	}
+	progress, ok := currentSongProgress(state)
+	if !ok || progress.songID != songID {
+		// 期间已切歌，新歌曲已重新安排
+		return
+	}
+	if progress.position < progress.duration {
+		// 期间有跳转，按最新进度重新安排
+		m.scheduleAutoAdvance(roomID, state)
+		return
+	}
+
+	playlist, err := m.GetPlaylist(ctx, roomID)
+	if err != nil {
+		logger.Warn("自动切歌获取歌单失败", logger.String("roomId", roomID), logger.ErrorField(err))
+		return
+	}
+	index := playlistIndexOf(playlist, state.CurrentSong)
+	if index < 0 {
+		index = state.CurrentIndex
+	}
+	next := index + 1
+	if next >= len(playlist) {
+		logger.Debug("歌单已播完，停止自动切歌", logger.String("roomId", roomID))
+		return
+	}
+
+	m.switchRoomSong(ctx, roomID, next, playlist[next], m.songHLSURL(ctx, playlist[next]), 0, autoAdvanceName)
+	logger.Info("歌曲播放结束，自动切到下一首",
+		logger.String("roomId", roomID),
+		logger.Int("index", next),
+		logger.String("songId", playlist[next].SongID))
+}
+
+// switchRoomSong 把房间切到歌单中的指定歌曲：写入播放状态（递增版本号，防止房主旧状态覆盖）并向听众和房主广播切歌
+// hlsURL 为 songHLSURL 签发的播放地址
+func (m *RoomManager) switchRoomSong(ctx context.Context, roomID string, index int, item cache.PlaylistItem, hlsURL string, position float64, changedByName string) {
+	duration := songDuration(item.Duration, time.Second)
+	now := time.Now()
+
+	var version int64 = 1
+	if current, _ := m.cache.GetPlaybackState(ctx, roomID); current != nil {
+		version = current.StateVersion + 1
+	}
+	state := &model.RoomPlaybackState{
+		CurrentIndex: index,
+		CurrentSong: map[string]interface{}{
+			"songId":   item.SongID,
+			"name":     item.Name,
+			"artist":   item.Artist,
+			"cover":    item.Cover,
+			"duration": duration.Milliseconds(),
+			"hlsUrl":   hlsURL,
+		},
+		Position:     position,
+		IsPlaying:    true,
+		UpdatedAt:    now.UnixMilli(),
+		StateVersion: version,
+	}
+	if err := m.cache.SetPlaybackState(ctx, roomID, state); err != nil {
+		logger.Warn("保存切歌状态到缓存失败", logger.String("roomId", roomID), logger.ErrorField(err))
+	}
+	m.playbackChanged(roomID, state)
+
+	m.broadcastSongChange(roomID, &SongChangeData{
+		SongID:        item.SongID,
+		SongName:      item.Name,
+		Artist:        item.Artist,
+		Cover:         item.Cover,
+		Duration:      int(duration.Milliseconds()),
+		HlsURL:        hlsURL,
+		Position:      position,
+		IsPlaying:     true,
+		ChangedByName: changedByName,
+		Timestamp:     now.UnixMilli(),
+	})
+}
+
+// songDuration 按 unit 换算歌曲时长，缺失时使用默认时长
+// 歌单项的时长以秒记录，播放状态和切歌消息中的时长以毫秒记录
+func songDuration(value int, unit time.Duration) time.Duration {
+	if value <= 0 {
+		return defaultSongDuration
+	}
+	return time.Duration(value) * unit
+}
+
+// songHLSURL 歌曲的 HLS 播放地址，房间歌单通常只记录歌曲 ID
+// 曲库曲目的地址由 LocalStreamResolver 按曲目记录签发，不使用歌单项中客户端提供的地址
+func (m *RoomManager) songHLSURL(ctx context.Context, item cache.PlaylistItem) string {
+	id := item.SourceID
+	if id == "" {
+		id = strings.TrimPrefix(item.SongID, cache.SourceNetease+"_")
+	}
+	trackID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return ""
+	}
+
+	if item.Source == cache.SourceLocal {
+		if m.streamResolver == nil {
+			return ""
+		}
+		url, err := m.streamResolver.LocalStreamURL(ctx, trackID)
+		if err != nil {
+			logger.Warn("签发曲库曲目的播放地址失败", logger.Int64("trackId", trackID), logger.ErrorField(err))
+			return ""
+		}
+		return url
+	}
+	if item.HLSURL != "" {
+		return item.HLSURL
+	}
+	return fmt.Sprintf("/streams/netease/%s/playlist.m3u8", id)
+}
+
+// playlistIndexOf 按播放状态中的歌曲 ID 查找歌单位置，找不到时返回 -1
+func playlistIndexOf(playlist []cache.PlaylistItem, currentSong interface{}) int {
+	song, ok := currentSong.(map[string]interface{})
+	if !ok {
+		return -1
+	}
+	songID, _ := song["songId"].(string)
+	if songID == "" {
+		return -1
+	}
+	for i, item := range playlist {
+		if item.SongID == songID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,489 @@
+package room
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/logger"
+)
+
+const (
+	// stationName 电台模式下 master_sync 和切歌消息中的发送者名称
+	stationName = "电台"
+	// stationSyncInterval 定期向听歌模式用户广播播放进度的间隔
+	stationSyncInterval = 10 * time.Second
+	// stationRefillTimeout 一次 AI 续播的最长时间
+	stationRefillTimeout = 2 * time.Minute
+	// stationIdleTimeout 房间内没有任何连接超过该时间后自动关闭电台
+	stationIdleTimeout = 30 * time.Minute
+)
+
+// StationSuggester 为房间电台生成续播歌曲，由 AI 电台服务实现
+type StationSuggester interface {
+	SuggestFromPlaylist(ctx context.Context, playlist []cache.PlaylistItem) ([]cache.PlaylistItem, error)
+}
+
+// StationStatus 房间电台状态（API 响应用）
+type StationStatus struct {
+	Enabled      bool   `json:"enabled"`
+	CurrentIndex int    `json:"currentIndex"`
+	SongID       string `json:"songId,omitempty"`
+	SongName     string `json:"songName,omitempty"`
+	StartedAt    int64  `json:"startedAt,omitempty"` // 电台开启时间（毫秒）
+}
+
+// station 服务端驱动的房间播放：按歌曲时长推进歌单，定期广播 master_sync，不依赖房主客户端
+type station struct {
+	roomID    string
+	ownerID   int64
+	startedAt time.Time
+
+	mu          sync.Mutex
+	index       int
+	song        cache.PlaylistItem
+	hlsURL      string    // 当前歌曲签发的播放地址
+	songStart   time.Time // 当前歌曲位置 0 对应的时间
+	duration    time.Duration
+	lastVisitor time.Time // 最近一次房间内有连接的时间
+
+	wake      chan struct{} // 当前歌曲被外部切换，重新计算切歌时间
+	skip      chan struct{} // 立即切到下一首
+	stop      chan struct{}
+	stopOnce  sync.Once
+	refilling atomic.Bool
+}
+
+// position 当前歌曲的播放位置（秒）
+func (st *station) position() float64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return time.Since(st.songStart).Seconds()
+}
+
+// remaining 当前歌曲剩余时间
+func (st *station) remaining() time.Duration {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return max(st.duration-time.Since(st.songStart), 0)
+}
+
+// idle 记录房间内是否有连接，没有连接超过 stationIdleTimeout 时返回 true
+func (st *station) idle(hasClients bool) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if hasClients {
+		st.lastVisitor = time.Now()
+		return false
+	}
+	return time.Since(st.lastVisitor) > stationIdleTimeout
+}
+
+// signal 非阻塞地发送信号，已有未处理的信号时合并
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// SetStationSuggester 启用电台歌单用完后的 AI 续播
+func (m *RoomManager) SetStationSuggester(suggester StationSuggester) {
+	m.suggester = suggester
+}
+
+// StartStation 开启房间电台模式（仅房主），从当前歌曲继续播放；歌单为空时先由 AI 生成歌曲
+func (m *RoomManager) StartStation(ctx context.Context, roomID string, userID int64) error {
+	room, err := m.GetRoom(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("获取房间失败: %w", err)
+	}
+	if room == nil {
+		return fmt.Errorf("房间不存在")
+	}
+	if room.OwnerID != userID {
+		return fmt.Errorf("只有房主可以开启电台")
+	}
+	if m.getStation(roomID) != nil {
+		return nil
+	}
+
+	st := &station{
+		roomID:      roomID,
+		ownerID:     room.OwnerID,
+		startedAt:   time.Now(),
+		lastVisitor: time.Now(),
+		wake:        make(chan struct{}, 1),
+		skip:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+	}
+
+	playlist, err := m.GetPlaylist(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("获取歌单失败: %w", err)
+	}
+	if len(playlist) == 0 {
+		if m.suggester == nil {
+			return fmt.Errorf("歌单为空，请先添加歌曲")
+		}
+		refillCtx, cancel := context.WithTimeout(ctx, stationRefillTimeout)
+		defer cancel()
+		if err := m.refillStation(refillCtx, st); err != nil {
+			return fmt.Errorf("生成电台歌曲失败: %w", err)
+		}
+		if playlist, err = m.GetPlaylist(ctx, roomID); err != nil || len(playlist) == 0 {
+			return fmt.Errorf("生成电台歌曲失败")
+		}
+	}
+
+	// 从房间当前歌曲和进度继续，没有播放状态时从第一首开始
+	index, position := 0, 0.0
+	if state, err := m.GetPlayback(ctx, roomID); err == nil && state != nil {
+		if i := playlistIndexOf(playlist, state.CurrentSong); i >= 0 {
+			index, position = i, state.Position
+		}
+	}
+
+	m.stationsMu.Lock()
+	if m.stations[roomID] != nil {
+		m.stationsMu.Unlock()
+		return nil
+	}
+	m.stations[roomID] = st
+	m.stationsMu.Unlock()
+
+	m.playStationSong(ctx, st, index, playlist[index], position)
+	m.broadcastStationMode(roomID, true)
+	go m.runStation(st)
+
+	logger.Info("房间电台已开启",
+		logger.String("roomId", roomID),
+		logger.Int64("ownerId", userID),
+		logger.Int("playlistSize", len(playlist)))
+	return nil
+}
+
+// StopStation 关闭房间电台模式（仅房主），播放状态保留，之后由房主客户端继续上报
+func (m *RoomManager) StopStation(ctx context.Context, roomID string, userID int64) error {
+	room, err := m.GetRoom(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("获取房间失败: %w", err)
+	}
+	if room == nil {
+		return fmt.Errorf("房间不存在")
+	}
+	if room.OwnerID != userID {
+		return fmt.Errorf("只有房主可以关闭电台")
+	}
+	m.stopStation(roomID, "房主关闭")
+	return nil
+}
+
+// GetStationStatus 获取房间电台状态
+func (m *RoomManager) GetStationStatus(roomID string) *StationStatus {
+	st := m.getStation(roomID)
+	if st == nil {
+		return &StationStatus{}
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return &StationStatus{
+		Enabled:      true,
+		CurrentIndex: st.index,
+		SongID:       st.song.SongID,
+		SongName:     st.song.Name,
+		StartedAt:    st.startedAt.UnixMilli(),
+	}
+}
+
+// IsStationActive 房间是否处于电台模式
+func (m *RoomManager) IsStationActive(roomID string) bool {
+	return m.getStation(roomID) != nil
+}
+
+// getStation 获取运行中的房间电台
+func (m *RoomManager) getStation(roomID string) *station {
+	m.stationsMu.Lock()
+	defer m.stationsMu.Unlock()
+	return m.stations[roomID]
+}
+
+// stopStation 停止房间电台并通知房间成员
+func (m *RoomManager) stopStation(roomID, reason string) {
+	m.stationsMu.Lock()
+	st := m.stations[roomID]
+	delete(m.stations, roomID)
+	m.stationsMu.Unlock()
+	if st == nil {
+		return
+	}
+
+	st.stopOnce.Do(func() { close(st.stop) })
+	m.broadcastStationMode(roomID, false)
+	// 恢复普通模式下的自动切歌
+	if state, err := m.cache.GetPlaybackState(context.Background(), roomID); err == nil {
+		m.playbackChanged(roomID, state)
+	}
+	logger.Info("房间电台已关闭",
+		logger.String("roomId", roomID),
+		logger.String("reason", reason))
+}
+
+// runStation 电台主循环：歌曲播完时切到下一首，定期广播播放进度
+func (m *RoomManager) runStation(st *station) {
+	ticker := time.NewTicker(stationSyncInterval)
+	defer ticker.Stop()
+	timer := time.NewTimer(st.remaining())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-st.stop:
+			return
+
+		case <-st.wake:
+			timer.Reset(st.remaining())
+
+		case <-st.skip:
+			m.advanceStation(st)
+			timer.Reset(st.remaining())
+
+		case <-timer.C:
+			m.advanceStation(st)
+			timer.Reset(st.remaining())
+
+		case <-ticker.C:
+			if st.idle(len(m.hub.GetRoomClients(st.roomID)) > 0) {
+				m.stopStation(st.roomID, "房间长时间无人")
+				return
+			}
+
+			ctx := context.Background()
+			if err := m.cache.UpdatePlaybackPosition(ctx, st.roomID, st.position(), 0); err != nil {
+				logger.Debug("更新电台播放进度失败", logger.String("roomId", st.roomID), logger.ErrorField(err))
+			}
+			m.broadcastMasterSyncToListeners(st.roomID, m.stationSyncData(st), 0)
+		}
+	}
+}
+
+// advanceStation 切到歌单中的下一首；正在播放最后一首时在后台续播，歌单已播完时同步续播
+// 没有可播放的歌曲时关闭电台
+func (m *RoomManager) advanceStation(st *station) {
+	select {
+	case <-st.stop:
+		return
+	default:
+	}
+
+	ctx := context.Background()
+	st.mu.Lock()
+	next := st.index + 1
+	st.mu.Unlock()
+
+	playlist, err := m.GetPlaylist(ctx, st.roomID)
+	if err != nil {
+		logger.Warn("电台获取歌单失败", logger.String("roomId", st.roomID), logger.ErrorField(err))
+		// 稍后重试，避免歌单暂时不可用时关闭电台
+		st.mu.Lock()
+		st.duration = time.Since(st.songStart) + stationSyncInterval
+		st.mu.Unlock()
+		return
+	}
+
+	if next >= len(playlist) && m.suggester != nil {
+		refillCtx, cancel := context.WithTimeout(ctx, stationRefillTimeout)
+		err := m.refillStation(refillCtx, st)
+		cancel()
+		if err != nil {
+			logger.Warn("电台续播失败", logger.String("roomId", st.roomID), logger.ErrorField(err))
+		}
+		playlist, _ = m.GetPlaylist(ctx, st.roomID)
+	}
+	if next >= len(playlist) {
+		m.stopStation(st.roomID, "歌单已播完")
+		return
+	}
+
+	m.playStationSong(ctx, st, next, playlist[next], 0)
+
+	// 正在播放最后一首，提前续播留出生成关键词和搜索的时间
+	if next == len(playlist)-1 && m.suggester != nil {
+		go func() {
+			refillCtx, cancel := context.WithTimeout(context.Background(), stationRefillTimeout)
+			defer cancel()
+			if err := m.refillStation(refillCtx, st); err != nil {
+				logger.Warn("电台续播失败", logger.String("roomId", st.roomID), logger.ErrorField(err))
+			}
+		}()
+	}
+}
+
+// playStationSong 把电台切到指定歌曲，写入播放状态并广播切歌和电台进度
+func (m *RoomManager) playStationSong(ctx context.Context, st *station, index int, item cache.PlaylistItem, position float64) {
+	hlsURL := m.songHLSURL(ctx, item)
+
+	st.mu.Lock()
+	st.index = index
+	st.song = item
+	st.hlsURL = hlsURL
+	st.duration = songDuration(item.Duration, time.Second)
+	st.songStart = time.Now().Add(-time.Duration(position * float64(time.Second)))
+	st.mu.Unlock()
+
+	m.switchRoomSong(ctx, st.roomID, index, item, hlsURL, position, stationName)
+	m.broadcastMasterSyncToListeners(st.roomID, m.stationSyncData(st), 0)
+}
+
+// followSongChange 有权限的用户在电台模式下切歌时，电台从该歌曲继续推进
+func (m *RoomManager) followSongChange(ctx context.Context, roomID string, songData *SongChangeData) {
+	st := m.getStation(roomID)
+	if st == nil {
+		return
+	}
+	playlist, _ := m.GetPlaylist(ctx, roomID)
+
+	st.mu.Lock()
+	if i := playlistIndexOf(playlist, map[string]interface{}{"songId": songData.SongID}); i >= 0 {
+		st.index = i
+		st.song = playlist[i]
+	} else {
+		st.song = cache.PlaylistItem{SongID: songData.SongID, Name: songData.SongName, Artist: songData.Artist, Cover: songData.Cover}
+	}
+	st.hlsURL = songData.HlsURL
+	st.duration = defaultSongDuration
+	if songData.Duration > 0 {
+		st.duration = time.Duration(songData.Duration) * time.Millisecond
+	}
+	st.songStart = time.Now().Add(-time.Duration(songData.Position * float64(time.Second)))
+	st.mu.Unlock()
+
+	signal(st.wake)
+}
+
+// skipStation 有播放控制权限的用户在电台模式下切到下一首
+func (m *RoomManager) skipStation(ctx context.Context, client *Client) {
+	st := m.getStation(client.RoomID)
+	if st == nil {
+		return
+	}
+	member, err := m.cache.GetMemberOnline(ctx, client.RoomID, client.UserID)
+	if err != nil || member == nil || (!member.CanControl && client.UserID != st.ownerID) {
+		logger.Warn("电台切歌失败：无权限",
+			logger.String("roomId", client.RoomID),
+			logger.Int64("userId", client.UserID))
+		return
+	}
+	signal(st.skip)
+}
+
+// refillStation 由 AI 根据歌单生成歌曲并追加到房间歌单，同一电台同时只进行一次
+func (m *RoomManager) refillStation(ctx context.Context, st *station) error {
+	if !st.refilling.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer st.refilling.Store(false)
+
+	playlist, err := m.GetPlaylist(ctx, st.roomID)
+	if err != nil {
+		return err
+	}
+	songs, err := m.suggester.SuggestFromPlaylist(ctx, playlist)
+	if err != nil {
+		return err
+	}
+	for _, song := range songs {
+		item := &cache.PlaylistItem{
+			SongID:   song.SourceID,
+			Name:     song.Title,
+			Artist:   song.Artist,
+			Cover:    song.Cover,
+			Duration: song.Duration,
+			Source:   song.Source,
+			SourceID: song.SourceID,
+			HLSURL:   song.HLSURL,
+			AddedAt:  time.Now().UnixMilli(),
+		}
+		if err := m.cache.AddToRoomPlaylist(ctx, st.roomID, item); err != nil {
+			return err
+		}
+		m.broadcastSongAdd(st.roomID, 0, &SongData{
+			SongID:   item.SongID,
+			Name:     item.Name,
+			Artist:   item.Artist,
+			Cover:    item.Cover,
+			Duration: item.Duration,
+			Source:   item.Source,
+			Position: item.Position,
+		})
+	}
+	logger.Info("电台已续播",
+		logger.String("roomId", st.roomID),
+		logger.Int("added", len(songs)))
+	return nil
+}
+
+// stationSyncData 电台当前的播放状态，格式与房主上报的 master_sync 一致
+func (m *RoomManager) stationSyncData(st *station) *MasterSyncData {
+	position := st.position()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return &MasterSyncData{
+		SongID:     st.song.SongID,
+		SongName:   st.song.Name,
+		Artist:     st.song.Artist,
+		Cover:      st.song.Cover,
+		Duration:   int(st.duration.Milliseconds()),
+		Position:   position,
+		IsPlaying:  true,
+		HlsURL:     st.hlsURL,
+		ServerTime: time.Now().UnixMilli(),
+		MasterID:   st.ownerID,
+		MasterName: stationName,
+	}
+}
+
+// sendStationSync 向单个用户发送电台播放状态，房间不在电台模式时返回 false
+func (m *RoomManager) sendStationSync(roomID string, userID int64) bool {
+	st := m.getStation(roomID)
+	if st == nil {
+		return false
+	}
+	syncData := m.stationSyncData(st)
+	data, err := json.Marshal(syncData)
+	if err != nil {
+		return true
+	}
+	msg := &WSMessage{
+		Type:      MsgTypeMasterSync,
+		RoomID:    roomID,
+		UserID:    syncData.MasterID,
+		Username:  syncData.MasterName,
+		Data:      data,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	if err := m.hub.SendToUser(roomID, userID, msg); err != nil {
+		logger.Debug("发送电台播放状态失败",
+			logger.String("roomId", roomID),
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+	}
+	return true
+}
+
+// broadcastStationMode 广播电台模式开关
+func (m *RoomManager) broadcastStationMode(roomID string, enabled bool) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"enabled": enabled,
+	})
+	msg := &WSMessage{
+		Type:   MsgTypeStationMode,
+		RoomID: roomID,
+		Data:   data,
+	}
+	m.hub.BroadcastWSMessage(roomID, msg, 0, "")
+}
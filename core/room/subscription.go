@@ -144,7 +144,7 @@ func (s *PlaybackSubscription) Publish(roomID string, state *MasterSyncData, exc
 	// 复制订阅者列表，避免长时间持锁
 	clients := make([]*Client, 0, len(subs))
 	for uid, client := range subs {
-		if uid != excludeUserID {
+		if uid != excludeUserID && client.IsSubscribed(TopicPlayback) {
 			clients = append(clients, client)
 		}
 	}
@@ -197,7 +197,7 @@ func (s *PlaybackSubscription) PublishToUser(roomID string, userID int64, state
 	client := subs[userID]
 	s.mu.RUnlock()
 
-	if client == nil {
+	if client == nil || !client.IsSubscribed(TopicPlayback) {
 		return
 	}
 
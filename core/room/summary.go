@@ -0,0 +1,124 @@
+package room
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// recordPlayback 播放状态变化时累计房间播放时长，并记录开始播放的歌曲，供房间关闭时生成总结
+func (m *RoomManager) recordPlayback(roomID string, state *model.RoomPlaybackState) {
+	if state == nil {
+		return
+	}
+	ctx := context.Background()
+	now := time.Now()
+	if err := m.cache.UpdateRoomPlayTime(ctx, roomID, state.IsPlaying && state.CurrentSong != nil, now); err != nil {
+		logger.Debug("累计房间播放时长失败", logger.String("roomId", roomID), logger.ErrorField(err))
+	}
+
+	song, ok := playedSong(state)
+	if !ok {
+		return
+	}
+	song.StartedAt = now.UnixMilli()
+	if err := m.cache.RecordRoomSong(ctx, roomID, song); err != nil {
+		logger.Debug("记录房间播放歌曲失败", logger.String("roomId", roomID), logger.ErrorField(err))
+	}
+}
+
+// playedSong 从播放状态中解析当前歌曲的展示信息
+func playedSong(state *model.RoomPlaybackState) (*model.RoomPlayedSong, bool) {
+	if state.CurrentSong == nil {
+		return nil, false
+	}
+	data, err := json.Marshal(state.CurrentSong)
+	if err != nil {
+		return nil, false
+	}
+	var song struct {
+		SongID string `json:"songId"`
+		Name   string `json:"name"`
+		Title  string `json:"title"`
+		Artist string `json:"artist"`
+		Cover  string `json:"cover"`
+	}
+	if err := json.Unmarshal(data, &song); err != nil || song.SongID == "" {
+		return nil, false
+	}
+	if song.Name == "" {
+		song.Name = song.Title
+	}
+	return &model.RoomPlayedSong{SongID: song.SongID, Name: song.Name, Artist: song.Artist, Cover: song.Cover}, true
+}
+
+// saveSummary 在房间关闭前汇总播放记录、聊天和在线数据并持久化；失败只记录日志，不影响关闭房间
+func (m *RoomManager) saveSummary(ctx context.Context, room *model.Room) {
+	roomID := room.ID
+	now := time.Now()
+	summary := &model.RoomSummary{
+		RoomID:    roomID,
+		RoomName:  room.Name,
+		OwnerID:   room.OwnerID,
+		StartedAt: room.CreatedAt,
+		ClosedAt:  now,
+	}
+
+	// 先把最后一段播放时间计入
+	if err := m.cache.UpdateRoomPlayTime(ctx, roomID, false, now); err != nil {
+		logger.Warn("累计房间播放时长失败", logger.String("roomId", roomID), logger.ErrorField(err))
+	}
+	if stats, err := m.cache.GetRoomStats(ctx, roomID); err != nil {
+		logger.Warn("获取房间统计失败", logger.String("roomId", roomID), logger.ErrorField(err))
+	} else {
+		summary.ListenSeconds = stats.PlayedMs / 1000
+		summary.PeakListeners = stats.Peak
+	}
+	if songs, err := m.cache.GetRoomHistory(ctx, roomID); err != nil {
+		logger.Warn("获取房间播放记录失败", logger.String("roomId", roomID), logger.ErrorField(err))
+	} else {
+		summary.Songs = songs
+		summary.SongCount = len(songs)
+	}
+
+	if chatter, err := m.repo.GetTopChatter(ctx, roomID); err != nil {
+		logger.Warn("获取房间最活跃成员失败", logger.String("roomId", roomID), logger.ErrorField(err))
+	} else if chatter != nil {
+		summary.TopChatterID = chatter.UserID
+		summary.TopChatterName = chatter.Username
+		summary.TopChatterMessages = chatter.Messages
+	}
+	if count, err := m.repo.CountChatMessages(ctx, roomID); err != nil {
+		logger.Warn("统计房间聊天消息失败", logger.String("roomId", roomID), logger.ErrorField(err))
+	} else {
+		summary.MessageCount = count
+	}
+	if count, err := m.repo.CountParticipants(ctx, roomID); err != nil {
+		logger.Warn("统计房间参与人数失败", logger.String("roomId", roomID), logger.ErrorField(err))
+	} else {
+		summary.Participants = count
+	}
+
+	if err := m.repo.SaveSummary(ctx, summary); err != nil {
+		logger.Warn("保存房间总结失败", logger.String("roomId", roomID), logger.ErrorField(err))
+		return
+	}
+	logger.Info("房间总结已生成",
+		logger.String("roomId", roomID),
+		logger.Int("songs", summary.SongCount),
+		logger.Int64("listenSeconds", summary.ListenSeconds),
+		logger.Int("peakListeners", summary.PeakListeners))
+}
+
+// HasParticipated 检查用户是否加入过房间，房间关闭后成员仍可查看总结
+func (m *RoomManager) HasParticipated(ctx context.Context, roomID string, userID int64) (bool, error) {
+	return m.repo.HasParticipated(ctx, roomID, userID)
+}
+
+// GetRoomSummary 获取房间关闭时生成的总结，房间未关闭或不存在时返回 nil
+func (m *RoomManager) GetRoomSummary(ctx context.Context, roomID string) (*model.RoomSummary, error) {
+	return m.repo.GetSummary(ctx, roomID)
+}
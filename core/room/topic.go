@@ -0,0 +1,89 @@
+package room
+
+import (
+	"strings"
+)
+
+// Topic 订阅主题
+// 客户端在握手时声明需要的主题，Hub 只推送对应主题的消息
+type Topic string
+
+const (
+	TopicChat     Topic = "chat"     // 聊天、歌曲搜索结果
+	TopicPlayback Topic = "playback" // 播放状态、切歌、房主同步
+	TopicPresence Topic = "presence" // 成员进出、角色与权限变化
+	TopicPlaylist Topic = "playlist" // 歌单增删与排序
+)
+
+// AllTopics 全部可订阅主题（未声明主题时的默认值）
+var AllTopics = []Topic{TopicChat, TopicPlayback, TopicPresence, TopicPlaylist}
+
+// messageTopics 消息类型 -> 所属主题
+// 未列出的消息类型（错误、心跳、连接状态、房间解散等）属于系统消息，始终推送
+var messageTopics = map[MessageType]Topic{
+	MsgTypeChat:       TopicChat,
+	MsgTypeSongSearch: TopicChat,
+
+	MsgTypeSync:             TopicPlayback,
+	MsgTypePlay:             TopicPlayback,
+	MsgTypePause:            TopicPlayback,
+	MsgTypeSeek:             TopicPlayback,
+	MsgTypeNext:             TopicPlayback,
+	MsgTypePrev:             TopicPlayback,
+	MsgTypePlayback:         TopicPlayback,
+	MsgTypeMasterSync:       TopicPlayback,
+	MsgTypeMasterRequest:    TopicPlayback,
+	MsgTypeMasterModeChange: TopicPlayback,
+	MsgTypeSongChange:       TopicPlayback,
+	MsgTypeStationMode:      TopicPlayback,
+	MsgTypeKaraokeMode:      TopicPlayback,
+	MsgTypeLyric:            TopicPlayback,
+
+	MsgTypeJoin:          TopicPresence,
+	MsgTypeLeave:         TopicPresence,
+	MsgTypeMemberList:    TopicPresence,
+	MsgTypeModeSync:      TopicPresence,
+	MsgTypeTransferOwner: TopicPresence,
+	MsgTypeGrantControl:  TopicPresence,
+	MsgTypeRoleUpdate:    TopicPresence,
+
+	MsgTypeSongAdd:         TopicPlaylist,
+	MsgTypeSongDel:         TopicPlaylist,
+	MsgTypeSongPlay:        TopicPlaylist,
+	MsgTypePlaylist:        TopicPlaylist,
+	MsgTypePlaylistReorder: TopicPlaylist,
+}
+
+// TopicOf 获取消息类型所属主题，系统消息返回空字符串
+func TopicOf(msgType MessageType) Topic {
+	return messageTopics[msgType]
+}
+
+// ParseTopics 解析逗号分隔的主题列表
+// 空字符串或没有任何合法主题时返回全部主题，保证旧客户端行为不变
+func ParseTopics(raw string) map[Topic]bool {
+	topics := make(map[Topic]bool)
+	for _, part := range strings.Split(raw, ",") {
+		topic := Topic(strings.ToLower(strings.TrimSpace(part)))
+		if isValidTopic(topic) {
+			topics[topic] = true
+		}
+	}
+
+	if len(topics) == 0 {
+		for _, topic := range AllTopics {
+			topics[topic] = true
+		}
+	}
+	return topics
+}
+
+// isValidTopic 检查主题是否合法
+func isValidTopic(topic Topic) bool {
+	for _, t := range AllTopics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
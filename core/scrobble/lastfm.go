@@ -0,0 +1,173 @@
+package scrobble
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"Bt1QFM/model"
+)
+
+// lastFMAPIURL Last.fm API 地址
+const lastFMAPIURL = "https://ws.audioscrobbler.com/2.0/"
+
+// Last.fm 可重试的错误码：服务离线、暂时不可用、超过调用频率
+var lastFMRetryableErrors = map[int]bool{11: true, 16: true, 29: true}
+
+// LastFM Last.fm 客户端，api key/secret 优先使用账号中用户自己的配置
+type LastFM struct {
+	apiKey    string
+	apiSecret string
+	client    *http.Client
+}
+
+// NewLastFM 创建 Last.fm 客户端，apiKey、apiSecret 为服务器默认的应用凭据
+func NewLastFM(apiKey, apiSecret string) *LastFM {
+	return &LastFM{apiKey: apiKey, apiSecret: apiSecret, client: httpClient}
+}
+
+// Configured 是否配置了服务器默认的应用凭据
+func (c *LastFM) Configured() bool {
+	return c.apiKey != "" && c.apiSecret != ""
+}
+
+// Authenticate 用 Last.fm 用户名和密码换取 session key，密码不会被保存
+// apiKey、apiSecret 为空时使用服务器默认的应用凭据
+func (c *LastFM) Authenticate(ctx context.Context, username, password, apiKey, apiSecret string) (*model.ScrobbleAccount, error) {
+	key, secret := c.credentials(apiKey, apiSecret)
+	if key == "" || secret == "" {
+		return nil, ErrNotConfigured
+	}
+
+	var resp struct {
+		Session struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"session"`
+	}
+	err := c.call(ctx, secret, map[string]string{
+		"method":   "auth.getMobileSession",
+		"username": username,
+		"password": password,
+		"api_key":  key,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Session.Key == "" {
+		return nil, fmt.Errorf("%w: last.fm returned no session", ErrRejected)
+	}
+	return &model.ScrobbleAccount{
+		Service:    model.ScrobbleServiceLastFM,
+		Username:   resp.Session.Name,
+		SessionKey: resp.Session.Key,
+		APIKey:     apiKey,
+		APISecret:  apiSecret,
+	}, nil
+}
+
+// Scrobble 提交一次收听
+func (c *LastFM) Scrobble(ctx context.Context, account *model.ScrobbleAccount, listen Listen) error {
+	key, secret := c.credentials(account.APIKey, account.APISecret)
+	if key == "" || secret == "" {
+		return ErrNotConfigured
+	}
+
+	params := map[string]string{
+		"method":    "track.scrobble",
+		"artist":    listen.Artist,
+		"track":     listen.Title,
+		"timestamp": strconv.FormatInt(listen.ListenedAt.Unix(), 10),
+		"api_key":   key,
+		"sk":        account.SessionKey,
+	}
+	if listen.Album != "" {
+		params["album"] = listen.Album
+	}
+	if listen.Duration > 0 {
+		params["duration"] = strconv.Itoa(listen.Duration)
+	}
+	return c.call(ctx, secret, params, nil)
+}
+
+// credentials 用户提供了完整的 key/secret 时使用用户的，否则使用服务器默认的
+func (c *LastFM) credentials(apiKey, apiSecret string) (string, string) {
+	if apiKey != "" && apiSecret != "" {
+		return apiKey, apiSecret
+	}
+	return c.apiKey, c.apiSecret
+}
+
+// call 签名并发送 POST 请求，Last.fm 在响应体中返回错误码
+func (c *LastFM) call(ctx context.Context, secret string, params map[string]string, out interface{}) error {
+	form := url.Values{}
+	for k, v := range params {
+		form.Set(k, v)
+	}
+	form.Set("api_sig", lastFMSignature(params, secret))
+	form.Set("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, lastFMAPIURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("last.fm request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	raw, err := readBody(resp)
+	if err != nil {
+		return err
+	}
+	if json.Unmarshal(raw, &body) == nil && body.Error != 0 {
+		if lastFMRetryableErrors[body.Error] {
+			return fmt.Errorf("last.fm error %d: %s", body.Error, body.Message)
+		}
+		return fmt.Errorf("%w: last.fm error %d: %s", ErrRejected, body.Error, body.Message)
+	}
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("last.fm returned status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: last.fm returned status %d", ErrRejected, resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("invalid last.fm response: %w", err)
+		}
+	}
+	return nil
+}
+
+// lastFMSignature 按参数名排序拼接参数名和值，追加 secret 后取 MD5
+func lastFMSignature(params map[string]string, secret string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteString(params[k])
+	}
+	b.WriteString(secret)
+	sum := md5.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,115 @@
+package scrobble
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"Bt1QFM/model"
+)
+
+// ListenBrainz ListenBrainz 客户端，使用用户在个人设置页获取的 user token
+type ListenBrainz struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewListenBrainz 创建 ListenBrainz 客户端，baseURL 可指向自建实例
+func NewListenBrainz(baseURL string) *ListenBrainz {
+	return &ListenBrainz{baseURL: strings.TrimRight(baseURL, "/"), client: httpClient}
+}
+
+// Authenticate 校验 user token 并返回对应的账号
+func (c *ListenBrainz) Authenticate(ctx context.Context, token string) (*model.ScrobbleAccount, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/1/validate-token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Token "+token)
+
+	var resp struct {
+		Valid    bool   `json:"valid"`
+		UserName string `json:"user_name"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Valid {
+		return nil, fmt.Errorf("%w: invalid listenbrainz token", ErrRejected)
+	}
+	return &model.ScrobbleAccount{
+		Service:    model.ScrobbleServiceListenBrainz,
+		Username:   resp.UserName,
+		SessionKey: token,
+	}, nil
+}
+
+// Scrobble 提交一次收听
+func (c *ListenBrainz) Scrobble(ctx context.Context, account *model.ScrobbleAccount, listen Listen) error {
+	additional := map[string]interface{}{
+		"submission_client": "1QFM",
+		"music_service":     listen.Source,
+	}
+	if listen.Duration > 0 {
+		additional["duration_ms"] = listen.Duration * 1000
+	}
+	metadata := map[string]interface{}{
+		"artist_name":     listen.Artist,
+		"track_name":      listen.Title,
+		"additional_info": additional,
+	}
+	if listen.Album != "" {
+		metadata["release_name"] = listen.Album
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"listen_type": "single",
+		"payload": []map[string]interface{}{{
+			"listened_at":    listen.ListenedAt.Unix(),
+			"track_metadata": metadata,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/1/submit-listens", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+account.SessionKey)
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, nil)
+}
+
+// do 发送请求，4xx（429 除外）视为被拒绝，不再重试
+func (c *ListenBrainz) do(req *http.Request, out interface{}) error {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("listenbrainz request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := readBody(resp)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(raw, &body)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return fmt.Errorf("%w: listenbrainz returned status %d: %s", ErrRejected, resp.StatusCode, body.Error)
+		}
+		return fmt.Errorf("listenbrainz returned status %d: %s", resp.StatusCode, body.Error)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("invalid listenbrainz response: %w", err)
+		}
+	}
+	return nil
+}
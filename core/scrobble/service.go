@@ -0,0 +1,256 @@
+package scrobble
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+var (
+	// ErrRejected 服务拒绝了请求（账号失效、参数错误等），重试也不会成功
+	ErrRejected = errors.New("scrobble rejected")
+	// ErrNotConfigured 服务器和账号都没有配置 Last.fm API key
+	ErrNotConfigured = errors.New("last.fm api key is not configured")
+)
+
+// 重试策略
+const (
+	retryInterval   = time.Minute // 检查重试队列的间隔
+	retryBatchSize  = 50
+	maxRetries      = 8 // 超过后丢弃
+	submitTimeout   = 15 * time.Second
+	maxResponseSize = 64 << 10
+)
+
+// httpClient 访问 Last.fm / ListenBrainz 的 HTTP 客户端
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Listen 一次达到提交条件的收听
+type Listen struct {
+	Source     string    `json:"source"`
+	SourceID   string    `json:"sourceId"`
+	Title      string    `json:"title"`
+	Artist     string    `json:"artist"`
+	Album      string    `json:"album,omitempty"`
+	Duration   int       `json:"duration,omitempty"` // 秒
+	ListenedAt time.Time `json:"listenedAt"`         // 开始收听的时间
+}
+
+// Client 听歌记录同步服务
+type Client interface {
+	Scrobble(ctx context.Context, account *model.ScrobbleAccount, listen Listen) error
+}
+
+// retryItem 重试队列中的一项，ID 保证相同内容的多次失败不会在队列中合并
+type retryItem struct {
+	ID       string `json:"id"`
+	UserID   int64  `json:"userId"`
+	Service  string `json:"service"`
+	Listen   Listen `json:"listen"`
+	Attempts int    `json:"attempts"`
+}
+
+// Service 向用户绑定的 Last.fm / ListenBrainz 账号提交收听记录，失败时进入 Redis 重试队列
+type Service struct {
+	accountRepo repository.ScrobbleAccountRepository
+	userRepo    repository.UserRepository
+	clients     map[string]Client
+
+	LastFM       *LastFM
+	ListenBrainz *ListenBrainz
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewService 创建听歌记录同步服务
+func NewService(accountRepo repository.ScrobbleAccountRepository, userRepo repository.UserRepository, cfg *config.Config) *Service {
+	lastFM := NewLastFM(cfg.LastFMAPIKey, cfg.LastFMAPISecret)
+	listenBrainz := NewListenBrainz(cfg.ListenBrainzAPIURL)
+	return &Service{
+		accountRepo: accountRepo,
+		userRepo:    userRepo,
+		clients: map[string]Client{
+			model.ScrobbleServiceLastFM:       lastFM,
+			model.ScrobbleServiceListenBrainz: listenBrainz,
+		},
+		LastFM:       lastFM,
+		ListenBrainz: listenBrainz,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// Submit 在后台向用户绑定的所有账号提交一次收听，用户未开启同步时忽略
+func (s *Service) Submit(userID int64, listen Listen) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), submitTimeout)
+		defer cancel()
+
+		user, err := s.userRepo.GetUserByID(ctx, userID)
+		if err != nil || user == nil || !user.GetPreferences().Scrobble.Enabled {
+			return
+		}
+		accounts, err := s.accountRepo.GetAccountsByUserID(ctx, userID)
+		if err != nil {
+			logger.Warn("获取听歌记录同步账号失败", logger.Int64("userId", userID), logger.ErrorField(err))
+			return
+		}
+		for _, account := range accounts {
+			s.submit(ctx, account, &retryItem{UserID: userID, Service: account.Service, Listen: listen})
+		}
+	}()
+}
+
+// submit 提交一次，可重试的失败进入重试队列
+func (s *Service) submit(ctx context.Context, account *model.ScrobbleAccount, item *retryItem) {
+	client, ok := s.clients[account.Service]
+	if !ok {
+		return
+	}
+	err := client.Scrobble(ctx, account, item.Listen)
+	if err == nil {
+		logger.Debug("听歌记录已同步",
+			logger.Int64("userId", item.UserID),
+			logger.String("service", account.Service),
+			logger.String("title", item.Listen.Title))
+		return
+	}
+	if errors.Is(err, ErrRejected) || errors.Is(err, ErrNotConfigured) {
+		logger.Warn("听歌记录被拒绝，不再重试",
+			logger.Int64("userId", item.UserID),
+			logger.String("service", account.Service),
+			logger.ErrorField(err))
+		return
+	}
+
+	item.Attempts++
+	if item.Attempts > maxRetries {
+		logger.Warn("听歌记录多次同步失败，已丢弃",
+			logger.Int64("userId", item.UserID),
+			logger.String("service", account.Service),
+			logger.Int("attempts", item.Attempts),
+			logger.ErrorField(err))
+		return
+	}
+	if err := s.queueRetry(ctx, item); err != nil {
+		logger.Error("听歌记录加入重试队列失败",
+			logger.Int64("userId", item.UserID),
+			logger.String("service", account.Service),
+			logger.ErrorField(err))
+		return
+	}
+	logger.Info("听歌记录同步失败，稍后重试",
+		logger.Int64("userId", item.UserID),
+		logger.String("service", account.Service),
+		logger.Int("attempts", item.Attempts),
+		logger.ErrorField(err))
+}
+
+// queueRetry 按指数退避（2、4、8……分钟）安排下一次重试
+func (s *Service) queueRetry(ctx context.Context, item *retryItem) error {
+	if item.ID == "" {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		item.ID = hex.EncodeToString(buf)
+	}
+	payload, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	delay := time.Duration(1<<item.Attempts) * time.Minute
+	return cache.QueueScrobbleRetry(ctx, payload, time.Now().Add(delay))
+}
+
+// Start 定期处理重试队列
+func (s *Service) Start() {
+	logger.Info("听歌记录同步服务启动", logger.Bool("lastfmConfigured", s.LastFM.Configured()))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(retryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				s.processRetries()
+			}
+		}
+	}()
+}
+
+// Stop 停止处理重试队列
+func (s *Service) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// processRetries 重新提交到期的听歌记录，账号已解绑或用户已关闭同步时丢弃
+func (s *Service) processRetries() {
+	ctx, cancel := context.WithTimeout(context.Background(), retryInterval)
+	defer cancel()
+
+	payloads, err := cache.PopDueScrobbleRetries(ctx, time.Now(), retryBatchSize)
+	if err != nil {
+		logger.Warn("读取听歌记录重试队列失败", logger.ErrorField(err))
+		return
+	}
+	for _, payload := range payloads {
+		var item retryItem
+		if err := json.Unmarshal(payload, &item); err != nil {
+			logger.Warn("丢弃无法解析的听歌记录", logger.ErrorField(err))
+			continue
+		}
+		user, err := s.userRepo.GetUserByID(ctx, item.UserID)
+		if err != nil {
+			s.requeue(ctx, &item, err)
+			continue
+		}
+		if user == nil || !user.GetPreferences().Scrobble.Enabled {
+			continue
+		}
+		account, err := s.accountRepo.GetAccount(ctx, item.UserID, item.Service)
+		if err != nil {
+			s.requeue(ctx, &item, err)
+			continue
+		}
+		if account == nil {
+			continue
+		}
+		s.submit(ctx, account, &item)
+	}
+}
+
+// requeue 读取账号失败时原样放回队列，不计入重试次数
+func (s *Service) requeue(ctx context.Context, item *retryItem, cause error) {
+	logger.Warn("处理听歌记录重试失败", logger.Int64("userId", item.UserID), logger.ErrorField(cause))
+	if err := s.queueRetry(ctx, item); err != nil {
+		logger.Error("听歌记录放回重试队列失败", logger.Int64("userId", item.UserID), logger.ErrorField(err))
+	}
+}
+
+// readBody 读取响应体，限制大小
+func readBody(resp *http.Response) ([]byte, error) {
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,203 @@
+package scrobble
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+const (
+	// maxBeatGap 两次心跳间最多计入的收听时长，客户端断线期间不计入
+	maxBeatGap = 60 * time.Second
+	// minScrobbleDuration Last.fm 不接受短于 30 秒的歌曲
+	minScrobbleDuration = 30
+	// unknownDurationThreshold 时长未知的歌曲收听超过该时长即提交
+	unknownDurationThreshold = 240
+	// restartPosition 同一首歌从该位置之前重新开始视为新的一次收听（单曲循环、重新播放）
+	restartPosition = 5
+)
+
+// Beat 客户端上报的一次播放心跳
+type Beat struct {
+	Source    string
+	SourceID  string
+	Position  float64
+	IsPlaying bool
+}
+
+// Tracker 根据播放心跳累计实际收听时长，写入播放历史，收听超过一半时提交听歌记录
+type Tracker struct {
+	historyRepo repository.PlayHistoryRepository
+	trackRepo   repository.TrackRepository
+	neteaseRepo *repository.NeteaseSongRepository
+	service     *Service
+
+	// 按用户分段加锁，同一用户多个设备的心跳依次处理
+	locks [64]sync.Mutex
+}
+
+// NewTracker 创建收听跟踪器
+func NewTracker(historyRepo repository.PlayHistoryRepository, trackRepo repository.TrackRepository, service *Service) *Tracker {
+	return &Tracker{
+		historyRepo: historyRepo,
+		trackRepo:   trackRepo,
+		neteaseRepo: repository.NewNeteaseSongRepository(),
+		service:     service,
+	}
+}
+
+// Heartbeat 处理一次播放心跳
+func (t *Tracker) Heartbeat(ctx context.Context, userID int64, beat Beat) error {
+	lock := &t.locks[userID%int64(len(t.locks))]
+	lock.Lock()
+	defer lock.Unlock()
+
+	now := time.Now()
+	state, err := cache.GetListenState(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	sameSong := state != nil && state.Source == beat.Source && state.SourceID == beat.SourceID
+	restarted := sameSong && beat.Position < restartPosition && state.Position-beat.Position > restartPosition*2
+	if !sameSong || restarted {
+		if state != nil {
+			t.finish(ctx, state)
+		}
+		if beat.SourceID == "" {
+			return cache.ClearListenState(ctx, userID)
+		}
+		state, err = t.start(ctx, userID, beat, now)
+		if err != nil || state == nil {
+			return err
+		}
+	} else {
+		if state.Playing {
+			gap := now.Sub(time.UnixMilli(state.LastBeat))
+			if gap > maxBeatGap {
+				gap = maxBeatGap
+			}
+			if gap > 0 {
+				state.Listened += gap.Seconds()
+			}
+		}
+		state.Position = beat.Position
+		state.Playing = beat.IsPlaying
+		state.LastBeat = now.UnixMilli()
+	}
+
+	if !state.Scrobbled && reachedThreshold(state) {
+		state.Scrobbled = true
+		if err := t.historyRepo.UpdatePlayProgress(ctx, state.HistoryID, state.Listened, true); err != nil {
+			logger.Warn("更新播放历史失败", logger.Int64("historyId", state.HistoryID), logger.ErrorField(err))
+		}
+		if t.service != nil && state.Artist != "" && state.Title != "" {
+			t.service.Submit(userID, Listen{
+				Source:     state.Source,
+				SourceID:   state.SourceID,
+				Title:      state.Title,
+				Artist:     state.Artist,
+				Album:      state.Album,
+				Duration:   int(state.Duration),
+				ListenedAt: time.Unix(state.StartedAt, 0),
+			})
+		}
+	}
+	return cache.SaveListenState(ctx, userID, state)
+}
+
+// reachedThreshold 收听时长超过歌曲的一半（时长未知时超过 4 分钟）
+func reachedThreshold(state *cache.ListenState) bool {
+	if state.Duration <= 0 {
+		return state.Listened >= unknownDurationThreshold
+	}
+	return state.Duration >= minScrobbleDuration && state.Listened >= state.Duration/2
+}
+
+// start 开始新的一次收听，写入播放历史并累计当天的播放次数，无法识别歌曲时返回 nil
+func (t *Tracker) start(ctx context.Context, userID int64, beat Beat, now time.Time) (*cache.ListenState, error) {
+	state := t.resolve(ctx, userID, beat)
+	if state == nil {
+		return nil, nil
+	}
+	state.Position = beat.Position
+	state.Playing = beat.IsPlaying
+	state.StartedAt = now.Unix()
+	state.LastBeat = now.UnixMilli()
+
+	id, err := t.historyRepo.CreatePlay(ctx, &model.PlayHistory{
+		UserID:    userID,
+		Source:    state.Source,
+		SourceID:  state.SourceID,
+		Title:     state.Title,
+		Artist:    state.Artist,
+		Album:     state.Album,
+		Duration:  state.Duration,
+		StartedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	state.HistoryID = id
+
+	if err := cache.IncrPlayCount(ctx, state.Source, state.SourceID, now); err != nil {
+		logger.Warn("累计播放次数失败", logger.String("source", state.Source), logger.String("sourceId", state.SourceID), logger.ErrorField(err))
+	}
+	return state, nil
+}
+
+// finish 保存上一次收听的累计时长
+func (t *Tracker) finish(ctx context.Context, state *cache.ListenState) {
+	if err := t.historyRepo.UpdatePlayProgress(ctx, state.HistoryID, state.Listened, state.Scrobbled); err != nil {
+		logger.Warn("更新播放历史失败", logger.Int64("historyId", state.HistoryID), logger.ErrorField(err))
+	}
+}
+
+// resolve 获取歌曲元数据：优先使用播放列表中的信息，其次查询曲库或网易云歌曲表
+func (t *Tracker) resolve(ctx context.Context, userID int64, beat Beat) *cache.ListenState {
+	state := &cache.ListenState{Source: beat.Source, SourceID: beat.SourceID}
+
+	if playlist, err := cache.GetPlaylist(ctx, userID); err == nil {
+		for _, item := range playlist {
+			if item.Matches(beat.Source, beat.SourceID) {
+				state.Title, state.Artist, state.Album = item.Title, item.Artist, item.Album
+				state.Duration = float64(item.Duration)
+				break
+			}
+		}
+	}
+	if state.Title != "" && state.Duration > 0 {
+		return state
+	}
+
+	switch beat.Source {
+	case cache.SourceLocal:
+		id, err := strconv.ParseInt(beat.SourceID, 10, 64)
+		if err != nil {
+			return nil
+		}
+		track, err := t.trackRepo.GetTrackByID(ctx, id)
+		if err != nil || track == nil || track.UserID != userID {
+			return nil
+		}
+		state.Title, state.Artist, state.Album = track.Title, track.Artist, track.Album
+		state.Duration = float64(track.Duration)
+	case cache.SourceNetease:
+		if song, err := t.neteaseRepo.GetNeteaseSongByID(beat.SourceID); err == nil && song != nil {
+			if state.Title == "" {
+				state.Title, state.Artist, state.Album = song.Title, song.Artist, song.Album
+			}
+			state.Duration = song.Duration
+		}
+	}
+	if state.Title == "" {
+		return nil
+	}
+	return state
+}
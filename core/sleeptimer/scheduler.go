@@ -0,0 +1,173 @@
+package sleeptimer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/logger"
+)
+
+const (
+	// MaxMinutes 睡眠定时最长的时长
+	MaxMinutes = 12 * 60
+	// Reason 定时到期时发给设备的暂停命令中的原因
+	Reason = "sleep_timer"
+	// checkInterval 检查到期定时的间隔，决定定时的精度
+	checkInterval = 5 * time.Second
+	// popBatch 每次最多处理的到期定时数
+	popBatch = 100
+)
+
+// Controller 让用户的在线设备暂停并同步睡眠定时，由设备 Hub 实现
+type Controller interface {
+	PauseAll(userID int64, reason string) int
+	PushSleepTimer(userID int64, payload interface{})
+}
+
+// Scheduler 睡眠定时任务：定时保存在 Redis，到期时把播放状态标记为暂停并让用户的所有在线设备暂停
+// 到期的定时从 Redis 中原子地取出，多个实例同时运行时只会触发一次
+type Scheduler struct {
+	controller Controller
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewScheduler 创建睡眠定时任务，controller 为空时到期只修改播放状态
+func NewScheduler(controller Controller) *Scheduler {
+	return &Scheduler{
+		controller: controller,
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// Start 启动时先触发停机期间到期的定时，之后每 5 秒检查一次
+func (s *Scheduler) Start() {
+	logger.Info("睡眠定时任务启动", logger.Duration("interval", checkInterval))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.tick()
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				s.tick()
+			}
+		}
+	}()
+}
+
+// Stop 停止定时任务
+func (s *Scheduler) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// Set 为用户设置 minutes 分钟后到期的睡眠定时，已有的定时被替换
+func (s *Scheduler) Set(ctx context.Context, userID int64, minutes int, deviceID string) (*cache.SleepTimer, error) {
+	now := time.Now()
+	timer := &cache.SleepTimer{
+		Minutes:   minutes,
+		EndsAt:    now.Add(time.Duration(minutes) * time.Minute).UnixMilli(),
+		DeviceID:  deviceID,
+		CreatedAt: now.UnixMilli(),
+	}
+	if err := cache.SetSleepTimer(ctx, userID, timer); err != nil {
+		return nil, err
+	}
+	s.push(userID, timer)
+	return timer, nil
+}
+
+// Get 返回用户的睡眠定时，没有定时时返回 nil
+func (s *Scheduler) Get(ctx context.Context, userID int64) (*cache.SleepTimer, error) {
+	return cache.GetSleepTimer(ctx, userID)
+}
+
+// Cancel 取消用户的睡眠定时，返回是否存在定时
+func (s *Scheduler) Cancel(ctx context.Context, userID int64) (bool, error) {
+	cancelled, err := cache.DeleteSleepTimer(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if cancelled {
+		s.push(userID, nil)
+	}
+	return cancelled, nil
+}
+
+func (s *Scheduler) tick() {
+	fired, err := s.Run(context.Background(), time.Now())
+	if err != nil {
+		logger.Warn("处理睡眠定时失败", logger.ErrorField(err))
+		return
+	}
+	if fired > 0 {
+		logger.Info("睡眠定时已到期", logger.Int("users", fired))
+	}
+}
+
+// Run 触发 now 之前到期的睡眠定时，返回触发的数量
+func (s *Scheduler) Run(ctx context.Context, now time.Time) (int, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	fired := 0
+	for {
+		userIDs, err := cache.PopDueSleepTimers(ctx, now, popBatch)
+		if err != nil {
+			return fired, err
+		}
+		for _, userID := range userIDs {
+			if s.fire(ctx, userID, now) {
+				fired++
+			}
+		}
+		if len(userIDs) < popBatch {
+			return fired, nil
+		}
+	}
+}
+
+// fire 让用户停止播放，定时在取出后被取消或重新设置时不触发
+func (s *Scheduler) fire(ctx context.Context, userID int64, now time.Time) bool {
+	timer, err := cache.GetSleepTimer(ctx, userID)
+	if err != nil {
+		logger.Warn("读取睡眠定时失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		return false
+	}
+	if timer == nil || timer.EndsAt > now.UnixMilli() {
+		return false
+	}
+	if _, err := cache.DeleteSleepTimer(ctx, userID); err != nil {
+		logger.Warn("删除睡眠定时失败", logger.Int64("userId", userID), logger.ErrorField(err))
+	}
+
+	if err := cache.PauseUserPlayback(ctx, userID); err != nil {
+		logger.Warn("暂停播放状态失败", logger.Int64("userId", userID), logger.ErrorField(err))
+	}
+	devices := 0
+	if s.controller != nil {
+		devices = s.controller.PauseAll(userID, Reason)
+	}
+	s.push(userID, nil)
+	logger.Debug("睡眠定时到期，已停止播放",
+		logger.Int64("userId", userID),
+		logger.Int("devices", devices))
+	return true
+}
+
+// push 向用户的在线设备同步睡眠定时，timer 为 nil 表示没有定时
+func (s *Scheduler) push(userID int64, timer *cache.SleepTimer) {
+	if s.controller != nil {
+		s.controller.PushSleepTimer(userID, timer)
+	}
+}
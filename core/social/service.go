@@ -0,0 +1,180 @@
+package social
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"Bt1QFM/core/notification"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+const (
+	// MaxLimit 关注列表和动态流单页最多返回的数量
+	MaxLimit = 100
+	// feedScanFactor 读取动态流时每批多取的倍数，过滤掉隐私设置隐藏的动态后仍能凑够一页
+	feedScanFactor = 2
+	// maxFeedBatches 读取一页动态流最多查询的批数
+	maxFeedBatches = 5
+)
+
+var (
+	// ErrFollowSelf 不能关注自己
+	ErrFollowSelf = errors.New("cannot follow yourself")
+	// ErrUserNotFound 要关注的用户不存在或已被禁用
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// Service 用户关注关系和动态流
+// 动态在发生时按用户的隐私设置决定是否记录，读取动态流时再按当前设置过滤，关闭后此前的动态也不再展示
+type Service struct {
+	repo          repository.SocialRepository
+	userRepo      repository.UserRepository
+	notifications *notification.Service
+}
+
+// NewService 创建关注和动态服务
+func NewService(repo repository.SocialRepository, userRepo repository.UserRepository) *Service {
+	return &Service{repo: repo, userRepo: userRepo}
+}
+
+// SetNotificationService 设置通知服务，被关注的用户会收到通知
+func (s *Service) SetNotificationService(service *notification.Service) {
+	s.notifications = service
+}
+
+// Follow 关注用户，重复关注不报错，只有第一次关注时通知对方
+func (s *Service) Follow(ctx context.Context, followerID, followeeID int64) error {
+	if followerID == followeeID {
+		return ErrFollowSelf
+	}
+	user, err := s.userRepo.GetUserByID(ctx, followeeID)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.IsDisabled() {
+		return ErrUserNotFound
+	}
+	created, err := s.repo.Follow(ctx, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+	if created && s.notifications != nil {
+		s.notifications.Notify(ctx, &model.Notification{
+			UserID:   followeeID,
+			Type:     model.NotificationFollow,
+			ActorID:  followerID,
+			ObjectID: strconv.FormatInt(followerID, 10),
+		})
+	}
+	return nil
+}
+
+// Unfollow 取消关注，返回之前是否已关注
+func (s *Service) Unfollow(ctx context.Context, followerID, followeeID int64) (bool, error) {
+	return s.repo.Unfollow(ctx, followerID, followeeID)
+}
+
+// Followers 获取关注 userID 的用户
+func (s *Service) Followers(ctx context.Context, userID int64, limit, offset int) ([]*model.FollowUser, error) {
+	return s.repo.GetFollowers(ctx, userID, min(limit, MaxLimit), offset)
+}
+
+// Following 获取 userID 关注的用户
+func (s *Service) Following(ctx context.Context, userID int64, limit, offset int) ([]*model.FollowUser, error) {
+	return s.repo.GetFollowing(ctx, userID, min(limit, MaxLimit), offset)
+}
+
+// Stats 获取 userID 的关注数、粉丝数以及 viewerID 是否已关注
+func (s *Service) Stats(ctx context.Context, viewerID, userID int64) (*model.FollowStats, error) {
+	stats, err := s.repo.GetFollowStats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if viewerID != userID {
+		if stats.IsFollowing, err = s.repo.IsFollowing(ctx, viewerID, userID); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// Record 记录用户的动态，用户在隐私设置中隐藏了该类动态时不记录；失败只记录日志，不影响触发动态的操作
+func (s *Service) Record(ctx context.Context, activity *model.Activity) {
+	user, err := s.userRepo.GetUserByID(ctx, activity.UserID)
+	if err != nil || user == nil {
+		logger.Warn("读取用户隐私设置失败，不记录动态",
+			logger.Int64("userId", activity.UserID),
+			logger.String("type", activity.Type),
+			logger.ErrorField(err))
+		return
+	}
+	if !user.GetPreferences().Social.Shares(activity.Type) {
+		return
+	}
+	if err := s.repo.CreateActivity(ctx, activity); err != nil {
+		logger.Warn("记录用户动态失败",
+			logger.Int64("userId", activity.UserID),
+			logger.String("type", activity.Type),
+			logger.ErrorField(err))
+	}
+}
+
+// Remove 删除关于某个对象的动态，例如评论被删除时
+func (s *Service) Remove(ctx context.Context, activityType, objectID string) {
+	if err := s.repo.DeleteActivities(ctx, activityType, objectID); err != nil {
+		logger.Warn("删除用户动态失败",
+			logger.String("type", activityType),
+			logger.String("objectId", objectID),
+			logger.ErrorField(err))
+	}
+}
+
+// Feed 获取 userID 关注的用户 ID 小于 beforeID 的动态（beforeID 为 0 时从最新开始），
+// 按动态发布者当前的隐私设置过滤。返回的 next 用作下一页的 beforeID，为 0 表示没有更多
+func (s *Service) Feed(ctx context.Context, userID, beforeID int64, limit int) (items []*model.Activity, next int64, err error) {
+	limit = min(limit, MaxLimit)
+	batchSize := limit * feedScanFactor
+	prefs := make(map[int64]model.SocialPreferences)
+	items = make([]*model.Activity, 0, limit)
+
+	for batch := 0; batch < maxFeedBatches; batch++ {
+		activities, err := s.repo.GetFeed(ctx, userID, beforeID, batchSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, a := range activities {
+			beforeID = a.ID
+			if !s.shares(ctx, prefs, a) {
+				continue
+			}
+			items = append(items, a)
+			if len(items) == limit {
+				return items, a.ID, nil
+			}
+		}
+		if len(activities) < batchSize {
+			return items, 0, nil
+		}
+	}
+	// 连续多批都被隐藏，返回已找到的动态，客户端可以从 next 继续
+	return items, beforeID, nil
+}
+
+// shares 按发布者当前的隐私设置判断动态是否可见，同一页内每个用户只读取一次
+func (s *Service) shares(ctx context.Context, known map[int64]model.SocialPreferences, a *model.Activity) bool {
+	p, ok := known[a.UserID]
+	if !ok {
+		user, err := s.userRepo.GetUserByID(ctx, a.UserID)
+		if err != nil || user == nil || user.IsDisabled() {
+			// 读取失败时按隐藏处理
+			p = model.SocialPreferences{HideActivity: true}
+		} else {
+			p = user.GetPreferences().Social
+		}
+		known[a.UserID] = p
+	}
+	return p.Shares(a.Type)
+}
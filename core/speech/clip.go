@@ -0,0 +1,86 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"Bt1QFM/storage"
+)
+
+// clipPrefix 合成语音在对象存储中的路径前缀，位于 audio/ 下以使用音频存储桶
+const clipPrefix = "audio/tts/"
+
+// markdownPattern 朗读前去掉的 Markdown 标记
+var markdownPattern = regexp.MustCompile("[*_`#>~]+|!?\\[([^\\]]*)\\]\\([^)]*\\)")
+
+// sentenceEnds 截断文本时优先停在这些标点之后
+const sentenceEnds = "。！？!?."
+
+// ClipService 把回复合成为语音片段并保存到对象存储，相同文本和音色只合成一次
+type ClipService struct {
+	synth    Synthesizer
+	maxChars int
+}
+
+// NewClipService 创建语音片段服务，maxChars 为单条回复合成的最大字数
+func NewClipService(synth Synthesizer, maxChars int) *ClipService {
+	return &ClipService{synth: synth, maxChars: maxChars}
+}
+
+// Name 返回语音合成服务的名称
+func (s *ClipService) Name() string {
+	return s.synth.Name()
+}
+
+// Clip 合成文本并返回可播放的 /static/ 地址；没有可朗读的内容时返回空字符串
+func (s *ClipService) Clip(ctx context.Context, text string) (string, error) {
+	text = s.prepare(text)
+	if text == "" {
+		return "", nil
+	}
+
+	sum := sha256.Sum256([]byte(s.synth.Name() + "\n" + text))
+	key := clipPrefix + hex.EncodeToString(sum[:16]) + ".mp3"
+
+	store := storage.GetStorage()
+	if store == nil {
+		return "", fmt.Errorf("storage not initialized")
+	}
+	if _, err := store.Stat(ctx, key); err == nil {
+		return "/static/" + key, nil
+	} else if !storage.IsNotFound(err) {
+		return "", fmt.Errorf("failed to stat clip: %w", err)
+	}
+
+	audio, err := s.synth.Synthesize(ctx, text)
+	if err != nil {
+		return "", err
+	}
+	if err := store.Put(ctx, key, bytes.NewReader(audio), int64(len(audio)), "audio/mpeg"); err != nil {
+		return "", fmt.Errorf("failed to upload clip: %w", err)
+	}
+	return "/static/" + key, nil
+}
+
+// prepare 去掉 Markdown 标记，超出长度时截断到最后一个完整句子
+func (s *ClipService) prepare(text string) string {
+	text = markdownPattern.ReplaceAllString(text, "$1")
+	text = strings.TrimSpace(strings.Join(strings.Fields(text), " "))
+
+	runes := []rune(text)
+	if s.maxChars <= 0 || len(runes) <= s.maxChars {
+		return text
+	}
+	cut := string(runes[:s.maxChars])
+	if i := strings.LastIndexAny(cut, sentenceEnds); i > 0 {
+		_, size := utf8.DecodeRuneInString(cut[i:])
+		return strings.TrimSpace(cut[:i+size])
+	}
+	return cut
+}
@@ -0,0 +1,156 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"Bt1QFM/config"
+)
+
+// Supported text-to-speech providers.
+const (
+	ProviderAzure = "azure" // Azure 语音服务
+)
+
+// maxClipBytes 合成语音的最大字节数，防止异常响应占满内存
+const maxClipBytes = 10 << 20
+
+// Synthesizer 语音合成服务，输出 MP3
+type Synthesizer interface {
+	// Name 返回 "类型/音色"，用于日志
+	Name() string
+	// Synthesize 把文本合成为 MP3
+	Synthesize(ctx context.Context, text string) ([]byte, error)
+}
+
+// NewSynthesizer 根据配置创建语音合成服务，未配置 TTS_PROVIDER 时返回 nil
+func NewSynthesizer(cfg *config.Config) (Synthesizer, error) {
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+	switch strings.ToLower(cfg.TTSProvider) {
+	case "":
+		return nil, nil
+	case ProviderOpenAI:
+		return &openAISynthesizer{
+			baseURL:    strings.TrimRight(cfg.TTSAPIBaseURL, "/"),
+			apiKey:     cfg.TTSAPIKey,
+			model:      cfg.TTSModel,
+			voice:      cfg.TTSVoice,
+			httpClient: httpClient,
+		}, nil
+	case ProviderAzure:
+		return &azureSynthesizer{
+			baseURL:    strings.TrimRight(cfg.TTSAPIBaseURL, "/"),
+			apiKey:     cfg.TTSAPIKey,
+			voice:      cfg.TTSVoice,
+			httpClient: httpClient,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported TTS provider %q", cfg.TTSProvider)
+	}
+}
+
+// openAISynthesizer 调用 OpenAI /audio/speech 接口
+type openAISynthesizer struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	voice      string
+	httpClient *http.Client
+}
+
+// Name 返回 "openai/音色"
+func (s *openAISynthesizer) Name() string {
+	return ProviderOpenAI + "/" + s.voice
+}
+
+// Synthesize 把文本合成为 MP3
+func (s *openAISynthesizer) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{
+		"model":           s.model,
+		"voice":           s.voice,
+		"input":           text,
+		"response_format": "mp3",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/audio/speech", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+	return doSynthesis(s.httpClient, req)
+}
+
+// azureSynthesizer 调用 Azure 语音服务的 REST 接口
+type azureSynthesizer struct {
+	baseURL    string
+	apiKey     string
+	voice      string
+	httpClient *http.Client
+}
+
+// Name 返回 "azure/音色"
+func (s *azureSynthesizer) Name() string {
+	return ProviderAzure + "/" + s.voice
+}
+
+// Synthesize 把文本合成为 MP3
+func (s *azureSynthesizer) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	var escaped bytes.Buffer
+	if err := xml.EscapeText(&escaped, []byte(text)); err != nil {
+		return nil, fmt.Errorf("failed to escape text: %w", err)
+	}
+	// 语言从音色名称中获取，如 zh-CN-XiaoxiaoNeural 为 zh-CN
+	lang := "zh-CN"
+	if parts := strings.SplitN(s.voice, "-", 3); len(parts) == 3 {
+		lang = parts[0] + "-" + parts[1]
+	}
+	ssml := fmt.Sprintf(`<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="%s"><voice name="%s">%s</voice></speak>`,
+		lang, s.voice, escaped.String())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/cognitiveservices/v1", strings.NewReader(ssml))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/ssml+xml")
+	req.Header.Set("X-Microsoft-OutputFormat", "audio-24khz-48kbitrate-mono-mp3")
+	req.Header.Set("Ocp-Apim-Subscription-Key", s.apiKey)
+	req.Header.Set("User-Agent", "Bt1QFM")
+	return doSynthesis(s.httpClient, req)
+}
+
+// doSynthesis 发送请求并读取音频
+func doSynthesis(httpClient *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	audio, err := io.ReadAll(io.LimitReader(resp.Body, maxClipBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio: %w", err)
+	}
+	if len(audio) > maxClipBytes {
+		return nil, fmt.Errorf("synthesized audio exceeds %d bytes", maxClipBytes)
+	}
+	if len(audio) == 0 {
+		return nil, fmt.Errorf("empty audio in response")
+	}
+	return audio, nil
+}
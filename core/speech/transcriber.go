@@ -0,0 +1,74 @@
+package speech
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"Bt1QFM/config"
+)
+
+// Supported speech-to-text providers.
+const (
+	ProviderOpenAI     = "openai"     // OpenAI Whisper API 及兼容接口（faster-whisper-server 等）
+	ProviderWhisperCpp = "whispercpp" // whisper.cpp 自带的 HTTP server
+)
+
+// AudioFormats 支持的语音格式，对应浏览器 MediaRecorder 和移动端常见的录音格式
+var AudioFormats = map[string]string{
+	"webm": "audio/webm",
+	"ogg":  "audio/ogg",
+	"mp3":  "audio/mpeg",
+	"wav":  "audio/wav",
+	"m4a":  "audio/mp4",
+}
+
+// Transcriber 语音识别服务
+type Transcriber interface {
+	// Name 返回 "类型/模型"，用于日志
+	Name() string
+	// Transcribe 识别一段语音，format 为 AudioFormats 中的格式
+	Transcribe(ctx context.Context, audio []byte, format string) (string, error)
+}
+
+// NewTranscriber 根据配置创建语音识别服务，未配置 STT_PROVIDER 时返回 nil
+func NewTranscriber(cfg *config.Config) (Transcriber, error) {
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+	switch strings.ToLower(cfg.STTProvider) {
+	case "":
+		return nil, nil
+	case ProviderOpenAI:
+		return &openAITranscriber{
+			baseURL:    strings.TrimRight(cfg.STTAPIBaseURL, "/"),
+			apiKey:     cfg.STTAPIKey,
+			model:      cfg.STTModel,
+			language:   cfg.STTLanguage,
+			httpClient: httpClient,
+		}, nil
+	case ProviderWhisperCpp:
+		return &whisperCppTranscriber{
+			baseURL:    strings.TrimRight(cfg.STTAPIBaseURL, "/"),
+			language:   cfg.STTLanguage,
+			httpClient: httpClient,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported STT provider %q", cfg.STTProvider)
+	}
+}
+
+// audioFileName 上传时使用的文件名，服务端根据扩展名识别格式
+func audioFileName(format string) string {
+	return "voice." + format
+}
+
+// checkResponse 非 200 响应转换为错误
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+}
@@ -0,0 +1,125 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// openAITranscriber 调用 OpenAI /audio/transcriptions 接口
+type openAITranscriber struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	language   string
+	httpClient *http.Client
+}
+
+// Name 返回 "openai/模型"
+func (t *openAITranscriber) Name() string {
+	return ProviderOpenAI + "/" + t.model
+}
+
+// Transcribe 上传语音并返回识别出的文本
+func (t *openAITranscriber) Transcribe(ctx context.Context, audio []byte, format string) (string, error) {
+	fields := map[string]string{"model": t.model, "response_format": "json"}
+	if t.language != "" {
+		fields["language"] = t.language
+	}
+	body, contentType, err := multipartAudio(audio, format, fields)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/audio/transcriptions", body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+	return doTranscription(t.httpClient, req)
+}
+
+// whisperCppTranscriber 调用 whisper.cpp server 的 /inference 接口
+type whisperCppTranscriber struct {
+	baseURL    string
+	language   string
+	httpClient *http.Client
+}
+
+// Name 返回 "whispercpp"，模型由 whisper.cpp server 启动参数决定
+func (t *whisperCppTranscriber) Name() string {
+	return ProviderWhisperCpp
+}
+
+// Transcribe 上传语音并返回识别出的文本
+// whisper.cpp 需要以 --convert 启动才能识别 wav 以外的格式
+func (t *whisperCppTranscriber) Transcribe(ctx context.Context, audio []byte, format string) (string, error) {
+	fields := map[string]string{"response_format": "json", "temperature": "0"}
+	if t.language != "" {
+		fields["language"] = t.language
+	}
+	body, contentType, err := multipartAudio(audio, format, fields)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/inference", body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	return doTranscription(t.httpClient, req)
+}
+
+// multipartAudio 构造包含语音文件和表单字段的 multipart 请求体
+func multipartAudio(audio []byte, format string, fields map[string]string) (*bytes.Buffer, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, audioFileName(format)))
+	header.Set("Content-Type", AudioFormats[format])
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(audio); err != nil {
+		return nil, "", fmt.Errorf("failed to write audio: %w", err)
+	}
+	for k, v := range fields {
+		if err := writer.WriteField(k, v); err != nil {
+			return nil, "", fmt.Errorf("failed to write field %s: %w", k, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+	return &body, writer.FormDataContentType(), nil
+}
+
+// doTranscription 发送请求并解析 {"text": "..."} 响应，两种服务的响应格式相同
+func doTranscription(httpClient *http.Client, req *http.Request) (string, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
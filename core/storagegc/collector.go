@@ -0,0 +1,319 @@
+package storagegc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+	"Bt1QFM/repository"
+	"Bt1QFM/storage"
+)
+
+const (
+	// gracePeriod 新建时间在此范围内的对象不回收，避免误删处理中的上传
+	gracePeriod = 2 * time.Hour
+	// maxReportItems 报告中列出的最大条目数
+	maxReportItems = 200
+	// pendingUploadPrefix 客户端预签名直传后未确认的对象前缀
+	pendingUploadPrefix = "uploads/pending/"
+)
+
+// tempPrefixes 系统临时目录中由本服务创建的文件前缀
+var tempPrefixes = []string{"upload-", "album-upload-", "regenerate-", "fingerprint-", "cover-", "preheat_", "netease_", "stream-", "loadtest-"}
+
+// Item 一个可回收的存储条目
+type Item struct {
+	Kind string `json:"kind"` // audio / stream / pending / local
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Report 一次垃圾回收的结果
+type Report struct {
+	DryRun           bool      `json:"dryRun"`
+	StartedAt        time.Time `json:"startedAt"`
+	DurationMs       int64     `json:"durationMs"`
+	AudioObjects     int       `json:"audioObjects"`
+	Streams          int       `json:"streams"`
+	PendingUploads   int       `json:"pendingUploads"`
+	LocalPaths       int       `json:"localPaths"`
+	ReclaimableBytes int64     `json:"reclaimableBytes"`
+	Items            []Item    `json:"items"`
+	Truncated        bool      `json:"truncated"`
+	Errors           []string  `json:"errors,omitempty"`
+}
+
+// add 记录一个可回收条目
+func (r *Report) add(item Item) {
+	r.ReclaimableBytes += item.Size
+	if len(r.Items) < maxReportItems {
+		r.Items = append(r.Items, item)
+	} else {
+		r.Truncated = true
+	}
+}
+
+// fail 记录一个非致命错误
+func (r *Report) fail(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	r.Errors = append(r.Errors, msg)
+	logger.Warn("存储回收出错", logger.String("error", msg))
+}
+
+// Collector 存储垃圾回收器
+// 删除没有曲目引用的源音频和 HLS 流，以及过期的本地临时文件
+// 回收站中的曲目仍视为引用，其文件在回收站清理时释放
+type Collector struct {
+	trackRepo repository.TrackRepository
+	cfg       *config.Config
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewCollector 创建存储垃圾回收器
+func NewCollector(trackRepo repository.TrackRepository, cfg *config.Config) *Collector {
+	return &Collector{
+		trackRepo: trackRepo,
+		cfg:       cfg,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Start 按配置的间隔定期执行回收，间隔为 0 时不启动
+func (c *Collector) Start() {
+	if c.cfg.StorageGCIntervalHours <= 0 {
+		logger.Info("存储自动回收未启用")
+		return
+	}
+	interval := time.Duration(c.cfg.StorageGCIntervalHours) * time.Hour
+	logger.Info("存储回收服务启动", logger.Duration("interval", interval))
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stopChan:
+				return
+			case <-ticker.C:
+				if _, err := c.Run(context.Background(), false); err != nil {
+					logger.Warn("定期存储回收失败", logger.ErrorField(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止定期回收
+func (c *Collector) Stop() {
+	close(c.stopChan)
+	c.wg.Wait()
+}
+
+// ErrAlreadyRunning 已有回收任务在执行
+var ErrAlreadyRunning = fmt.Errorf("storage gc is already running")
+
+// Run 执行一次回收，dryRun 为 true 时只统计可回收的空间不删除
+func (c *Collector) Run(ctx context.Context, dryRun bool) (*Report, error) {
+	if !c.running.TryLock() {
+		return nil, ErrAlreadyRunning
+	}
+	defer c.running.Unlock()
+
+	report := &Report{DryRun: dryRun, StartedAt: time.Now(), Items: make([]Item, 0)}
+
+	tracks, err := c.trackRepo.GetTrackStorageRefs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取曲目存储引用失败: %w", err)
+	}
+	liveFiles := make(map[string]bool, len(tracks))
+	liveStreams := make(map[string]bool, len(tracks))
+	for _, t := range tracks {
+		if t.FilePath != "" {
+			liveFiles[strings.TrimPrefix(t.FilePath, "/static/")] = true
+		}
+		// 旧数据的流ID为曲目ID，按内容哈希存储的流ID取自播放列表路径
+		liveStreams[t.StreamID()] = true
+	}
+
+	if store := storage.GetStorage(); store != nil {
+		c.collectAudio(ctx, store, liveFiles, report)
+		c.collectStreams(ctx, store, liveStreams, report)
+		c.collectPending(ctx, store, report)
+	} else {
+		report.fail("storage not initialized")
+	}
+	c.collectLocal(filepath.Join(c.cfg.StaticDir, "temp", "streams"), nil, report)
+	c.collectLocal(os.TempDir(), tempPrefixes, report)
+
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	logger.Info("存储回收完成",
+		logger.Bool("dryRun", dryRun),
+		logger.Int("audioObjects", report.AudioObjects),
+		logger.Int("streams", report.Streams),
+		logger.Int("pendingUploads", report.PendingUploads),
+		logger.Int("localPaths", report.LocalPaths),
+		logger.Int64("reclaimableBytes", report.ReclaimableBytes))
+	return report, nil
+}
+
+// collectAudio 回收没有有效曲目引用的源音频对象
+func (c *Collector) collectAudio(ctx context.Context, store storage.Storage, live map[string]bool, report *Report) {
+	objects, err := store.List(ctx, "audio/")
+	if err != nil {
+		report.fail("列出源音频失败: %v", err)
+		return
+	}
+	for _, object := range objects {
+		if live[object.Key] || time.Since(object.LastModified) < gracePeriod {
+			continue
+		}
+		report.AudioObjects++
+		report.add(Item{Kind: "audio", Path: object.Key, Size: object.Size})
+		if report.DryRun {
+			continue
+		}
+		if err := store.Delete(ctx, object.Key); err != nil {
+			report.fail("删除源音频 %s 失败: %v", object.Key, err)
+		}
+	}
+}
+
+// collectPending 回收预签名直传后一直未确认的对象
+func (c *Collector) collectPending(ctx context.Context, store storage.Storage, report *Report) {
+	objects, err := store.List(ctx, pendingUploadPrefix)
+	if err != nil {
+		report.fail("列出待确认上传失败: %v", err)
+		return
+	}
+	for _, object := range objects {
+		if time.Since(object.LastModified) < gracePeriod {
+			continue
+		}
+		report.PendingUploads++
+		report.add(Item{Kind: "pending", Path: object.Key, Size: object.Size})
+		if report.DryRun {
+			continue
+		}
+		if err := store.Delete(ctx, object.Key); err != nil {
+			report.fail("删除待确认上传 %s 失败: %v", object.Key, err)
+		}
+	}
+}
+
+// collectStreams 回收没有有效曲目引用的 HLS 流，网易云缓存流不在回收范围内
+func (c *Collector) collectStreams(ctx context.Context, store storage.Storage, live map[string]bool, report *Report) {
+	type orphan struct {
+		keys   []string
+		size   int64
+		recent bool
+	}
+	orphans := make(map[string]*orphan)
+	var order []string
+
+	objects, err := store.List(ctx, "streams/")
+	if err != nil {
+		report.fail("列出流文件失败: %v", err)
+		return
+	}
+	for _, object := range objects {
+		streamID, _, ok := strings.Cut(strings.TrimPrefix(object.Key, "streams/"), "/")
+		if !ok || streamID == "" || streamID == "netease" || live[streamID] {
+			continue
+		}
+		o := orphans[streamID]
+		if o == nil {
+			o = &orphan{}
+			orphans[streamID] = o
+			order = append(order, streamID)
+		}
+		o.keys = append(o.keys, object.Key)
+		o.size += object.Size
+		if time.Since(object.LastModified) < gracePeriod {
+			o.recent = true
+		}
+	}
+
+	for _, streamID := range order {
+		o := orphans[streamID]
+		if o.recent {
+			continue
+		}
+		report.Streams++
+		report.add(Item{Kind: "stream", Path: "streams/" + streamID + "/", Size: o.size})
+		if report.DryRun {
+			continue
+		}
+		for _, key := range o.keys {
+			if err := store.Delete(ctx, key); err != nil {
+				report.fail("删除流文件 %s 失败: %v", key, err)
+			}
+		}
+		if err := cache.DeleteSegmentPattern("segment:" + streamID + ":*"); err != nil {
+			report.fail("清理流 %s 的分片缓存失败: %v", streamID, err)
+		}
+	}
+}
+
+// collectLocal 回收目录下超过保留期的条目，prefixes 不为空时只处理匹配前缀的条目
+func (c *Collector) collectLocal(dir string, prefixes []string, report *Report) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			report.fail("读取目录 %s 失败: %v", dir, err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if len(prefixes) > 0 && !hasAnyPrefix(entry.Name(), prefixes) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < gracePeriod {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		report.LocalPaths++
+		report.add(Item{Kind: "local", Path: path, Size: pathSize(path)})
+		if report.DryRun {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			report.fail("删除本地文件 %s 失败: %v", path, err)
+		}
+	}
+}
+
+// hasAnyPrefix 判断名称是否以任一前缀开头
+func hasAnyPrefix(name string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathSize 计算文件或目录的总大小
+func pathSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,278 @@
+package storagegc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+	"Bt1QFM/storage"
+)
+
+// 对账发现的问题
+const (
+	ProblemSourceMissing   = "source_missing"   // 源音频丢失，流仍可播放，无法重新转码
+	ProblemStreamMissing   = "stream_missing"   // 已完成的曲目流丢失，源音频仍在
+	ProblemStuckProcessing = "stuck_processing" // 长时间停留在转码中，源音频仍在
+	ProblemOrphan          = "orphan"           // 源音频和流都不存在
+)
+
+// 对账采取的处理
+const (
+	ActionFlagged         = "flagged"          // 只记录，无法自动修复
+	ActionRepairScheduled = "repair_scheduled" // 已安排从源音频重新转码
+	ActionMarkedFailed    = "marked_failed"    // 标记为转码失败
+	ActionFailed          = "failed"           // 处理出错，见 Errors
+	ActionNone            = "none"             // 演练模式，未做处理
+)
+
+// RepairFunc 从源音频重新生成曲目的流，由服务层提供，应尽快返回并在后台完成转码
+type RepairFunc func(ctx context.Context, track *model.Track) error
+
+// ReconcileItem 一个存储对象缺失的曲目
+type ReconcileItem struct {
+	TrackID int64  `json:"trackId"`
+	UserID  int64  `json:"userId"`
+	Status  string `json:"status"`
+	Problem string `json:"problem"`
+	Action  string `json:"action"`
+}
+
+// ReconcileReport 一次对账的结果
+type ReconcileReport struct {
+	DryRun          bool            `json:"dryRun"`
+	StartedAt       time.Time       `json:"startedAt"`
+	DurationMs      int64           `json:"durationMs"`
+	Checked         int             `json:"checked"`
+	SourceMissing   int             `json:"sourceMissing"`
+	StreamMissing   int             `json:"streamMissing"`
+	StuckProcessing int             `json:"stuckProcessing"`
+	Orphans         int             `json:"orphans"`
+	Items           []ReconcileItem `json:"items"`
+	Truncated       bool            `json:"truncated"`
+	Errors          []string        `json:"errors,omitempty"`
+}
+
+// add 记录一个问题曲目
+func (r *ReconcileReport) add(item ReconcileItem) {
+	switch item.Problem {
+	case ProblemSourceMissing:
+		r.SourceMissing++
+	case ProblemStreamMissing:
+		r.StreamMissing++
+	case ProblemStuckProcessing:
+		r.StuckProcessing++
+	case ProblemOrphan:
+		r.Orphans++
+	}
+	if len(r.Items) < maxReportItems {
+		r.Items = append(r.Items, item)
+	} else {
+		r.Truncated = true
+	}
+}
+
+// fail 记录一个非致命错误
+func (r *ReconcileReport) fail(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	r.Errors = append(r.Errors, msg)
+	logger.Warn("存储对账出错", logger.String("error", msg))
+}
+
+// Reconciler 曲目记录与存储对象的对账
+// 与 Collector 方向相反：找出记录仍在但存储对象已丢失的曲目。源音频和流都不存在的曲目标记为转码失败；
+// 源音频仍在但流丢失或转码中断的曲目交给 RepairFunc 重新转码；只丢失源音频的曲目仍可播放，只记录
+type Reconciler struct {
+	trackRepo repository.TrackRepository
+	cfg       *config.Config
+	repair    RepairFunc
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewReconciler 创建存储对账器，repair 为 nil 时只记录可修复的曲目
+func NewReconciler(trackRepo repository.TrackRepository, cfg *config.Config, repair RepairFunc) *Reconciler {
+	return &Reconciler{
+		trackRepo: trackRepo,
+		cfg:       cfg,
+		repair:    repair,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Start 按配置的间隔定期对账，间隔为 0 时不启动
+func (r *Reconciler) Start() {
+	if r.cfg.StorageReconcileIntervalHours <= 0 {
+		logger.Info("存储自动对账未启用")
+		return
+	}
+	interval := time.Duration(r.cfg.StorageReconcileIntervalHours) * time.Hour
+	logger.Info("存储对账服务启动", logger.Duration("interval", interval))
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stopChan:
+				return
+			case <-ticker.C:
+				if _, err := r.Run(context.Background(), false); err != nil {
+					logger.Warn("定期存储对账失败", logger.ErrorField(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止定期对账
+func (r *Reconciler) Stop() {
+	close(r.stopChan)
+	r.wg.Wait()
+}
+
+// Run 执行一次对账，dryRun 为 true 时只报告问题不做处理
+// 最近 gracePeriod 内更新过的曲目可能仍在上传或转码，不参与对账
+func (r *Reconciler) Run(ctx context.Context, dryRun bool) (*ReconcileReport, error) {
+	if !r.running.TryLock() {
+		return nil, ErrAlreadyRunning
+	}
+	defer r.running.Unlock()
+
+	store := storage.GetStorage()
+	if store == nil {
+		return nil, fmt.Errorf("storage not initialized")
+	}
+
+	tracks, err := r.trackRepo.GetLiveTrackStorageStates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取曲目存储状态失败: %w", err)
+	}
+	// 列表失败时无法区分对象丢失和读取出错，放弃本次对账，避免误判
+	audioObjects, err := listKeys(ctx, store, "audio/")
+	if err != nil {
+		return nil, fmt.Errorf("列出源音频失败: %w", err)
+	}
+	streamObjects, err := listKeys(ctx, store, "streams/")
+	if err != nil {
+		return nil, fmt.Errorf("列出流文件失败: %w", err)
+	}
+
+	report := &ReconcileReport{DryRun: dryRun, StartedAt: time.Now(), Items: make([]ReconcileItem, 0)}
+	for _, track := range tracks {
+		if time.Since(track.UpdatedAt) < gracePeriod || track.Status == "failed" {
+			continue
+		}
+		report.Checked++
+
+		sourceOK := track.FilePath == "" || r.sourceExists(ctx, store, audioObjects, track.FilePath, report)
+		streamOK := track.HLSPlaylistPath != "" && r.streamExists(streamObjects, track.StreamID())
+
+		var problem string
+		switch {
+		case !sourceOK && !streamOK:
+			problem = ProblemOrphan
+		case !sourceOK:
+			problem = ProblemSourceMissing
+		case !streamOK && track.Status == "processing":
+			problem = ProblemStuckProcessing
+		case !streamOK && track.FilePath != "":
+			problem = ProblemStreamMissing
+		default:
+			continue
+		}
+
+		item := ReconcileItem{TrackID: track.ID, UserID: track.UserID, Status: track.Status, Problem: problem, Action: ActionNone}
+		if !dryRun {
+			item.Action = r.fix(ctx, track, problem, report)
+		}
+		report.add(item)
+	}
+
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	logger.Info("存储对账完成",
+		logger.Bool("dryRun", dryRun),
+		logger.Int("checked", report.Checked),
+		logger.Int("sourceMissing", report.SourceMissing),
+		logger.Int("streamMissing", report.StreamMissing),
+		logger.Int("stuckProcessing", report.StuckProcessing),
+		logger.Int("orphans", report.Orphans))
+	return report, nil
+}
+
+// fix 处理一个问题曲目，返回采取的处理
+func (r *Reconciler) fix(ctx context.Context, track *model.Track, problem string, report *ReconcileReport) string {
+	switch problem {
+	case ProblemOrphan:
+		if err := r.trackRepo.UpdateTrackStatus(ctx, track.ID, "failed"); err != nil {
+			report.fail("标记曲目 %d 为失败出错: %v", track.ID, err)
+			return ActionFailed
+		}
+		logger.Warn("曲目的源音频和流都已丢失，已标记为失败", logger.Int64("trackId", track.ID))
+		return ActionMarkedFailed
+	case ProblemStreamMissing, ProblemStuckProcessing:
+		if r.repair == nil {
+			return ActionFlagged
+		}
+		if err := r.repair(ctx, track); err != nil {
+			report.fail("重新转码曲目 %d 出错: %v", track.ID, err)
+			return ActionFailed
+		}
+		return ActionRepairScheduled
+	default:
+		logger.Warn("曲目的源音频已丢失，无法重新转码", logger.Int64("trackId", track.ID), logger.String("path", track.FilePath))
+		return ActionFlagged
+	}
+}
+
+// sourceExists 判断源音频是否存在，不在 audio/ 下的旧数据单独查询
+func (r *Reconciler) sourceExists(ctx context.Context, store storage.Storage, audioObjects map[string]bool, filePath string, report *ReconcileReport) bool {
+	key := strings.TrimPrefix(filePath, "/static/")
+	if strings.HasPrefix(key, "audio/") {
+		return audioObjects[key]
+	}
+	_, err := store.Stat(ctx, key)
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		// 无法确认时按存在处理
+		report.fail("查询源音频 %s 失败: %v", key, err)
+		return true
+	}
+	return false
+}
+
+// streamExists 判断流的播放列表是否在对象存储或本地临时目录中
+func (r *Reconciler) streamExists(streamObjects map[string]bool, streamID string) bool {
+	if streamObjects["streams/"+streamID+"/playlist.m3u8"] {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(r.cfg.StaticDir, "temp", "streams", streamID, "playlist.m3u8"))
+	return err == nil
+}
+
+// listKeys 列出前缀下的全部对象键
+func listKeys(ctx context.Context, store storage.Storage, prefix string) (map[string]bool, error) {
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		keys[object.Key] = true
+	}
+	return keys, nil
+}
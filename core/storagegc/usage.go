@@ -0,0 +1,124 @@
+package storagegc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"Bt1QFM/storage"
+)
+
+// UserUsage 单个用户曲目占用的存储空间
+// 按内容哈希共享的流计入每个引用它的用户，因此各用户之和可能大于实际占用
+type UserUsage struct {
+	UserID      int64 `json:"userId"`
+	Tracks      int   `json:"tracks"`
+	AudioBytes  int64 `json:"audioBytes"`
+	StreamBytes int64 `json:"streamBytes"`
+	TotalBytes  int64 `json:"totalBytes"`
+}
+
+// UsageReport 对象存储的占用统计，按对象实际大小计算
+type UsageReport struct {
+	GeneratedAt       time.Time   `json:"generatedAt"`
+	DurationMs        int64       `json:"durationMs"`
+	AudioBytes        int64       `json:"audioBytes"`        // audio/ 下的源音频
+	StreamBytes       int64       `json:"streamBytes"`       // streams/ 下的曲目 HLS 流
+	NeteaseBytes      int64       `json:"neteaseBytes"`      // streams/netease/ 下的网易云缓存流
+	UnreferencedBytes int64       `json:"unreferencedBytes"` // 没有曲目引用的源音频和流，等待回收
+	Users             []UserUsage `json:"users"`             // 按占用从大到小排列
+}
+
+// Usage 统计每个用户的曲目占用的存储空间
+func (c *Collector) Usage(ctx context.Context) (*UsageReport, error) {
+	store := storage.GetStorage()
+	if store == nil {
+		return nil, fmt.Errorf("storage not initialized")
+	}
+	report := &UsageReport{GeneratedAt: time.Now(), Users: make([]UserUsage, 0)}
+
+	tracks, err := c.trackRepo.GetTrackStorageRefs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取曲目存储引用失败: %w", err)
+	}
+
+	audioObjects, err := store.List(ctx, "audio/")
+	if err != nil {
+		return nil, fmt.Errorf("列出源音频失败: %w", err)
+	}
+	audioSizes := make(map[string]int64, len(audioObjects))
+	for _, object := range audioObjects {
+		audioSizes[object.Key] = object.Size
+		report.AudioBytes += object.Size
+	}
+
+	streamObjects, err := store.List(ctx, "streams/")
+	if err != nil {
+		return nil, fmt.Errorf("列出流文件失败: %w", err)
+	}
+	streamSizes := make(map[string]int64)
+	for _, object := range streamObjects {
+		streamID, _, ok := strings.Cut(strings.TrimPrefix(object.Key, "streams/"), "/")
+		if !ok || streamID == "" {
+			continue
+		}
+		if streamID == "netease" {
+			report.NeteaseBytes += object.Size
+			continue
+		}
+		streamSizes[streamID] += object.Size
+		report.StreamBytes += object.Size
+	}
+
+	users := make(map[int64]*UserUsage)
+	userStreams := make(map[int64]map[string]bool)
+	referencedAudio := make(map[string]bool, len(tracks))
+	referencedStreams := make(map[string]bool, len(tracks))
+	for _, t := range tracks {
+		u := users[t.UserID]
+		if u == nil {
+			u = &UserUsage{UserID: t.UserID}
+			users[t.UserID] = u
+			userStreams[t.UserID] = make(map[string]bool)
+		}
+		// 被替换的版本只占用存储，不计入曲目数
+		if t.Status != "version" {
+			u.Tracks++
+		}
+
+		if key := strings.TrimPrefix(t.FilePath, "/static/"); key != "" && !referencedAudio[key] {
+			referencedAudio[key] = true
+			u.AudioBytes += audioSizes[key]
+		}
+		streamID := t.StreamID()
+		referencedStreams[streamID] = true
+		if !userStreams[t.UserID][streamID] {
+			userStreams[t.UserID][streamID] = true
+			u.StreamBytes += streamSizes[streamID]
+		}
+	}
+
+	for key, size := range audioSizes {
+		if !referencedAudio[key] {
+			report.UnreferencedBytes += size
+		}
+	}
+	for streamID, size := range streamSizes {
+		if !referencedStreams[streamID] {
+			report.UnreferencedBytes += size
+		}
+	}
+
+	for _, u := range users {
+		u.TotalBytes = u.AudioBytes + u.StreamBytes
+		report.Users = append(report.Users, *u)
+	}
+	sort.Slice(report.Users, func(i, j int) bool {
+		return report.Users[i].TotalBytes > report.Users[j].TotalBytes
+	})
+
+	report.DurationMs = time.Since(report.GeneratedAt).Milliseconds()
+	return report, nil
+}
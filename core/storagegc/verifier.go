@@ -0,0 +1,428 @@
+package storagegc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+	"Bt1QFM/storage"
+)
+
+// 完整性校验发现的问题
+const (
+	IssueSourceMissing    = "source_missing"    // 源音频不存在
+	IssuePlaylistMissing  = "playlist_missing"  // 播放列表不存在
+	IssuePlaylistInvalid  = "playlist_invalid"  // 播放列表无法解析或不完整
+	IssueSegmentMissing   = "segment_missing"   // 播放列表引用的分片不存在或为空
+	IssueDurationMismatch = "duration_mismatch" // 分片总时长与曲目时长不符
+)
+
+const (
+	// durationTolerance 分片总时长与曲目时长允许的最小误差（秒）
+	durationTolerance = 2.0
+	// durationToleranceRatio 分片总时长与曲目时长允许的相对误差
+	durationToleranceRatio = 0.02
+	// maxMissingSegments 结果中列出的最大缺失分片数
+	maxMissingSegments = 20
+)
+
+// VerifyResult 一首曲目的完整性校验结果
+type VerifyResult struct {
+	TrackID          int64     `json:"trackId"`
+	StreamID         string    `json:"streamId"`
+	Healthy          bool      `json:"healthy"`
+	Issues           []string  `json:"issues"`
+	Segments         int       `json:"segments"`
+	MissingSegments  []string  `json:"missingSegments,omitempty"`
+	PlaylistDuration float64   `json:"playlistDuration"`
+	TrackDuration    float64   `json:"trackDuration"`
+	Action           string    `json:"action"`
+	CheckedAt        time.Time `json:"checkedAt"`
+}
+
+// hasIssue 判断是否发现了指定问题
+func (v *VerifyResult) hasIssue(issue string) bool {
+	for _, i := range v.Issues {
+		if i == issue {
+			return true
+		}
+	}
+	return false
+}
+
+// streamBroken 判断流是否损坏，需要从源音频重新转码
+func (v *VerifyResult) streamBroken() bool {
+	return len(v.Issues) > 0 && !(len(v.Issues) == 1 && v.Issues[0] == IssueSourceMissing)
+}
+
+// VerifyReport 一次定期校验的结果，只列出有问题的曲目
+type VerifyReport struct {
+	DryRun     bool            `json:"dryRun"`
+	StartedAt  time.Time       `json:"startedAt"`
+	DurationMs int64           `json:"durationMs"`
+	Checked    int             `json:"checked"`
+	Corrupted  int             `json:"corrupted"`
+	Items      []*VerifyResult `json:"items"`
+	Truncated  bool            `json:"truncated"`
+	Errors     []string        `json:"errors,omitempty"`
+}
+
+// fail 记录一个非致命错误
+func (r *VerifyReport) fail(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	r.Errors = append(r.Errors, msg)
+	logger.Warn("曲目完整性校验出错", logger.String("error", msg))
+}
+
+// Verifier 曲目完整性校验
+// 检查源音频是否存在、HLS 播放列表能否解析、引用的分片是否都在对象存储中以及分片总时长是否与曲目时长一致；
+// 流损坏且源音频仍在的曲目交给 RepairFunc 重新转码，源音频也已丢失的标记为转码失败
+type Verifier struct {
+	trackRepo repository.TrackRepository
+	cfg       *config.Config
+	repair    RepairFunc
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewVerifier 创建曲目完整性校验器，repair 为 nil 时只报告损坏的曲目
+func NewVerifier(trackRepo repository.TrackRepository, cfg *config.Config, repair RepairFunc) *Verifier {
+	return &Verifier{
+		trackRepo: trackRepo,
+		cfg:       cfg,
+		repair:    repair,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Start 按配置的间隔定期校验全部曲目，间隔为 0 时不启动
+func (v *Verifier) Start() {
+	if v.cfg.TrackVerifyIntervalHours <= 0 {
+		logger.Info("曲目定期完整性校验未启用")
+		return
+	}
+	interval := time.Duration(v.cfg.TrackVerifyIntervalHours) * time.Hour
+	logger.Info("曲目完整性校验服务启动", logger.Duration("interval", interval))
+
+	v.wg.Add(1)
+	go func() {
+		defer v.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-v.stopChan:
+				return
+			case <-ticker.C:
+				if _, err := v.Run(context.Background(), false); err != nil {
+					logger.Warn("定期曲目完整性校验失败", logger.ErrorField(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止定期校验
+func (v *Verifier) Stop() {
+	close(v.stopChan)
+	v.wg.Wait()
+}
+
+// Run 校验全部已转码完成的曲目，dryRun 为 true 时只报告问题不做处理
+// 共享同一个流的曲目只检查一次，损坏时只安排一次重新转码
+func (v *Verifier) Run(ctx context.Context, dryRun bool) (*VerifyReport, error) {
+	if !v.running.TryLock() {
+		return nil, ErrAlreadyRunning
+	}
+	defer v.running.Unlock()
+
+	store := storage.GetStorage()
+	if store == nil {
+		return nil, fmt.Errorf("storage not initialized")
+	}
+	tracks, err := v.trackRepo.GetLiveTrackStorageStates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取曲目存储状态失败: %w", err)
+	}
+
+	report := &VerifyReport{DryRun: dryRun, StartedAt: time.Now(), Items: make([]*VerifyResult, 0)}
+	repaired := make(map[string]bool)
+	for _, track := range tracks {
+		select {
+		case <-v.stopChan:
+			report.fail("服务停止，校验中断")
+			return report, nil
+		default:
+		}
+		if track.Status != "completed" || track.HLSPlaylistPath == "" || time.Since(track.UpdatedAt) < gracePeriod {
+			continue
+		}
+
+		result, err := v.check(ctx, store, track)
+		if err != nil {
+			report.fail("校验曲目 %d 出错: %v", track.ID, err)
+			continue
+		}
+		report.Checked++
+		if result.Healthy {
+			continue
+		}
+		report.Corrupted++
+
+		switch {
+		case dryRun:
+		case result.streamBroken() && !result.hasIssue(IssueSourceMissing) && repaired[result.StreamID]:
+			// 共享的流已安排重新转码
+			result.Action = ActionRepairScheduled
+		default:
+			result.Action = v.fix(ctx, track, result)
+			if result.Action == ActionRepairScheduled {
+				repaired[result.StreamID] = true
+			}
+		}
+		if len(report.Items) < maxReportItems {
+			report.Items = append(report.Items, result)
+		} else {
+			report.Truncated = true
+		}
+	}
+
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	logger.Info("曲目完整性校验完成",
+		logger.Bool("dryRun", dryRun),
+		logger.Int("checked", report.Checked),
+		logger.Int("corrupted", report.Corrupted))
+	return report, nil
+}
+
+// Verify 校验一首曲目，dryRun 为 true 或曲目正在转码时只报告问题不做处理
+func (v *Verifier) Verify(ctx context.Context, track *model.Track, dryRun bool) (*VerifyResult, error) {
+	store := storage.GetStorage()
+	if store == nil {
+		return nil, fmt.Errorf("storage not initialized")
+	}
+	result, err := v.check(ctx, store, track)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Healthy && !dryRun && track.Status != "processing" {
+		result.Action = v.fix(ctx, track, result)
+	}
+	logger.Info("曲目完整性校验完成",
+		logger.Int64("trackId", track.ID),
+		logger.Bool("healthy", result.Healthy),
+		logger.Any("issues", result.Issues),
+		logger.String("action", result.Action))
+	return result, nil
+}
+
+// fix 处理一首损坏的曲目，返回采取的处理
+func (v *Verifier) fix(ctx context.Context, track *model.Track, result *VerifyResult) string {
+	switch {
+	case !result.streamBroken():
+		logger.Warn("曲目的源音频已丢失，无法重新转码", logger.Int64("trackId", track.ID), logger.String("path", track.FilePath))
+		return ActionFlagged
+	case result.hasIssue(IssueSourceMissing) || track.FilePath == "":
+		if err := v.trackRepo.UpdateTrackStatus(ctx, track.ID, "failed"); err != nil {
+			logger.Warn("标记曲目为失败出错", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+			return ActionFailed
+		}
+		logger.Warn("曲目的流已损坏且源音频已丢失，已标记为失败", logger.Int64("trackId", track.ID))
+		return ActionMarkedFailed
+	case v.repair == nil:
+		return ActionFlagged
+	default:
+		if err := v.repair(ctx, track); err != nil {
+			logger.Warn("重新转码损坏的曲目出错", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+			return ActionFailed
+		}
+		logger.Warn("曲目的流已损坏，已安排重新转码",
+			logger.Int64("trackId", track.ID),
+			logger.Any("issues", result.Issues))
+		return ActionRepairScheduled
+	}
+}
+
+// check 检查曲目的源音频和流，对象存储出错无法判断时返回错误
+func (v *Verifier) check(ctx context.Context, store storage.Storage, track *model.Track) (*VerifyResult, error) {
+	result := &VerifyResult{
+		TrackID:       track.ID,
+		StreamID:      track.StreamID(),
+		Issues:        make([]string, 0),
+		TrackDuration: float64(track.Duration),
+		Action:        ActionNone,
+		CheckedAt:     time.Now(),
+	}
+
+	if track.FilePath != "" {
+		exists, err := objectExists(ctx, store, strings.TrimPrefix(track.FilePath, "/static/"))
+		if err != nil {
+			return nil, fmt.Errorf("查询源音频失败: %w", err)
+		}
+		if !exists {
+			result.Issues = append(result.Issues, IssueSourceMissing)
+		}
+	}
+
+	if err := v.checkStream(ctx, store, result); err != nil {
+		return nil, err
+	}
+	result.Healthy = len(result.Issues) == 0
+	return result, nil
+}
+
+// checkStream 检查流的播放列表、分片和时长
+func (v *Verifier) checkStream(ctx context.Context, store storage.Storage, result *VerifyResult) error {
+	streamDir := "streams/" + result.StreamID + "/"
+	object, err := store.Get(ctx, streamDir+"playlist.m3u8")
+	if err != nil {
+		if storage.IsNotFound(err) {
+			result.Issues = append(result.Issues, IssuePlaylistMissing)
+			return nil
+		}
+		return fmt.Errorf("读取播放列表失败: %w", err)
+	}
+	playlist, err := parsePlaylist(object)
+	object.Close()
+	if err != nil {
+		logger.Warn("播放列表无法解析", logger.String("streamId", result.StreamID), logger.ErrorField(err))
+		result.Issues = append(result.Issues, IssuePlaylistInvalid)
+		return nil
+	}
+	result.Segments = len(playlist.segments)
+	result.PlaylistDuration = math.Round(playlist.duration*1000) / 1000
+
+	objects, err := store.List(ctx, streamDir)
+	if err != nil {
+		return fmt.Errorf("列出流文件失败: %w", err)
+	}
+	sizes := make(map[string]int64, len(objects))
+	for _, object := range objects {
+		sizes[object.Key] = object.Size
+	}
+	for _, name := range playlist.files() {
+		if size, ok := sizes[streamDir+name]; ok && size > 0 {
+			continue
+		}
+		if len(result.MissingSegments) < maxMissingSegments {
+			result.MissingSegments = append(result.MissingSegments, name)
+		}
+	}
+	if len(result.MissingSegments) > 0 {
+		result.Issues = append(result.Issues, IssueSegmentMissing)
+	}
+
+	// 上传的曲目没有记录时长时不比较
+	if result.TrackDuration > 0 {
+		tolerance := math.Max(durationTolerance, result.TrackDuration*durationToleranceRatio)
+		if math.Abs(playlist.duration-result.TrackDuration) > tolerance {
+			result.Issues = append(result.Issues, IssueDurationMismatch)
+		}
+	}
+	return nil
+}
+
+// hlsPlaylist 解析后的 HLS 播放列表
+type hlsPlaylist struct {
+	initSegment string
+	segments    []string
+	duration    float64
+}
+
+// files 返回播放列表引用的全部文件名
+func (p *hlsPlaylist) files() []string {
+	if p.initSegment == "" {
+		return p.segments
+	}
+	return append([]string{p.initSegment}, p.segments...)
+}
+
+// parsePlaylist 解析转码生成的 HLS 播放列表，缺少 #EXT-X-ENDLIST 的播放列表视为转码未完成
+func parsePlaylist(r io.Reader) (*hlsPlaylist, error) {
+	playlist := &hlsPlaylist{}
+	scanner := bufio.NewScanner(r)
+	first, pendingDuration, ended := true, -1.0, false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if first {
+			if line != "#EXTM3U" {
+				return nil, fmt.Errorf("missing #EXTM3U header")
+			}
+			first = false
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			duration, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || duration < 0 {
+				return nil, fmt.Errorf("invalid #EXTINF duration %q", value)
+			}
+			pendingDuration = duration
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			_, rest, ok := strings.Cut(line, `URI="`)
+			uri, _, closed := strings.Cut(rest, `"`)
+			if !ok || !closed || uri == "" {
+				return nil, fmt.Errorf("invalid #EXT-X-MAP tag")
+			}
+			playlist.initSegment = segmentName(uri)
+		case line == "#EXT-X-ENDLIST":
+			ended = true
+		case strings.HasPrefix(line, "#"):
+		default:
+			if pendingDuration < 0 {
+				return nil, fmt.Errorf("segment %q has no #EXTINF", line)
+			}
+			playlist.segments = append(playlist.segments, segmentName(line))
+			playlist.duration += pendingDuration
+			pendingDuration = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if first {
+		return nil, fmt.Errorf("empty playlist")
+	}
+	if len(playlist.segments) == 0 {
+		return nil, fmt.Errorf("playlist has no segments")
+	}
+	if !ended {
+		return nil, fmt.Errorf("missing #EXT-X-ENDLIST")
+	}
+	return playlist, nil
+}
+
+// segmentName 取分片地址的文件名，去掉查询参数
+func segmentName(uri string) string {
+	uri, _, _ = strings.Cut(uri, "?")
+	return path.Base(uri)
+}
+
+// objectExists 判断对象是否存在
+func objectExists(ctx context.Context, store storage.Storage, key string) (bool, error) {
+	_, err := store.Stat(ctx, key)
+	if err == nil {
+		return true, nil
+	}
+	if storage.IsNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
@@ -0,0 +1,307 @@
+package streak
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/notification"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+const (
+	// MaxGoalMinutes 每日收听目标的上限
+	MaxGoalMinutes = 12 * 60
+	// RecentDays 接口返回的最近天数
+	RecentDays = 30
+	// rollupInterval 汇总收听时长和检查里程碑的间隔
+	rollupInterval = time.Hour
+	// catchUpDays 启动时重新汇总的天数，补上停机期间的收听
+	catchUpDays = 7
+	// pageSize 计算连续天数时每次读取的达标天数
+	pageSize = 400
+)
+
+// Milestones 发送通知的连续达标天数
+var Milestones = []int{3, 7, 14, 30, 100, 365}
+
+// Service 每日收听目标服务：每小时把播放历史汇总为每位用户每天的收听时长，
+// 并为最近有收听的用户更新连续达标天数，达到里程碑时发送通知
+type Service struct {
+	repo          repository.ListeningRepository
+	historyRepo   repository.PlayHistoryRepository
+	userRepo      repository.UserRepository
+	notifications *notification.Service
+	cfg           *config.Config
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Report 一次汇总的结果
+type Report struct {
+	Days          int `json:"days"`          // 汇总的天数
+	Users         int `json:"users"`         // 更新连续天数的用户数
+	Notifications int `json:"notifications"` // 发送的里程碑通知数
+}
+
+// NewService 创建每日收听目标服务
+func NewService(repo repository.ListeningRepository, historyRepo repository.PlayHistoryRepository, userRepo repository.UserRepository, cfg *config.Config) *Service {
+	return &Service{
+		repo:        repo,
+		historyRepo: historyRepo,
+		userRepo:    userRepo,
+		cfg:         cfg,
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// SetNotificationService 设置通知中心，未设置时不发送里程碑通知
+func (s *Service) SetNotificationService(service *notification.Service) {
+	s.notifications = service
+}
+
+// Start 启动时先重新汇总最近 7 天，之后每小时汇总前一天并检查今天的进度
+func (s *Service) Start() {
+	logger.Info("每日收听目标服务启动", logger.Int("goalMinutes", s.cfg.StreakDailyGoalMinutes))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.rollup(catchUpDays)
+		ticker := time.NewTicker(rollupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				s.rollup(1)
+			}
+		}
+	}()
+}
+
+// Stop 停止定时汇总
+func (s *Service) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// GoalMinutes 用户的每日收听目标，未设置时使用服务端默认值
+func (s *Service) GoalMinutes(prefs model.UserPreferences) int {
+	goal := prefs.Listening.DailyGoalMinutes
+	if goal <= 0 {
+		goal = s.cfg.StreakDailyGoalMinutes
+	}
+	return min(max(goal, 1), MaxGoalMinutes)
+}
+
+func (s *Service) rollup(days int) {
+	report, err := s.Rollup(context.Background(), time.Now(), days)
+	if err != nil {
+		logger.Warn("汇总收听时长失败", logger.ErrorField(err))
+		return
+	}
+	logger.Debug("收听时长已汇总",
+		logger.Int("days", report.Days),
+		logger.Int("users", report.Users),
+		logger.Int("notifications", report.Notifications))
+}
+
+// Rollup 汇总 now 之前 days 天的收听时长，再为这段时间内有收听的用户更新连续达标天数
+// 当天的收听时长仍在变化，不写入数据库，计算连续天数时直接从播放历史读取
+func (s *Service) Rollup(ctx context.Context, now time.Time, days int) (*Report, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	today := dayStart(now)
+	report := &Report{}
+	for i := days; i >= 1; i-- {
+		start := today.AddDate(0, 0, -i)
+		if _, err := s.repo.RollupDay(ctx, start.Format(cache.PlayCountDayLayout), start, start.AddDate(0, 0, 1)); err != nil {
+			return report, err
+		}
+		report.Days++
+	}
+
+	userIDs, err := s.historyRepo.GetActiveListeners(ctx, today.AddDate(0, 0, -days))
+	if err != nil {
+		return report, err
+	}
+	for _, userID := range userIDs {
+		select {
+		case <-s.stopChan:
+			return report, nil
+		default:
+		}
+		notified, err := s.update(ctx, userID, now)
+		if err != nil {
+			logger.Warn("更新连续收听天数失败", logger.Int64("userId", userID), logger.ErrorField(err))
+			continue
+		}
+		report.Users++
+		if notified {
+			report.Notifications++
+		}
+	}
+	return report, nil
+}
+
+// update 重新计算用户的连续达标天数并保存，新达到里程碑时发送通知，返回是否发送了通知
+func (s *Service) update(ctx context.Context, userID int64, now time.Time) (bool, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil || user == nil {
+		return false, err
+	}
+	prefs := user.GetPreferences()
+	current, startDay, _, err := s.current(ctx, userID, s.GoalMinutes(prefs), now)
+	if err != nil {
+		return false, err
+	}
+
+	record, err := s.repo.GetStreak(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if record == nil {
+		record = &model.StreakRecord{UserID: userID}
+	}
+	if record.StartDay != startDay {
+		// 连续中断后重新开始，之前通知过的里程碑可以再次通知
+		record.StartDay = startDay
+		record.NotifiedDays = 0
+	}
+	record.CurrentDays = current
+	record.LongestDays = max(record.LongestDays, current)
+
+	milestone := reachedMilestone(current)
+	notify := milestone > record.NotifiedDays
+	if notify {
+		record.NotifiedDays = milestone
+	}
+	if err := s.repo.SaveStreak(ctx, record); err != nil {
+		return false, err
+	}
+	if !notify || prefs.Listening.MuteMilestones || s.notifications == nil {
+		return false, nil
+	}
+	s.notifications.Notify(ctx, &model.Notification{
+		UserID:   userID,
+		Type:     model.NotificationStreak,
+		ObjectID: strconv.Itoa(milestone),
+		Title:    fmt.Sprintf("连续 %d 天达成收听目标", milestone),
+	})
+	logger.Info("连续收听达到里程碑",
+		logger.Int64("userId", userID),
+		logger.Int("days", milestone))
+	return true, nil
+}
+
+// Streak 返回用户的每日收听目标、今天的进度、连续达标天数和最近 30 天的收听时长
+func (s *Service) Streak(ctx context.Context, user *model.User, now time.Time) (*model.ListeningStreak, error) {
+	goal := s.GoalMinutes(user.GetPreferences())
+	current, _, todaySeconds, err := s.current(ctx, user.ID, goal, now)
+	if err != nil {
+		return nil, err
+	}
+	record, err := s.repo.GetStreak(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := dayStart(now)
+	days, err := s.repo.GetListeningDays(ctx, user.ID, today.AddDate(0, 0, 1-RecentDays).Format(cache.PlayCountDayLayout))
+	if err != nil {
+		return nil, err
+	}
+	todayKey := today.Format(cache.PlayCountDayLayout)
+	if len(days) > 0 && days[len(days)-1].Day == todayKey {
+		days = days[:len(days)-1]
+	}
+	days = append(days, model.ListeningDay{Day: todayKey, Seconds: todaySeconds})
+	goalSeconds := int64(goal) * 60
+	for i := range days {
+		days[i].GoalMet = days[i].Seconds >= goalSeconds
+	}
+
+	streak := &model.ListeningStreak{
+		GoalMinutes:   goal,
+		TodaySeconds:  todaySeconds,
+		GoalMet:       todaySeconds >= goalSeconds,
+		CurrentDays:   current,
+		LongestDays:   current,
+		NextMilestone: nextMilestone(current),
+		Days:          days,
+	}
+	if record != nil {
+		streak.LongestDays = max(record.LongestDays, current)
+	}
+	return streak, nil
+}
+
+// current 计算用户截至 now 的连续达标天数和第一天，今天还没达标时计到昨天；同时返回今天已收听的秒数
+func (s *Service) current(ctx context.Context, userID int64, goalMinutes int, now time.Time) (int, string, int64, error) {
+	today := dayStart(now)
+	todaySeconds, err := s.repo.GetListenedSeconds(ctx, userID, today, today.AddDate(0, 0, 1))
+	if err != nil {
+		return 0, "", 0, err
+	}
+	goalSeconds := int64(goalMinutes) * 60
+
+	count, startDay := 0, ""
+	if todaySeconds >= goalSeconds {
+		count, startDay = 1, today.Format(cache.PlayCountDayLayout)
+	}
+	expected := today.AddDate(0, 0, -1)
+	for {
+		days, err := s.repo.GetGoalDays(ctx, userID, goalSeconds, expected.Format(cache.PlayCountDayLayout), pageSize)
+		if err != nil {
+			return 0, "", 0, err
+		}
+		for _, day := range days {
+			if day != expected.Format(cache.PlayCountDayLayout) {
+				return count, startDay, todaySeconds, nil
+			}
+			count++
+			startDay = day
+			expected = expected.AddDate(0, 0, -1)
+		}
+		if len(days) < pageSize {
+			return count, startDay, todaySeconds, nil
+		}
+	}
+}
+
+// reachedMilestone 返回 days 已达到的最大里程碑，一个都没达到时为 0
+func reachedMilestone(days int) int {
+	reached := 0
+	for _, m := range Milestones {
+		if days >= m {
+			reached = m
+		}
+	}
+	return reached
+}
+
+// nextMilestone 返回 days 之后的下一个里程碑，已达到全部里程碑时为 0
+func nextMilestone(days int) int {
+	for _, m := range Milestones {
+		if days < m {
+			return m
+		}
+	}
+	return 0
+}
+
+// dayStart 返回 t 当天零点
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,191 @@
+package subsonic
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+)
+
+// APIVersion 兼容的 Subsonic API 版本
+const APIVersion = "1.16.1"
+
+// ServerType 响应中标识服务端的类型
+const ServerType = "bt1qfm"
+
+// Subsonic 错误码，失败时 HTTP 状态码仍为 200
+const (
+	ErrGeneric               = 0  // 其他错误
+	ErrMissingParameter      = 10 // 缺少必需参数
+	ErrWrongCredentials      = 40 // 用户名或密码错误
+	ErrTokenAuthNotSupported = 41 // 不支持 token 认证
+	ErrNotAuthorized         = 50 // 无权访问
+	ErrNotFound              = 70 // 请求的资源不存在
+)
+
+// Response 所有接口共用的响应，未使用的字段不输出
+type Response struct {
+	XMLName xml.Name `xml:"http://subsonic.org/restapi subsonic-response" json:"-"`
+	Status  string   `xml:"status,attr" json:"status"`
+	Version string   `xml:"version,attr" json:"version"`
+	Type    string   `xml:"type,attr" json:"type"`
+
+	Error         *Error            `xml:"error,omitempty" json:"error,omitempty"`
+	License       *License          `xml:"license,omitempty" json:"license,omitempty"`
+	MusicFolders  *MusicFolders     `xml:"musicFolders,omitempty" json:"musicFolders,omitempty"`
+	Artists       *Artists          `xml:"artists,omitempty" json:"artists,omitempty"`
+	Artist        *ArtistWithAlbums `xml:"artist,omitempty" json:"artist,omitempty"`
+	Album         *AlbumWithSongs   `xml:"album,omitempty" json:"album,omitempty"`
+	Song          *Child            `xml:"song,omitempty" json:"song,omitempty"`
+	Directory     *Directory        `xml:"directory,omitempty" json:"directory,omitempty"`
+	AlbumList     *AlbumList        `xml:"albumList,omitempty" json:"albumList,omitempty"`
+	AlbumList2    *AlbumList2       `xml:"albumList2,omitempty" json:"albumList2,omitempty"`
+	SearchResult3 *SearchResult3    `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
+}
+
+// Error 失败响应中的错误信息
+type Error struct {
+	Code    int    `xml:"code,attr" json:"code"`
+	Message string `xml:"message,attr" json:"message"`
+}
+
+// License 授权信息，客户端启动时会检查
+type License struct {
+	Valid bool `xml:"valid,attr" json:"valid"`
+}
+
+// MusicFolders 音乐目录列表
+type MusicFolders struct {
+	Folders []MusicFolder `xml:"musicFolder" json:"musicFolder,omitempty"`
+}
+
+// MusicFolder 音乐目录
+type MusicFolder struct {
+	ID   int    `xml:"id,attr" json:"id"`
+	Name string `xml:"name,attr" json:"name"`
+}
+
+// Artists 按首字母分组的艺术家索引
+type Artists struct {
+	IgnoredArticles string  `xml:"ignoredArticles,attr" json:"ignoredArticles"`
+	Index           []Index `xml:"index" json:"index,omitempty"`
+}
+
+// Index 同一首字母下的艺术家
+type Index struct {
+	Name    string      `xml:"name,attr" json:"name"`
+	Artists []ArtistID3 `xml:"artist" json:"artist,omitempty"`
+}
+
+// ArtistID3 艺术家
+type ArtistID3 struct {
+	ID         string `xml:"id,attr" json:"id"`
+	Name       string `xml:"name,attr" json:"name"`
+	CoverArt   string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	AlbumCount int    `xml:"albumCount,attr" json:"albumCount"`
+}
+
+// ArtistWithAlbums 艺术家及其专辑
+type ArtistWithAlbums struct {
+	ArtistID3
+	Albums []AlbumID3 `xml:"album" json:"album,omitempty"`
+}
+
+// AlbumID3 专辑
+type AlbumID3 struct {
+	ID        string `xml:"id,attr" json:"id"`
+	Name      string `xml:"name,attr" json:"name"`
+	Artist    string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	ArtistID  string `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+	CoverArt  string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	SongCount int    `xml:"songCount,attr" json:"songCount"`
+	Duration  int    `xml:"duration,attr" json:"duration"`
+	Created   string `xml:"created,attr" json:"created"`
+	Year      int    `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Genre     string `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	// IsCompilation OpenSubsonic 扩展字段，合辑（群星）为 true
+	IsCompilation bool `xml:"isCompilation,attr,omitempty" json:"isCompilation,omitempty"`
+}
+
+// AlbumWithSongs 专辑及其歌曲
+type AlbumWithSongs struct {
+	AlbumID3
+	Songs []Child `xml:"song" json:"song,omitempty"`
+}
+
+// Child 歌曲或目录（旧版基于目录的接口中专辑也以 Child 表示）
+type Child struct {
+	ID          string `xml:"id,attr" json:"id"`
+	Parent      string `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	IsDir       bool   `xml:"isDir,attr" json:"isDir"`
+	Title       string `xml:"title,attr" json:"title"`
+	Album       string `xml:"album,attr,omitempty" json:"album,omitempty"`
+	Artist      string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	Track       int    `xml:"track,attr,omitempty" json:"track,omitempty"`
+	DiscNumber  int    `xml:"discNumber,attr,omitempty" json:"discNumber,omitempty"`
+	Year        int    `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Genre       string `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	CoverArt    string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	ContentType string `xml:"contentType,attr,omitempty" json:"contentType,omitempty"`
+	Suffix      string `xml:"suffix,attr,omitempty" json:"suffix,omitempty"`
+	Duration    int    `xml:"duration,attr,omitempty" json:"duration,omitempty"`
+	Path        string `xml:"path,attr,omitempty" json:"path,omitempty"`
+	AlbumID     string `xml:"albumId,attr,omitempty" json:"albumId,omitempty"`
+	ArtistID    string `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+	Type        string `xml:"type,attr,omitempty" json:"type,omitempty"`
+	Created     string `xml:"created,attr,omitempty" json:"created,omitempty"`
+}
+
+// Directory 目录及其内容
+type Directory struct {
+	ID       string  `xml:"id,attr" json:"id"`
+	Parent   string  `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	Name     string  `xml:"name,attr" json:"name"`
+	Children []Child `xml:"child" json:"child,omitempty"`
+}
+
+// AlbumList 基于目录的专辑列表
+type AlbumList struct {
+	Albums []Child `xml:"album" json:"album,omitempty"`
+}
+
+// AlbumList2 按 ID3 标签组织的专辑列表
+type AlbumList2 struct {
+	Albums []AlbumID3 `xml:"album" json:"album,omitempty"`
+}
+
+// SearchResult3 搜索结果
+type SearchResult3 struct {
+	Artists []ArtistID3 `xml:"artist" json:"artist,omitempty"`
+	Albums  []AlbumID3  `xml:"album" json:"album,omitempty"`
+	Songs   []Child     `xml:"song" json:"song,omitempty"`
+}
+
+// NewResponse 创建成功响应
+func NewResponse() *Response {
+	return &Response{
+		Status:  "ok",
+		Version: APIVersion,
+		Type:    ServerType,
+	}
+}
+
+// NewError 创建失败响应
+func NewError(code int, message string) *Response {
+	resp := NewResponse()
+	resp.Status = "failed"
+	resp.Error = &Error{Code: code, Message: message}
+	return resp
+}
+
+// Write 按客户端请求的格式（f 参数：xml、json）写入响应，默认 XML
+func Write(w http.ResponseWriter, format string, resp *Response) error {
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		return json.NewEncoder(w).Encode(map[string]*Response{"subsonic-response": resp})
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(resp)
+}
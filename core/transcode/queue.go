@@ -0,0 +1,69 @@
+// Package transcode 通过 Redis 队列把上传曲目的转码任务分发给独立运行的转码 worker，
+// 使转码可以与 API 服务分开扩容
+package transcode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/audio"
+	"Bt1QFM/logger"
+)
+
+// DispatchWorker 配置 TranscodeDispatch 为该值时，上传的曲目交给转码 worker 处理
+const DispatchWorker = "worker"
+
+// 任务状态
+const (
+	StateQueued     = "queued"
+	StateProcessing = "processing"
+	StateCompleted  = "completed"
+	StateFailed     = "failed"
+)
+
+// Job 一个曲目的转码任务，源音频须已保存在对象存储中
+type Job struct {
+	TrackID    int64                   `json:"trackId"`
+	StreamID   string                  `json:"streamId"`
+	SourcePath string                  `json:"sourcePath"` // 源音频的对象路径，如 audio/{hash}.mp3
+	Options    *audio.TranscodeOptions `json:"options,omitempty"`
+	EnqueuedAt int64                   `json:"enqueuedAt"`
+}
+
+// Enqueue 将转码任务加入队列，曲目状态记为排队中
+func Enqueue(ctx context.Context, job *Job) error {
+	job.EnqueuedAt = time.Now().Unix()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if err := cache.PushTranscodeJob(ctx, data); err != nil {
+		return err
+	}
+	return cache.SetTranscodeStatus(ctx, job.TrackID, &cache.TranscodeStatus{State: StateQueued})
+}
+
+// dequeue 阻塞等待一个任务并移入 worker 的处理中列表，timeout 内没有任务时返回 nil
+// 返回的原始数据用于处理完成后 ack；无法解析的任务直接 ack 丢弃
+func dequeue(ctx context.Context, worker string, timeout time.Duration) (*Job, []byte, error) {
+	data, err := cache.ClaimTranscodeJob(ctx, worker, timeout)
+	if err != nil || data == nil {
+		return nil, nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		ack(ctx, worker, data)
+		return nil, nil, fmt.Errorf("invalid transcode job %q: %w", data, err)
+	}
+	return &job, data, nil
+}
+
+// ack 从 worker 的处理中列表删除已处理完的任务
+func ack(ctx context.Context, worker string, data []byte) {
+	if err := cache.AckTranscodeJob(ctx, worker, data); err != nil {
+		logger.Warn("确认转码任务失败", logger.String("worker", worker), logger.ErrorField(err))
+	}
+}
@@ -0,0 +1,235 @@
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/audio"
+	"Bt1QFM/logger"
+	"Bt1QFM/repository"
+	"Bt1QFM/storage"
+)
+
+const (
+	// pollTimeout 每次等待任务的最长时间，也是停止 worker 时最多等待的空闲时间
+	pollTimeout = 5 * time.Second
+	// downloadTimeout 下载源音频的超时时间
+	downloadTimeout = 5 * time.Minute
+	// heartbeatInterval worker 心跳间隔，同时检查其他 worker 是否已退出
+	heartbeatInterval = 30 * time.Second
+	// staleAfter 超过该时间没有心跳的 worker 视为已退出，其处理中的任务放回队列
+	staleAfter = 3 * heartbeatInterval
+)
+
+// Worker 转码 worker，从 Redis 队列取出任务，转码后将 HLS 输出上传到对象存储并回写曲目状态
+type Worker struct {
+	streamProcessor *audio.StreamProcessor
+	trackRepo       repository.TrackRepository
+	cfg             *config.Config
+	name            string
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewWorker 创建转码 worker，name 用于在任务状态中标识处理该任务的 worker
+func NewWorker(streamProcessor *audio.StreamProcessor, trackRepo repository.TrackRepository, cfg *config.Config, name string) *Worker {
+	return &Worker{
+		streamProcessor: streamProcessor,
+		trackRepo:       trackRepo,
+		cfg:             cfg,
+		name:            name,
+		stopChan:        make(chan struct{}),
+	}
+}
+
+// concurrency 同时处理的任务数，未配置时与转码池的并发数一致
+func (w *Worker) concurrency() int {
+	if w.cfg.TranscodeWorkerJobs > 0 {
+		return w.cfg.TranscodeWorkerJobs
+	}
+	if w.cfg.TranscodeConcurrency > 0 {
+		return w.cfg.TranscodeConcurrency
+	}
+	return runtime.NumCPU()
+}
+
+// Start 启动任务循环，先把已退出的 worker 未完成的任务放回队列
+func (w *Worker) Start() {
+	n := w.concurrency()
+	logger.Info("转码 worker 启动",
+		logger.String("worker", w.name),
+		logger.Int("concurrency", n))
+
+	w.heartbeat()
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stopChan:
+				return
+			case <-ticker.C:
+				w.heartbeat()
+			}
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.loop()
+		}()
+	}
+}
+
+// Stop 停止接收新任务，等待正在处理的任务完成后注销 worker
+func (w *Worker) Stop() {
+	close(w.stopChan)
+	w.wg.Wait()
+	if err := cache.RemoveTranscodeWorker(context.Background(), w.name); err != nil {
+		logger.Warn("注销转码 worker 失败", logger.String("worker", w.name), logger.ErrorField(err))
+	}
+	logger.Info("转码 worker 已停止", logger.String("worker", w.name))
+}
+
+// heartbeat 记录心跳，并把超时未心跳的 worker 处理中的任务放回队列
+func (w *Worker) heartbeat() {
+	ctx := context.Background()
+	if err := cache.TouchTranscodeWorker(ctx, w.name); err != nil {
+		logger.Warn("写入转码 worker 心跳失败", logger.String("worker", w.name), logger.ErrorField(err))
+		return
+	}
+	n, err := cache.RequeueStaleTranscodeJobs(ctx, staleAfter)
+	if err != nil {
+		logger.Warn("回收已退出 worker 的转码任务失败", logger.ErrorField(err))
+	}
+	if n > 0 {
+		logger.Info("已将已退出 worker 未完成的转码任务放回队列", logger.Int("jobs", n))
+	}
+}
+
+func (w *Worker) loop() {
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		default:
+		}
+
+		job, data, err := dequeue(context.Background(), w.name, pollTimeout)
+		if err != nil {
+			logger.Warn("获取转码任务失败", logger.ErrorField(err))
+			select {
+			case <-w.stopChan:
+				return
+			case <-time.After(pollTimeout):
+			}
+			continue
+		}
+		if job != nil {
+			w.process(context.Background(), job)
+			ack(context.Background(), w.name, data)
+		}
+	}
+}
+
+// process 处理一个任务，结果写入曲目状态和任务状态
+func (w *Worker) process(ctx context.Context, job *Job) {
+	start := time.Now()
+	logger.Info("开始处理转码任务",
+		logger.Int64("trackId", job.TrackID),
+		logger.String("streamId", job.StreamID),
+		logger.Duration("queued", start.Sub(time.Unix(job.EnqueuedAt, 0))))
+	w.setStatus(ctx, job.TrackID, StateProcessing, nil)
+
+	if err := w.transcode(ctx, job); err != nil {
+		logger.Error("转码任务失败",
+			logger.Int64("trackId", job.TrackID),
+			logger.String("streamId", job.StreamID),
+			logger.ErrorField(err))
+		if err := w.trackRepo.UpdateTrackStatus(ctx, job.TrackID, "failed"); err != nil {
+			logger.Warn("更新曲目状态失败", logger.Int64("trackId", job.TrackID), logger.ErrorField(err))
+		}
+		w.setStatus(ctx, job.TrackID, StateFailed, err)
+		return
+	}
+
+	if err := w.trackRepo.UpdateTrackStatus(ctx, job.TrackID, "completed"); err != nil {
+		logger.Warn("更新曲目状态失败", logger.Int64("trackId", job.TrackID), logger.ErrorField(err))
+	}
+	w.setStatus(ctx, job.TrackID, StateCompleted, nil)
+	logger.Info("转码任务完成",
+		logger.Int64("trackId", job.TrackID),
+		logger.String("streamId", job.StreamID),
+		logger.Duration("elapsed", time.Since(start)))
+}
+
+// transcode 下载源音频并同步转码，流水线模式下 HLS 输出在返回前已上传到对象存储
+func (w *Worker) transcode(ctx context.Context, job *Job) error {
+	tempFile, err := os.CreateTemp("", "transcode-*"+filepath.Ext(job.SourcePath))
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tempFilePath := tempFile.Name()
+	defer os.Remove(tempFilePath)
+
+	err = downloadSource(ctx, job.SourcePath, tempFile)
+	tempFile.Close()
+	if err != nil {
+		return err
+	}
+
+	os.RemoveAll(filepath.Join(w.cfg.StaticDir, "temp", "streams", job.StreamID))
+	w.streamProcessor.InvalidateStreamCache(job.StreamID)
+	if err := w.streamProcessor.StreamProcessSyncWithOptions(ctx, job.StreamID, tempFilePath, false, job.Options); err != nil {
+		return err
+	}
+
+	playlistPath := "/streams/" + job.StreamID + "/playlist.m3u8"
+	if err := w.trackRepo.UpdateTrackHLSPath(ctx, job.TrackID, playlistPath, 0); err != nil {
+		return fmt.Errorf("更新HLS路径失败: %w", err)
+	}
+	return nil
+}
+
+// downloadSource 从对象存储下载源音频到 dst
+func downloadSource(ctx context.Context, objectPath string, dst io.Writer) error {
+	store := storage.GetStorage()
+	if store == nil {
+		return fmt.Errorf("对象存储未初始化")
+	}
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	object, err := store.Get(ctx, objectPath)
+	if err != nil {
+		return fmt.Errorf("下载源音频 %s 失败: %w", objectPath, err)
+	}
+	defer object.Close()
+	if _, err := io.Copy(dst, object); err != nil {
+		return fmt.Errorf("下载源音频 %s 失败: %w", objectPath, err)
+	}
+	return nil
+}
+
+func (w *Worker) setStatus(ctx context.Context, trackID int64, state string, err error) {
+	status := &cache.TranscodeStatus{State: state, Worker: w.name}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	if err := cache.SetTranscodeStatus(ctx, trackID, status); err != nil {
+		logger.Warn("写入转码任务状态失败", logger.Int64("trackId", trackID), logger.ErrorField(err))
+	}
+}
@@ -0,0 +1,182 @@
+package trash
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+// purgeBatchSize 每批彻底删除的条目数
+const purgeBatchSize = 100
+
+// StorageReleaser 释放被彻底删除条目占用的存储
+type StorageReleaser interface {
+	// ReleaseTrackStorage 释放曲目的源音频、HLS 流和封面（仍被其他曲目引用的除外）
+	ReleaseTrackStorage(ctx context.Context, track *model.Track)
+	// ReleaseCover 释放不再被任何曲目或专辑引用的封面
+	ReleaseCover(ctx context.Context, coverPath string)
+}
+
+// Report 一次清理的结果
+type Report struct {
+	Tracks   int `json:"tracks"`
+	Albums   int `json:"albums"`
+	Versions int `json:"versions"`
+}
+
+// Purger 回收站清理器，彻底删除超过保留期的曲目和专辑以及到期的曲目旧版本并释放存储
+type Purger struct {
+	trackRepo   repository.TrackRepository
+	albumRepo   repository.AlbumRepository
+	versionRepo repository.TrackVersionRepository
+	releaser    StorageReleaser
+	cfg         *config.Config
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewPurger 创建回收站清理器
+func NewPurger(trackRepo repository.TrackRepository, albumRepo repository.AlbumRepository, versionRepo repository.TrackVersionRepository, releaser StorageReleaser, cfg *config.Config) *Purger {
+	return &Purger{
+		trackRepo:   trackRepo,
+		albumRepo:   albumRepo,
+		versionRepo: versionRepo,
+		releaser:    releaser,
+		cfg:         cfg,
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Retention 回收站保留时长
+func (p *Purger) Retention() time.Duration {
+	return time.Duration(p.cfg.TrashRetentionDays) * 24 * time.Hour
+}
+
+// Start 按配置的间隔定期清理，间隔为 0 时不启动
+func (p *Purger) Start() {
+	if p.cfg.TrashPurgeIntervalHours <= 0 {
+		logger.Info("回收站自动清理未启用")
+		return
+	}
+	interval := time.Duration(p.cfg.TrashPurgeIntervalHours) * time.Hour
+	logger.Info("回收站清理服务启动",
+		logger.Duration("interval", interval),
+		logger.Int("retentionDays", p.cfg.TrashRetentionDays))
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stopChan:
+				return
+			case <-ticker.C:
+				if _, err := p.Run(context.Background(), time.Now()); err != nil {
+					logger.Warn("定期清理回收站失败", logger.ErrorField(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止定期清理
+func (p *Purger) Stop() {
+	close(p.stopChan)
+	p.wg.Wait()
+}
+
+// Run 彻底删除在 now 之前已超过保留期的曲目和专辑，以及已到期的曲目旧版本
+func (p *Purger) Run(ctx context.Context, now time.Time) (*Report, error) {
+	p.running.Lock()
+	defer p.running.Unlock()
+
+	cutoff := now.Add(-p.Retention())
+	report := &Report{}
+
+	for {
+		tracks, err := p.trackRepo.GetTracksDeletedBefore(ctx, cutoff, purgeBatchSize)
+		if err != nil {
+			return report, err
+		}
+		purged := 0
+		for _, track := range tracks {
+			// 曲目的旧版本随曲目级联删除，先取出以便释放存储
+			versions, err := p.versionRepo.GetVersionsByTrackID(ctx, track.ID)
+			if err != nil {
+				logger.Warn("获取曲目版本失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+				continue
+			}
+			if err := p.trackRepo.PurgeTrack(ctx, track.ID); err != nil {
+				logger.Warn("彻底删除曲目失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+				continue
+			}
+			p.releaser.ReleaseTrackStorage(ctx, track)
+			for _, version := range versions {
+				p.releaser.ReleaseTrackStorage(ctx, version.StorageRef())
+			}
+			purged++
+		}
+		report.Tracks += purged
+		// 整批都失败时停止，避免反复查询同一批
+		if len(tracks) < purgeBatchSize || purged == 0 {
+			break
+		}
+	}
+
+	for {
+		albums, err := p.albumRepo.GetAlbumsDeletedBefore(ctx, cutoff, purgeBatchSize)
+		if err != nil {
+			return report, err
+		}
+		purged := 0
+		for _, album := range albums {
+			if err := p.albumRepo.PurgeAlbum(ctx, album.ID); err != nil {
+				logger.Warn("彻底删除专辑失败", logger.Int64("albumId", album.ID), logger.ErrorField(err))
+				continue
+			}
+			p.releaser.ReleaseCover(ctx, album.CoverPath)
+			purged++
+		}
+		report.Albums += purged
+		if len(albums) < purgeBatchSize || purged == 0 {
+			break
+		}
+	}
+
+	for {
+		versions, err := p.versionRepo.GetVersionsExpiredBefore(ctx, now, purgeBatchSize)
+		if err != nil {
+			return report, err
+		}
+		purged := 0
+		for _, version := range versions {
+			if err := p.versionRepo.DeleteVersion(ctx, version.ID); err != nil {
+				logger.Warn("删除到期的曲目版本失败", logger.Int64("versionId", version.ID), logger.ErrorField(err))
+				continue
+			}
+			p.releaser.ReleaseTrackStorage(ctx, version.StorageRef())
+			purged++
+		}
+		report.Versions += purged
+		if len(versions) < purgeBatchSize || purged == 0 {
+			break
+		}
+	}
+
+	if report.Tracks > 0 || report.Albums > 0 || report.Versions > 0 {
+		logger.Info("回收站清理完成",
+			logger.Int("tracks", report.Tracks),
+			logger.Int("albums", report.Albums),
+			logger.Int("versions", report.Versions))
+	}
+	return report, nil
+}
@@ -0,0 +1,227 @@
+package trending
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+)
+
+const (
+	// MaxDays 热门歌曲最多统计的天数
+	MaxDays = 30
+	// MaxLimit 热门歌曲最多返回的数量
+	MaxLimit = 100
+	// defaultRollupHour PLAY_COUNT_ROLLUP_HOUR 无效时的汇总时间
+	defaultRollupHour = 3
+)
+
+// Report 一次汇总的结果
+type Report struct {
+	Days  int `json:"days"`  // 汇总的天数
+	Songs int `json:"songs"` // 汇总的歌曲-天数
+}
+
+// Service 播放次数服务：开始播放时在 Redis 当天的计数上加一，每天定时把之前的计数汇总到数据库
+// 热门歌曲只包含网易云歌曲，本地上传的曲目只属于上传者，只累计到曲目自身的播放次数
+type Service struct {
+	repo        repository.PlayCountRepository
+	neteaseRepo *repository.NeteaseSongRepository
+	cfg         *config.Config
+
+	running  sync.Mutex
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewService 创建播放次数服务
+func NewService(repo repository.PlayCountRepository, neteaseRepo *repository.NeteaseSongRepository, cfg *config.Config) *Service {
+	return &Service{
+		repo:        repo,
+		neteaseRepo: neteaseRepo,
+		cfg:         cfg,
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Start 启动时先补汇总停机期间遗留的计数，之后每天在配置的时间汇总
+func (s *Service) Start() {
+	logger.Info("播放次数汇总服务启动", logger.Int("hour", s.rollupHour()))
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.rollup()
+		for {
+			timer := time.NewTimer(time.Until(nextRun(time.Now(), s.rollupHour())))
+			select {
+			case <-s.stopChan:
+				timer.Stop()
+				return
+			case <-timer.C:
+				s.rollup()
+			}
+		}
+	}()
+}
+
+// Stop 停止定时汇总
+func (s *Service) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+func (s *Service) rollupHour() int {
+	if s.cfg.PlayCountRollupHour < 0 || s.cfg.PlayCountRollupHour > 23 {
+		return defaultRollupHour
+	}
+	return s.cfg.PlayCountRollupHour
+}
+
+// nextRun 计算下一次汇总时间
+func nextRun(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.Add(24 * time.Hour)
+	}
+	return next
+}
+
+func (s *Service) rollup() {
+	report, err := s.Rollup(context.Background(), time.Now())
+	if err != nil {
+		logger.Warn("汇总播放次数失败", logger.ErrorField(err))
+		return
+	}
+	if report.Days > 0 {
+		logger.Info("播放次数已汇总",
+			logger.Int("days", report.Days),
+			logger.Int("songs", report.Songs))
+	}
+}
+
+// Rollup 把 now 当天之前的每日播放计数写入数据库并从 Redis 删除，当天的计数仍在累加，不汇总
+func (s *Service) Rollup(ctx context.Context, now time.Time) (*Report, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	days, err := cache.PlayCountDays(ctx)
+	if err != nil {
+		return nil, err
+	}
+	today := now.Format(cache.PlayCountDayLayout)
+	report := &Report{}
+	for _, day := range days {
+		if day >= today {
+			continue
+		}
+		counts, err := cache.GetPlayCounts(ctx, day)
+		if err != nil {
+			return report, err
+		}
+		if err := s.repo.SaveDailyPlayCounts(ctx, day, counts); err != nil {
+			return report, err
+		}
+		if err := cache.DeletePlayCounts(ctx, day); err != nil {
+			return report, err
+		}
+		report.Days++
+		report.Songs += len(counts)
+	}
+	return report, nil
+}
+
+// Days 规范化统计天数，未指定时使用配置的默认值
+func (s *Service) Days(days int) int {
+	if days <= 0 {
+		days = s.cfg.TrendingDays
+	}
+	return min(max(days, 1), MaxDays)
+}
+
+// Trending 返回包含 now 当天在内最近 days 天播放最多的网易云歌曲
+// 已汇总的天数从数据库读取，还在 Redis 中的计数（当天以及尚未汇总的前一天）实时合并
+func (s *Service) Trending(ctx context.Context, now time.Time, days, limit int) ([]model.TrendingSong, error) {
+	const source = cache.SourceNetease
+	first := now.AddDate(0, 0, 1-days)
+
+	stored, err := s.repo.GetTopPlayCounts(ctx, source, first.Format(cache.PlayCountDayLayout), MaxLimit)
+	if err != nil {
+		return nil, err
+	}
+	plays := make(map[string]int64, len(stored))
+	for _, c := range stored {
+		plays[c.SourceID] += c.Plays
+	}
+	for day := first; !day.After(now); day = day.AddDate(0, 0, 1) {
+		counts, err := cache.GetPlayCounts(ctx, day.Format(cache.PlayCountDayLayout))
+		if err != nil {
+			logger.Warn("读取当日播放次数失败", logger.ErrorField(err))
+			break
+		}
+		for _, c := range counts {
+			if c.Source == source {
+				plays[c.SourceID] += c.Plays
+			}
+		}
+	}
+
+	ranked := make([]model.PlayCount, 0, len(plays))
+	for id, n := range plays {
+		ranked = append(ranked, model.PlayCount{Source: source, SourceID: id, Plays: n})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Plays != ranked[j].Plays {
+			return ranked[i].Plays > ranked[j].Plays
+		}
+		return ranked[i].SourceID < ranked[j].SourceID
+	})
+
+	// 多取一些，跳过数据库中没有歌曲信息的记录后仍能凑够数量
+	ids := make([]int64, 0, limit*2)
+	for _, c := range ranked {
+		if len(ids) == cap(ids) {
+			break
+		}
+		if id, err := strconv.ParseInt(c.SourceID, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	songs, err := s.neteaseRepo.GetNeteaseSongsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*model.NeteaseSongDB, len(songs))
+	for _, song := range songs {
+		byID[strconv.FormatInt(song.ID, 10)] = song
+	}
+
+	items := make([]model.TrendingSong, 0, limit)
+	for _, c := range ranked {
+		if len(items) == limit {
+			break
+		}
+		song := byID[c.SourceID]
+		if song == nil {
+			continue
+		}
+		items = append(items, model.TrendingSong{
+			Source:   source,
+			SourceID: c.SourceID,
+			Title:    song.Title,
+			Artist:   song.Artist,
+			Album:    song.Album,
+			CoverURL: song.CoverArtPath,
+			Duration: song.Duration,
+			Plays:    c.Plays,
+		})
+	}
+	return items, nil
+}
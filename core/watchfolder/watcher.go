@@ -0,0 +1,321 @@
+package watchfolder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// failedDirName 导入失败的文件移入监视目录下的该子目录，修复后移回监视目录即可重试
+const failedDirName = "failed"
+
+// scanInterval 检查待导入文件是否写入完成的间隔
+const scanInterval = time.Second
+
+// Importer 导入服务器本地的音频文件
+type Importer interface {
+	// IsImportable 文件是否为支持导入的音频格式
+	IsImportable(path string) bool
+	// ImportFile 导入文件并同步转码，skipped 表示用户曲库中已有相同内容的曲目
+	ImportFile(ctx context.Context, userID int64, path, album string) (track *model.Track, skipped bool, err error)
+}
+
+// pendingFile 等待写入完成的文件
+type pendingFile struct {
+	size      int64
+	modTime   time.Time
+	changedAt time.Time
+}
+
+// Watcher 监视服务器上的目录，把放入的音频导入到指定用户（和专辑），导入后归档或删除源文件
+// 只监视目录顶层；文件大小和修改时间在 WatchFolderSettleSeconds 内不再变化才视为复制完成
+type Watcher struct {
+	importer  Importer
+	userRepo  repository.UserRepository
+	albumRepo repository.AlbumRepository
+	cfg       *config.Config
+
+	userID  int64
+	album   *model.Album
+	pending map[string]*pendingFile // 只在监视协程中访问
+
+	watcher  *fsnotify.Watcher
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewWatcher 创建目录监视器
+func NewWatcher(importer Importer, userRepo repository.UserRepository, albumRepo repository.AlbumRepository, cfg *config.Config) *Watcher {
+	return &Watcher{
+		importer:  importer,
+		userRepo:  userRepo,
+		albumRepo: albumRepo,
+		cfg:       cfg,
+		pending:   make(map[string]*pendingFile),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Start 校验配置并开始监视，未配置目录时不启动
+// 启动时目录中已有的文件同样会被导入
+func (w *Watcher) Start(ctx context.Context) error {
+	dir := w.cfg.WatchFolderDir
+	if dir == "" {
+		logger.Info("监视目录未启用")
+		return nil
+	}
+	if err := w.resolveTarget(ctx); err != nil {
+		return err
+	}
+
+	for _, d := range []string{dir, filepath.Join(dir, failedDirName), w.cfg.WatchFolderArchiveDir} {
+		if d == "" {
+			continue
+		}
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return fmt.Errorf("创建目录 %s 失败: %w", d, err)
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("创建文件监听器失败: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("监听目录 %s 失败: %w", dir, err)
+	}
+	w.watcher = watcher
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		watcher.Close()
+		return fmt.Errorf("读取目录 %s 失败: %w", dir, err)
+	}
+	for _, entry := range entries {
+		w.touch(filepath.Join(dir, entry.Name()))
+	}
+
+	logger.Info("监视目录服务启动",
+		logger.String("dir", dir),
+		logger.Int64("userId", w.userID),
+		logger.Int64("albumId", w.cfg.WatchFolderAlbumID),
+		logger.String("archiveDir", w.cfg.WatchFolderArchiveDir),
+		logger.Int("existingFiles", len(w.pending)))
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.run()
+	}()
+	return nil
+}
+
+// Stop 停止监视，正在导入的文件会先导入完成
+func (w *Watcher) Stop() {
+	if w.watcher == nil {
+		return
+	}
+	close(w.stopChan)
+	w.wg.Wait()
+	w.watcher.Close()
+	logger.Info("监视目录服务已停止")
+}
+
+// resolveTarget 查找导入的目标用户和专辑
+func (w *Watcher) resolveTarget(ctx context.Context) error {
+	if w.cfg.WatchFolderUser == "" {
+		return fmt.Errorf("已配置 WATCH_FOLDER_DIR 但未配置 WATCH_FOLDER_USER")
+	}
+	user, err := w.userRepo.GetUserByUsername(ctx, w.cfg.WatchFolderUser)
+	if err != nil {
+		return fmt.Errorf("查找用户 %s 失败: %w", w.cfg.WatchFolderUser, err)
+	}
+	if user == nil {
+		return fmt.Errorf("用户 %s 不存在", w.cfg.WatchFolderUser)
+	}
+	w.userID = user.ID
+
+	if w.cfg.WatchFolderAlbumID > 0 {
+		album, err := w.albumRepo.GetAlbumByID(ctx, w.cfg.WatchFolderAlbumID)
+		if err != nil {
+			return fmt.Errorf("查找专辑 %d 失败: %w", w.cfg.WatchFolderAlbumID, err)
+		}
+		if album == nil || album.UserID != user.ID {
+			return fmt.Errorf("专辑 %d 不存在或不属于用户 %s", w.cfg.WatchFolderAlbumID, user.Username)
+		}
+		w.album = album
+	}
+	return nil
+}
+
+// run 监视协程：记录有变化的文件，定期导入已写入完成的文件
+func (w *Watcher) run() {
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write) != 0 {
+				w.touch(event.Name)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warn("监视目录出错", logger.ErrorField(err))
+		case <-ticker.C:
+			w.importSettled()
+		}
+	}
+}
+
+// touch 记录新出现或被修改的音频文件，重新开始计算稳定时间
+func (w *Watcher) touch(path string) {
+	if strings.HasPrefix(filepath.Base(path), ".") || !w.importer.IsImportable(path) {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	w.pending[path] = &pendingFile{size: info.Size(), modTime: info.ModTime(), changedAt: time.Now()}
+}
+
+// importSettled 导入大小和修改时间已稳定的文件
+func (w *Watcher) importSettled() {
+	settle := time.Duration(w.cfg.WatchFolderSettleSeconds) * time.Second
+	for path, p := range w.pending {
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(w.pending, path)
+			continue
+		}
+		if info.Size() != p.size || !info.ModTime().Equal(p.modTime) {
+			p.size, p.modTime, p.changedAt = info.Size(), info.ModTime(), time.Now()
+			continue
+		}
+		if time.Since(p.changedAt) < settle {
+			continue
+		}
+		delete(w.pending, path)
+		w.importFile(path)
+
+		// 单个文件转码可能较久，每导入一个检查一次是否需要停止
+		select {
+		case <-w.stopChan:
+			return
+		default:
+		}
+	}
+}
+
+// importFile 导入单个文件，成功或已存在时归档源文件，失败时移入 failed 子目录
+func (w *Watcher) importFile(path string) {
+	ctx := context.Background()
+	start := time.Now()
+	var album string
+	if w.album != nil {
+		album = w.album.Name
+	}
+
+	track, skipped, err := w.importer.ImportFile(ctx, w.userID, path, album)
+	if err != nil {
+		logger.Error("监视目录导入失败",
+			logger.String("path", path),
+			logger.ErrorField(err))
+		failedPath := filepath.Join(w.cfg.WatchFolderDir, failedDirName, filepath.Base(path))
+		if err := moveFile(path, uniquePath(failedPath)); err != nil {
+			logger.Warn("移动导入失败的文件失败", logger.String("path", path), logger.ErrorField(err))
+		}
+		return
+	}
+
+	if !skipped && w.album != nil {
+		// 文件名中的碟号和曲号（如 "1-01 标题"）决定在专辑中的顺序
+		disc, number, _ := model.ParseTrackFilename(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+		placement := model.AlbumTrackPlacement{TrackID: track.ID, DiscNumber: disc, TrackNumber: number}
+		if err := w.albumRepo.AddPlacedTracksToAlbum(ctx, w.album.ID, []model.AlbumTrackPlacement{placement}); err != nil {
+			logger.Warn("添加曲目到专辑失败",
+				logger.Int64("trackId", track.ID),
+				logger.Int64("albumId", w.album.ID),
+				logger.ErrorField(err))
+		}
+	}
+
+	logger.Info("监视目录导入完成",
+		logger.String("path", path),
+		logger.Int64("trackId", track.ID),
+		logger.Bool("skipped", skipped),
+		logger.Duration("elapsed", time.Since(start)))
+
+	if err := w.archive(path); err != nil {
+		logger.Warn("归档已导入的文件失败", logger.String("path", path), logger.ErrorField(err))
+	}
+}
+
+// archive 把已导入的文件按日期移入归档目录，未配置归档目录时删除
+func (w *Watcher) archive(path string) error {
+	if w.cfg.WatchFolderArchiveDir == "" {
+		return os.Remove(path)
+	}
+	dir := filepath.Join(w.cfg.WatchFolderArchiveDir, time.Now().Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return moveFile(path, uniquePath(filepath.Join(dir, filepath.Base(path))))
+}
+
+// uniquePath 目标已存在时在文件名后追加时间戳
+func uniquePath(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), time.Now().UnixNano(), ext)
+}
+
+// moveFile 移动文件，跨文件系统无法重命名时复制后删除源文件
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 
 	// Added for checking alter table error
 	"Bt1QFM/config"
@@ -30,6 +31,9 @@ func ConnectDB(cfg *config.Config) error {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	SetQueryTimeouts(time.Duration(cfg.DBQueryTimeoutSeconds)*time.Second,
+		time.Duration(cfg.DBListQueryTimeoutSeconds)*time.Second)
+
 	log.Println("Successfully connected to the database.")
 	return nil
 }
@@ -53,6 +57,178 @@ func InitDB() error {
 	if err := createNeteaseSongTable(); err != nil {
 		return err
 	}
+	if err := createTrackFingerprintsTable(); err != nil {
+		return err
+	}
+	if err := createTagsTables(); err != nil {
+		return err
+	}
+	if err := createPlayHistoryTable(); err != nil {
+		return err
+	}
+	if err := createPlayCountsTable(); err != nil {
+		return err
+	}
+	if err := createUserBandwidthTable(); err != nil {
+		return err
+	}
+	if err := createListeningTables(); err != nil {
+		return err
+	}
+	if err := createTrackCommentsTable(); err != nil {
+		return err
+	}
+	if err := createSocialTables(); err != nil {
+		return err
+	}
+	if err := createAnnouncementTables(); err != nil {
+		return err
+	}
+	if err := createNotificationsTable(); err != nil {
+		return err
+	}
+	if err := createScrobbleAccountsTable(); err != nil {
+		return err
+	}
+	if err := createChatTables(); err != nil {
+		return err
+	}
+	if err := createTrackVersionsTable(); err != nil {
+		return err
+	}
+	if err := createJWTSigningKeysTable(); err != nil {
+		return err
+	}
+	if err := createStorageRefsTable(); err != nil {
+		return err
+	}
+
+	// 补齐旧库中缺失的列
+	if err := ensureColumn("tracks", "file_path", "VARCHAR(255)"); err != nil {
+		return err
+	}
+	if err := ensureColumn("tracks", "provenance", "VARCHAR(20) DEFAULT 'upload'"); err != nil {
+		return err
+	}
+	if err := ensureColumn("tracks", "license", "VARCHAR(512) DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn("tracks", "content_hash", "CHAR(64) DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn("tracks", "genre", "VARCHAR(100) DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn("tracks", "deleted_at", "DATETIME NULL"); err != nil {
+		return err
+	}
+	if err := ensureColumn("albums", "deleted_at", "DATETIME NULL"); err != nil {
+		return err
+	}
+	// 最近一次自动获取封面的时间，扫描时跳过近期已尝试的条目
+	if err := ensureColumn("tracks", "cover_attempted_at", "DATETIME NULL"); err != nil {
+		return err
+	}
+	if err := ensureColumn("albums", "cover_attempted_at", "DATETIME NULL"); err != nil {
+		return err
+	}
+	// 从 MusicBrainz 补全的专辑元数据和多碟专辑的碟号
+	if err := ensureColumn("albums", "label", "VARCHAR(255) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn("albums", "musicbrainz_id", "VARCHAR(36) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	// 合辑（群星）的曲目保留各自的艺术家
+	if err := ensureColumn("albums", "is_compilation", "TINYINT(1) NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn("album_tracks", "disc_number", "INT NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+	// 碟内曲号，0 表示未知，按 position 排在有曲号的曲目之后
+	if err := ensureColumn("album_tracks", "track_number", "INT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// 上传的源文件大小，用于统计用户存储配额；迁移前的曲目记为 0
+	if err := ensureColumn("tracks", "file_size", "BIGINT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// 已有用户视为已验证邮箱
+	if err := ensureColumn("users", "status", "VARCHAR(20) NOT NULL DEFAULT 'active'"); err != nil {
+		return err
+	}
+	if err := ensureColumn("users", "email_verified_at", "DATETIME NULL"); err != nil {
+		return err
+	}
+	if err := ensureIndex("tracks", "idx_content_hash", "content_hash"); err != nil {
+		return err
+	}
+	// 重复检测只比较时长相近的指纹
+	if err := ensureIndex("track_fingerprints", "idx_user_duration", "user_id, duration"); err != nil {
+		return err
+	}
+	// 相同内容的曲目共享源音频，回收站中的曲目、重复上传和专辑批量上传都会出现相同的 (user_id, file_path)
+	// 先建好 user_id 索引，外键依赖的唯一索引才能删除
+	if err := ensureIndex("tracks", "idx_user_id", "user_id"); err != nil {
+		return err
+	}
+	if err := dropIndex("tracks", "uq_user_filepath"); err != nil {
+		return err
+	}
+	// 每个用户可以有多个聊天会话
+	if err := ensureColumn("chat_sessions", "archived_at", "DATETIME NULL"); err != nil {
+		return err
+	}
+	// 先建好普通索引，外键依赖的唯一索引才能删除
+	if err := ensureIndex("chat_sessions", "idx_user_updated", "user_id, updated_at"); err != nil {
+		return err
+	}
+	if err := dropSingleColumnUniqueIndexes("chat_sessions", "user_id"); err != nil {
+		return err
+	}
+	// 超出上下文预算的早期对话合并为会话摘要
+	if err := ensureColumn("chat_sessions", "summary", "TEXT NULL"); err != nil {
+		return err
+	}
+	if err := ensureColumn("chat_sessions", "summary_until", "BIGINT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// 计算推荐时按来源和时间扫描所有用户的播放历史
+	if err := ensureIndex("play_history", "idx_source_started", "source, started_at"); err != nil {
+		return err
+	}
+	// 累计播放次数，由每日播放计数汇总得到
+	if err := ensureColumn("tracks", "play_count", "BIGINT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn("netease_song", "play_count", "BIGINT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// 发行年份，可通过元数据导入批量填写，0 表示未知
+	if err := ensureColumn("tracks", "release_year", "INT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// 公告定时发布与受众
+	if err := ensureColumn("announcements", "publish_at", "DATETIME NULL"); err != nil {
+		return err
+	}
+	if err := ensureColumn("announcements", "expire_at", "DATETIME NULL"); err != nil {
+		return err
+	}
+	if err := ensureColumn("announcements", "status", "VARCHAR(20) NOT NULL DEFAULT 'published'"); err != nil {
+		return err
+	}
+	if err := ensureColumn("announcements", "audience", "VARCHAR(20) NOT NULL DEFAULT 'all'"); err != nil {
+		return err
+	}
+	if err := ensureColumn("announcements", "joined_after", "DATETIME NULL"); err != nil {
+		return err
+	}
+	// 评论回复：指向同一首歌下被回复的评论
+	if err := ensureColumn("track_comments", "parent_id", "BIGINT NULL"); err != nil {
+		return err
+	}
 
 	// 检查是否需要迁移初始用户数据
 	if err := migrateInitialUserAndTracks(); err != nil {
@@ -72,6 +248,8 @@ func createUsersTable() error {
 		password_hash VARCHAR(255) NOT NULL,
 		phone VARCHAR(20),
 		preferences TEXT,
+		status VARCHAR(20) NOT NULL DEFAULT 'active',
+		email_verified_at DATETIME NULL,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -91,6 +269,7 @@ func createTracksTable() error {
 		title VARCHAR(255) NOT NULL,
 		artist VARCHAR(255),
 		album VARCHAR(255),
+		genre VARCHAR(100) DEFAULT '',
 		file_path VARCHAR(255) NOT NULL,
 		cover_art_path VARCHAR(255),
 		hls_playlist_path VARCHAR(255),
@@ -99,10 +278,15 @@ func createTracksTable() error {
 		status VARCHAR(50) DEFAULT 'processing',
 		state TINYINT DEFAULT 1,
 		source VARCHAR(20) DEFAULT 'library',
+		provenance VARCHAR(20) DEFAULT 'upload',
+		license VARCHAR(512) DEFAULT '',
+		content_hash CHAR(64) DEFAULT '',
+		deleted_at DATETIME NULL,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-		CONSTRAINT fk_user_tracks FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-		CONSTRAINT uq_user_filepath UNIQUE (user_id, file_path)
+		INDEX idx_content_hash (content_hash),
+		INDEX idx_user_id (user_id),
+		CONSTRAINT fk_user_tracks FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 	`
 	_, err := DB.Exec(query)
@@ -135,6 +319,93 @@ func alterTracksTableAddUserID() error {
 	return nil
 }
 
+// ensureColumn 检查列是否存在，不存在则添加
+func ensureColumn(table, column, definition string) error {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?", table, column).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to check if %s.%s column exists: %w", table, column, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s column to %s table: %w", column, table, err)
+	}
+	log.Printf("Column '%s' added to '%s' table.", column, table)
+	return nil
+}
+
+// dropSingleColumnUniqueIndexes 删除只包含 column 一列的唯一索引（主键除外），用于放开旧库中的唯一约束
+func dropSingleColumnUniqueIndexes(table, column string) error {
+	rows, err := DB.Query(`
+		SELECT INDEX_NAME FROM INFORMATION_SCHEMA.STATISTICS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND NON_UNIQUE = 0 AND INDEX_NAME <> 'PRIMARY'
+		GROUP BY INDEX_NAME
+		HAVING COUNT(*) = 1 AND MAX(COLUMN_NAME) = ?`, table, column)
+	if err != nil {
+		return fmt.Errorf("failed to query unique indexes on %s.%s: %w", table, column, err)
+	}
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan index name: %w", err)
+		}
+		indexes = append(indexes, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate unique indexes on %s.%s: %w", table, column, err)
+	}
+
+	for _, name := range indexes {
+		if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s DROP INDEX `%s`", table, name)); err != nil {
+			return fmt.Errorf("failed to drop unique index %s on %s table: %w", name, table, err)
+		}
+		log.Printf("Unique index '%s' dropped from '%s' table.", name, table)
+	}
+	return nil
+}
+
+// ensureIndex 检查索引是否存在，不存在则创建
+func ensureIndex(table, index, columns string) error {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM INFORMATION_SCHEMA.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?", table, index).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to check if index %s on %s exists: %w", index, table, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := DB.Exec(fmt.Sprintf("CREATE INDEX %s ON %s (%s)", index, table, columns)); err != nil {
+		return fmt.Errorf("failed to create index %s on %s table: %w", index, table, err)
+	}
+	log.Printf("Index '%s' created on '%s' table.", index, table)
+	return nil
+}
+
+// dropIndex 索引存在时删除
+func dropIndex(table, index string) error {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM INFORMATION_SCHEMA.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?", table, index).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to check if index %s on %s exists: %w", index, table, err)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s DROP INDEX `%s`", table, index)); err != nil {
+		return fmt.Errorf("failed to drop index %s on %s table: %w", index, table, err)
+	}
+	log.Printf("Index '%s' dropped from '%s' table.", index, table)
+	return nil
+}
+
 func migrateInitialUserAndTracks() error {
 	// 1. Create 'bt1q' user
 	username := "bt1q"
@@ -196,8 +467,12 @@ func createAlbumsTable() error {
 		release_time DATETIME,
 		genre VARCHAR(100),
 		description TEXT,
+		label VARCHAR(255) NOT NULL DEFAULT '',
+		musicbrainz_id VARCHAR(36) NOT NULL DEFAULT '',
+		is_compilation TINYINT(1) NOT NULL DEFAULT 0,
 		created_at DATETIME NOT NULL,
 		updated_at DATETIME NOT NULL,
+		deleted_at DATETIME NULL,
 		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
 		INDEX idx_user_id (user_id)
 	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -218,6 +493,8 @@ func createAlbumTracksTable() error {
 		album_id BIGINT NOT NULL,
 		track_id BIGINT NOT NULL,
 		position INT NOT NULL DEFAULT 0,
+		disc_number INT NOT NULL DEFAULT 1,
+		track_number INT NOT NULL DEFAULT 0,
 		created_at DATETIME NOT NULL,
 		updated_at DATETIME NOT NULL,
 		FOREIGN KEY (album_id) REFERENCES albums(id) ON DELETE CASCADE,
@@ -257,3 +534,439 @@ func createNeteaseSongTable() error {
 	log.Println("netease_song table initialized successfully.")
 	return nil
 }
+
+func createTrackFingerprintsTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS track_fingerprints (
+		track_id BIGINT PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		duration FLOAT NOT NULL DEFAULT 0,
+		fingerprint MEDIUMTEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE,
+		INDEX idx_user_id (user_id),
+		INDEX idx_user_duration (user_id, duration)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	_, err := DB.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to create track_fingerprints table: %w", err)
+	}
+	log.Println("track_fingerprints table initialized successfully.")
+	return nil
+}
+
+func createTagsTables() error {
+	tagsQuery := `
+	CREATE TABLE IF NOT EXISTS tags (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		name VARCHAR(50) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE KEY uq_user_tag (user_id, name),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(tagsQuery); err != nil {
+		return fmt.Errorf("failed to create tags table: %w", err)
+	}
+
+	trackTagsQuery := `
+	CREATE TABLE IF NOT EXISTS track_tags (
+		track_id BIGINT NOT NULL,
+		tag_id BIGINT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (track_id, tag_id),
+		INDEX idx_tag_id (tag_id),
+		FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(trackTagsQuery); err != nil {
+		return fmt.Errorf("failed to create track_tags table: %w", err)
+	}
+	log.Println("tags and track_tags tables initialized successfully.")
+	return nil
+}
+
+// createPlayHistoryTable 创建播放历史表，每次收听一首歌记录一行
+func createPlayHistoryTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS play_history (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		source VARCHAR(20) NOT NULL,
+		source_id VARCHAR(64) NOT NULL,
+		title VARCHAR(255) NOT NULL DEFAULT '',
+		artist VARCHAR(255) NOT NULL DEFAULT '',
+		album VARCHAR(255) NOT NULL DEFAULT '',
+		duration FLOAT NOT NULL DEFAULT 0,
+		listened FLOAT NOT NULL DEFAULT 0,
+		scrobbled TINYINT(1) NOT NULL DEFAULT 0,
+		started_at DATETIME NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		INDEX idx_user_started (user_id, started_at),
+		INDEX idx_source_started (source, started_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create play_history table: %w", err)
+	}
+	log.Println("play_history table initialized successfully.")
+	return nil
+}
+
+// createPlayCountsTable 创建每日播放次数表，每首歌每天一行，由 Redis 中的当日计数每晚汇总写入
+func createPlayCountsTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS play_counts (
+		day DATE NOT NULL,
+		source VARCHAR(20) NOT NULL,
+		source_id VARCHAR(64) NOT NULL,
+		plays INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (source, source_id, day),
+		INDEX idx_source_day (source, day)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create play_counts table: %w", err)
+	}
+	log.Println("play_counts table initialized successfully.")
+	return nil
+}
+
+// createUserBandwidthTable 创建每日用户流量表，每位用户每天一行，由 Redis 中的当日流量定时汇总写入
+func createUserBandwidthTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS user_bandwidth (
+		user_id BIGINT NOT NULL,
+		day DATE NOT NULL,
+		bytes BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (user_id, day),
+		INDEX idx_day (day)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create user_bandwidth table: %w", err)
+	}
+	log.Println("user_bandwidth table initialized successfully.")
+	return nil
+}
+
+// createListeningTables 创建每日收听时长表和连续达标记录表，由播放历史定时汇总得到
+func createListeningTables() error {
+	daysQuery := `
+	CREATE TABLE IF NOT EXISTS user_listening_days (
+		user_id BIGINT NOT NULL,
+		day DATE NOT NULL,
+		seconds BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (user_id, day)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(daysQuery); err != nil {
+		return fmt.Errorf("failed to create user_listening_days table: %w", err)
+	}
+
+	streaksQuery := `
+	CREATE TABLE IF NOT EXISTS user_streaks (
+		user_id BIGINT PRIMARY KEY,
+		start_day DATE NULL,
+		current_days INT NOT NULL DEFAULT 0,
+		longest_days INT NOT NULL DEFAULT 0,
+		notified_days INT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(streaksQuery); err != nil {
+		return fmt.Errorf("failed to create user_streaks table: %w", err)
+	}
+	log.Println("user_listening_days and user_streaks tables initialized successfully.")
+	return nil
+}
+
+// createTrackCommentsTable 创建曲目评论表，本地曲目和网易云歌曲按 source/source_id 区分
+func createTrackCommentsTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS track_comments (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		source VARCHAR(20) NOT NULL,
+		source_id VARCHAR(64) NOT NULL,
+		content TEXT NOT NULL,
+		position FLOAT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		INDEX idx_source_created (source, source_id, created_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create track_comments table: %w", err)
+	}
+	log.Println("track_comments table initialized successfully.")
+	return nil
+}
+
+// createTrackVersionsTable 创建曲目音频版本表，记录被替换的源音频和流，删除曲目时级联删除
+func createTrackVersionsTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS track_versions (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		track_id BIGINT NOT NULL,
+		user_id BIGINT NOT NULL,
+		file_path VARCHAR(255) NOT NULL DEFAULT '',
+		hls_playlist_path VARCHAR(255) NOT NULL DEFAULT '',
+		content_hash CHAR(64) NOT NULL DEFAULT '',
+		file_size BIGINT NOT NULL DEFAULT 0,
+		duration FLOAT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE,
+		INDEX idx_track_created (track_id, created_at),
+		INDEX idx_expires (expires_at),
+		INDEX idx_content_hash (content_hash)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create track_versions table: %w", err)
+	}
+	log.Println("track_versions table initialized successfully.")
+	return nil
+}
+
+// createJWTSigningKeysTable 创建 JWT 签名密钥表，多个实例共用同一组密钥并按计划轮换
+func createJWTSigningKeysTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+		kid VARCHAR(32) PRIMARY KEY,
+		algorithm VARCHAR(16) NOT NULL,
+		private_key TEXT NOT NULL,
+		activates_at DATETIME NOT NULL,
+		retired_at DATETIME NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_activates (activates_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create jwt_signing_keys table: %w", err)
+	}
+	log.Println("jwt_signing_keys table initialized successfully.")
+	return nil
+}
+
+// createStorageRefsTable 创建按内容哈希共享存储的引用锁表
+// 释放共享存储时锁住对应行再检查引用并删除对象，上传复用已有存储前先在同一行登记预留
+func createStorageRefsTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS storage_refs (
+		content_hash CHAR(64) PRIMARY KEY,
+		reserved_until DATETIME NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create storage_refs table: %w", err)
+	}
+	log.Println("storage_refs table initialized successfully.")
+	return nil
+}
+
+// createSocialTables 创建用户关注关系表和动态表
+func createSocialTables() error {
+	followsQuery := `
+	CREATE TABLE IF NOT EXISTS user_follows (
+		follower_id BIGINT NOT NULL,
+		followee_id BIGINT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (follower_id, followee_id),
+		INDEX idx_followee (followee_id),
+		FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (followee_id) REFERENCES users(id) ON DELETE CASCADE
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(followsQuery); err != nil {
+		return fmt.Errorf("failed to create user_follows table: %w", err)
+	}
+
+	activitiesQuery := `
+	CREATE TABLE IF NOT EXISTS user_activities (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		type VARCHAR(30) NOT NULL,
+		object_id VARCHAR(64) NOT NULL,
+		source VARCHAR(20) NOT NULL DEFAULT '',
+		source_id VARCHAR(64) NOT NULL DEFAULT '',
+		title VARCHAR(255) NOT NULL DEFAULT '',
+		content TEXT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_user_id (user_id, id),
+		INDEX idx_type_object (type, object_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(activitiesQuery); err != nil {
+		return fmt.Errorf("failed to create user_activities table: %w", err)
+	}
+	log.Println("user_follows and user_activities tables initialized successfully.")
+	return nil
+}
+
+// createAnnouncementTables 创建公告表、公告已读记录表、指定用户公告的用户表和公告历史版本表
+func createAnnouncementTables() error {
+	announcementsQuery := `
+	CREATE TABLE IF NOT EXISTS announcements (
+		id VARCHAR(36) PRIMARY KEY,
+		title VARCHAR(255) NOT NULL,
+		content TEXT NOT NULL,
+		version VARCHAR(50) NOT NULL,
+		type VARCHAR(20) NOT NULL DEFAULT 'info',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		created_by BIGINT NULL,
+		is_active BOOLEAN NOT NULL DEFAULT TRUE,
+		priority INT NOT NULL DEFAULT 0,
+		publish_at DATETIME NULL,
+		expire_at DATETIME NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'published',
+		audience VARCHAR(20) NOT NULL DEFAULT 'all',
+		joined_after DATETIME NULL,
+		INDEX idx_active_status (is_active, status)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(announcementsQuery); err != nil {
+		return fmt.Errorf("failed to create announcements table: %w", err)
+	}
+
+	readsQuery := `
+	CREATE TABLE IF NOT EXISTS user_announcement_reads (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		announcement_id VARCHAR(36) NOT NULL,
+		read_at DATETIME NOT NULL,
+		UNIQUE KEY uk_user_announcement (user_id, announcement_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(readsQuery); err != nil {
+		return fmt.Errorf("failed to create user_announcement_reads table: %w", err)
+	}
+
+	targetsQuery := `
+	CREATE TABLE IF NOT EXISTS announcement_targets (
+		announcement_id VARCHAR(36) NOT NULL,
+		user_id BIGINT NOT NULL,
+		PRIMARY KEY (announcement_id, user_id),
+		INDEX idx_user_id (user_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(targetsQuery); err != nil {
+		return fmt.Errorf("failed to create announcement_targets table: %w", err)
+	}
+
+	revisionsQuery := `
+	CREATE TABLE IF NOT EXISTS announcement_revisions (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		announcement_id VARCHAR(36) NOT NULL,
+		title VARCHAR(255) NOT NULL,
+		content TEXT NOT NULL,
+		version VARCHAR(50) NOT NULL,
+		type VARCHAR(20) NOT NULL,
+		edited_by BIGINT NULL,
+		edited_at DATETIME NOT NULL,
+		substantive BOOLEAN NOT NULL DEFAULT FALSE,
+		INDEX idx_announcement_id (announcement_id, id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(revisionsQuery); err != nil {
+		return fmt.Errorf("failed to create announcement_revisions table: %w", err)
+	}
+	log.Println("announcement tables initialized successfully.")
+	return nil
+}
+
+// createNotificationsTable 创建用户通知表，read_at 为空表示未读
+func createNotificationsTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS notifications (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		type VARCHAR(30) NOT NULL,
+		actor_id BIGINT NULL,
+		object_id VARCHAR(64) NOT NULL,
+		title VARCHAR(255) NOT NULL DEFAULT '',
+		content TEXT NULL,
+		read_at DATETIME NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_user_id (user_id, id),
+		INDEX idx_user_read (user_id, read_at),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create notifications table: %w", err)
+	}
+	log.Println("notifications table initialized successfully.")
+	return nil
+}
+
+// createScrobbleAccountsTable 创建用户绑定的 Last.fm / ListenBrainz 账号表
+func createScrobbleAccountsTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS scrobble_accounts (
+		user_id BIGINT NOT NULL,
+		service VARCHAR(20) NOT NULL,
+		username VARCHAR(100) NOT NULL DEFAULT '',
+		session_key VARCHAR(255) NOT NULL,
+		api_key VARCHAR(64) NOT NULL DEFAULT '',
+		api_secret VARCHAR(64) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, service),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create scrobble_accounts table: %w", err)
+	}
+	log.Println("scrobble_accounts table initialized successfully.")
+	return nil
+}
+
+// createChatTables 创建 AI 聊天会话和消息表，删除会话时级联删除消息
+func createChatTables() error {
+	sessionsQuery := `
+	CREATE TABLE IF NOT EXISTS chat_sessions (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		title VARCHAR(255) NOT NULL DEFAULT '',
+		archived_at DATETIME NULL,
+		summary TEXT NULL,
+		summary_until BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_user_updated (user_id, updated_at),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(sessionsQuery); err != nil {
+		return fmt.Errorf("failed to create chat_sessions table: %w", err)
+	}
+
+	messagesQuery := `
+	CREATE TABLE IF NOT EXISTS chat_messages (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		session_id BIGINT NOT NULL,
+		role VARCHAR(20) NOT NULL,
+		content TEXT NOT NULL,
+		songs JSON NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_session_created (session_id, created_at),
+		FOREIGN KEY (session_id) REFERENCES chat_sessions(id) ON DELETE CASCADE
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+	if _, err := DB.Exec(messagesQuery); err != nil {
+		return fmt.Errorf("failed to create chat_messages table: %w", err)
+	}
+	log.Println("chat_sessions and chat_messages tables initialized successfully.")
+	return nil
+}
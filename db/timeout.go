@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	// queryTimeout 单条查询（按主键查询、更新等）的超时
+	queryTimeout = 5 * time.Second
+	// listQueryTimeout 列表查询的超时，扫描行数多，给得更宽松
+	listQueryTimeout = 30 * time.Second
+)
+
+// SetQueryTimeouts 设置查询超时，<=0 表示不额外限制，只跟随调用方 context
+func SetQueryTimeouts(query, list time.Duration) {
+	queryTimeout = query
+	listQueryTimeout = list
+}
+
+// WithQueryTimeout 为单条查询附加超时
+// 传入请求的 context 时，客户端断开连接也会取消查询
+func WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, queryTimeout)
+}
+
+// WithListTimeout 为列表查询附加超时
+func WithListTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, listQueryTimeout)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
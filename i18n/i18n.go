@@ -0,0 +1,118 @@
+// Package i18n 服务端响应文案的本地化
+//
+// 现有代码中的文案中英文混杂，消息目录以原始文案为键：中文目录收录英文原文的译文，
+// 英文目录收录中文原文的译文，调用方无需改动原有文案即可按请求语言输出。
+package i18n
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 支持的语言
+const (
+	ZhCN = "zh-CN"
+	En   = "en"
+
+	// Default 无法从请求和用户偏好中确定语言时使用的语言
+	Default = ZhCN
+)
+
+// Supported 支持的语言列表
+var Supported = []string{ZhCN, En}
+
+// catalogs 各语言的消息目录：原始文案 -> 译文
+var catalogs = map[string]map[string]string{
+	ZhCN: zhMessages,
+	En:   enMessages,
+}
+
+// Normalize 将语言标签归一化为支持的语言，如 en-US -> en、zh-Hans-CN -> zh-CN，不支持时返回空串
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return ""
+	}
+	primary, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	switch primary {
+	case "zh":
+		return ZhCN
+	case "en":
+		return En
+	}
+	return ""
+}
+
+// FromAcceptLanguage 按 q 值从 Accept-Language 请求头中选出第一个支持的语言，都不支持时返回空串
+func FromAcceptLanguage(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		lang := Normalize(fields[0])
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if v, ok := strings.CutPrefix(param, "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{lang, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// Translate 查找文案在 lang 下的译文，目录中没有时 ok 为 false
+func Translate(lang, message string) (string, bool) {
+	translated, ok := catalogs[lang][message]
+	return translated, ok
+}
+
+// T 返回文案在 lang 下的译文，目录中没有时原样返回
+func T(lang, message string) string {
+	if translated, ok := Translate(lang, message); ok {
+		return translated
+	}
+	return message
+}
+
+// Matches 判断文案是否已经是 lang 对应的语言：含汉字视为中文，否则视为英文
+func Matches(lang, message string) bool {
+	hasHan := strings.IndexFunc(message, func(r rune) bool { return unicode.Is(unicode.Han, r) }) >= 0
+	if lang == ZhCN {
+		return hasHan
+	}
+	return !hasHan
+}
+
+type contextKey struct{}
+
+// WithLanguage 将请求语言写入 context，供 handler 之外的代码（如 AI 助手）选择文案
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, contextKey{}, lang)
+}
+
+// FromContext 读取 context 中的请求语言，未设置时返回 Default
+func FromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(contextKey{}).(string); ok && lang != "" {
+		return lang
+	}
+	return Default
+}
@@ -0,0 +1,107 @@
+package i18n
+
+// enMessages 中文原文的英文译文
+var enMessages = map[string]string{
+	// 错误码说明
+	"请求参数不合法":         "Invalid request parameters",
+	"请求体无法解析":         "The request body could not be parsed",
+	"路径或参数中的 ID 格式错误": "Malformed ID in the path or parameters",
+	"缺少必填字段":          "A required field is missing",
+	"资源不存在":           "Resource not found",
+	"不支持的请求方法":        "Method not allowed",
+	"与当前状态冲突":         "Conflicts with the current state",
+	"请求超时":            "Request timed out",
+	"请求过于频繁，details.retryAfter 为需要等待的秒数": "Too many requests, details.retryAfter is the number of seconds to wait",
+	"服务器内部错误":   "Internal server error",
+	"服务繁忙，稍后重试": "Service is busy, please try again later",
+	"使用同一 Idempotency-Key 的请求仍在处理中，稍后重试":      "A request with the same Idempotency-Key is still being processed, please retry later",
+	"Idempotency-Key 已用于内容不同的请求":              "The Idempotency-Key was already used for a different request",
+	"未登录或缺少认证信息":                              "Not logged in or missing credentials",
+	"Token 无效或已过期":                            "Token is invalid or expired",
+	"用户名或密码错误":                                "Invalid username or password",
+	"用户名或邮箱已被注册":                              "Username or email is already registered",
+	"用户不存在":                                   "User not found",
+	"没有权限访问该资源":                               "You are not allowed to access this resource",
+	"签名无效或已过期":                                "Signature is invalid or expired",
+	"重置密码链接无效、已过期或已被使用":                       "The password reset link is invalid, expired or already used",
+	"邮箱尚未验证，验证后才能执行该操作":                       "Please verify your email before doing this",
+	"邮箱验证链接无效、已过期或已被使用":                       "The verification link is invalid, expired or already used",
+	"账号已被管理员禁用":                               "The account has been disabled by an administrator",
+	"曲目不存在":                                   "Track not found",
+	"音频已存在于曲库中，details.duplicateOf 为重复的曲目 ID": "The audio already exists in the library, details.duplicateOf is the duplicated track ID",
+	"文件超过大小限制":                                "File exceeds the size limit",
+	"不支持的文件类型":                                "Unsupported file type",
+	"封面图片无效":                                  "Invalid cover image",
+	"超出用户存储配额，details 中包含已用、配额、剩余和本次所需字节数": "Storage quota exceeded, details contains the used, quota, remaining and required bytes",
+	"专辑不存在":                             "Album not found",
+	"房间不存在":                             "Room not found",
+	"公告不存在":                             "Announcement not found",
+	"目标设备不在线":                           "The target device is offline",
+	"投屏设备不存在，需重新搜索":                     "Renderer not found, refresh the renderer list",
+	"投屏设备拒绝了请求或无法连接":                    "The renderer rejected the request or could not be reached",
+	"聊天会话不存在":                           "Chat session not found",
+	"Last.fm 或 ListenBrainz 拒绝了提供的账号信息": "Last.fm or ListenBrainz rejected the provided account",
+	"Last.fm 或 ListenBrainz 暂时无法访问":     "Last.fm or ListenBrainz is temporarily unreachable",
+	"AI 电台暂时无法生成续播歌曲":                   "The AI radio cannot pick the next songs right now",
+	"对象存储不可用":                           "Object storage is not available",
+	"流尚未生成或分片未就绪":                       "The stream or segment is not ready yet",
+	"流正在转码，稍后重试":                        "The stream is being transcoded, please retry later",
+
+	// 接口错误信息
+	"未授权":           "Unauthorized",
+	"未授权访问":         "Unauthorized",
+	"无效的请求":         "Invalid request",
+	"请求参数错误":        "Invalid request body",
+	"必填字段不能为空":      "Required fields must not be empty",
+	"用户ID格式错误":      "Invalid user ID",
+	"需要管理员权限":       "Administrator permission is required",
+	"获取用户信息失败":      "Failed to get user information",
+	"获取统计信息失败":      "Failed to get statistics",
+	"通知服务不可用":       "Notification service is not available",
+	"房间ID不能为空":      "Room ID is required",
+	"房间ID和用户ID不能为空": "Room ID and user ID are required",
+	"验证房间成员失败":      "Failed to verify room membership",
+	"您不是该房间的成员":     "You are not a member of this room",
+	"您没有参与过该房间":     "You have never joined this room",
+	"只有房间成员可以邀请":    "Only room members can invite",
+	"不能邀请自己":        "You cannot invite yourself",
+	"该用户已在房间中":      "The user is already in the room",
+	"发送邀请失败":        "Failed to send the invitation",
+	"房间尚未解散，暂无总结":   "The room is still open, no summary yet",
+	"获取房间总结失败":      "Failed to get the room summary",
+	"歌曲ID和名称不能为空":   "Song ID and name are required",
+	"公告ID不能为空":      "Announcement ID is required",
+	"公告类型无效":        "Invalid announcement type",
+	"获取公告失败":        "Failed to get announcements",
+	"获取未读公告失败":      "Failed to get unread announcements",
+	"获取更新后的公告失败":    "Failed to get the updated announcement",
+	"获取公告历史失败":      "Failed to get the announcement history",
+	"标记已读失败":        "Failed to mark as read",
+	"创建公告失败":        "Failed to create the announcement",
+	"更新公告失败":        "Failed to update the announcement",
+	"删除公告失败":        "Failed to delete the announcement",
+
+	// 公告校验
+	"过期时间必须晚于当前时间":        "expireAt must be in the future",
+	"过期时间必须晚于发布时间":        "expireAt must be later than publishAt",
+	"指定用户的公告需要提供 userIds": "userIds is required when the audience is users",
+	"userIds 包含无效的用户ID":   "userIds contains an invalid user ID",
+	"公告受众无效":              "Invalid announcement audience",
+	"公告内容中的代码块没有闭合":       "A code block in the announcement content is not closed",
+
+	// 公告接口
+	"标记已读成功": "Marked as read",
+	"创建公告成功": "Announcement created",
+	"更新公告成功": "Announcement updated",
+	"删除公告成功": "Announcement deleted",
+
+	// 账号锁定通知
+	"账号因多次登录失败已被临时锁定": "Your account has been temporarily locked after too many failed login attempts",
+	"账号在 %[1]d 分钟内连续 %[2]d 次登录失败（最后一次来自 %[3]s），已锁定 %[4]d 分钟。如果不是本人操作，建议尽快修改密码。": "There were %[2]d failed login attempts on your account within %[1]d minutes (the last one from %[3]s), so it has been locked for %[4]d minutes. If this wasn't you, please change your password soon.",
+
+	// 公告类型
+	"信息": "Info",
+	"警告": "Warning",
+	"成功": "Success",
+	"错误": "Error",
+}
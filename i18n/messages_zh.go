@@ -0,0 +1,310 @@
+package i18n
+
+// zhMessages 英文原文的中文译文
+var zhMessages = map[string]string{
+	"Unauthorized":                       "未授权",
+	"Invalid request body":               "请求体格式错误",
+	"Method not allowed":                 "不支持的请求方法",
+	"User not found":                     "用户不存在",
+	"Track not found":                    "曲目不存在",
+	"Invalid track ID":                   "曲目ID格式错误",
+	"Internal server error":              "服务器内部错误",
+	"Invalid album ID":                   "专辑ID格式错误",
+	"Forbidden":                          "没有权限",
+	"Failed to update preferences":       "更新偏好设置失败",
+	"Failed to get track":                "获取曲目失败",
+	"Storage not available":              "存储服务不可用",
+	"File not found":                     "文件不存在",
+	"Invalid limit":                      "limit 参数无效",
+	"Failed to get album":                "获取专辑失败",
+	"Invalid user ID":                    "用户ID格式错误",
+	"Failed to process uploaded file.":   "处理上传的文件失败。",
+	"Failed to get preferences":          "获取偏好设置失败",
+	"Album not found":                    "专辑不存在",
+	"Only POST method is allowed":        "只支持 POST 请求",
+	"License text too long":              "版权信息过长",
+	"Invalid username/email or password": "用户名/邮箱或密码错误",
+	"Captcha verification required":      "需要完成人机验证",
+	"Captcha verification failed":        "人机验证未通过",
+	"Captcha service unavailable":        "人机验证服务暂不可用",
+	"Account is temporarily locked due to too many failed login attempts": "登录失败次数过多，账号已被临时锁定",
+	"Too many failed login attempts, please try again later":              "登录失败次数过多，请稍后再试",
+	"Invalid stream path":                       "流路径无效",
+	"Failed to update chat session":             "更新聊天会话失败",
+	"Failed to read uploaded object":            "读取上传的文件失败",
+	"Failed to get tracks":                      "获取曲目列表失败",
+	"Failed to get comment":                     "获取评论失败",
+	"Track stream not ready":                    "曲目的流尚未就绪",
+	"Track has no stored source file":           "曲目没有保存源文件",
+	"Track file not found":                      "曲目文件不存在",
+	"Title is too long":                         "标题过长",
+	"Social service is not available":           "社交服务不可用",
+	"Password reset is temporarily unavailable": "重置密码功能暂时不可用",
+	"Invalid token":                             "Token 无效",
+	"Invalid sourceId":                          "sourceId 无效",
+	"Invalid or expired signature":              "签名无效或已过期",
+	"Invalid offset":                            "offset 参数无效",
+	"Invalid file type. Supported formats: MP3, WAV, FLAC, AAC, M4A.":                                                                "文件类型无效，支持的格式：MP3、WAV、FLAC、AAC、M4A。",
+	"File content is not a supported audio format. Supported formats: MP3, WAV, FLAC, AAC, M4A.":                                     "文件内容不是支持的音频格式，支持的格式：MP3、WAV、FLAC、AAC、M4A。",
+	"File content does not match its declared type. Rename the file with the correct extension or convert it to a supported format.": "文件内容与声明的类型不符，请使用正确的扩展名重命名文件，或转换为支持的格式后重新上传。",
+	"The uploaded file is empty.": "上传的文件为空。",
+	"The file could not be parsed as audio. It may be corrupted; re-export or re-download it and try again.": "无法解析该音频文件，文件可能已损坏，请重新导出或重新下载后再试。",
+	"The file contains no audio stream. Please upload an audio file.":                                        "文件中没有音频流，请上传音频文件。",
+	"The audio has no playable content. Please check the file and try again.":                                "音频没有可播放的内容，请检查文件后重试。",
+	"The file appears to be truncated. Re-download or re-export it, then upload it again.":                   "文件似乎不完整（已被截断），请重新下载或重新导出后再上传。",
+	"Invalid before":                                                           "before 参数无效",
+	"Failed to upload cover to storage":                                        "上传封面到存储失败",
+	"Failed to update netease info":                                            "更新网易云信息失败",
+	"Failed to unsubscribe":                                                    "退订失败",
+	"Failed to process password":                                               "处理密码失败",
+	"Failed to parse form":                                                     "解析表单失败",
+	"Failed to get user stats":                                                 "获取用户统计失败",
+	"Failed to get trash":                                                      "获取回收站失败",
+	"Failed to get track tags":                                                 "获取曲目标签失败",
+	"Failed to get playlist":                                                   "获取播放列表失败",
+	"Failed to get notifications":                                              "获取通知失败",
+	"Failed to get album tracks":                                               "获取专辑曲目失败",
+	"Failed to create track entry in database":                                 "在数据库中创建曲目失败",
+	"Email verification is temporarily unavailable":                            "邮箱验证功能暂时不可用",
+	"Email is required":                                                        "邮箱不能为空",
+	"Duplicate track: this audio already exists in your library":               "重复的曲目：该音频已在你的曲库中",
+	"Chat session not found":                                                   "聊天会话不存在",
+	"Backup service is not available":                                          "备份服务不可用",
+	"Account is disabled":                                                      "账号已被禁用",
+	"profile must be one of aac, flac, opus":                                   "profile 只能是 aac、flac 或 opus",
+	"crossfadeSeconds must be between 0 and 10":                                "crossfadeSeconds 必须在 0 到 10 之间",
+	"apiKey and apiSecret must be provided together":                           "apiKey 和 apiSecret 必须同时提供",
+	"Waveform not found":                                                       "波形数据不存在",
+	"Volume must be between 0 and 100":                                         "音量必须在 0 到 100 之间",
+	"Verification email is not available":                                      "验证邮件功能不可用",
+	"Username/Email and password are required":                                 "用户名/邮箱和密码不能为空",
+	"Username, password and email are required":                                "用户名、密码和邮箱不能为空",
+	"Username or email already exists":                                         "用户名或邮箱已存在",
+	"Username and password are required":                                       "用户名和密码不能为空",
+	"Uploaded object not found":                                                "上传的文件不存在",
+	"Upload timeout. Please try with a smaller file or check your connection.": "上传超时，请尝试较小的文件或检查网络连接。",
+	"Track does not have this tag":                                             "曲目没有这个标签",
+	"Track IDs list cannot be empty":                                           "曲目ID列表不能为空",
+	"Too many requests":                                                        "请求过于频繁",
+	"Token is required":                                                        "Token 不能为空",
+	"Token and password are required":                                          "Token 和密码不能为空",
+	"Title or archived is required":                                            "title 和 archived 至少需要一个",
+	"Title must not be empty":                                                  "标题不能为空",
+	"Title is required for netease songs":                                      "网易云歌曲需要提供标题",
+	"Stream ID is required":                                                    "流ID不能为空",
+	"Storage GC is already running":                                            "存储清理正在进行中",
+	"Storage GC failed":                                                        "存储清理失败",
+	"Storage reconciliation is already running":                                "存储对账正在进行中",
+	"Storage reconciliation failed":                                            "存储对账失败",
+	"Failed to upload audio to storage":                                        "上传音频到存储失败",
+	"Track verification failed":                                                "曲目完整性校验失败",
+	"Reprocessing is already running":                                          "批量重新转码正在进行中",
+	"Failed to find tracks to reprocess":                                       "查找需要重新转码的曲目失败",
+	"userId is required for scope user":                                        "scope 为 user 时必须提供 userId",
+	"albumId is required for scope album":                                      "scope 为 album 时必须提供 albumId",
+	"scope must be one of all, user, album":                                    "scope 必须是 all、user、album 之一",
+	"Track is still processing":                                                "曲目仍在转码中",
+	"The uploaded file is identical to the current audio":                      "上传的文件与当前音频相同",
+	"Another track in your library already uses this audio":                    "曲库中已有其他曲目使用该音频",
+	"Failed to replace track audio":                                            "替换曲目音频失败",
+	"Failed to get track versions":                                             "获取曲目版本失败",
+	"Invalid version ID":                                                       "无效的版本 ID",
+	"Track version not found":                                                  "曲目版本不存在",
+	"Failed to restore track version":                                          "恢复曲目版本失败",
+	"MusicBrainz is temporarily unavailable":                                   "MusicBrainz 暂时无法访问",
+	"No matching release found on MusicBrainz":                                 "MusicBrainz 上没有找到匹配的发行版",
+	"Release not found on MusicBrainz":                                         "MusicBrainz 上不存在该发行版",
+	"Label is too long":                                                        "厂牌名称过长",
+	"Invalid MusicBrainz release ID":                                           "无效的 MusicBrainz 发行版 ID",
+	"Invalid release date":                                                     "无效的发行日期",
+	"Track is not in this album":                                               "曲目不在该专辑中",
+	"Invalid track placement":                                                  "无效的碟号或序号",
+	"Failed to apply album enrichment":                                         "应用专辑元数据补全失败",
+	"Failed to get bandwidth usage":                                            "获取流量统计失败",
+	"Failed to encode response":                                                "编码响应失败",
+	"Invalid status":                                                           "status 参数无效",
+	"Invalid source":                                                           "source 参数无效",
+	"Invalid from date":                                                        "from 日期无效",
+	"Invalid to date":                                                          "to 日期无效",
+	"Invalid sort field":                                                       "sort 参数无效",
+	"Invalid sort order":                                                       "order 参数无效",
+	"Too many tracks in one request":                                           "一次添加的歌曲过多",
+	"Either repeat or shuffle must be provided":                                "repeat 和 shuffle 至少提供一个",
+	"Invalid repeat mode":                                                      "循环模式无效",
+	"Minutes must be between 1 and 720":                                        "分钟数必须在 1 到 720 之间",
+	"Failed to set sleep timer":                                                "设置睡眠定时失败",
+	"Failed to get sleep timer":                                                "获取睡眠定时失败",
+	"Failed to cancel sleep timer":                                             "取消睡眠定时失败",
+	"Daily mix not found":                                                      "Daily Mix 不存在",
+	"Failed to get daily mixes":                                                "获取 Daily Mix 失败",
+	"Failed to refresh daily mixes":                                            "刷新 Daily Mix 失败",
+	"Failed to add track to playlist":                                          "添加到播放列表失败",
+	"Failed to get listening streak":                                           "获取连续收听天数失败",
+	"Source and sourceId must be provided together":                            "source 和 sourceId 必须同时提供",
+	"Server is busy, please try again later":                                   "服务器繁忙，请稍后重试",
+	"Segment not ready":                                                        "分片尚未就绪",
+	"Room service not available":                                               "房间服务不可用",
+	"Renderer rejected the media":                                              "投屏设备拒绝了媒体",
+	"Renderer not found, refresh the renderer list":                            "投屏设备不存在，请重新搜索",
+	"Recommendations are disabled":                                             "推荐功能已关闭",
+	"Radio started but failed to queue songs, will retry while playing":        "电台已开启，但添加歌曲失败，播放时会重试",
+	"Processing in progress, please retry":                                     "正在处理中，请稍后重试",
+	"Position is required":                                                     "位置不能为空",
+	"Position is outside the song":                                             "位置超出了歌曲时长",
+	"Please verify your email before uploading":                                "请先验证邮箱再上传",
+	"Please verify your email before logging in":                               "请先验证邮箱再登录",
+	"Password reset email is not available":                                    "重置密码邮件功能不可用",
+	"Parent comment not found on this song":                                    "这首歌下没有找到被回复的评论",
+	"Only the author or an admin can delete this comment":                      "只有作者或管理员可以删除这条评论",
+	"Only image files are allowed":                                             "只允许上传图片文件",
+	"Only GET method is allowed":                                               "只支持 GET 请求",
+	"Notification service is not available":                                    "通知服务不可用",
+	"Notification not found":                                                   "通知不存在",
+	"No files uploaded":                                                        "没有上传文件",
+	"No fields to update":                                                      "没有需要更新的字段",
+	"Network connection issue. Please check your connection and try again.":    "网络连接异常，请检查网络后重试。",
+	"Netease songs can only be cast as hls":                                    "网易云歌曲只能以 hls 格式投屏",
+	"Missing token":                                                            "缺少 Token",
+	"Missing tag":                                                              "缺少标签",
+	"Missing audio file. Please select a file to upload.":                      "缺少音频文件，请选择要上传的文件。",
+	"Missing artist name":                                                      "缺少歌手名",
+	"Missing 'trackIds'":                                                       "缺少 trackIds",
+	"Missing 'title' in form":                                                  "表单中缺少 title",
+	"Missing 'title'":                                                          "缺少 title",
+	"Missing 'tags'":                                                           "缺少 tags",
+	"Missing 'content'":                                                        "缺少 content",
+	"Last.fm API key is not configured on this server, provide your own apiKey and apiSecret": "服务器未配置 Last.fm API Key，请提供自己的 apiKey 和 apiSecret",
+	"Item not found in trash":                                            "回收站中没有该项目",
+	"Invalid unsubscribe token":                                          "退订链接无效",
+	"Invalid type, expected 'track' or 'album'":                          "type 无效，只能是 track 或 album",
+	"Invalid stream ID":                                                  "流ID无效",
+	"You do not have access to this stream":                              "没有权限播放该流",
+	"Failed to check stream access":                                      "检查流访问权限失败",
+	"Invalid status, expected 'active', 'pending' or 'disabled'":         "status 无效，只能是 active、pending 或 disabled",
+	"Invalid source, expected local or netease":                          "source 无效，只能是 local 或 netease",
+	"Invalid song ID":                                                    "歌曲ID无效",
+	"Invalid releaseTime format":                                         "releaseTime 格式错误",
+	"Invalid position":                                                   "位置无效",
+	"Invalid or expired verification link":                               "验证链接无效或已过期",
+	"Invalid or expired stream signature":                                "流签名无效或已过期",
+	"Invalid or expired reset token":                                     "重置密码链接无效或已过期",
+	"Invalid or expired cast signature":                                  "投屏签名无效或已过期",
+	"Invalid object key":                                                 "对象 key 无效",
+	"Invalid notification ID":                                            "通知ID无效",
+	"Invalid format, expected original or mp3":                           "format 无效，只能是 original 或 mp3",
+	"Invalid format, expected hls or mp4":                                "format 无效，只能是 hls 或 mp4",
+	"Invalid deviceId":                                                   "deviceId 无效",
+	"Invalid days":                                                       "days 参数无效",
+	"Invalid credentials":                                                "用户名或密码错误",
+	"Invalid cover path":                                                 "封面路径无效",
+	"Invalid cover file type":                                            "封面文件类型无效",
+	"Invalid comment ID":                                                 "评论ID无效",
+	"Invalid command":                                                    "指令无效",
+	"Invalid authorization header format":                                "Authorization 请求头格式错误",
+	"Invalid action, expected play, pause, stop, seek or volume":         "action 无效，只能是 play、pause、stop、seek 或 volume",
+	"Invalid action, expected play, pause, seek, next, prev or transfer": "action 无效，只能是 play、pause、seek、next、prev 或 transfer",
+	"Invalid ID format":                                                  "ID 格式错误",
+	"Invalid ID":                                                         "ID 无效",
+	"Index must not be negative":                                         "序号不能为负数",
+	"File too large":                                                     "文件过大",
+	"Failed to verify email":                                             "验证邮箱失败",
+	"Failed to update user status":                                       "更新用户状态失败",
+	"Failed to update user profile":                                      "更新用户资料失败",
+	"Failed to update tracks":                                            "更新曲目失败",
+	"Unsupported import format, use CSV or JSON":                         "不支持的导入格式，请使用 CSV 或 JSON",
+	"CSV header must contain a title column":                             "CSV 表头中必须包含标题列",
+	"Too many rows, at most 2000 rows can be imported at once":           "行数过多，一次最多导入 2000 行",
+	"Invalid import file":                                                "导入文件格式错误",
+	"Invalid year":                                                       "年份无效",
+	"Failed to update track position":                                    "更新曲目位置失败",
+	"Failed to update license":                                           "更新版权信息失败",
+	"Failed to update album":                                             "更新专辑失败",
+	"Failed to unfollow user":                                            "取消关注失败",
+	"Failed to store object":                                             "保存文件失败",
+	"Failed to stop radio":                                               "关闭电台失败",
+	"Failed to start radio":                                              "开启电台失败",
+	"Failed to send command":                                             "发送指令失败",
+	"Failed to save track":                                               "保存曲目失败",
+	"Failed to save playback state":                                      "保存播放状态失败",
+	"Failed to save account":                                             "保存账号失败",
+	"Failed to restore":                                                  "恢复失败",
+	"Failed to reset password":                                           "重置密码失败",
+	"Failed to remove track from album":                                  "从专辑中移除曲目失败",
+	"Failed to remove tag":                                               "移除标签失败",
+	"Failed to read playlist":                                            "读取播放列表失败",
+	"Failed to read file":                                                "读取文件失败",
+	"Failed to read cover file":                                          "读取封面文件失败",
+	"Failed to reach":                                                    "无法连接",
+	"Failed to presign upload":                                           "生成上传地址失败",
+	"Failed to presign playlist":                                         "生成播放列表地址失败",
+	"Failed to presign download":                                         "生成下载地址失败",
+	"Failed to parse upload form. Please check your file and try again.": "解析上传表单失败，请检查文件后重试。",
+	"Failed to open file":                                               "打开文件失败",
+	"Failed to mark notifications read":                                 "标记通知已读失败",
+	"Failed to mark notification read":                                  "标记通知已读失败",
+	"Failed to list chat sessions":                                      "获取聊天会话列表失败",
+	"Failed to get users":                                               "获取用户列表失败",
+	"Failed to get user profile":                                        "获取用户资料失败",
+	"Failed to get trending songs":                                      "获取热门歌曲失败",
+	"Failed to get track information":                                   "获取曲目信息失败",
+	"Failed to get tags":                                                "获取标签失败",
+	"Failed to get storage quota":                                       "获取存储配额失败",
+	"Failed to get recommendations":                                     "获取推荐失败",
+	"Failed to get playback state":                                      "获取播放状态失败",
+	"Failed to get play history":                                        "获取播放历史失败",
+	"Failed to get follow stats":                                        "获取关注统计失败",
+	"Failed to get fingerprints":                                        "获取音频指纹失败",
+	"Failed to get feed":                                                "获取动态失败",
+	"Failed to get cover file":                                          "获取封面文件失败",
+	"Failed to get comments":                                            "获取评论失败",
+	"Failed to get backup status":                                       "获取备份状态失败",
+	"Failed to get albums":                                              "获取专辑列表失败",
+	"Failed to get accounts":                                            "获取账号失败",
+	"Failed to generate upload key":                                     "生成上传 key 失败",
+	"Failed to generate token":                                          "生成 Token 失败",
+	"Failed to follow user":                                             "关注失败",
+	"Failed to filter tracks by tag":                                    "按标签筛选曲目失败",
+	"Failed to discover renderers":                                      "搜索投屏设备失败",
+	"Failed to disconnect account":                                      "解绑账号失败",
+	"Failed to delete track":                                            "删除曲目失败",
+	"Failed to delete comment":                                          "删除评论失败",
+	"Failed to delete chat session":                                     "删除聊天会话失败",
+	"Failed to delete album":                                            "删除专辑失败",
+	"Failed to create user":                                             "创建用户失败",
+	"Failed to create track":                                            "创建曲目失败",
+	"Failed to create comment":                                          "发表评论失败",
+	"Failed to create chat session":                                     "创建聊天会话失败",
+	"Failed to create album":                                            "创建专辑失败",
+	"Failed to count unread notifications":                              "统计未读通知失败",
+	"Failed to compute storage usage":                                   "统计存储用量失败",
+	"Failed to commit transaction":                                      "提交事务失败",
+	"Failed to begin transaction":                                       "开启事务失败",
+	"Failed to add tracks to album":                                     "添加曲目到专辑失败",
+	"Failed to add track to album":                                      "添加曲目到专辑失败",
+	"Failed to add tags":                                                "添加标签失败",
+	"Encrypted HLS streams cannot be cast, use mp4":                     "加密的 HLS 流无法投屏，请使用 mp4",
+	"Encrypted HLS streams cannot be cast":                              "加密的 HLS 流无法投屏",
+	"Either source and sourceId, trackId or neteaseId must be provided": "必须提供 source 和 sourceId、trackId 或 neteaseId 之一",
+	"Either source and sourceId, trackId or neteaseId is required":      "必须提供 source 和 sourceId、trackId 或 neteaseId 之一",
+	"Device is not online":                                              "设备不在线",
+	"Device is busy, try again later":                                   "设备忙，请稍后重试",
+	"Cover file too large":                                              "封面文件过大",
+	"Comment not found":                                                 "评论不存在",
+	"Cannot follow yourself":                                            "不能关注自己",
+	"Both source and sourceId are required":                             "source 和 sourceId 都不能为空",
+	"Backup is already running":                                         "备份正在进行中",
+	"Authorization header or signature is required":                     "需要 Authorization 请求头或签名",
+	"Authorization header is required":                                  "缺少 Authorization 请求头",
+	"Artist and album are required":                                     "歌手和专辑不能为空",
+	"Album not found or unauthorized":                                   "专辑不存在或无权访问",
+	"Album has no downloadable tracks":                                  "专辑中没有可下载的曲目",
+	"language must be one of zh-CN, en":                                 "language 只能是 zh-CN 或 en",
+	"dailyGoalMinutes must be between 0 and 720":                        "dailyGoalMinutes 必须在 0 到 720 之间",
+	"Failed to generate API document":                                   "生成接口文档失败",
+	"Account not connected":                                             "账号未绑定",
+	"If the email is registered, a password reset link has been sent":   "如果该邮箱已注册，重置密码链接已发送",
+	"Idempotency-Key must be at most 255 characters":                    "Idempotency-Key 最长 255 个字符",
+	"Idempotency-Key was already used for a different request":          "Idempotency-Key 已用于内容不同的请求",
+	"A request with this Idempotency-Key is still being processed":      "使用该 Idempotency-Key 的请求仍在处理中",
+	"Failed to read request body":                                       "读取请求体失败",
+}
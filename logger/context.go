@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Field 日志字段
+type Field = zap.Field
+
+// contextFieldsKey context 中日志字段的键
+type contextFieldsKey struct{}
+
+// ContextWithFields 返回附加了日志字段的 context，之后通过 Ctx(ctx) 输出的日志都会带上这些字段
+func ContextWithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	existing := FieldsFromContext(ctx)
+	merged := make([]zap.Field, 0, len(existing)+len(fields))
+	merged = append(merged, existing...)
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, contextFieldsKey{}, merged)
+}
+
+// FieldsFromContext 返回 context 中的日志字段
+func FieldsFromContext(ctx context.Context) []zap.Field {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(contextFieldsKey{}).([]zap.Field)
+	return fields
+}
+
+// ContextLogger 自动附加 context 中日志字段（如请求 ID、用户 ID）的 logger
+type ContextLogger struct {
+	fields []zap.Field
+}
+
+// Ctx 返回带上 context 中日志字段的 logger
+func Ctx(ctx context.Context) *ContextLogger {
+	return &ContextLogger{fields: FieldsFromContext(ctx)}
+}
+
+// with 合并 context 字段与本次调用的字段
+func (l *ContextLogger) with(fields []zap.Field) []zap.Field {
+	if len(l.fields) == 0 {
+		return fields
+	}
+	merged := make([]zap.Field, 0, len(l.fields)+len(fields))
+	merged = append(merged, l.fields...)
+	return append(merged, fields...)
+}
+
+// Debug 输出调试级别日志
+func (l *ContextLogger) Debug(msg string, fields ...zap.Field) {
+	if globalLogger != nil {
+		globalLogger.Debug(msg, l.with(fields)...)
+	}
+}
+
+// Info 输出信息级别日志
+func (l *ContextLogger) Info(msg string, fields ...zap.Field) {
+	if globalLogger != nil {
+		globalLogger.Info(msg, l.with(fields)...)
+	}
+}
+
+// Warn 输出警告级别日志
+func (l *ContextLogger) Warn(msg string, fields ...zap.Field) {
+	if globalLogger != nil {
+		globalLogger.Warn(msg, l.with(fields)...)
+	}
+}
+
+// Error 输出错误级别日志
+func (l *ContextLogger) Error(msg string, fields ...zap.Field) {
+	if globalLogger != nil {
+		globalLogger.Error(msg, l.with(fields)...)
+	}
+}
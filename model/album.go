@@ -2,31 +2,103 @@ package model
 
 import (
 	"database/sql"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Album 表示一张专辑
 type Album struct {
-	ID          int64          `json:"id"`
-	UserID      int64          `json:"userId"`
-	Artist      string         `json:"artist"`
-	Name        string         `json:"name"`
-	CoverPath   string         `json:"coverPath"`
-	ReleaseTime time.Time      `json:"releaseTime"`
-	Genre       string         `json:"genre"`
-	Description sql.NullString `json:"description"`
-	CreatedAt   time.Time      `json:"createdAt"`
-	UpdatedAt   time.Time      `json:"updatedAt"`
+	ID            int64          `json:"id"`
+	UserID        int64          `json:"userId"`
+	Artist        string         `json:"artist"`
+	Name          string         `json:"name"`
+	CoverPath     string         `json:"coverPath"`
+	ReleaseTime   time.Time      `json:"releaseTime"`
+	Genre         string         `json:"genre"`
+	Description   sql.NullString `json:"description"`
+	Label         string         `json:"label"`                   // 唱片厂牌，可从 MusicBrainz 补全
+	MusicBrainzID string         `json:"musicbrainzId,omitempty"` // 补全元数据时确认的 MusicBrainz 发行版 ID
+	IsCompilation bool           `json:"isCompilation"`           // 合辑（群星），曲目保留各自的艺术家
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	DeletedAt     *time.Time     `json:"deletedAt,omitempty"` // 移入回收站的时间
 }
 
+// VariousArtists 合辑未填写艺术家时使用的专辑艺术家
+const VariousArtists = "Various Artists"
+
 // AlbumTrack 表示专辑中的一首歌曲
 type AlbumTrack struct {
-	ID        int64     `json:"id"`
-	AlbumID   int64     `json:"albumId"`
-	TrackID   int64     `json:"trackId"`
-	Position  int       `json:"position"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID          int64     `json:"id"`
+	AlbumID     int64     `json:"albumId"`
+	TrackID     int64     `json:"trackId"`
+	Position    int       `json:"position"`    // 在专辑中的整体顺序，碟号和曲号相同时按此排序
+	DiscNumber  int       `json:"discNumber"`  // 碟号，从 1 开始
+	TrackNumber int       `json:"trackNumber"` // 碟内曲号，0 表示未知
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// AlbumTrackPlacement 曲目在专辑中的碟号、曲号和整体顺序
+type AlbumTrackPlacement struct {
+	TrackID     int64 `json:"trackId"`
+	DiscNumber  int   `json:"discNumber"`
+	TrackNumber int   `json:"trackNumber"`
+	Position    int   `json:"position"`
+}
+
+// AlbumTrackLess 专辑曲目的排序规则：先按碟号，同一张碟内有曲号的排在没有曲号的前面并按曲号排序，
+// 其余按整体顺序。与 GetAlbumTracks 的 ORDER BY 保持一致
+func AlbumTrackLess(a, b AlbumTrackPlacement) bool {
+	if a.DiscNumber != b.DiscNumber {
+		return a.DiscNumber < b.DiscNumber
+	}
+	if (a.TrackNumber == 0) != (b.TrackNumber == 0) {
+		return a.TrackNumber != 0
+	}
+	if a.TrackNumber != b.TrackNumber {
+		return a.TrackNumber < b.TrackNumber
+	}
+	return a.Position < b.Position
+}
+
+// SortAlbumTracks 按 AlbumTrackLess 排序，并把整体顺序重新编号为 1..n
+func SortAlbumTracks(placements []AlbumTrackPlacement) {
+	sort.SliceStable(placements, func(i, j int) bool {
+		return AlbumTrackLess(placements[i], placements[j])
+	})
+	for i := range placements {
+		placements[i].Position = i + 1
+	}
+}
+
+// 文件名开头的碟号和曲号，如 "1-01 Title"、"2.03 - Title"、"CD2-03 Title"，以及只有曲号的 "01 Title"、"07. Title"
+var (
+	discTrackPattern = regexp.MustCompile(`^(?i:(?:cd|disc|disk)\s*)?(\d{1,2})[-.](\d{1,3})(?:\s*[-._)]\s*|\s+)(.+)$`)
+	trackOnlyPattern = regexp.MustCompile(`^(\d{1,3})(?:\s*[-._)]\s*|\s+)(.+)$`)
+)
+
+// ParseTrackFilename 从不含扩展名的文件名中解析碟号和曲号，返回去掉编号后的标题
+// 没有碟号时返回 1，没有曲号时返回 0 和原文件名
+func ParseTrackFilename(name string) (disc, track int, title string) {
+	name = strings.TrimSpace(name)
+	if m := discTrackPattern.FindStringSubmatch(name); m != nil {
+		disc, _ = strconv.Atoi(m[1])
+		track, _ = strconv.Atoi(m[2])
+		if disc > 0 && track > 0 {
+			return disc, track, strings.TrimSpace(m[3])
+		}
+	}
+	if m := trackOnlyPattern.FindStringSubmatch(name); m != nil {
+		track, _ = strconv.Atoi(m[1])
+		if track > 0 && strings.Trim(m[2], "0123456789-. ") != "" {
+			return 1, track, strings.TrimSpace(m[2])
+		}
+	}
+	return 1, 0, name
 }
 
 // AlbumWithTracks 包含专辑信息和其包含的歌曲
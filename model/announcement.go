@@ -17,6 +17,14 @@ type Announcement struct {
 	CreatedBy *uint     `json:"createdBy"`
 	IsActive  bool      `json:"isActive"`
 	Priority  int       `json:"priority"`
+
+	// 定时发布与受众
+	PublishAt     *time.Time `json:"publishAt,omitempty"`   // 为空表示创建后立即发布
+	ExpireAt      *time.Time `json:"expireAt,omitempty"`    // 为空表示不过期
+	Status        string     `json:"status"`                // scheduled, published, expired
+	Audience      string     `json:"audience"`              // all, new_users, users
+	JoinedAfter   *time.Time `json:"joinedAfter,omitempty"` // new_users：在此之后注册的用户可见
+	TargetUserIDs []int64    `json:"userIds,omitempty"`     // users：可见的用户
 	
 	// 用户相关的虚拟字段（不存储在数据库中）
 	IsRead bool `json:"isRead"`
@@ -30,12 +38,21 @@ type UserAnnouncementRead struct {
 	ReadAt         time.Time `json:"readAt"`
 }
 
-// CreateAnnouncementRequest 创建公告请求
+// CreateAnnouncementRequest 创建公告请求，content 为 Markdown
 type CreateAnnouncementRequest struct {
 	Title   string `json:"title"`
 	Content string `json:"content"`
 	Version string `json:"version"`
 	Type    string `json:"type"`
+
+	PublishAt   *time.Time `json:"publishAt,omitempty"`
+	ExpireAt    *time.Time `json:"expireAt,omitempty"`
+	Audience    string     `json:"audience,omitempty"`    // 默认 all
+	UserIDs     []int64    `json:"userIds,omitempty"`     // audience 为 users 时必填
+	NewUserDays int        `json:"newUserDays,omitempty"` // audience 为 new_users 时，发布前多少天内注册的用户算新用户，默认 7
+
+	// Minor 仅用于更新：标记为小修改时保留用户的已读状态
+	Minor bool `json:"minor,omitempty"`
 }
 
 // AnnouncementResponse 公告响应（包含用户相关信息）
@@ -48,6 +65,19 @@ type AnnouncementResponse struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 	IsRead    bool      `json:"isRead"`
+	// TypeLabel 按请求语言显示的公告类型名称
+	TypeLabel string `json:"typeLabel,omitempty"`
+
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+	ExpireAt  *time.Time `json:"expireAt,omitempty"`
+}
+
+// AnnouncementTypeLabels 公告类型的中文名称，接口按请求语言翻译后返回
+var AnnouncementTypeLabels = map[string]string{
+	"info":    "信息",
+	"warning": "警告",
+	"success": "成功",
+	"error":   "错误",
 }
 
 // ToResponse 转换为响应格式
@@ -61,12 +91,15 @@ func (a *Announcement) ToResponse(isRead bool) AnnouncementResponse {
 		CreatedAt: a.CreatedAt,
 		UpdatedAt: a.UpdatedAt,
 		IsRead:    isRead,
+		PublishAt: a.PublishAt,
+		ExpireAt:  a.ExpireAt,
 	}
 }
 
 // NewAnnouncement 创建新公告
 func NewAnnouncement(req CreateAnnouncementRequest, userID uint) *Announcement {
-	return &Announcement{
+	now := time.Now()
+	a := &Announcement{
 		ID:        uuid.New().String(),
 		Title:     req.Title,
 		Content:   req.Content,
@@ -75,7 +108,9 @@ func NewAnnouncement(req CreateAnnouncementRequest, userID uint) *Announcement {
 		CreatedBy: &userID,
 		IsActive:  true,
 		Priority:  0,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
+	a.ApplySchedule(req, now)
+	return a
 }
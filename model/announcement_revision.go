@@ -0,0 +1,61 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// AnnouncementRevision 公告被编辑前的一个版本，EditedAt 为这次编辑的时间
+type AnnouncementRevision struct {
+	ID             int64     `json:"id"`
+	AnnouncementID string    `json:"announcementId"`
+	Title          string    `json:"title"`
+	Content        string    `json:"content"`
+	Version        string    `json:"version"`
+	Type           string    `json:"type"`
+	EditedBy       *uint     `json:"editedBy,omitempty"`
+	EditedAt       time.Time `json:"editedAt"`
+	// Substantive 这次编辑是否修改了实质内容，实质修改会让用户重新看到公告
+	Substantive bool `json:"substantive"`
+}
+
+// AnnouncementHistory 公告的当前版本和历史版本（按编辑时间倒序）
+type AnnouncementHistory struct {
+	Current   *Announcement           `json:"current"`
+	Revisions []*AnnouncementRevision `json:"revisions"`
+}
+
+// NewAnnouncementRevision 用公告编辑前的内容创建历史版本
+func NewAnnouncementRevision(previous *Announcement, editedBy uint, substantive bool) *AnnouncementRevision {
+	return &AnnouncementRevision{
+		AnnouncementID: previous.ID,
+		Title:          previous.Title,
+		Content:        previous.Content,
+		Version:        previous.Version,
+		Type:           previous.Type,
+		EditedBy:       &editedBy,
+		EditedAt:       time.Now(),
+		Substantive:    substantive,
+	}
+}
+
+// ContentChanged 编辑是否修改了公告的标题、正文、版本号或类型，只改排期和受众时不产生历史版本
+func (req *CreateAnnouncementRequest) ContentChanged(a *Announcement) bool {
+	return req.Title != a.Title || req.Content != a.Content || req.Version != a.Version || req.Type != a.Type
+}
+
+// IsSubstantiveEdit 编辑是否为实质修改：标题、正文（忽略空白差异）或版本号变化，且未标记为小修改
+// 只改类型、错别字修正等标记为 minor 的编辑不重置用户的已读状态
+func (req *CreateAnnouncementRequest) IsSubstantiveEdit(a *Announcement) bool {
+	if req.Minor {
+		return false
+	}
+	return normalizeWhitespace(req.Title) != normalizeWhitespace(a.Title) ||
+		normalizeWhitespace(req.Content) != normalizeWhitespace(a.Content) ||
+		req.Version != a.Version
+}
+
+// normalizeWhitespace 合并连续空白，比较内容时忽略排版差异
+func normalizeWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 公告状态
+const (
+	AnnouncementScheduled = "scheduled" // 等待 publishAt 到达后由定时任务发布
+	AnnouncementPublished = "published" // 对受众可见
+	AnnouncementExpired   = "expired"   // 已过 expireAt，不再展示
+)
+
+// 公告受众
+const (
+	AudienceAll      = "all"       // 所有用户
+	AudienceNewUsers = "new_users" // 发布前 newUserDays 天内注册的用户，以及之后注册的用户
+	AudienceUsers    = "users"     // 指定的用户
+)
+
+// 公告限制
+const (
+	MaxAnnouncementContentLength = 20000 // 公告正文最大字符数
+	MaxAnnouncementTargets       = 1000  // 指定用户时最多的用户数
+	DefaultNewUserDays           = 7     // new_users 默认统计的注册天数
+	MaxNewUserDays               = 365
+)
+
+var (
+	// markdownUnsafeTag 公告正文中不允许的 HTML 标签，前端直接渲染 Markdown
+	markdownUnsafeTag = regexp.MustCompile(`(?i)<\s*/?\s*(script|iframe|object|embed|style|form|input|link|meta)\b`)
+	// markdownLink Markdown 链接和图片的地址部分，如 [text](url) 或 ![alt](url "title")
+	markdownLink = regexp.MustCompile(`\]\(\s*<?([^)\s>]*)`)
+)
+
+// ValidateSchedule 校验定时发布和受众参数，now 之前的过期时间无效
+func (req *CreateAnnouncementRequest) ValidateSchedule(now time.Time) error {
+	if req.ExpireAt != nil {
+		if !req.ExpireAt.After(now) {
+			return errors.New("过期时间必须晚于当前时间")
+		}
+		if req.PublishAt != nil && !req.ExpireAt.After(*req.PublishAt) {
+			return errors.New("过期时间必须晚于发布时间")
+		}
+	}
+
+	switch req.Audience {
+	case "", AudienceAll:
+	case AudienceNewUsers:
+		if req.NewUserDays < 0 || req.NewUserDays > MaxNewUserDays {
+			return fmt.Errorf("newUserDays 必须在 0-%d 之间", MaxNewUserDays)
+		}
+	case AudienceUsers:
+		if len(req.UserIDs) == 0 {
+			return errors.New("指定用户的公告需要提供 userIds")
+		}
+		if len(req.UserIDs) > MaxAnnouncementTargets {
+			return fmt.Errorf("最多指定 %d 个用户", MaxAnnouncementTargets)
+		}
+		for _, id := range req.UserIDs {
+			if id <= 0 {
+				return errors.New("userIds 包含无效的用户ID")
+			}
+		}
+	default:
+		return errors.New("公告受众无效")
+	}
+	return nil
+}
+
+// ValidateAnnouncementMarkdown 校验公告的 Markdown 正文：长度、代码块闭合、不含脚本类 HTML 标签，
+// 链接和图片只能使用 http(s)、mailto 或相对地址
+func ValidateAnnouncementMarkdown(content string) error {
+	if n := len([]rune(content)); n > MaxAnnouncementContentLength {
+		return fmt.Errorf("公告内容不能超过 %d 个字符", MaxAnnouncementContentLength)
+	}
+
+	fences := 0
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fences++
+		}
+	}
+	if fences%2 != 0 {
+		return errors.New("公告内容中的代码块没有闭合")
+	}
+
+	if tag := markdownUnsafeTag.FindString(content); tag != "" {
+		return fmt.Errorf("公告内容不能包含 HTML 标签 %s", strings.TrimSpace(tag))
+	}
+
+	for _, m := range markdownLink.FindAllStringSubmatch(content, -1) {
+		u, err := url.Parse(m[1])
+		if err != nil {
+			return fmt.Errorf("公告内容中的链接无效：%s", m[1])
+		}
+		switch strings.ToLower(u.Scheme) {
+		case "", "http", "https", "mailto":
+		default:
+			return fmt.Errorf("公告内容中的链接协议不受支持：%s", u.Scheme)
+		}
+	}
+	return nil
+}
+
+// ApplySchedule 按请求设置发布时间、过期时间和受众，并计算 now 时刻的状态
+// 新用户按发布时间（未定时发布时为创建时间）往前推 newUserDays 天计算
+func (a *Announcement) ApplySchedule(req CreateAnnouncementRequest, now time.Time) {
+	a.PublishAt = req.PublishAt
+	a.ExpireAt = req.ExpireAt
+	a.Audience = req.Audience
+	a.JoinedAfter = nil
+	a.TargetUserIDs = nil
+
+	switch req.Audience {
+	case AudienceNewUsers:
+		days := req.NewUserDays
+		if days == 0 {
+			days = DefaultNewUserDays
+		}
+		since := a.CreatedAt
+		if a.PublishAt != nil {
+			since = *a.PublishAt
+		}
+		since = since.AddDate(0, 0, -days)
+		a.JoinedAfter = &since
+	case AudienceUsers:
+		a.TargetUserIDs = req.UserIDs
+	default:
+		a.Audience = AudienceAll
+	}
+	a.Status = a.StatusAt(now)
+}
+
+// StatusAt 根据发布时间和过期时间计算公告在 now 时刻的状态
+func (a *Announcement) StatusAt(now time.Time) string {
+	if a.PublishAt != nil && a.PublishAt.After(now) {
+		return AnnouncementScheduled
+	}
+	if a.ExpireAt != nil && !a.ExpireAt.After(now) {
+		return AnnouncementExpired
+	}
+	return AnnouncementPublished
+}
+
+// NotificationAudience 公告发布时接收通知的用户范围
+func (a *Announcement) NotificationAudience() NotificationAudience {
+	return NotificationAudience{UserIDs: a.TargetUserIDs, JoinedAfter: a.JoinedAfter}
+}
@@ -0,0 +1,9 @@
+package model
+
+// BandwidthUsage 一位用户某天从静态文件和流媒体接口下载的字节数
+type BandwidthUsage struct {
+	UserID   int64  `json:"userId,omitempty"`
+	Username string `json:"username,omitempty"`
+	Day      string `json:"day,omitempty"` // YYYY-MM-DD，按用户汇总多天时为空
+	Bytes    int64  `json:"bytes"`
+}
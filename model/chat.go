@@ -4,14 +4,24 @@ import (
 	"time"
 )
 
+// DefaultChatSessionTitle is the title of a new session until one is generated
+// from its first message.
+const DefaultChatSessionTitle = "新对话"
+
 // ChatSession represents a chat session between a user and the AI agent.
-// Each user has only one session.
+// A user may have several sessions; archived sessions are hidden from the default list.
 type ChatSession struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"userId"`
-	Title     string    `json:"title"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"userId"`
+	Title      string     `json:"title"`
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+
+	// Summary condenses the messages up to and including SummaryUntil (a message ID);
+	// it is sent to the model in place of those messages.
+	Summary      string `json:"-"`
+	SummaryUntil int64  `json:"-"`
 }
 
 // ChatMessage represents a single message in a chat session.
@@ -25,8 +35,12 @@ type ChatMessage struct {
 }
 
 // ChatMessageRequest represents the request body for sending a message.
+// Voice messages set Type to "voice" and carry base64 encoded Audio instead of Content.
 type ChatMessageRequest struct {
+	Type    string `json:"type,omitempty"` // "" for text, "voice"
 	Content string `json:"content"`
+	Audio   string `json:"audio,omitempty"`  // base64 encoded audio clip
+	Format  string `json:"format,omitempty"` // webm, ogg, mp3, wav or m4a
 }
 
 // ChatMessageResponse represents the response for a chat message.
@@ -42,15 +56,39 @@ type ChatHistoryResponse struct {
 }
 
 // OpenAIChatMessage represents a message in the OpenAI chat format.
+// Assistant messages may carry ToolCalls; "tool" messages answer one call by ToolCallID.
 type OpenAIChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// OpenAIToolCall represents a function call requested by the model.
+type OpenAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"` // always "function"
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"` // JSON encoded
+	} `json:"function"`
+}
+
+// OpenAITool describes a function the model may call.
+type OpenAITool struct {
+	Type     string `json:"type"` // always "function"
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Parameters  map[string]interface{} `json:"parameters"` // JSON schema
+	} `json:"function"`
 }
 
 // OpenAIChatRequest represents a request to the OpenAI chat API.
 type OpenAIChatRequest struct {
 	Model       string              `json:"model"`
 	Messages    []OpenAIChatMessage `json:"messages"`
+	Tools       []OpenAITool        `json:"tools,omitempty"`
 	MaxTokens   int                 `json:"max_tokens,omitempty"`
 	Temperature float64             `json:"temperature,omitempty"`
 	Stream      bool                `json:"stream"`
@@ -65,8 +103,9 @@ type OpenAIChatResponse struct {
 	Choices []struct {
 		Index   int `json:"index"`
 		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
+			Role      string           `json:"role"`
+			Content   string           `json:"content"`
+			ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
@@ -86,17 +125,32 @@ type OpenAIStreamChunk struct {
 	Choices []struct {
 		Index int `json:"index"`
 		Delta struct {
-			Role    string `json:"role,omitempty"`
-			Content string `json:"content,omitempty"`
+			Role      string                `json:"role,omitempty"`
+			Content   string                `json:"content,omitempty"`
+			ToolCalls []OpenAIToolCallDelta `json:"tool_calls,omitempty"`
 		} `json:"delta"`
 		FinishReason *string `json:"finish_reason"`
 	} `json:"choices"`
 }
 
+// OpenAIToolCallDelta is a fragment of a tool call in a streaming chunk.
+// Fragments with the same Index belong to one call; Arguments arrive in pieces.
+type OpenAIToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments,omitempty"`
+	} `json:"function"`
+}
+
 // WebSocketMessage represents a message sent over WebSocket.
 type WebSocketMessage struct {
-	Type    string `json:"type"`    // "start", "content", "end", "error", "songs"
+	Type    string `json:"type"`    // "session", "transcript", "start", "content", "title", "end", "error", "songs"
 	Content string `json:"content"` // Message content or error message
+	// AudioURL is the spoken reply, set on "end" when the client asked for TTS
+	AudioURL string `json:"audioUrl,omitempty"`
 }
 
 // SongCard 歌曲卡片结构，用于在聊天中展示可播放的歌曲
@@ -113,7 +167,7 @@ type SongCard struct {
 
 // ChatMessageWithSongs 带歌曲卡片的聊天消息
 type ChatMessageWithSongs struct {
-	Type    string     `json:"type"`    // "songs"
+	Type    string     `json:"type"`    // "songs"；AI 加入播放列表的歌曲为 "queued"
 	Content string     `json:"content"` // 文本内容
 	Songs   []SongCard `json:"songs"`   // 歌曲列表
 }
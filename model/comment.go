@@ -0,0 +1,51 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Comment 曲目或网易云歌曲下的评论，Position 不为空时表示评论指向歌曲中的某个时间点
+type Comment struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userId"`
+	Username  string    `json:"username"`
+	Source    string    `json:"source"`   // local, netease
+	SourceID  string    `json:"sourceId"` // 来源内的歌曲ID
+	Content   string    `json:"content"`
+	Position  *float64  `json:"position,omitempty"` // 时间点（秒）
+	ParentID  *int64    `json:"parentId,omitempty"` // 回复的评论ID
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// 评论限制
+const (
+	MaxCommentLength = 500 // 评论内容最大字符数
+	MaxCommentLimit  = 100 // 单页最多返回的评论数
+)
+
+// ParseCommentTimestamp 解析评论开头的时间点，如 "1:23 this drop!" 或 "1:02:03 ..."，返回秒数
+// 时间点后必须是空白或结尾，除第一段外每段为两位数字且不超过 59
+func ParseCommentTimestamp(content string) (float64, bool) {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	parts := strings.Split(fields[0], ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	seconds := 0
+	for i, part := range parts {
+		if part == "" || (i > 0 && len(part) != 2) || strings.Trim(part, "0123456789") != "" {
+			return 0, false
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || (i > 0 && n > 59) {
+			return 0, false
+		}
+		seconds = seconds*60 + n
+	}
+	return float64(seconds), true
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// DailyMix 每日推荐歌单，每晚按用户常听的歌手生成，歌单由常听歌曲和相似歌手的热门歌曲交替组成
+type DailyMix struct {
+	ID          int            `json:"id"`   // 从 1 开始的序号，每次生成时重新编号
+	Name        string         `json:"name"` // Daily Mix 1
+	SeedArtist  string         `json:"seedArtist"`
+	Artists     []string       `json:"artists"` // 歌单中出现的歌手，用于展示
+	Items       []DailyMixItem `json:"items"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+}
+
+// DailyMixItem Daily Mix 中的一首歌
+type DailyMixItem struct {
+	Source   string `json:"source"` // local、netease
+	SourceID string `json:"sourceId"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Album    string `json:"album,omitempty"`
+	Cover    string `json:"cover,omitempty"`
+	Duration int    `json:"duration,omitempty"` // 时长（秒），未知时为 0
+	Familiar bool   `json:"familiar"`           // 用户听过的歌曲为 true，新发现的歌曲为 false
+}
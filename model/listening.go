@@ -0,0 +1,28 @@
+package model
+
+// ListeningDay 用户某天的收听时长
+type ListeningDay struct {
+	Day     string `json:"day"` // YYYY-MM-DD
+	Seconds int64  `json:"seconds"`
+	GoalMet bool   `json:"goalMet"` // 是否达到当天的收听目标
+}
+
+// ListeningStreak 用户的每日收听目标和连续达标天数
+type ListeningStreak struct {
+	GoalMinutes   int            `json:"goalMinutes"`             // 每日收听目标（分钟）
+	TodaySeconds  int64          `json:"todaySeconds"`            // 今天已收听的秒数
+	GoalMet       bool           `json:"goalMet"`                 // 今天是否已达标
+	CurrentDays   int            `json:"currentDays"`             // 当前连续达标天数，今天还没达标时计到昨天
+	LongestDays   int            `json:"longestDays"`             // 历史最长连续达标天数
+	NextMilestone int            `json:"nextMilestone,omitempty"` // 下一个里程碑天数，已达到全部里程碑时为 0
+	Days          []ListeningDay `json:"days"`                    // 最近若干天的收听时长，旧的在前
+}
+
+// StreakRecord 保存在数据库中的连续达标记录，用于统计最长连续天数和避免重复发送里程碑通知
+type StreakRecord struct {
+	UserID       int64
+	StartDay     string // 当前连续达标的第一天，没有连续达标时为空
+	CurrentDays  int
+	LongestDays  int
+	NotifiedDays int // 当前这次连续达标中已通知过的最大里程碑
+}
@@ -52,6 +52,40 @@ type NeteasePlaylist struct {
 	CreatedAt   time.Time `json:"createdAt"`
 }
 
+// NeteaseToplist 排行榜信息，排行榜本身也是一个歌单
+type NeteaseToplist struct {
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	CoverURL        string `json:"coverImgUrl"`
+	UpdateFrequency string `json:"updateFrequency"` // 如 "每天更新"
+	PlayCount       int64  `json:"playCount"`
+	TrackCount      int    `json:"trackCount"`
+	UpdateTime      int64  `json:"updateTime"` // 毫秒时间戳
+}
+
+// NeteaseArtistAlbum 歌手的专辑
+type NeteaseArtistAlbum struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	PicURL      string `json:"picUrl"`
+	PublishTime int64  `json:"publishTime"` // 毫秒时间戳
+	Size        int    `json:"size"`        // 歌曲数
+}
+
+// NeteaseArtistInfo 歌手详情：基本信息、简介、热门歌曲和专辑
+type NeteaseArtistInfo struct {
+	ID        int64                `json:"id"`
+	Name      string               `json:"name"`
+	Alias     []string             `json:"alias"`
+	PicURL    string               `json:"picUrl"`
+	Bio       string               `json:"bio"`
+	MusicSize int                  `json:"musicSize"`
+	AlbumSize int                  `json:"albumSize"`
+	TopSongs  []NeteaseSong        `json:"topSongs"`
+	Albums    []NeteaseArtistAlbum `json:"albums"`
+}
+
 // NeteaseSongDB 用于数据库存储网易云音乐歌曲
 // 字段与netease_song表对应
 // 注意：与NeteaseSong区分，NeteaseSong用于API返回，NeteaseSongDB用于数据库
@@ -64,6 +98,7 @@ type NeteaseSongDB struct {
 	CoverArtPath    string    `json:"coverArtPath" db:"cover_art_path"`
 	HLSPlaylistPath string    `json:"hlsPlaylistPath" db:"hls_playlist_path"`
 	Duration        float64   `json:"duration" db:"duration"`
+	PlayCount       int64     `json:"playCount" db:"play_count"`
 	CreatedAt       time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt       time.Time `json:"updatedAt" db:"updated_at"`
 }
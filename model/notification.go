@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// 通知类型
+const (
+	NotificationRoomInvite   = "room_invite"   // 被邀请加入房间，ObjectID 为房间ID
+	NotificationFollow       = "follow"        // 被其他用户关注，ObjectID 为关注者的用户ID
+	NotificationCommentReply = "comment_reply" // 评论收到回复，ObjectID 为回复的评论ID
+	NotificationAnnouncement = "announcement"  // 管理员发布了公告，ObjectID 为公告ID
+	NotificationStreak       = "streak"        // 连续达成每日收听目标的天数达到里程碑，ObjectID 为天数
+	NotificationSecurity     = "security"      // 账号因登录失败次数过多被锁定，ObjectID 为最后一次尝试的客户端 IP
+)
+
+// 通知限制
+const (
+	MaxNotificationLimit = 100 // 单页最多返回的通知数
+)
+
+// Notification 发给某个用户的一条通知
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"userId"`
+	Type      string     `json:"type"`
+	ActorID   int64      `json:"actorId,omitempty"`   // 触发通知的用户，公告等系统通知为 0
+	ActorName string     `json:"actorName,omitempty"` // 触发通知的用户名
+	ObjectID  string     `json:"objectId"`
+	Title     string     `json:"title,omitempty"`   // 房间名、歌曲名或公告标题
+	Content   string     `json:"content,omitempty"` // 回复内容或公告正文
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// NotificationAudience 群发通知的接收范围，各条件同时满足，零值表示所有未禁用的用户
+type NotificationAudience struct {
+	UserIDs     []int64    // 非空时只发给这些用户
+	JoinedAfter *time.Time // 非空时只发给此后注册的用户
+}
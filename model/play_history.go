@@ -0,0 +1,76 @@
+package model
+
+import "time"
+
+// PlayHistory 一次收听记录，由客户端的播放心跳生成
+type PlayHistory struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userId"`
+	Source    string    `json:"source"`   // local、netease
+	SourceID  string    `json:"sourceId"` // 来源内的歌曲ID
+	Title     string    `json:"title"`
+	Artist    string    `json:"artist"`
+	Album     string    `json:"album"`
+	Duration  float64   `json:"duration"`  // 歌曲时长（秒），未知时为 0
+	Listened  float64   `json:"listened"`  // 实际收听时长（秒），不含暂停和跳过的部分
+	Scrobbled bool      `json:"scrobbled"` // 是否已同步到 Last.fm / ListenBrainz
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// PlayStat 一首歌的收听统计，按来源和歌曲ID聚合播放历史
+type PlayStat struct {
+	Source       string    `json:"source"`
+	SourceID     string    `json:"sourceId"`
+	Title        string    `json:"title"`
+	Artist       string    `json:"artist"`
+	Plays        int       `json:"plays"`     // 开始播放的次数
+	Completed    int       `json:"completed"` // 听够同步门槛的次数
+	LastPlayedAt time.Time `json:"lastPlayedAt"`
+}
+
+// ListenAggregate 一位用户对一首歌的完整收听次数，用于计算推荐
+type ListenAggregate struct {
+	UserID   int64
+	SourceID string
+	Title    string
+	Artist   string
+	Plays    int
+}
+
+// PlayCount 一首歌在某段时间内开始播放的次数
+type PlayCount struct {
+	Source   string `json:"source"`
+	SourceID string `json:"sourceId"`
+	Plays    int64  `json:"plays"`
+}
+
+// TrendingSong 实例内近期播放最多的一首歌
+type TrendingSong struct {
+	Source   string  `json:"source"`
+	SourceID string  `json:"sourceId"`
+	Title    string  `json:"title"`
+	Artist   string  `json:"artist"`
+	Album    string  `json:"album"`
+	CoverURL string  `json:"coverUrl,omitempty"`
+	Duration float64 `json:"duration"`
+	Plays    int64   `json:"plays"` // 统计窗口内的播放次数
+}
+
+// 听歌记录同步服务
+const (
+	ScrobbleServiceLastFM       = "lastfm"
+	ScrobbleServiceListenBrainz = "listenbrainz"
+)
+
+// ScrobbleAccount 用户绑定的听歌记录同步账号
+type ScrobbleAccount struct {
+	UserID     int64     `json:"-"`
+	Service    string    `json:"service"`  // lastfm、listenbrainz
+	Username   string    `json:"username"` // 服务上的用户名
+	SessionKey string    `json:"-"`        // Last.fm session key 或 ListenBrainz user token
+	APIKey     string    `json:"-"`        // 用户自己的 Last.fm API key，为空时使用服务器配置
+	APISecret  string    `json:"-"`
+	CreatedAt  time.Time `json:"connectedAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
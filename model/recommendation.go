@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Recommendation 一首推荐的网易云歌曲
+type Recommendation struct {
+	SourceID string  `json:"sourceId"` // 网易云歌曲ID
+	Title    string  `json:"title"`
+	Artist   string  `json:"artist"`
+	Score    float64 `json:"score"`
+	Because  string  `json:"because,omitempty"` // 贡献最大的一首用户听过的歌，用于展示推荐理由
+}
+
+// RecommendationList 预计算的推荐列表
+type RecommendationList struct {
+	Items       []Recommendation `json:"items"`
+	GeneratedAt time.Time        `json:"generatedAt"`
+}
@@ -146,6 +146,72 @@ type UserRoomInfo struct {
 	Status      string    `json:"status"`
 }
 
+// RoomPlayedSong 房间播放过的一首歌
+type RoomPlayedSong struct {
+	SongID    string `json:"songId"`
+	Name      string `json:"name"`
+	Artist    string `json:"artist,omitempty"`
+	Cover     string `json:"cover,omitempty"`
+	StartedAt int64  `json:"startedAt"` // 开始播放的时间戳毫秒
+}
+
+// RoomPlayedSongList 自定义类型用于 GORM JSON 字段的自动扫描
+type RoomPlayedSongList []RoomPlayedSong
+
+// Scan 实现 sql.Scanner 接口
+func (s *RoomPlayedSongList) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	}
+	if len(bytes) == 0 || string(bytes) == "null" {
+		*s = nil
+		return nil
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+// Value 实现 driver.Valuer 接口
+func (s RoomPlayedSongList) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+// RoomSummary 房间关闭时生成的听歌总结
+type RoomSummary struct {
+	RoomID             string             `json:"roomId" gorm:"primaryKey;size:8"`
+	RoomName           string             `json:"roomName" gorm:"size:100"`
+	OwnerID            int64              `json:"ownerId"`
+	Songs              RoomPlayedSongList `json:"songs" gorm:"type:json"`
+	SongCount          int                `json:"songCount"`
+	ListenSeconds      int64              `json:"listenSeconds"` // 累计播放时长（秒），暂停期间不计
+	TopChatterID       int64              `json:"topChatterId,omitempty"`
+	TopChatterName     string             `json:"topChatterName,omitempty" gorm:"size:255"`
+	TopChatterMessages int64              `json:"topChatterMessages"`
+	MessageCount       int64              `json:"messageCount"`  // 聊天消息总数
+	PeakListeners      int                `json:"peakListeners"` // 同时在线人数峰值
+	Participants       int64              `json:"participants"`  // 加入过房间的人数
+	StartedAt          time.Time          `json:"startedAt"`
+	ClosedAt           time.Time          `json:"closedAt"`
+}
+
+// TableName 指定表名
+func (RoomSummary) TableName() string {
+	return "room_summaries"
+}
+
+// RoomChatter 房间内发送聊天消息最多的用户
+type RoomChatter struct {
+	UserID   int64  `json:"userId"`
+	Username string `json:"username"`
+	Messages int64  `json:"messages"`
+}
+
 // ========== 常量定义 ==========
 
 const (
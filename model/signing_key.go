@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// JWTSigningKey 持久化的 JWT 签名密钥，私钥以 PKCS#8 PEM 保存
+// ActivatesAt 之前只发布公钥不用于签名，RetiredAt 之后不再签名，用它签发的 Token 过期后删除
+type JWTSigningKey struct {
+	ID          string     `json:"kid"`
+	Algorithm   string     `json:"alg"`
+	PrivateKey  string     `json:"-"`
+	ActivatesAt time.Time  `json:"activatesAt"`
+	RetiredAt   *time.Time `json:"retiredAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// 动态类型
+const (
+	ActivityRoomCreated   = "room_created"   // 创建了房间
+	ActivityCommentPosted = "comment_posted" // 评论了歌曲
+	ActivityPlaylistAdded = "playlist_added" // 向播放列表添加了歌曲
+)
+
+// Activity 用户的一条公开动态，展示在关注者的动态流中
+type Activity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userId"`
+	Username  string    `json:"username"`
+	Type      string    `json:"type"`
+	ObjectID  string    `json:"objectId"`           // 房间ID、评论ID，播放列表动态为添加的歌曲数
+	Source    string    `json:"source,omitempty"`   // 评论或添加的歌曲来源：local, netease
+	SourceID  string    `json:"sourceId,omitempty"` // 评论或添加的歌曲ID，批量添加时为第一首
+	Title     string    `json:"title,omitempty"`    // 房间名或歌曲名
+	Content   string    `json:"content,omitempty"`  // 评论内容
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// FollowUser 关注列表或粉丝列表中的一个用户
+type FollowUser struct {
+	UserID     int64     `json:"userId"`
+	Username   string    `json:"username"`
+	FollowedAt time.Time `json:"followedAt"`
+}
+
+// FollowStats 用户的关注数和粉丝数
+type FollowStats struct {
+	Following int64 `json:"following"`
+	Followers int64 `json:"followers"`
+	// IsFollowing 当前用户是否已关注该用户
+	IsFollowing bool `json:"isFollowing"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Tag 用户为曲目添加的标签，如流派、场景、现场版等，按用户隔离
+type Tag struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"userId"`
+	Name       string    `json:"name"`
+	TrackCount int       `json:"trackCount"` // 带有该标签的有效曲目数
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// 标签限制
+const (
+	MaxTagLength    = 50 // 标签名最大长度
+	MaxTagsPerTrack = 20 // 单个曲目的标签数上限
+)
+
+// NormalizeTagName 规范化标签名：去除首尾空白、合并连续空白并转为小写
+// 使 "Jazz"、" jazz " 被视为同一个标签
+func NormalizeTagName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
@@ -1,21 +1,194 @@
 package model
 
-import "time"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Track represents an audio track in the music library.
 type Track struct {
-	ID              int64     `json:"id"`
-	UserID          int64     `json:"userId"`
-	Title           string    `json:"title"`
-	Artist          string    `json:"artist"`
-	Album           string    `json:"album"`
-	FilePath        string    `json:"-"`               // Path to the original audio file, not exposed in API directly
-	CoverArtPath    string    `json:"coverArtPath"`    // Relative path to cover art, served via static server
-	HLSPlaylistPath string    `json:"hlsPlaylistPath"` // Relative path to HLS playlist, served via static server
-	Duration        float32   `json:"duration"`        // Duration in seconds
-	Status          string    `json:"status"`          // Track processing status: processing, completed, failed
-	State           int8      `json:"state"`           // 0=soft deleted, 1=normal
-	Source          string    `json:"source"`          // library=直接上传, album=通过专辑上传
-	CreatedAt       time.Time `json:"createdAt"`
-	UpdatedAt       time.Time `json:"updatedAt"`
+	ID              int64      `json:"id"`
+	UserID          int64      `json:"userId"`
+	Title           string     `json:"title"`
+	Artist          string     `json:"artist"`
+	Album           string     `json:"album"`
+	Genre           string     `json:"genre"`
+	FilePath        string     `json:"-"`                     // Path to the original audio file, not exposed in API directly
+	CoverArtPath    string     `json:"coverArtPath"`          // Relative path to cover art, served via static server
+	HLSPlaylistPath string     `json:"hlsPlaylistPath"`       // Relative path to HLS playlist, served via static server
+	Duration        float32    `json:"duration"`              // Duration in seconds
+	Status          string     `json:"status"`                // Track processing status: processing, completed, failed
+	State           int8       `json:"state"`                 // 0=soft deleted, 1=normal
+	Source          string     `json:"source"`                // library=直接上传, album=通过专辑上传
+	Provenance      string     `json:"provenance"`            // 音源出处：upload=用户上传, netease=网易云代理, url=链接导入
+	License         string     `json:"license"`               // 许可/署名信息，由上传者填写，可为空
+	Tags            []string   `json:"tags,omitempty"`        // 用户添加的标签，仅在列表接口中填充
+	ContentHash     string     `json:"-"`                     // 源文件 SHA-256，相同内容的曲目共享音频对象和 HLS 输出
+	FileSize        int64      `json:"-"`                     // 上传的源文件字节数，计入用户存储配额
+	PlayCount       int64      `json:"playCount"`             // 累计播放次数，每晚汇总前一天的播放，当天的播放次日才计入
+	Year            int        `json:"year,omitempty"`        // 发行年份，0 表示未知
+	CommentCount    int64      `json:"commentCount"`          // 评论数，仅在列表接口中填充
+	DiscNumber      int        `json:"discNumber,omitempty"`  // 专辑中的碟号，仅在专辑曲目列表中填充
+	TrackNumber     int        `json:"trackNumber,omitempty"` // 碟内曲号，仅在专辑曲目列表中填充
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+	DeletedAt       *time.Time `json:"deletedAt,omitempty"` // 移入回收站的时间
+}
+
+// 曲目音源出处，用于追踪再分发权利
+const (
+	ProvenanceUpload  = "upload"  // 用户上传
+	ProvenanceNetease = "netease" // 网易云代理
+	ProvenanceURL     = "url"     // 链接导入
+)
+
+// TrackStats 曲库中的曲目数量，回收站中的曲目单独计数
+type TrackStats struct {
+	Total    int64            `json:"total"`
+	ByStatus map[string]int64 `json:"byStatus"` // processing、completed、failed
+	Trashed  int64            `json:"trashed"`
+}
+
+// 曲库列表的排序字段
+const (
+	TrackSortCreated  = "created"
+	TrackSortUpdated  = "updated"
+	TrackSortTitle    = "title"
+	TrackSortArtist   = "artist"
+	TrackSortAlbum    = "album"
+	TrackSortDuration = "duration"
+	TrackSortPlays    = "plays"
+)
+
+// MaxTrackListLimit 曲库列表每页最多返回的曲目数
+const MaxTrackListLimit = 500
+
+// TrackFilter 曲库列表的筛选、排序和分页条件，零值字段不参与筛选
+type TrackFilter struct {
+	Artist        string    // 艺术家，完全匹配
+	Album         string    // 专辑名，完全匹配
+	Status        string    // processing、completed、failed
+	Sources       []string  // 来源（library、album），为空时不限制
+	Keyword       string    // 标题、艺术家或专辑包含的关键词
+	Tags          []string  // 需同时带有全部标签
+	CreatedAfter  time.Time // 上传时间不早于该时间
+	CreatedBefore time.Time // 上传时间早于该时间
+	Sort          string    // TrackSort*，为空时按上传时间
+	Ascending     bool      // 默认从新到旧、从大到小
+	Limit         int       // 为 0 时不分页
+	Offset        int
+}
+
+// MaxLicenseLength 许可/署名信息的最大长度
+const MaxLicenseLength = 512
+
+// 曲目元数据字段的最大长度，与 tracks 表的列定义一致
+const (
+	MaxTrackFieldLength = 255 // title、artist、album、cover_art_path
+	MaxGenreLength      = 100
+)
+
+// 发行年份的有效范围
+const (
+	MinTrackYear = 1000
+	MaxTrackYear = 2100
+)
+
+// ValidYear 年份是否在有效范围内
+func ValidYear(year int) bool {
+	return year >= MinTrackYear && year <= MaxTrackYear
+}
+
+// TrackMetadataUpdate 曲目元数据的部分更新，nil 字段保持不变
+type TrackMetadataUpdate struct {
+	Title        *string `json:"title,omitempty"`
+	Artist       *string `json:"artist,omitempty"`
+	Album        *string `json:"album,omitempty"`
+	Genre        *string `json:"genre,omitempty"`
+	Year         *int    `json:"year,omitempty"`
+	CoverArtPath *string `json:"coverArtPath,omitempty"`
+}
+
+// IsEmpty 是否没有任何需要更新的字段
+func (u *TrackMetadataUpdate) IsEmpty() bool {
+	return u.Title == nil && u.Artist == nil && u.Album == nil && u.Genre == nil && u.Year == nil && u.CoverArtPath == nil
+}
+
+// ApplyTo 将更新写入曲目
+func (u *TrackMetadataUpdate) ApplyTo(t *Track) {
+	if u.Title != nil {
+		t.Title = *u.Title
+	}
+	if u.Artist != nil {
+		t.Artist = *u.Artist
+	}
+	if u.Album != nil {
+		t.Album = *u.Album
+	}
+	if u.Genre != nil {
+		t.Genre = *u.Genre
+	}
+	if u.Year != nil {
+		t.Year = *u.Year
+	}
+	if u.CoverArtPath != nil {
+		t.CoverArtPath = *u.CoverArtPath
+	}
+}
+
+// TrackPublicInfo 曲目的公开信息，用于分享等无需登录的接口
+type TrackPublicInfo struct {
+	ID              int64   `json:"id"`
+	Title           string  `json:"title"`
+	Artist          string  `json:"artist"`
+	Album           string  `json:"album"`
+	Genre           string  `json:"genre,omitempty"`
+	CoverArtPath    string  `json:"coverArtPath"`
+	HLSPlaylistPath string  `json:"hlsPlaylistPath"`
+	Duration        float32 `json:"duration"`
+	Provenance      string  `json:"provenance"`
+	License         string  `json:"license,omitempty"`
+}
+
+// PublicInfo 返回曲目的公开信息
+func (t *Track) PublicInfo() *TrackPublicInfo {
+	return &TrackPublicInfo{
+		ID:              t.ID,
+		Title:           t.Title,
+		Artist:          t.Artist,
+		Album:           t.Album,
+		Genre:           t.Genre,
+		CoverArtPath:    t.CoverArtPath,
+		HLSPlaylistPath: t.HLSPlaylistPath,
+		Duration:        t.Duration,
+		Provenance:      t.Provenance,
+		License:         t.License,
+	}
+}
+
+// StreamID 返回曲目 HLS 流的ID
+// 按内容哈希共享的流从播放列表路径中解析，旧数据的流ID即曲目ID
+func (t *Track) StreamID() string {
+	if rest, ok := strings.CutPrefix(t.HLSPlaylistPath, "/streams/"); ok {
+		if id, _, ok := strings.Cut(rest, "/"); ok && id != "" {
+			return id
+		}
+	}
+	return strconv.FormatInt(t.ID, 10)
+}
+
+// TrackFingerprint 曲目的音频指纹（Chromaprint），用于重复检测
+type TrackFingerprint struct {
+	TrackID     int64     `json:"trackId"`
+	UserID      int64     `json:"userId"`
+	Duration    float64   `json:"duration"`
+	Fingerprint string    `json:"-"` // base64 编码的原始指纹
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// DuplicateCluster 一组被判定为同一录音的曲目
+type DuplicateCluster struct {
+	Tracks        []*Track `json:"tracks"`
+	MinSimilarity float64  `json:"minSimilarity"` // 簇内相连曲目的最低相似度
 }
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// TrackVersion 曲目被替换前的音频，保留期内可以恢复
+// 版本仍引用原来的源音频和 HLS 流，到期清理后才释放存储
+type TrackVersion struct {
+	ID              int64     `json:"id"`
+	TrackID         int64     `json:"trackId"`
+	UserID          int64     `json:"userId"`
+	FilePath        string    `json:"-"`
+	HLSPlaylistPath string    `json:"-"`
+	ContentHash     string    `json:"-"`
+	FileSize        int64     `json:"fileSize"`
+	Duration        float32   `json:"duration"`
+	CreatedAt       time.Time `json:"createdAt"` // 被替换的时间
+	ExpiresAt       time.Time `json:"expiresAt"`
+}
+
+// StorageRef 返回引用版本存储的曲目形式，供释放存储时判断引用
+func (v *TrackVersion) StorageRef() *Track {
+	return &Track{
+		UserID:          v.UserID,
+		FilePath:        v.FilePath,
+		HLSPlaylistPath: v.HLSPlaylistPath,
+		ContentHash:     v.ContentHash,
+	}
+}
@@ -2,9 +2,17 @@ package model
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
+// 账号状态
+const (
+	UserStatusPending  = "pending"  // 已注册，等待验证邮箱
+	UserStatusActive   = "active"   // 邮箱已验证，或无需验证
+	UserStatusDisabled = "disabled" // 被管理员禁用，不能登录，已签发的 Token 失效
+)
+
 // User represents a user in the system.
 type User struct {
 	ID              int64          `json:"id"`
@@ -15,6 +23,98 @@ type User struct {
 	Preferences     sql.NullString `json:"preferences,omitempty"`     // 支持NULL值
 	NeteaseUsername sql.NullString `json:"neteaseUsername,omitempty"` // 网易云用户名
 	NeteaseUID      sql.NullString `json:"neteaseUID,omitempty"`      // 网易云用户UID
+	Status          string         `json:"status"`                    // 账号状态：pending、active 或 disabled
+	EmailVerifiedAt *time.Time     `json:"emailVerifiedAt,omitempty"` // 邮箱验证时间
 	CreatedAt       time.Time      `json:"createdAt"`
 	UpdatedAt       time.Time      `json:"updatedAt"`
 }
+
+// UserPreferences 用户偏好设置，以 JSON 形式存储在 users.preferences 字段中
+type UserPreferences struct {
+	Transcode TranscodePreferences `json:"transcode"`
+	Digest    DigestPreferences    `json:"digest"`
+	Scrobble  ScrobblePreferences  `json:"scrobble"`
+	Social    SocialPreferences    `json:"social"`
+	Listening ListeningPreferences `json:"listening"`
+	// Language 接口响应和 AI 助手使用的语言：zh-CN 或 en，为空时按请求的 Accept-Language
+	Language string `json:"language,omitempty"`
+}
+
+// TranscodePreferences 转码偏好，影响该用户上传歌曲生成的 HLS 流
+type TranscodePreferences struct {
+	TrimSilence      bool    `json:"trimSilence"`       // 去除首尾静音
+	CrossfadeSeconds float64 `json:"crossfadeSeconds"`  // 首尾淡入淡出时长（秒），0 表示关闭
+	Profile          string  `json:"profile,omitempty"` // 输出格式：aac（默认）、flac（无损）或 opus（省流量）
+}
+
+// DigestPreferences 每日摘要邮件偏好，默认不订阅
+type DigestPreferences struct {
+	Enabled bool   `json:"enabled"` // 是否订阅每日摘要
+	Locale  string `json:"locale"`  // 邮件语言：zh-CN（默认）或 en
+}
+
+// ScrobblePreferences 听歌记录同步偏好，关闭时不向已绑定的 Last.fm / ListenBrainz 账号提交
+type ScrobblePreferences struct {
+	Enabled bool `json:"enabled"`
+}
+
+// ListeningPreferences 每日收听目标，连续达标天数达到里程碑时发送通知
+type ListeningPreferences struct {
+	DailyGoalMinutes int  `json:"dailyGoalMinutes"` // 每日收听目标（分钟），0 表示使用服务端默认值
+	MuteMilestones   bool `json:"muteMilestones"`   // 不发送连续达标的里程碑通知
+}
+
+// SocialPreferences 动态的隐私设置，默认向关注者展示全部动态
+type SocialPreferences struct {
+	HideActivity  bool `json:"hideActivity"`  // 不向关注者展示任何动态
+	HideRooms     bool `json:"hideRooms"`     // 不展示创建房间的动态
+	HideComments  bool `json:"hideComments"`  // 不展示发表评论的动态
+	HidePlaylists bool `json:"hidePlaylists"` // 不展示向播放列表添加歌曲的动态
+}
+
+// Shares 是否向关注者展示该类型的动态
+func (p SocialPreferences) Shares(activityType string) bool {
+	switch {
+	case p.HideActivity:
+		return false
+	case activityType == ActivityRoomCreated:
+		return !p.HideRooms
+	case activityType == ActivityCommentPosted:
+		return !p.HideComments
+	case activityType == ActivityPlaylistAdded:
+		return !p.HidePlaylists
+	}
+	return true
+}
+
+// IsActive 账号是否已激活（邮箱已验证）
+func (u *User) IsActive() bool {
+	return u.Status != UserStatusPending
+}
+
+// IsDisabled 账号是否被管理员禁用
+func (u *User) IsDisabled() bool {
+	return u.Status == UserStatusDisabled
+}
+
+// UserStats 用户数量和注册趋势（管理后台用）
+type UserStats struct {
+	Total    int64            `json:"total"`
+	ByStatus map[string]int64 `json:"byStatus"`
+	Signups  []DailyCount     `json:"signups"` // 最近若干天每天的注册数，没有注册的日期不列出
+}
+
+// DailyCount 某一天的计数
+type DailyCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int64  `json:"count"`
+}
+
+// GetPreferences 解析用户偏好，字段为空或格式错误时返回默认值
+func (u *User) GetPreferences() UserPreferences {
+	var prefs UserPreferences
+	if u.Preferences.Valid && u.Preferences.String != "" {
+		json.Unmarshal([]byte(u.Preferences.String), &prefs)
+	}
+	return prefs
+}
@@ -0,0 +1,847 @@
+// 服务间调用的内部接口定义，供拆分出的服务（如独立的转码 worker）与主服务通信
+//
+// 服务端实现在 server/internal_rpc.go，配置 INTERNAL_GRPC_ADDR 后随主服务启动。
+// 修改本文件后按下面的命令重新生成代码：
+//
+//   protoc --go_out=. --go_opt=module=Bt1QFM \
+//          --go-grpc_out=. --go-grpc_opt=module=Bt1QFM \
+//          proto/internalrpc/v1/internal.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        v5.29.3
+// source: proto/internalrpc/v1/internal.proto
+
+package internalv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetTrackRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTrackRequest) Reset() {
+	*x = GetTrackRequest{}
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTrackRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTrackRequest) ProtoMessage() {}
+
+func (x *GetTrackRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTrackRequest.ProtoReflect.Descriptor instead.
+func (*GetTrackRequest) Descriptor() ([]byte, []int) {
+	return file_proto_internalrpc_v1_internal_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetTrackRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+// Track 与 model.Track 的对外字段一致
+type Track struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId          int64                  `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Title           string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Artist          string                 `protobuf:"bytes,4,opt,name=artist,proto3" json:"artist,omitempty"`
+	Album           string                 `protobuf:"bytes,5,opt,name=album,proto3" json:"album,omitempty"`
+	Genre           string                 `protobuf:"bytes,6,opt,name=genre,proto3" json:"genre,omitempty"`
+	CoverArtPath    string                 `protobuf:"bytes,7,opt,name=cover_art_path,json=coverArtPath,proto3" json:"cover_art_path,omitempty"`
+	HlsPlaylistPath string                 `protobuf:"bytes,8,opt,name=hls_playlist_path,json=hlsPlaylistPath,proto3" json:"hls_playlist_path,omitempty"`
+	Duration        float32                `protobuf:"fixed32,9,opt,name=duration,proto3" json:"duration,omitempty"`    // 秒
+	Status          string                 `protobuf:"bytes,10,opt,name=status,proto3" json:"status,omitempty"`         // processing、completed、failed
+	Source          string                 `protobuf:"bytes,11,opt,name=source,proto3" json:"source,omitempty"`         // library、album
+	Provenance      string                 `protobuf:"bytes,12,opt,name=provenance,proto3" json:"provenance,omitempty"` // upload、netease、url
+	License         string                 `protobuf:"bytes,13,opt,name=license,proto3" json:"license,omitempty"`
+	PlayCount       int64                  `protobuf:"varint,14,opt,name=play_count,json=playCount,proto3" json:"play_count,omitempty"`
+	CreatedAt       int64                  `protobuf:"varint,15,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"` // Unix 秒
+	UpdatedAt       int64                  `protobuf:"varint,16,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Track) Reset() {
+	*x = Track{}
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Track) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Track) ProtoMessage() {}
+
+func (x *Track) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Track.ProtoReflect.Descriptor instead.
+func (*Track) Descriptor() ([]byte, []int) {
+	return file_proto_internalrpc_v1_internal_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Track) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Track) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *Track) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Track) GetArtist() string {
+	if x != nil {
+		return x.Artist
+	}
+	return ""
+}
+
+func (x *Track) GetAlbum() string {
+	if x != nil {
+		return x.Album
+	}
+	return ""
+}
+
+func (x *Track) GetGenre() string {
+	if x != nil {
+		return x.Genre
+	}
+	return ""
+}
+
+func (x *Track) GetCoverArtPath() string {
+	if x != nil {
+		return x.CoverArtPath
+	}
+	return ""
+}
+
+func (x *Track) GetHlsPlaylistPath() string {
+	if x != nil {
+		return x.HlsPlaylistPath
+	}
+	return ""
+}
+
+func (x *Track) GetDuration() float32 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+func (x *Track) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Track) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Track) GetProvenance() string {
+	if x != nil {
+		return x.Provenance
+	}
+	return ""
+}
+
+func (x *Track) GetLicense() string {
+	if x != nil {
+		return x.License
+	}
+	return ""
+}
+
+func (x *Track) GetPlayCount() int64 {
+	if x != nil {
+		return x.PlayCount
+	}
+	return 0
+}
+
+func (x *Track) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *Track) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+type GetStreamStatusRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 本地曲目为曲目 ID，网易云歌曲为 netease 歌曲 ID
+	Source        string `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"` // local、netease
+	SourceId      string `protobuf:"bytes,2,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStreamStatusRequest) Reset() {
+	*x = GetStreamStatusRequest{}
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStreamStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStreamStatusRequest) ProtoMessage() {}
+
+func (x *GetStreamStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStreamStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStreamStatusRequest) Descriptor() ([]byte, []int) {
+	return file_proto_internalrpc_v1_internal_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetStreamStatusRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *GetStreamStatusRequest) GetSourceId() string {
+	if x != nil {
+		return x.SourceId
+	}
+	return ""
+}
+
+type StreamStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         string                 `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`        // ready、idle、queued、processing、failed
+	Position      int32                  `protobuf:"varint,2,opt,name=position,proto3" json:"position,omitempty"` // 排队时前面还有多少个任务（从 1 开始）
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	PlaylistUrl   string                 `protobuf:"bytes,4,opt,name=playlist_url,json=playlistUrl,proto3" json:"playlist_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamStatus) Reset() {
+	*x = StreamStatus{}
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamStatus) ProtoMessage() {}
+
+func (x *StreamStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamStatus.ProtoReflect.Descriptor instead.
+func (*StreamStatus) Descriptor() ([]byte, []int) {
+	return file_proto_internalrpc_v1_internal_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamStatus) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *StreamStatus) GetPosition() int32 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+func (x *StreamStatus) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *StreamStatus) GetPlaylistUrl() string {
+	if x != nil {
+		return x.PlaylistUrl
+	}
+	return ""
+}
+
+type GetRoomStateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRoomStateRequest) Reset() {
+	*x = GetRoomStateRequest{}
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRoomStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRoomStateRequest) ProtoMessage() {}
+
+func (x *GetRoomStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRoomStateRequest.ProtoReflect.Descriptor instead.
+func (*GetRoomStateRequest) Descriptor() ([]byte, []int) {
+	return file_proto_internalrpc_v1_internal_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetRoomStateRequest) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+type RoomState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	OwnerId       int64                  `protobuf:"varint,3,opt,name=owner_id,json=ownerId,proto3" json:"owner_id,omitempty"`
+	OwnerName     string                 `protobuf:"bytes,4,opt,name=owner_name,json=ownerName,proto3" json:"owner_name,omitempty"`
+	Status        string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"` // active、closed
+	MemberCount   int32                  `protobuf:"varint,6,opt,name=member_count,json=memberCount,proto3" json:"member_count,omitempty"`
+	Playlist      []*PlaylistItem        `protobuf:"bytes,7,rep,name=playlist,proto3" json:"playlist,omitempty"`
+	Playback      *Playback              `protobuf:"bytes,8,opt,name=playback,proto3" json:"playback,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoomState) Reset() {
+	*x = RoomState{}
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomState) ProtoMessage() {}
+
+func (x *RoomState) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomState.ProtoReflect.Descriptor instead.
+func (*RoomState) Descriptor() ([]byte, []int) {
+	return file_proto_internalrpc_v1_internal_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RoomState) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RoomState) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RoomState) GetOwnerId() int64 {
+	if x != nil {
+		return x.OwnerId
+	}
+	return 0
+}
+
+func (x *RoomState) GetOwnerName() string {
+	if x != nil {
+		return x.OwnerName
+	}
+	return ""
+}
+
+func (x *RoomState) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *RoomState) GetMemberCount() int32 {
+	if x != nil {
+		return x.MemberCount
+	}
+	return 0
+}
+
+func (x *RoomState) GetPlaylist() []*PlaylistItem {
+	if x != nil {
+		return x.Playlist
+	}
+	return nil
+}
+
+func (x *RoomState) GetPlayback() *Playback {
+	if x != nil {
+		return x.Playback
+	}
+	return nil
+}
+
+// PlaylistItem 与 cache.PlaylistItem 一致
+type PlaylistItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	SourceId      string                 `protobuf:"bytes,2,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	Title         string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Artist        string                 `protobuf:"bytes,4,opt,name=artist,proto3" json:"artist,omitempty"`
+	Album         string                 `protobuf:"bytes,5,opt,name=album,proto3" json:"album,omitempty"`
+	Cover         string                 `protobuf:"bytes,6,opt,name=cover,proto3" json:"cover,omitempty"`
+	Duration      int32                  `protobuf:"varint,7,opt,name=duration,proto3" json:"duration,omitempty"` // 秒
+	HlsUrl        string                 `protobuf:"bytes,8,opt,name=hls_url,json=hlsUrl,proto3" json:"hls_url,omitempty"`
+	Position      int32                  `protobuf:"varint,9,opt,name=position,proto3" json:"position,omitempty"`
+	AddedBy       int64                  `protobuf:"varint,10,opt,name=added_by,json=addedBy,proto3" json:"added_by,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlaylistItem) Reset() {
+	*x = PlaylistItem{}
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlaylistItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlaylistItem) ProtoMessage() {}
+
+func (x *PlaylistItem) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlaylistItem.ProtoReflect.Descriptor instead.
+func (*PlaylistItem) Descriptor() ([]byte, []int) {
+	return file_proto_internalrpc_v1_internal_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PlaylistItem) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *PlaylistItem) GetSourceId() string {
+	if x != nil {
+		return x.SourceId
+	}
+	return ""
+}
+
+func (x *PlaylistItem) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *PlaylistItem) GetArtist() string {
+	if x != nil {
+		return x.Artist
+	}
+	return ""
+}
+
+func (x *PlaylistItem) GetAlbum() string {
+	if x != nil {
+		return x.Album
+	}
+	return ""
+}
+
+func (x *PlaylistItem) GetCover() string {
+	if x != nil {
+		return x.Cover
+	}
+	return ""
+}
+
+func (x *PlaylistItem) GetDuration() int32 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+func (x *PlaylistItem) GetHlsUrl() string {
+	if x != nil {
+		return x.HlsUrl
+	}
+	return ""
+}
+
+func (x *PlaylistItem) GetPosition() int32 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+func (x *PlaylistItem) GetAddedBy() int64 {
+	if x != nil {
+		return x.AddedBy
+	}
+	return 0
+}
+
+// Playback 与 model.RoomPlaybackState 一致
+type Playback struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CurrentIndex  int32                  `protobuf:"varint,1,opt,name=current_index,json=currentIndex,proto3" json:"current_index,omitempty"`
+	Position      float64                `protobuf:"fixed64,2,opt,name=position,proto3" json:"position,omitempty"` // 播放进度（秒）
+	IsPlaying     bool                   `protobuf:"varint,3,opt,name=is_playing,json=isPlaying,proto3" json:"is_playing,omitempty"`
+	UpdatedAt     int64                  `protobuf:"varint,4,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"` // Unix 毫秒
+	UpdatedBy     int64                  `protobuf:"varint,5,opt,name=updated_by,json=updatedBy,proto3" json:"updated_by,omitempty"`
+	StateVersion  int64                  `protobuf:"varint,6,opt,name=state_version,json=stateVersion,proto3" json:"state_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Playback) Reset() {
+	*x = Playback{}
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Playback) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Playback) ProtoMessage() {}
+
+func (x *Playback) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_internalrpc_v1_internal_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Playback.ProtoReflect.Descriptor instead.
+func (*Playback) Descriptor() ([]byte, []int) {
+	return file_proto_internalrpc_v1_internal_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Playback) GetCurrentIndex() int32 {
+	if x != nil {
+		return x.CurrentIndex
+	}
+	return 0
+}
+
+func (x *Playback) GetPosition() float64 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+func (x *Playback) GetIsPlaying() bool {
+	if x != nil {
+		return x.IsPlaying
+	}
+	return false
+}
+
+func (x *Playback) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+func (x *Playback) GetUpdatedBy() int64 {
+	if x != nil {
+		return x.UpdatedBy
+	}
+	return 0
+}
+
+func (x *Playback) GetStateVersion() int64 {
+	if x != nil {
+		return x.StateVersion
+	}
+	return 0
+}
+
+var File_proto_internalrpc_v1_internal_proto protoreflect.FileDescriptor
+
+const file_proto_internalrpc_v1_internal_proto_rawDesc = "" +
+	"\n" +
+	"#proto/internalrpc/v1/internal.proto\x12\x12bt1qfm.internal.v1\"!\n" +
+	"\x0fGetTrackRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\xbf\x03\n" +
+	"\x05Track\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\x03R\x06userId\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\x12\x16\n" +
+	"\x06artist\x18\x04 \x01(\tR\x06artist\x12\x14\n" +
+	"\x05album\x18\x05 \x01(\tR\x05album\x12\x14\n" +
+	"\x05genre\x18\x06 \x01(\tR\x05genre\x12$\n" +
+	"\x0ecover_art_path\x18\a \x01(\tR\fcoverArtPath\x12*\n" +
+	"\x11hls_playlist_path\x18\b \x01(\tR\x0fhlsPlaylistPath\x12\x1a\n" +
+	"\bduration\x18\t \x01(\x02R\bduration\x12\x16\n" +
+	"\x06status\x18\n" +
+	" \x01(\tR\x06status\x12\x16\n" +
+	"\x06source\x18\v \x01(\tR\x06source\x12\x1e\n" +
+	"\n" +
+	"provenance\x18\f \x01(\tR\n" +
+	"provenance\x12\x18\n" +
+	"\alicense\x18\r \x01(\tR\alicense\x12\x1d\n" +
+	"\n" +
+	"play_count\x18\x0e \x01(\x03R\tplayCount\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x0f \x01(\x03R\tcreatedAt\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\x10 \x01(\x03R\tupdatedAt\"M\n" +
+	"\x16GetStreamStatusRequest\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x1b\n" +
+	"\tsource_id\x18\x02 \x01(\tR\bsourceId\"y\n" +
+	"\fStreamStatus\x12\x14\n" +
+	"\x05state\x18\x01 \x01(\tR\x05state\x12\x1a\n" +
+	"\bposition\x18\x02 \x01(\x05R\bposition\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12!\n" +
+	"\fplaylist_url\x18\x04 \x01(\tR\vplaylistUrl\".\n" +
+	"\x13GetRoomStateRequest\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\"\x9c\x02\n" +
+	"\tRoomState\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x19\n" +
+	"\bowner_id\x18\x03 \x01(\x03R\aownerId\x12\x1d\n" +
+	"\n" +
+	"owner_name\x18\x04 \x01(\tR\townerName\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12!\n" +
+	"\fmember_count\x18\x06 \x01(\x05R\vmemberCount\x12<\n" +
+	"\bplaylist\x18\a \x03(\v2 .bt1qfm.internal.v1.PlaylistItemR\bplaylist\x128\n" +
+	"\bplayback\x18\b \x01(\v2\x1c.bt1qfm.internal.v1.PlaybackR\bplayback\"\x89\x02\n" +
+	"\fPlaylistItem\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x1b\n" +
+	"\tsource_id\x18\x02 \x01(\tR\bsourceId\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\x12\x16\n" +
+	"\x06artist\x18\x04 \x01(\tR\x06artist\x12\x14\n" +
+	"\x05album\x18\x05 \x01(\tR\x05album\x12\x14\n" +
+	"\x05cover\x18\x06 \x01(\tR\x05cover\x12\x1a\n" +
+	"\bduration\x18\a \x01(\x05R\bduration\x12\x17\n" +
+	"\ahls_url\x18\b \x01(\tR\x06hlsUrl\x12\x1a\n" +
+	"\bposition\x18\t \x01(\x05R\bposition\x12\x19\n" +
+	"\badded_by\x18\n" +
+	" \x01(\x03R\aaddedBy\"\xcd\x01\n" +
+	"\bPlayback\x12#\n" +
+	"\rcurrent_index\x18\x01 \x01(\x05R\fcurrentIndex\x12\x1a\n" +
+	"\bposition\x18\x02 \x01(\x01R\bposition\x12\x1d\n" +
+	"\n" +
+	"is_playing\x18\x03 \x01(\bR\tisPlaying\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\x04 \x01(\x03R\tupdatedAt\x12\x1d\n" +
+	"\n" +
+	"updated_by\x18\x05 \x01(\x03R\tupdatedBy\x12#\n" +
+	"\rstate_version\x18\x06 \x01(\x03R\fstateVersion2\x96\x02\n" +
+	"\x0fInternalService\x12J\n" +
+	"\bGetTrack\x12#.bt1qfm.internal.v1.GetTrackRequest\x1a\x19.bt1qfm.internal.v1.Track\x12_\n" +
+	"\x0fGetStreamStatus\x12*.bt1qfm.internal.v1.GetStreamStatusRequest\x1a .bt1qfm.internal.v1.StreamStatus\x12V\n" +
+	"\fGetRoomState\x12'.bt1qfm.internal.v1.GetRoomStateRequest\x1a\x1d.bt1qfm.internal.v1.RoomStateB(Z&Bt1QFM/proto/internalrpc/v1;internalv1b\x06proto3"
+
+var (
+	file_proto_internalrpc_v1_internal_proto_rawDescOnce sync.Once
+	file_proto_internalrpc_v1_internal_proto_rawDescData []byte
+)
+
+func file_proto_internalrpc_v1_internal_proto_rawDescGZIP() []byte {
+	file_proto_internalrpc_v1_internal_proto_rawDescOnce.Do(func() {
+		file_proto_internalrpc_v1_internal_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_internalrpc_v1_internal_proto_rawDesc), len(file_proto_internalrpc_v1_internal_proto_rawDesc)))
+	})
+	return file_proto_internalrpc_v1_internal_proto_rawDescData
+}
+
+var file_proto_internalrpc_v1_internal_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_proto_internalrpc_v1_internal_proto_goTypes = []any{
+	(*GetTrackRequest)(nil),        // 0: bt1qfm.internal.v1.GetTrackRequest
+	(*Track)(nil),                  // 1: bt1qfm.internal.v1.Track
+	(*GetStreamStatusRequest)(nil), // 2: bt1qfm.internal.v1.GetStreamStatusRequest
+	(*StreamStatus)(nil),           // 3: bt1qfm.internal.v1.StreamStatus
+	(*GetRoomStateRequest)(nil),    // 4: bt1qfm.internal.v1.GetRoomStateRequest
+	(*RoomState)(nil),              // 5: bt1qfm.internal.v1.RoomState
+	(*PlaylistItem)(nil),           // 6: bt1qfm.internal.v1.PlaylistItem
+	(*Playback)(nil),               // 7: bt1qfm.internal.v1.Playback
+}
+var file_proto_internalrpc_v1_internal_proto_depIdxs = []int32{
+	6, // 0: bt1qfm.internal.v1.RoomState.playlist:type_name -> bt1qfm.internal.v1.PlaylistItem
+	7, // 1: bt1qfm.internal.v1.RoomState.playback:type_name -> bt1qfm.internal.v1.Playback
+	0, // 2: bt1qfm.internal.v1.InternalService.GetTrack:input_type -> bt1qfm.internal.v1.GetTrackRequest
+	2, // 3: bt1qfm.internal.v1.InternalService.GetStreamStatus:input_type -> bt1qfm.internal.v1.GetStreamStatusRequest
+	4, // 4: bt1qfm.internal.v1.InternalService.GetRoomState:input_type -> bt1qfm.internal.v1.GetRoomStateRequest
+	1, // 5: bt1qfm.internal.v1.InternalService.GetTrack:output_type -> bt1qfm.internal.v1.Track
+	3, // 6: bt1qfm.internal.v1.InternalService.GetStreamStatus:output_type -> bt1qfm.internal.v1.StreamStatus
+	5, // 7: bt1qfm.internal.v1.InternalService.GetRoomState:output_type -> bt1qfm.internal.v1.RoomState
+	5, // [5:8] is the sub-list for method output_type
+	2, // [2:5] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_proto_internalrpc_v1_internal_proto_init() }
+func file_proto_internalrpc_v1_internal_proto_init() {
+	if File_proto_internalrpc_v1_internal_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_internalrpc_v1_internal_proto_rawDesc), len(file_proto_internalrpc_v1_internal_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_internalrpc_v1_internal_proto_goTypes,
+		DependencyIndexes: file_proto_internalrpc_v1_internal_proto_depIdxs,
+		MessageInfos:      file_proto_internalrpc_v1_internal_proto_msgTypes,
+	}.Build()
+	File_proto_internalrpc_v1_internal_proto = out.File
+	file_proto_internalrpc_v1_internal_proto_goTypes = nil
+	file_proto_internalrpc_v1_internal_proto_depIdxs = nil
+}
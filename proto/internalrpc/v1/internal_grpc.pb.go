@@ -0,0 +1,216 @@
+// 服务间调用的内部接口定义，供拆分出的服务（如独立的转码 worker）与主服务通信
+//
+// 服务端实现在 server/internal_rpc.go，配置 INTERNAL_GRPC_ADDR 后随主服务启动。
+// 修改本文件后按下面的命令重新生成代码：
+//
+//   protoc --go_out=. --go_opt=module=Bt1QFM \
+//          --go-grpc_out=. --go-grpc_opt=module=Bt1QFM \
+//          proto/internalrpc/v1/internal.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.1
+// - protoc             v5.29.3
+// source: proto/internalrpc/v1/internal.proto
+
+package internalv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	InternalService_GetTrack_FullMethodName        = "/bt1qfm.internal.v1.InternalService/GetTrack"
+	InternalService_GetStreamStatus_FullMethodName = "/bt1qfm.internal.v1.InternalService/GetStreamStatus"
+	InternalService_GetRoomState_FullMethodName    = "/bt1qfm.internal.v1.InternalService/GetRoomState"
+)
+
+// InternalServiceClient is the client API for InternalService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// InternalService 主服务对内暴露的核心操作，只应监听内网地址
+type InternalServiceClient interface {
+	// GetTrack 按 ID 查询曲目，曲目不存在时返回 NOT_FOUND
+	GetTrack(ctx context.Context, in *GetTrackRequest, opts ...grpc.CallOption) (*Track, error)
+	// GetStreamStatus 查询流是否已转码完成，对应 /api/netease/{id}/prepare 返回的状态
+	GetStreamStatus(ctx context.Context, in *GetStreamStatusRequest, opts ...grpc.CallOption) (*StreamStatus, error)
+	// GetRoomState 查询房间信息、歌单和播放状态
+	GetRoomState(ctx context.Context, in *GetRoomStateRequest, opts ...grpc.CallOption) (*RoomState, error)
+}
+
+type internalServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewInternalServiceClient(cc grpc.ClientConnInterface) InternalServiceClient {
+	return &internalServiceClient{cc}
+}
+
+func (c *internalServiceClient) GetTrack(ctx context.Context, in *GetTrackRequest, opts ...grpc.CallOption) (*Track, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Track)
+	err := c.cc.Invoke(ctx, InternalService_GetTrack_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalServiceClient) GetStreamStatus(ctx context.Context, in *GetStreamStatusRequest, opts ...grpc.CallOption) (*StreamStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StreamStatus)
+	err := c.cc.Invoke(ctx, InternalService_GetStreamStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalServiceClient) GetRoomState(ctx context.Context, in *GetRoomStateRequest, opts ...grpc.CallOption) (*RoomState, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RoomState)
+	err := c.cc.Invoke(ctx, InternalService_GetRoomState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InternalServiceServer is the server API for InternalService service.
+// All implementations must embed UnimplementedInternalServiceServer
+// for forward compatibility.
+//
+// InternalService 主服务对内暴露的核心操作，只应监听内网地址
+type InternalServiceServer interface {
+	// GetTrack 按 ID 查询曲目，曲目不存在时返回 NOT_FOUND
+	GetTrack(context.Context, *GetTrackRequest) (*Track, error)
+	// GetStreamStatus 查询流是否已转码完成，对应 /api/netease/{id}/prepare 返回的状态
+	GetStreamStatus(context.Context, *GetStreamStatusRequest) (*StreamStatus, error)
+	// GetRoomState 查询房间信息、歌单和播放状态
+	GetRoomState(context.Context, *GetRoomStateRequest) (*RoomState, error)
+	mustEmbedUnimplementedInternalServiceServer()
+}
+
+// UnimplementedInternalServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedInternalServiceServer struct{}
+
+func (UnimplementedInternalServiceServer) GetTrack(context.Context, *GetTrackRequest) (*Track, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetTrack not implemented")
+}
+func (UnimplementedInternalServiceServer) GetStreamStatus(context.Context, *GetStreamStatusRequest) (*StreamStatus, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetStreamStatus not implemented")
+}
+func (UnimplementedInternalServiceServer) GetRoomState(context.Context, *GetRoomStateRequest) (*RoomState, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetRoomState not implemented")
+}
+func (UnimplementedInternalServiceServer) mustEmbedUnimplementedInternalServiceServer() {}
+func (UnimplementedInternalServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeInternalServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to InternalServiceServer will
+// result in compilation errors.
+type UnsafeInternalServiceServer interface {
+	mustEmbedUnimplementedInternalServiceServer()
+}
+
+func RegisterInternalServiceServer(s grpc.ServiceRegistrar, srv InternalServiceServer) {
+	// If the following call panics, it indicates UnimplementedInternalServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&InternalService_ServiceDesc, srv)
+}
+
+func _InternalService_GetTrack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTrackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InternalServiceServer).GetTrack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InternalService_GetTrack_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalServiceServer).GetTrack(ctx, req.(*GetTrackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InternalService_GetStreamStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStreamStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InternalServiceServer).GetStreamStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InternalService_GetStreamStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalServiceServer).GetStreamStatus(ctx, req.(*GetStreamStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InternalService_GetRoomState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRoomStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InternalServiceServer).GetRoomState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InternalService_GetRoomState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalServiceServer).GetRoomState(ctx, req.(*GetRoomStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// InternalService_ServiceDesc is the grpc.ServiceDesc for InternalService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var InternalService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bt1qfm.internal.v1.InternalService",
+	HandlerType: (*InternalServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetTrack",
+			Handler:    _InternalService_GetTrack_Handler,
+		},
+		{
+			MethodName: "GetStreamStatus",
+			Handler:    _InternalService_GetStreamStatus_Handler,
+		},
+		{
+			MethodName: "GetRoomState",
+			Handler:    _InternalService_GetRoomState_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/internalrpc/v1/internal.proto",
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"time"
 
+	"Bt1QFM/db"
 	"Bt1QFM/logger"
 	"Bt1QFM/model"
 )
@@ -23,7 +24,7 @@ type AlbumRepository interface {
 	// UpdateAlbum 更新专辑信息
 	UpdateAlbum(ctx context.Context, album *model.Album) error
 
-	// DeleteAlbum 删除专辑
+	// DeleteAlbum 将专辑移入回收站
 	DeleteAlbum(ctx context.Context, id int64) error
 
 	// AddTrackToAlbum 添加歌曲到专辑
@@ -38,8 +39,40 @@ type AlbumRepository interface {
 	// UpdateTrackPosition 更新专辑中歌曲的位置
 	UpdateTrackPosition(ctx context.Context, albumID, trackID int64, newPosition int) error
 
+	// UpdateTrackNumbering 更新专辑中歌曲的碟号和碟内曲号
+	UpdateTrackNumbering(ctx context.Context, albumID, trackID int64, discNumber, trackNumber int) error
+
 	// AddTracksToAlbum 批量添加歌曲到专辑
 	AddTracksToAlbum(ctx context.Context, albumID int64, trackIDs []int64) error
+
+	// AddPlacedTracksToAlbum 批量添加带碟号和曲号的歌曲到专辑，整体顺序接在已有歌曲之后
+	AddPlacedTracksToAlbum(ctx context.Context, albumID int64, placements []model.AlbumTrackPlacement) error
+
+	// GetAlbumsWithoutCover 获取没有封面且在 attemptedBefore 之后未尝试获取过封面的专辑
+	GetAlbumsWithoutCover(ctx context.Context, attemptedBefore time.Time, limit int) ([]*model.Album, error)
+	// MarkAlbumCoverAttempted 记录专辑最近一次尝试获取封面的时间
+	MarkAlbumCoverAttempted(ctx context.Context, albumID int64) error
+
+	// UpdateAlbumCoverPath 更新专辑封面路径
+	UpdateAlbumCoverPath(ctx context.Context, albumID int64, coverPath string) error
+
+	// SetAlbumCoverIfEmpty 专辑仍没有封面时设置封面路径，返回是否已设置
+	SetAlbumCoverIfEmpty(ctx context.Context, albumID int64, coverPath string) (bool, error)
+
+	// GetDeletedAlbumsByUserID 获取用户回收站中的专辑
+	GetDeletedAlbumsByUserID(ctx context.Context, userID int64) ([]*model.Album, error)
+
+	// GetAlbumsDeletedBefore 获取在指定时间之前移入回收站的专辑
+	GetAlbumsDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*model.Album, error)
+
+	// RestoreAlbum 恢复用户回收站中的专辑，返回专辑是否在回收站中
+	RestoreAlbum(ctx context.Context, userID, albumID int64) (bool, error)
+
+	// PurgeAlbum 彻底删除回收站中的专辑
+	PurgeAlbum(ctx context.Context, albumID int64) error
+
+	// ApplyAlbumEnrichment 在同一事务中更新专辑的发行信息和曲目的碟号、曲号、整体顺序
+	ApplyAlbumEnrichment(ctx context.Context, album *model.Album, placements []model.AlbumTrackPlacement) error
 }
 
 // MySQLAlbumRepository MySQL实现的专辑仓库
@@ -54,6 +87,9 @@ func NewMySQLAlbumRepository(db *sql.DB) *MySQLAlbumRepository {
 
 // CreateAlbum 创建新专辑
 func (r *MySQLAlbumRepository) CreateAlbum(ctx context.Context, album *model.Album) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	logger.Debug("Creating new album",
 		logger.String("artist", album.Artist),
 		logger.String("name", album.Name),
@@ -61,8 +97,8 @@ func (r *MySQLAlbumRepository) CreateAlbum(ctx context.Context, album *model.Alb
 	)
 
 	query := `
-		INSERT INTO albums (user_id, artist, name, cover_path, release_time, genre, description, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO albums (user_id, artist, name, cover_path, release_time, genre, description, is_compilation, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	now := time.Now()
@@ -74,6 +110,7 @@ func (r *MySQLAlbumRepository) CreateAlbum(ctx context.Context, album *model.Alb
 		album.ReleaseTime,
 		album.Genre,
 		album.Description,
+		album.IsCompilation,
 		now,
 		now,
 	)
@@ -106,12 +143,16 @@ func (r *MySQLAlbumRepository) CreateAlbum(ctx context.Context, album *model.Alb
 
 // GetAlbumByID 根据ID获取专辑信息
 func (r *MySQLAlbumRepository) GetAlbumByID(ctx context.Context, id int64) (*model.Album, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	logger.Debug("Getting album by ID", logger.Int64("albumId", id))
 
 	query := `
-		SELECT id, user_id, artist, name, cover_path, release_time, genre, description, created_at, updated_at
+		SELECT id, user_id, artist, name, cover_path, release_time, genre, description,
+		       COALESCE(label, ''), COALESCE(musicbrainz_id, ''), is_compilation, created_at, updated_at
 		FROM albums
-		WHERE id = ?
+		WHERE id = ? AND deleted_at IS NULL
 	`
 
 	album := &model.Album{}
@@ -124,6 +165,9 @@ func (r *MySQLAlbumRepository) GetAlbumByID(ctx context.Context, id int64) (*mod
 		&album.ReleaseTime,
 		&album.Genre,
 		&album.Description,
+		&album.Label,
+		&album.MusicBrainzID,
+		&album.IsCompilation,
 		&album.CreatedAt,
 		&album.UpdatedAt,
 	)
@@ -149,12 +193,16 @@ func (r *MySQLAlbumRepository) GetAlbumByID(ctx context.Context, id int64) (*mod
 
 // GetAlbumsByUserID 获取用户的所有专辑
 func (r *MySQLAlbumRepository) GetAlbumsByUserID(ctx context.Context, userID int64) ([]*model.Album, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
 	logger.Debug("Getting albums by user ID", logger.Int64("userId", userID))
 
 	query := `
-		SELECT id, user_id, artist, name, cover_path, release_time, genre, description, created_at, updated_at
+		SELECT id, user_id, artist, name, cover_path, release_time, genre, description,
+		       COALESCE(label, ''), COALESCE(musicbrainz_id, ''), is_compilation, created_at, updated_at
 		FROM albums
-		WHERE user_id = ?
+		WHERE user_id = ? AND deleted_at IS NULL
 		ORDER BY created_at DESC
 	`
 
@@ -180,6 +228,9 @@ func (r *MySQLAlbumRepository) GetAlbumsByUserID(ctx context.Context, userID int
 			&album.ReleaseTime,
 			&album.Genre,
 			&album.Description,
+			&album.Label,
+			&album.MusicBrainzID,
+			&album.IsCompilation,
 			&album.CreatedAt,
 			&album.UpdatedAt,
 		)
@@ -202,6 +253,9 @@ func (r *MySQLAlbumRepository) GetAlbumsByUserID(ctx context.Context, userID int
 
 // UpdateAlbum 更新专辑信息
 func (r *MySQLAlbumRepository) UpdateAlbum(ctx context.Context, album *model.Album) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	logger.Debug("Updating album",
 		logger.Int64("albumId", album.ID),
 		logger.String("artist", album.Artist),
@@ -210,8 +264,8 @@ func (r *MySQLAlbumRepository) UpdateAlbum(ctx context.Context, album *model.Alb
 
 	query := `
 		UPDATE albums
-		SET artist = ?, name = ?, cover_path = ?, release_time = ?, genre = ?, description = ?, updated_at = ?
-		WHERE id = ? AND user_id = ?
+		SET artist = ?, name = ?, cover_path = ?, release_time = ?, genre = ?, description = ?, is_compilation = ?, updated_at = ?
+		WHERE id = ? AND user_id = ? AND deleted_at IS NULL
 	`
 
 	_, err := r.db.ExecContext(ctx, query,
@@ -221,6 +275,7 @@ func (r *MySQLAlbumRepository) UpdateAlbum(ctx context.Context, album *model.Alb
 		album.ReleaseTime,
 		album.Genre,
 		album.Description,
+		album.IsCompilation,
 		time.Now(),
 		album.ID,
 		album.UserID,
@@ -243,12 +298,15 @@ func (r *MySQLAlbumRepository) UpdateAlbum(ctx context.Context, album *model.Alb
 	return nil
 }
 
-// DeleteAlbum 删除专辑
+// DeleteAlbum 将专辑移入回收站，专辑中的歌曲关联保留以便恢复
 func (r *MySQLAlbumRepository) DeleteAlbum(ctx context.Context, id int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	logger.Debug("Deleting album", logger.Int64("albumId", id))
 
-	query := `DELETE FROM albums WHERE id = ?`
-	_, err := r.db.ExecContext(ctx, query, id)
+	query := `UPDATE albums SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, time.Now(), id)
 	if err != nil {
 		logger.Error("Failed to delete album",
 			logger.Int64("albumId", id),
@@ -257,12 +315,15 @@ func (r *MySQLAlbumRepository) DeleteAlbum(ctx context.Context, id int64) error
 		return err
 	}
 
-	logger.Info("Album deleted successfully", logger.Int64("albumId", id))
+	logger.Info("Album moved to trash", logger.Int64("albumId", id))
 	return nil
 }
 
 // AddTrackToAlbum 添加歌曲到专辑
 func (r *MySQLAlbumRepository) AddTrackToAlbum(ctx context.Context, albumID, trackID int64, position int) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	logger.Debug("Adding track to album",
 		logger.Int64("albumId", albumID),
 		logger.Int64("trackId", trackID),
@@ -295,6 +356,9 @@ func (r *MySQLAlbumRepository) AddTrackToAlbum(ctx context.Context, albumID, tra
 
 // RemoveTrackFromAlbum 从专辑中移除歌曲
 func (r *MySQLAlbumRepository) RemoveTrackFromAlbum(ctx context.Context, albumID, trackID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	logger.Debug("Removing track from album",
 		logger.Int64("albumId", albumID),
 		logger.Int64("trackId", trackID),
@@ -320,15 +384,19 @@ func (r *MySQLAlbumRepository) RemoveTrackFromAlbum(ctx context.Context, albumID
 
 // GetAlbumTracks 获取专辑中的所有歌曲
 func (r *MySQLAlbumRepository) GetAlbumTracks(ctx context.Context, albumID int64) ([]*model.Track, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
 	logger.Debug("Getting album tracks", logger.Int64("albumId", albumID))
 
 	query := `
 		SELECT t.id, t.user_id, t.title, t.artist, t.album, t.cover_art_path, 
-			   t.hls_playlist_path, t.duration, t.created_at, t.updated_at
+			   t.hls_playlist_path, t.duration, COALESCE(t.provenance, 'upload'), COALESCE(t.license, ''),
+			   COALESCE(t.file_path, ''), t.state, at.disc_number, at.track_number, t.created_at, t.updated_at
 		FROM tracks t
 		JOIN album_tracks at ON t.id = at.track_id
 		WHERE at.album_id = ?
-		ORDER BY at.position
+		ORDER BY at.disc_number, at.track_number = 0, at.track_number, at.position
 	`
 
 	rows, err := r.db.QueryContext(ctx, query, albumID)
@@ -353,6 +421,12 @@ func (r *MySQLAlbumRepository) GetAlbumTracks(ctx context.Context, albumID int64
 			&track.CoverArtPath,
 			&track.HLSPlaylistPath,
 			&track.Duration,
+			&track.Provenance,
+			&track.License,
+			&track.FilePath,
+			&track.State,
+			&track.DiscNumber,
+			&track.TrackNumber,
 			&track.CreatedAt,
 			&track.UpdatedAt,
 		)
@@ -375,6 +449,9 @@ func (r *MySQLAlbumRepository) GetAlbumTracks(ctx context.Context, albumID int64
 
 // UpdateTrackPosition 更新专辑中歌曲的位置
 func (r *MySQLAlbumRepository) UpdateTrackPosition(ctx context.Context, albumID, trackID int64, newPosition int) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	logger.Debug("Updating track position",
 		logger.Int64("albumId", albumID),
 		logger.Int64("trackId", trackID),
@@ -405,11 +482,44 @@ func (r *MySQLAlbumRepository) UpdateTrackPosition(ctx context.Context, albumID,
 	return nil
 }
 
+// UpdateTrackNumbering 更新专辑中歌曲的碟号和碟内曲号
+func (r *MySQLAlbumRepository) UpdateTrackNumbering(ctx context.Context, albumID, trackID int64, discNumber, trackNumber int) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE album_tracks
+		SET disc_number = ?, track_number = ?, updated_at = ?
+		WHERE album_id = ? AND track_id = ?
+	`
+	if _, err := r.db.ExecContext(ctx, query, discNumber, trackNumber, time.Now(), albumID, trackID); err != nil {
+		logger.Error("Failed to update track numbering",
+			logger.Int64("albumId", albumID),
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err),
+		)
+		return err
+	}
+	return nil
+}
+
 // AddTracksToAlbum 批量添加歌曲到专辑
 func (r *MySQLAlbumRepository) AddTracksToAlbum(ctx context.Context, albumID int64, trackIDs []int64) error {
+	placements := make([]model.AlbumTrackPlacement, len(trackIDs))
+	for i, trackID := range trackIDs {
+		placements[i] = model.AlbumTrackPlacement{TrackID: trackID, DiscNumber: 1}
+	}
+	return r.AddPlacedTracksToAlbum(ctx, albumID, placements)
+}
+
+// AddPlacedTracksToAlbum 批量添加带碟号和曲号的歌曲到专辑，整体顺序按传入顺序接在已有歌曲之后
+func (r *MySQLAlbumRepository) AddPlacedTracksToAlbum(ctx context.Context, albumID int64, placements []model.AlbumTrackPlacement) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	logger.Debug("Adding multiple tracks to album",
 		logger.Int64("albumId", albumID),
-		logger.Int("trackCount", len(trackIDs)),
+		logger.Int("trackCount", len(placements)),
 	)
 
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -435,8 +545,8 @@ func (r *MySQLAlbumRepository) AddTracksToAlbum(ctx context.Context, albumID int
 
 	// 准备批量插入语句
 	query := `
-		INSERT INTO album_tracks (album_id, track_id, position, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO album_tracks (album_id, track_id, position, disc_number, track_number, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
@@ -449,13 +559,17 @@ func (r *MySQLAlbumRepository) AddTracksToAlbum(ctx context.Context, albumID int
 	defer stmt.Close()
 
 	now := time.Now()
-	for i, trackID := range trackIDs {
+	for i, p := range placements {
 		position := maxPosition + i + 1
-		_, err = stmt.ExecContext(ctx, albumID, trackID, position, now, now)
+		disc := p.DiscNumber
+		if disc < 1 {
+			disc = 1
+		}
+		_, err = stmt.ExecContext(ctx, albumID, p.TrackID, position, disc, p.TrackNumber, now, now)
 		if err != nil {
 			logger.Error("Failed to add track to album",
 				logger.Int64("albumId", albumID),
-				logger.Int64("trackId", trackID),
+				logger.Int64("trackId", p.TrackID),
 				logger.ErrorField(err),
 			)
 			return err
@@ -472,7 +586,262 @@ func (r *MySQLAlbumRepository) AddTracksToAlbum(ctx context.Context, albumID int
 
 	logger.Info("Successfully added multiple tracks to album",
 		logger.Int64("albumId", albumID),
-		logger.Int("trackCount", len(trackIDs)),
+		logger.Int("trackCount", len(placements)),
+	)
+	return nil
+}
+
+// GetAlbumsWithoutCover 获取没有封面且在 attemptedBefore 之后未尝试获取过封面的专辑
+// 从未尝试过的专辑优先，其余按最近一次尝试时间从早到晚，失败的专辑不会一直占满批次
+func (r *MySQLAlbumRepository) GetAlbumsWithoutCover(ctx context.Context, attemptedBefore time.Time, limit int) ([]*model.Album, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, artist, name
+		FROM albums
+		WHERE (cover_path IS NULL OR cover_path = '') AND deleted_at IS NULL
+		  AND (cover_attempted_at IS NULL OR cover_attempted_at < ?)
+		ORDER BY cover_attempted_at IS NOT NULL, cover_attempted_at, id
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, attemptedBefore, limit)
+	if err != nil {
+		logger.Error("Failed to query albums without cover", logger.ErrorField(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var albums []*model.Album
+	for rows.Next() {
+		album := &model.Album{}
+		if err := rows.Scan(&album.ID, &album.UserID, &album.Artist, &album.Name); err != nil {
+			logger.Error("Failed to scan album row", logger.ErrorField(err))
+			return nil, err
+		}
+		albums = append(albums, album)
+	}
+	return albums, rows.Err()
+}
+
+// MarkAlbumCoverAttempted 记录专辑最近一次尝试获取封面的时间
+func (r *MySQLAlbumRepository) MarkAlbumCoverAttempted(ctx context.Context, albumID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, `UPDATE albums SET cover_attempted_at = ? WHERE id = ?`, time.Now(), albumID); err != nil {
+		logger.Error("Failed to mark album cover attempt", logger.Int64("albumId", albumID), logger.ErrorField(err))
+		return err
+	}
+	return nil
+}
+
+// UpdateAlbumCoverPath 更新专辑封面路径
+func (r *MySQLAlbumRepository) UpdateAlbumCoverPath(ctx context.Context, albumID int64, coverPath string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE albums SET cover_path = ?, updated_at = ? WHERE id = ?`
+
+	if _, err := r.db.ExecContext(ctx, query, coverPath, time.Now(), albumID); err != nil {
+		logger.Error("Failed to update album cover",
+			logger.Int64("albumId", albumID),
+			logger.ErrorField(err),
+		)
+		return err
+	}
+
+	logger.Info("Album cover updated",
+		logger.Int64("albumId", albumID),
+		logger.String("path", coverPath),
+	)
+	return nil
+}
+
+// SetAlbumCoverIfEmpty 专辑仍没有封面时设置封面路径，已有封面（用户上传或外部获取）时不覆盖
+func (r *MySQLAlbumRepository) SetAlbumCoverIfEmpty(ctx context.Context, albumID int64, coverPath string) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE albums SET cover_path = ?, updated_at = ? WHERE id = ? AND (cover_path IS NULL OR cover_path = '')`
+	result, err := r.db.ExecContext(ctx, query, coverPath, time.Now(), albumID)
+	if err != nil {
+		logger.Error("Failed to set album cover",
+			logger.Int64("albumId", albumID),
+			logger.ErrorField(err),
+		)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetDeletedAlbumsByUserID 获取用户回收站中的专辑，最近删除的在前
+func (r *MySQLAlbumRepository) GetDeletedAlbumsByUserID(ctx context.Context, userID int64) ([]*model.Album, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, artist, name, COALESCE(cover_path, ''), deleted_at
+		FROM albums
+		WHERE user_id = ? AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		logger.Error("Failed to query deleted albums",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+
+	albums := make([]*model.Album, 0)
+	for rows.Next() {
+		album := &model.Album{}
+		var deletedAt time.Time
+		if err := rows.Scan(&album.ID, &album.UserID, &album.Artist, &album.Name, &album.CoverPath, &deletedAt); err != nil {
+			logger.Error("Failed to scan album row", logger.ErrorField(err))
+			return nil, err
+		}
+		album.DeletedAt = &deletedAt
+		albums = append(albums, album)
+	}
+	return albums, rows.Err()
+}
+
+// GetAlbumsDeletedBefore 获取在指定时间之前移入回收站的专辑
+func (r *MySQLAlbumRepository) GetAlbumsDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*model.Album, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, artist, name, COALESCE(cover_path, '')
+		FROM albums
+		WHERE deleted_at IS NOT NULL AND deleted_at < ?
+		ORDER BY id
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		logger.Error("Failed to query expired deleted albums", logger.ErrorField(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	albums := make([]*model.Album, 0)
+	for rows.Next() {
+		album := &model.Album{}
+		if err := rows.Scan(&album.ID, &album.UserID, &album.Artist, &album.Name, &album.CoverPath); err != nil {
+			logger.Error("Failed to scan album row", logger.ErrorField(err))
+			return nil, err
+		}
+		albums = append(albums, album)
+	}
+	return albums, rows.Err()
+}
+
+// RestoreAlbum 恢复用户回收站中的专辑
+func (r *MySQLAlbumRepository) RestoreAlbum(ctx context.Context, userID, albumID int64) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE albums SET deleted_at = NULL, updated_at = ? WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`
+	res, err := r.db.ExecContext(ctx, query, time.Now(), albumID, userID)
+	if err != nil {
+		logger.Error("Failed to restore album",
+			logger.Int64("albumId", albumID),
+			logger.ErrorField(err),
+		)
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		logger.Info("Album restored from trash", logger.Int64("albumId", albumID))
+	}
+	return affected > 0, nil
+}
+
+// PurgeAlbum 彻底删除回收站中的专辑，专辑歌曲关联随外键级联删除，歌曲本身保留
+func (r *MySQLAlbumRepository) PurgeAlbum(ctx context.Context, albumID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM albums WHERE id = ? AND deleted_at IS NOT NULL`, albumID); err != nil {
+		logger.Error("Failed to purge album",
+			logger.Int64("albumId", albumID),
+			logger.ErrorField(err),
+		)
+		return err
+	}
+
+	logger.Info("Album purged", logger.Int64("albumId", albumID))
+	return nil
+}
+
+// ApplyAlbumEnrichment 在同一事务中更新专辑的发行时间、厂牌、MusicBrainz ID 和曲目的碟号、曲号、整体顺序
+func (r *MySQLAlbumRepository) ApplyAlbumEnrichment(ctx context.Context, album *model.Album, placements []model.AlbumTrackPlacement) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, `
+		UPDATE albums
+		SET release_time = ?, label = ?, musicbrainz_id = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`, album.ReleaseTime, album.Label, album.MusicBrainzID, now, album.ID)
+	if err != nil {
+		logger.Error("Failed to update album release info",
+			logger.Int64("albumId", album.ID),
+			logger.ErrorField(err),
+		)
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		UPDATE album_tracks
+		SET disc_number = ?, track_number = ?, position = ?, updated_at = ?
+		WHERE album_id = ? AND track_id = ?
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, p := range placements {
+		if _, err := stmt.ExecContext(ctx, p.DiscNumber, p.TrackNumber, p.Position, now, album.ID, p.TrackID); err != nil {
+			logger.Error("Failed to update album track placement",
+				logger.Int64("albumId", album.ID),
+				logger.Int64("trackId", p.TrackID),
+				logger.ErrorField(err),
+			)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	logger.Info("Album enrichment applied",
+		logger.Int64("albumId", album.ID),
+		logger.String("musicbrainzId", album.MusicBrainzID),
+		logger.Int("trackCount", len(placements)),
 	)
 	return nil
 }
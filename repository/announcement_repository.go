@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 	"Bt1QFM/model"
 	"Bt1QFM/db"
@@ -16,14 +17,92 @@ func NewAnnouncementRepository() *AnnouncementRepository {
 	return &AnnouncementRepository{DB: db.DB}
 }
 
-// GetAnnouncements 获取所有活跃公告（按优先级和创建时间排序）
-func (r *AnnouncementRepository) GetAnnouncements() ([]model.Announcement, error) {
-	query := `SELECT id, title, content, version, type, created_at, updated_at, created_by, is_active, priority
-		FROM announcements 
-		WHERE is_active = 1 
-		ORDER BY priority DESC, created_at DESC`
-	
-	rows, err := r.DB.Query(query)
+// announcementColumns 公告查询的列，与 scanAnnouncement 的顺序一致
+const announcementColumns = `a.id, a.title, a.content, a.version, a.type, a.created_at, a.updated_at, a.created_by, a.is_active, a.priority,
+		a.publish_at, a.expire_at, a.status, a.audience, a.joined_after`
+
+// visibleAnnouncementCondition 用户可见的公告：已发布、未过期且用户在受众内，参数依次为当前时间和两次用户ID
+const visibleAnnouncementCondition = `a.is_active = 1 AND a.status = 'published' AND (a.expire_at IS NULL OR a.expire_at > ?)
+		AND (a.audience = 'all'
+			OR (a.audience = 'new_users' AND EXISTS (SELECT 1 FROM users u WHERE u.id = ? AND u.created_at >= a.joined_after))
+			OR (a.audience = 'users' AND EXISTS (SELECT 1 FROM announcement_targets t WHERE t.announcement_id = a.id AND t.user_id = ?)))`
+
+// GetAnnouncements 获取用户可见的公告（按优先级和创建时间排序）
+func (r *AnnouncementRepository) GetAnnouncements(userID uint) ([]model.Announcement, error) {
+	query := `SELECT ` + announcementColumns + `
+		FROM announcements a
+		WHERE ` + visibleAnnouncementCondition + `
+		ORDER BY a.priority DESC, a.created_at DESC`
+
+	return r.queryAnnouncements(query, time.Now(), userID, userID)
+}
+
+// ListAnnouncements 获取所有未删除的公告，包括等待发布和已过期的公告（管理员）
+func (r *AnnouncementRepository) ListAnnouncements() ([]model.Announcement, error) {
+	query := `SELECT ` + announcementColumns + `
+		FROM announcements a
+		WHERE a.is_active = 1
+		ORDER BY COALESCE(a.publish_at, a.created_at) DESC`
+
+	announcements, err := r.queryAnnouncements(query)
+	if err != nil {
+		return nil, err
+	}
+	for i := range announcements {
+		if err := r.loadTargets(&announcements[i]); err != nil {
+			return nil, err
+		}
+	}
+	return announcements, nil
+}
+
+// GetDueAnnouncements 获取发布时间已到但仍在等待发布的公告
+func (r *AnnouncementRepository) GetDueAnnouncements(now time.Time) ([]model.Announcement, error) {
+	query := `SELECT ` + announcementColumns + `
+		FROM announcements a
+		WHERE a.is_active = 1 AND a.status = 'scheduled' AND a.publish_at <= ?
+		ORDER BY a.publish_at`
+
+	announcements, err := r.queryAnnouncements(query, now)
+	if err != nil {
+		return nil, err
+	}
+	for i := range announcements {
+		if err := r.loadTargets(&announcements[i]); err != nil {
+			return nil, err
+		}
+	}
+	return announcements, nil
+}
+
+// UpdateStatus 将公告从 from 状态切换到 to 状态，返回是否切换成功；多个实例同时执行时只有一个成功
+func (r *AnnouncementRepository) UpdateStatus(id, from, to string) (bool, error) {
+	query := `UPDATE announcements SET status = ?, updated_at = ? WHERE id = ? AND status = ? AND is_active = 1`
+	res, err := r.DB.Exec(query, to, time.Now(), id, from)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ExpireAnnouncements 将过期时间已到的已发布公告标记为已过期，返回标记的数量
+func (r *AnnouncementRepository) ExpireAnnouncements(now time.Time) (int64, error) {
+	query := `UPDATE announcements SET status = 'expired', updated_at = ?
+		WHERE is_active = 1 AND status = 'published' AND expire_at IS NOT NULL AND expire_at <= ?`
+	res, err := r.DB.Exec(query, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// queryAnnouncements 执行公告查询，查询的列为 announcementColumns
+func (r *AnnouncementRepository) queryAnnouncements(query string, args ...interface{}) ([]model.Announcement, error) {
+	rows, err := r.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -31,40 +110,104 @@ func (r *AnnouncementRepository) GetAnnouncements() ([]model.Announcement, error
 
 	var announcements []model.Announcement
 	for rows.Next() {
-		var announcement model.Announcement
-		var createdBy sql.NullInt64
-		
-		err := rows.Scan(
-			&announcement.ID,
-			&announcement.Title,
-			&announcement.Content,
-			&announcement.Version,
-			&announcement.Type,
-			&announcement.CreatedAt,
-			&announcement.UpdatedAt,
-			&createdBy,
-			&announcement.IsActive,
-			&announcement.Priority,
-		)
+		announcement, err := scanAnnouncement(rows)
 		if err != nil {
 			return nil, err
 		}
-		
-		if createdBy.Valid {
-			createdByUint := uint(createdBy.Int64)
-			announcement.CreatedBy = &createdByUint
-		}
-		
-		announcements = append(announcements, announcement)
+		announcements = append(announcements, *announcement)
 	}
-	
+
 	return announcements, rows.Err()
 }
 
+// scanAnnouncement 扫描一行 announcementColumns
+func scanAnnouncement(row rowScanner) (*model.Announcement, error) {
+	var announcement model.Announcement
+	var createdBy sql.NullInt64
+	var publishAt, expireAt, joinedAfter sql.NullTime
+
+	err := row.Scan(
+		&announcement.ID,
+		&announcement.Title,
+		&announcement.Content,
+		&announcement.Version,
+		&announcement.Type,
+		&announcement.CreatedAt,
+		&announcement.UpdatedAt,
+		&createdBy,
+		&announcement.IsActive,
+		&announcement.Priority,
+		&publishAt,
+		&expireAt,
+		&announcement.Status,
+		&announcement.Audience,
+		&joinedAfter,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if createdBy.Valid {
+		createdByUint := uint(createdBy.Int64)
+		announcement.CreatedBy = &createdByUint
+	}
+	if publishAt.Valid {
+		announcement.PublishAt = &publishAt.Time
+	}
+	if expireAt.Valid {
+		announcement.ExpireAt = &expireAt.Time
+	}
+	if joinedAfter.Valid {
+		announcement.JoinedAfter = &joinedAfter.Time
+	}
+	return &announcement, nil
+}
+
+// loadTargets 读取指定用户公告的用户列表
+func (r *AnnouncementRepository) loadTargets(announcement *model.Announcement) error {
+	if announcement.Audience != model.AudienceUsers {
+		return nil
+	}
+	rows, err := r.DB.Query(`SELECT user_id FROM announcement_targets WHERE announcement_id = ? ORDER BY user_id`, announcement.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	announcement.TargetUserIDs = nil
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return err
+		}
+		announcement.TargetUserIDs = append(announcement.TargetUserIDs, userID)
+	}
+	return rows.Err()
+}
+
+// saveTargets 替换指定用户公告的用户列表
+func saveTargets(tx *sql.Tx, announcement *model.Announcement) error {
+	if _, err := tx.Exec(`DELETE FROM announcement_targets WHERE announcement_id = ?`, announcement.ID); err != nil {
+		return err
+	}
+	if announcement.Audience != model.AudienceUsers || len(announcement.TargetUserIDs) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(announcement.TargetUserIDs))
+	args := make([]interface{}, 0, len(announcement.TargetUserIDs)*2)
+	for _, userID := range announcement.TargetUserIDs {
+		values = append(values, "(?, ?)")
+		args = append(args, announcement.ID, userID)
+	}
+	_, err := tx.Exec(`INSERT IGNORE INTO announcement_targets (announcement_id, user_id) VALUES `+strings.Join(values, ", "), args...)
+	return err
+}
+
 // GetAnnouncementsWithReadStatus 获取公告并标记用户已读状态
 func (r *AnnouncementRepository) GetAnnouncementsWithReadStatus(userID uint) ([]model.AnnouncementResponse, error) {
-	// 获取所有活跃公告
-	announcements, err := r.GetAnnouncements()
+	// 获取用户可见的公告
+	announcements, err := r.GetAnnouncements(userID)
 	if err != nil {
 		return nil, err
 	}
@@ -108,61 +251,43 @@ func (r *AnnouncementRepository) getUserReadMap(userID uint) (map[string]bool, e
 
 // GetUnreadAnnouncements 获取用户未读公告
 func (r *AnnouncementRepository) GetUnreadAnnouncements(userID uint) ([]model.AnnouncementResponse, error) {
-	query := `SELECT a.id, a.title, a.content, a.version, a.type, a.created_at, a.updated_at, a.created_by, a.is_active, a.priority
+	query := `SELECT ` + announcementColumns + `
 		FROM announcements a
 		LEFT JOIN user_announcement_reads r ON a.id = r.announcement_id AND r.user_id = ?
-		WHERE a.is_active = 1 AND r.announcement_id IS NULL
+		WHERE ` + visibleAnnouncementCondition + ` AND r.announcement_id IS NULL
 		ORDER BY a.priority DESC, a.created_at DESC`
-	
-	rows, err := r.DB.Query(query, userID)
+
+	announcements, err := r.queryAnnouncements(query, userID, time.Now(), userID, userID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var responses []model.AnnouncementResponse
-	for rows.Next() {
-		var announcement model.Announcement
-		var createdBy sql.NullInt64
-		
-		err := rows.Scan(
-			&announcement.ID,
-			&announcement.Title,
-			&announcement.Content,
-			&announcement.Version,
-			&announcement.Type,
-			&announcement.CreatedAt,
-			&announcement.UpdatedAt,
-			&createdBy,
-			&announcement.IsActive,
-			&announcement.Priority,
-		)
-		if err != nil {
-			return nil, err
-		}
-		
-		if createdBy.Valid {
-			createdByUint := uint(createdBy.Int64)
-			announcement.CreatedBy = &createdByUint
-		}
-		
+	for _, announcement := range announcements {
 		responses = append(responses, announcement.ToResponse(false))
 	}
-	
-	return responses, rows.Err()
+
+	return responses, nil
 }
 
 // CreateAnnouncement 创建公告
 func (r *AnnouncementRepository) CreateAnnouncement(announcement *model.Announcement) error {
-	query := `INSERT INTO announcements (id, title, content, version, type, created_at, updated_at, created_by, is_active, priority)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO announcements (id, title, content, version, type, created_at, updated_at, created_by, is_active, priority,
+			publish_at, expire_at, status, audience, joined_after)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	var createdBy interface{}
 	if announcement.CreatedBy != nil {
 		createdBy = *announcement.CreatedBy
 	}
-	
-	_, err := r.DB.Exec(query,
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(query,
 		announcement.ID,
 		announcement.Title,
 		announcement.Content,
@@ -173,9 +298,20 @@ func (r *AnnouncementRepository) CreateAnnouncement(announcement *model.Announce
 		createdBy,
 		announcement.IsActive,
 		announcement.Priority,
+		announcement.PublishAt,
+		announcement.ExpireAt,
+		announcement.Status,
+		announcement.Audience,
+		announcement.JoinedAfter,
 	)
-	
-	return err
+	if err != nil {
+		return err
+	}
+	if err := saveTargets(tx, announcement); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // DeleteAnnouncement 软删除公告（设置is_active为false）
@@ -187,39 +323,23 @@ func (r *AnnouncementRepository) DeleteAnnouncement(id string) error {
 
 // GetAnnouncementByID 根据ID获取公告
 func (r *AnnouncementRepository) GetAnnouncementByID(id string) (*model.Announcement, error) {
-	query := `SELECT id, title, content, version, type, created_at, updated_at, created_by, is_active, priority
-		FROM announcements 
-		WHERE id = ? AND is_active = 1`
-	
-	var announcement model.Announcement
-	var createdBy sql.NullInt64
-	
-	err := r.DB.QueryRow(query, id).Scan(
-		&announcement.ID,
-		&announcement.Title,
-		&announcement.Content,
-		&announcement.Version,
-		&announcement.Type,
-		&announcement.CreatedAt,
-		&announcement.UpdatedAt,
-		&createdBy,
-		&announcement.IsActive,
-		&announcement.Priority,
-	)
-	
+	query := `SELECT ` + announcementColumns + `
+		FROM announcements a
+		WHERE a.id = ? AND a.is_active = 1`
+
+	announcement, err := scanAnnouncement(r.DB.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("公告不存在")
 		}
 		return nil, err
 	}
-	
-	if createdBy.Valid {
-		createdByUint := uint(createdBy.Int64)
-		announcement.CreatedBy = &createdByUint
+
+	if err := r.loadTargets(announcement); err != nil {
+		return nil, err
 	}
-	
-	return &announcement, nil
+
+	return announcement, nil
 }
 
 // MarkAsRead 标记公告为已读
@@ -274,29 +394,123 @@ func (r *AnnouncementRepository) GetAnnouncementStats() (map[string]interface{},
 	if err != nil {
 		return nil, err
 	}
+
+	// 等待发布的公告数
+	var scheduledCount int64
+	err = r.DB.QueryRow(`SELECT COUNT(*) FROM announcements WHERE is_active = 1 AND status = 'scheduled'`).Scan(&scheduledCount)
+	if err != nil {
+		return nil, err
+	}
 	
 	stats := map[string]interface{}{
-		"total_announcements":  totalCount,
-		"active_announcements": activeCount,
+		"total_announcements":     totalCount,
+		"active_announcements":    activeCount,
+		"scheduled_announcements": scheduledCount,
 	}
 	
 	return stats, nil
 }
 
-// UpdateAnnouncement 更新公告
-func (r *AnnouncementRepository) UpdateAnnouncement(id string, req model.CreateAnnouncementRequest) error {
+// UpdateAnnouncement 更新公告内容、定时发布和受众
+// revision 不为空时保存编辑前的版本，实质修改同时清除用户的已读记录，让用户重新看到公告
+func (r *AnnouncementRepository) UpdateAnnouncement(announcement *model.Announcement, revision *model.AnnouncementRevision) error {
 	query := `UPDATE announcements 
-		SET title = ?, content = ?, version = ?, type = ?, updated_at = ?
+		SET title = ?, content = ?, version = ?, type = ?, updated_at = ?,
+			publish_at = ?, expire_at = ?, status = ?, audience = ?, joined_after = ?
 		WHERE id = ? AND is_active = 1`
-	
-	_, err := r.DB.Exec(query,
-		req.Title,
-		req.Content,
-		req.Version,
-		req.Type,
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(query,
+		announcement.Title,
+		announcement.Content,
+		announcement.Version,
+		announcement.Type,
 		time.Now(),
-		id,
+		announcement.PublishAt,
+		announcement.ExpireAt,
+		announcement.Status,
+		announcement.Audience,
+		announcement.JoinedAfter,
+		announcement.ID,
 	)
-	
-	return err
+	if err != nil {
+		return err
+	}
+	if err := saveTargets(tx, announcement); err != nil {
+		return err
+	}
+
+	if revision != nil {
+		var editedBy interface{}
+		if revision.EditedBy != nil {
+			editedBy = *revision.EditedBy
+		}
+		_, err = tx.Exec(`INSERT INTO announcement_revisions (announcement_id, title, content, version, type, edited_by, edited_at, substantive)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			revision.AnnouncementID,
+			revision.Title,
+			revision.Content,
+			revision.Version,
+			revision.Type,
+			editedBy,
+			revision.EditedAt,
+			revision.Substantive,
+		)
+		if err != nil {
+			return err
+		}
+		if revision.Substantive {
+			if _, err := tx.Exec(`DELETE FROM user_announcement_reads WHERE announcement_id = ?`, announcement.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRevisions 获取公告的历史版本，按编辑时间倒序
+func (r *AnnouncementRepository) GetRevisions(announcementID string) ([]*model.AnnouncementRevision, error) {
+	query := `SELECT id, announcement_id, title, content, version, type, edited_by, edited_at, substantive
+		FROM announcement_revisions
+		WHERE announcement_id = ?
+		ORDER BY id DESC`
+
+	rows, err := r.DB.Query(query, announcementID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]*model.AnnouncementRevision, 0)
+	for rows.Next() {
+		revision := &model.AnnouncementRevision{}
+		var editedBy sql.NullInt64
+		err := rows.Scan(
+			&revision.ID,
+			&revision.AnnouncementID,
+			&revision.Title,
+			&revision.Content,
+			&revision.Version,
+			&revision.Type,
+			&editedBy,
+			&revision.EditedAt,
+			&revision.Substantive,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if editedBy.Valid {
+			editedByUint := uint(editedBy.Int64)
+			revision.EditedBy = &editedByUint
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
 }
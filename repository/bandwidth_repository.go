@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// bandwidthBatchSize 每条 SQL 语句写入的用户数
+const bandwidthBatchSize = 500
+
+// BandwidthRepository defines the interface for daily per-user bandwidth operations.
+type BandwidthRepository interface {
+	SaveDailyBandwidth(ctx context.Context, day string, usage []model.BandwidthUsage) error
+	GetUserDailyBandwidth(ctx context.Context, userID int64, sinceDay string) ([]model.BandwidthUsage, error)
+	GetDailyBandwidthTotals(ctx context.Context, sinceDay string) ([]model.BandwidthUsage, error)
+	GetTopBandwidthUsers(ctx context.Context, sinceDay string, limit int) ([]model.BandwidthUsage, error)
+}
+
+// mysqlBandwidthRepository implements BandwidthRepository for MySQL.
+type mysqlBandwidthRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLBandwidthRepository creates a new instance of mysqlBandwidthRepository.
+func NewMySQLBandwidthRepository() BandwidthRepository {
+	return &mysqlBandwidthRepository{DB: db.DB}
+}
+
+// SaveDailyBandwidth stores one day's per-user bandwidth.
+// Saving the same day again replaces its values, so a rollup interrupted before clearing Redis can simply be retried.
+func (r *mysqlBandwidthRepository) SaveDailyBandwidth(ctx context.Context, day string, usage []model.BandwidthUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	for start := 0; start < len(usage); start += bandwidthBatchSize {
+		batch := usage[start:min(start+bandwidthBatchSize, len(usage))]
+		args := make([]interface{}, 0, len(batch)*3)
+		for _, u := range batch {
+			args = append(args, u.UserID, day, u.Bytes)
+		}
+		query := `INSERT INTO user_bandwidth (user_id, day, bytes) VALUES ` +
+			strings.TrimSuffix(strings.Repeat("(?, ?, ?), ", len(batch)), ", ") +
+			` ON DUPLICATE KEY UPDATE bytes = VALUES(bytes)`
+		if _, err := r.DB.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to save bandwidth for %s: %w", day, err)
+		}
+	}
+	return nil
+}
+
+// GetUserDailyBandwidth returns a user's bandwidth per day from sinceDay (inclusive) onwards, oldest first.
+func (r *mysqlBandwidthRepository) GetUserDailyBandwidth(ctx context.Context, userID int64, sinceDay string) ([]model.BandwidthUsage, error) {
+	query := `SELECT DATE_FORMAT(day, '%Y-%m-%d'), bytes FROM user_bandwidth
+	           WHERE user_id = ? AND day >= ? ORDER BY day`
+	return r.queryDaily(ctx, "GetUserDailyBandwidth", query, userID, sinceDay)
+}
+
+// GetDailyBandwidthTotals returns the bandwidth of all users per day from sinceDay (inclusive) onwards, oldest first.
+func (r *mysqlBandwidthRepository) GetDailyBandwidthTotals(ctx context.Context, sinceDay string) ([]model.BandwidthUsage, error) {
+	query := `SELECT DATE_FORMAT(day, '%Y-%m-%d'), SUM(bytes) FROM user_bandwidth
+	           WHERE day >= ? GROUP BY day ORDER BY day`
+	return r.queryDaily(ctx, "GetDailyBandwidthTotals", query, sinceDay)
+}
+
+// queryDaily runs a query returning (day, bytes) rows.
+func (r *mysqlBandwidthRepository) queryDaily(ctx context.Context, name, query string, args ...interface{}) ([]model.BandwidthUsage, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily bandwidth: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make([]model.BandwidthUsage, 0)
+	for rows.Next() {
+		var u model.BandwidthUsage
+		if err := rows.Scan(&u.Day, &u.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan daily bandwidth: %w", err)
+		}
+		usage = append(usage, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in %s: %w", name, err)
+	}
+
+	return usage, nil
+}
+
+// GetTopBandwidthUsers returns the users with the most bandwidth from sinceDay (inclusive) onwards.
+func (r *mysqlBandwidthRepository) GetTopBandwidthUsers(ctx context.Context, sinceDay string, limit int) ([]model.BandwidthUsage, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT b.user_id, COALESCE(u.username, ''), SUM(b.bytes) AS total FROM user_bandwidth b
+	           LEFT JOIN users u ON u.id = b.user_id
+	           WHERE b.day >= ?
+	           GROUP BY b.user_id, u.username ORDER BY total DESC, b.user_id LIMIT ?`
+	rows, err := r.DB.QueryContext(ctx, query, sinceDay, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top bandwidth users: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make([]model.BandwidthUsage, 0)
+	for rows.Next() {
+		var u model.BandwidthUsage
+		if err := rows.Scan(&u.UserID, &u.Username, &u.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan top bandwidth users: %w", err)
+		}
+		usage = append(usage, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTopBandwidthUsers: %w", err)
+	}
+
+	return usage, nil
+}
@@ -13,11 +13,19 @@ type ChatRepository interface {
 	// Session operations
 	GetOrCreateSession(userID int64) (*model.ChatSession, error)
 	GetSessionByUserID(userID int64) (*model.ChatSession, error)
+	GetUserSession(userID, sessionID int64) (*model.ChatSession, error)
+	ListSessions(userID int64, includeArchived bool) ([]*model.ChatSession, error)
+	CreateSession(userID int64, title string) (*model.ChatSession, error)
+	RenameSession(sessionID int64, title string) error
+	SetGeneratedTitle(sessionID int64, title string) (bool, error)
+	SetSessionArchived(sessionID int64, archived bool) error
+	UpdateSummary(sessionID int64, summary string, untilMessageID int64) (bool, error)
 	DeleteSession(sessionID int64) error
 
 	// Message operations
 	CreateMessage(message *model.ChatMessage) (int64, error)
 	GetMessagesBySessionID(sessionID int64, limit int) ([]*model.ChatMessage, error)
+	GetMessagesAfter(sessionID, afterID int64, limit int) ([]*model.ChatMessage, error)
 	DeleteMessagesBySessionID(sessionID int64) error
 }
 
@@ -31,7 +39,23 @@ func NewMySQLChatRepository(db *sql.DB) ChatRepository {
 	return &mysqlChatRepository{db: db}
 }
 
-// GetOrCreateSession gets an existing session for a user or creates a new one.
+// sessionColumns is the column list scanned by scanSession.
+const sessionColumns = "id, user_id, title, archived_at, COALESCE(summary, ''), summary_until, created_at, updated_at"
+
+// scanSession scans one chat_sessions row.
+func scanSession(row interface{ Scan(...interface{}) error }) (*model.ChatSession, error) {
+	session := &model.ChatSession{}
+	var archivedAt sql.NullTime
+	if err := row.Scan(&session.ID, &session.UserID, &session.Title, &archivedAt, &session.Summary, &session.SummaryUntil, &session.CreatedAt, &session.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if archivedAt.Valid {
+		session.ArchivedAt = &archivedAt.Time
+	}
+	return session, nil
+}
+
+// GetOrCreateSession gets the user's most recently active session or creates a new one.
 func (r *mysqlChatRepository) GetOrCreateSession(userID int64) (*model.ChatSession, error) {
 	// First, try to get existing session
 	session, err := r.GetSessionByUserID(userID)
@@ -41,16 +65,62 @@ func (r *mysqlChatRepository) GetOrCreateSession(userID int64) (*model.ChatSessi
 	if session != nil {
 		return session, nil
 	}
+	return r.CreateSession(userID, model.DefaultChatSessionTitle)
+}
 
-	// Create new session
-	query := "INSERT INTO chat_sessions (user_id, title) VALUES (?, '音乐助手')"
-	stmt, err := r.db.Prepare(query)
+// GetSessionByUserID retrieves the user's most recently active, non-archived session.
+func (r *mysqlChatRepository) GetSessionByUserID(userID int64) (*model.ChatSession, error) {
+	query := "SELECT " + sessionColumns + " FROM chat_sessions WHERE user_id = ? AND archived_at IS NULL ORDER BY updated_at DESC, id DESC LIMIT 1"
+	session, err := scanSession(r.db.QueryRow(query, userID))
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare create session statement: %w", err)
+		if err == sql.ErrNoRows {
+			return nil, nil // Session not found
+		}
+		return nil, fmt.Errorf("failed to scan session row for user ID %d: %w", userID, err)
 	}
-	defer stmt.Close()
+	return session, nil
+}
+
+// GetUserSession retrieves a session by ID, returning nil if it does not belong to the user.
+func (r *mysqlChatRepository) GetUserSession(userID, sessionID int64) (*model.ChatSession, error) {
+	session, err := r.getSessionByID(sessionID)
+	if err != nil || session == nil || session.UserID != userID {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ListSessions lists the user's sessions, most recently active first.
+func (r *mysqlChatRepository) ListSessions(userID int64, includeArchived bool) ([]*model.ChatSession, error) {
+	query := "SELECT " + sessionColumns + " FROM chat_sessions WHERE user_id = ?"
+	if !includeArchived {
+		query += " AND archived_at IS NULL"
+	}
+	query += " ORDER BY updated_at DESC, id DESC"
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	sessions := []*model.ChatSession{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session row: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating session rows: %w", err)
+	}
+	return sessions, nil
+}
 
-	res, err := stmt.Exec(userID)
+// CreateSession creates a new session for the user.
+func (r *mysqlChatRepository) CreateSession(userID int64, title string) (*model.ChatSession, error) {
+	res, err := r.db.Exec("INSERT INTO chat_sessions (user_id, title) VALUES (?, ?)", userID, title)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute create session statement: %w", err)
 	}
@@ -64,29 +134,60 @@ func (r *mysqlChatRepository) GetOrCreateSession(userID int64) (*model.ChatSessi
 	return r.getSessionByID(sessionID)
 }
 
-// GetSessionByUserID retrieves a session by user ID.
-func (r *mysqlChatRepository) GetSessionByUserID(userID int64) (*model.ChatSession, error) {
-	query := "SELECT id, user_id, title, created_at, updated_at FROM chat_sessions WHERE user_id = ?"
-	row := r.db.QueryRow(query, userID)
+// RenameSession sets the title of a session. Renaming and archiving keep updated_at,
+// which orders sessions by their last message.
+func (r *mysqlChatRepository) RenameSession(sessionID int64, title string) error {
+	if _, err := r.db.Exec("UPDATE chat_sessions SET title = ?, updated_at = updated_at WHERE id = ?", title, sessionID); err != nil {
+		return fmt.Errorf("failed to rename session %d: %w", sessionID, err)
+	}
+	return nil
+}
 
-	session := &model.ChatSession{}
-	err := row.Scan(&session.ID, &session.UserID, &session.Title, &session.CreatedAt, &session.UpdatedAt)
+// SetGeneratedTitle sets the title only while the session still has the default title,
+// so a title the user chose in the meantime is kept. Reports whether the title was set.
+func (r *mysqlChatRepository) SetGeneratedTitle(sessionID int64, title string) (bool, error) {
+	res, err := r.db.Exec("UPDATE chat_sessions SET title = ?, updated_at = updated_at WHERE id = ? AND title = ?", title, sessionID, model.DefaultChatSessionTitle)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // Session not found
-		}
-		return nil, fmt.Errorf("failed to scan session row for user ID %d: %w", userID, err)
+		return false, fmt.Errorf("failed to set generated title for session %d: %w", sessionID, err)
 	}
-	return session, nil
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// SetSessionArchived archives or restores a session.
+func (r *mysqlChatRepository) SetSessionArchived(sessionID int64, archived bool) error {
+	query := "UPDATE chat_sessions SET archived_at = NULL, updated_at = updated_at WHERE id = ?"
+	if archived {
+		query = "UPDATE chat_sessions SET archived_at = NOW(), updated_at = updated_at WHERE id = ?"
+	}
+	if _, err := r.db.Exec(query, sessionID); err != nil {
+		return fmt.Errorf("failed to update archive state of session %d: %w", sessionID, err)
+	}
+	return nil
+}
+
+// UpdateSummary stores the session summary covering messages up to untilMessageID.
+// A summary never replaces one that already covers later messages; reports whether it was stored.
+func (r *mysqlChatRepository) UpdateSummary(sessionID int64, summary string, untilMessageID int64) (bool, error) {
+	res, err := r.db.Exec("UPDATE chat_sessions SET summary = ?, summary_until = ?, updated_at = updated_at WHERE id = ? AND summary_until < ?",
+		summary, untilMessageID, sessionID, untilMessageID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update summary of session %d: %w", sessionID, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return affected > 0, nil
 }
 
 // getSessionByID retrieves a session by its ID.
 func (r *mysqlChatRepository) getSessionByID(sessionID int64) (*model.ChatSession, error) {
-	query := "SELECT id, user_id, title, created_at, updated_at FROM chat_sessions WHERE id = ?"
-	row := r.db.QueryRow(query, sessionID)
-
-	session := &model.ChatSession{}
-	err := row.Scan(&session.ID, &session.UserID, &session.Title, &session.CreatedAt, &session.UpdatedAt)
+	query := "SELECT " + sessionColumns + " FROM chat_sessions WHERE id = ?"
+	session, err := scanSession(r.db.QueryRow(query, sessionID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Session not found
@@ -151,16 +252,22 @@ func (r *mysqlChatRepository) CreateMessage(message *model.ChatMessage) (int64,
 
 // GetMessagesBySessionID retrieves messages for a session with a limit.
 func (r *mysqlChatRepository) GetMessagesBySessionID(sessionID int64, limit int) ([]*model.ChatMessage, error) {
+	return r.GetMessagesAfter(sessionID, 0, limit)
+}
+
+// GetMessagesAfter retrieves the most recent messages of a session whose ID is greater
+// than afterID, in chronological order.
+func (r *mysqlChatRepository) GetMessagesAfter(sessionID, afterID int64, limit int) ([]*model.ChatMessage, error) {
 	// Get the most recent messages, ordered by created_at ASC for conversation flow
 	query := `
 		SELECT id, session_id, role, content, songs, created_at
 		FROM chat_messages
-		WHERE session_id = ?
-		ORDER BY created_at DESC
+		WHERE session_id = ? AND id > ?
+		ORDER BY created_at DESC, id DESC
 		LIMIT ?
 	`
 
-	rows, err := r.db.Query(query, sessionID, limit)
+	rows, err := r.db.Query(query, sessionID, afterID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query messages for session ID %d: %w", sessionID, err)
 	}
@@ -211,5 +318,10 @@ func (r *mysqlChatRepository) DeleteMessagesBySessionID(sessionID int64) error {
 	if err != nil {
 		return fmt.Errorf("failed to execute delete messages statement: %w", err)
 	}
+
+	// The summary describes the deleted messages, drop it as well
+	if _, err := r.db.Exec("UPDATE chat_sessions SET summary = NULL, summary_until = 0, updated_at = updated_at WHERE id = ?", sessionID); err != nil {
+		return fmt.Errorf("failed to reset summary of session %d: %w", sessionID, err)
+	}
 	return nil
 }
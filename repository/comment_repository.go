@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// CommentRepository defines the interface for track comment operations.
+type CommentRepository interface {
+	CreateComment(ctx context.Context, comment *model.Comment) (int64, error)
+	GetCommentByID(ctx context.Context, id int64) (*model.Comment, error)
+	GetComments(ctx context.Context, source, sourceID string, byPosition bool, limit, offset int) ([]*model.Comment, int64, error)
+	DeleteComment(ctx context.Context, id int64) error
+	CountComments(ctx context.Context, source string, sourceIDs []string) (map[string]int64, error)
+}
+
+// mysqlCommentRepository implements CommentRepository for MySQL.
+type mysqlCommentRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLCommentRepository creates a new instance of mysqlCommentRepository.
+func NewMySQLCommentRepository() CommentRepository {
+	return &mysqlCommentRepository{DB: db.DB}
+}
+
+// CreateComment inserts a comment and returns its ID.
+func (r *mysqlCommentRepository) CreateComment(ctx context.Context, comment *model.Comment) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO track_comments (user_id, source, source_id, content, position, parent_id) VALUES (?, ?, ?, ?, ?, ?)`
+	res, err := r.DB.ExecContext(ctx, query, comment.UserID, comment.Source, comment.SourceID, comment.Content,
+		comment.Position, comment.ParentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create comment: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID for comment: %w", err)
+	}
+	return id, nil
+}
+
+// GetCommentByID retrieves a comment with its author's username, or nil if it does not exist.
+func (r *mysqlCommentRepository) GetCommentByID(ctx context.Context, id int64) (*model.Comment, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT c.id, c.user_id, COALESCE(u.username, ''), c.source, c.source_id, c.content, c.position, c.parent_id, c.created_at
+	           FROM track_comments c
+	           LEFT JOIN users u ON u.id = c.user_id
+	           WHERE c.id = ?`
+	comment, err := scanComment(r.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment ID %d: %w", id, err)
+	}
+	return comment, nil
+}
+
+// GetComments retrieves one page of comments of a song together with the total count.
+// Comments are returned newest first, or when byPosition is set only timestamped comments in playback order.
+func (r *mysqlCommentRepository) GetComments(ctx context.Context, source, sourceID string, byPosition bool, limit, offset int) ([]*model.Comment, int64, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	where := `c.source = ? AND c.source_id = ?`
+	order := `c.created_at DESC, c.id DESC`
+	if byPosition {
+		where += ` AND c.position IS NOT NULL`
+		order = `c.position, c.id`
+	}
+
+	var total int64
+	if err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM track_comments c WHERE `+where, source, sourceID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count comments: %w", err)
+	}
+
+	query := `SELECT c.id, c.user_id, COALESCE(u.username, ''), c.source, c.source_id, c.content, c.position, c.parent_id, c.created_at
+	           FROM track_comments c
+	           LEFT JOIN users u ON u.id = c.user_id
+	           WHERE ` + where + `
+	           ORDER BY ` + order + `
+	           LIMIT ? OFFSET ?`
+	rows, err := r.DB.QueryContext(ctx, query, source, sourceID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := make([]*model.Comment, 0)
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan comment in GetComments: %w", err)
+		}
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error during rows iteration in GetComments: %w", err)
+	}
+
+	return comments, total, nil
+}
+
+// DeleteComment deletes a comment by ID.
+func (r *mysqlCommentRepository) DeleteComment(ctx context.Context, id int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.DB.ExecContext(ctx, `DELETE FROM track_comments WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete comment ID %d: %w", id, err)
+	}
+	return nil
+}
+
+// CountComments returns the number of comments of each given song; songs without comments are omitted.
+func (r *mysqlCommentRepository) CountComments(ctx context.Context, source string, sourceIDs []string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(sourceIDs))
+	if len(sourceIDs) == 0 {
+		return counts, nil
+	}
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	args := make([]interface{}, 0, len(sourceIDs)+1)
+	args = append(args, source)
+	for _, id := range sourceIDs {
+		args = append(args, id)
+	}
+	query := `SELECT source_id, COUNT(*) FROM track_comments
+	           WHERE source = ? AND source_id IN (` + placeholders(len(sourceIDs)) + `)
+	           GROUP BY source_id`
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count comments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sourceID string
+		var count int64
+		if err := rows.Scan(&sourceID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan comment count: %w", err)
+		}
+		counts[sourceID] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in CountComments: %w", err)
+	}
+
+	return counts, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanComment scans one comment row selected with its author's username.
+func scanComment(row rowScanner) (*model.Comment, error) {
+	comment := &model.Comment{}
+	var position sql.NullFloat64
+	var parentID sql.NullInt64
+	if err := row.Scan(&comment.ID, &comment.UserID, &comment.Username, &comment.Source, &comment.SourceID,
+		&comment.Content, &position, &parentID, &comment.CreatedAt); err != nil {
+		return nil, err
+	}
+	if position.Valid {
+		comment.Position = &position.Float64
+	}
+	if parentID.Valid {
+		comment.ParentID = &parentID.Int64
+	}
+	return comment, nil
+}
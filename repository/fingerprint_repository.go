@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// FingerprintRepository defines the interface for track fingerprint operations.
+type FingerprintRepository interface {
+	SaveFingerprint(fp *model.TrackFingerprint) error
+	GetFingerprintsByUserID(userID int64) ([]*model.TrackFingerprint, error)
+	GetFingerprintsNearDuration(userID int64, duration, tolerance float64, limit int) ([]*model.TrackFingerprint, error)
+}
+
+// mysqlFingerprintRepository implements FingerprintRepository for MySQL.
+type mysqlFingerprintRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLFingerprintRepository creates a new instance of mysqlFingerprintRepository.
+func NewMySQLFingerprintRepository() FingerprintRepository {
+	return &mysqlFingerprintRepository{DB: db.DB}
+}
+
+// SaveFingerprint inserts or replaces the fingerprint of a track.
+func (r *mysqlFingerprintRepository) SaveFingerprint(fp *model.TrackFingerprint) error {
+	query := `INSERT INTO track_fingerprints (track_id, user_id, duration, fingerprint)
+	           VALUES (?, ?, ?, ?)
+	           ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), duration = VALUES(duration), fingerprint = VALUES(fingerprint)`
+	if _, err := r.DB.Exec(query, fp.TrackID, fp.UserID, fp.Duration, fp.Fingerprint); err != nil {
+		return fmt.Errorf("failed to save fingerprint for track ID %d: %w", fp.TrackID, err)
+	}
+	return nil
+}
+
+// GetFingerprintsByUserID retrieves fingerprints of all active tracks owned by a user, ordered by duration.
+func (r *mysqlFingerprintRepository) GetFingerprintsByUserID(userID int64) ([]*model.TrackFingerprint, error) {
+	query := `SELECT f.track_id, f.user_id, f.duration, f.fingerprint, f.created_at
+	           FROM track_fingerprints f
+	           JOIN tracks t ON t.id = f.track_id
+	           WHERE f.user_id = ? AND t.state = 1
+	           ORDER BY f.duration, f.track_id`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fingerprints for user ID %d: %w", userID, err)
+	}
+	return scanFingerprints(rows, "GetFingerprintsByUserID")
+}
+
+// GetFingerprintsNearDuration retrieves fingerprints of a user's active tracks whose duration is within
+// tolerance seconds of duration, closest first, at most limit rows.
+func (r *mysqlFingerprintRepository) GetFingerprintsNearDuration(userID int64, duration, tolerance float64, limit int) ([]*model.TrackFingerprint, error) {
+	query := `SELECT f.track_id, f.user_id, f.duration, f.fingerprint, f.created_at
+	           FROM track_fingerprints f
+	           JOIN tracks t ON t.id = f.track_id
+	           WHERE f.user_id = ? AND f.duration BETWEEN ? AND ? AND t.state = 1
+	           ORDER BY ABS(f.duration - ?), f.track_id
+	           LIMIT ?`
+	rows, err := r.DB.Query(query, userID, duration-tolerance, duration+tolerance, duration, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fingerprints near duration for user ID %d: %w", userID, err)
+	}
+	return scanFingerprints(rows, "GetFingerprintsNearDuration")
+}
+
+// scanFingerprints reads all fingerprint rows and closes rows.
+func scanFingerprints(rows *sql.Rows, caller string) ([]*model.TrackFingerprint, error) {
+	defer rows.Close()
+
+	fps := make([]*model.TrackFingerprint, 0)
+	for rows.Next() {
+		fp := &model.TrackFingerprint{}
+		if err := rows.Scan(&fp.TrackID, &fp.UserID, &fp.Duration, &fp.Fingerprint, &fp.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan fingerprint in %s: %w", caller, err)
+		}
+		fps = append(fps, fp)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in %s: %w", caller, err)
+	}
+
+	return fps, nil
+}
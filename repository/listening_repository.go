@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// ListeningRepository defines the interface for daily listening time and streak operations.
+type ListeningRepository interface {
+	RollupDay(ctx context.Context, day string, start, end time.Time) (int64, error)
+	GetListenedSeconds(ctx context.Context, userID int64, start, end time.Time) (int64, error)
+	GetListeningDays(ctx context.Context, userID int64, sinceDay string) ([]model.ListeningDay, error)
+	GetGoalDays(ctx context.Context, userID int64, minSeconds int64, untilDay string, limit int) ([]string, error)
+	GetStreak(ctx context.Context, userID int64) (*model.StreakRecord, error)
+	SaveStreak(ctx context.Context, record *model.StreakRecord) error
+}
+
+// mysqlListeningRepository implements ListeningRepository for MySQL.
+type mysqlListeningRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLListeningRepository creates a new instance of mysqlListeningRepository.
+func NewMySQLListeningRepository() ListeningRepository {
+	return &mysqlListeningRepository{DB: db.DB}
+}
+
+// RollupDay sums the listening time of plays started in [start, end) per user and stores it under day.
+// Rolling up the same day again replaces its values, so listens still in progress are picked up by the next run.
+func (r *mysqlListeningRepository) RollupDay(ctx context.Context, day string, start, end time.Time) (int64, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO user_listening_days (user_id, day, seconds)
+	           SELECT user_id, ?, ROUND(SUM(listened)) FROM play_history
+	           WHERE started_at >= ? AND started_at < ? GROUP BY user_id
+	           ON DUPLICATE KEY UPDATE seconds = VALUES(seconds)`
+	result, err := r.DB.ExecContext(ctx, query, day, start, end)
+	if err != nil {
+		return 0, fmt.Errorf("failed to roll up listening time for %s: %w", day, err)
+	}
+	return result.RowsAffected()
+}
+
+// GetListenedSeconds returns how long a user listened to plays started in [start, end).
+func (r *mysqlListeningRepository) GetListenedSeconds(ctx context.Context, userID int64, start, end time.Time) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var seconds int64
+	query := `SELECT COALESCE(ROUND(SUM(listened)), 0) FROM play_history
+	           WHERE user_id = ? AND started_at >= ? AND started_at < ?`
+	if err := r.DB.QueryRowContext(ctx, query, userID, start, end).Scan(&seconds); err != nil {
+		return 0, fmt.Errorf("failed to query listened seconds for user ID %d: %w", userID, err)
+	}
+	return seconds, nil
+}
+
+// GetListeningDays returns a user's rolled-up listening time per day from sinceDay (inclusive) onwards, oldest first.
+func (r *mysqlListeningRepository) GetListeningDays(ctx context.Context, userID int64, sinceDay string) ([]model.ListeningDay, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT DATE_FORMAT(day, '%Y-%m-%d'), seconds FROM user_listening_days
+	           WHERE user_id = ? AND day >= ? ORDER BY day`
+	rows, err := r.DB.QueryContext(ctx, query, userID, sinceDay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query listening days for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	days := make([]model.ListeningDay, 0)
+	for rows.Next() {
+		var d model.ListeningDay
+		if err := rows.Scan(&d.Day, &d.Seconds); err != nil {
+			return nil, fmt.Errorf("failed to scan listening day: %w", err)
+		}
+		days = append(days, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetListeningDays: %w", err)
+	}
+
+	return days, nil
+}
+
+// GetGoalDays returns up to limit days on or before untilDay on which a user listened at least minSeconds, newest first.
+func (r *mysqlListeningRepository) GetGoalDays(ctx context.Context, userID int64, minSeconds int64, untilDay string, limit int) ([]string, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT DATE_FORMAT(day, '%Y-%m-%d') FROM user_listening_days
+	           WHERE user_id = ? AND day <= ? AND seconds >= ? ORDER BY day DESC LIMIT ?`
+	rows, err := r.DB.QueryContext(ctx, query, userID, untilDay, minSeconds, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goal days for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	days := make([]string, 0)
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("failed to scan goal day: %w", err)
+		}
+		days = append(days, day)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetGoalDays: %w", err)
+	}
+
+	return days, nil
+}
+
+// GetStreak returns a user's stored streak record, or nil if the user has none yet.
+func (r *mysqlListeningRepository) GetStreak(ctx context.Context, userID int64) (*model.StreakRecord, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	record := &model.StreakRecord{UserID: userID}
+	var startDay sql.NullString
+	query := `SELECT DATE_FORMAT(start_day, '%Y-%m-%d'), current_days, longest_days, notified_days
+	           FROM user_streaks WHERE user_id = ?`
+	err := r.DB.QueryRowContext(ctx, query, userID).Scan(&startDay, &record.CurrentDays, &record.LongestDays, &record.NotifiedDays)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get streak for user ID %d: %w", userID, err)
+	}
+	record.StartDay = startDay.String
+	return record, nil
+}
+
+// SaveStreak creates or replaces a user's streak record.
+func (r *mysqlListeningRepository) SaveStreak(ctx context.Context, record *model.StreakRecord) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var startDay interface{}
+	if record.StartDay != "" {
+		startDay = record.StartDay
+	}
+	query := `INSERT INTO user_streaks (user_id, start_day, current_days, longest_days, notified_days)
+	           VALUES (?, ?, ?, ?, ?)
+	           ON DUPLICATE KEY UPDATE start_day = VALUES(start_day), current_days = VALUES(current_days),
+	           longest_days = VALUES(longest_days), notified_days = VALUES(notified_days)`
+	if _, err := r.DB.ExecContext(ctx, query, record.UserID, startDay, record.CurrentDays, record.LongestDays, record.NotifiedDays); err != nil {
+		return fmt.Errorf("failed to save streak for user ID %d: %w", record.UserID, err)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"Bt1QFM/db"
 	"Bt1QFM/model"
 	"database/sql"
@@ -60,7 +62,7 @@ func (repo *NeteaseSongRepository) GetNeteaseSongByID(songID string) (*model.Net
 		return nil, fmt.Errorf("invalid song ID: %w", err)
 	}
 
-	query := `SELECT id, title, artist, album, file_path, cover_art_path, hls_playlist_path, duration, created_at, updated_at 
+	query := `SELECT id, title, artist, album, file_path, cover_art_path, hls_playlist_path, duration, play_count, created_at, updated_at 
 		FROM netease_song WHERE id = ?`
 
 	var song model.NeteaseSongDB
@@ -73,6 +75,7 @@ func (repo *NeteaseSongRepository) GetNeteaseSongByID(songID string) (*model.Net
 		&song.CoverArtPath,
 		&song.HLSPlaylistPath,
 		&song.Duration,
+		&song.PlayCount,
 		&song.CreatedAt,
 		&song.UpdatedAt,
 	)
@@ -120,3 +123,30 @@ func (repo *NeteaseSongRepository) UpdateNeteaseSong(song *model.NeteaseSongDB)
 
 	return rowsAffected > 0, nil
 }
+
+// GetNeteaseSongsByIDs 批量获取网易云歌曲信息，数据库中没有的歌曲不在结果中
+func (repo *NeteaseSongRepository) GetNeteaseSongsByIDs(ctx context.Context, ids []int64) ([]*model.NeteaseSongDB, error) {
+	if len(ids) == 0 {
+		return []*model.NeteaseSongDB{}, nil
+	}
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, title, COALESCE(artist, ''), COALESCE(album, ''), COALESCE(cover_art_path, ''), COALESCE(duration, 0), play_count
+		FROM netease_song WHERE id IN (` + placeholders(len(ids)) + `)`
+	rows, err := repo.DB.QueryContext(ctx, query, int64Args(ids)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := make([]*model.NeteaseSongDB, 0, len(ids))
+	for rows.Next() {
+		var song model.NeteaseSongDB
+		if err := rows.Scan(&song.ID, &song.Title, &song.Artist, &song.Album, &song.CoverArtPath, &song.Duration, &song.PlayCount); err != nil {
+			return nil, err
+		}
+		songs = append(songs, &song)
+	}
+	return songs, rows.Err()
+}
@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// NotificationRepository defines the interface for user notification operations.
+type NotificationRepository interface {
+	CreateNotification(ctx context.Context, n *model.Notification) (int64, error)
+	CreateForAudience(ctx context.Context, n *model.Notification, audience model.NotificationAudience) (int64, error)
+	GetObjectNotifications(ctx context.Context, userIDs []int64, notificationType, objectID string) ([]*model.Notification, error)
+	GetNotifications(ctx context.Context, userID int64, unreadOnly bool, beforeID int64, limit int) ([]*model.Notification, error)
+	CountUnread(ctx context.Context, userID int64) (int64, error)
+	MarkRead(ctx context.Context, userID int64, ids []int64) (int64, error)
+	MarkAllRead(ctx context.Context, userID int64) (int64, error)
+	MarkObjectRead(ctx context.Context, userID int64, notificationType, objectID string) error
+	MarkObjectUnread(ctx context.Context, notificationType, objectID string) (int64, error)
+	GetNotificationByID(ctx context.Context, userID, id int64) (*model.Notification, error)
+}
+
+// mysqlNotificationRepository implements NotificationRepository for MySQL.
+type mysqlNotificationRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLNotificationRepository creates a new instance of mysqlNotificationRepository.
+func NewMySQLNotificationRepository() NotificationRepository {
+	return &mysqlNotificationRepository{DB: db.DB}
+}
+
+// CreateNotification inserts a notification for one user and returns its ID.
+func (r *mysqlNotificationRepository) CreateNotification(ctx context.Context, n *model.Notification) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO notifications (user_id, type, actor_id, object_id, title, content) VALUES (?, ?, ?, ?, ?, ?)`
+	res, err := r.DB.ExecContext(ctx, query, n.UserID, n.Type, nullActor(n.ActorID), n.ObjectID, n.Title, n.Content)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create notification for user ID %d: %w", n.UserID, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID for notification: %w", err)
+	}
+	return id, nil
+}
+
+// CreateForAudience inserts a copy of the notification for every enabled user in the audience
+// and returns the number of rows created.
+func (r *mysqlNotificationRepository) CreateForAudience(ctx context.Context, n *model.Notification, audience model.NotificationAudience) (int64, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	args := []interface{}{n.Type, nullActor(n.ActorID), n.ObjectID, n.Title, n.Content, model.UserStatusDisabled}
+	query := `INSERT INTO notifications (user_id, type, actor_id, object_id, title, content)
+	           SELECT id, ?, ?, ?, ?, ? FROM users WHERE status <> ?`
+	if audience.JoinedAfter != nil {
+		query += ` AND created_at >= ?`
+		args = append(args, *audience.JoinedAfter)
+	}
+	if len(audience.UserIDs) > 0 {
+		query += ` AND id IN (` + placeholders(len(audience.UserIDs)) + `)`
+		args = append(args, int64Args(audience.UserIDs)...)
+	}
+
+	res, err := r.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s notifications: %w", n.Type, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows for CreateForAudience: %w", err)
+	}
+	return affected, nil
+}
+
+// GetObjectNotifications retrieves the notifications about an object received by the given users.
+func (r *mysqlNotificationRepository) GetObjectNotifications(ctx context.Context, userIDs []int64, notificationType, objectID string) ([]*model.Notification, error) {
+	notifications := make([]*model.Notification, 0, len(userIDs))
+	if len(userIDs) == 0 {
+		return notifications, nil
+	}
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	args := append([]interface{}{notificationType, objectID}, int64Args(userIDs)...)
+	query := `SELECT n.id, n.user_id, n.type, COALESCE(n.actor_id, 0), COALESCE(u.username, ''), n.object_id, n.title,
+	                 COALESCE(n.content, ''), n.read_at, n.created_at
+	           FROM notifications n
+	           LEFT JOIN users u ON u.id = n.actor_id
+	           WHERE n.type = ? AND n.object_id = ? AND n.user_id IN (` + placeholders(len(userIDs)) + `)`
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s %s notifications: %w", notificationType, objectID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification in GetObjectNotifications: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetObjectNotifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// GetNotifications retrieves the notifications of a user with IDs below beforeID, newest first.
+// A beforeID of 0 starts from the newest notification.
+func (r *mysqlNotificationRepository) GetNotifications(ctx context.Context, userID int64, unreadOnly bool, beforeID int64, limit int) ([]*model.Notification, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	where := `n.user_id = ? AND (? = 0 OR n.id < ?)`
+	if unreadOnly {
+		where += ` AND n.read_at IS NULL`
+	}
+	query := `SELECT n.id, n.user_id, n.type, COALESCE(n.actor_id, 0), COALESCE(u.username, ''), n.object_id, n.title,
+	                 COALESCE(n.content, ''), n.read_at, n.created_at
+	           FROM notifications n
+	           LEFT JOIN users u ON u.id = n.actor_id
+	           WHERE ` + where + `
+	           ORDER BY n.id DESC
+	           LIMIT ?`
+	rows, err := r.DB.QueryContext(ctx, query, userID, beforeID, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*model.Notification, 0)
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification in GetNotifications: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetNotifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// GetNotificationByID retrieves a notification of a user, or nil if it does not exist or belongs to someone else.
+func (r *mysqlNotificationRepository) GetNotificationByID(ctx context.Context, userID, id int64) (*model.Notification, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT n.id, n.user_id, n.type, COALESCE(n.actor_id, 0), COALESCE(u.username, ''), n.object_id, n.title,
+	                 COALESCE(n.content, ''), n.read_at, n.created_at
+	           FROM notifications n
+	           LEFT JOIN users u ON u.id = n.actor_id
+	           WHERE n.id = ? AND n.user_id = ?`
+	n, err := scanNotification(r.DB.QueryRowContext(ctx, query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification ID %d: %w", id, err)
+	}
+	return n, nil
+}
+
+// CountUnread counts the unread notifications of a user.
+func (r *mysqlNotificationRepository) CountUnread(ctx context.Context, userID int64) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL`
+	if err := r.DB.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications for user ID %d: %w", userID, err)
+	}
+	return count, nil
+}
+
+// MarkRead marks the given notifications of a user as read and returns how many were unread.
+func (r *mysqlNotificationRepository) MarkRead(ctx context.Context, userID int64, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	args := append([]interface{}{userID}, int64Args(ids)...)
+	query := `UPDATE notifications SET read_at = NOW()
+	           WHERE user_id = ? AND read_at IS NULL AND id IN (` + placeholders(len(ids)) + `)`
+	res, err := r.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read for user ID %d: %w", userID, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows for MarkRead: %w", err)
+	}
+	return affected, nil
+}
+
+// MarkAllRead marks every notification of a user as read and returns how many were unread.
+func (r *mysqlNotificationRepository) MarkAllRead(ctx context.Context, userID int64) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := r.DB.ExecContext(ctx, `UPDATE notifications SET read_at = NOW() WHERE user_id = ? AND read_at IS NULL`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark all notifications read for user ID %d: %w", userID, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows for MarkAllRead: %w", err)
+	}
+	return affected, nil
+}
+
+// MarkObjectRead marks the notifications of a user about an object as read, e.g. when an announcement is read elsewhere.
+func (r *mysqlNotificationRepository) MarkObjectRead(ctx context.Context, userID int64, notificationType, objectID string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE notifications SET read_at = NOW()
+	           WHERE user_id = ? AND type = ? AND object_id = ? AND read_at IS NULL`
+	if _, err := r.DB.ExecContext(ctx, query, userID, notificationType, objectID); err != nil {
+		return fmt.Errorf("failed to mark %s %s read for user ID %d: %w", notificationType, objectID, userID, err)
+	}
+	return nil
+}
+
+// MarkObjectUnread marks every user's notifications about an object as unread again, e.g. after an announcement is edited.
+func (r *mysqlNotificationRepository) MarkObjectUnread(ctx context.Context, notificationType, objectID string) (int64, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE notifications SET read_at = NULL WHERE type = ? AND object_id = ? AND read_at IS NOT NULL`
+	res, err := r.DB.ExecContext(ctx, query, notificationType, objectID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark %s %s unread: %w", notificationType, objectID, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows for MarkObjectUnread: %w", err)
+	}
+	return affected, nil
+}
+
+// nullActor stores system notifications without an actor as NULL.
+func nullActor(actorID int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: actorID, Valid: actorID > 0}
+}
+
+// scanNotification scans one notification row selected with its actor's username.
+func scanNotification(row rowScanner) (*model.Notification, error) {
+	n := &model.Notification{}
+	var readAt sql.NullTime
+	if err := row.Scan(&n.ID, &n.UserID, &n.Type, &n.ActorID, &n.ActorName, &n.ObjectID, &n.Title,
+		&n.Content, &readAt, &n.CreatedAt); err != nil {
+		return nil, err
+	}
+	if readAt.Valid {
+		n.ReadAt = &readAt.Time
+	}
+	return n, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// playCountBatchSize 每条 SQL 语句写入或更新的歌曲数
+const playCountBatchSize = 500
+
+// playCountTotals 各来源累计播放次数所在的表
+var playCountTotals = map[string]string{
+	"local":   "tracks",
+	"netease": "netease_song",
+}
+
+// PlayCountRepository defines the interface for daily play counter operations.
+type PlayCountRepository interface {
+	SaveDailyPlayCounts(ctx context.Context, day string, counts []model.PlayCount) error
+	GetTopPlayCounts(ctx context.Context, source, sinceDay string, limit int) ([]model.PlayCount, error)
+}
+
+// mysqlPlayCountRepository implements PlayCountRepository for MySQL.
+type mysqlPlayCountRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLPlayCountRepository creates a new instance of mysqlPlayCountRepository.
+func NewMySQLPlayCountRepository() PlayCountRepository {
+	return &mysqlPlayCountRepository{DB: db.DB}
+}
+
+// SaveDailyPlayCounts stores one day's play counts and refreshes the play_count totals of the affected songs.
+// Saving the same day again replaces its counts, so a rollup interrupted before clearing Redis can simply be retried.
+func (r *mysqlPlayCountRepository) SaveDailyPlayCounts(ctx context.Context, day string, counts []model.PlayCount) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(counts); start += playCountBatchSize {
+		batch := counts[start:min(start+playCountBatchSize, len(counts))]
+		args := make([]interface{}, 0, len(batch)*4)
+		for _, c := range batch {
+			args = append(args, day, c.Source, c.SourceID, c.Plays)
+		}
+		query := `INSERT INTO play_counts (day, source, source_id, plays) VALUES ` +
+			strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?), ", len(batch)), ", ") +
+			` ON DUPLICATE KEY UPDATE plays = VALUES(plays)`
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to save play counts for %s: %w", day, err)
+		}
+	}
+
+	bySource := make(map[string][]interface{})
+	for _, c := range counts {
+		if _, ok := playCountTotals[c.Source]; ok {
+			bySource[c.Source] = append(bySource[c.Source], c.SourceID)
+		}
+	}
+	for source, ids := range bySource {
+		table := playCountTotals[source]
+		for start := 0; start < len(ids); start += playCountBatchSize {
+			batch := ids[start:min(start+playCountBatchSize, len(ids))]
+			query := `UPDATE ` + table + ` t SET play_count =
+			           (SELECT COALESCE(SUM(plays), 0) FROM play_counts c WHERE c.source = ? AND c.source_id = CAST(t.id AS CHAR))
+			           WHERE t.id IN (` + placeholders(len(batch)) + `)`
+			args := append([]interface{}{source}, batch...)
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return fmt.Errorf("failed to update %s play counts: %w", table, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit play counts: %w", err)
+	}
+	return nil
+}
+
+// GetTopPlayCounts returns the most played songs of a source from sinceDay (inclusive) onwards.
+func (r *mysqlPlayCountRepository) GetTopPlayCounts(ctx context.Context, source, sinceDay string, limit int) ([]model.PlayCount, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT source_id, SUM(plays) AS total FROM play_counts
+	           WHERE source = ? AND day >= ?
+	           GROUP BY source_id ORDER BY total DESC, source_id LIMIT ?`
+	rows, err := r.DB.QueryContext(ctx, query, source, sinceDay, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top play counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make([]model.PlayCount, 0)
+	for rows.Next() {
+		c := model.PlayCount{Source: source}
+		if err := rows.Scan(&c.SourceID, &c.Plays); err != nil {
+			return nil, fmt.Errorf("failed to scan top play counts: %w", err)
+		}
+		counts = append(counts, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTopPlayCounts: %w", err)
+	}
+
+	return counts, nil
+}
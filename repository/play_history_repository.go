@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// PlayHistoryRepository defines the interface for playback history operations.
+type PlayHistoryRepository interface {
+	CreatePlay(ctx context.Context, play *model.PlayHistory) (int64, error)
+	UpdatePlayProgress(ctx context.Context, id int64, listened float64, scrobbled bool) error
+	GetRecentPlays(ctx context.Context, userID int64, limit int) ([]*model.PlayHistory, error)
+	GetPlayStats(ctx context.Context, userID int64, source string, limit int) ([]*model.PlayStat, error)
+	GetListenAggregates(ctx context.Context, source string, since time.Time) ([]*model.ListenAggregate, error)
+	GetActiveListeners(ctx context.Context, since time.Time) ([]int64, error)
+}
+
+// mysqlPlayHistoryRepository implements PlayHistoryRepository for MySQL.
+type mysqlPlayHistoryRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLPlayHistoryRepository creates a new instance of mysqlPlayHistoryRepository.
+func NewMySQLPlayHistoryRepository() PlayHistoryRepository {
+	return &mysqlPlayHistoryRepository{DB: db.DB}
+}
+
+// CreatePlay records the start of a listen.
+func (r *mysqlPlayHistoryRepository) CreatePlay(ctx context.Context, play *model.PlayHistory) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO play_history (user_id, source, source_id, title, artist, album, duration, listened, scrobbled, started_at)
+	           VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := r.DB.ExecContext(ctx, query, play.UserID, play.Source, play.SourceID, play.Title, play.Artist, play.Album,
+		play.Duration, play.Listened, play.Scrobbled, play.StartedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert play history for user ID %d: %w", play.UserID, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID for play history: %w", err)
+	}
+	return id, nil
+}
+
+// UpdatePlayProgress updates the listened time and scrobble flag of a listen.
+func (r *mysqlPlayHistoryRepository) UpdatePlayProgress(ctx context.Context, id int64, listened float64, scrobbled bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE play_history SET listened = ?, scrobbled = ? WHERE id = ?"
+	if _, err := r.DB.ExecContext(ctx, query, listened, scrobbled, id); err != nil {
+		return fmt.Errorf("failed to update play history ID %d: %w", id, err)
+	}
+	return nil
+}
+
+// GetRecentPlays retrieves the most recent listens of a user, newest first.
+func (r *mysqlPlayHistoryRepository) GetRecentPlays(ctx context.Context, userID int64, limit int) ([]*model.PlayHistory, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, source, source_id, title, artist, album, duration, listened, scrobbled, started_at, updated_at
+	           FROM play_history WHERE user_id = ? ORDER BY started_at DESC, id DESC LIMIT ?`
+	rows, err := r.DB.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query play history for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	plays := make([]*model.PlayHistory, 0)
+	for rows.Next() {
+		p := &model.PlayHistory{}
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Source, &p.SourceID, &p.Title, &p.Artist, &p.Album, &p.Duration, &p.Listened, &p.Scrobbled, &p.StartedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan play history in GetRecentPlays: %w", err)
+		}
+		plays = append(plays, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetRecentPlays: %w", err)
+	}
+
+	return plays, nil
+}
+
+// GetPlayStats aggregates a user's listens per song, most completed listens first.
+// An empty source includes all sources.
+func (r *mysqlPlayHistoryRepository) GetPlayStats(ctx context.Context, userID int64, source string, limit int) ([]*model.PlayStat, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT source, source_id, MAX(title), MAX(artist), COUNT(*), SUM(scrobbled), MAX(started_at)
+	           FROM play_history WHERE user_id = ?`
+	args := []interface{}{userID}
+	if source != "" {
+		query += " AND source = ?"
+		args = append(args, source)
+	}
+	query += " GROUP BY source, source_id ORDER BY SUM(scrobbled) DESC, COUNT(*) DESC, MAX(started_at) DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query play stats for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	stats := make([]*model.PlayStat, 0)
+	for rows.Next() {
+		s := &model.PlayStat{}
+		if err := rows.Scan(&s.Source, &s.SourceID, &s.Title, &s.Artist, &s.Plays, &s.Completed, &s.LastPlayedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan play stats in GetPlayStats: %w", err)
+		}
+		stats = append(stats, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetPlayStats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetListenAggregates counts every user's completed listens per song of a source since the given time.
+func (r *mysqlPlayHistoryRepository) GetListenAggregates(ctx context.Context, source string, since time.Time) ([]*model.ListenAggregate, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT user_id, source_id, MAX(title), MAX(artist), COUNT(*)
+	           FROM play_history WHERE source = ? AND scrobbled = 1 AND started_at >= ?
+	           GROUP BY user_id, source_id`
+	rows, err := r.DB.QueryContext(ctx, query, source, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query listen aggregates: %w", err)
+	}
+	defer rows.Close()
+
+	aggregates := make([]*model.ListenAggregate, 0)
+	for rows.Next() {
+		a := &model.ListenAggregate{}
+		if err := rows.Scan(&a.UserID, &a.SourceID, &a.Title, &a.Artist, &a.Plays); err != nil {
+			return nil, fmt.Errorf("failed to scan listen aggregates: %w", err)
+		}
+		aggregates = append(aggregates, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetListenAggregates: %w", err)
+	}
+
+	return aggregates, nil
+}
+
+// GetActiveListeners returns the IDs of users who started at least one listen since the given time.
+func (r *mysqlPlayHistoryRepository) GetActiveListeners(ctx context.Context, since time.Time) ([]int64, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, "SELECT DISTINCT user_id FROM play_history WHERE started_at >= ?", since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active listeners: %w", err)
+	}
+	defer rows.Close()
+
+	userIDs := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan active listeners: %w", err)
+		}
+		userIDs = append(userIDs, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetActiveListeners: %w", err)
+	}
+
+	return userIDs, nil
+}
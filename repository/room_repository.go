@@ -40,6 +40,14 @@ type RoomRepository interface {
 
 	// 用户房间
 	GetUserRooms(ctx context.Context, userID int64) ([]*model.UserRoomInfo, error)
+
+	// 房间总结
+	HasParticipated(ctx context.Context, roomID string, userID int64) (bool, error)
+	CountParticipants(ctx context.Context, roomID string) (int64, error)
+	CountChatMessages(ctx context.Context, roomID string) (int64, error)
+	GetTopChatter(ctx context.Context, roomID string) (*model.RoomChatter, error)
+	SaveSummary(ctx context.Context, summary *model.RoomSummary) error
+	GetSummary(ctx context.Context, roomID string) (*model.RoomSummary, error)
 }
 
 // gormRoomRepository GORM 实现
@@ -338,3 +346,71 @@ func (r *gormRoomRepository) GetUserRooms(ctx context.Context, userID int64) ([]
 
 	return rooms, nil
 }
+
+// ========== 房间总结 ==========
+
+// HasParticipated 检查用户是否加入过房间（包括已离开的成员）
+func (r *gormRoomRepository) HasParticipated(ctx context.Context, roomID string, userID int64) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.RoomMember{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CountParticipants 统计加入过房间的人数
+func (r *gormRoomRepository) CountParticipants(ctx context.Context, roomID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.RoomMember{}).
+		Where("room_id = ?", roomID).
+		Distinct("user_id").
+		Count(&count).Error
+	return count, err
+}
+
+// CountChatMessages 统计房间内的聊天消息数（不含系统消息和点歌消息）
+func (r *gormRoomRepository) CountChatMessages(ctx context.Context, roomID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.RoomMessage{}).
+		Where("room_id = ? AND message_type = ?", roomID, model.RoomMsgTypeText).
+		Count(&count).Error
+	return count, err
+}
+
+// GetTopChatter 获取房间内发送聊天消息最多的用户，没有聊天消息时返回 nil
+func (r *gormRoomRepository) GetTopChatter(ctx context.Context, roomID string) (*model.RoomChatter, error) {
+	var chatters []*model.RoomChatter
+	err := r.db.WithContext(ctx).
+		Table("room_messages").
+		Select("room_messages.user_id, COALESCE(users.username, '') as username, COUNT(*) as messages").
+		Joins("LEFT JOIN users ON room_messages.user_id = users.id").
+		Where("room_messages.room_id = ? AND room_messages.message_type = ?", roomID, model.RoomMsgTypeText).
+		Group("room_messages.user_id, users.username").
+		Order("messages DESC, MIN(room_messages.id) ASC").
+		Limit(1).
+		Scan(&chatters).Error
+	if err != nil || len(chatters) == 0 {
+		return nil, err
+	}
+	return chatters[0], nil
+}
+
+// SaveSummary 保存房间总结，同一房间重复保存时覆盖
+func (r *gormRoomRepository) SaveSummary(ctx context.Context, summary *model.RoomSummary) error {
+	return r.db.WithContext(ctx).Save(summary).Error
+}
+
+// GetSummary 获取房间总结，不存在时返回 nil
+func (r *gormRoomRepository) GetSummary(ctx context.Context, roomID string) (*model.RoomSummary, error) {
+	var summary model.RoomSummary
+	err := r.db.WithContext(ctx).
+		Where("room_id = ?", roomID).
+		First(&summary).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &summary, nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// ScrobbleAccountRepository defines the interface for Last.fm / ListenBrainz account operations.
+type ScrobbleAccountRepository interface {
+	GetAccountsByUserID(ctx context.Context, userID int64) ([]*model.ScrobbleAccount, error)
+	GetAccount(ctx context.Context, userID int64, service string) (*model.ScrobbleAccount, error)
+	SaveAccount(ctx context.Context, account *model.ScrobbleAccount) error
+	DeleteAccount(ctx context.Context, userID int64, service string) (bool, error)
+}
+
+// mysqlScrobbleAccountRepository implements ScrobbleAccountRepository for MySQL.
+type mysqlScrobbleAccountRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLScrobbleAccountRepository creates a new instance of mysqlScrobbleAccountRepository.
+func NewMySQLScrobbleAccountRepository() ScrobbleAccountRepository {
+	return &mysqlScrobbleAccountRepository{DB: db.DB}
+}
+
+const scrobbleAccountColumns = "user_id, service, username, session_key, api_key, api_secret, created_at, updated_at"
+
+func scanScrobbleAccount(row interface{ Scan(...interface{}) error }) (*model.ScrobbleAccount, error) {
+	a := &model.ScrobbleAccount{}
+	err := row.Scan(&a.UserID, &a.Service, &a.Username, &a.SessionKey, &a.APIKey, &a.APISecret, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+// GetAccountsByUserID retrieves all scrobble accounts connected by a user.
+func (r *mysqlScrobbleAccountRepository) GetAccountsByUserID(ctx context.Context, userID int64) ([]*model.ScrobbleAccount, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT " + scrobbleAccountColumns + " FROM scrobble_accounts WHERE user_id = ? ORDER BY service"
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scrobble accounts for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	accounts := make([]*model.ScrobbleAccount, 0)
+	for rows.Next() {
+		a, err := scanScrobbleAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan scrobble account in GetAccountsByUserID: %w", err)
+		}
+		accounts = append(accounts, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetAccountsByUserID: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// GetAccount retrieves the account a user connected for a service, or nil if none.
+func (r *mysqlScrobbleAccountRepository) GetAccount(ctx context.Context, userID int64, service string) (*model.ScrobbleAccount, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT " + scrobbleAccountColumns + " FROM scrobble_accounts WHERE user_id = ? AND service = ?"
+	a, err := scanScrobbleAccount(r.DB.QueryRowContext(ctx, query, userID, service))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s account for user ID %d: %w", service, userID, err)
+	}
+	return a, nil
+}
+
+// SaveAccount inserts or replaces the account a user connected for a service.
+func (r *mysqlScrobbleAccountRepository) SaveAccount(ctx context.Context, account *model.ScrobbleAccount) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO scrobble_accounts (user_id, service, username, session_key, api_key, api_secret)
+	           VALUES (?, ?, ?, ?, ?, ?)
+	           ON DUPLICATE KEY UPDATE username = VALUES(username), session_key = VALUES(session_key), api_key = VALUES(api_key), api_secret = VALUES(api_secret)`
+	if _, err := r.DB.ExecContext(ctx, query, account.UserID, account.Service, account.Username, account.SessionKey, account.APIKey, account.APISecret); err != nil {
+		return fmt.Errorf("failed to save %s account for user ID %d: %w", account.Service, account.UserID, err)
+	}
+	return nil
+}
+
+// DeleteAccount disconnects a service, reporting whether an account was connected.
+func (r *mysqlScrobbleAccountRepository) DeleteAccount(ctx context.Context, userID int64, service string) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := r.DB.ExecContext(ctx, "DELETE FROM scrobble_accounts WHERE user_id = ? AND service = ?", userID, service)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete %s account for user ID %d: %w", service, userID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// SigningKeyRepository defines the interface for persisted JWT signing keys.
+type SigningKeyRepository interface {
+	ListSigningKeys(ctx context.Context) ([]*model.JWTSigningKey, error)
+	CreateSigningKey(ctx context.Context, key *model.JWTSigningKey) error
+	RetireSigningKey(ctx context.Context, id string, retiredAt time.Time) error
+	DeleteSigningKey(ctx context.Context, id string) error
+}
+
+// mysqlSigningKeyRepository implements SigningKeyRepository for MySQL.
+type mysqlSigningKeyRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLSigningKeyRepository creates a new instance of mysqlSigningKeyRepository.
+func NewMySQLSigningKeyRepository() SigningKeyRepository {
+	return &mysqlSigningKeyRepository{DB: db.DB}
+}
+
+// ListSigningKeys returns all stored signing keys, oldest activation first.
+func (r *mysqlSigningKeyRepository) ListSigningKeys(ctx context.Context) ([]*model.JWTSigningKey, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT kid, algorithm, private_key, activates_at, retired_at, created_at
+	           FROM jwt_signing_keys ORDER BY activates_at, kid`
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]*model.JWTSigningKey, 0)
+	for rows.Next() {
+		key := &model.JWTSigningKey{}
+		var retiredAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Algorithm, &key.PrivateKey, &key.ActivatesAt, &retiredAt, &key.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+		if retiredAt.Valid {
+			key.RetiredAt = &retiredAt.Time
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+// CreateSigningKey stores a newly generated signing key.
+func (r *mysqlSigningKeyRepository) CreateSigningKey(ctx context.Context, key *model.JWTSigningKey) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO jwt_signing_keys (kid, algorithm, private_key, activates_at) VALUES (?, ?, ?, ?)`
+	if _, err := r.DB.ExecContext(ctx, query, key.ID, key.Algorithm, key.PrivateKey, key.ActivatesAt); err != nil {
+		return fmt.Errorf("failed to create signing key %s: %w", key.ID, err)
+	}
+	return nil
+}
+
+// RetireSigningKey marks a key as no longer used for signing. Keys that are already retired are left unchanged.
+func (r *mysqlSigningKeyRepository) RetireSigningKey(ctx context.Context, id string, retiredAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE jwt_signing_keys SET retired_at = ? WHERE kid = ? AND retired_at IS NULL`
+	if _, err := r.DB.ExecContext(ctx, query, retiredAt, id); err != nil {
+		return fmt.Errorf("failed to retire signing key %s: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteSigningKey removes a signing key once no valid token can reference it.
+func (r *mysqlSigningKeyRepository) DeleteSigningKey(ctx context.Context, id string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.DB.ExecContext(ctx, `DELETE FROM jwt_signing_keys WHERE kid = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete signing key %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// SocialRepository defines the interface for follow relationship and activity operations.
+type SocialRepository interface {
+	Follow(ctx context.Context, followerID, followeeID int64) (bool, error)
+	Unfollow(ctx context.Context, followerID, followeeID int64) (bool, error)
+	IsFollowing(ctx context.Context, followerID, followeeID int64) (bool, error)
+	GetFollowers(ctx context.Context, userID int64, limit, offset int) ([]*model.FollowUser, error)
+	GetFollowing(ctx context.Context, userID int64, limit, offset int) ([]*model.FollowUser, error)
+	GetFollowStats(ctx context.Context, userID int64) (*model.FollowStats, error)
+	CreateActivity(ctx context.Context, activity *model.Activity) error
+	DeleteActivities(ctx context.Context, activityType, objectID string) error
+	GetFeed(ctx context.Context, userID, beforeID int64, limit int) ([]*model.Activity, error)
+}
+
+// mysqlSocialRepository implements SocialRepository for MySQL.
+type mysqlSocialRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLSocialRepository creates a new instance of mysqlSocialRepository.
+func NewMySQLSocialRepository() SocialRepository {
+	return &mysqlSocialRepository{DB: db.DB}
+}
+
+// Follow makes followerID follow followeeID and reports whether the relationship is new;
+// following someone twice is a no-op.
+func (r *mysqlSocialRepository) Follow(ctx context.Context, followerID, followeeID int64) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT IGNORE INTO user_follows (follower_id, followee_id) VALUES (?, ?)`
+	res, err := r.DB.ExecContext(ctx, query, followerID, followeeID)
+	if err != nil {
+		return false, fmt.Errorf("failed to follow user ID %d: %w", followeeID, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows for Follow: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// Unfollow removes a follow relationship and reports whether it existed.
+func (r *mysqlSocialRepository) Unfollow(ctx context.Context, followerID, followeeID int64) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM user_follows WHERE follower_id = ? AND followee_id = ?`, followerID, followeeID)
+	if err != nil {
+		return false, fmt.Errorf("failed to unfollow user ID %d: %w", followeeID, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows for Unfollow: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// IsFollowing reports whether followerID follows followeeID.
+func (r *mysqlSocialRepository) IsFollowing(ctx context.Context, followerID, followeeID int64) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM user_follows WHERE follower_id = ? AND followee_id = ?)`
+	if err := r.DB.QueryRowContext(ctx, query, followerID, followeeID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check follow relationship: %w", err)
+	}
+	return exists, nil
+}
+
+// GetFollowers retrieves the users following userID, most recent first.
+func (r *mysqlSocialRepository) GetFollowers(ctx context.Context, userID int64, limit, offset int) ([]*model.FollowUser, error) {
+	query := `SELECT u.id, u.username, f.created_at
+	           FROM user_follows f
+	           JOIN users u ON u.id = f.follower_id
+	           WHERE f.followee_id = ?
+	           ORDER BY f.created_at DESC, u.id
+	           LIMIT ? OFFSET ?`
+	return r.queryFollowUsers(ctx, "GetFollowers", query, userID, limit, offset)
+}
+
+// GetFollowing retrieves the users followed by userID, most recent first.
+func (r *mysqlSocialRepository) GetFollowing(ctx context.Context, userID int64, limit, offset int) ([]*model.FollowUser, error) {
+	query := `SELECT u.id, u.username, f.created_at
+	           FROM user_follows f
+	           JOIN users u ON u.id = f.followee_id
+	           WHERE f.follower_id = ?
+	           ORDER BY f.created_at DESC, u.id
+	           LIMIT ? OFFSET ?`
+	return r.queryFollowUsers(ctx, "GetFollowing", query, userID, limit, offset)
+}
+
+func (r *mysqlSocialRepository) queryFollowUsers(ctx context.Context, name, query string, args ...interface{}) ([]*model.FollowUser, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users in %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	users := make([]*model.FollowUser, 0)
+	for rows.Next() {
+		u := &model.FollowUser{}
+		if err := rows.Scan(&u.UserID, &u.Username, &u.FollowedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user in %s: %w", name, err)
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in %s: %w", name, err)
+	}
+
+	return users, nil
+}
+
+// GetFollowStats counts the users followed by and following userID.
+func (r *mysqlSocialRepository) GetFollowStats(ctx context.Context, userID int64) (*model.FollowStats, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	stats := &model.FollowStats{}
+	query := `SELECT
+	           (SELECT COUNT(*) FROM user_follows WHERE follower_id = ?),
+	           (SELECT COUNT(*) FROM user_follows WHERE followee_id = ?)`
+	if err := r.DB.QueryRowContext(ctx, query, userID, userID).Scan(&stats.Following, &stats.Followers); err != nil {
+		return nil, fmt.Errorf("failed to get follow stats for user ID %d: %w", userID, err)
+	}
+	return stats, nil
+}
+
+// CreateActivity records an activity of a user.
+func (r *mysqlSocialRepository) CreateActivity(ctx context.Context, activity *model.Activity) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO user_activities (user_id, type, object_id, source, source_id, title, content)
+	           VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.DB.ExecContext(ctx, query, activity.UserID, activity.Type, activity.ObjectID,
+		activity.Source, activity.SourceID, activity.Title, activity.Content)
+	if err != nil {
+		return fmt.Errorf("failed to create activity: %w", err)
+	}
+	return nil
+}
+
+// DeleteActivities removes the activities about an object, e.g. when a comment is deleted.
+func (r *mysqlSocialRepository) DeleteActivities(ctx context.Context, activityType, objectID string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM user_activities WHERE type = ? AND object_id = ?`
+	if _, err := r.DB.ExecContext(ctx, query, activityType, objectID); err != nil {
+		return fmt.Errorf("failed to delete activities of %s %s: %w", activityType, objectID, err)
+	}
+	return nil
+}
+
+// GetFeed retrieves the activities of the users followed by userID with IDs below beforeID, newest first.
+// A beforeID of 0 starts from the newest activity.
+func (r *mysqlSocialRepository) GetFeed(ctx context.Context, userID, beforeID int64, limit int) ([]*model.Activity, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT a.id, a.user_id, u.username, a.type, a.object_id, a.source, a.source_id, a.title, COALESCE(a.content, ''), a.created_at
+	           FROM user_follows f
+	           JOIN user_activities a ON a.user_id = f.followee_id
+	           JOIN users u ON u.id = a.user_id
+	           WHERE f.follower_id = ? AND (? = 0 OR a.id < ?)
+	           ORDER BY a.id DESC
+	           LIMIT ?`
+	rows, err := r.DB.QueryContext(ctx, query, userID, beforeID, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feed for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	activities := make([]*model.Activity, 0)
+	for rows.Next() {
+		a := &model.Activity{}
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Username, &a.Type, &a.ObjectID, &a.Source, &a.SourceID,
+			&a.Title, &a.Content, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan activity in GetFeed: %w", err)
+		}
+		activities = append(activities, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetFeed: %w", err)
+	}
+
+	return activities, nil
+}
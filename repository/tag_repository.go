@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// TagRepository defines the interface for track tag operations.
+type TagRepository interface {
+	GetTagsByUserID(ctx context.Context, userID int64) ([]*model.Tag, error)
+	GetTagsByTrackIDs(ctx context.Context, trackIDs []int64) (map[int64][]string, error)
+	AddTagsToTrack(ctx context.Context, userID, trackID int64, names []string) error
+	RemoveTagFromTrack(ctx context.Context, userID, trackID int64, name string) (bool, error)
+	GetTrackIDsWithTags(ctx context.Context, userID int64, names []string) ([]int64, error)
+}
+
+// mysqlTagRepository implements TagRepository for MySQL.
+type mysqlTagRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLTagRepository creates a new instance of mysqlTagRepository.
+func NewMySQLTagRepository() TagRepository {
+	return &mysqlTagRepository{DB: db.DB}
+}
+
+// GetTagsByUserID retrieves all tags of a user with the number of active tracks carrying each tag.
+func (r *mysqlTagRepository) GetTagsByUserID(ctx context.Context, userID int64) ([]*model.Tag, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT tg.id, tg.user_id, tg.name, COUNT(t.id), tg.created_at
+	           FROM tags tg
+	           LEFT JOIN track_tags tt ON tt.tag_id = tg.id
+	           LEFT JOIN tracks t ON t.id = tt.track_id AND t.state = 1
+	           WHERE tg.user_id = ?
+	           GROUP BY tg.id, tg.user_id, tg.name, tg.created_at
+	           ORDER BY tg.name`
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	tags := make([]*model.Tag, 0)
+	for rows.Next() {
+		tag := &model.Tag{}
+		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.TrackCount, &tag.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tag in GetTagsByUserID: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTagsByUserID: %w", err)
+	}
+
+	return tags, nil
+}
+
+// GetTagsByTrackIDs retrieves the tag names of the given tracks, keyed by track ID.
+func (r *mysqlTagRepository) GetTagsByTrackIDs(ctx context.Context, trackIDs []int64) (map[int64][]string, error) {
+	result := make(map[int64][]string, len(trackIDs))
+	if len(trackIDs) == 0 {
+		return result, nil
+	}
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT tt.track_id, tg.name
+	           FROM track_tags tt
+	           JOIN tags tg ON tg.id = tt.tag_id
+	           WHERE tt.track_id IN (` + placeholders(len(trackIDs)) + `)
+	           ORDER BY tt.track_id, tg.name`
+	rows, err := r.DB.QueryContext(ctx, query, int64Args(trackIDs)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags by track IDs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trackID int64
+		var name string
+		if err := rows.Scan(&trackID, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan tag in GetTagsByTrackIDs: %w", err)
+		}
+		result[trackID] = append(result[trackID], name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTagsByTrackIDs: %w", err)
+	}
+
+	return result, nil
+}
+
+// AddTagsToTrack 为曲目添加标签，不存在的标签会自动创建，已有的关联保持不变
+func (r *mysqlTagRepository) AddTagsToTrack(ctx context.Context, userID, trackID int64, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for AddTagsToTrack: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, name := range names {
+		if _, err := tx.ExecContext(ctx, `INSERT IGNORE INTO tags (user_id, name) VALUES (?, ?)`, userID, name); err != nil {
+			return fmt.Errorf("failed to create tag %q: %w", name, err)
+		}
+		query := `INSERT IGNORE INTO track_tags (track_id, tag_id)
+		           SELECT ?, id FROM tags WHERE user_id = ? AND name = ?`
+		if _, err := tx.ExecContext(ctx, query, trackID, userID, name); err != nil {
+			return fmt.Errorf("failed to add tag %q to track ID %d: %w", name, trackID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit AddTagsToTrack: %w", err)
+	}
+	return nil
+}
+
+// RemoveTagFromTrack 移除曲目的标签，返回曲目原本是否带有该标签
+func (r *mysqlTagRepository) RemoveTagFromTrack(ctx context.Context, userID, trackID int64, name string) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE tt FROM track_tags tt
+	           JOIN tags tg ON tg.id = tt.tag_id
+	           WHERE tt.track_id = ? AND tg.user_id = ? AND tg.name = ?`
+	res, err := r.DB.ExecContext(ctx, query, trackID, userID, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove tag %q from track ID %d: %w", name, trackID, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows for RemoveTagFromTrack: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// GetTrackIDsWithTags retrieves the IDs of a user's tracks that carry all of the given tags.
+func (r *mysqlTagRepository) GetTrackIDsWithTags(ctx context.Context, userID int64, names []string) ([]int64, error) {
+	if len(names) == 0 {
+		return []int64{}, nil
+	}
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT tt.track_id
+	           FROM track_tags tt
+	           JOIN tags tg ON tg.id = tt.tag_id
+	           WHERE tg.user_id = ? AND tg.name IN (` + placeholders(len(names)) + `)
+	           GROUP BY tt.track_id
+	           HAVING COUNT(DISTINCT tg.id) = ?`
+	args := make([]interface{}, 0, len(names)+2)
+	args = append(args, userID)
+	for _, name := range names {
+		args = append(args, name)
+	}
+	args = append(args, len(names))
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracks by tags for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan track ID in GetTrackIDsWithTags: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTrackIDsWithTags: %w", err)
+	}
+
+	return ids, nil
+}
+
+// placeholders 返回 n 个以逗号分隔的 SQL 占位符
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// int64Args 将 ID 列表转换为查询参数
+func int64Args(ids []int64) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"Bt1QFM/db"
@@ -12,19 +15,41 @@ import (
 
 // TrackRepository defines the interface for track data operations.
 type TrackRepository interface {
-	CreateTrack(track *model.Track) (int64, error)
-	GetTrackByID(id int64) (*model.Track, error)
-	GetAllTracksByUserID(userID int64) ([]*model.Track, error)
-	UpdateTrackHLSPath(trackID int64, hlsPath string, duration float32) error
-	UpdateTrackCoverArtPath(trackID int64, coverPath string) error
-	GetTrackByUserIDAndFilePath(userID int64, filePath string) (*model.Track, error)
-	BeginTx() (*sql.Tx, error)
+	CreateTrack(ctx context.Context, track *model.Track) (int64, error)
+	GetTrackByID(ctx context.Context, id int64) (*model.Track, error)
+	GetTracksByIDs(ctx context.Context, ids []int64) ([]*model.Track, error)
+	GetAllTracksByUserID(ctx context.Context, userID int64) ([]*model.Track, error)
+	ListTracks(ctx context.Context, userID int64, filter model.TrackFilter) ([]*model.Track, int64, error)
+	UpdateTrackHLSPath(ctx context.Context, trackID int64, hlsPath string, duration float32) error
+	UpdateTrackCoverArtPath(ctx context.Context, trackID int64, coverPath string) error
+	GetTrackByUserIDAndFilePath(ctx context.Context, userID int64, filePath string) (*model.Track, error)
+	BeginTx(ctx context.Context) (*sql.Tx, error)
 	RollbackTx(tx *sql.Tx)
 	CommitTx(tx *sql.Tx) error
-	CreateTrackWithTx(tx *sql.Tx, track *model.Track) (int64, error)
-	DeleteTrackWithTx(tx *sql.Tx, trackID int64) error
-	UpdateTrackStatus(trackID int64, status string) error
-	UpdateTrackState(trackID int64, state int8) error
+	CreateTrackWithTx(ctx context.Context, tx *sql.Tx, track *model.Track) (int64, error)
+	DeleteTrackWithTx(ctx context.Context, tx *sql.Tx, trackID int64) error
+	UpdateTrackMetadataWithTx(ctx context.Context, tx *sql.Tx, trackID int64, update *model.TrackMetadataUpdate) error
+	UpdateTrackFileWithTx(ctx context.Context, tx *sql.Tx, track *model.Track) error
+	UpdateTrackStatus(ctx context.Context, trackID int64, status string) error
+	UpdateTrackState(ctx context.Context, trackID int64, state int8) error
+	UpdateTrackLicense(ctx context.Context, trackID int64, license string) error
+	GetFailedTracksSince(ctx context.Context, userID int64, since time.Time) ([]*model.Track, error)
+	GetTracksWithoutCover(ctx context.Context, attemptedBefore time.Time, limit int) ([]*model.Track, error)
+	MarkTrackCoverAttempted(ctx context.Context, trackID int64) error
+	GetTracksByContentHash(ctx context.Context, contentHash string) ([]*model.Track, error)
+	ReserveContentStorage(ctx context.Context, contentHash string, ttl time.Duration) error
+	LockContentStorageWithTx(ctx context.Context, tx *sql.Tx, contentHash string) (bool, error)
+	DeleteContentStorageRefWithTx(ctx context.Context, tx *sql.Tx, contentHash string) error
+	GetTracksByHLSPlaylistPath(ctx context.Context, playlistPath string) ([]*model.Track, error)
+	GetTrackStorageRefs(ctx context.Context) ([]*model.Track, error)
+	GetLiveTrackStorageStates(ctx context.Context) ([]*model.Track, error)
+	GetDeletedTracksByUserID(ctx context.Context, userID int64) ([]*model.Track, error)
+	GetTracksDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*model.Track, error)
+	RestoreTrack(ctx context.Context, userID, trackID int64) (bool, error)
+	PurgeTrack(ctx context.Context, trackID int64) error
+	IsCoverArtReferenced(ctx context.Context, coverPath string) (bool, error)
+	GetUserUploadedBytes(ctx context.Context, userID int64) (int64, error)
+	GetTrackStats(ctx context.Context) (*model.TrackStats, error)
 }
 
 // mysqlTrackRepository implements TrackRepository for MySQL.
@@ -38,10 +63,13 @@ func NewMySQLTrackRepository() TrackRepository {
 }
 
 // CreateTrack adds a new track to the database.
-func (r *mysqlTrackRepository) CreateTrack(track *model.Track) (int64, error) {
-	query := `INSERT INTO tracks (title, artist, album, cover_art_path, hls_playlist_path, duration, user_id, source, created_at, updated_at)
-	           VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	stmt, err := r.DB.Prepare(query)
+func (r *mysqlTrackRepository) CreateTrack(ctx context.Context, track *model.Track) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO tracks (title, artist, album, file_path, cover_art_path, hls_playlist_path, duration, user_id, source, provenance, license, content_hash, file_size, created_at, updated_at)
+	           VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	stmt, err := r.DB.PrepareContext(ctx, query)
 	if err != nil {
 		return 0, fmt.Errorf("failed to prepare statement for CreateTrack: %w", err)
 	}
@@ -52,7 +80,10 @@ func (r *mysqlTrackRepository) CreateTrack(track *model.Track) (int64, error) {
 	if track.Source == "" {
 		track.Source = "library"
 	}
-	res, err := stmt.Exec(track.Title, track.Artist, track.Album, track.CoverArtPath, track.HLSPlaylistPath, track.Duration, track.UserID, track.Source, now, now)
+	if track.Provenance == "" {
+		track.Provenance = model.ProvenanceUpload
+	}
+	res, err := stmt.ExecContext(ctx, track.Title, track.Artist, track.Album, track.FilePath, track.CoverArtPath, track.HLSPlaylistPath, track.Duration, track.UserID, track.Source, track.Provenance, track.License, track.ContentHash, track.FileSize, now, now)
 	if err != nil {
 		return 0, fmt.Errorf("failed to execute CreateTrack: %w", err)
 	}
@@ -66,13 +97,16 @@ func (r *mysqlTrackRepository) CreateTrack(track *model.Track) (int64, error) {
 }
 
 // GetTrackByID retrieves a track by its ID.
-func (r *mysqlTrackRepository) GetTrackByID(id int64) (*model.Track, error) {
-	query := `SELECT id, user_id, title, artist, album, cover_art_path, hls_playlist_path, duration, state, source, created_at, updated_at
+func (r *mysqlTrackRepository) GetTrackByID(ctx context.Context, id int64) (*model.Track, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, title, artist, album, COALESCE(genre, ''), COALESCE(file_path, ''), cover_art_path, hls_playlist_path, duration, COALESCE(status, ''), state, source, COALESCE(provenance, 'upload'), COALESCE(license, ''), COALESCE(content_hash, ''), play_count, release_year, created_at, updated_at
 	           FROM tracks WHERE id = ?`
-	row := r.DB.QueryRow(query, id)
+	row := r.DB.QueryRowContext(ctx, query, id)
 
 	track := &model.Track{}
-	err := row.Scan(&track.ID, &track.UserID, &track.Title, &track.Artist, &track.Album, &track.CoverArtPath, &track.HLSPlaylistPath, &track.Duration, &track.State, &track.Source, &track.CreatedAt, &track.UpdatedAt)
+	err := row.Scan(&track.ID, &track.UserID, &track.Title, &track.Artist, &track.Album, &track.Genre, &track.FilePath, &track.CoverArtPath, &track.HLSPlaylistPath, &track.Duration, &track.Status, &track.State, &track.Source, &track.Provenance, &track.License, &track.ContentHash, &track.PlayCount, &track.Year, &track.CreatedAt, &track.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Track not found
@@ -82,11 +116,47 @@ func (r *mysqlTrackRepository) GetTrackByID(id int64) (*model.Track, error) {
 	return track, nil
 }
 
+// GetTracksByIDs retrieves the tracks with the given IDs; missing IDs are simply absent from the result.
+func (r *mysqlTrackRepository) GetTracksByIDs(ctx context.Context, ids []int64) ([]*model.Track, error) {
+	if len(ids) == 0 {
+		return []*model.Track{}, nil
+	}
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, title, artist, album, COALESCE(genre, ''), COALESCE(file_path, ''), cover_art_path, hls_playlist_path, duration, state, source, COALESCE(provenance, 'upload'), COALESCE(license, ''), COALESCE(content_hash, ''), play_count, release_year, created_at, updated_at
+	           FROM tracks WHERE id IN (` + placeholders(len(ids)) + `)`
+	rows, err := r.DB.QueryContext(ctx, query, int64Args(ids)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracks by IDs: %w", err)
+	}
+	defer rows.Close()
+
+	tracks := make([]*model.Track, 0, len(ids))
+	for rows.Next() {
+		track := &model.Track{}
+		err := rows.Scan(&track.ID, &track.UserID, &track.Title, &track.Artist, &track.Album, &track.Genre, &track.FilePath, &track.CoverArtPath, &track.HLSPlaylistPath, &track.Duration, &track.State, &track.Source, &track.Provenance, &track.License, &track.ContentHash, &track.PlayCount, &track.Year, &track.CreatedAt, &track.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan track in GetTracksByIDs: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTracksByIDs: %w", err)
+	}
+
+	return tracks, nil
+}
+
 // GetAllTracks retrieves all active tracks from the database (state=1).
-func (r *mysqlTrackRepository) GetAllTracksByUserID(userID int64) ([]*model.Track, error) {
-	query := `SELECT id, user_id, title, artist, album, cover_art_path, hls_playlist_path, duration, state, source, created_at, updated_at
+func (r *mysqlTrackRepository) GetAllTracksByUserID(ctx context.Context, userID int64) ([]*model.Track, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, title, artist, album, COALESCE(genre, ''), COALESCE(file_path, ''), cover_art_path, hls_playlist_path, duration, state, source, COALESCE(provenance, 'upload'), COALESCE(license, ''), COALESCE(content_hash, ''), play_count, release_year, created_at, updated_at
 	           FROM tracks WHERE user_id = ? AND state = 1 ORDER BY created_at DESC`
-	rows, err := r.DB.Query(query, userID)
+	rows, err := r.DB.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tracks for user ID %d: %w", userID, err)
 	}
@@ -95,7 +165,7 @@ func (r *mysqlTrackRepository) GetAllTracksByUserID(userID int64) ([]*model.Trac
 	tracks := make([]*model.Track, 0)
 	for rows.Next() {
 		track := &model.Track{}
-		err := rows.Scan(&track.ID, &track.UserID, &track.Title, &track.Artist, &track.Album, &track.CoverArtPath, &track.HLSPlaylistPath, &track.Duration, &track.State, &track.Source, &track.CreatedAt, &track.UpdatedAt)
+		err := rows.Scan(&track.ID, &track.UserID, &track.Title, &track.Artist, &track.Album, &track.Genre, &track.FilePath, &track.CoverArtPath, &track.HLSPlaylistPath, &track.Duration, &track.State, &track.Source, &track.Provenance, &track.License, &track.ContentHash, &track.PlayCount, &track.Year, &track.CreatedAt, &track.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan track in GetAllTracksByUserID: %w", err)
 		}
@@ -109,16 +179,129 @@ func (r *mysqlTrackRepository) GetAllTracksByUserID(userID int64) ([]*model.Trac
 	return tracks, nil
 }
 
+// trackSortColumns 曲库列表排序字段对应的列
+var trackSortColumns = map[string]string{
+	model.TrackSortCreated:  "created_at",
+	model.TrackSortUpdated:  "updated_at",
+	model.TrackSortTitle:    "title",
+	model.TrackSortArtist:   "artist",
+	model.TrackSortAlbum:    "album",
+	model.TrackSortDuration: "duration",
+	model.TrackSortPlays:    "play_count",
+}
+
+// likePattern 转义 LIKE 的通配符，返回包含关键词的匹配模式
+func likePattern(keyword string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(keyword) + "%"
+}
+
+// ListTracks returns a user's tracks matching the filter, sorted and paginated in SQL, along with the total number of matches.
+func (r *mysqlTrackRepository) ListTracks(ctx context.Context, userID int64, filter model.TrackFilter) ([]*model.Track, int64, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	where := []string{"user_id = ?", "state = 1"}
+	args := []interface{}{userID}
+	if filter.Artist != "" {
+		where = append(where, "artist = ?")
+		args = append(args, filter.Artist)
+	}
+	if filter.Album != "" {
+		where = append(where, "album = ?")
+		args = append(args, filter.Album)
+	}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if len(filter.Sources) > 0 {
+		where = append(where, "COALESCE(source, '') IN ("+placeholders(len(filter.Sources))+")")
+		for _, source := range filter.Sources {
+			args = append(args, source)
+		}
+	}
+	if filter.Keyword != "" {
+		pattern := likePattern(filter.Keyword)
+		where = append(where, "(title LIKE ? OR artist LIKE ? OR album LIKE ?)")
+		args = append(args, pattern, pattern, pattern)
+	}
+	if len(filter.Tags) > 0 {
+		where = append(where, `id IN (SELECT tt.track_id FROM track_tags tt
+		           JOIN tags tg ON tg.id = tt.tag_id
+		           WHERE tg.user_id = ? AND tg.name IN (`+placeholders(len(filter.Tags))+`)
+		           GROUP BY tt.track_id HAVING COUNT(DISTINCT tg.id) = ?)`)
+		args = append(args, userID)
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
+		}
+		args = append(args, len(filter.Tags))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.CreatedBefore)
+	}
+	conditions := strings.Join(where, " AND ")
+
+	var total int64
+	if err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM tracks WHERE `+conditions, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count tracks for user ID %d: %w", userID, err)
+	}
+
+	column, ok := trackSortColumns[filter.Sort]
+	if !ok {
+		column = "created_at"
+	}
+	direction := "DESC"
+	if filter.Ascending {
+		direction = "ASC"
+	}
+	query := `SELECT id, user_id, title, artist, album, COALESCE(genre, ''), COALESCE(file_path, ''), cover_art_path, hls_playlist_path, duration, COALESCE(status, ''), state, source, COALESCE(provenance, 'upload'), COALESCE(license, ''), COALESCE(content_hash, ''), play_count, release_year, created_at, updated_at
+	           FROM tracks WHERE ` + conditions + `
+	           ORDER BY ` + column + ` ` + direction + `, id ` + direction
+	if filter.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	}
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list tracks for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	tracks := make([]*model.Track, 0)
+	for rows.Next() {
+		track := &model.Track{}
+		err := rows.Scan(&track.ID, &track.UserID, &track.Title, &track.Artist, &track.Album, &track.Genre, &track.FilePath, &track.CoverArtPath, &track.HLSPlaylistPath, &track.Duration, &track.Status, &track.State, &track.Source, &track.Provenance, &track.License, &track.ContentHash, &track.PlayCount, &track.Year, &track.CreatedAt, &track.UpdatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan track in ListTracks: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error during rows iteration in ListTracks: %w", err)
+	}
+
+	return tracks, total, nil
+}
+
 // UpdateTrackHLSPath updates the HLS playlist path and duration for a given track ID.
-func (r *mysqlTrackRepository) UpdateTrackHLSPath(trackID int64, hlsPath string, duration float32) error {
+func (r *mysqlTrackRepository) UpdateTrackHLSPath(ctx context.Context, trackID int64, hlsPath string, duration float32) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	query := `UPDATE tracks SET hls_playlist_path = ?, duration = ?, updated_at = ? WHERE id = ?`
-	stmt, err := r.DB.Prepare(query)
+	stmt, err := r.DB.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement for UpdateTrackHLSPath: %w", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(hlsPath, duration, time.Now(), trackID)
+	_, err = stmt.ExecContext(ctx, hlsPath, duration, time.Now(), trackID)
 	if err != nil {
 		return fmt.Errorf("failed to execute UpdateTrackHLSPath for track ID %d: %w", trackID, err)
 	}
@@ -127,15 +310,18 @@ func (r *mysqlTrackRepository) UpdateTrackHLSPath(trackID int64, hlsPath string,
 }
 
 // UpdateTrackCoverArtPath updates the cover art path for a given track ID.
-func (r *mysqlTrackRepository) UpdateTrackCoverArtPath(trackID int64, coverPath string) error {
+func (r *mysqlTrackRepository) UpdateTrackCoverArtPath(ctx context.Context, trackID int64, coverPath string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	query := `UPDATE tracks SET cover_art_path = ?, updated_at = ? WHERE id = ?`
-	stmt, err := r.DB.Prepare(query)
+	stmt, err := r.DB.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement for UpdateTrackCoverArtPath: %w", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(coverPath, time.Now(), trackID)
+	_, err = stmt.ExecContext(ctx, coverPath, time.Now(), trackID)
 	if err != nil {
 		return fmt.Errorf("failed to execute UpdateTrackCoverArtPath for track ID %d: %w", trackID, err)
 	}
@@ -144,15 +330,15 @@ func (r *mysqlTrackRepository) UpdateTrackCoverArtPath(trackID int64, coverPath
 }
 
 // GetTrackByFilePath retrieves a track by its file path to check for existence.
-func (r *mysqlTrackRepository) GetTrackByUserIDAndFilePath(userID int64, filePath string) (*model.Track, error) {
+func (r *mysqlTrackRepository) GetTrackByUserIDAndFilePath(ctx context.Context, userID int64, filePath string) (*model.Track, error) {
 	// 由于file_path字段已被移除，这个方法需要重新实现
 	// 暂时返回nil表示未找到
 	return nil, nil
 }
 
 // BeginTx 开始一个新的事务
-func (r *mysqlTrackRepository) BeginTx() (*sql.Tx, error) {
-	return r.DB.Begin()
+func (r *mysqlTrackRepository) BeginTx(ctx context.Context) (*sql.Tx, error) {
+	return r.DB.BeginTx(ctx, nil)
 }
 
 // RollbackTx 回滚事务
@@ -168,10 +354,13 @@ func (r *mysqlTrackRepository) CommitTx(tx *sql.Tx) error {
 }
 
 // CreateTrackWithTx 在事务中创建新曲目
-func (r *mysqlTrackRepository) CreateTrackWithTx(tx *sql.Tx, track *model.Track) (int64, error) {
-	query := `INSERT INTO tracks (title, artist, album, cover_art_path, hls_playlist_path, duration, user_id, source, created_at, updated_at)
-	           VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	stmt, err := tx.Prepare(query)
+func (r *mysqlTrackRepository) CreateTrackWithTx(ctx context.Context, tx *sql.Tx, track *model.Track) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO tracks (title, artist, album, file_path, cover_art_path, hls_playlist_path, duration, user_id, source, provenance, license, content_hash, file_size, created_at, updated_at)
+	           VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return 0, fmt.Errorf("failed to prepare statement for CreateTrackWithTx: %w", err)
 	}
@@ -182,7 +371,10 @@ func (r *mysqlTrackRepository) CreateTrackWithTx(tx *sql.Tx, track *model.Track)
 	if track.Source == "" {
 		track.Source = "library"
 	}
-	res, err := stmt.Exec(track.Title, track.Artist, track.Album, track.CoverArtPath, track.HLSPlaylistPath, track.Duration, track.UserID, track.Source, now, now)
+	if track.Provenance == "" {
+		track.Provenance = model.ProvenanceUpload
+	}
+	res, err := stmt.ExecContext(ctx, track.Title, track.Artist, track.Album, track.FilePath, track.CoverArtPath, track.HLSPlaylistPath, track.Duration, track.UserID, track.Source, track.Provenance, track.License, track.ContentHash, track.FileSize, now, now)
 	if err != nil {
 		return 0, fmt.Errorf("failed to execute CreateTrackWithTx: %w", err)
 	}
@@ -196,31 +388,96 @@ func (r *mysqlTrackRepository) CreateTrackWithTx(tx *sql.Tx, track *model.Track)
 }
 
 // DeleteTrackWithTx 在事务中删除曲目
-func (r *mysqlTrackRepository) DeleteTrackWithTx(tx *sql.Tx, trackID int64) error {
+func (r *mysqlTrackRepository) DeleteTrackWithTx(ctx context.Context, tx *sql.Tx, trackID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	query := `DELETE FROM tracks WHERE id = ?`
-	stmt, err := tx.Prepare(query)
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement for DeleteTrackWithTx: %w", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(trackID)
+	_, err = stmt.ExecContext(ctx, trackID)
 	if err != nil {
 		return fmt.Errorf("failed to execute DeleteTrackWithTx: %w", err)
 	}
 	return nil
 }
 
+// UpdateTrackFileWithTx 在事务中把曲目切换到新的源音频和流，元数据和收藏、播放列表等引用保持不变
+func (r *mysqlTrackRepository) UpdateTrackFileWithTx(ctx context.Context, tx *sql.Tx, track *model.Track) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE tracks SET file_path = ?, content_hash = ?, file_size = ?, hls_playlist_path = ?, duration = ?, status = ?, updated_at = ?
+	           WHERE id = ?`
+	_, err := tx.ExecContext(ctx, query, track.FilePath, track.ContentHash, track.FileSize, track.HLSPlaylistPath,
+		track.Duration, track.Status, time.Now(), track.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update file for track ID %d: %w", track.ID, err)
+	}
+	return nil
+}
+
+// UpdateTrackMetadataWithTx 在事务中更新曲目的元数据，只更新 update 中非 nil 的字段
+func (r *mysqlTrackRepository) UpdateTrackMetadataWithTx(ctx context.Context, tx *sql.Tx, trackID int64, update *model.TrackMetadataUpdate) error {
+	if update == nil || update.IsEmpty() {
+		return nil
+	}
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	sets := make([]string, 0, 7)
+	args := make([]interface{}, 0, 8)
+	if update.Artist != nil {
+		sets = append(sets, "artist = ?")
+		args = append(args, *update.Artist)
+	}
+	if update.Album != nil {
+		sets = append(sets, "album = ?")
+		args = append(args, *update.Album)
+	}
+	if update.Genre != nil {
+		sets = append(sets, "genre = ?")
+		args = append(args, *update.Genre)
+	}
+	if update.CoverArtPath != nil {
+		sets = append(sets, "cover_art_path = ?")
+		args = append(args, *update.CoverArtPath)
+	}
+	if update.Title != nil {
+		sets = append(sets, "title = ?")
+		args = append(args, *update.Title)
+	}
+	if update.Year != nil {
+		sets = append(sets, "release_year = ?")
+		args = append(args, *update.Year)
+	}
+	sets = append(sets, "updated_at = ?")
+	args = append(args, time.Now(), trackID)
+
+	query := `UPDATE tracks SET ` + strings.Join(sets, ", ") + ` WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to execute UpdateTrackMetadataWithTx for track ID %d: %w", trackID, err)
+	}
+	return nil
+}
+
 // UpdateTrackStatus updates the processing status for a given track ID.
-func (r *mysqlTrackRepository) UpdateTrackStatus(trackID int64, status string) error {
+func (r *mysqlTrackRepository) UpdateTrackStatus(ctx context.Context, trackID int64, status string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	query := `UPDATE tracks SET status = ?, updated_at = ? WHERE id = ?`
-	stmt, err := r.DB.Prepare(query)
+	stmt, err := r.DB.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement for UpdateTrackStatus: %w", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(status, time.Now(), trackID)
+	_, err = stmt.ExecContext(ctx, status, time.Now(), trackID)
 	if err != nil {
 		return fmt.Errorf("failed to execute UpdateTrackStatus for track ID %d: %w", trackID, err)
 	}
@@ -229,18 +486,444 @@ func (r *mysqlTrackRepository) UpdateTrackStatus(trackID int64, status string) e
 }
 
 // UpdateTrackState updates the state for a given track ID (0=deleted, 1=normal).
-func (r *mysqlTrackRepository) UpdateTrackState(trackID int64, state int8) error {
-	query := `UPDATE tracks SET state = ?, updated_at = ? WHERE id = ?`
-	stmt, err := r.DB.Prepare(query)
+func (r *mysqlTrackRepository) UpdateTrackState(ctx context.Context, trackID int64, state int8) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	// 软删除时记录删除时间，回收站按此计算清理时间
+	query := `UPDATE tracks SET state = ?, deleted_at = IF(? = 0, ?, NULL), updated_at = ? WHERE id = ?`
+	stmt, err := r.DB.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement for UpdateTrackState: %w", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(state, time.Now(), trackID)
+	now := time.Now()
+	_, err = stmt.ExecContext(ctx, state, state, now, now, trackID)
 	if err != nil {
 		return fmt.Errorf("failed to execute UpdateTrackState for track ID %d: %w", trackID, err)
 	}
 	logger.Info("Track state updated", logger.Int64("trackId", trackID), logger.Int("state", int(state)))
 	return nil
 }
+
+// UpdateTrackLicense updates the license/attribution text for a given track ID.
+func (r *mysqlTrackRepository) UpdateTrackLicense(ctx context.Context, trackID int64, license string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE tracks SET license = ?, updated_at = ? WHERE id = ?`
+	stmt, err := r.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement for UpdateTrackLicense: %w", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, license, time.Now(), trackID)
+	if err != nil {
+		return fmt.Errorf("failed to execute UpdateTrackLicense for track ID %d: %w", trackID, err)
+	}
+	logger.Info("Track license updated", logger.Int64("trackId", trackID))
+	return nil
+}
+
+// GetFailedTracksSince retrieves active tracks of a user whose processing failed after the given time.
+func (r *mysqlTrackRepository) GetFailedTracksSince(ctx context.Context, userID int64, since time.Time) ([]*model.Track, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, title, artist, album, status, updated_at
+	           FROM tracks WHERE user_id = ? AND state = 1 AND status = 'failed' AND updated_at >= ?
+	           ORDER BY updated_at DESC`
+	rows, err := r.DB.QueryContext(ctx, query, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query failed tracks for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	tracks := make([]*model.Track, 0)
+	for rows.Next() {
+		track := &model.Track{}
+		if err := rows.Scan(&track.ID, &track.UserID, &track.Title, &track.Artist, &track.Album, &track.Status, &track.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan track in GetFailedTracksSince: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetFailedTracksSince: %w", err)
+	}
+
+	return tracks, nil
+}
+
+// GetTracksWithoutCover retrieves active tracks that have no cover art and whose last cover lookup
+// was before attemptedBefore, never-attempted tracks first, then the least recently attempted.
+func (r *mysqlTrackRepository) GetTracksWithoutCover(ctx context.Context, attemptedBefore time.Time, limit int) ([]*model.Track, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, title, artist, album
+	           FROM tracks WHERE state = 1 AND (cover_art_path IS NULL OR cover_art_path = '')
+	             AND (cover_attempted_at IS NULL OR cover_attempted_at < ?)
+	           ORDER BY cover_attempted_at IS NOT NULL, cover_attempted_at, id LIMIT ?`
+	rows, err := r.DB.QueryContext(ctx, query, attemptedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracks without cover: %w", err)
+	}
+	defer rows.Close()
+
+	tracks := make([]*model.Track, 0)
+	for rows.Next() {
+		track := &model.Track{}
+		if err := rows.Scan(&track.ID, &track.UserID, &track.Title, &track.Artist, &track.Album); err != nil {
+			return nil, fmt.Errorf("failed to scan track in GetTracksWithoutCover: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTracksWithoutCover: %w", err)
+	}
+
+	return tracks, nil
+}
+
+// MarkTrackCoverAttempted records that a cover lookup was just attempted for a track.
+func (r *mysqlTrackRepository) MarkTrackCoverAttempted(ctx context.Context, trackID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.DB.ExecContext(ctx, `UPDATE tracks SET cover_attempted_at = ? WHERE id = ?`, time.Now(), trackID); err != nil {
+		return fmt.Errorf("failed to mark cover attempt for track ID %d: %w", trackID, err)
+	}
+	return nil
+}
+
+// GetTracksByContentHash retrieves tracks sharing storage with the given content hash,
+// including tracks in the trash whose storage has not been purged yet.
+func (r *mysqlTrackRepository) GetTracksByContentHash(ctx context.Context, contentHash string) ([]*model.Track, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, COALESCE(file_path, ''), hls_playlist_path, duration, COALESCE(status, ''), content_hash
+	           FROM tracks WHERE content_hash = ? ORDER BY id`
+	rows, err := r.DB.QueryContext(ctx, query, contentHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracks by content hash: %w", err)
+	}
+	defer rows.Close()
+
+	tracks := make([]*model.Track, 0)
+	for rows.Next() {
+		track := &model.Track{}
+		if err := rows.Scan(&track.ID, &track.UserID, &track.FilePath, &track.HLSPlaylistPath, &track.Duration, &track.Status, &track.ContentHash); err != nil {
+			return nil, fmt.Errorf("failed to scan track in GetTracksByContentHash: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTracksByContentHash: %w", err)
+	}
+
+	return tracks, nil
+}
+
+// ReserveContentStorage marks the storage of the given content hash as about to be reused for ttl,
+// so that a concurrent release keeps it even though no track references it yet.
+// Blocks while a release holds the lock from LockContentStorageWithTx.
+func (r *mysqlTrackRepository) ReserveContentStorage(ctx context.Context, contentHash string, ttl time.Duration) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO storage_refs (content_hash, reserved_until) VALUES (?, DATE_ADD(NOW(), INTERVAL ? SECOND))
+	           ON DUPLICATE KEY UPDATE reserved_until = GREATEST(reserved_until, VALUES(reserved_until))`
+	if _, err := r.DB.ExecContext(ctx, query, contentHash, int64(ttl.Seconds())); err != nil {
+		return fmt.Errorf("failed to reserve storage for content hash %s: %w", contentHash, err)
+	}
+	return nil
+}
+
+// LockContentStorageWithTx locks the storage of the given content hash until tx ends
+// and reports whether an upload has reserved it for reuse.
+func (r *mysqlTrackRepository) LockContentStorageWithTx(ctx context.Context, tx *sql.Tx, contentHash string) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	insert := `INSERT INTO storage_refs (content_hash, reserved_until) VALUES (?, NOW())
+	            ON DUPLICATE KEY UPDATE content_hash = content_hash`
+	if _, err := tx.ExecContext(ctx, insert, contentHash); err != nil {
+		return false, fmt.Errorf("failed to create storage ref for content hash %s: %w", contentHash, err)
+	}
+
+	var reserved bool
+	query := `SELECT reserved_until > NOW() FROM storage_refs WHERE content_hash = ? FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, contentHash).Scan(&reserved); err != nil {
+		return false, fmt.Errorf("failed to lock storage ref for content hash %s: %w", contentHash, err)
+	}
+	return reserved, nil
+}
+
+// DeleteContentStorageRefWithTx removes the storage ref of a content hash no longer referenced by any track.
+func (r *mysqlTrackRepository) DeleteContentStorageRefWithTx(ctx context.Context, tx *sql.Tx, contentHash string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM storage_refs WHERE content_hash = ?`, contentHash); err != nil {
+		return fmt.Errorf("failed to delete storage ref for content hash %s: %w", contentHash, err)
+	}
+	return nil
+}
+
+// GetTracksByHLSPlaylistPath retrieves the live tracks that play the given HLS stream.
+// Tracks with identical content and transcode options share one stream, so several users may reference it.
+func (r *mysqlTrackRepository) GetTracksByHLSPlaylistPath(ctx context.Context, playlistPath string) ([]*model.Track, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, hls_playlist_path
+	           FROM tracks WHERE hls_playlist_path = ? AND deleted_at IS NULL AND COALESCE(state, 1) <> 0 ORDER BY id`
+	rows, err := r.DB.QueryContext(ctx, query, playlistPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracks by playlist path: %w", err)
+	}
+	defer rows.Close()
+
+	tracks := make([]*model.Track, 0)
+	for rows.Next() {
+		track := &model.Track{}
+		if err := rows.Scan(&track.ID, &track.UserID, &track.HLSPlaylistPath); err != nil {
+			return nil, fmt.Errorf("failed to scan track in GetTracksByHLSPlaylistPath: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTracksByHLSPlaylistPath: %w", err)
+	}
+	return tracks, nil
+}
+
+// GetTrackStorageRefs retrieves the owner and storage paths referenced by all tracks, including tracks in the trash
+// and replaced versions that are still recoverable. Version refs carry the track ID and have Status "version".
+func (r *mysqlTrackRepository) GetTrackStorageRefs(ctx context.Context) ([]*model.Track, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, COALESCE(file_path, ''), COALESCE(hls_playlist_path, ''), '' FROM tracks
+	           UNION ALL
+	           SELECT track_id, user_id, file_path, hls_playlist_path, 'version' FROM track_versions`
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query track storage refs: %w", err)
+	}
+	defer rows.Close()
+
+	tracks := make([]*model.Track, 0)
+	for rows.Next() {
+		track := &model.Track{}
+		if err := rows.Scan(&track.ID, &track.UserID, &track.FilePath, &track.HLSPlaylistPath, &track.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan track in GetTrackStorageRefs: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTrackStorageRefs: %w", err)
+	}
+
+	return tracks, nil
+}
+
+// GetLiveTrackStorageStates retrieves the storage paths, duration, processing status and last update time of tracks not in the trash.
+func (r *mysqlTrackRepository) GetLiveTrackStorageStates(ctx context.Context) ([]*model.Track, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, COALESCE(file_path, ''), COALESCE(hls_playlist_path, ''), duration, COALESCE(status, ''), COALESCE(content_hash, ''), updated_at
+	           FROM tracks WHERE state = 1 ORDER BY id`
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query live track storage states: %w", err)
+	}
+	defer rows.Close()
+
+	tracks := make([]*model.Track, 0)
+	for rows.Next() {
+		track := &model.Track{}
+		if err := rows.Scan(&track.ID, &track.UserID, &track.FilePath, &track.HLSPlaylistPath, &track.Duration, &track.Status, &track.ContentHash, &track.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan track in GetLiveTrackStorageStates: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetLiveTrackStorageStates: %w", err)
+	}
+
+	return tracks, nil
+}
+
+// GetDeletedTracksByUserID retrieves a user's tracks in the trash, most recently deleted first.
+func (r *mysqlTrackRepository) GetDeletedTracksByUserID(ctx context.Context, userID int64) ([]*model.Track, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, title, artist, album, cover_art_path, duration, deleted_at
+	           FROM tracks WHERE user_id = ? AND state = 0
+	           ORDER BY deleted_at DESC, id DESC`
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted tracks for user ID %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	tracks := make([]*model.Track, 0)
+	for rows.Next() {
+		track := &model.Track{}
+		var coverArtPath sql.NullString
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&track.ID, &track.UserID, &track.Title, &track.Artist, &track.Album, &coverArtPath, &track.Duration, &deletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan track in GetDeletedTracksByUserID: %w", err)
+		}
+		track.CoverArtPath = coverArtPath.String
+		if deletedAt.Valid {
+			track.DeletedAt = &deletedAt.Time
+		}
+		tracks = append(tracks, track)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetDeletedTracksByUserID: %w", err)
+	}
+
+	return tracks, nil
+}
+
+// GetTracksDeletedBefore retrieves tracks moved to the trash before the given time.
+// Tracks soft-deleted before the trash existed have no deletion time and are included as well.
+func (r *mysqlTrackRepository) GetTracksDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*model.Track, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, user_id, COALESCE(file_path, ''), COALESCE(cover_art_path, ''), COALESCE(hls_playlist_path, ''), COALESCE(content_hash, '')
+	           FROM tracks WHERE state = 0 AND (deleted_at IS NULL OR deleted_at < ?)
+	           ORDER BY id LIMIT ?`
+	rows, err := r.DB.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracks deleted before %s: %w", before, err)
+	}
+	defer rows.Close()
+
+	tracks := make([]*model.Track, 0)
+	for rows.Next() {
+		track := &model.Track{}
+		if err := rows.Scan(&track.ID, &track.UserID, &track.FilePath, &track.CoverArtPath, &track.HLSPlaylistPath, &track.ContentHash); err != nil {
+			return nil, fmt.Errorf("failed to scan track in GetTracksDeletedBefore: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTracksDeletedBefore: %w", err)
+	}
+
+	return tracks, nil
+}
+
+// RestoreTrack 将用户回收站中的曲目恢复，返回曲目是否在回收站中
+func (r *mysqlTrackRepository) RestoreTrack(ctx context.Context, userID, trackID int64) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE tracks SET state = 1, deleted_at = NULL, updated_at = ? WHERE id = ? AND user_id = ? AND state = 0`
+	res, err := r.DB.ExecContext(ctx, query, time.Now(), trackID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute RestoreTrack for track ID %d: %w", trackID, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows for RestoreTrack: %w", err)
+	}
+	if affected > 0 {
+		logger.Info("Track restored from trash", logger.Int64("trackId", trackID))
+	}
+	return affected > 0, nil
+}
+
+// PurgeTrack 彻底删除回收站中的曲目记录，关联的指纹、标签和专辑条目随外键级联删除，评论单独删除
+func (r *mysqlTrackRepository) PurgeTrack(ctx context.Context, trackID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM tracks WHERE id = ? AND state = 0`, trackID)
+	if err != nil {
+		return fmt.Errorf("failed to execute PurgeTrack for track ID %d: %w", trackID, err)
+	}
+	if affected, _ := res.RowsAffected(); affected > 0 {
+		query := `DELETE FROM track_comments WHERE source = 'local' AND source_id = ?`
+		if _, err := r.DB.ExecContext(ctx, query, strconv.FormatInt(trackID, 10)); err != nil {
+			return fmt.Errorf("failed to delete comments of purged track ID %d: %w", trackID, err)
+		}
+	}
+	logger.Info("Track purged", logger.Int64("trackId", trackID))
+	return nil
+}
+
+// IsCoverArtReferenced reports whether any track or album still uses the given cover path.
+func (r *mysqlTrackRepository) IsCoverArtReferenced(ctx context.Context, coverPath string) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT EXISTS(SELECT 1 FROM tracks WHERE cover_art_path = ?) OR EXISTS(SELECT 1 FROM albums WHERE cover_path = ?)`
+	var referenced bool
+	if err := r.DB.QueryRowContext(ctx, query, coverPath, coverPath).Scan(&referenced); err != nil {
+		return false, fmt.Errorf("failed to check cover references for %s: %w", coverPath, err)
+	}
+	return referenced, nil
+}
+
+// GetUserUploadedBytes 统计用户上传的源文件总字节数，回收站中的曲目彻底删除前仍计入
+func (r *mysqlTrackRepository) GetUserUploadedBytes(ctx context.Context, userID int64) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var total int64
+	query := `SELECT COALESCE(SUM(file_size), 0) FROM tracks WHERE user_id = ?`
+	if err := r.DB.QueryRowContext(ctx, query, userID).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to get uploaded bytes for user %d: %w", userID, err)
+	}
+	return total, nil
+}
+
+// GetTrackStats 按处理状态统计曲目数，回收站中的曲目只计入 Trashed
+func (r *mysqlTrackRepository) GetTrackStats(ctx context.Context) (*model.TrackStats, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	stats := &model.TrackStats{ByStatus: make(map[string]int64)}
+	query := `SELECT COALESCE(status, ''), deleted_at IS NOT NULL, COUNT(*) FROM tracks GROUP BY status, deleted_at IS NOT NULL`
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tracks by status: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var trashed bool
+		var count int64
+		if err := rows.Scan(&status, &trashed, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan track status count: %w", err)
+		}
+		if trashed {
+			stats.Trashed += count
+			continue
+		}
+		stats.ByStatus[status] += count
+		stats.Total += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetTrackStats: %w", err)
+	}
+	return stats, nil
+}
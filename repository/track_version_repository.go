@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Bt1QFM/db"
+	"Bt1QFM/model"
+)
+
+// TrackVersionRepository defines the interface for replaced track audio versions.
+type TrackVersionRepository interface {
+	CreateVersionWithTx(ctx context.Context, tx *sql.Tx, version *model.TrackVersion) (int64, error)
+	GetVersionByID(ctx context.Context, id int64) (*model.TrackVersion, error)
+	GetVersionsByTrackID(ctx context.Context, trackID int64) ([]*model.TrackVersion, error)
+	GetVersionsByContentHash(ctx context.Context, contentHash string) ([]*model.TrackVersion, error)
+	GetVersionsExpiredBefore(ctx context.Context, before time.Time, limit int) ([]*model.TrackVersion, error)
+	DeleteVersionWithTx(ctx context.Context, tx *sql.Tx, id int64) error
+	DeleteVersion(ctx context.Context, id int64) error
+}
+
+// mysqlTrackVersionRepository implements TrackVersionRepository for MySQL.
+type mysqlTrackVersionRepository struct {
+	DB *sql.DB
+}
+
+// NewMySQLTrackVersionRepository creates a new instance of mysqlTrackVersionRepository.
+func NewMySQLTrackVersionRepository() TrackVersionRepository {
+	return &mysqlTrackVersionRepository{DB: db.DB}
+}
+
+const trackVersionColumns = `id, track_id, user_id, file_path, hls_playlist_path, content_hash, file_size, duration, created_at, expires_at`
+
+// CreateVersionWithTx records a replaced audio version within a transaction and returns its ID.
+func (r *mysqlTrackVersionRepository) CreateVersionWithTx(ctx context.Context, tx *sql.Tx, version *model.TrackVersion) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO track_versions (track_id, user_id, file_path, hls_playlist_path, content_hash, file_size, duration, created_at, expires_at)
+	           VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := tx.ExecContext(ctx, query, version.TrackID, version.UserID, version.FilePath, version.HLSPlaylistPath,
+		version.ContentHash, version.FileSize, version.Duration, version.CreatedAt, version.ExpiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create version for track ID %d: %w", version.TrackID, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID for track version: %w", err)
+	}
+	return id, nil
+}
+
+// GetVersionByID retrieves a version by its ID, or nil if it does not exist.
+func (r *mysqlTrackVersionRepository) GetVersionByID(ctx context.Context, id int64) (*model.TrackVersion, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + trackVersionColumns + ` FROM track_versions WHERE id = ?`
+	version, err := scanTrackVersion(r.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan track version by ID %d: %w", id, err)
+	}
+	return version, nil
+}
+
+// GetVersionsByTrackID retrieves the versions of a track, most recently replaced first.
+func (r *mysqlTrackVersionRepository) GetVersionsByTrackID(ctx context.Context, trackID int64) ([]*model.TrackVersion, error) {
+	query := `SELECT ` + trackVersionColumns + ` FROM track_versions WHERE track_id = ? ORDER BY created_at DESC, id DESC`
+	return r.queryVersions(ctx, query, trackID)
+}
+
+// GetVersionsByContentHash retrieves the versions still referencing storage with the given content hash.
+func (r *mysqlTrackVersionRepository) GetVersionsByContentHash(ctx context.Context, contentHash string) ([]*model.TrackVersion, error) {
+	query := `SELECT ` + trackVersionColumns + ` FROM track_versions WHERE content_hash = ? ORDER BY id`
+	return r.queryVersions(ctx, query, contentHash)
+}
+
+// GetVersionsExpiredBefore retrieves up to limit versions whose retention ended before the given time.
+func (r *mysqlTrackVersionRepository) GetVersionsExpiredBefore(ctx context.Context, before time.Time, limit int) ([]*model.TrackVersion, error) {
+	query := `SELECT ` + trackVersionColumns + ` FROM track_versions WHERE expires_at < ? ORDER BY expires_at LIMIT ?`
+	return r.queryVersions(ctx, query, before, limit)
+}
+
+// DeleteVersionWithTx deletes a version within a transaction.
+func (r *mysqlTrackVersionRepository) DeleteVersionWithTx(ctx context.Context, tx *sql.Tx, id int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM track_versions WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete track version %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteVersion deletes a version.
+func (r *mysqlTrackVersionRepository) DeleteVersion(ctx context.Context, id int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.DB.ExecContext(ctx, `DELETE FROM track_versions WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete track version %d: %w", id, err)
+	}
+	return nil
+}
+
+func (r *mysqlTrackVersionRepository) queryVersions(ctx context.Context, query string, args ...interface{}) ([]*model.TrackVersion, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query track versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make([]*model.TrackVersion, 0)
+	for rows.Next() {
+		version, err := scanTrackVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan track version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during track version rows iteration: %w", err)
+	}
+	return versions, nil
+}
+
+func scanTrackVersion(row rowScanner) (*model.TrackVersion, error) {
+	version := &model.TrackVersion{}
+	err := row.Scan(&version.ID, &version.TrackID, &version.UserID, &version.FilePath, &version.HLSPlaylistPath,
+		&version.ContentHash, &version.FileSize, &version.Duration, &version.CreatedAt, &version.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
@@ -1,22 +1,31 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"Bt1QFM/db"
 	"Bt1QFM/model"
 	"github.com/go-sql-driver/mysql"
 )
 
 // UserRepository defines the interface for user data operations.
 type UserRepository interface {
-	CreateUser(user *model.User) (int64, error)
-	GetUserByID(id int64) (*model.User, error)
-	GetUserByUsername(username string) (*model.User, error)
-	GetUserByEmail(email string) (*model.User, error)
-	UpdateNeteaseInfo(userID int64, neteaseUsername, neteaseUID string) error
-	UpdateUserProfile(userID int64, username, email, phone string) error
+	CreateUser(ctx context.Context, user *model.User) (int64, error)
+	GetUserByID(ctx context.Context, id int64) (*model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
+	UpdateNeteaseInfo(ctx context.Context, userID int64, neteaseUsername, neteaseUID string) error
+	UpdateUserProfile(ctx context.Context, userID int64, username, email, phone string) error
+	UpdatePreferences(ctx context.Context, userID int64, old sql.NullString, preferences string) (bool, error)
+	UpdatePassword(ctx context.Context, userID int64, passwordHash string) error
+	UpdateUserStatus(ctx context.Context, userID int64, status string) (bool, error)
+	GetAllUsers(ctx context.Context) ([]*model.User, error)
+	GetUserStats(ctx context.Context, since time.Time) (*model.UserStats, error)
+	GetUserIDsByStatus(ctx context.Context, status string) ([]int64, error)
 }
 
 // mysqlUserRepository implements UserRepository for MySQL.
@@ -33,15 +42,23 @@ func NewMySQLUserRepository(db *sql.DB) UserRepository {
 var ErrDuplicateUser = errors.New("username or email already exists")
 
 // CreateUser adds a new user to the database.
-func (r *mysqlUserRepository) CreateUser(user *model.User) (int64, error) {
-	query := "INSERT INTO users (username, email, password_hash, phone, preferences, netease_username, netease_uid) VALUES (?, ?, ?, ?, ?, ?, ?)"
-	stmt, err := r.db.Prepare(query)
+func (r *mysqlUserRepository) CreateUser(ctx context.Context, user *model.User) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	status := user.Status
+	if status == "" {
+		status = model.UserStatusActive
+	}
+
+	query := "INSERT INTO users (username, email, password_hash, phone, preferences, netease_username, netease_uid, status, email_verified_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, IF(? = 'active', NOW(), NULL))"
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return 0, fmt.Errorf("failed to prepare create user statement: %w", err)
 	}
 	defer stmt.Close()
 
-	res, err := stmt.Exec(user.Username, user.Email, user.PasswordHash, user.Phone, user.Preferences, user.NeteaseUsername, user.NeteaseUID)
+	res, err := stmt.ExecContext(ctx, user.Username, user.Email, user.PasswordHash, user.Phone, user.Preferences, user.NeteaseUsername, user.NeteaseUID, status, status)
 	if err != nil {
 		// 检查是否是 MySQL 唯一约束冲突错误（错误码 1062）
 		var mysqlErr *mysql.MySQLError
@@ -59,11 +76,14 @@ func (r *mysqlUserRepository) CreateUser(user *model.User) (int64, error) {
 }
 
 // GetUserByID retrieves a user by their ID.
-func (r *mysqlUserRepository) GetUserByID(id int64) (*model.User, error) {
-	query := "SELECT id, username, email, password_hash, phone, preferences, netease_username, netease_uid, created_at, updated_at FROM users WHERE id = ?"
-	row := r.db.QueryRow(query, id)
+func (r *mysqlUserRepository) GetUserByID(ctx context.Context, id int64) (*model.User, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, username, email, password_hash, phone, preferences, netease_username, netease_uid, status, email_verified_at, created_at, updated_at FROM users WHERE id = ?"
+	row := r.db.QueryRowContext(ctx, query, id)
 	user := &model.User{}
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Phone, &user.Preferences, &user.NeteaseUsername, &user.NeteaseUID, &user.CreatedAt, &user.UpdatedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Phone, &user.Preferences, &user.NeteaseUsername, &user.NeteaseUID, &user.Status, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // User not found
@@ -74,11 +94,14 @@ func (r *mysqlUserRepository) GetUserByID(id int64) (*model.User, error) {
 }
 
 // GetUserByUsername retrieves a user by their username.
-func (r *mysqlUserRepository) GetUserByUsername(username string) (*model.User, error) {
-	query := "SELECT id, username, email, password_hash, phone, preferences, netease_username, netease_uid, created_at, updated_at FROM users WHERE username = ?"
-	row := r.db.QueryRow(query, username)
+func (r *mysqlUserRepository) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, username, email, password_hash, phone, preferences, netease_username, netease_uid, status, email_verified_at, created_at, updated_at FROM users WHERE username = ?"
+	row := r.db.QueryRowContext(ctx, query, username)
 	user := &model.User{}
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Phone, &user.Preferences, &user.NeteaseUsername, &user.NeteaseUID, &user.CreatedAt, &user.UpdatedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Phone, &user.Preferences, &user.NeteaseUsername, &user.NeteaseUID, &user.Status, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // User not found
@@ -89,11 +112,14 @@ func (r *mysqlUserRepository) GetUserByUsername(username string) (*model.User, e
 }
 
 // GetUserByEmail retrieves a user by their email address.
-func (r *mysqlUserRepository) GetUserByEmail(email string) (*model.User, error) {
-	query := "SELECT id, username, email, password_hash, phone, preferences, netease_username, netease_uid, created_at, updated_at FROM users WHERE email = ?"
-	row := r.db.QueryRow(query, email)
+func (r *mysqlUserRepository) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, username, email, password_hash, phone, preferences, netease_username, netease_uid, status, email_verified_at, created_at, updated_at FROM users WHERE email = ?"
+	row := r.db.QueryRowContext(ctx, query, email)
 	user := &model.User{}
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Phone, &user.Preferences, &user.NeteaseUsername, &user.NeteaseUID, &user.CreatedAt, &user.UpdatedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Phone, &user.Preferences, &user.NeteaseUsername, &user.NeteaseUID, &user.Status, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // User not found
@@ -104,9 +130,12 @@ func (r *mysqlUserRepository) GetUserByEmail(email string) (*model.User, error)
 }
 
 // UpdateNeteaseInfo updates user's netease username and UID.
-func (r *mysqlUserRepository) UpdateNeteaseInfo(userID int64, neteaseUsername, neteaseUID string) error {
+func (r *mysqlUserRepository) UpdateNeteaseInfo(ctx context.Context, userID int64, neteaseUsername, neteaseUID string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	query := "UPDATE users SET netease_username = ?, netease_uid = ?, updated_at = NOW() WHERE id = ?"
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare update netease info statement: %w", err)
 	}
@@ -120,7 +149,7 @@ func (r *mysqlUserRepository) UpdateNeteaseInfo(userID int64, neteaseUsername, n
 		neteaseUIDNull = sql.NullString{String: neteaseUID, Valid: true}
 	}
 
-	_, err = stmt.Exec(neteaseUsernameNull, neteaseUIDNull, userID)
+	_, err = stmt.ExecContext(ctx, neteaseUsernameNull, neteaseUIDNull, userID)
 	if err != nil {
 		return fmt.Errorf("failed to execute update netease info statement: %w", err)
 	}
@@ -128,9 +157,12 @@ func (r *mysqlUserRepository) UpdateNeteaseInfo(userID int64, neteaseUsername, n
 }
 
 // UpdateUserProfile updates user's basic profile information.
-func (r *mysqlUserRepository) UpdateUserProfile(userID int64, username, email, phone string) error {
+func (r *mysqlUserRepository) UpdateUserProfile(ctx context.Context, userID int64, username, email, phone string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	query := "UPDATE users SET username = ?, email = ?, phone = ?, updated_at = NOW() WHERE id = ?"
-	stmt, err := r.db.Prepare(query)
+	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare update user profile statement: %w", err)
 	}
@@ -141,9 +173,180 @@ func (r *mysqlUserRepository) UpdateUserProfile(userID int64, username, email, p
 		phoneNull = sql.NullString{String: phone, Valid: true}
 	}
 
-	_, err = stmt.Exec(username, email, phoneNull, userID)
+	_, err = stmt.ExecContext(ctx, username, email, phoneNull, userID)
 	if err != nil {
 		return fmt.Errorf("failed to execute update user profile statement: %w", err)
 	}
 	return nil
 }
+
+// UpdatePreferences updates user's preferences JSON only if it still equals old,
+// so concurrent read-modify-write updates cannot overwrite each other.
+// 返回 false 表示偏好已被其他请求修改（或用户不存在），调用方应重新读取后重试
+func (r *mysqlUserRepository) UpdatePreferences(ctx context.Context, userID int64, old sql.NullString, preferences string) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE users SET preferences = ?, updated_at = NOW() WHERE id = ? AND preferences <=> ?"
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return false, fmt.Errorf("failed to prepare update preferences statement: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, preferences, userID, old)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute update preferences statement: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows for UpdatePreferences: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// UpdatePassword updates user's password hash.
+func (r *mysqlUserRepository) UpdatePassword(ctx context.Context, userID int64, passwordHash string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE users SET password_hash = ?, updated_at = NOW() WHERE id = ?"
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare update password statement: %w", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, passwordHash, userID)
+	if err != nil {
+		return fmt.Errorf("failed to execute update password statement: %w", err)
+	}
+	return nil
+}
+
+// UpdateUserStatus 更新账号状态，返回用户是否存在
+// 变为 active 时记录邮箱验证时间，变为 pending 时清除，禁用账号时保留
+func (r *mysqlUserRepository) UpdateUserStatus(ctx context.Context, userID int64, status string) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE users SET status = ?,
+	           email_verified_at = CASE ? WHEN 'active' THEN COALESCE(email_verified_at, NOW()) WHEN 'pending' THEN NULL ELSE email_verified_at END,
+	           updated_at = NOW()
+	           WHERE id = ?`
+	res, err := r.db.ExecContext(ctx, query, status, status, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update status for user ID %d: %w", userID, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows for UpdateUserStatus: %w", err)
+	}
+	if affected > 0 {
+		return true, nil
+	}
+	// 状态未变化时 RowsAffected 为 0，再确认用户是否存在
+	var exists int
+	err = r.db.QueryRowContext(ctx, "SELECT 1 FROM users WHERE id = ?", userID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check user ID %d: %w", userID, err)
+	}
+	return true, nil
+}
+
+// GetAllUsers retrieves all users.
+func (r *mysqlUserRepository) GetAllUsers(ctx context.Context) ([]*model.User, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, username, email, password_hash, phone, preferences, netease_username, netease_uid, status, email_verified_at, created_at, updated_at FROM users ORDER BY id"
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*model.User, 0)
+	for rows.Next() {
+		user := &model.User{}
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Phone, &user.Preferences, &user.NeteaseUsername, &user.NeteaseUID, &user.Status, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetAllUsers: %w", err)
+	}
+	return users, nil
+}
+
+// GetUserStats 统计各状态的用户数，以及 since 之后每天的注册数
+func (r *mysqlUserRepository) GetUserStats(ctx context.Context, since time.Time) (*model.UserStats, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	stats := &model.UserStats{ByStatus: make(map[string]int64), Signups: make([]model.DailyCount, 0)}
+	rows, err := r.db.QueryContext(ctx, "SELECT status, COUNT(*) FROM users GROUP BY status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users by status: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan user status count: %w", err)
+		}
+		stats.ByStatus[status] = count
+		stats.Total += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetUserStats: %w", err)
+	}
+
+	signupRows, err := r.db.QueryContext(ctx,
+		"SELECT DATE_FORMAT(created_at, '%Y-%m-%d') AS day, COUNT(*) FROM users WHERE created_at >= ? GROUP BY day ORDER BY day", since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count signups: %w", err)
+	}
+	defer signupRows.Close()
+	for signupRows.Next() {
+		var day model.DailyCount
+		if err := signupRows.Scan(&day.Date, &day.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan signup count: %w", err)
+		}
+		stats.Signups = append(stats.Signups, day)
+	}
+	if err := signupRows.Err(); err != nil {
+		return nil, fmt.Errorf("error during signup rows iteration in GetUserStats: %w", err)
+	}
+	return stats, nil
+}
+
+// GetUserIDsByStatus 获取指定状态的所有用户 ID
+func (r *mysqlUserRepository) GetUserIDsByStatus(ctx context.Context, status string) ([]int64, error) {
+	ctx, cancel := db.WithListTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id FROM users WHERE status = ?", status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users with status %s: %w", status, err)
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan user ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration in GetUserIDsByStatus: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"Bt1QFM/logger"
+)
+
+// requestIDHeader 请求 ID 的请求头和响应头
+const requestIDHeader = "X-Request-ID"
+
+// requestInfoKey 请求信息在 context 中的键
+type requestInfoKey struct{}
+
+// requestInfo 单个请求的上下文信息，由访问日志中间件创建，认证中间件补充用户
+type requestInfo struct {
+	id       string
+	userID   int64
+	username string
+}
+
+// AccessLogMiddleware 为每个请求分配请求 ID 并记录访问日志
+// 请求 ID 沿用客户端传入的 X-Request-ID，写入响应头、错误响应和 context 中的日志字段，
+// 用户反馈问题时提供请求 ID 即可在日志中找到对应请求
+func AccessLogMiddleware(next http.Handler, proxies []*net.IPNet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		info := &requestInfo{id: requestID}
+		ctx := context.WithValue(r.Context(), requestInfoKey{}, info)
+		ctx = logger.ContextWithFields(ctx, logger.String("requestId", requestID))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		fields := []logger.Field{
+			logger.String("requestId", requestID),
+			logger.String("method", r.Method),
+			logger.String("path", r.URL.Path),
+			logger.Int("status", status),
+			logger.Int64("bytes", rec.bytes),
+			logger.Duration("latency", time.Since(start)),
+			logger.String("ip", clientIP(r, proxies)),
+		}
+		if info.userID != 0 {
+			fields = append(fields, logger.Int64("userId", info.userID), logger.String("username", info.username))
+		}
+
+		switch {
+		case status >= http.StatusBadRequest:
+			logger.Warn("HTTP 请求", fields...)
+		case strings.HasPrefix(r.URL.Path, "/streams/") || strings.HasPrefix(r.URL.Path, "/static/"):
+			// 播放时每几秒请求一个分片，成功的流媒体请求只在调试级别记录
+			logger.Debug("HTTP 请求", fields...)
+		default:
+			logger.Info("HTTP 请求", fields...)
+		}
+	})
+}
+
+// withRequestUser 记录当前请求的用户，用于访问日志和之后的日志字段
+func withRequestUser(ctx context.Context, userID int64, username string) context.Context {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.userID = userID
+		info.username = username
+	}
+	return logger.ContextWithFields(ctx, logger.Int64("userId", userID))
+}
+
+// GetRequestIDFromContext 返回当前请求的 ID
+func GetRequestIDFromContext(ctx context.Context) string {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		return info.id
+	}
+	return ""
+}
+
+// newRequestID 生成随机请求 ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder 记录响应状态码和字节数
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader 记录状态码
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write 记录写出的字节数
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(p)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap 返回原始 ResponseWriter，供 http.ResponseController 使用
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Flush 支持流式响应
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 支持 WebSocket 升级
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	if rec.status == 0 {
+		rec.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/netease"
+	"Bt1QFM/core/room"
+	"Bt1QFM/core/storagegc"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// 管理后台注册趋势的默认和最大统计天数
+const (
+	defaultSignupDays = 30
+	maxSignupDays     = 365
+)
+
+// SetRoomManager 设置房间管理器，供管理接口查看和关闭房间、断开被禁用用户的连接
+func (h *APIHandler) SetRoomManager(manager *room.RoomManager) {
+	h.roomManager = manager
+}
+
+// SetStorageReconciler 设置存储对账器，供管理接口手动触发对账
+func (h *APIHandler) SetStorageReconciler(reconciler *storagegc.Reconciler) {
+	h.reconciler = reconciler
+}
+
+// StorageGCHandler 手动触发存储垃圾回收，dryRun=true 时只报告可回收的空间
+func (h *APIHandler) StorageGCHandler(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dryRun") == "true" || r.URL.Query().Get("dryRun") == "1"
+
+	report, err := h.storageGC.Run(r.Context(), dryRun)
+	if err == storagegc.ErrAlreadyRunning {
+		writeError(w, CodeConflict, "Storage GC is already running")
+		return
+	}
+	if err != nil {
+		logger.Error("存储回收失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Storage GC failed")
+		return
+	}
+
+	username, _ := GetUsernameFromContext(r.Context())
+	logger.Info("管理员触发存储回收",
+		logger.String("username", username),
+		logger.Bool("dryRun", dryRun),
+		logger.Int64("reclaimableBytes", report.ReclaimableBytes))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// StorageReconcileHandler 手动触发存储对账，dryRun=true 时只报告存储对象已丢失的曲目
+func (h *APIHandler) StorageReconcileHandler(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dryRun") == "true" || r.URL.Query().Get("dryRun") == "1"
+
+	report, err := h.reconciler.Run(r.Context(), dryRun)
+	if err == storagegc.ErrAlreadyRunning {
+		writeError(w, CodeConflict, "Storage reconciliation is already running")
+		return
+	}
+	if err != nil {
+		logger.Error("存储对账失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Storage reconciliation failed")
+		return
+	}
+
+	username, _ := GetUsernameFromContext(r.Context())
+	logger.Info("管理员触发存储对账",
+		logger.String("username", username),
+		logger.Bool("dryRun", dryRun),
+		logger.Int("checked", report.Checked),
+		logger.Int("problems", len(report.Items)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// StreamCacheStatsHandler 返回 HLS 播放列表和分片各级缓存的命中统计
+func (h *APIHandler) StreamCacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    h.streamProcessor.CacheStats(),
+	})
+}
+
+// NeteaseHealthHandler 返回各网易云API地址的熔断状态和失败统计
+func (h *APIHandler) NeteaseHealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    netease.Health(),
+	})
+}
+
+// TranscodeStatsHandler 返回转码池的并发上限、运行中和排队中的任务数
+// 转码交给 worker 时，workerQueue 为 Redis 队列中等待 worker 处理的任务数
+func (h *APIHandler) TranscodeStatsHandler(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]interface{}{
+		"success": true,
+		"data":    audio.GetTranscodeStats(),
+	}
+	if h.dispatchesToWorker() {
+		if queued, err := cache.TranscodeQueueLength(r.Context()); err != nil {
+			logger.Ctx(r.Context()).Warn("获取转码队列长度失败", logger.ErrorField(err))
+		} else {
+			resp["workerQueue"] = queued
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// AdminOverviewHandler 返回管理后台概览：用户数量和注册趋势、活跃房间、转码池状态
+// GET /api/admin/overview?days=30
+func (h *APIHandler) AdminOverviewHandler(w http.ResponseWriter, r *http.Request) {
+	userStats, err := h.userRepo.GetUserStats(r.Context(), signupSince(r))
+	if err != nil {
+		logger.Ctx(r.Context()).Error("统计用户失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get user stats")
+		return
+	}
+
+	rooms := h.activeRooms(r)
+	connections := 0
+	for _, active := range rooms {
+		connections += active.Connections
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"users": userStats,
+			"rooms": map[string]interface{}{
+				"active":      len(rooms),
+				"connections": connections,
+			},
+			"transcode": audio.GetTranscodeStats(),
+		},
+	})
+}
+
+// AdminUserStatsHandler 返回各状态的用户数和每天的注册数，GET /api/admin/users/stats?days=30
+func (h *APIHandler) AdminUserStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.userRepo.GetUserStats(r.Context(), signupSince(r))
+	if err != nil {
+		logger.Ctx(r.Context()).Error("统计用户失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get user stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    stats,
+	})
+}
+
+// AdminStorageUsageHandler 按对象存储中的实际大小统计每个用户占用的空间，GET /api/admin/storage/usage
+// 需要列出全部对象，曲库较大时耗时较长
+func (h *APIHandler) AdminStorageUsageHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := h.storageGC.Usage(r.Context())
+	if err != nil {
+		logger.Ctx(r.Context()).Error("统计存储占用失败", logger.ErrorField(err))
+		writeError(w, CodeStorageUnavailable, "Failed to compute storage usage")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// AdminRoomsHandler 列出当前有连接的房间，GET /api/admin/rooms
+func (h *APIHandler) AdminRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    h.activeRooms(r),
+	})
+}
+
+// AdminCloseRoomHandler 强制关闭房间并断开所有连接，POST /api/admin/rooms/{room_id}/close
+func (h *APIHandler) AdminCloseRoomHandler(w http.ResponseWriter, r *http.Request) {
+	if h.roomManager == nil {
+		writeError(w, CodeServiceUnavailable, "Room service not available")
+		return
+	}
+	roomID := mux.Vars(r)["room_id"]
+	if err := h.roomManager.ForceCloseRoom(r.Context(), roomID); err != nil {
+		writeError(w, CodeRoomNotFound, err.Error())
+		return
+	}
+
+	admin, _ := GetUsernameFromContext(r.Context())
+	logger.Ctx(r.Context()).Info("管理员关闭房间",
+		logger.String("admin", admin),
+		logger.String("roomId", roomID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// activeRooms 当前有连接的房间，房间服务未启用时为空
+func (h *APIHandler) activeRooms(r *http.Request) []*room.ActiveRoom {
+	if h.roomManager == nil {
+		return []*room.ActiveRoom{}
+	}
+	return h.roomManager.ActiveRooms(r.Context())
+}
+
+// signupSince 根据 days 参数计算注册趋势的起始时间（当天零点）
+func signupSince(r *http.Request) time.Time {
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days <= 0 {
+		days = defaultSignupDays
+	}
+	days = min(days, maxSignupDays)
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day()-days+1, 0, 0, 0, 0, now.Location())
+}
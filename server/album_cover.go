@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"Bt1QFM/core/cover"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// albumCoverTimeout 从歌曲中提取并处理专辑封面的超时时间
+const albumCoverTimeout = 2 * time.Minute
+
+// extractAlbumCover 从上传到专辑的第一首歌曲中提取内嵌封面作为专辑封面
+// 歌曲没有内嵌封面或处理失败时交给封面获取服务从外部来源查找
+func (h *APIHandler) extractAlbumCover(album *model.Album, audio []byte, ext string) {
+	ctx, cancel := context.WithTimeout(context.Background(), albumCoverTimeout)
+	defer cancel()
+
+	coverPath, err := h.storeEmbeddedCover(ctx, audio, ext)
+	if err != nil {
+		logger.Info("未能从歌曲中提取专辑封面，改为从外部来源获取",
+			logger.Int64("albumId", album.ID),
+			logger.ErrorField(err))
+		h.coverFetcher.Enqueue(cover.Job{
+			Kind:  cover.KindAlbum,
+			ID:    album.ID,
+			Query: cover.Query{Artist: album.Artist, Album: album.Name},
+		})
+		return
+	}
+
+	set, err := h.albumRepo.SetAlbumCoverIfEmpty(ctx, album.ID, coverPath)
+	if err != nil {
+		logger.Error("设置专辑封面失败",
+			logger.Int64("albumId", album.ID),
+			logger.ErrorField(err))
+	}
+	if !set {
+		// 提取期间专辑已有了封面，未被引用的提取结果直接删除
+		h.ReleaseCover(ctx, coverPath)
+		return
+	}
+	logger.Info("已使用歌曲内嵌封面作为专辑封面",
+		logger.Int64("albumId", album.ID),
+		logger.String("path", coverPath))
+}
+
+// storeEmbeddedCover 提取音频的内嵌封面，生成各尺寸变体并上传，返回最大的 WebP 变体路径
+func (h *APIHandler) storeEmbeddedCover(ctx context.Context, audio []byte, ext string) (string, error) {
+	tempFile, err := os.CreateTemp("", "album-cover-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(audio)
+	tempFile.Close()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := cover.ExtractEmbeddedCover(ctx, h.cfg.FFmpegPath, tempFile.Name())
+	if err != nil {
+		return "", err
+	}
+	processed, err := cover.ProcessCover(ctx, h.cfg.FFmpegPath, data)
+	if err != nil {
+		return "", err
+	}
+
+	var coverPath string
+	for _, v := range processed.Variants {
+		objectPath := processed.ObjectPath(v)
+		if err := h.uploadBytesToStorage(v.Data, objectPath, v.ContentType); err != nil {
+			return "", fmt.Errorf("上传封面变体失败: %w", err)
+		}
+		if v.Format == "webp" {
+			coverPath = "/static/" + objectPath
+		}
+	}
+	if coverPath == "" {
+		return "", fmt.Errorf("没有生成 WebP 封面")
+	}
+	return coverPath, nil
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// albumDownloadTimeout 专辑打包下载的最长写入时间，覆盖服务器默认的 WriteTimeout
+const albumDownloadTimeout = 30 * time.Minute
+
+// zipNameReplacer 替换压缩包内文件名中不允许或易混淆的字符
+var zipNameReplacer = strings.NewReplacer(
+	"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_",
+	"\"", "'", "<", "_", ">", "_", "|", "_",
+)
+
+// DownloadAlbumHandler 将专辑中曲目的原始音频和封面打包为 ZIP 流式返回
+// 每个文件边从对象存储读取边写入响应，服务器不缓存整个压缩包
+func (h *APIHandler) DownloadAlbumHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	albumID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid album ID")
+		return
+	}
+
+	album, err := h.albumRepo.GetAlbumByID(r.Context(), albumID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取专辑失败", logger.Int64("albumId", albumID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get album")
+		return
+	}
+	if album == nil || album.UserID != userID {
+		writeError(w, CodeAlbumNotFound, "Album not found")
+		return
+	}
+
+	tracks, err := h.albumRepo.GetAlbumTracks(r.Context(), albumID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取专辑歌曲失败", logger.Int64("albumId", albumID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get album tracks")
+		return
+	}
+	downloadable := make([]*model.Track, 0, len(tracks))
+	for _, t := range tracks {
+		if t.State == 1 && strings.HasPrefix(t.FilePath, "/static/") {
+			downloadable = append(downloadable, t)
+		}
+	}
+	if len(downloadable) == 0 {
+		writeError(w, CodeNotFound, "Album has no downloadable tracks")
+		return
+	}
+
+	store := storage.GetStorage()
+	if store == nil {
+		writeError(w, CodeStorageUnavailable, "Storage not available")
+		return
+	}
+
+	// 大专辑的下载时间可能超过服务器的 WriteTimeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(albumDownloadTimeout)); err != nil {
+		logger.Ctx(r.Context()).Debug("无法延长写入超时", logger.ErrorField(err))
+	}
+
+	archiveName := zipEntryName(album.Name)
+	if album.Artist != "" {
+		archiveName = zipEntryName(album.Artist) + " - " + archiveName
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archiveName + ".zip"}))
+	w.Header().Set("Cache-Control", "no-store")
+
+	zw := zip.NewWriter(w)
+	zw.SetComment(fmt.Sprintf("%s - %s", album.Artist, album.Name))
+
+	if strings.HasPrefix(album.CoverPath, "/static/") {
+		coverName := "cover" + strings.ToLower(path.Ext(album.CoverPath))
+		if err := writeZipEntry(r.Context(), zw, store, strings.TrimPrefix(album.CoverPath, "/static/"), coverName); err != nil && !storage.IsNotFound(err) {
+			logger.Ctx(r.Context()).Warn("打包专辑封面失败", logger.Int64("albumId", albumID), logger.ErrorField(err))
+		}
+	}
+
+	written := 0
+	for i, t := range downloadable {
+		name := fmt.Sprintf("%02d - %s%s", i+1, zipTrackTitle(t, album), strings.ToLower(path.Ext(t.FilePath)))
+		err := writeZipEntry(r.Context(), zw, store, strings.TrimPrefix(t.FilePath, "/static/"), name)
+		if err == nil {
+			written++
+			continue
+		}
+		if storage.IsNotFound(err) {
+			logger.Ctx(r.Context()).Warn("专辑歌曲源文件不存在，跳过",
+				logger.Int64("trackId", t.ID),
+				logger.String("path", t.FilePath))
+			continue
+		}
+		// 响应已开始写入，无法再返回错误，中止后客户端会得到不完整的压缩包
+		logger.Ctx(r.Context()).Error("打包专辑歌曲失败，中止下载",
+			logger.Int64("albumId", albumID),
+			logger.Int64("trackId", t.ID),
+			logger.ErrorField(err))
+		return
+	}
+
+	if err := zw.Close(); err != nil {
+		logger.Ctx(r.Context()).Warn("结束专辑压缩包失败", logger.Int64("albumId", albumID), logger.ErrorField(err))
+		return
+	}
+	logger.Ctx(r.Context()).Info("专辑打包下载完成",
+		logger.Int64("albumId", albumID),
+		logger.Int("tracks", written))
+}
+
+// writeZipEntry 将对象存储中的对象写入压缩包，音频和图片本身已压缩，直接存储不再压缩
+func writeZipEntry(ctx context.Context, zw *zip.Writer, store storage.Storage, key, name string) error {
+	reader, err := store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	entry, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, reader)
+	return err
+}
+
+// zipTrackTitle 压缩包内的歌曲文件名，歌手与专辑歌手不同时带上歌手
+func zipTrackTitle(t *model.Track, album *model.Album) string {
+	title := zipEntryName(t.Title)
+	if t.Artist != "" && t.Artist != album.Artist {
+		title = zipEntryName(t.Artist) + " - " + title
+	}
+	return title
+}
+
+// zipEntryName 清理压缩包内的文件名，保留中文等非 ASCII 字符
+func zipEntryName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(zipNameReplacer.Replace(name))
+	name = strings.Trim(name, ".")
+	if name == "" {
+		return "Untitled"
+	}
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	return name
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"Bt1QFM/core/musicbrainz"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// enrichCandidateLimit 按专辑名搜索时返回的候选发行版数量
+	enrichCandidateLimit = 5
+	// enrichMinScore 自动选用搜索结果的最低匹配度
+	enrichMinScore = 80
+	// releaseDateLayout 建议和提交的发行日期格式
+	releaseDateLayout = "2006-01-02"
+)
+
+// albumEnrichment 专辑元数据补全的内容，建议接口返回后由用户确认或修改，再原样提交到应用接口
+type albumEnrichment struct {
+	ReleaseID   string                      `json:"releaseId"`
+	ReleaseDate string                      `json:"releaseDate"` // YYYY-MM-DD，也接受 YYYY 或 YYYY-MM
+	Label       string                      `json:"label"`
+	Tracks      []model.AlbumTrackPlacement `json:"tracks"`
+}
+
+// enrichmentTrack 专辑中一首曲目的匹配结果
+type enrichmentTrack struct {
+	musicbrainz.TrackMatch
+	Title              string `json:"title"`
+	CurrentDiscNumber  int    `json:"currentDiscNumber"`
+	CurrentTrackNumber int    `json:"currentTrackNumber"`
+	CurrentPosition    int    `json:"currentPosition"` // 当前在专辑中的顺序，从 1 开始
+}
+
+// SetMusicBrainzClient 设置补全专辑元数据使用的 MusicBrainz 客户端
+func (h *APIHandler) SetMusicBrainzClient(client *musicbrainz.Client) {
+	h.musicbrainz = client
+}
+
+// GetAlbumEnrichmentHandler 从 MusicBrainz 查询专辑的发行日期、厂牌、曲目顺序和碟号，返回建议的修改，不修改数据
+// 未指定 releaseId 时按艺术家和专辑名搜索并选用匹配度最高的发行版，其余候选一并返回供用户改选
+func (h *APIHandler) GetAlbumEnrichmentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	album, ok := h.loadOwnedAlbum(w, r, userID)
+	if !ok {
+		return
+	}
+
+	releaseID := r.URL.Query().Get("releaseId")
+	candidates := []musicbrainz.ReleaseSummary{}
+	if releaseID == "" {
+		candidates, err = h.musicbrainz.SearchReleases(r.Context(), album.Artist, album.Name, enrichCandidateLimit)
+		if err != nil {
+			logger.Ctx(r.Context()).Warn("搜索 MusicBrainz 发行版失败", logger.Int64("albumId", album.ID), logger.ErrorField(err))
+			writeError(w, CodeMetadataUnavailable, "MusicBrainz is temporarily unavailable")
+			return
+		}
+		if len(candidates) == 0 || candidates[0].Score < enrichMinScore {
+			writeError(w, CodeNotFound, "No matching release found on MusicBrainz")
+			return
+		}
+		releaseID = candidates[0].ID
+	}
+
+	release, err := h.musicbrainz.GetRelease(r.Context(), releaseID)
+	if err != nil {
+		if errors.Is(err, musicbrainz.ErrNotFound) {
+			writeError(w, CodeNotFound, "Release not found on MusicBrainz")
+			return
+		}
+		logger.Ctx(r.Context()).Warn("获取 MusicBrainz 发行版失败", logger.String("releaseId", releaseID), logger.ErrorField(err))
+		writeError(w, CodeMetadataUnavailable, "MusicBrainz is temporarily unavailable")
+		return
+	}
+
+	tracks, err := h.albumRepo.GetAlbumTracks(r.Context(), album.ID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取专辑歌曲失败", logger.Int64("albumId", album.ID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get album tracks")
+		return
+	}
+	local := make([]musicbrainz.LocalTrack, len(tracks))
+	for i, t := range tracks {
+		local[i] = musicbrainz.LocalTrack{ID: t.ID, Title: t.Title}
+	}
+	matches := musicbrainz.MatchTracks(local, release)
+
+	current := albumEnrichment{
+		ReleaseID: album.MusicBrainzID,
+		Label:     album.Label,
+		Tracks:    make([]model.AlbumTrackPlacement, len(tracks)),
+	}
+	if !album.ReleaseTime.IsZero() {
+		current.ReleaseDate = album.ReleaseTime.Format(releaseDateLayout)
+	}
+	proposed := albumEnrichment{
+		ReleaseID:   release.ID,
+		ReleaseDate: current.ReleaseDate,
+		Label:       current.Label,
+		Tracks:      make([]model.AlbumTrackPlacement, len(tracks)),
+	}
+	if date, err := musicbrainz.ParseDate(release.Date); err == nil {
+		proposed.ReleaseDate = date.Format(releaseDateLayout)
+	}
+	if release.Label != "" {
+		proposed.Label = release.Label
+	}
+
+	// 未匹配的曲目放在最后一张碟，不设曲号，按原有的相对顺序排在有曲号的曲目之后
+	lastDisc := 1
+	for _, m := range matches {
+		if m.Matched && m.DiscNumber > lastDisc {
+			lastDisc = m.DiscNumber
+		}
+	}
+	matched := 0
+	items := make([]enrichmentTrack, len(tracks))
+	for i, t := range tracks {
+		current.Tracks[i] = model.AlbumTrackPlacement{TrackID: t.ID, DiscNumber: t.DiscNumber, TrackNumber: t.TrackNumber, Position: i + 1}
+		placement := model.AlbumTrackPlacement{TrackID: t.ID, DiscNumber: lastDisc, Position: i + 1}
+		if matches[i].Matched {
+			matched++
+			placement.DiscNumber, placement.TrackNumber = matches[i].DiscNumber, matches[i].Position
+		}
+		proposed.Tracks[i] = placement
+		items[i] = enrichmentTrack{
+			TrackMatch:         matches[i],
+			Title:              t.Title,
+			CurrentDiscNumber:  t.DiscNumber,
+			CurrentTrackNumber: t.TrackNumber,
+			CurrentPosition:    i + 1,
+		}
+	}
+	model.SortAlbumTracks(proposed.Tracks)
+
+	logger.Ctx(r.Context()).Info("生成专辑元数据补全建议",
+		logger.Int64("albumId", album.ID),
+		logger.String("releaseId", release.ID),
+		logger.Int("tracks", len(tracks)),
+		logger.Int("matched", matched))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"release":    release,
+			"candidates": candidates,
+			"current":    current,
+			"proposed":   proposed,
+			"tracks":     items,
+			"matched":    matched,
+		},
+	})
+}
+
+// ApplyAlbumEnrichmentHandler 应用用户确认（可修改）后的补全结果，专辑和曲目的修改在同一事务中完成
+// 只调整 tracks 中列出的曲目，未列出的曲目保持不变
+func (h *APIHandler) ApplyAlbumEnrichmentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	album, ok := h.loadOwnedAlbum(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var req albumEnrichment
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if utf8.RuneCountInString(req.Label) > model.MaxTrackFieldLength {
+		writeError(w, CodeBadRequest, "Label is too long")
+		return
+	}
+	if len(req.ReleaseID) > 36 {
+		writeError(w, CodeBadRequest, "Invalid MusicBrainz release ID")
+		return
+	}
+	if req.ReleaseDate != "" {
+		date, err := musicbrainz.ParseDate(req.ReleaseDate)
+		if err != nil {
+			writeError(w, CodeBadRequest, "Invalid release date")
+			return
+		}
+		album.ReleaseTime = date
+	}
+	album.Label = req.Label
+	album.MusicBrainzID = req.ReleaseID
+
+	tracks, err := h.albumRepo.GetAlbumTracks(r.Context(), album.ID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取专辑歌曲失败", logger.Int64("albumId", album.ID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get album tracks")
+		return
+	}
+	inAlbum := make(map[int64]bool, len(tracks))
+	for _, t := range tracks {
+		inAlbum[t.ID] = true
+	}
+	seen := make(map[int64]bool, len(req.Tracks))
+	for _, p := range req.Tracks {
+		if !inAlbum[p.TrackID] {
+			writeError(w, CodeBadRequest, "Track is not in this album")
+			return
+		}
+		if seen[p.TrackID] || p.DiscNumber < 1 || p.TrackNumber < 0 || p.Position < 1 {
+			writeError(w, CodeBadRequest, "Invalid track placement")
+			return
+		}
+		seen[p.TrackID] = true
+	}
+
+	if err := h.albumRepo.ApplyAlbumEnrichment(r.Context(), album, req.Tracks); err != nil {
+		logger.Ctx(r.Context()).Error("应用专辑元数据补全失败", logger.Int64("albumId", album.ID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to apply album enrichment")
+		return
+	}
+	tracks, err = h.albumRepo.GetAlbumTracks(r.Context(), album.ID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取专辑歌曲失败", logger.Int64("albumId", album.ID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get album tracks")
+		return
+	}
+
+	logger.Ctx(r.Context()).Info("专辑元数据已补全",
+		logger.Int64("albumId", album.ID),
+		logger.String("releaseId", album.MusicBrainzID),
+		logger.Int("tracks", len(req.Tracks)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": model.AlbumWithTracks{
+			Album:  *album,
+			Tracks: tracks,
+		},
+	})
+}
+
+// loadOwnedAlbum 解析路径中的专辑ID并加载当前用户的专辑，失败时已写入响应
+func (h *APIHandler) loadOwnedAlbum(w http.ResponseWriter, r *http.Request, userID int64) (*model.Album, bool) {
+	albumID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid album ID")
+		return nil, false
+	}
+	album, err := h.albumRepo.GetAlbumByID(r.Context(), albumID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取专辑失败", logger.Int64("albumId", albumID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get album")
+		return nil, false
+	}
+	if album == nil || album.UserID != userID {
+		writeError(w, CodeAlbumNotFound, "Album not found")
+		return nil, false
+	}
+	return album, true
+}
@@ -8,7 +8,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -16,6 +15,8 @@ import (
 	"strings"
 	"time"
 
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/cover"
 	"Bt1QFM/logger"
 	"context"
 
@@ -32,7 +33,7 @@ func (h *APIHandler) UploadTracksToAlbumHandler(w http.ResponseWriter, r *http.R
 	// 解析multipart表单
 	err := r.ParseMultipartForm(32 << 20) // 32MB
 	if err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "Failed to parse form")
 		return
 	}
 
@@ -40,49 +41,133 @@ func (h *APIHandler) UploadTracksToAlbumHandler(w http.ResponseWriter, r *http.R
 	albumIDStr := r.FormValue("albumId")
 	albumID, err := strconv.ParseInt(albumIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid album ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid album ID")
 		return
 	}
 
 	// 验证专辑所有权
 	album, err := h.albumRepo.GetAlbumByID(r.Context(), albumID)
 	if err != nil {
-		http.Error(w, "Failed to get album", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to get album")
 		return
 	}
 	if album == nil || album.UserID != userID {
-		http.Error(w, "Album not found or unauthorized", http.StatusForbidden)
+		writeError(w, CodeForbidden, "Album not found or unauthorized")
 		return
 	}
 
 	// 获取上传的文件
 	files := r.MultipartForm.File["files"]
 	if len(files) == 0 {
-		http.Error(w, "No files uploaded", http.StatusBadRequest)
+		writeError(w, CodeMissingField, "No files uploaded")
 		return
 	}
 
-	var trackIDs []int64
+	// 整批文件需一次放进剩余配额，避免只上传一部分
+	var totalSize int64
 	for _, fileHeader := range files {
+		totalSize += fileHeader.Size
+	}
+	if !h.checkStorageQuota(w, r, userID, totalSize) {
+		return
+	}
+
+	// 创建任何曲目记录之前逐个校验文件内容并检测重复上传，与单曲上传使用相同的检查
+	fingerprints := make([]*audio.Fingerprint, len(files))
+	var duplicates []map[string]interface{}
+	for i, fileHeader := range files {
+		file, err := fileHeader.Open()
+		if err != nil {
+			writeError(w, CodeInternal, "Failed to open file")
+			return
+		}
+		valid := h.validateUploadedAudio(w, r, file, fileHeader.Filename, fileHeader.Header.Get("Content-Type"))
+		if !valid {
+			file.Close()
+			return
+		}
+		// fpcalc 不可用时跳过重复检测
+		fingerprint, err := h.computeFingerprintFromReader(file, filepath.Ext(fileHeader.Filename))
+		file.Close()
+		if err != nil {
+			logger.Warn("计算音频指纹失败，跳过重复检测", logger.String("filename", fileHeader.Filename), logger.ErrorField(err))
+			continue
+		}
+		fingerprints[i] = fingerprint
+		duplicateOf, err := h.findDuplicateTracks(userID, fingerprint)
+		if err != nil {
+			logger.Warn("重复检测失败", logger.ErrorField(err))
+			continue
+		}
+		if len(duplicateOf) == 0 {
+			continue
+		}
+		logger.Warn("检测到重复上传",
+			logger.Int64("userId", userID),
+			logger.String("filename", fileHeader.Filename),
+			logger.Any("duplicateOf", duplicateOf))
+		if h.cfg.BlockDuplicateUploads {
+			writeErrorDetails(w, CodeDuplicateTrack, "Duplicate track: this audio already exists in your library", map[string]interface{}{
+				"file":        fileHeader.Filename,
+				"duplicateOf": duplicateOf,
+			})
+			return
+		}
+		duplicates = append(duplicates, map[string]interface{}{
+			"file":        fileHeader.Filename,
+			"duplicateOf": duplicateOf,
+		})
+	}
+
+	// 全部曲目记录创建并加入专辑后才开始上传和转码，中途失败时删除本批已创建的记录
+	type albumUploadJob struct {
+		trackID     int64
+		fileBuffer  *bytes.Buffer
+		upload      *sharedUpload
+		fingerprint *audio.Fingerprint
+	}
+	var trackIDs []int64
+	var placements []model.AlbumTrackPlacement
+	var jobs []albumUploadJob
+	rollback := func() {
+		h.deleteTrackRecords(context.Background(), trackIDs)
+	}
+
+	// 合辑可以按文件顺序在 artists 字段中提供每首歌曲的艺术家
+	formArtists := r.MultipartForm.Value["artists"]
+	for i, fileHeader := range files {
 		// 打开文件
 		file, err := fileHeader.Open()
 		if err != nil {
-			http.Error(w, "Failed to open file", http.StatusInternalServerError)
+			rollback()
+			writeError(w, CodeInternal, "Failed to open file")
 			return
 		}
 		defer file.Close()
 
-		// 提取原始文件名（去掉扩展名）作为title
+		// 提取原始文件名（去掉扩展名）作为title，"1-01 标题"、"01 标题" 形式的编号解析为碟号和曲号
 		originalName := strings.TrimSuffix(fileHeader.Filename, filepath.Ext(fileHeader.Filename))
+		discNumber, trackNumber, title := model.ParseTrackFilename(originalName)
+
+		// 合辑保留每首歌曲自己的艺术家，普通专辑统一使用专辑艺术家
+		artist := album.Artist
+		if album.IsCompilation {
+			var formArtist string
+			if i < len(formArtists) {
+				formArtist = formArtists[i]
+			}
+			artist, title = h.compilationTrackArtist(file, filepath.Ext(fileHeader.Filename), formArtist, title, album.Artist)
+		}
 
 		// 创建新的track记录
 		track := &model.Track{
-			UserID: userID,
-			Title:  originalName, // 使用原始文件名作为标题
-			Artist: album.Artist,
-			Album:  album.Name,
-			Status: "processing", // 添加状态字段
-			Source: "album",      // 标记来源为专辑
+			UserID:   userID,
+			Title:    title, // 使用去掉编号的文件名作为标题
+			Artist:   artist,
+			Album:    album.Name,
+			Status:   "processing", // 添加状态字段
+			Source:   "album",      // 标记来源为专辑
+			FileSize: fileHeader.Size,
 		}
 
 		// 生成安全的文件名（与UploadTrackHandler保持完全一致）
@@ -91,12 +176,47 @@ func (h *APIHandler) UploadTracksToAlbumHandler(w http.ResponseWriter, r *http.R
 			fileExt = ".mp3" // 默认扩展名
 		}
 
+		// 按内容哈希存储，相同内容的曲目共享源音频和 HLS 输出
+		contentHash, err := hashContent(file)
+		if err != nil {
+			rollback()
+			writeError(w, CodeInternal, "Failed to read file")
+			return
+		}
+		transcodeOpts := h.loadTranscodeOptions(r, userID)
+		streamID := contentStreamID(contentHash, transcodeOpts)
+		shared, err := h.findSharedStorage(r.Context(), contentHash, streamID)
+		if err != nil {
+			logger.Warn("查询共享存储失败，按新文件处理", logger.ErrorField(err))
+			shared = &sharedStorage{}
+		}
+		track.ContentHash = contentHash
+		track.FilePath = "/static/audio/" + contentHash + fileExt
+		if shared.FilePath != "" {
+			track.FilePath = shared.FilePath
+		}
+		if shared.Stream != nil {
+			track.HLSPlaylistPath = shared.Stream.HLSPlaylistPath
+			track.Duration = shared.Stream.Duration
+		}
+		upload := &sharedUpload{
+			minioPath:    strings.TrimPrefix(track.FilePath, "/static/"),
+			contentType:  fileHeader.Header.Get("Content-Type"),
+			uploadSource: shared.FilePath == "",
+			transcode:    shared.Stream == nil,
+			streamID:     streamID,
+			opts:         transcodeOpts,
+		}
+
 		// 保存track到数据库
-		trackID, err := h.trackRepo.CreateTrack(track)
+		trackID, err := h.trackRepo.CreateTrack(r.Context(), track)
 		if err != nil {
-			http.Error(w, "Failed to save track", http.StatusInternalServerError)
+			rollback()
+			writeError(w, CodeInternal, "Failed to save track")
 			return
 		}
+		trackIDs = append(trackIDs, trackID)
+		placements = append(placements, model.AlbumTrackPlacement{TrackID: trackID, DiscNumber: discNumber, TrackNumber: trackNumber})
 
 		// 将文件内容读取到缓冲区，避免文件关闭后无法读取
 		fileBuffer := &bytes.Buffer{}
@@ -104,44 +224,99 @@ func (h *APIHandler) UploadTracksToAlbumHandler(w http.ResponseWriter, r *http.R
 			logger.Error("读取文件到缓冲区失败",
 				logger.ErrorField(err),
 				logger.Int64("trackId", trackID))
-			h.trackRepo.UpdateTrackStatus(trackID, "failed")
-			continue
+			rollback()
+			writeError(w, CodeInternal, "Failed to read file")
+			return
 		}
+		jobs = append(jobs, albumUploadJob{trackID: trackID, fileBuffer: fileBuffer, upload: upload, fingerprint: fingerprints[i]})
+	}
+
+	// 将tracks添加到专辑，碟号和曲号决定多碟专辑的显示顺序
+	err = h.albumRepo.AddPlacedTracksToAlbum(r.Context(), albumID, placements)
+	if err != nil {
+		rollback()
+		writeError(w, CodeInternal, "Failed to add tracks to album")
+		return
+	}
+
+	// 专辑还没有封面时从第一首歌曲中提取内嵌封面，缓冲区会被异步处理读走，先复制一份
+	if album.CoverPath == "" {
+		firstAudio := append([]byte(nil), jobs[0].fileBuffer.Bytes()...)
+		go h.extractAlbumCover(album, firstAudio, filepath.Ext(files[0].Filename))
+	}
 
+	for _, job := range jobs {
 		// 启动异步处理
-		go func(trackID int64, fileBuffer *bytes.Buffer, fileHeader *multipart.FileHeader, fileExt string, originalName string) {
+		go func(trackID int64, fileBuffer *bytes.Buffer, upload *sharedUpload, fingerprint *audio.Fingerprint) {
 			// 处理音频文件流处理
-			if err := h.processTrackStreamAsync(trackID, fileBuffer, fileHeader, fileExt, originalName); err != nil {
+			if err := h.processTrackStreamAsync(userID, trackID, fileBuffer, upload, fingerprint); err != nil {
 				logger.Error("异步流处理失败",
 					logger.ErrorField(err),
 					logger.Int64("trackId", trackID))
 				// 更新track状态为失败
-				h.trackRepo.UpdateTrackStatus(trackID, "failed")
+				h.trackRepo.UpdateTrackStatus(context.Background(), trackID, "failed")
+				return
+			}
+			// 交给转码 worker 时由 worker 回写状态
+			if upload.transcode && h.dispatchesToWorker() {
 				return
 			}
 			// 更新track状态为完成
-			h.trackRepo.UpdateTrackStatus(trackID, "completed")
-		}(trackID, fileBuffer, fileHeader, fileExt, originalName)
-
-		trackIDs = append(trackIDs, trackID)
-	}
-
-	// 将tracks添加到专辑
-	err = h.albumRepo.AddTracksToAlbum(r.Context(), albumID, trackIDs)
-	if err != nil {
-		http.Error(w, "Failed to add tracks to album", http.StatusInternalServerError)
-		return
+			h.trackRepo.UpdateTrackStatus(context.Background(), trackID, "completed")
+		}(job.trackID, job.fileBuffer, job.upload, job.fingerprint)
 	}
 
 	// 返回成功响应
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	resp := map[string]interface{}{
 		"message": "Tracks uploaded successfully",
 		"count":   len(trackIDs),
-	})
+	}
+	if len(duplicates) > 0 {
+		resp["duplicates"] = duplicates
+		resp["warning"] = "Some files appear to duplicate existing tracks in your library"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+
+
+// compilationTrackArtist 确定合辑中一首歌曲的艺术家，依次使用表单提供的艺术家、音频文件的艺术家标签、
+// "艺术家 - 标题" 形式的文件名，都没有时使用专辑艺术家；返回艺术家和去掉艺术家前缀后的标题
+func (h *APIHandler) compilationTrackArtist(file io.ReadSeeker, ext, formArtist, title, albumArtist string) (string, string) {
+	if artist := strings.TrimSpace(formArtist); artist != "" {
+		return truncateRunes(artist, model.MaxTrackFieldLength), title
+	}
+	tags, err := h.readAudioTags(file, ext)
+	if err != nil {
+		logger.Debug("读取合辑歌曲的音频标签失败", logger.String("title", title), logger.ErrorField(err))
+	} else if tags.Artist != "" {
+		return truncateRunes(tags.Artist, model.MaxTrackFieldLength), title
+	}
+	if artist, rest, ok := strings.Cut(title, " - "); ok && strings.TrimSpace(artist) != "" && strings.TrimSpace(rest) != "" {
+		return truncateRunes(strings.TrimSpace(artist), model.MaxTrackFieldLength), strings.TrimSpace(rest)
+	}
+	return albumArtist, title
 }
 
+// readAudioTags 把上传的文件写入临时文件后读取音频标签，读取后将文件重置到开头
+func (h *APIHandler) readAudioTags(file io.ReadSeeker, ext string) (*audio.AudioTags, error) {
+	tempFile, err := os.CreateTemp("", "tags-*"+ext)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tempFile.Name())
 
+	_, err = io.Copy(tempFile, file)
+	tempFile.Close()
+	if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil {
+		return nil, seekErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.audioProcessor.GetAudioTags(tempFile.Name())
+}
 
 // generateSafeFilename 生成安全的文件名
 func generateSafeFilename(originalName string) string {
@@ -180,7 +355,7 @@ func (h *APIHandler) GetUserAlbumsHandler(w http.ResponseWriter, r *http.Request
 		logger.Warn("Invalid method for get user albums",
 			logger.String("method", r.Method),
 		)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -189,7 +364,7 @@ func (h *APIHandler) GetUserAlbumsHandler(w http.ResponseWriter, r *http.Request
 		logger.Error("Failed to get user ID from context",
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -201,7 +376,7 @@ func (h *APIHandler) GetUserAlbumsHandler(w http.ResponseWriter, r *http.Request
 			logger.Int64("userId", userID),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Failed to get albums", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to get albums")
 		return
 	}
 
@@ -210,8 +385,7 @@ func (h *APIHandler) GetUserAlbumsHandler(w http.ResponseWriter, r *http.Request
 		logger.Int("count", len(albums)),
 	)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(albums)
+	writeJSONWithETag(w, r, albums)
 }
 
 // CreateAlbumHandler 创建新专辑
@@ -225,7 +399,7 @@ func (h *APIHandler) CreateAlbumHandler(w http.ResponseWriter, r *http.Request)
 		logger.Warn("Invalid method for create album",
 			logger.String("method", r.Method),
 		)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -249,6 +423,7 @@ func (h *APIHandler) CreateAlbumHandler(w http.ResponseWriter, r *http.Request)
 			ReleaseTime string `json:"releaseTime"`
 			Genre       string `json:"genre"`
 			Description string `json:"description"`
+			IsCompilation bool `json:"isCompilation"`
 		}
 		var input albumInput
 		if err2 := json.NewDecoder(r.Body).Decode(&input); err2 == nil {
@@ -259,7 +434,7 @@ func (h *APIHandler) CreateAlbumHandler(w http.ResponseWriter, r *http.Request)
 					logger.String("releaseTime", input.ReleaseTime),
 					logger.ErrorField(timeErr),
 				)
-				http.Error(w, "Invalid releaseTime format", http.StatusBadRequest)
+				writeError(w, CodeBadRequest, "Invalid releaseTime format")
 				return
 			}
 			album.Artist = input.Artist
@@ -268,11 +443,12 @@ func (h *APIHandler) CreateAlbumHandler(w http.ResponseWriter, r *http.Request)
 			album.ReleaseTime = parsedTime
 			album.Genre = input.Genre
 			album.Description = sql.NullString{String: input.Description, Valid: input.Description != ""}
+			album.IsCompilation = input.IsCompilation
 		} else {
 			logger.Error("Failed to decode albumInput struct",
 				logger.ErrorField(err2),
 			)
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, CodeInvalidBody, "Invalid request body")
 			return
 		}
 	} else {
@@ -284,10 +460,13 @@ func (h *APIHandler) CreateAlbumHandler(w http.ResponseWriter, r *http.Request)
 		logger.Error("Failed to get user ID from context",
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 	album.UserID = userID
+	if album.IsCompilation && strings.TrimSpace(album.Artist) == "" {
+		album.Artist = model.VariousArtists
+	}
 
 	logger.Debug("Creating new album",
 		logger.Int64("userId", userID),
@@ -303,7 +482,7 @@ func (h *APIHandler) CreateAlbumHandler(w http.ResponseWriter, r *http.Request)
 			logger.String("name", album.Name),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Failed to create album", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to create album")
 		return
 	}
 
@@ -314,6 +493,15 @@ func (h *APIHandler) CreateAlbumHandler(w http.ResponseWriter, r *http.Request)
 		logger.String("name", album.Name),
 	)
 
+	// 未设置封面时后台从外部来源获取
+	if album.CoverPath == "" {
+		h.coverFetcher.Enqueue(cover.Job{
+			Kind:  cover.KindAlbum,
+			ID:    id,
+			Query: cover.Query{Artist: album.Artist, Album: album.Name},
+		})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(album)
@@ -330,7 +518,7 @@ func (h *APIHandler) GetAlbumHandler(w http.ResponseWriter, r *http.Request) {
 		logger.Warn("Invalid method for get album",
 			logger.String("method", r.Method),
 		)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -341,7 +529,7 @@ func (h *APIHandler) GetAlbumHandler(w http.ResponseWriter, r *http.Request) {
 			logger.String("id", vars["id"]),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid album ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid album ID")
 		return
 	}
 
@@ -353,13 +541,13 @@ func (h *APIHandler) GetAlbumHandler(w http.ResponseWriter, r *http.Request) {
 			logger.Int64("albumId", albumID),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Failed to get album", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to get album")
 		return
 	}
 
 	if album == nil {
 		logger.Warn("Album not found", logger.Int64("albumId", albumID))
-		http.Error(w, "Album not found", http.StatusNotFound)
+		writeError(w, CodeAlbumNotFound, "Album not found")
 		return
 	}
 
@@ -384,7 +572,7 @@ func (h *APIHandler) UpdateAlbumHandler(w http.ResponseWriter, r *http.Request)
 		logger.Warn("Invalid method for update album",
 			logger.String("method", r.Method),
 		)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -395,7 +583,7 @@ func (h *APIHandler) UpdateAlbumHandler(w http.ResponseWriter, r *http.Request)
 			logger.String("id", vars["id"]),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid album ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid album ID")
 		return
 	}
 
@@ -404,7 +592,7 @@ func (h *APIHandler) UpdateAlbumHandler(w http.ResponseWriter, r *http.Request)
 		logger.Error("Failed to decode album data",
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "Invalid request body")
 		return
 	}
 
@@ -413,11 +601,14 @@ func (h *APIHandler) UpdateAlbumHandler(w http.ResponseWriter, r *http.Request)
 		logger.Error("Failed to get user ID from context",
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 	album.ID = albumID
 	album.UserID = userID
+	if album.IsCompilation && strings.TrimSpace(album.Artist) == "" {
+		album.Artist = model.VariousArtists
+	}
 
 	logger.Debug("Updating album",
 		logger.Int64("albumId", albumID),
@@ -432,7 +623,7 @@ func (h *APIHandler) UpdateAlbumHandler(w http.ResponseWriter, r *http.Request)
 			logger.String("name", album.Name),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Failed to update album", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to update album")
 		return
 	}
 
@@ -446,7 +637,7 @@ func (h *APIHandler) UpdateAlbumHandler(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(album)
 }
 
-// DeleteAlbumHandler 删除专辑
+// DeleteAlbumHandler 删除专辑（移入回收站）
 func (h *APIHandler) DeleteAlbumHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Debug("Handling delete album request",
 		logger.String("method", r.Method),
@@ -457,7 +648,7 @@ func (h *APIHandler) DeleteAlbumHandler(w http.ResponseWriter, r *http.Request)
 		logger.Warn("Invalid method for delete album",
 			logger.String("method", r.Method),
 		)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -468,7 +659,26 @@ func (h *APIHandler) DeleteAlbumHandler(w http.ResponseWriter, r *http.Request)
 			logger.String("id", vars["id"]),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid album ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid album ID")
+		return
+	}
+
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	album, err := h.albumRepo.GetAlbumByID(r.Context(), albumID)
+	if err != nil {
+		logger.Error("Failed to get album",
+			logger.Int64("albumId", albumID),
+			logger.ErrorField(err),
+		)
+		writeError(w, CodeInternal, "Failed to get album")
+		return
+	}
+	if album == nil || album.UserID != userID {
+		writeError(w, CodeAlbumNotFound, "Album not found")
 		return
 	}
 
@@ -479,11 +689,11 @@ func (h *APIHandler) DeleteAlbumHandler(w http.ResponseWriter, r *http.Request)
 			logger.Int64("albumId", albumID),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Failed to delete album", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to delete album")
 		return
 	}
 
-	logger.Info("Album deleted successfully", logger.Int64("albumId", albumID))
+	logger.Info("Album moved to trash", logger.Int64("albumId", albumID))
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -498,7 +708,7 @@ func (h *APIHandler) AddTrackToAlbumHandler(w http.ResponseWriter, r *http.Reque
 		logger.Warn("Invalid method for add track to album",
 			logger.String("method", r.Method),
 		)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -509,7 +719,7 @@ func (h *APIHandler) AddTrackToAlbumHandler(w http.ResponseWriter, r *http.Reque
 			logger.String("id", vars["id"]),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid album ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid album ID")
 		return
 	}
 
@@ -522,7 +732,7 @@ func (h *APIHandler) AddTrackToAlbumHandler(w http.ResponseWriter, r *http.Reque
 		logger.Error("Failed to decode request body",
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "Invalid request body")
 		return
 	}
 
@@ -538,7 +748,7 @@ func (h *APIHandler) AddTrackToAlbumHandler(w http.ResponseWriter, r *http.Reque
 			logger.Int64("trackId", req.TrackID),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Failed to add track to album", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to add track to album")
 		return
 	}
 
@@ -561,7 +771,7 @@ func (h *APIHandler) RemoveTrackFromAlbumHandler(w http.ResponseWriter, r *http.
 		logger.Warn("Invalid method for remove track from album",
 			logger.String("method", r.Method),
 		)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -572,7 +782,7 @@ func (h *APIHandler) RemoveTrackFromAlbumHandler(w http.ResponseWriter, r *http.
 			logger.String("id", vars["id"]),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid album ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid album ID")
 		return
 	}
 
@@ -582,7 +792,7 @@ func (h *APIHandler) RemoveTrackFromAlbumHandler(w http.ResponseWriter, r *http.
 			logger.String("id", vars["track_id"]),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid track ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid track ID")
 		return
 	}
 
@@ -597,7 +807,7 @@ func (h *APIHandler) RemoveTrackFromAlbumHandler(w http.ResponseWriter, r *http.
 			logger.Int64("trackId", trackID),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Failed to remove track from album", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to remove track from album")
 		return
 	}
 
@@ -619,7 +829,7 @@ func (h *APIHandler) GetAlbumTracksHandler(w http.ResponseWriter, r *http.Reques
 		logger.Warn("Invalid method for get album tracks",
 			logger.String("method", r.Method),
 		)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -630,7 +840,7 @@ func (h *APIHandler) GetAlbumTracksHandler(w http.ResponseWriter, r *http.Reques
 			logger.String("id", vars["id"]),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid album ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid album ID")
 		return
 	}
 
@@ -645,12 +855,12 @@ func (h *APIHandler) GetAlbumTracksHandler(w http.ResponseWriter, r *http.Reques
 			logger.Int64("albumId", albumID),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Failed to get album", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to get album")
 		return
 	}
 	if album == nil {
 		logger.Warn("Album not found", logger.Int64("albumId", albumID))
-		http.Error(w, "Album not found", http.StatusNotFound)
+		writeError(w, CodeAlbumNotFound, "Album not found")
 		return
 	}
 
@@ -660,14 +870,14 @@ func (h *APIHandler) GetAlbumTracksHandler(w http.ResponseWriter, r *http.Reques
 			logger.Int64("albumId", albumID),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Failed to get album tracks", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to get album tracks")
 		return
 	}
 
 	// 新增：自动补全 coverArtPath
 	for _, track := range tracks {
 		if (track.CoverArtPath == "" || track.CoverArtPath == "null") && album.CoverPath != "" {
-			err := h.trackRepo.UpdateTrackCoverArtPath(track.ID, album.CoverPath)
+			err := h.trackRepo.UpdateTrackCoverArtPath(r.Context(), track.ID, album.CoverPath)
 			if err == nil {
 				track.CoverArtPath = album.CoverPath
 			}
@@ -683,8 +893,9 @@ func (h *APIHandler) GetAlbumTracksHandler(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(tracks)
 }
 
-// processTrackStreamAsync 异步处理曲目的流处理
-func (h *APIHandler) processTrackStreamAsync(trackID int64, fileBuffer *bytes.Buffer, fileHeader *multipart.FileHeader, fileExt, originalName string) error {
+// processTrackStreamAsync 异步处理曲目的流处理，已有共享存储时跳过对应的上传和转码
+// fingerprint 为上传时计算的指纹，为空时在这里重新计算
+func (h *APIHandler) processTrackStreamAsync(userID, trackID int64, fileBuffer *bytes.Buffer, upload *sharedUpload, fingerprint *audio.Fingerprint) error {
 	// 创建临时文件
 	tempFile, err := os.CreateTemp("", "album-upload-*")
 	if err != nil {
@@ -716,24 +927,59 @@ func (h *APIHandler) processTrackStreamAsync(trackID int64, fileBuffer *bytes.Bu
 		return fmt.Errorf("重置文件指针失败: %v", err)
 	}
 
-	// 使用共享的流处理器处理音频（避免每次创建新实例）
-	streamID := strconv.FormatInt(trackID, 10) // 只使用trackID数字，去掉"track_"前缀
+	// 计算并保存音频指纹，用于重复检测
+	if fingerprint == nil {
+		if fingerprint, err = h.computeFingerprint(tempFilePath); err != nil {
+			logger.Warn("计算音频指纹失败",
+				logger.Int64("trackId", trackID),
+				logger.ErrorField(err))
+		}
+	}
+	if fingerprint != nil {
+		h.saveTrackFingerprint(trackID, userID, fingerprint)
+	}
+
+	// 保存源文件到对象存储，供转码偏好变化时重新生成流
+	if upload.uploadSource {
+		if _, err := tempFile.Seek(0, 0); err != nil {
+			return fmt.Errorf("重置文件指针失败: %v", err)
+		}
+		if err := h.uploadFileToStorage(tempFile, upload.minioPath, upload.contentType); err != nil {
+			logger.Warn("上传源音频到对象存储失败",
+				logger.Int64("trackId", trackID),
+				logger.String("path", upload.minioPath),
+				logger.ErrorField(err))
+			// 转码 worker 从对象存储读取源音频
+			if upload.transcode && h.dispatchesToWorker() {
+				return fmt.Errorf("上传源音频失败: %v", err)
+			}
+		}
+	}
+
+	if !upload.transcode {
+		logger.Info("复用已有的共享流",
+			logger.Int64("trackId", trackID),
+			logger.String("streamId", upload.streamID))
+		return nil
+	}
+
+	if h.dispatchesToWorker() {
+		return h.enqueueTranscode(trackID, upload.streamID, upload.minioPath, upload.opts)
+	}
 
-	// 启动流处理
-	if err := h.streamProcessor.StreamProcess(context.Background(), streamID, tempFilePath, false); err != nil {
+	// 启动流处理，应用用户的转码偏好
+	if err := h.streamProcessor.StreamProcessWithOptions(context.Background(), upload.streamID, tempFilePath, false, upload.opts); err != nil {
 		logger.Error("流处理失败",
 			logger.Int64("trackId", trackID),
 			logger.ErrorField(err))
 		return fmt.Errorf("流处理失败: %v", err)
 	}
 
-	// 生成HLS流路径
-	safeBaseFilename := generateSafeFilenamePrefix(originalName, "", "")
-	hlsStreamDir := filepath.Join("streams", safeBaseFilename)
-	m3u8ServePath := "/static/" + strings.ReplaceAll(filepath.ToSlash(hlsStreamDir), "\\", "/") + "/playlist.m3u8"
+	// 流按内容哈希存储，由流处理接口提供
+	m3u8ServePath := streamPlaylistPath(upload.streamID)
 
 	// 更新数据库中的HLS路径
-	if err := h.trackRepo.UpdateTrackHLSPath(trackID, m3u8ServePath, 0); err != nil {
+	if err := h.trackRepo.UpdateTrackHLSPath(context.Background(), trackID, m3u8ServePath, 0); err != nil {
 		logger.Error("更新HLS路径失败",
 			logger.ErrorField(err),
 			logger.Int64("trackId", trackID),
@@ -4,8 +4,11 @@ import (
 	"net/http"
 	"fmt"
 	"encoding/json"
+	"time"
 	
 	"github.com/gorilla/mux"
+	"Bt1QFM/core/announcement"
+	"Bt1QFM/core/notification"
 	"Bt1QFM/model"
 	"Bt1QFM/repository"
 	"Bt1QFM/logger"
@@ -14,6 +17,8 @@ import (
 type AnnouncementHandler struct {
 	announcementRepo *repository.AnnouncementRepository
 	userRepo         repository.UserRepository
+	notifications    *notification.Service
+	scheduler        *announcement.Scheduler
 }
 
 func NewAnnouncementHandler(announcementRepo *repository.AnnouncementRepository, userRepo repository.UserRepository) *AnnouncementHandler {
@@ -23,6 +28,16 @@ func NewAnnouncementHandler(announcementRepo *repository.AnnouncementRepository,
 	}
 }
 
+// SetNotificationService 设置通知服务，用户确认公告时同步通知的已读状态
+func (h *AnnouncementHandler) SetNotificationService(service *notification.Service) {
+	h.notifications = service
+}
+
+// SetScheduler 设置公告定时任务，立即发布的公告通过它通知受众
+func (h *AnnouncementHandler) SetScheduler(scheduler *announcement.Scheduler) {
+	h.scheduler = scheduler
+}
+
 // GetAnnouncements 获取公告列表
 func (h *AnnouncementHandler) GetAnnouncements(w http.ResponseWriter, r *http.Request) {
 	logger.Info("收到获取公告列表请求", 
@@ -33,7 +48,7 @@ func (h *AnnouncementHandler) GetAnnouncements(w http.ResponseWriter, r *http.Re
 	userID := r.Context().Value("userID")
 	if userID == nil {
 		logger.Warn("获取公告列表失败：未授权访问")
-		http.Error(w, `{"success": false, "message": "未授权访问"}`, http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权访问")
 		return
 	}
 
@@ -52,7 +67,7 @@ func (h *AnnouncementHandler) GetAnnouncements(w http.ResponseWriter, r *http.Re
 		logger.Error("获取公告列表失败：用户ID格式错误", 
 			logger.Any("userID", userID),
 			logger.String("userIDType", fmt.Sprintf("%T", userID)))
-		http.Error(w, `{"success": false, "message": "用户ID格式错误"}`, http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "用户ID格式错误")
 		return
 	}
 
@@ -63,7 +78,7 @@ func (h *AnnouncementHandler) GetAnnouncements(w http.ResponseWriter, r *http.Re
 		logger.Error("获取公告列表失败：数据库查询错误", 
 			logger.Any("userId", uid),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "获取公告失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "获取公告失败")
 		return
 	}
 
@@ -71,6 +86,7 @@ func (h *AnnouncementHandler) GetAnnouncements(w http.ResponseWriter, r *http.Re
 		logger.Any("userId", uid),
 		logger.Int("count", len(announcements)))
 
+	localizeAnnouncements(w, announcements)
 	response := map[string]interface{}{
 		"success": true,
 		"data":    announcements,
@@ -90,7 +106,7 @@ func (h *AnnouncementHandler) GetUnreadAnnouncements(w http.ResponseWriter, r *h
 	userID := r.Context().Value("userID")
 	if userID == nil {
 		logger.Warn("获取未读公告失败：未授权访问")
-		http.Error(w, `{"success": false, "message": "未授权访问"}`, http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权访问")
 		return
 	}
 
@@ -109,7 +125,7 @@ func (h *AnnouncementHandler) GetUnreadAnnouncements(w http.ResponseWriter, r *h
 		logger.Error("获取未读公告失败：用户ID格式错误", 
 			logger.Any("userID", userID),
 			logger.String("userIDType", fmt.Sprintf("%T", userID)))
-		http.Error(w, `{"success": false, "message": "用户ID格式错误"}`, http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "用户ID格式错误")
 		return
 	}
 
@@ -120,7 +136,7 @@ func (h *AnnouncementHandler) GetUnreadAnnouncements(w http.ResponseWriter, r *h
 		logger.Error("获取未读公告失败：数据库查询错误", 
 			logger.Any("userId", uid),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "获取未读公告失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "获取未读公告失败")
 		return
 	}
 
@@ -128,6 +144,7 @@ func (h *AnnouncementHandler) GetUnreadAnnouncements(w http.ResponseWriter, r *h
 		logger.Any("userId", uid),
 		logger.Int("unreadCount", len(announcements)))
 
+	localizeAnnouncements(w, announcements)
 	response := map[string]interface{}{
 		"success": true,
 		"data":    announcements,
@@ -147,7 +164,7 @@ func (h *AnnouncementHandler) MarkAsRead(w http.ResponseWriter, r *http.Request)
 	userID := r.Context().Value("userID")
 	if userID == nil {
 		logger.Warn("标记公告已读失败：未授权访问")
-		http.Error(w, `{"success": false, "message": "未授权访问"}`, http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权访问")
 		return
 	}
 
@@ -166,7 +183,7 @@ func (h *AnnouncementHandler) MarkAsRead(w http.ResponseWriter, r *http.Request)
 		logger.Error("标记公告已读失败：用户ID格式错误", 
 			logger.Any("userID", userID),
 			logger.String("userIDType", fmt.Sprintf("%T", userID)))
-		http.Error(w, `{"success": false, "message": "用户ID格式错误"}`, http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "用户ID格式错误")
 		return
 	}
 
@@ -175,7 +192,7 @@ func (h *AnnouncementHandler) MarkAsRead(w http.ResponseWriter, r *http.Request)
 	if announcementID == "" {
 		logger.Error("标记公告已读失败：公告ID为空", 
 			logger.Any("userId", uid))
-		http.Error(w, `{"success": false, "message": "公告ID不能为空"}`, http.StatusBadRequest)
+		writeError(w, CodeMissingField, "公告ID不能为空")
 		return
 	}
 
@@ -190,7 +207,7 @@ func (h *AnnouncementHandler) MarkAsRead(w http.ResponseWriter, r *http.Request)
 			logger.Any("userId", uid),
 			logger.String("announcementId", announcementID),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "公告不存在"}`, http.StatusNotFound)
+		writeError(w, CodeAnnouncementNotFound, "公告不存在")
 		return
 	}
 
@@ -201,7 +218,7 @@ func (h *AnnouncementHandler) MarkAsRead(w http.ResponseWriter, r *http.Request)
 			logger.Any("userId", uid),
 			logger.String("announcementId", announcementID),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "标记已读失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "标记已读失败")
 		return
 	}
 
@@ -209,9 +226,14 @@ func (h *AnnouncementHandler) MarkAsRead(w http.ResponseWriter, r *http.Request)
 		logger.Any("userId", uid),
 		logger.String("announcementId", announcementID))
 
+	// 同步通知中心里对应的公告通知
+	if h.notifications != nil {
+		h.notifications.MarkObjectRead(r.Context(), int64(uid), model.NotificationAnnouncement, announcementID)
+	}
+
 	response := map[string]interface{}{
 		"success": true,
-		"message": "标记已读成功",
+		"message": localize(w, "标记已读成功"),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -230,7 +252,7 @@ func (h *AnnouncementHandler) CreateAnnouncement(w http.ResponseWriter, r *http.
 	userID := r.Context().Value("userID")
 	if userID == nil {
 		logger.Warn("创建公告失败：未授权访问")
-		http.Error(w, `{"success": false, "message": "未授权访问"}`, http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权访问")
 		return
 	}
 
@@ -249,19 +271,19 @@ func (h *AnnouncementHandler) CreateAnnouncement(w http.ResponseWriter, r *http.
 		logger.Error("创建公告失败：用户ID格式错误", 
 			logger.Any("userID", userID),
 			logger.String("userIDType", fmt.Sprintf("%T", userID)))
-		http.Error(w, `{"success": false, "message": "用户ID格式错误"}`, http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "用户ID格式错误")
 		return
 	}
 
 	logger.Info("开始验证管理员权限", logger.Any("userId", uid))
 
 	// 检查用户是否为管理员
-	user, err := h.userRepo.GetUserByID(int64(uid))
+	user, err := h.userRepo.GetUserByID(r.Context(), int64(uid))
 	if err != nil {
 		logger.Error("创建公告失败：获取用户信息失败", 
 			logger.Any("userId", uid),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "获取用户信息失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "获取用户信息失败")
 		return
 	}
 	
@@ -270,7 +292,7 @@ func (h *AnnouncementHandler) CreateAnnouncement(w http.ResponseWriter, r *http.
 		logger.Warn("创建公告失败：用户没有管理员权限", 
 			logger.Any("userId", uid),
 			logger.String("username", user.Username))
-		http.Error(w, `{"success": false, "message": "需要管理员权限"}`, http.StatusForbidden)
+		writeError(w, CodeForbidden, "需要管理员权限")
 		return
 	}
 
@@ -281,7 +303,7 @@ func (h *AnnouncementHandler) CreateAnnouncement(w http.ResponseWriter, r *http.
 		logger.Error("创建公告失败：JSON解析错误", 
 			logger.Any("userId", uid),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "请求参数错误"}`, http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "请求参数错误")
 		return
 	}
 
@@ -300,7 +322,7 @@ func (h *AnnouncementHandler) CreateAnnouncement(w http.ResponseWriter, r *http.
 			logger.Bool("contentEmpty", req.Content == ""),
 			logger.Bool("versionEmpty", req.Version == ""),
 			logger.Bool("typeEmpty", req.Type == ""))
-		http.Error(w, `{"success": false, "message": "必填字段不能为空"}`, http.StatusBadRequest)
+		writeError(w, CodeMissingField, "必填字段不能为空")
 		return
 	}
 
@@ -310,7 +332,16 @@ func (h *AnnouncementHandler) CreateAnnouncement(w http.ResponseWriter, r *http.
 		logger.Error("创建公告失败：公告类型无效", 
 			logger.Any("userId", uid),
 			logger.String("invalidType", req.Type))
-		http.Error(w, `{"success": false, "message": "公告类型无效"}`, http.StatusBadRequest)
+		writeError(w, CodeBadRequest, "公告类型无效")
+		return
+	}
+
+	// 验证定时发布、受众和 Markdown 正文
+	if err := validateAnnouncementRequest(&req); err != nil {
+		logger.Warn("创建公告失败：参数无效",
+			logger.Any("userId", uid),
+			logger.ErrorField(err))
+		writeError(w, CodeBadRequest, err.Error())
 		return
 	}
 
@@ -333,7 +364,7 @@ func (h *AnnouncementHandler) CreateAnnouncement(w http.ResponseWriter, r *http.
 			logger.Any("userId", uid),
 			logger.String("announcementId", announcement.ID),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "创建公告失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "创建公告失败")
 		return
 	}
 
@@ -343,10 +374,15 @@ func (h *AnnouncementHandler) CreateAnnouncement(w http.ResponseWriter, r *http.
 		logger.String("title", announcement.Title),
 		logger.String("version", announcement.Version))
 
+	// 定时发布的公告由定时任务在发布时通知
+	if announcement.Status == model.AnnouncementPublished && h.scheduler != nil {
+		h.scheduler.Announce(r.Context(), announcement)
+	}
+
 	response := map[string]interface{}{
 		"success": true,
-		"data":    announcement.ToResponse(false),
-		"message": "创建公告成功",
+		"data":    announcement,
+		"message": localize(w, "创建公告成功"),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -363,7 +399,7 @@ func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.
 	userID := r.Context().Value("userID")
 	if userID == nil {
 		logger.Warn("更新公告失败：未授权访问")
-		http.Error(w, `{"success": false, "message": "未授权访问"}`, http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权访问")
 		return
 	}
 
@@ -382,17 +418,17 @@ func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.
 		logger.Error("更新公告失败：用户ID格式错误", 
 			logger.Any("userID", userID),
 			logger.String("userIDType", fmt.Sprintf("%T", userID)))
-		http.Error(w, `{"success": false, "message": "用户ID格式错误"}`, http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "用户ID格式错误")
 		return
 	}
 
 	// 检查用户是否为管理员
-	_, err := h.userRepo.GetUserByID(int64(uid))
+	_, err := h.userRepo.GetUserByID(r.Context(), int64(uid))
 	if err != nil {
 		logger.Error("更新公告失败：获取用户信息失败", 
 			logger.Any("userId", uid),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "获取用户信息失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "获取用户信息失败")
 		return
 	}
 	
@@ -400,7 +436,7 @@ func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.
 	if uid != 1 {
 		logger.Warn("更新公告失败：用户没有管理员权限", 
 			logger.Any("userId", uid))
-		http.Error(w, `{"success": false, "message": "需要管理员权限"}`, http.StatusForbidden)
+		writeError(w, CodeForbidden, "需要管理员权限")
 		return
 	}
 
@@ -409,7 +445,7 @@ func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.
 	if announcementID == "" {
 		logger.Error("更新公告失败：公告ID为空", 
 			logger.Any("userId", uid))
-		http.Error(w, `{"success": false, "message": "公告ID不能为空"}`, http.StatusBadRequest)
+		writeError(w, CodeMissingField, "公告ID不能为空")
 		return
 	}
 
@@ -419,7 +455,7 @@ func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.
 			logger.Any("userId", uid),
 			logger.String("announcementId", announcementID),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "请求参数错误"}`, http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "请求参数错误")
 		return
 	}
 
@@ -435,7 +471,7 @@ func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.
 		logger.Error("更新公告失败：必填字段为空", 
 			logger.Any("userId", uid),
 			logger.String("announcementId", announcementID))
-		http.Error(w, `{"success": false, "message": "必填字段不能为空"}`, http.StatusBadRequest)
+		writeError(w, CodeMissingField, "必填字段不能为空")
 		return
 	}
 
@@ -446,7 +482,17 @@ func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.
 			logger.Any("userId", uid),
 			logger.String("announcementId", announcementID),
 			logger.String("invalidType", req.Type))
-		http.Error(w, `{"success": false, "message": "公告类型无效"}`, http.StatusBadRequest)
+		writeError(w, CodeBadRequest, "公告类型无效")
+		return
+	}
+
+	// 验证定时发布、受众和 Markdown 正文
+	if err := validateAnnouncementRequest(&req); err != nil {
+		logger.Warn("更新公告失败：参数无效",
+			logger.Any("userId", uid),
+			logger.String("announcementId", announcementID),
+			logger.ErrorField(err))
+		writeError(w, CodeBadRequest, err.Error())
 		return
 	}
 
@@ -457,7 +503,7 @@ func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.
 			logger.Any("userId", uid),
 			logger.String("announcementId", announcementID),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "公告不存在"}`, http.StatusNotFound)
+		writeError(w, CodeAnnouncementNotFound, "公告不存在")
 		return
 	}
 
@@ -466,14 +512,27 @@ func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.
 		logger.String("announcementId", announcementID),
 		logger.String("currentTitle", existingAnnouncement.Title))
 
-	// 更新公告
-	err = h.announcementRepo.UpdateAnnouncement(announcementID, req)
+	// 修改了内容时保存编辑前的版本，实质修改会清除用户的已读状态
+	var revision *model.AnnouncementRevision
+	substantive := req.IsSubstantiveEdit(existingAnnouncement)
+	if req.ContentChanged(existingAnnouncement) {
+		revision = model.NewAnnouncementRevision(existingAnnouncement, uid, substantive)
+	}
+
+	// 更新公告，状态按新的发布和过期时间重新计算
+	previousStatus := existingAnnouncement.Status
+	existingAnnouncement.Title = req.Title
+	existingAnnouncement.Content = req.Content
+	existingAnnouncement.Version = req.Version
+	existingAnnouncement.Type = req.Type
+	existingAnnouncement.ApplySchedule(req, time.Now())
+	err = h.announcementRepo.UpdateAnnouncement(existingAnnouncement, revision)
 	if err != nil {
 		logger.Error("更新公告失败：数据库操作错误", 
 			logger.Any("userId", uid),
 			logger.String("announcementId", announcementID),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "更新公告失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "更新公告失败")
 		return
 	}
 
@@ -484,7 +543,7 @@ func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.
 			logger.Any("userId", uid),
 			logger.String("announcementId", announcementID),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "获取更新后的公告失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "获取更新后的公告失败")
 		return
 	}
 
@@ -492,12 +551,25 @@ func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.
 		logger.Any("userId", uid),
 		logger.String("announcementId", announcementID),
 		logger.String("newTitle", updatedAnnouncement.Title),
-		logger.String("newVersion", updatedAnnouncement.Version))
+		logger.String("newVersion", updatedAnnouncement.Version),
+		logger.String("status", updatedAnnouncement.Status))
+
+	// 原本等待发布、修改后立即发布的公告在这里通知受众
+	if previousStatus == model.AnnouncementScheduled && updatedAnnouncement.Status == model.AnnouncementPublished && h.scheduler != nil {
+		h.scheduler.Announce(r.Context(), updatedAnnouncement)
+	}
+
+	// 实质修改后通知中心里的公告通知同样重新变为未读
+	readStateReset := revision != nil && revision.Substantive
+	if readStateReset && h.notifications != nil {
+		h.notifications.MarkObjectUnread(r.Context(), model.NotificationAnnouncement, announcementID)
+	}
 
 	response := map[string]interface{}{
-		"success": true,
-		"data":    updatedAnnouncement.ToResponse(false),
-		"message": "更新公告成功",
+		"success":        true,
+		"data":           updatedAnnouncement,
+		"readStateReset": readStateReset,
+		"message":        localize(w, "更新公告成功"),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -514,7 +586,7 @@ func (h *AnnouncementHandler) DeleteAnnouncement(w http.ResponseWriter, r *http.
 	userID := r.Context().Value("userID")
 	if userID == nil {
 		logger.Warn("删除公告失败：未授权访问")
-		http.Error(w, `{"success": false, "message": "未授权访问"}`, http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权访问")
 		return
 	}
 
@@ -533,17 +605,17 @@ func (h *AnnouncementHandler) DeleteAnnouncement(w http.ResponseWriter, r *http.
 		logger.Error("删除公告失败：用户ID格式错误", 
 			logger.Any("userID", userID),
 			logger.String("userIDType", fmt.Sprintf("%T", userID)))
-		http.Error(w, `{"success": false, "message": "用户ID格式错误"}`, http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "用户ID格式错误")
 		return
 	}
 
 	// 检查用户是否为管理员
-	_, err := h.userRepo.GetUserByID(int64(uid))
+	_, err := h.userRepo.GetUserByID(r.Context(), int64(uid))
 	if err != nil {
 		logger.Error("删除公告失败：获取用户信息失败", 
 			logger.Any("userId", uid),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "获取用户信息失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "获取用户信息失败")
 		return
 	}
 	
@@ -551,7 +623,7 @@ func (h *AnnouncementHandler) DeleteAnnouncement(w http.ResponseWriter, r *http.
 	if uid != 1 {
 		logger.Warn("删除公告失败：用户没有管理员权限", 
 			logger.Any("userId", uid))
-		http.Error(w, `{"success": false, "message": "需要管理员权限"}`, http.StatusForbidden)
+		writeError(w, CodeForbidden, "需要管理员权限")
 		return
 	}
 
@@ -560,7 +632,7 @@ func (h *AnnouncementHandler) DeleteAnnouncement(w http.ResponseWriter, r *http.
 	if announcementID == "" {
 		logger.Error("删除公告失败：公告ID为空", 
 			logger.Any("userId", uid))
-		http.Error(w, `{"success": false, "message": "公告ID不能为空"}`, http.StatusBadRequest)
+		writeError(w, CodeMissingField, "公告ID不能为空")
 		return
 	}
 
@@ -575,7 +647,7 @@ func (h *AnnouncementHandler) DeleteAnnouncement(w http.ResponseWriter, r *http.
 			logger.Any("userId", uid),
 			logger.String("announcementId", announcementID),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "公告不存在"}`, http.StatusNotFound)
+		writeError(w, CodeAnnouncementNotFound, "公告不存在")
 		return
 	}
 
@@ -591,7 +663,7 @@ func (h *AnnouncementHandler) DeleteAnnouncement(w http.ResponseWriter, r *http.
 			logger.Any("userId", uid),
 			logger.String("announcementId", announcementID),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "删除公告失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "删除公告失败")
 		return
 	}
 
@@ -602,7 +674,7 @@ func (h *AnnouncementHandler) DeleteAnnouncement(w http.ResponseWriter, r *http.
 
 	response := map[string]interface{}{
 		"success": true,
-		"message": "删除公告成功",
+		"message": localize(w, "删除公告成功"),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -619,7 +691,7 @@ func (h *AnnouncementHandler) GetAnnouncementStats(w http.ResponseWriter, r *htt
 	userID := r.Context().Value("userID")
 	if userID == nil {
 		logger.Warn("获取公告统计失败：未授权访问")
-		http.Error(w, `{"success": false, "message": "未授权访问"}`, http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权访问")
 		return
 	}
 
@@ -638,17 +710,17 @@ func (h *AnnouncementHandler) GetAnnouncementStats(w http.ResponseWriter, r *htt
 		logger.Error("获取公告统计失败：用户ID格式错误", 
 			logger.Any("userID", userID),
 			logger.String("userIDType", fmt.Sprintf("%T", userID)))
-		http.Error(w, `{"success": false, "message": "用户ID格式错误"}`, http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "用户ID格式错误")
 		return
 	}
 
 	// 检查用户是否为管理员
-	_, err := h.userRepo.GetUserByID(int64(uid))
+	_, err := h.userRepo.GetUserByID(r.Context(), int64(uid))
 	if err != nil {
 		logger.Error("获取公告统计失败：获取用户信息失败", 
 			logger.Any("userId", uid),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "获取用户信息失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "获取用户信息失败")
 		return
 	}
 	
@@ -656,7 +728,7 @@ func (h *AnnouncementHandler) GetAnnouncementStats(w http.ResponseWriter, r *htt
 	if uid != 1 {
 		logger.Warn("获取公告统计失败：用户没有管理员权限", 
 			logger.Any("userId", uid))
-		http.Error(w, `{"success": false, "message": "需要管理员权限"}`, http.StatusForbidden)
+		writeError(w, CodeForbidden, "需要管理员权限")
 		return
 	}
 
@@ -667,7 +739,7 @@ func (h *AnnouncementHandler) GetAnnouncementStats(w http.ResponseWriter, r *htt
 		logger.Error("获取公告统计失败：数据库查询错误", 
 			logger.Any("userId", uid),
 			logger.ErrorField(err))
-		http.Error(w, `{"success": false, "message": "获取统计信息失败"}`, http.StatusInternalServerError)
+		writeError(w, CodeInternal, "获取统计信息失败")
 		return
 	}
 
@@ -684,6 +756,93 @@ func (h *AnnouncementHandler) GetAnnouncementStats(w http.ResponseWriter, r *htt
 	json.NewEncoder(w).Encode(response)
 }
 
+// ListAllAnnouncements 获取所有未删除的公告，包括等待发布和已过期的公告（管理员）
+func (h *AnnouncementHandler) ListAllAnnouncements(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "未授权访问")
+		return
+	}
+
+	// 简单的管理员检查，与创建和更新公告一致
+	if userID != 1 {
+		logger.Warn("获取全部公告失败：用户没有管理员权限", logger.Int64("userId", userID))
+		writeError(w, CodeForbidden, "需要管理员权限")
+		return
+	}
+
+	announcements, err := h.announcementRepo.ListAnnouncements()
+	if err != nil {
+		logger.Error("获取全部公告失败：数据库查询错误", logger.ErrorField(err))
+		writeError(w, CodeInternal, "获取公告失败")
+		return
+	}
+	if announcements == nil {
+		announcements = []model.Announcement{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    announcements,
+	})
+}
+
+// GetAnnouncementHistory 获取公告的当前版本和编辑历史（管理员）
+func (h *AnnouncementHandler) GetAnnouncementHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "未授权访问")
+		return
+	}
+
+	// 简单的管理员检查，与创建和更新公告一致
+	if userID != 1 {
+		logger.Warn("获取公告历史失败：用户没有管理员权限", logger.Int64("userId", userID))
+		writeError(w, CodeForbidden, "需要管理员权限")
+		return
+	}
+
+	announcementID := mux.Vars(r)["id"]
+	current, err := h.announcementRepo.GetAnnouncementByID(announcementID)
+	if err != nil {
+		writeError(w, CodeAnnouncementNotFound, "公告不存在")
+		return
+	}
+
+	revisions, err := h.announcementRepo.GetRevisions(announcementID)
+	if err != nil {
+		logger.Error("获取公告历史失败：数据库查询错误",
+			logger.String("announcementId", announcementID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "获取公告历史失败")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    &model.AnnouncementHistory{Current: current, Revisions: revisions},
+	})
+}
+
+// localizeAnnouncements 按响应语言填写公告类型名称
+func localizeAnnouncements(w http.ResponseWriter, announcements []model.AnnouncementResponse) {
+	for i := range announcements {
+		if label, ok := model.AnnouncementTypeLabels[announcements[i].Type]; ok {
+			announcements[i].TypeLabel = localize(w, label)
+		}
+	}
+}
+
+// validateAnnouncementRequest 校验创建和更新公告时的定时发布、受众和 Markdown 正文
+func validateAnnouncementRequest(req *model.CreateAnnouncementRequest) error {
+	if err := req.ValidateSchedule(time.Now()); err != nil {
+		return err
+	}
+	return model.ValidateAnnouncementMarkdown(req.Content)
+}
+
 // RegisterAnnouncementRoutes 注册公告相关路由 - 适配现有中间件
 func RegisterAnnouncementRoutes(router *mux.Router, handler *AnnouncementHandler, authMiddleware func(http.HandlerFunc) http.HandlerFunc) {
 	logger.Info("开始注册公告相关路由")
@@ -696,7 +855,9 @@ func RegisterAnnouncementRoutes(router *mux.Router, handler *AnnouncementHandler
 	router.HandleFunc("/api/announcements/{id}", authMiddleware(handler.UpdateAnnouncement)).Methods("PUT")
 	router.HandleFunc("/api/announcements/{id}", authMiddleware(handler.DeleteAnnouncement)).Methods("DELETE")
 	router.HandleFunc("/api/announcements/stats", authMiddleware(handler.GetAnnouncementStats)).Methods("GET")
+	router.HandleFunc("/api/announcements/all", authMiddleware(handler.ListAllAnnouncements)).Methods("GET")
+	router.HandleFunc("/api/announcements/{id}/history", authMiddleware(handler.GetAnnouncementHistory)).Methods("GET")
 	
 	logger.Info("公告路由注册完成", 
-		logger.String("routes", "GET,POST /api/announcements | GET /api/announcements/unread | PUT /api/announcements/{id}/read | PUT,DELETE /api/announcements/{id} | GET /api/announcements/stats"))
+		logger.String("routes", "GET,POST /api/announcements | GET /api/announcements/unread | PUT /api/announcements/{id}/read | PUT,DELETE /api/announcements/{id} | GET /api/announcements/stats | GET /api/announcements/all | GET /api/announcements/{id}/history"))
 }
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"Bt1QFM/i18n"
+	"Bt1QFM/logger"
+)
+
+// ErrorCode 机器可读的错误码，客户端应按错误码而不是错误信息分支
+type ErrorCode string
+
+// 错误码目录，新增错误码时需同时登记到 errorCatalog
+const (
+	// 通用
+	CodeBadRequest         ErrorCode = "BAD_REQUEST"
+	CodeInvalidBody        ErrorCode = "INVALID_BODY"
+	CodeInvalidID          ErrorCode = "INVALID_ID"
+	CodeMissingField       ErrorCode = "MISSING_FIELD"
+	CodeNotFound           ErrorCode = "NOT_FOUND"
+	CodeMethodNotAllowed   ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeConflict           ErrorCode = "CONFLICT"
+	CodeRequestTimeout     ErrorCode = "REQUEST_TIMEOUT"
+	CodeRateLimited        ErrorCode = "RATE_LIMITED"
+	CodeInternal           ErrorCode = "INTERNAL_ERROR"
+	CodeServiceUnavailable ErrorCode = "SERVICE_UNAVAILABLE"
+
+	// 幂等请求
+	CodeIdempotencyInProgress ErrorCode = "IDEMPOTENCY_IN_PROGRESS"
+	CodeIdempotencyKeyReused  ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+
+	// 认证与权限
+	CodeUnauthorized       ErrorCode = "UNAUTHORIZED"
+	CodeInvalidToken       ErrorCode = "INVALID_TOKEN"
+	CodeInvalidCredentials ErrorCode = "INVALID_CREDENTIALS"
+	CodeUserExists         ErrorCode = "USER_EXISTS"
+	CodeUserNotFound       ErrorCode = "USER_NOT_FOUND"
+	CodeForbidden          ErrorCode = "FORBIDDEN"
+	CodeInvalidSignature   ErrorCode = "INVALID_SIGNATURE"
+	CodeInvalidResetToken  ErrorCode = "INVALID_RESET_TOKEN"
+	CodeEmailNotVerified   ErrorCode = "EMAIL_NOT_VERIFIED"
+	CodeInvalidVerifyToken ErrorCode = "INVALID_VERIFY_TOKEN"
+	CodeAccountDisabled    ErrorCode = "ACCOUNT_DISABLED"
+	CodeCaptchaRequired    ErrorCode = "CAPTCHA_REQUIRED"
+	CodeCaptchaInvalid     ErrorCode = "CAPTCHA_INVALID"
+	CodeAccountLocked      ErrorCode = "ACCOUNT_LOCKED"
+	CodeLoginThrottled     ErrorCode = "LOGIN_THROTTLED"
+
+	// 曲目与上传
+	CodeTrackNotFound        ErrorCode = "TRACK_NOT_FOUND"
+	CodeDuplicateTrack       ErrorCode = "DUPLICATE_TRACK"
+	CodeFileTooLarge         ErrorCode = "FILE_TOO_LARGE"
+	CodeUnsupportedFileType  ErrorCode = "UNSUPPORTED_FILE_TYPE"
+	CodeFileTypeMismatch     ErrorCode = "FILE_TYPE_MISMATCH"
+	CodeInvalidAudio         ErrorCode = "INVALID_AUDIO"
+	CodeInvalidCover         ErrorCode = "INVALID_COVER"
+	CodeStorageQuotaExceeded ErrorCode = "STORAGE_QUOTA_EXCEEDED"
+
+	// 专辑、房间、公告、设备、投屏、聊天会话
+	CodeAlbumNotFound        ErrorCode = "ALBUM_NOT_FOUND"
+	CodeRoomNotFound         ErrorCode = "ROOM_NOT_FOUND"
+	CodeAnnouncementNotFound ErrorCode = "ANNOUNCEMENT_NOT_FOUND"
+	CodeDeviceNotFound       ErrorCode = "DEVICE_NOT_FOUND"
+	CodeRendererNotFound     ErrorCode = "RENDERER_NOT_FOUND"
+	CodeRendererError        ErrorCode = "RENDERER_ERROR"
+	CodeChatSessionNotFound  ErrorCode = "CHAT_SESSION_NOT_FOUND"
+
+	// 听歌记录同步
+	CodeScrobblerAuthFailed  ErrorCode = "SCROBBLER_AUTH_FAILED"
+	CodeScrobblerUnavailable ErrorCode = "SCROBBLER_UNAVAILABLE"
+
+	// AI 电台
+	CodeRadioUnavailable ErrorCode = "RADIO_UNAVAILABLE"
+
+	// 专辑元数据补全
+	CodeMetadataUnavailable ErrorCode = "METADATA_UNAVAILABLE"
+
+	// 存储与流媒体
+	CodeStorageUnavailable ErrorCode = "STORAGE_UNAVAILABLE"
+	CodeStreamNotReady     ErrorCode = "STREAM_NOT_READY"
+	CodeStreamProcessing   ErrorCode = "STREAM_PROCESSING"
+)
+
+// errorSpec 错误码对应的 HTTP 状态码和说明
+type errorSpec struct {
+	Status      int
+	Description string
+}
+
+// errorCatalog 错误码目录
+var errorCatalog = map[ErrorCode]errorSpec{
+	CodeBadRequest:         {http.StatusBadRequest, "请求参数不合法"},
+	CodeInvalidBody:        {http.StatusBadRequest, "请求体无法解析"},
+	CodeInvalidID:          {http.StatusBadRequest, "路径或参数中的 ID 格式错误"},
+	CodeMissingField:       {http.StatusBadRequest, "缺少必填字段"},
+	CodeNotFound:           {http.StatusNotFound, "资源不存在"},
+	CodeMethodNotAllowed:   {http.StatusMethodNotAllowed, "不支持的请求方法"},
+	CodeConflict:           {http.StatusConflict, "与当前状态冲突"},
+	CodeRequestTimeout:     {http.StatusRequestTimeout, "请求超时"},
+	CodeRateLimited:        {http.StatusTooManyRequests, "请求过于频繁，details.retryAfter 为需要等待的秒数"},
+	CodeInternal:           {http.StatusInternalServerError, "服务器内部错误"},
+	CodeServiceUnavailable: {http.StatusServiceUnavailable, "服务繁忙，稍后重试"},
+
+	CodeIdempotencyInProgress: {http.StatusConflict, "使用同一 Idempotency-Key 的请求仍在处理中，稍后重试"},
+	CodeIdempotencyKeyReused:  {http.StatusUnprocessableEntity, "Idempotency-Key 已用于内容不同的请求"},
+
+	CodeUnauthorized:       {http.StatusUnauthorized, "未登录或缺少认证信息"},
+	CodeInvalidToken:       {http.StatusUnauthorized, "Token 无效或已过期"},
+	CodeInvalidCredentials: {http.StatusUnauthorized, "用户名或密码错误"},
+	CodeUserExists:         {http.StatusConflict, "用户名或邮箱已被注册"},
+	CodeUserNotFound:       {http.StatusNotFound, "用户不存在"},
+	CodeForbidden:          {http.StatusForbidden, "没有权限访问该资源"},
+	CodeInvalidSignature:   {http.StatusForbidden, "签名无效或已过期"},
+	CodeInvalidResetToken:  {http.StatusBadRequest, "重置密码链接无效、已过期或已被使用"},
+	CodeEmailNotVerified:   {http.StatusForbidden, "邮箱尚未验证，验证后才能执行该操作"},
+	CodeInvalidVerifyToken: {http.StatusBadRequest, "邮箱验证链接无效、已过期或已被使用"},
+	CodeAccountDisabled:    {http.StatusForbidden, "账号已被管理员禁用"},
+	CodeCaptchaRequired:    {http.StatusForbidden, "需要先通过人机验证，通过 GET /api/auth/captcha 获取验证参数，details.provider 为验证方式"},
+	CodeCaptchaInvalid:     {http.StatusForbidden, "人机验证未通过、已过期或已被使用，需要重新验证"},
+	CodeAccountLocked:      {http.StatusLocked, "账号因登录失败次数过多被临时锁定，details.retryAfter 为剩余秒数"},
+	CodeLoginThrottled:     {http.StatusTooManyRequests, "登录失败次数过多，details.retryAfter 秒后才能再次尝试"},
+
+	CodeTrackNotFound:        {http.StatusNotFound, "曲目不存在"},
+	CodeDuplicateTrack:       {http.StatusConflict, "音频已存在于曲库中，details.duplicateOf 为重复的曲目 ID"},
+	CodeFileTooLarge:         {http.StatusRequestEntityTooLarge, "文件超过大小限制"},
+	CodeUnsupportedFileType:  {http.StatusBadRequest, "不支持的文件类型"},
+	CodeFileTypeMismatch:     {http.StatusUnsupportedMediaType, "文件内容与声明的类型或扩展名不符，details 中包含声明和识别出的类型"},
+	CodeInvalidAudio:         {http.StatusUnprocessableEntity, "音频文件无法解析、没有音频流或已被截断，details.reason 为具体原因"},
+	CodeInvalidCover:         {http.StatusBadRequest, "封面图片无效"},
+	CodeStorageQuotaExceeded: {http.StatusRequestEntityTooLarge, "超出用户存储配额，details 中包含已用、配额、剩余和本次所需字节数"},
+
+	CodeAlbumNotFound:        {http.StatusNotFound, "专辑不存在"},
+	CodeRoomNotFound:         {http.StatusNotFound, "房间不存在"},
+	CodeAnnouncementNotFound: {http.StatusNotFound, "公告不存在"},
+	CodeDeviceNotFound:       {http.StatusNotFound, "目标设备不在线"},
+	CodeRendererNotFound:     {http.StatusNotFound, "投屏设备不存在，需重新搜索"},
+	CodeRendererError:        {http.StatusBadGateway, "投屏设备拒绝了请求或无法连接"},
+	CodeChatSessionNotFound:  {http.StatusNotFound, "聊天会话不存在"},
+
+	CodeScrobblerAuthFailed:  {http.StatusBadRequest, "Last.fm 或 ListenBrainz 拒绝了提供的账号信息"},
+	CodeScrobblerUnavailable: {http.StatusBadGateway, "Last.fm 或 ListenBrainz 暂时无法访问"},
+
+	CodeRadioUnavailable: {http.StatusBadGateway, "AI 电台暂时无法生成续播歌曲"},
+
+	CodeMetadataUnavailable: {http.StatusBadGateway, "MusicBrainz 暂时无法访问"},
+
+	CodeStorageUnavailable: {http.StatusInternalServerError, "对象存储不可用"},
+	CodeStreamNotReady:     {http.StatusNotFound, "流尚未生成或分片未就绪"},
+	CodeStreamProcessing:   {http.StatusAccepted, "流正在转码，稍后重试"},
+}
+
+// ErrorResponse 统一的错误响应结构
+type ErrorResponse struct {
+	Code      ErrorCode   `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// writeError 写入统一格式的错误响应，状态码由错误码决定
+func writeError(w http.ResponseWriter, code ErrorCode, message string) {
+	writeErrorDetails(w, code, message, nil)
+}
+
+// writeErrorDetails 写入带附加信息的错误响应，错误信息按响应语言翻译
+func writeErrorDetails(w http.ResponseWriter, code ErrorCode, message string, details interface{}) {
+	spec, ok := errorCatalog[code]
+	if !ok {
+		logger.Warn("未登记的错误码", logger.String("code", string(code)))
+		spec = errorCatalog[CodeInternal]
+	}
+	message = localizeErrorMessage(responseLanguage(w), spec, message)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(spec.Status)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: w.Header().Get(requestIDHeader),
+	})
+}
+
+// localizeErrorMessage 翻译错误信息；消息目录中没有且与响应语言不一致时（如拼接了参数的信息），
+// 使用错误码说明代替，保证客户端看到的信息是请求的语言。未协商语言时原样返回
+func localizeErrorMessage(lang string, spec errorSpec, message string) string {
+	if lang == "" {
+		return message
+	}
+	if translated, ok := i18n.Translate(lang, message); ok {
+		return translated
+	}
+	if message == "" || i18n.Matches(lang, message) {
+		return message
+	}
+	return i18n.T(lang, spec.Description)
+}
+
+// ErrorCatalogHandler 返回错误码目录，供客户端生成错误处理代码
+func (h *APIHandler) ErrorCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	type catalogEntry struct {
+		Code        ErrorCode `json:"code"`
+		Status      int       `json:"status"`
+		Description string    `json:"description"`
+	}
+	entries := make([]catalogEntry, 0, len(errorCatalog))
+	for code, spec := range errorCatalog {
+		entries = append(entries, catalogEntry{Code: code, Status: spec.Status, Description: localize(w, spec.Description)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": entries,
+	})
+}
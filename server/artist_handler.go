@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/netease"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/mux"
+)
+
+// artistSeparators 多位歌手之间常见的分隔符
+var artistSeparators = strings.NewReplacer(" feat. ", "/", " ft. ", "/", " & ", "/", ",", "/", "，", "/", "、", "/", ";", "/")
+
+// artistPage 歌手详情页：用户本地曲库中该歌手的歌曲和网易云上的歌手信息
+type artistPage struct {
+	Name        string                   `json:"name"`
+	LocalTracks []*model.Track           `json:"localTracks"`
+	Netease     *model.NeteaseArtistInfo `json:"netease"` // 网易云上没有该歌手或获取失败时为 null
+}
+
+// ArtistPageHandler 返回歌手详情页，网易云部分按歌手名缓存
+func (h *APIHandler) ArtistPageHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	name := strings.TrimSpace(mux.Vars(r)["name"])
+	if name == "" {
+		writeError(w, CodeMissingField, "Missing artist name")
+		return
+	}
+
+	tracks, err := h.trackRepo.GetAllTracksByUserID(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取曲目失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get tracks")
+		return
+	}
+	page := artistPage{
+		Name:        name,
+		LocalTracks: make([]*model.Track, 0),
+		Netease:     h.neteaseArtistInfo(r, name),
+	}
+	for _, track := range tracks {
+		if matchesArtist(track.Artist, name) {
+			page.LocalTracks = append(page.LocalTracks, track)
+		}
+	}
+	if page.Netease != nil {
+		page.Name = page.Netease.Name
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// neteaseArtistInfo 获取网易云歌手详情，优先读取缓存；获取失败时返回 nil，页面仍展示本地歌曲
+func (h *APIHandler) neteaseArtistInfo(r *http.Request, name string) *model.NeteaseArtistInfo {
+	log := logger.Ctx(r.Context())
+	info, found, err := cache.GetArtistInfo(r.Context(), name)
+	if err != nil {
+		log.Warn("读取歌手缓存失败", logger.String("artist", name), logger.ErrorField(err))
+	}
+	if found {
+		return info
+	}
+
+	info, err = h.neteaseClient.GetArtistInfo(name)
+	if err != nil && !errors.Is(err, netease.ErrArtistNotFound) {
+		// 网络等临时错误不缓存，下次请求重试
+		log.Warn("获取网易云歌手信息失败", logger.String("artist", name), logger.ErrorField(err))
+		return nil
+	}
+	if err := cache.SetArtistInfo(r.Context(), name, info); err != nil {
+		log.Warn("写入歌手缓存失败", logger.String("artist", name), logger.ErrorField(err))
+	}
+	return info
+}
+
+// matchesArtist 曲目的歌手字段是否包含该歌手，支持 "A / B"、"A feat. B" 等多位歌手的写法
+func matchesArtist(artistField, name string) bool {
+	for _, artist := range strings.Split(artistSeparators.Replace(artistField), "/") {
+		if strings.EqualFold(strings.TrimSpace(artist), name) {
+			return true
+		}
+	}
+	return false
+}
@@ -7,8 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
+	"Bt1QFM/cache"
 	"Bt1QFM/core/auth"
 	"Bt1QFM/logger"
 	"Bt1QFM/model"
@@ -17,38 +19,51 @@ import (
 
 // LoginRequest represents the login request body
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	CaptchaToken string `json:"captchaToken,omitempty"`
 }
 
 // RegisterRequest represents the registration request body
 type RegisterRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Email    string `json:"email"`
-	Phone    string `json:"phone"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	Email        string `json:"email"`
+	Phone        string `json:"phone"`
+	CaptchaToken string `json:"captchaToken"` // 启用人机验证时必填
 }
 
 // LoginHandler handles user login requests
 func (h *APIHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req struct {
-		Username string `json:"username"` // 可以是用户名或邮箱
-		Password string `json:"password"`
+		Username     string `json:"username"` // 可以是用户名或邮箱
+		Password     string `json:"password"`
+		CaptchaToken string `json:"captchaToken"` // 同一 IP 登录失败次数过多后必填
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("[Login] 解析请求体失败", logger.ErrorField(err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	if req.Username == "" || req.Password == "" {
-		http.Error(w, "Username/Email and password are required", http.StatusBadRequest)
+		writeError(w, CodeMissingField, "Username/Email and password are required")
+		return
+	}
+
+	// 同一 IP 登录失败次数过多时需要先通过人机验证
+	ip := clientIP(r, trustedProxies(h.cfg))
+	if h.loginNeedsCaptcha(r.Context(), ip) && !h.verifyCaptcha(w, r, req.CaptchaToken) {
+		return
+	}
+	// 同一 IP 连续失败后需要等待退避时间
+	if h.loginBlocked(w, r, loginIPSubject(ip)) {
 		return
 	}
 
@@ -56,32 +71,53 @@ func (h *APIHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var user *model.User
 	var err error
 	if strings.Contains(req.Username, "@") {
-		user, err = h.userRepo.GetUserByEmail(req.Username)
+		user, err = h.userRepo.GetUserByEmail(r.Context(), req.Username)
 	} else {
-		user, err = h.userRepo.GetUserByUsername(req.Username)
+		user, err = h.userRepo.GetUserByUsername(r.Context(), req.Username)
 	}
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			logger.Warn("[Login] 用户不存在", logger.String("username", req.Username))
-			http.Error(w, "Invalid username/email or password", http.StatusUnauthorized)
+			h.writeLoginFailure(w, r, ip, nil)
 		} else {
 			logger.Error("[Login] 查询用户失败", logger.ErrorField(err))
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			writeError(w, CodeInternal, "Internal server error")
 		}
 		return
 	}
 
 	if user == nil {
 		logger.Warn("[Login] 用户不存在", logger.String("username", req.Username))
-		http.Error(w, "Invalid username/email or password", http.StatusUnauthorized)
+		h.writeLoginFailure(w, r, ip, nil)
+		return
+	}
+
+	// 账号处于退避或锁定中时不验证密码，避免继续猜测
+	if h.loginBlocked(w, r, loginAccountSubject(user.ID)) {
 		return
 	}
 
 	// 验证密码
 	if !auth.VerifyPassword(req.Password, user.PasswordHash) {
 		logger.Warn("[Login] 密码验证失败", logger.String("username", req.Username))
-		http.Error(w, "Invalid username/email or password", http.StatusUnauthorized)
+		h.writeLoginFailure(w, r, ip, user)
+		return
+	}
+	h.clearAccountLoginFailures(r.Context(), user.ID)
+
+	if user.IsDisabled() {
+		logger.Warn("[Login] 账号已禁用", logger.String("username", req.Username))
+		writeError(w, CodeAccountDisabled, "Account is disabled")
+		return
+	}
+
+	// 未验证邮箱的账号按配置禁止登录
+	if !user.IsActive() && h.blocksUnverifiedLogin() {
+		logger.Warn("[Login] 邮箱未验证", logger.String("username", req.Username))
+		writeErrorDetails(w, CodeEmailNotVerified, "Please verify your email before logging in", map[string]interface{}{
+			"email": user.Email,
+		})
 		return
 	}
 
@@ -89,10 +125,17 @@ func (h *APIHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GenerateToken(user.ID, user.Username)
 	if err != nil {
 		logger.Error("[Login] 生成Token失败", logger.ErrorField(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Internal server error")
 		return
 	}
 
+	// 登录时同步语言偏好，Redis 数据丢失后由此恢复
+	if lang := user.GetPreferences().Language; lang != "" {
+		if err := cache.SetUserLanguage(r.Context(), user.ID, lang); err != nil {
+			logger.Warn("[Login] 同步语言偏好失败", logger.Int64("userId", user.ID), logger.ErrorField(err))
+		}
+	}
+
 	// 构建响应
 	response := struct {
 		Token string     `json:"token"`
@@ -103,6 +146,7 @@ func (h *APIHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 			ID:        user.ID,
 			Username:  user.Username,
 			Email:     user.Email,
+			Status:    user.Status,
 			CreatedAt: user.CreatedAt,
 			UpdatedAt: user.UpdatedAt,
 		},
@@ -124,26 +168,31 @@ func (h *APIHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 // RegisterHandler handles user registration requests
 func (h *APIHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Only POST method is allowed")
 		return
 	}
 
 	var req RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	// Validate request
 	if req.Username == "" || req.Password == "" || req.Email == "" {
-		http.Error(w, "Username, password and email are required", http.StatusBadRequest)
+		writeError(w, CodeMissingField, "Username, password and email are required")
+		return
+	}
+
+	// 启用人机验证时注册必须通过
+	if !h.verifyCaptcha(w, r, req.CaptchaToken) {
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := auth.HashPassword(req.Password)
 	if err != nil {
-		http.Error(w, "Failed to process password", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to process password")
 		return
 	}
 
@@ -152,6 +201,10 @@ func (h *APIHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		Username:     req.Username,
 		Email:        req.Email,
 		PasswordHash: hashedPassword,
+		Status:       model.UserStatusActive,
+	}
+	if h.emailVerificationEnabled() {
+		user.Status = model.UserStatusPending
 	}
 
 	// 只有当Phone字段不为空时才设置
@@ -162,26 +215,28 @@ func (h *APIHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	userID, err := h.userRepo.CreateUser(user)
+	userID, err := h.userRepo.CreateUser(r.Context(), user)
 	if err != nil {
 		// 使用 errors.Is 检查是否是重复用户错误
 		if errors.Is(err, repository.ErrDuplicateUser) {
 			logger.Warn("[Register] 用户名或邮箱已存在",
 				logger.String("username", req.Username),
 				logger.String("email", req.Email))
-			http.Error(w, "Username or email already exists", http.StatusConflict)
+			writeError(w, CodeUserExists, "Username or email already exists")
 			return
 		}
 		logger.Error("[Register] 创建用户失败", logger.ErrorField(err))
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to create user")
 		return
 	}
 
-	// Generate JWT token
-	token, err := auth.GenerateToken(userID, user.Username)
-	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
-		return
+	user.ID = userID
+
+	// 发送验证邮件失败时账号仍然创建成功，用户可以稍后重发
+	if !user.IsActive() {
+		if err := h.issueVerificationEmail(r, user); err != nil {
+			logger.Warn("[Register] 签发验证邮件失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		}
 	}
 
 	// Return user info and token
@@ -189,6 +244,7 @@ func (h *APIHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		"id":       userID,
 		"username": user.Username,
 		"email":    user.Email,
+		"status":   user.Status,
 	}
 
 	// 只有当Phone字段有效时才添加到响应中
@@ -196,11 +252,23 @@ func (h *APIHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		userResponse["phone"] = user.Phone.String
 	}
 
+	response := map[string]interface{}{
+		"user":                 userResponse,
+		"verificationRequired": !user.IsActive(),
+	}
+
+	// 禁止未验证账号登录时，验证邮箱后再通过登录获取 Token
+	if user.IsActive() || !h.blocksUnverifiedLogin() {
+		token, err := auth.GenerateToken(userID, user.Username)
+		if err != nil {
+			writeError(w, CodeInternal, "Failed to generate token")
+			return
+		}
+		response["token"] = token
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"token": token,
-		"user":  userResponse,
-	})
+	json.NewEncoder(w).Encode(response)
 }
 
 // AuthMiddleware is a middleware function that checks for a valid JWT token
@@ -209,33 +277,62 @@ func (h *APIHandler) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		// Get the Authorization header
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+			writeError(w, CodeUnauthorized, "Authorization header is required")
 			return
 		}
 
 		// Check if the header has the correct format
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+			writeError(w, CodeInvalidToken, "Invalid authorization header format")
 			return
 		}
 
 		// Parse and validate the token
 		claims, err := auth.ParseToken(parts[1])
 		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			writeError(w, CodeInvalidToken, "Invalid token")
+			return
+		}
+		// 账号被禁用前签发的 Token 同样拒绝
+		if cache.IsUserDisabled(r.Context(), claims.UserID) {
+			writeError(w, CodeAccountDisabled, "Account is disabled")
 			return
 		}
 
 		// Add user info to the request context
 		ctx := context.WithValue(r.Context(), "userID", claims.UserID)
 		ctx = context.WithValue(ctx, "username", claims.Username)
+		ctx = withRequestUser(ctx, claims.UserID, claims.Username)
+		r = applyUserLanguage(w, r.WithContext(ctx), claims.UserID)
 
 		// Call the next handler with the updated context
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r)
 	}
 }
 
+// AdminMiddleware 在 AuthMiddleware 的基础上要求当前用户在管理员列表中
+func (h *APIHandler) AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return h.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := GetUserIDFromContext(r.Context())
+		if !h.isAdmin(userID) {
+			username, _ := GetUsernameFromContext(r.Context())
+			logger.Ctx(r.Context()).Warn("非管理员访问管理接口",
+				logger.Int64("userId", userID),
+				logger.String("username", username),
+				logger.String("path", r.URL.Path))
+			writeError(w, CodeForbidden, "Forbidden")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAdmin 判断用户ID是否在配置的管理员列表中
+func (h *APIHandler) isAdmin(userID int64) bool {
+	return userID > 0 && slices.Contains(h.cfg.AdminUserIDs, userID)
+}
+
 // GetUserIDFromContext extracts the user ID from the request context
 func GetUserIDFromContext(ctx context.Context) (int64, error) {
 	userID, ok := ctx.Value("userID").(int64)
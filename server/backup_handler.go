@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"Bt1QFM/core/backup"
+	"Bt1QFM/logger"
+)
+
+// SetBackupService 设置备份服务，供管理接口触发备份和查看状态
+func (h *APIHandler) SetBackupService(service *backup.Service) {
+	h.backupService = service
+}
+
+// TriggerBackupHandler 在后台开始一次备份，通过 GET /api/admin/backups 查看进度和结果
+func (h *APIHandler) TriggerBackupHandler(w http.ResponseWriter, r *http.Request) {
+	if h.backupService == nil {
+		writeError(w, CodeServiceUnavailable, "Backup service is not available")
+		return
+	}
+	if err := h.backupService.Trigger(); err == backup.ErrAlreadyRunning {
+		writeError(w, CodeConflict, "Backup is already running")
+		return
+	}
+
+	username, _ := GetUsernameFromContext(r.Context())
+	logger.Ctx(r.Context()).Info("管理员触发备份", logger.String("username", username))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Backup started",
+	})
+}
+
+// BackupStatusHandler 返回备份是否在执行、最近一次结果和备份目录中的备份文件
+func (h *APIHandler) BackupStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if h.backupService == nil {
+		writeError(w, CodeServiceUnavailable, "Backup service is not available")
+		return
+	}
+	status, err := h.backupService.Status()
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取备份状态失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get backup status")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    status,
+	})
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/core/auth"
+	"Bt1QFM/core/bandwidth"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultBandwidthLimit 管理员统计默认返回的用户数
+const defaultBandwidthLimit = 20
+
+// BandwidthHandler 用户流量统计处理器
+type BandwidthHandler struct {
+	service *bandwidth.Service
+}
+
+// NewBandwidthHandler 创建用户流量统计处理器
+func NewBandwidthHandler(service *bandwidth.Service) *BandwidthHandler {
+	return &BandwidthHandler{service: service}
+}
+
+// Meter 统计 /static/ 和 /streams/ 响应的字节数，计入请求携带的登录 Token 对应的用户
+// 这两个前缀无需登录即可访问，未携带有效 Token 的请求不计入任何用户
+// 配置了软上限时在响应头中返回当天用量，接近或超出时通过 X-Bandwidth-Warning 提示，不拒绝请求
+func (h *BandwidthHandler) Meter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		claims, err := auth.ParseToken(token)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.setSoftCapHeaders(w, r, claims.UserID)
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if err := h.service.Record(context.Background(), claims.UserID, rec.bytes); err != nil {
+			logger.Debug("记录用户流量失败",
+				logger.Int64("userId", claims.UserID),
+				logger.ErrorField(err))
+		}
+	})
+}
+
+// setSoftCapHeaders 配置了软上限时写入用户当天的流量用量和提示
+func (h *BandwidthHandler) setSoftCapHeaders(w http.ResponseWriter, r *http.Request, userID int64) {
+	softCap := h.service.SoftCapBytes()
+	if softCap <= 0 {
+		return
+	}
+	used, err := h.service.Today(r.Context(), userID, time.Now())
+	if err != nil {
+		return
+	}
+	w.Header().Set("X-Bandwidth-Used", strconv.FormatInt(used, 10))
+	w.Header().Set("X-Bandwidth-Soft-Cap", strconv.FormatInt(softCap, 10))
+	switch {
+	case used >= softCap:
+		w.Header().Set("X-Bandwidth-Warning", "daily soft cap exceeded")
+	case float64(used) >= float64(softCap)*bandwidth.WarnRatio:
+		w.Header().Set("X-Bandwidth-Warning", "approaching daily soft cap")
+	}
+}
+
+// parseBandwidthDays 解析 days 参数，未指定时使用默认值
+func (h *BandwidthHandler) parseBandwidthDays(w http.ResponseWriter, r *http.Request) (int, bool) {
+	days := 0
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, CodeBadRequest, "Invalid days")
+			return 0, false
+		}
+		days = n
+	}
+	return h.service.Days(days), true
+}
+
+// GetMyBandwidthHandler 返回当前用户最近每天的流量，GET /api/users/me/bandwidth?days=30
+func (h *BandwidthHandler) GetMyBandwidthHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	days, ok := h.parseBandwidthDays(w, r)
+	if !ok {
+		return
+	}
+
+	daily, err := h.service.UserUsage(r.Context(), userID, time.Now(), days)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取用户流量失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get bandwidth usage")
+		return
+	}
+	var total int64
+	for _, d := range daily {
+		total += d.Bytes
+	}
+	softCap := h.service.SoftCapBytes()
+	today := daily[len(daily)-1].Bytes
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"days":         days,
+			"totalBytes":   total,
+			"todayBytes":   today,
+			"softCapBytes": softCap, // 不限制时为 0
+			"exceeded":     softCap > 0 && today >= softCap,
+			"daily":        daily,
+		},
+	})
+}
+
+// AdminBandwidthHandler 返回全部用户最近每天的流量和流量最多的用户，GET /api/admin/bandwidth?days=30&limit=20
+func (h *BandwidthHandler) AdminBandwidthHandler(w http.ResponseWriter, r *http.Request) {
+	days, ok := h.parseBandwidthDays(w, r)
+	if !ok {
+		return
+	}
+	limit := defaultBandwidthLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, CodeBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, bandwidth.MaxLimit)
+	}
+
+	summary, err := h.service.Summarize(r.Context(), time.Now(), days, limit)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("统计用户流量失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get bandwidth usage")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"summary":      summary,
+			"softCapBytes": h.service.SoftCapBytes(),
+		},
+	})
+}
+
+// RegisterBandwidthRoutes 注册用户流量统计路由
+func RegisterBandwidthRoutes(router *mux.Router, handler *BandwidthHandler, authMiddleware, adminMiddleware func(http.HandlerFunc) http.HandlerFunc) {
+	router.HandleFunc("/api/users/me/bandwidth", authMiddleware(handler.GetMyBandwidthHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/bandwidth", adminMiddleware(handler.AdminBandwidthHandler)).Methods(http.MethodGet)
+
+	logger.Info("流量统计API端点注册完成",
+		logger.String("endpoints", "GET /api/users/me/bandwidth, GET /api/admin/bandwidth"))
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/captcha"
+	"Bt1QFM/logger"
+)
+
+// SetCaptchaVerifier 设置人机验证方式，未设置时注册和登录不需要验证
+func (h *APIHandler) SetCaptchaVerifier(verifier captcha.Verifier) {
+	h.captcha = verifier
+}
+
+// GetCaptchaHandler 返回完成人机验证所需的参数，GET /api/auth/captcha?for=login|register
+// required 表示当前请求是否需要验证：注册总是需要，登录在同一 IP 失败次数达到配置值后才需要；
+// 工作量证明每次请求都会签发新的挑战，挑战 5 分钟内有效且只能使用一次
+func (h *APIHandler) GetCaptchaHandler(w http.ResponseWriter, r *http.Request) {
+	if h.captcha == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data": map[string]interface{}{
+				"provider": captcha.ProviderNone,
+				"required": false,
+			},
+		})
+		return
+	}
+
+	required := true
+	if r.URL.Query().Get("for") == "login" {
+		required = h.loginNeedsCaptcha(r.Context(), clientIP(r, trustedProxies(h.cfg)))
+	}
+	challenge, err := h.captcha.Challenge(r.Context())
+	if err != nil {
+		logger.Ctx(r.Context()).Error("签发人机验证挑战失败", logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Captcha service unavailable")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"provider":   challenge.Provider,
+			"siteKey":    challenge.SiteKey,
+			"challenge":  challenge.Challenge,
+			"difficulty": challenge.Difficulty,
+			"expiresAt":  challenge.ExpiresAt,
+			"required":   required,
+		},
+	})
+}
+
+// verifyCaptcha 校验请求中的人机验证令牌，未通过时写入错误响应并返回 false
+func (h *APIHandler) verifyCaptcha(w http.ResponseWriter, r *http.Request, token string) bool {
+	if h.captcha == nil {
+		return true
+	}
+	details := map[string]interface{}{"provider": strings.ToLower(h.cfg.CaptchaProvider)}
+	if token == "" {
+		writeErrorDetails(w, CodeCaptchaRequired, "Captcha verification required", details)
+		return false
+	}
+
+	err := h.captcha.Verify(r.Context(), token, clientIP(r, trustedProxies(h.cfg)))
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, captcha.ErrInvalid):
+		logger.Ctx(r.Context()).Warn("人机验证未通过", logger.String("path", r.URL.Path), logger.ErrorField(err))
+		writeErrorDetails(w, CodeCaptchaInvalid, "Captcha verification failed", details)
+	default:
+		logger.Ctx(r.Context()).Error("人机验证服务不可用", logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Captcha service unavailable")
+	}
+	return false
+}
+
+// loginNeedsCaptcha 客户端 IP 登录时是否需要人机验证，按登录退避使用的 IP 失败次数判断，Redis 不可用时按需要处理
+// 登录成功不清除 IP 的失败次数，只随统计窗口过期，避免在两次猜测之间登录自己的账号绕过验证
+func (h *APIHandler) loginNeedsCaptcha(ctx context.Context, ip string) bool {
+	if h.captcha == nil || h.cfg.CaptchaLoginFailures < 0 {
+		return false
+	}
+	if h.cfg.CaptchaLoginFailures == 0 {
+		return true
+	}
+	failures, err := cache.GetLoginFailures(ctx, loginIPSubject(ip))
+	if err != nil {
+		logger.Warn("读取登录失败次数失败", logger.String("ip", ip), logger.ErrorField(err))
+		return true
+	}
+	return failures >= int64(h.cfg.CaptchaLoginFailures)
+}
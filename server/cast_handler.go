@@ -0,0 +1,443 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/core/cast"
+	"Bt1QFM/logger"
+	"Bt1QFM/repository"
+	"Bt1QFM/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// 投屏媒体格式
+const (
+	castFormatHLS = "hls"
+	castFormatMP4 = "mp4"
+)
+
+// castControlTimeout 单次控制渲染器的超时时间
+const castControlTimeout = 10 * time.Second
+
+// castTranscodeRetryAfter 转码池已满时建议渲染器重试的间隔（秒）
+const castTranscodeRetryAfter = 5
+
+// CastHandler 投屏处理器：搜索局域网渲染器、生成渲染器可直接拉取的签名地址并控制 DLNA 设备
+type CastHandler struct {
+	registry  *cast.Registry
+	trackRepo repository.TrackRepository
+	cfg       *config.Config
+}
+
+// NewCastHandler 创建投屏处理器
+func NewCastHandler(trackRepo repository.TrackRepository, cfg *config.Config) *CastHandler {
+	return &CastHandler{
+		registry:  cast.NewRegistry(time.Duration(cfg.CastDiscoveryTimeoutSeconds) * time.Second),
+		trackRepo: trackRepo,
+		cfg:       cfg,
+	}
+}
+
+// castMediaRequest 投屏媒体请求
+type castMediaRequest struct {
+	Source   string  `json:"source"`
+	SourceID string  `json:"sourceId"`
+	Format   string  `json:"format,omitempty"`   // hls、mp4，为空时自动选择
+	Position float64 `json:"position,omitempty"` // 加载后跳转到的位置（秒）
+	Title    string  `json:"title,omitempty"`    // 网易云歌曲可由客户端提供元数据
+	Artist   string  `json:"artist,omitempty"`
+	Cover    string  `json:"cover,omitempty"`
+}
+
+// ListRenderersHandler 返回局域网中的投屏设备，GET /api/cast/renderers?refresh=true
+// 搜索需要等待数秒，未指定 refresh 时返回缓存结果，从未搜索过时自动搜索
+func (h *CastHandler) ListRenderersHandler(w http.ResponseWriter, r *http.Request) {
+	renderers := h.registry.List()
+	if r.URL.Query().Get("refresh") == "true" || h.registry.LastScan().IsZero() {
+		var err error
+		renderers, err = h.registry.Refresh(r.Context())
+		if err != nil {
+			logger.Ctx(r.Context()).Error("搜索投屏设备失败", logger.ErrorField(err))
+			writeError(w, CodeServiceUnavailable, "Failed to discover renderers")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    renderers,
+	})
+}
+
+// CastMediaURLHandler 返回渲染器可直接拉取的签名地址，GET /api/cast/media?source=local&sourceId=1&format=mp4
+// Chromecast 等由客户端控制的设备使用该地址通过 Cast SDK 投屏
+func (h *CastHandler) CastMediaURLHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	media, expires, ok := h.resolveMedia(w, r, userID, &castMediaRequest{
+		Source:   query.Get("source"),
+		SourceID: query.Get("sourceId"),
+		Format:   query.Get("format"),
+	})
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"url":       media.URL,
+			"mimeType":  media.MimeType,
+			"title":     media.Title,
+			"artist":    media.Artist,
+			"cover":     media.Cover,
+			"duration":  media.Duration,
+			"expiresAt": expires,
+		},
+	})
+}
+
+// LoadHandler 在渲染器上加载并播放歌曲，POST /api/cast/renderers/{id}/load
+// 请求体 {"source": "local", "sourceId": "1", "format": "hls", "position": 30}
+func (h *CastHandler) LoadHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	renderer, ok := h.controllableRenderer(w, r)
+	if !ok {
+		return
+	}
+
+	var req castMediaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	media, _, ok := h.resolveMedia(w, r, userID, &req)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), castControlTimeout)
+	defer cancel()
+	err = renderer.Load(ctx, *media)
+	if err == nil {
+		err = renderer.Play(ctx)
+	}
+	if err == nil && req.Position > 0 {
+		// 部分渲染器在缓冲完成前不接受跳转，失败时从头播放
+		if seekErr := renderer.Seek(ctx, req.Position); seekErr != nil {
+			logger.Ctx(r.Context()).Warn("投屏跳转失败", logger.String("renderer", renderer.ID), logger.ErrorField(seekErr))
+		}
+	}
+	if err != nil {
+		logger.Ctx(r.Context()).Error("投屏加载失败",
+			logger.String("renderer", renderer.ID),
+			logger.String("source", req.Source),
+			logger.String("sourceId", req.SourceID),
+			logger.ErrorField(err))
+		writeError(w, CodeRendererError, "Renderer rejected the media")
+		return
+	}
+
+	logger.Ctx(r.Context()).Info("投屏开始播放",
+		logger.Int64("userId", userID),
+		logger.String("renderer", renderer.Name),
+		logger.String("source", req.Source),
+		logger.String("sourceId", req.SourceID))
+	writeCastOK(w)
+}
+
+// ControlHandler 控制渲染器，POST /api/cast/renderers/{id}/{action}
+// action 为 play、pause、stop、seek（请求体 {"position": 30}）或 volume（请求体 {"volume": 50}）
+func (h *CastHandler) ControlHandler(w http.ResponseWriter, r *http.Request) {
+	renderer, ok := h.controllableRenderer(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Position *float64 `json:"position"`
+		Volume   *int     `json:"volume"`
+	}
+	action := mux.Vars(r)["action"]
+	if action == "seek" || action == "volume" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, CodeInvalidBody, "Invalid request body")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), castControlTimeout)
+	defer cancel()
+
+	var err error
+	switch action {
+	case "play":
+		err = renderer.Play(ctx)
+	case "pause":
+		err = renderer.Pause(ctx)
+	case "stop":
+		err = renderer.Stop(ctx)
+	case "seek":
+		if req.Position == nil || *req.Position < 0 {
+			writeError(w, CodeMissingField, "Position is required")
+			return
+		}
+		err = renderer.Seek(ctx, *req.Position)
+	case "volume":
+		if req.Volume == nil || *req.Volume < 0 || *req.Volume > 100 {
+			writeError(w, CodeBadRequest, "Volume must be between 0 and 100")
+			return
+		}
+		err = renderer.SetVolume(ctx, *req.Volume)
+	default:
+		writeError(w, CodeBadRequest, "Invalid action, expected play, pause, stop, seek or volume")
+		return
+	}
+	if err != nil {
+		logger.Ctx(r.Context()).Warn("投屏控制失败",
+			logger.String("renderer", renderer.ID),
+			logger.String("action", action),
+			logger.ErrorField(err))
+		writeError(w, CodeRendererError, fmt.Sprintf("Renderer rejected %s", action))
+		return
+	}
+	writeCastOK(w)
+}
+
+// TranscodeHandler 为渲染器实时转码本地歌曲为分片 MP4（AAC），GET /cast/tracks/{id}.mp4?expires=...&signature=...
+// 渲染器无法携带登录信息，通过签名校验访问权限
+func (h *CastHandler) TranscodeHandler(w http.ResponseWriter, r *http.Request) {
+	trackID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid track ID")
+		return
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || !auth.VerifyStreamSignature(castTrackPath(trackID), expires, r.URL.Query().Get("signature")) {
+		writeError(w, CodeInvalidSignature, "Invalid or expired cast signature")
+		return
+	}
+
+	track, err := h.trackRepo.GetTrackByID(r.Context(), trackID)
+	if err != nil || track == nil || track.State != 1 || !strings.HasPrefix(track.FilePath, "/static/") {
+		writeError(w, CodeTrackNotFound, "Track not found")
+		return
+	}
+	// 渲染器常先发送 HEAD 探测类型，无需启动转码
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Type", "audio/mp4")
+		w.Header().Set("transferMode.dlna.org", "Streaming")
+		return
+	}
+
+	// 与其他实时转码共用转码池，池已满时不排队，由渲染器稍后重试
+	cmd, release, err := audio.TryTranscodeCommand(r.Context(), h.cfg.FFmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-vn",
+		"-c:a", "aac",
+		"-b:a", h.cfg.AudioBitrate,
+		"-movflags", "frag_keyframe+empty_moov+default_base_moof",
+		"-f", "mp4",
+		"pipe:1",
+	)
+	if err != nil {
+		logger.Ctx(r.Context()).Warn("转码池已满，拒绝投屏转码", logger.Int64("trackId", trackID))
+		w.Header().Set("Retry-After", strconv.Itoa(castTranscodeRetryAfter))
+		writeError(w, CodeServiceUnavailable, "Transcoder is busy, try again later")
+		return
+	}
+	defer release()
+
+	store := storage.GetStorage()
+	if store == nil {
+		writeError(w, CodeStorageUnavailable, "Storage not available")
+		return
+	}
+	source, err := store.Get(r.Context(), strings.TrimPrefix(track.FilePath, "/static/"))
+	if err != nil {
+		logger.Ctx(r.Context()).Error("读取投屏源文件失败", logger.Int64("trackId", trackID), logger.ErrorField(err))
+		writeError(w, CodeTrackNotFound, "Track file not found")
+		return
+	}
+	defer source.Close()
+
+	// 转码时长与歌曲时长相当，不受服务器 WriteTimeout 限制
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Ctx(r.Context()).Debug("无法取消写入超时", logger.ErrorField(err))
+	}
+
+	cmd.Stdin = source
+	cmd.Stdout = w
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	w.Header().Set("Content-Type", "audio/mp4")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("transferMode.dlna.org", "Streaming")
+	if err := audio.ObserveTranscode(r.Context(), cmd.Run()); err != nil && r.Context().Err() == nil {
+		logger.Ctx(r.Context()).Error("投屏转码失败",
+			logger.Int64("trackId", trackID),
+			logger.String("stderr", stderr.String()),
+			logger.ErrorField(err))
+	}
+}
+
+// resolveMedia 校验请求的歌曲并生成签名地址，失败时已写入错误响应
+func (h *CastHandler) resolveMedia(w http.ResponseWriter, r *http.Request, userID int64, req *castMediaRequest) (*cast.Media, int64, bool) {
+	id, err := strconv.ParseInt(req.SourceID, 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, CodeInvalidID, "Invalid sourceId")
+		return nil, 0, false
+	}
+	format := strings.ToLower(req.Format)
+	if format != "" && format != castFormatHLS && format != castFormatMP4 {
+		writeError(w, CodeBadRequest, "Invalid format, expected hls or mp4")
+		return nil, 0, false
+	}
+
+	ttl := time.Duration(h.cfg.StreamURLTTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = 6 * time.Hour
+	}
+	expires := time.Now().Add(ttl).Unix()
+	base := h.castBaseURL()
+
+	media := &cast.Media{Title: req.Title, Artist: req.Artist, Cover: req.Cover}
+	switch req.Source {
+	case cache.SourceLocal:
+		track, err := h.trackRepo.GetTrackByID(r.Context(), id)
+		if err != nil {
+			logger.Ctx(r.Context()).Error("获取投屏歌曲失败", logger.Int64("trackId", id), logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to get track")
+			return nil, 0, false
+		}
+		if track == nil || track.UserID != userID || track.State != 1 {
+			writeError(w, CodeTrackNotFound, "Track not found")
+			return nil, 0, false
+		}
+		media.Title, media.Artist, media.Album = track.Title, track.Artist, track.Album
+		media.Duration = int(track.Duration)
+		if strings.HasPrefix(track.CoverArtPath, "/") {
+			media.Cover = base + track.CoverArtPath
+		}
+
+		// 加密的 HLS 密钥需要登录才能获取，渲染器无法播放，改用 MP4
+		if format == "" {
+			format = castFormatHLS
+			if h.cfg.HLSEncryption {
+				format = castFormatMP4
+			}
+		}
+		if format == castFormatHLS && h.cfg.HLSEncryption {
+			writeError(w, CodeBadRequest, "Encrypted HLS streams cannot be cast, use mp4")
+			return nil, 0, false
+		}
+		if format == castFormatMP4 {
+			path := castTrackPath(id)
+			media.URL = fmt.Sprintf("%s%s?expires=%d&signature=%s", base, path, expires, auth.SignStreamPath(path, expires))
+			media.MimeType = "audio/mp4"
+		} else {
+			dir := fmt.Sprintf("/streams/%d/", id)
+			media.URL = base + dir + "playlist.m3u8?" + signStreamQuery(dir, expires)
+			media.MimeType = "application/vnd.apple.mpegurl"
+		}
+
+	case cache.SourceNetease:
+		if format == castFormatMP4 {
+			writeError(w, CodeBadRequest, "Netease songs can only be cast as hls")
+			return nil, 0, false
+		}
+		if h.cfg.HLSEncryption {
+			writeError(w, CodeBadRequest, "Encrypted HLS streams cannot be cast")
+			return nil, 0, false
+		}
+		if media.Title == "" {
+			if song, err := repository.NewNeteaseSongRepository().GetNeteaseSongByID(req.SourceID); err == nil && song != nil {
+				media.Title, media.Artist, media.Album = song.Title, song.Artist, song.Album
+				media.Duration = int(song.Duration)
+				if media.Cover == "" {
+					media.Cover = song.CoverArtPath
+				}
+			}
+		}
+		dir := fmt.Sprintf("/streams/netease/%d/", id)
+		media.URL = base + dir + "playlist.m3u8?" + signStreamQuery(dir, expires)
+		media.MimeType = "application/vnd.apple.mpegurl"
+
+	default:
+		writeError(w, CodeBadRequest, "Invalid source, expected local or netease")
+		return nil, 0, false
+	}
+
+	return media, expires, true
+}
+
+// controllableRenderer 按路径中的 ID 获取可由服务端控制的渲染器，失败时已写入错误响应
+func (h *CastHandler) controllableRenderer(w http.ResponseWriter, r *http.Request) (*cast.Renderer, bool) {
+	renderer := h.registry.Get(mux.Vars(r)["id"])
+	if renderer == nil {
+		writeError(w, CodeRendererNotFound, "Renderer not found, refresh the renderer list")
+		return nil, false
+	}
+	if !renderer.Controllable {
+		writeError(w, CodeBadRequest, cast.ErrUnsupported.Error()+", cast from the client with /api/cast/media instead")
+		return nil, false
+	}
+	return renderer, true
+}
+
+// castBaseURL 渲染器访问服务器使用的根地址
+func (h *CastHandler) castBaseURL() string {
+	if h.cfg.CastBaseURL != "" {
+		return strings.TrimRight(h.cfg.CastBaseURL, "/")
+	}
+	return strings.TrimRight(h.cfg.PublicBaseURL, "/")
+}
+
+// castTrackPath 本地歌曲转码地址的路径，同时作为签名内容
+func castTrackPath(trackID int64) string {
+	return fmt.Sprintf("/cast/tracks/%d.mp4", trackID)
+}
+
+// writeCastOK 返回控制成功
+func writeCastOK(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// RegisterCastRoutes 注册投屏相关路由
+func RegisterCastRoutes(router *mux.Router, handler *CastHandler, authMiddleware func(http.HandlerFunc) http.HandlerFunc) {
+	router.HandleFunc("/api/cast/renderers", authMiddleware(handler.ListRenderersHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/cast/media", authMiddleware(handler.CastMediaURLHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/cast/renderers/{id}/load", authMiddleware(handler.LoadHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/cast/renderers/{id}/{action}", authMiddleware(handler.ControlHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/cast/tracks/{id:[0-9]+}.mp4", handler.TranscodeHandler).Methods(http.MethodGet, http.MethodHead)
+
+	logger.Info("投屏API端点注册完成",
+		logger.String("endpoints", "GET /api/cast/renderers, GET /api/cast/media, POST /api/cast/renderers/{id}/{load|play|pause|stop|seek|volume}, GET /cast/tracks/{id}.mp4"))
+}
@@ -3,14 +3,19 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"Bt1QFM/config"
 	"Bt1QFM/core/agent"
-	"Bt1QFM/core/auth"
 	"Bt1QFM/core/plugin"
+	"Bt1QFM/core/speech"
+	"Bt1QFM/i18n"
 	"Bt1QFM/logger"
 	"Bt1QFM/model"
 	"Bt1QFM/repository"
@@ -23,7 +28,16 @@ type ChatHandler struct {
 	chatRepo    repository.ChatRepository
 	musicAgent  *agent.MusicAgent
 	upgrader    websocket.Upgrader
+	wsAuth      *wsAuthenticator
 	connections sync.Map // map[int64]*websocket.Conn - userID to connection
+	summarizing sync.Map // map[int64]struct{} - 正在生成摘要的会话
+	// messageLimit 每个用户发送聊天消息的频率限制，未设置时不限流
+	messageLimit config.RateLimitRule
+	// transcriber 语音消息的语音识别服务，为空时不接受语音消息
+	transcriber   speech.Transcriber
+	maxAudioBytes int
+	// clips 回复的语音合成服务，为空时不提供语音播报
+	clips *speech.ClipService
 }
 
 const (
@@ -36,38 +50,42 @@ const (
 	// 分层超时配置
 	softTimeout = 8 * time.Second  // 软超时：提示用户"AI思考中"
 	hardTimeout = 30 * time.Second // 硬超时：提示用户可以重试
+
+	// maxContextMessages 每次最多读取的未摘要历史消息数，再按 token 预算裁剪
+	maxContextMessages = 200
 )
 
 // NewChatHandler creates a new ChatHandler.
-func NewChatHandler(chatRepo repository.ChatRepository, agentConfig *agent.MusicAgentConfig) *ChatHandler {
+func NewChatHandler(chatRepo repository.ChatRepository, agentConfig *agent.MusicAgentConfig, wsAuth *wsAuthenticator) *ChatHandler {
 	return &ChatHandler{
 		chatRepo:   chatRepo,
 		musicAgent: agent.NewMusicAgent(agentConfig),
-		upgrader: websocket.Upgrader{
-			ReadBufferSize:  4096,  // 增加读缓冲
-			WriteBufferSize: 4096,  // 增加写缓冲
-			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow all origins for now
-			},
-		},
+		upgrader:   wsAuth.Upgrader(4096, 4096), // 增加读写缓冲
+		wsAuth:     wsAuth,
 	}
 }
 
+// SetMessageRateLimit 设置每个用户发送聊天消息的频率限制，保护 AI 服务不被刷量
+func (h *ChatHandler) SetMessageRateLimit(rule config.RateLimitRule) {
+	h.messageLimit = rule
+}
+
+// SetLibrary 允许 AI 助手读取用户的曲库、常听歌曲和播放列表
+func (h *ChatHandler) SetLibrary(trackRepo repository.TrackRepository, historyRepo repository.PlayHistoryRepository) {
+	h.musicAgent.SetLibrary(trackRepo, historyRepo)
+}
+
 // GetChatHistoryHandler returns the chat history for the current user.
 func (h *ChatHandler) GetChatHistoryHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := GetUserIDFromContext(r.Context())
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	// Get or create session
-	session, err := h.chatRepo.GetOrCreateSession(userID)
-	if err != nil {
-		logger.Error("Failed to get or create session",
-			logger.Int64("userID", userID),
-			logger.ErrorField(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	// ?sessionId= 选择会话，未指定时使用最近活跃的会话
+	session, ok := h.resolveSessionForRequest(w, r, userID, true)
+	if !ok {
 		return
 	}
 
@@ -77,7 +95,7 @@ func (h *ChatHandler) GetChatHistoryHandler(w http.ResponseWriter, r *http.Reque
 		logger.Error("Failed to get messages",
 			logger.Int64("sessionID", session.ID),
 			logger.ErrorField(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Internal server error")
 		return
 	}
 
@@ -94,16 +112,12 @@ func (h *ChatHandler) GetChatHistoryHandler(w http.ResponseWriter, r *http.Reque
 func (h *ChatHandler) ClearChatHistoryHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := GetUserIDFromContext(r.Context())
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	session, err := h.chatRepo.GetSessionByUserID(userID)
-	if err != nil {
-		logger.Error("Failed to get session",
-			logger.Int64("userID", userID),
-			logger.ErrorField(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	session, ok := h.resolveSessionForRequest(w, r, userID, false)
+	if !ok {
 		return
 	}
 
@@ -118,7 +132,7 @@ func (h *ChatHandler) ClearChatHistoryHandler(w http.ResponseWriter, r *http.Req
 		logger.Error("Failed to delete messages",
 			logger.Int64("sessionID", session.ID),
 			logger.ErrorField(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Internal server error")
 		return
 	}
 
@@ -132,33 +146,20 @@ func (h *ChatHandler) ClearChatHistoryHandler(w http.ResponseWriter, r *http.Req
 
 // WebSocketChatHandler handles WebSocket connections for streaming chat.
 func (h *ChatHandler) WebSocketChatHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract user info from query params (token validation)
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		http.Error(w, "Token required", http.StatusUnauthorized)
-		return
-	}
-
-	// Validate token and get user ID using auth package
-	claims, err := auth.ParseToken(token)
+	// Token 通过 Sec-WebSocket-Protocol 或首条认证消息传递，认证失败时连接已按关闭码关闭
+	conn, claims, err := h.wsAuth.Accept(w, r, &h.upgrader)
 	if err != nil {
-		logger.Warn("Invalid WebSocket token", logger.ErrorField(err))
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		logger.Warn("Chat WebSocket 认证失败", logger.ErrorField(err))
 		return
 	}
 	userID := claims.UserID
-
-	// Upgrade to WebSocket
-	conn, err := h.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		logger.Error("Failed to upgrade WebSocket",
-			logger.Int64("userID", userID),
-			logger.ErrorField(err))
-		return
-	}
+	r = r.WithContext(withRequestUser(r.Context(), userID, claims.Username))
+	// 回复语言：用户的语言偏好优先，其次是握手请求的 Accept-Language
+	r = applyUserLanguage(w, r, userID)
+	lang := i18n.FromContext(r.Context())
 
 	// Configure connection
-	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadLimit(h.readLimit())
 	conn.SetReadDeadline(time.Now().Add(pongWait))
 	conn.SetPongHandler(func(string) error {
 		conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -175,15 +176,26 @@ func (h *ChatHandler) WebSocketChatHandler(w http.ResponseWriter, r *http.Reques
 	logger.Info("WebSocket connected",
 		logger.Int64("userID", userID))
 
-	// Get or create session
-	session, err := h.chatRepo.GetOrCreateSession(userID)
+	// 握手时通过 ?sessionId= 选择会话，未指定时使用最近活跃的会话
+	session, err := h.resolveSession(userID, r.URL.Query().Get("sessionId"), true)
 	if err != nil {
-		logger.Error("Failed to get or create session",
+		logger.Warn("Failed to get chat session",
 			logger.Int64("userID", userID),
 			logger.ErrorField(err))
-		h.sendWebSocketError(conn, "Failed to initialize chat session")
+		if errors.Is(err, errChatSessionNotFound) {
+			h.sendWebSocketError(conn, "Chat session not found")
+		} else {
+			h.sendWebSocketError(conn, "Failed to initialize chat session")
+		}
 		return
 	}
+	h.sendWebSocketMessage(conn, model.WebSocketMessage{
+		Type:    "session",
+		Content: strconv.FormatInt(session.ID, 10),
+	})
+
+	// 握手时通过 ?tts=true 开启回复的语音播报
+	speak := h.clips != nil && r.URL.Query().Get("tts") == "true"
 
 	// Start ping goroutine to keep connection alive
 	done := make(chan struct{})
@@ -212,13 +224,29 @@ func (h *ChatHandler) WebSocketChatHandler(w http.ResponseWriter, r *http.Reques
 			continue
 		}
 
-		if msgReq.Content == "" {
+		if msgReq.Type != voiceMessageType && msgReq.Content == "" {
 			h.sendWebSocketError(conn, "Message content is required")
 			continue
 		}
 
+		// 限制消息频率，超限的消息不会发送给 AI
+		if h.messageLimit.Enabled() {
+			if allowed, retryAfter := checkRateLimit("chat", fmt.Sprintf("user:%d", userID), h.messageLimit); !allowed {
+				h.sendWebSocketError(conn, fmt.Sprintf("Too many messages, please retry in %d seconds", retryAfterSeconds(retryAfter)))
+				continue
+			}
+		}
+
+		// 语音消息先识别为文字，再按文字消息处理
+		content := msgReq.Content
+		if msgReq.Type == voiceMessageType {
+			if content = h.transcribeVoice(conn, userID, msgReq); content == "" {
+				continue
+			}
+		}
+
 		// Process the message
-		h.handleChatMessage(conn, session, userID, msgReq.Content)
+		h.handleChatMessage(conn, session, userID, content, speak, lang)
 	}
 }
 
@@ -241,7 +269,9 @@ func (h *ChatHandler) pingLoop(conn *websocket.Conn, done chan struct{}) {
 }
 
 // handleChatMessage processes a chat message and streams the response.
-func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, session *model.ChatSession, userID int64, content string) {
+// When speak is set the reply is also synthesized and referenced by the "end" message.
+// lang selects the language of the agent prompts.
+func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, session *model.ChatSession, userID int64, content string, speak bool, lang string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
@@ -262,8 +292,8 @@ func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, session *model.Cha
 	userMsg.ID = userMsgID
 	userMsg.CreatedAt = time.Now()
 
-	// Get history for context
-	history, err := h.chatRepo.GetMessagesBySessionID(session.ID, 50)
+	// Get history for context (messages already covered by the summary are skipped)
+	history, err := h.chatRepo.GetMessagesAfter(session.ID, session.SummaryUntil, maxContextMessages)
 	if err != nil {
 		logger.Error("Failed to get history",
 			logger.Int64("sessionID", session.ID),
@@ -277,6 +307,19 @@ func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, session *model.Cha
 		history = history[:len(history)-1]
 	}
 
+	// 会话的第一条消息：与回复并行生成标题
+	var titleCh <-chan string
+	if len(history) == 0 && session.SummaryUntil == 0 && session.Title == model.DefaultChatSessionTitle {
+		titleCh = h.generateSessionTitle(session, content, lang)
+	}
+
+	// 历史超出 token 预算时只发送最近的消息，更早的消息在后台合并进会话摘要
+	window, stale := h.musicAgent.SplitHistory(history)
+	if len(stale) > 0 {
+		h.summarizeSession(session, stale)
+	}
+	chatCtx := agent.ChatContext{Summary: session.Summary, History: window, Language: lang}
+
 	// Send start signal
 	h.sendWebSocketMessage(conn, model.WebSocketMessage{
 		Type:    "start",
@@ -290,6 +333,75 @@ func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, session *model.Cha
 	// 启动超时检测 goroutine
 	go h.timeoutWatcher(conn, firstChunkReceived, ctx)
 
+	markFirstChunk := func() {
+		firstChunkOnce.Do(func() {
+			close(firstChunkReceived)
+		})
+	}
+
+	// 模型服务支持原生工具调用时使用工具，否则解析回复中的 <search_music> 标签
+	var cleanContent, searchQuery string
+	var finalSongCards []model.SongCard
+	if h.musicAgent.SupportsTools() {
+		cleanContent, finalSongCards, searchQuery, err = h.streamWithTools(ctx, conn, userID, chatCtx, content, markFirstChunk)
+	} else {
+		cleanContent, finalSongCards, searchQuery, err = h.streamWithTags(ctx, conn, userID, chatCtx, content, markFirstChunk)
+	}
+	if err != nil {
+		logger.Error("Failed to get AI response",
+			logger.Int64("userID", userID),
+			logger.ErrorField(err))
+			h.sendWebSocketError(conn, "Failed to get AI response: "+err.Error())
+		return
+	}
+
+	// Save assistant message (保存清理后的内容和歌曲数据)
+	assistantMsg := &model.ChatMessage{
+		SessionID: session.ID,
+		Role:      "assistant",
+		Content:   cleanContent,      // 保存不含标签的内容
+		Songs:     finalSongCards,    // 保存歌曲卡片数据
+	}
+	assistantMsgID, err := h.chatRepo.CreateMessage(assistantMsg)
+	if err != nil {
+		logger.Error("Failed to save assistant message",
+			logger.Int64("userID", userID),
+			logger.ErrorField(err))
+		// Don't return error to user since they already got the response
+	}
+	assistantMsg.ID = assistantMsgID
+	assistantMsg.CreatedAt = time.Now()
+
+	if titleCh != nil {
+		if title := <-titleCh; title != "" {
+			h.sendWebSocketMessage(conn, model.WebSocketMessage{
+				Type:    "title",
+				Content: title,
+			})
+		}
+	}
+
+	// Send end signal
+	var audioURL string
+	if speak {
+		audioURL = h.speakReply(userID, cleanContent)
+	}
+	h.sendWebSocketMessage(conn, model.WebSocketMessage{
+		Type:     "end",
+		Content:  "",
+		AudioURL: audioURL,
+	})
+
+	logger.Info("Chat message processed",
+		logger.Int64("userID", userID),
+		logger.Int("responseLength", len(cleanContent)),
+		logger.String("musicQuery", searchQuery),
+		logger.Int("songsCount", len(finalSongCards)))
+}
+
+// streamWithTags 流式回复并实时解析 <search_music> 标签，用于不支持工具调用的模型服务
+// 返回去掉标签的回复、歌曲卡片和搜索关键词
+func (h *ChatHandler) streamWithTags(ctx context.Context, conn *websocket.Conn, userID int64, chatCtx agent.ChatContext, content string, markFirstChunk func()) (string, []model.SongCard, string, error) {
 	// 流式解析状态：实时检测 <search_music> 标签
 	var streamBuffer strings.Builder      // 累积的完整响应
 	var searchMusicTriggered bool         // 标记是否已触发搜索
@@ -298,12 +410,9 @@ func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, session *model.Cha
 	var searchMu sync.Mutex               // 保护并发访问
 
 	// Stream response from AI
-	var fullResponse string
-	fullResponse, err = h.musicAgent.ChatStream(ctx, history, content, func(chunk string) error {
+	fullResponse, err := h.musicAgent.ChatStream(ctx, chatCtx, content, func(chunk string) error {
 		// 标记已收到首个响应
-		firstChunkOnce.Do(func() {
-			close(firstChunkReceived)
-		})
+		markFirstChunk()
 
 		// 累积响应文本
 		searchMu.Lock()
@@ -348,11 +457,7 @@ func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, session *model.Cha
 	})
 
 	if err != nil {
-		logger.Error("Failed to get AI response",
-			logger.Int64("userID", userID),
-			logger.ErrorField(err))
-			h.sendWebSocketError(conn, "Failed to get AI response: "+err.Error())
-		return
+		return "", nil, "", err
 	}
 
 	// 如果流式过程中未触发搜索，在结束后再解析一次（兜底）
@@ -381,35 +486,7 @@ func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, session *model.Cha
 
 	// 解析清理后的内容（移除标签）
 	cleanContent, _ := h.musicAgent.ParseSearchMusic(fullResponse)
-
-	// Save assistant message (保存清理后的内容和歌曲数据)
-	assistantMsg := &model.ChatMessage{
-		SessionID: session.ID,
-		Role:      "assistant",
-		Content:   cleanContent,      // 保存不含标签的内容
-		Songs:     finalSongCards,    // 保存歌曲卡片数据
-	}
-	assistantMsgID, err := h.chatRepo.CreateMessage(assistantMsg)
-	if err != nil {
-		logger.Error("Failed to save assistant message",
-			logger.Int64("userID", userID),
-			logger.ErrorField(err))
-		// Don't return error to user since they already got the response
-	}
-	assistantMsg.ID = assistantMsgID
-	assistantMsg.CreatedAt = time.Now()
-
-	// Send end signal
-	h.sendWebSocketMessage(conn, model.WebSocketMessage{
-		Type:    "end",
-		Content: "",
-	})
-
-	logger.Info("Chat message processed",
-		logger.Int64("userID", userID),
-		logger.Int("responseLength", len(fullResponse)),
-		logger.String("musicQuery", searchQuery),
-		logger.Int("songsCount", len(finalSongCards)))
+	return cleanContent, finalSongCards, searchQuery, nil
 }
 
 // timeoutWatcher 监控首响应超时，发送分层超时提示
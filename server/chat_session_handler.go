@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/core/agent"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxChatTitleRunes 用户自定义会话标题的最大长度
+	maxChatTitleRunes = 100
+	// titleTimeout 生成会话标题的最长时间，超时后用第一条消息截断作为标题
+	titleTimeout = 15 * time.Second
+	// summaryTimeout 生成会话摘要的最长时间
+	summaryTimeout = time.Minute
+)
+
+// errChatSessionNotFound 会话不存在或不属于当前用户
+var errChatSessionNotFound = errors.New("chat session not found")
+
+// resolveSession 按 ID 获取用户的会话；rawID 为空时使用最近活跃的会话，create 为 true 时没有会话则新建
+// 不存在时返回 errChatSessionNotFound，create 为 false 且用户没有会话时返回 nil
+func (h *ChatHandler) resolveSession(userID int64, rawID string, create bool) (*model.ChatSession, error) {
+	if rawID == "" {
+		if create {
+			return h.chatRepo.GetOrCreateSession(userID)
+		}
+		return h.chatRepo.GetSessionByUserID(userID)
+	}
+
+	sessionID, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return nil, errChatSessionNotFound
+	}
+	session, err := h.chatRepo.GetUserSession(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, errChatSessionNotFound
+	}
+	return session, nil
+}
+
+// resolveSessionForRequest 读取 ?sessionId= 并获取会话，失败时写入错误响应并返回 false
+func (h *ChatHandler) resolveSessionForRequest(w http.ResponseWriter, r *http.Request, userID int64, create bool) (*model.ChatSession, bool) {
+	session, err := h.resolveSession(userID, r.URL.Query().Get("sessionId"), create)
+	if errors.Is(err, errChatSessionNotFound) {
+		writeError(w, CodeChatSessionNotFound, "Chat session not found")
+		return nil, false
+	}
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取聊天会话失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Internal server error")
+		return nil, false
+	}
+	return session, true
+}
+
+// sessionFromPath 获取路径中 {id} 指定的会话，失败时写入错误响应并返回 nil
+func (h *ChatHandler) sessionFromPath(w http.ResponseWriter, r *http.Request, userID int64) *model.ChatSession {
+	session, err := h.resolveSession(userID, mux.Vars(r)["id"], false)
+	if errors.Is(err, errChatSessionNotFound) {
+		writeError(w, CodeChatSessionNotFound, "Chat session not found")
+		return nil
+	}
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取聊天会话失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Internal server error")
+		return nil
+	}
+	return session
+}
+
+// ListSessionsHandler 列出当前用户的会话，最近活跃的在前，GET /api/chat/sessions?archived=true
+// 默认不包含已归档的会话
+func (h *ChatHandler) ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	sessions, err := h.chatRepo.ListSessions(userID, r.URL.Query().Get("archived") == "true")
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取聊天会话列表失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to list chat sessions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    sessions,
+	})
+}
+
+// CreateSessionHandler 新建会话，POST /api/chat/sessions
+// 请求体 {"title": "..."} 可选，未提供时根据第一条消息自动生成标题
+func (h *ChatHandler) CreateSessionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Title string `json:"title"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, CodeInvalidBody, "Invalid request body")
+			return
+		}
+	}
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = model.DefaultChatSessionTitle
+	}
+	if len([]rune(title)) > maxChatTitleRunes {
+		writeError(w, CodeBadRequest, "Title is too long")
+		return
+	}
+
+	session, err := h.chatRepo.CreateSession(userID, title)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("创建聊天会话失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to create chat session")
+		return
+	}
+
+	logger.Ctx(r.Context()).Info("已创建聊天会话", logger.Int64("sessionID", session.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    session,
+	})
+}
+
+// UpdateSessionHandler 重命名、归档或恢复会话，PATCH /api/chat/sessions/{id}
+// 请求体 {"title": "...", "archived": true}，字段均可选
+func (h *ChatHandler) UpdateSessionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Title    *string `json:"title"`
+		Archived *bool   `json:"archived"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if req.Title == nil && req.Archived == nil {
+		writeError(w, CodeMissingField, "Title or archived is required")
+		return
+	}
+	var title string
+	if req.Title != nil {
+		title = strings.TrimSpace(*req.Title)
+		if title == "" {
+			writeError(w, CodeMissingField, "Title must not be empty")
+			return
+		}
+		if len([]rune(title)) > maxChatTitleRunes {
+			writeError(w, CodeBadRequest, "Title is too long")
+			return
+		}
+	}
+
+	session := h.sessionFromPath(w, r, userID)
+	if session == nil {
+		return
+	}
+	if req.Title != nil {
+		if err := h.chatRepo.RenameSession(session.ID, title); err != nil {
+			logger.Ctx(r.Context()).Error("重命名聊天会话失败", logger.Int64("sessionID", session.ID), logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to update chat session")
+			return
+		}
+	}
+	if req.Archived != nil {
+		if err := h.chatRepo.SetSessionArchived(session.ID, *req.Archived); err != nil {
+			logger.Ctx(r.Context()).Error("归档聊天会话失败", logger.Int64("sessionID", session.ID), logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to update chat session")
+			return
+		}
+	}
+
+	updated, err := h.chatRepo.GetUserSession(userID, session.ID)
+	if err != nil || updated == nil {
+		logger.Ctx(r.Context()).Error("获取聊天会话失败", logger.Int64("sessionID", session.ID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to update chat session")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    updated,
+	})
+}
+
+// DeleteSessionHandler 删除会话及其全部消息，DELETE /api/chat/sessions/{id}
+func (h *ChatHandler) DeleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	session := h.sessionFromPath(w, r, userID)
+	if session == nil {
+		return
+	}
+	if err := h.chatRepo.DeleteSession(session.ID); err != nil {
+		logger.Ctx(r.Context()).Error("删除聊天会话失败", logger.Int64("sessionID", session.ID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to delete chat session")
+		return
+	}
+
+	logger.Ctx(r.Context()).Info("已删除聊天会话", logger.Int64("sessionID", session.ID))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// generateSessionTitle 在后台根据第一条消息生成会话标题，模型不可用时截断消息作为标题
+// 用户在此期间已重命名会话时不覆盖；返回的 channel 收到实际写入的标题，未写入时为空字符串
+func (h *ChatHandler) generateSessionTitle(session *model.ChatSession, firstMessage, lang string) <-chan string {
+	ch := make(chan string, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+
+		title, err := h.musicAgent.GenerateTitle(ctx, firstMessage, lang)
+		if err != nil {
+			logger.Warn("生成会话标题失败，使用消息内容作为标题",
+				logger.Int64("sessionID", session.ID),
+				logger.ErrorField(err))
+			title = agent.TruncateTitle(firstMessage)
+		}
+
+		set, err := h.chatRepo.SetGeneratedTitle(session.ID, title)
+		if err != nil {
+			logger.Warn("保存会话标题失败", logger.Int64("sessionID", session.ID), logger.ErrorField(err))
+		}
+		if !set {
+			title = ""
+		}
+		ch <- title
+	}()
+	return ch
+}
+
+// summarizeSession 在后台把超出上下文预算的早期消息合并进会话摘要，同一会话同时只生成一份
+// 失败时不影响对话，下一条消息会再次尝试
+func (h *ChatHandler) summarizeSession(session *model.ChatSession, stale []*model.ChatMessage) {
+	if _, running := h.summarizing.LoadOrStore(session.ID, struct{}{}); running {
+		return
+	}
+	go func() {
+		defer h.summarizing.Delete(session.ID)
+		ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+		defer cancel()
+
+		summary, err := h.musicAgent.Summarize(ctx, session.Summary, stale)
+		if err != nil {
+			logger.Warn("生成会话摘要失败", logger.Int64("sessionID", session.ID), logger.ErrorField(err))
+			return
+		}
+		until := stale[len(stale)-1].ID
+		if _, err := h.chatRepo.UpdateSummary(session.ID, summary, until); err != nil {
+			logger.Warn("保存会话摘要失败", logger.Int64("sessionID", session.ID), logger.ErrorField(err))
+			return
+		}
+		logger.Info("已更新会话摘要",
+			logger.Int64("sessionID", session.ID),
+			logger.Int64("summaryUntil", until),
+			logger.Int("messages", len(stale)))
+	}()
+}
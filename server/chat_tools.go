@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/agent"
+	"Bt1QFM/core/plugin"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/websocket"
+)
+
+// streamWithTools 以工具调用模式流式回复，模型搜索或加入播放列表的歌曲实时以卡片推送给客户端
+// 返回回复文本、歌曲卡片和搜索关键词
+func (h *ChatHandler) streamWithTools(ctx context.Context, conn *websocket.Conn, userID int64, chatCtx agent.ChatContext, content string, markFirstChunk func()) (string, []model.SongCard, string, error) {
+	var cards []model.SongCard
+	var queries []string
+	shown := make(map[string]bool)
+
+	reply, err := h.musicAgent.ChatStreamWithTools(ctx, chatCtx, content, agent.ToolContext{UserID: userID},
+		func(chunk string) error {
+			markFirstChunk()
+			return h.sendWebSocketMessage(conn, model.WebSocketMessage{
+				Type:    "content",
+				Content: chunk,
+			})
+		},
+		func(result agent.ToolResult) {
+			if result.Err != nil {
+				logger.Warn("[ChatHandler] 工具调用失败",
+					logger.Int64("userID", userID),
+					logger.String("tool", result.Name),
+					logger.ErrorField(result.Err))
+				return
+			}
+			if result.Name == agent.ToolSearchMusic {
+				queries = append(queries, result.Query)
+			}
+			if len(result.Songs) == 0 {
+				return
+			}
+
+			// 每次搜索只展示第一首；加入播放列表的歌曲总是推送，客户端据此刷新播放列表
+			song := result.Songs[0]
+			msgType := "songs"
+			if result.Name == agent.ToolQueueSong {
+				msgType = "queued"
+			} else if shown[song.Source+":"+song.ID] {
+				return
+			}
+			newCards := h.convertToSongCards([]plugin.PluginSong{song})
+			if song.Source == cache.SourceNetease {
+				newCards = h.convertToSongCardsWithDetail([]plugin.PluginSong{song})
+			}
+			h.sendSongCards(conn, userID, msgType, newCards)
+			if !shown[song.Source+":"+song.ID] {
+				shown[song.Source+":"+song.ID] = true
+				cards = append(cards, newCards...)
+			}
+		})
+	if err != nil && reply == "" {
+		return "", nil, "", err
+	}
+	if err != nil {
+		// 已输出部分回复，保留已输出的内容
+		logger.Warn("[ChatHandler] 工具调用模式回复中断",
+			logger.Int64("userID", userID),
+			logger.ErrorField(err))
+	}
+	return reply, cards, strings.Join(queries, "; "), nil
+}
+
+// sendSongCards 推送歌曲卡片
+func (h *ChatHandler) sendSongCards(conn *websocket.Conn, userID int64, msgType string, cards []model.SongCard) {
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := conn.WriteJSON(model.ChatMessageWithSongs{
+		Type:  msgType,
+		Songs: cards,
+	}); err != nil {
+		logger.Error("[ChatHandler] 发送歌曲卡片失败",
+			logger.Int64("userID", userID),
+			logger.ErrorField(err))
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"Bt1QFM/core/speech"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// voiceMessageType 语音消息的 type 字段
+	voiceMessageType = "voice"
+	// transcribeTimeout 识别一条语音的最长时间
+	transcribeTimeout = 30 * time.Second
+	// speakTimeout 合成一条回复语音的最长时间，超时后只返回文字回复
+	speakTimeout = 20 * time.Second
+)
+
+// SetTranscriber 启用语音消息，maxAudioBytes 为单条语音解码后的最大字节数
+func (h *ChatHandler) SetTranscriber(transcriber speech.Transcriber, maxAudioBytes int) {
+	h.transcriber = transcriber
+	h.maxAudioBytes = maxAudioBytes
+}
+
+// SetClipService 启用回复的语音播报
+func (h *ChatHandler) SetClipService(clips *speech.ClipService) {
+	h.clips = clips
+}
+
+// readLimit WebSocket 单条消息的最大长度，启用语音消息时放宽到能容纳 base64 编码的语音
+func (h *ChatHandler) readLimit() int64 {
+	if h.transcriber == nil {
+		return maxMessageSize
+	}
+	return int64(base64.StdEncoding.EncodedLen(h.maxAudioBytes)) + maxMessageSize
+}
+
+// transcribeVoice 识别语音消息并把识别结果以 "transcript" 消息推送给客户端
+// 失败时推送错误并返回空字符串
+func (h *ChatHandler) transcribeVoice(conn *websocket.Conn, userID int64, req model.ChatMessageRequest) string {
+	if h.transcriber == nil {
+		h.sendWebSocketError(conn, "Voice messages are not supported")
+		return ""
+	}
+	format := strings.ToLower(strings.TrimPrefix(req.Format, "."))
+	if _, ok := speech.AudioFormats[format]; !ok {
+		h.sendWebSocketError(conn, "Unsupported audio format")
+		return ""
+	}
+	audio, err := base64.StdEncoding.DecodeString(req.Audio)
+	if err != nil || len(audio) == 0 {
+		h.sendWebSocketError(conn, "Invalid audio data")
+		return ""
+	}
+	if len(audio) > h.maxAudioBytes {
+		h.sendWebSocketError(conn, "Voice message is too long")
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), transcribeTimeout)
+	defer cancel()
+	start := time.Now()
+	text, err := h.transcriber.Transcribe(ctx, audio, format)
+	if err != nil {
+		logger.Error("[ChatHandler] 语音识别失败",
+			logger.Int64("userID", userID),
+			logger.String("provider", h.transcriber.Name()),
+			logger.ErrorField(err))
+		h.sendWebSocketError(conn, "Failed to transcribe voice message")
+		return ""
+	}
+	if text == "" {
+		h.sendWebSocketError(conn, "No speech recognized")
+		return ""
+	}
+
+	logger.Info("[ChatHandler] 语音识别完成",
+		logger.Int64("userID", userID),
+		logger.Int("audioBytes", len(audio)),
+		logger.Duration("elapsed", time.Since(start)))
+	h.sendWebSocketMessage(conn, model.WebSocketMessage{
+		Type:    "transcript",
+		Content: text,
+	})
+	return text
+}
+
+// speakReply 把回复合成为语音，返回播放地址；失败时只记录日志，客户端仍有文字回复
+func (h *ChatHandler) speakReply(userID int64, reply string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), speakTimeout)
+	defer cancel()
+	audioURL, err := h.clips.Clip(ctx, reply)
+	if err != nil {
+		logger.Warn("[ChatHandler] 语音合成失败",
+			logger.Int64("userID", userID),
+			logger.String("provider", h.clips.Name()),
+			logger.ErrorField(err))
+		return ""
+	}
+	return audioURL
+}
@@ -0,0 +1,284 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultCommentLimit 默认每页返回的评论数
+const defaultCommentLimit = 20
+
+// CreateCommentRequest 发表评论请求，position 为空时尝试从内容开头解析时间点（如 "1:23 this drop!"）
+// parentId 不为空时表示回复同一首歌下的另一条评论，被回复的作者会收到通知
+type CreateCommentRequest struct {
+	Content  string   `json:"content"`
+	Position *float64 `json:"position,omitempty"`
+	ParentID *int64   `json:"parentId,omitempty"`
+}
+
+// GetTrackCommentsHandler 分页获取本地曲目的评论，GET /api/tracks/{id}/comments?limit=20&offset=0&sort=position
+func (h *APIHandler) GetTrackCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	track, ok := h.loadCommentTrack(w, r)
+	if !ok {
+		return
+	}
+	h.writeComments(w, r, cache.SourceLocal, strconv.FormatInt(track.ID, 10))
+}
+
+// AddTrackCommentHandler 为本地曲目发表评论
+func (h *APIHandler) AddTrackCommentHandler(w http.ResponseWriter, r *http.Request) {
+	track, ok := h.loadCommentTrack(w, r)
+	if !ok {
+		return
+	}
+	h.createComment(w, r, cache.SourceLocal, strconv.FormatInt(track.ID, 10), track.Title, float64(track.Duration))
+}
+
+// GetNeteaseCommentsHandler 分页获取网易云歌曲在本实例内的评论
+func (h *APIHandler) GetNeteaseCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	songID, ok := parseNeteaseSongID(w, r)
+	if !ok {
+		return
+	}
+	h.writeComments(w, r, cache.SourceNetease, songID)
+}
+
+// AddNeteaseCommentHandler 为网易云歌曲发表评论
+func (h *APIHandler) AddNeteaseCommentHandler(w http.ResponseWriter, r *http.Request) {
+	songID, ok := parseNeteaseSongID(w, r)
+	if !ok {
+		return
+	}
+	h.createComment(w, r, cache.SourceNetease, songID, "", 0)
+}
+
+// DeleteCommentHandler 删除评论，只有评论作者和管理员可以删除
+func (h *APIHandler) DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	commentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid comment ID")
+		return
+	}
+
+	comment, err := h.commentRepo.GetCommentByID(r.Context(), commentID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取评论失败", logger.Int64("commentId", commentID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get comment")
+		return
+	}
+	if comment == nil {
+		writeError(w, CodeNotFound, "Comment not found")
+		return
+	}
+	if comment.UserID != userID && !h.isAdmin(userID) {
+		writeError(w, CodeForbidden, "Only the author or an admin can delete this comment")
+		return
+	}
+
+	if err := h.commentRepo.DeleteComment(r.Context(), commentID); err != nil {
+		logger.Ctx(r.Context()).Error("删除评论失败", logger.Int64("commentId", commentID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to delete comment")
+		return
+	}
+	if h.socialService != nil {
+		h.socialService.Remove(r.Context(), model.ActivityCommentPosted, strconv.FormatInt(commentID, 10))
+	}
+
+	logger.Ctx(r.Context()).Info("评论已删除",
+		logger.Int64("commentId", commentID),
+		logger.Int64("authorId", comment.UserID),
+		logger.Int64("deletedBy", userID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadCommentTrack 解析路径中的曲目ID并加载有效曲目，失败时已写入响应
+// 与分享链接一致，登录用户可以查看和评论任意有效曲目
+func (h *APIHandler) loadCommentTrack(w http.ResponseWriter, r *http.Request) (*model.Track, bool) {
+	trackID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid track ID")
+		return nil, false
+	}
+	track, err := h.trackRepo.GetTrackByID(r.Context(), trackID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取track失败",
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get track")
+		return nil, false
+	}
+	if track == nil || track.State == 0 {
+		writeError(w, CodeTrackNotFound, "Track not found")
+		return nil, false
+	}
+	return track, true
+}
+
+// parseNeteaseSongID 校验路径中的网易云歌曲ID，失败时已写入响应
+func parseNeteaseSongID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	songID := mux.Vars(r)["id"]
+	if id, err := strconv.ParseInt(songID, 10, 64); err != nil || id <= 0 {
+		writeError(w, CodeInvalidID, "Invalid song ID")
+		return "", false
+	}
+	return songID, true
+}
+
+// writeComments 按分页参数返回一首歌的评论和评论总数
+func (h *APIHandler) writeComments(w http.ResponseWriter, r *http.Request, source, sourceID string) {
+	limit := defaultCommentLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, CodeBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, model.MaxCommentLimit)
+	}
+	offset := 0
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeError(w, CodeBadRequest, "Invalid offset")
+			return
+		}
+		offset = n
+	}
+	// sort=position 只返回带时间点的评论并按时间点排序，供进度条上标注
+	byPosition := r.URL.Query().Get("sort") == "position"
+
+	comments, total, err := h.commentRepo.GetComments(r.Context(), source, sourceID, byPosition, limit, offset)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取评论失败",
+			logger.String("source", source),
+			logger.String("sourceId", sourceID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get comments")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":  comments,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// createComment 校验并保存评论，记录到关注者的动态流并通知被回复的用户，duration 大于 0 时时间点不能超过歌曲时长
+func (h *APIHandler) createComment(w http.ResponseWriter, r *http.Request, source, sourceID, title string, duration float64) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req CreateCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		writeError(w, CodeMissingField, "Missing 'content'")
+		return
+	}
+	if len([]rune(content)) > model.MaxCommentLength {
+		writeError(w, CodeBadRequest, fmt.Sprintf("Comment must be at most %d characters", model.MaxCommentLength))
+		return
+	}
+
+	inSong := func(seconds float64) bool {
+		return seconds >= 0 && (duration <= 0 || seconds <= duration)
+	}
+	position := req.Position
+	if position != nil && !inSong(*position) {
+		writeError(w, CodeBadRequest, "Position is outside the song")
+		return
+	}
+	// 内容开头的时间点超出歌曲时长时按普通文字处理
+	if seconds, ok := model.ParseCommentTimestamp(content); position == nil && ok && inSong(seconds) {
+		position = &seconds
+	}
+
+	var parent *model.Comment
+	if req.ParentID != nil {
+		parent, err = h.commentRepo.GetCommentByID(r.Context(), *req.ParentID)
+		if err != nil {
+			logger.Ctx(r.Context()).Error("获取被回复的评论失败", logger.Int64("parentId", *req.ParentID), logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to get comment")
+			return
+		}
+		if parent == nil || parent.Source != source || parent.SourceID != sourceID {
+			writeError(w, CodeBadRequest, "Parent comment not found on this song")
+			return
+		}
+	}
+
+	comment := &model.Comment{
+		UserID:   userID,
+		Source:   source,
+		SourceID: sourceID,
+		Content:  content,
+		Position: position,
+		ParentID: req.ParentID,
+	}
+	id, err := h.commentRepo.CreateComment(r.Context(), comment)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("发表评论失败",
+			logger.String("source", source),
+			logger.String("sourceId", sourceID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to create comment")
+		return
+	}
+
+	created, err := h.commentRepo.GetCommentByID(r.Context(), id)
+	if err != nil || created == nil {
+		logger.Ctx(r.Context()).Error("获取新评论失败", logger.Int64("commentId", id), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get comment")
+		return
+	}
+
+	if h.socialService != nil {
+		h.socialService.Record(r.Context(), &model.Activity{
+			UserID:   userID,
+			Type:     model.ActivityCommentPosted,
+			ObjectID: strconv.FormatInt(id, 10),
+			Source:   source,
+			SourceID: sourceID,
+			Title:    title,
+			Content:  content,
+		})
+	}
+	if parent != nil && h.notifications != nil {
+		h.notifications.Notify(r.Context(), &model.Notification{
+			UserID:    parent.UserID,
+			Type:      model.NotificationCommentReply,
+			ActorID:   userID,
+			ActorName: created.Username,
+			ObjectID:  strconv.FormatInt(id, 10),
+			Title:     title,
+			Content:   content,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
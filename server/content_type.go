@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// contentTypeSniffLen 推断内容类型时读取的字节数，与 http.DetectContentType 一致
+const contentTypeSniffLen = 512
+
+// storedContentTypes 对象存储中常见文件的内容类型，不依赖系统的 mime.types（精简镜像中通常没有 .flac 等条目）
+var storedContentTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".flac": "audio/flac",
+	".wav":  "audio/wav",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".aif":  "audio/aiff",
+	".aiff": "audio/aiff",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+	".gif":  "image/gif",
+	".avif": "image/avif",
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/MP2T",
+	".m4s":  "audio/mp4",
+	".json": "application/json",
+	".lrc":  "text/plain; charset=utf-8",
+	".txt":  "text/plain; charset=utf-8",
+}
+
+// activeContentTypes 浏览器会执行脚本的类型，从存储直接返回时强制下载，避免上传的文件在本站域名下执行
+var activeContentTypes = map[string]bool{
+	"text/html":              true,
+	"application/xhtml+xml":  true,
+	"image/svg+xml":          true,
+	"text/xml":               true,
+	"application/xml":        true,
+	"text/javascript":        true,
+	"application/javascript": true,
+}
+
+// contentTypeByExt 根据扩展名返回内容类型，未知扩展名时返回 false
+func contentTypeByExt(key string) (string, bool) {
+	ext := strings.ToLower(path.Ext(key))
+	if contentType, ok := storedContentTypes[ext]; ok {
+		return contentType, true
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType, true
+	}
+	return "", false
+}
+
+// sniffContentType 根据文件开头的内容推断类型，先识别 http.DetectContentType 不支持的音频格式
+func sniffContentType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return "audio/flac"
+	case bytes.HasPrefix(head, []byte("ID3")),
+		len(head) >= 2 && head[0] == 0xFF && head[1]&0xE6 == 0xE2: // 没有 ID3 标签的 MP3，以 MPEG 音频第三层的帧同步开头
+		return "audio/mpeg"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xF6 == 0xF0: // ADTS 封装的 AAC，帧同步后的层字段为 0
+		return "audio/aac"
+	case len(head) >= 12 && bytes.Equal(head[4:8], []byte("ftyp")) &&
+		(bytes.HasPrefix(head[8:], []byte("M4A")) || bytes.HasPrefix(head[8:], []byte("M4B"))):
+		return "audio/mp4"
+	case bytes.HasPrefix(head, []byte("#EXTM3U")):
+		return "application/vnd.apple.mpegurl"
+	}
+	return http.DetectContentType(head)
+}
+
+// isActiveContent 内容类型是否可能在浏览器中执行脚本
+func isActiveContent(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return activeContentTypes[mediaType]
+}
+
+// audioContentType 音频文件的内容类型，无法识别时按 MP3 处理
+func audioContentType(key string) string {
+	if contentType, ok := contentTypeByExt(key); ok && strings.HasPrefix(contentType, "audio/") {
+		return contentType
+	}
+	return "audio/mpeg"
+}
+
+// imageContentType 封面图片的内容类型，无法识别时按 JPEG 处理
+func imageContentType(key string) string {
+	if contentType, ok := contentTypeByExt(key); ok && strings.HasPrefix(contentType, "image/") && !isActiveContent(contentType) {
+		return contentType
+	}
+	return "image/jpeg"
+}
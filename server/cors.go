@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"Bt1QFM/config"
+	"Bt1QFM/logger"
+)
+
+// CORS 允许的方法、请求头和暴露给前端的响应头
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE, OPTIONS, HEAD"
+	corsAllowHeaders  = "Content-Type, Authorization, Range, X-Request-ID, If-None-Match, Idempotency-Key"
+	corsExposeHeaders = "Content-Length, Content-Range, Content-Disposition, ETag, Retry-After, X-Request-ID, X-Total-Count, X-Bandwidth-Used, X-Bandwidth-Soft-Cap, X-Bandwidth-Warning, Idempotent-Replayed"
+)
+
+// CORSMiddleware 统一处理跨域请求，包在路由器外层，未匹配路由和方法的预检请求同样生效
+// 允许的来源为 * 且不携带凭据时返回 *，否则回显请求的 Origin 并设置 Vary: Origin
+func CORSMiddleware(next http.Handler, cfg *config.Config) http.Handler {
+	allowAll := false
+	allowed := make(map[string]bool, len(cfg.CORSAllowedOrigins))
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if origin == "*" {
+			allowAll = true
+			continue
+		}
+		if normalized := normalizeOrigin(origin); normalized != "" {
+			allowed[normalized] = true
+		}
+	}
+	if allowAll && cfg.CORSAllowCredentials {
+		logger.Warn("CORS 允许任意来源且携带凭据，任何网站都能以用户身份调用接口")
+	}
+	maxAge := strconv.Itoa(cfg.CORSMaxAgeSeconds)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		// 非跨域请求不需要 CORS 头
+		if origin == "" {
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !allowAll && !allowed[normalizeOrigin(origin)] {
+			if preflight {
+				logger.Ctx(r.Context()).Warn("拒绝不在白名单中的跨域请求",
+					logger.String("origin", origin),
+					logger.String("path", r.URL.Path))
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			// 不带 CORS 头，由浏览器拦截响应
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if allowAll && !cfg.CORSAllowCredentials {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.CORSAllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			if cfg.CORSMaxAgeSeconds > 0 {
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
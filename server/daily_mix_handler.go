@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/dailymix"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/mux"
+)
+
+// DailyMixHandler Daily Mix 推荐歌单处理器
+type DailyMixHandler struct {
+	service *dailymix.Service
+}
+
+// NewDailyMixHandler 创建 Daily Mix 处理器
+func NewDailyMixHandler(service *dailymix.Service) *DailyMixHandler {
+	return &DailyMixHandler{service: service}
+}
+
+// GetDailyMixesHandler 返回用户最近一次生成的 Daily Mix，GET /api/playlist/mixes
+func (h *DailyMixHandler) GetDailyMixesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	mixes, err := h.service.ForUser(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取 Daily Mix 失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to get daily mixes")
+		return
+	}
+	writeDailyMixes(w, mixes)
+}
+
+// RefreshDailyMixesHandler 立即重新生成 Daily Mix，POST /api/playlist/mixes/refresh
+// 距上次生成不足 10 分钟时返回上次的结果；收听记录太少时返回空列表
+func (h *DailyMixHandler) RefreshDailyMixesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	mixes, err := h.service.Refresh(r.Context(), userID, time.Now())
+	if err != nil {
+		logger.Ctx(r.Context()).Error("刷新 Daily Mix 失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to refresh daily mixes")
+		return
+	}
+	if mixes == nil {
+		mixes = []model.DailyMix{}
+	}
+	writeDailyMixes(w, mixes)
+}
+
+// QueueDailyMixHandler 把一个 Daily Mix 的歌曲加入播放列表，POST /api/playlist/mixes/{id}/queue?position=next
+// position 为 next 时插入到当前播放的歌曲之后，默认追加到末尾
+func (h *DailyMixHandler) QueueDailyMixHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid ID format")
+		return
+	}
+	var playNext bool
+	switch r.URL.Query().Get("position") {
+	case "", "end":
+	case "next":
+		playNext = true
+	default:
+		writeError(w, CodeBadRequest, "Invalid position")
+		return
+	}
+
+	mixes, err := h.service.ForUser(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取 Daily Mix 失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to get daily mixes")
+		return
+	}
+	var mix *model.DailyMix
+	for i := range mixes {
+		if mixes[i].ID == id {
+			mix = &mixes[i]
+			break
+		}
+	}
+	if mix == nil {
+		writeError(w, CodeNotFound, "Daily mix not found")
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	items := make([]cache.PlaylistItem, 0, len(mix.Items))
+	for _, song := range mix.Items {
+		item := cache.PlaylistItem{
+			Source:   song.Source,
+			SourceID: song.SourceID,
+			Title:    song.Title,
+			Artist:   song.Artist,
+			Album:    song.Album,
+			Cover:    song.Cover,
+			Duration: song.Duration,
+			AddedBy:  userID,
+			AddedAt:  now,
+		}
+		if item.Normalize() {
+			items = append(items, item)
+		}
+	}
+	if _, err := cache.InsertTracksIntoPlaylist(r.Context(), userID, items, playNext); err != nil {
+		logger.Ctx(r.Context()).Error("Daily Mix 加入播放列表失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to add track to playlist")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"id":    mix.ID,
+			"added": len(items),
+		},
+	})
+}
+
+// writeDailyMixes 返回 Daily Mix 列表
+func writeDailyMixes(w http.ResponseWriter, mixes []model.DailyMix) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    mixes,
+	})
+}
+
+// RegisterDailyMixRoutes 注册 Daily Mix 路由
+func RegisterDailyMixRoutes(router *mux.Router, handler *DailyMixHandler, authMiddleware func(http.HandlerFunc) http.HandlerFunc) {
+	router.HandleFunc("/api/playlist/mixes", authMiddleware(handler.GetDailyMixesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/playlist/mixes/refresh", authMiddleware(handler.RefreshDailyMixesHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/playlist/mixes/{id:[0-9]+}/queue", authMiddleware(handler.QueueDailyMixHandler)).Methods(http.MethodPost)
+
+	logger.Info("Daily Mix API端点注册完成",
+		logger.String("endpoints", "GET /api/playlist/mixes, POST /api/playlist/mixes/refresh, POST /api/playlist/mixes/{id}/queue"))
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"Bt1QFM/core/device"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// maxDeviceNameLength 设备名称的最大长度
+const maxDeviceNameLength = 64
+
+// DeviceHandler 多设备播放控制处理器
+type DeviceHandler struct {
+	hub      *device.Hub
+	upgrader websocket.Upgrader
+	wsAuth   *wsAuthenticator
+}
+
+// NewDeviceHandler 创建设备处理器
+func NewDeviceHandler(hub *device.Hub, wsAuth *wsAuthenticator) *DeviceHandler {
+	return &DeviceHandler{
+		hub:      hub,
+		upgrader: wsAuth.Upgrader(0, 0),
+		wsAuth:   wsAuth,
+	}
+}
+
+// WebSocketHandler 设备连接，/ws/devices?deviceId=...&name=...&type=web
+// deviceId 由客户端生成并持久化，同一设备重连时替换旧连接
+func (h *DeviceHandler) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	deviceID := strings.TrimSpace(query.Get("deviceId"))
+	if deviceID == "" || len(deviceID) > maxDeviceIDLength {
+		writeError(w, CodeMissingField, "Invalid deviceId")
+		return
+	}
+	name := truncateRunes(strings.TrimSpace(query.Get("name")), maxDeviceNameLength)
+	if name == "" {
+		name = deviceID
+	}
+	deviceType := truncateRunes(strings.TrimSpace(query.Get("type")), 16)
+
+	conn, claims, err := h.wsAuth.Accept(w, r, &h.upgrader)
+	if err != nil {
+		logger.Warn("设备 WebSocket 认证失败",
+			logger.String("deviceId", deviceID),
+			logger.ErrorField(err))
+		return
+	}
+
+	d := device.NewDevice(h.hub, conn, claims.UserID, deviceID, name, deviceType)
+	h.hub.Register(d)
+
+	go d.WritePump()
+	go d.ReadPump(context.Background())
+
+	logger.Info("设备连接建立",
+		logger.Int64("userId", claims.UserID),
+		logger.String("deviceId", deviceID),
+		logger.String("name", name))
+}
+
+// ListDevicesHandler 返回当前用户的在线设备，GET /api/devices
+func (h *DeviceHandler) ListDevicesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    h.hub.Devices(userID),
+	})
+}
+
+// SendCommandHandler 向当前用户的某个设备发送控制命令，POST /api/devices/{deviceId}/commands
+// 请求体 {"action": "transfer", "position": 12.5, "fromDeviceId": "..."}，供未建立设备连接的客户端使用
+func (h *DeviceHandler) SendCommandHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var cmd device.Command
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	cmd.TargetDeviceID = mux.Vars(r)["deviceId"]
+	if !device.ValidAction(cmd.Action) {
+		writeError(w, CodeBadRequest, "Invalid action, expected play, pause, seek, next, prev or transfer")
+		return
+	}
+
+	if err := h.hub.SendCommand(userID, &cmd); err != nil {
+		switch {
+		case errors.Is(err, device.ErrDeviceNotFound):
+			writeError(w, CodeDeviceNotFound, "Device is not online")
+		case errors.Is(err, device.ErrInvalidCommand):
+			writeError(w, CodeBadRequest, "Invalid command")
+		case errors.Is(err, device.ErrSendBufferFull):
+			writeError(w, CodeServiceUnavailable, "Device is busy, try again later")
+		default:
+			logger.Ctx(r.Context()).Error("发送设备命令失败",
+				logger.Int64("userId", userID),
+				logger.String("deviceId", cmd.TargetDeviceID),
+				logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to send command")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, max int) string {
+	if runes := []rune(s); len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
+
+// RegisterDeviceRoutes 注册设备相关路由
+func RegisterDeviceRoutes(router *mux.Router, handler *DeviceHandler, authMiddleware func(http.HandlerFunc) http.HandlerFunc) {
+	router.HandleFunc("/api/devices", authMiddleware(handler.ListDevicesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/devices/{deviceId}/commands", authMiddleware(handler.SendCommandHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/ws/devices", handler.WebSocketHandler)
+
+	logger.Info("设备控制API端点注册完成",
+		logger.String("endpoints", "GET /api/devices, POST /api/devices/{deviceId}/commands, WS /ws/devices"))
+}
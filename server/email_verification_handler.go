@@ -0,0 +1,276 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/mux"
+)
+
+// 未验证账号的限制方式
+const (
+	restrictUnverifiedUpload = "upload" // 可以登录，不能上传
+	restrictUnverifiedLogin  = "login"  // 不能登录
+)
+
+// resendVerificationMessage 无论邮箱是否需要验证都返回相同的提示，避免泄露注册信息
+const resendVerificationMessage = "If the email belongs to an unverified account, a verification link has been sent"
+
+// verifyEmailTexts 验证邮件的本地化文案
+type verifyEmailTexts struct {
+	subject string
+	body    string // 参数：用户名、验证链接、有效期（小时）
+}
+
+var verifyEmailLocales = map[string]verifyEmailTexts{
+	"zh-CN": {
+		subject: "Bt1QFM 验证邮箱",
+		body:    "%s，你好！\n\n感谢注册 Bt1QFM，点击以下链接验证你的邮箱：\n\n%s\n\n链接 %d 小时内有效，且只能使用一次。如果你没有注册过 Bt1QFM，请忽略此邮件。\n",
+	},
+	"en": {
+		subject: "Verify your Bt1QFM email",
+		body:    "Hi %s,\n\nThanks for signing up for Bt1QFM. Open the link below to verify your email address:\n\n%s\n\nThe link expires in %d hours and can only be used once. If you did not sign up for Bt1QFM, you can ignore this email.\n",
+	},
+}
+
+// emailVerificationEnabled 新注册用户是否需要验证邮箱，未配置邮件发送器时不需要
+func (h *APIHandler) emailVerificationEnabled() bool {
+	return h.cfg.EmailVerificationRequired && h.mailer.Enabled()
+}
+
+// blocksUnverifiedLogin 未验证邮箱的账号是否禁止登录
+func (h *APIHandler) blocksUnverifiedLogin() bool {
+	return strings.EqualFold(h.cfg.UnverifiedRestriction, restrictUnverifiedLogin)
+}
+
+// VerifyEmailHandler 通过邮件中的链接验证邮箱，无需登录
+func (h *APIHandler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSpace(r.URL.Query().Get("token"))
+	if token == "" {
+		writeError(w, CodeMissingField, "Missing token")
+		return
+	}
+
+	userID, err := cache.ConsumeOneTimeToken(r.Context(), cache.TokenEmailVerification, auth.HashOneTimeToken(token))
+	if err != nil {
+		logger.Ctx(r.Context()).Error("[VerifyEmail] 读取验证令牌失败", logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Email verification is temporarily unavailable")
+		return
+	}
+	if userID == 0 {
+		writeError(w, CodeInvalidVerifyToken, "Invalid or expired verification link")
+		return
+	}
+
+	found, err := h.userRepo.UpdateUserStatus(r.Context(), userID, model.UserStatusActive)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("[VerifyEmail] 更新账号状态失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to verify email")
+		return
+	}
+	if !found {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	logger.Ctx(r.Context()).Info("[VerifyEmail] 邮箱已验证", logger.Int64("userId", userID))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("Your email has been verified. / 邮箱验证成功。"))
+}
+
+// ResendVerificationHandler 重新发送验证邮件，请求体 {"email": "..."}
+// 无需登录，以便禁止未验证账号登录时也能重发
+func (h *APIHandler) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		writeError(w, CodeMissingField, "Email is required")
+		return
+	}
+
+	if !h.mailer.Enabled() {
+		writeError(w, CodeServiceUnavailable, "Verification email is not available")
+		return
+	}
+
+	if h.cfg.RateLimitEnabled && h.cfg.RateLimitVerification.Enabled() {
+		if allowed, _ := checkRateLimit("verification_email", strings.ToLower(email), h.cfg.RateLimitVerification); !allowed {
+			logger.Ctx(r.Context()).Warn("[ResendVerification] 同一邮箱请求过于频繁", logger.String("email", email))
+			writeResendVerificationResponse(w)
+			return
+		}
+	}
+
+	user, err := h.userRepo.GetUserByEmail(r.Context(), email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Ctx(r.Context()).Error("[ResendVerification] 查询用户失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Internal server error")
+		return
+	}
+	if user == nil || user.IsActive() {
+		writeResendVerificationResponse(w)
+		return
+	}
+
+	if err := h.issueVerificationEmail(r, user); err != nil {
+		writeError(w, CodeServiceUnavailable, "Email verification is temporarily unavailable")
+		return
+	}
+	writeResendVerificationResponse(w)
+}
+
+// issueVerificationEmail 签发验证令牌并异步发送验证邮件，之前的验证链接随之失效
+func (h *APIHandler) issueVerificationEmail(r *http.Request, user *model.User) error {
+	token, tokenHash, err := auth.GenerateOneTimeToken()
+	if err != nil {
+		logger.Ctx(r.Context()).Error("生成邮箱验证令牌失败", logger.ErrorField(err))
+		return err
+	}
+	ttl := time.Duration(h.cfg.EmailVerificationTokenTTLHours) * time.Hour
+	if err := cache.SaveOneTimeToken(r.Context(), cache.TokenEmailVerification, user.ID, tokenHash, ttl); err != nil {
+		logger.Ctx(r.Context()).Error("保存邮箱验证令牌失败", logger.ErrorField(err))
+		return err
+	}
+
+	log := logger.Ctx(r.Context())
+	go func() {
+		if err := h.sendVerificationEmail(user, token); err != nil {
+			log.Error("发送验证邮件失败",
+				logger.Int64("userId", user.ID),
+				logger.ErrorField(err))
+			return
+		}
+		log.Info("已发送验证邮件", logger.Int64("userId", user.ID))
+	}()
+	return nil
+}
+
+// sendVerificationEmail 按用户的语言偏好发送验证邮件
+func (h *APIHandler) sendVerificationEmail(user *model.User, token string) error {
+	texts, ok := verifyEmailLocales[user.GetPreferences().Digest.Locale]
+	if !ok {
+		texts = verifyEmailLocales["zh-CN"]
+	}
+
+	q := url.Values{}
+	q.Set("token", token)
+	link := strings.TrimRight(h.cfg.PublicBaseURL, "/") + "/api/auth/verify-email?" + q.Encode()
+
+	body := fmt.Sprintf(texts.body, user.Username, link, h.cfg.EmailVerificationTokenTTLHours)
+	return h.mailer.Send(user.Email, texts.subject, body, nil)
+}
+
+// writeResendVerificationResponse 返回统一的重发验证邮件提示
+func writeResendVerificationResponse(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": resendVerificationMessage,
+	})
+}
+
+// RequireVerifiedEmail 要求当前用户已验证邮箱，需在 AuthMiddleware 之后使用
+func (h *APIHandler) RequireVerifiedEmail(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := GetUserIDFromContext(r.Context())
+		if err != nil {
+			writeError(w, CodeUnauthorized, "Unauthorized")
+			return
+		}
+		user, err := h.userRepo.GetUserByID(r.Context(), userID)
+		if err != nil {
+			logger.Ctx(r.Context()).Error("获取用户信息失败", logger.ErrorField(err))
+			writeError(w, CodeInternal, "Internal server error")
+			return
+		}
+		if user == nil {
+			writeError(w, CodeUserNotFound, "User not found")
+			return
+		}
+		if !user.IsActive() {
+			writeError(w, CodeEmailNotVerified, "Please verify your email before uploading")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// AdminUpdateUserStatusHandler 管理员手动设置账号状态，请求体 {"status": "active"}
+// 用于用户收不到验证邮件等情况；设为 disabled 时禁用账号，已签发的 Token 失效并断开房间连接
+func (h *APIHandler) AdminUpdateUserStatusHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid user ID")
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if req.Status != model.UserStatusActive && req.Status != model.UserStatusPending && req.Status != model.UserStatusDisabled {
+		writeError(w, CodeBadRequest, "Invalid status, expected 'active', 'pending' or 'disabled'")
+		return
+	}
+
+	found, err := h.userRepo.UpdateUserStatus(r.Context(), userID, req.Status)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("更新账号状态失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to update user status")
+		return
+	}
+	if !found {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	disabled := req.Status == model.UserStatusDisabled
+	if err := cache.SetUserDisabled(r.Context(), userID, disabled); err != nil {
+		logger.Ctx(r.Context()).Warn("更新禁用用户集合失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+	}
+	if disabled && h.roomManager != nil {
+		h.roomManager.DisconnectUser(userID, "account_disabled")
+	}
+
+	admin, _ := GetUsernameFromContext(r.Context())
+	logger.Ctx(r.Context()).Info("管理员更新账号状态",
+		logger.String("admin", admin),
+		logger.Int64("userId", userID),
+		logger.String("status", req.Status))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"id":     userID,
+			"status": req.Status,
+		},
+	})
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"Bt1QFM/logger"
+)
+
+// writeJSONWithETag 写出 JSON 响应并附带由响应内容计算的弱 ETag，
+// 请求的 If-None-Match 与之匹配时返回 304，客户端复用已缓存的内容
+// 列表内容还取决于标签、评论数等其他表，按内容计算比按更新时间计算更不容易返回过期的 304
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		logger.Ctx(r.Context()).Error("编码响应失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to encode response")
+		return
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
+
+// etagMatches 按弱比较判断 If-None-Match 中是否有与 etag 相同的值
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"Bt1QFM/core/audio"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+const (
+	// fingerprintTimeout 单个文件计算指纹的超时时间
+	fingerprintTimeout = 60 * time.Second
+	// maxDuplicateCandidates 上传时最多与多少首时长最接近的已有曲目比较指纹
+	maxDuplicateCandidates = 50
+	// maxDuplicateComparisons 列出重复簇时一次请求最多比较的指纹对数，超出时返回部分结果
+	maxDuplicateComparisons = 5000
+)
+
+// computeFingerprintFromReader 将上传内容写入临时文件并计算指纹
+func (h *APIHandler) computeFingerprintFromReader(r io.Reader, ext string) (*audio.Fingerprint, error) {
+	tempFile, err := os.CreateTemp("", "fingerprint-*"+ext)
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	tempFilePath := tempFile.Name()
+	defer os.Remove(tempFilePath)
+
+	_, err = io.Copy(tempFile, r)
+	tempFile.Close()
+	if err != nil {
+		return nil, fmt.Errorf("写入临时文件失败: %v", err)
+	}
+
+	return h.computeFingerprint(tempFilePath)
+}
+
+// computeFingerprint 计算本地音频文件的指纹
+func (h *APIHandler) computeFingerprint(path string) (*audio.Fingerprint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fingerprintTimeout)
+	defer cancel()
+	return audio.ComputeFingerprint(ctx, h.cfg.FpcalcPath, path)
+}
+
+// findDuplicateTracks 查找用户已有曲目中与指纹相同的曲目ID，只比较时长在容差内且最接近的少量曲目
+func (h *APIHandler) findDuplicateTracks(userID int64, fp *audio.Fingerprint) ([]int64, error) {
+	existing, err := h.fingerprintRepo.GetFingerprintsNearDuration(userID, fp.Duration, audio.DuplicateDurationTolerance, maxDuplicateCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := make([]int64, 0)
+	for _, e := range existing {
+		other, err := audio.DecodeFingerprint(e.Fingerprint, e.Duration)
+		if err != nil {
+			logger.Warn("解析已存储指纹失败", logger.Int64("trackId", e.TrackID), logger.ErrorField(err))
+			continue
+		}
+		if ok, _ := fp.IsDuplicateOf(other); ok {
+			duplicates = append(duplicates, e.TrackID)
+		}
+	}
+	return duplicates, nil
+}
+
+// saveTrackFingerprint 保存曲目指纹，失败时仅记录日志
+func (h *APIHandler) saveTrackFingerprint(trackID, userID int64, fp *audio.Fingerprint) {
+	if err := h.fingerprintRepo.SaveFingerprint(&model.TrackFingerprint{
+		TrackID:     trackID,
+		UserID:      userID,
+		Duration:    fp.Duration,
+		Fingerprint: fp.Encode(),
+	}); err != nil {
+		logger.Warn("保存曲目指纹失败",
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err))
+	}
+}
+
+// GetDuplicateTracksHandler 列出当前用户曲目中检测到的重复簇
+// 指纹按时长排序，只在时长容差的窗口内两两比较；比较次数超过上限时停止并标记 truncated
+func (h *APIHandler) GetDuplicateTracksHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		logger.Error("获取用户ID失败", logger.ErrorField(err))
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	stored, err := h.fingerprintRepo.GetFingerprintsByUserID(userID)
+	if err != nil {
+		logger.Error("获取用户指纹失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get fingerprints")
+		return
+	}
+
+	tracks, err := h.trackRepo.GetAllTracksByUserID(r.Context(), userID)
+	if err != nil {
+		logger.Error("获取用户曲目失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get tracks")
+		return
+	}
+	trackByID := make(map[int64]*model.Track, len(tracks))
+	for _, t := range tracks {
+		trackByID[t.ID] = t
+	}
+
+	fps := make([]*audio.Fingerprint, 0, len(stored))
+	ids := make([]int64, 0, len(stored))
+	for _, s := range stored {
+		if trackByID[s.TrackID] == nil {
+			continue
+		}
+		fp, err := audio.DecodeFingerprint(s.Fingerprint, s.Duration)
+		if err != nil {
+			logger.Warn("解析已存储指纹失败", logger.Int64("trackId", s.TrackID), logger.ErrorField(err))
+			continue
+		}
+		fps = append(fps, fp)
+		ids = append(ids, s.TrackID)
+	}
+
+	// 比较时长相近的指纹并用并查集合并为重复簇
+	parent := make([]int, len(fps))
+	minSim := make([]float64, len(fps))
+	for i := range parent {
+		parent[i] = i
+		minSim[i] = 1
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	comparisons, truncated := 0, false
+	for i := 0; i < len(fps) && !truncated; i++ {
+		for j := i + 1; j < len(fps) && fps[j].Duration-fps[i].Duration <= audio.DuplicateDurationTolerance; j++ {
+			if comparisons >= maxDuplicateComparisons {
+				truncated = true
+				break
+			}
+			comparisons++
+			ok, sim := fps[i].IsDuplicateOf(fps[j])
+			if !ok {
+				continue
+			}
+			ri, rj := find(i), find(j)
+			if ri != rj {
+				parent[rj] = ri
+				if minSim[rj] < minSim[ri] {
+					minSim[ri] = minSim[rj]
+				}
+			}
+			if sim < minSim[ri] {
+				minSim[ri] = sim
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	order := make([]int, 0)
+	for i := range fps {
+		root := find(i)
+		if _, exists := groups[root]; !exists {
+			order = append(order, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+
+	clusters := make([]*model.DuplicateCluster, 0)
+	for _, root := range order {
+		members := groups[root]
+		if len(members) < 2 {
+			continue
+		}
+		cluster := &model.DuplicateCluster{MinSimilarity: minSim[root]}
+		for _, idx := range members {
+			cluster.Tracks = append(cluster.Tracks, trackByID[ids[idx]])
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	if truncated {
+		logger.Ctx(r.Context()).Warn("重复检测比较次数达到上限，返回部分结果",
+			logger.Int64("userId", userID),
+			logger.Int("fingerprints", len(fps)),
+			logger.Int("comparisons", comparisons))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"clusters":  clusters,
+		"truncated": truncated,
+	})
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/logger"
+)
+
+const (
+	// idempotencyKeyHeader 客户端为可能重试的 POST 请求生成的唯一键
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotencyReplayedHeader 响应来自首次请求的保存结果时设置为 true
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	// idempotencyKeyMaxLength Idempotency-Key 的最大长度
+	idempotencyKeyMaxLength = 255
+	// idempotencyPendingTTL 首次请求处理期间占位记录的有效期，进程崩溃时占位在此之后失效
+	idempotencyPendingTTL = 30 * time.Minute
+	// idempotencyMemoryBody 不超过该大小的请求体在内存中缓存，更大的请求体（上传文件）写入临时文件
+	idempotencyMemoryBody = 1 << 20
+	// idempotencyMaxBody 可缓存的最大请求体，超出时返回 413，避免请求体写满临时目录
+	idempotencyMaxBody = 1 << 30
+	// idempotencyMaxResponse 可保存的最大响应体，超出时不保存结果
+	idempotencyMaxResponse = 1 << 20
+	// idempotencyStoreTimeout 保存或释放记录的超时时间
+	idempotencyStoreTimeout = 3 * time.Second
+)
+
+// Idempotent 为带 Idempotency-Key 请求头的 POST 请求提供幂等保证，需放在 AuthMiddleware 和 RateLimit 之内，被限流的请求不会缓存请求体
+// 首次请求的响应按用户和键保存 IdempotencyTTLHours 小时，期间使用同一键重试相同请求直接返回保存的响应；
+// 同一键用于内容不同的请求或首次请求仍在处理时返回错误。5xx 和 429 响应不保存，客户端可用同一键重试
+func (h *APIHandler) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	ttl := time.Duration(h.cfg.IdempotencyTTLHours) * time.Hour
+	if ttl <= 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || r.Method != http.MethodPost {
+			next(w, r)
+			return
+		}
+		if len(key) > idempotencyKeyMaxLength {
+			writeError(w, CodeBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+		userID, err := GetUserIDFromContext(r.Context())
+		if err != nil {
+			next(w, r)
+			return
+		}
+
+		body, fingerprint, err := spoolRequestBody(w, r)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, CodeFileTooLarge, fmt.Sprintf("Request too large. Maximum size is %d MB", tooLarge.Limit>>20))
+				return
+			}
+			logger.Ctx(r.Context()).Warn("读取请求体失败", logger.ErrorField(err))
+			writeError(w, CodeInvalidBody, "Failed to read request body")
+			return
+		}
+		defer body.Close()
+		r.Body = body
+
+		record, err := cache.ReserveIdempotencyKey(r.Context(), userID, key, fingerprint, idempotencyPendingTTL)
+		if err != nil {
+			logger.Ctx(r.Context()).Warn("幂等键检查失败，按普通请求处理",
+				logger.String("idempotencyKey", key),
+				logger.ErrorField(err))
+			next(w, r)
+			return
+		}
+		if record != nil {
+			switch {
+			case record.Fingerprint != fingerprint:
+				writeError(w, CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+			case !record.Completed:
+				writeError(w, CodeIdempotencyInProgress, "A request with this Idempotency-Key is still being processed")
+			default:
+				logger.Ctx(r.Context()).Info("重放幂等请求的响应",
+					logger.String("idempotencyKey", key),
+					logger.String("path", r.URL.Path),
+					logger.Int("status", record.Status))
+				replayIdempotentResponse(w, record)
+			}
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		saved := false
+		defer func() {
+			// 处理中 panic 或响应不可保存时释放占位，客户端可用同一键重试
+			if !saved {
+				releaseIdempotencyKey(userID, key)
+			}
+		}()
+		next(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		// 被限流的请求没有执行，同样不保存
+		if rec.status >= http.StatusInternalServerError || rec.status == http.StatusTooManyRequests || rec.overflow {
+			return
+		}
+		// 客户端断开时请求的 context 已取消，结果仍需保存供重试使用
+		ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+		defer cancel()
+		err = cache.SaveIdempotentResponse(ctx, userID, key, &cache.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      rec.status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+		}, ttl)
+		if err != nil {
+			logger.Ctx(r.Context()).Warn("保存幂等请求的响应失败",
+				logger.String("idempotencyKey", key),
+				logger.ErrorField(err))
+			return
+		}
+		saved = true
+	}
+}
+
+// replayIdempotentResponse 返回首次请求保存的响应
+func replayIdempotentResponse(w http.ResponseWriter, record *cache.IdempotencyRecord) {
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	w.Header().Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+func releaseIdempotencyKey(userID int64, key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+	defer cancel()
+	if err := cache.ReleaseIdempotencyKey(ctx, userID, key); err != nil {
+		logger.Warn("释放幂等键失败",
+			logger.Int64("userId", userID),
+			logger.String("idempotencyKey", key),
+			logger.ErrorField(err))
+	}
+}
+
+// idempotencyRecorder 在写出响应的同时记录状态码和响应体
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+// WriteHeader 记录状态码
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write 记录响应体，超过 idempotencyMaxResponse 后不再记录
+func (rec *idempotencyRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if !rec.overflow {
+		if rec.body.Len()+len(p) > idempotencyMaxResponse {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap 返回原始 ResponseWriter，供 http.ResponseController 使用
+func (rec *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// tempFileBody 缓存在临时文件中的请求体，关闭时删除文件
+type tempFileBody struct {
+	*os.File
+}
+
+// Close 关闭并删除临时文件
+func (b *tempFileBody) Close() error {
+	err := b.File.Close()
+	os.Remove(b.File.Name())
+	return err
+}
+
+// spoolRequestBody 读取请求体并计算请求指纹，返回可供处理函数重新读取的请求体
+// 请求体超过 idempotencyMaxBody 时返回 *http.MaxBytesError
+func spoolRequestBody(w http.ResponseWriter, r *http.Request) (io.ReadCloser, string, error) {
+	defer r.Body.Close()
+	r.Body = http.MaxBytesReader(w, r.Body, idempotencyMaxBody)
+
+	if r.ContentLength >= 0 && r.ContentLength <= idempotencyMemoryBody {
+		data, err := io.ReadAll(io.LimitReader(r.Body, idempotencyMemoryBody+1))
+		if err != nil {
+			return nil, "", err
+		}
+		if len(data) <= idempotencyMemoryBody {
+			fingerprint, err := requestFingerprint(r, bytes.NewReader(data))
+			if err != nil {
+				return nil, "", err
+			}
+			return io.NopCloser(bytes.NewReader(data)), fingerprint, nil
+		}
+		// Content-Length 与实际长度不符，余下部分写入临时文件
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+	}
+
+	file, err := os.CreateTemp("", "idempotency-*")
+	if err != nil {
+		return nil, "", err
+	}
+	body := &tempFileBody{File: file}
+	if _, err := io.Copy(file, r.Body); err != nil {
+		body.Close()
+		return nil, "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		body.Close()
+		return nil, "", err
+	}
+	fingerprint, err := requestFingerprint(r, file)
+	if err != nil {
+		body.Close()
+		return nil, "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		body.Close()
+		return nil, "", err
+	}
+	return body, fingerprint, nil
+}
+
+// requestFingerprint 计算请求的指纹：方法、路径、查询参数、媒体类型和请求体内容
+// multipart 请求按各部分的字段名、文件名和内容计算，客户端重试时重新生成的分隔符不影响指纹
+func requestFingerprint(r *http.Request, body io.ReadSeeker) (string, error) {
+	h := sha256.New()
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	io.WriteString(h, r.Method+"\n"+r.URL.Path+"?"+r.URL.RawQuery+"\n"+mediaType+"\n")
+
+	if boundary := params["boundary"]; mediaType == "multipart/form-data" && boundary != "" {
+		if err := hashMultipart(h, multipart.NewReader(body, boundary)); err == nil {
+			return hex.EncodeToString(h.Sum(nil)), nil
+		}
+		// 无法解析的 multipart 按原始字节计算，交由处理函数返回错误
+		h.Reset()
+		io.WriteString(h, r.Method+"\n"+r.URL.Path+"?"+r.URL.RawQuery+"\n"+mediaType+"\n")
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashMultipart(h hash.Hash, reader *multipart.Reader) error {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		io.WriteString(h, "\n"+part.FormName()+"\n"+part.FileName()+"\n")
+		if _, err := io.Copy(h, part); err != nil {
+			return err
+		}
+	}
+}
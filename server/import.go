@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/cover"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// importContentTypes 导入本地文件支持的音频扩展名及对应的 Content-Type
+var importContentTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+	".aac":  "audio/aac",
+	".m4a":  "audio/mp4",
+	".ogg":  "audio/ogg",
+	".opus": "audio/opus",
+}
+
+// IsImportableAudio 文件扩展名是否为支持导入的音频格式
+func IsImportableAudio(path string) bool {
+	_, ok := importContentTypes[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// IsImportable 文件是否为支持导入的音频格式，供监视目录使用
+func (h *APIHandler) IsImportable(path string) bool {
+	return IsImportableAudio(path)
+}
+
+// ImportFile 导入服务器本地的音频文件：读取标签、按内容哈希存储源文件并同步转码，供命令行和监视目录使用
+// album 不为空时覆盖文件标签中的专辑名；用户曲库（含回收站）中已有相同内容的曲目时跳过并返回 skipped，重复执行是安全的
+func (h *APIHandler) ImportFile(ctx context.Context, userID int64, path, album string) (*model.Track, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+
+	contentHash, err := hashContent(file)
+	if err != nil {
+		return nil, false, fmt.Errorf("计算文件哈希失败: %w", err)
+	}
+	existing, err := h.trackRepo.GetTracksByContentHash(ctx, contentHash)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, t := range existing {
+		if t.UserID == userID {
+			return t, true, nil
+		}
+	}
+
+	tags, err := h.audioProcessor.GetAudioTags(path)
+	if err != nil {
+		logger.Warn("读取音频标签失败，使用文件名作为标题",
+			logger.String("path", path),
+			logger.ErrorField(err))
+		tags = &audio.AudioTags{}
+	}
+	if tags.Title == "" {
+		tags.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if album != "" {
+		tags.Album = album
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	contentType := importContentTypes[ext]
+	if contentType == "" {
+		contentType = mime.TypeByExtension(ext)
+	}
+	opts := h.userTranscodeOptions(ctx, userID)
+	streamID := contentStreamID(contentHash, opts)
+	shared, err := h.findSharedStorage(ctx, contentHash, streamID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	track := &model.Track{
+		UserID:      userID,
+		Title:       truncateRunes(tags.Title, model.MaxTrackFieldLength),
+		Artist:      truncateRunes(tags.Artist, model.MaxTrackFieldLength),
+		Album:       truncateRunes(tags.Album, model.MaxTrackFieldLength),
+		Genre:       truncateRunes(tags.Genre, model.MaxGenreLength),
+		FilePath:    "/static/audio/" + contentHash + ext,
+		Duration:    tags.Duration,
+		Status:      "processing",
+		Source:      "library",
+		Provenance:  model.ProvenanceUpload,
+		ContentHash: contentHash,
+		FileSize:    info.Size(),
+	}
+	if shared.FilePath != "" {
+		track.FilePath = shared.FilePath
+	}
+	if shared.Stream != nil {
+		track.HLSPlaylistPath = shared.Stream.HLSPlaylistPath
+		track.Duration = shared.Stream.Duration
+	}
+
+	tx, err := h.trackRepo.BeginTx(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer h.trackRepo.RollbackTx(tx)
+	track.ID, err = h.trackRepo.CreateTrackWithTx(ctx, tx, track)
+	if err != nil {
+		return nil, false, err
+	}
+	if track.Genre != "" {
+		if err := h.trackRepo.UpdateTrackMetadataWithTx(ctx, tx, track.ID, &model.TrackMetadataUpdate{Genre: &track.Genre}); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := h.trackRepo.CommitTx(tx); err != nil {
+		return nil, false, err
+	}
+
+	if shared.FilePath == "" {
+		if err := h.uploadFileToStorage(file, strings.TrimPrefix(track.FilePath, "/static/"), contentType); err != nil {
+			h.trackRepo.UpdateTrackStatus(ctx, track.ID, "failed")
+			track.Status = "failed"
+			return track, false, fmt.Errorf("上传源音频失败: %w", err)
+		}
+	}
+	if shared.Stream == nil {
+		if err := h.streamProcessor.StreamProcessSyncWithOptions(ctx, streamID, path, false, opts); err != nil {
+			h.trackRepo.UpdateTrackStatus(ctx, track.ID, "failed")
+			track.Status = "failed"
+			return track, false, fmt.Errorf("转码失败: %w", err)
+		}
+		track.HLSPlaylistPath = streamPlaylistPath(streamID)
+		if err := h.trackRepo.UpdateTrackHLSPath(ctx, track.ID, track.HLSPlaylistPath, track.Duration); err != nil {
+			return track, false, err
+		}
+	}
+	if err := h.trackRepo.UpdateTrackStatus(ctx, track.ID, "completed"); err != nil {
+		return track, false, err
+	}
+	track.Status = "completed"
+
+	if h.coverFetcher != nil {
+		h.coverFetcher.Enqueue(cover.Job{
+			Kind:  cover.KindTrack,
+			ID:    track.ID,
+			Query: cover.Query{Artist: track.Artist, Album: track.Album, Title: track.Title},
+		})
+	}
+	return track, false, nil
+}
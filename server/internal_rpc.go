@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/room"
+	"Bt1QFM/logger"
+	internalv1 "Bt1QFM/proto/internalrpc/v1"
+	"Bt1QFM/repository"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// internalRPCServer 实现 proto/internalrpc/v1 的 InternalService，直接复用曲库、转码队列和房间管理
+type internalRPCServer struct {
+	internalv1.UnimplementedInternalServiceServer
+	trackRepo     repository.TrackRepository
+	userRepo      repository.UserRepository
+	streamHandler *StreamHandler
+	roomManager   *room.RoomManager
+}
+
+// StartInternalRPC 在 cfg.InternalGRPCAddr 上启动内部 gRPC 服务，未配置地址时返回 nil；关闭时调用返回值的 GracefulStop
+func StartInternalRPC(cfg *config.Config, trackRepo repository.TrackRepository, userRepo repository.UserRepository,
+	streamHandler *StreamHandler, roomManager *room.RoomManager) (*grpc.Server, error) {
+	if cfg.InternalGRPCAddr == "" {
+		return nil, nil
+	}
+	listener, err := net.Listen("tcp", cfg.InternalGRPCAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(internalRPCInterceptor(cfg.InternalGRPCToken)))
+	internalv1.RegisterInternalServiceServer(server, &internalRPCServer{
+		trackRepo:     trackRepo,
+		userRepo:      userRepo,
+		streamHandler: streamHandler,
+		roomManager:   roomManager,
+	})
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			logger.Error("内部 gRPC 服务异常退出", logger.ErrorField(err))
+		}
+	}()
+	logger.Info("内部 gRPC 服务已启动", logger.String("addr", cfg.InternalGRPCAddr))
+	return server, nil
+}
+
+// internalRPCInterceptor 校验调用方的 Bearer Token（token 为空时不校验），并记录失败的调用
+func internalRPCInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if token != "" {
+			md, _ := metadata.FromIncomingContext(ctx)
+			values := md.Get("authorization")
+			if len(values) == 0 || subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(values[0], "Bearer ")), []byte(token)) != 1 {
+				return nil, status.Error(codes.Unauthenticated, "invalid internal token")
+			}
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		if err != nil && status.Code(err) == codes.Internal {
+			logger.Warn("内部 gRPC 调用失败",
+				logger.String("method", info.FullMethod),
+				logger.Duration("耗时", time.Since(start)),
+				logger.ErrorField(err))
+		}
+		return resp, err
+	}
+}
+
+// GetTrack 按 ID 查询曲目，不存在或已移入回收站时返回 NOT_FOUND
+func (s *internalRPCServer) GetTrack(ctx context.Context, req *internalv1.GetTrackRequest) (*internalv1.Track, error) {
+	track, err := s.trackRepo.GetTrackByID(ctx, req.GetId())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get track: %v", err)
+	}
+	if track == nil || track.State == 0 {
+		return nil, status.Errorf(codes.NotFound, "track %d not found", req.GetId())
+	}
+	return &internalv1.Track{
+		Id:              track.ID,
+		UserId:          track.UserID,
+		Title:           track.Title,
+		Artist:          track.Artist,
+		Album:           track.Album,
+		Genre:           track.Genre,
+		CoverArtPath:    track.CoverArtPath,
+		HlsPlaylistPath: track.HLSPlaylistPath,
+		Duration:        track.Duration,
+		Status:          track.Status,
+		Source:          track.Source,
+		Provenance:      track.Provenance,
+		License:         track.License,
+		PlayCount:       track.PlayCount,
+		CreatedAt:       track.CreatedAt.Unix(),
+		UpdatedAt:       track.UpdatedAt.Unix(),
+	}, nil
+}
+
+// GetStreamStatus 查询流的就绪状态。与 prepare 接口不同，只查询不排队
+func (s *internalRPCServer) GetStreamStatus(ctx context.Context, req *internalv1.GetStreamStatusRequest) (*internalv1.StreamStatus, error) {
+	switch req.GetSource() {
+	case cache.SourceLocal:
+		trackID, err := strconv.ParseInt(req.GetSourceId(), 10, 64)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "source_id must be a track ID")
+		}
+		track, err := s.trackRepo.GetTrackByID(ctx, trackID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "get track: %v", err)
+		}
+		if track == nil || track.State == 0 {
+			return nil, status.Errorf(codes.NotFound, "track %d not found", trackID)
+		}
+		resp := &internalv1.StreamStatus{PlaylistUrl: track.HLSPlaylistPath}
+		switch track.Status {
+		case "completed":
+			resp.State = prepareStateReady
+		case "failed":
+			resp.State = audio.PrepareStateFailed
+		default:
+			resp.State = audio.PrepareStateProcessing
+		}
+		return resp, nil
+	case cache.SourceNetease:
+		songID := req.GetSourceId()
+		if _, err := strconv.ParseInt(songID, 10, 64); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "source_id must be a netease song ID")
+		}
+		resp := &internalv1.StreamStatus{PlaylistUrl: "/streams/netease/" + songID + "/playlist.m3u8"}
+		if s.streamHandler.isStreamReady(songID) {
+			resp.State = prepareStateReady
+			return resp, nil
+		}
+		prepare := s.streamHandler.mp3Processor.PrepareStatus(songID)
+		resp.State = prepare.State
+		resp.Position = int32(prepare.Position)
+		resp.Error = prepare.Error
+		return resp, nil
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown source %q", req.GetSource())
+	}
+}
+
+// GetRoomState 查询房间信息、歌单和播放状态，房间不存在或已关闭时返回 NOT_FOUND
+func (s *internalRPCServer) GetRoomState(ctx context.Context, req *internalv1.GetRoomStateRequest) (*internalv1.RoomState, error) {
+	r, err := s.roomManager.GetRoom(ctx, req.GetRoomId())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get room: %v", err)
+	}
+	if r == nil {
+		return nil, status.Errorf(codes.NotFound, "room %s not found", req.GetRoomId())
+	}
+
+	ownerName := ""
+	if owner, err := s.userRepo.GetUserByID(ctx, r.OwnerID); err != nil {
+		logger.Warn("获取房主信息失败", logger.String("roomId", r.ID), logger.ErrorField(err))
+	} else if owner != nil {
+		ownerName = owner.Username
+	}
+	info, err := s.roomManager.GetRoomInfo(ctx, r.ID, ownerName)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get room info: %v", err)
+	}
+	playlist, err := s.roomManager.GetPlaylist(ctx, r.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get playlist: %v", err)
+	}
+	playback, err := s.roomManager.GetPlayback(ctx, r.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get playback: %v", err)
+	}
+
+	resp := &internalv1.RoomState{
+		Id:          info.ID,
+		Name:        info.Name,
+		OwnerId:     info.OwnerID,
+		OwnerName:   info.OwnerName,
+		Status:      info.Status,
+		MemberCount: int32(info.MemberCount),
+		Playlist:    make([]*internalv1.PlaylistItem, 0, len(playlist)),
+	}
+	for _, item := range playlist {
+		title := item.Title
+		if title == "" {
+			title = item.Name
+		}
+		resp.Playlist = append(resp.Playlist, &internalv1.PlaylistItem{
+			Source:   item.Source,
+			SourceId: item.SourceID,
+			Title:    title,
+			Artist:   item.Artist,
+			Album:    item.Album,
+			Cover:    item.Cover,
+			Duration: int32(item.Duration),
+			HlsUrl:   item.HLSURL,
+			Position: int32(item.Position),
+			AddedBy:  item.AddedBy,
+		})
+	}
+	if playback != nil {
+		resp.Playback = &internalv1.Playback{
+			CurrentIndex: int32(playback.CurrentIndex),
+			Position:     playback.Position,
+			IsPlaying:    playback.IsPlaying,
+			UpdatedAt:    playback.UpdatedAt,
+			UpdatedBy:    playback.UpdatedBy,
+			StateVersion: playback.StateVersion,
+		}
+	}
+	return resp, nil
+}
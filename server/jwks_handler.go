@@ -0,0 +1,21 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"Bt1QFM/core/auth"
+)
+
+// jwksMaxAge JWKS 的缓存时长（秒），小于新密钥启用前的发布时长，其他服务总能在启用前拿到新公钥
+const jwksMaxAge = 300
+
+// JWKSHandler 返回校验登录 Token 的公钥集合，GET /.well-known/jwks.json 或 /api/auth/jwks.json
+// 按 RFC 7517 的格式直接返回 {"keys": [...]}，不包在 success/data 中，便于其他服务的 JWT 库直接使用；
+// 包括正在签名的密钥、即将启用的密钥和已停用但签发的 Token 仍未过期的密钥。使用 HS256 签名时为空
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(jwksMaxAge))
+	json.NewEncoder(w).Encode(auth.PublicKeys())
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/i18n"
+)
+
+// contentLanguageHeader 响应语言的响应头，错误响应和本地化文案据此选择语言
+const contentLanguageHeader = "Content-Language"
+
+// LanguageMiddleware 按 Accept-Language 选择响应语言，写入 Content-Language 响应头和 context
+// 登录用户设置了语言偏好时，认证中间件会用偏好覆盖；两者都没有时不翻译，文案保持原样
+func LanguageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lang := i18n.FromAcceptLanguage(r.Header.Get("Accept-Language")); lang != "" {
+			r = withLanguage(w, r, lang)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// applyUserLanguage 用户设置了语言偏好时以偏好作为响应语言，认证通过后调用
+func applyUserLanguage(w http.ResponseWriter, r *http.Request, userID int64) *http.Request {
+	lang := cache.GetUserLanguage(r.Context(), userID)
+	if lang == "" {
+		return r
+	}
+	return withLanguage(w, r, lang)
+}
+
+func withLanguage(w http.ResponseWriter, r *http.Request, lang string) *http.Request {
+	w.Header().Set(contentLanguageHeader, lang)
+	return r.WithContext(i18n.WithLanguage(r.Context(), lang))
+}
+
+// responseLanguage 当前响应的语言，没有协商出语言时为空
+func responseLanguage(w http.ResponseWriter) string {
+	return w.Header().Get(contentLanguageHeader)
+}
+
+// localize 将文案翻译为当前响应的语言，未协商语言或目录中没有时原样返回
+func localize(w http.ResponseWriter, message string) string {
+	return i18n.T(responseLanguage(w), message)
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/core/storagegc"
+	"Bt1QFM/db"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+	"Bt1QFM/storage"
+)
+
+// Library 离线曲库管理，供命令行子命令在不启动 HTTP 服务的情况下导入、重新转码和统计
+// 与上传接口共用存储布局和转码流程，导入的曲目与通过接口上传的没有区别
+type Library struct {
+	h *APIHandler
+}
+
+// LibraryStats 曲库概况
+type LibraryStats struct {
+	Users   *model.UserStats       `json:"users"`
+	Tracks  *model.TrackStats      `json:"tracks"`
+	Storage *storagegc.UsageReport `json:"storage,omitempty"`
+}
+
+// OpenLibrary 连接对象存储和数据库；transcode 为 true 时检查 FFmpeg，导入和重新转码需要
+// Redis 不可用时只跳过分片缓存，不影响转码结果
+func OpenLibrary(cfg *config.Config, transcode bool) (*Library, error) {
+	if err := auth.SetSecret(cfg.AppSecret); err != nil {
+		return nil, err
+	}
+	if transcode {
+		ffmpegInfo, err := audio.CheckFFmpeg(context.Background(), cfg)
+		if err != nil {
+			return nil, err
+		}
+		cfg.FFmpegPath = ffmpegInfo.FFmpegPath
+		audio.ConfigureTranscoding(cfg)
+	}
+	if err := storage.InitStorage(cfg); err != nil {
+		return nil, fmt.Errorf("初始化对象存储失败: %w", err)
+	}
+	if err := db.ConnectDB(cfg); err != nil {
+		return nil, fmt.Errorf("连接数据库失败: %w", err)
+	}
+	if err := db.InitDB(); err != nil {
+		db.DB.Close()
+		return nil, fmt.Errorf("初始化数据库失败: %w", err)
+	}
+	if err := cache.ConnectRedis(cfg); err != nil {
+		logger.Warn("连接 Redis 失败，跳过分片缓存", logger.ErrorField(err))
+		cache.CloseRedis()
+		cache.RedisClient = nil
+	}
+
+	trackRepo := repository.NewMySQLTrackRepository()
+	audioProcessor := audio.NewFFmpegProcessor(cfg.FFmpegPath)
+	streamProcessor := audio.NewStreamProcessor(audio.NewMP3Processor(cfg.FFmpegPath), cfg)
+	h := NewAPIHandler(
+		trackRepo,
+		repository.NewMySQLUserRepository(db.DB),
+		repository.NewMySQLAlbumRepository(db.DB),
+		audioProcessor,
+		streamProcessor,
+		nil,
+		storagegc.NewCollector(trackRepo, cfg),
+		cfg,
+	)
+	return &Library{h: h}, nil
+}
+
+// Close 断开数据库和 Redis 连接
+func (l *Library) Close() {
+	cache.CloseRedis()
+	db.DB.Close()
+}
+
+// LookupUser 按用户名或数字 ID 查找用户
+func (l *Library) LookupUser(ctx context.Context, userRef string) (*model.User, error) {
+	user, err := l.h.userRepo.GetUserByUsername(ctx, userRef)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		if id, convErr := strconv.ParseInt(userRef, 10, 64); convErr == nil {
+			user, err = l.h.userRepo.GetUserByID(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if user == nil {
+		return nil, fmt.Errorf("用户 %s 不存在", userRef)
+	}
+	return user, nil
+}
+
+// ImportFile 导入单个音频文件到用户曲库，返回的 skipped 表示已有相同内容的曲目
+func (l *Library) ImportFile(ctx context.Context, userID int64, path, album string) (*model.Track, bool, error) {
+	return l.h.ImportFile(ctx, userID, path, album)
+}
+
+// ReprocessTrack 按曲目所有者当前的转码偏好重新转码曲目，用于转码失败或转码参数调整后的修复
+// 即使目标流已存在也会重新生成，旧流没有其他曲目引用时删除
+func (l *Library) ReprocessTrack(ctx context.Context, trackID int64) (*model.Track, error) {
+	h := l.h
+	track, err := h.trackRepo.GetTrackByID(ctx, trackID)
+	if err != nil {
+		return nil, err
+	}
+	if track == nil {
+		return nil, fmt.Errorf("曲目 %d 不存在", trackID)
+	}
+	if track.FilePath == "" {
+		return nil, fmt.Errorf("曲目 %d 缺少源文件，无法重新转码", trackID)
+	}
+
+	opts := h.userTranscodeOptions(ctx, track.UserID)
+	streamID := strconv.FormatInt(track.ID, 10)
+	if track.ContentHash != "" {
+		streamID = contentStreamID(track.ContentHash, opts)
+	}
+
+	start := time.Now()
+	if err := h.trackRepo.UpdateTrackStatus(ctx, track.ID, "processing"); err != nil {
+		return nil, err
+	}
+	if err := h.transcodeTrackSource(track, streamID, opts); err != nil {
+		h.trackRepo.UpdateTrackStatus(ctx, track.ID, "failed")
+		return nil, fmt.Errorf("转码失败: %w", err)
+	}
+
+	previous := *track
+	track.HLSPlaylistPath = streamPlaylistPath(streamID)
+	if err := h.trackRepo.UpdateTrackHLSPath(ctx, track.ID, track.HLSPlaylistPath, track.Duration); err != nil {
+		return nil, err
+	}
+	if err := h.trackRepo.UpdateTrackStatus(ctx, track.ID, "completed"); err != nil {
+		return nil, err
+	}
+	track.Status = "completed"
+	if previous.HLSPlaylistPath != "" && previous.HLSPlaylistPath != track.HLSPlaylistPath {
+		h.releaseTrackStorage(ctx, &previous, false)
+	}
+
+	logger.Info("曲目已重新转码",
+		logger.Int64("trackId", track.ID),
+		logger.String("streamId", streamID),
+		logger.Duration("elapsed", time.Since(start)))
+	return track, nil
+}
+
+// CreateUser 创建账号，由运维创建的账号视为已验证邮箱
+func (l *Library) CreateUser(ctx context.Context, username, email, password string) (int64, error) {
+	if username == "" || email == "" || password == "" {
+		return 0, fmt.Errorf("用户名、邮箱和密码不能为空")
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+	return l.h.userRepo.CreateUser(ctx, &model.User{
+		Username:     username,
+		Email:        email,
+		PasswordHash: hashedPassword,
+		Status:       model.UserStatusActive,
+	})
+}
+
+// Stats 统计用户和曲目数量及 since 之后的注册趋势；withStorage 为 true 时另外按对象实际大小统计存储占用
+func (l *Library) Stats(ctx context.Context, since time.Time, withStorage bool) (*LibraryStats, error) {
+	users, err := l.h.userRepo.GetUserStats(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := l.h.trackRepo.GetTrackStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats := &LibraryStats{Users: users, Tracks: tracks}
+	if withStorage {
+		if stats.Storage, err = l.h.storageGC.Usage(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/storage"
+)
+
+// LocalStorageHandler 处理本地存储后端签发的预签名地址，行为对应 MinIO 的预签名 GET/PUT
+type LocalStorageHandler struct {
+	store *storage.LocalStorage
+}
+
+// NewLocalStorageHandler 创建 LocalStorageHandler 实例
+func NewLocalStorageHandler(store *storage.LocalStorage) *LocalStorageHandler {
+	return &LocalStorageHandler{store: store}
+}
+
+// ServeHTTP 校验签名后读取或写入对象
+func (h *LocalStorageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, storage.LocalPresignPrefix)
+	query := r.URL.Query()
+	if query.Get("method") != r.Method ||
+		!h.store.VerifyPresigned(r.Method, key, query.Get("expires"), query.Get("signature")) {
+		writeError(w, CodeInvalidSignature, "Invalid or expired signature")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		info, err := h.store.Stat(r.Context(), key)
+		if err != nil {
+			writeError(w, CodeNotFound, "File not found")
+			return
+		}
+		object, err := h.store.Get(r.Context(), key)
+		if err != nil {
+			writeError(w, CodeNotFound, "File not found")
+			return
+		}
+		defer object.Close()
+
+		w.Header().Set("Content-Type", info.ContentType)
+		if _, err := io.Copy(w, object); err != nil {
+			logger.Error("Error serving presigned file", logger.ErrorField(err))
+		}
+	case http.MethodPut:
+		body := http.MaxBytesReader(w, r.Body, DefaultUploadConfig().MaxFileSize)
+		if err := h.store.Put(r.Context(), key, body, r.ContentLength, r.Header.Get("Content-Type")); err != nil {
+			logger.Error("预签名上传失败",
+				logger.String("key", key),
+				logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to store object")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/notification"
+	"Bt1QFM/i18n"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// loginAccountSubject 账号的登录失败统计主体
+func loginAccountSubject(userID int64) string {
+	return fmt.Sprintf("account:%d", userID)
+}
+
+// loginIPSubject 客户端 IP 的登录失败统计主体
+func loginIPSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// loginThrottle 登录失败的退避与账号锁定，登录接口和 Subsonic 的 u/p 认证共用
+type loginThrottle struct {
+	cfg           *config.Config
+	notifications *notification.Service // 账号被锁定时提醒用户，为空时不通知
+}
+
+// block 返回 subject 当前的限制类型和剩余时间，未被限制、未启用或 Redis 不可用时类型为空
+func (t *loginThrottle) block(ctx context.Context, subject string) (string, time.Duration) {
+	if !t.cfg.LoginThrottleEnabled {
+		return "", 0
+	}
+	kind, ttl, err := cache.GetLoginBlock(ctx, subject)
+	if err != nil {
+		logger.Ctx(ctx).Warn("检查登录限制失败，放行请求", logger.String("subject", subject), logger.ErrorField(err))
+		return "", 0
+	}
+	if kind != "" {
+		logger.Ctx(ctx).Warn("登录被限制",
+			logger.String("subject", subject),
+			logger.String("kind", kind),
+			logger.Duration("retryAfter", ttl))
+	}
+	return kind, ttl
+}
+
+// recordFailure 记录一次凭据错误，user 为空表示账号不存在，只按 IP 统计。
+// IP 的失败次数同时决定登录是否需要人机验证，未启用退避时同样统计。
+// 本次失败使账号被锁定时通知用户并返回锁定时长和 true，否则返回下次尝试前需要等待的时间
+func (t *loginThrottle) recordFailure(ctx context.Context, ip string, user *model.User) (time.Duration, bool) {
+	ipSubject := loginIPSubject(ip)
+	ipFailures := t.countFailure(ctx, ipSubject)
+	if !t.cfg.LoginThrottleEnabled {
+		return 0, false
+	}
+	retryAfter := t.throttle(ctx, ipSubject, t.cfg.LoginIPFreeAttempts, ipFailures)
+	if user == nil {
+		return retryAfter, false
+	}
+
+	subject := loginAccountSubject(user.ID)
+	failures := t.countFailure(ctx, subject)
+	if lockout := t.lockAccount(ctx, subject, failures); lockout > 0 {
+		t.notifyAccountLocked(ctx, user, ip, failures)
+		return lockout, true
+	}
+	return max(retryAfter, t.throttle(ctx, subject, t.cfg.LoginFreeAttempts, failures)), false
+}
+
+// loginBlocked 检查 subject 是否处于退避或锁定中，是则写入 429 或 423 响应并返回 true；Redis 不可用时放行
+func (h *APIHandler) loginBlocked(w http.ResponseWriter, r *http.Request, subject string) bool {
+	kind, ttl := h.loginThrottle.block(r.Context(), subject)
+	if kind == "" {
+		return false
+	}
+
+	seconds := retryAfterSeconds(ttl)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	details := map[string]interface{}{"retryAfter": seconds}
+	if kind == cache.LoginBlockLocked {
+		writeErrorDetails(w, CodeAccountLocked, "Account is temporarily locked due to too many failed login attempts", details)
+	} else {
+		writeErrorDetails(w, CodeLoginThrottled, "Too many failed login attempts, please try again later", details)
+	}
+	return true
+}
+
+// writeLoginFailure 记录一次登录失败并返回凭据错误；user 为空表示账号不存在，只按 IP 统计。
+// details.retryAfter 为下次尝试前需要等待的秒数，details.captchaRequired 表示之后的登录是否需要人机验证；
+// 本次失败使账号被锁定时返回 423 并通知用户
+func (h *APIHandler) writeLoginFailure(w http.ResponseWriter, r *http.Request, ip string, user *model.User) {
+	details := make(map[string]interface{})
+	retryAfter, locked := h.loginThrottle.recordFailure(r.Context(), ip, user)
+	if h.loginNeedsCaptcha(r.Context(), ip) {
+		details["captchaRequired"] = true
+	}
+	if locked {
+		seconds := retryAfterSeconds(retryAfter)
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		details["retryAfter"] = seconds
+		writeErrorDetails(w, CodeAccountLocked, "Account is temporarily locked due to too many failed login attempts", details)
+		return
+	}
+	if retryAfter > 0 {
+		details["retryAfter"] = retryAfterSeconds(retryAfter)
+	}
+
+	if len(details) == 0 {
+		writeError(w, CodeInvalidCredentials, "Invalid username/email or password")
+		return
+	}
+	writeErrorDetails(w, CodeInvalidCredentials, "Invalid username/email or password", details)
+}
+
+// clearAccountLoginFailures 登录成功后清除账号的失败次数
+func (h *APIHandler) clearAccountLoginFailures(ctx context.Context, userID int64) {
+	h.loginThrottle.clearAccount(ctx, userID)
+}
+
+// countFailure 记录 subject 的一次登录失败，返回窗口内的失败次数；Redis 不可用时返回 0
+func (t *loginThrottle) countFailure(ctx context.Context, subject string) int64 {
+	failures, err := cache.RecordLoginAttemptFailure(ctx, subject, t.failureWindow())
+	if err != nil {
+		logger.Warn("记录登录失败次数失败", logger.String("subject", subject), logger.ErrorField(err))
+		return 0
+	}
+	return failures
+}
+
+// lockAccount 失败次数达到锁定次数时锁定账号并清零失败次数，返回锁定时长，未锁定时为 0
+func (t *loginThrottle) lockAccount(ctx context.Context, subject string, failures int64) time.Duration {
+	if t.cfg.LoginLockoutThreshold <= 0 || failures < int64(t.cfg.LoginLockoutThreshold) {
+		return 0
+	}
+
+	if err := cache.ClearLoginFailures(ctx, subject); err != nil {
+		logger.Warn("清除登录失败次数失败", logger.String("subject", subject), logger.ErrorField(err))
+	}
+	lockout := time.Duration(max(t.cfg.LoginLockoutMinutes, 1)) * time.Minute
+	if err := cache.BlockLogin(ctx, subject, cache.LoginBlockLocked, lockout); err != nil {
+		logger.Warn("锁定账号失败", logger.String("subject", subject), logger.ErrorField(err))
+		return 0
+	}
+	logger.Warn("账号因登录失败次数过多被锁定",
+		logger.String("subject", subject),
+		logger.Int64("failures", failures),
+		logger.Duration("lockout", lockout))
+	return lockout
+}
+
+// throttle 失败次数超过免费次数后设置指数退避，返回需要等待的时间
+func (t *loginThrottle) throttle(ctx context.Context, subject string, freeAttempts int, failures int64) time.Duration {
+	delay := loginBackoff(failures, freeAttempts, time.Duration(t.cfg.LoginBackoffMaxSeconds)*time.Second)
+	if delay <= 0 {
+		return 0
+	}
+	if err := cache.BlockLogin(ctx, subject, cache.LoginBlockBackoff, delay); err != nil {
+		logger.Warn("设置登录退避失败", logger.String("subject", subject), logger.ErrorField(err))
+		return 0
+	}
+	return delay
+}
+
+// loginBackoff 第 failures 次失败后的等待时间：前 freeAttempts 次不等待，之后从 1 秒开始每次翻倍，不超过 maxDelay
+func loginBackoff(failures int64, freeAttempts int, maxDelay time.Duration) time.Duration {
+	exceeded := failures - int64(max(freeAttempts, 0))
+	if exceeded <= 0 || maxDelay <= 0 {
+		return 0
+	}
+	if exceeded > 30 {
+		return maxDelay
+	}
+	return min(time.Second<<(exceeded-1), maxDelay)
+}
+
+// clearAccount 登录成功后清除账号的失败次数
+func (t *loginThrottle) clearAccount(ctx context.Context, userID int64) {
+	if !t.cfg.LoginThrottleEnabled {
+		return
+	}
+	if err := cache.ClearLoginFailures(ctx, loginAccountSubject(userID)); err != nil {
+		logger.Warn("清除登录失败次数失败", logger.Int64("userId", userID), logger.ErrorField(err))
+	}
+}
+
+// 账号锁定提醒的文案，按用户的语言偏好翻译
+const (
+	accountLockedTitle   = "账号因多次登录失败已被临时锁定"
+	accountLockedContent = "账号在 %[1]d 分钟内连续 %[2]d 次登录失败（最后一次来自 %[3]s），已锁定 %[4]d 分钟。如果不是本人操作，建议尽快修改密码。"
+)
+
+// notifyAccountLocked 通过通知中心提醒用户账号被锁定，文案使用用户设置的语言，未设置时使用请求的语言
+func (t *loginThrottle) notifyAccountLocked(ctx context.Context, user *model.User, ip string, failures int64) {
+	if t.notifications == nil {
+		return
+	}
+	lang := i18n.Normalize(user.GetPreferences().Language)
+	if lang == "" {
+		lang = i18n.FromContext(ctx)
+	}
+	t.notifications.Notify(ctx, &model.Notification{
+		UserID:   user.ID,
+		Type:     model.NotificationSecurity,
+		ObjectID: ip,
+		Title:    i18n.T(lang, accountLockedTitle),
+		Content: fmt.Sprintf(i18n.T(lang, accountLockedContent),
+			t.cfg.LoginFailureWindowMinutes, failures, ip, t.cfg.LoginLockoutMinutes),
+	})
+}
+
+// failureWindow 登录失败次数的统计窗口
+func (t *loginThrottle) failureWindow() time.Duration {
+	return time.Duration(max(t.cfg.LoginFailureWindowMinutes, 1)) * time.Minute
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"Bt1QFM/core/metaimport"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// maxMetadataImportSize 元数据导入文件的大小上限
+const maxMetadataImportSize = 5 << 20
+
+// fieldChange 一个字段修改前后的值
+type fieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// metadataImportResult 导入文件中一行的匹配和修改结果
+type metadataImportResult struct {
+	Line    int                    `json:"line"`
+	Title   string                 `json:"title"`
+	Artist  string                 `json:"artist,omitempty"`
+	Status  string                 `json:"status"` // matched、unchanged、unmatched、ambiguous、duplicate 或 invalid
+	Message string                 `json:"message,omitempty"`
+	TrackID int64                  `json:"trackId,omitempty"`
+	Score   float64                `json:"score,omitempty"`
+	Changes map[string]fieldChange `json:"changes,omitempty"`
+
+	update *model.TrackMetadataUpdate
+}
+
+// ImportMetadataHandler 从 CSV/JSON 导出文件批量修正曲目元数据，POST /api/import/metadata?dryRun=true
+// 文件可以作为请求体直接上传（Content-Type 为 text/csv 或 application/json），也可以用 multipart 的 file 字段上传；
+// 每行按标题和歌手模糊匹配当前用户曲库中的曲目，只修改文件中非空且与曲库不同的字段（title、artist、album、year、genre）。
+// dryRun=true 时只返回预览，不修改曲目；否则所有修改在同一个事务中完成
+func (h *APIHandler) ImportMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxMetadataImportSize+1<<20)
+	body, format, err := metadataImportFile(r)
+	if err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	defer body.Close()
+	if format == "" {
+		writeError(w, CodeBadRequest, "Unsupported import format, use CSV or JSON")
+		return
+	}
+
+	rows, err := metaimport.Parse(io.LimitReader(body, maxMetadataImportSize), format)
+	switch {
+	case errors.Is(err, metaimport.ErrMissingTitleColumn):
+		writeError(w, CodeBadRequest, "CSV header must contain a title column")
+		return
+	case errors.Is(err, metaimport.ErrTooManyRows):
+		writeError(w, CodeBadRequest, "Too many rows, at most 2000 rows can be imported at once")
+		return
+	case err != nil:
+		logger.Ctx(r.Context()).Warn("解析元数据导入文件失败", logger.String("format", format), logger.ErrorField(err))
+		writeError(w, CodeBadRequest, "Invalid import file")
+		return
+	}
+
+	tracks, err := h.trackRepo.GetAllTracksByUserID(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取用户曲目失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get tracks")
+		return
+	}
+
+	results := make([]*metadataImportResult, 0, len(rows))
+	var changed []*metadataImportResult
+	counts := make(map[string]int)
+	for _, m := range metaimport.MatchRows(rows, tracks) {
+		result := &metadataImportResult{
+			Line:    m.Row.Line,
+			Title:   m.Row.Title,
+			Artist:  m.Row.Artist,
+			Status:  m.Status,
+			Message: m.Row.Error,
+			Score:   math.Round(m.Score*1000) / 1000,
+		}
+		if m.Track != nil {
+			result.TrackID = m.Track.ID
+			result.update, result.Changes = metadataChanges(m.Row, m.Track)
+			if msg := normalizeTrackMetadataUpdate(result.update); msg != "" {
+				result.Status, result.Message = metaimport.StatusInvalid, msg
+			} else if result.update.IsEmpty() {
+				result.Status = metaimport.StatusUnchanged
+			} else {
+				changed = append(changed, result)
+			}
+		}
+		counts[result.Status]++
+		results = append(results, result)
+	}
+
+	if !dryRun && len(changed) > 0 {
+		tx, err := h.trackRepo.BeginTx(r.Context())
+		if err != nil {
+			logger.Ctx(r.Context()).Error("开始数据库事务失败", logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to begin transaction")
+			return
+		}
+		defer h.trackRepo.RollbackTx(tx)
+
+		for _, result := range changed {
+			if err := h.trackRepo.UpdateTrackMetadataWithTx(r.Context(), tx, result.TrackID, result.update); err != nil {
+				logger.Ctx(r.Context()).Error("导入曲目元数据失败",
+					logger.Int64("trackId", result.TrackID),
+					logger.ErrorField(err))
+				writeError(w, CodeInternal, "Failed to update tracks")
+				return
+			}
+		}
+		if err := h.trackRepo.CommitTx(tx); err != nil {
+			logger.Ctx(r.Context()).Error("提交数据库事务失败", logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to commit transaction")
+			return
+		}
+	}
+
+	updated := 0
+	if !dryRun {
+		updated = len(changed)
+	}
+	logger.Ctx(r.Context()).Info("导入曲目元数据",
+		logger.Int64("userId", userID),
+		logger.String("format", format),
+		logger.Bool("dryRun", dryRun),
+		logger.Int("rows", len(rows)),
+		logger.Int("updated", updated))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"dryRun":    dryRun,
+			"total":     len(rows),
+			"matched":   counts[metaimport.StatusMatched] + counts[metaimport.StatusUnchanged],
+			"changed":   len(changed),
+			"updated":   updated,
+			"unmatched": counts[metaimport.StatusUnmatched],
+			"ambiguous": counts[metaimport.StatusAmbiguous],
+			"duplicate": counts[metaimport.StatusDuplicate],
+			"invalid":   counts[metaimport.StatusInvalid],
+			"results":   results,
+		},
+	})
+}
+
+// metadataImportFile 返回上传的文件和格式，格式依次由 format 参数、Content-Type 和文件扩展名决定，无法识别时为空
+func metadataImportFile(r *http.Request) (io.ReadCloser, string, error) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	body, name := r.Body, ""
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		body, name = file, header.Filename
+		mediaType, _, _ = mime.ParseMediaType(header.Header.Get("Content-Type"))
+	}
+
+	if format == "" {
+		switch mediaType {
+		case "text/csv", "application/csv":
+			format = metaimport.FormatCSV
+		case "application/json":
+			format = metaimport.FormatJSON
+		}
+	}
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	}
+	if format != metaimport.FormatCSV && format != metaimport.FormatJSON {
+		format = ""
+	}
+	return body, format, nil
+}
+
+// metadataChanges 比较导入的一行和曲目，返回需要修改的字段；行中为空的字段不修改
+func metadataChanges(row metaimport.Row, track *model.Track) (*model.TrackMetadataUpdate, map[string]fieldChange) {
+	update := &model.TrackMetadataUpdate{}
+	changes := make(map[string]fieldChange)
+	setString := func(name string, value, current string, target **string) {
+		if value == "" || value == current {
+			return
+		}
+		v := value
+		*target = &v
+		changes[name] = fieldChange{From: current, To: value}
+	}
+	setString("title", row.Title, track.Title, &update.Title)
+	setString("artist", row.Artist, track.Artist, &update.Artist)
+	setString("album", row.Album, track.Album, &update.Album)
+	setString("genre", row.Genre, track.Genre, &update.Genre)
+	if row.Year != 0 && row.Year != track.Year {
+		year := row.Year
+		update.Year = &year
+		changes["year"] = fieldChange{From: track.Year, To: year}
+	}
+	if len(changes) == 0 {
+		changes = nil
+	}
+	return update, changes
+}
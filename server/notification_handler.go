@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"Bt1QFM/core/notification"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultNotificationLimit 通知列表默认每页的数量
+const defaultNotificationLimit = 20
+
+// SetNotificationService 设置通知服务，未设置时相关接口返回 503
+func (h *APIHandler) SetNotificationService(service *notification.Service) {
+	h.notifications = service
+	h.loginThrottle.notifications = service
+}
+
+// GetNotificationsHandler 获取当前用户的通知，GET /api/notifications?unread=true&limit=20&before=<上一页返回的 next>
+func (h *APIHandler) GetNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.notificationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultNotificationLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, CodeBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, model.MaxNotificationLimit)
+	}
+	var before int64
+	if raw := query.Get("before"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, CodeBadRequest, "Invalid before")
+			return
+		}
+		before = n
+	}
+	unreadOnly := query.Get("unread") == "true"
+
+	items, next, err := h.notifications.List(r.Context(), userID, unreadOnly, before, limit)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取通知列表失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get notifications")
+		return
+	}
+	unread, err := h.notifications.UnreadCount(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("统计未读通知失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get notifications")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":       items,
+		"unreadCount": unread,
+		"next":        next,
+	})
+}
+
+// GetUnreadNotificationCountHandler 获取当前用户的未读通知数，GET /api/notifications/unread-count
+func (h *APIHandler) GetUnreadNotificationCountHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.notificationRequest(w, r)
+	if !ok {
+		return
+	}
+	h.writeUnreadCount(w, r, userID)
+}
+
+// MarkNotificationReadHandler 将一条通知标记为已读，PUT /api/notifications/{id}/read
+func (h *APIHandler) MarkNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.notificationRequest(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid notification ID")
+		return
+	}
+
+	n, err := h.notifications.MarkRead(r.Context(), userID, id)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("标记通知已读失败", logger.Int64("notificationId", id), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to mark notification read")
+		return
+	}
+	if n == nil {
+		writeError(w, CodeNotFound, "Notification not found")
+		return
+	}
+	h.writeUnreadCount(w, r, userID)
+}
+
+// MarkAllNotificationsReadHandler 将当前用户的所有通知标记为已读，PUT /api/notifications/read-all
+func (h *APIHandler) MarkAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.notificationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	marked, err := h.notifications.MarkAllRead(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("标记全部通知已读失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to mark notifications read")
+		return
+	}
+
+	logger.Ctx(r.Context()).Info("已将全部通知标记为已读", logger.Int64("userId", userID), logger.Int64("marked", marked))
+	h.writeUnreadCount(w, r, userID)
+}
+
+// notificationRequest 检查通知服务并读取当前用户ID，失败时已写入响应
+func (h *APIHandler) notificationRequest(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if h.notifications == nil {
+		writeError(w, CodeServiceUnavailable, "Notification service is not available")
+		return 0, false
+	}
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return 0, false
+	}
+	return userID, true
+}
+
+// writeUnreadCount 返回当前用户的未读通知数
+func (h *APIHandler) writeUnreadCount(w http.ResponseWriter, r *http.Request, userID int64) {
+	unread, err := h.notifications.UnreadCount(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("统计未读通知失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to count unread notifications")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"unreadCount": unread})
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// openAPIVersion 生成的文档遵循的 OpenAPI 版本
+const openAPIVersion = "3.0.3"
+
+// swaggerUIVersion Swagger UI 页面从 CDN 加载的版本
+const swaggerUIVersion = "5"
+
+// routeParam 路由模板中的路径参数，如 {id:[0-9]+}
+var routeParam = regexp.MustCompile(`\{(\w+)(?::([^}]+))?\}`)
+
+// nonIdentifier operationId 中需要替换为下划线的字符
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// authMiddlewareName AuthMiddleware 返回的处理函数的函数名，据此判断路由是否需要登录
+var authMiddlewareName = handlerName((&APIHandler{}).AuthMiddleware(nil))
+
+// OpenAPIHandler 根据路由表生成 OpenAPI 文档，文档在第一次请求时生成，此时所有路由都已注册
+type OpenAPIHandler struct {
+	router *mux.Router
+
+	once sync.Once
+	spec []byte
+	err  error
+}
+
+// NewOpenAPIHandler 创建 OpenAPI 文档处理器
+func NewOpenAPIHandler(router *mux.Router) *OpenAPIHandler {
+	return &OpenAPIHandler{router: router}
+}
+
+// SpecHandler 返回 OpenAPI 文档，GET /api/openapi.json
+func (h *OpenAPIHandler) SpecHandler(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(func() {
+		var spec map[string]interface{}
+		var undocumented []string
+		spec, undocumented, h.err = buildOpenAPISpec(h.router)
+		if h.err == nil {
+			h.spec, h.err = json.Marshal(spec)
+		}
+		if len(undocumented) > 0 {
+			logger.Warn("以下接口没有在 apiDocs 中登记说明", logger.Any("routes", undocumented))
+		}
+	})
+	if h.err != nil {
+		logger.Ctx(r.Context()).Error("生成 OpenAPI 文档失败", logger.ErrorField(h.err))
+		writeError(w, CodeInternal, "Failed to generate API document")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.spec)
+}
+
+// SwaggerUIHandler 返回浏览 OpenAPI 文档的 Swagger UI 页面，GET /api/docs
+func (h *OpenAPIHandler) SwaggerUIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", swaggerUIContentSecurityPolicy)
+	w.Write([]byte(swaggerUIPage))
+}
+
+// swaggerUIContentSecurityPolicy Swagger UI 页面的 CSP，允许从 CDN 加载资源和页面内的初始化脚本
+const swaggerUIContentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline' https://unpkg.com; " +
+	"style-src 'self' 'unsafe-inline' https://unpkg.com; img-src 'self' data: https:; connect-src 'self'; " +
+	"object-src 'none'; frame-ancestors 'none'"
+
+// swaggerUIPage Swagger UI 页面，静态资源从 CDN 加载，避免把前端资源打包进服务端
+var swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Bt1QFM API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({
+      url: "/api/openapi.json",
+      dom_id: "#swagger-ui",
+      persistAuthorization: true
+    });
+  </script>
+</body>
+</html>
+`
+
+// buildOpenAPISpec 遍历路由表生成 OpenAPI 文档，只包含 /api/ 下声明了请求方法的路由
+// 返回没有在 apiDocs 中登记说明的接口
+func buildOpenAPISpec(router *mux.Router) (map[string]interface{}, []string, error) {
+	paths := map[string]map[string]interface{}{}
+	var undocumented []string
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(template, "/api/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// 没有限定请求方法的路由（如 WebSocket）不写入文档
+			return nil
+		}
+
+		path, params := openAPIPath(template)
+		secured := handlerName(route.GetHandler()) == authMiddlewareName
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		for _, method := range methods {
+			key := method + " " + template
+			doc, ok := apiDocs[key]
+			if !ok {
+				undocumented = append(undocumented, key)
+			}
+			admin := doc.Admin || strings.HasPrefix(template, "/api/admin/")
+			paths[path][strings.ToLower(method)] = openAPIOperation(method, path, params, doc.Summary, secured, admin)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(undocumented)
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":       "Bt1QFM API",
+			"version":     "1.0",
+			"description": "需要登录的接口在 Authorization 请求头中携带登录返回的 Token：Bearer <token>。错误响应的 code 见 /api/errors。上传和播放列表的 POST 接口支持 Idempotency-Key 请求头，重试时返回首次请求的结果。",
+		},
+		"paths":      paths,
+		"components": openAPIComponents(),
+	}, undocumented, nil
+}
+
+// openAPIPath 将路由模板转换为 OpenAPI 路径，去掉参数中的正则并生成路径参数定义
+func openAPIPath(template string) (string, []interface{}) {
+	var params []interface{}
+	for _, m := range routeParam.FindAllStringSubmatch(template, -1) {
+		schema := map[string]interface{}{"type": "string"}
+		switch m[2] {
+		case "":
+		case "[0-9]+":
+			schema = map[string]interface{}{"type": "integer", "format": "int64"}
+		default:
+			schema["pattern"] = "^" + m[2] + "$"
+		}
+		params = append(params, map[string]interface{}{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+	}
+	return routeParam.ReplaceAllString(template, "{$1}"), params
+}
+
+// openAPIOperation 生成一个接口的文档，分组取 /api/ 之后的第一段路径
+func openAPIOperation(method, path string, params []interface{}, summary string, secured, admin bool) map[string]interface{} {
+	tag := strings.SplitN(strings.TrimPrefix(path, "/api/"), "/", 2)[0]
+	op := map[string]interface{}{
+		"tags":        []string{tag},
+		"operationId": strings.ToLower(method) + "_" + strings.Trim(nonIdentifier.ReplaceAllString(strings.TrimPrefix(path, "/api/"), "_"), "_"),
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{"description": "成功"},
+			"default": map[string]interface{}{
+				"description": "错误",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"},
+					},
+				},
+			},
+		},
+	}
+	if summary != "" {
+		op["summary"] = summary
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if secured {
+		op["security"] = []map[string][]string{{"bearerAuth": {}}}
+	}
+	if admin {
+		op["description"] = "需要管理员权限"
+	}
+	return op
+}
+
+// openAPIComponents 登录认证方式和统一的错误响应结构
+func openAPIComponents() map[string]interface{} {
+	codes := make([]string, 0, len(errorCatalog))
+	for code := range errorCatalog {
+		codes = append(codes, string(code))
+	}
+	sort.Strings(codes)
+
+	return map[string]interface{}{
+		"securitySchemes": map[string]interface{}{
+			"bearerAuth": map[string]interface{}{
+				"type":         "http",
+				"scheme":       "bearer",
+				"bearerFormat": "JWT",
+			},
+		},
+		"schemas": map[string]interface{}{
+			"ErrorResponse": map[string]interface{}{
+				"type":     "object",
+				"required": []string{"code", "message"},
+				"properties": map[string]interface{}{
+					"code":      map[string]interface{}{"type": "string", "enum": codes},
+					"message":   map[string]interface{}{"type": "string"},
+					"details":   map[string]interface{}{"type": "object"},
+					"requestId": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	}
+}
+
+// handlerName 返回处理函数的函数名，中间件返回的闭包名称形如 server.(*APIHandler).AuthMiddleware.func1
+func handlerName(handler http.Handler) string {
+	fn, ok := handler.(http.HandlerFunc)
+	if !ok || fn == nil {
+		return ""
+	}
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
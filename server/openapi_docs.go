@@ -0,0 +1,197 @@
+package server
+
+// apiDoc 接口在 OpenAPI 文档中的说明
+type apiDoc struct {
+	Summary string
+	// Admin 接口内部检查管理员权限；/api/admin/ 下的接口无需标注
+	Admin bool
+}
+
+// apiDocs 接口说明，键为 "方法 路由模板"，与注册路由时的写法一致
+// 路径、方法、路径参数和是否需要登录都从路由表生成，这里只补充说明；
+// 新增接口时在此登记，未登记的接口仍会出现在文档中，生成文档时会记录未登记的接口
+var apiDocs = map[string]apiDoc{
+	"GET /api/admin/bandwidth":                           {Summary: "返回最近每天全部用户的流量和流量最多的用户，可用 days 和 limit 参数调整范围"},
+	"GET /api/admin/backups":                             {Summary: "返回备份是否在执行、最近一次结果和备份目录中的备份文件"},
+	"POST /api/admin/backups":                            {Summary: "在后台开始一次备份，通过 GET /api/admin/backups 查看进度和结果"},
+	"GET /api/admin/cache/streams":                       {Summary: "返回 HLS 播放列表和分片各级缓存的命中统计"},
+	"GET /api/admin/netease/health":                      {Summary: "返回各网易云API地址的熔断状态和失败统计"},
+	"GET /api/admin/overview":                            {Summary: "返回管理后台概览：用户数量和注册趋势、活跃房间、转码池状态"},
+	"GET /api/admin/rooms":                               {Summary: "列出当前有连接的房间"},
+	"POST /api/admin/rooms/{room_id}/close":              {Summary: "强制关闭房间并断开所有连接"},
+	"GET /api/admin/reprocess":                           {Summary: "返回当前或最近一次批量重新转码的进度"},
+	"POST /api/admin/reprocess":                          {Summary: "按范围重新转码码率、分片时长或转码偏好已变化的曲目，请求体 {\"scope\": \"all|user|album\", \"userId\": 1, \"albumId\": 1, \"dryRun\": false}，新流生成完成后再切换播放列表路径"},
+	"POST /api/admin/storage/reconcile":                  {Summary: "手动触发存储对账，找出源音频或流已丢失的曲目并修复，dryRun=true 时只报告"},
+	"POST /api/admin/storage/gc":                         {Summary: "手动触发存储垃圾回收，dryRun=true 时只报告可回收的空间"},
+	"GET /api/admin/storage/usage":                       {Summary: "按对象存储中的实际大小统计每个用户占用的空间"},
+	"GET /api/admin/transcode/stats":                     {Summary: "返回转码池的并发上限、运行中和排队中的任务数"},
+	"GET /api/admin/users/stats":                         {Summary: "返回各状态的用户数和每天的注册数"},
+	"PUT /api/admin/users/{id}/status":                   {Summary: "管理员手动设置账号状态，请求体 {\"status\": \"active\"}"},
+	"GET /api/albums":                                    {Summary: "获取用户的所有专辑"},
+	"POST /api/albums":                                   {Summary: "创建新专辑"},
+	"POST /api/albums/upload-tracks":                     {Summary: "批量上传歌曲到专辑，合辑可按文件顺序在 artists 字段中提供每首歌曲的艺术家；与已有曲目重复的文件在 duplicates 中列出"},
+	"GET /api/albums/user":                               {Summary: "获取用户的所有专辑（兼容旧路径）"},
+	"GET /api/albums/{id}":                               {Summary: "获取专辑信息"},
+	"PUT /api/albums/{id}":                               {Summary: "更新专辑信息"},
+	"DELETE /api/albums/{id}":                            {Summary: "删除专辑（移入回收站）"},
+	"GET /api/albums/{id}/download":                      {Summary: "将专辑中曲目的原始音频和封面打包为 ZIP 流式返回"},
+	"GET /api/albums/{id}/enrichment":                    {Summary: "从 MusicBrainz 查询专辑的发行日期、厂牌、曲目顺序和碟号，返回建议的修改，可用 releaseId 指定发行版"},
+	"POST /api/albums/{id}/enrichment":                   {Summary: "应用确认后的专辑元数据补全，请求体与建议接口返回的 proposed 相同，专辑和曲目在同一事务中更新"},
+	"GET /api/albums/{id}/tracks":                        {Summary: "获取专辑中的所有歌曲"},
+	"POST /api/albums/{id}/tracks":                       {Summary: "添加歌曲到专辑"},
+	"DELETE /api/albums/{id}/tracks/{track_id}":          {Summary: "从专辑中移除歌曲"},
+	"PUT /api/albums/{id}/tracks/{track_id}/position":    {Summary: "更新专辑中歌曲的位置，可同时提供 disc_number 和 track_number 设置碟号和碟内曲号"},
+	"GET /api/announcements":                             {Summary: "获取公告列表"},
+	"POST /api/announcements":                            {Summary: "创建公告", Admin: true},
+	"GET /api/announcements/all":                         {Summary: "获取所有未删除的公告，包括等待发布和已过期的公告", Admin: true},
+	"GET /api/announcements/stats":                       {Summary: "获取公告统计信息", Admin: true},
+	"GET /api/announcements/unread":                      {Summary: "获取未读公告"},
+	"PUT /api/announcements/{id}":                        {Summary: "更新公告", Admin: true},
+	"DELETE /api/announcements/{id}":                     {Summary: "删除公告", Admin: true},
+	"GET /api/announcements/{id}/history":                {Summary: "获取公告的当前版本和编辑历史", Admin: true},
+	"PUT /api/announcements/{id}/read":                   {Summary: "标记公告为已读"},
+	"GET /api/artists/{name}":                            {Summary: "返回歌手详情页，网易云部分按歌手名缓存"},
+	"GET /api/auth/captcha":                              {Summary: "获取人机验证参数（hCaptcha/Turnstile 站点密钥或工作量证明挑战）及当前是否需要验证"},
+	"GET /api/auth/jwks.json":                            {Summary: "JWT 校验公钥（JWKS，与 /.well-known/jwks.json 相同），包括即将启用和已停用但仍在有效期内的密钥"},
+	"POST /api/auth/forgot-password":                     {Summary: "向注册邮箱发送一次性的重置密码链接"},
+	"POST /api/auth/login":                               {Summary: "用户名或邮箱登录，返回 JWT"},
+	"POST /api/auth/register":                            {Summary: "注册账号"},
+	"POST /api/auth/resend-verification":                 {Summary: "重新发送验证邮件"},
+	"POST /api/auth/reset-password":                      {Summary: "使用重置令牌设置新密码"},
+	"GET /api/auth/verify-email":                         {Summary: "通过邮件中的链接验证邮箱，无需登录"},
+	"GET /api/cast/media":                                {Summary: "返回渲染器可直接拉取的签名地址"},
+	"GET /api/cast/renderers":                            {Summary: "返回局域网中的投屏设备"},
+	"POST /api/cast/renderers/{id}/load":                 {Summary: "在渲染器上加载并播放歌曲"},
+	"POST /api/cast/renderers/{id}/{action}":             {Summary: "控制渲染器"},
+	"DELETE /api/chat/clear":                             {Summary: "清空当前会话的聊天记录"},
+	"GET /api/chat/history":                              {Summary: "获取当前会话的聊天记录"},
+	"GET /api/chat/sessions":                             {Summary: "列出当前用户的会话，最近活跃的在前"},
+	"POST /api/chat/sessions":                            {Summary: "新建会话"},
+	"PATCH /api/chat/sessions/{id:[0-9]+}":               {Summary: "重命名、归档或恢复会话"},
+	"DELETE /api/chat/sessions/{id:[0-9]+}":              {Summary: "删除会话及其全部消息"},
+	"DELETE /api/comments/{id}":                          {Summary: "删除评论，只有评论作者和管理员可以删除"},
+	"GET /api/devices":                                   {Summary: "返回当前用户的在线设备"},
+	"POST /api/devices/{deviceId}/commands":              {Summary: "向当前用户的某个设备发送控制命令"},
+	"GET /api/digest/unsubscribe":                        {Summary: "打开邮件中的签名退订链接，返回退订确认页，不修改订阅状态"},
+	"POST /api/digest/unsubscribe":                       {Summary: "通过邮件中的签名链接退订每日摘要，无需登录，支持 RFC 8058 一键退订"},
+	"GET /api/errors":                                    {Summary: "返回错误码目录，供客户端生成错误处理代码"},
+	"GET /api/docs":                                      {Summary: "浏览接口文档的 Swagger UI 页面"},
+	"GET /api/feed":                                      {Summary: "获取当前用户关注的人的动态"},
+	"POST /api/import/metadata":                          {Summary: "上传 CSV/JSON 导出文件，按标题和歌手模糊匹配曲库中的曲目并批量修正标题、歌手、专辑、年份和流派，dryRun=true 时只返回预览"},
+	"GET /api/netease/artists/{id:[0-9]+}/top":           {Summary: "获取网易云歌手的热门歌曲"},
+	"GET /api/netease/charts":                            {Summary: "获取网易云排行榜列表"},
+	"GET /api/netease/charts/{id:[0-9]+}":                {Summary: "获取网易云排行榜的歌曲"},
+	"GET /api/netease/get/userids":                       {Summary: "按昵称查询网易云用户ID"},
+	"GET /api/netease/lyric/new":                         {Summary: "获取网易云歌曲的逐字歌词"},
+	"GET /api/netease/new":                               {Summary: "获取网易云新歌速递"},
+	"GET /api/netease/playlist/detail":                   {Summary: "获取网易云歌单详情"},
+	"GET /api/netease/search":                            {Summary: "搜索网易云歌曲"},
+	"GET /api/netease/song/detail":                       {Summary: "获取网易云歌曲详情"},
+	"GET /api/netease/song/dynamic/cover":                {Summary: "获取网易云歌曲的动态封面"},
+	"GET /api/netease/songs/{id:[0-9]+}/comments":        {Summary: "分页获取网易云歌曲在本实例内的评论"},
+	"POST /api/netease/songs/{id:[0-9]+}/comments":       {Summary: "为网易云歌曲发表评论"},
+	"POST /api/netease/update/info":                      {Summary: "更新当前用户绑定的网易云信息"},
+	"GET /api/netease/user/playlist":                     {Summary: "获取网易云用户的歌单"},
+	"POST /api/netease/{id:[0-9]+}/prepare":              {Summary: "用户即将播放一首网易云歌曲时调用"},
+	"GET /api/notifications":                             {Summary: "获取当前用户的通知"},
+	"PUT /api/notifications/read-all":                    {Summary: "将当前用户的所有通知标记为已读"},
+	"GET /api/notifications/unread-count":                {Summary: "获取当前用户的未读通知数"},
+	"PUT /api/notifications/{id:[0-9]+}/read":            {Summary: "将一条通知标记为已读"},
+	"POST /api/playback/heartbeat":                       {Summary: "客户端定期上报当前播放的歌曲和进度"},
+	"GET /api/openapi.json":                              {Summary: "由路由表生成的 OpenAPI 文档"},
+	"GET /api/playback/history":                          {Summary: "返回当前用户最近的播放历史"},
+	"PUT /api/playback/mode":                             {Summary: "修改循环（off、one、all）和随机播放模式，保存在播放状态中，所有设备共用"},
+	"POST /api/playback/sleep-timer":                     {Summary: "设置睡眠定时（分钟），到期时服务端让用户的所有在线设备暂停"},
+	"GET /api/playback/sleep-timer":                      {Summary: "返回当前的睡眠定时和剩余秒数"},
+	"DELETE /api/playback/sleep-timer":                   {Summary: "取消睡眠定时"},
+	"GET /api/playback/next":                             {Summary: "按循环和随机模式返回下一首歌曲、HLS 地址和就绪状态，未转码的网易云歌曲会被排队预处理，skip=true 表示手动切歌"},
+	"GET /api/playback/state":                            {Summary: "返回用户最近一次上报的播放进度和对应的歌曲"},
+	"GET /api/playlist":                                  {Summary: "获取、添加或删除当前用户播放列表中的歌曲"},
+	"POST /api/playlist":                                 {Summary: "添加一首或多首歌曲到播放列表，position=next 时插入到当前播放的歌曲之后，返回添加后的播放列表"},
+	"DELETE /api/playlist":                               {Summary: "获取、添加或删除当前用户播放列表中的歌曲"},
+	"POST /api/playlist/all":                             {Summary: "将用户的所有歌曲添加到播放列表"},
+	"GET /api/playlist/mixes":                            {Summary: "返回用户最近一次生成的 Daily Mix 推荐歌单"},
+	"POST /api/playlist/mixes/refresh":                   {Summary: "立即重新生成 Daily Mix，距上次生成不足 10 分钟时返回上次的结果"},
+	"POST /api/playlist/mixes/{id:[0-9]+}/queue":         {Summary: "把一个 Daily Mix 的歌曲加入播放列表，position=next 时插入到当前播放的歌曲之后"},
+	"GET /api/public/tracks/{id}":                        {Summary: "获取曲目的公开信息（含出处与许可），用于分享链接，需携带 raw-url 签发的分享签名"},
+	"POST /api/radio/start":                              {Summary: "开启 AI 电台"},
+	"POST /api/radio/stop":                               {Summary: "关闭 AI 电台，已追加的歌曲保留在队列中"},
+	"GET /api/recommendations":                           {Summary: "返回用户的推荐歌曲"},
+	"POST /api/rooms":                                    {Summary: "创建房间"},
+	"POST /api/rooms/control":                            {Summary: "授权成员控制播放"},
+	"POST /api/rooms/disband":                            {Summary: "解散房间（仅房主可操作）"},
+	"POST /api/rooms/invite":                             {Summary: "邀请用户加入房间，只有房间成员可以邀请，被邀请的用户会收到通知"},
+	"POST /api/rooms/join":                               {Summary: "加入房间"},
+	"POST /api/rooms/karaoke":                            {Summary: "开启或关闭房间卡拉 OK 模式（仅房主）"},
+	"POST /api/rooms/leave":                              {Summary: "离开房间"},
+	"POST /api/rooms/mode":                               {Summary: "切换房间的播放模式"},
+	"GET /api/rooms/my":                                  {Summary: "获取当前用户参与的房间列表"},
+	"POST /api/rooms/station":                            {Summary: "开启或关闭房间电台（仅房主）"},
+	"POST /api/rooms/transfer":                           {Summary: "转让房主"},
+	"GET /api/rooms/{room_id}":                           {Summary: "获取房间信息"},
+	"GET /api/rooms/{room_id}/karaoke":                   {Summary: "获取房间卡拉 OK 模式状态"},
+	"GET /api/rooms/{room_id}/messages":                  {Summary: "获取房间的历史消息"},
+	"GET /api/rooms/{room_id}/playback":                  {Summary: "获取房间的播放状态"},
+	"GET /api/rooms/{room_id}/playlist":                  {Summary: "获取房间歌单"},
+	"POST /api/rooms/{room_id}/playlist":                 {Summary: "添加歌曲到房间歌单"},
+	"GET /api/rooms/{room_id}/station":                   {Summary: "获取房间电台状态"},
+	"GET /api/rooms/{room_id}/summary":                   {Summary: "获取房间解散后生成的听歌总结，只有加入过房间的用户可以查看"},
+	"GET /api/scrobble/accounts":                         {Summary: "返回当前用户绑定的账号"},
+	"PUT /api/scrobble/accounts/lastfm":                  {Summary: "绑定 Last.fm 账号"},
+	"PUT /api/scrobble/accounts/listenbrainz":            {Summary: "绑定 ListenBrainz 账号"},
+	"DELETE /api/scrobble/accounts/{service}":            {Summary: "解绑账号"},
+	"GET /api/stats/streak":                              {Summary: "返回每日收听目标、今天的进度、连续达标天数、最长连续天数和最近 30 天的收听时长"},
+	"GET /api/streams/netease/{id}/events":               {Summary: "以 SSE 推送转码进度"},
+	"GET /api/streams/sign":                              {Summary: "为 /streams/ 下的播放列表签发带过期时间的地址，曲库中的流只签发给曲目所有者、持有分享签名或房间歌单中包含该曲目的成员"},
+	"GET /api/streams/{id}/events":                       {Summary: "以 SSE 推送转码进度"},
+	"GET /api/streams/{streamId}/key":                    {Summary: "下发 HLS 分片的 AES-128 密钥，只下发给可以播放该流的用户"},
+	"GET /api/tags":                                      {Summary: "返回当前用户的全部标签及各标签下的曲目数"},
+	"GET /api/tracks":                                    {Summary: "获取当前用户的曲目，支持按 artist、album、status、source、q、tag、from、to 筛选，按 sort、order 排序，按 limit、offset 分页（总数在 X-Total-Count 响应头中）"},
+	"PATCH /api/tracks/batch":                            {Summary: "批量修改曲目的标题、歌手、专辑、流派、年份和封面"},
+	"GET /api/tracks/duplicates":                         {Summary: "列出当前用户曲目中检测到的重复簇，比较次数达到上限时 truncated 为 true，只返回部分结果"},
+	"DELETE /api/tracks/{id}":                            {Summary: "删除曲目（移入回收站）"},
+	"GET /api/tracks/{id}/comments":                      {Summary: "分页获取本地曲目的评论"},
+	"POST /api/tracks/{id}/comments":                     {Summary: "为本地曲目发表评论"},
+	"PUT /api/tracks/{id}/file":                          {Summary: "替换曲目的音频并重新转码，原音频作为旧版本保留 TRACK_VERSION_RETENTION_DAYS 天"},
+	"GET /api/tracks/{id}/download-url":                  {Summary: "签发曲目源音频的限时下载地址，仅限曲目所有者"},
+	"PUT /api/tracks/{id}/license":                       {Summary: "更新曲目的许可/署名信息（仅限上传者）"},
+	"GET /api/tracks/{id}/presigned/playlist.m3u8":       {Summary: "返回分片地址替换为预签名地址的 HLS 播放列表，播放器直接从对象存储拉取分片"},
+	"GET /api/tracks/{id}/raw":                           {Summary: "以单个文件返回歌曲音频，供不支持 HLS 的客户端（机器人、简单播放器等）使用"},
+	"HEAD /api/tracks/{id}/raw":                          {Summary: "以单个文件返回歌曲音频，供不支持 HLS 的客户端（机器人、简单播放器等）使用"},
+	"GET /api/tracks/{id}/raw-url":                       {Summary: "为自己的歌曲签发可分享的直接播放地址"},
+	"POST /api/tracks/{id}/tags":                         {Summary: "为曲目添加标签"},
+	"DELETE /api/tracks/{id}/tags/{tag}":                 {Summary: "移除曲目的一个标签"},
+	"POST /api/tracks/{id}/verify":                       {Summary: "校验曲目的源音频、播放列表、分片和时长，发现损坏时从源音频重新转码，dryRun=true 时只报告"},
+	"GET /api/tracks/{id}/versions":                      {Summary: "列出曲目被替换后仍可恢复的旧版本"},
+	"POST /api/tracks/{id}/versions/{versionId}/restore": {Summary: "恢复曲目的旧版本音频，当前音频同样保存为旧版本"},
+	"GET /api/tracks/{id}/waveform":                      {Summary: "获取曲目的波形峰值数据，供前端渲染波形进度条"},
+	"GET /api/trash":                                     {Summary: "列出当前用户回收站中的曲目和专辑，最近删除的在前"},
+	"POST /api/trash/{id}/restore":                       {Summary: "从回收站恢复曲目或专辑，?type=album 恢复专辑，默认恢复曲目"},
+	"GET /api/trending":                                  {Summary: "返回实例内最近播放最多的歌曲"},
+	"POST /api/upload":                                   {Summary: "上传音频文件并创建曲目"},
+	"POST /api/upload/cover":                             {Summary: "上传封面图片，生成多尺寸 WebP/JPEG 变体"},
+	"POST /api/upload/finalize":                          {Summary: "客户端直传完成后的回调，校验对象并创建曲目，然后与普通上传一样后台转码"},
+	"POST /api/upload/presign":                           {Summary: "签发限时的 PUT 地址，客户端直接上传音频到对象存储，不经过 API 服务"},
+	"POST /api/user/netease/update":                      {Summary: "更新网易云信息"},
+	"GET /api/user/preferences/digest":                   {Summary: "获取当前用户的每日摘要邮件偏好"},
+	"PUT /api/user/preferences/digest":                   {Summary: "订阅/退订每日摘要邮件并设置语言"},
+	"GET /api/user/preferences/language":                 {Summary: "获取当前用户的语言偏好，language 为空表示按 Accept-Language"},
+	"PUT /api/user/preferences/language":                 {Summary: "设置当前用户的语言偏好，传空串恢复按 Accept-Language"},
+	"GET /api/user/preferences/scrobble":                 {Summary: "获取当前用户的听歌记录同步开关"},
+	"PUT /api/user/preferences/scrobble":                 {Summary: "开启/关闭向已绑定的 Last.fm / ListenBrainz 账号同步听歌记录"},
+	"GET /api/user/preferences/listening":                {Summary: "获取当前用户的每日收听目标"},
+	"PUT /api/user/preferences/listening":                {Summary: "设置每日收听目标（分钟，0 表示默认值）和是否接收连续达标的里程碑通知"},
+	"GET /api/user/preferences/social":                   {Summary: "获取当前用户的动态隐私设置"},
+	"PUT /api/user/preferences/social":                   {Summary: "更新当前用户的动态隐私设置，对已有动态同样生效"},
+	"GET /api/user/preferences/transcode":                {Summary: "获取当前用户的转码偏好"},
+	"PUT /api/user/preferences/transcode":                {Summary: "更新当前用户的转码偏好，偏好变化时后台重新生成该用户所有歌曲的 HLS 流"},
+	"GET /api/user/profile":                              {Summary: "获取用户资料"},
+	"PUT /api/user/profile":                              {Summary: "更新用户资料"},
+	"GET /api/users/me/bandwidth":                        {Summary: "返回当前用户最近每天从静态文件和流媒体接口下载的流量及每日软上限"},
+	"GET /api/users/me/quota":                            {Summary: "返回当前用户的存储用量和配额"},
+	"GET /api/users/{id:[0-9]+}/follow":                  {Summary: "获取用户的关注数、粉丝数以及当前用户是否已关注"},
+	"POST /api/users/{id:[0-9]+}/follow":                 {Summary: "关注用户"},
+	"DELETE /api/users/{id:[0-9]+}/follow":               {Summary: "取消关注用户"},
+	"GET /api/users/{id:[0-9]+}/followers":               {Summary: "获取关注该用户的用户列表"},
+	"GET /api/users/{id:[0-9]+}/following":               {Summary: "获取该用户关注的用户列表"},
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// minPasswordLength 重置密码时新密码的最小长度
+const minPasswordLength = 6
+
+// forgotPasswordMessage 无论邮箱是否已注册都返回相同的提示，避免泄露注册信息
+const forgotPasswordMessage = "If the email is registered, a password reset link has been sent"
+
+// resetEmailTexts 重置密码邮件的本地化文案
+type resetEmailTexts struct {
+	subject string
+	body    string // 参数：用户名、重置链接、有效期（分钟）
+}
+
+var resetEmailLocales = map[string]resetEmailTexts{
+	"zh-CN": {
+		subject: "Bt1QFM 重置密码",
+		body:    "%s，你好！\n\n我们收到了重置你的 Bt1QFM 账号密码的请求，点击以下链接设置新密码：\n\n%s\n\n链接 %d 分钟内有效，且只能使用一次。如果这不是你本人的操作，请忽略此邮件，你的密码不会改变。\n",
+	},
+	"en": {
+		subject: "Reset your Bt1QFM password",
+		body:    "Hi %s,\n\nWe received a request to reset the password of your Bt1QFM account. Open the link below to choose a new password:\n\n%s\n\nThe link expires in %d minutes and can only be used once. If you did not request this, you can ignore this email and your password will stay the same.\n",
+	},
+}
+
+// ForgotPasswordHandler 向注册邮箱发送一次性的重置密码链接，请求体 {"email": "..."}
+func (h *APIHandler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		writeError(w, CodeMissingField, "Email is required")
+		return
+	}
+
+	if !h.mailer.Enabled() {
+		writeError(w, CodeServiceUnavailable, "Password reset email is not available")
+		return
+	}
+
+	// 按邮箱限流，防止对同一地址反复发送邮件；超限时同样返回成功提示
+	if h.cfg.RateLimitEnabled && h.cfg.RateLimitPasswordReset.Enabled() {
+		if allowed, _ := checkRateLimit("password_reset_email", strings.ToLower(email), h.cfg.RateLimitPasswordReset); !allowed {
+			logger.Ctx(r.Context()).Warn("[ForgotPassword] 同一邮箱请求过于频繁", logger.String("email", email))
+			writeForgotPasswordResponse(w)
+			return
+		}
+	}
+
+	user, err := h.userRepo.GetUserByEmail(r.Context(), email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Ctx(r.Context()).Error("[ForgotPassword] 查询用户失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Internal server error")
+		return
+	}
+	if user == nil {
+		logger.Ctx(r.Context()).Info("[ForgotPassword] 邮箱未注册", logger.String("email", email))
+		writeForgotPasswordResponse(w)
+		return
+	}
+
+	token, tokenHash, err := auth.GenerateOneTimeToken()
+	if err != nil {
+		logger.Ctx(r.Context()).Error("[ForgotPassword] 生成重置令牌失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Internal server error")
+		return
+	}
+	ttl := time.Duration(h.cfg.PasswordResetTokenTTLMinutes) * time.Minute
+	if err := cache.SaveOneTimeToken(r.Context(), cache.TokenPasswordReset, user.ID, tokenHash, ttl); err != nil {
+		logger.Ctx(r.Context()).Error("[ForgotPassword] 保存重置令牌失败", logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Password reset is temporarily unavailable")
+		return
+	}
+
+	// 异步发送，响应时间不随邮箱是否注册而变化
+	log := logger.Ctx(r.Context())
+	go func() {
+		if err := h.sendPasswordResetEmail(user, token); err != nil {
+			log.Error("[ForgotPassword] 发送重置密码邮件失败",
+				logger.Int64("userId", user.ID),
+				logger.ErrorField(err))
+			return
+		}
+		log.Info("[ForgotPassword] 已发送重置密码邮件", logger.Int64("userId", user.ID))
+	}()
+
+	writeForgotPasswordResponse(w)
+}
+
+// ResetPasswordHandler 使用重置令牌设置新密码，请求体 {"token": "...", "password": "..."}
+func (h *APIHandler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" || req.Password == "" {
+		writeError(w, CodeMissingField, "Token and password are required")
+		return
+	}
+	if len([]rune(req.Password)) < minPasswordLength {
+		writeError(w, CodeBadRequest, fmt.Sprintf("Password must be at least %d characters", minPasswordLength))
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		writeError(w, CodeInternal, "Failed to process password")
+		return
+	}
+
+	// 先校验密码再消耗令牌，密码不合法时令牌仍可使用
+	userID, err := cache.ConsumeOneTimeToken(r.Context(), cache.TokenPasswordReset, auth.HashOneTimeToken(req.Token))
+	if err != nil {
+		logger.Ctx(r.Context()).Error("[ResetPassword] 读取重置令牌失败", logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Password reset is temporarily unavailable")
+		return
+	}
+	if userID == 0 {
+		writeError(w, CodeInvalidResetToken, "Invalid or expired reset token")
+		return
+	}
+
+	if err := h.userRepo.UpdatePassword(r.Context(), userID, hashedPassword); err != nil {
+		logger.Ctx(r.Context()).Error("[ResetPassword] 更新密码失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to reset password")
+		return
+	}
+
+	logger.Ctx(r.Context()).Info("[ResetPassword] 密码已重置", logger.Int64("userId", userID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Password has been reset",
+	})
+}
+
+// sendPasswordResetEmail 按用户的语言偏好发送重置密码邮件
+func (h *APIHandler) sendPasswordResetEmail(user *model.User, token string) error {
+	texts, ok := resetEmailLocales[user.GetPreferences().Digest.Locale]
+	if !ok {
+		texts = resetEmailLocales["zh-CN"]
+	}
+
+	q := url.Values{}
+	q.Set("token", token)
+	link := strings.TrimRight(h.cfg.PublicBaseURL, "/") + "/reset-password?" + q.Encode()
+
+	body := fmt.Sprintf(texts.body, user.Username, link, h.cfg.PasswordResetTokenTTLMinutes)
+	return h.mailer.Send(user.Email, texts.subject, body, nil)
+}
+
+// writeForgotPasswordResponse 返回统一的找回密码提示
+func writeForgotPasswordResponse(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": localize(w, forgotPasswordMessage),
+	})
+}
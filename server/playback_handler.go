@@ -0,0 +1,353 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/radio"
+	"Bt1QFM/core/scrobble"
+	"Bt1QFM/logger"
+)
+
+// maxDeviceIDLength 设备标识的最大长度
+const maxDeviceIDLength = 64
+
+// SetPlaybackTracker 设置收听跟踪器，心跳会同时用于记录播放历史和同步听歌记录
+func (h *APIHandler) SetPlaybackTracker(tracker *scrobble.Tracker) {
+	h.playbackTracker = tracker
+}
+
+// SetRadioService 设置 AI 电台服务，开启电台的用户在队列快播完时由心跳触发续播
+func (h *APIHandler) SetRadioService(service *radio.Service) {
+	h.radioService = service
+}
+
+// SetStreamHandler 设置流媒体处理器，查询下一首时用于触发网易云歌曲的预处理
+func (h *APIHandler) SetStreamHandler(handler *StreamHandler) {
+	h.streamHandler = handler
+}
+
+// PlaybackHeartbeatHandler 客户端定期上报当前播放的歌曲和进度，POST /api/playback/heartbeat
+// 请求体 {"index": 3, "position": 42.5, "isPlaying": true, "source": "netease", "sourceId": "123", "deviceId": "..."}
+// 只在切换歌曲时读取播放列表和写播放历史，其余心跳只读写 Redis，可以高频调用
+func (h *APIHandler) PlaybackHeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Index     int     `json:"index"`
+		Position  float64 `json:"position"`
+		IsPlaying bool    `json:"isPlaying"`
+		Source    string  `json:"source"`
+		SourceID  string  `json:"sourceId"`
+		DeviceID  string  `json:"deviceId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if req.Index < 0 {
+		writeError(w, CodeBadRequest, "Index must not be negative")
+		return
+	}
+	if req.Position < 0 || math.IsNaN(req.Position) || math.IsInf(req.Position, 0) {
+		writeError(w, CodeBadRequest, "Invalid position")
+		return
+	}
+	if (req.Source == "") != (req.SourceID == "") {
+		writeError(w, CodeMissingField, "Source and sourceId must be provided together")
+		return
+	}
+	if len(req.DeviceID) > maxDeviceIDLength {
+		req.DeviceID = req.DeviceID[:maxDeviceIDLength]
+	}
+
+	state := &cache.UserPlaybackState{
+		CurrentIndex: req.Index,
+		Position:     req.Position,
+		IsPlaying:    req.IsPlaying,
+		Source:       strings.ToLower(req.Source),
+		SourceID:     req.SourceID,
+		DeviceID:     req.DeviceID,
+		UpdatedAt:    time.Now().UnixMilli(),
+	}
+	if err := cache.SaveUserPlaybackHeartbeat(r.Context(), userID, state); err != nil {
+		logger.Ctx(r.Context()).Error("保存播放进度失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to save playback state")
+		return
+	}
+
+	// 播放历史只是附带记录，失败不影响进度保存
+	if h.playbackTracker != nil {
+		err := h.playbackTracker.Heartbeat(r.Context(), userID, scrobble.Beat{
+			Source:    state.Source,
+			SourceID:  state.SourceID,
+			Position:  state.Position,
+			IsPlaying: state.IsPlaying,
+		})
+		if err != nil {
+			logger.Ctx(r.Context()).Warn("记录播放历史失败",
+				logger.Int64("userId", userID),
+				logger.ErrorField(err))
+		}
+	}
+	if h.radioService != nil {
+		h.radioService.OnHeartbeat(r.Context(), userID, state)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPlaybackStateHandler 返回用户最近一次上报的播放进度和对应的歌曲，GET /api/playback/state
+// 播放列表在其他设备上被修改时，按上报的歌曲重新定位索引；歌曲已被移除时从头播放该位置的歌曲
+func (h *APIHandler) GetPlaybackStateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	state, err := cache.GetUserPlaybackState(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取播放进度失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to get playback state")
+		return
+	}
+	if state == nil {
+		writePlaybackState(w, nil, nil)
+		return
+	}
+
+	playlist, err := cache.GetPlaylist(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取播放列表失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to get playlist")
+		return
+	}
+	if len(playlist) == 0 {
+		writePlaybackState(w, nil, nil)
+		return
+	}
+
+	index := resolvePlaybackIndex(state, playlist)
+	if index != state.CurrentIndex {
+		logger.Ctx(r.Context()).Debug("播放列表已变化，重新定位播放索引",
+			logger.Int64("userId", userID),
+			logger.Int("from", state.CurrentIndex),
+			logger.Int("to", index))
+		state.CurrentIndex = index
+	}
+	// 上报的歌曲已不在列表中，进度对新歌曲没有意义
+	if state.SourceID != "" && !playlist[index].Matches(state.Source, state.SourceID) {
+		state.Position = 0
+	}
+	item := playlist[index]
+	writePlaybackState(w, state, &item)
+}
+
+// resolvePlaybackIndex 优先使用上报的索引，索引处不是上报的歌曲时按来源查找，找不到时夹在列表范围内
+func resolvePlaybackIndex(state *cache.UserPlaybackState, playlist []cache.PlaylistItem) int {
+	index := state.CurrentIndex
+	if state.SourceID != "" {
+		if index < len(playlist) && playlist[index].Matches(state.Source, state.SourceID) {
+			return index
+		}
+		for i := range playlist {
+			if playlist[i].Matches(state.Source, state.SourceID) {
+				return i
+			}
+		}
+	}
+	if index >= len(playlist) {
+		index = len(playlist) - 1
+	}
+	return index
+}
+
+// writePlaybackState 返回播放进度，没有可恢复的进度时 data 为 null
+func writePlaybackState(w http.ResponseWriter, state *cache.UserPlaybackState, item *cache.PlaylistItem) {
+	var data interface{}
+	if state != nil {
+		data = map[string]interface{}{
+			"index":     state.CurrentIndex,
+			"position":  state.Position,
+			"isPlaying": state.IsPlaying,
+			"deviceId":  state.DeviceID,
+			"updatedAt": state.UpdatedAt,
+			"repeat":    state.RepeatMode(),
+			"shuffle":   state.Shuffle,
+			"track":     playbackTrack(item),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// playbackTrack 播放状态接口中的歌曲信息
+func playbackTrack(item *cache.PlaylistItem) map[string]interface{} {
+	return map[string]interface{}{
+		"source":   item.Source,
+		"sourceId": item.SourceID,
+		"title":    item.Title,
+		"artist":   item.Artist,
+		"album":    item.Album,
+		"cover":    item.Cover,
+		"duration": item.Duration,
+		"hlsUrl":   item.HLSURL,
+	}
+}
+
+// SetPlaybackModeHandler 修改循环和随机模式，PUT /api/playback/mode
+// 请求体 {"repeat": "off|one|all", "shuffle": true}，未提供的字段保持不变；模式保存在播放状态中，所有设备共用
+func (h *APIHandler) SetPlaybackModeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Repeat  *string `json:"repeat"`
+		Shuffle *bool   `json:"shuffle"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if req.Repeat == nil && req.Shuffle == nil {
+		writeError(w, CodeMissingField, "Either repeat or shuffle must be provided")
+		return
+	}
+	if req.Repeat != nil {
+		mode := strings.ToLower(strings.TrimSpace(*req.Repeat))
+		if !cache.ValidRepeatMode(mode) {
+			writeError(w, CodeBadRequest, "Invalid repeat mode")
+			return
+		}
+		req.Repeat = &mode
+	}
+
+	state, err := cache.SetUserPlaybackMode(r.Context(), userID, req.Repeat, req.Shuffle)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("保存播放模式失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to save playback state")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"repeat":  state.RepeatMode(),
+			"shuffle": state.Shuffle,
+		},
+	})
+}
+
+// GetNextTrackHandler 按循环和随机模式返回当前歌曲之后播放的歌曲，GET /api/playback/next?skip=true
+// 当前歌曲按最近上报的播放状态定位；skip=true 表示用户手动切歌，单曲循环时也切到下一首；没有下一首时 data 为 null
+// 同时返回下一首的 HLS 地址和就绪状态，尚未转码的网易云歌曲会被排队预处理，
+// 客户端可以据此提前加载，并按当前歌曲的剩余秒数安排淡入淡出
+func (h *APIHandler) GetNextTrackHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	state, err := cache.GetUserPlaybackState(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取播放进度失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to get playback state")
+		return
+	}
+	playlist, err := cache.GetPlaylist(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取播放列表失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to get playlist")
+		return
+	}
+
+	var data interface{}
+	if state == nil {
+		state = &cache.UserPlaybackState{}
+	}
+	if len(playlist) > 0 {
+		current := resolvePlaybackIndex(state, playlist)
+		if next := state.NextIndex(playlist, current, r.URL.Query().Get("skip") == "true"); next >= 0 {
+			item := &playlist[next]
+			prepare := h.prefetchNextTrack(r.Context(), item)
+			entry := map[string]interface{}{
+				"index":   next,
+				"repeat":  state.RepeatMode(),
+				"shuffle": state.Shuffle,
+				"track":   playbackTrack(item),
+				"prepare": prepare,
+			}
+			// 上报的歌曲仍是当前歌曲时才能算出剩余时间
+			if duration := float64(playlist[current].Duration); duration > 0 &&
+				(state.SourceID == "" || playlist[current].Matches(state.Source, state.SourceID)) {
+				entry["remainingSeconds"] = math.Max(duration-state.Position, 0)
+			}
+			data = entry
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// prefetchNextTrack 返回下一首的就绪状态：本地曲目按处理状态判断，网易云歌曲未转码时排队预处理
+func (h *APIHandler) prefetchNextTrack(ctx context.Context, item *cache.PlaylistItem) audio.PrepareStatus {
+	switch item.Source {
+	case cache.SourceLocal:
+		track, err := h.trackRepo.GetTrackByID(ctx, item.TrackID)
+		if err != nil || track == nil {
+			return audio.PrepareStatus{State: audio.PrepareStateFailed, Error: "track not found"}
+		}
+		switch track.Status {
+		case "completed":
+			if track.HLSPlaylistPath != "" {
+				item.HLSURL = track.HLSPlaylistPath
+			}
+			return audio.PrepareStatus{State: prepareStateReady}
+		case "failed":
+			return audio.PrepareStatus{State: audio.PrepareStateFailed}
+		default:
+			return audio.PrepareStatus{State: audio.PrepareStateProcessing}
+		}
+	case cache.SourceNetease:
+		if h.streamHandler != nil {
+			return h.streamHandler.PrefetchNeteaseSong(item.SourceID)
+		}
+	}
+	return audio.PrepareStatus{State: audio.PrepareStateIdle}
+}
@@ -1,14 +1,14 @@
 package server
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	// "Bt1QFM/db"
 	"Bt1QFM/cache"
@@ -18,21 +18,10 @@ import (
 
 // PlaylistHandler 处理播放列表相关的请求
 func (h *APIHandler) PlaylistHandler(w http.ResponseWriter, r *http.Request) {
-	// 设置CORS头，允许跨域请求
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-	// 处理预检请求
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	// 获取当前用户ID（从认证中间件中获取）
 	userID, err := GetUserIDFromContext(r.Context())
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -60,238 +49,294 @@ func (h *APIHandler) PlaylistHandler(w http.ResponseWriter, r *http.Request) {
 			h.UpdatePlaylistOrderHandler(ctx, userID, w, r)
 		}
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 	}
 }
 
 // GetPlaylistHandler 返回用户的播放列表
+// 每项都带有统一的 source、sourceId、title、artist、cover、hlsUrl，
+// 同时保留 trackId/neteaseId、coverArtPath、hlsPlaylistUrl 以兼容旧客户端
 func (h *APIHandler) GetPlaylistHandler(ctx context.Context, userID int64, w http.ResponseWriter, r *http.Request) {
 	// 检查用户ID是否有效
 	if userID <= 0 {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid user ID")
 		return
 	}
 
-	// 获取播放列表
+	// 获取播放列表（旧格式的项目会在读取时迁移）
 	playlist, err := cache.GetPlaylist(ctx, userID)
 	if err != nil {
 		log.Printf("Error getting playlist for user %d: %v", userID, err)
-		http.Error(w, fmt.Sprintf("Failed to get playlist: %v", err), http.StatusInternalServerError)
+		writeError(w, CodeInternal, fmt.Sprintf("Failed to get playlist: %v", err))
 		return
 	}
 
-	// 如果播放列表为空，返回空数组
-	if playlist == nil {
-		playlist = []cache.PlaylistItem{}
+	enhancedPlaylist, err := h.playlistEntries(ctx, playlist)
+	if err != nil {
+		log.Printf("Client disconnected while building playlist for user %d: %v", userID, err)
+		return
 	}
 
-	// 为每首歌添加完整信息（如果需要）
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"playlist": enhancedPlaylist,
+	})
+}
+
+// playlistEntries 生成播放列表接口返回的项目，客户端断开时返回 ctx 的错误
+func (h *APIHandler) playlistEntries(ctx context.Context, playlist []cache.PlaylistItem) ([]map[string]interface{}, error) {
 	enhancedPlaylist := make([]map[string]interface{}, 0, len(playlist))
 	for _, item := range playlist {
-		// 如果是网易云音乐的歌曲
-		if item.NeteaseID != 0 {
-			enhancedPlaylist = append(enhancedPlaylist, map[string]interface{}{
-				"neteaseId":      item.NeteaseID,
-				"title":          item.Title,
-				"artist":         item.Artist,
-				"album":          item.Album,
-				"position":       item.Position,
-				"hlsPlaylistUrl": fmt.Sprintf("/streams/netease/%d/playlist.m3u8", item.NeteaseID),
-			})
-			continue
+		// 客户端已断开时不再逐条查询
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
 
-		// 检查trackRepo是否初始化
-		if h.trackRepo == nil {
-			log.Printf("Error: trackRepo is not initialized")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
+		// 本地歌曲以数据库中的最新信息为准
+		if item.Source == cache.SourceLocal {
+			track, err := h.trackRepo.GetTrackByID(ctx, item.TrackID)
+			if err != nil {
+				log.Printf("Warning: Failed to get full info for track %d: %v", item.TrackID, err)
+			} else if track != nil {
+				item.Title = track.Title
+				item.Artist = track.Artist
+				item.Album = track.Album
+				item.Cover = track.CoverArtPath
+				item.Duration = int(track.Duration)
+			}
 		}
 
-		track, err := h.trackRepo.GetTrackByID(item.TrackID)
-		if err != nil {
-			log.Printf("Warning: Failed to get full info for track %d: %v", item.TrackID, err)
-			// 使用现有的播放列表项信息
-			enhancedPlaylist = append(enhancedPlaylist, map[string]interface{}{
-				"trackId":  item.TrackID,
-				"title":    item.Title,
-				"artist":   item.Artist,
-				"album":    item.Album,
-				"position": item.Position,
-			})
-		} else if track != nil {
-			// 使用从数据库获取的完整信息
-			enhancedPlaylist = append(enhancedPlaylist, map[string]interface{}{
-				"trackId":        track.ID,
-				"title":          track.Title,
-				"artist":         track.Artist,
-				"album":          track.Album,
-				"position":       item.Position,
-				"coverArtPath":   track.CoverArtPath,
-				"hlsPlaylistUrl": fmt.Sprintf("/streams/%d/playlist.m3u8", track.ID),
-			})
+		entry := map[string]interface{}{
+			"source":         item.Source,
+			"sourceId":       item.SourceID,
+			"title":          item.Title,
+			"artist":         item.Artist,
+			"album":          item.Album,
+			"cover":          item.Cover,
+			"duration":       item.Duration,
+			"hlsUrl":         item.HLSURL,
+			"position":       item.Position,
+			"coverArtPath":   item.Cover,
+			"hlsPlaylistUrl": item.HLSURL,
+		}
+		if item.Source == cache.SourceNetease {
+			entry["neteaseId"] = item.NeteaseID
 		} else {
-			// 如果track为nil，使用播放列表项的基本信息
-			enhancedPlaylist = append(enhancedPlaylist, map[string]interface{}{
-				"trackId":  item.TrackID,
-				"title":    item.Title,
-				"artist":   item.Artist,
-				"album":    item.Album,
-				"position": item.Position,
-			})
+			entry["trackId"] = item.TrackID
 		}
+		enhancedPlaylist = append(enhancedPlaylist, entry)
 	}
+	return enhancedPlaylist, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"playlist": enhancedPlaylist,
-	})
+// maxPlaylistBatch 一次最多添加到播放列表的歌曲数
+const maxPlaylistBatch = 500
+
+// playlistItemRequest 添加到播放列表的一首歌曲
+type playlistItemRequest struct {
+	Source       string `json:"source,omitempty"`
+	SourceID     string `json:"sourceId,omitempty"`
+	TrackID      int64  `json:"trackId,omitempty"`
+	NeteaseID    int64  `json:"neteaseId,omitempty"`
+	Title        string `json:"title"`
+	Artist       string `json:"artist"`
+	Album        string `json:"album"`
+	Cover        string `json:"cover,omitempty"`
+	CoverArtPath string `json:"coverArtPath,omitempty"`
+	Duration     int    `json:"duration,omitempty"`
+	HLSURL       string `json:"hlsUrl,omitempty"`
 }
 
 // AddToPlaylistHandler 将歌曲添加到播放列表
+// 请求体为 {"source": "netease", "sourceId": "123", "title": ..., "artist": ..., "cover": ...}，
+// 外部来源的歌曲直接保存在播放列表项中，不再需要在数据库中创建占位记录；
+// 旧的 {"trackId": 1} / {"neteaseId": 123} 格式仍然可用。
+// 批量添加时使用 {"items": [...]} 或 {"trackIds": [1, 2]}，歌曲按给定顺序连续插入，任意一首无效时都不添加；
+// "position": "next" 插入到当前播放的歌曲之后，默认追加到末尾。返回添加后的播放列表
 func (h *APIHandler) AddToPlaylistHandler(ctx context.Context, userID int64, w http.ResponseWriter, r *http.Request) {
 	var requestData struct {
-		TrackID   int64  `json:"trackId,omitempty"`
-		NeteaseID int64  `json:"neteaseId,omitempty"`
-		Title     string `json:"title"`
-		Artist    string `json:"artist"`
-		Album     string `json:"album"`
+		playlistItemRequest
+		Items    []playlistItemRequest `json:"items,omitempty"`
+		TrackIDs []int64               `json:"trackIds,omitempty"`
+		Position string                `json:"position,omitempty"` // end、next
 	}
 
-	// 读取原始请求体
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("[AddToPlaylistHandler] 读取请求体失败: %v", err)
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return
-	}
-	log.Printf("[AddToPlaylistHandler] 原始请求体: %s", string(bodyBytes))
-
-	// 重置请求体
-	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
 		log.Printf("[AddToPlaylistHandler] 解析请求数据失败: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "Invalid request body")
 		return
 	}
 
-	log.Printf("[AddToPlaylistHandler] 开始处理添加歌曲请求 (用户ID: %d)", userID)
-	log.Printf("[AddToPlaylistHandler] 请求数据: {TrackID:%d NeteaseID:%d Title:%s Artist:%s Album:%s}",
-		requestData.TrackID, requestData.NeteaseID, requestData.Title, requestData.Artist, requestData.Album)
-
-	// 检查是否提供了有效的ID
-	if requestData.TrackID == 0 && requestData.NeteaseID == 0 {
-		log.Printf("[AddToPlaylistHandler] 错误: 未提供有效的ID")
-		http.Error(w, "Either trackId or neteaseId must be provided", http.StatusBadRequest)
+	requests := requestData.Items
+	for _, id := range requestData.TrackIDs {
+		requests = append(requests, playlistItemRequest{TrackID: id})
+	}
+	if len(requests) == 0 {
+		requests = []playlistItemRequest{requestData.playlistItemRequest}
+	}
+	if len(requests) > maxPlaylistBatch {
+		writeError(w, CodeBadRequest, "Too many tracks in one request")
+		return
+	}
+	var playNext bool
+	switch requestData.Position {
+	case "", "end":
+	case "next":
+		playNext = true
+	default:
+		writeError(w, CodeBadRequest, "Invalid position")
 		return
 	}
 
-	// 如果是网易云音乐的歌曲
-	if requestData.NeteaseID != 0 {
-		log.Printf("[AddToPlaylistHandler] 验证网易云音乐歌曲信息 (ID: %d)", requestData.NeteaseID)
-		// 验证网易云音乐歌曲信息
-		neteaseRepo := repository.NewNeteaseSongRepository()
-		song, err := neteaseRepo.GetNeteaseSongByID(fmt.Sprintf("%d", requestData.NeteaseID))
-		if err != nil {
-			log.Printf("[AddToPlaylistHandler] 获取网易云音乐歌曲信息失败 (ID: %d): %v", requestData.NeteaseID, err)
-			http.Error(w, "Failed to get netease song information", http.StatusInternalServerError)
+	items := make([]cache.PlaylistItem, 0, len(requests))
+	for _, req := range requests {
+		item, ok := h.resolvePlaylistItem(ctx, userID, w, req)
+		if !ok {
 			return
 		}
-		if song == nil {
-			log.Printf("[AddToPlaylistHandler] 网易云音乐歌曲不存在，尝试使用请求数据创建记录 (ID: %d)", requestData.NeteaseID)
-			// 当数据库中不存在时，尝试插入一条简单记录，保证后续播放功能正常
-			hlsPath := fmt.Sprintf("/streams/netease/%d/playlist.m3u8", requestData.NeteaseID)
-			newSong := &model.NeteaseSongDB{
-				ID:              requestData.NeteaseID,
-				Title:           requestData.Title,
-				Artist:          requestData.Artist,
-				Album:           requestData.Album,
-				HLSPlaylistPath: hlsPath,
-			}
-			if _, err := neteaseRepo.InsertNeteaseSong(newSong); err != nil {
-				log.Printf("[AddToPlaylistHandler] 创建网易云歌曲记录失败 (ID: %d): %v", requestData.NeteaseID, err)
-				http.Error(w, "Failed to create netease song", http.StatusInternalServerError)
-				return
-			}
-			song = newSong
-		}
+		items = append(items, item)
+	}
 
-		log.Printf("[AddToPlaylistHandler] 找到/创建网易云歌曲: %s - %s", song.Title, song.Artist)
-		// 使用数据库中的信息更新请求数据
-		requestData.Title = song.Title
-		requestData.Artist = song.Artist
-		requestData.Album = song.Album
-	} else if requestData.TrackID != 0 {
-		log.Printf("[AddToPlaylistHandler] 验证普通歌曲信息 (TrackID: %d)", requestData.TrackID)
-		// 如果是普通歌曲，验证歌曲信息
-		track, err := h.trackRepo.GetTrackByID(requestData.TrackID)
-		if err != nil {
-			log.Printf("[AddToPlaylistHandler] 获取普通歌曲信息失败 (ID: %d): %v", requestData.TrackID, err)
-			http.Error(w, "Failed to get track information", http.StatusInternalServerError)
-			return
-		}
-		if track == nil {
-			log.Printf("[AddToPlaylistHandler] 普通歌曲不存在 (ID: %d)", requestData.TrackID)
-			http.Error(w, "Track not found", http.StatusNotFound)
-			return
-		}
-		log.Printf("[AddToPlaylistHandler] 找到普通歌曲: %s - %s", track.Title, track.Artist)
+	playlist, err := cache.InsertTracksIntoPlaylist(ctx, userID, items, playNext)
+	if err != nil {
+		log.Printf("[AddToPlaylistHandler] 添加到播放列表失败: %v", err)
+		writeError(w, CodeInternal, fmt.Sprintf("Failed to add track to playlist: %v", err))
+		return
+	}
+	log.Printf("[AddToPlaylistHandler] 成功添加 %d 首歌曲到播放列表 (用户ID: %d, 下一首播放: %t)", len(items), userID, playNext)
+
+	if h.socialService != nil {
+		h.socialService.Record(ctx, &model.Activity{
+			UserID:   userID,
+			Type:     model.ActivityPlaylistAdded,
+			ObjectID: strconv.Itoa(len(items)),
+			Source:   items[0].Source,
+			SourceID: items[0].SourceID,
+			Title:    items[0].Title,
+		})
 	}
 
+	entries, err := h.playlistEntries(ctx, playlist)
+	if err != nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Track added to playlist successfully",
+		"count":    len(items),
+		"playlist": entries,
+	})
+}
+
+// resolvePlaylistItem 校验要添加的歌曲并补全歌曲信息，失败时已写入错误响应
+func (h *APIHandler) resolvePlaylistItem(ctx context.Context, userID int64, w http.ResponseWriter, requestData playlistItemRequest) (cache.PlaylistItem, bool) {
 	item := cache.PlaylistItem{
+		Source:    strings.ToLower(strings.TrimSpace(requestData.Source)),
+		SourceID:  strings.TrimSpace(requestData.SourceID),
 		TrackID:   requestData.TrackID,
 		NeteaseID: requestData.NeteaseID,
-		Title:     requestData.Title,
-		Artist:    requestData.Artist,
-		Album:     requestData.Album,
+		Title:     strings.TrimSpace(requestData.Title),
+		Artist:    strings.TrimSpace(requestData.Artist),
+		Album:     strings.TrimSpace(requestData.Album),
+		Cover:     requestData.Cover,
+		Duration:  requestData.Duration,
+		AddedBy:   userID,
+		AddedAt:   time.Now().UnixMilli(),
+	}
+	if item.Cover == "" {
+		item.Cover = requestData.CoverArtPath
+	}
+	// 播放地址由服务端决定，只接受站内的 HLS 地址
+	if strings.HasPrefix(requestData.HLSURL, "/streams/") {
+		item.HLSURL = requestData.HLSURL
 	}
 
-	log.Printf("[AddToPlaylistHandler] 准备添加到播放列表: {TrackID:%d NeteaseID:%d Title:%s Artist:%s Album:%s}",
-		item.TrackID, item.NeteaseID, item.Title, item.Artist, item.Album)
+	if item.Source == "" && item.SourceID == "" && item.TrackID == 0 && item.NeteaseID == 0 {
+		writeError(w, CodeMissingField, "Either source and sourceId, trackId or neteaseId must be provided")
+		return item, false
+	}
+	if item.Source != "" && item.Source != cache.SourceLocal && item.Source != cache.SourceNetease {
+		writeError(w, CodeBadRequest, fmt.Sprintf("Unsupported source: %s", item.Source))
+		return item, false
+	}
+	if !item.Normalize() {
+		writeError(w, CodeInvalidID, "Invalid sourceId")
+		return item, false
+	}
 
-	if err := cache.AddTrackToPlaylist(ctx, userID, item); err != nil {
-		log.Printf("[AddToPlaylistHandler] 添加到播放列表失败: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to add track to playlist: %v", err), http.StatusInternalServerError)
-		return
+	switch item.Source {
+	case cache.SourceLocal:
+		track, err := h.trackRepo.GetTrackByID(ctx, item.TrackID)
+		if err != nil {
+			log.Printf("[resolvePlaylistItem] 获取普通歌曲信息失败 (ID: %d): %v", item.TrackID, err)
+			writeError(w, CodeInternal, "Failed to get track information")
+			return item, false
+		}
+		if track == nil {
+			writeError(w, CodeTrackNotFound, "Track not found")
+			return item, false
+		}
+		item.Title = track.Title
+		item.Artist = track.Artist
+		item.Album = track.Album
+		item.Cover = track.CoverArtPath
+		item.Duration = int(track.Duration)
+	case cache.SourceNetease:
+		// 客户端未提供歌曲信息时尝试使用已缓存的网易云歌曲记录补全
+		if item.Title == "" {
+			song, err := repository.NewNeteaseSongRepository().GetNeteaseSongByID(item.SourceID)
+			if err != nil {
+				log.Printf("[resolvePlaylistItem] 获取网易云音乐歌曲信息失败 (ID: %s): %v", item.SourceID, err)
+			} else if song != nil {
+				item.Title = song.Title
+				item.Artist = song.Artist
+				item.Album = song.Album
+				if item.Cover == "" {
+					item.Cover = song.CoverArtPath
+				}
+				if item.Duration == 0 {
+					item.Duration = int(song.Duration)
+				}
+			}
+		}
+		if item.Title == "" {
+			writeError(w, CodeMissingField, "Title is required for netease songs")
+			return item, false
+		}
 	}
 
-	log.Printf("[AddToPlaylistHandler] 成功添加到播放列表")
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Track added to playlist successfully",
-	})
+	return item, true
 }
 
 // RemoveFromPlaylistHandler 从播放列表中删除歌曲
+// 支持 ?source=netease&sourceId=123，以及旧的 ?trackId= / ?neteaseId=
 func (h *APIHandler) RemoveFromPlaylistHandler(ctx context.Context, userID int64, w http.ResponseWriter, r *http.Request) {
-	// 获取 trackId 或 neteaseId
-	trackIDStr := r.URL.Query().Get("trackId")
-	neteaseIDStr := r.URL.Query().Get("neteaseId")
-
-	var trackID int64
-	var err error
-
-	if trackIDStr != "" {
-		trackID, err = strconv.ParseInt(trackIDStr, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid track ID format", http.StatusBadRequest)
+	query := r.URL.Query()
+	source := strings.ToLower(query.Get("source"))
+	sourceID := query.Get("sourceId")
+
+	switch {
+	case source != "" || sourceID != "":
+		if source == "" || sourceID == "" {
+			writeError(w, CodeMissingField, "Both source and sourceId are required")
 			return
 		}
-	} else if neteaseIDStr != "" {
-		trackID, err = strconv.ParseInt(neteaseIDStr, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid netease ID format", http.StatusBadRequest)
-			return
-		}
-	} else {
-		http.Error(w, "Either trackId or neteaseId is required", http.StatusBadRequest)
+	case query.Get("trackId") != "":
+		source, sourceID = cache.SourceLocal, query.Get("trackId")
+	case query.Get("neteaseId") != "":
+		source, sourceID = cache.SourceNetease, query.Get("neteaseId")
+	default:
+		writeError(w, CodeMissingField, "Either source and sourceId, trackId or neteaseId is required")
+		return
+	}
+	if id, err := strconv.ParseInt(sourceID, 10, 64); err != nil || id <= 0 {
+		writeError(w, CodeInvalidID, "Invalid ID format")
 		return
 	}
 
-	if err := cache.RemoveTrackFromPlaylist(ctx, userID, trackID); err != nil {
+	if err := cache.RemoveSourceFromPlaylist(ctx, userID, source, sourceID); err != nil {
 		log.Printf("Error removing track from playlist: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to remove track from playlist: %v", err), http.StatusInternalServerError)
+		writeError(w, CodeInternal, fmt.Sprintf("Failed to remove track from playlist: %v", err))
 		return
 	}
 
@@ -305,7 +350,7 @@ func (h *APIHandler) RemoveFromPlaylistHandler(ctx context.Context, userID int64
 func (h *APIHandler) ClearPlaylistHandler(ctx context.Context, userID int64, w http.ResponseWriter, r *http.Request) {
 	if err := cache.ClearPlaylist(ctx, userID); err != nil {
 		log.Printf("Error clearing playlist: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to clear playlist: %v", err), http.StatusInternalServerError)
+		writeError(w, CodeInternal, fmt.Sprintf("Failed to clear playlist: %v", err))
 		return
 	}
 
@@ -322,18 +367,18 @@ func (h *APIHandler) UpdatePlaylistOrderHandler(ctx context.Context, userID int6
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	if len(requestData.TrackIDs) == 0 {
-		http.Error(w, "Track IDs list cannot be empty", http.StatusBadRequest)
+		writeError(w, CodeMissingField, "Track IDs list cannot be empty")
 		return
 	}
 
 	if err := cache.UpdatePlaylistOrder(ctx, userID, requestData.TrackIDs); err != nil {
 		log.Printf("Error updating playlist order: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to update playlist order: %v", err), http.StatusInternalServerError)
+		writeError(w, CodeInternal, fmt.Sprintf("Failed to update playlist order: %v", err))
 		return
 	}
 
@@ -347,7 +392,7 @@ func (h *APIHandler) UpdatePlaylistOrderHandler(ctx context.Context, userID int6
 func (h *APIHandler) ShufflePlaylistHandler(ctx context.Context, userID int64, w http.ResponseWriter, r *http.Request) {
 	if err := cache.ShufflePlaylist(ctx, userID); err != nil {
 		log.Printf("Error shuffling playlist: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to shuffle playlist: %v", err), http.StatusInternalServerError)
+		writeError(w, CodeInternal, fmt.Sprintf("Failed to shuffle playlist: %v", err))
 		return
 	}
 
@@ -359,26 +404,15 @@ func (h *APIHandler) ShufflePlaylistHandler(ctx context.Context, userID int64, w
 
 // AddAllTracksToPlaylistHandler 将用户的所有歌曲添加到播放列表
 func (h *APIHandler) AddAllTracksToPlaylistHandler(w http.ResponseWriter, r *http.Request) {
-	// 设置CORS头，允许跨域请求
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-	// 处理预检请求
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Only POST method is allowed")
 		return
 	}
 
 	// 获取当前用户ID（从认证中间件中获取）
 	userID, err := GetUserIDFromContext(r.Context())
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -387,15 +421,15 @@ func (h *APIHandler) AddAllTracksToPlaylistHandler(w http.ResponseWriter, r *htt
 	// 首先清空现有播放列表
 	if err := cache.ClearPlaylist(ctx, userID); err != nil {
 		log.Printf("Error clearing playlist before adding all tracks: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to clear existing playlist: %v", err), http.StatusInternalServerError)
+		writeError(w, CodeInternal, fmt.Sprintf("Failed to clear existing playlist: %v", err))
 		return
 	}
 
 	// 获取用户的所有歌曲
-	tracks, err := h.trackRepo.GetAllTracksByUserID(userID)
+	tracks, err := h.trackRepo.GetAllTracksByUserID(ctx, userID)
 	if err != nil {
 		log.Printf("Error getting user tracks: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to get user tracks: %v", err), http.StatusInternalServerError)
+		writeError(w, CodeInternal, fmt.Sprintf("Failed to get user tracks: %v", err))
 		return
 	}
 
@@ -0,0 +1,665 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/core/streak"
+	"Bt1QFM/i18n"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// maxCrossfadeSeconds 淡入淡出时长上限（秒）
+const maxCrossfadeSeconds = 10
+
+// maxPreferencesUpdateAttempts 偏好被并发修改时重新读取并重试的最大次数
+const maxPreferencesUpdateAttempts = 5
+
+// errPreferencesConflict 多次重试后偏好仍被其他请求并发修改
+var errPreferencesConflict = errors.New("preferences were modified concurrently")
+
+// transcodeOptionsFromPreferences 将用户转码偏好转换为转码参数
+func transcodeOptionsFromPreferences(prefs model.TranscodePreferences) *audio.TranscodeOptions {
+	return &audio.TranscodeOptions{
+		TrimSilence:      prefs.TrimSilence,
+		CrossfadeSeconds: prefs.CrossfadeSeconds,
+		Profile:          prefs.Profile,
+	}
+}
+
+// loadTranscodeOptions 读取用户的转码参数，读取失败时使用默认转码
+// 请求带有 profile 查询参数时，本次上传使用该输出格式而不是偏好中的格式
+func (h *APIHandler) loadTranscodeOptions(r *http.Request, userID int64) *audio.TranscodeOptions {
+	opts := h.userTranscodeOptions(r.Context(), userID)
+	if profile := r.URL.Query().Get("profile"); profile != "" {
+		if !audio.IsValidProfile(profile) {
+			logger.Warn("忽略不支持的输出格式参数",
+				logger.Int64("userId", userID),
+				logger.String("profile", profile))
+			return opts
+		}
+		opts = opts.WithProfile(profile)
+	}
+	return opts
+}
+
+// userTranscodeOptions 读取用户转码偏好中的转码参数并附加配置的码率和分片时长，读取失败时使用默认转码
+func (h *APIHandler) userTranscodeOptions(ctx context.Context, userID int64) *audio.TranscodeOptions {
+	user, err := h.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		logger.Warn("读取用户转码偏好失败，使用默认转码",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		return h.withEncoding(nil)
+	}
+	if user == nil {
+		return h.withEncoding(nil)
+	}
+	return h.withEncoding(transcodeOptionsFromPreferences(user.GetPreferences().Transcode))
+}
+
+// withEncoding 为转码参数附加配置的码率和分片时长
+func (h *APIHandler) withEncoding(opts *audio.TranscodeOptions) *audio.TranscodeOptions {
+	return opts.WithEncoding(h.cfg.AudioBitrate, h.cfg.TrackSegmentTime)
+}
+
+// GetTranscodePreferencesHandler 获取当前用户的转码偏好
+func (h *APIHandler) GetTranscodePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.userRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		logger.Error("获取用户信息失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get preferences")
+		return
+	}
+	if user == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    user.GetPreferences().Transcode,
+	})
+}
+
+// UpdateTranscodePreferencesHandler 更新当前用户的转码偏好，偏好变化时后台重新生成该用户所有歌曲的 HLS 流
+func (h *APIHandler) UpdateTranscodePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req model.TranscodePreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if req.CrossfadeSeconds < 0 || req.CrossfadeSeconds > maxCrossfadeSeconds {
+		writeError(w, CodeBadRequest, "crossfadeSeconds must be between 0 and 10")
+		return
+	}
+	if !audio.IsValidProfile(req.Profile) {
+		writeError(w, CodeBadRequest, "profile must be one of aac, flac, opus")
+		return
+	}
+
+	prev, err := h.updatePreferences(r.Context(), userID, func(prefs *model.UserPreferences) {
+		prefs.Transcode = req
+	})
+	if err != nil {
+		writeError(w, CodeInternal, "Failed to update preferences")
+		return
+	}
+	if prev == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+	changed := prev.Transcode != req
+
+	logger.Info("用户转码偏好已更新",
+		logger.Int64("userId", userID),
+		logger.Bool("trimSilence", req.TrimSilence),
+		logger.Float64("crossfadeSeconds", req.CrossfadeSeconds),
+		logger.String("profile", req.Profile),
+		logger.Bool("changed", changed))
+
+	if changed {
+		h.queueRegenerateStreams(userID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"data":         req,
+		"regenerating": changed,
+	})
+}
+
+// regenerateQueue 等待按新转码偏好重新生成流的用户，由一个后台 goroutine 依次处理
+// 同一用户排队期间多次修改偏好只处理一次，处理时读取最新的偏好；FFmpeg 进程在共享转码池中排队
+type regenerateQueue struct {
+	mu      sync.Mutex
+	pending map[int64]bool
+	order   []int64
+	running bool
+}
+
+// queueRegenerateStreams 将用户加入重新生成流的队列，没有正在处理队列的 goroutine 时启动一个
+func (h *APIHandler) queueRegenerateStreams(userID int64) {
+	q := &h.regenerate
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		q.pending = make(map[int64]bool)
+	}
+	if !q.pending[userID] {
+		q.pending[userID] = true
+		q.order = append(q.order, userID)
+	}
+	if !q.running {
+		q.running = true
+		go h.drainRegenerateQueue()
+	}
+}
+
+// drainRegenerateQueue 依次重新生成队列中用户的流，队列为空时退出
+func (h *APIHandler) drainRegenerateQueue() {
+	q := &h.regenerate
+	for {
+		q.mu.Lock()
+		if len(q.order) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		userID := q.order[0]
+		q.order = q.order[1:]
+		delete(q.pending, userID)
+		q.mu.Unlock()
+
+		// 在后台运行，不能使用已结束请求的 context
+		ctx := context.Background()
+		h.regenerateUserStreams(ctx, userID, h.userTranscodeOptions(ctx, userID))
+	}
+}
+
+// regenerateUserStreams 使用新的转码参数重新生成用户所有歌曲的 HLS 流
+func (h *APIHandler) regenerateUserStreams(ctx context.Context, userID int64, opts *audio.TranscodeOptions) {
+	tracks, err := h.trackRepo.GetAllTracksByUserID(ctx, userID)
+	if err != nil {
+		logger.Error("获取用户歌曲失败，无法重新生成流",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		return
+	}
+
+	logger.Info("开始重新生成用户歌曲流",
+		logger.Int64("userId", userID),
+		logger.Int("trackCount", len(tracks)))
+
+	for _, track := range tracks {
+		if track.FilePath == "" {
+			logger.Warn("歌曲缺少源文件路径，跳过重新生成", logger.Int64("trackId", track.ID))
+			continue
+		}
+		if err := h.regenerateTrackStream(ctx, track, opts); err != nil {
+			logger.Error("重新生成歌曲流失败",
+				logger.Int64("trackId", track.ID),
+				logger.ErrorField(err))
+		}
+	}
+}
+
+// regenerateTrackStream 使用新的转码参数重新生成歌曲的流
+// 按内容哈希存储的歌曲切换到对应参数的共享流，已存在时直接复用；旧数据原地重新生成
+func (h *APIHandler) regenerateTrackStream(ctx context.Context, track *model.Track, opts *audio.TranscodeOptions) error {
+	if track.ContentHash == "" {
+		return h.transcodeTrackSource(track, strconv.FormatInt(track.ID, 10), opts)
+	}
+
+	streamID := contentStreamID(track.ContentHash, opts)
+	if streamID == track.StreamID() {
+		return nil
+	}
+
+	shared, err := h.findSharedStorage(ctx, track.ContentHash, streamID)
+	if err != nil {
+		return err
+	}
+	duration := track.Duration
+	if shared.Stream != nil {
+		duration = shared.Stream.Duration
+	} else if err := h.transcodeTrackSource(track, streamID, opts); err != nil {
+		return err
+	}
+
+	if err := h.trackRepo.UpdateTrackHLSPath(ctx, track.ID, streamPlaylistPath(streamID), duration); err != nil {
+		return err
+	}
+	h.releaseTrackStorage(ctx, track, false)
+	return nil
+}
+
+// transcodeTrackSource 下载歌曲源文件，清理目标流的旧缓存后同步转码，避免同时启动大量 FFmpeg 进程
+func (h *APIHandler) transcodeTrackSource(track *model.Track, streamID string, opts *audio.TranscodeOptions) error {
+	tempFile, err := os.CreateTemp("", "regenerate-*"+filepath.Ext(track.FilePath))
+	if err != nil {
+		return err
+	}
+	tempFilePath := tempFile.Name()
+	tempFile.Close()
+	defer os.Remove(tempFilePath)
+
+	objectPath := strings.TrimPrefix(track.FilePath, "/static/")
+	if err := h.downloadFileFromStorage(objectPath, tempFilePath); err != nil {
+		return err
+	}
+
+	os.RemoveAll(filepath.Join(h.cfg.StaticDir, "temp", "streams", streamID))
+	h.streamProcessor.InvalidateStreamCache(streamID)
+
+	return h.streamProcessor.StreamProcessSyncWithOptions(context.Background(), streamID, tempFilePath, false, opts)
+}
+
+// GetDigestPreferencesHandler 获取当前用户的每日摘要邮件偏好
+func (h *APIHandler) GetDigestPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.userRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		logger.Error("获取用户信息失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get preferences")
+		return
+	}
+	if user == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    user.GetPreferences().Digest,
+	})
+}
+
+// UpdateDigestPreferencesHandler 订阅/退订每日摘要邮件并设置语言
+func (h *APIHandler) UpdateDigestPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req model.DigestPreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	prev, err := h.updatePreferences(r.Context(), userID, func(prefs *model.UserPreferences) {
+		prefs.Digest = req
+	})
+	if err != nil {
+		writeError(w, CodeInternal, "Failed to update preferences")
+		return
+	}
+	if prev == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	logger.Info("用户摘要偏好已更新",
+		logger.Int64("userId", userID),
+		logger.Bool("enabled", req.Enabled),
+		logger.String("locale", req.Locale))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    req,
+	})
+}
+
+// GetScrobblePreferencesHandler 获取当前用户的听歌记录同步开关
+func (h *APIHandler) GetScrobblePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.userRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		logger.Error("获取用户信息失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get preferences")
+		return
+	}
+	if user == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    user.GetPreferences().Scrobble,
+	})
+}
+
+// UpdateScrobblePreferencesHandler 开启/关闭向已绑定的 Last.fm / ListenBrainz 账号同步听歌记录
+func (h *APIHandler) UpdateScrobblePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req model.ScrobblePreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	prev, err := h.updatePreferences(r.Context(), userID, func(prefs *model.UserPreferences) {
+		prefs.Scrobble = req
+	})
+	if err != nil {
+		writeError(w, CodeInternal, "Failed to update preferences")
+		return
+	}
+	if prev == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	logger.Info("用户听歌记录同步偏好已更新",
+		logger.Int64("userId", userID),
+		logger.Bool("enabled", req.Enabled))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    req,
+	})
+}
+
+// GetListeningPreferencesHandler 获取当前用户的每日收听目标，dailyGoalMinutes 为 0 表示使用服务端默认值
+func (h *APIHandler) GetListeningPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.userRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		logger.Error("获取用户信息失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get preferences")
+		return
+	}
+	if user == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    user.GetPreferences().Listening,
+	})
+}
+
+// UpdateListeningPreferencesHandler 设置每日收听目标和是否接收连续达标的里程碑通知
+func (h *APIHandler) UpdateListeningPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req model.ListeningPreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if req.DailyGoalMinutes < 0 || req.DailyGoalMinutes > streak.MaxGoalMinutes {
+		writeError(w, CodeBadRequest, "dailyGoalMinutes must be between 0 and 720")
+		return
+	}
+
+	prev, err := h.updatePreferences(r.Context(), userID, func(prefs *model.UserPreferences) {
+		prefs.Listening = req
+	})
+	if err != nil {
+		writeError(w, CodeInternal, "Failed to update preferences")
+		return
+	}
+	if prev == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	logger.Info("用户每日收听目标已更新",
+		logger.Int64("userId", userID),
+		logger.Int("dailyGoalMinutes", req.DailyGoalMinutes),
+		logger.Bool("muteMilestones", req.MuteMilestones))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    req,
+	})
+}
+
+// GetLanguagePreferenceHandler 获取当前用户的语言偏好，language 为空表示按 Accept-Language
+func (h *APIHandler) GetLanguagePreferenceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.userRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		logger.Error("获取用户信息失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get preferences")
+		return
+	}
+	if user == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"language":  user.GetPreferences().Language,
+			"supported": i18n.Supported,
+		},
+	})
+}
+
+// UpdateLanguagePreferenceHandler 设置当前用户的语言偏好，请求体 {"language": "en"}，传空串恢复按 Accept-Language
+func (h *APIHandler) UpdateLanguagePreferenceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Language string `json:"language"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	lang := i18n.Normalize(req.Language)
+	if req.Language != "" && lang == "" {
+		writeError(w, CodeBadRequest, "language must be one of zh-CN, en")
+		return
+	}
+
+	prev, err := h.updatePreferences(r.Context(), userID, func(prefs *model.UserPreferences) {
+		prefs.Language = lang
+	})
+	if err != nil {
+		writeError(w, CodeInternal, "Failed to update preferences")
+		return
+	}
+	if prev == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+	if err := cache.SetUserLanguage(r.Context(), userID, lang); err != nil {
+		logger.Warn("缓存用户语言偏好失败", logger.Int64("userId", userID), logger.ErrorField(err))
+	}
+
+	logger.Info("用户语言偏好已更新",
+		logger.Int64("userId", userID),
+		logger.String("language", lang))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    map[string]string{"language": lang},
+	})
+}
+
+// digestUnsubscribePage 退订确认页，表单以相同的签名参数 POST 到当前地址
+var digestUnsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Unsubscribe / 退订每日摘要</title>
+</head>
+<body>
+  <p>Stop receiving the daily digest email? / 不再接收每日摘要邮件？</p>
+  <form method="post" action="?{{.}}">
+    <button type="submit">Unsubscribe / 退订</button>
+  </form>
+</body>
+</html>
+`))
+
+// DigestUnsubscribePageHandler 打开邮件中的退订链接时返回确认页，不修改订阅状态
+// 邮件扫描器和链接预取会请求 GET，真正的退订由确认页或邮件客户端的 POST 完成
+func (h *APIHandler) DigestUnsubscribePageHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := verifyUnsubscribeLink(w, r); !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := digestUnsubscribePage.Execute(w, template.URL(r.URL.Query().Encode())); err != nil {
+		logger.Ctx(r.Context()).Warn("渲染退订确认页失败", logger.ErrorField(err))
+	}
+}
+
+// DigestUnsubscribeHandler 通过邮件中的签名链接退订每日摘要，无需登录
+// 同时用于确认页的表单提交和 RFC 8058 一键退订（邮件客户端按 List-Unsubscribe-Post 发送的 POST）
+func (h *APIHandler) DigestUnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := verifyUnsubscribeLink(w, r)
+	if !ok {
+		return
+	}
+
+	prev, err := h.updatePreferences(r.Context(), userID, func(prefs *model.UserPreferences) {
+		prefs.Digest.Enabled = false
+	})
+	if err != nil {
+		writeError(w, CodeInternal, "Failed to unsubscribe")
+		return
+	}
+	if prev == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+	if prev.Digest.Enabled {
+		logger.Info("用户已通过邮件链接退订每日摘要", logger.Int64("userId", userID))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("You have been unsubscribed from the daily digest. / 已退订每日摘要邮件。"))
+}
+
+// verifyUnsubscribeLink 校验退订链接中的用户ID和签名，失败时已写入响应
+func verifyUnsubscribeLink(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userID, err := strconv.ParseInt(r.URL.Query().Get("uid"), 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid user ID")
+		return 0, false
+	}
+	if !auth.VerifyUnsubscribeToken(userID, r.URL.Query().Get("token")) {
+		writeError(w, CodeInvalidSignature, "Invalid unsubscribe token")
+		return 0, false
+	}
+	return userID, true
+}
+
+// updatePreferences 读取用户偏好，经 mutate 修改后写回；写入时校验偏好仍是读取时的值（乐观并发），
+// 期间被其他请求修改时重新读取并重试，避免并发更新互相覆盖。返回修改前的偏好，用户不存在时返回 nil
+func (h *APIHandler) updatePreferences(ctx context.Context, userID int64, mutate func(*model.UserPreferences)) (*model.UserPreferences, error) {
+	for attempt := 1; attempt <= maxPreferencesUpdateAttempts; attempt++ {
+		user, err := h.userRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			logger.Error("获取用户信息失败", logger.Int64("userId", userID), logger.ErrorField(err))
+			return nil, err
+		}
+		if user == nil {
+			return nil, nil
+		}
+
+		prev := user.GetPreferences()
+		prefs := prev
+		mutate(&prefs)
+		data, err := json.Marshal(prefs)
+		if err != nil {
+			return nil, err
+		}
+		if user.Preferences.Valid && user.Preferences.String == string(data) {
+			return &prev, nil
+		}
+
+		saved, err := h.userRepo.UpdatePreferences(ctx, userID, user.Preferences, string(data))
+		if err != nil {
+			logger.Error("更新用户偏好失败",
+				logger.Int64("userId", userID),
+				logger.ErrorField(err))
+			return nil, err
+		}
+		if saved {
+			return &prev, nil
+		}
+		logger.Debug("用户偏好已被其他请求修改，重新读取后重试",
+			logger.Int64("userId", userID),
+			logger.Int("attempt", attempt))
+	}
+	logger.Error("更新用户偏好失败：并发修改冲突", logger.Int64("userId", userID))
+	return nil, errPreferencesConflict
+}
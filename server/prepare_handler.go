@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/netease"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// prepareStateReady 歌曲已转码完成，可以直接播放
+const prepareStateReady = "ready"
+
+// prepareResponse 预处理接口的响应
+type prepareResponse struct {
+	SongID      string `json:"songId"`
+	PlaylistURL string `json:"playlistUrl"`
+	audio.PrepareStatus
+}
+
+// PrepareHandler 用户即将播放一首网易云歌曲时调用，POST /api/netease/{id}/prepare
+// 尚未转码的歌曲以最高优先级排队（已在队列中的推测性任务会被提前），返回当前的就绪状态
+func (h *StreamHandler) PrepareHandler(w http.ResponseWriter, r *http.Request) {
+	songID := mux.Vars(r)["id"]
+	resp := prepareResponse{
+		SongID:      songID,
+		PlaylistURL: "/streams/netease/" + songID + "/playlist.m3u8",
+	}
+
+	if h.isStreamReady(songID) {
+		resp.State = prepareStateReady
+	} else {
+		resp.PrepareStatus = h.mp3Processor.EnqueuePrepare(songID, audio.PriorityRequested)
+		logger.Ctx(r.Context()).Info("歌曲预处理已提升优先级",
+			logger.String("songId", songID),
+			logger.String("state", resp.State),
+			logger.Int("position", resp.Position))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// PrefetchNeteaseSong 客户端查询下一首时调用：已转码的歌曲返回 ready，否则以明确请求的优先级排队预处理
+func (h *StreamHandler) PrefetchNeteaseSong(songID string) audio.PrepareStatus {
+	if h.isStreamReady(songID) {
+		return audio.PrepareStatus{State: prepareStateReady}
+	}
+	return h.mp3Processor.EnqueuePrepare(songID, audio.PriorityRequested)
+}
+
+// isStreamReady 网易云歌曲的播放列表是否已存在且不在处理中
+func (h *StreamHandler) isStreamReady(songID string) bool {
+	if h.mp3Processor.IsProcessing(songID) {
+		return false
+	}
+	_, _, err := h.streamProcessor.StreamGet(songID, "playlist.m3u8", true)
+	return err == nil
+}
+
+// prepareNeteaseSong 预处理队列使用的处理函数，已转码的歌曲直接跳过
+func (h *StreamHandler) prepareNeteaseSong(ctx context.Context, songID string) error {
+	if _, _, err := h.streamProcessor.StreamGet(songID, "playlist.m3u8", true); err == nil {
+		return nil
+	}
+	return h.reprocessNeteaseSong(ctx, songID, netease.NewClient())
+}
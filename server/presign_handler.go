@@ -0,0 +1,522 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/core/cover"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/storage"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// presignUploadExpiry 预签名上传地址有效期
+	presignUploadExpiry = 15 * time.Minute
+	// presignDownloadExpiry 预签名下载地址有效期
+	presignDownloadExpiry = time.Hour
+	// pendingUploadPrefix 客户端直传的待确认对象前缀，确认后移动到 audio/ 下
+	pendingUploadPrefix = "uploads/pending/"
+)
+
+// presignUploadRequest 申请预签名上传地址的请求
+type presignUploadRequest struct {
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+}
+
+// finalizeUploadRequest 直传完成后创建曲目的请求
+type finalizeUploadRequest struct {
+	ObjectKey    string `json:"objectKey"`
+	Title        string `json:"title"`
+	Artist       string `json:"artist"`
+	Album        string `json:"album"`
+	License      string `json:"license"`
+	CoverArtPath string `json:"coverArtPath"`
+}
+
+// pendingUploadDir 用户待确认对象所在的前缀
+func pendingUploadDir(userID int64) string {
+	return fmt.Sprintf("%s%d/", pendingUploadPrefix, userID)
+}
+
+// userCoverDir 用户通过 /api/upload/cover 上传的封面所在的前缀，直传确认时只接受调用方自己上传的封面
+func userCoverDir(userID int64) string {
+	return fmt.Sprintf("covers/users/%d/", userID)
+}
+
+// PresignUploadHandler 签发限时的 PUT 地址，客户端直接上传音频到对象存储，不经过 API 服务
+func (h *APIHandler) PresignUploadHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req presignUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	uploadConfig := DefaultUploadConfig()
+	if req.Size <= 0 || req.Size > uploadConfig.MaxFileSize {
+		writeError(w, CodeFileTooLarge, fmt.Sprintf("File too large. Maximum size is %d MB", uploadConfig.MaxFileSize>>20))
+		return
+	}
+	validType := false
+	for _, t := range uploadConfig.AllowedTypes {
+		if req.ContentType == t {
+			validType = true
+			break
+		}
+	}
+	if !validType {
+		writeError(w, CodeUnsupportedFileType, "Invalid file type. Supported formats: MP3, WAV, FLAC, AAC, M4A.")
+		return
+	}
+	if !h.checkStorageQuota(w, r, userID, req.Size) {
+		return
+	}
+
+	store := storage.GetStorage()
+	if store == nil {
+		writeError(w, CodeStorageUnavailable, "Storage not available")
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(req.FileName))
+	if ext == "" {
+		ext = ".dat"
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		writeError(w, CodeInternal, "Failed to generate upload key")
+		return
+	}
+	objectKey := pendingUploadDir(userID) + hex.EncodeToString(token) + ext
+
+	uploadURL, err := store.Presign(r.Context(), http.MethodPut, objectKey, presignUploadExpiry)
+	if err != nil {
+		logger.Error("生成预签名上传地址失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to presign upload")
+		return
+	}
+
+	logger.Info("签发预签名上传地址",
+		logger.Int64("userId", userID),
+		logger.String("objectKey", objectKey),
+		logger.Int64("size", req.Size))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"uploadUrl": uploadURL,
+		"method":    http.MethodPut,
+		"objectKey": objectKey,
+		"headers":   map[string]string{"Content-Type": req.ContentType},
+		"expiresAt": time.Now().Add(presignUploadExpiry).Unix(),
+	})
+}
+
+// FinalizeUploadHandler 客户端直传完成后的回调，校验对象并创建曲目，然后与普通上传一样后台转码
+func (h *APIHandler) FinalizeUploadHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req finalizeUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	req.License = strings.TrimSpace(req.License)
+	if req.Title == "" {
+		writeError(w, CodeMissingField, "Missing 'title'")
+		return
+	}
+	if len(req.License) > model.MaxLicenseLength {
+		writeError(w, CodeBadRequest, "License text too long")
+		return
+	}
+	// 只能确认自己申请的对象
+	if !strings.HasPrefix(req.ObjectKey, pendingUploadDir(userID)) || path.Clean(req.ObjectKey) != req.ObjectKey {
+		writeError(w, CodeForbidden, "Invalid object key")
+		return
+	}
+	if req.CoverArtPath != "" && (!strings.HasPrefix(req.CoverArtPath, "/static/"+userCoverDir(userID)) || path.Clean(req.CoverArtPath) != req.CoverArtPath) {
+		writeError(w, CodeBadRequest, "Invalid cover path")
+		return
+	}
+
+	store := storage.GetStorage()
+	if store == nil {
+		writeError(w, CodeStorageUnavailable, "Storage not available")
+		return
+	}
+
+	info, err := store.Stat(r.Context(), req.ObjectKey)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			writeError(w, CodeNotFound, "Uploaded object not found")
+			return
+		}
+		logger.Error("获取直传对象信息失败",
+			logger.String("objectKey", req.ObjectKey),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to read uploaded object")
+		return
+	}
+	if maxSize := DefaultUploadConfig().MaxFileSize; info.Size > maxSize {
+		go h.removeStorageObjects(req.ObjectKey)
+		writeError(w, CodeFileTooLarge, fmt.Sprintf("File too large. Maximum size is %d MB", maxSize>>20))
+		return
+	}
+	// 申请地址时按声明的大小检查过，这里按实际大小再检查一次
+	if !h.checkStorageQuota(w, r, userID, info.Size) {
+		go h.removeStorageObjects(req.ObjectKey)
+		return
+	}
+
+	// 源文件需要计算哈希、指纹并转码，大小已校验，直接读入内存
+	object, err := store.Get(r.Context(), req.ObjectKey)
+	if err != nil {
+		logger.Error("读取直传对象失败",
+			logger.String("objectKey", req.ObjectKey),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to read uploaded object")
+		return
+	}
+	data, err := io.ReadAll(object)
+	object.Close()
+	if err != nil {
+		logger.Error("读取直传对象失败",
+			logger.String("objectKey", req.ObjectKey),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to read uploaded object")
+		return
+	}
+
+	// 直传的内容没有经过服务端，申请地址时只检查了声明的类型，这里按实际内容校验
+	if !h.validateUploadedAudio(w, r, bytes.NewReader(data), req.ObjectKey, info.ContentType) {
+		go h.removeStorageObjects(req.ObjectKey)
+		return
+	}
+
+	ext := filepath.Ext(req.ObjectKey)
+	contentHash, err := hashContent(bytes.NewReader(data))
+	if err != nil {
+		logger.Error("计算文件哈希失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to process uploaded file.")
+		return
+	}
+	transcodeOpts := h.loadTranscodeOptions(r, userID)
+	streamID := contentStreamID(contentHash, transcodeOpts)
+	shared, err := h.findSharedStorage(r.Context(), contentHash, streamID)
+	if err != nil {
+		logger.Warn("查询共享存储失败，按新文件处理", logger.ErrorField(err))
+		shared = &sharedStorage{}
+	}
+
+	minioTrackPath := "audio/" + contentHash + ext
+	trackFilePath := "/static/" + minioTrackPath
+	if shared.FilePath != "" {
+		trackFilePath = shared.FilePath
+		minioTrackPath = strings.TrimPrefix(shared.FilePath, "/static/")
+	}
+
+	// 计算音频指纹并检测重复上传（fpcalc 不可用时跳过）
+	fingerprint, err := h.computeFingerprintFromReader(bytes.NewReader(data), ext)
+	if err != nil {
+		logger.Warn("计算音频指纹失败，跳过重复检测", logger.ErrorField(err))
+	}
+	var duplicateOf []int64
+	if fingerprint != nil {
+		duplicateOf, err = h.findDuplicateTracks(userID, fingerprint)
+		if err != nil {
+			logger.Warn("重复检测失败", logger.ErrorField(err))
+		}
+		if len(duplicateOf) > 0 && h.cfg.BlockDuplicateUploads {
+			go h.removeStorageObjects(req.ObjectKey)
+			writeErrorDetails(w, CodeDuplicateTrack, "Duplicate track: this audio already exists in your library", map[string]interface{}{
+				"duplicateOf": duplicateOf,
+			})
+			return
+		}
+	}
+
+	// 先把源音频转存到内容哈希路径再创建曲目记录，转存失败时不会留下找不到源文件的曲目；
+	// 曲目记录未能提交时补偿删除转存的源音频
+	var uploadedSource string
+	if shared.FilePath == "" {
+		if err := h.uploadBytesToStorage(data, minioTrackPath, info.ContentType); err != nil {
+			logger.Error("转存源音频失败",
+				logger.String("path", minioTrackPath),
+				logger.ErrorField(err))
+			writeError(w, CodeStorageUnavailable, "Failed to upload audio to storage")
+			return
+		}
+		uploadedSource = trackFilePath
+	}
+	committed := false
+	defer func() {
+		if !committed && uploadedSource != "" {
+			h.rollbackUpload(contentHash, uploadedSource, "")
+		}
+	}()
+
+	tx, err := h.trackRepo.BeginTx(r.Context())
+	if err != nil {
+		logger.Error("开始数据库事务失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to create track")
+		return
+	}
+	defer h.trackRepo.RollbackTx(tx)
+
+	newTrack := &model.Track{
+		UserID:       userID,
+		Title:        req.Title,
+		Artist:       req.Artist,
+		Album:        req.Album,
+		FilePath:     trackFilePath,
+		CoverArtPath: req.CoverArtPath,
+		Status:       "processing",
+		Source:       "library",
+		Provenance:   model.ProvenanceUpload,
+		License:      req.License,
+		ContentHash:  contentHash,
+		FileSize:     info.Size,
+	}
+	if shared.Stream != nil {
+		newTrack.HLSPlaylistPath = shared.Stream.HLSPlaylistPath
+		newTrack.Duration = shared.Stream.Duration
+	}
+
+	trackID, err := h.trackRepo.CreateTrackWithTx(r.Context(), tx, newTrack)
+	if err != nil {
+		logger.Error("创建曲目记录失败",
+			logger.ErrorField(err),
+			logger.Int64("userId", userID))
+		writeError(w, CodeInternal, "Failed to create track entry in database")
+		return
+	}
+	if err := h.trackRepo.CommitTx(tx); err != nil {
+		logger.Error("提交事务失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to create track entry in database")
+		return
+	}
+	committed = true
+	newTrack.ID = trackID
+
+	logger.Info("直传曲目创建成功",
+		logger.Int64("trackId", trackID),
+		logger.String("objectKey", req.ObjectKey),
+		logger.String("contentHash", contentHash),
+		logger.Bool("sharedStream", shared.Stream != nil))
+
+	if fingerprint != nil {
+		h.saveTrackFingerprint(trackID, userID, fingerprint)
+	}
+	if req.CoverArtPath == "" {
+		h.coverFetcher.Enqueue(cover.Job{
+			Kind:  cover.KindTrack,
+			ID:    trackID,
+			Query: cover.Query{Artist: req.Artist, Album: req.Album, Title: req.Title},
+		})
+	}
+	if shared.Stream != nil {
+		if err := h.trackRepo.UpdateTrackStatus(r.Context(), trackID, "completed"); err != nil {
+			logger.Warn("更新曲目状态失败", logger.Int64("trackId", trackID), logger.ErrorField(err))
+		}
+		newTrack.Status = "completed"
+	}
+
+	resp := map[string]interface{}{
+		"message": "Track upload finalized",
+		"trackId": trackID,
+		"track":   newTrack,
+	}
+	if len(duplicateOf) > 0 {
+		resp["duplicateOf"] = duplicateOf
+		resp["warning"] = "This audio appears to duplicate existing tracks in your library"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+
+	// 源音频已转存到内容哈希路径，待确认对象不再需要
+	go h.removeStorageObjects(req.ObjectKey)
+	if shared.Stream != nil {
+		return
+	}
+	go func() {
+		if err := h.processAudioFileAsync(bytes.NewBuffer(data), minioTrackPath, trackID, streamID, transcodeOpts); err != nil {
+			logger.Error("异步处理音频文件失败",
+				logger.ErrorField(err),
+				logger.Int64("trackId", trackID))
+			h.trackRepo.UpdateTrackStatus(context.Background(), trackID, "failed")
+			return
+		}
+		// 交给转码 worker 时由 worker 回写状态
+		if h.dispatchesToWorker() {
+			return
+		}
+		h.trackRepo.UpdateTrackStatus(context.Background(), trackID, "completed")
+	}()
+}
+
+// PresignTrackDownloadHandler 签发曲目源音频的限时下载地址，仅限曲目所有者
+func (h *APIHandler) PresignTrackDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	track, ok := h.loadPresignTrack(w, r)
+	if !ok {
+		return
+	}
+	if track.UserID != userID {
+		writeError(w, CodeForbidden, "Forbidden")
+		return
+	}
+	if !strings.HasPrefix(track.FilePath, "/static/") {
+		writeError(w, CodeTrackNotFound, "Track has no stored source file")
+		return
+	}
+
+	downloadURL, err := storage.GetStorage().Presign(r.Context(), http.MethodGet, strings.TrimPrefix(track.FilePath, "/static/"), presignDownloadExpiry)
+	if err != nil {
+		logger.Error("生成预签名下载地址失败",
+			logger.Int64("trackId", track.ID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to presign download")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"url":       downloadURL,
+		"expiresAt": time.Now().Add(presignDownloadExpiry).Unix(),
+	})
+}
+
+// PresignedPlaylistHandler 返回分片地址替换为预签名地址的 HLS 播放列表，播放器直接从对象存储拉取分片
+// 与 /streams/ 相同，只返回给可以播放该曲目的用户，其他用户按曲目不存在处理
+func (h *APIHandler) PresignedPlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	track, ok := h.loadPresignTrack(w, r)
+	if !ok {
+		return
+	}
+	allowed, _, err := h.canAccessStream(r.Context(), r, userID, track.StreamID())
+	if err != nil {
+		logger.Ctx(r.Context()).Error("检查流访问权限失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to check stream access")
+		return
+	}
+	if !allowed {
+		logger.Ctx(r.Context()).Warn("拒绝签发预签名播放列表", logger.Int64("userId", userID), logger.Int64("trackId", track.ID))
+		writeError(w, CodeNotFound, "Track not found")
+		return
+	}
+	if track.HLSPlaylistPath == "" {
+		writeError(w, CodeStreamNotReady, "Track stream not ready")
+		return
+	}
+
+	store := storage.GetStorage()
+	streamDir := "streams/" + track.StreamID() + "/"
+	object, err := store.Get(r.Context(), streamDir+"playlist.m3u8")
+	if err != nil {
+		if storage.IsNotFound(err) {
+			writeError(w, CodeStreamNotReady, "Track stream not ready")
+			return
+		}
+		logger.Error("读取播放列表失败",
+			logger.Int64("trackId", track.ID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to read playlist")
+		return
+	}
+	defer object.Close()
+
+	playlist, err := presignPlaylist(r.Context(), store, object, streamDir)
+	if err != nil {
+		logger.Error("签名播放列表失败",
+			logger.Int64("trackId", track.ID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to presign playlist")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(presignDownloadExpiry.Seconds()/2)))
+	w.Write(playlist)
+}
+
+// presignPlaylist 将播放列表中的相对分片路径替换为预签名地址
+func presignPlaylist(ctx context.Context, store storage.Storage, r io.Reader, streamDir string) ([]byte, error) {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.Contains(line, "://") {
+			u, err := store.Presign(ctx, http.MethodGet, streamDir+path.Base(line), presignDownloadExpiry)
+			if err != nil {
+				return nil, err
+			}
+			line = u
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// loadPresignTrack 解析路径中的曲目ID并加载有效曲目，失败时已写入响应
+func (h *APIHandler) loadPresignTrack(w http.ResponseWriter, r *http.Request) (*model.Track, bool) {
+	trackID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid track ID")
+		return nil, false
+	}
+	track, err := h.trackRepo.GetTrackByID(r.Context(), trackID)
+	if err != nil {
+		logger.Error("获取track失败",
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get track")
+		return nil, false
+	}
+	if track == nil || track.State == 0 {
+		writeError(w, CodeTrackNotFound, "Track not found")
+		return nil, false
+	}
+	return track, true
+}
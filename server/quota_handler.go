@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"Bt1QFM/logger"
+)
+
+// StorageQuota 用户上传源文件的存储用量和配额
+type StorageQuota struct {
+	UsedBytes      int64 `json:"usedBytes"`
+	QuotaBytes     int64 `json:"quotaBytes"`     // 不限制时为 0
+	RemainingBytes int64 `json:"remainingBytes"` // 不限制时为 -1
+	Unlimited      bool  `json:"unlimited"`
+}
+
+// userStorageQuota 统计用户已上传的字节数并计算剩余配额
+func (h *APIHandler) userStorageQuota(ctx context.Context, userID int64) (*StorageQuota, error) {
+	used, err := h.trackRepo.GetUserUploadedBytes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	quota := &StorageQuota{UsedBytes: used}
+	if h.cfg.UserStorageQuotaMB <= 0 {
+		quota.Unlimited = true
+		quota.RemainingBytes = -1
+		return quota, nil
+	}
+	quota.QuotaBytes = int64(h.cfg.UserStorageQuotaMB) << 20
+	quota.RemainingBytes = quota.QuotaBytes - used
+	if quota.RemainingBytes < 0 {
+		quota.RemainingBytes = 0
+	}
+	return quota, nil
+}
+
+// checkStorageQuota 检查用户剩余配额能否容纳 size 字节的新上传，超出时写入错误响应并返回 false
+// 用量统计失败时放行，避免数据库抖动导致无法上传
+func (h *APIHandler) checkStorageQuota(w http.ResponseWriter, r *http.Request, userID, size int64) bool {
+	if h.cfg.UserStorageQuotaMB <= 0 {
+		return true
+	}
+	quota, err := h.userStorageQuota(r.Context(), userID)
+	if err != nil {
+		logger.Warn("统计用户存储用量失败，跳过配额检查",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		return true
+	}
+	if size <= quota.RemainingBytes {
+		return true
+	}
+
+	logger.Warn("上传超出用户存储配额",
+		logger.Int64("userId", userID),
+		logger.Int64("size", size),
+		logger.Int64("usedBytes", quota.UsedBytes),
+		logger.Int64("quotaBytes", quota.QuotaBytes))
+	writeErrorDetails(w, CodeStorageQuotaExceeded,
+		fmt.Sprintf("Storage quota exceeded: %.1f MB remaining of %d MB, this upload needs %.1f MB",
+			float64(quota.RemainingBytes)/(1<<20), h.cfg.UserStorageQuotaMB, float64(size)/(1<<20)),
+		map[string]interface{}{
+			"usedBytes":      quota.UsedBytes,
+			"quotaBytes":     quota.QuotaBytes,
+			"remainingBytes": quota.RemainingBytes,
+			"requiredBytes":  size,
+		})
+	return false
+}
+
+// GetStorageQuotaHandler 返回当前用户的存储用量和配额
+func (h *APIHandler) GetStorageQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	quota, err := h.userStorageQuota(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("统计用户存储用量失败",
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get storage quota")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    quota,
+	})
+}
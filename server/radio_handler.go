@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/radio"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// radioStartTimeout 开启电台时同步续播的最长时间
+const radioStartTimeout = 2 * time.Minute
+
+// RadioHandler AI 电台处理器
+type RadioHandler struct {
+	service *radio.Service
+}
+
+// NewRadioHandler 创建 AI 电台处理器
+func NewRadioHandler(service *radio.Service) *RadioHandler {
+	return &RadioHandler{service: service}
+}
+
+// StartRadioHandler 开启 AI 电台，POST /api/radio/start
+// 队列为空或正在播放最后一首时立即续播并返回追加的歌曲，之后由播放心跳在队列快播完时自动续播
+func (h *RadioHandler) StartRadioHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.service.Enable(r.Context(), userID); err != nil {
+		logger.Ctx(r.Context()).Error("开启 AI 电台失败", logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to start radio")
+		return
+	}
+
+	playlist, err := cache.GetPlaylist(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取播放列表失败", logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to get playlist")
+		return
+	}
+	currentIndex := 0
+	if state, err := cache.GetUserPlaybackState(r.Context(), userID); err == nil && state != nil {
+		currentIndex = resolvePlaybackIndex(state, playlist)
+	}
+
+	added := []cache.PlaylistItem{}
+	if radio.NeedsRefill(len(playlist), currentIndex) {
+		ctx, cancel := context.WithTimeout(r.Context(), radioStartTimeout)
+		defer cancel()
+
+		items, err := h.service.Refill(ctx, userID)
+		if err != nil && !errors.Is(err, radio.ErrRefillInProgress) {
+			logger.Ctx(r.Context()).Error("AI 电台续播失败", logger.ErrorField(err))
+			writeError(w, CodeRadioUnavailable, "Radio started but failed to queue songs, will retry while playing")
+			return
+		}
+		if items != nil {
+			added = items
+		}
+	}
+
+	logger.Ctx(r.Context()).Info("AI 电台已开启", logger.Int("added", len(added)))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"enabled": true,
+			"added":   added,
+		},
+	})
+}
+
+// StopRadioHandler 关闭 AI 电台，已追加的歌曲保留在队列中，POST /api/radio/stop
+func (h *RadioHandler) StopRadioHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.service.Disable(r.Context(), userID); err != nil {
+		logger.Ctx(r.Context()).Error("关闭 AI 电台失败", logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to stop radio")
+		return
+	}
+
+	logger.Ctx(r.Context()).Info("AI 电台已关闭")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"enabled": false,
+		},
+	})
+}
+
+// RegisterRadioRoutes 注册 AI 电台相关路由
+func RegisterRadioRoutes(router *mux.Router, handler *RadioHandler, authMiddleware func(http.HandlerFunc) http.HandlerFunc) {
+	router.HandleFunc("/api/radio/start", authMiddleware(handler.StartRadioHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/radio/stop", authMiddleware(handler.StopRadioHandler)).Methods(http.MethodPost)
+
+	logger.Info("AI 电台API端点注册完成",
+		logger.String("endpoints", "POST /api/radio/start, POST /api/radio/stop"))
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/logger"
+)
+
+// RateLimit 按规则限制 next 的调用频率，超限时返回 429 和 Retry-After
+// 已登录（或携带有效 Token）的请求按用户计数，否则按客户端 IP 计数
+func (h *APIHandler) RateLimit(scope string, rule config.RateLimitRule, next http.HandlerFunc) http.HandlerFunc {
+	if !h.cfg.RateLimitEnabled || !rule.Enabled() {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		subject := rateLimitSubject(r, trustedProxies(h.cfg))
+		allowed, retryAfter := checkRateLimit(scope, subject, rule)
+		if !allowed {
+			logger.Ctx(r.Context()).Warn("请求被限流",
+				logger.String("scope", scope),
+				logger.String("subject", subject),
+				logger.String("path", r.URL.Path),
+				logger.Duration("retryAfter", retryAfter))
+			seconds := retryAfterSeconds(retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			writeErrorDetails(w, CodeRateLimited, "Too many requests", map[string]interface{}{
+				"retryAfter": seconds,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// checkRateLimit 消耗 subject 在 scope 下的一个令牌，Redis 不可用时放行
+func checkRateLimit(scope, subject string, rule config.RateLimitRule) (bool, time.Duration) {
+	key := fmt.Sprintf("ratelimit:%s:%s", scope, subject)
+	allowed, retryAfter, err := cache.AllowRate(key, rule.Limit, rule.Period)
+	if err != nil {
+		logger.Warn("限流检查失败，放行请求",
+			logger.String("key", key),
+			logger.ErrorField(err))
+		return true, 0
+	}
+	return allowed, retryAfter
+}
+
+// retryAfterSeconds Retry-After 以秒为单位，至少为 1
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// rateLimitSubject 返回限流计数的主体：user:{id} 或 ip:{addr}
+func rateLimitSubject(r *http.Request, proxies []*net.IPNet) string {
+	if userID, err := GetUserIDFromContext(r.Context()); err == nil {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	// 未经过 AuthMiddleware 的接口（如网易云搜索）同样优先按用户计数
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		if claims, err := auth.ParseToken(strings.TrimPrefix(authHeader, "Bearer ")); err == nil {
+			return fmt.Sprintf("user:%d", claims.UserID)
+		}
+	}
+	return "ip:" + clientIP(r, proxies)
+}
+
+// trustedProxies 返回读取代理请求头时信任的反向代理，未开启 RateLimitTrustProxy 时为空
+func trustedProxies(cfg *config.Config) []*net.IPNet {
+	if !cfg.RateLimitTrustProxy {
+		return nil
+	}
+	return cfg.TrustedProxies
+}
+
+// clientIP 返回客户端 IP。直连地址属于受信任的代理时才读取代理请求头：
+// X-Forwarded-For 从右往左跳过受信任的代理，第一个不受信任的地址即客户端，左侧的项可由客户端伪造
+func clientIP(r *http.Request, proxies []*net.IPNet) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !ipInNets(peer, proxies) {
+		return peer
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				// 无法解析的项之后的地址都不可信，使用最后一个经过受信任代理确认的地址
+				break
+			}
+			if !ipInNets(hop, proxies) {
+				return hop
+			}
+			peer = hop
+		}
+		return peer
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return peer
+}
+
+// ipInNets 判断地址是否属于任一网段
+func ipInNets(addr string, nets []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/storage"
+)
+
+// 直接播放地址的输出格式
+const (
+	rawFormatOriginal = "original"
+	rawFormatMP3      = "mp3"
+)
+
+// rawMP3Bitrate 实时转码 MP3 的码率
+const rawMP3Bitrate = "192k"
+
+// rawTrackPath 歌曲直接播放地址的路径，同时作为分享签名内容
+func rawTrackPath(trackID int64) string {
+	return fmt.Sprintf("/api/tracks/%d/raw", trackID)
+}
+
+// RawTrackHandler 以单个文件返回歌曲音频，供不支持 HLS 的客户端（机器人、简单播放器等）使用
+// GET /api/tracks/{id}/raw?format=original|mp3
+// 登录用户只能访问自己的歌曲；携带 expires/signature 分享签名时无需登录
+// 原始文件支持 Range 请求；需要转码为 MP3 时实时输出，不支持 Range
+func (h *APIHandler) RawTrackHandler(w http.ResponseWriter, r *http.Request) {
+	track, ok := h.loadPresignTrack(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorizeRawTrack(w, r, track) {
+		return
+	}
+	if !strings.HasPrefix(track.FilePath, "/static/") {
+		writeError(w, CodeTrackNotFound, "Track has no stored source file")
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = rawFormatOriginal
+	}
+	if format != rawFormatOriginal && format != rawFormatMP3 {
+		writeError(w, CodeBadRequest, "Invalid format, expected original or mp3")
+		return
+	}
+
+	store := storage.GetStorage()
+	if store == nil {
+		writeError(w, CodeStorageUnavailable, "Storage not available")
+		return
+	}
+	key := strings.TrimPrefix(track.FilePath, "/static/")
+	object, err := store.Get(r.Context(), key)
+	if err != nil {
+		if !storage.IsNotFound(err) {
+			logger.Ctx(r.Context()).Error("读取歌曲源文件失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+		}
+		writeError(w, CodeTrackNotFound, "Track file not found")
+		return
+	}
+	defer object.Close()
+
+	// 音频文件较大，不受服务器 WriteTimeout 限制
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Ctx(r.Context()).Debug("无法取消写入超时", logger.ErrorField(err))
+	}
+
+	ext := strings.ToLower(path.Ext(key))
+	if format == rawFormatMP3 && ext != ".mp3" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": rawTrackFilename(track, ".mp3")}))
+		h.transcodeRawTrack(w, r, track.ID, object)
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": rawTrackFilename(track, ext)}))
+	serveStoredObject(w, r, store, key, object, subsonicContentType(key))
+}
+
+// RawTrackURLHandler 为自己的歌曲签发可分享的直接播放地址，GET /api/tracks/{id}/raw-url
+func (h *APIHandler) RawTrackURLHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	track, ok := h.loadPresignTrack(w, r)
+	if !ok {
+		return
+	}
+	if track.UserID != userID {
+		writeError(w, CodeForbidden, "Forbidden")
+		return
+	}
+
+	ttl := time.Duration(h.cfg.StreamURLTTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = 6 * time.Hour
+	}
+	expires := time.Now().Add(ttl).Unix()
+	rawPath := rawTrackPath(track.ID)
+	url := fmt.Sprintf("%s%s?expires=%d&signature=%s", strings.TrimRight(h.cfg.PublicBaseURL, "/"), rawPath, expires, auth.SignStreamPath(rawPath, expires))
+	if format := strings.ToLower(r.URL.Query().Get("format")); format == rawFormatMP3 {
+		url += "&format=" + rawFormatMP3
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"url":       url,
+		"expiresAt": expires,
+	})
+}
+
+// authorizeRawTrack 校验分享签名或登录身份，失败时已写入错误响应
+func (h *APIHandler) authorizeRawTrack(w http.ResponseWriter, r *http.Request, track *model.Track) bool {
+	query := r.URL.Query()
+	if signature := query.Get("signature"); signature != "" {
+		expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+		if err != nil || !auth.VerifyStreamSignature(rawTrackPath(track.ID), expires, signature) {
+			writeError(w, CodeInvalidSignature, "Invalid or expired signature")
+			return false
+		}
+		return true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		writeError(w, CodeUnauthorized, "Authorization header or signature is required")
+		return false
+	}
+	claims, err := auth.ParseToken(token)
+	if err != nil {
+		writeError(w, CodeInvalidToken, "Invalid token")
+		return false
+	}
+	withRequestUser(r.Context(), claims.UserID, claims.Username)
+	if track.UserID != claims.UserID {
+		writeError(w, CodeForbidden, "Forbidden")
+		return false
+	}
+	return true
+}
+
+// transcodeRawTrack 通过共享转码池把源文件实时转码为 MP3 并写入响应
+func (h *APIHandler) transcodeRawTrack(w http.ResponseWriter, r *http.Request, trackID int64, source io.Reader) {
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		return
+	}
+	cmd, release, err := audio.TranscodeCommand(r.Context(), h.cfg.FFmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-vn",
+		"-c:a", "libmp3lame",
+		"-b:a", rawMP3Bitrate,
+		"-f", "mp3",
+		"pipe:1",
+	)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	cmd.Stdin = source
+	cmd.Stdout = w
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Accept-Ranges", "none")
+	if err := audio.ObserveTranscode(r.Context(), cmd.Run()); err != nil && r.Context().Err() == nil {
+		logger.Ctx(r.Context()).Error("歌曲实时转码失败",
+			logger.Int64("trackId", trackID),
+			logger.String("stderr", stderr.String()),
+			logger.ErrorField(err))
+	}
+}
+
+// serveStoredObject 写出存储中的对象；对象可随机读取时（本地文件、MinIO 对象）支持 Range 和条件请求
+func serveStoredObject(w http.ResponseWriter, r *http.Request, store storage.Storage, key string, object io.ReadCloser, contentType string) {
+	w.Header().Set("Content-Type", contentType)
+	info, statErr := store.Stat(r.Context(), key)
+	if seeker, ok := object.(io.ReadSeeker); ok {
+		var modTime time.Time
+		if statErr == nil {
+			modTime = info.LastModified
+		}
+		http.ServeContent(w, r, "", modTime, seeker)
+		return
+	}
+
+	if statErr == nil && info.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, object); err != nil && r.Context().Err() == nil {
+		logger.Ctx(r.Context()).Warn("发送文件中断", logger.String("key", key), logger.ErrorField(err))
+	}
+}
+
+// rawTrackFilename 下载时使用的文件名
+func rawTrackFilename(track *model.Track, ext string) string {
+	name := track.Title
+	if track.Artist != "" {
+		name = track.Artist + " - " + name
+	}
+	if name == "" {
+		name = strconv.FormatInt(track.ID, 10)
+	}
+	return name + ext
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"Bt1QFM/core/recommend"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultRecommendLimit 默认返回的推荐数量
+const defaultRecommendLimit = 20
+
+// RecommendHandler 推荐处理器
+type RecommendHandler struct {
+	service *recommend.Service
+}
+
+// NewRecommendHandler 创建推荐处理器
+func NewRecommendHandler(service *recommend.Service) *RecommendHandler {
+	return &RecommendHandler{service: service}
+}
+
+// GetRecommendationsHandler 返回用户的推荐歌曲，GET /api/recommendations?limit=20
+// 还没有足够播放历史的用户返回热门歌曲，personalized 为 false
+func (h *RecommendHandler) GetRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	if !h.service.Enabled() {
+		writeError(w, CodeServiceUnavailable, "Recommendations are disabled")
+		return
+	}
+
+	limit := defaultRecommendLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 {
+			writeError(w, CodeBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(limit, recommend.ListLimit)
+	}
+
+	list, personalized, err := h.service.ForUser(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取推荐失败", logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to get recommendations")
+		return
+	}
+	items := list.Items
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":        items,
+		"personalized": personalized,
+		"generatedAt":  list.GeneratedAt,
+	})
+}
+
+// RegisterRecommendRoutes 注册推荐路由
+func RegisterRecommendRoutes(router *mux.Router, handler *RecommendHandler, authMiddleware func(http.HandlerFunc) http.HandlerFunc) {
+	router.HandleFunc("/api/recommendations", authMiddleware(handler.GetRecommendationsHandler)).Methods(http.MethodGet)
+
+	logger.Info("推荐API端点注册完成",
+		logger.String("endpoints", "GET /api/recommendations"))
+}
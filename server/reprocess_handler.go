@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"Bt1QFM/core/audio"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// 批量重新转码的范围
+const (
+	reprocessScopeAll   = "all"
+	reprocessScopeUser  = "user"
+	reprocessScopeAlbum = "album"
+)
+
+// reprocessRequest 批量重新转码请求
+type reprocessRequest struct {
+	Scope   string `json:"scope"`
+	UserID  int64  `json:"userId"`
+	AlbumID int64  `json:"albumId"`
+	DryRun  bool   `json:"dryRun"`
+}
+
+// ReprocessStatus 批量重新转码的进度
+type ReprocessStatus struct {
+	Running    bool       `json:"running"`
+	Scope      string     `json:"scope,omitempty"`
+	UserID     int64      `json:"userId,omitempty"`
+	AlbumID    int64      `json:"albumId,omitempty"`
+	Queued     int        `json:"queued"`
+	Done       int        `json:"done"`
+	Failed     int        `json:"failed"`
+	Skipped    int        `json:"skipped"` // 没有内容哈希的旧数据无法切换到新流，不重新转码
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// reprocessState 当前或最近一次批量重新转码，同一时间只允许一个任务
+type reprocessState struct {
+	mu     sync.Mutex
+	status ReprocessStatus
+}
+
+// reprocessTask 一首需要重新转码的曲目
+type reprocessTask struct {
+	track *model.Track
+	opts  *audio.TranscodeOptions
+}
+
+// ReprocessHandler 按范围（all / user / album）找出流的码率、分片时长或用户偏好与当前配置不一致的曲目，在后台重新转码
+// 新的流生成到新的流ID下，完成后再切换曲目的播放列表路径，转码期间旧的流仍可播放；dryRun=true 时只统计不转码
+func (h *APIHandler) ReprocessHandler(w http.ResponseWriter, r *http.Request) {
+	var req reprocessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	switch {
+	case req.Scope == reprocessScopeUser && req.UserID <= 0:
+		writeError(w, CodeBadRequest, "userId is required for scope user")
+		return
+	case req.Scope == reprocessScopeAlbum && req.AlbumID <= 0:
+		writeError(w, CodeBadRequest, "albumId is required for scope album")
+		return
+	case req.Scope != reprocessScopeAll && req.Scope != reprocessScopeUser && req.Scope != reprocessScopeAlbum:
+		writeError(w, CodeBadRequest, "scope must be one of all, user, album")
+		return
+	}
+
+	tasks, skipped, err := h.staleStreamTracks(r.Context(), &req)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("查找需要重新转码的曲目失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to find tracks to reprocess")
+		return
+	}
+	if tasks == nil {
+		writeError(w, CodeAlbumNotFound, "Album not found")
+		return
+	}
+
+	status := ReprocessStatus{Scope: req.Scope, UserID: req.UserID, AlbumID: req.AlbumID, Queued: len(tasks), Skipped: skipped}
+	if req.DryRun {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data":    status,
+		})
+		return
+	}
+
+	h.reprocess.mu.Lock()
+	if h.reprocess.status.Running {
+		h.reprocess.mu.Unlock()
+		writeError(w, CodeConflict, "Reprocessing is already running")
+		return
+	}
+	now := time.Now()
+	status.Running = len(tasks) > 0
+	status.StartedAt = &now
+	if !status.Running {
+		status.FinishedAt = &now
+	}
+	h.reprocess.status = status
+	h.reprocess.mu.Unlock()
+
+	username, _ := GetUsernameFromContext(r.Context())
+	logger.Ctx(r.Context()).Info("管理员触发批量重新转码",
+		logger.String("username", username),
+		logger.String("scope", req.Scope),
+		logger.Int64("userId", req.UserID),
+		logger.Int64("albumId", req.AlbumID),
+		logger.Int("queued", len(tasks)),
+		logger.Int("skipped", skipped))
+	if status.Running {
+		go h.runReprocess(tasks)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    status,
+	})
+}
+
+// ReprocessStatusHandler 返回当前或最近一次批量重新转码的进度
+func (h *APIHandler) ReprocessStatusHandler(w http.ResponseWriter, r *http.Request) {
+	h.reprocess.mu.Lock()
+	status := h.reprocess.status
+	h.reprocess.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    status,
+	})
+}
+
+// staleStreamTracks 返回范围内流ID与当前转码参数不一致的已完成曲目和跳过的旧数据数量
+// 指定的专辑不存在时返回 nil
+func (h *APIHandler) staleStreamTracks(ctx context.Context, req *reprocessRequest) ([]reprocessTask, int, error) {
+	var albumTrackIDs map[int64]bool
+	if req.Scope == reprocessScopeAlbum {
+		album, err := h.albumRepo.GetAlbumByID(ctx, req.AlbumID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if album == nil {
+			return nil, 0, nil
+		}
+		albumTracks, err := h.albumRepo.GetAlbumTracks(ctx, req.AlbumID)
+		if err != nil {
+			return nil, 0, err
+		}
+		albumTrackIDs = make(map[int64]bool, len(albumTracks))
+		for _, track := range albumTracks {
+			albumTrackIDs[track.ID] = true
+		}
+	}
+
+	tracks, err := h.trackRepo.GetLiveTrackStorageStates(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	tasks := make([]reprocessTask, 0)
+	skipped := 0
+	optsByUser := make(map[int64]*audio.TranscodeOptions)
+	for _, track := range tracks {
+		if req.Scope == reprocessScopeUser && track.UserID != req.UserID {
+			continue
+		}
+		if albumTrackIDs != nil && !albumTrackIDs[track.ID] {
+			continue
+		}
+		if track.Status != "completed" || track.FilePath == "" || track.HLSPlaylistPath == "" {
+			continue
+		}
+		if track.ContentHash == "" {
+			skipped++
+			continue
+		}
+
+		opts, ok := optsByUser[track.UserID]
+		if !ok {
+			opts = h.userTranscodeOptions(ctx, track.UserID)
+			optsByUser[track.UserID] = opts
+		}
+		if track.StreamID() != contentStreamID(track.ContentHash, opts) {
+			tasks = append(tasks, reprocessTask{track: track, opts: opts})
+		}
+	}
+	return tasks, skipped, nil
+}
+
+// runReprocess 依次重新生成曲目的流，避免同时启动大量 FFmpeg 进程
+// 相同内容的曲目共享新的流，第一首转码完成后其余曲目直接切换
+func (h *APIHandler) runReprocess(tasks []reprocessTask) {
+	// 在后台运行，不能使用已结束请求的 context
+	ctx := context.Background()
+	for _, task := range tasks {
+		err := h.regenerateTrackStream(ctx, task.track, task.opts)
+		if err != nil {
+			logger.Error("重新转码曲目失败",
+				logger.Int64("trackId", task.track.ID),
+				logger.ErrorField(err))
+		}
+
+		h.reprocess.mu.Lock()
+		if err != nil {
+			h.reprocess.status.Failed++
+		} else {
+			h.reprocess.status.Done++
+		}
+		h.reprocess.mu.Unlock()
+	}
+
+	h.reprocess.mu.Lock()
+	now := time.Now()
+	h.reprocess.status.Running = false
+	h.reprocess.status.FinishedAt = &now
+	status := h.reprocess.status
+	h.reprocess.mu.Unlock()
+
+	logger.Info("批量重新转码完成",
+		logger.String("scope", status.Scope),
+		logger.Int("done", status.Done),
+		logger.Int("failed", status.Failed))
+}
@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"strconv"
 
+	"Bt1QFM/core/notification"
 	"Bt1QFM/core/room"
+	"Bt1QFM/core/social"
 	"Bt1QFM/logger"
 	"Bt1QFM/model"
 
@@ -16,20 +18,32 @@ import (
 
 // RoomHandler 房间 HTTP 处理器
 type RoomHandler struct {
-	manager  *room.RoomManager
-	upgrader websocket.Upgrader
+	manager       *room.RoomManager
+	upgrader      websocket.Upgrader
+	wsAuth        *wsAuthenticator
+	social        *social.Service
+	notifications *notification.Service
 }
 
 // NewRoomHandler 创建房间处理器
-func NewRoomHandler(manager *room.RoomManager) *RoomHandler {
+func NewRoomHandler(manager *room.RoomManager, wsAuth *wsAuthenticator) *RoomHandler {
 	return &RoomHandler{
-		manager: manager,
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
-		},
+		manager:  manager,
+		upgrader: wsAuth.Upgrader(0, 0),
+		wsAuth:   wsAuth,
 	}
 }
 
+// SetSocialService 设置动态服务，创建房间时记录到关注者的动态流
+func (h *RoomHandler) SetSocialService(service *social.Service) {
+	h.social = service
+}
+
+// SetNotificationService 设置通知服务，未设置时邀请接口返回 503
+func (h *RoomHandler) SetNotificationService(service *notification.Service) {
+	h.notifications = service
+}
+
 // ========== HTTP 处理器 ==========
 
 // CreateRoomRequest 创建房间请求
@@ -49,14 +63,14 @@ func (h *RoomHandler) CreateRoomHandler(w http.ResponseWriter, r *http.Request)
 	// 从上下文获取用户信息
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
-		http.Error(w, "未授权", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权")
 		return
 	}
 	username, _ := ctx.Value("username").(string)
 
 	var req CreateRoomRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "无效的请求", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "无效的请求")
 		return
 	}
 
@@ -67,10 +81,19 @@ func (h *RoomHandler) CreateRoomHandler(w http.ResponseWriter, r *http.Request)
 	room, err := h.manager.CreateRoom(ctx, userID, username, req.Name)
 	if err != nil {
 		logger.Error("创建房间失败", logger.ErrorField(err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, CodeInternal, err.Error())
 		return
 	}
 
+	if h.social != nil {
+		h.social.Record(ctx, &model.Activity{
+			UserID:   userID,
+			Type:     model.ActivityRoomCreated,
+			ObjectID: room.ID,
+			Title:    room.Name,
+		})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(&CreateRoomResponse{Room: room})
 }
@@ -92,7 +115,7 @@ func (h *RoomHandler) JoinRoomHandler(w http.ResponseWriter, r *http.Request) {
 
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
-		http.Error(w, "未授权", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权")
 		return
 	}
 	username, _ := ctx.Value("username").(string)
@@ -100,19 +123,19 @@ func (h *RoomHandler) JoinRoomHandler(w http.ResponseWriter, r *http.Request) {
 
 	var req JoinRoomRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "无效的请求", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "无效的请求")
 		return
 	}
 
 	if req.RoomID == "" {
-		http.Error(w, "房间ID不能为空", http.StatusBadRequest)
+		writeError(w, CodeMissingField, "房间ID不能为空")
 		return
 	}
 
 	roomInfo, member, err := h.manager.JoinRoom(ctx, req.RoomID, userID, username, avatar)
 	if err != nil {
 		logger.Warn("加入房间失败", logger.ErrorField(err))
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, CodeBadRequest, err.Error())
 		return
 	}
 
@@ -120,6 +143,91 @@ func (h *RoomHandler) JoinRoomHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(&JoinRoomResponse{Room: roomInfo, Member: member})
 }
 
+// InviteRoomRequest 邀请用户加入房间请求
+type InviteRoomRequest struct {
+	RoomID string `json:"roomId"`
+	UserID int64  `json:"userId"`
+}
+
+// InviteRoomHandler 邀请用户加入房间，只有房间成员可以邀请，被邀请的用户会收到通知
+func (h *RoomHandler) InviteRoomHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.notifications == nil {
+		writeError(w, CodeServiceUnavailable, "通知服务不可用")
+		return
+	}
+	userID, ok := ctx.Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "未授权")
+		return
+	}
+
+	var req InviteRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "无效的请求")
+		return
+	}
+	if req.RoomID == "" || req.UserID <= 0 {
+		writeError(w, CodeMissingField, "房间ID和用户ID不能为空")
+		return
+	}
+	if req.UserID == userID {
+		writeError(w, CodeBadRequest, "不能邀请自己")
+		return
+	}
+
+	roomInfo, err := h.manager.GetRoom(ctx, req.RoomID)
+	if err != nil || roomInfo == nil || roomInfo.Status != model.RoomStatusActive {
+		writeError(w, CodeRoomNotFound, "房间不存在")
+		return
+	}
+	inviterIn, err := h.manager.IsMember(ctx, req.RoomID, userID)
+	if err != nil {
+		logger.Ctx(ctx).Error("验证房间成员失败", logger.String("roomId", req.RoomID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "验证房间成员失败")
+		return
+	}
+	if !inviterIn {
+		writeError(w, CodeForbidden, "只有房间成员可以邀请")
+		return
+	}
+	inviteeIn, err := h.manager.IsMember(ctx, req.RoomID, req.UserID)
+	if err != nil {
+		logger.Ctx(ctx).Error("验证房间成员失败", logger.String("roomId", req.RoomID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "验证房间成员失败")
+		return
+	}
+	if inviteeIn {
+		writeError(w, CodeConflict, "该用户已在房间中")
+		return
+	}
+
+	err = h.notifications.Send(ctx, &model.Notification{
+		UserID:   req.UserID,
+		Type:     model.NotificationRoomInvite,
+		ActorID:  userID,
+		ObjectID: req.RoomID,
+		Title:    roomInfo.Name,
+	})
+	if err == notification.ErrUserNotFound {
+		writeError(w, CodeUserNotFound, "用户不存在")
+		return
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error("发送房间邀请失败", logger.String("roomId", req.RoomID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "发送邀请失败")
+		return
+	}
+
+	logger.Ctx(ctx).Info("已发送房间邀请",
+		logger.String("roomId", req.RoomID),
+		logger.Int64("inviterId", userID),
+		logger.Int64("inviteeId", req.UserID))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "邀请已发送"})
+}
+
 // LeaveRoomRequest 离开房间请求
 type LeaveRoomRequest struct {
 	RoomID     string `json:"roomId"`
@@ -132,19 +240,19 @@ func (h *RoomHandler) LeaveRoomHandler(w http.ResponseWriter, r *http.Request) {
 
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
-		http.Error(w, "未授权", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权")
 		return
 	}
 
 	var req LeaveRoomRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "无效的请求", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "无效的请求")
 		return
 	}
 
 	if err := h.manager.LeaveRoom(ctx, req.RoomID, userID, req.TransferTo); err != nil {
 		logger.Warn("离开房间失败", logger.ErrorField(err))
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, CodeBadRequest, err.Error())
 		return
 	}
 
@@ -159,13 +267,13 @@ func (h *RoomHandler) GetRoomHandler(w http.ResponseWriter, r *http.Request) {
 	roomID := vars["room_id"]
 
 	if roomID == "" {
-		http.Error(w, "房间ID不能为空", http.StatusBadRequest)
+		writeError(w, CodeMissingField, "房间ID不能为空")
 		return
 	}
 
 	roomInfo, err := h.manager.GetRoomInfo(ctx, roomID, "")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, CodeNotFound, err.Error())
 		return
 	}
 
@@ -181,7 +289,7 @@ func (h *RoomHandler) GetPlaylistHandler(w http.ResponseWriter, r *http.Request)
 
 	playlist, err := h.manager.GetPlaylist(ctx, roomID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, CodeInternal, err.Error())
 		return
 	}
 
@@ -197,7 +305,7 @@ func (h *RoomHandler) GetPlaybackHandler(w http.ResponseWriter, r *http.Request)
 
 	state, err := h.manager.GetPlayback(ctx, roomID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, CodeInternal, err.Error())
 		return
 	}
 
@@ -229,24 +337,24 @@ func (h *RoomHandler) AddSongHandler(w http.ResponseWriter, r *http.Request) {
 	// 从上下文获取用户信息
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
-		http.Error(w, "未授权", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权")
 		return
 	}
 	username, _ := ctx.Value("username").(string)
 
 	if roomID == "" {
-		http.Error(w, "房间ID不能为空", http.StatusBadRequest)
+		writeError(w, CodeMissingField, "房间ID不能为空")
 		return
 	}
 
 	var req AddSongRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "无效的请求", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "无效的请求")
 		return
 	}
 
 	if req.SongID == "" || req.Name == "" {
-		http.Error(w, "歌曲ID和名称不能为空", http.StatusBadRequest)
+		writeError(w, CodeMissingField, "歌曲ID和名称不能为空")
 		return
 	}
 
@@ -254,11 +362,11 @@ func (h *RoomHandler) AddSongHandler(w http.ResponseWriter, r *http.Request) {
 	isMember, err := h.manager.IsMember(ctx, roomID, userID)
 	if err != nil {
 		logger.Warn("验证房间成员失败", logger.ErrorField(err))
-		http.Error(w, "验证房间成员失败", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "验证房间成员失败")
 		return
 	}
 	if !isMember {
-		http.Error(w, "您不是该房间的成员", http.StatusForbidden)
+		writeError(w, CodeForbidden, "您不是该房间的成员")
 		return
 	}
 
@@ -274,7 +382,7 @@ func (h *RoomHandler) AddSongHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err := h.manager.AddSong(ctx, roomID, userID, songData); err != nil {
 		logger.Error("添加歌曲失败", logger.ErrorField(err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, CodeInternal, err.Error())
 		return
 	}
 
@@ -309,18 +417,18 @@ func (h *RoomHandler) SwitchModeHandler(w http.ResponseWriter, r *http.Request)
 
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
-		http.Error(w, "未授权", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权")
 		return
 	}
 
 	var req SwitchModeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "无效的请求", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "无效的请求")
 		return
 	}
 
 	if err := h.manager.SwitchMode(ctx, req.RoomID, userID, req.Mode); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, CodeBadRequest, err.Error())
 		return
 	}
 
@@ -340,18 +448,18 @@ func (h *RoomHandler) TransferOwnerHandler(w http.ResponseWriter, r *http.Reques
 
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
-		http.Error(w, "未授权", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权")
 		return
 	}
 
 	var req TransferOwnerRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "无效的请求", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "无效的请求")
 		return
 	}
 
 	if err := h.manager.TransferOwner(ctx, req.RoomID, userID, req.TargetUserID); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, CodeBadRequest, err.Error())
 		return
 	}
 
@@ -372,18 +480,18 @@ func (h *RoomHandler) GrantControlHandler(w http.ResponseWriter, r *http.Request
 
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
-		http.Error(w, "未授权", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权")
 		return
 	}
 
 	var req GrantControlRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "无效的请求", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "无效的请求")
 		return
 	}
 
 	if err := h.manager.GrantControl(ctx, req.RoomID, userID, req.TargetUserID, req.CanControl); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, CodeBadRequest, err.Error())
 		return
 	}
 
@@ -412,7 +520,7 @@ func (h *RoomHandler) GetMessagesHandler(w http.ResponseWriter, r *http.Request)
 
 	messages, err := h.manager.GetMessages(ctx, roomID, limit, offset)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, CodeInternal, err.Error())
 		return
 	}
 
@@ -426,14 +534,14 @@ func (h *RoomHandler) GetMyRoomsHandler(w http.ResponseWriter, r *http.Request)
 
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
-		http.Error(w, "未授权", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权")
 		return
 	}
 
 	rooms, err := h.manager.GetUserRooms(ctx, userID)
 	if err != nil {
 		logger.Warn("获取用户房间列表失败", logger.ErrorField(err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, CodeInternal, err.Error())
 		return
 	}
 
@@ -452,24 +560,24 @@ func (h *RoomHandler) DisbandRoomHandler(w http.ResponseWriter, r *http.Request)
 
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
-		http.Error(w, "未授权", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "未授权")
 		return
 	}
 
 	var req DisbandRoomRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "无效的请求", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "无效的请求")
 		return
 	}
 
 	if req.RoomID == "" {
-		http.Error(w, "房间ID不能为空", http.StatusBadRequest)
+		writeError(w, CodeMissingField, "房间ID不能为空")
 		return
 	}
 
 	if err := h.manager.DisbandRoom(ctx, req.RoomID, userID); err != nil {
 		logger.Warn("解散房间失败", logger.ErrorField(err))
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, CodeBadRequest, err.Error())
 		return
 	}
 
@@ -477,62 +585,211 @@ func (h *RoomHandler) DisbandRoomHandler(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(map[string]string{"message": "房间已解散"})
 }
 
-// ========== WebSocket 处理器 ==========
+// StationRequest 开关房间电台请求
+type StationRequest struct {
+	RoomID  string `json:"roomId"`
+	Enabled bool   `json:"enabled"`
+}
 
-// WebSocketHandler 处理 WebSocket 连接
-func (h *RoomHandler) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	roomID := vars["room_id"]
+// StationHandler 开启或关闭房间电台（仅房主）
+// 电台模式下由服务端按歌曲时长推进歌单并广播 master_sync，房主离线后仍继续播放，歌单播完时由 AI 续播
+func (h *RoomHandler) StationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 
-	if roomID == "" {
-		http.Error(w, "房间ID不能为空", http.StatusBadRequest)
+	userID, ok := ctx.Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "未授权")
 		return
 	}
 
-	// 从查询参数获取用户信息（WebSocket 无法通过 header 传递 token）
-	userIDStr := r.URL.Query().Get("userId")
-	username := r.URL.Query().Get("username")
-	token := r.URL.Query().Get("token")
+	var req StationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "无效的请求")
+		return
+	}
+	if req.RoomID == "" {
+		writeError(w, CodeMissingField, "房间ID不能为空")
+		return
+	}
 
-	if userIDStr == "" || token == "" {
-		http.Error(w, "缺少认证信息", http.StatusUnauthorized)
+	var err error
+	if req.Enabled {
+		err = h.manager.StartStation(ctx, req.RoomID, userID)
+	} else {
+		err = h.manager.StopStation(ctx, req.RoomID, userID)
+	}
+	if err != nil {
+		logger.Warn("切换房间电台失败", logger.String("roomId", req.RoomID), logger.ErrorField(err))
+		writeError(w, CodeBadRequest, err.Error())
 		return
 	}
 
-	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.manager.GetStationStatus(req.RoomID))
+}
+
+// GetStationHandler 获取房间电台状态
+func (h *RoomHandler) GetStationHandler(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["room_id"]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.manager.GetStationStatus(roomID))
+}
+
+// KaraokeRequest 开关房间卡拉 OK 模式请求，offsetMs 为可选的歌词偏移（正数表示歌词提前显示）
+type KaraokeRequest struct {
+	RoomID   string `json:"roomId"`
+	Enabled  bool   `json:"enabled"`
+	OffsetMs *int64 `json:"offsetMs,omitempty"`
+}
+
+// KaraokeHandler 开启或关闭房间卡拉 OK 模式（仅房主）
+// 开启后服务端按播放进度向听歌模式用户推送 lyric 消息；房间内也可用 "/offset <毫秒>" 命令校准偏移
+func (h *RoomHandler) KaraokeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := ctx.Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "未授权")
+		return
+	}
+
+	var req KaraokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "无效的请求")
+		return
+	}
+	if req.RoomID == "" {
+		writeError(w, CodeMissingField, "房间ID不能为空")
+		return
+	}
+
+	err := h.manager.SetKaraoke(ctx, req.RoomID, userID, req.Enabled)
+	if err == nil && req.Enabled && req.OffsetMs != nil {
+		err = h.manager.SetKaraokeOffset(ctx, req.RoomID, userID, *req.OffsetMs)
+	}
+	if err != nil {
+		logger.Warn("切换房间卡拉 OK 模式失败", logger.String("roomId", req.RoomID), logger.ErrorField(err))
+		writeError(w, CodeBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.manager.GetKaraokeStatus(req.RoomID))
+}
+
+// GetKaraokeHandler 获取房间卡拉 OK 模式状态
+func (h *RoomHandler) GetKaraokeHandler(w http.ResponseWriter, r *http.Request) {
+	roomID := mux.Vars(r)["room_id"]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.manager.GetKaraokeStatus(roomID))
+}
+
+// GetRoomSummaryHandler 获取房间解散后生成的听歌总结，只有加入过房间的用户可以查看
+func (h *RoomHandler) GetRoomSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := ctx.Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "未授权")
+		return
+	}
+
+	roomID := mux.Vars(r)["room_id"]
+	joined, err := h.manager.HasParticipated(ctx, roomID, userID)
+	if err != nil {
+		logger.Ctx(ctx).Error("验证房间成员失败", logger.String("roomId", roomID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "验证房间成员失败")
+		return
+	}
+	if !joined {
+		writeError(w, CodeForbidden, "您没有参与过该房间")
+		return
+	}
+
+	summary, err := h.manager.GetRoomSummary(ctx, roomID)
 	if err != nil {
-		http.Error(w, "无效的用户ID", http.StatusBadRequest)
+		logger.Ctx(ctx).Error("获取房间总结失败", logger.String("roomId", roomID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "获取房间总结失败")
+		return
+	}
+	if summary == nil {
+		writeError(w, CodeNotFound, "房间尚未解散，暂无总结")
 		return
 	}
 
-	// TODO: 验证 token
-	// 这里应该调用 token 验证逻辑
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// ========== WebSocket 处理器 ==========
+
+// WebSocketHandler 处理 WebSocket 连接
+func (h *RoomHandler) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	roomID := vars["room_id"]
+
+	if roomID == "" {
+		writeError(w, CodeMissingField, "房间ID不能为空")
+		return
+	}
+
+	// 订阅主题，逗号分隔（chat,playback,presence,playlist），为空订阅全部
+	topics := room.ParseTopics(r.URL.Query().Get("topics"))
+
+	// 断线重连时携带最后收到的消息序号，服务端补发断线期间的广播消息
+	resumeSeq, _ := strconv.ParseInt(r.URL.Query().Get("lastSeq"), 10, 64)
 
 	// 检查房间是否存在
 	ctx := r.Context()
 	roomInfo, err := h.manager.GetRoom(ctx, roomID)
 	if err != nil || roomInfo == nil {
-		http.Error(w, "房间不存在", http.StatusNotFound)
+		writeError(w, CodeRoomNotFound, "房间不存在")
 		return
 	}
 
-	// 升级为 WebSocket 连接
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+	// 升级为 WebSocket 连接并认证，用户身份以 Token 为准
+	conn, claims, err := h.wsAuth.Accept(w, r, &h.upgrader)
 	if err != nil {
-		logger.Error("WebSocket 升级失败", logger.ErrorField(err))
+		logger.Warn("房间 WebSocket 认证失败",
+			logger.String("roomId", roomID),
+			logger.ErrorField(err))
+		return
+	}
+	userID := claims.UserID
+	username := claims.Username
+
+	// 只有房间成员可以建立连接
+	isMember, err := h.manager.IsMember(ctx, roomID, userID)
+	if err != nil {
+		logger.Error("检查房间成员失败",
+			logger.String("roomId", roomID),
+			logger.Int64("userId", userID),
+			logger.ErrorField(err))
+		closeWebSocket(conn, websocket.CloseInternalServerErr, "failed to check membership")
+		return
+	}
+	if !isMember {
+		logger.Warn("非房间成员尝试建立 WebSocket 连接",
+			logger.String("roomId", roomID),
+			logger.Int64("userId", userID))
+		closeWebSocket(conn, wsCloseForbidden, "not a room member")
 		return
 	}
 
 	// 创建客户端
 	client := &room.Client{
-		Hub:      h.manager.GetHub(),
-		Conn:     conn,
-		Send:     make(chan []byte, 256),
-		RoomID:   roomID,
-		UserID:   userID,
-		Username: username,
-		Mode:     model.RoomModeChat,
-		Role:     model.RoomRoleMember,
+		Hub:       h.manager.GetHub(),
+		Conn:      conn,
+		Send:      make(chan []byte, 256),
+		RoomID:    roomID,
+		UserID:    userID,
+		Username:  username,
+		Mode:      model.RoomModeChat,
+		Role:      model.RoomRoleMember,
+		Topics:    topics,
+		ResumeSeq: max(resumeSeq, 0),
 	}
 
 	// 注册客户端
@@ -554,6 +811,7 @@ func RegisterRoomRoutes(router *mux.Router, handler *RoomHandler, authMiddleware
 	router.HandleFunc("/api/rooms", authMiddleware(handler.CreateRoomHandler)).Methods(http.MethodPost)
 	router.HandleFunc("/api/rooms/my", authMiddleware(handler.GetMyRoomsHandler)).Methods(http.MethodGet)
 	router.HandleFunc("/api/rooms/join", authMiddleware(handler.JoinRoomHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/rooms/invite", authMiddleware(handler.InviteRoomHandler)).Methods(http.MethodPost)
 	router.HandleFunc("/api/rooms/leave", authMiddleware(handler.LeaveRoomHandler)).Methods(http.MethodPost)
 	router.HandleFunc("/api/rooms/disband", authMiddleware(handler.DisbandRoomHandler)).Methods(http.MethodPost)
 	router.HandleFunc("/api/rooms/{room_id}", authMiddleware(handler.GetRoomHandler)).Methods(http.MethodGet)
@@ -561,13 +819,18 @@ func RegisterRoomRoutes(router *mux.Router, handler *RoomHandler, authMiddleware
 	router.HandleFunc("/api/rooms/{room_id}/playlist", authMiddleware(handler.AddSongHandler)).Methods(http.MethodPost)
 	router.HandleFunc("/api/rooms/{room_id}/playback", authMiddleware(handler.GetPlaybackHandler)).Methods(http.MethodGet)
 	router.HandleFunc("/api/rooms/{room_id}/messages", authMiddleware(handler.GetMessagesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/rooms/{room_id}/station", authMiddleware(handler.GetStationHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/rooms/{room_id}/karaoke", authMiddleware(handler.GetKaraokeHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/rooms/{room_id}/summary", authMiddleware(handler.GetRoomSummaryHandler)).Methods(http.MethodGet)
 	router.HandleFunc("/api/rooms/mode", authMiddleware(handler.SwitchModeHandler)).Methods(http.MethodPost)
 	router.HandleFunc("/api/rooms/transfer", authMiddleware(handler.TransferOwnerHandler)).Methods(http.MethodPost)
 	router.HandleFunc("/api/rooms/control", authMiddleware(handler.GrantControlHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/rooms/station", authMiddleware(handler.StationHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/rooms/karaoke", authMiddleware(handler.KaraokeHandler)).Methods(http.MethodPost)
 
 	// WebSocket 路由
 	router.HandleFunc("/ws/room/{room_id}", handler.WebSocketHandler)
 
 	logger.Info("房间系统API端点注册完成",
-		logger.String("endpoints", "POST /api/rooms, GET /api/rooms/my, POST /api/rooms/join, POST /api/rooms/leave, POST /api/rooms/disband, GET /api/rooms/{id}, POST /api/rooms/{id}/playlist, WS /ws/room/{id}"))
+		logger.String("endpoints", "POST /api/rooms, GET /api/rooms/my, POST /api/rooms/join, POST /api/rooms/invite, POST /api/rooms/leave, POST /api/rooms/disband, GET /api/rooms/{id}, POST /api/rooms/{id}/playlist, POST /api/rooms/station, POST /api/rooms/karaoke, GET /api/rooms/{id}/summary, WS /ws/room/{id}"))
 }
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"Bt1QFM/core/scrobble"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// maxPlayHistoryLimit 播放历史单次返回的最大条数
+const maxPlayHistoryLimit = 200
+
+// ScrobbleHandler 听歌记录同步账号与播放历史处理器
+type ScrobbleHandler struct {
+	service     *scrobble.Service
+	accountRepo repository.ScrobbleAccountRepository
+	historyRepo repository.PlayHistoryRepository
+}
+
+// NewScrobbleHandler 创建听歌记录处理器
+func NewScrobbleHandler(service *scrobble.Service, accountRepo repository.ScrobbleAccountRepository, historyRepo repository.PlayHistoryRepository) *ScrobbleHandler {
+	return &ScrobbleHandler{
+		service:     service,
+		accountRepo: accountRepo,
+		historyRepo: historyRepo,
+	}
+}
+
+// ListAccountsHandler 返回当前用户绑定的账号，GET /api/scrobble/accounts
+func (h *ScrobbleHandler) ListAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	accounts, err := h.accountRepo.GetAccountsByUserID(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取听歌记录同步账号失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get accounts")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"accounts":         accounts,
+			"lastfmConfigured": h.service.LastFM.Configured(),
+		},
+	})
+}
+
+// ConnectLastFMHandler 绑定 Last.fm 账号，PUT /api/scrobble/accounts/lastfm
+// 请求体 {"username": "...", "password": "...", "apiKey": "...", "apiSecret": "..."}，密码只用于换取 session key，不会保存
+// 服务器未配置 LASTFM_API_KEY 时需要提供自己的 apiKey 和 apiSecret
+func (h *ScrobbleHandler) ConnectLastFMHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Username  string `json:"username"`
+		Password  string `json:"password"`
+		APIKey    string `json:"apiKey"`
+		APISecret string `json:"apiSecret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	req.APIKey, req.APISecret = strings.TrimSpace(req.APIKey), strings.TrimSpace(req.APISecret)
+	if req.Username == "" || req.Password == "" {
+		writeError(w, CodeMissingField, "Username and password are required")
+		return
+	}
+	if (req.APIKey == "") != (req.APISecret == "") {
+		writeError(w, CodeMissingField, "apiKey and apiSecret must be provided together")
+		return
+	}
+
+	account, err := h.service.LastFM.Authenticate(r.Context(), req.Username, req.Password, req.APIKey, req.APISecret)
+	if err != nil {
+		h.writeConnectError(w, r, model.ScrobbleServiceLastFM, err)
+		return
+	}
+	h.saveAccount(w, r, userID, account)
+}
+
+// ConnectListenBrainzHandler 绑定 ListenBrainz 账号，PUT /api/scrobble/accounts/listenbrainz
+// 请求体 {"token": "..."}，token 在 ListenBrainz 个人设置页获取
+func (h *ScrobbleHandler) ConnectListenBrainzHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		writeError(w, CodeMissingField, "Token is required")
+		return
+	}
+
+	account, err := h.service.ListenBrainz.Authenticate(r.Context(), req.Token)
+	if err != nil {
+		h.writeConnectError(w, r, model.ScrobbleServiceListenBrainz, err)
+		return
+	}
+	h.saveAccount(w, r, userID, account)
+}
+
+// DisconnectHandler 解绑账号，DELETE /api/scrobble/accounts/{service}
+func (h *ScrobbleHandler) DisconnectHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	service := mux.Vars(r)["service"]
+	deleted, err := h.accountRepo.DeleteAccount(r.Context(), userID, service)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("解绑听歌记录同步账号失败", logger.String("service", service), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to disconnect account")
+		return
+	}
+	if !deleted {
+		writeError(w, CodeNotFound, "Account not connected")
+		return
+	}
+
+	logger.Ctx(r.Context()).Info("已解绑听歌记录同步账号", logger.String("service", service))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// GetPlayHistoryHandler 返回当前用户最近的播放历史，GET /api/playback/history?limit=50
+func (h *ScrobbleHandler) GetPlayHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = min(n, maxPlayHistoryLimit)
+		}
+	}
+
+	plays, err := h.historyRepo.GetRecentPlays(r.Context(), userID, limit)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取播放历史失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get play history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    plays,
+	})
+}
+
+// saveAccount 保存验证通过的账号
+func (h *ScrobbleHandler) saveAccount(w http.ResponseWriter, r *http.Request, userID int64, account *model.ScrobbleAccount) {
+	account.UserID = userID
+	if err := h.accountRepo.SaveAccount(r.Context(), account); err != nil {
+		logger.Ctx(r.Context()).Error("保存听歌记录同步账号失败", logger.String("service", account.Service), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to save account")
+		return
+	}
+
+	logger.Ctx(r.Context()).Info("已绑定听歌记录同步账号",
+		logger.String("service", account.Service),
+		logger.String("username", account.Username))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"service":  account.Service,
+			"username": account.Username,
+		},
+	})
+}
+
+// writeConnectError 将绑定账号的错误转换为错误响应
+func (h *ScrobbleHandler) writeConnectError(w http.ResponseWriter, r *http.Request, service string, err error) {
+	switch {
+	case errors.Is(err, scrobble.ErrNotConfigured):
+		writeError(w, CodeBadRequest, "Last.fm API key is not configured on this server, provide your own apiKey and apiSecret")
+	case errors.Is(err, scrobble.ErrRejected):
+		logger.Ctx(r.Context()).Warn("绑定听歌记录同步账号被拒绝", logger.String("service", service), logger.ErrorField(err))
+		writeError(w, CodeScrobblerAuthFailed, "Invalid credentials")
+	default:
+		logger.Ctx(r.Context()).Error("绑定听歌记录同步账号失败", logger.String("service", service), logger.ErrorField(err))
+		writeError(w, CodeScrobblerUnavailable, "Failed to reach "+service)
+	}
+}
+
+// RegisterScrobbleRoutes 注册听歌记录同步与播放历史相关路由
+func RegisterScrobbleRoutes(router *mux.Router, handler *ScrobbleHandler, authMiddleware func(http.HandlerFunc) http.HandlerFunc) {
+	router.HandleFunc("/api/scrobble/accounts", authMiddleware(handler.ListAccountsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/scrobble/accounts/lastfm", authMiddleware(handler.ConnectLastFMHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/scrobble/accounts/listenbrainz", authMiddleware(handler.ConnectListenBrainzHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/scrobble/accounts/{service}", authMiddleware(handler.DisconnectHandler)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/playback/history", authMiddleware(handler.GetPlayHistoryHandler)).Methods(http.MethodGet)
+
+	logger.Info("听歌记录同步API端点注册完成",
+		logger.String("endpoints", "GET /api/scrobble/accounts, PUT /api/scrobble/accounts/{lastfm|listenbrainz}, DELETE /api/scrobble/accounts/{service}, GET /api/playback/history"))
+}
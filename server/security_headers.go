@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+
+	"Bt1QFM/config"
+)
+
+// SecurityHeadersMiddleware 为所有响应设置 X-Content-Type-Options: nosniff 和 Referrer-Policy，
+// HTML 响应另外设置 Content-Security-Policy 和 X-Frame-Options；处理函数已设置的同名响应头不覆盖
+func SecurityHeadersMiddleware(next http.Handler, cfg *config.Config) http.Handler {
+	if !cfg.SecurityHeadersEnabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		if r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&securityHeaderWriter{ResponseWriter: w, policy: cfg.ContentSecurityPolicy}, r)
+	})
+}
+
+// securityHeaderWriter 在写出响应头前根据 Content-Type 补充 HTML 的安全响应头
+type securityHeaderWriter struct {
+	http.ResponseWriter
+	policy  string
+	applied bool
+}
+
+// apply 只执行一次；没有 Content-Type 时按 net/http 的规则从第一段内容推断
+func (sw *securityHeaderWriter) apply(p []byte) {
+	if sw.applied {
+		return
+	}
+	sw.applied = true
+
+	header := sw.Header()
+	contentType := header.Get("Content-Type")
+	if contentType == "" && len(p) > 0 && header.Get("Content-Encoding") == "" {
+		contentType = http.DetectContentType(p)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return
+	}
+	if sw.policy != "" && header.Get("Content-Security-Policy") == "" {
+		header.Set("Content-Security-Policy", sw.policy)
+	}
+	if header.Get("X-Frame-Options") == "" {
+		header.Set("X-Frame-Options", "SAMEORIGIN")
+	}
+}
+
+func (sw *securityHeaderWriter) WriteHeader(status int) {
+	sw.apply(nil)
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *securityHeaderWriter) Write(p []byte) (int, error) {
+	sw.apply(p)
+	return sw.ResponseWriter.Write(p)
+}
+
+// Flush 支持事件流等流式响应
+func (sw *securityHeaderWriter) Flush() {
+	sw.apply(nil)
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 返回原始 ResponseWriter，供 http.ResponseController 使用
+func (sw *securityHeaderWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// Hijack 支持 WebSocket 升级，升级请求通常已在中间件入口跳过
+func (sw *securityHeaderWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
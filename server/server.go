@@ -12,9 +12,33 @@ import (
 	"Bt1QFM/cache"
 	"Bt1QFM/config"
 	"Bt1QFM/core/agent"
+	"Bt1QFM/core/announcement"
 	"Bt1QFM/core/audio"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/core/backup"
+	"Bt1QFM/core/bandwidth"
+	"Bt1QFM/core/captcha"
+	"Bt1QFM/core/cover"
+	"Bt1QFM/core/dailymix"
+	"Bt1QFM/core/device"
+	"Bt1QFM/core/digest"
+	"Bt1QFM/core/keyrotation"
+	"Bt1QFM/core/mail"
+	"Bt1QFM/core/musicbrainz"
 	"Bt1QFM/core/netease"
+	"Bt1QFM/core/notification"
+	"Bt1QFM/core/radio"
+	"Bt1QFM/core/recommend"
 	"Bt1QFM/core/room"
+	"Bt1QFM/core/scrobble"
+	"Bt1QFM/core/sleeptimer"
+	"Bt1QFM/core/social"
+	"Bt1QFM/core/speech"
+	"Bt1QFM/core/storagegc"
+	"Bt1QFM/core/streak"
+	"Bt1QFM/core/trash"
+	"Bt1QFM/core/trending"
+	"Bt1QFM/core/watchfolder"
 	"Bt1QFM/db"
 	"Bt1QFM/logger"
 	"Bt1QFM/model"
@@ -38,17 +62,39 @@ func Start() {
 		Compress:   true,              // 压缩旧日志文件
 	})
 
-	// 设置服务器超时
+	// 签名密钥用于派生分片密钥和签名播放地址，不能使用默认值
+	if err := auth.SetSecret(cfg.AppSecret); err != nil {
+		logger.Fatal("签名密钥配置无效", logger.ErrorField(err))
+	}
+
+	// 检查 FFmpeg 是否可用，缺少时直接退出，而不是等到转码时才失败
+	ffmpegInfo, err := audio.CheckFFmpeg(context.Background(), cfg)
+	if err != nil {
+		logger.Fatal("FFmpeg 检查失败", logger.ErrorField(err))
+	}
+	cfg.FFmpegPath = ffmpegInfo.FFmpegPath
+	logger.Info("FFmpeg 检查通过",
+		logger.String("ffmpeg", ffmpegInfo.FFmpegPath),
+		logger.String("ffprobe", ffmpegInfo.FFprobePath),
+		logger.String("version", ffmpegInfo.Version))
+
+	// 设置服务器超时和请求头大小，开启明文 HTTP/2 时反向代理可以用 HTTP/2 转发
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(cfg.HTTPUnencryptedHTTP2)
 	server := &http.Server{
-		Addr:         ":8080",
-		ReadTimeout:  300 * time.Second,
-		WriteTimeout: 300 * time.Second,
-		IdleTimeout:  1200 * time.Second,
+		Addr:              ":8080",
+		ReadTimeout:       time.Duration(cfg.HTTPReadTimeoutSeconds) * time.Second,
+		ReadHeaderTimeout: time.Duration(cfg.HTTPReadHeaderTimeoutSeconds) * time.Second,
+		WriteTimeout:      time.Duration(cfg.HTTPWriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(cfg.HTTPIdleTimeoutSeconds) * time.Second,
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderKB << 10,
+		Protocols:         &protocols,
 	}
 
-	// 初始化 MinIO 客户端
-	if err := storage.InitMinio(); err != nil {
-		logger.Fatal("初始化 MinIO 失败", logger.ErrorField(err))
+	// 初始化对象存储（MinIO 或本地磁盘）
+	if err := storage.InitStorage(cfg); err != nil {
+		logger.Fatal("初始化对象存储失败", logger.ErrorField(err))
 	}
 
 	// Connect to the database
@@ -72,7 +118,7 @@ func Start() {
 	logger.Info("成功连接到 GORM 数据库")
 
 	// Auto migrate room models
-	if err := db.AutoMigrateModels(&model.Room{}, &model.RoomMember{}, &model.RoomMessage{}); err != nil {
+	if err := db.AutoMigrateModels(&model.Room{}, &model.RoomMember{}, &model.RoomMessage{}, &model.RoomSummary{}); err != nil {
 		logger.Fatal("房间模型迁移失败", logger.ErrorField(err))
 	}
 
@@ -88,6 +134,15 @@ func Start() {
 	ensureDirExists(cfg.CoverUploadDir)                      // For cover art
 	ensureDirExists(filepath.Join(cfg.StaticDir, "streams")) // For HLS streams
 
+	// 🔑 加载 JWT 签名密钥，需在开始签发 Token 之前完成；之后定时检查轮换
+	keyRotation := keyrotation.NewService(repository.NewMySQLSigningKeyRepository(), cfg)
+	keyRotation.Start()
+
+	// 网易云API的重试、熔断和备用地址，需在创建网易云客户端之前设置
+	netease.Configure(cfg)
+	// 转码并发和资源限制，需在开始转码之前设置
+	audio.ConfigureTranscoding(cfg)
+
 	audioProcessor := audio.NewFFmpegProcessor(cfg.FFmpegPath)
 	mp3Processor := audio.NewMP3Processor(cfg.FFmpegPath)
 	streamProcessor := audio.NewStreamProcessor(mp3Processor, cfg) // 创建单例 StreamProcessor
@@ -97,28 +152,105 @@ func Start() {
 	announcementRepo := repository.NewAnnouncementRepository()
 	chatRepo := repository.NewMySQLChatRepository(db.DB)
 
+	// 🚫 按数据库重建禁用用户集合，Redis 数据丢失后被禁用账号的 Token 仍然无效
+	if ids, err := userRepo.GetUserIDsByStatus(context.Background(), model.UserStatusDisabled); err != nil {
+		logger.Warn("读取禁用用户失败", logger.ErrorField(err))
+	} else if err := cache.ReplaceDisabledUsers(context.Background(), ids); err != nil {
+		logger.Warn("重建禁用用户集合失败", logger.ErrorField(err))
+	}
+
+	// 🖼️ 初始化封面自动获取服务（网易云 -> iTunes -> MusicBrainz）
+	coverHTTPClient := &http.Client{Timeout: 15 * time.Second}
+	coverFetcher := cover.NewFetcher(trackRepo, albumRepo, []cover.Provider{
+		cover.NewNeteaseProvider(netease.NewClient()),
+		cover.NewITunesProvider(coverHTTPClient),
+		cover.NewMusicBrainzProvider(coverHTTPClient),
+	}, cfg)
+	coverFetcher.Start()
+
+	// 🧹 初始化存储垃圾回收
+	storageGC := storagegc.NewCollector(trackRepo, cfg)
+	storageGC.Start()
+
 	// 初始化处理器
-	apiHandler := NewAPIHandler(trackRepo, userRepo, albumRepo, audioProcessor, streamProcessor, cfg)
-	neteaseHandler := netease.NewNeteaseHandler(cfg.NeteaseAPIURL, cfg)
+	apiHandler := NewAPIHandler(trackRepo, userRepo, albumRepo, audioProcessor, streamProcessor, coverFetcher, storageGC, cfg)
+
+	// 🔎 初始化存储对账，处理存储对象已丢失的曲目
+	reconciler := storagegc.NewReconciler(trackRepo, cfg, apiHandler.RepairTrackStream)
+	reconciler.Start()
+	apiHandler.SetStorageReconciler(reconciler)
+	apiHandler.SetMusicBrainzClient(musicbrainz.NewClient(cfg.MusicBrainzAPIURL))
+	trackVerifier := storagegc.NewVerifier(trackRepo, cfg, apiHandler.RepairTrackStream)
+	trackVerifier.Start()
+	apiHandler.SetTrackVerifier(trackVerifier)
+
+	// 🗑️ 初始化回收站清理，超过保留期的曲目和专辑彻底删除并释放存储
+	trashPurger := trash.NewPurger(trackRepo, albumRepo, repository.NewMySQLTrackVersionRepository(), apiHandler, cfg)
+	trashPurger.Start()
+
+	// 📂 初始化监视目录，放入的音频自动导入到指定用户的曲库
+	watchFolder := watchfolder.NewWatcher(apiHandler, userRepo, albumRepo, cfg)
+	if err := watchFolder.Start(context.Background()); err != nil {
+		logger.Error("监视目录启动失败", logger.ErrorField(err))
+	}
+
+	// 💾 初始化备份服务，定期打包数据库转储、对象存储清单和配置文件
+	backupService := backup.NewService(db.DB, cfg)
+	backupService.Start()
+	apiHandler.SetBackupService(backupService)
+
+	neteaseHandler := netease.NewNeteaseHandler(cfg.NeteaseAPIURL, mp3Processor, cfg)
 	userHandler := NewUserHandler(userRepo)
 	announcementHandler := NewAnnouncementHandler(announcementRepo, userRepo)
 
 	// 初始化聊天处理器
 	agentConfig := &agent.MusicAgentConfig{
-		APIBaseURL:  cfg.AgentAPIBaseURL,
-		APIKey:      cfg.AgentAPIKey,
-		Model:       cfg.AgentModel,
-		MaxTokens:   cfg.AgentMaxTokens,
-		Temperature: cfg.AgentTemperature,
+		Provider:      cfg.AgentProvider,
+		APIBaseURL:    cfg.AgentAPIBaseURL,
+		APIKey:        cfg.AgentAPIKey,
+		Model:         cfg.AgentModel,
+		MaxTokens:     cfg.AgentMaxTokens,
+		Temperature:   cfg.AgentTemperature,
+		ContextTokens: cfg.AgentContextTokens,
+	}
+	if cfg.AgentFallbackProvider != "" {
+		agentConfig.Fallbacks = append(agentConfig.Fallbacks, &agent.MusicAgentConfig{
+			Provider:    cfg.AgentFallbackProvider,
+			APIBaseURL:  cfg.AgentFallbackAPIBaseURL,
+			APIKey:      cfg.AgentFallbackAPIKey,
+			Model:       cfg.AgentFallbackModel,
+			MaxTokens:   cfg.AgentMaxTokens,
+			Temperature: cfg.AgentTemperature,
+		})
 	}
 
 	logger.Info("Agent config initialized",
+		logger.String("provider", agentConfig.Provider),
+		logger.String("fallbackProvider", cfg.AgentFallbackProvider),
 		logger.String("model", agentConfig.Model),
 		logger.Int("maxTokens", agentConfig.MaxTokens),
 		logger.Float64("temperature", agentConfig.Temperature),
+		logger.Int("contextTokens", agentConfig.ContextTokens),
 		logger.String("apiBaseURL", agentConfig.APIBaseURL))
 
-	chatHandler := NewChatHandler(chatRepo, agentConfig)
+	chatHandler := NewChatHandler(chatRepo, agentConfig, apiHandler.wsAuth)
+	if cfg.RateLimitEnabled {
+		chatHandler.SetMessageRateLimit(cfg.RateLimitChat)
+	}
+	transcriber, err := speech.NewTranscriber(cfg)
+	if err != nil {
+		logger.Error("语音识别配置无效，语音消息不可用", logger.ErrorField(err))
+	} else if transcriber != nil {
+		chatHandler.SetTranscriber(transcriber, cfg.STTMaxAudioBytes)
+		logger.Info("已启用聊天语音消息", logger.String("provider", transcriber.Name()))
+	}
+	synthesizer, err := speech.NewSynthesizer(cfg)
+	if err != nil {
+		logger.Error("语音合成配置无效，语音播报不可用", logger.ErrorField(err))
+	} else if synthesizer != nil {
+		chatHandler.SetClipService(speech.NewClipService(synthesizer, cfg.TTSMaxChars))
+		logger.Info("已启用 AI 回复语音播报", logger.String("provider", synthesizer.Name()))
+	}
 
 	// 🏠 初始化房间系统
 	logger.Info("初始化房间系统...")
@@ -127,9 +259,97 @@ func Start() {
 	roomHub := room.NewRoomHub()
 	go roomHub.Run() // 启动 Hub 主循环
 	roomManager := room.NewRoomManager(roomRepo, roomCache, roomHub)
-	roomHandler := NewRoomHandler(roomManager)
+	roomHandler := NewRoomHandler(roomManager, apiHandler.wsAuth)
+	apiHandler.SetRoomManager(roomManager)
+	roomManager.SetLocalStreamResolver(apiHandler)
 	logger.Info("房间系统初始化完成")
 
+	// 📱 初始化多设备播放控制
+	deviceHub := device.NewHub()
+	go deviceHub.Run()
+	deviceHandler := NewDeviceHandler(deviceHub, apiHandler.wsAuth)
+
+	// 😴 初始化睡眠定时，到期时通过设备通道让用户的所有在线设备暂停
+	sleepTimerScheduler := sleeptimer.NewScheduler(deviceHub)
+	sleepTimerScheduler.Start()
+	sleepTimerHandler := NewSleepTimerHandler(sleepTimerScheduler)
+
+	// 🎼 初始化播放历史与听歌记录同步（Last.fm / ListenBrainz）
+	playHistoryRepo := repository.NewMySQLPlayHistoryRepository()
+	scrobbleAccountRepo := repository.NewMySQLScrobbleAccountRepository()
+	scrobbleService := scrobble.NewService(scrobbleAccountRepo, userRepo, cfg)
+	scrobbleService.Start()
+	apiHandler.SetPlaybackTracker(scrobble.NewTracker(playHistoryRepo, trackRepo, scrobbleService))
+	scrobbleHandler := NewScrobbleHandler(scrobbleService, scrobbleAccountRepo, playHistoryRepo)
+	chatHandler.SetLibrary(trackRepo, playHistoryRepo)
+
+	// 📻 初始化 AI 电台，队列快播完时根据播放历史自动续播
+	radioService := radio.NewService(agent.NewMusicAgent(agentConfig), playHistoryRepo)
+	apiHandler.SetRadioService(radioService)
+	roomManager.SetStationSuggester(radioService)
+	radioHandler := NewRadioHandler(radioService)
+
+	// 🎯 初始化推荐服务，定期根据所有用户的播放历史预计算推荐列表
+	recommendService := recommend.NewService(playHistoryRepo, cfg)
+	recommendService.Start()
+	recommendHandler := NewRecommendHandler(recommendService)
+
+	// 🎚️ 初始化 Daily Mix，每晚根据收听历史和网易云相似歌手生成推荐歌单
+	dailyMixService := dailymix.NewService(playHistoryRepo, netease.NewClient(), cfg)
+	dailyMixService.Start()
+	dailyMixHandler := NewDailyMixHandler(dailyMixService)
+
+	// 📈 初始化播放次数汇总，当天的计数保存在 Redis，每天汇总到数据库
+	trendingService := trending.NewService(repository.NewMySQLPlayCountRepository(), repository.NewNeteaseSongRepository(), cfg)
+	trendingService.Start()
+	trendingHandler := NewTrendingHandler(trendingService)
+
+	// 📶 初始化用户流量统计，当天的流量保存在 Redis，定时汇总到数据库
+	bandwidthService := bandwidth.NewService(repository.NewMySQLBandwidthRepository(), userRepo, cfg)
+	bandwidthService.Start()
+	bandwidthHandler := NewBandwidthHandler(bandwidthService)
+
+	// 🔔 初始化通知中心，开启推送时通过设备通道实时推送给在线用户
+	notificationRepo := repository.NewMySQLNotificationRepository()
+	notificationService := notification.NewService(notificationRepo, userRepo)
+	notificationService.SetAnnouncementReader(announcementRepo)
+	if cfg.NotificationPushEnabled {
+		notificationService.SetPusher(deviceHub)
+	}
+	apiHandler.SetNotificationService(notificationService)
+	roomHandler.SetNotificationService(notificationService)
+	announcementHandler.SetNotificationService(notificationService)
+
+	// 📢 初始化公告定时任务，按发布和过期时间切换公告状态
+	announcementScheduler := announcement.NewScheduler(announcementRepo, notificationService, cfg)
+	announcementScheduler.Start()
+	announcementHandler.SetScheduler(announcementScheduler)
+
+	// 👥 初始化关注关系与动态流
+	socialRepo := repository.NewMySQLSocialRepository()
+	socialService := social.NewService(socialRepo, userRepo)
+	socialService.SetNotificationService(notificationService)
+	apiHandler.SetSocialService(socialService)
+	roomHandler.SetSocialService(socialService)
+
+	// 🎯 初始化每日收听目标，每小时汇总收听时长，连续达标天数达到里程碑时发送通知
+	streakService := streak.NewService(repository.NewMySQLListeningRepository(), playHistoryRepo, userRepo, cfg)
+	streakService.SetNotificationService(notificationService)
+	streakService.Start()
+	streakHandler := NewStreakHandler(streakService, userRepo)
+
+	// 🤖 初始化人机验证，注册总是需要验证，登录在同一 IP 多次失败后需要验证
+	if verifier := captcha.New(cfg); verifier != nil {
+		apiHandler.SetCaptchaVerifier(verifier)
+		logger.Info("人机验证已启用",
+			logger.String("provider", cfg.CaptchaProvider),
+			logger.Int("loginFailures", cfg.CaptchaLoginFailures))
+	}
+
+	// 📧 初始化每日摘要邮件服务
+	digestService := digest.NewService(userRepo, trackRepo, roomRepo, socialRepo, notificationRepo, mail.NewSender(cfg), cfg)
+	digestService.Start()
+
 	// 🔥 初始化预热服务
 	logger.Info("初始化预热服务...")
 	// 创建网易云歌曲 URL 获取函数
@@ -144,26 +364,16 @@ func Start() {
 	// 使用 gorilla/mux 创建路由器
 	router := mux.NewRouter()
 
-	// 添加 CORS 中间件
-	router.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, HEAD")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range")
-			w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range")
-			w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
-
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
+	// 错误码目录
+	router.HandleFunc("/api/errors", apiHandler.ErrorCatalogHandler).Methods(http.MethodGet)
 
-			next.ServeHTTP(w, r)
-		})
-	})
+	// OpenAPI 文档和 Swagger UI，文档由路由表生成
+	openAPIHandler := NewOpenAPIHandler(router)
+	router.HandleFunc("/api/openapi.json", openAPIHandler.SpecHandler).Methods(http.MethodGet)
+	router.HandleFunc("/api/docs", openAPIHandler.SwaggerUIHandler).Methods(http.MethodGet)
 
 	// 网易云音乐相关的API端点
-	router.HandleFunc("/api/netease/search", neteaseHandler.HandleSearch).Methods(http.MethodGet)
+	router.HandleFunc("/api/netease/search", apiHandler.RateLimit("search", cfg.RateLimitSearch, neteaseHandler.HandleSearch)).Methods(http.MethodGet)
 	router.HandleFunc("/api/netease/song/detail", neteaseHandler.HandleSongDetail).Methods(http.MethodGet)
 	router.HandleFunc("/api/netease/song/dynamic/cover", neteaseHandler.HandleDynamicCover).Methods(http.MethodGet)
 	router.HandleFunc("/api/netease/lyric/new", neteaseHandler.HandleLyricNew).Methods(http.MethodGet)
@@ -171,20 +381,59 @@ func Start() {
 	router.HandleFunc("/api/netease/user/playlist", neteaseHandler.HandleUserPlaylists).Methods(http.MethodGet)
 	router.HandleFunc("/api/netease/get/userids", neteaseHandler.HandleGetUserIDs).Methods(http.MethodGet)
 	router.HandleFunc("/api/netease/playlist/detail", neteaseHandler.HandlePlaylistDetail).Methods(http.MethodGet)
+	router.HandleFunc("/api/netease/charts", neteaseHandler.HandleCharts).Methods(http.MethodGet)
+	router.HandleFunc("/api/netease/charts/{id:[0-9]+}", neteaseHandler.HandleChartTracks).Methods(http.MethodGet)
+	router.HandleFunc("/api/netease/new", neteaseHandler.HandleNewSongs).Methods(http.MethodGet)
+	router.HandleFunc("/api/netease/artists/{id:[0-9]+}/top", neteaseHandler.HandleArtistTopSongs).Methods(http.MethodGet)
 	router.HandleFunc("/api/netease/update/info", apiHandler.AuthMiddleware(neteaseHandler.HandleUpdateNeteaseInfo(userRepo))).Methods(http.MethodPost)
 
 	// API Endpoints
 	router.HandleFunc("/api/tracks", apiHandler.AuthMiddleware(apiHandler.GetTracksHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/artists/{name}", apiHandler.AuthMiddleware(apiHandler.ArtistPageHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/tracks/duplicates", apiHandler.AuthMiddleware(apiHandler.GetDuplicateTracksHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/tracks/batch", apiHandler.AuthMiddleware(apiHandler.BatchUpdateTracksHandler)).Methods(http.MethodPatch)
+	router.HandleFunc("/api/import/metadata", apiHandler.AuthMiddleware(apiHandler.ImportMetadataHandler)).Methods(http.MethodPost)
 	router.HandleFunc("/api/tracks/{id}", apiHandler.AuthMiddleware(apiHandler.DeleteTrackHandler)).Methods(http.MethodDelete)
-	router.HandleFunc("/api/upload", apiHandler.AuthMiddleware(apiHandler.UploadTrackHandler)).Methods(http.MethodPost)
-	router.HandleFunc("/api/upload/cover", apiHandler.AuthMiddleware(apiHandler.UploadCoverHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/tracks/{id}/license", apiHandler.AuthMiddleware(apiHandler.UpdateTrackLicenseHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/tracks/{id}/tags", apiHandler.AuthMiddleware(apiHandler.AddTrackTagsHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/tracks/{id}/tags/{tag}", apiHandler.AuthMiddleware(apiHandler.RemoveTrackTagHandler)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/tags", apiHandler.AuthMiddleware(apiHandler.GetTagsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/tracks/{id}/comments", apiHandler.AuthMiddleware(apiHandler.GetTrackCommentsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/tracks/{id}/comments", apiHandler.AuthMiddleware(apiHandler.AddTrackCommentHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/netease/songs/{id:[0-9]+}/comments", apiHandler.AuthMiddleware(apiHandler.GetNeteaseCommentsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/netease/songs/{id:[0-9]+}/comments", apiHandler.AuthMiddleware(apiHandler.AddNeteaseCommentHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/comments/{id}", apiHandler.AuthMiddleware(apiHandler.DeleteCommentHandler)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/trash", apiHandler.AuthMiddleware(apiHandler.GetTrashHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/trash/{id}/restore", apiHandler.AuthMiddleware(apiHandler.RestoreTrashHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/tracks/{id}/verify", apiHandler.AuthMiddleware(apiHandler.VerifyTrackHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/tracks/{id}/file", apiHandler.AuthMiddleware(apiHandler.RequireVerifiedEmail(apiHandler.RateLimit("upload", cfg.RateLimitUpload, apiHandler.ReplaceTrackFileHandler)))).Methods(http.MethodPut)
+	router.HandleFunc("/api/tracks/{id}/versions", apiHandler.AuthMiddleware(apiHandler.GetTrackVersionsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/tracks/{id}/versions/{versionId}/restore", apiHandler.AuthMiddleware(apiHandler.RestoreTrackVersionHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/tracks/{id}/waveform", apiHandler.AuthMiddleware(apiHandler.GetTrackWaveformHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/public/tracks/{id}", apiHandler.GetPublicTrackHandler).Methods(http.MethodGet)
+	router.HandleFunc("/api/upload", apiHandler.AuthMiddleware(apiHandler.RequireVerifiedEmail(apiHandler.RateLimit("upload", cfg.RateLimitUpload, apiHandler.Idempotent(apiHandler.UploadTrackHandler))))).Methods(http.MethodPost)
+	router.HandleFunc("/api/upload/cover", apiHandler.AuthMiddleware(apiHandler.RequireVerifiedEmail(apiHandler.RateLimit("upload", cfg.RateLimitUpload, apiHandler.UploadCoverHandler)))).Methods(http.MethodPost)
+	// 预签名直传/直读，大文件不经过 API 服务
+	router.HandleFunc("/api/upload/presign", apiHandler.AuthMiddleware(apiHandler.RequireVerifiedEmail(apiHandler.RateLimit("upload", cfg.RateLimitUpload, apiHandler.PresignUploadHandler)))).Methods(http.MethodPost)
+	router.HandleFunc("/api/upload/finalize", apiHandler.AuthMiddleware(apiHandler.RequireVerifiedEmail(apiHandler.Idempotent(apiHandler.FinalizeUploadHandler)))).Methods(http.MethodPost)
+	router.HandleFunc("/api/tracks/{id}/download-url", apiHandler.AuthMiddleware(apiHandler.PresignTrackDownloadHandler)).Methods(http.MethodGet)
+	// 单文件直接播放，登录或携带分享签名访问
+	router.HandleFunc("/api/tracks/{id}/raw", apiHandler.RawTrackHandler).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc("/api/tracks/{id}/raw-url", apiHandler.AuthMiddleware(apiHandler.RawTrackURLHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/tracks/{id}/presigned/playlist.m3u8", apiHandler.AuthMiddleware(apiHandler.PresignedPlaylistHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/streams/sign", apiHandler.AuthMiddleware(apiHandler.SignStreamURLHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/streams/{streamId}/key", apiHandler.AuthMiddleware(apiHandler.StreamKeyHandler)).Methods(http.MethodGet)
 	// router.HandleFunc("/streams/{track_id}/playlist.m3u8", apiHandler.StreamHandler).Methods(http.MethodGet)
 
 	router.HandleFunc("/ws/stream/{track_id}", apiHandler.WebSocketStreamHandler)
 
 	// 播放列表相关的API端点
-	router.HandleFunc("/api/playlist", apiHandler.AuthMiddleware(apiHandler.PlaylistHandler)).Methods(http.MethodGet, http.MethodPost, http.MethodDelete)
-	router.HandleFunc("/api/playlist/all", apiHandler.AuthMiddleware(apiHandler.AddAllTracksToPlaylistHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/playlist", apiHandler.AuthMiddleware(apiHandler.Idempotent(apiHandler.PlaylistHandler))).Methods(http.MethodGet, http.MethodPost, http.MethodDelete)
+	router.HandleFunc("/api/playlist/all", apiHandler.AuthMiddleware(apiHandler.Idempotent(apiHandler.AddAllTracksToPlaylistHandler))).Methods(http.MethodPost)
+	router.HandleFunc("/api/playback/heartbeat", apiHandler.AuthMiddleware(apiHandler.PlaybackHeartbeatHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/playback/state", apiHandler.AuthMiddleware(apiHandler.GetPlaybackStateHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/playback/mode", apiHandler.AuthMiddleware(apiHandler.SetPlaybackModeHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/playback/next", apiHandler.AuthMiddleware(apiHandler.GetNextTrackHandler)).Methods(http.MethodGet)
 
 	// 专辑相关的API端点
 	router.HandleFunc("/api/albums", apiHandler.AuthMiddleware(apiHandler.GetUserAlbumsHandler)).Methods(http.MethodGet)
@@ -194,16 +443,68 @@ func Start() {
 	router.HandleFunc("/api/albums/{id}", apiHandler.AuthMiddleware(apiHandler.UpdateAlbumHandler)).Methods(http.MethodPut)
 	router.HandleFunc("/api/albums/{id}", apiHandler.AuthMiddleware(apiHandler.DeleteAlbumHandler)).Methods(http.MethodDelete)
 	router.HandleFunc("/api/albums/{id}/tracks", apiHandler.AuthMiddleware(apiHandler.GetAlbumTracksHandler)).Methods(http.MethodGet)
-	router.HandleFunc("/api/albums/{id}/tracks", apiHandler.AuthMiddleware(apiHandler.AddTrackToAlbumHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/albums/{id}/enrichment", apiHandler.AuthMiddleware(apiHandler.GetAlbumEnrichmentHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/albums/{id}/enrichment", apiHandler.AuthMiddleware(apiHandler.ApplyAlbumEnrichmentHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/albums/{id}/download", apiHandler.AuthMiddleware(apiHandler.DownloadAlbumHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/albums/{id}/tracks", apiHandler.AuthMiddleware(apiHandler.Idempotent(apiHandler.AddTrackToAlbumHandler))).Methods(http.MethodPost)
 	router.HandleFunc("/api/albums/{id}/tracks/{track_id}", apiHandler.AuthMiddleware(apiHandler.RemoveTrackFromAlbumHandler)).Methods(http.MethodDelete)
 	router.HandleFunc("/api/albums/{id}/tracks/{track_id}/position", apiHandler.AuthMiddleware(apiHandler.UpdateTrackPositionHandler)).Methods(http.MethodPut)
-	router.HandleFunc("/api/albums/upload-tracks", apiHandler.AuthMiddleware(apiHandler.UploadTracksToAlbumHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/albums/upload-tracks", apiHandler.AuthMiddleware(apiHandler.RequireVerifiedEmail(apiHandler.RateLimit("upload", cfg.RateLimitUpload, apiHandler.Idempotent(apiHandler.UploadTracksToAlbumHandler))))).Methods(http.MethodPost)
 
 	// 用户认证相关的API端点
-	router.HandleFunc("/api/auth/login", apiHandler.LoginHandler).Methods(http.MethodPost)
-	router.HandleFunc("/api/auth/register", apiHandler.RegisterHandler).Methods(http.MethodPost)
+	router.HandleFunc("/.well-known/jwks.json", JWKSHandler).Methods(http.MethodGet)
+	router.HandleFunc("/api/auth/jwks.json", JWKSHandler).Methods(http.MethodGet)
+	router.HandleFunc("/api/auth/captcha", apiHandler.RateLimit("auth", cfg.RateLimitAuth, apiHandler.GetCaptchaHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/auth/login", apiHandler.RateLimit("auth", cfg.RateLimitAuth, apiHandler.LoginHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/register", apiHandler.RateLimit("auth", cfg.RateLimitAuth, apiHandler.RegisterHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/forgot-password", apiHandler.RateLimit("password_reset", cfg.RateLimitPasswordReset, apiHandler.ForgotPasswordHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/reset-password", apiHandler.RateLimit("password_reset", cfg.RateLimitPasswordReset, apiHandler.ResetPasswordHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/verify-email", apiHandler.VerifyEmailHandler).Methods(http.MethodGet)
+	router.HandleFunc("/api/auth/resend-verification", apiHandler.RateLimit("verification", cfg.RateLimitVerification, apiHandler.ResendVerificationHandler)).Methods(http.MethodPost)
 	router.HandleFunc("/api/user/profile", apiHandler.AuthMiddleware(userHandler.GetUserProfileHandler)).Methods(http.MethodGet)
 	router.HandleFunc("/api/user/profile", apiHandler.AuthMiddleware(userHandler.UpdateUserProfileHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/users/me/quota", apiHandler.AuthMiddleware(apiHandler.GetStorageQuotaHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/user/preferences/transcode", apiHandler.AuthMiddleware(apiHandler.GetTranscodePreferencesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/user/preferences/transcode", apiHandler.AuthMiddleware(apiHandler.UpdateTranscodePreferencesHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/user/preferences/digest", apiHandler.AuthMiddleware(apiHandler.GetDigestPreferencesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/user/preferences/digest", apiHandler.AuthMiddleware(apiHandler.UpdateDigestPreferencesHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/user/preferences/scrobble", apiHandler.AuthMiddleware(apiHandler.GetScrobblePreferencesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/user/preferences/scrobble", apiHandler.AuthMiddleware(apiHandler.UpdateScrobblePreferencesHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/user/preferences/social", apiHandler.AuthMiddleware(apiHandler.GetSocialPreferencesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/user/preferences/social", apiHandler.AuthMiddleware(apiHandler.UpdateSocialPreferencesHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/user/preferences/listening", apiHandler.AuthMiddleware(apiHandler.GetListeningPreferencesHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/user/preferences/listening", apiHandler.AuthMiddleware(apiHandler.UpdateListeningPreferencesHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/user/preferences/language", apiHandler.AuthMiddleware(apiHandler.GetLanguagePreferenceHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/user/preferences/language", apiHandler.AuthMiddleware(apiHandler.UpdateLanguagePreferenceHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/users/{id:[0-9]+}/follow", apiHandler.AuthMiddleware(apiHandler.GetFollowStatsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/users/{id:[0-9]+}/follow", apiHandler.AuthMiddleware(apiHandler.FollowUserHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/users/{id:[0-9]+}/follow", apiHandler.AuthMiddleware(apiHandler.UnfollowUserHandler)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/users/{id:[0-9]+}/followers", apiHandler.AuthMiddleware(apiHandler.GetFollowersHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/users/{id:[0-9]+}/following", apiHandler.AuthMiddleware(apiHandler.GetFollowingHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/feed", apiHandler.AuthMiddleware(apiHandler.GetFeedHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/notifications", apiHandler.AuthMiddleware(apiHandler.GetNotificationsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/notifications/unread-count", apiHandler.AuthMiddleware(apiHandler.GetUnreadNotificationCountHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/notifications/read-all", apiHandler.AuthMiddleware(apiHandler.MarkAllNotificationsReadHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/notifications/{id:[0-9]+}/read", apiHandler.AuthMiddleware(apiHandler.MarkNotificationReadHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/digest/unsubscribe", apiHandler.DigestUnsubscribePageHandler).Methods(http.MethodGet)
+	router.HandleFunc("/api/digest/unsubscribe", apiHandler.DigestUnsubscribeHandler).Methods(http.MethodPost)
+
+	// 管理接口
+	router.HandleFunc("/api/admin/storage/gc", apiHandler.AdminMiddleware(apiHandler.StorageGCHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/admin/storage/reconcile", apiHandler.AdminMiddleware(apiHandler.StorageReconcileHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/admin/reprocess", apiHandler.AdminMiddleware(apiHandler.ReprocessStatusHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/reprocess", apiHandler.AdminMiddleware(apiHandler.ReprocessHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/admin/backups", apiHandler.AdminMiddleware(apiHandler.BackupStatusHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/backups", apiHandler.AdminMiddleware(apiHandler.TriggerBackupHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/admin/cache/streams", apiHandler.AdminMiddleware(apiHandler.StreamCacheStatsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/netease/health", apiHandler.AdminMiddleware(apiHandler.NeteaseHealthHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/transcode/stats", apiHandler.AdminMiddleware(apiHandler.TranscodeStatsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/users/{id}/status", apiHandler.AdminMiddleware(apiHandler.AdminUpdateUserStatusHandler)).Methods(http.MethodPut)
+	router.HandleFunc("/api/admin/overview", apiHandler.AdminMiddleware(apiHandler.AdminOverviewHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/users/stats", apiHandler.AdminMiddleware(apiHandler.AdminUserStatsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/storage/usage", apiHandler.AdminMiddleware(apiHandler.AdminStorageUsageHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/rooms", apiHandler.AdminMiddleware(apiHandler.AdminRoomsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/admin/rooms/{room_id}/close", apiHandler.AdminMiddleware(apiHandler.AdminCloseRoomHandler)).Methods(http.MethodPost)
 	router.HandleFunc("/api/user/netease/update", apiHandler.AuthMiddleware(userHandler.UpdateNeteaseInfoHandler)).Methods(http.MethodPost)
 
 	// 🎉 公告相关的API端点 - 正式上线
@@ -216,21 +517,77 @@ func Start() {
 	logger.Info("注册AI聊天助手API端点...")
 	router.HandleFunc("/api/chat/history", apiHandler.AuthMiddleware(chatHandler.GetChatHistoryHandler)).Methods(http.MethodGet)
 	router.HandleFunc("/api/chat/clear", apiHandler.AuthMiddleware(chatHandler.ClearChatHistoryHandler)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/chat/sessions", apiHandler.AuthMiddleware(chatHandler.ListSessionsHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/chat/sessions", apiHandler.AuthMiddleware(chatHandler.CreateSessionHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/chat/sessions/{id:[0-9]+}", apiHandler.AuthMiddleware(chatHandler.UpdateSessionHandler)).Methods(http.MethodPatch)
+	router.HandleFunc("/api/chat/sessions/{id:[0-9]+}", apiHandler.AuthMiddleware(chatHandler.DeleteSessionHandler)).Methods(http.MethodDelete)
 	router.HandleFunc("/ws/chat", chatHandler.WebSocketChatHandler)
 	logger.Info("AI聊天助手API端点注册完成",
-		logger.String("endpoints", "GET /api/chat/history, DELETE /api/chat/clear, WS /ws/chat"))
+		logger.String("endpoints", "GET /api/chat/history, DELETE /api/chat/clear, /api/chat/sessions, WS /ws/chat"))
 
 	// 🏠 房间系统相关的API端点
 	logger.Info("注册房间系统API端点...")
 	RegisterRoomRoutes(router, roomHandler, apiHandler.AuthMiddleware)
 
+	// 📱 多设备播放控制相关的API端点
+	RegisterDeviceRoutes(router, deviceHandler, apiHandler.AuthMiddleware)
+
+	// 😴 睡眠定时相关的API端点
+	RegisterSleepTimerRoutes(router, sleepTimerHandler, apiHandler.AuthMiddleware)
+
+	// 🎼 听歌记录同步与播放历史相关的API端点
+	RegisterScrobbleRoutes(router, scrobbleHandler, apiHandler.AuthMiddleware)
+
+	// 📻 AI 电台相关的API端点
+	RegisterRadioRoutes(router, radioHandler, apiHandler.AuthMiddleware)
+
+	// 🎯 推荐相关的API端点
+	RegisterRecommendRoutes(router, recommendHandler, apiHandler.AuthMiddleware)
+
+	// 🎚️ Daily Mix 相关的API端点
+	RegisterDailyMixRoutes(router, dailyMixHandler, apiHandler.AuthMiddleware)
+	RegisterStreakRoutes(router, streakHandler, apiHandler.AuthMiddleware)
+
+	// 📈 热门歌曲相关的API端点
+	RegisterTrendingRoutes(router, trendingHandler, apiHandler.AuthMiddleware)
+
+	// 📶 用户流量统计相关的API端点
+	RegisterBandwidthRoutes(router, bandwidthHandler, apiHandler.AuthMiddleware, apiHandler.AdminMiddleware)
+
+	// 📺 投屏相关的API端点（仅在服务器与渲染器处于同一局域网时启用）
+	if cfg.CastEnabled {
+		RegisterCastRoutes(router, NewCastHandler(trackRepo, cfg), apiHandler.AuthMiddleware)
+	}
+
+	// 🎧 Subsonic 兼容接口，供现有的第三方客户端使用
+	if cfg.SubsonicEnabled {
+		RegisterSubsonicRoutes(router, NewSubsonicHandler(trackRepo, albumRepo, userRepo, apiHandler.loginThrottle, cfg))
+	}
+
 	// 🎵 流媒体服务路由
 	streamHandler := NewStreamHandler(streamProcessor, mp3Processor, cfg)
-	router.PathPrefix("/streams/").Handler(streamHandler)
+	mp3Processor.SetPrepareFunc(streamHandler.prepareNeteaseSong)
+	apiHandler.SetStreamHandler(streamHandler)
+	router.HandleFunc("/api/netease/{id:[0-9]+}/prepare", apiHandler.AuthMiddleware(streamHandler.PrepareHandler)).Methods(http.MethodPost)
+	// 转码进度事件与 /streams/ 一样无需登录
+	router.HandleFunc("/api/streams/netease/{id}/events", streamHandler.StreamEventsHandler(true)).Methods(http.MethodGet)
+	router.HandleFunc("/api/streams/{id}/events", streamHandler.StreamEventsHandler(false)).Methods(http.MethodGet)
+	router.PathPrefix("/streams/").Handler(bandwidthHandler.Meter(streamHandler))
+
+	// 内部 gRPC 服务
+	internalRPC, err := StartInternalRPC(cfg, trackRepo, userRepo, streamHandler, roomManager)
+	if err != nil {
+		logger.Fatal("内部 gRPC 服务启动失败", logger.ErrorField(err))
+	}
 
-	// 📦 MinIO 静态文件服务路由
+	// 📦 对象存储静态文件服务路由
 	staticHandler := NewStaticHandler(cfg)
-	router.PathPrefix("/static/").Handler(staticHandler)
+	router.PathPrefix("/static/").Handler(bandwidthHandler.Meter(staticHandler))
+
+	// 本地存储后端的预签名地址
+	if local, ok := storage.GetStorage().(*storage.LocalStorage); ok {
+		router.PathPrefix(storage.LocalPresignPrefix).Handler(NewLocalStorageHandler(local))
+	}
 
 	// Static file serving
 	uploadsFileServer := http.FileServer(http.Dir(cfg.UploadDir))
@@ -240,7 +597,13 @@ func Start() {
 	uiFileServer := http.FileServer(http.Dir(cfg.WebAppDir))
 	router.PathPrefix("/").Handler(uiFileServer)
 
-	server.Handler = router
+	// 访问日志包在最外层，未匹配路由的请求同样会分配请求 ID 并记录，记录的字节数为压缩后的大小；
+	// CORS 包在路由器外，预检请求不受路由方法限制
+	var handler http.Handler = SecurityHeadersMiddleware(LanguageMiddleware(CORSMiddleware(router, cfg)), cfg)
+	if cfg.HTTPCompressionEnabled {
+		handler = CompressionMiddleware(handler, cfg.HTTPCompressionMinBytes)
+	}
+	server.Handler = AccessLogMiddleware(handler, trustedProxies(cfg))
 
 	// 创建一个通道来接收操作系统信号
 	stop := make(chan os.Signal, 1)
@@ -286,6 +649,60 @@ func Start() {
 	preheatService.Stop()
 	logger.Info("预热服务已停止")
 
+	// 停止每日摘要服务
+	digestService.Stop()
+
+	// 停止签名密钥轮换
+	keyRotation.Stop()
+
+	// 停止听歌记录重试
+	scrobbleService.Stop()
+
+	// 停止封面获取服务
+	coverFetcher.Stop()
+
+	// 停止存储回收服务
+	storageGC.Stop()
+
+	// 停止存储对账服务
+	reconciler.Stop()
+	trackVerifier.Stop()
+
+	// 停止回收站清理服务
+	trashPurger.Stop()
+
+	// 停止监视目录
+	watchFolder.Stop()
+
+	// 停止备份服务
+	backupService.Stop()
+
+	// 停止推荐刷新
+	recommendService.Stop()
+
+	// 停止 Daily Mix 生成
+	dailyMixService.Stop()
+
+	// 停止播放次数汇总
+	trendingService.Stop()
+
+	// 停止用户流量汇总
+	bandwidthService.Stop()
+
+	// 停止收听时长汇总
+	streakService.Stop()
+
+	// 停止公告定时任务
+	announcementScheduler.Stop()
+
+	// 停止睡眠定时任务
+	sleepTimerScheduler.Stop()
+
+	// 停止内部 gRPC 服务
+	if internalRPC != nil {
+		internalRPC.GracefulStop()
+	}
+
 	// 停止房间 Hub
 	roomHub.Stop()
 	logger.Info("房间系统已停止")
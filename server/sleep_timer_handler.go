@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/sleeptimer"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// SleepTimerHandler 睡眠定时处理器
+type SleepTimerHandler struct {
+	scheduler *sleeptimer.Scheduler
+}
+
+// NewSleepTimerHandler 创建睡眠定时处理器
+func NewSleepTimerHandler(scheduler *sleeptimer.Scheduler) *SleepTimerHandler {
+	return &SleepTimerHandler{scheduler: scheduler}
+}
+
+// SetSleepTimerHandler 设置睡眠定时，POST /api/playback/sleep-timer
+// 请求体 {"minutes": 30, "deviceId": "..."}，已有的定时被替换；到期时服务端让用户的所有在线设备暂停
+func (h *SleepTimerHandler) SetSleepTimerHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Minutes  int    `json:"minutes"`
+		DeviceID string `json:"deviceId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if req.Minutes <= 0 || req.Minutes > sleeptimer.MaxMinutes {
+		writeError(w, CodeBadRequest, "Minutes must be between 1 and 720")
+		return
+	}
+	deviceID := strings.TrimSpace(req.DeviceID)
+	if len(deviceID) > maxDeviceIDLength {
+		deviceID = deviceID[:maxDeviceIDLength]
+	}
+
+	timer, err := h.scheduler.Set(r.Context(), userID, req.Minutes, deviceID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("设置睡眠定时失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to set sleep timer")
+		return
+	}
+	logger.Ctx(r.Context()).Info("睡眠定时已设置",
+		logger.Int64("userId", userID),
+		logger.Int("minutes", req.Minutes))
+	writeSleepTimer(w, timer)
+}
+
+// GetSleepTimerHandler 返回当前的睡眠定时，GET /api/playback/sleep-timer，没有定时时 data 为 null
+func (h *SleepTimerHandler) GetSleepTimerHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	timer, err := h.scheduler.Get(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取睡眠定时失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to get sleep timer")
+		return
+	}
+	writeSleepTimer(w, timer)
+}
+
+// CancelSleepTimerHandler 取消睡眠定时，DELETE /api/playback/sleep-timer
+func (h *SleepTimerHandler) CancelSleepTimerHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	cancelled, err := h.scheduler.Cancel(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("取消睡眠定时失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		writeError(w, CodeServiceUnavailable, "Failed to cancel sleep timer")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"cancelled": cancelled,
+		},
+	})
+}
+
+// writeSleepTimer 返回睡眠定时和剩余秒数，没有定时时 data 为 null
+func writeSleepTimer(w http.ResponseWriter, timer *cache.SleepTimer) {
+	var data interface{}
+	if timer != nil {
+		remaining := time.Until(time.UnixMilli(timer.EndsAt))
+		data = map[string]interface{}{
+			"minutes":          timer.Minutes,
+			"endsAt":           timer.EndsAt,
+			"remainingSeconds": int64(max(remaining, 0) / time.Second),
+			"deviceId":         timer.DeviceID,
+			"createdAt":        timer.CreatedAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// RegisterSleepTimerRoutes 注册睡眠定时路由
+func RegisterSleepTimerRoutes(router *mux.Router, handler *SleepTimerHandler, authMiddleware func(http.HandlerFunc) http.HandlerFunc) {
+	router.HandleFunc("/api/playback/sleep-timer", authMiddleware(handler.SetSleepTimerHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/playback/sleep-timer", authMiddleware(handler.GetSleepTimerHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/api/playback/sleep-timer", authMiddleware(handler.CancelSleepTimerHandler)).Methods(http.MethodDelete)
+
+	logger.Info("睡眠定时API端点注册完成",
+		logger.String("endpoints", "POST/GET/DELETE /api/playback/sleep-timer"))
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"Bt1QFM/core/social"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultSocialLimit 关注列表和动态流默认每页的数量
+const defaultSocialLimit = 20
+
+// SetSocialService 设置关注和动态服务，未设置时相关接口返回 503
+func (h *APIHandler) SetSocialService(service *social.Service) {
+	h.socialService = service
+}
+
+// FollowUserHandler 关注用户，POST /api/users/{id}/follow
+func (h *APIHandler) FollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := h.socialRequest(w, r)
+	if !ok {
+		return
+	}
+
+	switch err := h.socialService.Follow(r.Context(), userID, targetID); err {
+	case nil:
+	case social.ErrFollowSelf:
+		writeError(w, CodeBadRequest, "Cannot follow yourself")
+		return
+	case social.ErrUserNotFound:
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	default:
+		logger.Ctx(r.Context()).Error("关注用户失败", logger.Int64("targetId", targetID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to follow user")
+		return
+	}
+
+	h.writeFollowStats(w, r, userID, targetID)
+}
+
+// UnfollowUserHandler 取消关注用户，DELETE /api/users/{id}/follow
+func (h *APIHandler) UnfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := h.socialRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.socialService.Unfollow(r.Context(), userID, targetID); err != nil {
+		logger.Ctx(r.Context()).Error("取消关注失败", logger.Int64("targetId", targetID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to unfollow user")
+		return
+	}
+
+	h.writeFollowStats(w, r, userID, targetID)
+}
+
+// GetFollowStatsHandler 获取用户的关注数、粉丝数以及当前用户是否已关注，GET /api/users/{id}/follow
+func (h *APIHandler) GetFollowStatsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := h.socialRequest(w, r)
+	if !ok {
+		return
+	}
+	h.writeFollowStats(w, r, userID, targetID)
+}
+
+// GetFollowersHandler 获取关注该用户的用户列表，GET /api/users/{id}/followers?limit=20&offset=0
+func (h *APIHandler) GetFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeFollowUsers(w, r, h.socialService.Followers)
+}
+
+// GetFollowingHandler 获取该用户关注的用户列表，GET /api/users/{id}/following?limit=20&offset=0
+func (h *APIHandler) GetFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeFollowUsers(w, r, h.socialService.Following)
+}
+
+// GetFeedHandler 获取当前用户关注的人的动态，GET /api/feed?limit=20&before=<上一页返回的 next>
+func (h *APIHandler) GetFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if h.socialService == nil {
+		writeError(w, CodeServiceUnavailable, "Social service is not available")
+		return
+	}
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, _, ok := parseSocialPage(w, r)
+	if !ok {
+		return
+	}
+	var before int64
+	if raw := r.URL.Query().Get("before"); raw != "" {
+		if before, err = strconv.ParseInt(raw, 10, 64); err != nil || before < 0 {
+			writeError(w, CodeBadRequest, "Invalid before")
+			return
+		}
+	}
+
+	items, next, err := h.socialService.Feed(r.Context(), userID, before, limit)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取动态流失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get feed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items": items,
+		"next":  next,
+	})
+}
+
+// GetSocialPreferencesHandler 获取当前用户的动态隐私设置
+func (h *APIHandler) GetSocialPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.userRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		logger.Error("获取用户信息失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get preferences")
+		return
+	}
+	if user == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    user.GetPreferences().Social,
+	})
+}
+
+// UpdateSocialPreferencesHandler 更新当前用户的动态隐私设置，对已有动态同样生效
+func (h *APIHandler) UpdateSocialPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int64)
+	if !ok {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req model.SocialPreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	prev, err := h.updatePreferences(r.Context(), userID, func(prefs *model.UserPreferences) {
+		prefs.Social = req
+	})
+	if err != nil {
+		writeError(w, CodeInternal, "Failed to update preferences")
+		return
+	}
+	if prev == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	logger.Info("用户动态隐私设置已更新",
+		logger.Int64("userId", userID),
+		logger.Bool("hideActivity", req.HideActivity),
+		logger.Bool("hideRooms", req.HideRooms),
+		logger.Bool("hideComments", req.HideComments),
+		logger.Bool("hidePlaylists", req.HidePlaylists))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    req,
+	})
+}
+
+// socialRequest 读取当前用户和路径中的目标用户ID，失败时已写入响应
+func (h *APIHandler) socialRequest(w http.ResponseWriter, r *http.Request) (userID, targetID int64, ok bool) {
+	if h.socialService == nil {
+		writeError(w, CodeServiceUnavailable, "Social service is not available")
+		return 0, 0, false
+	}
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return 0, 0, false
+	}
+	targetID, err = strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid user ID")
+		return 0, 0, false
+	}
+	return userID, targetID, true
+}
+
+// writeFollowStats 返回目标用户的关注数、粉丝数以及当前用户是否已关注
+func (h *APIHandler) writeFollowStats(w http.ResponseWriter, r *http.Request, userID, targetID int64) {
+	stats, err := h.socialService.Stats(r.Context(), userID, targetID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取关注统计失败", logger.Int64("targetId", targetID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get follow stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// writeFollowUsers 按分页参数返回关注列表或粉丝列表
+func (h *APIHandler) writeFollowUsers(w http.ResponseWriter, r *http.Request,
+	list func(ctx context.Context, userID int64, limit, offset int) ([]*model.FollowUser, error)) {
+	_, targetID, ok := h.socialRequest(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, ok := parseSocialPage(w, r)
+	if !ok {
+		return
+	}
+
+	users, err := list(r.Context(), targetID, limit, offset)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取关注列表失败", logger.Int64("targetId", targetID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get users")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":  users,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// parseSocialPage 解析 limit 和 offset 查询参数，失败时已写入响应
+func parseSocialPage(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	limit = defaultSocialLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, CodeBadRequest, "Invalid limit")
+			return 0, 0, false
+		}
+		limit = min(n, social.MaxLimit)
+	}
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeError(w, CodeBadRequest, "Invalid offset")
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
+}
@@ -1,20 +1,22 @@
 package server
 
 import (
+	"bufio"
 	"context"
 	"io"
+	"mime"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"Bt1QFM/config"
 	"Bt1QFM/logger"
 	"Bt1QFM/storage"
-
-	"github.com/minio/minio-go/v7"
 )
 
-// StaticHandler 处理 MinIO 静态文件请求
+// StaticHandler 处理对象存储静态文件请求
 type StaticHandler struct {
 	cfg *config.Config
 }
@@ -28,40 +30,54 @@ func NewStaticHandler(cfg *config.Config) *StaticHandler {
 func (h *StaticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	objectPath := strings.TrimPrefix(r.URL.Path, "/static/")
 
-	client := storage.GetMinioClient()
-	if client == nil {
-		http.Error(w, "MinIO client not available", http.StatusInternalServerError)
+	// 开启播放签名后流文件只能通过 /streams/ 访问，避免绕过签名校验
+	if h.cfg.StreamSignedURLs && strings.HasPrefix(objectPath, "streams/") {
+		writeError(w, CodeForbidden, "Forbidden")
+		return
+	}
+
+	store := storage.GetStorage()
+	if store == nil {
+		writeError(w, CodeStorageUnavailable, "Storage not available")
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	object, err := client.GetObject(ctx, h.cfg.MinioBucket, objectPath, minio.GetObjectOptions{})
+	object, err := store.Get(ctx, objectPath)
 	if err != nil {
-		http.Error(w, "File not found", http.StatusNotFound)
+		if !storage.IsNotFound(err) {
+			logger.Error("Error reading file from storage", logger.ErrorField(err))
+		}
+		writeError(w, CodeNotFound, "File not found")
 		return
 	}
 	defer object.Close()
 
-	contentType := detectContentType(objectPath)
-	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Cache-Control", "public, max-age=31536000")
+	// 内容类型优先按扩展名确定，未知扩展名时根据文件开头的内容推断
+	var body io.Reader = object
+	contentType, ok := contentTypeByExt(objectPath)
+	if !ok {
+		buffered := bufio.NewReaderSize(object, contentTypeSniffLen)
+		head, _ := buffered.Peek(contentTypeSniffLen)
+		contentType = sniffContentType(head)
+		body = buffered
+	}
 
-	if _, err := io.Copy(w, object); err != nil {
-		logger.Error("Error serving file from MinIO", logger.ErrorField(err))
+	// download=true 时作为附件下载；可能执行脚本的类型总是作为附件，避免在本站域名下打开
+	disposition := "inline"
+	if download, _ := strconv.ParseBool(r.URL.Query().Get("download")); download || isActiveContent(contentType) {
+		disposition = "attachment"
 	}
-}
+	if isActiveContent(contentType) {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": path.Base(objectPath)}))
+	w.Header().Set("Cache-Control", "public, max-age=31536000")
 
-// detectContentType 根据路径前缀检测内容类型
-func detectContentType(path string) string {
-	switch {
-	case strings.HasPrefix(path, "covers/"):
-		return "image/jpeg"
-	case strings.HasPrefix(path, "audio/"):
-		return "audio/mpeg"
-	default:
-		return "application/octet-stream"
+	if _, err := io.Copy(w, body); err != nil {
+		logger.Error("Error serving file from storage", logger.ErrorField(err))
 	}
 }
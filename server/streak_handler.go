@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"Bt1QFM/core/streak"
+	"Bt1QFM/logger"
+	"Bt1QFM/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// StreakHandler 每日收听目标处理器
+type StreakHandler struct {
+	service  *streak.Service
+	userRepo repository.UserRepository
+}
+
+// NewStreakHandler 创建每日收听目标处理器
+func NewStreakHandler(service *streak.Service, userRepo repository.UserRepository) *StreakHandler {
+	return &StreakHandler{service: service, userRepo: userRepo}
+}
+
+// GetStreakHandler 返回每日收听目标、今天的进度、连续达标天数和最近 30 天的收听时长，GET /api/stats/streak
+func (h *StreakHandler) GetStreakHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.userRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取用户信息失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get listening streak")
+		return
+	}
+	if user == nil {
+		writeError(w, CodeUserNotFound, "User not found")
+		return
+	}
+
+	result, err := h.service.Streak(r.Context(), user, time.Now())
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取连续收听天数失败", logger.Int64("userId", userID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get listening streak")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// RegisterStreakRoutes 注册每日收听目标路由
+func RegisterStreakRoutes(router *mux.Router, handler *StreakHandler, authMiddleware func(http.HandlerFunc) http.HandlerFunc) {
+	router.HandleFunc("/api/stats/streak", authMiddleware(handler.GetStreakHandler)).Methods(http.MethodGet)
+
+	logger.Info("每日收听目标API端点注册完成",
+		logger.String("endpoints", "GET /api/stats/streak"))
+}
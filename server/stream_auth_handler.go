@@ -0,0 +1,294 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// streamDir 返回请求所在的流目录，播放签名按目录签发，目录下的播放列表和分片共用
+func (req *streamRequest) streamDir() string {
+	if req.isNetease {
+		return "/streams/netease/" + req.streamID + "/"
+	}
+	return "/streams/" + req.streamID + "/"
+}
+
+// signStreamQuery 为流目录生成签名查询参数
+func signStreamQuery(streamDir string, expires int64) string {
+	return fmt.Sprintf("expires=%d&signature=%s", expires, auth.SignStreamPath(streamDir, expires))
+}
+
+// verifyStreamRequest 校验请求携带的播放签名，返回签名的过期时间及是否有效
+func verifyStreamRequest(r *http.Request, req *streamRequest) (int64, bool) {
+	signature := r.URL.Query().Get("signature")
+	if signature == "" {
+		return 0, false
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return expires, auth.VerifyStreamSignature(req.streamDir(), expires, signature)
+}
+
+// canAccessStream 检查用户能否播放曲库中的流：拥有引用该流的曲目、携带其中一首曲目有效的分享签名
+// （trackId、expires、signature，与 /api/tracks/{id}/raw-url 签发的相同），或该曲目在用户所在房间的歌单中；
+// inLibrary 表示是否有曲目引用该流，没有时可能是网易云歌曲的流
+func (h *APIHandler) canAccessStream(ctx context.Context, r *http.Request, userID int64, streamID string) (allowed, inLibrary bool, err error) {
+	tracks, err := h.trackRepo.GetTracksByHLSPlaylistPath(ctx, streamPlaylistPath(streamID))
+	if err != nil {
+		return false, false, err
+	}
+	if len(tracks) == 0 {
+		return false, false, nil
+	}
+
+	trackIDs := make(map[int64]bool, len(tracks))
+	for _, track := range tracks {
+		if track.UserID == userID {
+			return true, true, nil
+		}
+		trackIDs[track.ID] = true
+	}
+
+	query := r.URL.Query()
+	if signature := query.Get("signature"); signature != "" {
+		trackID, _ := strconv.ParseInt(query.Get("trackId"), 10, 64)
+		expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+		if trackIDs[trackID] && auth.VerifyStreamSignature(rawTrackPath(trackID), expires, signature) {
+			return true, true, nil
+		}
+	}
+
+	if h.roomManager == nil {
+		return false, true, nil
+	}
+	rooms, err := h.roomManager.GetUserRooms(ctx, userID)
+	if err != nil {
+		return false, true, err
+	}
+	for _, info := range rooms {
+		playlist, err := h.roomManager.GetPlaylist(ctx, info.ID)
+		if err != nil {
+			logger.Ctx(ctx).Warn("获取房间歌单失败", logger.String("roomId", info.ID), logger.ErrorField(err))
+			continue
+		}
+		for _, item := range playlist {
+			if item.Normalize() && item.Source == cache.SourceLocal && trackIDs[item.TrackID] {
+				return true, true, nil
+			}
+		}
+	}
+	return false, true, nil
+}
+
+// isNeteaseStream 流 ID 是否对应已转码的网易云歌曲，网易云的歌曲登录即可播放
+func (h *APIHandler) isNeteaseStream(streamID string) bool {
+	if id, err := strconv.ParseInt(streamID, 10, 64); err != nil || id <= 0 {
+		return false
+	}
+	_, _, err := h.streamProcessor.StreamGet(streamID, "playlist.m3u8", true)
+	return err == nil
+}
+
+// StreamKeyHandler 下发 HLS 分片的 AES-128 密钥，只下发给可以播放该流的用户
+func (h *APIHandler) StreamKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	streamID := mux.Vars(r)["streamId"]
+	if streamID == "" {
+		writeError(w, CodeInvalidID, "Invalid stream ID")
+		return
+	}
+
+	// 曲库的流与网易云的流共用密钥地址，没有曲目引用时才按网易云歌曲处理
+	allowed, inLibrary, err := h.canAccessStream(r.Context(), r, userID, streamID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("检查流访问权限失败", logger.String("streamId", streamID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to check stream access")
+		return
+	}
+	if !allowed && (inLibrary || !h.isNeteaseStream(streamID)) {
+		logger.Ctx(r.Context()).Warn("拒绝下发流密钥", logger.Int64("userId", userID), logger.String("streamId", streamID))
+		writeError(w, CodeForbidden, "You do not have access to this stream")
+		return
+	}
+
+	logger.Debug("下发流密钥",
+		logger.Int64("userId", userID),
+		logger.String("streamId", streamID))
+
+	key := audio.NewHLSEncryption(streamID).Key
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(key)
+}
+
+// SignStreamURLHandler 为 /streams/ 下的播放列表签发带过期时间的地址，曲库中的流只签发给可以播放的用户
+func (h *APIHandler) SignStreamURLHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	streamPath := r.URL.Query().Get("path")
+	if !strings.HasPrefix(streamPath, "/streams/") || strings.Contains(streamPath, "..") {
+		writeError(w, CodeBadRequest, "Invalid stream path")
+		return
+	}
+	req, err := parseStreamPath(streamPath)
+	if err != nil {
+		writeError(w, CodeBadRequest, "Invalid stream path")
+		return
+	}
+	if !req.isNetease {
+		allowed, _, err := h.canAccessStream(r.Context(), r, userID, req.streamID)
+		if err != nil {
+			logger.Ctx(r.Context()).Error("检查流访问权限失败", logger.String("streamId", req.streamID), logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to check stream access")
+			return
+		}
+		if !allowed {
+			logger.Ctx(r.Context()).Warn("拒绝签发播放地址", logger.Int64("userId", userID), logger.String("streamId", req.streamID))
+			writeError(w, CodeForbidden, "You do not have access to this stream")
+			return
+		}
+	}
+
+	ttl := time.Duration(h.cfg.StreamURLTTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = 6 * time.Hour
+	}
+	expires := time.Now().Add(ttl).Unix()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"url":       req.streamDir() + req.fileName + "?" + signStreamQuery(req.streamDir(), expires),
+		"expiresAt": expires,
+		"encrypted": h.cfg.HLSEncryption,
+	})
+}
+
+// LocalStreamURL 按曲目记录中的播放列表路径签发房间内播放用的地址，供房间服务端切歌使用
+// 房间成员通过歌单即可播放，签名让不在房间鉴权上下文中的播放器同样能拉取
+func (h *APIHandler) LocalStreamURL(ctx context.Context, trackID int64) (string, error) {
+	track, err := h.trackRepo.GetTrackByID(ctx, trackID)
+	if err != nil {
+		return "", err
+	}
+	if track == nil || track.State == 0 || track.HLSPlaylistPath == "" {
+		return "", fmt.Errorf("曲目 %d 没有可播放的流", trackID)
+	}
+
+	ttl := time.Duration(h.cfg.StreamURLTTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = 6 * time.Hour
+	}
+	expires := time.Now().Add(ttl).Unix()
+	streamDir := path.Dir(track.HLSPlaylistPath) + "/"
+	return track.HLSPlaylistPath + "?" + signStreamQuery(streamDir, expires), nil
+}
+
+// signedPlaylistWriter 缓存播放列表响应，写出时为其中的分片地址追加签名参数
+// 开启签名校验后分片请求同样需要签名，播放器只需拿到签名后的播放列表地址即可播放
+type signedPlaylistWriter struct {
+	http.ResponseWriter
+	query   string
+	expires int64
+	status  int
+	buf     bytes.Buffer
+}
+
+// WriteHeader 延迟到 flush 时写出状态码
+func (sw *signedPlaylistWriter) WriteHeader(status int) {
+	sw.status = status
+}
+
+// Write 缓存响应内容
+func (sw *signedPlaylistWriter) Write(p []byte) (int, error) {
+	return sw.buf.Write(p)
+}
+
+// flush 改写播放列表并写出响应
+func (sw *signedPlaylistWriter) flush() {
+	status := sw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	body := sw.buf.Bytes()
+	if status == http.StatusOK && strings.Contains(sw.Header().Get("Content-Type"), "mpegurl") {
+		body = []byte(appendSegmentQuery(string(body), sw.query))
+		// 签名过期后分片地址失效，缓存时间不能超过签名有效期
+		if strings.HasPrefix(sw.Header().Get("Cache-Control"), "public") {
+			maxAge := sw.expires - time.Now().Unix()
+			if maxAge < 0 {
+				maxAge = 0
+			}
+			sw.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+		}
+	}
+	sw.ResponseWriter.WriteHeader(status)
+	if _, err := sw.ResponseWriter.Write(body); err != nil {
+		logger.Error("写入响应失败", logger.ErrorField(err))
+	}
+}
+
+// appendSegmentQuery 为播放列表中的分片地址追加查询参数
+// fMP4 初始化分片的 #EXT-X-MAP 地址同样追加，其他注释和标签行保持不变
+func appendSegmentQuery(playlist, query string) string {
+	lines := strings.Split(playlist, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#EXT-X-MAP:") {
+			lines[i] = appendMapURIQuery(trimmed, query)
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		sep := "?"
+		if strings.Contains(trimmed, "?") {
+			sep = "&"
+		}
+		lines[i] = trimmed + sep + query
+	}
+	return strings.Join(lines, "\n")
+}
+
+// appendMapURIQuery 为 #EXT-X-MAP 标签的 URI 属性追加查询参数
+func appendMapURIQuery(tag, query string) string {
+	start := strings.Index(tag, `URI="`)
+	if start < 0 {
+		return tag
+	}
+	start += len(`URI="`)
+	end := strings.IndexByte(tag[start:], '"')
+	if end < 0 {
+		return tag
+	}
+	uri := tag[start : start+end]
+	sep := "?"
+	if strings.Contains(uri, "?") {
+		sep = "&"
+	}
+	return tag[:start] + uri + sep + query + tag[start+end:]
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"Bt1QFM/core/audio"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// streamEventsInterval 检查转码进度的间隔
+	streamEventsInterval = 500 * time.Millisecond
+	// streamEventsHeartbeat 进度没有变化时发送心跳注释的间隔，防止代理断开空闲连接
+	streamEventsHeartbeat = 15 * time.Second
+	// streamEventsMaxDuration 单个事件流的最长时间，超时后由客户端重连
+	streamEventsMaxDuration = 10 * time.Minute
+)
+
+// 转码进度事件的状态，queued/processing/failed/idle 与预处理队列一致
+const (
+	streamEventDownloading = "downloading" // 正在下载源文件（网易云歌曲）
+	streamEventTranscoding = "transcoding" // 正在转码，percent 为估算进度
+)
+
+// streamProgressEvent 转码进度事件
+type streamProgressEvent struct {
+	StreamID string `json:"streamId"`
+	State    string `json:"state"`
+	Percent  int    `json:"percent"`            // 0-100，未知时为 0
+	Segments int    `json:"segments"`           // 已可播放的分片数
+	Playable bool   `json:"playable"`           // 已有足够分片，可以请求播放列表开始播放
+	Position int    `json:"position,omitempty"` // 排队位置，从 1 开始
+	Error    string `json:"error,omitempty"`
+}
+
+// terminal 是否为终止状态，终止后关闭事件流
+func (e streamProgressEvent) terminal() bool {
+	return e.State == prepareStateReady || e.State == audio.PrepareStateFailed
+}
+
+// StreamEventsHandler 以 SSE 推送转码进度
+// GET /api/streams/{id}/events 和 GET /api/streams/netease/{id}/events
+// 进度变化时发送 progress 事件，进入 ready 或 failed 后关闭；playable 为 true 时即可开始播放
+func (h *StreamHandler) StreamEventsHandler(isNetease bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamID := mux.Vars(r)["id"]
+		if streamID == "" {
+			writeError(w, CodeMissingField, "Stream ID is required")
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Now().Add(streamEventsMaxDuration)); err != nil {
+			logger.Ctx(r.Context()).Debug("无法延长写入超时", logger.ErrorField(err))
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		// 客户端通常在转码开始前后打开事件流，首次检查对象存储确认是否已转码完成
+		last := h.streamProgress(streamID, isNetease, true)
+		if err := writeStreamEvent(w, rc, last); err != nil || last.terminal() {
+			return
+		}
+
+		ticker := time.NewTicker(streamEventsInterval)
+		defer ticker.Stop()
+		deadline := time.NewTimer(streamEventsMaxDuration - streamEventsInterval)
+		defer deadline.Stop()
+		lastSent := time.Now()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-deadline.C:
+				return
+			case <-ticker.C:
+			}
+
+			// 刚离开处理中状态时内存中已无进度，再检查一次对象存储区分完成和失败
+			active := last.State == streamEventDownloading || last.State == streamEventTranscoding || last.State == audio.PrepareStateQueued
+			current := h.streamProgress(streamID, isNetease, false)
+			if active && current.State == audio.PrepareStateIdle {
+				current = h.streamProgress(streamID, isNetease, true)
+			}
+
+			if current != last {
+				if err := writeStreamEvent(w, rc, current); err != nil || current.terminal() {
+					return
+				}
+				last = current
+				lastSent = time.Now()
+				continue
+			}
+			if time.Since(lastSent) >= streamEventsHeartbeat {
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil || rc.Flush() != nil {
+					return
+				}
+				lastSent = time.Now()
+			}
+		}
+	}
+}
+
+// streamProgress 汇总渐进式 HLS、处理状态和预处理队列得到当前进度
+// checkStored 为 true 时内存中没有进度的流会检查对象存储中是否已有播放列表
+func (h *StreamHandler) streamProgress(streamID string, isNetease, checkStored bool) streamProgressEvent {
+	event := streamProgressEvent{StreamID: streamID, State: audio.PrepareStateIdle}
+
+	if hlsState := audio.GetProgressiveHLSManager().GetState(streamID); hlsState != nil {
+		event.Segments = hlsState.GetCompletedSegmentCount()
+		event.Playable = hlsState.HasEnoughSegmentsToPlay()
+		if !hlsState.IsProcessing() {
+			event.State = prepareStateReady
+			event.Percent = 100
+			return event
+		}
+		event.State = streamEventTranscoding
+		event.Percent = int(math.Floor(hlsState.Progress() * 100))
+		return event
+	}
+
+	if state := h.streamProcessor.GetProcessingState(streamID); state != nil {
+		switch {
+		case state.IsProcessing:
+			event.State = streamEventTranscoding
+			event.Percent = int(math.Floor(state.Progress * 100))
+			return event
+		case state.Error != nil:
+			event.State = audio.PrepareStateFailed
+			event.Error = state.Error.Error()
+			return event
+		}
+	}
+
+	if isNetease {
+		status := h.mp3Processor.PrepareStatus(streamID)
+		switch status.State {
+		case audio.PrepareStateProcessing:
+			event.State = streamEventDownloading
+			return event
+		case audio.PrepareStateQueued:
+			event.State = audio.PrepareStateQueued
+			event.Position = status.Position
+			return event
+		}
+		if checkStored && h.isStreamReady(streamID) {
+			return readyStreamEvent(streamID)
+		}
+		if status.State == audio.PrepareStateFailed {
+			event.State = audio.PrepareStateFailed
+			event.Error = status.Error
+		}
+		return event
+	}
+
+	if checkStored {
+		if _, _, err := h.streamProcessor.StreamGet(streamID, "playlist.m3u8", false); err == nil {
+			return readyStreamEvent(streamID)
+		}
+	}
+	return event
+}
+
+// readyStreamEvent 已转码完成的流
+func readyStreamEvent(streamID string) streamProgressEvent {
+	return streamProgressEvent{StreamID: streamID, State: prepareStateReady, Percent: 100, Playable: true}
+}
+
+// writeStreamEvent 发送一条 progress 事件
+func writeStreamEvent(w http.ResponseWriter, rc *http.ResponseController, event streamProgressEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
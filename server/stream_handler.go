@@ -1,11 +1,13 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -66,12 +68,28 @@ func parseStreamPath(urlPath string) (*streamRequest, error) {
 func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	req, err := parseStreamPath(r.URL.Path)
 	if err != nil {
-		http.Error(w, "Invalid stream path", http.StatusBadRequest)
+		writeError(w, CodeBadRequest, "Invalid stream path")
 		return
 	}
 
+	// 校验播放签名，携带有效签名的播放列表会为其中的分片地址追加同一签名
+	expires, signed := verifyStreamRequest(r, req)
+	if h.cfg.StreamSignedURLs && !signed {
+		writeError(w, CodeInvalidSignature, "Invalid or expired stream signature")
+		return
+	}
+	if signed && req.fileName == "playlist.m3u8" {
+		sw := &signedPlaylistWriter{
+			ResponseWriter: w,
+			query:          signStreamQuery(req.streamDir(), expires),
+			expires:        expires,
+		}
+		defer sw.flush()
+		w = sw
+	}
+
 	// 正在处理中的歌曲
-	if h.mp3Processor.IsProcessing(req.streamID) {
+	if h.mp3Processor.IsProcessing(req.streamID) || h.streamProcessor.IsStreamProcessing(req.streamID) {
 		h.handleProcessingStream(w, req)
 		return
 	}
@@ -93,7 +111,7 @@ func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		logger.String("streamId", req.streamID),
 		logger.String("fileName", req.fileName),
 		logger.ErrorField(err))
-	http.Error(w, "File not found", http.StatusNotFound)
+	writeError(w, CodeNotFound, "File not found")
 }
 
 // handleProcessingStream 处理正在转码中的流请求
@@ -133,9 +151,15 @@ func (h *StreamHandler) handleProgressivePlaylist(w http.ResponseWriter, req *st
 		return
 	}
 
+	// 非流水线模式没有渐进式状态，返回 FFmpeg 正在写入的 EVENT 播放列表
+	if data, contentType, err := h.streamProcessor.StreamGet(req.streamID, req.fileName, req.isNetease); err == nil {
+		h.writeStreamResponse(w, data, contentType, true)
+		return
+	}
+
 	logger.Warn("等待分片超时",
 		logger.String("streamId", req.streamID))
-	http.Error(w, "Processing in progress, please retry", http.StatusAccepted)
+	writeError(w, CodeStreamProcessing, "Processing in progress, please retry")
 }
 
 // handleSegmentRequest 处理分片请求
@@ -166,7 +190,7 @@ func (h *StreamHandler) handleSegmentRequest(w http.ResponseWriter, req *streamR
 	logger.Warn("等待分片超时",
 		logger.String("streamId", req.streamID),
 		logger.String("fileName", req.fileName))
-	http.Error(w, "Segment not ready", http.StatusNotFound)
+	writeError(w, CodeStreamNotReady, "Segment not ready")
 }
 
 // handleNeteaseReprocess 处理网易云歌曲重新处理
@@ -270,7 +294,7 @@ func (h *StreamHandler) waitAndServeProgressivePlaylist(w http.ResponseWriter, r
 		return
 	}
 
-	http.Error(w, "File not found", http.StatusNotFound)
+	writeError(w, CodeNotFound, "File not found")
 }
 
 // waitForExternalProcessing 等待外部进程处理完成
@@ -310,21 +334,28 @@ func (h *StreamHandler) waitForExternalProcessing(w http.ResponseWriter, req *st
 		return
 	}
 
-	http.Error(w, "File not found", http.StatusNotFound)
+	writeError(w, CodeNotFound, "File not found")
 }
 
 // waitForEnoughSegments 等待足够分片生成（智能判断）
+// 非流水线模式下 FFmpeg 写出播放列表时也会提前返回 nil，由调用方读取该播放列表
 func (h *StreamHandler) waitForEnoughSegments(streamID string, timeout time.Duration) *audio.ProgressiveHLSState {
 	deadline := time.Now().Add(timeout)
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
+	playlistPath := filepath.Join(h.cfg.StaticDir, "temp", "streams", streamID, "playlist.m3u8")
 	for time.Now().Before(deadline) {
 		<-ticker.C
 		hlsState := audio.GetProgressiveHLSManager().GetState(streamID)
 		if hlsState != nil && hlsState.HasEnoughSegmentsToPlay() {
 			return hlsState
 		}
+		if hlsState == nil {
+			if _, err := os.Stat(playlistPath); err == nil {
+				return nil
+			}
+		}
 	}
 	return nil
 }
@@ -346,10 +377,10 @@ func (h *StreamHandler) waitForSegment(req *streamRequest, timeout time.Duration
 }
 
 // writeStreamResponse 写入流媒体响应
+// 尚未写入 #EXT-X-ENDLIST 的播放列表还在增长，总是不缓存
 func (h *StreamHandler) writeStreamResponse(w http.ResponseWriter, data []byte, contentType string, noCache bool) {
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	if noCache {
+	if noCache || (contentType == "application/vnd.apple.mpegurl" && !bytes.Contains(data, []byte("#EXT-X-ENDLIST"))) {
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	} else {
 		w.Header().Set("Cache-Control", "public, max-age=31536000")
@@ -364,7 +395,6 @@ func (h *StreamHandler) writeStreamResponse(w http.ResponseWriter, data []byte,
 func (h *StreamHandler) writeM3U8Response(w http.ResponseWriter, hlsState *audio.ProgressiveHLSState) {
 	content := hlsState.GenerateM3U8()
 	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	if hlsState.IsProcessing() {
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
@@ -0,0 +1,876 @@
+package server
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/core/subsonic"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/repository"
+	"Bt1QFM/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// subsonicCredentialTTL 验证通过的密码在内存中缓存的时间，避免每个请求都计算 bcrypt
+const subsonicCredentialTTL = 10 * time.Minute
+
+// Subsonic ID 前缀，歌曲 ID 直接使用曲目 ID
+const (
+	subsonicArtistPrefix     = "ar-"
+	subsonicAlbumPrefix      = "al-"
+	subsonicTrackAlbumPrefix = "al-t" // 未归入专辑的曲目按专辑名分组形成的虚拟专辑
+	subsonicTrackCoverPrefix = "tr-"
+)
+
+// Subsonic 中艺术家或专辑名为空时的显示名称
+const (
+	subsonicUnknownArtist = "[Unknown Artist]"
+	subsonicUnknownAlbum  = "[Unknown Album]"
+)
+
+// SubsonicHandler Subsonic API 兼容层，使 DSub、Symfonium 等现有客户端可直接播放曲库
+type SubsonicHandler struct {
+	trackRepo repository.TrackRepository
+	albumRepo repository.AlbumRepository
+	userRepo  repository.UserRepository
+	throttle  *loginThrottle
+	cfg       *config.Config
+
+	mu          sync.Mutex
+	credentials map[string]subsonicCredential
+}
+
+// subsonicCredential 缓存的已验证凭据
+type subsonicCredential struct {
+	userID    int64
+	username  string
+	expiresAt time.Time
+}
+
+// subsonicRequest 认证后的请求
+type subsonicRequest struct {
+	*http.Request
+	userID int64
+	format string
+}
+
+// NewSubsonicHandler 创建 Subsonic 处理器
+// throttle 与登录接口共用，u/p 认证失败同样计入账号和 IP 的失败次数
+func NewSubsonicHandler(trackRepo repository.TrackRepository, albumRepo repository.AlbumRepository, userRepo repository.UserRepository, throttle *loginThrottle, cfg *config.Config) *SubsonicHandler {
+	return &SubsonicHandler{
+		trackRepo:   trackRepo,
+		albumRepo:   albumRepo,
+		userRepo:    userRepo,
+		throttle:    throttle,
+		cfg:         cfg,
+		credentials: make(map[string]subsonicCredential),
+	}
+}
+
+// ServeHTTP 处理 /rest/{method}，方法名可带 .view 后缀
+func (h *SubsonicHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	format := r.FormValue("f")
+	method := strings.TrimSuffix(mux.Vars(r)["method"], ".view")
+
+	handler, ok := map[string]func(http.ResponseWriter, *subsonicRequest){
+		"ping":              h.ping,
+		"getLicense":        h.getLicense,
+		"getMusicFolders":   h.getMusicFolders,
+		"getArtists":        h.getArtists,
+		"getArtist":         h.getArtist,
+		"getAlbum":          h.getAlbum,
+		"getSong":           h.getSong,
+		"getMusicDirectory": h.getMusicDirectory,
+		"getAlbumList":      h.getAlbumList,
+		"getAlbumList2":     h.getAlbumList2,
+		"search3":           h.search3,
+		"stream":            h.stream,
+		"download":          h.stream,
+		"getCoverArt":       h.getCoverArt,
+	}[method]
+	if !ok {
+		subsonic.Write(w, format, subsonic.NewError(subsonic.ErrGeneric, "Unsupported method: "+method))
+		return
+	}
+
+	cred, errResp := h.authenticate(r)
+	if errResp != nil {
+		subsonic.Write(w, format, errResp)
+		return
+	}
+	r = r.WithContext(withRequestUser(r.Context(), cred.userID, cred.username))
+	handler(w, &subsonicRequest{Request: r, userID: cred.userID, format: format})
+}
+
+// authenticate 校验 u、p 参数，失败时返回错误响应
+// 密码以 bcrypt 存储，无法校验 t、s（md5(密码+盐)）形式的 token 认证
+func (h *SubsonicHandler) authenticate(r *http.Request) (*subsonicCredential, *subsonic.Response) {
+	username := r.FormValue("u")
+	password := r.FormValue("p")
+	if username == "" {
+		return nil, subsonic.NewError(subsonic.ErrMissingParameter, "Required parameter is missing: u")
+	}
+	if password == "" {
+		if r.FormValue("t") != "" {
+			return nil, subsonic.NewError(subsonic.ErrTokenAuthNotSupported, "Token authentication is not supported, enable legacy (password) authentication in your client")
+		}
+		return nil, subsonic.NewError(subsonic.ErrMissingParameter, "Required parameter is missing: p")
+	}
+	if encoded, ok := strings.CutPrefix(password, "enc:"); ok {
+		decoded, err := hex.DecodeString(encoded)
+		if err != nil {
+			return nil, subsonic.NewError(subsonic.ErrWrongCredentials, "Wrong username or password")
+		}
+		password = string(decoded)
+	}
+
+	sum := sha256.Sum256([]byte(password))
+	cacheKey := username + "\x00" + hex.EncodeToString(sum[:])
+	h.mu.Lock()
+	cred, ok := h.credentials[cacheKey]
+	h.mu.Unlock()
+	if ok && time.Now().Before(cred.expiresAt) {
+		return &cred, nil
+	}
+
+	// 未命中缓存时才计算 bcrypt，按客户端 IP 与登录接口共用限流、失败退避和账号锁定
+	ip := clientIP(r, trustedProxies(h.cfg))
+	if h.cfg.RateLimitEnabled && h.cfg.RateLimitAuth.Enabled() {
+		if allowed, _ := checkRateLimit("auth", "ip:"+ip, h.cfg.RateLimitAuth); !allowed {
+			return nil, subsonic.NewError(subsonic.ErrGeneric, "Too many requests")
+		}
+	}
+	if resp := h.loginBlocked(r.Context(), loginIPSubject(ip)); resp != nil {
+		return nil, resp
+	}
+
+	var user *model.User
+	var err error
+	if strings.Contains(username, "@") {
+		user, err = h.userRepo.GetUserByEmail(r.Context(), username)
+	} else {
+		user, err = h.userRepo.GetUserByUsername(r.Context(), username)
+	}
+	if err != nil {
+		logger.Ctx(r.Context()).Error("Subsonic 查询用户失败", logger.String("username", username), logger.ErrorField(err))
+		return nil, subsonic.NewError(subsonic.ErrGeneric, "Internal server error")
+	}
+	if user == nil {
+		logger.Ctx(r.Context()).Warn("Subsonic 认证失败", logger.String("username", username))
+		return nil, h.loginFailure(r.Context(), ip, nil)
+	}
+	// 账号处于退避或锁定中时不验证密码
+	if resp := h.loginBlocked(r.Context(), loginAccountSubject(user.ID)); resp != nil {
+		return nil, resp
+	}
+	if !auth.VerifyPassword(password, user.PasswordHash) {
+		logger.Ctx(r.Context()).Warn("Subsonic 认证失败", logger.String("username", username))
+		return nil, h.loginFailure(r.Context(), ip, user)
+	}
+	h.throttle.clearAccount(r.Context(), user.ID)
+	if user.IsDisabled() {
+		return nil, subsonic.NewError(subsonic.ErrNotAuthorized, "Account is disabled")
+	}
+	if !user.IsActive() && strings.EqualFold(h.cfg.UnverifiedRestriction, restrictUnverifiedLogin) {
+		return nil, subsonic.NewError(subsonic.ErrNotAuthorized, "Please verify your email before logging in")
+	}
+
+	h.mu.Lock()
+	now := time.Now()
+	for key, c := range h.credentials {
+		if now.After(c.expiresAt) {
+			delete(h.credentials, key)
+		}
+	}
+	cred = subsonicCredential{userID: user.ID, username: user.Username, expiresAt: now.Add(subsonicCredentialTTL)}
+	h.credentials[cacheKey] = cred
+	h.mu.Unlock()
+	return &cred, nil
+}
+
+// loginBlocked subject 处于退避或锁定中时返回错误响应，Subsonic 响应没有响应头，等待时间写在错误信息中
+func (h *SubsonicHandler) loginBlocked(ctx context.Context, subject string) *subsonic.Response {
+	kind, ttl := h.throttle.block(ctx, subject)
+	switch kind {
+	case "":
+		return nil
+	case cache.LoginBlockLocked:
+		return subsonic.NewError(subsonic.ErrNotAuthorized,
+			fmt.Sprintf("Account is temporarily locked due to too many failed login attempts, retry after %d seconds", retryAfterSeconds(ttl)))
+	default:
+		return subsonic.NewError(subsonic.ErrGeneric,
+			fmt.Sprintf("Too many failed login attempts, retry after %d seconds", retryAfterSeconds(ttl)))
+	}
+}
+
+// loginFailure 记录一次认证失败，返回凭据错误；本次失败使账号被锁定时返回锁定错误
+func (h *SubsonicHandler) loginFailure(ctx context.Context, ip string, user *model.User) *subsonic.Response {
+	if lockout, locked := h.throttle.recordFailure(ctx, ip, user); locked {
+		return subsonic.NewError(subsonic.ErrNotAuthorized,
+			fmt.Sprintf("Account is temporarily locked due to too many failed login attempts, retry after %d seconds", retryAfterSeconds(lockout)))
+	}
+	return subsonic.NewError(subsonic.ErrWrongCredentials, "Wrong username or password")
+}
+
+func (h *SubsonicHandler) ping(w http.ResponseWriter, r *subsonicRequest) {
+	subsonic.Write(w, r.format, subsonic.NewResponse())
+}
+
+func (h *SubsonicHandler) getLicense(w http.ResponseWriter, r *subsonicRequest) {
+	resp := subsonic.NewResponse()
+	resp.License = &subsonic.License{Valid: true}
+	subsonic.Write(w, r.format, resp)
+}
+
+func (h *SubsonicHandler) getMusicFolders(w http.ResponseWriter, r *subsonicRequest) {
+	resp := subsonic.NewResponse()
+	resp.MusicFolders = &subsonic.MusicFolders{Folders: []subsonic.MusicFolder{{ID: 1, Name: "1QFM"}}}
+	subsonic.Write(w, r.format, resp)
+}
+
+// getArtists 按首字母分组返回艺术家
+func (h *SubsonicHandler) getArtists(w http.ResponseWriter, r *subsonicRequest) {
+	lib, ok := h.loadLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	indexes := make(map[string][]subsonic.ArtistID3)
+	for _, artist := range lib.artists() {
+		letter := subsonicIndexLetter(artist.Name)
+		indexes[letter] = append(indexes[letter], artist)
+	}
+	letters := make([]string, 0, len(indexes))
+	for letter := range indexes {
+		letters = append(letters, letter)
+	}
+	sort.Strings(letters)
+
+	result := &subsonic.Artists{IgnoredArticles: "The El La Los Las Le Les"}
+	for _, letter := range letters {
+		result.Index = append(result.Index, subsonic.Index{Name: letter, Artists: indexes[letter]})
+	}
+	resp := subsonic.NewResponse()
+	resp.Artists = result
+	subsonic.Write(w, r.format, resp)
+}
+
+// getArtist 返回艺术家及其专辑
+func (h *SubsonicHandler) getArtist(w http.ResponseWriter, r *subsonicRequest) {
+	id, ok := requireSubsonicParam(w, r, "id")
+	if !ok {
+		return
+	}
+	lib, ok := h.loadLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	for _, artist := range lib.artists() {
+		if artist.ID != id {
+			continue
+		}
+		result := &subsonic.ArtistWithAlbums{ArtistID3: artist}
+		for _, album := range lib.albums {
+			if subsonicArtistID(album.artist) == id {
+				result.Albums = append(result.Albums, album.id3())
+			}
+		}
+		resp := subsonic.NewResponse()
+		resp.Artist = result
+		subsonic.Write(w, r.format, resp)
+		return
+	}
+	subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrNotFound, "Artist not found"))
+}
+
+// getAlbum 返回专辑及其歌曲
+func (h *SubsonicHandler) getAlbum(w http.ResponseWriter, r *subsonicRequest) {
+	id, ok := requireSubsonicParam(w, r, "id")
+	if !ok {
+		return
+	}
+	lib, ok := h.loadLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	album := lib.albumByID[id]
+	if album == nil {
+		subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrNotFound, "Album not found"))
+		return
+	}
+	result := &subsonic.AlbumWithSongs{AlbumID3: album.id3()}
+	for i, t := range album.tracks {
+		result.Songs = append(result.Songs, subsonicSong(t, album, i+1))
+	}
+	resp := subsonic.NewResponse()
+	resp.Album = result
+	subsonic.Write(w, r.format, resp)
+}
+
+// getSong 返回单首歌曲
+func (h *SubsonicHandler) getSong(w http.ResponseWriter, r *subsonicRequest) {
+	id, ok := requireSubsonicParam(w, r, "id")
+	if !ok {
+		return
+	}
+	lib, ok := h.loadLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	for _, album := range lib.albums {
+		for i, t := range album.tracks {
+			if strconv.FormatInt(t.ID, 10) == id {
+				song := subsonicSong(t, album, i+1)
+				resp := subsonic.NewResponse()
+				resp.Song = &song
+				subsonic.Write(w, r.format, resp)
+				return
+			}
+		}
+	}
+	subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrNotFound, "Song not found"))
+}
+
+// getMusicDirectory 基于目录的浏览接口，目录即 getAlbumList 返回的专辑
+func (h *SubsonicHandler) getMusicDirectory(w http.ResponseWriter, r *subsonicRequest) {
+	id, ok := requireSubsonicParam(w, r, "id")
+	if !ok {
+		return
+	}
+	lib, ok := h.loadLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	album := lib.albumByID[id]
+	if album == nil {
+		subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrNotFound, "Directory not found"))
+		return
+	}
+	result := &subsonic.Directory{ID: album.id, Name: album.name}
+	for i, t := range album.tracks {
+		result.Children = append(result.Children, subsonicSong(t, album, i+1))
+	}
+	resp := subsonic.NewResponse()
+	resp.Directory = result
+	subsonic.Write(w, r.format, resp)
+}
+
+// getAlbumList 基于目录的专辑列表
+func (h *SubsonicHandler) getAlbumList(w http.ResponseWriter, r *subsonicRequest) {
+	albums, ok := h.listAlbums(w, r)
+	if !ok {
+		return
+	}
+	result := &subsonic.AlbumList{}
+	for _, album := range albums {
+		result.Albums = append(result.Albums, album.child())
+	}
+	resp := subsonic.NewResponse()
+	resp.AlbumList = result
+	subsonic.Write(w, r.format, resp)
+}
+
+// getAlbumList2 按 ID3 标签组织的专辑列表
+func (h *SubsonicHandler) getAlbumList2(w http.ResponseWriter, r *subsonicRequest) {
+	albums, ok := h.listAlbums(w, r)
+	if !ok {
+		return
+	}
+	result := &subsonic.AlbumList2{}
+	for _, album := range albums {
+		result.Albums = append(result.Albums, album.id3())
+	}
+	resp := subsonic.NewResponse()
+	resp.AlbumList2 = result
+	subsonic.Write(w, r.format, resp)
+}
+
+// listAlbums 按 type、size、offset 等参数筛选排序专辑，失败时已写入错误响应
+// 曲库不记录播放次数和评分，frequent、highest、starred 返回空列表
+func (h *SubsonicHandler) listAlbums(w http.ResponseWriter, r *subsonicRequest) ([]*subsonicAlbum, bool) {
+	listType, ok := requireSubsonicParam(w, r, "type")
+	if !ok {
+		return nil, false
+	}
+	lib, ok := h.loadLibrary(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	albums := append([]*subsonicAlbum(nil), lib.albums...)
+	switch listType {
+	case "random":
+		rand.Shuffle(len(albums), func(i, j int) { albums[i], albums[j] = albums[j], albums[i] })
+	case "newest", "recent":
+		sort.SliceStable(albums, func(i, j int) bool { return albums[i].created.After(albums[j].created) })
+	case "alphabeticalByName":
+		sort.SliceStable(albums, func(i, j int) bool { return strings.ToLower(albums[i].name) < strings.ToLower(albums[j].name) })
+	case "alphabeticalByArtist":
+		sort.SliceStable(albums, func(i, j int) bool {
+			if a, b := strings.ToLower(albums[i].artist), strings.ToLower(albums[j].artist); a != b {
+				return a < b
+			}
+			return strings.ToLower(albums[i].name) < strings.ToLower(albums[j].name)
+		})
+	case "byGenre":
+		genre, ok := requireSubsonicParam(w, r, "genre")
+		if !ok {
+			return nil, false
+		}
+		albums = filterSubsonicAlbums(albums, func(a *subsonicAlbum) bool { return strings.EqualFold(a.genre, genre) })
+	case "byYear":
+		from, _ := strconv.Atoi(r.FormValue("fromYear"))
+		to, _ := strconv.Atoi(r.FormValue("toYear"))
+		low, high := min(from, to), max(from, to)
+		albums = filterSubsonicAlbums(albums, func(a *subsonicAlbum) bool { return a.year >= low && a.year <= high })
+		sort.SliceStable(albums, func(i, j int) bool {
+			if from > to {
+				return albums[i].year > albums[j].year
+			}
+			return albums[i].year < albums[j].year
+		})
+	case "frequent", "highest", "starred":
+		albums = nil
+	default:
+		subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrGeneric, "Invalid list type: "+listType))
+		return nil, false
+	}
+
+	size := subsonicIntParam(r, "size", 10, 500)
+	offset := subsonicIntParam(r, "offset", 0, len(albums))
+	return albums[offset:min(offset+size, len(albums))], true
+}
+
+// search3 按关键字搜索艺术家、专辑和歌曲，query 为空时返回全部（部分客户端借此同步曲库）
+func (h *SubsonicHandler) search3(w http.ResponseWriter, r *subsonicRequest) {
+	lib, ok := h.loadLibrary(w, r)
+	if !ok {
+		return
+	}
+	query := strings.ToLower(strings.Trim(strings.TrimSpace(r.FormValue("query")), `"`))
+	match := func(fields ...string) bool {
+		if query == "" {
+			return true
+		}
+		for _, f := range fields {
+			if strings.Contains(strings.ToLower(f), query) {
+				return true
+			}
+		}
+		return false
+	}
+
+	result := &subsonic.SearchResult3{}
+
+	var artists []subsonic.ArtistID3
+	for _, artist := range lib.artists() {
+		if match(artist.Name) {
+			artists = append(artists, artist)
+		}
+	}
+	result.Artists = subsonicPage(r, artists, "artistCount", "artistOffset")
+
+	var albums []subsonic.AlbumID3
+	var songs []subsonic.Child
+	for _, album := range lib.albums {
+		if match(album.name, album.artist) {
+			albums = append(albums, album.id3())
+		}
+		for i, t := range album.tracks {
+			if match(t.Title, t.Artist, t.Album) {
+				songs = append(songs, subsonicSong(t, album, i+1))
+			}
+		}
+	}
+	result.Albums = subsonicPage(r, albums, "albumCount", "albumOffset")
+	result.Songs = subsonicPage(r, songs, "songCount", "songOffset")
+
+	resp := subsonic.NewResponse()
+	resp.SearchResult3 = result
+	subsonic.Write(w, r.format, resp)
+}
+
+// stream 返回歌曲的原始音频文件
+func (h *SubsonicHandler) stream(w http.ResponseWriter, r *subsonicRequest) {
+	id, ok := requireSubsonicParam(w, r, "id")
+	if !ok {
+		return
+	}
+	trackID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrNotFound, "Song not found"))
+		return
+	}
+	track, err := h.trackRepo.GetTrackByID(r.Context(), trackID)
+	if err != nil || track == nil || track.UserID != r.userID || track.State != 1 || !strings.HasPrefix(track.FilePath, "/static/") {
+		subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrNotFound, "Song not found"))
+		return
+	}
+
+	// 音频文件较大，不受服务器 WriteTimeout 限制
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Ctx(r.Context()).Debug("无法取消写入超时", logger.ErrorField(err))
+	}
+	h.serveObject(w, r, strings.TrimPrefix(track.FilePath, "/static/"), subsonicContentType(track.FilePath))
+}
+
+// getCoverArt 返回歌曲或专辑封面，id 为 tr-{曲目ID} 或专辑 ID
+func (h *SubsonicHandler) getCoverArt(w http.ResponseWriter, r *subsonicRequest) {
+	id, ok := requireSubsonicParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var coverPath string
+	switch {
+	case strings.HasPrefix(id, subsonicTrackCoverPrefix):
+		trackID, err := strconv.ParseInt(strings.TrimPrefix(id, subsonicTrackCoverPrefix), 10, 64)
+		if err != nil {
+			break
+		}
+		if track, err := h.trackRepo.GetTrackByID(r.Context(), trackID); err == nil && track != nil && track.UserID == r.userID {
+			coverPath = track.CoverArtPath
+		}
+	case strings.HasPrefix(id, subsonicAlbumPrefix) && !strings.HasPrefix(id, subsonicTrackAlbumPrefix):
+		albumID, err := strconv.ParseInt(strings.TrimPrefix(id, subsonicAlbumPrefix), 10, 64)
+		if err != nil {
+			break
+		}
+		if album, err := h.albumRepo.GetAlbumByID(r.Context(), albumID); err == nil && album != nil && album.UserID == r.userID {
+			coverPath = album.CoverPath
+		}
+	}
+
+	if strings.HasPrefix(coverPath, "http://") || strings.HasPrefix(coverPath, "https://") {
+		http.Redirect(w, r.Request, coverPath, http.StatusFound)
+		return
+	}
+	if !strings.HasPrefix(coverPath, "/static/") {
+		subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrNotFound, "Cover art not found"))
+		return
+	}
+	key := strings.TrimPrefix(coverPath, "/static/")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	h.serveObject(w, r, key, imageContentType(key))
+}
+
+// serveObject 将存储中的对象写入响应，支持 Range 请求以便客户端拖动进度
+func (h *SubsonicHandler) serveObject(w http.ResponseWriter, r *subsonicRequest, key, contentType string) {
+	store := storage.GetStorage()
+	if store == nil {
+		subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrGeneric, "Storage not available"))
+		return
+	}
+	object, err := store.Get(r.Context(), key)
+	if err != nil {
+		if !storage.IsNotFound(err) {
+			logger.Ctx(r.Context()).Error("Subsonic 读取文件失败", logger.String("key", key), logger.ErrorField(err))
+		}
+		subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrNotFound, "File not found"))
+		return
+	}
+	defer object.Close()
+
+	serveStoredObject(w, r.Request, store, key, object, contentType)
+}
+
+// subsonicLibrary 用户曲库在 Subsonic 中的视图
+// 专辑由用户创建的专辑和未归入专辑的曲目按（艺术家，专辑名）分组形成的虚拟专辑组成
+type subsonicLibrary struct {
+	albums    []*subsonicAlbum
+	albumByID map[string]*subsonicAlbum
+}
+
+// subsonicAlbum Subsonic 中的专辑
+type subsonicAlbum struct {
+	id       string
+	name     string
+	artist   string
+	coverArt string
+	genre    string
+	year     int
+	created  time.Time
+	tracks   []*model.Track
+	// compilation 合辑（群星），歌曲使用各自的艺术家
+	compilation bool
+}
+
+// loadLibrary 读取用户的专辑和曲目，失败时已写入错误响应
+func (h *SubsonicHandler) loadLibrary(w http.ResponseWriter, r *subsonicRequest) (*subsonicLibrary, bool) {
+	lib, err := h.buildLibrary(r.Context(), r.userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("Subsonic 读取曲库失败", logger.ErrorField(err))
+		subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrGeneric, "Failed to load library"))
+		return nil, false
+	}
+	return lib, true
+}
+
+func (h *SubsonicHandler) buildLibrary(ctx context.Context, userID int64) (*subsonicLibrary, error) {
+	tracks, err := h.trackRepo.GetAllTracksByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	albums, err := h.albumRepo.GetAlbumsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	lib := &subsonicLibrary{albumByID: make(map[string]*subsonicAlbum)}
+	claimed := make(map[int64]bool)
+	for _, a := range albums {
+		albumTracks, err := h.albumRepo.GetAlbumTracks(ctx, a.ID)
+		if err != nil {
+			return nil, err
+		}
+		album := &subsonicAlbum{
+			id:          subsonicAlbumPrefix + strconv.FormatInt(a.ID, 10),
+			name:        a.Name,
+			artist:      a.Artist,
+			genre:       a.Genre,
+			created:     a.CreatedAt,
+			compilation: a.IsCompilation,
+		}
+		if !a.ReleaseTime.IsZero() {
+			album.year = a.ReleaseTime.Year()
+		}
+		if a.CoverPath != "" {
+			album.coverArt = album.id
+		}
+		for _, t := range albumTracks {
+			if t.State == 1 && t.UserID == userID {
+				album.tracks = append(album.tracks, t)
+				claimed[t.ID] = true
+			}
+		}
+		lib.add(album)
+	}
+
+	// 曲目按上传时间倒序返回，虚拟专辑内按上传时间正序排列
+	for i := len(tracks) - 1; i >= 0; i-- {
+		t := tracks[i]
+		if claimed[t.ID] {
+			continue
+		}
+		artist, name := subsonicName(t.Artist, subsonicUnknownArtist), subsonicName(t.Album, subsonicUnknownAlbum)
+		id := subsonicTrackAlbumPrefix + subsonicHash(artist+"\x00"+name)
+		album := lib.albumByID[id]
+		if album == nil {
+			album = &subsonicAlbum{id: id, name: name, artist: artist, genre: t.Genre, created: t.CreatedAt}
+			lib.add(album)
+		}
+		if album.coverArt == "" && t.CoverArtPath != "" {
+			album.coverArt = subsonicTrackCoverPrefix + strconv.FormatInt(t.ID, 10)
+		}
+		album.tracks = append(album.tracks, t)
+	}
+	return lib, nil
+}
+
+func (lib *subsonicLibrary) add(album *subsonicAlbum) {
+	album.artist = subsonicName(album.artist, subsonicUnknownArtist)
+	lib.albums = append(lib.albums, album)
+	lib.albumByID[album.id] = album
+}
+
+// artists 从专辑艺术家汇总艺术家列表，按名称排序
+func (lib *subsonicLibrary) artists() []subsonic.ArtistID3 {
+	byID := make(map[string]*subsonic.ArtistID3)
+	for _, album := range lib.albums {
+		id := subsonicArtistID(album.artist)
+		artist := byID[id]
+		if artist == nil {
+			artist = &subsonic.ArtistID3{ID: id, Name: album.artist}
+			byID[id] = artist
+		}
+		artist.AlbumCount++
+		if artist.CoverArt == "" {
+			artist.CoverArt = album.coverArt
+		}
+	}
+
+	result := make([]subsonic.ArtistID3, 0, len(byID))
+	for _, artist := range byID {
+		result = append(result, *artist)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result
+}
+
+func (a *subsonicAlbum) duration() int {
+	total := 0
+	for _, t := range a.tracks {
+		total += int(t.Duration)
+	}
+	return total
+}
+
+func (a *subsonicAlbum) id3() subsonic.AlbumID3 {
+	return subsonic.AlbumID3{
+		ID:            a.id,
+		Name:          a.name,
+		Artist:        a.artist,
+		ArtistID:      subsonicArtistID(a.artist),
+		CoverArt:      a.coverArt,
+		SongCount:     len(a.tracks),
+		Duration:      a.duration(),
+		Created:       a.created.UTC().Format(time.RFC3339),
+		Year:          a.year,
+		Genre:         a.genre,
+		IsCompilation: a.compilation,
+	}
+}
+
+func (a *subsonicAlbum) child() subsonic.Child {
+	return subsonic.Child{
+		ID:       a.id,
+		Parent:   subsonicArtistID(a.artist),
+		IsDir:    true,
+		Title:    a.name,
+		Album:    a.name,
+		Artist:   a.artist,
+		Year:     a.year,
+		Genre:    a.genre,
+		CoverArt: a.coverArt,
+		Duration: a.duration(),
+		Created:  a.created.UTC().Format(time.RFC3339),
+	}
+}
+
+// subsonicSong 将曲目转换为 Subsonic 歌曲，position 为在专辑中的序号，曲目有碟内曲号时优先使用曲号
+func subsonicSong(t *model.Track, album *subsonicAlbum, position int) subsonic.Child {
+	if t.TrackNumber > 0 {
+		position = t.TrackNumber
+	}
+	song := subsonic.Child{
+		ID:          strconv.FormatInt(t.ID, 10),
+		Parent:      album.id,
+		Title:       t.Title,
+		Album:       album.name,
+		Artist:      subsonicName(t.Artist, album.artist),
+		Track:       position,
+		DiscNumber:  t.DiscNumber,
+		Year:        album.year,
+		Genre:       t.Genre,
+		ContentType: subsonicContentType(t.FilePath),
+		Suffix:      strings.TrimPrefix(strings.ToLower(path.Ext(t.FilePath)), "."),
+		Duration:    int(t.Duration),
+		Path:        fmt.Sprintf("%s/%s/%s", album.artist, album.name, path.Base(t.FilePath)),
+		AlbumID:     album.id,
+		ArtistID:    subsonicArtistID(subsonicName(t.Artist, album.artist)),
+		Type:        "music",
+		Created:     t.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if t.CoverArtPath != "" {
+		song.CoverArt = subsonicTrackCoverPrefix + song.ID
+	} else {
+		song.CoverArt = album.coverArt
+	}
+	return song
+}
+
+// subsonicContentType 根据文件扩展名推断音频类型
+func subsonicContentType(filePath string) string {
+	return audioContentType(filePath)
+}
+
+func subsonicArtistID(name string) string {
+	return subsonicArtistPrefix + subsonicHash(name)
+}
+
+// subsonicHash 为没有数据库 ID 的艺术家和虚拟专辑生成稳定的 ID
+func subsonicHash(s string) string {
+	sum := sha1.Sum([]byte(strings.ToLower(s)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func subsonicName(name, fallback string) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	return fallback
+}
+
+// subsonicIndexLetter 艺术家索引的首字母，非拉丁字母归入 #
+func subsonicIndexLetter(name string) string {
+	for _, article := range []string{"The ", "El ", "La ", "Los ", "Las ", "Le ", "Les "} {
+		if len(name) > len(article) && strings.EqualFold(name[:len(article)], article) {
+			name = name[len(article):]
+			break
+		}
+	}
+	for _, c := range name {
+		if c < unicode.MaxASCII && unicode.IsLetter(c) {
+			return string(unicode.ToUpper(c))
+		}
+		return "#"
+	}
+	return "#"
+}
+
+func filterSubsonicAlbums(albums []*subsonicAlbum, keep func(*subsonicAlbum) bool) []*subsonicAlbum {
+	result := albums[:0]
+	for _, a := range albums {
+		if keep(a) {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// subsonicPage 按 countParam、offsetParam 截取搜索结果，默认每类 20 条
+func subsonicPage[T any](r *subsonicRequest, items []T, countParam, offsetParam string) []T {
+	count := subsonicIntParam(r, countParam, 20, 500)
+	offset := subsonicIntParam(r, offsetParam, 0, len(items))
+	return items[offset:min(offset+count, len(items))]
+}
+
+// subsonicIntParam 读取非负整数参数，超过 limit 时取 limit
+func subsonicIntParam(r *subsonicRequest, name string, fallback, limit int) int {
+	n, err := strconv.Atoi(r.FormValue(name))
+	if err != nil || n < 0 {
+		n = fallback
+	}
+	return min(n, limit)
+}
+
+// requireSubsonicParam 读取必需参数，缺少时写入错误响应
+func requireSubsonicParam(w http.ResponseWriter, r *subsonicRequest, name string) (string, bool) {
+	value := r.FormValue(name)
+	if value == "" {
+		subsonic.Write(w, r.format, subsonic.NewError(subsonic.ErrMissingParameter, "Required parameter is missing: "+name))
+		return "", false
+	}
+	return value, true
+}
+
+// RegisterSubsonicRoutes 注册 Subsonic 兼容接口，认证通过 u、p 参数完成，不经过 AuthMiddleware
+func RegisterSubsonicRoutes(router *mux.Router, handler *SubsonicHandler) {
+	router.Handle("/rest/{method}", handler).Methods(http.MethodGet, http.MethodPost)
+
+	logger.Info("Subsonic API端点注册完成",
+		logger.String("endpoints", "/rest/{ping,getLicense,getMusicFolders,getArtists,getArtist,getAlbum,getSong,getMusicDirectory,getAlbumList,getAlbumList2,search3,stream,download,getCoverArt}[.view]"))
+}
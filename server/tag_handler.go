@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/mux"
+)
+
+// GetTagsHandler 返回当前用户的全部标签及各标签下的曲目数
+func (h *APIHandler) GetTagsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	tags, err := h.tagRepo.GetTagsByUserID(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取用户标签失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get tags")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// AddTrackTagsHandler 为曲目添加标签，请求体 {"tags": ["jazz", "live"]}
+func (h *APIHandler) AddTrackTagsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	names, msg := normalizeTagNames(req.Tags)
+	if msg != "" {
+		writeError(w, CodeBadRequest, msg)
+		return
+	}
+	if len(names) == 0 {
+		writeError(w, CodeMissingField, "Missing 'tags'")
+		return
+	}
+
+	track, ok := h.loadOwnedTrack(w, r, userID)
+	if !ok {
+		return
+	}
+
+	existing, err := h.tagRepo.GetTagsByTrackIDs(r.Context(), []int64{track.ID})
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取曲目标签失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get track tags")
+		return
+	}
+	if len(mergeTagNames(existing[track.ID], names)) > model.MaxTagsPerTrack {
+		writeError(w, CodeBadRequest, fmt.Sprintf("A track can have at most %d tags", model.MaxTagsPerTrack))
+		return
+	}
+
+	if err := h.tagRepo.AddTagsToTrack(r.Context(), userID, track.ID, names); err != nil {
+		logger.Ctx(r.Context()).Error("添加曲目标签失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to add tags")
+		return
+	}
+
+	h.writeTrackTags(w, r, track.ID)
+}
+
+// RemoveTrackTagHandler 移除曲目的一个标签
+func (h *APIHandler) RemoveTrackTagHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	name := model.NormalizeTagName(mux.Vars(r)["tag"])
+	if name == "" {
+		writeError(w, CodeMissingField, "Missing tag")
+		return
+	}
+
+	track, ok := h.loadOwnedTrack(w, r, userID)
+	if !ok {
+		return
+	}
+
+	removed, err := h.tagRepo.RemoveTagFromTrack(r.Context(), userID, track.ID, name)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("移除曲目标签失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to remove tag")
+		return
+	}
+	if !removed {
+		writeError(w, CodeNotFound, "Track does not have this tag")
+		return
+	}
+
+	h.writeTrackTags(w, r, track.ID)
+}
+
+// writeTrackTags 返回曲目当前的标签
+func (h *APIHandler) writeTrackTags(w http.ResponseWriter, r *http.Request, trackID int64) {
+	tags, err := h.tagRepo.GetTagsByTrackIDs(r.Context(), []int64{trackID})
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取曲目标签失败", logger.Int64("trackId", trackID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get track tags")
+		return
+	}
+	trackTags := tags[trackID]
+	if trackTags == nil {
+		trackTags = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"trackId": trackID,
+		"tags":    trackTags,
+	})
+}
+
+// loadOwnedTrack 解析路径中的曲目ID并加载当前用户的有效曲目，失败时已写入响应
+func (h *APIHandler) loadOwnedTrack(w http.ResponseWriter, r *http.Request, userID int64) (*model.Track, bool) {
+	trackID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid track ID")
+		return nil, false
+	}
+	track, err := h.trackRepo.GetTrackByID(r.Context(), trackID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取track失败",
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get track")
+		return nil, false
+	}
+	if track == nil || track.State == 0 {
+		writeError(w, CodeTrackNotFound, "Track not found")
+		return nil, false
+	}
+	if track.UserID != userID {
+		writeError(w, CodeForbidden, "Forbidden")
+		return nil, false
+	}
+	return track, true
+}
+
+// normalizeTagNames 规范化并去重标签名，返回错误信息
+func normalizeTagNames(raw []string) ([]string, string) {
+	names := make([]string, 0, len(raw))
+	for _, r := range raw {
+		name := model.NormalizeTagName(r)
+		if name == "" {
+			continue
+		}
+		if len([]rune(name)) > model.MaxTagLength {
+			return nil, fmt.Sprintf("Tag %q is too long", name)
+		}
+		names = mergeTagNames(names, []string{name})
+	}
+	return names, ""
+}
+
+// mergeTagNames 合并两组标签名，去除重复
+func mergeTagNames(a, b []string) []string {
+	merged := append([]string{}, a...)
+	for _, name := range b {
+		found := false
+		for _, existing := range merged {
+			if existing == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, name)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+)
+
+// maxBatchTrackUpdate 单次批量修改的曲目数上限
+const maxBatchTrackUpdate = 200
+
+// batchTrackUpdateRequest 批量修改曲目元数据的请求
+type batchTrackUpdateRequest struct {
+	TrackIDs []int64                   `json:"trackIds"`
+	Updates  model.TrackMetadataUpdate `json:"updates"`
+}
+
+// batchTrackUpdateResult 单个曲目的修改结果
+type batchTrackUpdateResult struct {
+	TrackID int64        `json:"trackId"`
+	Success bool         `json:"success"`
+	Code    ErrorCode    `json:"code,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Track   *model.Track `json:"track,omitempty"`
+}
+
+// BatchUpdateTracksHandler 批量修改曲目的标题、歌手、专辑、流派、年份和封面
+// 所有可修改的曲目在同一个事务中更新；不存在或不属于当前用户的曲目在结果中单独标记，不影响其他曲目
+func (h *APIHandler) BatchUpdateTracksHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req batchTrackUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if len(req.TrackIDs) == 0 {
+		writeError(w, CodeMissingField, "Missing 'trackIds'")
+		return
+	}
+	if len(req.TrackIDs) > maxBatchTrackUpdate {
+		writeError(w, CodeBadRequest, fmt.Sprintf("At most %d tracks can be updated at once", maxBatchTrackUpdate))
+		return
+	}
+	if req.Updates.IsEmpty() {
+		writeError(w, CodeMissingField, "No fields to update")
+		return
+	}
+	if msg := normalizeTrackMetadataUpdate(&req.Updates); msg != "" {
+		writeError(w, CodeBadRequest, msg)
+		return
+	}
+
+	trackIDs := uniqueTrackIDs(req.TrackIDs)
+	tracks, err := h.trackRepo.GetTracksByIDs(r.Context(), trackIDs)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("批量获取曲目失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get tracks")
+		return
+	}
+	byID := make(map[int64]*model.Track, len(tracks))
+	for _, t := range tracks {
+		byID[t.ID] = t
+	}
+
+	results := make([]*batchTrackUpdateResult, 0, len(trackIDs))
+	editable := make([]*batchTrackUpdateResult, 0, len(trackIDs))
+	for _, id := range trackIDs {
+		result := &batchTrackUpdateResult{TrackID: id}
+		results = append(results, result)
+
+		track := byID[id]
+		switch {
+		case track == nil || track.State == 0:
+			result.Code, result.Message = CodeTrackNotFound, "Track not found"
+		case track.UserID != userID:
+			result.Code, result.Message = CodeForbidden, "Forbidden"
+		default:
+			result.Track = track
+			editable = append(editable, result)
+		}
+	}
+
+	if len(editable) > 0 {
+		tx, err := h.trackRepo.BeginTx(r.Context())
+		if err != nil {
+			logger.Ctx(r.Context()).Error("开始数据库事务失败", logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to begin transaction")
+			return
+		}
+		defer h.trackRepo.RollbackTx(tx)
+
+		for _, result := range editable {
+			if err := h.trackRepo.UpdateTrackMetadataWithTx(r.Context(), tx, result.TrackID, &req.Updates); err != nil {
+				logger.Ctx(r.Context()).Error("批量修改曲目元数据失败",
+					logger.Int64("trackId", result.TrackID),
+					logger.ErrorField(err))
+				writeError(w, CodeInternal, "Failed to update tracks")
+				return
+			}
+		}
+		if err := h.trackRepo.CommitTx(tx); err != nil {
+			logger.Ctx(r.Context()).Error("提交数据库事务失败", logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to commit transaction")
+			return
+		}
+
+		for _, result := range editable {
+			req.Updates.ApplyTo(result.Track)
+			result.Success = true
+		}
+	}
+
+	logger.Ctx(r.Context()).Info("批量修改曲目元数据",
+		logger.Int("requested", len(trackIDs)),
+		logger.Int("updated", len(editable)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"updated": len(editable),
+		"failed":  len(results) - len(editable),
+		"results": results,
+	})
+}
+
+// normalizeTrackMetadataUpdate 去除字段首尾空白并校验长度、年份和封面路径，返回错误信息
+func normalizeTrackMetadataUpdate(u *model.TrackMetadataUpdate) string {
+	trim := func(s *string) {
+		if s != nil {
+			*s = strings.TrimSpace(*s)
+		}
+	}
+	trim(u.Title)
+	trim(u.Artist)
+	trim(u.Album)
+	trim(u.Genre)
+	trim(u.CoverArtPath)
+
+	if u.Title != nil && *u.Title == "" {
+		return "Title must not be empty"
+	}
+	if u.Title != nil && len(*u.Title) > model.MaxTrackFieldLength {
+		return "Title is too long"
+	}
+	if u.Artist != nil && len(*u.Artist) > model.MaxTrackFieldLength {
+		return "Artist too long"
+	}
+	if u.Album != nil && len(*u.Album) > model.MaxTrackFieldLength {
+		return "Album too long"
+	}
+	if u.Genre != nil && len(*u.Genre) > model.MaxGenreLength {
+		return "Genre too long"
+	}
+	// 0 表示清除年份
+	if u.Year != nil && *u.Year != 0 && !model.ValidYear(*u.Year) {
+		return "Invalid year"
+	}
+	// 封面只能引用已上传的封面，空字符串表示清除封面
+	if cover := u.CoverArtPath; cover != nil && *cover != "" {
+		if len(*cover) > model.MaxTrackFieldLength || !strings.HasPrefix(*cover, "/static/covers/") || path.Clean(*cover) != *cover {
+			return "Invalid cover path"
+		}
+	}
+	return ""
+}
+
+// uniqueTrackIDs 去除重复的曲目ID，保持原有顺序
+func uniqueTrackIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/model"
+)
+
+// trackStatuses 可以筛选的曲目处理状态
+var trackStatuses = map[string]bool{
+	"processing": true,
+	"completed":  true,
+	"failed":     true,
+}
+
+// trackSources 可以筛选的曲目来源
+var trackSources = map[string]bool{
+	"library": true,
+	"album":   true,
+}
+
+// parseTrackFilter 解析曲库列表的筛选、排序和分页参数，失败时已写入错误响应
+//
+//	artist、album       按艺术家、专辑名完全匹配
+//	status              processing、completed、failed
+//	source              library、album，未指定时按 includeAlbum 决定是否包含专辑来源的曲目
+//	q                   标题、艺术家或专辑包含的关键词
+//	tag                 可重复，需同时带有全部标签
+//	from、to            上传时间范围，YYYY-MM-DD（to 当天包含在内）或 RFC 3339
+//	sort、order         排序字段（created、updated、title、artist、album、duration、plays）和方向（asc、desc），
+//	                    文本字段默认升序，其他默认降序
+//	limit、offset       分页，未指定 limit 时返回全部
+func parseTrackFilter(w http.ResponseWriter, r *http.Request) (model.TrackFilter, bool) {
+	query := r.URL.Query()
+	filter := model.TrackFilter{
+		Artist:  strings.TrimSpace(query.Get("artist")),
+		Album:   strings.TrimSpace(query.Get("album")),
+		Status:  query.Get("status"),
+		Keyword: strings.TrimSpace(query.Get("q")),
+		Sort:    query.Get("sort"),
+	}
+	filter.Tags, _ = normalizeTagNames(query["tag"])
+
+	if filter.Status != "" && !trackStatuses[filter.Status] {
+		writeError(w, CodeBadRequest, "Invalid status")
+		return filter, false
+	}
+
+	switch source := query.Get("source"); {
+	case source != "":
+		if !trackSources[source] {
+			writeError(w, CodeBadRequest, "Invalid source")
+			return filter, false
+		}
+		filter.Sources = []string{source}
+		// 早期的曲目没有来源，视为直接上传
+		if source == "library" {
+			filter.Sources = append(filter.Sources, "")
+		}
+	case query.Get("includeAlbum") != "true":
+		filter.Sources = []string{"library", ""}
+	}
+
+	var err error
+	if raw := query.Get("from"); raw != "" {
+		if filter.CreatedAfter, _, err = parseFilterTime(raw); err != nil {
+			writeError(w, CodeBadRequest, "Invalid from date")
+			return filter, false
+		}
+	}
+	if raw := query.Get("to"); raw != "" {
+		to, dateOnly, err := parseFilterTime(raw)
+		if err != nil {
+			writeError(w, CodeBadRequest, "Invalid to date")
+			return filter, false
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.CreatedBefore = to
+	}
+
+	switch filter.Sort {
+	case "", model.TrackSortCreated, model.TrackSortUpdated, model.TrackSortDuration, model.TrackSortPlays:
+	case model.TrackSortTitle, model.TrackSortArtist, model.TrackSortAlbum:
+		filter.Ascending = true
+	default:
+		writeError(w, CodeBadRequest, "Invalid sort field")
+		return filter, false
+	}
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		filter.Ascending = true
+	case "desc":
+		filter.Ascending = false
+	default:
+		writeError(w, CodeBadRequest, "Invalid sort order")
+		return filter, false
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, CodeBadRequest, "Invalid limit")
+			return filter, false
+		}
+		filter.Limit = min(n, model.MaxTrackListLimit)
+	}
+	if raw := query.Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeError(w, CodeBadRequest, "Invalid offset")
+			return filter, false
+		}
+		filter.Offset = n
+	}
+	return filter, true
+}
+
+// parseFilterTime 解析 YYYY-MM-DD 或 RFC 3339 格式的时间，返回是否只有日期
+func parseFilterTime(raw string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", raw, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	return t, false, err
+}
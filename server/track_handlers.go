@@ -17,15 +17,28 @@ import (
 	"strings"
 	"time"
 
+	"Bt1QFM/cache"
 	"Bt1QFM/config"
 	"Bt1QFM/core/audio"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/core/backup"
+	"Bt1QFM/core/captcha"
+	"Bt1QFM/core/cover"
+	"Bt1QFM/core/mail"
+	"Bt1QFM/core/musicbrainz"
+	"Bt1QFM/core/netease"
+	"Bt1QFM/core/notification"
+	"Bt1QFM/core/radio"
+	"Bt1QFM/core/room"
+	"Bt1QFM/core/scrobble"
+	"Bt1QFM/core/social"
+	"Bt1QFM/core/storagegc"
 	"Bt1QFM/logger"
 	"Bt1QFM/model"
 	"Bt1QFM/repository"
 	"Bt1QFM/storage"
 
 	"github.com/gorilla/mux"
-	"github.com/minio/minio-go/v7"
 )
 
 // APIHandler 处理所有API请求
@@ -36,6 +49,29 @@ type APIHandler struct {
 	audioProcessor  *audio.FFmpegProcessor
 	mp3Processor    *audio.MP3Processor
 	streamProcessor *audio.StreamProcessor
+	fingerprintRepo repository.FingerprintRepository
+	tagRepo         repository.TagRepository
+	versionRepo     repository.TrackVersionRepository
+	commentRepo     repository.CommentRepository
+	coverFetcher    *cover.Fetcher
+	musicbrainz     *musicbrainz.Client
+	storageGC       *storagegc.Collector
+	reconciler      *storagegc.Reconciler
+	verifier        *storagegc.Verifier
+	reprocess       reprocessState
+	regenerate      regenerateQueue
+	mailer          mail.Sender
+	wsAuth          *wsAuthenticator
+	playbackTracker *scrobble.Tracker
+	radioService    *radio.Service
+	neteaseClient   *netease.Client
+	roomManager     *room.RoomManager
+	backupService   *backup.Service
+	socialService   *social.Service
+	notifications   *notification.Service
+	loginThrottle   *loginThrottle
+	streamHandler   *StreamHandler
+	captcha         captcha.Verifier
 	cfg             *config.Config
 }
 
@@ -46,6 +82,8 @@ func NewAPIHandler(
 	albumRepo repository.AlbumRepository,
 	audioProcessor *audio.FFmpegProcessor,
 	streamProcessor *audio.StreamProcessor,
+	coverFetcher *cover.Fetcher,
+	storageGC *storagegc.Collector,
 	cfg *config.Config,
 ) *APIHandler {
 	return &APIHandler{
@@ -55,6 +93,16 @@ func NewAPIHandler(
 		audioProcessor:  audioProcessor,
 		mp3Processor:    audio.NewMP3Processor(audioProcessor.FFmpegPath()),
 		streamProcessor: streamProcessor,
+		fingerprintRepo: repository.NewMySQLFingerprintRepository(),
+		tagRepo:         repository.NewMySQLTagRepository(),
+		versionRepo:     repository.NewMySQLTrackVersionRepository(),
+		commentRepo:     repository.NewMySQLCommentRepository(),
+		coverFetcher:    coverFetcher,
+		storageGC:       storageGC,
+		mailer:          mail.NewSender(cfg),
+		wsAuth:          newWSAuthenticator(cfg),
+		loginThrottle:   &loginThrottle{cfg: cfg},
+		neteaseClient:   netease.NewClient(),
 		cfg:             cfg,
 	}
 }
@@ -158,7 +206,7 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 		logger.Warn("请求体过大，拒绝处理",
 			logger.Int64("contentLength", r.ContentLength),
 			logger.Int64("maxSize", config.MaxFileSize))
-		http.Error(w, fmt.Sprintf("Request too large. Maximum size is %d MB", config.MaxFileSize>>20), http.StatusRequestEntityTooLarge)
+		writeError(w, CodeFileTooLarge, fmt.Sprintf("Request too large. Maximum size is %d MB", config.MaxFileSize>>20))
 		return
 	}
 
@@ -168,13 +216,13 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 		defer func() { <-uploadSemaphore }()
 	default:
 		logger.Warn("服务器繁忙，拒绝新的上传请求")
-		http.Error(w, "Server is busy, please try again later", http.StatusServiceUnavailable)
+		writeError(w, CodeServiceUnavailable, "Server is busy, please try again later")
 		return
 	}
 
 	if r.Method != http.MethodPost {
 		logger.Warn("不支持的请求方法", logger.String("method", r.Method))
-		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Only POST method is allowed")
 		return
 	}
 
@@ -182,7 +230,7 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 	userID, err := GetUserIDFromContext(r.Context())
 	if err != nil {
 		logger.Error("获取用户ID失败", logger.ErrorField(err))
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 	logger.Info("获取用户信息成功", logger.Int64("userId", userID))
@@ -218,7 +266,7 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 					logger.String("remoteAddr", r.RemoteAddr),
 					logger.Int64("contentLength", r.ContentLength),
 					logger.Duration("parseTime", time.Since(parseStart)))
-				http.Error(w, "Network connection issue. Please check your connection and try again.", http.StatusRequestTimeout)
+				writeError(w, CodeRequestTimeout, "Network connection issue. Please check your connection and try again.")
 				return
 			}
 
@@ -229,7 +277,7 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 					logger.ErrorField(err),
 					logger.Int64("contentLength", r.ContentLength),
 					logger.Int64("maxSize", config.MaxFileSize))
-				http.Error(w, fmt.Sprintf("File too large. Maximum size is %d MB", config.MaxFileSize>>20), http.StatusRequestEntityTooLarge)
+				writeError(w, CodeFileTooLarge, fmt.Sprintf("File too large. Maximum size is %d MB", config.MaxFileSize>>20))
 				return
 			}
 
@@ -238,7 +286,7 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 				logger.String("remoteAddr", r.RemoteAddr),
 				logger.Int64("contentLength", r.ContentLength),
 				logger.Duration("parseTime", time.Since(parseStart)))
-			http.Error(w, "Failed to parse upload form. Please check your file and try again.", http.StatusBadRequest)
+			writeError(w, CodeInvalidBody, "Failed to parse upload form. Please check your file and try again.")
 			return
 		}
 	case <-parseCtx.Done():
@@ -247,7 +295,7 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 			logger.Int64("contentLength", r.ContentLength),
 			logger.Duration("timeout", config.UploadTimeout),
 			logger.Duration("elapsed", time.Since(parseStart)))
-		http.Error(w, "Upload timeout. Please try with a smaller file or check your connection.", http.StatusRequestTimeout)
+		writeError(w, CodeRequestTimeout, "Upload timeout. Please try with a smaller file or check your connection.")
 		return
 	}
 
@@ -263,9 +311,9 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 			logger.ErrorField(err),
 			logger.String("remoteAddr", r.RemoteAddr))
 		if err == http.ErrMissingFile {
-			http.Error(w, "Missing audio file. Please select a file to upload.", http.StatusBadRequest)
+			writeError(w, CodeMissingField, "Missing audio file. Please select a file to upload.")
 		} else {
-			http.Error(w, "Failed to process uploaded file.", http.StatusBadRequest)
+			writeError(w, CodeInvalidBody, "Failed to process uploaded file.")
 		}
 		return
 	}
@@ -277,7 +325,7 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 			logger.Int64("size", trackHeader.Size),
 			logger.Int64("maxSize", config.MaxFileSize),
 			logger.String("filename", trackHeader.Filename))
-		http.Error(w, fmt.Sprintf("File too large. Maximum size is %d MB", config.MaxFileSize>>20), http.StatusBadRequest)
+		writeError(w, CodeFileTooLarge, fmt.Sprintf("File too large. Maximum size is %d MB", config.MaxFileSize>>20))
 		return
 	}
 
@@ -294,7 +342,13 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 		logger.Warn("不支持的文件类型",
 			logger.String("contentType", contentType),
 			logger.String("filename", trackHeader.Filename))
-		http.Error(w, "Invalid file type. Supported formats: MP3, WAV, FLAC, AAC, M4A.", http.StatusBadRequest)
+		writeError(w, CodeUnsupportedFileType, "Invalid file type. Supported formats: MP3, WAV, FLAC, AAC, M4A.")
+		return
+	}
+	if !h.checkStorageQuota(w, r, userID, trackHeader.Size) {
+		return
+	}
+	if !h.validateUploadedAudio(w, r, trackFile, trackHeader.Filename, contentType) {
 		return
 	}
 	logger.Info("文件验证完成",
@@ -307,11 +361,16 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 	title := r.FormValue("title")
 	if title == "" {
 		logger.Warn("缺少标题字段")
-		http.Error(w, "Missing 'title' in form", http.StatusBadRequest)
+		writeError(w, CodeMissingField, "Missing 'title' in form")
 		return
 	}
 	artist := r.FormValue("artist")
 	album := r.FormValue("album")
+	license := strings.TrimSpace(r.FormValue("license"))
+	if len(license) > model.MaxLicenseLength {
+		writeError(w, CodeBadRequest, "License text too long")
+		return
+	}
 	logger.Info("获取元数据完成",
 		logger.String("title", title),
 		logger.String("artist", artist),
@@ -324,15 +383,74 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 	if trackFileExt == "" {
 		trackFileExt = ".dat"
 	}
-	trackStoreFileName := safeBaseFilename + trackFileExt
+
+	// 按内容哈希存储源音频和 HLS 输出，相同内容的曲目共享存储
+	contentHash, err := hashContent(trackFile)
+	if err != nil {
+		logger.Error("计算文件哈希失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to process uploaded file.")
+		return
+	}
+	transcodeOpts := h.loadTranscodeOptions(r, userID)
+	streamID := contentStreamID(contentHash, transcodeOpts)
+	shared, err := h.findSharedStorage(r.Context(), contentHash, streamID)
+	if err != nil {
+		logger.Warn("查询共享存储失败，按新文件处理", logger.ErrorField(err))
+		shared = &sharedStorage{}
+	}
 
 	// 设置文件路径
-	minioTrackPath := "audio/" + trackStoreFileName
-	trackFilePath := "/static/audio/" + trackStoreFileName
+	minioTrackPath := "audio/" + contentHash + trackFileExt
+	trackFilePath := "/static/" + minioTrackPath
+	if shared.FilePath != "" {
+		trackFilePath = shared.FilePath
+		minioTrackPath = strings.TrimPrefix(shared.FilePath, "/static/")
+	}
 	logger.Info("生成文件名完成",
 		logger.Duration("耗时", time.Since(generateStart)),
-		logger.String("safeFilename", safeBaseFilename),
-		logger.String("minioPath", minioTrackPath))
+		logger.String("contentHash", contentHash),
+		logger.String("minioPath", minioTrackPath),
+		logger.Bool("sharedFile", shared.FilePath != ""),
+		logger.Bool("sharedStream", shared.Stream != nil))
+
+	// 计算音频指纹并检测重复上传（fpcalc 不可用时跳过）
+	fingerprint, err := h.computeFingerprintFromReader(trackFile, trackFileExt)
+	if err != nil {
+		logger.Warn("计算音频指纹失败，跳过重复检测", logger.ErrorField(err))
+	}
+	if _, err := trackFile.Seek(0, io.SeekStart); err != nil {
+		logger.Error("重置上传文件指针失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to process uploaded file.")
+		return
+	}
+	var duplicateOf []int64
+	if fingerprint != nil {
+		duplicateOf, err = h.findDuplicateTracks(userID, fingerprint)
+		if err != nil {
+			logger.Warn("重复检测失败", logger.ErrorField(err))
+		}
+		if len(duplicateOf) > 0 {
+			logger.Warn("检测到重复上传",
+				logger.Int64("userId", userID),
+				logger.String("title", title),
+				logger.Any("duplicateOf", duplicateOf))
+			if h.cfg.BlockDuplicateUploads {
+				writeErrorDetails(w, CodeDuplicateTrack, "Duplicate track: this audio already exists in your library", map[string]interface{}{
+					"duplicateOf": duplicateOf,
+				})
+				return
+			}
+		}
+	}
+
+	// 曲目记录未能提交时补偿删除本次写入对象存储的源音频和封面，避免留下没有记录引用的文件
+	var uploadedSource, uploadedCover string
+	committed := false
+	defer func() {
+		if !committed && (uploadedSource != "" || uploadedCover != "") {
+			h.rollbackUpload(contentHash, uploadedSource, uploadedCover)
+		}
+	}()
 
 	// 处理封面图片（如果存在）
 	var coverArtServePath string
@@ -343,14 +461,14 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 		// 验证封面文件
 		if coverHeader.Size > 10<<20 { // 10MB
 			logger.Warn("封面文件过大", logger.Int64("size", coverHeader.Size))
-			http.Error(w, "Cover file too large", http.StatusBadRequest)
+			writeError(w, CodeFileTooLarge, "Cover file too large")
 			return
 		}
 
 		coverContentType := coverHeader.Header.Get("Content-Type")
 		if !strings.HasPrefix(coverContentType, "image/") {
 			logger.Warn("不支持的封面文件类型", logger.String("contentType", coverContentType))
-			http.Error(w, "Invalid cover file type", http.StatusBadRequest)
+			writeError(w, CodeUnsupportedFileType, "Invalid cover file type")
 			return
 		}
 
@@ -362,25 +480,43 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 		minioCoverPath := "covers/" + coverStoreFileName
 		coverArtServePath = "/static/covers/" + coverStoreFileName
 
-		// 上传封面到MinIO
-		if err := h.uploadFileToMinio(coverFile, minioCoverPath, coverContentType); err != nil {
-			logger.Error("上传封面到MinIO失败", logger.ErrorField(err))
-			http.Error(w, "Failed to upload cover to MinIO", http.StatusInternalServerError)
+		// 上传封面到对象存储
+		if err := h.uploadFileToStorage(coverFile, minioCoverPath, coverContentType); err != nil {
+			logger.Error("上传封面到对象存储失败", logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to upload cover to storage")
 			return
 		}
+		uploadedCover = coverArtServePath
 		logger.Info("封面文件上传成功", logger.String("path", minioCoverPath))
 	} else if err != http.ErrMissingFile {
 		logger.Error("处理封面文件失败", logger.ErrorField(err))
-		http.Error(w, fmt.Sprintf("Error processing cover file: %v", err), http.StatusBadRequest)
+		writeError(w, CodeInvalidCover, fmt.Sprintf("Error processing cover file: %v", err))
 		return
 	}
 
+	// 先保存源音频再创建曲目记录，保存失败时不会留下找不到源文件的曲目
+	if shared.FilePath == "" {
+		if err := h.uploadFileToStorage(trackFile, minioTrackPath, contentType); err != nil {
+			logger.Error("上传源音频到对象存储失败",
+				logger.String("path", minioTrackPath),
+				logger.ErrorField(err))
+			writeError(w, CodeStorageUnavailable, "Failed to upload audio to storage")
+			return
+		}
+		uploadedSource = trackFilePath
+		if _, err := trackFile.Seek(0, io.SeekStart); err != nil {
+			logger.Error("重置上传文件指针失败", logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to process uploaded file.")
+			return
+		}
+	}
+
 	// 开始数据库事务
 	dbStart := time.Now()
-	tx, err := h.trackRepo.BeginTx()
+	tx, err := h.trackRepo.BeginTx(r.Context())
 	if err != nil {
 		logger.Error("开始数据库事务失败", logger.ErrorField(err))
-		http.Error(w, fmt.Sprintf("Failed to begin transaction: %v", err), http.StatusInternalServerError)
+		writeError(w, CodeInternal, fmt.Sprintf("Failed to begin transaction: %v", err))
 		return
 	}
 	defer h.trackRepo.RollbackTx(tx)
@@ -395,18 +531,26 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 		CoverArtPath: coverArtServePath,
 		Status:       "processing", // 添加状态字段
 		Source:       "library",    // 标记来源为library
+		Provenance:   model.ProvenanceUpload,
+		License:      license,
+		ContentHash:  contentHash,
+		FileSize:     trackHeader.Size,
+	}
+	if shared.Stream != nil {
+		newTrack.HLSPlaylistPath = shared.Stream.HLSPlaylistPath
+		newTrack.Duration = shared.Stream.Duration
 	}
 
 	// 在事务中创建曲目
-	trackID, err := h.trackRepo.CreateTrackWithTx(tx, newTrack)
+	trackID, err := h.trackRepo.CreateTrackWithTx(r.Context(), tx, newTrack)
 	if err != nil {
 		logger.Error("创建曲目记录失败",
 			logger.ErrorField(err),
 			logger.Int64("userId", userID))
 		if strings.Contains(strings.ToLower(err.Error()), "unique constraint") || strings.Contains(strings.ToLower(err.Error()), "duplicate entry") {
-			http.Error(w, fmt.Sprintf("Failed to create track: A track with a similar name or file path already exists for your account. Original error: %v", err), http.StatusConflict)
+			writeError(w, CodeDuplicateTrack, fmt.Sprintf("Failed to create track: A track with a similar name or file path already exists for your account. Original error: %v", err))
 		} else {
-			http.Error(w, fmt.Sprintf("Failed to create track entry in database: %v", err), http.StatusInternalServerError)
+			writeError(w, CodeInternal, fmt.Sprintf("Failed to create track entry in database: %v", err))
 		}
 		return
 	}
@@ -419,18 +563,53 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 	commitStart := time.Now()
 	if err := h.trackRepo.CommitTx(tx); err != nil {
 		logger.Error("提交事务失败", logger.ErrorField(err))
-		http.Error(w, fmt.Sprintf("Failed to commit transaction: %v", err), http.StatusInternalServerError)
+		writeError(w, CodeInternal, fmt.Sprintf("Failed to commit transaction: %v", err))
 		return
 	}
+	committed = true
 	logger.Info("事务提交成功", logger.Duration("耗时", time.Since(commitStart)))
 
+	if fingerprint != nil {
+		h.saveTrackFingerprint(trackID, userID, fingerprint)
+	}
+
+	// 未上传封面时后台从外部来源获取
+	if coverArtServePath == "" {
+		h.coverFetcher.Enqueue(cover.Job{
+			Kind:  cover.KindTrack,
+			ID:    trackID,
+			Query: cover.Query{Artist: artist, Album: album, Title: title},
+		})
+	}
+
+	// 已有相同内容和转码参数的流，直接复用，无需再次上传和转码
+	if shared.Stream != nil {
+		if err := h.trackRepo.UpdateTrackStatus(r.Context(), trackID, "completed"); err != nil {
+			logger.Warn("更新曲目状态失败", logger.Int64("trackId", trackID), logger.ErrorField(err))
+		}
+		newTrack.Status = "completed"
+	}
+
 	// 立即返回响应
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	resp := map[string]interface{}{
 		"message": "Track upload started",
 		"trackId": trackID,
 		"track":   newTrack,
-	})
+	}
+	if len(duplicateOf) > 0 {
+		resp["duplicateOf"] = duplicateOf
+		resp["warning"] = "This audio appears to duplicate existing tracks in your library"
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+
+	if shared.Stream != nil {
+		logger.Info("复用已有的共享流",
+			logger.Int64("trackId", trackID),
+			logger.Int64("sharedWith", shared.Stream.ID),
+			logger.String("streamId", streamID))
+		return
+	}
 
 	// 将文件内容读取到缓冲区，避免文件关闭后无法读取
 	fileBuffer := &bytes.Buffer{}
@@ -438,28 +617,37 @@ func (h *APIHandler) UploadTrackHandler(w http.ResponseWriter, r *http.Request)
 		logger.Error("读取文件到缓冲区失败",
 			logger.ErrorField(err),
 			logger.Int64("trackId", trackID))
-		h.trackRepo.UpdateTrackStatus(trackID, "failed")
+		h.trackRepo.UpdateTrackStatus(r.Context(), trackID, "failed")
 		return
 	}
 
 	// 启动异步处理
 	go func() {
 		// 处理音频文件上传
-		if err := h.processAudioFileAsync(fileBuffer, trackHeader, minioTrackPath, contentType, trackID, safeBaseFilename); err != nil {
+		if err := h.processAudioFileAsync(fileBuffer, minioTrackPath, trackID, streamID, transcodeOpts); err != nil {
 			logger.Error("异步处理音频文件失败",
 				logger.ErrorField(err),
 				logger.Int64("trackId", trackID))
 			// 更新track状态为失败
-			h.trackRepo.UpdateTrackStatus(trackID, "failed")
+			h.trackRepo.UpdateTrackStatus(context.Background(), trackID, "failed")
+			return
+		}
+		// 交给转码 worker 时由 worker 回写状态
+		if h.dispatchesToWorker() {
 			return
 		}
 		// 更新track状态为完成
-		h.trackRepo.UpdateTrackStatus(trackID, "completed")
+		h.trackRepo.UpdateTrackStatus(context.Background(), trackID, "completed")
 	}()
 }
 
-// processAudioFileAsync 异步处理音频文件
-func (h *APIHandler) processAudioFileAsync(fileBuffer *bytes.Buffer, trackHeader *multipart.FileHeader, minioTrackPath, contentType string, trackID int64, safeBaseFilename string) error {
+// processAudioFileAsync 异步处理音频文件，源音频已在创建曲目前保存到对象存储
+func (h *APIHandler) processAudioFileAsync(fileBuffer *bytes.Buffer, minioTrackPath string, trackID int64, streamID string, opts *audio.TranscodeOptions) error {
+	// 转码 worker 从对象存储读取源音频
+	if h.dispatchesToWorker() {
+		return h.enqueueTranscode(trackID, streamID, minioTrackPath, opts)
+	}
+
 	// 创建临时文件
 	tempFile, err := os.CreateTemp("", "upload-*")
 	if err != nil {
@@ -486,34 +674,24 @@ func (h *APIHandler) processAudioFileAsync(fileBuffer *bytes.Buffer, trackHeader
 		return fmt.Errorf("写入缓冲区到临时文件失败: %v", err)
 	}
 
-	// 重置文件指针
-	if _, err := tempFile.Seek(0, 0); err != nil {
-		return fmt.Errorf("重置文件指针失败: %v", err)
-	}
-
-
-	// 使用共享的流处理器处理音频（避免每次创建新实例）
-	streamID := strconv.FormatInt(trackID, 10)
-
 	// 重置文件指针以供流处理使用
 	if _, err := tempFile.Seek(0, 0); err != nil {
 		return fmt.Errorf("重置文件指针失败: %v", err)
 	}
 
-	// 启动流处理
-	if err := h.streamProcessor.StreamProcess(context.Background(), streamID, tempFilePath, false); err != nil {
+	// 启动流处理，应用用户的转码偏好
+	if err := h.streamProcessor.StreamProcessWithOptions(context.Background(), streamID, tempFilePath, false, opts); err != nil {
 		logger.Error("流处理失败",
 			logger.Int64("trackId", trackID),
 			logger.ErrorField(err))
 		return fmt.Errorf("流处理失败: %v", err)
 	}
 
-	// 生成HLS流
-	hlsStreamDir := filepath.Join("streams", safeBaseFilename)
-	m3u8ServePath := "/static/" + strings.ReplaceAll(filepath.ToSlash(hlsStreamDir), "\\", "/") + "/playlist.m3u8"
+	// 流按内容哈希存储，由流处理接口提供
+	m3u8ServePath := streamPlaylistPath(streamID)
 
 	// 更新数据库中的HLS路径
-	if err := h.trackRepo.UpdateTrackHLSPath(trackID, m3u8ServePath, 0); err != nil {
+	if err := h.trackRepo.UpdateTrackHLSPath(context.Background(), trackID, m3u8ServePath, 0); err != nil {
 		logger.Error("更新HLS路径失败",
 			logger.ErrorField(err),
 			logger.Int64("trackId", trackID),
@@ -524,14 +702,13 @@ func (h *APIHandler) processAudioFileAsync(fileBuffer *bytes.Buffer, trackHeader
 	return nil
 }
 
-// uploadFileToMinio 上传文件到MinIO
-func (h *APIHandler) uploadFileToMinio(file multipart.File, objectPath, contentType string) error {
-	client := storage.GetMinioClient()
-	if client == nil {
-		return fmt.Errorf("MinIO client not initialized")
+// uploadFileToStorage 上传文件到对象存储
+func (h *APIHandler) uploadFileToStorage(file multipart.File, objectPath, contentType string) error {
+	store := storage.GetStorage()
+	if store == nil {
+		return fmt.Errorf("storage not initialized")
 	}
 
-	cfg := config.Load()
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -547,70 +724,127 @@ func (h *APIHandler) uploadFileToMinio(file multipart.File, objectPath, contentT
 		return fmt.Errorf("failed to read file: %v", err)
 	}
 
-	opts := minio.PutObjectOptions{
-		ContentType:      contentType,
-		DisableMultipart: true,
+	if err := store.Put(ctx, objectPath, bytes.NewReader(buffer.Bytes()), size, contentType); err != nil {
+		return fmt.Errorf("failed to upload to storage: %v", err)
 	}
 
-	_, err = client.PutObject(ctx, cfg.MinioBucket, objectPath, bytes.NewReader(buffer.Bytes()), size, opts)
-	if err != nil {
-		return fmt.Errorf("failed to upload to MinIO: %v", err)
+	return nil
+}
+
+// uploadBytesToStorage 上传内存中的数据到对象存储
+func (h *APIHandler) uploadBytesToStorage(data []byte, objectPath, contentType string) error {
+	store := storage.GetStorage()
+	if store == nil {
+		return fmt.Errorf("storage not initialized")
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := store.Put(ctx, objectPath, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return fmt.Errorf("failed to upload to storage: %v", err)
+	}
 	return nil
 }
 
 // GetTracksHandler retrieves and returns a list of all tracks for the current user.
 func (h *APIHandler) GetTracksHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Only GET method is allowed")
 		return
 	}
 
 	// Get user ID from context (set by AuthMiddleware)
 	userID, err := GetUserIDFromContext(r.Context())
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	// 获取includeAlbum参数（是否包含专辑来源的tracks）
-	includeAlbum := r.URL.Query().Get("includeAlbum") == "true"
-
-	tracks, err := h.trackRepo.GetAllTracksByUserID(userID)
+	// 筛选、排序和分页在 SQL 中完成，未指定 includeAlbum=true 或 source 时不包含专辑来源的曲目
+	filter, ok := parseTrackFilter(w, r)
+	if !ok {
+		return
+	}
+	tracks, total, err := h.trackRepo.ListTracks(r.Context(), userID, filter)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to retrieve tracks for user %d: %v", userID, err), http.StatusInternalServerError)
+		writeError(w, CodeInternal, fmt.Sprintf("Failed to retrieve tracks for user %d: %v", userID, err))
 		return
 	}
+	if filter.Limit > 0 {
+		w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	}
 
-	// 根据includeAlbum参数过滤
-	if !includeAlbum {
-		filteredTracks := make([]*model.Track, 0)
-		for _, track := range tracks {
-			if track.Source == "library" || track.Source == "" {
-				filteredTracks = append(filteredTracks, track)
-			}
-		}
-		tracks = filteredTracks
+	// 填充曲目标签
+	trackIDs := make([]int64, len(tracks))
+	for i, track := range tracks {
+		trackIDs[i] = track.ID
+	}
+	tagsByTrack, err := h.tagRepo.GetTagsByTrackIDs(r.Context(), trackIDs)
+	if err != nil {
+		logger.Ctx(r.Context()).Warn("获取曲目标签失败", logger.ErrorField(err))
+	}
+	for _, track := range tracks {
+		track.Tags = tagsByTrack[track.ID]
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tracks)
+	// 填充评论数
+	sourceIDs := make([]string, len(tracks))
+	for i, track := range tracks {
+		sourceIDs[i] = strconv.FormatInt(track.ID, 10)
+	}
+	commentCounts, err := h.commentRepo.CountComments(r.Context(), cache.SourceLocal, sourceIDs)
+	if err != nil {
+		logger.Ctx(r.Context()).Warn("获取曲目评论数失败", logger.ErrorField(err))
+	}
+	for i, track := range tracks {
+		track.CommentCount = commentCounts[sourceIDs[i]]
+	}
+
+	writeJSONWithETag(w, r, tracks)
 }
 
 // StreamHandler serves the HLS playlist for a given track ID.
 
-// UploadCoverHandler 处理封面图片上传
+// coverProcessTimeout 处理单张封面的超时时间
+const coverProcessTimeout = 60 * time.Second
+
+// coverVariantInfo 封面变体信息
+type coverVariantInfo struct {
+	Size   int    `json:"size"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Format string `json:"format"`
+	Path   string `json:"path"`
+}
+
+// coverUploadResponse 封面上传响应，srcset 可直接用于 <img srcset>
+type coverUploadResponse struct {
+	CoverPath      string             `json:"coverPath"` // 最大尺寸的 WebP
+	Hash           string             `json:"hash"`
+	Width          int                `json:"width"`
+	Height         int                `json:"height"`
+	Srcset         string             `json:"srcset"`
+	FallbackSrcset string             `json:"fallbackSrcset"` // JPEG，供不支持 WebP 的客户端使用
+	Variants       []coverVariantInfo `json:"variants"`
+}
+
+// UploadCoverHandler 处理封面图片上传，生成多尺寸 WebP/JPEG 变体并存储在 covers/users/{userId}/{hash}/ 下
 func (h *APIHandler) UploadCoverHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	const maxFileSize = 10 << 20 // 10MB
 	if err := r.ParseMultipartForm(maxFileSize); err != nil {
 		logger.Error("解析表单失败", logger.ErrorField(err))
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "Failed to parse form")
 		return
 	}
 
@@ -620,54 +854,92 @@ func (h *APIHandler) UploadCoverHandler(w http.ResponseWriter, r *http.Request)
 		logger.Warn("缺少必要字段",
 			logger.Bool("hasArtist", artist != ""),
 			logger.Bool("hasAlbum", album != ""))
-		http.Error(w, "Artist and album are required", http.StatusBadRequest)
+		writeError(w, CodeMissingField, "Artist and album are required")
 		return
 	}
 
 	file, header, err := r.FormFile("cover")
 	if err != nil {
 		logger.Error("获取文件失败", logger.ErrorField(err))
-		http.Error(w, "Failed to get cover file", http.StatusBadRequest)
+		writeError(w, CodeInvalidCover, "Failed to get cover file")
 		return
 	}
 	defer file.Close()
 
 	if header.Size > maxFileSize {
 		logger.Warn("文件过大", logger.Int64("size", header.Size))
-		http.Error(w, "File too large", http.StatusBadRequest)
+		writeError(w, CodeFileTooLarge, "File too large")
 		return
 	}
 
 	contentType := header.Header.Get("Content-Type")
 	if !strings.HasPrefix(contentType, "image/") {
 		logger.Warn("不支持的文件类型", logger.String("contentType", contentType))
-		http.Error(w, "Only image files are allowed", http.StatusBadRequest)
+		writeError(w, CodeUnsupportedFileType, "Only image files are allowed")
 		return
 	}
 
-	safeFilename := generateSafeFilenamePrefix(artist, album, "")
-	coverFilename := fmt.Sprintf("%s_cover%s", safeFilename, filepath.Ext(header.Filename))
-
-	// MinIO路径和服务路径
-	minioCoverPath := "covers/" + coverFilename
-	servePath := "/static/covers/" + coverFilename
+	data, err := io.ReadAll(io.LimitReader(file, maxFileSize+1))
+	if err != nil || len(data) > maxFileSize {
+		logger.Error("读取封面文件失败", logger.ErrorField(err))
+		writeError(w, CodeInvalidCover, "Failed to read cover file")
+		return
+	}
 
-	// 上传到MinIO
-	if err := h.uploadFileToMinio(file, minioCoverPath, contentType); err != nil {
-		logger.Error("上传到MinIO失败", logger.ErrorField(err))
-		http.Error(w, "Failed to upload cover to MinIO", http.StatusInternalServerError)
+	// 校验尺寸并生成各尺寸变体，重新编码同时去除 EXIF
+	ctx, cancel := context.WithTimeout(r.Context(), coverProcessTimeout)
+	defer cancel()
+	processed, err := cover.ProcessCover(ctx, h.cfg.FFmpegPath, data)
+	if err != nil {
+		logger.Warn("封面处理失败",
+			logger.String("artist", artist),
+			logger.String("album", album),
+			logger.ErrorField(err))
+		writeError(w, CodeInvalidCover, fmt.Sprintf("Invalid cover image: %v", err))
 		return
 	}
 
-	response := map[string]string{
-		"coverPath": servePath,
+	resp := coverUploadResponse{
+		Hash:   processed.Hash,
+		Width:  processed.Width,
+		Height: processed.Height,
 	}
+	var webpSrcset, jpegSrcset []string
+	for _, v := range processed.Variants {
+		objectPath := processed.ObjectPathIn(userCoverDir(userID), v)
+		if err := h.uploadBytesToStorage(v.Data, objectPath, v.ContentType); err != nil {
+			logger.Error("上传封面变体到对象存储失败",
+				logger.String("path", objectPath),
+				logger.ErrorField(err))
+			writeError(w, CodeInternal, "Failed to upload cover to storage")
+			return
+		}
+		servePath := "/static/" + objectPath
+		entry := fmt.Sprintf("%s %dw", servePath, v.Width)
+		if v.Format == "webp" {
+			webpSrcset = append(webpSrcset, entry)
+			resp.CoverPath = servePath
+		} else {
+			jpegSrcset = append(jpegSrcset, entry)
+		}
+		resp.Variants = append(resp.Variants, coverVariantInfo{
+			Size:   v.Size,
+			Width:  v.Width,
+			Height: v.Height,
+			Format: v.Format,
+			Path:   servePath,
+		})
+	}
+	resp.Srcset = strings.Join(webpSrcset, ", ")
+	resp.FallbackSrcset = strings.Join(jpegSrcset, ", ")
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(resp)
 	logger.Info("封面上传成功",
 		logger.String("artist", artist),
 		logger.String("album", album),
-		logger.String("path", minioCoverPath))
+		logger.String("hash", processed.Hash),
+		logger.Int("variants", len(processed.Variants)))
 }
 
 // UpdateTrackPositionHandler 更新专辑中歌曲的位置
@@ -681,7 +953,7 @@ func (h *APIHandler) UpdateTrackPositionHandler(w http.ResponseWriter, r *http.R
 		logger.Warn("Invalid method for update track position",
 			logger.String("method", r.Method),
 		)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -692,7 +964,7 @@ func (h *APIHandler) UpdateTrackPositionHandler(w http.ResponseWriter, r *http.R
 			logger.String("id", vars["id"]),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid album ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid album ID")
 		return
 	}
 
@@ -702,19 +974,27 @@ func (h *APIHandler) UpdateTrackPositionHandler(w http.ResponseWriter, r *http.R
 			logger.String("id", vars["track_id"]),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid track ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid track ID")
 		return
 	}
 
+	// disc_number、track_number 可选，同时提供时一并更新碟号和曲号（曲号 0 表示未知）
 	var req struct {
-		NewPosition int `json:"new_position"`
+		NewPosition int  `json:"new_position"`
+		DiscNumber  *int `json:"disc_number"`
+		TrackNumber *int `json:"track_number"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error("Failed to decode request body",
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if (req.DiscNumber == nil) != (req.TrackNumber == nil) ||
+		(req.DiscNumber != nil && (*req.DiscNumber < 1 || *req.TrackNumber < 0)) {
+		writeError(w, CodeBadRequest, "Invalid track placement")
 		return
 	}
 
@@ -730,9 +1010,20 @@ func (h *APIHandler) UpdateTrackPositionHandler(w http.ResponseWriter, r *http.R
 			logger.Int64("trackId", trackID),
 			logger.ErrorField(err),
 		)
-		http.Error(w, "Failed to update track position", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to update track position")
 		return
 	}
+	if req.DiscNumber != nil {
+		if err := h.albumRepo.UpdateTrackNumbering(r.Context(), albumID, trackID, *req.DiscNumber, *req.TrackNumber); err != nil {
+			logger.Error("Failed to update track numbering",
+				logger.Int64("albumId", albumID),
+				logger.Int64("trackId", trackID),
+				logger.ErrorField(err),
+			)
+			writeError(w, CodeInternal, "Failed to update track position")
+			return
+		}
+	}
 
 	logger.Info("Track position updated successfully",
 		logger.Int64("albumId", albumID),
@@ -742,34 +1033,33 @@ func (h *APIHandler) UpdateTrackPositionHandler(w http.ResponseWriter, r *http.R
 	w.WriteHeader(http.StatusOK)
 }
 
-// downloadFileFromMinio 从MinIO下载文件到本地
-func (h *APIHandler) downloadFileFromMinio(objectPath, localPath string) error {
-	client := storage.GetMinioClient()
-	if client == nil {
-		return fmt.Errorf("MinIO client not initialized")
+// downloadFileFromStorage 从对象存储下载文件到本地
+func (h *APIHandler) downloadFileFromStorage(objectPath, localPath string) error {
+	store := storage.GetStorage()
+	if store == nil {
+		return fmt.Errorf("storage not initialized")
 	}
 
-	cfg := config.Load()
 	// 增加超时时间到5分钟，适应大文件下载
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	logger.Info("开始从MinIO下载文件",
+	logger.Info("开始从对象存储下载文件",
 		logger.String("objectPath", objectPath),
 		logger.String("localPath", localPath))
 
 	// 获取文件信息
-	stat, err := client.StatObject(ctx, cfg.MinioBucket, objectPath, minio.StatObjectOptions{})
+	stat, err := store.Stat(ctx, objectPath)
 	if err != nil {
-		return fmt.Errorf("failed to get object stat from MinIO: %v", err)
+		return fmt.Errorf("failed to get object stat from storage: %v", err)
 	}
 	logger.Info("文件信息",
 		logger.Int64("size", stat.Size),
 		logger.Float64("sizeMB", float64(stat.Size)/(1024*1024)))
 
-	object, err := client.GetObject(ctx, cfg.MinioBucket, objectPath, minio.GetObjectOptions{})
+	object, err := store.Get(ctx, objectPath)
 	if err != nil {
-		return fmt.Errorf("failed to get object from MinIO: %v", err)
+		return fmt.Errorf("failed to get object from storage: %v", err)
 	}
 	defer object.Close()
 
@@ -810,7 +1100,7 @@ func (h *APIHandler) downloadFileFromMinio(objectPath, localPath string) error {
 			break
 		}
 		if readErr != nil {
-			return fmt.Errorf("failed to read from MinIO: %v", readErr)
+			return fmt.Errorf("failed to read from storage: %v", readErr)
 		}
 
 		// 检查上下文是否已取消
@@ -844,49 +1134,14 @@ func (h *APIHandler) RegisterRoutes(router *mux.Router) {
 	// 更新音轨顺序
 	router.HandleFunc("/albums/{id}/tracks/{track_id}/position", h.UpdateTrackPositionHandler).Methods(http.MethodPut)
 
-	// 静态文件服务（MinIO）
-	router.PathPrefix("/static/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		objectPath := strings.TrimPrefix(r.URL.Path, "/static/")
-		client := storage.GetMinioClient()
-		if client == nil {
-			http.Error(w, "MinIO client not available", http.StatusInternalServerError)
-			return
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		object, err := client.GetObject(ctx, h.cfg.MinioBucket, objectPath, minio.GetObjectOptions{})
-		if err != nil {
-			http.Error(w, "File not found", http.StatusNotFound)
-			return
-		}
-		defer object.Close()
-
-		var contentType string
-		if strings.HasPrefix(objectPath, "covers/") {
-			contentType = "image/jpeg"
-		} else if strings.HasPrefix(objectPath, "audio/") {
-			contentType = "audio/mpeg"
-		} else {
-			contentType = "application/octet-stream"
-		}
-
-		w.Header().Set("Content-Type", contentType)
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Cache-Control", "public, max-age=31536000") // 缓存一年
-
-		_, err = io.Copy(w, object)
-		if err != nil {
-			logger.Error("Error serving file from MinIO", logger.ErrorField(err))
-		}
-	})
+	// 静态文件服务（对象存储）
+	router.PathPrefix("/static/").Handler(NewStaticHandler(h.cfg))
 }
 
 // DeleteTrackHandler 软删除track（设置state=0）
 func (h *APIHandler) DeleteTrackHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -894,7 +1149,7 @@ func (h *APIHandler) DeleteTrackHandler(w http.ResponseWriter, r *http.Request)
 	userID, err := GetUserIDFromContext(r.Context())
 	if err != nil {
 		logger.Error("获取用户ID失败", logger.ErrorField(err))
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -905,22 +1160,22 @@ func (h *APIHandler) DeleteTrackHandler(w http.ResponseWriter, r *http.Request)
 		logger.Error("Invalid track ID",
 			logger.String("id", vars["id"]),
 			logger.ErrorField(err))
-		http.Error(w, "Invalid track ID", http.StatusBadRequest)
+		writeError(w, CodeInvalidID, "Invalid track ID")
 		return
 	}
 
 	// 验证track是否属于当前用户
-	track, err := h.trackRepo.GetTrackByID(trackID)
+	track, err := h.trackRepo.GetTrackByID(r.Context(), trackID)
 	if err != nil {
 		logger.Error("获取track失败",
 			logger.Int64("trackId", trackID),
 			logger.ErrorField(err))
-		http.Error(w, "Failed to get track", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to get track")
 		return
 	}
 
 	if track == nil {
-		http.Error(w, "Track not found", http.StatusNotFound)
+		writeError(w, CodeTrackNotFound, "Track not found")
 		return
 	}
 
@@ -929,16 +1184,16 @@ func (h *APIHandler) DeleteTrackHandler(w http.ResponseWriter, r *http.Request)
 			logger.Int64("userId", userID),
 			logger.Int64("trackId", trackID),
 			logger.Int64("trackUserId", track.UserID))
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		writeError(w, CodeForbidden, "Forbidden")
 		return
 	}
 
 	// 软删除track（设置state=0）
-	if err := h.trackRepo.UpdateTrackState(trackID, 0); err != nil {
+	if err := h.trackRepo.UpdateTrackState(r.Context(), trackID, 0); err != nil {
 		logger.Error("软删除track失败",
 			logger.Int64("trackId", trackID),
 			logger.ErrorField(err))
-		http.Error(w, "Failed to delete track", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to delete track")
 		return
 	}
 
@@ -946,8 +1201,142 @@ func (h *APIHandler) DeleteTrackHandler(w http.ResponseWriter, r *http.Request)
 		logger.Int64("trackId", trackID),
 		logger.Int64("userId", userID))
 
+	// 存储在回收站保留期结束、曲目被彻底删除时才释放，以便恢复
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Track deleted successfully",
+		"message": "Track moved to trash",
 	})
 }
+
+// UpdateTrackLicenseHandler 更新曲目的许可/署名信息（仅限上传者）
+func (h *APIHandler) UpdateTrackLicenseHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		logger.Error("获取用户ID失败", logger.ErrorField(err))
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	trackID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid track ID")
+		return
+	}
+
+	var req struct {
+		License string `json:"license"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	license := strings.TrimSpace(req.License)
+	if len(license) > model.MaxLicenseLength {
+		writeError(w, CodeBadRequest, "License text too long")
+		return
+	}
+
+	track, err := h.trackRepo.GetTrackByID(r.Context(), trackID)
+	if err != nil {
+		logger.Error("获取track失败",
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get track")
+		return
+	}
+	if track == nil || track.State == 0 {
+		writeError(w, CodeTrackNotFound, "Track not found")
+		return
+	}
+	if track.UserID != userID {
+		logger.Warn("用户尝试修改不属于自己的track许可信息",
+			logger.Int64("userId", userID),
+			logger.Int64("trackId", trackID),
+			logger.Int64("trackUserId", track.UserID))
+		writeError(w, CodeForbidden, "Forbidden")
+		return
+	}
+
+	if err := h.trackRepo.UpdateTrackLicense(r.Context(), trackID, license); err != nil {
+		logger.Error("更新track许可信息失败",
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to update license")
+		return
+	}
+	track.License = license
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(track)
+}
+
+// GetPublicTrackHandler 获取曲目的公开信息（含出处与许可），用于分享链接，无需登录
+// 需携带 /api/tracks/{id}/raw-url 签发的 expires 和 signature，不能通过遍历 ID 获取他人的曲目信息
+func (h *APIHandler) GetPublicTrackHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	trackID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid track ID")
+		return
+	}
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || !auth.VerifyStreamSignature(rawTrackPath(trackID), expires, query.Get("signature")) {
+		writeError(w, CodeInvalidSignature, "Invalid or expired signature")
+		return
+	}
+
+	track, err := h.trackRepo.GetTrackByID(r.Context(), trackID)
+	if err != nil {
+		logger.Error("获取track失败",
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get track")
+		return
+	}
+	if track == nil || track.State == 0 {
+		writeError(w, CodeTrackNotFound, "Track not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(track.PublicInfo())
+}
+
+// GetTrackWaveformHandler 获取曲目的波形峰值数据，供前端渲染波形进度条
+func (h *APIHandler) GetTrackWaveformHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	trackID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid track ID")
+		return
+	}
+
+	track, err := h.trackRepo.GetTrackByID(r.Context(), trackID)
+	if err != nil {
+		logger.Error("获取track失败",
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get track")
+		return
+	}
+	if track == nil || track.State == 0 {
+		writeError(w, CodeTrackNotFound, "Track not found")
+		return
+	}
+
+	data, contentType, err := h.streamProcessor.StreamGet(track.StreamID(), audio.WaveformFileName, false)
+	if err != nil {
+		logger.Debug("波形数据不存在",
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err))
+		writeError(w, CodeNotFound, "Waveform not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(data)
+}
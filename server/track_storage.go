@@ -0,0 +1,323 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/core/audio"
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+	"Bt1QFM/storage"
+)
+
+// hashContent 计算上传内容的 SHA-256，完成后将读取位置重置到开头
+func hashContent(r io.ReadSeeker) (string, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// contentStreamID 按内容哈希和转码选项生成共享的流ID，不同转码偏好生成不同的流
+func contentStreamID(contentHash string, opts *audio.TranscodeOptions) string {
+	if key := opts.Key(); key != "" {
+		return contentHash + "-" + key
+	}
+	return contentHash
+}
+
+// streamPlaylistPath 返回流的播放列表路径
+func streamPlaylistPath(streamID string) string {
+	return "/streams/" + streamID + "/playlist.m3u8"
+}
+
+// storageReservationTTL 上传复用共享存储的预留时长，需覆盖从查找共享存储到曲目记录提交的整个上传过程
+// 预留期间释放存储时保留对象，上传失败留下的无引用对象由存储回收处理
+const storageReservationTTL = 30 * time.Minute
+
+// sharedStorage 与上传内容相同的已有存储
+type sharedStorage struct {
+	FilePath string       // 已存储的源音频路径，为空表示需要上传
+	Stream   *model.Track // 已使用相同流ID的曲目，为空表示需要转码
+}
+
+// sharedUpload 上传曲目的存储处理参数
+type sharedUpload struct {
+	minioPath    string // 源音频对象路径
+	contentType  string
+	uploadSource bool // 源音频尚未存储，需要上传
+	transcode    bool // 尚无可复用的流，需要转码
+	streamID     string
+	opts         *audio.TranscodeOptions
+}
+
+// findSharedStorage 查找内容哈希相同的曲目，复用其源音频和 HLS 输出
+// 查找前先预留该内容的存储，避免找到的对象在曲目记录提交前被并发的释放删除
+func (h *APIHandler) findSharedStorage(ctx context.Context, contentHash, streamID string) (*sharedStorage, error) {
+	if err := h.trackRepo.ReserveContentStorage(ctx, contentHash, storageReservationTTL); err != nil {
+		return nil, err
+	}
+	tracks, err := h.trackRepo.GetTracksByContentHash(ctx, contentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := &sharedStorage{}
+	playlistPath := streamPlaylistPath(streamID)
+	for _, t := range tracks {
+		if shared.FilePath == "" && t.FilePath != "" {
+			shared.FilePath = t.FilePath
+		}
+		if shared.Stream == nil && t.HLSPlaylistPath == playlistPath && t.Status != "failed" {
+			shared.Stream = t
+		}
+	}
+	return shared, nil
+}
+
+// releaseTrackStorage 若曲目使用的源音频或 HLS 流已无其他有效曲目引用则删除
+// releaseFile 为 false 时只释放流，用于曲目切换到新的流之后
+// 检查引用和删除对象在同一个事务中持有内容哈希的行锁，与上传的预留互斥
+func (h *APIHandler) releaseTrackStorage(ctx context.Context, track *model.Track, releaseFile bool) {
+	if track.ContentHash == "" {
+		return
+	}
+
+	tx, err := h.trackRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Warn("锁定共享存储失败，跳过清理",
+			logger.Int64("trackId", track.ID),
+			logger.ErrorField(err))
+		return
+	}
+	defer h.trackRepo.RollbackTx(tx)
+
+	reserved, err := h.trackRepo.LockContentStorageWithTx(ctx, tx, track.ContentHash)
+	if err != nil {
+		logger.Warn("锁定共享存储失败，跳过清理",
+			logger.Int64("trackId", track.ID),
+			logger.ErrorField(err))
+		return
+	}
+	if reserved {
+		logger.Info("共享存储已被进行中的上传预留，跳过清理",
+			logger.Int64("trackId", track.ID),
+			logger.String("contentHash", track.ContentHash))
+		return
+	}
+
+	others, err := h.trackRepo.GetTracksByContentHash(ctx, track.ContentHash)
+	if err != nil {
+		logger.Warn("查询共享存储引用失败，跳过清理",
+			logger.Int64("trackId", track.ID),
+			logger.ErrorField(err))
+		return
+	}
+
+	// 仍可恢复的旧版本同样引用存储
+	versions, err := h.versionRepo.GetVersionsByContentHash(ctx, track.ContentHash)
+	if err != nil {
+		logger.Warn("查询曲目版本的存储引用失败，跳过清理",
+			logger.Int64("trackId", track.ID),
+			logger.ErrorField(err))
+		return
+	}
+	for _, v := range versions {
+		others = append(others, v.StorageRef())
+	}
+
+	fileReferenced, streamReferenced, hashReferenced := false, false, false
+	for _, t := range others {
+		// 旧版本和回滚的存储没有曲目ID，不能把其他版本的引用当作自身跳过
+		if track.ID != 0 && t.ID == track.ID {
+			continue
+		}
+		hashReferenced = true
+		if t.FilePath == track.FilePath {
+			fileReferenced = true
+		}
+		if t.HLSPlaylistPath == track.HLSPlaylistPath {
+			streamReferenced = true
+		}
+	}
+
+	if releaseFile && !fileReferenced && track.FilePath != "" {
+		objectPath := strings.TrimPrefix(track.FilePath, "/static/")
+		if err := h.removeStorageObjects(objectPath); err != nil {
+			logger.Warn("删除源音频失败",
+				logger.String("path", objectPath),
+				logger.ErrorField(err))
+		} else {
+			logger.Info("源音频已无引用，已删除", logger.String("path", objectPath))
+		}
+	}
+	if !streamReferenced && track.HLSPlaylistPath != "" {
+		h.removeStream(track.StreamID())
+	}
+
+	if releaseFile && !hashReferenced {
+		if err := h.trackRepo.DeleteContentStorageRefWithTx(ctx, tx, track.ContentHash); err != nil {
+			logger.Warn("删除共享存储引用记录失败",
+				logger.String("contentHash", track.ContentHash),
+				logger.ErrorField(err))
+			return
+		}
+	}
+	if err := h.trackRepo.CommitTx(tx); err != nil {
+		logger.Warn("提交共享存储清理失败",
+			logger.String("contentHash", track.ContentHash),
+			logger.ErrorField(err))
+	}
+}
+
+// ReleaseTrackStorage 释放回收站中被彻底删除的曲目占用的存储
+func (h *APIHandler) ReleaseTrackStorage(ctx context.Context, track *model.Track) {
+	h.releaseTrackStorage(ctx, track, true)
+	h.ReleaseCover(ctx, track.CoverArtPath)
+}
+
+// ReleaseCover 封面不再被任何曲目或专辑引用时删除
+func (h *APIHandler) ReleaseCover(ctx context.Context, coverPath string) {
+	if !strings.HasPrefix(coverPath, "/static/covers/") {
+		return
+	}
+	referenced, err := h.trackRepo.IsCoverArtReferenced(ctx, coverPath)
+	if err != nil {
+		logger.Warn("查询封面引用失败，跳过清理",
+			logger.String("path", coverPath),
+			logger.ErrorField(err))
+		return
+	}
+	if referenced {
+		return
+	}
+
+	objectPath := strings.TrimPrefix(coverPath, "/static/")
+	if err := h.removeStorageObjects(objectPath); err != nil {
+		logger.Warn("删除封面失败",
+			logger.String("path", objectPath),
+			logger.ErrorField(err))
+		return
+	}
+	logger.Info("封面已无引用，已删除", logger.String("path", objectPath))
+}
+
+// rollbackUpload 曲目记录未能创建时删除本次上传写入对象存储的源音频和封面（补偿删除）
+// 源音频按内容哈希共享，仍有其他曲目引用时保留
+func (h *APIHandler) rollbackUpload(contentHash, filePath, coverPath string) {
+	ctx := context.Background()
+	if filePath != "" {
+		h.releaseTrackStorage(ctx, &model.Track{ContentHash: contentHash, FilePath: filePath}, true)
+	}
+	h.ReleaseCover(ctx, coverPath)
+}
+
+// deleteTrackRecords 批量上传中途失败时删除本批已创建的曲目记录（补偿删除）
+func (h *APIHandler) deleteTrackRecords(ctx context.Context, trackIDs []int64) {
+	if len(trackIDs) == 0 {
+		return
+	}
+	tx, err := h.trackRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("删除上传失败的曲目记录失败", logger.Any("trackIds", trackIDs), logger.ErrorField(err))
+		return
+	}
+	defer h.trackRepo.RollbackTx(tx)
+
+	for _, trackID := range trackIDs {
+		if err := h.trackRepo.DeleteTrackWithTx(ctx, tx, trackID); err != nil {
+			logger.Error("删除上传失败的曲目记录失败", logger.Int64("trackId", trackID), logger.ErrorField(err))
+			return
+		}
+	}
+	if err := h.trackRepo.CommitTx(tx); err != nil {
+		logger.Error("删除上传失败的曲目记录失败", logger.Any("trackIds", trackIDs), logger.ErrorField(err))
+		return
+	}
+	logger.Info("已删除上传失败的曲目记录", logger.Any("trackIds", trackIDs))
+}
+
+// RepairTrackStream 从源音频重新生成流已丢失或转码中断的曲目，供存储对账调用
+// 曲目先标记为转码中再在后台转码，转码期间不会被对账重复安排
+func (h *APIHandler) RepairTrackStream(ctx context.Context, track *model.Track) error {
+	opts := h.userTranscodeOptions(ctx, track.UserID)
+	streamID := strconv.FormatInt(track.ID, 10)
+	if track.ContentHash != "" {
+		streamID = contentStreamID(track.ContentHash, opts)
+	}
+	if err := h.trackRepo.UpdateTrackStatus(ctx, track.ID, "processing"); err != nil {
+		return err
+	}
+	logger.Info("从源音频重新生成曲目的流",
+		logger.Int64("trackId", track.ID),
+		logger.String("streamId", streamID))
+
+	if h.dispatchesToWorker() {
+		return h.enqueueTranscode(track.ID, streamID, strings.TrimPrefix(track.FilePath, "/static/"), opts)
+	}
+	go func() {
+		ctx := context.Background()
+		status := "completed"
+		if err := h.transcodeTrackSource(track, streamID, opts); err != nil {
+			logger.Error("重新生成曲目的流失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+			status = "failed"
+		} else if err := h.trackRepo.UpdateTrackHLSPath(ctx, track.ID, streamPlaylistPath(streamID), track.Duration); err != nil {
+			logger.Error("更新HLS路径失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+			status = "failed"
+		}
+		if err := h.trackRepo.UpdateTrackStatus(ctx, track.ID, status); err != nil {
+			logger.Warn("更新曲目状态失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+		}
+	}()
+	return nil
+}
+
+// removeStream 删除流在对象存储、Redis 和临时目录中的全部数据
+func (h *APIHandler) removeStream(streamID string) {
+	if err := h.removeStorageObjects("streams/" + streamID + "/"); err != nil {
+		logger.Warn("删除流文件失败",
+			logger.String("streamId", streamID),
+			logger.ErrorField(err))
+	}
+	h.streamProcessor.InvalidateStreamCache(streamID)
+	os.RemoveAll(filepath.Join(h.cfg.StaticDir, "temp", "streams", streamID))
+	logger.Info("流已无引用，已删除", logger.String("streamId", streamID))
+}
+
+// removeStorageObjects 删除对象，以 / 结尾时删除该前缀下的全部对象
+func (h *APIHandler) removeStorageObjects(path string) error {
+	store := storage.GetStorage()
+	if store == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if !strings.HasSuffix(path, "/") {
+		return store.Delete(ctx, path)
+	}
+
+	objects, err := store.List(ctx, path)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := store.Delete(ctx, object.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"Bt1QFM/core/storagegc"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// SetTrackVerifier 设置曲目完整性校验器，供校验接口使用
+func (h *APIHandler) SetTrackVerifier(verifier *storagegc.Verifier) {
+	h.verifier = verifier
+}
+
+// VerifyTrackHandler 校验曲目的源音频、播放列表、分片和时长，发现损坏时从源音频重新转码
+// 只有曲目所有者和管理员可以校验，dryRun=true 时只报告问题
+func (h *APIHandler) VerifyTrackHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	trackID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid track ID")
+		return
+	}
+
+	track, err := h.trackRepo.GetTrackByID(r.Context(), trackID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取track失败",
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get track")
+		return
+	}
+	if track == nil || track.State == 0 {
+		writeError(w, CodeTrackNotFound, "Track not found")
+		return
+	}
+	if track.UserID != userID && !h.isAdmin(userID) {
+		writeError(w, CodeForbidden, "Forbidden")
+		return
+	}
+	if track.HLSPlaylistPath == "" {
+		writeError(w, CodeStreamNotReady, "Track stream not ready")
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dryRun") == "true" || r.URL.Query().Get("dryRun") == "1"
+	result, err := h.verifier.Verify(r.Context(), track, dryRun)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("曲目完整性校验失败",
+			logger.Int64("trackId", trackID),
+			logger.ErrorField(err))
+		writeError(w, CodeStorageUnavailable, "Track verification failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    result,
+	})
+}
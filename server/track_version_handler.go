@@ -0,0 +1,371 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"Bt1QFM/logger"
+	"Bt1QFM/model"
+
+	"github.com/gorilla/mux"
+)
+
+// ReplaceTrackFileHandler 替换曲目的音频（如更好的版本），标题等元数据、收藏和播放列表引用保持不变
+// 原来的源音频和流作为旧版本保留 TrackVersionRetentionDays 天，期间可以恢复；新音频按内容哈希存储并重新生成 HLS
+func (h *APIHandler) ReplaceTrackFileHandler(w http.ResponseWriter, r *http.Request) {
+	config := DefaultUploadConfig()
+	if r.ContentLength > config.MaxFileSize {
+		writeError(w, CodeFileTooLarge, fmt.Sprintf("Request too large. Maximum size is %d MB", config.MaxFileSize>>20))
+		return
+	}
+
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	track, ok := h.loadOwnedTrack(w, r, userID)
+	if !ok {
+		return
+	}
+	if track.Status == "processing" {
+		writeError(w, CodeConflict, "Track is still processing")
+		return
+	}
+
+	select {
+	case uploadSemaphore <- struct{}{}:
+		defer func() { <-uploadSemaphore }()
+	default:
+		writeError(w, CodeServiceUnavailable, "Server is busy, please try again later")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, config.MaxFileSize+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		logger.Ctx(r.Context()).Warn("解析替换音频表单失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+		writeError(w, CodeInvalidBody, "Failed to parse upload form. Please check your file and try again.")
+		return
+	}
+	trackFile, trackHeader, err := r.FormFile("trackFile")
+	if err != nil {
+		if err == http.ErrMissingFile {
+			writeError(w, CodeMissingField, "Missing audio file. Please select a file to upload.")
+		} else {
+			writeError(w, CodeInvalidBody, "Failed to process uploaded file.")
+		}
+		return
+	}
+	defer trackFile.Close()
+
+	if trackHeader.Size > config.MaxFileSize {
+		writeError(w, CodeFileTooLarge, fmt.Sprintf("File too large. Maximum size is %d MB", config.MaxFileSize>>20))
+		return
+	}
+	contentType := trackHeader.Header.Get("Content-Type")
+	validType := false
+	for _, t := range config.AllowedTypes {
+		if contentType == t {
+			validType = true
+			break
+		}
+	}
+	if !validType {
+		writeError(w, CodeUnsupportedFileType, "Invalid file type. Supported formats: MP3, WAV, FLAC, AAC, M4A.")
+		return
+	}
+	if !h.checkStorageQuota(w, r, userID, trackHeader.Size) {
+		return
+	}
+	if !h.validateUploadedAudio(w, r, trackFile, trackHeader.Filename, contentType) {
+		return
+	}
+
+	contentHash, err := hashContent(trackFile)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("计算文件哈希失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to process uploaded file.")
+		return
+	}
+	if contentHash == track.ContentHash {
+		writeError(w, CodeBadRequest, "The uploaded file is identical to the current audio")
+		return
+	}
+
+	trackFileExt := filepath.Ext(trackHeader.Filename)
+	if trackFileExt == "" {
+		trackFileExt = ".dat"
+	}
+	transcodeOpts := h.loadTranscodeOptions(r, userID)
+	streamID := contentStreamID(contentHash, transcodeOpts)
+	shared, err := h.findSharedStorage(r.Context(), contentHash, streamID)
+	if err != nil {
+		logger.Ctx(r.Context()).Warn("查询共享存储失败，按新文件处理", logger.ErrorField(err))
+		shared = &sharedStorage{}
+	}
+	minioTrackPath := "audio/" + contentHash + trackFileExt
+	trackFilePath := "/static/" + minioTrackPath
+	if shared.FilePath != "" {
+		trackFilePath = shared.FilePath
+		minioTrackPath = strings.TrimPrefix(shared.FilePath, "/static/")
+	}
+
+	fingerprint, err := h.computeFingerprintFromReader(trackFile, trackFileExt)
+	if err != nil {
+		logger.Ctx(r.Context()).Warn("计算音频指纹失败", logger.ErrorField(err))
+	}
+	if _, err := trackFile.Seek(0, io.SeekStart); err != nil {
+		writeError(w, CodeInternal, "Failed to process uploaded file.")
+		return
+	}
+
+	// 先保存新的源音频再切换曲目记录，切换失败时补偿删除
+	var uploadedSource string
+	committed := false
+	defer func() {
+		if !committed && uploadedSource != "" {
+			h.rollbackUpload(contentHash, uploadedSource, "")
+		}
+	}()
+	if shared.FilePath == "" {
+		if err := h.uploadFileToStorage(trackFile, minioTrackPath, contentType); err != nil {
+			logger.Ctx(r.Context()).Error("上传源音频到对象存储失败",
+				logger.String("path", minioTrackPath),
+				logger.ErrorField(err))
+			writeError(w, CodeStorageUnavailable, "Failed to upload audio to storage")
+			return
+		}
+		uploadedSource = trackFilePath
+		if _, err := trackFile.Seek(0, io.SeekStart); err != nil {
+			writeError(w, CodeInternal, "Failed to process uploaded file.")
+			return
+		}
+	}
+
+	// 转码完成前继续播放旧的流，由转码流程切换到新的流
+	updated := *track
+	updated.FilePath = trackFilePath
+	updated.ContentHash = contentHash
+	updated.FileSize = trackHeader.Size
+	updated.Status = "processing"
+	if shared.Stream != nil {
+		updated.HLSPlaylistPath = shared.Stream.HLSPlaylistPath
+		updated.Duration = shared.Stream.Duration
+		updated.Status = "completed"
+	}
+	version, err := h.swapTrackFile(r.Context(), track, &updated, 0)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("替换曲目音频失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+		if isDuplicateEntry(err) {
+			writeError(w, CodeDuplicateTrack, "Another track in your library already uses this audio")
+			return
+		}
+		writeError(w, CodeInternal, "Failed to replace track audio")
+		return
+	}
+	committed = true
+
+	if fingerprint != nil {
+		h.saveTrackFingerprint(track.ID, userID, fingerprint)
+	}
+	logger.Ctx(r.Context()).Info("曲目音频已替换",
+		logger.Int64("trackId", track.ID),
+		logger.Int64("versionId", version.ID),
+		logger.String("contentHash", contentHash),
+		logger.Bool("sharedStream", shared.Stream != nil))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"track":           &updated,
+			"previousVersion": version,
+		},
+	})
+
+	if shared.Stream != nil {
+		return
+	}
+	fileBuffer := &bytes.Buffer{}
+	if _, err := io.Copy(fileBuffer, trackFile); err != nil {
+		logger.Error("读取文件到缓冲区失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+		h.trackRepo.UpdateTrackStatus(context.Background(), track.ID, "failed")
+		return
+	}
+	go func() {
+		if err := h.processAudioFileAsync(fileBuffer, minioTrackPath, track.ID, streamID, transcodeOpts); err != nil {
+			logger.Error("替换音频后重新生成流失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+			h.trackRepo.UpdateTrackStatus(context.Background(), track.ID, "failed")
+			return
+		}
+		// 交给转码 worker 时由 worker 回写状态
+		if h.dispatchesToWorker() {
+			return
+		}
+		h.trackRepo.UpdateTrackStatus(context.Background(), track.ID, "completed")
+	}()
+}
+
+// GetTrackVersionsHandler 列出曲目被替换后仍可恢复的旧版本，最近替换的在前
+func (h *APIHandler) GetTrackVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	track, ok := h.loadOwnedTrack(w, r, userID)
+	if !ok {
+		return
+	}
+
+	versions, err := h.versionRepo.GetVersionsByTrackID(r.Context(), track.ID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取曲目版本失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get track versions")
+		return
+	}
+	// 到期但尚未清理的版本不再返回
+	now := time.Now()
+	available := make([]*model.TrackVersion, 0, len(versions))
+	for _, v := range versions {
+		if v.ExpiresAt.After(now) {
+			available = append(available, v)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":       true,
+		"data":          available,
+		"retentionDays": h.cfg.TrackVersionRetentionDays,
+	})
+}
+
+// RestoreTrackVersionHandler 恢复曲目的旧版本，当前的音频同样保存为可恢复的旧版本
+func (h *APIHandler) RestoreTrackVersionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	track, ok := h.loadOwnedTrack(w, r, userID)
+	if !ok {
+		return
+	}
+	versionID, err := strconv.ParseInt(mux.Vars(r)["versionId"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid version ID")
+		return
+	}
+	version, err := h.versionRepo.GetVersionByID(r.Context(), versionID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取曲目版本失败", logger.Int64("versionId", versionID), logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get track versions")
+		return
+	}
+	if version == nil || version.TrackID != track.ID || !version.ExpiresAt.After(time.Now()) {
+		writeError(w, CodeNotFound, "Track version not found")
+		return
+	}
+	if track.Status == "processing" {
+		writeError(w, CodeConflict, "Track is still processing")
+		return
+	}
+
+	restored := *track
+	restored.FilePath = version.FilePath
+	restored.ContentHash = version.ContentHash
+	restored.FileSize = version.FileSize
+	restored.HLSPlaylistPath = version.HLSPlaylistPath
+	restored.Duration = version.Duration
+	restored.Status = "completed"
+	if restored.HLSPlaylistPath == "" {
+		restored.Status = "processing"
+	}
+	previous, err := h.swapTrackFile(r.Context(), track, &restored, version.ID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("恢复曲目版本失败",
+			logger.Int64("trackId", track.ID),
+			logger.Int64("versionId", versionID),
+			logger.ErrorField(err))
+		if isDuplicateEntry(err) {
+			writeError(w, CodeDuplicateTrack, "Another track in your library already uses this audio")
+			return
+		}
+		writeError(w, CodeInternal, "Failed to restore track version")
+		return
+	}
+	logger.Ctx(r.Context()).Info("曲目旧版本已恢复",
+		logger.Int64("trackId", track.ID),
+		logger.Int64("restoredVersionId", versionID),
+		logger.Int64("previousVersionId", previous.ID))
+
+	// 旧版本替换前没有生成流时从源音频重新转码
+	if restored.Status == "processing" {
+		if err := h.RepairTrackStream(context.Background(), &restored); err != nil {
+			logger.Warn("恢复的版本重新转码失败", logger.Int64("trackId", track.ID), logger.ErrorField(err))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"track":           &restored,
+			"previousVersion": previous,
+		},
+	})
+}
+
+// swapTrackFile 在同一事务中把曲目当前的音频保存为旧版本并切换到 updated，
+// restoreVersionID 不为 0 时同时删除被恢复的版本，返回新保存的旧版本
+func (h *APIHandler) swapTrackFile(ctx context.Context, current, updated *model.Track, restoreVersionID int64) (*model.TrackVersion, error) {
+	now := time.Now()
+	version := &model.TrackVersion{
+		TrackID:         current.ID,
+		UserID:          current.UserID,
+		FilePath:        current.FilePath,
+		HLSPlaylistPath: current.HLSPlaylistPath,
+		ContentHash:     current.ContentHash,
+		FileSize:        current.FileSize,
+		Duration:        current.Duration,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(time.Duration(h.cfg.TrackVersionRetentionDays) * 24 * time.Hour),
+	}
+
+	tx, err := h.trackRepo.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer h.trackRepo.RollbackTx(tx)
+
+	if version.ID, err = h.versionRepo.CreateVersionWithTx(ctx, tx, version); err != nil {
+		return nil, err
+	}
+	if restoreVersionID != 0 {
+		if err := h.versionRepo.DeleteVersionWithTx(ctx, tx, restoreVersionID); err != nil {
+			return nil, err
+		}
+	}
+	if err := h.trackRepo.UpdateTrackFileWithTx(ctx, tx, updated); err != nil {
+		return nil, err
+	}
+	if err := h.trackRepo.CommitTx(tx); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// isDuplicateEntry 判断数据库错误是否为唯一约束冲突
+func isDuplicateEntry(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "duplicate entry") || strings.Contains(msg, "unique constraint")
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/transcode"
+	"Bt1QFM/logger"
+)
+
+// enqueueTimeout 写入转码队列的超时时间
+const enqueueTimeout = 10 * time.Second
+
+// dispatchesToWorker 上传的曲目是否交给转码 worker 处理，而不是在本进程内转码
+func (h *APIHandler) dispatchesToWorker() bool {
+	return h.cfg.TranscodeDispatch == transcode.DispatchWorker
+}
+
+// enqueueTranscode 将曲目交给转码 worker，sourcePath 为已保存在对象存储中的源音频路径
+func (h *APIHandler) enqueueTranscode(trackID int64, streamID, sourcePath string, opts *audio.TranscodeOptions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), enqueueTimeout)
+	defer cancel()
+
+	if err := transcode.Enqueue(ctx, &transcode.Job{
+		TrackID:    trackID,
+		StreamID:   streamID,
+		SourcePath: sourcePath,
+		Options:    opts,
+	}); err != nil {
+		return err
+	}
+	logger.Info("转码任务已加入队列",
+		logger.Int64("trackId", trackID),
+		logger.String("streamId", streamID))
+	return nil
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// 回收站条目类型
+const (
+	trashTypeTrack = "track"
+	trashTypeAlbum = "album"
+)
+
+// trashItem 回收站中的一个曲目或专辑
+type trashItem struct {
+	Type      string     `json:"type"`
+	ID        int64      `json:"id"`
+	Title     string     `json:"title"`
+	Artist    string     `json:"artist"`
+	Album     string     `json:"album,omitempty"`
+	CoverPath string     `json:"coverPath,omitempty"`
+	Duration  float32    `json:"duration,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	PurgeAt   *time.Time `json:"purgeAt,omitempty"` // 为空表示在下次清理时删除
+}
+
+// GetTrashHandler 列出当前用户回收站中的曲目和专辑，最近删除的在前
+func (h *APIHandler) GetTrashHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	tracks, err := h.trackRepo.GetDeletedTracksByUserID(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取回收站曲目失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get trash")
+		return
+	}
+	albums, err := h.albumRepo.GetDeletedAlbumsByUserID(r.Context(), userID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取回收站专辑失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get trash")
+		return
+	}
+
+	retention := time.Duration(h.cfg.TrashRetentionDays) * 24 * time.Hour
+	purgeAt := func(deletedAt *time.Time) *time.Time {
+		if deletedAt == nil {
+			return nil
+		}
+		t := deletedAt.Add(retention)
+		return &t
+	}
+
+	items := make([]trashItem, 0, len(tracks)+len(albums))
+	for _, t := range tracks {
+		items = append(items, trashItem{
+			Type:      trashTypeTrack,
+			ID:        t.ID,
+			Title:     t.Title,
+			Artist:    t.Artist,
+			Album:     t.Album,
+			CoverPath: t.CoverArtPath,
+			Duration:  t.Duration,
+			DeletedAt: t.DeletedAt,
+			PurgeAt:   purgeAt(t.DeletedAt),
+		})
+	}
+	for _, a := range albums {
+		items = append(items, trashItem{
+			Type:      trashTypeAlbum,
+			ID:        a.ID,
+			Title:     a.Name,
+			Artist:    a.Artist,
+			CoverPath: a.CoverPath,
+			DeletedAt: a.DeletedAt,
+			PurgeAt:   purgeAt(a.DeletedAt),
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].DeletedAt == nil || items[j].DeletedAt == nil {
+			return items[j].DeletedAt == nil && items[i].DeletedAt != nil
+		}
+		return items[i].DeletedAt.After(*items[j].DeletedAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":         items,
+		"retentionDays": h.cfg.TrashRetentionDays,
+	})
+}
+
+// RestoreTrashHandler 从回收站恢复曲目或专辑，?type=album 恢复专辑，默认恢复曲目
+func (h *APIHandler) RestoreTrashHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, CodeInvalidID, "Invalid ID")
+		return
+	}
+
+	itemType := r.URL.Query().Get("type")
+	if itemType == "" {
+		itemType = trashTypeTrack
+	}
+
+	var restored bool
+	switch itemType {
+	case trashTypeTrack:
+		restored, err = h.trackRepo.RestoreTrack(r.Context(), userID, id)
+	case trashTypeAlbum:
+		restored, err = h.albumRepo.RestoreAlbum(r.Context(), userID, id)
+	default:
+		writeError(w, CodeBadRequest, "Invalid type, expected 'track' or 'album'")
+		return
+	}
+	if err != nil {
+		logger.Ctx(r.Context()).Error("从回收站恢复失败",
+			logger.String("type", itemType),
+			logger.Int64("id", id),
+			logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to restore")
+		return
+	}
+	if !restored {
+		writeError(w, CodeNotFound, "Item not found in trash")
+		return
+	}
+
+	logger.Ctx(r.Context()).Info("已从回收站恢复",
+		logger.String("type", itemType),
+		logger.Int64("id", id))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":     itemType,
+		"id":       id,
+		"restored": true,
+	})
+}
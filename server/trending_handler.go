@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Bt1QFM/core/trending"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultTrendingLimit 默认返回的热门歌曲数量
+const defaultTrendingLimit = 20
+
+// TrendingHandler 热门歌曲处理器
+type TrendingHandler struct {
+	service *trending.Service
+}
+
+// NewTrendingHandler 创建热门歌曲处理器
+func NewTrendingHandler(service *trending.Service) *TrendingHandler {
+	return &TrendingHandler{service: service}
+}
+
+// GetTrendingHandler 返回实例内最近播放最多的歌曲，GET /api/trending?days=7&limit=20
+func (h *TrendingHandler) GetTrendingHandler(w http.ResponseWriter, r *http.Request) {
+	days := 0
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, CodeBadRequest, "Invalid days")
+			return
+		}
+		days = n
+	}
+	days = h.service.Days(days)
+
+	limit := defaultTrendingLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, CodeBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, trending.MaxLimit)
+	}
+
+	items, err := h.service.Trending(r.Context(), time.Now(), days, limit)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取热门歌曲失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to get trending songs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items": items,
+		"days":  days,
+	})
+}
+
+// RegisterTrendingRoutes 注册热门歌曲路由
+func RegisterTrendingRoutes(router *mux.Router, handler *TrendingHandler, authMiddleware func(http.HandlerFunc) http.HandlerFunc) {
+	router.HandleFunc("/api/trending", authMiddleware(handler.GetTrendingHandler)).Methods(http.MethodGet)
+
+	logger.Info("热门歌曲API端点注册完成",
+		logger.String("endpoints", "GET /api/trending"))
+}
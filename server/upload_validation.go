@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"time"
+
+	"Bt1QFM/core/audio"
+	"Bt1QFM/logger"
+)
+
+// uploadAudioFormats 上传支持的音频格式：内容类型 -> 格式名，声明的类型、扩展名和文件头识别出的类型按格式名比较
+var uploadAudioFormats = map[string]string{
+	"audio/mpeg":   "mp3",
+	"audio/mp3":    "mp3",
+	"audio/wav":    "wav",
+	"audio/x-wav":  "wav",
+	"audio/wave":   "wav", // http.DetectContentType 对 RIFF WAVE 返回的类型
+	"audio/flac":   "flac",
+	"audio/x-flac": "flac",
+	"audio/aac":    "aac",
+	"audio/mp4":    "m4a",
+	"video/mp4":    "m4a", // 使用 isom、mp42 等通用品牌的 M4A，是否有音频流由 ffprobe 检查
+}
+
+// invalidAudioMessages 音频结构检查未通过时按原因返回的提示
+var invalidAudioMessages = map[string]string{
+	audio.InvalidReasonCorrupt:       "The file could not be parsed as audio. It may be corrupted; re-export or re-download it and try again.",
+	audio.InvalidReasonNoAudioStream: "The file contains no audio stream. Please upload an audio file.",
+	audio.InvalidReasonZeroDuration:  "The audio has no playable content. Please check the file and try again.",
+	audio.InvalidReasonTruncated:     "The file appears to be truncated. Re-download or re-export it, then upload it again.",
+}
+
+// uploadFormat 返回内容类型对应的上传格式名，不支持时返回空串
+func uploadFormat(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return uploadAudioFormats[mediaType]
+}
+
+// validateUploadedAudio 不信任客户端声明的类型：按文件头识别真实格式，与声明的 Content-Type 或扩展名不符时拒绝（无法识别的声明不参与比较），
+// 启用深度校验时再用 ffprobe 检查结构和截断。未通过时写入错误响应并返回 false；返回前把 file 重置到开头
+func (h *APIHandler) validateUploadedAudio(w http.ResponseWriter, r *http.Request, file io.ReadSeeker, filename, declaredType string) bool {
+	head := make([]byte, contentTypeSniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		logger.Ctx(r.Context()).Error("读取上传文件失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to process uploaded file.")
+		return false
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		logger.Ctx(r.Context()).Error("重置上传文件指针失败", logger.ErrorField(err))
+		writeError(w, CodeInternal, "Failed to process uploaded file.")
+		return false
+	}
+	if n == 0 {
+		writeErrorDetails(w, CodeInvalidAudio, "The uploaded file is empty.", map[string]interface{}{"reason": "empty"})
+		return false
+	}
+
+	detectedType := sniffContentType(head[:n])
+	detected := uploadFormat(detectedType)
+	if detected == "" {
+		logger.Ctx(r.Context()).Warn("上传文件内容不是支持的音频格式",
+			logger.String("filename", filename),
+			logger.String("declaredType", declaredType),
+			logger.String("detectedType", detectedType))
+		writeErrorDetails(w, CodeUnsupportedFileType, "File content is not a supported audio format. Supported formats: MP3, WAV, FLAC, AAC, M4A.", map[string]interface{}{
+			"declaredType": declaredType,
+			"detectedType": detectedType,
+		})
+		return false
+	}
+
+	extType, _ := contentTypeByExt(filename)
+	extFormat := uploadFormat(extType)
+	declared := uploadFormat(declaredType)
+	if (declared != "" && declared != detected) || (extFormat != "" && extFormat != detected) {
+		logger.Ctx(r.Context()).Warn("上传文件内容与声明的类型不符",
+			logger.String("filename", filename),
+			logger.String("declaredType", declaredType),
+			logger.String("detectedType", detectedType))
+		writeErrorDetails(w, CodeFileTypeMismatch, "File content does not match its declared type. Rename the file with the correct extension or convert it to a supported format.", map[string]interface{}{
+			"declaredType":   declaredType,
+			"declaredFormat": declared,
+			"extensionType":  extType,
+			"detectedType":   detectedType,
+			"detectedFormat": detected,
+		})
+		return false
+	}
+
+	if !h.cfg.UploadDeepValidation {
+		return true
+	}
+	return h.probeUploadedAudio(w, r, file, filename, detected)
+}
+
+// probeUploadedAudio 把上传内容写入临时文件交给 ffprobe 检查；ffprobe 无法执行或超时时只记录日志并放行，由后续转码兜底
+func (h *APIHandler) probeUploadedAudio(w http.ResponseWriter, r *http.Request, file io.ReadSeeker, filename, format string) bool {
+	tempFile, err := os.CreateTemp("", "upload-probe-*."+format)
+	if err != nil {
+		logger.Ctx(r.Context()).Warn("创建临时文件失败，跳过音频结构检查", logger.ErrorField(err))
+		return true
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = io.Copy(tempFile, file)
+	tempFile.Close()
+	if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil {
+		logger.Ctx(r.Context()).Error("重置上传文件指针失败", logger.ErrorField(seekErr))
+		writeError(w, CodeInternal, "Failed to process uploaded file.")
+		return false
+	}
+	if err != nil {
+		logger.Ctx(r.Context()).Warn("写入临时文件失败，跳过音频结构检查", logger.ErrorField(err))
+		return true
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(max(h.cfg.UploadProbeTimeoutSeconds, 1))*time.Second)
+	defer cancel()
+	probe, err := audio.ProbeUpload(ctx, h.cfg.FFmpegPath, tempFile.Name())
+
+	var invalid *audio.InvalidAudioError
+	if errors.As(err, &invalid) {
+		logger.Ctx(r.Context()).Warn("上传音频未通过结构检查",
+			logger.String("filename", filename),
+			logger.String("reason", invalid.Reason),
+			logger.String("detail", invalid.Detail))
+		writeErrorDetails(w, CodeInvalidAudio, invalidAudioMessages[invalid.Reason], map[string]interface{}{
+			"reason": invalid.Reason,
+			"detail": invalid.Detail,
+		})
+		return false
+	}
+	if err != nil {
+		logger.Ctx(r.Context()).Warn("音频结构检查失败，跳过", logger.String("filename", filename), logger.ErrorField(err))
+		return true
+	}
+
+	logger.Ctx(r.Context()).Info("音频结构检查通过",
+		logger.String("filename", filename),
+		logger.String("format", probe.FormatName),
+		logger.String("codec", probe.Codec),
+		logger.Float64("duration", probe.Duration),
+		logger.Duration("耗时", time.Since(start)))
+	return true
+}
@@ -25,20 +25,20 @@ func (h *UserHandler) GetUserProfileHandler(w http.ResponseWriter, r *http.Reque
 	// 从上下文中获取用户ID
 	userID, ok := r.Context().Value("userID").(int64)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	// 从数据库获取用户信息
-	user, err := h.userRepo.GetUserByID(userID)
+	user, err := h.userRepo.GetUserByID(r.Context(), userID)
 	if err != nil {
 		logger.Error("获取用户信息失败", logger.ErrorField(err))
-		http.Error(w, "Failed to get user profile", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to get user profile")
 		return
 	}
 
 	if user == nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		writeError(w, CodeUserNotFound, "User not found")
 		return
 	}
 
@@ -47,6 +47,10 @@ func (h *UserHandler) GetUserProfileHandler(w http.ResponseWriter, r *http.Reque
 		"id":       user.ID,
 		"username": user.Username,
 		"email":    user.Email,
+		"status":   user.Status,
+	}
+	if user.EmailVerifiedAt != nil {
+		profile["emailVerifiedAt"] = user.EmailVerifiedAt
 	}
 
 	// 添加网易云信息
@@ -70,7 +74,6 @@ func (h *UserHandler) GetUserProfileHandler(w http.ResponseWriter, r *http.Reque
 
 	// 设置响应头
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// 返回用户资料
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
@@ -84,7 +87,7 @@ func (h *UserHandler) GetUserProfileHandler(w http.ResponseWriter, r *http.Reque
 // UpdateNeteaseInfoHandler 更新网易云信息
 func (h *UserHandler) UpdateNeteaseInfoHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -95,27 +98,26 @@ func (h *UserHandler) UpdateNeteaseInfoHandler(w http.ResponseWriter, r *http.Re
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	// 从上下文中获取用户ID
 	userID, ok := r.Context().Value("userID").(int64)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	// 更新用户网易云信息
-	if err := h.userRepo.UpdateNeteaseInfo(userID, req.NeteaseUsername, req.NeteaseUID); err != nil {
+	if err := h.userRepo.UpdateNeteaseInfo(r.Context(), userID, req.NeteaseUsername, req.NeteaseUID); err != nil {
 		logger.Error("更新用户网易云信息失败", logger.ErrorField(err))
-		http.Error(w, "Failed to update netease info", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to update netease info")
 		return
 	}
 
 	// 设置响应头
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// 返回成功响应
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
@@ -129,7 +131,7 @@ func (h *UserHandler) UpdateNeteaseInfoHandler(w http.ResponseWriter, r *http.Re
 // UpdateUserProfileHandler 更新用户资料
 func (h *UserHandler) UpdateUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -143,34 +145,33 @@ func (h *UserHandler) UpdateUserProfileHandler(w http.ResponseWriter, r *http.Re
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, CodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	// 从上下文中获取用户ID
 	userID, ok := r.Context().Value("userID").(int64)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	// 更新用户基本信息
-	if err := h.userRepo.UpdateUserProfile(userID, req.Username, req.Email, req.Phone); err != nil {
+	if err := h.userRepo.UpdateUserProfile(r.Context(), userID, req.Username, req.Email, req.Phone); err != nil {
 		logger.Error("更新用户基本信息失败", logger.ErrorField(err))
-		http.Error(w, "Failed to update user profile", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to update user profile")
 		return
 	}
 
 	// 更新用户网易云信息
-	if err := h.userRepo.UpdateNeteaseInfo(userID, req.NeteaseUsername, req.NeteaseUID); err != nil {
+	if err := h.userRepo.UpdateNeteaseInfo(r.Context(), userID, req.NeteaseUsername, req.NeteaseUID); err != nil {
 		logger.Error("更新用户网易云信息失败", logger.ErrorField(err))
-		http.Error(w, "Failed to update netease info", http.StatusInternalServerError)
+		writeError(w, CodeInternal, "Failed to update netease info")
 		return
 	}
 
 	// 设置响应头
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// 返回成功响应
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/audio"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/core/transcode"
+	"Bt1QFM/db"
+	"Bt1QFM/logger"
+	"Bt1QFM/repository"
+	"Bt1QFM/storage"
+)
+
+// StartTranscodeWorker 以转码 worker 模式运行：只初始化转码所需的存储、数据库和 Redis，
+// 处理 API 服务写入 Redis 队列的转码任务，收到中断信号后等待正在处理的任务完成再退出
+func StartTranscodeWorker() {
+	cfg := config.Load()
+
+	logger.InitLogger(logger.Config{
+		Level:      logger.InfoLevel,
+		OutputPath: "logs/worker.log",
+		MaxSize:    100,
+		MaxBackups: 10,
+		MaxAge:     30,
+		Compress:   true,
+	})
+
+	// 加密分片的密钥由签名密钥派生，必须与 API 服务相同
+	if err := auth.SetSecret(cfg.AppSecret); err != nil {
+		logger.Fatal("签名密钥配置无效", logger.ErrorField(err))
+	}
+
+	ffmpegInfo, err := audio.CheckFFmpeg(context.Background(), cfg)
+	if err != nil {
+		logger.Fatal("FFmpeg 检查失败", logger.ErrorField(err))
+	}
+	cfg.FFmpegPath = ffmpegInfo.FFmpegPath
+
+	if err := storage.InitStorage(cfg); err != nil {
+		logger.Fatal("初始化对象存储失败", logger.ErrorField(err))
+	}
+	if err := db.ConnectDB(cfg); err != nil {
+		logger.Fatal("连接数据库失败", logger.ErrorField(err))
+	}
+	defer db.DB.Close()
+	// 转码任务队列在 Redis 中，连接失败时无法工作
+	if err := cache.ConnectRedis(cfg); err != nil {
+		logger.Fatal("连接 Redis 失败", logger.ErrorField(err))
+	}
+	defer cache.CloseRedis()
+
+	audio.ConfigureTranscoding(cfg)
+	streamProcessor := audio.NewStreamProcessor(audio.NewMP3Processor(cfg.FFmpegPath), cfg)
+
+	hostname, _ := os.Hostname()
+	worker := transcode.NewWorker(streamProcessor, repository.NewMySQLTrackRepository(), cfg, fmt.Sprintf("%s-%d", hostname, os.Getpid()))
+	worker.Start()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	logger.Info("正在停止转码 worker，等待正在处理的任务完成...")
+	worker.Stop()
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"Bt1QFM/cache"
+	"Bt1QFM/config"
+	"Bt1QFM/core/auth"
+	"Bt1QFM/logger"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket 子协议：客户端以 new WebSocket(url, ["bt1qfm", "bearer.<token>"]) 传递 Token，
+// 服务端只回应 bt1qfm，Token 不会出现在 URL 和访问日志中
+const (
+	wsProtocol           = "bt1qfm"
+	wsBearerProtocolPref = "bearer."
+)
+
+// wsAuthTimeout 未通过子协议携带 Token 时，等待首条认证消息的时间
+const wsAuthTimeout = 10 * time.Second
+
+// 认证失败时的 WebSocket 关闭码（4000-4999 为应用自定义）
+const (
+	wsCloseAuthRequired = 4001 // 未提供 Token 或首条消息不是认证消息
+	wsCloseInvalidToken = 4002 // Token 无效或已过期
+	wsCloseForbidden    = 4003 // 已认证但无权访问
+	wsCloseNotFound     = 4004 // 资源不存在，无权访问的资源同样使用该关闭码
+	wsCloseAuthTimeout  = 4008 // 等待认证消息超时
+)
+
+var (
+	errWSAuthRequired    = errors.New("websocket authentication required")
+	errWSInvalidToken    = errors.New("invalid websocket token")
+	errWSAuthTimeout     = errors.New("websocket authentication timed out")
+	errWSAccountDisabled = errors.New("websocket account disabled")
+)
+
+// wsAuthMessage 首条认证消息 {"type": "auth", "token": "..."}
+type wsAuthMessage struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+}
+
+// wsAuthenticator 负责 WebSocket 的来源校验和身份认证
+type wsAuthenticator struct {
+	allowedOrigins  map[string]bool // 允许的 Origin（scheme://host[:port]，小写）
+	allowAnyOrigin  bool
+	allowQueryToken bool // 兼容旧客户端通过 ?token= 传递 Token
+}
+
+// newWSAuthenticator 根据配置创建 WebSocket 认证器
+// 未配置 WS_ALLOWED_ORIGINS 时只允许同源和 PUBLIC_BASE_URL，配置为 * 时允许任意来源
+func newWSAuthenticator(cfg *config.Config) *wsAuthenticator {
+	a := &wsAuthenticator{
+		allowedOrigins:  make(map[string]bool),
+		allowQueryToken: cfg.WSAllowQueryToken,
+	}
+	for _, origin := range cfg.WSAllowedOrigins {
+		if origin == "*" {
+			a.allowAnyOrigin = true
+			continue
+		}
+		if normalized := normalizeOrigin(origin); normalized != "" {
+			a.allowedOrigins[normalized] = true
+		}
+	}
+	if normalized := normalizeOrigin(cfg.PublicBaseURL); normalized != "" {
+		a.allowedOrigins[normalized] = true
+	}
+	return a
+}
+
+// Upgrader 返回带来源校验和子协议协商的 Upgrader
+func (a *wsAuthenticator) Upgrader(readBufferSize, writeBufferSize int) websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:  readBufferSize,
+		WriteBufferSize: writeBufferSize,
+		Subprotocols:    []string{wsProtocol},
+		CheckOrigin:     a.CheckOrigin,
+	}
+}
+
+// CheckOrigin 校验握手请求的 Origin，非浏览器客户端不带 Origin 时放行
+func (a *wsAuthenticator) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || a.allowAnyOrigin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		logger.Ctx(r.Context()).Warn("WebSocket Origin 格式错误", logger.String("origin", origin))
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) || a.allowedOrigins[normalizeOrigin(origin)] {
+		return true
+	}
+	logger.Ctx(r.Context()).Warn("拒绝不在白名单中的 WebSocket Origin",
+		logger.String("origin", origin),
+		logger.String("path", r.URL.Path))
+	return false
+}
+
+// Accept 升级连接并完成认证
+// Token 优先从 Sec-WebSocket-Protocol 读取，否则等待首条 {"type":"auth"} 消息；
+// 认证失败时以对应的关闭码关闭连接并返回错误，调用方无需再处理 conn
+func (a *wsAuthenticator) Accept(w http.ResponseWriter, r *http.Request, upgrader *websocket.Upgrader) (*websocket.Conn, *auth.Claims, error) {
+	token := tokenFromSubprotocols(websocket.Subprotocols(r))
+	if token == "" && a.allowQueryToken {
+		token = r.URL.Query().Get("token")
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade 失败时已写入 HTTP 错误响应
+		return nil, nil, err
+	}
+
+	if token == "" {
+		token, err = readAuthMessage(conn)
+		if err != nil {
+			code := wsCloseAuthRequired
+			if errors.Is(err, errWSAuthTimeout) {
+				code = wsCloseAuthTimeout
+			}
+			closeWebSocket(conn, code, err.Error())
+			return nil, nil, err
+		}
+	}
+
+	claims, err := auth.ParseToken(token)
+	if err != nil {
+		logger.Ctx(r.Context()).Warn("WebSocket Token 无效", logger.ErrorField(err))
+		closeWebSocket(conn, wsCloseInvalidToken, "invalid token")
+		return nil, nil, errWSInvalidToken
+	}
+	if cache.IsUserDisabled(r.Context(), claims.UserID) {
+		closeWebSocket(conn, wsCloseForbidden, "account disabled")
+		return nil, nil, errWSAccountDisabled
+	}
+	return conn, claims, nil
+}
+
+// tokenFromSubprotocols 从 bearer.<token> 子协议中取出 Token
+func tokenFromSubprotocols(protocols []string) string {
+	for _, p := range protocols {
+		if strings.HasPrefix(p, wsBearerProtocolPref) {
+			return strings.TrimPrefix(p, wsBearerProtocolPref)
+		}
+	}
+	return ""
+}
+
+// readAuthMessage 读取首条认证消息
+func readAuthMessage(conn *websocket.Conn) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(wsAuthTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		var netErr interface{ Timeout() bool }
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return "", errWSAuthTimeout
+		}
+		return "", errWSAuthRequired
+	}
+
+	var msg wsAuthMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "auth" || msg.Token == "" {
+		return "", errWSAuthRequired
+	}
+	return msg.Token, nil
+}
+
+// closeWebSocket 发送关闭帧后关闭连接
+func closeWebSocket(conn *websocket.Conn, code int, reason string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	conn.Close()
+}
+
+// normalizeOrigin 将地址规范化为 scheme://host[:port]，无法解析时返回空字符串
+func normalizeOrigin(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
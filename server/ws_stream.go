@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net/http"
@@ -11,7 +12,6 @@ import (
 	"strings"
 	"time"
 	"Bt1QFM/cache"
-	"Bt1QFM/config"
 	// "Bt1QFM/db"
 	"Bt1QFM/logger"
 	"Bt1QFM/storage"
@@ -19,32 +19,50 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
-	"github.com/minio/minio-go/v7"
 )
 
-var wsUpgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
-}
-
+// WebSocketStreamHandler 通过 WebSocket 边转码边推送曲目的 HLS 分片
+// 连接需先完成认证，且只推送给可以播放该曲目的用户（与 /streams/ 的访问控制相同），否则以 4004 关闭连接
 func (h *APIHandler) WebSocketStreamHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	vars := mux.Vars(r)
+	idStr := vars["track_id"]
+	trackID, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		logger.Error("websocket upgrade failed", logger.ErrorField(err))
+		writeError(w, CodeInvalidID, "Invalid track ID")
 		return
 	}
-	defer conn.Close()
 
-	vars := mux.Vars(r)
-	idStr := vars["track_id"]
-	trackID, err := strconv.ParseInt(idStr, 10, 64)
+	upgrader := h.wsAuth.Upgrader(0, 0)
+	conn, claims, err := h.wsAuth.Accept(w, r, &upgrader)
 	if err != nil {
-		logger.Warn("invalid track id", logger.String("id", idStr))
+		logger.Ctx(r.Context()).Warn("流 WebSocket 认证失败", logger.Int64("trackId", trackID), logger.ErrorField(err))
 		return
 	}
+	defer conn.Close()
 
-	track, err := h.trackRepo.GetTrackByID(trackID)
-	if err != nil || track == nil {
-		logger.Warn("track not found", logger.ErrorField(err))
+	track, err := h.trackRepo.GetTrackByID(r.Context(), trackID)
+	if err != nil {
+		logger.Ctx(r.Context()).Error("获取track失败", logger.Int64("trackId", trackID), logger.ErrorField(err))
+		closeWebSocket(conn, websocket.CloseInternalServerErr, "failed to get track")
+		return
+	}
+	if track == nil || track.State == 0 {
+		closeWebSocket(conn, wsCloseNotFound, "track not found")
+		return
+	}
+	// 曲目还未转码完成时没有流可以检查，所有者始终可以播放
+	allowed := track.UserID == claims.UserID
+	if !allowed {
+		allowed, _, err = h.canAccessStream(r.Context(), r, claims.UserID, track.StreamID())
+		if err != nil {
+			logger.Ctx(r.Context()).Error("检查流访问权限失败", logger.Int64("trackId", trackID), logger.ErrorField(err))
+			closeWebSocket(conn, websocket.CloseInternalServerErr, "failed to check stream access")
+			return
+		}
+	}
+	if !allowed {
+		logger.Ctx(r.Context()).Warn("拒绝推送曲目分片", logger.Int64("userId", claims.UserID), logger.Int64("trackId", trackID))
+		closeWebSocket(conn, wsCloseNotFound, "track not found")
 		return
 	}
 
@@ -57,7 +75,7 @@ func (h *APIHandler) WebSocketStreamHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	tempAudio := filepath.Join(tempDir, filepath.Base(minioPath))
-	if err := h.downloadFileFromMinio(minioPath, tempAudio); err != nil {
+	if err := h.downloadFileFromStorage(minioPath, tempAudio); err != nil {
 		logger.Error("download audio failed", logger.ErrorField(err))
 		return
 	}
@@ -95,8 +113,7 @@ func (h *APIHandler) WebSocketStreamHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	processed := make(map[string]bool)
-	cfg := config.Load()
-	minioClient := storage.GetMinioClient()
+	store := storage.GetStorage()
 	minioDir := fmt.Sprintf("streams/%d_ws", trackID)
 
 	done := make(chan struct{})
@@ -109,7 +126,7 @@ func (h *APIHandler) WebSocketStreamHandler(w http.ResponseWriter, r *http.Reque
 						continue
 					}
 					processed[event.Name] = true
-					sendSegment(event.Name, conn, trackID, minioClient, cfg, minioDir)
+					sendSegment(event.Name, conn, trackID, store, minioDir)
 				}
 			case err := <-watcher.Errors:
 				logger.Warn("watcher error", logger.ErrorField(err))
@@ -128,7 +145,7 @@ func (h *APIHandler) WebSocketStreamHandler(w http.ResponseWriter, r *http.Reque
 	}(tempDir)
 }
 
-func sendSegment(path string, conn *websocket.Conn, trackID int64, client *minio.Client, cfg *config.Config, minioDir string) {
+func sendSegment(path string, conn *websocket.Conn, trackID int64, store storage.Storage, minioDir string) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		logger.Warn("read segment", logger.ErrorField(err))
@@ -138,15 +155,10 @@ func sendSegment(path string, conn *websocket.Conn, trackID int64, client *minio
 		logger.Warn("websocket write", logger.ErrorField(err))
 	}
 
-	if client != nil {
-		f, err := os.Open(path)
-		if err == nil {
-			defer f.Close()
-			objectName := filepath.Join(minioDir, filepath.Base(path))
-			opts := minio.PutObjectOptions{ContentType: "video/MP2T", DisableMultipart: true}
-			if _, err := client.PutObject(context.Background(), cfg.MinioBucket, objectName, f, int64(len(data)), opts); err != nil {
-				logger.Warn("upload segment", logger.ErrorField(err))
-			}
+	if store != nil {
+		objectName := filepath.Join(minioDir, filepath.Base(path))
+		if err := store.Put(context.Background(), objectName, bytes.NewReader(data), int64(len(data)), "video/MP2T"); err != nil {
+			logger.Warn("upload segment", logger.ErrorField(err))
 		}
 	}
 
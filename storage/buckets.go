@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"Bt1QFM/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// 对象类别，决定对象存放在哪个存储桶
+const (
+	ClassAudio  = "audio"
+	ClassCover  = "covers"
+	ClassStream = "streams"
+)
+
+// Classes 所有对象类别
+var Classes = []string{ClassAudio, ClassCover, ClassStream}
+
+// classPrefixes 各类别包含的对象路径前缀
+var classPrefixes = map[string][]string{
+	ClassAudio:  {"audio/", "uploads/"},
+	ClassCover:  {"covers/"},
+	ClassStream: {"streams/"},
+}
+
+// ClassPrefixes 返回类别包含的对象路径前缀
+func ClassPrefixes(class string) []string {
+	return classPrefixes[class]
+}
+
+// BucketFor 返回类别对应的存储桶配置，Endpoint、Region 已按全局配置补全
+func BucketFor(cfg *config.Config, class string) config.BucketConfig {
+	var bucket config.BucketConfig
+	switch class {
+	case ClassAudio:
+		bucket = cfg.MinioAudioBucket
+	case ClassCover:
+		bucket = cfg.MinioCoverBucket
+	case ClassStream:
+		bucket = cfg.MinioStreamBucket
+	}
+	if bucket.Name == "" {
+		bucket.Name = cfg.MinioBucket
+	}
+	if bucket.Endpoint == "" {
+		bucket.Endpoint = cfg.MinioEndpoint
+	}
+	if bucket.Region == "" {
+		bucket.Region = cfg.MinioRegion
+	}
+	return bucket
+}
+
+// NewBucketClient 使用全局凭证为指定的 Endpoint/Region 创建 MinIO 客户端
+func NewBucketClient(cfg *config.Config, bucket config.BucketConfig) (*minio.Client, error) {
+	client, err := minio.New(bucket.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.MinioAccessKey, cfg.MinioSecretKey, ""),
+		Secure: cfg.MinioUseSSL,
+		Region: bucket.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建 MinIO 客户端失败 (%s): %v", bucket.Endpoint, err)
+	}
+	return client, nil
+}
+
+// EnsureBucket 存储桶不存在时创建
+func EnsureBucket(ctx context.Context, client *minio.Client, bucket config.BucketConfig) error {
+	exists, err := client.BucketExists(ctx, bucket.Name)
+	if err != nil {
+		return fmt.Errorf("检查存储桶 %s 失败: %v", bucket.Name, err)
+	}
+	if exists {
+		return nil
+	}
+	if err := client.MakeBucket(ctx, bucket.Name, minio.MakeBucketOptions{Region: bucket.Region}); err != nil {
+		return fmt.Errorf("创建存储桶 %s 失败: %v", bucket.Name, err)
+	}
+	log.Printf("✅ 成功创建存储桶: %s", bucket.Name)
+	return nil
+}
+
+// newRoutedMinioStorage 创建 MinIO 存储后端，并为单独配置了存储桶的类别添加路由
+func newRoutedMinioStorage(cfg *config.Config) (*MinioStorage, error) {
+	s := NewMinioStorage(minioClient, cfg.MinioBucket)
+	defaultBucket := config.BucketConfig{Name: cfg.MinioBucket, Endpoint: cfg.MinioEndpoint, Region: cfg.MinioRegion}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, class := range Classes {
+		bucket := BucketFor(cfg, class)
+		if bucket == defaultBucket {
+			continue
+		}
+		client := minioClient
+		if bucket.Endpoint != defaultBucket.Endpoint || bucket.Region != defaultBucket.Region {
+			var err error
+			if client, err = NewBucketClient(cfg, bucket); err != nil {
+				return nil, err
+			}
+		}
+		if err := EnsureBucket(ctx, client, bucket); err != nil {
+			return nil, err
+		}
+		for _, prefix := range classPrefixes[class] {
+			s.Route(prefix, client, bucket.Name)
+		}
+		log.Printf("✅ %s 使用存储桶: %s (%s)", class, bucket.Name, bucket.Endpoint)
+	}
+	return s, nil
+}
+
+// minioRoute 对象路径前缀到存储桶的路由
+type minioRoute struct {
+	prefix string
+	client *minio.Client
+	bucket string
+}
+
+// Route 将指定前缀的对象路由到另一个存储桶
+func (s *MinioStorage) Route(prefix string, client *minio.Client, bucket string) {
+	s.routes = append(s.routes, minioRoute{prefix: prefix, client: client, bucket: bucket})
+}
+
+// target 返回对象所在的客户端和存储桶
+func (s *MinioStorage) target(key string) (*minio.Client, string) {
+	for _, r := range s.routes {
+		if strings.HasPrefix(key, r.prefix) {
+			return r.client, r.bucket
+		}
+	}
+	return s.client, s.bucket
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"Bt1QFM/config"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// MigrateOptions 存储桶迁移参数
+type MigrateOptions struct {
+	// Source 源存储桶，Name 为空时使用 MinioBucket，Endpoint/Region 为空时沿用全局配置
+	Source config.BucketConfig
+	// Classes 需要迁移的对象类别，为空时迁移全部类别
+	Classes []string
+	// Workers 并发复制数
+	Workers int
+	// DryRun 只统计需要复制的对象，不实际复制
+	DryRun bool
+	// DeleteSource 复制成功后删除源对象
+	DeleteSource bool
+}
+
+// MigrateProgress 单个类别的迁移进度
+type MigrateProgress struct {
+	Class       string
+	Total       int
+	Copied      int
+	Skipped     int // 目标中已存在相同对象
+	Failed      int
+	Bytes       int64
+	TotalBytes  int64
+	LastKey     string
+	LastFailure error
+}
+
+// Done 已处理的对象数
+func (p MigrateProgress) Done() int {
+	return p.Copied + p.Skipped + p.Failed
+}
+
+// migrateEndpoint 一个存储桶及其客户端
+type migrateEndpoint struct {
+	bucket config.BucketConfig
+	client *minio.Client
+}
+
+// MigrateBuckets 将源存储桶中各类别的对象复制到配置中对应的存储桶
+// 同一 Endpoint 内使用服务端复制，跨 Endpoint 时经本机流式转存；目标中大小和 ETag 相同的对象会跳过，可重复执行
+func MigrateBuckets(ctx context.Context, cfg *config.Config, opts MigrateOptions, onProgress func(MigrateProgress)) ([]MigrateProgress, error) {
+	if opts.Source.Name == "" {
+		opts.Source.Name = cfg.MinioBucket
+	}
+	if opts.Source.Endpoint == "" {
+		opts.Source.Endpoint = cfg.MinioEndpoint
+	}
+	if opts.Source.Region == "" {
+		opts.Source.Region = cfg.MinioRegion
+	}
+	if len(opts.Classes) == 0 {
+		opts.Classes = Classes
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+
+	clients := make(map[string]*minio.Client)
+	clientFor := func(bucket config.BucketConfig) (*minio.Client, error) {
+		key := bucket.Endpoint + "|" + bucket.Region
+		if c, ok := clients[key]; ok {
+			return c, nil
+		}
+		c, err := NewBucketClient(cfg, bucket)
+		if err != nil {
+			return nil, err
+		}
+		clients[key] = c
+		return c, nil
+	}
+
+	srcClient, err := clientFor(opts.Source)
+	if err != nil {
+		return nil, err
+	}
+	src := migrateEndpoint{bucket: opts.Source, client: srcClient}
+
+	var results []MigrateProgress
+	for _, class := range opts.Classes {
+		prefixes, ok := classPrefixes[class]
+		if !ok {
+			return results, fmt.Errorf("未知的对象类别: %s", class)
+		}
+		dstBucket := BucketFor(cfg, class)
+		if dstBucket == opts.Source {
+			results = append(results, MigrateProgress{Class: class})
+			continue
+		}
+		dstClient, err := clientFor(dstBucket)
+		if err != nil {
+			return results, err
+		}
+		dst := migrateEndpoint{bucket: dstBucket, client: dstClient}
+		if !opts.DryRun {
+			if err := EnsureBucket(ctx, dstClient, dstBucket); err != nil {
+				return results, err
+			}
+		}
+
+		progress, err := migrateClass(ctx, class, prefixes, src, dst, opts, onProgress)
+		results = append(results, progress)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// migrateClass 复制一个类别下的全部对象
+func migrateClass(ctx context.Context, class string, prefixes []string, src, dst migrateEndpoint, opts MigrateOptions, onProgress func(MigrateProgress)) (MigrateProgress, error) {
+	progress := MigrateProgress{Class: class}
+
+	var objects []minio.ObjectInfo
+	for _, prefix := range prefixes {
+		for object := range src.client.ListObjects(ctx, src.bucket.Name, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+			if object.Err != nil {
+				return progress, fmt.Errorf("列出 %s 失败: %v", prefix, object.Err)
+			}
+			objects = append(objects, object)
+			progress.TotalBytes += object.Size
+		}
+	}
+	progress.Total = len(objects)
+	if onProgress != nil {
+		onProgress(progress)
+	}
+
+	var mu sync.Mutex
+	report := func(object minio.ObjectInfo, skipped bool, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			progress.Failed++
+			progress.LastFailure = fmt.Errorf("%s: %v", object.Key, err)
+		case skipped:
+			progress.Skipped++
+		default:
+			progress.Copied++
+		}
+		progress.Bytes += object.Size
+		progress.LastKey = object.Key
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+
+	jobs := make(chan minio.ObjectInfo)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range jobs {
+				skipped, err := migrateObject(ctx, object, src, dst, opts)
+				report(object, skipped, err)
+			}
+		}()
+	}
+	for _, object := range objects {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- object
+	}
+	close(jobs)
+	wg.Wait()
+
+	return progress, ctx.Err()
+}
+
+// migrateObject 复制单个对象，返回是否因目标已存在而跳过
+func migrateObject(ctx context.Context, object minio.ObjectInfo, src, dst migrateEndpoint, opts MigrateOptions) (bool, error) {
+	existing, err := dst.client.StatObject(ctx, dst.bucket.Name, object.Key, minio.StatObjectOptions{})
+	skipped := err == nil && existing.Size == object.Size && existing.ETag == object.ETag
+	if opts.DryRun {
+		return skipped, nil
+	}
+	if !skipped {
+		if err := copyObject(ctx, object, src, dst); err != nil {
+			return false, err
+		}
+	}
+
+	if opts.DeleteSource {
+		if err := src.client.RemoveObject(ctx, src.bucket.Name, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return skipped, fmt.Errorf("删除源对象失败: %v", err)
+		}
+	}
+	return skipped, nil
+}
+
+// copyObject 同一 Endpoint 内使用服务端复制，否则经本机流式转存
+func copyObject(ctx context.Context, object minio.ObjectInfo, src, dst migrateEndpoint) error {
+	if src.bucket.Endpoint == dst.bucket.Endpoint {
+		_, err := dst.client.CopyObject(ctx,
+			minio.CopyDestOptions{Bucket: dst.bucket.Name, Object: object.Key},
+			minio.CopySrcOptions{Bucket: src.bucket.Name, Object: object.Key})
+		return err
+	}
+
+	reader, err := src.client.GetObject(ctx, src.bucket.Name, object.Key, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	info, err := reader.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = dst.client.PutObject(ctx, dst.bucket.Name, object.Key, reader, info.Size, minio.PutObjectOptions{
+		ContentType: info.ContentType,
+	})
+	return err
+}
@@ -163,14 +163,14 @@ func PrintBucketStatus(prefix string) error {
 	log.Printf("\n📊 存储桶状态报告: %s", cfg.MinioBucket)
 	log.Printf("🔍 前缀过滤: %s", prefix)
 	log.Printf("📝 总文件数: %d", stats.TotalObjects)
-	log.Printf("💾 总存储大小: %s", formatSize(stats.TotalSize))
+	log.Printf("💾 总存储大小: %s", FormatSize(stats.TotalSize))
 	log.Printf("🕒 最后更新时间: %s", stats.LastModified.Format("2006-01-02 15:04:05"))
 	log.Printf("\n📋 文件列表:")
 
 	// 打印文件列表
 	for _, obj := range objects {
 		log.Printf("  ├─ %s", obj.Key)
-		log.Printf("  │  ├─ 大小: %s", formatSize(obj.Size))
+		log.Printf("  │  ├─ 大小: %s", FormatSize(obj.Size))
 		log.Printf("  │  ├─ 类型: %s", obj.ContentType)
 		log.Printf("  │  └─ 修改时间: %s", obj.LastModified.Format("2006-01-02 15:04:05"))
 	}
@@ -178,8 +178,8 @@ func PrintBucketStatus(prefix string) error {
 	return nil
 }
 
-// formatSize 格式化文件大小
-func formatSize(size int64) string {
+// FormatSize 格式化文件大小
+func FormatSize(size int64) string {
 	const unit = 1024
 	if size < unit {
 		return fmt.Sprintf("%d B", size)
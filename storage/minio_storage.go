@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// MinioStorage 基于 MinIO 的存储后端
+type MinioStorage struct {
+	client *minio.Client
+	bucket string
+	routes []minioRoute
+}
+
+// NewMinioStorage 创建 MinIO 存储后端
+func NewMinioStorage(client *minio.Client, bucket string) *MinioStorage {
+	return &MinioStorage{client: client, bucket: bucket}
+}
+
+// Put 上传对象，已知大小时禁用分片上传
+func (s *MinioStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	client, bucket := s.target(key)
+	_, err := client.PutObject(ctx, bucket, key, r, size, minio.PutObjectOptions{
+		ContentType:      contentType,
+		DisableMultipart: size >= 0,
+	})
+	return err
+}
+
+// Get 读取对象，先获取对象信息以便对象不存在时立即返回
+func (s *MinioStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	client, bucket := s.target(key)
+	object, err := client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, convertMinioError(err)
+	}
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, convertMinioError(err)
+	}
+	return object, nil
+}
+
+// Stat 获取对象信息
+func (s *MinioStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	client, bucket := s.target(key)
+	info, err := client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, convertMinioError(err)
+	}
+	return &ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+	}, nil
+}
+
+// Delete 删除对象
+func (s *MinioStorage) Delete(ctx context.Context, key string) error {
+	client, bucket := s.target(key)
+	return client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{})
+}
+
+// List 递归列出指定前缀下的对象，前缀按所在类别路由到对应存储桶
+func (s *MinioStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	client, bucket := s.target(prefix)
+	var objects []ObjectInfo
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+			ContentType:  object.ContentType,
+			ETag:         object.ETag,
+		})
+	}
+	return objects, nil
+}
+
+// Presign 生成 MinIO 预签名地址
+func (s *MinioStorage) Presign(ctx context.Context, method, key string, expiry time.Duration) (string, error) {
+	if err := validPresignMethod(method); err != nil {
+		return "", err
+	}
+	client, bucket := s.target(key)
+	if method == http.MethodPut {
+		u, err := client.PresignedPutObject(ctx, bucket, key, expiry)
+		if err != nil {
+			return "", fmt.Errorf("生成上传地址失败: %v", err)
+		}
+		return u.String(), nil
+	}
+	u, err := client.PresignedGetObject(ctx, bucket, key, expiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("生成下载地址失败: %v", err)
+	}
+	return u.String(), nil
+}
+
+// convertMinioError 将对象不存在的错误转换为 ErrNotFound
+func convertMinioError(err error) error {
+	if resp := minio.ToErrorResponse(err); resp.Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"Bt1QFM/config"
+)
+
+// 存储后端类型
+const (
+	BackendMinio = "minio"
+	BackendLocal = "local"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("对象不存在")
+
+// Storage 对象存储接口，key 为不带 /static/ 前缀的对象路径（如 audio/xxx.mp3）
+type Storage interface {
+	// Put 写入对象，size 未知时传 -1
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象，对象不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat 获取对象信息，对象不存在时返回 ErrNotFound
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// List 递归列出指定前缀下的对象
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Presign 生成限时访问地址，method 为 GET 或 PUT
+	Presign(ctx context.Context, method, key string, expiry time.Duration) (string, error)
+}
+
+var defaultStorage Storage
+
+// InitStorage 根据配置初始化存储后端
+func InitStorage(cfg *config.Config) error {
+	switch cfg.StorageBackend {
+	case BackendLocal:
+		key := []byte(cfg.StorageSigningKey)
+		if len(key) == 0 {
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return fmt.Errorf("生成签名密钥失败: %v", err)
+			}
+			log.Println("⚠️ 未配置 STORAGE_SIGNING_KEY，使用随机密钥，重启后已签发的地址将失效")
+		}
+		local, err := NewLocalStorage(cfg.StorageLocalDir, cfg.PublicBaseURL, key)
+		if err != nil {
+			return err
+		}
+		defaultStorage = local
+		log.Printf("✅ 使用本地磁盘存储: %s", cfg.StorageLocalDir)
+	case BackendMinio, "":
+		if err := InitMinio(); err != nil {
+			return err
+		}
+		routed, err := newRoutedMinioStorage(cfg)
+		if err != nil {
+			return err
+		}
+		defaultStorage = routed
+	default:
+		return fmt.Errorf("未知的存储后端: %s", cfg.StorageBackend)
+	}
+	return nil
+}
+
+// GetStorage 获取当前存储后端实例
+func GetStorage() Storage {
+	return defaultStorage
+}
+
+// IsNotFound 判断错误是否表示对象不存在
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// validPresignMethod 检查预签名请求方法
+func validPresignMethod(method string) error {
+	if method != http.MethodGet && method != http.MethodPut {
+		return fmt.Errorf("不支持的预签名方法: %s", method)
+	}
+	return nil
+}
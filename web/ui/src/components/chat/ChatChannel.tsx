@@ -110,10 +110,11 @@ const ChatChannel: React.FC<ChatChannelProps> = ({ className = '' }) => {
     if (isConnectingRef.current) return;
 
     isConnectingRef.current = true;
-    const wsUrl = `${getWebSocketUrl()}/ws/chat?token=${authToken}`;
+    const wsUrl = `${getWebSocketUrl()}/ws/chat`;
     console.log('Connecting to WebSocket:', wsUrl);
 
-    const ws = new WebSocket(wsUrl);
+    // Token 通过子协议传递，不出现在 URL 中
+    const ws = new WebSocket(wsUrl, ['bt1qfm', `bearer.${authToken}`]);
     wsRef.current = ws;
 
     ws.onopen = () => {
@@ -859,13 +859,13 @@ export const PlayerProvider: React.FC<{ children: ReactNode }> = ({ children })
       console.log('Adding to playlist:', playlistTrack);
 
       const requestData = {
-        trackId: playlistTrack.source === 'netease' ? 0 : Number(playlistTrack.id),
-        neteaseId: playlistTrack.source === 'netease' ? Number(playlistTrack.id) : 0,
+        source: playlistTrack.source === 'netease' ? 'netease' : 'local',
+        sourceId: String(playlistTrack.id),
         title: playlistTrack.title,
         artist: playlistTrack.artist || '',
         album: playlistTrack.album || '',
-        coverArtPath: playlistTrack.coverArtPath,
-        hlsPlaylistUrl: playlistTrack.hlsPlaylistUrl
+        cover: playlistTrack.coverArtPath,
+        hlsUrl: playlistTrack.hlsPlaylistUrl
       };
 
       const response = await fetch(`${backendUrl}/api/playlist`, {
@@ -523,11 +523,12 @@ export const RoomProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
     setReconnectCountdown(null);
 
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/ws/room/${roomId}?userId=${currentUser.id}&username=${encodeURIComponent(currentUser.username)}&token=${authToken}`;
+    const wsUrl = `${protocol}//${window.location.host}/ws/room/${roomId}`;
 
     console.log('正在连接 WebSocket...', reconnectAttemptsRef.current > 0 ? `(重连 #${reconnectAttemptsRef.current})` : '');
 
-    const ws = new WebSocket(wsUrl);
+    // Token 通过子协议传递，不出现在 URL 中
+    const ws = new WebSocket(wsUrl, ['bt1qfm', `bearer.${authToken}`]);
     wsRef.current = ws;
 
     ws.onopen = () => {
@@ -586,6 +587,13 @@ export const RoomProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
         closeReason = closeReason || 'network_error';
       }
 
+      // 4001-4003 为认证失败或不是房间成员，重连也无法成功
+      if (event.code >= 4001 && event.code <= 4003) {
+        setError(event.code === 4003 ? '你不是该房间的成员' : '登录已失效，请重新登录');
+        setConnectionStatus('failed');
+        return;
+      }
+
       // 非正常关闭且仍在当前房间时尝试重连
       // 1000 = 正常关闭, 1001 = 页面离开
       if (event.code !== 1000 && event.code !== 1001 && currentRoomIdRef.current === roomId && !isManualDisconnectRef.current) {